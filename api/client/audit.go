@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ExportAuditLog streams the audit log as CSV.
+// The server filters by calendar day, so From and To are sent as YYYY-MM-DD.
+// The caller must close the returned reader.
+func (c *Client) ExportAuditLog(ctx context.Context, opts *AuditExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Action != "" {
+			query.Set("action", opts.Action)
+		}
+		if opts.TableKey != "" {
+			query.Set("table", opts.TableKey)
+		}
+		if opts.Severity != "" {
+			query.Set("severity", opts.Severity)
+		}
+		if !opts.From.IsZero() {
+			query.Set("from", opts.From.Format("2006-01-02"))
+		}
+		if !opts.To.IsZero() {
+			query.Set("to", opts.To.Format("2006-01-02"))
+		}
+	}
+
+	resp, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/audit-log/export",
+		query:     query,
+		retryable: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
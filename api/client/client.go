@@ -0,0 +1,320 @@
+// Package client provides a typed Go client for the CSV import HTTP API.
+//
+// It wraps multipart uploads, Server-Sent Events progress streams, and the
+// JSON mutation endpoints so tools can integrate without hand-rolling HTTP.
+//
+// Requests that fail with a transient error (network error, 429, 502-504) are
+// retried with exponential backoff. Every POST carries an Idempotency-Key
+// header that stays the same across retries, so the server replays the first
+// response instead of applying the operation twice.
+//
+// Usage:
+//
+//	c := client.New("http://localhost:8080", client.WithAPIKey(key))
+//	id, err := c.Upload(ctx, "sfdc_customers", "customers.csv", f, nil)
+//	result, err := c.WaitForUpload(ctx, id, func(p client.Progress) {
+//	    fmt.Printf("%d%%\n", p.Percent())
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header used to deduplicate retried POSTs.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Client is an HTTP API client. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	apiKey       string
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client (default: http.DefaultClient).
+// Do not set a client-wide Timeout if you use progress streams or exports;
+// bound those calls with the context instead.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAPIKey sets the X-API-Key header sent with every request.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithRetries sets the maximum retry count and initial backoff for transient
+// failures (default: 3 retries, 200ms backoff doubled per attempt).
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// New creates a client for the server at baseURL (e.g. "http://localhost:8080").
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   http.DefaultClient,
+		maxRetries:   3,
+		retryBackoff: 200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the server responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Action     string `json:"action,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("api error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Message)
+}
+
+// NewIdempotencyKey returns a random key suitable for the Idempotency-Key header.
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is unrecoverable; fall back to a time-based key
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// request describes a single API call. body is re-created for every attempt.
+type request struct {
+	method         string
+	path           string
+	query          url.Values
+	contentType    string
+	body           func() (io.Reader, error)
+	idempotencyKey string
+	retryable      bool
+}
+
+// jsonBody returns a body factory that encodes v as JSON.
+func jsonBody(v any) func() (io.Reader, error) {
+	return func() (io.Reader, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+		return bytes.NewReader(data), nil
+	}
+}
+
+// do executes req with retries and returns the successful response.
+// The caller must close the response body.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	if req.method == http.MethodPost && req.idempotencyKey == "" {
+		req.idempotencyKey = NewIdempotencyKey()
+	}
+
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.send(ctx, req)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+		} else {
+			lastErr = decodeError(resp)
+			resp.Body.Close()
+			if !retryableStatus(resp.StatusCode) {
+				return nil, lastErr
+			}
+		}
+
+		if !req.retryable || attempt >= c.maxRetries {
+			return nil, lastErr
+		}
+	}
+}
+
+// send performs a single HTTP attempt.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		var err error
+		if body, err = req.body(); err != nil {
+			return nil, err
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, req.idempotencyKey)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// doJSON executes req and decodes a JSON response into out (if non-nil).
+func (c *Client) doJSON(ctx context.Context, req request, out any) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// backoff returns the delay before the given retry attempt (1-based).
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBackoff
+	for i := 1; i < attempt && d < 10*time.Second; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// retryableStatus reports whether a response status indicates a transient failure.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodeError builds an APIError from an error response.
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Action  string `json:"action"`
+		Code    string `json:"code"`
+	}
+	if err := json.Unmarshal(data, &payload); err == nil {
+		apiErr.Code = payload.Code
+		apiErr.Action = payload.Action
+		apiErr.Message = payload.Message
+		if apiErr.Message == "" {
+			apiErr.Message = payload.Error
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// ApprovalPendingError is returned when a reset or rollback was held for a
+// second user's approval instead of run (see APPROVAL_ROW_THRESHOLD).
+type ApprovalPendingError struct {
+	ApprovalID string
+	Rows       int64
+	Message    string
+}
+
+func (e *ApprovalPendingError) Error() string {
+	return e.Message
+}
+
+// approvalResponse is the body of a request held for approval.
+type approvalResponse struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Approval *struct {
+		ID   string `json:"id"`
+		Rows int64  `json:"rows"`
+	} `json:"approval"`
+}
+
+// err returns an *ApprovalPendingError if the request is held for
+// approval, or nil.
+func (r approvalResponse) err() error {
+	if r.Status != "pending_approval" || r.Approval == nil {
+		return nil
+	}
+	return &ApprovalPendingError{ApprovalID: r.Approval.ID, Rows: r.Approval.Rows, Message: r.Message}
+}
+
+// IsApprovalPending reports whether err is an ApprovalPendingError.
+func IsApprovalPending(err error) bool {
+	var pending *ApprovalPendingError
+	return errors.As(err, &pending)
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
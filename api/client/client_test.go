@@ -0,0 +1,328 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"id: 10\nevent: progress\ndata: {\"Inserted\":5}\n\n" +
+		"id: 20\nevent: progress\ndata: {\"Inserted\":10}\n\n" +
+		"event: complete\ndata: {}\n\n"
+
+	var events []event
+	err := readEvents(strings.NewReader(stream), func(ev event) bool {
+		events = append(events, ev)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readEvents: %v", err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+	if events[0].id != "10" || events[0].name != "progress" || events[0].data != `{"Inserted":5}` {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[2].name != "complete" {
+		t.Errorf("expected complete event, got %q", events[2].name)
+	}
+}
+
+func TestSubscribeProgress_ResumesFromLastEventID(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Drop the connection after one event
+			fmt.Fprint(w, "id: 40\nevent: progress\ndata: {\"CurrentRow\":40,\"TotalRows\":100}\n\n")
+			return
+		}
+		if got := r.URL.Query().Get("lastEventId"); got != "40" {
+			t.Errorf("expected lastEventId=40 on reconnect, got %q", got)
+		}
+		fmt.Fprint(w, "id: 100\nevent: progress\ndata: {\"CurrentRow\":100,\"TotalRows\":100}\n\n")
+		fmt.Fprint(w, "event: complete\ndata: {}\n\n")
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+	var percents []int
+	err := c.SubscribeProgress(context.Background(), "abc", func(p Progress) {
+		percents = append(percents, p.Percent())
+	})
+	if err != nil {
+		t.Fatalf("SubscribeProgress: %v", err)
+	}
+	if len(percents) != 2 || percents[0] != 40 || percents[1] != 100 {
+		t.Errorf("unexpected progress sequence: %v", percents)
+	}
+}
+
+func TestDo_RetriesWithSameIdempotencyKey(t *testing.T) {
+	var calls int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"keys":["a"]}` {
+			t.Errorf("unexpected body on attempt %d: %s", len(keys), body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"deleted":1}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(3, time.Millisecond))
+	deleted, err := c.DeleteRows(context.Background(), "t", []string{"a"})
+	if err != nil {
+		t.Fatalf("DeleteRows: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted, got %d", deleted)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Errorf("expected the same non-empty key on every attempt, got %v", keys)
+	}
+}
+
+func TestDo_NoRetryOnClientError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"bad","message":"bad input","code":"VAL001"}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(3, time.Millisecond))
+	_, err := c.UpdateCell(context.Background(), "t", "k", "c", "v")
+
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "VAL001" || apiErr.Message != "bad input" {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestUpload_StreamsMultipart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("FormFile: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "data.csv" || string(data) != "a,b\n1,2\n" {
+			t.Errorf("unexpected file %q: %q", header.Filename, data)
+		}
+		if got := r.FormValue("mapping"); got != `{"a":0}` {
+			t.Errorf("unexpected mapping %q", got)
+		}
+		if got := r.FormValue("mode"); got != "upsert" {
+			t.Errorf("unexpected mode %q", got)
+		}
+		if got := r.FormValue("duplicates"); got != "overwrite" {
+			t.Errorf("unexpected duplicates %q", got)
+		}
+		fmt.Fprint(w, `{"upload_id":"u1"}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	id, err := c.Upload(context.Background(), "t", "data.csv", strings.NewReader("a,b\n1,2\n"),
+		&UploadOptions{Mapping: map[string]int{"a": 0}, Mode: "upsert", Duplicates: "overwrite"})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if id != "u1" {
+		t.Errorf("expected upload ID u1, got %q", id)
+	}
+}
+
+func TestDryRun_SendsFlag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/preview/t" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.FormValue("dryRun"); got != "true" {
+			t.Errorf("unexpected dryRun %q", got)
+		}
+		if got := r.FormValue("mode"); got != "replace" {
+			t.Errorf("unexpected mode %q", got)
+		}
+		fmt.Fprint(w, `{"mode":"replace","summary":{"totalRows":2,"errorRows":1},"errors":[{"lineNumber":3,"reason":"bad"}]}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	report, err := c.DryRun(context.Background(), "t", "data.csv", strings.NewReader("a,b\n1,2\nx\n"),
+		&UploadOptions{Mode: "replace"})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if report.Summary.ErrorRows != 1 || len(report.Errors) != 1 || report.Errors[0].LineNumber != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestSetUploadReview(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/upload/u1/review" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("X-API-Key"); got != "reviewer-key" {
+			t.Errorf("unexpected API key %q", got)
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if body["status"] != "approved" || body["reviewer"] != "jane" || body["note"] != "March close" {
+			t.Errorf("unexpected body %v", body)
+		}
+		fmt.Fprint(w, `{"upload_id":"u1","review_status":"approved","reviewed_by":"jane"}`)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithAPIKey("reviewer-key"))
+	review, err := c.SetUploadReview(context.Background(), "u1", "approved", "jane", "March close")
+	if err != nil {
+		t.Fatalf("SetUploadReview: %v", err)
+	}
+	if review.ReviewStatus != "approved" || review.ReviewedBy != "jane" {
+		t.Errorf("unexpected review %+v", review)
+	}
+}
+
+func TestResetTable_ApprovalPending(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/reset/invoices":
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"status":"pending_approval","message":"approval required","approval":{"id":"a1","rows":5000}}`)
+		case "/api/reset/orders":
+			fmt.Fprint(w, `{"status":"reset"}`)
+		case "/api/rollback/u1":
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"status":"pending_approval","message":"approval required","approval":{"id":"a2","rows":2000}}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	err := c.ResetTable(context.Background(), "invoices")
+	var pending *ApprovalPendingError
+	if !errors.As(err, &pending) || pending.ApprovalID != "a1" || pending.Rows != 5000 {
+		t.Errorf("ResetTable(invoices) = %v, want pending approval a1", err)
+	}
+	if err := c.ResetTable(context.Background(), "orders"); err != nil {
+		t.Errorf("ResetTable(orders) = %v", err)
+	}
+	if _, err := c.RollbackUpload(context.Background(), "u1"); !IsApprovalPending(err) {
+		t.Errorf("RollbackUpload = %v, want pending approval", err)
+	}
+}
+
+func TestStartExport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/export-jobs/vendor_bills":
+			if got := r.URL.Query(); got.Get("format") != "xlsx" || got.Get("filter[Vendor]") != "eq:Acme" {
+				t.Errorf("unexpected query %v", got)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"operation_id":"op1"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/export-job/op1/download":
+			fmt.Fprint(w, "file")
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	id, err := c.StartExport(context.Background(), "vendor_bills", &ExportOptions{
+		Format:  "xlsx",
+		Filters: map[string]string{"Vendor": "eq:Acme"},
+	})
+	if err != nil || id != "op1" {
+		t.Fatalf("StartExport = %q, %v", id, err)
+	}
+
+	rc, err := c.DownloadExport(context.Background(), id)
+	if err != nil {
+		t.Fatalf("DownloadExport: %v", err)
+	}
+	defer rc.Close()
+	if b, _ := io.ReadAll(rc); string(b) != "file" {
+		t.Errorf("download = %q", b)
+	}
+}
+
+func TestTableData_FollowsCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/data/vendor_bills" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("sort") != "Amount" || q.Get("dir") != "desc" || q.Get("pageSize") != "1" {
+			t.Errorf("unexpected query %v", q)
+		}
+		switch q.Get("cursor") {
+		case "":
+			fmt.Fprint(w, `{"tableKey":"vendor_bills","rows":[{"Amount":"20"}],"page":1,"nextCursor":"c1"}`)
+		case "c1":
+			if q.Has("page") {
+				t.Error("page sent with cursor")
+			}
+			fmt.Fprint(w, `{"tableKey":"vendor_bills","rows":[{"Amount":"10"}],"cursor":"c1","nextCursor":""}`)
+		default:
+			t.Errorf("unexpected cursor %q", q.Get("cursor"))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	opts := &TableDataOptions{PageSize: 1, Sort: "Amount", Dir: "desc"}
+	var amounts []string
+	for {
+		page, err := c.TableData(context.Background(), "vendor_bills", opts)
+		if err != nil {
+			t.Fatalf("TableData: %v", err)
+		}
+		for _, row := range page.Rows {
+			amounts = append(amounts, row["Amount"])
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if got := fmt.Sprint(amounts); got != "[20 10]" {
+		t.Errorf("amounts = %s", got)
+	}
+}
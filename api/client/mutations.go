@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Mutations are sent with an Idempotency-Key and retried on transient
+// failures; the server replays the original response for a repeated key.
+
+// DeleteRows deletes rows by unique key ("val1|val2" for composite keys).
+func (c *Client) DeleteRows(ctx context.Context, tableKey string, keys []string) (int, error) {
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/delete/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body:        jsonBody(map[string][]string{"keys": keys}),
+		retryable:   true,
+	}, &resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
+
+// UpdateCell sets a single cell, identified by row key and column name.
+func (c *Client) UpdateCell(ctx context.Context, tableKey, rowKey, column, value string) (*UpdateCellResult, error) {
+	var result UpdateCellResult
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/update/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body: jsonBody(map[string]string{
+			"rowKey": rowKey,
+			"column": column,
+			"value":  value,
+		}),
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// InsertRow adds one row, with values keyed by column name. The row is
+// validated as an upload row; a rejected row is reported in the result.
+func (c *Client) InsertRow(ctx context.Context, tableKey string, values map[string]string) (*InsertRowResult, error) {
+	var result InsertRowResult
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/insert/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body:        jsonBody(map[string]map[string]string{"values": values}),
+		retryable:   true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CloneRow adds a copy of the row with key rowKey, with values replacing
+// its values; an empty value clears the column. The copy needs a new
+// unique key.
+func (c *Client) CloneRow(ctx context.Context, tableKey, rowKey string, values map[string]string) (*InsertRowResult, error) {
+	var result InsertRowResult
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/clone/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body: jsonBody(struct {
+			RowKey string            `json:"rowKey"`
+			Values map[string]string `json:"values"`
+		}{rowKey, values}),
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BulkEdit sets one column to the same value across multiple rows.
+func (c *Client) BulkEdit(ctx context.Context, tableKey string, keys []string, column, value string) (*BulkEditResult, error) {
+	var result BulkEditResult
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/bulk-edit/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body: jsonBody(struct {
+			Keys   []string `json:"keys"`
+			Column string   `json:"column"`
+			Value  string   `json:"value"`
+		}{keys, column, value}),
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BulkEditColumns applies several column edits to each of the rows at
+// once, in one transaction.
+func (c *Client) BulkEditColumns(ctx context.Context, tableKey string, keys []string, edits []BulkColumnEdit) (*BulkEditResult, error) {
+	var result BulkEditResult
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/bulk-edit/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body: jsonBody(struct {
+			Keys  []string         `json:"keys"`
+			Edits []BulkColumnEdit `json:"edits"`
+		}{keys, edits}),
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ResetTable deletes all data from a table. A reset that needs approval
+// deletes nothing and returns an *ApprovalPendingError.
+func (c *Client) ResetTable(ctx context.Context, tableKey string) error {
+	var resp approvalResponse
+	err := c.doJSON(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/reset/" + url.PathEscape(tableKey),
+		retryable: true,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	return resp.err()
+}
+
+// RollbackUpload deletes all rows inserted by an upload. A rollback that
+// needs approval deletes nothing and returns an *ApprovalPendingError.
+func (c *Client) RollbackUpload(ctx context.Context, uploadID string) (*RollbackResult, error) {
+	var result struct {
+		RollbackResult
+		approvalResponse
+	}
+	err := c.doJSON(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/rollback/" + url.PathEscape(uploadID),
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.approvalResponse.err(); err != nil {
+		return nil, err
+	}
+	return &result.RollbackResult, nil
+}
+
+// SetUploadReview moves an upload through the review workflow: status is
+// needs-review, approved or archived. Signing off may need a reviewer API
+// key (see WithAPIKey).
+func (c *Client) SetUploadReview(ctx context.Context, uploadID, status, reviewer, note string) (*UploadReview, error) {
+	var review UploadReview
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/upload/" + url.PathEscape(uploadID) + "/review",
+		contentType: "application/json",
+		body:        jsonBody(map[string]string{"status": status, "reviewer": reviewer, "note": note}),
+	}, &review)
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
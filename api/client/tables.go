@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListTables returns all importable tables organized by group.
+func (c *Client) ListTables(ctx context.Context) (map[string][]TableInfo, error) {
+	var tables map[string][]TableInfo
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/tables",
+		retryable: true,
+	}, &tables)
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// ExportTable streams table data as CSV, with the header row first, or in
+// opts.Format. The caller must close the returned reader.
+func (c *Client) ExportTable(ctx context.Context, tableKey string, opts *ExportOptions) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/export/" + url.PathEscape(tableKey),
+		query:     opts.query(),
+		retryable: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// StartExport exports table data in the background and returns the
+// operation ID. Once the operation completes, DownloadExport returns the
+// file.
+func (c *Client) StartExport(ctx context.Context, tableKey string, opts *ExportOptions) (string, error) {
+	var resp struct {
+		OperationID string `json:"operation_id"`
+	}
+	err := c.doJSON(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/export-jobs/" + url.PathEscape(tableKey),
+		query:     opts.query(),
+		retryable: true,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.OperationID, nil
+}
+
+// DownloadExport returns the file written by a background export. The
+// caller must close the returned reader.
+func (c *Client) DownloadExport(ctx context.Context, operationID string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/export-job/" + url.PathEscape(operationID) + "/download",
+		retryable: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// TableData returns a page of table data. opts may be nil for the first
+// page in the default order; pass the result's NextCursor as opts.Cursor to
+// read the next one.
+func (c *Client) TableData(ctx context.Context, tableKey string, opts *TableDataOptions) (*TablePage, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Cursor != "" {
+			query.Set("cursor", opts.Cursor)
+		} else if opts.Page > 0 {
+			query.Set("page", strconv.Itoa(opts.Page))
+		}
+		if opts.PageSize > 0 {
+			query.Set("pageSize", strconv.Itoa(opts.PageSize))
+		}
+		if opts.Sort != "" {
+			query.Set("sort", opts.Sort)
+			query.Set("dir", opts.Dir)
+		}
+		if opts.Search != "" {
+			query.Set("search", opts.Search)
+		}
+		for col, filter := range opts.Filters {
+			query.Add("filter["+col+"]", filter)
+		}
+	}
+
+	var page TablePage
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/data/" + url.PathEscape(tableKey),
+		query:     query,
+		retryable: true,
+	}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// query returns the export query parameters for opts, which may be nil.
+func (opts *ExportOptions) query() url.Values {
+	query := url.Values{}
+	if opts == nil {
+		return query
+	}
+	if opts.Search != "" {
+		query.Set("search", opts.Search)
+	}
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if opts.Batch > 0 {
+		query.Set("batch", strconv.Itoa(opts.Batch))
+	}
+	if opts.OnConflict != "" {
+		query.Set("on_conflict", opts.OnConflict)
+	}
+	if opts.Watermark {
+		query.Set("watermark", "true")
+	}
+	for col, filter := range opts.Filters {
+		query.Add("filter["+col+"]", filter)
+	}
+	return query
+}
+
+// CheckDuplicates returns the subset of keys that already exist in the table.
+func (c *Client) CheckDuplicates(ctx context.Context, tableKey string, keys []string) ([]string, error) {
+	var resp struct {
+		Existing []string `json:"existing"`
+	}
+	err := c.doJSON(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/check-duplicates/" + url.PathEscape(tableKey),
+		contentType: "application/json",
+		body:        jsonBody(map[string][]string{"keys": keys}),
+		retryable:   true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Existing, nil
+}
+
+// KeyViolations returns the unique key violations of the table's last limit
+// uploads; 0 uses the server's alert window.
+func (c *Client) KeyViolations(ctx context.Context, tableKey string, limit int) (*KeyViolationTrend, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var trend KeyViolationTrend
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/tables/" + url.PathEscape(tableKey) + "/key-violations",
+		query:     query,
+		retryable: true,
+	}, &trend)
+	if err != nil {
+		return nil, err
+	}
+	return &trend, nil
+}
+
+// DataQuality returns the column-level data quality report of the table.
+func (c *Client) DataQuality(ctx context.Context, tableKey string) (*DataQualityReport, error) {
+	var report DataQualityReport
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/quality/" + url.PathEscape(tableKey),
+		retryable: true,
+	}, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListUploadReviews returns uploads with their review status, newest
+// first. tableKey and review (needs-review, approved, archived or none)
+// filter the list when not empty.
+func (c *Client) ListUploadReviews(ctx context.Context, tableKey, review string) ([]UploadReview, error) {
+	query := url.Values{}
+	if tableKey != "" {
+		query.Set("table", tableKey)
+	}
+	if review != "" {
+		query.Set("review", review)
+	}
+
+	var reviews []UploadReview
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/uploads/reviews",
+		query:     query,
+		retryable: true,
+	}, &reviews)
+	if err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
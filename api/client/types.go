@@ -0,0 +1,369 @@
+package client
+
+import "time"
+
+// Upload phases reported in Progress.Phase.
+const (
+	PhaseStarting   = "starting"
+	PhaseReading    = "reading"
+	PhaseValidating = "validating"
+	PhaseInserting  = "inserting"
+	PhaseComplete   = "complete"
+	PhaseFailed     = "failed"
+	PhaseCancelled  = "cancelled"
+)
+
+// TableInfo describes an importable table.
+type TableInfo struct {
+	Key       string
+	Group     string
+	Label     string
+	Directory string
+	Columns   []string
+	UniqueKey []string
+	ReadOnly  bool // A view: readable and exportable, but uploads are rejected
+}
+
+// Progress is a single upload progress event.
+type Progress struct {
+	UploadID   string
+	TableKey   string
+	Phase      string
+	FileName   string
+	TotalRows  int
+	CurrentRow int
+	Inserted   int
+	Skipped    int
+	Error      string
+	BytesRead  int64
+	BytesTotal int64
+}
+
+// Percent returns the progress as a percentage (0-100).
+// Uses row-based progress if TotalRows is known, otherwise byte-based.
+func (p Progress) Percent() int {
+	if p.TotalRows > 0 {
+		return (p.CurrentRow * 100) / p.TotalRows
+	}
+	if p.BytesTotal > 0 {
+		return int((p.BytesRead * 100) / p.BytesTotal)
+	}
+	return 0
+}
+
+// FailedRow is a CSV row that could not be inserted.
+type FailedRow struct {
+	FileName   string
+	LineNumber int
+	Reason     string
+	Data       []string
+}
+
+// UploadResult is the final outcome of an upload.
+type UploadResult struct {
+	UploadID     string        `json:"upload_id"`
+	TableKey     string        `json:"table_key"`
+	FileName     string        `json:"file_name"`
+	TotalRows    int           `json:"total_rows"`
+	Mode         string        `json:"mode"`
+	Inserted     int           `json:"inserted"`
+	Updated      int           `json:"updated"`
+	Replaced     int           `json:"replaced"`
+	Deleted      int           `json:"deleted"`
+	Skipped      int           `json:"skipped"`
+	Duplicates   string        `json:"duplicates"`
+	DupSkipped   int           `json:"duplicates_skipped"`
+	DupInFile    int           `json:"duplicates_in_file"`
+	FailedRows   []FailedRow   `json:"failed_rows,omitempty"`
+	Retries      int           `json:"retries"`
+	Duration     string        `json:"duration"`
+	DateWarnings []DateWarning `json:"date_warnings,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// DateWarning flags a date column whose dates may not have been read as
+// meant. Kind is "ambiguous_day_month" for numeric dates, such as
+// 01/02/2024, that may have been read month first in a day-first file, or
+// "two_digit_year" for 2-digit years given a century by the year pivot.
+type DateWarning struct {
+	Kind       string `json:"kind"`
+	Column     string `json:"column"`
+	Checked    int    `json:"checked"`
+	Ambiguous  int    `json:"ambiguous"`
+	DayFirst   int    `json:"dayFirst"`
+	MonthFirst int    `json:"monthFirst"`
+	Example    string `json:"example"`
+	Message    string `json:"message"`
+
+	TwoDigitYears int `json:"twoDigitYears,omitempty"`
+	YearPivot     int `json:"yearPivot,omitempty"`
+	FirstYear     int `json:"firstYear,omitempty"`
+	LastYear      int `json:"lastYear,omitempty"`
+}
+
+// PreviewSummary contains the summary counts for an upload preview.
+type PreviewSummary struct {
+	TotalRows       int `json:"totalRows"`
+	NewRows         int `json:"newRows"`
+	UpdateRows      int `json:"updateRows"`
+	ErrorRows       int `json:"errorRows"`
+	DuplicateInFile int `json:"duplicateInFile"`
+	UnchangedRows   int `json:"unchangedRows"` // Upsert only: updates that change no value
+}
+
+// RowPreview is a sample row that would be inserted.
+type RowPreview struct {
+	LineNumber int               `json:"lineNumber"`
+	RowKey     string            `json:"rowKey"`
+	Values     map[string]string `json:"values"`
+}
+
+// UpdateDiff is a before/after diff for a row whose key already exists.
+type UpdateDiff struct {
+	LineNumber int               `json:"lineNumber"`
+	RowKey     string            `json:"rowKey"`
+	Current    map[string]string `json:"current"`
+	Incoming   map[string]string `json:"incoming"`
+	Changed    []string          `json:"changed"`
+}
+
+// ErrorPreview is a sample row with validation errors.
+type ErrorPreview struct {
+	LineNumber int               `json:"lineNumber"`
+	RowKey     string            `json:"rowKey,omitempty"`
+	Values     map[string]string `json:"values"`
+	Errors     []string          `json:"errors"`
+}
+
+// DuplicatePreview is a key that appears on multiple lines of the file.
+type DuplicatePreview struct {
+	RowKey      string `json:"rowKey"`
+	LineNumbers []int  `json:"lineNumbers"`
+}
+
+// ColumnChange counts the matched rows whose value for Column an upsert
+// would change. Cleared counts those whose current value would be emptied.
+type ColumnChange struct {
+	Column  string        `json:"column"`
+	Changed int           `json:"changed"`
+	Cleared int           `json:"cleared"`
+	Samples []ValueChange `json:"samples"`
+}
+
+// ValueChange is one changed value in a ColumnChange.
+type ValueChange struct {
+	LineNumber int    `json:"lineNumber"`
+	RowKey     string `json:"rowKey"`
+	Current    string `json:"current"`
+	Incoming   string `json:"incoming"`
+}
+
+// Preview is the read-only analysis of a CSV file before upload.
+type Preview struct {
+	Mode             string             `json:"mode"`
+	Summary          PreviewSummary     `json:"summary"`
+	NewRowSamples    []RowPreview       `json:"newRowSamples"`
+	UpdateDiffs      []UpdateDiff       `json:"updateDiffs"`
+	ErrorSamples     []ErrorPreview     `json:"errorSamples"`
+	DuplicateSamples []DuplicatePreview `json:"duplicateSamples"`
+	ColumnChanges    []ColumnChange     `json:"columnChanges,omitempty"` // Upsert only
+	DateWarnings     []DateWarning      `json:"dateWarnings,omitempty"`
+	ProcessingTimeMs int64              `json:"processingTimeMs"`
+}
+
+// DryRunSummary contains the counts a real upload of the file would produce.
+type DryRunSummary struct {
+	TotalRows       int `json:"totalRows"`
+	Inserted        int `json:"inserted"`
+	Updated         int `json:"updated"`
+	Replaced        int `json:"replaced"`
+	Deleted         int `json:"deleted"`
+	ErrorRows       int `json:"errorRows"`
+	DuplicateInFile int `json:"duplicateInFile"`
+	ExistingRows    int `json:"existingRows"`
+}
+
+// DryRunRowError is a row that a real upload would skip.
+type DryRunRowError struct {
+	LineNumber int      `json:"lineNumber"`
+	Reason     string   `json:"reason"`
+	Values     []string `json:"values"`
+}
+
+// DryRunExistingRow is a valid row whose unique key is already in the table.
+type DryRunExistingRow struct {
+	LineNumber int    `json:"lineNumber"`
+	RowKey     string `json:"rowKey"`
+}
+
+// DryRunReport is the complete result of a dry-run upload.
+type DryRunReport struct {
+	TableKey         string              `json:"tableKey"`
+	Mode             string              `json:"mode"`
+	DateWarnings     []DateWarning       `json:"dateWarnings,omitempty"`
+	Summary          DryRunSummary       `json:"summary"`
+	Errors           []DryRunRowError    `json:"errors"`
+	Duplicates       []DuplicatePreview  `json:"duplicates"`
+	Existing         []DryRunExistingRow `json:"existing"`
+	ProcessingTimeMs int64               `json:"processingTimeMs"`
+}
+
+// UpdateCellResult is the outcome of a single cell update.
+type UpdateCellResult struct {
+	Success         bool   `json:"success"`
+	DuplicateKey    bool   `json:"duplicateKey,omitempty"`
+	ConflictingKey  string `json:"conflictingKey,omitempty"`
+	ValidationError string `json:"validationError,omitempty"`
+}
+
+// InsertRowResult is the outcome of a row insert. FieldErrors maps each
+// invalid column to why its value was rejected.
+type InsertRowResult struct {
+	Success         bool              `json:"success"`
+	RowKey          string            `json:"rowKey,omitempty"`
+	FieldErrors     map[string]string `json:"fieldErrors,omitempty"`
+	ValidationError string            `json:"validationError,omitempty"`
+	DuplicateKey    bool              `json:"duplicateKey,omitempty"`
+}
+
+// BulkColumnEdit is how BulkEditColumns changes one column. Mode is set
+// (default), replace, adjust, trim, upper or lower; Find is the text
+// replace replaces.
+type BulkColumnEdit struct {
+	Column string `json:"column"`
+	Mode   string `json:"mode,omitempty"`
+	Find   string `json:"find,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+// BulkEditResult is the outcome of a bulk column edit.
+type BulkEditResult struct {
+	Updated int      `json:"updated"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// RollbackResult is the outcome of rolling back an upload.
+type RollbackResult struct {
+	UploadID    string `json:"uploadId"`
+	OperationID string `json:"operationId,omitempty"`
+	TableKey    string `json:"tableKey"`
+	RowsDeleted int64  `json:"rowsDeleted"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// KeyViolationPoint is one upload in a table's key violation trend.
+type KeyViolationPoint struct {
+	UploadID   string    `json:"upload_id"`
+	FileName   string    `json:"file_name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Rows       int       `json:"rows"`
+	Violations int       `json:"violations"`
+	Percent    float64   `json:"percent"`
+}
+
+// KeyViolationTrend is the unique key violations of a table's recent
+// uploads, oldest first.
+type KeyViolationTrend struct {
+	TableKey  string              `json:"table_key"`
+	Uploads   []KeyViolationPoint `json:"uploads"`
+	Percent   float64             `json:"percent"`
+	Threshold int                 `json:"threshold"` // Alert percent; 0 if alerts are off
+	Alerting  bool                `json:"alerting"`
+}
+
+// DataQualityReport profiles every column of a table.
+type DataQualityReport struct {
+	TableKey string          `json:"tableKey"`
+	Rows     int64           `json:"rows"`
+	Columns  []ColumnQuality `json:"columns"`
+}
+
+// ColumnQuality profiles one column. Fields for other column types are
+// zero.
+type ColumnQuality struct {
+	Column   string  `json:"column"`
+	Type     string  `json:"type"` // text, enum, date, numeric or bool
+	Empty    int64   `json:"empty"`
+	NullRate float64 `json:"nullRate"` // 0 to 1
+	Distinct int64   `json:"distinct"`
+
+	MinLength     *int  `json:"minLength,omitempty"` // Text and enum
+	MaxLength     *int  `json:"maxLength,omitempty"`
+	UnknownValues int64 `json:"unknownValues,omitempty"` // Enum values not allowed
+
+	MinDate     string `json:"minDate,omitempty"` // Dates, as 2024-01-31
+	MaxDate     string `json:"maxDate,omitempty"`
+	FutureDates int64  `json:"futureDates,omitempty"`
+
+	LowerFence *float64 `json:"lowerFence,omitempty"` // Numbers outside the fences are outliers
+	UpperFence *float64 `json:"upperFence,omitempty"`
+	Outliers   int64    `json:"outliers,omitempty"`
+
+	Flags []string `json:"flags,omitempty"` // e.g. missing_required, outliers
+}
+
+// UploadReview is an upload's place in the review workflow.
+type UploadReview struct {
+	UploadID     string     `json:"upload_id"`
+	TableKey     string     `json:"table_key"`
+	FileName     string     `json:"file_name"`
+	UploadedAt   time.Time  `json:"uploaded_at"`
+	Status       string     `json:"status"`        // active or rolled_back
+	ReviewStatus string     `json:"review_status"` // Empty until first reviewed
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"`
+	ReviewNote   string     `json:"review_note,omitempty"`
+}
+
+// ExportOptions filters a table export. Filters map a column name to
+// "operator:value", the same format as the table view's filter[col] params.
+type ExportOptions struct {
+	Search  string
+	Filters map[string]string
+	Format  string // csv (default), json, xlsx or sql
+
+	// Watermark adds a traceable export ID to the file; tables may
+	// watermark every export regardless
+	Watermark bool
+
+	// For the sql format
+	Batch      int    // Rows per INSERT (default 100)
+	OnConflict string // nothing or update; empty inserts every row
+}
+
+// TableDataOptions selects a page of table data. Sort and Dir are
+// comma-separated, as the table view's sort and dir params. Cursor, a
+// previous page's NextCursor with the same Sort, takes precedence over Page.
+type TableDataOptions struct {
+	Page     int
+	PageSize int
+	Cursor   string
+	Sort     string
+	Dir      string
+	Search   string
+	Filters  map[string]string
+}
+
+// TablePage is a page of table data. Cells are formatted as in a CSV export.
+type TablePage struct {
+	TableKey   string              `json:"tableKey"`
+	Columns    []string            `json:"columns"`
+	Rows       []map[string]string `json:"rows"`
+	TotalRows  int64               `json:"totalRows"`
+	Page       int                 `json:"page"` // 0 for a page read by cursor
+	PageSize   int                 `json:"pageSize"`
+	TotalPages int                 `json:"totalPages"`
+	Cursor     string              `json:"cursor"`
+	NextCursor string              `json:"nextCursor"` // Empty on the last page
+}
+
+// AuditExportOptions filters an audit log export.
+type AuditExportOptions struct {
+	Action   string
+	TableKey string
+	Severity string
+	From     time.Time // Zero means unbounded
+	To       time.Time // Zero means unbounded
+}
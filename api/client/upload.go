@@ -0,0 +1,397 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UploadOptions are optional settings for Upload and Preview.
+type UploadOptions struct {
+	// Mapping maps database column names to CSV column indexes.
+	Mapping map[string]int
+
+	// Constants fills columns with a value instead of a CSV column, e.g.
+	// {"Source": "SFDC"}. "=today" and "=now" are the date and time the
+	// upload starts. Requires Mapping for the file's columns.
+	Constants map[string]string
+
+	// Mode is "insert", "upsert", "replace" or "delete"; empty uses the
+	// table default. A delete upload removes the rows whose unique key the
+	// file lists.
+	// Ignored by Preview; used by Upload and DryRun.
+	Mode string
+
+	// Duplicates is "skip", "overwrite", "fail-upload", or "keep-both"; empty
+	// uses the mode's default. Requires a table with a unique key unless
+	// keep-both. Ignored by Preview and DryRun.
+	Duplicates string
+
+	// DateFormat is "mdy" or "dmy" for how numeric dates such as 01/02/2024
+	// are read; empty reads them month first and warns about columns that
+	// look day first.
+	DateFormat string
+
+	// YearPivot sets how 2-digit years are read: years more than this many
+	// years ahead are taken as the previous century. 0 uses the table's.
+	YearPivot int
+
+	// Columns limits the upload to some of the table's columns, such as a
+	// key plus one new attribute; the file only needs these. Columns left
+	// out are NULL, or keep the replaced rows' values in upsert mode.
+	// Ignored by Preview; used by Upload and DryRun.
+	Columns []string
+
+	// IdempotencyKey overrides the generated key, e.g. to make a retry of a
+	// whole job (not just one HTTP attempt) return the original upload ID.
+	IdempotencyKey string
+}
+
+// Upload streams a CSV file to the server and returns the upload ID.
+// The file is sent with a streaming multipart body, so memory use does not
+// grow with file size. Processing continues server-side; use
+// SubscribeProgress or WaitForUpload to follow it.
+//
+// The request is retried only if r implements io.Seeker, since a consumed
+// stream cannot be re-sent.
+func (c *Client) Upload(ctx context.Context, tableKey, fileName string, r io.Reader, opts *UploadOptions) (string, error) {
+	req, err := multipartRequest("/api/upload/"+url.PathEscape(tableKey), fileName, r, opts, false)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		UploadID string `json:"upload_id"`
+	}
+	if err := c.doJSON(ctx, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.UploadID, nil
+}
+
+// Preview analyzes a CSV file without importing it. With an upsert mode
+// (opts.Mode, or the table's default), every update is compared with the
+// row it would replace and counted per column in ColumnChanges.
+func (c *Client) Preview(ctx context.Context, tableKey, fileName string, r io.Reader, opts *UploadOptions) (*Preview, error) {
+	req, err := multipartRequest("/api/preview/"+url.PathEscape(tableKey), fileName, r, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var preview Preview
+	if err := c.doJSON(ctx, req, &preview); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// DryRun runs the full upload pipeline on the server in a transaction that
+// is rolled back, and returns every row that would fail. Nothing is written.
+func (c *Client) DryRun(ctx context.Context, tableKey, fileName string, r io.Reader, opts *UploadOptions) (*DryRunReport, error) {
+	req, err := multipartRequest("/api/preview/"+url.PathEscape(tableKey), fileName, r, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var report DryRunReport
+	if err := c.doJSON(ctx, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// multipartRequest builds a POST whose body streams r as the "file" field.
+func multipartRequest(path, fileName string, r io.Reader, opts *UploadOptions, dryRun bool) (request, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+
+	var mappingJSON []byte
+	if len(opts.Mapping) > 0 || len(opts.Constants) > 0 {
+		// The server reads string entries as constants
+		mapping := make(map[string]any, len(opts.Mapping)+len(opts.Constants))
+		for col, idx := range opts.Mapping {
+			mapping[col] = idx
+		}
+		for col, value := range opts.Constants {
+			mapping[col] = value
+		}
+		var err error
+		if mappingJSON, err = json.Marshal(mapping); err != nil {
+			return request{}, fmt.Errorf("encode mapping: %w", err)
+		}
+	}
+
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	var prevWriter chan struct{}
+
+	body := func() (io.Reader, error) {
+		if prevWriter != nil {
+			// The transport closed the previous pipe; wait for its writer
+			// to stop reading r before rewinding it.
+			<-prevWriter
+			if !seekable {
+				return nil, fmt.Errorf("upload body is not seekable and cannot be re-sent")
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("rewind upload body: %w", err)
+			}
+		}
+		done := make(chan struct{})
+		prevWriter = done
+
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+
+		go func() {
+			defer close(done)
+			part, err := mw.CreateFormFile("file", fileName)
+			if err == nil {
+				_, err = io.Copy(part, r)
+			}
+			if err == nil && mappingJSON != nil {
+				err = mw.WriteField("mapping", string(mappingJSON))
+			}
+			if err == nil && opts.Mode != "" {
+				err = mw.WriteField("mode", opts.Mode)
+			}
+			if err == nil && opts.Duplicates != "" {
+				err = mw.WriteField("duplicates", opts.Duplicates)
+			}
+			if err == nil && opts.DateFormat != "" {
+				err = mw.WriteField("date_format", opts.DateFormat)
+			}
+			if err == nil && opts.YearPivot != 0 {
+				err = mw.WriteField("year_pivot", strconv.Itoa(opts.YearPivot))
+			}
+			if err == nil && len(opts.Columns) > 0 {
+				err = mw.WriteField("columns", strings.Join(opts.Columns, ","))
+			}
+			if err == nil && dryRun {
+				err = mw.WriteField("dryRun", "true")
+			}
+			if err == nil {
+				err = mw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	return request{
+		method:         http.MethodPost,
+		path:           path,
+		contentType:    "multipart/form-data; boundary=" + boundary,
+		body:           body,
+		idempotencyKey: opts.IdempotencyKey,
+		retryable:      seekable,
+	}, nil
+}
+
+// SubscribeProgress streams progress events for an upload, calling fn for
+// each one, until the server reports completion or ctx is cancelled.
+// Dropped connections are resumed from the last received event ID.
+// Returns nil when the stream completes normally.
+func (c *Client) SubscribeProgress(ctx context.Context, uploadID string, fn func(Progress)) error {
+	lastEventID := ""
+	failures := 0
+
+	for {
+		done, err := c.streamProgress(ctx, uploadID, &lastEventID, fn)
+		if done {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if IsNotFound(err) {
+			// Upload finished and was cleaned up, or never existed
+			return err
+		}
+
+		failures++
+		if failures > c.maxRetries {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("progress stream: %w", err)
+		}
+		if err := sleep(ctx, c.backoff(failures)); err != nil {
+			return err
+		}
+	}
+}
+
+// streamProgress reads one SSE connection. done is true once the server
+// sends the "complete" event.
+func (c *Client) streamProgress(ctx context.Context, uploadID string, lastEventID *string, fn func(Progress)) (bool, error) {
+	query := url.Values{}
+	if *lastEventID != "" {
+		query.Set("lastEventId", *lastEventID)
+	}
+
+	resp, err := c.send(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/upload/" + url.PathEscape(uploadID) + "/progress",
+		query:  query,
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return false, decodeError(resp)
+	}
+
+	done := false
+	err = readEvents(resp.Body, func(ev event) bool {
+		switch ev.name {
+		case "complete":
+			done = true
+			return false
+		case "progress":
+			var p Progress
+			if err := json.Unmarshal([]byte(ev.data), &p); err != nil {
+				return true
+			}
+			if ev.id != "" {
+				*lastEventID = ev.id
+			}
+			fn(p)
+		}
+		return true
+	})
+	return done, err
+}
+
+// event is a single Server-Sent Event.
+type event struct {
+	id   string
+	name string
+	data string
+}
+
+// readEvents parses an SSE stream, calling fn per event until fn returns
+// false or the stream ends.
+func readEvents(r io.Reader, fn func(event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	var ev event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 || ev.name != "" {
+				ev.data = strings.Join(data, "\n")
+				if ev.name == "" {
+					ev.name = "message"
+				}
+				if !fn(ev) {
+					return nil
+				}
+			}
+			ev = event{}
+			data = data[:0]
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment / keepalive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			ev.id = value
+		case "event":
+			ev.name = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// GetUploadResult returns the final result of an upload.
+func (c *Client) GetUploadResult(ctx context.Context, uploadID string) (*UploadResult, error) {
+	var result UploadResult
+	err := c.doJSON(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/upload/" + url.PathEscape(uploadID) + "/result",
+		retryable: true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// WaitForUpload follows an upload's progress (calling fn if non-nil) and
+// returns its final result.
+func (c *Client) WaitForUpload(ctx context.Context, uploadID string, fn func(Progress)) (*UploadResult, error) {
+	if fn == nil {
+		fn = func(Progress) {}
+	}
+	if err := c.SubscribeProgress(ctx, uploadID, fn); err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	return c.GetUploadResult(ctx, uploadID)
+}
+
+// CancelUpload cancels an in-progress upload.
+func (c *Client) CancelUpload(ctx context.Context, uploadID string) error {
+	return c.doJSON(ctx, request{
+		method:    http.MethodPost,
+		path:      "/api/upload/" + url.PathEscape(uploadID) + "/cancel",
+		retryable: true,
+	}, nil)
+}
+
+// ExportFailedRows streams the failed rows of an upload as CSV.
+// The caller must close the returned reader.
+func (c *Client) ExportFailedRows(ctx context.Context, uploadID string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/upload/" + url.PathEscape(uploadID) + "/failed-rows",
+		retryable: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// UploadEvidence streams an upload's evidence package as a zip file.
+// The caller must close the returned reader.
+func (c *Client) UploadEvidence(ctx context.Context, uploadID string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{
+		method:    http.MethodGet,
+		path:      "/api/upload/" + url.PathEscape(uploadID) + "/evidence",
+		retryable: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,117 @@
+// Command proposetemplates proposes import templates from the header rows of
+// a table's past uploads, and can save them.
+//
+//	go run ./cmd/proposetemplates -table ns_invoices
+//	go run ./cmd/proposetemplates -table ns_invoices -min-uploads 5 -create
+//	go run ./cmd/proposetemplates -table ns_invoices -create -signature 3f2a9c41d0b7e815
+//
+// It reads the same configuration as the server (.env, DATABASE_URL).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+	"github.com/JonMunkholm/TUI/internal/core"
+	_ "github.com/JonMunkholm/TUI/internal/core/tables" // Register all tables
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	table := flag.String("table", "", "table key (required)")
+	minUploads := flag.Int("min-uploads", core.DefaultProposalMinUploads, "uploads that must share a header row")
+	create := flag.Bool("create", false, "save the proposals as import templates")
+	signatures := flag.String("signature", "", "comma-separated proposals to save with -create (default: all)")
+	flag.Parse()
+
+	if *table == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*table, *minUploads, *create, *signatures); err != nil {
+		slog.Error("template proposal failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(table string, minUploads int, create bool, signatures string) error {
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load configuration: %w", err)
+	}
+	if err := core.RegisterTableConfigFiles(cfg.Server.TableConfigFiles...); err != nil {
+		return fmt.Errorf("load table config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if _, err := config.ResolveSecrets(ctx, cfg); err != nil {
+		return fmt.Errorf("resolve secrets: %w", err)
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		return fmt.Errorf("parse database URL: %w", err)
+	}
+	if cfg.Database.Password != "" {
+		poolConfig.ConnConfig.Password = cfg.Database.Password
+	}
+	core.ConfigurePooling(poolConfig.ConnConfig, cfg.Database.TransactionPooling)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	service, err := core.NewService(pool, cfg)
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+
+	if create {
+		var sigs []string
+		if signatures != "" {
+			sigs = strings.Split(signatures, ",")
+		}
+		templates, err := service.CreateProposedTemplates(ctx, table, sigs, minUploads)
+		for _, t := range templates {
+			fmt.Printf("created template %q (%d columns)\n", t.Name, len(t.ColumnMapping))
+		}
+		if err != nil {
+			return err
+		}
+		if len(templates) == 0 {
+			fmt.Println("no new templates to create")
+		}
+		return nil
+	}
+
+	proposals, err := service.ProposeTemplates(ctx, table, minUploads)
+	if err != nil {
+		return err
+	}
+	if len(proposals) == 0 {
+		fmt.Printf("no header row was used in %d or more uploads\n", minUploads)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIGNATURE\tNAME\tUPLOADS\tLAST UPLOAD\tUNMAPPED\tEXISTING")
+	for _, p := range proposals {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", p.Signature, p.Name, p.Uploads,
+			p.LastUploadAt.Format("2006-01-02"), strings.Join(p.Unmapped, ", "), p.ExistingTemplate)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,135 @@
+// Command schemamigrate writes a goose migration that brings the database in
+// line with the table registry: columns declared in TableDefinition.Renames
+// are renamed, new FieldSpecs added, DroppedColumns dropped, and import
+// templates rewritten to use current column names.
+//
+//	go run ./cmd/schemamigrate -name rename_customer_columns
+//	go run ./cmd/schemamigrate -table sfdc_customers -dry-run
+//
+// It reads the same configuration as the server (.env, DATABASE_URL).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+	"github.com/JonMunkholm/TUI/internal/core"
+	_ "github.com/JonMunkholm/TUI/internal/core/tables" // Register all tables
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	name := flag.String("name", "schema_evolution", "migration name, used in the file name")
+	dir := flag.String("dir", "sql/schema", "migrations directory")
+	tables := flag.String("table", "", "comma-separated table keys (default: all tables)")
+	dryRun := flag.Bool("dry-run", false, "print the migration instead of writing it")
+	flag.Parse()
+
+	if err := run(*name, *dir, *tables, *dryRun); err != nil {
+		slog.Error("schema migration failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(name, dir, tables string, dryRun bool) error {
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load configuration: %w", err)
+	}
+	if err := core.RegisterTableConfigFiles(cfg.Server.TableConfigFiles...); err != nil {
+		return fmt.Errorf("load table config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if _, err := config.ResolveSecrets(ctx, cfg); err != nil {
+		return fmt.Errorf("resolve secrets: %w", err)
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.Database.URL)
+	if err != nil {
+		return fmt.Errorf("parse database URL: %w", err)
+	}
+	if cfg.Database.Password != "" {
+		poolConfig.ConnConfig.Password = cfg.Database.Password
+	}
+	core.ConfigurePooling(poolConfig.ConnConfig, cfg.Database.TransactionPooling)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer pool.Close()
+
+	service, err := core.NewService(pool, cfg)
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+
+	var keys []string
+	if tables != "" {
+		keys = strings.Split(tables, ",")
+	}
+	m, err := service.GenerateSchemaMigration(ctx, keys...)
+	if err != nil {
+		return err
+	}
+	if m.Empty() {
+		fmt.Println("database matches the registry; no migration needed")
+		return nil
+	}
+	if dryRun {
+		fmt.Print(m.SQL())
+		return nil
+	}
+
+	path, err := nextMigrationPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(m.SQL()), 0o644); err != nil {
+		return fmt.Errorf("write migration: %w", err)
+	}
+	fmt.Println("wrote", path)
+	for _, w := range m.Warnings {
+		fmt.Println("warning:", w)
+	}
+	return nil
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+
+// nextMigrationPath returns dir/NNN_name.sql, numbered after the highest
+// existing migration.
+func nextMigrationPath(dir, name string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("read migrations directory: %w", err)
+	}
+
+	last := 0
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && n > last {
+			last = n
+		}
+	}
+
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	return filepath.Join(dir, fmt.Sprintf("%03d_%s.sql", last+1, name)), nil
+}
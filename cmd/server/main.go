@@ -8,12 +8,14 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/JonMunkholm/TUI/internal/config"
 	"github.com/JonMunkholm/TUI/internal/core"
 	_ "github.com/JonMunkholm/TUI/internal/core/tables" // Register all tables
 	"github.com/JonMunkholm/TUI/internal/logging"
 	"github.com/JonMunkholm/TUI/internal/web"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 )
@@ -36,9 +38,28 @@ func main() {
 	// Setup structured logging based on config
 	logging.Setup(cfg.Logging.Level, cfg.Logging.Format)
 
+	// Tables declared in schema files join the ones registered in code
+	if err := core.RegisterTableConfigFiles(cfg.Server.TableConfigFiles...); err != nil {
+		slog.Error("failed to load table config", "error", err)
+		os.Exit(1)
+	}
+
+	// Resolve secret references (vault://, awssm://, gcpsm://, file://)
+	secretsCtx, cancelSecrets := context.WithTimeout(context.Background(), 30*time.Second)
+	secrets, err := config.ResolveSecrets(secretsCtx, cfg)
+	cancelSecrets()
+	if err != nil {
+		slog.Error("failed to resolve secrets", "error", err)
+		os.Exit(1)
+	}
+	if secrets.Managed("API_KEYS") {
+		cfg.Security.APIKeyProvider = secrets.APIKeys
+	}
+
 	slog.Info("configuration loaded",
 		"port", cfg.Server.Port,
 		"db_max_conns", cfg.Database.MaxConns,
+		"db_transaction_pooling", cfg.Database.TransactionPooling,
 		"upload_max_concurrent", cfg.Upload.MaxConcurrent,
 		"rate_limit_enabled", cfg.Rate.Enabled,
 	)
@@ -56,6 +77,23 @@ func main() {
 	poolConfig.MaxConnLifetime = cfg.Database.MaxConnLifetime
 	poolConfig.MaxConnIdleTime = cfg.Database.MaxConnIdleTime
 
+	// Cancel statements on the server when their context is cancelled
+	core.ConfigureCancellation(poolConfig.ConnConfig, cfg.Database.CancelGrace)
+
+	// Keep no prepared statements on connections behind a transaction pooler
+	core.ConfigurePooling(poolConfig.ConnConfig, cfg.Database.TransactionPooling)
+
+	// Apply the DB password override; new connections pick up rotated passwords
+	if cfg.Database.Password != "" {
+		poolConfig.ConnConfig.Password = cfg.Database.Password
+	}
+	poolConfig.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if pw, ok := secrets.DatabasePassword(); ok {
+			cc.Password = pw
+		}
+		return nil
+	}
+
 	// Connect to database
 	ctx := context.Background()
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
@@ -86,6 +124,46 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Operations still recorded as running were cut off by the last shutdown
+	if n, err := service.MarkInterruptedOperations(ctx); err != nil {
+		slog.Warn("failed to mark interrupted operations", "error", err)
+	} else if n > 0 {
+		slog.Info("marked interrupted operations as failed", "count", n)
+	}
+
+	// Declared tables are created from their schema files, not migrations
+	if err := service.SyncConfigTables(ctx); err != nil {
+		slog.Error("failed to create declared tables", "error", err)
+		os.Exit(1)
+	}
+
+	// Views behind read-only tables are defined in the registry, not migrations
+	if err := service.SyncViews(ctx); err != nil {
+		slog.Warn("failed to create views", "error", err)
+	}
+
+	// Apply declarative bootstrap file before serving traffic
+	if cfg.Server.BootstrapFile != "" {
+		spec, err := core.LoadBootstrapFile(cfg.Server.BootstrapFile)
+		if err != nil {
+			slog.Error("failed to load bootstrap file", "error", err)
+			os.Exit(1)
+		}
+		result, err := service.ApplyBootstrap(ctx, spec, false)
+		if err != nil {
+			slog.Error("failed to apply bootstrap file", "error", err)
+			os.Exit(1)
+		}
+		for _, c := range result.Changes {
+			slog.Info("bootstrap", "kind", c.Kind, "target", c.Target, "action", c.Action, "detail", c.Detail)
+		}
+		if result.Errors > 0 {
+			slog.Error("bootstrap file has errors", "errors", result.Errors)
+			os.Exit(1)
+		}
+		slog.Info("bootstrap applied", "file", cfg.Server.BootstrapFile, "changes", result.Applied)
+	}
+
 	// Log registered tables
 	slog.Info("tables registered",
 		"count", core.TableCount(),
@@ -102,12 +180,34 @@ func main() {
 	// Create cancellable context for background jobs
 	jobCtx, cancelJobs := context.WithCancel(context.Background())
 
+	// Follow secret rotation. Recycling the pool moves connections to the new
+	// database password once in-flight queries release them. New spool keys
+	// apply to new uploads; POST /api/admin/spool/rotate re-encrypts the rest.
+	go secrets.Watch(jobCtx, cfg.Security.SecretsRefreshInterval, func(changed []string) {
+		for _, env := range changed {
+			switch env {
+			case "DB_PASSWORD", "DATABASE_URL":
+				pool.Reset()
+			case "UPLOAD_SPOOL_KEYS":
+				if spool := service.Spool(); spool != nil {
+					if err := spool.SetKeys(secrets.Strings(env)); err != nil {
+						slog.Error("rotated spool keys are invalid; keeping current keys", "error", err)
+					}
+				}
+			}
+		}
+	})
+
 	// Start archive scheduler with config values
 	go service.StartArchiveScheduler(jobCtx, core.ArchiveConfig{
 		HotRetentionDays:      cfg.Archive.HotRetentionDays,
 		ArchiveRetentionYears: cfg.Archive.ArchiveRetentionYears,
 		BatchSize:             cfg.Archive.BatchSize,
 		CheckInterval:         cfg.Archive.CheckInterval,
+		FailedRowsCompactDays: cfg.Archive.FailedRowsCompactDays,
+		FailedRowsCompactMode: cfg.Archive.FailedRowsCompactMode,
+
+		SoftDeleteRetentionDays: cfg.Archive.SoftDeleteRetentionDays,
 	})
 
 	// Graceful shutdown
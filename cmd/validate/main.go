@@ -0,0 +1,121 @@
+// Command validate checks a CSV file against a table without a database and
+// prints a JSON report of the errors by code, for vendors' pre-submission
+// checks and CI pipelines.
+//
+//	go run ./cmd/validate -table sfdc_customers customers.csv
+//	go run ./cmd/validate -table sfdc_customers -max-error-percent 2 - < customers.csv.gz
+//
+// The exit status is 0 if the file passes the thresholds, 1 if it does not,
+// and 2 for usage errors or an unreadable file. Without thresholds, any
+// error row fails the file.
+//
+// Tables declared in schema files are loaded from TABLE_CONFIG_FILES, as
+// the server does; no other configuration is needed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	_ "github.com/JonMunkholm/TUI/internal/core/tables" // Register all tables
+	"github.com/joho/godotenv"
+)
+
+// Exit statuses.
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+func main() {
+	table := flag.String("table", "", "table key to validate against (required)")
+	mapping := flag.String("mapping", "", `column mapping as JSON, e.g. {"Customer ID": 0} (default: detect the header)`)
+	maxErrors := flag.Int("max-errors", -1, "max error rows to pass (default: no limit unless no threshold is set)")
+	maxPercent := flag.Float64("max-error-percent", -1, "max percent of error rows to pass (default: no limit unless no threshold is set)")
+	batchSize := flag.Int("batch-size", 1000, "rows per ValidateBatch call, as UPLOAD_BATCH_SIZE")
+	dateFormat := flag.String("date-format", "", "how numeric dates are read: mdy, dmy, or auto (default: mdy, warning about ambiguous columns)")
+	yearPivot := flag.Int("year-pivot", 0, "2-digit years more than this many years ahead are the previous century (default: the column's pivot, else 20)")
+	maxFileSize := flag.Int64("max-file-size", 104857600, "max (decompressed) file size in bytes, as UPLOAD_MAX_FILE_SIZE")
+	compact := flag.Bool("compact", false, "print the report on one line")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: validate -table KEY [flags] FILE|-\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *table == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	opts := core.ValidateOptions{
+		BatchSize:   *batchSize,
+		MaxFileSize: *maxFileSize,
+		Thresholds:  core.ValidationThresholds{MaxErrorRows: *maxErrors, MaxErrorPercent: *maxPercent},
+	}
+	var err error
+	if opts.Dates, err = core.NewDateOptions(*dateFormat, *yearPivot); err != nil {
+		fmt.Fprintln(os.Stderr, "validate:", err)
+		os.Exit(exitError)
+	}
+	if *mapping != "" {
+		if err := json.Unmarshal([]byte(*mapping), &opts.Mapping); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid mapping:", err)
+			os.Exit(exitError)
+		}
+	}
+
+	report, err := run(*table, flag.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "validate:", err)
+		os.Exit(exitError)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	if !*compact {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, "validate:", err)
+		os.Exit(exitError)
+	}
+	if !report.Passed {
+		os.Exit(exitFailed)
+	}
+	os.Exit(exitPassed)
+}
+
+func run(table, path string, opts core.ValidateOptions) (*core.ValidationReport, error) {
+	_ = godotenv.Load()
+
+	var files []string
+	for _, f := range strings.Split(os.Getenv("TABLE_CONFIG_FILES"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	if err := core.RegisterTableConfigFiles(files...); err != nil {
+		return nil, fmt.Errorf("load table config: %w", err)
+	}
+
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+		opts.FileName = filepath.Base(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return core.ValidateFile(table, data, opts)
+}
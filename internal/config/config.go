@@ -15,6 +15,8 @@ type Config struct {
 	Security SecurityConfig
 	Logging  LoggingConfig
 	Archive  ArchiveConfig
+	Query    QueryConfig
+	Webhooks WebhookConfig
 }
 
 // ServerConfig holds HTTP server settings.
@@ -39,13 +41,29 @@ type ServerConfig struct {
 
 	// RequestTimeout is the middleware timeout for requests (default: 60s)
 	RequestTimeout time.Duration `env:"SERVER_REQUEST_TIMEOUT" default:"60s"`
+
+	// IdempotencyTTL is how long responses to requests carrying an
+	// Idempotency-Key header are kept for replay (default: 1h, 0 disables)
+	IdempotencyTTL time.Duration `env:"SERVER_IDEMPOTENCY_TTL" default:"1h"`
+
+	// BootstrapFile is a declarative settings file applied at startup
+	// (default: empty, disabled)
+	BootstrapFile string `env:"BOOTSTRAP_FILE"`
+
+	// TableConfigFiles are schema files (JSON or YAML) declaring tables to
+	// register at startup, comma-separated (default: empty, none)
+	TableConfigFiles []string `env:"TABLE_CONFIG_FILES"`
 }
 
 // DatabaseConfig holds database connection settings.
 type DatabaseConfig struct {
 	// URL is the PostgreSQL connection string (required)
 	// Supports both DATABASE_URL and DB_URL env vars for compatibility
-	URL string `env:"DATABASE_URL" envAlt:"DB_URL" required:"true"`
+	URL string `env:"DATABASE_URL" envAlt:"DB_URL" required:"true" secret:"true"`
+
+	// Password overrides the password in URL, so the URL can be kept free of
+	// credentials and the password supplied from a secret manager (optional)
+	Password string `env:"DB_PASSWORD" secret:"true"`
 
 	// MaxConns is the maximum number of connections in the pool (default: 20)
 	MaxConns int `env:"DB_MAX_CONNS" default:"20"`
@@ -58,6 +76,28 @@ type DatabaseConfig struct {
 
 	// MaxConnIdleTime is the maximum idle time before a connection is closed (default: 30m)
 	MaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME" default:"30m"`
+
+	// QueryTimeout bounds lookups and paginated reads inside Service (default: 30s).
+	// Applied regardless of caller, so CLI and background jobs are bounded too.
+	// Streaming exports are not bounded. Zero disables.
+	QueryTimeout time.Duration `env:"DB_QUERY_TIMEOUT" default:"30s"`
+
+	// AggregateTimeout bounds counts, aggregations, and whole-table scans (default: 2m). Zero disables.
+	AggregateTimeout time.Duration `env:"DB_AGGREGATE_TIMEOUT" default:"2m"`
+
+	// MutationTimeout bounds row edits, deletes, and rollbacks (default: 1m). Zero disables.
+	MutationTimeout time.Duration `env:"DB_MUTATION_TIMEOUT" default:"1m"`
+
+	// CancelGrace is how long a cancelled query has to stop after PostgreSQL
+	// is asked to cancel it, before its connection is dropped (default: 1s).
+	// Zero drops the connection as soon as the cancel request is sent.
+	CancelGrace time.Duration `env:"DB_CANCEL_GRACE" default:"1s"`
+
+	// TransactionPooling is set when DATABASE_URL points at a connection
+	// pooler in transaction mode, such as pgbouncer with pool_mode =
+	// transaction. Queries are then sent without prepared statements, and
+	// no state is kept on a connection between transactions (default: false)
+	TransactionPooling bool `env:"DB_TRANSACTION_POOLING" default:"false"`
 }
 
 // UploadConfig holds CSV upload processing settings.
@@ -65,20 +105,94 @@ type UploadConfig struct {
 	// MaxFileSize is the maximum allowed file size in bytes (default: 100MB)
 	MaxFileSize int64 `env:"UPLOAD_MAX_FILE_SIZE" default:"104857600"`
 
+	// MaxPasteSize is the largest clipboard paste accepted by
+	// /api/upload-paste, in bytes (default: 256KB; 0 uses the default)
+	MaxPasteSize int64 `env:"UPLOAD_MAX_PASTE_SIZE" default:"262144"`
+
+	// TemplateDrift is what happens when a file uploaded with an import
+	// template has different headers than the template was saved with:
+	// "warn" reports it, "block" fails uploads it would misread, "off"
+	// skips the check (default: warn; empty means warn)
+	TemplateDrift string `env:"UPLOAD_TEMPLATE_DRIFT" default:"warn"`
+
 	// MaxConcurrent is the maximum number of parallel uploads (default: 5)
 	MaxConcurrent int `env:"UPLOAD_MAX_CONCURRENT" default:"5"`
 
 	// MaxWaitTime is how long to wait for an upload slot (default: 30s)
 	MaxWaitTime time.Duration `env:"UPLOAD_MAX_WAIT_TIME" default:"30s"`
 
+	// MaxConcurrentPerUploader is the most parallel uploads one API key (or,
+	// without one, one client IP) may run, within MaxConcurrent; 0 disables
+	// the per-uploader limit
+	MaxConcurrentPerUploader int `env:"UPLOAD_MAX_CONCURRENT_PER_UPLOADER" default:"0"`
+
+	// DailyUploadsPerUploader is how many uploads one uploader may start per
+	// UTC day; 0 disables the quota
+	DailyUploadsPerUploader int `env:"UPLOAD_DAILY_UPLOADS_PER_UPLOADER" default:"0"`
+
+	// DailyBytesPerUploader is how many bytes one uploader may upload per
+	// UTC day; 0 disables the quota
+	DailyBytesPerUploader int64 `env:"UPLOAD_DAILY_BYTES_PER_UPLOADER" default:"0"`
+
 	// BatchSize is the number of rows to insert per batch (default: 1000)
 	BatchSize int `env:"UPLOAD_BATCH_SIZE" default:"1000"`
 
 	// Timeout is the maximum duration for a single upload operation (default: 10m)
 	Timeout time.Duration `env:"UPLOAD_TIMEOUT" default:"10m"`
 
-	// ResetTimeout is the maximum duration for a reset operation (default: 30s)
-	ResetTimeout time.Duration `env:"UPLOAD_RESET_TIMEOUT" default:"30s"`
+	// ResetTimeout is the maximum duration for a table reset or upload
+	// rollback, which delete in batches (default: 30m)
+	ResetTimeout time.Duration `env:"UPLOAD_RESET_TIMEOUT" default:"30m"`
+
+	// DeleteBatchSize is the number of rows a reset or rollback deletes per
+	// statement (default: 10000; 0 uses the default)
+	DeleteBatchSize int `env:"UPLOAD_DELETE_BATCH_SIZE" default:"10000"`
+
+	// BatchRetries is how many times a batch is retried after a serialization
+	// failure, deadlock or lock timeout before falling back to row-by-row
+	// insert (default: 3)
+	BatchRetries int `env:"UPLOAD_BATCH_RETRIES" default:"3"`
+
+	// RetryBackoff is the initial delay between batch retries, doubled per attempt (default: 100ms)
+	RetryBackoff time.Duration `env:"UPLOAD_RETRY_BACKOFF" default:"100ms"`
+
+	// SpoolKeys enables spooling uploads to disk, encrypted at rest, instead
+	// of buffering them in memory. Entries are "keyID:base64(32-byte key)";
+	// the first encrypts new files and all can decrypt. Empty disables spooling.
+	SpoolKeys []string `env:"UPLOAD_SPOOL_KEYS" secret:"true"`
+
+	// SpoolDir is where spooled uploads are stored (default: accounting/uploads)
+	SpoolDir string `env:"UPLOAD_SPOOL_DIR"`
+
+	// ResumableChunkSize is the largest chunk a resumable upload accepts per
+	// request (default: 8MB). Resumable uploads need SpoolKeys.
+	ResumableChunkSize int64 `env:"UPLOAD_RESUMABLE_CHUNK_SIZE" default:"8388608"`
+
+	// ResumableTTL is how long an unfinished resumable upload is kept after
+	// its last chunk before it is discarded (default: 24h)
+	ResumableTTL time.Duration `env:"UPLOAD_RESUMABLE_TTL" default:"24h"`
+
+	// RemoteSources lists the URL prefixes uploads may be streamed from,
+	// e.g. "s3://exports/netsuite/,https://files.example.com/". Prefixes
+	// match whole path segments. Empty disables uploads from URLs.
+	RemoteSources []string `env:"UPLOAD_REMOTE_SOURCES"`
+
+	// KeyViolationAlertPercent alerts when more than this percent of the rows
+	// in a table's recent uploads violated its unique key; 0 disables alerts
+	KeyViolationAlertPercent int `env:"UPLOAD_KEY_VIOLATION_ALERT_PERCENT" default:"0"`
+
+	// KeyViolationAlertWindow is how many recent uploads the rate covers (default: 10)
+	KeyViolationAlertWindow int `env:"UPLOAD_KEY_VIOLATION_ALERT_WINDOW" default:"10"`
+
+	// AlertWebhookURL receives each alert as a JSON POST, in addition to the log
+	AlertWebhookURL string `env:"UPLOAD_ALERT_WEBHOOK_URL" secret:"true"`
+
+	// HookURLs receive every upload lifecycle event as a JSON POST, and
+	// can reject or adjust uploads before they start (see core.UploadHook)
+	HookURLs []string `env:"UPLOAD_HOOK_URLS" secret:"true"`
+
+	// HookTimeout bounds each request to a hook URL (default: 5s)
+	HookTimeout time.Duration `env:"UPLOAD_HOOK_TIMEOUT" default:"5s"`
 }
 
 // RateLimitConfig holds rate limiting settings per time window.
@@ -106,8 +220,62 @@ type SecurityConfig struct {
 	RequireAPIKey bool `env:"REQUIRE_API_KEY" default:"false"`
 
 	// APIKeys is a comma-separated list of valid API keys
-	// Only used when RequireAPIKey is true
-	APIKeys []string `env:"API_KEYS"`
+	// Only used when RequireAPIKey is true. They have full access; scoped
+	// API tokens, created with one of them, are kept in the database.
+	APIKeys []string `env:"API_KEYS" secret:"true"`
+
+	// APIKeyProvider, if set, supplies the current API keys in place of
+	// APIKeys, so rotated keys take effect without a restart. Set in code.
+	APIKeyProvider func() []string
+
+	// ReviewerAPIKeys is a comma-separated list of API keys that hold the
+	// reviewer role: only they may approve or archive uploads, or reopen a
+	// signed-off one. Empty lets any caller sign off.
+	ReviewerAPIKeys []string `env:"REVIEWER_API_KEYS" secret:"true"`
+
+	// SecretsRefreshInterval is how often secret references (see
+	// ResolveSecrets) are re-resolved to pick up rotations (default: 0, disabled)
+	SecretsRefreshInterval time.Duration `env:"SECRETS_REFRESH_INTERVAL" default:"0s"`
+
+	// DestructiveAllowCIDRs restricts destructive endpoints (delete, reset,
+	// rollback, settings) to these client networks, independent of API keys.
+	// Empty allows all networks.
+	DestructiveAllowCIDRs []string `env:"DESTRUCTIVE_ALLOW_CIDRS"`
+
+	// DestructiveDenyCountries rejects destructive requests from these ISO
+	// 3166-1 alpha-2 country codes. Requires an IP resolver (see web.Server.SetIPResolver).
+	DestructiveDenyCountries []string `env:"DESTRUCTIVE_DENY_COUNTRIES"`
+
+	// DestructiveDenyASNs rejects destructive requests from these autonomous
+	// systems ("AS64500" or "64500"). Requires an IP resolver.
+	DestructiveDenyASNs []string `env:"DESTRUCTIVE_DENY_ASNS"`
+
+	// AuthMaxFailures is how many failed API key attempts from one IP within
+	// AuthFailureWindow trigger a lockout (default: 10, 0 disables).
+	// Attempts are also progressively delayed after the third failure.
+	AuthMaxFailures int `env:"AUTH_MAX_FAILURES" default:"10"`
+
+	// AuthFailureWindow is how long failed attempts are remembered (default: 15m)
+	AuthFailureWindow time.Duration `env:"AUTH_FAILURE_WINDOW" default:"15m"`
+
+	// AuthLockoutDuration is how long a locked-out IP is rejected (default: 15m)
+	AuthLockoutDuration time.Duration `env:"AUTH_LOCKOUT_DURATION" default:"15m"`
+
+	// RequireLogin requires every page and API request to come from a
+	// signed-in user or carry a valid API key (default: false). Users are
+	// created through the admin API, so API_KEYS must be set.
+	RequireLogin bool `env:"REQUIRE_LOGIN" default:"false"`
+
+	// SessionTTL is how long a login lasts (default: 12h; 0 also means 12h)
+	SessionTTL time.Duration `env:"SESSION_TTL" default:"12h"`
+
+	// ApprovalRowThreshold makes resets and rollbacks that would remove more
+	// rows than this wait for a second user's approval (default: 0, disabled)
+	ApprovalRowThreshold int64 `env:"APPROVAL_ROW_THRESHOLD" default:"0"`
+
+	// ApprovalTTL is how long a request waits for approval before it
+	// expires (default: 24h)
+	ApprovalTTL time.Duration `env:"APPROVAL_TTL" default:"24h"`
 }
 
 // LoggingConfig holds logging settings.
@@ -132,6 +300,90 @@ type ArchiveConfig struct {
 
 	// CheckInterval is how often to run the archive job (default: 24h)
 	CheckInterval time.Duration `env:"ARCHIVE_CHECK_INTERVAL" default:"24h"`
+
+	// FailedRowsCompactDays compacts failed-row data older than this many
+	// days during the archive job; 0 disables compaction (default: 30)
+	FailedRowsCompactDays int `env:"FAILED_ROWS_COMPACT_AFTER_DAYS" default:"30"`
+
+	// FailedRowsCompactMode is compress (lossless) or summarize (keeps only
+	// unique key columns) (default: compress)
+	FailedRowsCompactMode string `env:"FAILED_ROWS_COMPACT_MODE" default:"compress"`
+
+	// SoftDeleteRetentionDays purges rows soft-deleted more than this many
+	// days ago during the archive job; 0 keeps them (default: 30)
+	SoftDeleteRetentionDays int `env:"SOFT_DELETE_RETENTION_DAYS" default:"30"`
+
+	// RetentionExportDir is where rows are archived before the archive job
+	// purges them, as gzipped NDJSON with a checksummed manifest. Empty
+	// purges without keeping a copy.
+	RetentionExportDir string `env:"ARCHIVE_RETENTION_EXPORT_DIR"`
+
+	// RetentionExportGraceDays is how long those archives are kept
+	// (default: 90; 0 uses the default)
+	RetentionExportGraceDays int `env:"ARCHIVE_RETENTION_EXPORT_GRACE_DAYS" default:"90"`
+}
+
+// QueryConfig holds table view query settings.
+type QueryConfig struct {
+	// MinPageSize is the smallest page size a table view may request
+	// (default: 10; 0 uses 1)
+	MinPageSize int `env:"QUERY_MIN_PAGE_SIZE" default:"10"`
+
+	// MaxPageSize is the largest page size a table view may request
+	// (default: 500; 0 uses the default)
+	MaxPageSize int `env:"QUERY_MAX_PAGE_SIZE" default:"500"`
+
+	// MaxSortLevels is how many sort columns a table view may apply
+	// (default: 4; 0 uses the default)
+	MaxSortLevels int `env:"QUERY_MAX_SORT_LEVELS" default:"4"`
+
+	// MaxSummaryGroups is how many groups a grouped summary returns before
+	// it is truncated (default: 10000; 0 uses the default)
+	MaxSummaryGroups int `env:"QUERY_MAX_SUMMARY_GROUPS" default:"10000"`
+
+	// AggregateMinGroupSize is the fewest rows a summary group or
+	// histogram bin may count before it is suppressed for aggregates-only
+	// users and tokens, for tables that set no MinGroupSize of their own
+	// (default: 10; 0 uses the default, 1 suppresses nothing)
+	AggregateMinGroupSize int `env:"QUERY_AGGREGATE_MIN_GROUP_SIZE" default:"10"`
+
+	// CacheTTL is how long table stats and unfiltered aggregations are
+	// cached; changes to a table drop its entries at once (default: 30s;
+	// 0 disables the cache)
+	CacheTTL time.Duration `env:"QUERY_CACHE_TTL" default:"30s"`
+
+	// ExportJobDir is where background exports write their files
+	// (default: accounting/exports)
+	ExportJobDir string `env:"EXPORT_JOB_DIR"`
+
+	// ExportJobTTL is how long a background export's file can be
+	// downloaded before it is deleted (default: 24h; 0 uses the default)
+	ExportJobTTL time.Duration `env:"EXPORT_JOB_TTL" default:"24h"`
+}
+
+// WebhookConfig holds settings for webhooks sent when uploads finish and
+// when rows are rolled back or reset.
+type WebhookConfig struct {
+	// URLs receive each event as a JSON POST (default: empty, disabled)
+	URLs []string `env:"WEBHOOK_URLS" secret:"true"`
+
+	// Secret signs each request with HMAC-SHA256 in the X-Webhook-Signature
+	// header (default: empty, unsigned)
+	Secret string `env:"WEBHOOK_SECRET" secret:"true"`
+
+	// Events limits the events sent, from upload_completed, upload_failed,
+	// rollback and reset (default: empty, all)
+	Events []string `env:"WEBHOOK_EVENTS"`
+
+	// Timeout bounds each delivery attempt (default: 10s)
+	Timeout time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s"`
+
+	// MaxAttempts is how many times an event is sent before it is given up
+	// and logged (default: 5)
+	MaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" default:"5"`
+
+	// RetryBackoff is the delay before the first retry, doubled per attempt (default: 2s)
+	RetryBackoff time.Duration `env:"WEBHOOK_RETRY_BACKOFF" default:"2s"`
 }
 
 // Addr returns the server listen address in host:port format.
@@ -29,9 +29,36 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Upload.MaxFileSize != 104857600 {
 		t.Errorf("Upload.MaxFileSize = %d, want %d", cfg.Upload.MaxFileSize, 104857600)
 	}
+	if cfg.Upload.MaxPasteSize != 262144 {
+		t.Errorf("Upload.MaxPasteSize = %d, want %d", cfg.Upload.MaxPasteSize, 262144)
+	}
+	if cfg.Upload.TemplateDrift != "warn" {
+		t.Errorf("Upload.TemplateDrift = %q, want %q", cfg.Upload.TemplateDrift, "warn")
+	}
 	if cfg.Rate.RequestsPerMinute != 100 {
 		t.Errorf("Rate.RequestsPerMinute = %d, want %d", cfg.Rate.RequestsPerMinute, 100)
 	}
+	if cfg.Query.MinPageSize != 10 || cfg.Query.MaxPageSize != 500 {
+		t.Errorf("Query page size limits = %d..%d, want 10..500", cfg.Query.MinPageSize, cfg.Query.MaxPageSize)
+	}
+	if cfg.Query.MaxSortLevels != 4 {
+		t.Errorf("Query.MaxSortLevels = %d, want 4", cfg.Query.MaxSortLevels)
+	}
+	if cfg.Archive.RetentionExportGraceDays != 90 {
+		t.Errorf("Archive.RetentionExportGraceDays = %d, want 90", cfg.Archive.RetentionExportGraceDays)
+	}
+	if cfg.Query.MaxSummaryGroups != 10000 {
+		t.Errorf("Query.MaxSummaryGroups = %d, want 10000", cfg.Query.MaxSummaryGroups)
+	}
+	if cfg.Query.AggregateMinGroupSize != 10 {
+		t.Errorf("Query.AggregateMinGroupSize = %d, want 10", cfg.Query.AggregateMinGroupSize)
+	}
+	if cfg.Query.ExportJobTTL != 24*time.Hour {
+		t.Errorf("Query.ExportJobTTL = %v, want 24h", cfg.Query.ExportJobTTL)
+	}
+	if cfg.Query.CacheTTL != 30*time.Second {
+		t.Errorf("Query.CacheTTL = %v, want 30s", cfg.Query.CacheTTL)
+	}
 }
 
 func TestLoad_OverrideDefaults(t *testing.T) {
@@ -192,6 +219,48 @@ func TestValidate_InvalidLogLevel(t *testing.T) {
 	}
 }
 
+func TestValidate_NegativeUploaderQuota(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute, DailyBytesPerUploader: -1},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "UPLOAD_DAILY_BYTES_PER_UPLOADER") {
+		t.Errorf("Validate() = %v, want UPLOAD_DAILY_BYTES_PER_UPLOADER error", err)
+	}
+}
+
+func TestValidate_InvalidNetworkPolicy(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Security: SecurityConfig{
+			DestructiveAllowCIDRs: []string{"10.0.0.0/8", "192.168.1.5", "10.0.0.0/33"},
+			DestructiveDenyASNs:   []string{"AS64500", "64501", "ASX"},
+		},
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for invalid network policy")
+	}
+	if !contains(err.Error(), `"10.0.0.0/33"`) || !contains(err.Error(), `"ASX"`) {
+		t.Errorf("error should mention the invalid entries: %v", err)
+	}
+	if contains(err.Error(), `"192.168.1.5"`) || contains(err.Error(), `"AS64500"`) {
+		t.Errorf("error should not mention valid entries: %v", err)
+	}
+}
+
 func TestServerAddr(t *testing.T) {
 	tests := []struct {
 		host string
@@ -238,3 +307,276 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestValidate_KeyViolationAlerts(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload: UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute,
+			KeyViolationAlertPercent: 101, AlertWebhookURL: "ftp://hooks.example.com"},
+		Rate:    RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive: ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for invalid alert settings")
+	}
+	for _, want := range []string{"UPLOAD_KEY_VIOLATION_ALERT_PERCENT", "UPLOAD_KEY_VIOLATION_ALERT_WINDOW", "UPLOAD_ALERT_WEBHOOK_URL"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+}
+
+func TestValidate_Resumable(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload: UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute,
+			SpoolKeys: []string{"k1:key"}},
+		Rate:    RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive: ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for unset resumable settings")
+	}
+	for _, want := range []string{"UPLOAD_RESUMABLE_CHUNK_SIZE", "UPLOAD_RESUMABLE_TTL"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+
+	cfg.Upload.ResumableChunkSize = 1 << 20
+	cfg.Upload.ResumableTTL = time.Hour
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_UploadHooks(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload: UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute,
+			HookURLs: []string{"https://hooks.example.com/upload", "hooks.example.com"}},
+		Rate:    RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive: ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for invalid hook settings")
+	}
+	for _, want := range []string{"UPLOAD_HOOK_URLS", "UPLOAD_HOOK_TIMEOUT"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+
+	cfg.Upload.HookURLs = cfg.Upload.HookURLs[:1]
+	cfg.Upload.HookTimeout = 5 * time.Second
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_Webhooks(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+		Webhooks: WebhookConfig{URLs: []string{"https://etl.example.com/hook", "etl.example.com"}, Events: []string{"upload_started"}},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for invalid webhook settings")
+	}
+	for _, want := range []string{"WEBHOOK_URLS", "WEBHOOK_EVENTS", "WEBHOOK_TIMEOUT", "WEBHOOK_MAX_ATTEMPTS"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+
+	cfg.Webhooks = WebhookConfig{
+		URLs:        []string{"https://etl.example.com/hook"},
+		Events:      []string{"upload_completed", "reset"},
+		Timeout:     10 * time.Second,
+		MaxAttempts: 5,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_RequireLogin(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+		Security: SecurityConfig{RequireLogin: true, SessionTTL: -time.Hour},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for REQUIRE_LOGIN without API keys")
+	}
+	for _, want := range []string{"REQUIRE_LOGIN", "SESSION_TTL"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("error should mention %s: %v", want, err)
+		}
+	}
+
+	cfg.Security.APIKeys = []string{"admin-key"}
+	cfg.Security.SessionTTL = 8 * time.Hour
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_CancelGrace(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4, CancelGrace: -time.Second},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error for negative DB_CANCEL_GRACE")
+	}
+	if !contains(err.Error(), "DB_CANCEL_GRACE") {
+		t.Errorf("error should mention DB_CANCEL_GRACE: %v", err)
+	}
+
+	cfg.Database.CancelGrace = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_TransactionPooling(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost:6432/test?default_query_exec_mode=cache_statement", MaxConns: 20, MinConns: 4, TransactionPooling: true},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "DB_TRANSACTION_POOLING") {
+		t.Errorf("Validate() = %v, want DB_TRANSACTION_POOLING error for cache_statement", err)
+	}
+
+	cfg.Database.URL = "host=localhost port=6432 dbname=test default_query_exec_mode=cache_describe"
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "DB_TRANSACTION_POOLING") {
+		t.Errorf("Validate() = %v, want DB_TRANSACTION_POOLING error for cache_describe", err)
+	}
+
+	for _, url := range []string{
+		"postgres://localhost:6432/test",
+		"postgres://localhost:6432/test?default_query_exec_mode=simple_protocol",
+		"host=localhost port=6432 dbname=test",
+	} {
+		cfg.Database.URL = url
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: Validate() = %v", url, err)
+		}
+	}
+
+	cfg.Database.TransactionPooling = false
+	cfg.Database.URL = "postgres://localhost/test?default_query_exec_mode=cache_statement"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("without pooling: Validate() = %v", err)
+	}
+}
+
+func TestValidate_Approvals(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+		Security: SecurityConfig{ApprovalRowThreshold: -1},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "APPROVAL_ROW_THRESHOLD") {
+		t.Errorf("Validate() = %v, want an APPROVAL_ROW_THRESHOLD error", err)
+	}
+
+	cfg.Security.ApprovalRowThreshold = 1000
+	err = cfg.Validate()
+	if err == nil || !contains(err.Error(), "APPROVAL_TTL") {
+		t.Errorf("Validate() = %v, want an APPROVAL_TTL error", err)
+	}
+
+	cfg.Security.ApprovalTTL = time.Hour
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestValidate_PageSizeLimits(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://localhost/test", MaxConns: 20, MinConns: 4},
+		Server:   ServerConfig{Port: 8080, ShutdownTimeout: time.Second},
+		Upload:   UploadConfig{MaxFileSize: 1, MaxConcurrent: 1, BatchSize: 1, MaxWaitTime: time.Second, Timeout: time.Minute},
+		Rate:     RateLimitConfig{Enabled: true, RequestsPerMinute: 100},
+		Archive:  ArchiveConfig{HotRetentionDays: 90, ArchiveRetentionYears: 7, BatchSize: 1000, CheckInterval: time.Hour},
+		Logging:  LoggingConfig{Level: "info", Format: "text"},
+		Query:    QueryConfig{MinPageSize: 50, MaxPageSize: 20},
+	}
+
+	err := cfg.Validate()
+	if err == nil || !contains(err.Error(), "QUERY_MAX_PAGE_SIZE") {
+		t.Fatalf("Validate() = %v, want QUERY_MAX_PAGE_SIZE error", err)
+	}
+
+	cfg.Query.MaxPageSize = 0 // Uses the default cap
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	cfg.Query.MaxSortLevels = -1
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "QUERY_MAX_SORT_LEVELS") {
+		t.Errorf("Validate() = %v, want QUERY_MAX_SORT_LEVELS error", err)
+	}
+
+	cfg.Query.MaxSortLevels = 0
+	cfg.Query.MaxSummaryGroups = -1
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "QUERY_MAX_SUMMARY_GROUPS") {
+		t.Errorf("Validate() = %v, want QUERY_MAX_SUMMARY_GROUPS error", err)
+	}
+
+	cfg.Query.MaxSummaryGroups = 0
+	cfg.Query.CacheTTL = -time.Second
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "QUERY_CACHE_TTL") {
+		t.Errorf("Validate() = %v, want QUERY_CACHE_TTL error", err)
+	}
+
+	cfg.Query.CacheTTL = 0
+	cfg.Query.ExportJobTTL = -time.Hour
+	if err := cfg.Validate(); err == nil || !contains(err.Error(), "EXPORT_JOB_TTL") {
+		t.Errorf("Validate() = %v, want EXPORT_JOB_TTL error", err)
+	}
+}
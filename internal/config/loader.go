@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
@@ -177,13 +179,48 @@ func (c *Config) Validate() error {
 		errs = append(errs, "SERVER_SHUTDOWN_TIMEOUT must be positive")
 	}
 
+	if c.Database.QueryTimeout < 0 {
+		errs = append(errs, "DB_QUERY_TIMEOUT must not be negative")
+	}
+	if c.Database.AggregateTimeout < 0 {
+		errs = append(errs, "DB_AGGREGATE_TIMEOUT must not be negative")
+	}
+	if c.Database.MutationTimeout < 0 {
+		errs = append(errs, "DB_MUTATION_TIMEOUT must not be negative")
+	}
+	if c.Database.CancelGrace < 0 {
+		errs = append(errs, "DB_CANCEL_GRACE must not be negative")
+	}
+	if c.Database.TransactionPooling {
+		if mode := queryExecMode(c.Database.URL); strings.HasPrefix(mode, "cache_") {
+			errs = append(errs, "DB_TRANSACTION_POOLING can't be used with default_query_exec_mode="+mode+" in DATABASE_URL")
+		}
+	}
+
 	// Upload validation
 	if c.Upload.MaxFileSize <= 0 {
 		errs = append(errs, "UPLOAD_MAX_FILE_SIZE must be positive")
 	}
+	if c.Upload.MaxPasteSize < 0 {
+		errs = append(errs, "UPLOAD_MAX_PASTE_SIZE must not be negative")
+	}
+	switch c.Upload.TemplateDrift {
+	case "", "warn", "block", "off":
+	default:
+		errs = append(errs, fmt.Sprintf("UPLOAD_TEMPLATE_DRIFT (%q) must be one of: warn, block, off", c.Upload.TemplateDrift))
+	}
 	if c.Upload.MaxConcurrent <= 0 {
 		errs = append(errs, "UPLOAD_MAX_CONCURRENT must be positive")
 	}
+	if c.Upload.MaxConcurrentPerUploader < 0 {
+		errs = append(errs, "UPLOAD_MAX_CONCURRENT_PER_UPLOADER must not be negative")
+	}
+	if c.Upload.DailyUploadsPerUploader < 0 {
+		errs = append(errs, "UPLOAD_DAILY_UPLOADS_PER_UPLOADER must not be negative")
+	}
+	if c.Upload.DailyBytesPerUploader < 0 {
+		errs = append(errs, "UPLOAD_DAILY_BYTES_PER_UPLOADER must not be negative")
+	}
 	if c.Upload.BatchSize <= 0 {
 		errs = append(errs, "UPLOAD_BATCH_SIZE must be positive")
 	}
@@ -193,6 +230,42 @@ func (c *Config) Validate() error {
 	if c.Upload.Timeout <= 0 {
 		errs = append(errs, "UPLOAD_TIMEOUT must be positive")
 	}
+	if c.Upload.DeleteBatchSize < 0 {
+		errs = append(errs, "UPLOAD_DELETE_BATCH_SIZE must not be negative")
+	}
+	if c.Upload.BatchRetries < 0 {
+		errs = append(errs, "UPLOAD_BATCH_RETRIES must not be negative")
+	}
+	if c.Upload.RetryBackoff < 0 {
+		errs = append(errs, "UPLOAD_RETRY_BACKOFF must not be negative")
+	}
+	if c.Upload.SpoolDir != "" && len(c.Upload.SpoolKeys) == 0 {
+		errs = append(errs, "UPLOAD_SPOOL_DIR requires UPLOAD_SPOOL_KEYS (spooled uploads are always encrypted)")
+	}
+	if len(c.Upload.SpoolKeys) > 0 && c.Upload.ResumableChunkSize <= 0 {
+		errs = append(errs, "UPLOAD_RESUMABLE_CHUNK_SIZE must be positive when spooling is enabled")
+	}
+	if len(c.Upload.SpoolKeys) > 0 && c.Upload.ResumableTTL <= 0 {
+		errs = append(errs, "UPLOAD_RESUMABLE_TTL must be positive when spooling is enabled")
+	}
+	if c.Upload.KeyViolationAlertPercent < 0 || c.Upload.KeyViolationAlertPercent > 100 {
+		errs = append(errs, fmt.Sprintf("UPLOAD_KEY_VIOLATION_ALERT_PERCENT (%d) must be 0-100", c.Upload.KeyViolationAlertPercent))
+	}
+	if c.Upload.KeyViolationAlertPercent > 0 && c.Upload.KeyViolationAlertWindow <= 0 {
+		errs = append(errs, "UPLOAD_KEY_VIOLATION_ALERT_WINDOW must be positive when key violation alerts are enabled")
+	}
+	if u := c.Upload.AlertWebhookURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		errs = append(errs, "UPLOAD_ALERT_WEBHOOK_URL must be an http or https URL")
+	}
+	for _, u := range c.Upload.HookURLs {
+		if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			errs = append(errs, "UPLOAD_HOOK_URLS must be http or https URLs")
+			break
+		}
+	}
+	if len(c.Upload.HookURLs) > 0 && c.Upload.HookTimeout <= 0 {
+		errs = append(errs, "UPLOAD_HOOK_TIMEOUT must be positive when upload hooks are configured")
+	}
 
 	// Rate limit validation
 	if c.Rate.Enabled && c.Rate.RequestsPerMinute <= 0 {
@@ -212,11 +285,115 @@ func (c *Config) Validate() error {
 	if c.Archive.CheckInterval <= 0 {
 		errs = append(errs, "ARCHIVE_CHECK_INTERVAL must be positive")
 	}
+	if c.Archive.FailedRowsCompactDays < 0 {
+		errs = append(errs, "FAILED_ROWS_COMPACT_AFTER_DAYS must not be negative")
+	}
+	if c.Archive.SoftDeleteRetentionDays < 0 {
+		errs = append(errs, "SOFT_DELETE_RETENTION_DAYS must not be negative")
+	}
+	if c.Archive.RetentionExportGraceDays < 0 {
+		errs = append(errs, "ARCHIVE_RETENTION_EXPORT_GRACE_DAYS must not be negative")
+	}
+	if c.Archive.FailedRowsCompactDays > 0 {
+		switch c.Archive.FailedRowsCompactMode {
+		case "compress", "summarize":
+		default:
+			errs = append(errs, fmt.Sprintf("FAILED_ROWS_COMPACT_MODE must be compress or summarize, got %q", c.Archive.FailedRowsCompactMode))
+		}
+	}
+
+	// Query validation
+	if c.Query.MinPageSize < 0 {
+		errs = append(errs, "QUERY_MIN_PAGE_SIZE must not be negative")
+	}
+	if c.Query.MaxPageSize < 0 {
+		errs = append(errs, "QUERY_MAX_PAGE_SIZE must not be negative")
+	}
+	if c.Query.MaxPageSize > 0 && c.Query.MaxPageSize < c.Query.MinPageSize {
+		errs = append(errs, fmt.Sprintf("QUERY_MAX_PAGE_SIZE (%d) must be >= QUERY_MIN_PAGE_SIZE (%d)", c.Query.MaxPageSize, c.Query.MinPageSize))
+	}
+	if c.Query.MaxSortLevels < 0 {
+		errs = append(errs, "QUERY_MAX_SORT_LEVELS must not be negative")
+	}
+	if c.Query.MaxSummaryGroups < 0 {
+		errs = append(errs, "QUERY_MAX_SUMMARY_GROUPS must not be negative")
+	}
+	if c.Query.AggregateMinGroupSize < 0 {
+		errs = append(errs, "QUERY_AGGREGATE_MIN_GROUP_SIZE must not be negative")
+	}
+	if c.Query.CacheTTL < 0 {
+		errs = append(errs, "QUERY_CACHE_TTL must not be negative")
+	}
+	if c.Query.ExportJobTTL < 0 {
+		errs = append(errs, "EXPORT_JOB_TTL must not be negative")
+	}
+
+	// Webhook validation
+	for _, u := range c.Webhooks.URLs {
+		if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+			errs = append(errs, "WEBHOOK_URLS must be http or https URLs")
+			break
+		}
+	}
+	for _, e := range c.Webhooks.Events {
+		switch e {
+		case "upload_completed", "upload_failed", "rollback", "reset":
+		default:
+			errs = append(errs, fmt.Sprintf("WEBHOOK_EVENTS must be upload_completed, upload_failed, rollback or reset, got %q", e))
+		}
+	}
+	if len(c.Webhooks.URLs) > 0 {
+		if c.Webhooks.Timeout <= 0 {
+			errs = append(errs, "WEBHOOK_TIMEOUT must be positive when webhooks are configured")
+		}
+		if c.Webhooks.MaxAttempts <= 0 {
+			errs = append(errs, "WEBHOOK_MAX_ATTEMPTS must be positive when webhooks are configured")
+		}
+		if c.Webhooks.RetryBackoff < 0 {
+			errs = append(errs, "WEBHOOK_RETRY_BACKOFF must not be negative")
+		}
+	}
 
 	// Security validation
 	if c.Security.RequireAPIKey && len(c.Security.APIKeys) == 0 {
 		errs = append(errs, "REQUIRE_API_KEY is true but API_KEYS is empty; configure at least one API key or disable auth")
 	}
+	if c.Security.RequireLogin && len(c.Security.APIKeys) == 0 {
+		errs = append(errs, "REQUIRE_LOGIN is true but API_KEYS is empty; an API key is needed to create the first user")
+	}
+	if c.Security.SessionTTL < 0 {
+		errs = append(errs, "SESSION_TTL must not be negative")
+	}
+	if c.Security.ApprovalRowThreshold < 0 {
+		errs = append(errs, "APPROVAL_ROW_THRESHOLD must not be negative")
+	}
+	if c.Security.ApprovalRowThreshold > 0 && c.Security.ApprovalTTL <= 0 {
+		errs = append(errs, "APPROVAL_TTL must be positive when APPROVAL_ROW_THRESHOLD is set")
+	}
+	if c.Security.SecretsRefreshInterval < 0 {
+		errs = append(errs, "SECRETS_REFRESH_INTERVAL must not be negative")
+	}
+	if c.Security.AuthMaxFailures < 0 {
+		errs = append(errs, "AUTH_MAX_FAILURES must not be negative")
+	}
+	if c.Security.AuthMaxFailures > 0 && (c.Security.AuthFailureWindow <= 0 || c.Security.AuthLockoutDuration <= 0) {
+		errs = append(errs, "AUTH_FAILURE_WINDOW and AUTH_LOCKOUT_DURATION must be positive when AUTH_MAX_FAILURES is set")
+	}
+	for _, cidr := range c.Security.DestructiveAllowCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			errs = append(errs, fmt.Sprintf("DESTRUCTIVE_ALLOW_CIDRS entry %q is not a CIDR or IP address", cidr))
+		}
+	}
+	for _, cc := range c.Security.DestructiveDenyCountries {
+		if len(cc) != 2 {
+			errs = append(errs, fmt.Sprintf("DESTRUCTIVE_DENY_COUNTRIES entry %q must be a two-letter country code", cc))
+		}
+	}
+	for _, asn := range c.Security.DestructiveDenyASNs {
+		if _, err := ParseASN(asn); err != nil {
+			errs = append(errs, fmt.Sprintf("DESTRUCTIVE_DENY_ASNS entry %q must be a number, optionally prefixed with AS", asn))
+		}
+	}
 
 	// Logging validation
 	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
@@ -253,3 +430,30 @@ func (c *Config) String() string {
 	b.WriteString("}")
 	return b.String()
 }
+
+// ParseASN parses an autonomous system number written as "AS64500" or "64500".
+func ParseASN(s string) (uint32, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q: %w", s, err)
+	}
+	return uint32(n), nil
+}
+
+// queryExecMode returns the default_query_exec_mode set in a PostgreSQL
+// connection string, URL or keyword/value, or "" if none is.
+func queryExecMode(conn string) string {
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		if u, err := url.Parse(conn); err == nil {
+			return u.Query().Get("default_query_exec_mode")
+		}
+		return ""
+	}
+	for _, field := range strings.Fields(conn) {
+		if v, ok := strings.CutPrefix(field, "default_query_exec_mode="); ok {
+			return strings.Trim(v, "'")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,562 @@
+package config
+
+// secrets.go resolves sensitive config values from secret managers, so they
+// do not have to be stored as plaintext environment variables.
+//
+// Any field tagged `secret:"true"` may hold a secret reference instead of a
+// literal value:
+//
+//	file:///run/secrets/db_password                      Mounted file (Docker/Kubernetes secrets)
+//	vault://secret/data/csv-importer#db_password         HashiCorp Vault KV (v1 or v2)
+//	awssm://prod/csv-importer#db_password                AWS Secrets Manager (name or ARN)
+//	gcpsm://projects/p/secrets/db-password               GCP Secret Manager (latest version)
+//
+// The optional #key selects a field when the secret is a JSON object (Vault
+// secrets always are). For list fields, each element may be a reference, and
+// a resolved value may itself be a comma-separated list.
+//
+// Providers read the standard environment of their platform:
+//
+//	vault:  VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE (optional)
+//	awssm:  AWS_REGION (or AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID,
+//	        AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN (optional),
+//	        AWS_ENDPOINT_URL_SECRETS_MANAGER (optional)
+//	gcpsm:  GOOGLE_OAUTH_ACCESS_TOKEN, or the GCE/GKE metadata server
+//
+// References are resolved once at startup by ResolveSecrets, which writes the
+// values into Config. Rotated values are picked up by Secrets.Watch; since
+// Config is not safe to mutate while serving, consumers that must follow
+// rotation read from Secrets instead (see DatabasePassword and APIKeys).
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider resolves a secret reference for one scheme. ref is the part
+// after "scheme://" without the #key fragment.
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to the SecretProvider interface.
+type SecretProviderFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve calls f(ctx, ref).
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]SecretProvider{
+		"file":  SecretProviderFunc(resolveFileSecret),
+		"vault": SecretProviderFunc(resolveVaultSecret),
+		"awssm": SecretProviderFunc(resolveAWSSecret),
+		"gcpsm": SecretProviderFunc(resolveGCPSecret),
+	}
+)
+
+// RegisterSecretProvider adds or replaces the provider for a scheme.
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+// secretHTTPClient is used by the network providers.
+var secretHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// IsSecretRef reports whether value is a reference to a registered provider.
+func IsSecretRef(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	_, ok = providers[scheme]
+	return ok
+}
+
+// resolveSecretRef resolves a single reference.
+func resolveSecretRef(ctx context.Context, value string) (string, error) {
+	scheme, rest, _ := strings.Cut(value, "://")
+	ref, key, hasKey := strings.Cut(rest, "#")
+
+	providersMu.RLock()
+	p := providers[scheme]
+	providersMu.RUnlock()
+
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s secret %q: %w", scheme, ref, err)
+	}
+	if !hasKey {
+		return secret, nil
+	}
+	return jsonSecretKey(secret, key)
+}
+
+// jsonSecretKey extracts key from a JSON object secret.
+func jsonSecretKey(secret, key string) (string, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %q", key)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// secretField is a config field whose value came from secret references.
+type secretField struct {
+	env  string   // Env var name, used as the lookup key
+	refs []string // Original values, references and literals
+	list bool     // True for []string fields
+}
+
+// Secrets holds the current values of config fields resolved from secret
+// references, and refreshes them to follow rotation. Safe for concurrent use.
+type Secrets struct {
+	mu     sync.RWMutex
+	fields []secretField
+	values map[string][]string
+}
+
+// ResolveSecrets resolves secret references in all `secret:"true"` fields of
+// cfg, writing the values into cfg. Call once at startup, before cfg is
+// shared. The returned Secrets tracks the referenced fields for rotation.
+func ResolveSecrets(ctx context.Context, cfg *Config) (*Secrets, error) {
+	s := &Secrets{values: make(map[string][]string)}
+	if err := s.collect(ctx, reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// collect walks v, resolving and recording fields that hold references.
+func (s *Secrets) collect(ctx context.Context, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldVal := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			if err := s.collect(ctx, fieldVal); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.Tag.Get("secret") != "true" {
+			continue
+		}
+
+		sf := secretField{env: field.Tag.Get("env")}
+		switch fieldVal.Kind() {
+		case reflect.String:
+			sf.refs = []string{fieldVal.String()}
+		case reflect.Slice:
+			sf.refs = fieldVal.Interface().([]string)
+			sf.list = true
+		default:
+			return fmt.Errorf("secret field %s must be a string or []string", field.Name)
+		}
+		if !slices.ContainsFunc(sf.refs, IsSecretRef) {
+			continue
+		}
+
+		values, err := sf.resolve(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sf.env, err)
+		}
+		if sf.list {
+			fieldVal.Set(reflect.ValueOf(values))
+		} else {
+			fieldVal.SetString(values[0])
+		}
+
+		s.fields = append(s.fields, sf)
+		s.values[sf.env] = values
+	}
+	return nil
+}
+
+// resolve resolves every reference of the field; literals pass through.
+func (sf secretField) resolve(ctx context.Context) ([]string, error) {
+	values := make([]string, 0, len(sf.refs))
+	for _, ref := range sf.refs {
+		if !IsSecretRef(ref) {
+			values = append(values, ref)
+			continue
+		}
+		value, err := resolveSecretRef(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !sf.list {
+			values = append(values, value)
+			continue
+		}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values, nil
+}
+
+// Managed reports whether the field for env was resolved from a reference.
+func (s *Secrets) Managed(env string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.values[env]
+	return ok
+}
+
+// Strings returns the current values of a managed field.
+func (s *Secrets) Strings(env string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[env]
+}
+
+// Get returns the current value of a managed string field.
+func (s *Secrets) Get(env string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[env]
+	if !ok || len(v) == 0 {
+		return "", false
+	}
+	return v[0], true
+}
+
+// APIKeys returns the current API keys, for SecurityConfig.APIKeyProvider.
+func (s *Secrets) APIKeys() []string {
+	return s.Strings("API_KEYS")
+}
+
+// DatabasePassword returns the current database password if it is managed,
+// from DB_PASSWORD or else from the password in DATABASE_URL.
+func (s *Secrets) DatabasePassword() (string, bool) {
+	if pw, ok := s.Get("DB_PASSWORD"); ok {
+		return pw, true
+	}
+	if dbURL, ok := s.Get("DATABASE_URL"); ok {
+		if u, err := url.Parse(dbURL); err == nil && u.User != nil {
+			return u.User.Password()
+		}
+	}
+	return "", false
+}
+
+// Refresh re-resolves all managed fields and returns the env names of those
+// whose value changed. On error, all current values are kept.
+func (s *Secrets) Refresh(ctx context.Context) ([]string, error) {
+	// s.fields is fixed after ResolveSecrets; only values change
+	fresh := make(map[string][]string, len(s.fields))
+	for _, sf := range s.fields {
+		values, err := sf.resolve(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sf.env, err)
+		}
+		fresh[sf.env] = values
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []string
+	for env, values := range fresh {
+		if !slices.Equal(s.values[env], values) {
+			changed = append(changed, env)
+			s.values[env] = values
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Watch refreshes managed fields every interval until ctx is cancelled,
+// calling onChange with the env names of fields that changed. Failed
+// refreshes are logged and retried at the next tick.
+func (s *Secrets) Watch(ctx context.Context, interval time.Duration, onChange func(changed []string)) {
+	if interval <= 0 || len(s.fields) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Refresh(ctx)
+			if err != nil {
+				slog.Error("secret refresh failed", "error", err)
+				continue
+			}
+			if len(changed) > 0 {
+				slog.Info("secrets rotated", "fields", changed)
+				if onChange != nil {
+					onChange(changed)
+				}
+			}
+		}
+	}
+}
+
+// =============================================================================
+// Providers
+// =============================================================================
+
+// resolveFileSecret reads a mounted secret file, without its trailing newline.
+func resolveFileSecret(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveVaultSecret reads a Vault KV secret and returns its data as JSON.
+// KV v2 responses nest the data one level deeper; both are handled.
+func resolveVaultSecret(ctx context.Context, path string) (string, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := doSecretRequest(req, &resp); err != nil {
+		return "", err
+	}
+
+	// KV v2: {"data": {"data": {...}, "metadata": {...}}}
+	if inner, ok := resp.Data["data"]; ok {
+		if _, hasMeta := resp.Data["metadata"]; hasMeta {
+			return string(inner), nil
+		}
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// resolveAWSSecret calls Secrets Manager GetSecretValue, signed with SigV4.
+func resolveAWSSecret(ctx context.Context, secretID string) (string, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if region == "" || accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID, and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	SignAWSRequest(req, body, accessKey, secretKey, region, "secretsmanager", time.Now())
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doSecretRequest(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.SecretString, nil
+}
+
+// SignAWSRequest adds an AWS Signature Version 4 Authorization header.
+// All headers already set on req are signed. Also used by the S3 upload source.
+func SignAWSRequest(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// GCP endpoints; variables so tests can point them at a local server.
+var (
+	gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com"
+	gcpMetadataTokenURL      = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// resolveGCPSecret accesses a Secret Manager version ("latest" if the
+// reference names only the secret).
+func resolveGCPSecret(ctx context.Context, name string) (string, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := GCPAccessToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("get access token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpSecretManagerEndpoint+"/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doSecretRequest(req, &resp); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decode payload: %w", err)
+	}
+	return string(data), nil
+}
+
+// GCPAccessToken returns GOOGLE_OAUTH_ACCESS_TOKEN if set, else a token for
+// the default service account from the metadata server. Also used by the GCS
+// upload source.
+func GCPAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doSecretRequest(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.AccessToken, nil
+}
+
+// doSecretRequest sends req and decodes a JSON response into out.
+// Error bodies are not included, since they may echo sensitive input.
+func doSecretRequest(req *http.Request, out any) error {
+	resp, err := secretHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL.Host, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
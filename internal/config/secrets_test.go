@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveSecrets_FileAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := filepath.Join(dir, "db_password")
+	keysFile := filepath.Join(dir, "api_keys")
+	os.WriteFile(pwFile, []byte("s3cret\n"), 0o600)
+	os.WriteFile(keysFile, []byte("key1, key2"), 0o600)
+
+	cfg := &Config{
+		Database: DatabaseConfig{URL: "postgres://app@db/app", Password: "file://" + pwFile},
+		Security: SecurityConfig{APIKeys: []string{"static", "file://" + keysFile}},
+	}
+
+	secrets, err := ResolveSecrets(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.Database.Password != "s3cret" {
+		t.Errorf("Password = %q, want %q", cfg.Database.Password, "s3cret")
+	}
+	if got := strings.Join(cfg.Security.APIKeys, ","); got != "static,key1,key2" {
+		t.Errorf("APIKeys = %q, want %q", got, "static,key1,key2")
+	}
+	if secrets.Managed("DATABASE_URL") {
+		t.Error("DATABASE_URL has no reference and should not be managed")
+	}
+
+	// Rotate the password
+	os.WriteFile(pwFile, []byte("rotated"), 0o600)
+	changed, err := secrets.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "DB_PASSWORD" {
+		t.Errorf("changed = %v, want [DB_PASSWORD]", changed)
+	}
+	if pw, _ := secrets.DatabasePassword(); pw != "rotated" {
+		t.Errorf("DatabasePassword = %q, want %q", pw, "rotated")
+	}
+}
+
+func TestResolveSecrets_Vault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" || r.URL.Path != "/v1/secret/data/app" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"data":{"db_url":"postgres://u:p@db/app"},"metadata":{"version":3}}}`)
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "tok")
+
+	cfg := &Config{Database: DatabaseConfig{URL: "vault://secret/data/app#db_url"}}
+	secrets, err := ResolveSecrets(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.Database.URL != "postgres://u:p@db/app" {
+		t.Errorf("URL = %q", cfg.Database.URL)
+	}
+	if pw, ok := secrets.DatabasePassword(); !ok || pw != "p" {
+		t.Errorf("DatabasePassword = %q, %v; want password from URL", pw, ok)
+	}
+}
+
+func TestResolveSecrets_AWS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			string(body) != `{"SecretId":"prod/app"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"SecretString":"{\"api_keys\":\"a,b\"}"}`)
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", srv.URL)
+
+	cfg := &Config{Security: SecurityConfig{APIKeys: []string{"awssm://prod/app#api_keys"}}}
+	secrets, err := ResolveSecrets(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if got := strings.Join(secrets.APIKeys(), ","); got != "a,b" {
+		t.Errorf("APIKeys = %q, want %q", got, "a,b")
+	}
+}
+
+func TestResolveSecrets_GCP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" ||
+			r.URL.Path != "/v1/projects/p/secrets/db/versions/latest:access" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"payload":{"data":%q}}`, base64.StdEncoding.EncodeToString([]byte("gcp-pw")))
+	}))
+	defer srv.Close()
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "tok")
+	defer func(old string) { gcpSecretManagerEndpoint = old }(gcpSecretManagerEndpoint)
+	gcpSecretManagerEndpoint = srv.URL
+
+	cfg := &Config{Database: DatabaseConfig{Password: "gcpsm://projects/p/secrets/db"}}
+	if _, err := ResolveSecrets(context.Background(), cfg); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.Database.Password != "gcp-pw" {
+		t.Errorf("Password = %q, want %q", cfg.Database.Password, "gcp-pw")
+	}
+}
+
+func TestResolveSecrets_ErrorNamesField(t *testing.T) {
+	cfg := &Config{Database: DatabaseConfig{Password: "file:///nonexistent/secret"}}
+	_, err := ResolveSecrets(context.Background(), cfg)
+	if err == nil || !contains(err.Error(), "DB_PASSWORD") {
+		t.Errorf("expected error naming DB_PASSWORD, got %v", err)
+	}
+}
+
+func TestSignAWSRequest_KnownSignature(t *testing.T) {
+	// "get-vanilla" from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	SignAWSRequest(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n  %s\nwant\n  %s", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+// activity_events.go broadcasts what is happening across all tables, for a
+// live activity feed: uploads starting and finishing, rollbacks, resets and
+// bulk edits.
+//
+// Unlike table events (see table_events.go), activity events are not
+// debounced and carry no stats: each action is one event, sent as it
+// happens. They name no user, as every subscriber sees them. A subscriber
+// that does not keep up misses events.
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ActivityKind names what an ActivityEvent reports.
+type ActivityKind string
+
+const (
+	ActivityUploadStarted   ActivityKind = "upload_started"
+	ActivityUploadCompleted ActivityKind = "upload_completed" // Committed
+	ActivityUploadFailed    ActivityKind = "upload_failed"    // Failed or cancelled; nothing was written
+	ActivityRollback        ActivityKind = "rollback"         // A committed upload was rolled back
+	ActivityReset           ActivityKind = "reset"
+	ActivityBulkEdit        ActivityKind = "bulk_edit"
+)
+
+// ActivityEvent is one action on a table.
+type ActivityEvent struct {
+	Kind     ActivityKind `json:"kind"`
+	TableKey string       `json:"table_key"`
+	UploadID string       `json:"upload_id,omitempty"` // In-memory upload (progress API)
+	RecordID string       `json:"record_id,omitempty"` // csv_uploads row, once committed
+	FileName string       `json:"file_name,omitempty"`
+	Rows     int64        `json:"rows,omitempty"`   // Rows written, rolled back, reset or edited
+	Column   string       `json:"column,omitempty"` // bulk_edit: the columns edited
+	Error    string       `json:"error,omitempty"`  // upload_failed: why
+	Time     time.Time    `json:"time"`
+}
+
+// activityHub fans activity events out to subscribers.
+type activityHub struct {
+	mu   sync.Mutex
+	subs map[chan ActivityEvent]struct{}
+}
+
+func newActivityHub() *activityHub {
+	return &activityHub{subs: make(map[chan ActivityEvent]struct{})}
+}
+
+// subscribe returns a channel of events. It is closed when ctx is done.
+func (h *activityHub) subscribe(ctx context.Context) <-chan ActivityEvent {
+	ch := make(chan ActivityEvent, 32)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs, ch)
+		close(ch) // Sends happen under h.mu, so none can follow
+		h.mu.Unlock()
+	}()
+	return ch
+}
+
+// publish sends ev to every subscriber, skipping those that are behind.
+func (h *activityHub) publish(ev ActivityEvent) {
+	if h == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			slog.Debug("dropped activity event for slow subscriber", "kind", ev.Kind, "table", ev.TableKey)
+		}
+	}
+}
+
+// SubscribeEvents streams activity across all tables: uploads started,
+// committed and failed, rollbacks, resets and bulk edits. The channel is
+// closed when ctx is done.
+func (s *Service) SubscribeEvents(ctx context.Context) <-chan ActivityEvent {
+	return s.activity.subscribe(ctx)
+}
+
+// uploadStartedActivity reports that upload began processing. Dry runs are
+// not reported.
+func (s *Service) uploadStartedActivity(upload *activeUpload) {
+	if upload.DryRun {
+		return
+	}
+	s.activity.publish(ActivityEvent{
+		Kind:     ActivityUploadStarted,
+		TableKey: upload.TableKey,
+		UploadID: upload.ID,
+		FileName: upload.FileName,
+	})
+}
+
+// uploadActivity reports the end of an upload, or the rollback of a
+// committed one, from its after_* hook event.
+func (s *Service) uploadActivity(ev UploadEvent) {
+	a := ActivityEvent{
+		TableKey: ev.TableKey,
+		UploadID: ev.UploadID,
+		RecordID: ev.RecordID,
+		FileName: ev.FileName,
+		Time:     ev.Time,
+	}
+	switch {
+	case ev.Event == HookAfterCommit:
+		a.Kind = ActivityUploadCompleted
+		a.Rows = int64(ev.Inserted + ev.Updated + ev.Deleted)
+	case ev.Event == HookAfterRollback && ev.RecordID != "":
+		a.Kind = ActivityRollback
+		a.Rows = ev.RowsDeleted
+	case ev.Event == HookAfterRollback:
+		a.Kind = ActivityUploadFailed
+		a.Error = ev.Error
+	default:
+		return
+	}
+	s.activity.publish(a)
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActivityEvents(t *testing.T) {
+	s := &Service{activity: newActivityHub()}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := s.SubscribeEvents(ctx)
+
+	s.uploadStartedActivity(&activeUpload{ID: "u1", TableKey: "invoices", FileName: "jan.csv"})
+	s.uploadStartedActivity(&activeUpload{ID: "dry-run", TableKey: "invoices", DryRun: true})
+	s.uploadActivity(UploadEvent{Event: HookAfterBatch, UploadID: "u1", TableKey: "invoices"})
+	s.uploadActivity(UploadEvent{Event: HookAfterCommit, UploadID: "u1", RecordID: "r1", TableKey: "invoices", Inserted: 8, Updated: 2})
+	s.uploadActivity(UploadEvent{Event: HookAfterRollback, UploadID: "u2", TableKey: "invoices", Error: "cancelled"})
+	s.uploadActivity(rollbackEvent("invoices", "r1", 10))
+
+	want := []ActivityEvent{
+		{Kind: ActivityUploadStarted, UploadID: "u1", FileName: "jan.csv"},
+		{Kind: ActivityUploadCompleted, UploadID: "u1", RecordID: "r1", Rows: 10},
+		{Kind: ActivityUploadFailed, UploadID: "u2", Error: "cancelled"},
+		{Kind: ActivityRollback, RecordID: "r1", Rows: 10},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Kind != w.Kind || ev.TableKey != "invoices" || ev.UploadID != w.UploadID || ev.RecordID != w.RecordID ||
+				ev.FileName != w.FileName || ev.Rows != w.Rows || ev.Error != w.Error || ev.Time.IsZero() {
+				t.Errorf("event %d = %+v, want %+v", i, ev, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing event %d (%s)", i, w.Kind)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event %+v", ev)
+	default:
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected channel closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	// A service without a hub drops events
+	(&Service{}).uploadActivity(UploadEvent{Event: HookAfterCommit, TableKey: "invoices"})
+}
@@ -0,0 +1,206 @@
+package core
+
+// aggregate_access.go gives people who should see financial summaries but
+// not individual transactions an aggregates-only access level: a user
+// created with AggregatesOnly, or an API token with the aggregate scope.
+// The web layer only lets them reach the summary and histogram APIs, and
+// the queries behind those check the caller again here:
+//
+//   - min and max are refused, since they are always one row's value
+//   - summary groups counting fewer rows than the table's minimum group
+//     size are left out, as are histogram counts below it, and the result
+//     says how many were suppressed
+//
+// The minimum is TableDefinition.MinGroupSize, or
+// QUERY_AGGREGATE_MIN_GROUP_SIZE for tables that set none. Suppression
+// applies to each result on its own: callers who can vary filters can
+// still difference two results, so this widens access to summaries, not
+// to data that must never leak.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrAggregatesOnly is returned when an aggregates-only caller asks for
+// something that could show a single row.
+var ErrAggregatesOnly = errors.New("not allowed with aggregates-only access")
+
+// DefaultAggregateMinGroupSize is the minimum group size when neither the
+// table nor QUERY_AGGREGATE_MIN_GROUP_SIZE sets one.
+const DefaultAggregateMinGroupSize = 10
+
+// MaxHistogramBuckets caps the buckets of a histogram.
+const MaxHistogramBuckets = 100
+
+// AggregatesOnly reports whether the caller in ctx may only see
+// aggregates: a user with AggregatesOnly, or an API token with the
+// aggregate scope.
+func AggregatesOnly(ctx context.Context) bool {
+	if u := GetUserFromContext(ctx); u != nil {
+		return u.AggregatesOnly
+	}
+	if t := GetAPITokenFromContext(ctx); t != nil {
+		return !t.HasScope(ScopeRead)
+	}
+	return false
+}
+
+// minGroupSize returns the fewest rows a group or bin of def may count
+// before it is suppressed for aggregates-only callers.
+func (s *Service) minGroupSize(def TableDefinition) int {
+	if def.MinGroupSize > 0 {
+		return def.MinGroupSize
+	}
+	if s.cfg.Query.AggregateMinGroupSize > 0 {
+		return s.cfg.Query.AggregateMinGroupSize
+	}
+	return DefaultAggregateMinGroupSize
+}
+
+// checkAggregateSpecs refuses the aggregates that pick out one row's value.
+func checkAggregateSpecs(aggs []AggSpec) error {
+	for _, a := range aggs {
+		if a.Func == AggMin || a.Func == AggMax {
+			return fmt.Errorf("%w: %s shows a single row's value", ErrAggregatesOnly, a.Func)
+		}
+	}
+	return nil
+}
+
+// suppressSmallGroups drops the groups of result counting fewer than
+// minSize rows and counts them in result.Suppressed.
+func suppressSmallGroups(result *SummaryResult, minSize int) {
+	kept := result.Groups[:0]
+	for _, g := range result.Groups {
+		if g.Count < int64(minSize) {
+			result.Suppressed++
+			continue
+		}
+		kept = append(kept, g)
+	}
+	result.Groups = kept
+}
+
+// HistogramBin is one bucket of a histogram, holding values from From up
+// to but not including To.
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count *int64  `json:"count"` // Nil when suppressed
+}
+
+// HistogramResult counts the values of a numeric column in equal-width
+// buckets between bounds the caller chose.
+type HistogramResult struct {
+	TableKey   string         `json:"tableKey"`
+	Column     string         `json:"column"`
+	Bins       []HistogramBin `json:"bins"`
+	Below      *int64         `json:"below"`                // Values under the first bucket; nil when suppressed
+	Above      *int64         `json:"above"`                // Values at or over the last; nil when suppressed
+	Suppressed int            `json:"suppressed,omitempty"` // Counts hidden from aggregates-only callers
+}
+
+// GetHistogram counts the values of column in the rows of tableKey
+// matching filters, split into equal-width buckets between from and to.
+// Empty values are not counted. The bounds are the caller's, so the histogram
+// never shows the smallest or largest value.
+func (s *Service) GetHistogram(ctx context.Context, tableKey, column string, from, to float64, buckets int, filters FilterSet) (*HistogramResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
+	wb.AddFilters(filters)
+	whereClause, queryArgs := wb.Build()
+
+	name, sel, err := histogramSelect(def, column, from, to, buckets, wb.NextArgIndex())
+	if err != nil {
+		return nil, err
+	}
+	sql := "SELECT " + sel + ", COUNT(*) FROM " + quoteIdentifier(tableKey) + whereClause + " GROUP BY 1"
+	queryArgs = append(queryArgs, from, to)
+
+	rows, err := s.pool.Query(ctx, sql, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query histogram: %w", err)
+	}
+	defer rows.Close()
+
+	// Buckets 1..buckets are the bins; 0 is below and buckets+1 above
+	counts := make([]int64, buckets+2)
+	for rows.Next() {
+		var (
+			bucket *int32
+			n      int64
+		)
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, fmt.Errorf("scan histogram: %w", err)
+		}
+		if bucket != nil { // Empty values
+			counts[*bucket] = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	minSize := 0
+	if AggregatesOnly(ctx) {
+		minSize = s.minGroupSize(def)
+	}
+	return newHistogram(tableKey, name, from, to, counts, minSize), nil
+}
+
+// histogramSelect validates a histogram request against def and returns
+// the column's display name and the expression numbering its buckets,
+// with the bounds as placeholders $argIdx and $argIdx+1.
+func histogramSelect(def TableDefinition, column string, from, to float64, buckets, argIdx int) (string, string, error) {
+	name, spec, err := summaryColumn(def, column)
+	if err != nil {
+		return "", "", err
+	}
+	if spec.Type != FieldNumeric {
+		return "", "", fmt.Errorf("%w: a histogram needs a numeric column; %s is not", ErrInvalidSummary, name)
+	}
+	if buckets < 1 || buckets > MaxHistogramBuckets {
+		return "", "", fmt.Errorf("%w: buckets must be between 1 and %d", ErrInvalidSummary, MaxHistogramBuckets)
+	}
+	if math.IsNaN(from) || math.IsInf(from, 0) || math.IsNaN(to) || math.IsInf(to, 0) || from >= to {
+		return "", "", fmt.Errorf("%w: from must be a number below to", ErrInvalidSummary)
+	}
+	col := quoteIdentifier(resolveDBColumn(name, def.FieldSpecs))
+	return name, fmt.Sprintf("width_bucket(%s::float8, $%d::float8, $%d::float8, %d)", col, argIdx, argIdx+1, buckets), nil
+}
+
+// newHistogram builds a histogram from the counts of each width_bucket
+// number, suppressing counts from 1 to minSize-1.
+func newHistogram(tableKey, column string, from, to float64, counts []int64, minSize int) *HistogramResult {
+	buckets := len(counts) - 2
+	h := &HistogramResult{TableKey: tableKey, Column: column, Bins: make([]HistogramBin, buckets)}
+	count := func(n int64) *int64 {
+		if n > 0 && n < int64(minSize) {
+			h.Suppressed++
+			return nil
+		}
+		return &n
+	}
+	width := (to - from) / float64(buckets)
+	for i := range h.Bins {
+		h.Bins[i] = HistogramBin{
+			From:  from + width*float64(i),
+			To:    from + width*float64(i+1),
+			Count: count(counts[i+1]),
+		}
+	}
+	h.Bins[buckets-1].To = to
+	h.Below = count(counts[0])
+	h.Above = count(counts[buckets+1])
+	return h
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestAggregatesOnly(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"anonymous", ctx, false},
+		{"user", ContextWithUser(ctx, &User{ID: "u1"}), false},
+		{"aggregates-only user", ContextWithUser(ctx, &User{ID: "u1", AggregatesOnly: true}), true},
+		{"read token", ContextWithAPIToken(ctx, &APIToken{Scope: ScopeRead}), false},
+		{"aggregate token", ContextWithAPIToken(ctx, &APIToken{Scope: ScopeAggregate}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AggregatesOnly(tt.ctx); got != tt.want {
+				t.Errorf("AggregatesOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinGroupSize(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	if got := s.minGroupSize(TableDefinition{}); got != DefaultAggregateMinGroupSize {
+		t.Errorf("default = %d, want %d", got, DefaultAggregateMinGroupSize)
+	}
+	s.cfg.Query.AggregateMinGroupSize = 5
+	if got := s.minGroupSize(TableDefinition{}); got != 5 {
+		t.Errorf("configured = %d, want 5", got)
+	}
+	if got := s.minGroupSize(TableDefinition{MinGroupSize: 25}); got != 25 {
+		t.Errorf("per table = %d, want 25", got)
+	}
+}
+
+func TestCheckAggregateSpecs(t *testing.T) {
+	if err := checkAggregateSpecs([]AggSpec{{Func: AggSum, Column: "Amount"}, {Func: AggCount}}); err != nil {
+		t.Errorf("sum and count: %v", err)
+	}
+	for _, fn := range []AggFunc{AggMin, AggMax} {
+		err := checkAggregateSpecs([]AggSpec{{Func: AggAvg, Column: "Amount"}, {Func: fn, Column: "Amount"}})
+		if !errors.Is(err, ErrAggregatesOnly) {
+			t.Errorf("%s: err = %v, want ErrAggregatesOnly", fn, err)
+		}
+	}
+}
+
+func TestSuppressSmallGroups(t *testing.T) {
+	key := func(s string) []*string { return []*string{&s} }
+	result := &SummaryResult{Groups: []SummaryGroup{
+		{Keys: key("Acme"), Count: 12},
+		{Keys: key("Globex"), Count: 1},
+		{Keys: key("Initech"), Count: 10},
+		{Keys: key("Umbrella"), Count: 9},
+	}}
+	suppressSmallGroups(result, 10)
+
+	if result.Suppressed != 2 {
+		t.Errorf("Suppressed = %d, want 2", result.Suppressed)
+	}
+	var kept []string
+	for _, g := range result.Groups {
+		kept = append(kept, *g.Keys[0])
+	}
+	if got := strings.Join(kept, ","); got != "Acme,Initech" {
+		t.Errorf("groups = %s, want Acme,Initech", got)
+	}
+}
+
+func TestHistogramSelect(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "hist_invoices"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Customer", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+		},
+	}
+
+	name, sel, err := histogramSelect(def, "amount", 0, 1000, 10, 3)
+	if err != nil {
+		t.Fatalf("histogramSelect: %v", err)
+	}
+	if name != "Amount" {
+		t.Errorf("name = %q, want Amount", name)
+	}
+	if want := `width_bucket("amount"::float8, $3::float8, $4::float8, 10)`; sel != want {
+		t.Errorf("select = %s, want %s", sel, want)
+	}
+
+	bad := []struct {
+		column   string
+		from, to float64
+		buckets  int
+	}{
+		{"Customer", 0, 1000, 10},
+		{"Missing", 0, 1000, 10},
+		{"Amount", 0, 1000, 0},
+		{"Amount", 0, 1000, MaxHistogramBuckets + 1},
+		{"Amount", 1000, 0, 10},
+		{"Amount", 5, 5, 10},
+	}
+	for _, b := range bad {
+		if _, _, err := histogramSelect(def, b.column, b.from, b.to, b.buckets, 1); !errors.Is(err, ErrInvalidSummary) {
+			t.Errorf("histogramSelect(%+v) = %v, want ErrInvalidSummary", b, err)
+		}
+	}
+}
+
+func TestNewHistogram(t *testing.T) {
+	// below, bins 1-4, above
+	counts := []int64{3, 0, 12, 4, 20, 15}
+
+	h := newHistogram("invoices", "Amount", 0, 100, counts, 0)
+	if h.Suppressed != 0 || *h.Below != 3 || *h.Above != 15 {
+		t.Errorf("unsuppressed: below %v above %v suppressed %d", h.Below, h.Above, h.Suppressed)
+	}
+	if len(h.Bins) != 4 || h.Bins[1].From != 25 || h.Bins[1].To != 50 || *h.Bins[1].Count != 12 {
+		t.Errorf("bins = %+v", h.Bins)
+	}
+
+	h = newHistogram("invoices", "Amount", 0, 100, counts, 10)
+	if h.Suppressed != 2 {
+		t.Errorf("Suppressed = %d, want 2", h.Suppressed)
+	}
+	if h.Below != nil || h.Bins[2].Count != nil {
+		t.Error("counts under 10 shown")
+	}
+	if h.Bins[0].Count == nil || *h.Bins[0].Count != 0 {
+		t.Error("empty bin suppressed")
+	}
+	if *h.Bins[3].Count != 20 || *h.Above != 15 {
+		t.Error("counts of 10 or more suppressed")
+	}
+}
@@ -0,0 +1,233 @@
+package core
+
+// anonymize.go produces anonymized samples of a table, so realistic test
+// files can be shared with developers and vendors without real customer data.
+//
+// Each FieldSpec's Mask is the table's masking policy. Masked text columns
+// get fake values derived from an HMAC of the real value under a key
+// generated per export: the same value becomes the same fake everywhere in
+// the file (so joins and group-bys still work), but exports cannot be
+// correlated with each other or reversed. Numeric and date columns keep
+// their distributions: amounts are jittered by a few percent per value and
+// every date is shifted by one offset chosen per export, so ordering and
+// gaps between dates survive. Unmasked text, enum and bool columns are
+// copied as-is.
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaskKind is how a column is anonymized in sample exports.
+type MaskKind string
+
+const (
+	MaskNone   MaskKind = ""       // Copied as-is (numeric and date columns are still perturbed)
+	MaskName   MaskKind = "name"   // Fake person or company name, e.g. "Amber Falcon 417"
+	MaskEmail  MaskKind = "email"  // Fake address at example.com
+	MaskPhone  MaskKind = "phone"  // Fake 555 number
+	MaskID     MaskKind = "id"     // Same length and shape: letters, digits and punctuation kept in place
+	MaskRedact MaskKind = "redact" // Always empty
+)
+
+const (
+	// DefaultSampleRows is the sample size when the request does not set one.
+	DefaultSampleRows = 1000
+
+	// MaxSampleRows caps the sample size of an anonymized export.
+	MaxSampleRows = 50000
+
+	// numericJitter is the largest relative change made to a numeric value.
+	numericJitter = 0.05
+
+	// maxDateShiftDays is the largest shift applied to the dates of an export.
+	maxDateShiftDays = 90
+)
+
+// exportDateLayout is the date format used by table exports of columns
+// without a display layout.
+const exportDateLayout = "2006-01-02"
+
+var fakeAdjectives = []string{
+	"Amber", "Bright", "Cedar", "Copper", "Crimson", "Golden", "Granite", "Hollow",
+	"Ivory", "Jade", "Lunar", "Maple", "Misty", "Noble", "Quiet", "Silver",
+}
+
+var fakeNouns = []string{
+	"Falcon", "Harbor", "Meadow", "Ridge", "River", "Summit", "Willow", "Anchor",
+	"Beacon", "Canyon", "Forest", "Lantern", "Orchard", "Pine", "Stone", "Valley",
+}
+
+// checkMask reports whether a mask can be applied to a field type.
+func checkMask(mask MaskKind, ft FieldType) error {
+	switch mask {
+	case MaskNone, MaskRedact:
+		return nil
+	case MaskName, MaskEmail, MaskPhone, MaskID:
+		if ft != FieldText {
+			return fmt.Errorf("mask %q needs a text field", mask)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mask %q (want name, email, phone, id or redact)", mask)
+	}
+}
+
+// Anonymizer rewrites exported records according to a table's masking
+// policy. Create one per export with NewAnonymizer.
+type Anonymizer struct {
+	fields    []anonField
+	key       []byte
+	dateShift time.Duration
+}
+
+type anonField struct {
+	ftype      FieldType
+	mask       MaskKind
+	dateLayout string // Layout dates are exported in
+}
+
+// NewAnonymizer returns an anonymizer for records holding the given
+// columns of def, in order, with a fresh random key and date shift.
+func NewAnonymizer(def TableDefinition, columns []string) (*Anonymizer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate anonymization key: %w", err)
+	}
+	return newAnonymizer(def, columns, key), nil
+}
+
+// newAnonymizer builds an anonymizer from a fixed key, for tests.
+func newAnonymizer(def TableDefinition, columns []string, key []byte) *Anonymizer {
+	a := &Anonymizer{fields: make([]anonField, len(columns)), key: key}
+
+	specs := columnSpecs(def)
+	for i, col := range columns {
+		if spec, ok := specs[strings.ToLower(col)]; ok {
+			a.fields[i] = anonField{ftype: spec.Type, mask: spec.Mask, dateLayout: spec.Display.dateLayout()}
+		}
+	}
+
+	// Shift by 1..maxDateShiftDays days, forwards or backwards
+	h := a.hash("date-shift", "")
+	days := int(h%maxDateShiftDays) + 1
+	if h&(1<<63) != 0 {
+		days = -days
+	}
+	a.dateShift = time.Duration(days) * 24 * time.Hour
+	return a
+}
+
+// Anonymize returns an anonymized copy of record, whose values are
+// formatted as in a table export.
+func (a *Anonymizer) Anonymize(record []string) []string {
+	out := make([]string, len(record))
+	for i, v := range record {
+		if i >= len(a.fields) || v == "" {
+			out[i] = v
+			continue
+		}
+		out[i] = a.value(a.fields[i], v)
+	}
+	return out
+}
+
+// value anonymizes one non-empty value.
+func (a *Anonymizer) value(f anonField, v string) string {
+	switch f.mask {
+	case MaskRedact:
+		return ""
+	case MaskName:
+		h := a.hash(string(f.mask), v)
+		return fmt.Sprintf("%s %s %03d",
+			fakeAdjectives[h%uint64(len(fakeAdjectives))],
+			fakeNouns[(h>>8)%uint64(len(fakeNouns))],
+			(h>>16)%1000)
+	case MaskEmail:
+		// Lowercased so addresses differing only in case stay equal
+		sum := a.sum(string(f.mask), strings.ToLower(v))
+		return "user-" + hex.EncodeToString(sum[:5]) + "@example.com"
+	case MaskPhone:
+		h := a.hash(string(f.mask), v)
+		return fmt.Sprintf("555-%03d-%04d", (h>>32)%1000, h%10000)
+	case MaskID:
+		return a.shape(v)
+	}
+
+	switch f.ftype {
+	case FieldNumeric:
+		return a.numeric(v)
+	case FieldDate:
+		t, err := time.Parse(f.dateLayout, v)
+		if err != nil {
+			// Not a date after all; drop it rather than leak it
+			return ""
+		}
+		return t.Add(a.dateShift).Format(f.dateLayout)
+	}
+	return v
+}
+
+// numeric jitters a number by up to numericJitter, keeping its sign and
+// number of decimal places. Equal inputs get equal outputs.
+func (a *Anonymizer) numeric(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return ""
+	}
+	decimals := 0
+	if dot := strings.IndexByte(v, '.'); dot >= 0 {
+		decimals = len(v) - dot - 1
+	}
+
+	// Map the hash to [-1, 1)
+	u := float64(a.hash("numeric", v)>>11)/float64(1<<53)*2 - 1
+	f *= 1 + u*numericJitter
+
+	scale := math.Pow(10, float64(decimals))
+	f = math.Round(f*scale) / scale
+	return strconv.FormatFloat(f, 'f', decimals, 64)
+}
+
+// shape replaces every letter and digit of v with a pseudo-random one of
+// the same class and case, keeping length and punctuation.
+func (a *Anonymizer) shape(v string) string {
+	sum := a.sum("id", v)
+	var b strings.Builder
+	for i, r := range v {
+		n := int(sum[i%len(sum)]) + i/len(sum)
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteByte(byte('0' + n%10))
+		case r >= 'a' && r <= 'z':
+			b.WriteByte(byte('a' + n%26))
+		case r >= 'A' && r <= 'Z':
+			b.WriteByte(byte('A' + n%26))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sum returns the HMAC of a value, namespaced by kind.
+func (a *Anonymizer) sum(kind, v string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(v))
+	return mac.Sum(nil)
+}
+
+// hash returns the first eight bytes of sum as an integer.
+func (a *Anonymizer) hash(kind, v string) uint64 {
+	return binary.BigEndian.Uint64(a.sum(kind, v))
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func anonymizeTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "anonymize_test"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Account ID", Type: FieldText, Mask: MaskID},
+			{Name: "Account", Type: FieldText, Mask: MaskName},
+			{Name: "Email", Type: FieldText, Mask: MaskEmail},
+			{Name: "Phone", Type: FieldText, Mask: MaskPhone},
+			{Name: "Notes", Type: FieldText, Mask: MaskRedact},
+			{Name: "Status", Type: FieldEnum, EnumValues: []string{"Open", "Closed"}},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Close Date", Type: FieldDate},
+		},
+	}
+}
+
+func TestAnonymizer_Masks(t *testing.T) {
+	def := anonymizeTestTable()
+	cols := []string{"Account ID", "Account", "Email", "Phone", "Notes", "Status", "Amount", "Close Date"}
+	a := newAnonymizer(def, cols, []byte("test-key"))
+
+	in := []string{"001Ab00000XyZ-1", "Acme Corp", "Jane@Acme.com", "+1 415 555 1234", "call back", "Open", "1250.50", "2024-03-15"}
+	out := a.Anonymize(in)
+
+	for i := 0; i < 4; i++ {
+		if out[i] == in[i] || out[i] == "" {
+			t.Errorf("%s not masked: %q", cols[i], out[i])
+		}
+	}
+	if len(out[0]) != len(in[0]) || out[0][3] < 'A' || out[0][3] > 'Z' || out[0][13] != '-' {
+		t.Errorf("id shape not kept: %q", out[0])
+	}
+	if !strings.HasSuffix(out[2], "@example.com") || !strings.HasPrefix(out[3], "555-") {
+		t.Errorf("email %q phone %q", out[2], out[3])
+	}
+	if out[4] != "" {
+		t.Errorf("redacted = %q", out[4])
+	}
+	if out[5] != "Open" {
+		t.Errorf("enum changed: %q", out[5])
+	}
+
+	// Same input, same fake: joins survive. Email ignores case.
+	again := a.Anonymize([]string{"001Ab00000XyZ-1", "Acme Corp", "jane@acme.com", "", "", "", "", ""})
+	for i := 0; i < 3; i++ {
+		if again[i] != out[i] {
+			t.Errorf("%s not consistent: %q vs %q", cols[i], again[i], out[i])
+		}
+	}
+	if again[3] != "" {
+		t.Errorf("empty value became %q", again[3])
+	}
+
+	// A different key gives different fakes
+	other := newAnonymizer(def, cols, []byte("other-key")).Anonymize(in)
+	if other[1] == out[1] && other[2] == out[2] {
+		t.Errorf("fakes do not depend on the key: %q", other[1])
+	}
+}
+
+func TestAnonymizer_Distributions(t *testing.T) {
+	def := anonymizeTestTable()
+	cols := []string{"Amount", "Close Date"}
+	a := newAnonymizer(def, cols, []byte("test-key"))
+
+	for _, amount := range []string{"1250.50", "-80.00", "3", "0"} {
+		got := a.Anonymize([]string{amount, ""})[0]
+		want, gotF := parseTestFloat(t, amount), parseTestFloat(t, got)
+		if gotF < want-math.Abs(want)*numericJitter-0.01 || gotF > want+math.Abs(want)*numericJitter+0.01 {
+			t.Errorf("%s -> %s, outside jitter", amount, got)
+		}
+		if strings.Contains(amount, ".") != strings.Contains(got, ".") {
+			t.Errorf("%s -> %s, decimals changed", amount, got)
+		}
+	}
+
+	// Every date moves by the same offset, so gaps are kept
+	d1 := a.Anonymize([]string{"", "2024-01-01"})[1]
+	d2 := a.Anonymize([]string{"", "2024-01-31"})[1]
+	t1, _ := time.Parse(exportDateLayout, d1)
+	t2, _ := time.Parse(exportDateLayout, d2)
+	if d1 == "2024-01-01" || t2.Sub(t1) != 30*24*time.Hour {
+		t.Errorf("dates %s, %s", d1, d2)
+	}
+}
+
+func TestCheckMask(t *testing.T) {
+	if err := checkMask(MaskRedact, FieldNumeric); err != nil {
+		t.Errorf("redact numeric: %v", err)
+	}
+	if err := checkMask(MaskEmail, FieldDate); err == nil {
+		t.Error("email on a date field accepted")
+	}
+	if err := checkMask("hash", FieldText); err == nil {
+		t.Error("unknown mask accepted")
+	}
+}
+
+func parseTestFloat(t *testing.T, s string) float64 {
+	t.Helper()
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+	}
+	return f
+}
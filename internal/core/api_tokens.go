@@ -0,0 +1,316 @@
+package core
+
+// api_tokens.go keeps the API tokens scripts and integrations authenticate
+// with in the X-API-Key header, so each gets its own credential instead of
+// a shared key from API_KEYS.
+//
+// A token has a scope - aggregate, read, write or destructive, each
+// including the ones before it - and may be limited to some tables. It can expire, and
+// can be revoked at any time. Only the SHA-256 of a token is stored; the
+// token itself is returned once, when it is created.
+//
+// The web layer puts the token a request authenticated with in its
+// context (see ContextWithAPIToken), and every audit entry written with
+// that context is attributed to it.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// API token errors.
+var (
+	ErrAPITokenNotFound = errors.New("API token not found")
+	ErrInvalidAPIToken  = errors.New("invalid API token")
+	ErrAPITokenUnknown  = errors.New("unknown or revoked API token")
+	ErrAPITokenExpired  = errors.New("API token expired")
+)
+
+// TokenScope is what an API token may do.
+type TokenScope string
+
+const (
+	ScopeAggregate   TokenScope = "aggregate"   // Summaries and histograms only
+	ScopeRead        TokenScope = "read"        // GET requests
+	ScopeWrite       TokenScope = "write"       // Uploads, templates, views and other changes
+	ScopeDestructive TokenScope = "destructive" // Deletes, edits, resets, rollbacks and administration
+)
+
+// level orders scopes; 0 for an unknown scope.
+func (s TokenScope) level() int {
+	switch s {
+	case ScopeAggregate:
+		return 1
+	case ScopeRead:
+		return 2
+	case ScopeWrite:
+		return 3
+	case ScopeDestructive:
+		return 4
+	}
+	return 0
+}
+
+// API token settings
+const (
+	// APITokenPrefix starts every API token, telling it apart from the
+	// keys in API_KEYS.
+	APITokenPrefix       = "tok_"
+	apiTokenBytes        = 32
+	apiTokenShownChars   = len(APITokenPrefix) + 8 // Kept as APIToken.Prefix
+	apiTokenUsedInterval = time.Minute             // How often LastUsedAt is updated
+)
+
+// APIToken is an API token. The token itself is only known when it is
+// created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the token
+	Scope      TokenScope `json:"scope"`
+	Tables     []string   `json:"tables"` // Empty for all tables
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	OwnerID    string     `json:"ownerId,omitempty"` // User who created it; "" if created with an API key
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Allows reports whether t may make a request needing scope on tableKey.
+// A token limited to tables may not make requests on no table ("") either:
+// which table's data they show is not known.
+func (t *APIToken) Allows(scope TokenScope, tableKey string) bool {
+	if !t.HasScope(scope) {
+		return false
+	}
+	return len(t.Tables) == 0 || slices.Contains(t.Tables, tableKey)
+}
+
+// HasScope reports whether t's scope includes scope.
+func (t *APIToken) HasScope(scope TokenScope) bool {
+	return t.Scope.level() >= scope.level()
+}
+
+// APITokenParams contains the fields of an API token to create.
+type APITokenParams struct {
+	Name      string     `json:"name"`
+	Scope     TokenScope `json:"scope"`
+	Tables    []string   `json:"tables"`
+	ExpiresAt *time.Time `json:"expiresAt"` // Nil never expires
+}
+
+// NewAPIToken is a created API token with the token itself.
+type NewAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// validateAPIToken checks p and returns it with the name trimmed and the
+// tables sorted.
+func validateAPIToken(p APITokenParams, now time.Time) (APITokenParams, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return p, fmt.Errorf("%w: name is required", ErrInvalidAPIToken)
+	}
+	if p.Scope.level() == 0 {
+		return p, fmt.Errorf("%w: scope must be aggregate, read, write or destructive, not %q", ErrInvalidAPIToken, p.Scope)
+	}
+	for _, key := range p.Tables {
+		if _, ok := Get(key); !ok {
+			return p, fmt.Errorf("%w: unknown table %q", ErrInvalidAPIToken, key)
+		}
+	}
+	p.Tables = slices.Compact(slices.Sorted(slices.Values(p.Tables)))
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(now) {
+		return p, fmt.Errorf("%w: expiresAt is in the past", ErrInvalidAPIToken)
+	}
+	return p, nil
+}
+
+// CreateAPIToken creates an API token and returns it with the token
+// itself, which is not stored.
+func (s *Service) CreateAPIToken(ctx context.Context, p APITokenParams) (*NewAPIToken, error) {
+	p, err := validateAPIToken(p, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, apiTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate API token: %w", err)
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	var expires pgtype.Timestamptz
+	if p.ExpiresAt != nil {
+		expires = pgtype.Timestamptz{Time: *p.ExpiresAt, Valid: true}
+	}
+	tables := p.Tables
+	if tables == nil {
+		tables = []string{}
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	created, err := scanAPIToken(s.pool.QueryRow(ctx,
+		`INSERT INTO api_tokens (name, token_hash, prefix, scope, table_keys, created_by, owner_id, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+apiTokenColumns,
+		p.Name, tokenHash(token), token[:apiTokenShownChars], string(p.Scope), tables, contextActor(ctx), ToPgUUID(contextOwner(ctx)), expires,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create API token: %w", err)
+	}
+	return &NewAPIToken{APIToken: *created, Token: token}, nil
+}
+
+// ListAPITokens returns all API tokens, revoked and expired ones included,
+// newest first.
+func (s *Service) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan API token: %w", err)
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken revokes an API token at once. The token is kept so the
+// audit log still names it.
+func (s *Service) RevokeAPIToken(ctx context.Context, id string) (*APIToken, error) {
+	tid := ToPgUUID(id)
+	if !tid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrAPITokenNotFound, id)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	t, err := scanAPIToken(s.pool.QueryRow(ctx,
+		`UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, NOW())
+		 WHERE id = $1
+		 RETURNING `+apiTokenColumns,
+		tid,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPITokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("revoke API token: %w", err)
+	}
+	return t, nil
+}
+
+// AuthenticateAPIToken returns the API token token, or ErrAPITokenUnknown
+// or ErrAPITokenExpired if it may not be used.
+func (s *Service) AuthenticateAPIToken(ctx context.Context, token string) (*APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, ErrAPITokenUnknown
+	}
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	t, err := scanAPIToken(s.pool.QueryRow(ctx,
+		`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = $1`,
+		tokenHash(token),
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPITokenUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up API token: %w", err)
+	}
+	now := time.Now()
+	switch {
+	case t.RevokedAt != nil:
+		return nil, ErrAPITokenUnknown
+	case t.ExpiresAt != nil && !t.ExpiresAt.After(now):
+		return nil, ErrAPITokenExpired
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > apiTokenUsedInterval {
+		if _, err := s.pool.Exec(ctx, `UPDATE api_tokens SET last_used_at = $2 WHERE id = $1`, ToPgUUID(t.ID), now); err != nil {
+			return nil, fmt.Errorf("record API token use: %w", err)
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+// contextActor names who is acting in ctx: the signed-in user's email or
+// the API token's name, or "" if neither.
+func contextActor(ctx context.Context) string {
+	if u := GetUserFromContext(ctx); u != nil {
+		return u.Email
+	}
+	if t := GetAPITokenFromContext(ctx); t != nil {
+		return "token " + t.Name
+	}
+	return ""
+}
+
+// contextOwner returns the ID of the user behind the caller in ctx: the
+// signed-in user or the owner of their API token; "" for anyone else.
+func contextOwner(ctx context.Context) string {
+	if u := GetUserFromContext(ctx); u != nil {
+		return u.ID
+	}
+	if t := GetAPITokenFromContext(ctx); t != nil {
+		return t.OwnerID
+	}
+	return ""
+}
+
+const apiTokenColumns = `id, name, prefix, scope, table_keys, created_by, owner_id, created_at, expires_at, last_used_at, revoked_at`
+
+// scanAPIToken scans one row selected with apiTokenColumns.
+func scanAPIToken(row pgx.Row) (*APIToken, error) {
+	var (
+		id       pgtype.UUID
+		scope    string
+		expires  pgtype.Timestamptz
+		lastUsed pgtype.Timestamptz
+		revoked  pgtype.Timestamptz
+		owner    pgtype.UUID
+		t        APIToken
+	)
+	if err := row.Scan(&id, &t.Name, &t.Prefix, &scope, &t.Tables, &t.CreatedBy, &owner, &t.CreatedAt, &expires, &lastUsed, &revoked); err != nil {
+		return nil, err
+	}
+	t.ID = PgUUIDToString(id)
+	t.Scope = TokenScope(scope)
+	t.OwnerID = PgUUIDToString(owner)
+	if t.Tables == nil {
+		t.Tables = []string{}
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+	return &t, nil
+}
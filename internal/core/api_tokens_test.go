@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAPITokenAllows(t *testing.T) {
+	all := &APIToken{Scope: ScopeWrite}
+	limited := &APIToken{Scope: ScopeDestructive, Tables: []string{"invoices"}}
+
+	tests := []struct {
+		name     string
+		token    *APIToken
+		scope    TokenScope
+		tableKey string
+		want     bool
+	}{
+		{"read with write", all, ScopeRead, "orders", true},
+		{"write with write", all, ScopeWrite, "", true},
+		{"destructive with write", all, ScopeDestructive, "orders", false},
+		{"own table", limited, ScopeDestructive, "invoices", true},
+		{"other table", limited, ScopeRead, "orders", false},
+		{"read without table", limited, ScopeRead, "", false},
+		{"write without table", limited, ScopeWrite, "", false},
+		{"unknown scope", &APIToken{Scope: "admin"}, ScopeRead, "", false},
+		{"aggregate with read", all, ScopeAggregate, "orders", true},
+		{"read with aggregate", &APIToken{Scope: ScopeAggregate}, ScopeRead, "orders", false},
+		{"aggregate without table", limited, ScopeAggregate, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Allows(tt.scope, tt.tableKey); got != tt.want {
+				t.Errorf("Allows(%s, %q) = %v, want %v", tt.scope, tt.tableKey, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAPIToken(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "token_invoices"},
+		FieldSpecs: []FieldSpec{{Name: "Invoice", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "token_invoices")
+		registryMu.Unlock()
+	})
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	p, err := validateAPIToken(APITokenParams{
+		Name:      "  nightly  ",
+		Scope:     ScopeRead,
+		Tables:    []string{"token_invoices", "token_invoices"},
+		ExpiresAt: &future,
+	}, now)
+	if err != nil {
+		t.Fatalf("validateAPIToken: %v", err)
+	}
+	if p.Name != "nightly" || !slices.Equal(p.Tables, []string{"token_invoices"}) {
+		t.Errorf("params = %+v", p)
+	}
+
+	for _, bad := range []APITokenParams{
+		{Scope: ScopeRead},
+		{Name: "x", Scope: "admin"},
+		{Name: "x", Scope: ScopeRead, Tables: []string{"nope"}},
+		{Name: "x", Scope: ScopeRead, ExpiresAt: &past},
+	} {
+		if _, err := validateAPIToken(bad, now); !errors.Is(err, ErrInvalidAPIToken) {
+			t.Errorf("validateAPIToken(%+v) = %v, want ErrInvalidAPIToken", bad, err)
+		}
+	}
+}
+
+func TestWithContextUser_APIToken(t *testing.T) {
+	token := &APIToken{ID: "t1", Name: "nightly"}
+	ctx := ContextWithAPIToken(context.Background(), token)
+
+	p := withContextUser(ctx, AuditLogParams{Action: ActionCellEdit})
+	if p.UserID != "token:t1" || p.UserName != "API token nightly" || p.UserEmail != "" {
+		t.Errorf("params = %+v, want the token", p)
+	}
+	if got := contextActor(ctx); got != "token nightly" {
+		t.Errorf("contextActor = %q", got)
+	}
+
+	// A signed-in user wins
+	ctx = ContextWithUser(ctx, &User{ID: "u1", Email: "ada@example.com"})
+	if p := withContextUser(ctx, AuditLogParams{}); p.UserID != "u1" {
+		t.Errorf("params = %+v, want the user", p)
+	}
+}
@@ -0,0 +1,495 @@
+package core
+
+// approvals.go puts large resets and rollbacks behind a second person.
+//
+// With APPROVAL_ROW_THRESHOLD set, a reset or rollback that would remove
+// more rows than the threshold is not run. It is recorded as a pending
+// approval instead, and the request fails with an ApprovalRequiredError
+// carrying it. A second caller approves it with ApproveRequest, which runs
+// the operation as it was requested; anyone may reject it. An approval not
+// decided within APPROVAL_TTL expires. A range rollback's approval names
+// the uploads it covers, and it fails with ErrApprovalChanged rather than
+// roll back uploads added to the range since.
+//
+// Callers are told apart by their signed-in user; an API token counts as
+// the user who owns it, so one person can't approve their own request by
+// switching from the web UI to a token. The approver must be a signed-in
+// user or use a token a user owns, and must not be the requester; a shared
+// key from API_KEYS can request but never approve. Requesting, approving
+// and rejecting are audited, and the approved operation's own audit
+// entries name both the requester and the approver.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Approval errors.
+var (
+	ErrApprovalRequired  = errors.New("approval required")
+	ErrApprovalNotFound  = errors.New("approval not found")
+	ErrApprovalDecided   = errors.New("approval no longer pending")
+	ErrApprovalForbidden = errors.New("approval not allowed")
+	ErrApprovalChanged   = errors.New("operation changed since it was approved")
+)
+
+// ApprovalKind is the kind of operation awaiting approval.
+type ApprovalKind string
+
+const (
+	ApprovalReset         ApprovalKind = "reset"          // Reset of one table
+	ApprovalResetAll      ApprovalKind = "reset_all"      // Reset of all tables
+	ApprovalRollback      ApprovalKind = "rollback"       // Rollback of one upload
+	ApprovalRollbackRange ApprovalKind = "rollback_range" // Rollback of a table's uploads in a date range
+	ApprovalRollbackBatch ApprovalKind = "rollback_batch" // Rollback of an upload batch
+)
+
+// ApprovalStatus is the state of an approval.
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired" // Pending past its expiry; not stored
+)
+
+// Approval is a reset or rollback awaiting, or decided by, a second user.
+type Approval struct {
+	ID          string            `json:"id"`
+	Kind        ApprovalKind      `json:"kind"`
+	TableKey    string            `json:"tableKey,omitempty"`
+	Target      string            `json:"target,omitempty"` // Upload or batch ID
+	Params      map[string]string `json:"params,omitempty"`
+	Rows        int64             `json:"rows"` // Rows it would remove when requested
+	Status      ApprovalStatus    `json:"status"`
+	RequestedBy string            `json:"requestedBy,omitempty"`
+	RequestedAt time.Time         `json:"requestedAt"`
+	ExpiresAt   time.Time         `json:"expiresAt"`
+	DecidedBy   string            `json:"decidedBy,omitempty"`
+	DecidedAt   *time.Time        `json:"decidedAt,omitempty"`
+	Note        string            `json:"note,omitempty"`
+	OperationID string            `json:"operationId,omitempty"` // Of the approved operation, if it runs in the background
+	Error       string            `json:"error,omitempty"`       // Why the approved operation failed
+
+	requestedByID string
+}
+
+// ApprovalRequiredError is returned in place of running an operation that
+// needs approval. It matches ErrApprovalRequired.
+type ApprovalRequiredError struct {
+	Approval *Approval
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%s: %s would remove %d rows; approval %s is pending", ErrApprovalRequired, e.Approval.describe(), e.Approval.Rows, e.Approval.ID)
+}
+
+func (e *ApprovalRequiredError) Unwrap() error { return ErrApprovalRequired }
+
+// approvalRequest is a destructive operation that may need approval.
+type approvalRequest struct {
+	kind     ApprovalKind
+	tableKey string
+	target   string
+	params   map[string]string
+}
+
+// covers reports whether a is the approval of req.
+func (a *Approval) covers(req approvalRequest) bool {
+	if a.Kind != req.kind || a.TableKey != req.tableKey || a.Target != req.target || len(a.Params) != len(req.params) {
+		return false
+	}
+	for k, v := range req.params {
+		if a.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// describe names the operation, e.g. "reset of invoices".
+func (a *Approval) describe() string {
+	switch a.Kind {
+	case ApprovalReset:
+		return "reset of " + a.TableKey
+	case ApprovalResetAll:
+		return "reset of all tables"
+	case ApprovalRollback:
+		return "rollback of upload " + a.Target
+	case ApprovalRollbackRange:
+		return fmt.Sprintf("rollback of %s uploads from %s to %s", a.TableKey, a.Params["from"], a.Params["to"])
+	case ApprovalRollbackBatch:
+		return "rollback of upload batch " + a.Target
+	}
+	return string(a.Kind)
+}
+
+// approvalActor identifies the caller in ctx for approvals, with a name to
+// show: "user:" and the ID of the signed-in user or of the user owning the
+// API token; failing that the token or API key itself. The ID is "" if the
+// caller can't be told apart from others.
+func approvalActor(ctx context.Context) (id, name string) {
+	if u := GetUserFromContext(ctx); u != nil {
+		return "user:" + u.ID, u.Email
+	}
+	if t := GetAPITokenFromContext(ctx); t != nil {
+		if t.OwnerID != "" {
+			return "user:" + t.OwnerID, "token " + t.Name
+		}
+		return "token:" + t.ID, "token " + t.Name
+	}
+	if v, ok := ctx.Value(ctxKeyUploader).(string); ok && v != "" {
+		return v, "API key " + strings.TrimPrefix(v, "key:")
+	}
+	return "", GetUploaderFromContext(ctx)
+}
+
+// checkApprover returns ErrApprovalForbidden unless approverID, from
+// approvalActor, is a user other than the one who requested a.
+func checkApprover(a *Approval, approverID string) error {
+	switch {
+	case !strings.HasPrefix(approverID, "user:"):
+		return fmt.Errorf("%w: approve as a signed-in user or with an API token a user owns", ErrApprovalForbidden)
+	case approverID == a.requestedByID:
+		return fmt.Errorf("%w: a request must be approved by someone other than its requester", ErrApprovalForbidden)
+	}
+	return nil
+}
+
+// contextWithApproval marks ctx as running the operation a approved.
+func contextWithApproval(ctx context.Context, a *Approval) context.Context {
+	return context.WithValue(ctx, ctxKeyApproval, a)
+}
+
+// approvalFromContext returns the approval ctx runs under, or nil.
+func approvalFromContext(ctx context.Context) *Approval {
+	if a, ok := ctx.Value(ctxKeyApproval).(*Approval); ok {
+		return a
+	}
+	return nil
+}
+
+// withApproval adds the requester and approver to the reason of an audit
+// entry written while running an approved operation.
+func withApproval(ctx context.Context, p AuditLogParams) AuditLogParams {
+	a := approvalFromContext(ctx)
+	if a == nil {
+		return p
+	}
+	requester := a.RequestedBy
+	if requester == "" {
+		requester = "an unidentified caller"
+	}
+	approved := fmt.Sprintf("requested by %s, approved by %s (approval %s)", requester, a.DecidedBy, a.ID)
+	if p.Reason == "" {
+		p.Reason = "Approval: " + approved
+	} else {
+		p.Reason += "; " + approved
+	}
+	return p
+}
+
+// requireApproval returns an ApprovalRequiredError, recording a pending
+// approval, if req would remove more rows than APPROVAL_ROW_THRESHOLD and
+// ctx is not running its approval. rows counts the rows req would remove,
+// stopping at limit unless it is 0. It returns ErrApprovalChanged if ctx
+// runs an approval of the same operation with other parameters, such as
+// a range rollback whose uploads have changed.
+func (s *Service) requireApproval(ctx context.Context, req approvalRequest, rows func(ctx context.Context, limit int64) (int64, error)) error {
+	if a := approvalFromContext(ctx); a != nil {
+		if a.covers(req) {
+			return nil
+		}
+		if a.Kind == req.kind && a.TableKey == req.tableKey && a.Target == req.target {
+			return fmt.Errorf("%w: %s; request it again", ErrApprovalChanged, a.describe())
+		}
+	}
+	if s.cfg == nil || s.cfg.Security.ApprovalRowThreshold <= 0 {
+		return nil
+	}
+	threshold := s.cfg.Security.ApprovalRowThreshold
+	n, err := rows(ctx, threshold+1)
+	if err == nil && n > threshold {
+		n, err = rows(ctx, 0) // All of them, for the approver
+	}
+	if err != nil {
+		return fmt.Errorf("count rows for approval: %w", err)
+	}
+	if n <= threshold {
+		return nil
+	}
+	a, err := s.createApproval(ctx, req, n)
+	if err != nil {
+		return err
+	}
+	return &ApprovalRequiredError{Approval: a}
+}
+
+// countRows returns the rows of def matching where (with args), counting
+// no further than limit unless it is 0.
+func (s *Service) countRows(ctx context.Context, def TableDefinition, limit int64, where string, args ...any) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdentifier(def.Info.Key), where)
+	if limit > 0 {
+		query = fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM %s WHERE %s LIMIT %d) t", quoteIdentifier(def.Info.Key), where, limit)
+	}
+	var n int64
+	err := s.pool.QueryRow(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// requireResetApproval applies requireApproval to a reset of def.
+func (s *Service) requireResetApproval(ctx context.Context, def TableDefinition) error {
+	return s.requireApproval(ctx, approvalRequest{kind: ApprovalReset, tableKey: def.Info.Key}, func(ctx context.Context, limit int64) (int64, error) {
+		return s.countRows(ctx, def, limit, "TRUE")
+	})
+}
+
+// createApproval records req, which would remove rows rows, as pending.
+func (s *Service) createApproval(ctx context.Context, req approvalRequest, rows int64) (*Approval, error) {
+	if req.params == nil {
+		req.params = map[string]string{}
+	}
+	params, err := json.Marshal(req.params)
+	if err != nil {
+		return nil, fmt.Errorf("encode approval params: %w", err)
+	}
+	requesterID, requester := approvalActor(ctx)
+
+	qctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	a, err := scanApproval(s.pool.QueryRow(qctx,
+		`INSERT INTO approvals (kind, table_key, target, params, row_count, requested_by_id, requested_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+approvalColumns,
+		string(req.kind), req.tableKey, req.target, params, rows, requesterID, requester, time.Now().Add(s.cfg.Security.ApprovalTTL),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create approval: %w", err)
+	}
+	s.logApproval(ctx, ActionApprovalRequest, a, fmt.Sprintf("Requested approval of %s (%d rows)", a.describe(), a.Rows))
+	return a, nil
+}
+
+// ListApprovals returns approvals, newest first: only those still pending
+// unless all is set.
+func (s *Service) ListApprovals(ctx context.Context, all bool, limit int) ([]Approval, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	query := `SELECT ` + approvalColumns + ` FROM approvals`
+	if !all {
+		query += ` WHERE status = 'pending' AND expires_at > NOW()`
+	}
+	rows, err := s.pool.Query(ctx, query+` ORDER BY requested_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvals := make([]Approval, 0)
+	for rows.Next() {
+		a, err := scanApproval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan approval: %w", err)
+		}
+		approvals = append(approvals, *a)
+	}
+	return approvals, rows.Err()
+}
+
+// GetApproval returns one approval.
+func (s *Service) GetApproval(ctx context.Context, id string) (*Approval, error) {
+	aid := ToPgUUID(id)
+	if !aid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrApprovalNotFound, id)
+	}
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	a, err := scanApproval(s.pool.QueryRow(ctx, `SELECT `+approvalColumns+` FROM approvals WHERE id = $1`, aid))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrApprovalNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get approval: %w", err)
+	}
+	return a, nil
+}
+
+// ApproveRequest approves a pending approval and runs its operation as
+// the caller in ctx, who must be a user other than the requester. Resets
+// and single rollbacks run in the background, and the returned approval
+// has their operation ID; other rollbacks run before it returns. If the
+// operation can't run, the approval stays approved with the error.
+func (s *Service) ApproveRequest(ctx context.Context, id, note string) (*Approval, error) {
+	a, err := s.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != ApprovalPending {
+		return nil, fmt.Errorf("%w: approval %s is %s", ErrApprovalDecided, id, a.Status)
+	}
+	approverID, approver := approvalActor(ctx)
+	if err := checkApprover(a, approverID); err != nil {
+		return nil, err
+	}
+
+	a, err = s.decideApproval(ctx, id, ApprovalApproved, approverID, approver, note)
+	if err != nil {
+		return nil, err
+	}
+	s.logApproval(ctx, ActionApprovalGrant, a, fmt.Sprintf("Approved %s (%d rows) requested by %s", a.describe(), a.Rows, a.RequestedBy))
+
+	opID, runErr := s.runApproved(contextWithApproval(ctx, a), a)
+	a.OperationID = opID
+	if runErr != nil {
+		a.Error = runErr.Error()
+	}
+	qctx, cancel := s.withOpTimeout(context.WithoutCancel(ctx), opMutation)
+	defer cancel()
+	if _, err := s.pool.Exec(qctx, `UPDATE approvals SET operation_id = $2, error = $3 WHERE id = $1`, ToPgUUID(a.ID), a.OperationID, a.Error); err != nil {
+		return a, fmt.Errorf("record approved operation: %w", err)
+	}
+	return a, nil
+}
+
+// RejectRequest rejects a pending approval; its operation never runs.
+// Anyone may reject, the requester included.
+func (s *Service) RejectRequest(ctx context.Context, id, note string) (*Approval, error) {
+	deciderID, decider := approvalActor(ctx)
+	a, err := s.decideApproval(ctx, id, ApprovalRejected, deciderID, decider, note)
+	if err != nil {
+		return nil, err
+	}
+	reason := fmt.Sprintf("Rejected %s requested by %s", a.describe(), a.RequestedBy)
+	if note != "" {
+		reason += ": " + note
+	}
+	s.logApproval(ctx, ActionApprovalReject, a, reason)
+	return a, nil
+}
+
+// decideApproval moves a pending, unexpired approval to status.
+func (s *Service) decideApproval(ctx context.Context, id string, status ApprovalStatus, deciderID, decider, note string) (*Approval, error) {
+	aid := ToPgUUID(id)
+	if !aid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrApprovalNotFound, id)
+	}
+	qctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	a, err := scanApproval(s.pool.QueryRow(qctx,
+		`UPDATE approvals SET status = $2, decided_by_id = $3, decided_by = $4, decided_at = NOW(), note = $5
+		 WHERE id = $1 AND status = 'pending' AND expires_at > NOW()
+		 RETURNING `+approvalColumns,
+		aid, string(status), deciderID, decider, note,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unknown, or decided or expired meanwhile
+		current, getErr := s.GetApproval(ctx, id)
+		if getErr != nil {
+			return nil, getErr
+		}
+		return nil, fmt.Errorf("%w: approval %s is %s", ErrApprovalDecided, id, current.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decide approval: %w", err)
+	}
+	return a, nil
+}
+
+// runApproved runs the operation a approved, with ctx marked as running
+// it, and returns its operation ID if it runs in the background.
+func (s *Service) runApproved(ctx context.Context, a *Approval) (string, error) {
+	switch a.Kind {
+	case ApprovalReset:
+		return s.StartReset(ctx, a.TableKey)
+	case ApprovalResetAll:
+		return s.StartResetAll(ctx, ResetAllOptions{Incremental: a.Params["incremental"] == "true"})
+	case ApprovalRollback:
+		result, err := s.StartRollback(ctx, a.Target)
+		return result.OperationID, err
+	case ApprovalRollbackRange:
+		from, err := time.Parse(time.RFC3339Nano, a.Params["from"])
+		if err != nil {
+			return "", fmt.Errorf("invalid range start: %w", err)
+		}
+		to, err := time.Parse(time.RFC3339Nano, a.Params["to"])
+		if err != nil {
+			return "", fmt.Errorf("invalid range end: %w", err)
+		}
+		_, err = s.RollbackUploadsInRange(ctx, a.TableKey, from, to, false)
+		return "", err
+	case ApprovalRollbackBatch:
+		_, err := s.RollbackUploadBatch(ctx, a.Target)
+		return "", err
+	}
+	return "", fmt.Errorf("unknown approval kind %q", a.Kind)
+}
+
+// logApproval records requesting or deciding a in the audit log.
+func (s *Service) logApproval(ctx context.Context, action AuditAction, a *Approval, reason string) {
+	s.LogAudit(context.WithoutCancel(ctx), AuditLogParams{
+		Action:       action,
+		TableKey:     a.TableKey,
+		NewValue:     a.ID,
+		RowsAffected: int(a.Rows),
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       reason,
+		RowData: map[string]any{
+			"approval_id":  a.ID,
+			"kind":         a.Kind,
+			"target":       a.Target,
+			"params":       a.Params,
+			"requested_by": a.RequestedBy,
+			"decided_by":   a.DecidedBy,
+		},
+	})
+}
+
+const approvalColumns = `id, kind, table_key, target, params, row_count, status, requested_by_id, requested_by, requested_at, expires_at, decided_by, decided_at, note, operation_id, error`
+
+// scanApproval scans one row selected with approvalColumns.
+func scanApproval(row pgx.Row) (*Approval, error) {
+	var (
+		id      pgtype.UUID
+		kind    string
+		params  []byte
+		status  string
+		decided pgtype.Timestamptz
+		a       Approval
+	)
+	if err := row.Scan(&id, &kind, &a.TableKey, &a.Target, &params, &a.Rows, &status, &a.requestedByID, &a.RequestedBy,
+		&a.RequestedAt, &a.ExpiresAt, &a.DecidedBy, &decided, &a.Note, &a.OperationID, &a.Error); err != nil {
+		return nil, err
+	}
+	a.ID = PgUUIDToString(id)
+	a.Kind = ApprovalKind(kind)
+	a.Status = ApprovalStatus(status)
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &a.Params); err != nil {
+			return nil, fmt.Errorf("decode approval params: %w", err)
+		}
+	}
+	if decided.Valid {
+		a.DecidedAt = &decided.Time
+	}
+	if a.Status == ApprovalPending && !a.ExpiresAt.After(time.Now()) {
+		a.Status = ApprovalExpired
+	}
+	return &a, nil
+}
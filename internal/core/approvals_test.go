@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestRequireApproval_SkipsWithoutNeed(t *testing.T) {
+	req := approvalRequest{kind: ApprovalReset, tableKey: "invoices"}
+	counted := 0
+	rows := func(_ context.Context, limit int64) (int64, error) {
+		counted++
+		if limit != 1001 {
+			t.Errorf("counted up to %d, want 1001", limit)
+		}
+		return 1000, nil
+	}
+	ctx := context.Background()
+
+	if err := (&Service{}).requireApproval(ctx, req, rows); err != nil {
+		t.Errorf("no config: %v", err)
+	}
+	s := &Service{cfg: &config.Config{}}
+	if err := s.requireApproval(ctx, req, rows); err != nil {
+		t.Errorf("threshold unset: %v", err)
+	}
+	if counted != 0 {
+		t.Errorf("counted rows %d times with approvals disabled", counted)
+	}
+
+	s.cfg.Security.ApprovalRowThreshold = 1000
+	if err := s.requireApproval(ctx, req, rows); err != nil {
+		t.Errorf("at threshold: %v", err)
+	}
+	if counted != 1 {
+		t.Errorf("counted rows %d times, want 1", counted)
+	}
+
+	approved := contextWithApproval(ctx, &Approval{Kind: ApprovalReset, TableKey: "invoices"})
+	over := func(context.Context, int64) (int64, error) {
+		t.Error("counted rows of an approved operation")
+		return 5000, nil
+	}
+	if err := s.requireApproval(approved, req, over); err != nil {
+		t.Errorf("approved: %v", err)
+	}
+
+	// An approved range rollback whose uploads have changed since is refused
+	rangeReq := approvalRequest{kind: ApprovalRollbackRange, tableKey: "invoices", params: map[string]string{"from": "a", "to": "b", "uploads": "u1,u2"}}
+	rangeApproved := contextWithApproval(ctx, &Approval{Kind: ApprovalRollbackRange, TableKey: "invoices", Params: map[string]string{"from": "a", "to": "b", "uploads": "u1"}})
+	if err := s.requireApproval(rangeApproved, rangeReq, over); !errors.Is(err, ErrApprovalChanged) {
+		t.Errorf("changed uploads: err = %v, want ErrApprovalChanged", err)
+	}
+	if err := (&Service{}).requireApproval(rangeApproved, rangeReq, over); !errors.Is(err, ErrApprovalChanged) {
+		t.Errorf("changed uploads with approvals since disabled: err = %v, want ErrApprovalChanged", err)
+	}
+
+	failing := func(context.Context, int64) (int64, error) { return 0, errors.New("boom") }
+	if err := s.requireApproval(ctx, req, failing); err == nil || errors.Is(err, ErrApprovalRequired) {
+		t.Errorf("count error: got %v", err)
+	}
+}
+
+func TestApproval_Covers(t *testing.T) {
+	a := &Approval{Kind: ApprovalRollbackRange, TableKey: "invoices", Params: map[string]string{"from": "2025-01-01T00:00:00Z", "to": "2025-01-31T23:59:59Z"}}
+	tests := []struct {
+		name string
+		req  approvalRequest
+		want bool
+	}{
+		{"same", approvalRequest{kind: ApprovalRollbackRange, tableKey: "invoices", params: map[string]string{"from": "2025-01-01T00:00:00Z", "to": "2025-01-31T23:59:59Z"}}, true},
+		{"other table", approvalRequest{kind: ApprovalRollbackRange, tableKey: "orders", params: a.Params}, false},
+		{"other kind", approvalRequest{kind: ApprovalReset, tableKey: "invoices"}, false},
+		{"other range", approvalRequest{kind: ApprovalRollbackRange, tableKey: "invoices", params: map[string]string{"from": "2024-01-01T00:00:00Z", "to": "2025-01-31T23:59:59Z"}}, false},
+		{"fewer params", approvalRequest{kind: ApprovalRollbackRange, tableKey: "invoices", params: map[string]string{"from": "2025-01-01T00:00:00Z"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.covers(tt.req); got != tt.want {
+				t.Errorf("covers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	batch := &Approval{Kind: ApprovalRollbackBatch, Target: "b1", Params: map[string]string{}}
+	if !batch.covers(approvalRequest{kind: ApprovalRollbackBatch, target: "b1"}) {
+		t.Error("empty params should match nil params")
+	}
+	if batch.covers(approvalRequest{kind: ApprovalRollbackBatch, target: "b2"}) {
+		t.Error("covers another batch")
+	}
+}
+
+func TestApprovalActor(t *testing.T) {
+	ctx := ContextWithIPAddress(context.Background(), "10.0.0.1")
+	if id, name := approvalActor(ctx); id != "" || name != "ip:10.0.0.1" {
+		t.Errorf("anonymous = %q, %q", id, name)
+	}
+	keyed := ContextWithUploader(ctx, "key:abc123")
+	if id, name := approvalActor(keyed); id != "key:abc123" || name != "API key abc123" {
+		t.Errorf("API key = %q, %q", id, name)
+	}
+	token := ContextWithAPIToken(keyed, &APIToken{ID: "t1", Name: "nightly"})
+	if id, name := approvalActor(token); id != "token:t1" || name != "token nightly" {
+		t.Errorf("token = %q, %q", id, name)
+	}
+	owned := ContextWithAPIToken(keyed, &APIToken{ID: "t2", Name: "ada's script", OwnerID: "u1"})
+	if id, name := approvalActor(owned); id != "user:u1" || name != "token ada's script" {
+		t.Errorf("owned token = %q, %q", id, name)
+	}
+	user := ContextWithUser(token, &User{ID: "u1", Email: "ada@example.com"})
+	if id, name := approvalActor(user); id != "user:u1" || name != "ada@example.com" {
+		t.Errorf("user = %q, %q", id, name)
+	}
+}
+
+func TestCheckApprover(t *testing.T) {
+	a := &Approval{requestedByID: "user:u1"}
+	tests := []struct {
+		approver string
+		ok       bool
+	}{
+		{"", false},
+		{"key:abc123", false}, // Shared API key
+		{"token:t1", false},   // Token nobody owns
+		{"user:u1", false},    // The requester, signed in or with their token
+		{"user:u2", true},
+	}
+	for _, tt := range tests {
+		err := checkApprover(a, tt.approver)
+		if (err == nil) != tt.ok || (err != nil && !errors.Is(err, ErrApprovalForbidden)) {
+			t.Errorf("approver %q: err = %v", tt.approver, err)
+		}
+	}
+	if err := checkApprover(&Approval{requestedByID: "key:abc123"}, "user:u1"); err != nil {
+		t.Errorf("user approving a key's request: %v", err)
+	}
+}
+
+func TestWithApproval_NamesBoth(t *testing.T) {
+	p := AuditLogParams{Action: ActionTableReset, Reason: "reset"}
+	if got := withApproval(context.Background(), p); got.Reason != "reset" {
+		t.Errorf("without approval: %q", got.Reason)
+	}
+
+	ctx := contextWithApproval(context.Background(), &Approval{ID: "a1", RequestedBy: "ada@example.com", DecidedBy: "grace@example.com"})
+	got := withApproval(ctx, p).Reason
+	for _, want := range []string{"reset", "ada@example.com", "grace@example.com", "a1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("reason %q does not contain %q", got, want)
+		}
+	}
+	if got := withApproval(ctx, AuditLogParams{}).Reason; !strings.HasPrefix(got, "Approval: requested by ada@example.com") {
+		t.Errorf("empty reason: %q", got)
+	}
+}
+
+func TestApprovalRequiredError(t *testing.T) {
+	var err error = &ApprovalRequiredError{Approval: &Approval{ID: "a1", Kind: ApprovalReset, TableKey: "invoices", Rows: 5000}}
+	if !errors.Is(err, ErrApprovalRequired) {
+		t.Error("does not match ErrApprovalRequired")
+	}
+	for _, want := range []string{"reset of invoices", "5000 rows", "a1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package core
+
+// archive_runs.go records each run of the archive scheduler in the
+// archive_runs table: what started it, when, how long it took and how
+// many entries and rows each step archived, purged, compacted or deleted.
+// The scheduler reads the last run back to decide when the next is due
+// (see scheduler.go), and admins list recent runs with ArchiveStatus.
+//
+// Recording is best effort, as with operations: a failed write is logged
+// and the run carries on.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Archive run errors.
+var (
+	ErrArchiveRunning      = errors.New("archive run already in progress")
+	ErrArchiveNotScheduled = errors.New("archive scheduler not started")
+)
+
+// ArchiveTrigger is what started an archive run.
+type ArchiveTrigger string
+
+const (
+	ArchiveScheduled ArchiveTrigger = "schedule" // CheckInterval after the last run
+	ArchiveCatchUp   ArchiveTrigger = "catch_up" // At startup, with no run in the last CheckInterval
+	ArchiveManual    ArchiveTrigger = "manual"   // StartArchiveRun
+)
+
+// archiveInterruptedError is the error of runs cut short by a shutdown.
+const archiveInterruptedError = "interrupted by shutdown"
+
+// ArchiveRun is one run of the archive job and what it did.
+type ArchiveRun struct {
+	ID                  string         `json:"id"` // Its operation ID
+	Trigger             ArchiveTrigger `json:"trigger"`
+	Initiator           string         `json:"initiator"`
+	StartedAt           time.Time      `json:"startedAt"`
+	FinishedAt          *time.Time     `json:"finishedAt,omitempty"`
+	DurationMs          *int64         `json:"durationMs,omitempty"`
+	EntriesArchived     int64          `json:"entriesArchived"`
+	EntriesPurged       int64          `json:"entriesPurged"`
+	FailedRowsCompacted int64          `json:"failedRowsCompacted"`
+	RecycledRowsPurged  int64          `json:"recycledRowsPurged"`
+	ArchivesExpired     int64          `json:"archivesExpired"`
+	Error               string         `json:"error,omitempty"`
+	Interrupted         bool           `json:"interrupted,omitempty"` // Cancelled or cut short by a shutdown
+}
+
+// ArchiveStatus is the state of the archive scheduler and its recent runs.
+type ArchiveStatus struct {
+	Scheduled bool         `json:"scheduled"`          // The scheduler is running
+	Interval  string       `json:"interval,omitempty"` // CheckInterval, e.g. "24h0m0s"
+	NextRunAt *time.Time   `json:"nextRunAt,omitempty"`
+	RunningID string       `json:"runningId,omitempty"` // Operation ID of the run in progress
+	Runs      []ArchiveRun `json:"runs"`                // Newest first
+}
+
+// ArchiveStatus returns the scheduler's state and its last limit runs
+// (default 20, max 500).
+func (s *Service) ArchiveStatus(ctx context.Context, limit int) (*ArchiveStatus, error) {
+	status := &ArchiveStatus{}
+	s.archive.mu.Lock()
+	if s.archive.cfg != nil {
+		status.Scheduled = true
+		status.Interval = s.archive.cfg.CheckInterval.String()
+	}
+	if !s.archive.nextRun.IsZero() {
+		next := s.archive.nextRun
+		status.NextRunAt = &next
+	}
+	status.RunningID = s.archive.running
+	s.archive.mu.Unlock()
+
+	runs, err := s.ListArchiveRuns(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	status.Runs = runs
+	return status, nil
+}
+
+// ListArchiveRuns returns the last limit archive runs, newest first
+// (default 20, max 500).
+func (s *Service) ListArchiveRuns(ctx context.Context, limit int) ([]ArchiveRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	limit = min(limit, 500)
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+archiveRunColumns+` FROM archive_runs ORDER BY started_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list archive runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]ArchiveRun, 0)
+	for rows.Next() {
+		run, err := scanArchiveRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan archive run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// lastArchiveRunStart returns when the last run that was not interrupted
+// started, or the zero time if none has.
+func (s *Service) lastArchiveRunStart(ctx context.Context) (time.Time, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+	var last pgtype.Timestamptz
+	if err := s.pool.QueryRow(ctx,
+		`SELECT MAX(started_at) FROM archive_runs WHERE NOT interrupted`,
+	).Scan(&last); err != nil {
+		return time.Time{}, err
+	}
+	return last.Time, nil
+}
+
+// interruptArchiveRuns marks the runs left unfinished by an earlier
+// process as interrupted, so they are not taken for the last run.
+func (s *Service) interruptArchiveRuns(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE archive_runs SET finished_at = NOW(), interrupted = TRUE, error = $1
+		 WHERE finished_at IS NULL`,
+		archiveInterruptedError,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// recordArchiveRun records a run as started.
+func (s *Service) recordArchiveRun(ctx context.Context, run *ArchiveRun) {
+	ctx, cancel := s.withOpTimeout(context.WithoutCancel(ctx), opMutation)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx,
+		`INSERT INTO archive_runs (id, trigger, initiator, started_at) VALUES ($1, $2, $3, $4)`,
+		ToPgUUID(run.ID), string(run.Trigger), run.Initiator, run.StartedAt,
+	); err != nil {
+		slog.Error("failed to record archive run", "operation_id", run.ID, "error", err)
+	}
+}
+
+// finishArchiveRun records what a run did. A run whose ctx was cancelled
+// counts as interrupted.
+func (s *Service) finishArchiveRun(ctx context.Context, run *ArchiveRun, runErr error) {
+	now := time.Now()
+	duration := now.Sub(run.StartedAt).Milliseconds()
+	run.FinishedAt, run.DurationMs = &now, &duration
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+	if ctx.Err() != nil {
+		run.Interrupted = true
+		if run.Error == "" {
+			run.Error = archiveInterruptedError
+		}
+	}
+
+	ctx, cancel := s.withOpTimeout(context.WithoutCancel(ctx), opMutation)
+	defer cancel()
+	if _, err := s.pool.Exec(ctx,
+		`UPDATE archive_runs SET
+			finished_at = $2, duration_ms = $3,
+			entries_archived = $4, entries_purged = $5, failed_rows_compacted = $6,
+			recycled_rows_purged = $7, archives_expired = $8,
+			error = $9, interrupted = $10
+		 WHERE id = $1`,
+		ToPgUUID(run.ID), now, duration,
+		run.EntriesArchived, run.EntriesPurged, run.FailedRowsCompacted,
+		run.RecycledRowsPurged, run.ArchivesExpired,
+		run.Error, run.Interrupted,
+	); err != nil {
+		slog.Error("failed to record archive run", "operation_id", run.ID, "error", err)
+	}
+}
+
+// archiveInitiator names who started a run in ctx: the signed-in user or
+// API token, the client IP, or "system" for scheduled runs.
+func archiveInitiator(ctx context.Context) string {
+	if actor := contextActor(ctx); actor != "" {
+		return actor
+	}
+	if ip := GetIPAddressFromContext(ctx); ip != "" {
+		return ip
+	}
+	return operationInitiatorSystem
+}
+
+const archiveRunColumns = `id, trigger, initiator, started_at, finished_at, duration_ms,
+	entries_archived, entries_purged, failed_rows_compacted, recycled_rows_purged, archives_expired,
+	error, interrupted`
+
+// scanArchiveRun scans one row selected with archiveRunColumns.
+func scanArchiveRun(row pgx.Row) (*ArchiveRun, error) {
+	var (
+		id       pgtype.UUID
+		trigger  string
+		finished pgtype.Timestamptz
+		duration pgtype.Int8
+		run      ArchiveRun
+	)
+	if err := row.Scan(&id, &trigger, &run.Initiator, &run.StartedAt, &finished, &duration,
+		&run.EntriesArchived, &run.EntriesPurged, &run.FailedRowsCompacted, &run.RecycledRowsPurged, &run.ArchivesExpired,
+		&run.Error, &run.Interrupted,
+	); err != nil {
+		return nil, err
+	}
+	run.ID = PgUUIDToString(id)
+	run.Trigger = ArchiveTrigger(trigger)
+	if finished.Valid {
+		run.FinishedAt = &finished.Time
+	}
+	if duration.Valid {
+		run.DurationMs = &duration.Int64
+	}
+	return &run, nil
+}
@@ -37,16 +37,36 @@ import (
 type AuditAction string
 
 const (
-	ActionUpload         AuditAction = "upload"
-	ActionUploadRollback AuditAction = "upload_rollback"
-	ActionCellEdit       AuditAction = "cell_edit"
-	ActionBulkEdit       AuditAction = "bulk_edit"
-	ActionRowDelete      AuditAction = "row_delete"
-	ActionRowRestore     AuditAction = "row_restore"
-	ActionTableReset     AuditAction = "table_reset"
-	ActionTemplateCreate AuditAction = "template_create"
-	ActionTemplateUpdate AuditAction = "template_update"
-	ActionTemplateDelete AuditAction = "template_delete"
+	ActionUpload           AuditAction = "upload"
+	ActionUploadRollback   AuditAction = "upload_rollback"
+	ActionCellEdit         AuditAction = "cell_edit"
+	ActionBulkEdit         AuditAction = "bulk_edit"
+	ActionRowDelete        AuditAction = "row_delete"
+	ActionRowRestore       AuditAction = "row_restore"
+	ActionRowInsert        AuditAction = "row_insert"
+	ActionTableReset       AuditAction = "table_reset"
+	ActionTableConfig      AuditAction = "table_config"
+	ActionTemplateCreate   AuditAction = "template_create"
+	ActionTemplateUpdate   AuditAction = "template_update"
+	ActionTemplateDelete   AuditAction = "template_delete"
+	ActionAuthLockout      AuditAction = "auth_lockout"
+	ActionAuthUnlock       AuditAction = "auth_unlock"
+	ActionAuditImport      AuditAction = "audit_import"
+	ActionColumnBackfill   AuditAction = "column_backfill"
+	ActionUploadReview     AuditAction = "upload_review"
+	ActionDataExport       AuditAction = "data_export"
+	ActionRowPurge         AuditAction = "row_purge"
+	ActionAccessDenied     AuditAction = "access_denied"
+	ActionRequestRejected  AuditAction = "request_rejected"
+	ActionLegalHoldSet     AuditAction = "legal_hold_set"
+	ActionLegalHoldRelease AuditAction = "legal_hold_release"
+	ActionUserCreate       AuditAction = "user_create"
+	ActionUserDisable      AuditAction = "user_disable"
+	ActionLoginFailure     AuditAction = "login_failure"
+	ActionTableAction      AuditAction = "table_action"
+	ActionApprovalRequest  AuditAction = "approval_request"
+	ActionApprovalGrant    AuditAction = "approval_grant"
+	ActionApprovalReject   AuditAction = "approval_reject"
 )
 
 // AuditSeverity represents the severity level of an audit entry.
@@ -107,11 +127,11 @@ type AuditLogParams struct {
 // determineSeverity returns the appropriate severity for an action.
 func determineSeverity(action AuditAction) AuditSeverity {
 	switch action {
-	case ActionUpload, ActionUploadRollback, ActionBulkEdit, ActionRowDelete:
+	case ActionUpload, ActionUploadRollback, ActionBulkEdit, ActionRowDelete, ActionAuthLockout, ActionAuditImport, ActionColumnBackfill, ActionUploadReview, ActionDataExport, ActionRowPurge, ActionUserCreate, ActionUserDisable, ActionTableAction, ActionApprovalRequest, ActionApprovalReject:
 		return SeverityHigh
-	case ActionTableReset:
+	case ActionTableReset, ActionLegalHoldSet, ActionLegalHoldRelease, ActionApprovalGrant:
 		return SeverityCritical
-	case ActionTemplateCreate, ActionTemplateUpdate, ActionTemplateDelete:
+	case ActionTemplateCreate, ActionTemplateUpdate, ActionTemplateDelete, ActionRequestRejected:
 		return SeverityLow
 	default:
 		return SeverityMedium
@@ -120,6 +140,7 @@ func determineSeverity(action AuditAction) AuditSeverity {
 
 // LogAudit creates a new audit log entry.
 func (s *Service) LogAudit(ctx context.Context, params AuditLogParams) (*AuditEntry, error) {
+	params = withContextUser(ctx, params)
 	severity := determineSeverity(params.Action)
 
 	var rowDataJSON []byte
@@ -184,6 +205,9 @@ type AuditLogFilter struct {
 
 // GetAuditLog retrieves audit log entries with optional filtering.
 func (s *Service) GetAuditLog(ctx context.Context, filter AuditLogFilter) ([]AuditEntry, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	if filter.Limit <= 0 {
 		filter.Limit = DefaultHistoryLimit
 	}
@@ -298,6 +322,9 @@ func (s *Service) ExportAuditLog(ctx context.Context, opts AuditLogFilter) (io.R
 
 // GetAuditLogByID retrieves a single audit log entry by ID.
 func (s *Service) GetAuditLogByID(ctx context.Context, id string) (*AuditEntry, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	pgUUID := ToPgUUID(id)
 	row, err := db.New(s.pool).GetAuditLogByID(ctx, pgUUID)
 	if err != nil {
@@ -308,6 +335,9 @@ func (s *Service) GetAuditLogByID(ctx context.Context, id string) (*AuditEntry,
 
 // CountAuditLog returns the total count of audit log entries matching the filter.
 func (s *Service) CountAuditLog(ctx context.Context, filter AuditLogFilter) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	// Build WHERE clause dynamically (same logic as GetAuditLog)
 	wb := NewWhereBuilder()
 	wb.Add("action", string(filter.Action))
@@ -387,6 +417,9 @@ func (s *Service) StreamAuditLog(ctx context.Context, filter AuditLogFilter, cal
 
 // GetAuditLogArchive retrieves archived audit log entries.
 func (s *Service) GetAuditLogArchive(ctx context.Context, filter AuditLogFilter) ([]AuditEntry, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	if filter.Limit <= 0 {
 		filter.Limit = DefaultHistoryLimit
 	}
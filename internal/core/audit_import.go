@@ -0,0 +1,709 @@
+package core
+
+// audit_import.go ingests audit-log exports from another deployment, so
+// history carries over when migrating databases or consolidating instances.
+//
+// Both export layouts produced by this tool are accepted: the streaming CSV
+// from /api/audit-log/export ("Timestamp" column, local time without zone)
+// and ExportAuditLog's CSV ("Created At", RFC 3339), mapped by header name.
+// JSON input is an array or a stream of AuditEntry objects. CSV exports omit
+// user ID, user agent, row data, batch and related-entry IDs, so entries
+// imported from CSV have those fields empty.
+//
+// Imported entries go straight to audit_log_archive, tagged with the
+// audit_log_imports row that records their provenance: an operator-supplied
+// source label, the file's SHA-256, and continuity notes. Entries whose ID
+// already exists here (in the hot table or the archive) are skipped, so
+// re-importing a file, or overlapping exports, is harmless. Imported entries
+// are purged after ARCHIVE_RETENTION_YEARS like any other archived entry.
+//
+// This deployment does not hash-chain its audit log, so there is no chain to
+// splice into. Instead each import records a chain digest over its entries
+// in file order:
+//
+//	h0 = 32 zero bytes
+//	hN = SHA-256(hN-1 || canonical JSON of entry N)
+//
+// where the canonical JSON is the entry's AuditEntry encoding with the
+// timestamp in UTC. Recomputing the digest from the source's own export
+// shows whether anything changed in transit; comparing it across re-imports
+// shows whether the source history was edited. Notes also flag entries out
+// of chronological order and gaps or overlaps with earlier imports from the
+// same source.
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Audit import formats.
+const (
+	AuditImportCSV  = "csv"
+	AuditImportJSON = "json"
+)
+
+// maxAuditImportErrors caps how many invalid entries are reported.
+const maxAuditImportErrors = 20
+
+// auditImportIDChunk bounds the IDs per existence query.
+const auditImportIDChunk = 10000
+
+// auditExportTimestamp is the layout of the streaming CSV export.
+const auditExportTimestamp = "2006-01-02 15:04:05"
+
+// AuditImportOptions controls an audit log import.
+type AuditImportOptions struct {
+	Source   string         // Label for the origin deployment (required)
+	FileName string         // Original file name, for provenance and format detection
+	Format   string         // AuditImportCSV or AuditImportJSON; empty detects
+	Location *time.Location // Zone of zone-less CSV timestamps (default UTC)
+}
+
+// AuditImport describes one import of audit history.
+type AuditImport struct {
+	ID              string     `json:"id"`
+	Source          string     `json:"source"`
+	FileName        string     `json:"fileName,omitempty"`
+	Format          string     `json:"format"`
+	FileSHA256      string     `json:"fileSha256"`
+	ChainDigest     string     `json:"chainDigest"`
+	EntriesTotal    int        `json:"entriesTotal"`
+	EntriesImported int        `json:"entriesImported"`
+	EntriesSkipped  int        `json:"entriesSkipped"`
+	FirstEntryAt    *time.Time `json:"firstEntryAt,omitempty"`
+	LastEntryAt     *time.Time `json:"lastEntryAt,omitempty"`
+	Notes           []string   `json:"notes"`
+	ImportedAt      time.Time  `json:"importedAt"`
+}
+
+// AuditImportError lists invalid entries in an import file. Nothing is
+// imported when it is returned.
+type AuditImportError struct {
+	Errors []string // "line N: ..." or "entry N: ...", capped at maxAuditImportErrors
+	Total  int      // Number of invalid entries
+}
+
+func (e *AuditImportError) Error() string {
+	msg := fmt.Sprintf("%d invalid audit entries: %s", e.Total, strings.Join(e.Errors, "; "))
+	if e.Total > len(e.Errors) {
+		msg += "; ..."
+	}
+	return msg
+}
+
+// add records an invalid entry.
+func (e *AuditImportError) add(format string, args ...any) {
+	e.Total++
+	if len(e.Errors) < maxAuditImportErrors {
+		e.Errors = append(e.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// detectAuditImportFormat picks the format from the file extension, falling
+// back to sniffing the first non-space byte.
+func detectAuditImportFormat(fileName string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json", ".ndjson", ".jsonl":
+		return AuditImportJSON
+	case ".csv":
+		return AuditImportCSV
+	}
+	trimmed := bytes.TrimLeft(stripBOM(data), " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return AuditImportJSON
+	}
+	return AuditImportCSV
+}
+
+// parseAuditExport parses an audit export into validated entries, in file
+// order. Notes describe columns that were ignored or missing.
+func parseAuditExport(data []byte, format string, loc *time.Location) ([]AuditEntry, []string, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	data = stripBOM(data)
+
+	switch format {
+	case AuditImportCSV:
+		return parseAuditCSV(data, loc)
+	case AuditImportJSON:
+		entries, err := parseAuditJSON(data)
+		return entries, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown audit import format %q (want csv or json)", format)
+	}
+}
+
+// auditCSVColumns maps lowercased export headers to entry fields.
+var auditCSVColumns = map[string]func(e *AuditEntry, v string){
+	"id":            func(e *AuditEntry, v string) { e.ID = v },
+	"action":        func(e *AuditEntry, v string) { e.Action = AuditAction(v) },
+	"severity":      func(e *AuditEntry, v string) { e.Severity = AuditSeverity(v) },
+	"table":         func(e *AuditEntry, v string) { e.TableKey = v },
+	"user email":    func(e *AuditEntry, v string) { e.UserEmail = v },
+	"user name":     func(e *AuditEntry, v string) { e.UserName = v },
+	"ip address":    func(e *AuditEntry, v string) { e.IPAddress = v },
+	"row key":       func(e *AuditEntry, v string) { e.RowKey = v },
+	"column":        func(e *AuditEntry, v string) { e.ColumnName = v },
+	"old value":     func(e *AuditEntry, v string) { e.OldValue = v },
+	"new value":     func(e *AuditEntry, v string) { e.NewValue = v },
+	"upload id":     func(e *AuditEntry, v string) { e.UploadID = v },
+	"reason":        func(e *AuditEntry, v string) { e.Reason = v },
+	"timestamp":     nil, // Parsed separately
+	"created at":    nil,
+	"rows affected": nil,
+}
+
+func parseAuditCSV(data []byte, loc *time.Location) ([]AuditEntry, []string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("empty file")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	var notes []string
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(h))
+		if _, known := auditCSVColumns[name]; !known {
+			notes = append(notes, fmt.Sprintf("ignored unknown column %q", h))
+			continue
+		}
+		cols[name] = i
+	}
+	timeCol, ok := cols["created at"]
+	if !ok {
+		timeCol, ok = cols["timestamp"]
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf(`CSV has no "Timestamp" or "Created At" column`)
+	}
+	for _, required := range []string{"id", "action"} {
+		if _, ok := cols[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV has no %q column", required)
+		}
+	}
+
+	var entries []AuditEntry
+	invalid := &AuditImportError{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			invalid.add("%v", err) // *csv.ParseError includes the line
+			continue
+		}
+		line, _ := r.FieldPos(0) // Quoted newlines make lines and records differ
+		if isEmptyRow(record) {
+			continue
+		}
+
+		cell := func(i int) string {
+			if i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		var e AuditEntry
+		for name, i := range cols {
+			if set := auditCSVColumns[name]; set != nil {
+				set(&e, cell(i))
+			}
+		}
+		if i, ok := cols["rows affected"]; ok && cell(i) != "" {
+			n, err := strconv.Atoi(cell(i))
+			if err != nil {
+				invalid.add("line %d: invalid rows affected %q", line, cell(i))
+				continue
+			}
+			e.RowsAffected = n
+		}
+		if e.CreatedAt, err = parseAuditTimestamp(cell(timeCol), loc); err != nil {
+			invalid.add("line %d: %v", line, err)
+			continue
+		}
+		if err := normalizeImportedEntry(&e); err != nil {
+			invalid.add("line %d: %v", line, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if invalid.Total > 0 {
+		return nil, nil, invalid
+	}
+	return entries, notes, nil
+}
+
+// parseAuditTimestamp accepts RFC 3339 or the streaming export's zone-less
+// layout, interpreted in loc.
+func parseAuditTimestamp(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(auditExportTimestamp, s, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+func parseAuditJSON(data []byte) ([]AuditEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// An array of entries, or a stream of entry objects (NDJSON)
+	array := false
+	if tok, err := dec.Token(); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty file")
+		}
+		return nil, fmt.Errorf("read JSON: %w", err)
+	} else if delim, ok := tok.(json.Delim); ok && delim == '[' {
+		array = true
+	} else {
+		dec = json.NewDecoder(bytes.NewReader(data))
+	}
+
+	var entries []AuditEntry
+	invalid := &AuditImportError{}
+	for n := 1; !array || dec.More(); n++ {
+		var e AuditEntry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The decoder cannot resynchronize after a syntax error
+			invalid.add("entry %d: %v", n, err)
+			break
+		}
+		if err := normalizeImportedEntry(&e); err != nil {
+			invalid.add("entry %d: %v", n, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if invalid.Total > 0 {
+		return nil, invalid
+	}
+	return entries, nil
+}
+
+// normalizeImportedEntry validates an entry and canonicalizes its fields:
+// lowercase UUIDs, a severity derived from the action when absent, a bare
+// IP address, and a UTC timestamp.
+func normalizeImportedEntry(e *AuditEntry) error {
+	id, err := uuid.Parse(strings.TrimSpace(e.ID))
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", e.ID)
+	}
+	e.ID = id.String()
+
+	if e.Action == "" {
+		return fmt.Errorf("missing action")
+	}
+	if e.CreatedAt.IsZero() {
+		return fmt.Errorf("missing timestamp")
+	}
+	e.CreatedAt = e.CreatedAt.UTC()
+
+	switch e.Severity {
+	case "":
+		e.Severity = determineSeverity(e.Action)
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+	default:
+		return fmt.Errorf("invalid severity %q", e.Severity)
+	}
+
+	if e.IPAddress != "" {
+		host := e.IPAddress
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return fmt.Errorf("invalid IP address %q", e.IPAddress)
+		}
+		e.IPAddress = addr.String()
+	}
+
+	for _, ref := range []*string{&e.UploadID, &e.BatchID, &e.RelatedAuditID} {
+		if *ref == "" {
+			continue
+		}
+		parsed, err := uuid.Parse(*ref)
+		if err != nil {
+			return fmt.Errorf("invalid UUID %q", *ref)
+		}
+		*ref = parsed.String()
+	}
+	return nil
+}
+
+// auditChainDigest returns the chained SHA-256 over entries in order (see
+// the file comment).
+func auditChainDigest(entries []AuditEntry) (string, error) {
+	h := make([]byte, sha256.Size)
+	for i := range entries {
+		canonical, err := json.Marshal(entries[i])
+		if err != nil {
+			return "", fmt.Errorf("encode entry %s: %w", entries[i].ID, err)
+		}
+		sum := sha256.New()
+		sum.Write(h)
+		sum.Write(canonical)
+		h = sum.Sum(h[:0])
+	}
+	return hex.EncodeToString(h), nil
+}
+
+// auditContinuityNotes describes ordering within the file. Gaps relative to
+// earlier imports are added by ImportAuditLog.
+func auditContinuityNotes(entries []AuditEntry) []string {
+	var notes []string
+	outOfOrder, firstLine := 0, 0
+	for i := 1; i < len(entries); i++ {
+		if entries[i].CreatedAt.Before(entries[i-1].CreatedAt) {
+			if outOfOrder == 0 {
+				firstLine = i + 1
+			}
+			outOfOrder++
+		}
+	}
+	if outOfOrder > 0 {
+		notes = append(notes, fmt.Sprintf("%d entries are earlier than the entry before them (first is entry %d); the chain digest follows file order", outOfOrder, firstLine))
+	}
+	return notes
+}
+
+// ImportAuditLog imports an audit export from another deployment into the
+// archive. The whole file is validated first; if any entry is invalid an
+// *AuditImportError is returned and nothing is written. Entries already
+// present are skipped. The import itself is recorded in the audit log and
+// as an audit_import operation.
+func (s *Service) ImportAuditLog(ctx context.Context, data []byte, opts AuditImportOptions) (*AuditImport, error) {
+	op := s.StartOperation(ctx, OperationAuditImport, "", []OperationStep{{Name: "import", Weight: 1}})
+	op.Begin("import")
+	imp, err := s.importAuditLog(ctx, data, opts)
+	if err == nil {
+		op.SetDetail("import", fmt.Sprintf("%d of %d entries imported", imp.EntriesImported, imp.EntriesTotal))
+		op.SetResultLink("/api/admin/audit-log/imports")
+	}
+	op.Finish(err)
+	return imp, err
+}
+
+// importAuditLog does the work of ImportAuditLog.
+func (s *Service) importAuditLog(ctx context.Context, data []byte, opts AuditImportOptions) (*AuditImport, error) {
+	source := strings.TrimSpace(opts.Source)
+	if source == "" {
+		return nil, fmt.Errorf("source is required")
+	}
+	format := opts.Format
+	if format == "" {
+		format = detectAuditImportFormat(opts.FileName, data)
+	}
+
+	fileSum := sha256.Sum256(data)
+	entries, notes, err := parseAuditExport(data, format, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no audit entries in file")
+	}
+
+	digest, err := auditChainDigest(entries)
+	if err != nil {
+		return nil, err
+	}
+	notes = append(notes, auditContinuityNotes(entries)...)
+	if format == AuditImportCSV {
+		notes = append(notes, "CSV exports omit user ID, user agent, row data, batch ID and related entry ID; imported entries have them empty")
+	}
+
+	imp := &AuditImport{
+		Source:       source,
+		FileName:     opts.FileName,
+		Format:       format,
+		FileSHA256:   hex.EncodeToString(fileSum[:]),
+		ChainDigest:  digest,
+		EntriesTotal: len(entries),
+	}
+	first, last := entries[0].CreatedAt, entries[0].CreatedAt
+	for _, e := range entries[1:] {
+		if e.CreatedAt.Before(first) {
+			first = e.CreatedAt
+		}
+		if e.CreatedAt.After(last) {
+			last = e.CreatedAt
+		}
+	}
+	imp.FirstEntryAt, imp.LastEntryAt = &first, &last
+
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize imports so concurrent ones cannot both insert an ID
+	if _, err := tx.Exec(ctx, "LOCK TABLE audit_log_imports IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, fmt.Errorf("lock audit imports: %w", err)
+	}
+
+	existing, err := existingAuditIDs(ctx, tx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	toInsert := make([]AuditEntry, 0, len(entries))
+	inFile := make(map[string]bool, len(entries))
+	dupInFile := 0
+	for _, e := range entries {
+		switch {
+		case existing[e.ID]:
+			imp.EntriesSkipped++
+		case inFile[e.ID]:
+			dupInFile++
+		default:
+			inFile[e.ID] = true
+			toInsert = append(toInsert, e)
+		}
+	}
+	imp.EntriesImported = len(toInsert)
+	if imp.EntriesSkipped > 0 {
+		notes = append(notes, fmt.Sprintf("%d entries were already present and skipped", imp.EntriesSkipped))
+	}
+	if dupInFile > 0 {
+		notes = append(notes, fmt.Sprintf("%d entries repeat an ID earlier in the file; only the first was imported", dupInFile))
+	}
+	imp.EntriesSkipped += dupInFile
+
+	notes = append(notes, auditImportGapNotes(ctx, tx, source, first, last)...)
+	imp.Notes = notes
+
+	var id pgtype.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_log_imports (
+			source, file_name, format, file_sha256, chain_digest,
+			entries_total, entries_imported, entries_skipped,
+			first_entry_at, last_entry_at, notes
+		) VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, imported_at`,
+		imp.Source, imp.FileName, imp.Format, imp.FileSHA256, imp.ChainDigest,
+		imp.EntriesTotal, imp.EntriesImported, imp.EntriesSkipped,
+		first, last, notes,
+	).Scan(&id, &imp.ImportedAt)
+	if err != nil {
+		return nil, fmt.Errorf("record audit import: %w", err)
+	}
+	imp.ID = PgUUIDToString(id)
+
+	if err := copyImportedAuditEntries(ctx, tx, id, toInsert); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	if _, err := s.LogAudit(ctx, AuditLogParams{
+		Action:       ActionAuditImport,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		RowsAffected: imp.EntriesImported,
+		Reason: fmt.Sprintf("Imported %d audit entries from %s (import %s, chain digest %s)",
+			imp.EntriesImported, imp.Source, imp.ID, imp.ChainDigest),
+	}); err != nil {
+		slog.Error("failed to log audit import", "import_id", imp.ID, "error", err)
+	}
+	return imp, nil
+}
+
+// existingAuditIDs returns which entry IDs are already in audit_log or
+// audit_log_archive.
+func existingAuditIDs(ctx context.Context, tx pgx.Tx, entries []AuditEntry) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(entries); start += auditImportIDChunk {
+		end := min(start+auditImportIDChunk, len(entries))
+		ids := make([]string, 0, end-start)
+		for _, e := range entries[start:end] {
+			ids = append(ids, e.ID)
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT id::text FROM audit_log WHERE id = ANY($1::uuid[])
+			UNION
+			SELECT id::text FROM audit_log_archive WHERE id = ANY($1::uuid[])`, ids)
+		if err != nil {
+			return nil, fmt.Errorf("check existing audit entries: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("check existing audit entries: %w", err)
+			}
+			existing[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("check existing audit entries: %w", err)
+		}
+	}
+	return existing, nil
+}
+
+// auditImportGapNotes compares the imported time range with the previous
+// import from the same source.
+func auditImportGapNotes(ctx context.Context, tx pgx.Tx, source string, first, last time.Time) []string {
+	var prevID pgtype.UUID
+	var prevFirst, prevLast pgtype.Timestamptz
+	err := tx.QueryRow(ctx, `
+		SELECT id, first_entry_at, last_entry_at
+		FROM audit_log_imports
+		WHERE source = $1
+		ORDER BY last_entry_at DESC NULLS LAST
+		LIMIT 1`, source).Scan(&prevID, &prevFirst, &prevLast)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []string{fmt.Sprintf("first import from source %q", source)}
+	}
+	if err != nil || !prevLast.Valid {
+		return nil
+	}
+
+	prev := PgUUIDToString(prevID)
+	switch {
+	case first.After(prevLast.Time):
+		return []string{fmt.Sprintf("starts %s after import %s from this source ended (%s); entries in between are not covered",
+			first.Sub(prevLast.Time).Round(time.Second), prev, prevLast.Time.Format(time.RFC3339))}
+	case last.Before(prevFirst.Time):
+		return []string{fmt.Sprintf("ends before import %s from this source began (%s)", prev, prevFirst.Time.Format(time.RFC3339))}
+	default:
+		return []string{fmt.Sprintf("overlaps import %s from this source (%s to %s)",
+			prev, prevFirst.Time.Format(time.RFC3339), prevLast.Time.Format(time.RFC3339))}
+	}
+}
+
+// copyImportedAuditEntries bulk-inserts entries into the archive via COPY.
+func copyImportedAuditEntries(ctx context.Context, tx pgx.Tx, importID pgtype.UUID, entries []AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	rows := make([][]any, len(entries))
+	for i, e := range entries {
+		var rowData []byte
+		if e.RowData != nil {
+			var err error
+			if rowData, err = json.Marshal(e.RowData); err != nil {
+				return fmt.Errorf("encode row data for %s: %w", e.ID, err)
+			}
+		}
+		var ip *netip.Addr
+		if e.IPAddress != "" {
+			addr, _ := netip.ParseAddr(e.IPAddress) // Validated by normalizeImportedEntry
+			ip = &addr
+		}
+		var rowsAffected pgtype.Int4
+		if e.RowsAffected != 0 {
+			rowsAffected = pgtype.Int4{Int32: int32(e.RowsAffected), Valid: true}
+		}
+
+		rows[i] = []any{
+			ToPgUUID(e.ID), string(e.Action), string(e.Severity), e.TableKey,
+			ToPgText(e.UserID), ToPgText(e.UserEmail), ToPgText(e.UserName),
+			ip, ToPgText(e.UserAgent),
+			ToPgText(e.RowKey), ToPgText(e.ColumnName),
+			ToPgText(e.OldValue), ToPgText(e.NewValue), rowData, rowsAffected,
+			ToPgUUID(e.UploadID), ToPgUUID(e.BatchID), ToPgUUID(e.RelatedAuditID),
+			ToPgText(e.Reason), e.CreatedAt, importID,
+		}
+	}
+
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"audit_log_archive"},
+		[]string{
+			"id", "action", "severity", "table_key",
+			"user_id", "user_email", "user_name",
+			"ip_address", "user_agent",
+			"row_key", "column_name",
+			"old_value", "new_value", "row_data", "rows_affected",
+			"upload_id", "batch_id", "related_audit_id",
+			"reason", "created_at", "import_id",
+		},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("COPY audit_log_archive: %w", err)
+	}
+	return nil
+}
+
+// ListAuditImports returns recorded audit imports, newest first.
+func (s *Service) ListAuditImports(ctx context.Context) ([]AuditImport, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, source, COALESCE(file_name, ''), format, file_sha256, chain_digest,
+		       entries_total, entries_imported, entries_skipped,
+		       first_entry_at, last_entry_at, notes, imported_at
+		FROM audit_log_imports
+		ORDER BY imported_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list audit imports: %w", err)
+	}
+	defer rows.Close()
+
+	imports := []AuditImport{}
+	for rows.Next() {
+		var imp AuditImport
+		var id pgtype.UUID
+		var first, last pgtype.Timestamptz
+		if err := rows.Scan(&id, &imp.Source, &imp.FileName, &imp.Format, &imp.FileSHA256, &imp.ChainDigest,
+			&imp.EntriesTotal, &imp.EntriesImported, &imp.EntriesSkipped,
+			&first, &last, &imp.Notes, &imp.ImportedAt); err != nil {
+			return nil, fmt.Errorf("scan audit import: %w", err)
+		}
+		imp.ID = PgUUIDToString(id)
+		if first.Valid {
+			imp.FirstEntryAt = &first.Time
+		}
+		if last.Valid {
+			imp.LastEntryAt = &last.Time
+		}
+		imports = append(imports, imp)
+	}
+	return imports, rows.Err()
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAuditExport_StreamingCSV(t *testing.T) {
+	data := "ID,Timestamp,Action,Severity,Table,User Email,User Name,IP Address,Row Key,Column,Old Value,New Value,Rows Affected,Upload ID,Reason\n" +
+		"6F9619FF-8B86-D011-B42D-00C04FC964FF,2024-03-01 09:30:00,upload,,sfdc_customers,a@example.com,,10.0.0.1:5123,,,,,42,,\"Uploaded a.csv, again\"\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e11,2024-03-01 10:00:00,cell_edit,medium,sfdc_customers,,,,K1,name,\"old\nvalue\",new,0,,\n"
+
+	loc, _ := time.LoadLocation("America/New_York")
+	entries, notes, err := parseAuditExport([]byte(data), AuditImportCSV, loc)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("unexpected notes %q", notes)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.ID != "6f9619ff-8b86-d011-b42d-00c04fc964ff" {
+		t.Errorf("ID = %q, want lowercase", e.ID)
+	}
+	if e.Severity != SeverityHigh {
+		t.Errorf("severity = %q, want derived %q", e.Severity, SeverityHigh)
+	}
+	if e.IPAddress != "10.0.0.1" {
+		t.Errorf("IP = %q, want port stripped", e.IPAddress)
+	}
+	if want := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC); !e.CreatedAt.Equal(want) || e.CreatedAt.Location() != time.UTC {
+		t.Errorf("created at = %v, want %v in UTC", e.CreatedAt, want)
+	}
+	if e.RowsAffected != 42 || e.Reason != "Uploaded a.csv, again" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if entries[1].OldValue != "old\nvalue" {
+		t.Errorf("old value = %q", entries[1].OldValue)
+	}
+}
+
+func TestParseAuditExport_ServiceCSVAndJSON(t *testing.T) {
+	csvData := "ID,Action,Severity,Table,Extra,Created At\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e11,table_reset,,t,x,2024-03-01T10:00:00+02:00\n"
+	entries, notes, err := parseAuditExport([]byte(csvData), AuditImportCSV, nil)
+	if err != nil {
+		t.Fatalf("parse CSV: %v", err)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], `"Extra"`) {
+		t.Errorf("notes = %q, want ignored column", notes)
+	}
+	if entries[0].CreatedAt.Hour() != 8 || entries[0].Severity != SeverityCritical {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	for name, data := range map[string]string{
+		"array":  `[{"id":"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e11","action":"upload","tableKey":"t","createdAt":"2024-03-01T10:00:00Z","rowData":{"a":1}}]`,
+		"stream": "{\"id\":\"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e11\",\"action\":\"upload\",\"tableKey\":\"t\",\"createdAt\":\"2024-03-01T10:00:00Z\",\"rowData\":{\"a\":1}}\n",
+	} {
+		entries, _, err := parseAuditExport([]byte(data), AuditImportJSON, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(entries) != 1 || entries[0].RowData["a"] != float64(1) {
+			t.Errorf("%s: unexpected entries %+v", name, entries)
+		}
+	}
+}
+
+func TestParseAuditExport_ReportsInvalidEntries(t *testing.T) {
+	data := "ID,Timestamp,Action,Severity,IP Address\n" +
+		"not-a-uuid,2024-03-01 09:30:00,upload,,\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e11,yesterday,upload,,\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e12,2024-03-01 09:30:00,upload,urgent,\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e13,2024-03-01 09:30:00,upload,,nowhere\n" +
+		"7a1c3a5e-2b4f-4e8e-9d55-3f2b1c0d9e14,2024-03-01 09:30:00,upload,,\n"
+
+	_, _, err := parseAuditExport([]byte(data), AuditImportCSV, nil)
+	var importErr *AuditImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("error = %v, want *AuditImportError", err)
+	}
+	if importErr.Total != 4 {
+		t.Errorf("total = %d, want 4: %q", importErr.Total, importErr.Errors)
+	}
+	for i, want := range []string{"line 2: invalid ID", "line 3: invalid timestamp", "line 4: invalid severity", "line 5: invalid IP"} {
+		if !strings.HasPrefix(importErr.Errors[i], want) {
+			t.Errorf("error %d = %q, want prefix %q", i, importErr.Errors[i], want)
+		}
+	}
+
+	if _, _, err := parseAuditExport([]byte("ID,Action\n"), AuditImportCSV, nil); err == nil {
+		t.Error("expected error for missing timestamp column")
+	}
+}
+
+func TestAuditChainDigest(t *testing.T) {
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	a := AuditEntry{ID: "a", Action: ActionUpload, CreatedAt: at}
+	b := AuditEntry{ID: "b", Action: ActionCellEdit, CreatedAt: at.Add(time.Minute)}
+
+	empty, _ := auditChainDigest(nil)
+	if empty != strings.Repeat("0", 64) {
+		t.Errorf("empty digest = %s", empty)
+	}
+	ab, _ := auditChainDigest([]AuditEntry{a, b})
+	ba, _ := auditChainDigest([]AuditEntry{b, a})
+	if ab == ba {
+		t.Error("digest does not depend on order")
+	}
+	b.Reason = "edited"
+	if edited, _ := auditChainDigest([]AuditEntry{a, b}); edited == ab {
+		t.Error("digest does not depend on content")
+	}
+
+	notes := auditContinuityNotes([]AuditEntry{b, a})
+	if len(notes) != 1 || !strings.Contains(notes[0], "first is entry 2") {
+		t.Errorf("notes = %q", notes)
+	}
+}
+
+func TestDetectAuditImportFormat(t *testing.T) {
+	tests := []struct {
+		name, file, data, want string
+	}{
+		{"json extension", "audit.json", "ID,Action", AuditImportJSON},
+		{"csv extension", "audit.csv", "[", AuditImportCSV},
+		{"sniff array", "", "\xef\xbb\xbf  [{}]", AuditImportJSON},
+		{"sniff stream", "export", "{\"id\":1}", AuditImportJSON},
+		{"sniff csv", "", "ID,Timestamp", AuditImportCSV},
+	}
+	for _, tt := range tests {
+		if got := detectAuditImportFormat(tt.file, []byte(tt.data)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestAuditActionsAllowedBySchema checks that the newest migration setting
+// audit_log_action_check allows every AuditAction, so no audit entry is
+// rejected by the database.
+func TestAuditActionsAllowedBySchema(t *testing.T) {
+	files, err := filepath.Glob("../../sql/schema/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	sort.Strings(files)
+
+	var check string
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if i := strings.LastIndex(up, "ADD CONSTRAINT audit_log_action_check"); i >= 0 {
+			check = up[i:]
+		}
+	}
+	allowed := make(map[string]bool)
+	for _, m := range regexp.MustCompile(`'([a-z_]+)'`).FindAllStringSubmatch(check, -1) {
+		allowed[m[1]] = true
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "audit.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	ast.Inspect(f, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		if id, ok := spec.Type.(*ast.Ident); !ok || id.Name != "AuditAction" {
+			return true
+		}
+		for i, v := range spec.Values {
+			lit, ok := v.(*ast.BasicLit)
+			if !ok {
+				continue
+			}
+			action, _ := strconv.Unquote(lit.Value)
+			n++
+			if !allowed[action] {
+				t.Errorf("%s (%q) is not allowed by audit_log_action_check", spec.Names[i].Name, action)
+			}
+		}
+		return true
+	})
+	if n == 0 {
+		t.Fatal("no audit actions found in audit.go")
+	}
+}
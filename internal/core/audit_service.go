@@ -239,6 +239,8 @@ func (a *AuditService) LogTemplateDelete(ctx context.Context, entry TemplateAudi
 
 // Log creates a new audit log entry using the generic params structure.
 func (a *AuditService) Log(ctx context.Context, params AuditLogParams) (*AuditEntry, error) {
+	params = withContextUser(ctx, params)
+	params = withApproval(ctx, params)
 	severity := auditSeverity(params.Action)
 
 	var rowDataJSON []byte
@@ -745,11 +747,11 @@ func fillAuditOptionalFields(entry *AuditEntry,
 // auditSeverity returns the appropriate severity for an action.
 func auditSeverity(action AuditAction) AuditSeverity {
 	switch action {
-	case ActionUpload, ActionUploadRollback, ActionBulkEdit, ActionRowDelete:
+	case ActionUpload, ActionUploadRollback, ActionBulkEdit, ActionRowDelete, ActionAuthLockout, ActionAuditImport, ActionColumnBackfill, ActionUploadReview, ActionDataExport, ActionRowPurge, ActionUserCreate, ActionUserDisable, ActionTableAction, ActionApprovalRequest, ActionApprovalReject:
 		return SeverityHigh
-	case ActionTableReset:
+	case ActionTableReset, ActionLegalHoldSet, ActionLegalHoldRelease, ActionApprovalGrant:
 		return SeverityCritical
-	case ActionTemplateCreate, ActionTemplateUpdate, ActionTemplateDelete:
+	case ActionTemplateCreate, ActionTemplateUpdate, ActionTemplateDelete, ActionRequestRejected:
 		return SeverityLow
 	default:
 		return SeverityMedium
@@ -0,0 +1,593 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BackfillKind is where a backfill takes its values from.
+type BackfillKind string
+
+const (
+	// BackfillReparse re-parses another column of the same row with the
+	// target column's type and normalizer.
+	BackfillReparse BackfillKind = "reparse"
+	// BackfillCompute derives the value from another column of the same row
+	// with a named function (see backfillFunctions) or BackfillSource.Compute.
+	BackfillCompute BackfillKind = "compute"
+	// BackfillLookup copies the value from a matching row of another table.
+	BackfillLookup BackfillKind = "lookup"
+)
+
+// DefaultBackfillBatchSize is the number of rows read and updated per
+// backfill transaction when BackfillSource.BatchSize is not set.
+const DefaultBackfillBatchSize = 1000
+
+// BackfillSource describes how BackfillColumn fills a column.
+type BackfillSource struct {
+	Kind BackfillKind `json:"kind"`
+
+	// Column is the column of the same table read by reparse and compute.
+	Column string `json:"column,omitempty"`
+
+	// Function names the compute function; see backfillFunctions.
+	Function string `json:"function,omitempty"`
+	// FiscalYearStartMonth is the first month (1-12) of the fiscal year for
+	// fiscal_year and fiscal_quarter. Defaults to January.
+	FiscalYearStartMonth int `json:"fiscalYearStartMonth,omitempty"`
+	// Compute, when set, is used instead of Function. It receives the
+	// source column's value as text and returns the new value as text;
+	// an empty result leaves the row NULL.
+	Compute func(value string) (string, error) `json:"-"`
+
+	// LookupTable is the table values are copied from. A row matches when
+	// its LookupKey equals this row's MatchColumn; the value is read from
+	// LookupValue. Rows with no match, or whose key has conflicting values
+	// in LookupTable, are left unchanged.
+	LookupTable string `json:"lookupTable,omitempty"`
+	MatchColumn string `json:"matchColumn,omitempty"`
+	LookupKey   string `json:"lookupKey,omitempty"`
+	LookupValue string `json:"lookupValue,omitempty"`
+
+	// Overwrite replaces existing values. By default only rows where the
+	// column is NULL are filled.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// BatchSize is the number of rows per transaction.
+	BatchSize int `json:"batchSize,omitempty"`
+}
+
+// BackfillResult summarizes a backfill.
+type BackfillResult struct {
+	TableKey  string   `json:"tableKey"`
+	Column    string   `json:"column"`
+	Scanned   int      `json:"scanned"`
+	Updated   int      `json:"updated"`
+	Skipped   int      `json:"skipped"`          // No value could be derived
+	Errors    []string `json:"errors,omitempty"` // First few reasons rows were skipped
+	Cancelled bool     `json:"cancelled,omitempty"`
+}
+
+// maxBackfillErrors caps BackfillResult.Errors.
+const maxBackfillErrors = 10
+
+// backfillFunctions are the named compute functions. Date functions parse
+// the source value with ToPgDate.
+var backfillFunctions = map[string]func(value string, fiscalStart int) (string, error){
+	"year": func(v string, _ int) (string, error) {
+		t, err := backfillDate(v)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(t.Year()), nil
+	},
+	"month": func(v string, _ int) (string, error) {
+		t, err := backfillDate(v)
+		if err != nil {
+			return "", err
+		}
+		return t.Format("2006-01"), nil
+	},
+	"quarter": func(v string, _ int) (string, error) {
+		t, err := backfillDate(v)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d Q%d", t.Year(), (int(t.Month())-1)/3+1), nil
+	},
+	"fiscal_year": func(v string, start int) (string, error) {
+		t, err := backfillDate(v)
+		if err != nil {
+			return "", err
+		}
+		year, _ := fiscalPeriod(t.Year(), int(t.Month()), start)
+		return fmt.Sprintf("FY%d", year), nil
+	},
+	"fiscal_quarter": func(v string, start int) (string, error) {
+		t, err := backfillDate(v)
+		if err != nil {
+			return "", err
+		}
+		year, quarter := fiscalPeriod(t.Year(), int(t.Month()), start)
+		return fmt.Sprintf("FY%d Q%d", year, quarter), nil
+	},
+	"upper": func(v string, _ int) (string, error) { return strings.ToUpper(v), nil },
+	"lower": func(v string, _ int) (string, error) { return strings.ToLower(v), nil },
+	"trim":  func(v string, _ int) (string, error) { return strings.TrimSpace(v), nil },
+}
+
+// BackfillFunctions returns the names of the built-in compute functions.
+func BackfillFunctions() []string {
+	return []string{"year", "month", "quarter", "fiscal_year", "fiscal_quarter", "upper", "lower", "trim"}
+}
+
+func backfillDate(v string) (time.Time, error) {
+	d := ToPgDate(v)
+	if !d.Valid {
+		return time.Time{}, fmt.Errorf("invalid date %q", v)
+	}
+	return d.Time, nil
+}
+
+// fiscalPeriod returns the fiscal year and quarter of a calendar month for
+// a fiscal year starting in month start. A fiscal year is named after the
+// calendar year it ends in.
+func fiscalPeriod(year, month, start int) (fiscalYear, quarter int) {
+	if start < 1 || start > 12 {
+		start = 1
+	}
+	offset := (month - start + 12) % 12
+	fiscalYear = year
+	if start > 1 && month >= start {
+		fiscalYear++
+	}
+	return fiscalYear, offset/3 + 1
+}
+
+// backfillPlan is a validated BackfillSource resolved to database columns.
+type backfillPlan struct {
+	def      TableDefinition
+	column   string // Current header name of the target
+	dbCol    string
+	spec     *FieldSpec
+	sqlType  string // Target column type as reported by the database
+	srcCol   string // Source column (reparse, compute) or match column (lookup)
+	lookup   TableDefinition
+	keyCol   string
+	valueCol string
+	compute  func(string) (string, error)
+}
+
+// describe returns a one-line description of the source for audit entries.
+func (p *backfillPlan) describe(src BackfillSource) string {
+	switch src.Kind {
+	case BackfillLookup:
+		return fmt.Sprintf("lookup %s.%s by %s = %s", p.lookup.Info.Key, p.valueCol, p.srcCol, p.keyCol)
+	case BackfillCompute:
+		fn := src.Function
+		if src.Compute != nil {
+			fn = "custom"
+		}
+		return fmt.Sprintf("compute %s(%s)", fn, p.srcCol)
+	default:
+		return fmt.Sprintf("reparse %s", p.srcCol)
+	}
+}
+
+// planBackfill validates src against the registry. Column types are
+// checked against the database by BackfillColumn.
+func planBackfill(tableKey, column string, src BackfillSource) (*backfillPlan, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	p := &backfillPlan{def: def}
+
+	var err error
+	if p.column, p.spec, err = backfillColumn(def, column); err != nil {
+		return nil, err
+	}
+	p.dbCol = resolveDBColumn(p.column, def.FieldSpecs)
+	if containsFold(def.Info.UniqueKey, p.column) {
+		return nil, fmt.Errorf("cannot backfill unique key column %s", p.column)
+	}
+
+	switch src.Kind {
+	case BackfillReparse, BackfillCompute:
+		name, _, err := backfillColumn(def, src.Column)
+		if err != nil {
+			return nil, fmt.Errorf("source %w", err)
+		}
+		p.srcCol = resolveDBColumn(name, def.FieldSpecs)
+		if src.Kind == BackfillReparse {
+			if strings.EqualFold(name, p.column) && p.spec.Normalizer == nil {
+				return nil, fmt.Errorf("reparse source is the target column")
+			}
+			break
+		}
+		switch {
+		case src.Compute != nil:
+			p.compute = src.Compute
+		case backfillFunctions[src.Function] != nil:
+			fn, start := backfillFunctions[src.Function], src.FiscalYearStartMonth
+			if start < 0 || start > 12 {
+				return nil, fmt.Errorf("fiscal year start month must be 1-12, got %d", start)
+			}
+			p.compute = func(v string) (string, error) { return fn(v, start) }
+		case src.Function == "":
+			return nil, fmt.Errorf("compute requires a function (%s)", strings.Join(BackfillFunctions(), ", "))
+		default:
+			return nil, fmt.Errorf("unknown compute function %q (%s)", src.Function, strings.Join(BackfillFunctions(), ", "))
+		}
+
+	case BackfillLookup:
+		lookup, ok := Get(src.LookupTable)
+		if !ok {
+			return nil, fmt.Errorf("unknown lookup table: %s", src.LookupTable)
+		}
+		p.lookup = lookup
+		match, _, err := backfillColumn(def, src.MatchColumn)
+		if err != nil {
+			return nil, fmt.Errorf("match %w", err)
+		}
+		key, _, err := backfillColumn(lookup, src.LookupKey)
+		if err != nil {
+			return nil, fmt.Errorf("lookup key %w", err)
+		}
+		value, _, err := backfillColumn(lookup, src.LookupValue)
+		if err != nil {
+			return nil, fmt.Errorf("lookup value %w", err)
+		}
+		p.srcCol = resolveDBColumn(match, def.FieldSpecs)
+		p.keyCol = resolveDBColumn(key, lookup.FieldSpecs)
+		p.valueCol = resolveDBColumn(value, lookup.FieldSpecs)
+
+	default:
+		return nil, fmt.Errorf("unknown backfill source %q (reparse, compute or lookup)", src.Kind)
+	}
+	return p, nil
+}
+
+// backfillColumn resolves col, which may be an old name, to a current
+// column of def and its FieldSpec.
+func backfillColumn(def TableDefinition, col string) (string, *FieldSpec, error) {
+	if col == "" {
+		return "", nil, fmt.Errorf("column is required")
+	}
+	name := ResolveColumnName(def, col, "backfill")
+	for i := range def.FieldSpecs {
+		if strings.EqualFold(def.FieldSpecs[i].Name, name) {
+			return def.FieldSpecs[i].Name, &def.FieldSpecs[i], nil
+		}
+	}
+	return "", nil, fmt.Errorf("column %q not found in %s", col, def.Info.Key)
+}
+
+// convert turns a derived text value into the target column's type.
+// ok is false when the value is empty or does not parse.
+func (p *backfillPlan) convert(value string) (v any, ok bool) {
+	if p.spec.Normalizer != nil {
+		value = p.spec.Normalizer(value)
+	}
+	switch p.spec.Type {
+	case FieldNumeric:
+		n := ToPgNumeric(value)
+		return n, n.Valid
+	case FieldDate:
+		d := ToPgDate(value)
+		return d, d.Valid
+	case FieldBool:
+		b := ToPgBool(value)
+		return b, b.Valid
+	case FieldEnum:
+		t := ToPgText(value)
+		if t.Valid && len(p.spec.EnumValues) > 0 && !containsFold(p.spec.EnumValues, t.String) {
+			return nil, false
+		}
+		return t, t.Valid
+	default:
+		t := ToPgText(value)
+		return t, t.Valid
+	}
+}
+
+// BackfillColumn populates column of existing rows in tableKey from src, in
+// batches of src.BatchSize rows, each in its own transaction. Unless
+// src.Overwrite is set only NULL values are filled, so an interrupted
+// backfill can simply be run again. Rows for which no value can be derived
+// are left unchanged and counted as skipped.
+//
+// The column must already exist in the database (see
+// GenerateSchemaMigration). Progress is reported as a backfill operation
+// and the result is recorded in the audit log.
+func (s *Service) BackfillColumn(ctx context.Context, tableKey, column string, src BackfillSource) (*BackfillResult, error) {
+	p, err := planBackfill(tableKey, column, src)
+	if err != nil {
+		return nil, err
+	}
+	op := s.StartOperation(ctx, OperationBackfill, tableKey, []OperationStep{{Name: stepBackfill, Weight: 1}})
+	result, err := s.runBackfill(ctx, op, p, src)
+	op.Finish(err)
+	return result, err
+}
+
+// StartBackfill validates src and runs BackfillColumn in the background,
+// returning the operation ID to follow it with. The backfill keeps running
+// if ctx is cancelled; CancelOperation stops it after the current batch.
+func (s *Service) StartBackfill(ctx context.Context, tableKey, column string, src BackfillSource) (string, error) {
+	p, err := planBackfill(tableKey, column, src)
+	if err != nil {
+		return "", err
+	}
+	op := s.StartOperation(ctx, OperationBackfill, tableKey, []OperationStep{{Name: stepBackfill, Weight: 1}})
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		_, err := s.runBackfill(ctx, op, p, src)
+		return err
+	})
+	return op.ID(), nil
+}
+
+// stepBackfill is the single step of a backfill operation.
+const stepBackfill = "backfill"
+
+// runBackfill does the work of BackfillColumn under op.
+func (s *Service) runBackfill(ctx context.Context, op *Operation, p *backfillPlan, src BackfillSource) (*BackfillResult, error) {
+	tableKey := p.def.Info.Key
+	result := &BackfillResult{TableKey: tableKey, Column: p.column}
+	op.Begin(stepBackfill)
+	defer s.invalidateQueryCache(tableKey)
+
+	if err := s.checkBackfillColumns(ctx, p); err != nil {
+		op.EndStep(stepBackfill, err)
+		return nil, err
+	}
+
+	batchSize := src.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatchSize
+	}
+	pending := ""
+	if !src.Overwrite {
+		pending = fmt.Sprintf(" AND %s IS NULL", quoteIdentifier(p.dbCol))
+	}
+
+	countCtx, cancel := s.withOpTimeout(ctx, opAggregate)
+	var total int64
+	err := s.pool.QueryRow(countCtx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE TRUE%s",
+		quoteIdentifier(tableKey), pending)).Scan(&total)
+	cancel()
+	if err != nil {
+		err = fmt.Errorf("count rows: %w", err)
+		op.EndStep(stepBackfill, err)
+		return nil, err
+	}
+	op.Advance(stepBackfill, 0, total)
+
+	// Keyset pagination on id: rows that could not be filled stay NULL and
+	// must not be read again.
+	lastID := "00000000-0000-0000-0000-000000000000"
+	for {
+		if ctx.Err() != nil {
+			result.Cancelled = true
+			break
+		}
+		n, next, err := s.backfillBatch(ctx, p, src, pending, lastID, batchSize, result)
+		if err != nil {
+			err = fmt.Errorf("backfill %s.%s after %d rows: %w", tableKey, p.dbCol, result.Updated, err)
+			op.EndStep(stepBackfill, err)
+			s.logBackfill(ctx, p, src, result)
+			return result, err
+		}
+		if n == 0 {
+			break
+		}
+		lastID = next
+		op.Advance(stepBackfill, int64(result.Scanned), total)
+	}
+
+	op.SetDetail(stepBackfill, fmt.Sprintf("%d of %d rows updated", result.Updated, result.Scanned))
+	if result.Cancelled {
+		op.EndStep(stepBackfill, ctx.Err())
+	} else {
+		op.EndStep(stepBackfill, nil)
+	}
+	s.logBackfill(ctx, p, src, result)
+	if result.Cancelled {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// checkBackfillColumns confirms the target and source columns exist in
+// the database and records the target's type.
+func (s *Service) checkBackfillColumns(ctx context.Context, p *backfillPlan) error {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	cols, err := s.tableColumnTypes(ctx, p.def.Info.Key)
+	if err != nil {
+		return err
+	}
+	typ, ok := cols[p.dbCol]
+	if !ok {
+		return fmt.Errorf("column %s does not exist in %s; apply the schema migration first", p.dbCol, p.def.Info.Key)
+	}
+	p.sqlType = typ
+	if _, ok := cols[p.srcCol]; !ok {
+		return fmt.Errorf("column %s does not exist in %s", p.srcCol, p.def.Info.Key)
+	}
+
+	if p.lookup.Info.Key == "" {
+		return nil
+	}
+	lookupCols, err := s.tableColumnTypes(ctx, p.lookup.Info.Key)
+	if err != nil {
+		return err
+	}
+	for _, col := range []string{p.keyCol, p.valueCol} {
+		if _, ok := lookupCols[col]; !ok {
+			return fmt.Errorf("column %s does not exist in %s", col, p.lookup.Info.Key)
+		}
+	}
+	return nil
+}
+
+// backfillBatch fills up to limit rows after lastID in one transaction.
+// It returns the number of rows read and the last ID read.
+func (s *Service) backfillBatch(ctx context.Context, p *backfillPlan, src BackfillSource, pending, lastID string, limit int, result *BackfillResult) (int, string, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	table := quoteIdentifier(p.def.Info.Key)
+	rows, err := tx.Query(ctx, fmt.Sprintf(
+		"SELECT id::text, %s::text FROM %s WHERE id > $1%s ORDER BY id LIMIT $2",
+		quoteIdentifier(p.srcCol), table, pending,
+	), lastID, limit)
+	if err != nil {
+		return 0, "", fmt.Errorf("read batch: %w", err)
+	}
+	var ids, values []string
+	var nulls []bool
+	for rows.Next() {
+		var id string
+		var value *string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, "", fmt.Errorf("scan row: %w", err)
+		}
+		ids = append(ids, id)
+		values = append(values, "")
+		nulls = append(nulls, value == nil)
+		if value != nil {
+			values[len(values)-1] = *value
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, "", fmt.Errorf("read batch: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, "", nil
+	}
+	result.Scanned += len(ids)
+
+	var updated int
+	if src.Kind == BackfillLookup {
+		updated, err = s.backfillLookupBatch(ctx, tx, p, ids)
+		if err != nil {
+			return 0, "", err
+		}
+		result.Skipped += len(ids) - updated
+	} else {
+		batch := &pgx.Batch{}
+		query := fmt.Sprintf("UPDATE %s SET %s = $1 WHERE id = $2", table, quoteIdentifier(p.dbCol))
+		for i, id := range ids {
+			if nulls[i] {
+				result.Skipped++
+				continue
+			}
+			derived := values[i]
+			if p.compute != nil {
+				if derived, err = p.compute(derived); err != nil {
+					result.skip(fmt.Sprintf("row %s: %v", id, err))
+					continue
+				}
+			}
+			v, ok := p.convert(derived)
+			if !ok {
+				if derived != "" {
+					result.skip(fmt.Sprintf("row %s: %q is not a valid %s value", id, derived, p.sqlType))
+				} else {
+					result.Skipped++
+				}
+				continue
+			}
+			batch.Queue(query, v, id)
+		}
+		if batch.Len() > 0 {
+			br := tx.SendBatch(ctx, batch)
+			for range batch.Len() {
+				tag, err := br.Exec()
+				if err != nil {
+					br.Close()
+					return 0, "", fmt.Errorf("update rows: %w", err)
+				}
+				updated += int(tag.RowsAffected())
+			}
+			if err := br.Close(); err != nil {
+				return 0, "", fmt.Errorf("update rows: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, "", fmt.Errorf("commit: %w", err)
+	}
+	result.Updated += updated
+	return len(ids), ids[len(ids)-1], nil
+}
+
+// backfillLookupBatch copies lookup values onto the rows in ids. Keys with
+// more than one distinct value in the lookup table are ambiguous and left
+// unchanged.
+func (s *Service) backfillLookupBatch(ctx context.Context, tx pgx.Tx, p *backfillPlan, ids []string) (int, error) {
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE %[1]s t SET %[2]s = l.value::%[3]s
+		FROM (
+			SELECT %[5]s::text AS key, MIN(%[6]s::text) AS value
+			FROM %[4]s
+			WHERE %[5]s IS NOT NULL AND %[6]s IS NOT NULL
+			GROUP BY %[5]s::text
+			HAVING COUNT(DISTINCT %[6]s::text) = 1
+		) l
+		WHERE t.id = ANY($1::uuid[]) AND t.%[7]s::text = l.key`,
+		quoteIdentifier(p.def.Info.Key), quoteIdentifier(p.dbCol), p.sqlType,
+		quoteIdentifier(p.lookup.Info.Key), quoteIdentifier(p.keyCol), quoteIdentifier(p.valueCol),
+		quoteIdentifier(p.srcCol),
+	), ids)
+	if err != nil {
+		return 0, fmt.Errorf("lookup update: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// skip counts a skipped row and keeps the first few reasons.
+func (r *BackfillResult) skip(reason string) {
+	r.Skipped++
+	if len(r.Errors) < maxBackfillErrors {
+		r.Errors = append(r.Errors, reason)
+	}
+}
+
+// logBackfill records a backfill in the audit log.
+func (s *Service) logBackfill(ctx context.Context, p *backfillPlan, src BackfillSource, result *BackfillResult) {
+	reason := fmt.Sprintf("Backfilled %d of %d rows", result.Updated, result.Scanned)
+	if result.Cancelled {
+		reason += " (cancelled)"
+	}
+	// Record the backfill even when it was cancelled part way
+	ctx = context.WithoutCancel(ctx)
+	s.LogAudit(ctx, AuditLogParams{
+		Action:       ActionColumnBackfill,
+		TableKey:     p.def.Info.Key,
+		ColumnName:   p.column,
+		NewValue:     p.describe(src),
+		RowsAffected: result.Updated,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       reason,
+	})
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFiscalPeriod(t *testing.T) {
+	tests := []struct {
+		year, month, start int
+		wantYear, wantQ    int
+	}{
+		{2024, 1, 1, 2024, 1},
+		{2024, 12, 1, 2024, 4},
+		{2024, 2, 2, 2025, 1}, // FY starting February is named for the year it ends in
+		{2025, 1, 2, 2025, 4},
+		{2024, 10, 10, 2025, 1},
+		{2024, 9, 10, 2024, 4},
+		{2024, 5, 0, 2024, 2}, // Unset start defaults to January
+	}
+	for _, tt := range tests {
+		y, q := fiscalPeriod(tt.year, tt.month, tt.start)
+		if y != tt.wantYear || q != tt.wantQ {
+			t.Errorf("fiscalPeriod(%d, %d, %d) = FY%d Q%d, want FY%d Q%d",
+				tt.year, tt.month, tt.start, y, q, tt.wantYear, tt.wantQ)
+		}
+	}
+}
+
+func TestBackfillFunctions(t *testing.T) {
+	tests := []struct {
+		fn, value string
+		start     int
+		want      string
+	}{
+		{"year", "2024-03-15", 0, "2024"},
+		{"month", "03/15/2024", 0, "2024-03"},
+		{"quarter", "2024-08-01", 0, "2024 Q3"},
+		{"fiscal_year", "2024-08-01", 7, "FY2025"},
+		{"fiscal_quarter", "2024-08-01", 7, "FY2025 Q1"},
+		{"upper", "acme", 0, "ACME"},
+		{"trim", "  acme ", 0, "acme"},
+	}
+	for _, tt := range tests {
+		got, err := backfillFunctions[tt.fn](tt.value, tt.start)
+		if err != nil || got != tt.want {
+			t.Errorf("%s(%q) = %q, %v; want %q", tt.fn, tt.value, got, err, tt.want)
+		}
+	}
+	if _, err := backfillFunctions["year"]("not a date", 0); err == nil {
+		t.Error("year of invalid date: expected error")
+	}
+	for _, name := range BackfillFunctions() {
+		if backfillFunctions[name] == nil {
+			t.Errorf("BackfillFunctions lists %q, which is not defined", name)
+		}
+	}
+	if len(BackfillFunctions()) != len(backfillFunctions) {
+		t.Error("BackfillFunctions does not list every function")
+	}
+}
+
+func registerBackfillTables(t *testing.T) {
+	t.Helper()
+	Register(TableDefinition{
+		Info: TableInfo{Key: "backfill_orders", UniqueKey: []string{"Order ID"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Order ID", Type: FieldText},
+			{Name: "Close Date", Type: FieldDate},
+			{Name: "Amount Raw", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Fiscal Quarter", DBColumn: "fiscal_qtr", Type: FieldText},
+			{Name: "Account ID", Type: FieldText},
+			{Name: "Region", Type: FieldText},
+		},
+		Renames: []ColumnRename{{From: "Closed On", To: "Close Date"}},
+	})
+	Register(TableDefinition{
+		Info: TableInfo{Key: "backfill_accounts"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Account ID", Type: FieldText},
+			{Name: "Territory", Type: FieldText},
+		},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "backfill_orders")
+		delete(registry, "backfill_accounts")
+		registryMu.Unlock()
+	})
+}
+
+func TestPlanBackfill(t *testing.T) {
+	registerBackfillTables(t)
+
+	p, err := planBackfill("backfill_orders", "fiscal quarter", BackfillSource{
+		Kind: BackfillCompute, Column: "Closed On", Function: "fiscal_quarter", FiscalYearStartMonth: 2,
+	})
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if p.dbCol != "fiscal_qtr" || p.srcCol != "close_date" || p.column != "Fiscal Quarter" {
+		t.Errorf("plan = %+v", p)
+	}
+	if got, _ := p.compute("2024-02-10"); got != "FY2025 Q1" {
+		t.Errorf("compute = %q", got)
+	}
+
+	p, err = planBackfill("backfill_orders", "Region", BackfillSource{
+		Kind: BackfillLookup, LookupTable: "backfill_accounts",
+		MatchColumn: "Account ID", LookupKey: "Account ID", LookupValue: "Territory",
+	})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if p.keyCol != "account_id" || p.valueCol != "territory" || p.srcCol != "account_id" {
+		t.Errorf("lookup plan = %+v", p)
+	}
+
+	tests := []struct {
+		name   string
+		column string
+		src    BackfillSource
+		want   string
+	}{
+		{"unknown kind", "Amount", BackfillSource{Kind: "guess", Column: "Amount Raw"}, "unknown backfill source"},
+		{"unknown target", "Nope", BackfillSource{Kind: BackfillReparse, Column: "Amount Raw"}, `column "Nope" not found`},
+		{"unique key target", "Order ID", BackfillSource{Kind: BackfillReparse, Column: "Amount Raw"}, "unique key"},
+		{"missing source", "Amount", BackfillSource{Kind: BackfillReparse}, "source column is required"},
+		{"reparse itself", "Amount", BackfillSource{Kind: BackfillReparse, Column: "amount"}, "is the target column"},
+		{"no function", "Fiscal Quarter", BackfillSource{Kind: BackfillCompute, Column: "Close Date"}, "requires a function"},
+		{"unknown function", "Fiscal Quarter", BackfillSource{Kind: BackfillCompute, Column: "Close Date", Function: "week"}, "unknown compute function"},
+		{"bad fiscal start", "Fiscal Quarter", BackfillSource{Kind: BackfillCompute, Column: "Close Date", Function: "fiscal_year", FiscalYearStartMonth: 13}, "1-12"},
+		{"unknown lookup table", "Region", BackfillSource{Kind: BackfillLookup, LookupTable: "nope"}, "unknown lookup table"},
+		{"unknown lookup value", "Region", BackfillSource{
+			Kind: BackfillLookup, LookupTable: "backfill_accounts",
+			MatchColumn: "Account ID", LookupKey: "Account ID", LookupValue: "Owner",
+		}, "lookup value column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := planBackfill("backfill_orders", tt.column, tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackfillPlan_Convert(t *testing.T) {
+	registerBackfillTables(t)
+
+	p, err := planBackfill("backfill_orders", "Amount", BackfillSource{Kind: BackfillReparse, Column: "Amount Raw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.convert("($1,250.00)"); !ok {
+		t.Error("accounting amount should convert")
+	}
+	if _, ok := p.convert("n/a"); ok {
+		t.Error("non-numeric value should not convert")
+	}
+	if _, ok := p.convert(""); ok {
+		t.Error("empty value should not convert")
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+// batch_delete.go removes large sets of rows in bounded statements.
+//
+// Table resets and upload rollbacks used to be one DELETE, which on a table
+// of millions of rows runs past any reasonable timeout and holds its locks
+// the whole time. Instead they delete DefaultDeleteBatchSize rows at a time
+// by id, advancing an operation step after each batch and checking for
+// cancellation in between. Outside a transaction batches commit on their
+// own: a cancelled or failed run keeps what it already deleted, and running
+// it again finishes the job.
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultDeleteBatchSize is the number of rows deleted per statement when
+// UPLOAD_DELETE_BATCH_SIZE is not set.
+const DefaultDeleteBatchSize = 10000
+
+// deleteBatchSize returns the configured rows per delete statement.
+func (s *Service) deleteBatchSize() int {
+	if n := s.cfg.Upload.DeleteBatchSize; n > 0 {
+		return n
+	}
+	return DefaultDeleteBatchSize
+}
+
+// batchDeleteSQL returns a statement deleting up to limit rows of table
+// matching where. Rows are picked by id, which every table has.
+func batchDeleteSQL(table, where string, limit int) string {
+	t := quoteIdentifier(table)
+	return fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s LIMIT %d)", t, t, where, limit)
+}
+
+// deleteInBatches deletes the rows of table matching where through db,
+// reporting them as step of op, and returns how many it deleted. It stops
+// with ctx's error between batches once ctx is done.
+func (s *Service) deleteInBatches(ctx context.Context, db DBTX, op *Operation, step, table, where string, args ...any) (int64, error) {
+	countCtx, cancel := s.withOpTimeout(ctx, opAggregate)
+	var total int64
+	err := db.QueryRow(countCtx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdentifier(table), where), args...).Scan(&total)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("count rows: %w", err)
+	}
+	op.Advance(step, 0, total)
+
+	limit := s.deleteBatchSize()
+	query := batchDeleteSQL(table, where, limit)
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		batchCtx, cancel := s.withOpTimeout(ctx, opMutation)
+		tag, err := db.Exec(batchCtx, query, args...)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return deleted, ctx.Err()
+			}
+			return deleted, fmt.Errorf("delete batch after %d rows: %w", deleted, err)
+		}
+		deleted += tag.RowsAffected()
+		// Rows inserted meanwhile can push past the initial count
+		op.Advance(step, deleted, max(total, deleted))
+		op.SetDetail(step, fmt.Sprintf("%d rows deleted", deleted))
+		if tag.RowsAffected() < int64(limit) {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestBatchDeleteSQL(t *testing.T) {
+	got := batchDeleteSQL("ns_customers", "upload_id = $1", 500)
+	want := `DELETE FROM "ns_customers" WHERE id IN (SELECT id FROM "ns_customers" WHERE upload_id = $1 LIMIT 500)`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDeleteBatchSize(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	if got := s.deleteBatchSize(); got != DefaultDeleteBatchSize {
+		t.Errorf("unset = %d, want %d", got, DefaultDeleteBatchSize)
+	}
+	s.cfg.Upload.DeleteBatchSize = 250
+	if got := s.deleteBatchSize(); got != 250 {
+		t.Errorf("configured = %d, want 250", got)
+	}
+}
+
+func TestResetSteps(t *testing.T) {
+	steps := resetSteps(
+		TableDefinition{Info: TableInfo{Key: "a"}},
+		TableDefinition{Info: TableInfo{Key: "b"}},
+	)
+	if len(steps) != 2 || steps[0].Name != "a" || steps[1].Name != "b" {
+		t.Errorf("steps = %+v", steps)
+	}
+}
@@ -0,0 +1,339 @@
+package core
+
+// bootstrap.go applies a declarative environment file so deployments are
+// reproducible: the file states what should exist, and ApplyBootstrap
+// computes the difference from the running state and applies it.
+//
+// Applying is idempotent - a second run against the same state reports every
+// item as unchanged. Nothing that is absent from the file is deleted.
+//
+// Supported sections:
+//   - tables:    per-table enum value and upload limit overrides (tables
+//                themselves are registered in code or declared in
+//                TABLE_CONFIG_FILES, not created from this file)
+//   - templates: import templates, matched by (tableKey, name)
+//
+// Applied enum and limit changes are audited as table_config, and templates
+// as template_create and template_update.
+//
+// Sections for features this server does not have (freezeWindows, roles)
+// are accepted and reported as skipped rather than silently dropped. So is
+// webhooks: webhook URLs and the signing secret are credentials, set in
+// WEBHOOK_URLS and WEBHOOK_SECRET from the environment or a secret manager
+// (see internal/config), not in a file that is meant to be checked in.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// BootstrapSpec is the declarative bootstrap file format (JSON).
+type BootstrapSpec struct {
+	Tables    []BootstrapTable    `json:"tables,omitempty"`
+	Templates []BootstrapTemplate `json:"templates,omitempty"`
+
+	// Not applied; reported as skipped when present. Webhooks are
+	// configured with WEBHOOK_URLS and WEBHOOK_SECRET instead.
+	FreezeWindows json.RawMessage `json:"freezeWindows,omitempty"`
+	Webhooks      json.RawMessage `json:"webhooks,omitempty"`
+	Roles         json.RawMessage `json:"roles,omitempty"`
+}
+
+// BootstrapTable declares settings for a registered table.
+type BootstrapTable struct {
+	Key        string              `json:"key"`
+	EnumValues map[string][]string `json:"enumValues,omitempty"` // Column name -> allowed values
+	Limits     *UploadLimits       `json:"limits,omitempty"`     // Replaces all limits when set
+}
+
+// BootstrapTemplate declares an import template.
+type BootstrapTemplate struct {
+	TableKey      string         `json:"tableKey"`
+	Name          string         `json:"name"`
+	ColumnMapping map[string]int `json:"columnMapping"`
+	CSVHeaders    []string       `json:"csvHeaders"`
+}
+
+// Bootstrap change actions.
+const (
+	BootstrapCreate    = "create"
+	BootstrapUpdate    = "update"
+	BootstrapUnchanged = "unchanged"
+	BootstrapSkipped   = "skipped"
+	BootstrapError     = "error"
+)
+
+// BootstrapChange is one line of the bootstrap diff.
+type BootstrapChange struct {
+	Kind   string `json:"kind"`   // "enum", "limits", "template", or a skipped section name
+	Target string `json:"target"` // e.g. "sfdc_customers.Status" or "sfdc_customers/Default"
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// BootstrapResult reports the diff and what was applied.
+type BootstrapResult struct {
+	DryRun  bool              `json:"dryRun"`
+	Changes []BootstrapChange `json:"changes"`
+	Applied int               `json:"applied"`
+	Errors  int               `json:"errors"`
+}
+
+// ParseBootstrapSpec decodes a bootstrap file. Unknown fields are rejected
+// so typos fail loudly instead of being ignored.
+func ParseBootstrapSpec(data []byte) (*BootstrapSpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var spec BootstrapSpec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("parse bootstrap file: %w", err)
+	}
+	return &spec, nil
+}
+
+// LoadBootstrapFile reads and parses a bootstrap file from disk.
+func LoadBootstrapFile(path string) (*BootstrapSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read bootstrap file: %w", err)
+	}
+	return ParseBootstrapSpec(data)
+}
+
+// ApplyBootstrap diffs spec against the current state and, unless dryRun,
+// applies the differences. Individual item failures are reported in the
+// result; the returned error is reserved for failures that stop the run.
+func (s *Service) ApplyBootstrap(ctx context.Context, spec *BootstrapSpec, dryRun bool) (*BootstrapResult, error) {
+	result := &BootstrapResult{
+		DryRun:  dryRun,
+		Changes: []BootstrapChange{},
+	}
+
+	record := func(c BootstrapChange) {
+		if c.Action == BootstrapError {
+			result.Errors++
+		} else if !dryRun && (c.Action == BootstrapCreate || c.Action == BootstrapUpdate) {
+			result.Applied++
+		}
+		result.Changes = append(result.Changes, c)
+	}
+
+	for _, t := range spec.Tables {
+		for _, c := range s.applyBootstrapTable(ctx, t, dryRun) {
+			record(c)
+		}
+	}
+
+	for _, t := range spec.Templates {
+		c, err := s.applyBootstrapTemplate(ctx, t, dryRun)
+		if err != nil {
+			return result, err
+		}
+		record(c)
+	}
+
+	for _, section := range []struct {
+		name string
+		raw  json.RawMessage
+	}{
+		{"freezeWindows", spec.FreezeWindows},
+		{"webhooks", spec.Webhooks},
+		{"roles", spec.Roles},
+	} {
+		if len(section.raw) > 0 && string(section.raw) != "null" {
+			detail := "not supported by this server"
+			if section.name == "webhooks" {
+				detail = "set WEBHOOK_URLS and WEBHOOK_SECRET instead"
+			}
+			record(BootstrapChange{
+				Kind:   section.name,
+				Target: section.name,
+				Action: BootstrapSkipped,
+				Detail: detail,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// applyBootstrapTable diffs and applies enum and limit overrides for one table.
+func (s *Service) applyBootstrapTable(ctx context.Context, t BootstrapTable, dryRun bool) []BootstrapChange {
+	def, ok := Get(t.Key)
+	if !ok {
+		return []BootstrapChange{{
+			Kind:   "table",
+			Target: t.Key,
+			Action: BootstrapError,
+			Detail: "unknown table (tables are registered in code or TABLE_CONFIG_FILES)",
+		}}
+	}
+
+	// Sort columns for a stable diff
+	columns := make([]string, 0, len(t.EnumValues))
+	for col := range t.EnumValues {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	changes := make([]BootstrapChange, 0, len(columns))
+	for _, col := range columns {
+		want := t.EnumValues[col]
+		change := BootstrapChange{Kind: "enum", Target: t.Key + "." + col}
+
+		var current []string
+		found := false
+		for _, spec := range def.FieldSpecs {
+			if spec.Name == col {
+				current, found = spec.EnumValues, true
+				break
+			}
+		}
+
+		switch {
+		case !found:
+			change.Action = BootstrapError
+			change.Detail = "unknown column"
+		case equalStringSlices(current, want):
+			change.Action = BootstrapUnchanged
+		default:
+			change.Action = BootstrapUpdate
+			change.Detail = fmt.Sprintf("%v -> %v", current, want)
+			if !dryRun {
+				if err := SetEnumValues(t.Key, col, want); err != nil {
+					change.Action = BootstrapError
+					change.Detail = err.Error()
+				} else {
+					s.logTableConfig(ctx, t.Key, col, fmt.Sprint(current), fmt.Sprint(want))
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	if t.Limits != nil {
+		change := BootstrapChange{Kind: "limits", Target: t.Key}
+		if *t.Limits == def.Limits {
+			change.Action = BootstrapUnchanged
+		} else {
+			change.Action = BootstrapUpdate
+			change.Detail = fmt.Sprintf("%+v -> %+v", def.Limits, *t.Limits)
+			if !dryRun {
+				if err := SetUploadLimits(t.Key, *t.Limits); err != nil {
+					change.Action = BootstrapError
+					change.Detail = err.Error()
+				} else {
+					s.logTableConfig(ctx, t.Key, "", fmt.Sprintf("%+v", def.Limits), fmt.Sprintf("%+v", *t.Limits))
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// logTableConfig audits a bootstrap change of tableKey's configuration. The
+// change is applied already, so a failed entry is logged.
+func (s *Service) logTableConfig(ctx context.Context, tableKey, column, old, new string) {
+	if s.pool == nil {
+		return
+	}
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), tableConfigAuditParams(ctx, tableKey, column, old, new)); err != nil {
+		slog.Error("failed to log table config audit", "table", tableKey, "column", column, "error", err)
+	}
+}
+
+// tableConfigAuditParams returns the audit entry of a bootstrap change from
+// old to new of column's enum values, or of the upload limits if column is
+// "".
+func tableConfigAuditParams(ctx context.Context, tableKey, column, old, new string) AuditLogParams {
+	reason := fmt.Sprintf("Bootstrap set the enum values of %s.%s", tableKey, column)
+	if column == "" {
+		reason = fmt.Sprintf("Bootstrap set the upload limits of %s", tableKey)
+	}
+	return AuditLogParams{
+		Action:     ActionTableConfig,
+		TableKey:   tableKey,
+		ColumnName: column,
+		OldValue:   old,
+		NewValue:   new,
+		IPAddress:  GetIPAddressFromContext(ctx),
+		UserAgent:  GetUserAgentFromContext(ctx),
+		Reason:     reason,
+	}
+}
+
+// applyBootstrapTemplate diffs and applies one import template.
+func (s *Service) applyBootstrapTemplate(ctx context.Context, t BootstrapTemplate, dryRun bool) (BootstrapChange, error) {
+	change := BootstrapChange{Kind: "template", Target: t.TableKey + "/" + t.Name}
+
+	if _, ok := Get(t.TableKey); !ok {
+		change.Action = BootstrapError
+		change.Detail = "unknown table"
+		return change, nil
+	}
+	if t.Name == "" || len(t.ColumnMapping) == 0 {
+		change.Action = BootstrapError
+		change.Detail = "name and columnMapping are required"
+		return change, nil
+	}
+
+	existing, err := s.ListTemplates(ctx, t.TableKey)
+	if err != nil {
+		return change, fmt.Errorf("list templates for %s: %w", t.TableKey, err)
+	}
+
+	var current *ImportTemplate
+	for i := range existing {
+		if existing[i].Name == t.Name {
+			current = &existing[i]
+			break
+		}
+	}
+
+	switch {
+	case current == nil:
+		change.Action = BootstrapCreate
+		if !dryRun {
+			if _, err := s.CreateTemplate(ctx, t.TableKey, t.Name, t.ColumnMapping, t.CSVHeaders, nil); err != nil {
+				change.Action = BootstrapError
+				change.Detail = err.Error()
+			}
+		}
+	case reflect.DeepEqual(current.ColumnMapping, t.ColumnMapping) && equalStringSlices(current.CSVHeaders, t.CSVHeaders):
+		change.Action = BootstrapUnchanged
+	default:
+		change.Action = BootstrapUpdate
+		change.Detail = "column mapping or headers differ"
+		if !dryRun {
+			if _, err := s.UpdateTemplate(ctx, current.ID, t.Name, t.ColumnMapping, t.CSVHeaders, current.Transforms); err != nil {
+				change.Action = BootstrapError
+				change.Detail = err.Error()
+			}
+		}
+	}
+
+	return change, nil
+}
+
+// equalStringSlices reports whether a and b hold the same values in order.
+// nil and empty are equal.
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
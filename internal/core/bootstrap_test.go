@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseBootstrapSpec_RejectsUnknownFields(t *testing.T) {
+	if _, err := ParseBootstrapSpec([]byte(`{"tabels": []}`)); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := ParseBootstrapSpec([]byte(`{"tables": [{"key": "x"}]}`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestApplyBootstrap_EnumDiffIsIdempotent(t *testing.T) {
+	Register(TableDefinition{
+		Info: TableInfo{Key: "bootstrap_test", Group: "Test", Label: "Bootstrap Test"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Status", Type: FieldEnum, EnumValues: []string{"active"}},
+			{Name: "Name", Type: FieldText},
+		},
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "bootstrap_test")
+		registryMu.Unlock()
+	}()
+
+	spec := &BootstrapSpec{
+		Tables: []BootstrapTable{{
+			Key: "bootstrap_test",
+			EnumValues: map[string][]string{
+				"Status":  {"active", "closed"},
+				"Missing": {"x"},
+			},
+			Limits: &UploadLimits{MaxRows: 100},
+		}},
+		Roles: []byte(`[{"name": "admin"}]`),
+	}
+	s := &Service{}
+
+	// Dry run reports the diff without applying it
+	result, err := s.ApplyBootstrap(context.Background(), spec, true)
+	if err != nil {
+		t.Fatalf("ApplyBootstrap: %v", err)
+	}
+	want := map[string]string{
+		"bootstrap_test.Missing": BootstrapError,
+		"bootstrap_test.Status":  BootstrapUpdate,
+		"bootstrap_test":         BootstrapUpdate,
+		"roles":                  BootstrapSkipped,
+	}
+	if len(result.Changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), result.Changes)
+	}
+	for _, c := range result.Changes {
+		if want[c.Target] != c.Action {
+			t.Errorf("%s: action = %q, want %q", c.Target, c.Action, want[c.Target])
+		}
+	}
+	if result.Applied != 0 || result.Errors != 1 {
+		t.Errorf("dry run: applied=%d errors=%d, want 0 and 1", result.Applied, result.Errors)
+	}
+	if def, _ := Get("bootstrap_test"); len(def.FieldSpecs[0].EnumValues) != 1 {
+		t.Errorf("dry run modified enum values: %v", def.FieldSpecs[0].EnumValues)
+	}
+
+	// Apply, then a second run is a no-op
+	if result, err = s.ApplyBootstrap(context.Background(), spec, false); err != nil {
+		t.Fatalf("ApplyBootstrap: %v", err)
+	}
+	if result.Applied != 2 {
+		t.Errorf("expected 2 applied changes, got %d", result.Applied)
+	}
+	if def, _ := Get("bootstrap_test"); rowLimitError(def, 101) == "" || rowLimitError(def, 100) != "" {
+		t.Errorf("row limit not applied: %+v", def.Limits)
+	}
+	result, err = s.ApplyBootstrap(context.Background(), spec, false)
+	if err != nil {
+		t.Fatalf("ApplyBootstrap: %v", err)
+	}
+	for _, c := range result.Changes {
+		if c.Action == BootstrapUpdate {
+			t.Errorf("second run: %s action = %q, want unchanged", c.Target, c.Action)
+		}
+	}
+}
+
+func TestTableConfigAuditParams(t *testing.T) {
+	p := tableConfigAuditParams(context.Background(), "vendor_bills", "Status", "[active]", "[active closed]")
+	if p.Action != ActionTableConfig || p.ColumnName != "Status" || p.OldValue != "[active]" || p.NewValue != "[active closed]" {
+		t.Errorf("params = %+v", p)
+	}
+	if want := "Bootstrap set the enum values of vendor_bills.Status"; p.Reason != want {
+		t.Errorf("reason = %q, want %q", p.Reason, want)
+	}
+	p = tableConfigAuditParams(context.Background(), "vendor_bills", "", "{MaxRows:0}", "{MaxRows:100}")
+	if want := "Bootstrap set the upload limits of vendor_bills"; p.Reason != want {
+		t.Errorf("reason = %q, want %q", p.Reason, want)
+	}
+}
@@ -0,0 +1,589 @@
+package core
+
+// bulk_edit.go holds the ways a bulk edit can change a column: set one
+// value everywhere, or work out each row's new value from its old one.
+//
+//	set      Value in every row
+//	replace  Find replaced with Value in each value
+//	adjust   Arithmetic on numbers: Value is +10, -2.5, *1.05, /4, +10% or -3%
+//	trim     Leading and trailing spaces removed
+//	upper    Upper case
+//	lower    Lower case
+//
+// A bulk edit may change several columns, each in its own way. However
+// many rows are selected, a bulk edit reads their values with one query
+// and writes them with one UPDATE, joining the table to arrays of keys and
+// new values, and records each changed cell's history with one COPY, all
+// in one transaction.
+//
+// PreviewBulkEdit works out the new values without writing them, so they
+// can be checked before BulkEditRows commits them.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInvalidBulkEdit is returned for a bulk edit that can't be applied.
+var ErrInvalidBulkEdit = errors.New("invalid bulk edit")
+
+// BulkEditMode is how a bulk edit changes the column.
+type BulkEditMode string
+
+const (
+	BulkEditSet     BulkEditMode = "set"
+	BulkEditReplace BulkEditMode = "replace"
+	BulkEditAdjust  BulkEditMode = "adjust"
+	BulkEditTrim    BulkEditMode = "trim"
+	BulkEditUpper   BulkEditMode = "upper"
+	BulkEditLower   BulkEditMode = "lower"
+)
+
+// BulkColumnEdit is how a bulk edit changes one column.
+type BulkColumnEdit struct {
+	Column string
+	Mode   BulkEditMode // Set if empty
+	Find   string       // Text to replace, for BulkEditReplace
+	Value  string       // New value, replacement text or adjustment
+}
+
+// BulkEditChange is one cell's value before and after a bulk edit.
+type BulkEditChange struct {
+	RowKey   string `json:"rowKey"`
+	Column   string `json:"column,omitempty"` // Empty if the whole row fails
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+	Error    string `json:"error,omitempty"` // Why the row can't be changed
+}
+
+// BulkEditPreview is what a bulk edit would do.
+type BulkEditPreview struct {
+	Changes   []BulkEditChange `json:"changes"`   // Cells that would change, or why rows fail
+	Unchanged int              `json:"unchanged"` // Rows left as they are
+	Failed    int              `json:"failed"`    // Rows that can't be changed
+}
+
+// bulkEditFunc works out a row's new value from its old one.
+type bulkEditFunc func(old string) (string, error)
+
+// bulkEditTransform returns the function computing new values for req
+// on a column of spec, or ErrInvalidBulkEdit if req doesn't fit it.
+func bulkEditTransform(req BulkColumnEdit, spec FieldSpec) (bulkEditFunc, error) {
+	textual := spec.Type == FieldText || spec.Type == FieldEnum
+	switch req.Mode {
+	case "", BulkEditSet:
+		if err := validateCellValue(req.Value, spec); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+		return func(string) (string, error) { return req.Value, nil }, nil
+	case BulkEditReplace:
+		if req.Find == "" {
+			return nil, fmt.Errorf("%w: find is required", ErrInvalidBulkEdit)
+		}
+		return func(old string) (string, error) {
+			return strings.ReplaceAll(old, req.Find, req.Value), nil
+		}, nil
+	case BulkEditAdjust:
+		if spec.Type != FieldNumeric {
+			return nil, fmt.Errorf("%w: %s is not numeric", ErrInvalidBulkEdit, spec.Name)
+		}
+		return parseAdjustment(req.Value)
+	case BulkEditTrim, BulkEditUpper, BulkEditLower:
+		if !textual {
+			return nil, fmt.Errorf("%w: %s applies to text columns; %s is not one", ErrInvalidBulkEdit, req.Mode, spec.Name)
+		}
+		fn := map[BulkEditMode]func(string) string{
+			BulkEditTrim:  strings.TrimSpace,
+			BulkEditUpper: strings.ToUpper,
+			BulkEditLower: strings.ToLower,
+		}[req.Mode]
+		return func(old string) (string, error) { return fn(old), nil }, nil
+	}
+	return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidBulkEdit, req.Mode)
+}
+
+// parseAdjustment parses an arithmetic adjustment such as +10, *1.05 or
+// -5%. Empty values stay empty. Results keep the old value's decimal
+// places, or the operand's for + and - if it has more, rounded half away
+// from zero.
+func parseAdjustment(expr string) (bulkEditFunc, error) {
+	expr = strings.TrimSpace(expr)
+	if len(expr) < 2 || !strings.ContainsRune("+-*/", rune(expr[0])) {
+		return nil, fmt.Errorf("%w: adjustment must be +n, -n, *n, /n or ±n%%, not %q", ErrInvalidBulkEdit, expr)
+	}
+	op, operand := expr[0], strings.TrimSpace(expr[1:])
+	percent := strings.HasSuffix(operand, "%")
+	if percent {
+		if op != '+' && op != '-' {
+			return nil, fmt.Errorf("%w: percentages can only be added or subtracted", ErrInvalidBulkEdit)
+		}
+		operand = strings.TrimSpace(strings.TrimSuffix(operand, "%"))
+	}
+	n, ok := new(big.Rat).SetString(operand)
+	if !ok || strings.ContainsAny(operand, "/eE") {
+		return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidBulkEdit, operand)
+	}
+	if op == '/' && n.Sign() == 0 {
+		return nil, fmt.Errorf("%w: division by zero", ErrInvalidBulkEdit)
+	}
+	operandScale := decimalPlaces(operand)
+
+	return func(old string) (string, error) {
+		if old == "" {
+			return "", nil
+		}
+		v, ok := new(big.Rat).SetString(old)
+		if !ok {
+			return "", fmt.Errorf("%q is not a number", old)
+		}
+		scale := decimalPlaces(old)
+		switch {
+		case percent:
+			factor := new(big.Rat).Quo(n, big.NewRat(100, 1))
+			if op == '-' {
+				factor.Neg(factor)
+			}
+			v.Mul(v, factor.Add(factor, big.NewRat(1, 1)))
+		case op == '+', op == '-':
+			if op == '+' {
+				v.Add(v, n)
+			} else {
+				v.Sub(v, n)
+			}
+			if operandScale > scale {
+				scale = operandScale
+			}
+		case op == '*':
+			v.Mul(v, n)
+		case op == '/':
+			v.Quo(v, n)
+		}
+		return v.FloatString(scale), nil
+	}, nil
+}
+
+// decimalPlaces returns the digits after the decimal point of a number.
+func decimalPlaces(s string) int {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// bulkEditColumn returns the column a bulk edit of def changes and its
+// database name, refusing unique key columns.
+func bulkEditColumn(def TableDefinition, column string) (*FieldSpec, string, error) {
+	var spec *FieldSpec
+	for i := range def.FieldSpecs {
+		if strings.EqualFold(def.FieldSpecs[i].Name, column) {
+			spec = &def.FieldSpecs[i]
+			break
+		}
+	}
+	if spec == nil {
+		return nil, "", fmt.Errorf("column not found: %s", column)
+	}
+
+	// Block editing unique key columns (would cause duplicates)
+	if isKeyColumn(def.Info.UniqueKey, column) {
+		return nil, "", fmt.Errorf("cannot bulk edit unique key column: %s", column)
+	}
+
+	dbCol := toDBColumnName(column)
+	if spec.DBColumn != "" {
+		dbCol = spec.DBColumn
+	}
+	return spec, dbCol, nil
+}
+
+// bulkEditTarget is a column a bulk edit changes.
+type bulkEditTarget struct {
+	spec      *FieldSpec
+	dbCol     string
+	transform bulkEditFunc
+}
+
+// bulkEditTargets checks each of req's edits against def.
+func bulkEditTargets(def TableDefinition, req BulkEditRequest) ([]bulkEditTarget, error) {
+	if len(req.Edits) == 0 {
+		return nil, fmt.Errorf("%w: no columns specified", ErrInvalidBulkEdit)
+	}
+	targets := make([]bulkEditTarget, len(req.Edits))
+	seen := make(map[string]bool, len(req.Edits))
+	for i, edit := range req.Edits {
+		spec, dbCol, err := bulkEditColumn(def, edit.Column)
+		if err != nil {
+			return nil, err
+		}
+		if seen[dbCol] {
+			return nil, fmt.Errorf("%w: %s is edited more than once", ErrInvalidBulkEdit, spec.Name)
+		}
+		seen[dbCol] = true
+		transform, err := bulkEditTransform(edit, *spec)
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = bulkEditTarget{spec: spec, dbCol: dbCol, transform: transform}
+	}
+	return targets, nil
+}
+
+// bulkEditRow is one row of a bulk edit.
+type bulkEditRow struct {
+	key     []string         // Canonical key parts; nil if the key is invalid
+	changes []BulkEditChange // One per target, or one with the row's error
+}
+
+// planBulkEdit reads the rows' current values, in one query, and works out
+// their new ones. Repeated keys are planned once. types are the table's
+// column types (tableColumnTypes). In a transaction, forUpdate locks the
+// rows until it ends.
+func (s *Service) planBulkEdit(ctx context.Context, q DBTX, def TableDefinition, types map[string]string, keys []string, targets []bulkEditTarget, forUpdate bool) ([]bulkEditRow, error) {
+	specs := keySpecs(def)
+	rows := make([]bulkEditRow, 0, len(keys))
+	keyArrays := make([][]string, len(specs))
+	var queried []int // Index in rows of each key in keyArrays
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		parts := strings.Split(key, "|")
+		if len(parts) != len(specs) {
+			rows = append(rows, bulkEditRow{changes: []BulkEditChange{{RowKey: key, Error: "invalid key format"}}})
+			continue
+		}
+		row := bulkEditRow{key: make([]string, len(parts)), changes: []BulkEditChange{{RowKey: key}}}
+		ok := true
+		for i, part := range parts {
+			if row.key[i], ok = canonicalKeyPart(specs[i], part); !ok {
+				break
+			}
+		}
+		if !ok {
+			// An empty or unparseable key part matches no row
+			row.changes[0].Error = "row not found"
+			rows = append(rows, row)
+			continue
+		}
+		for i, part := range row.key {
+			keyArrays[i] = append(keyArrays[i], part)
+		}
+		queried = append(queried, len(rows))
+		rows = append(rows, row)
+	}
+	if len(queried) == 0 {
+		return rows, nil
+	}
+
+	old, err := selectBulkEditValues(ctx, q, def, types, keyArrays, targets, forUpdate)
+	if err != nil {
+		return nil, err
+	}
+	for n, idx := range queried {
+		row := &rows[idx]
+		key := row.changes[0].RowKey
+		values, ok := old[int64(n+1)]
+		if !ok {
+			row.changes[0].Error = "row not found"
+			continue
+		}
+		row.changes = make([]BulkEditChange, len(targets))
+		for i, t := range targets {
+			c := BulkEditChange{RowKey: key, Column: t.spec.Name, OldValue: values[i]}
+			if c.NewValue, err = t.transform(values[i]); err != nil {
+				c.Error = err.Error()
+			} else if err := validateCellValue(c.NewValue, *t.spec); err != nil {
+				c.Error = err.Error()
+			}
+			row.changes[i] = c
+		}
+	}
+	return rows, nil
+}
+
+// bulkEditRowError returns why a row of planBulkEdit can't be changed, or
+// "" if it can.
+func bulkEditRowError(changes []BulkEditChange) string {
+	var errs []string
+	for _, c := range changes {
+		switch {
+		case c.Error == "":
+		case c.Column == "":
+			return c.Error
+		default:
+			errs = append(errs, c.Column+": "+c.Error)
+		}
+	}
+	return strings.Join(errs, "; ")
+}
+
+// bulkEditKeySource returns the unnest arrays ($1, $2, ...) and names of
+// the key columns of a bulk edit statement, and the conditions matching
+// table alias t to them.
+func bulkEditKeySource(def TableDefinition, types map[string]string) (arrays, names, conds []string) {
+	specs := keySpecs(def)
+	for i, col := range resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs) {
+		arrays = append(arrays, fmt.Sprintf("$%d::text[]", i+1))
+		names = append(names, fmt.Sprintf("k%d", i+1))
+		conds = append(conds, fmt.Sprintf("t.%s = k.k%d::%s", quoteIdentifier(col), i+1, columnCast(types, col, specs[i].Type)))
+	}
+	if live := liveRowsCondition(def, "t"); live != "" {
+		conds = append(conds, live)
+	}
+	return arrays, names, conds
+}
+
+// selectBulkEditValues returns the targets' current values in the rows
+// with the keys in keyArrays (one array per key column), by the key's
+// ordinal from 1. Postgres's text form is what an edit would accept, as
+// in getCellValue. Where several rows share a key, the first found is
+// used.
+func selectBulkEditValues(ctx context.Context, q DBTX, def TableDefinition, types map[string]string, keyArrays [][]string, targets []bulkEditTarget, forUpdate bool) (map[int64][]string, error) {
+	arrays, names, conds := bulkEditKeySource(def, types)
+	cols := make([]string, len(targets))
+	for i, t := range targets {
+		cols[i] = "t." + quoteIdentifier(t.dbCol) + "::text"
+	}
+	query := fmt.Sprintf(`SELECT k.i, %s
+FROM unnest(%s) WITH ORDINALITY AS k(%s, i)
+JOIN %s AS t ON %s`,
+		strings.Join(cols, ", "), strings.Join(arrays, ", "), strings.Join(names, ", "),
+		quoteIdentifier(def.Info.Key), strings.Join(conds, " AND "))
+	if forUpdate {
+		query += "\nFOR UPDATE OF t"
+	}
+
+	args := make([]any, len(keyArrays))
+	for i, a := range keyArrays {
+		args[i] = a
+	}
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read current values: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[int64][]string)
+	for rows.Next() {
+		var ord int64
+		texts := make([]pgtype.Text, len(targets))
+		dest := make([]any, 0, len(targets)+1)
+		dest = append(dest, &ord)
+		for i := range texts {
+			dest = append(dest, &texts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if _, ok := values[ord]; ok {
+			continue
+		}
+		row := make([]string, len(texts))
+		for i, t := range texts {
+			row[i] = t.String
+		}
+		values[ord] = row
+	}
+	return values, rows.Err()
+}
+
+// bulkEditUpdate collects the new values of a bulk edit, written to every
+// row with one UPDATE.
+type bulkEditUpdate struct {
+	def     TableDefinition
+	types   map[string]string
+	targets []bulkEditTarget
+	keys    [][]string      // One array per key column
+	values  [][]pgtype.Text // One array per target
+	changed [][]bool        // One array per target: whether the row's value changes
+}
+
+func newBulkEditUpdate(def TableDefinition, types map[string]string, targets []bulkEditTarget) *bulkEditUpdate {
+	return &bulkEditUpdate{
+		def:     def,
+		types:   types,
+		targets: targets,
+		keys:    make([][]string, len(def.Info.UniqueKey)),
+		values:  make([][]pgtype.Text, len(targets)),
+		changed: make([][]bool, len(targets)),
+	}
+}
+
+// add queues a planned row's changed values, reporting whether it has any.
+func (u *bulkEditUpdate) add(row bulkEditRow) bool {
+	changed := false
+	for _, c := range row.changes {
+		changed = changed || c.NewValue != c.OldValue
+	}
+	if !changed {
+		return false
+	}
+	for i, part := range row.key {
+		u.keys[i] = append(u.keys[i], part)
+	}
+	for i, c := range row.changes {
+		u.values[i] = append(u.values[i], cellDBText(c.NewValue, u.targets[i].spec))
+		u.changed[i] = append(u.changed[i], c.NewValue != c.OldValue)
+	}
+	return true
+}
+
+// exec writes the queued rows. Each column is set only in the rows where
+// it changes.
+func (u *bulkEditUpdate) exec(ctx context.Context, q DBTX) error {
+	if len(u.keys) == 0 || len(u.keys[0]) == 0 {
+		return nil
+	}
+	arrays, names, conds := bulkEditKeySource(u.def, u.types)
+	args := make([]any, 0, len(u.keys)+2*len(u.targets))
+	for _, a := range u.keys {
+		args = append(args, a)
+	}
+	sets := make([]string, len(u.targets))
+	for i, t := range u.targets {
+		args = append(args, u.values[i], u.changed[i])
+		arrays = append(arrays, fmt.Sprintf("$%d::text[]", len(args)-1), fmt.Sprintf("$%d::boolean[]", len(args)))
+		names = append(names, fmt.Sprintf("v%d", i+1), fmt.Sprintf("c%d", i+1))
+		col := quoteIdentifier(t.dbCol)
+		sets[i] = fmt.Sprintf("%s = CASE WHEN k.c%d THEN k.v%d::%s ELSE t.%s END", col, i+1, i+1, columnCast(u.types, t.dbCol, t.spec.Type), col)
+	}
+
+	query := fmt.Sprintf(`UPDATE %s AS t
+SET %s
+FROM unnest(%s) AS k(%s)
+WHERE %s`,
+		quoteIdentifier(u.def.Info.Key), strings.Join(sets, ",\n    "),
+		strings.Join(arrays, ", "), strings.Join(names, ", "), strings.Join(conds, " AND "))
+	_, err := q.Exec(ctx, query, args...)
+	return err
+}
+
+// columnCast returns the type a text value for dbCol is cast to: the
+// column's own, or else its field type's.
+func columnCast(types map[string]string, dbCol string, t FieldType) string {
+	if typ, ok := types[dbCol]; ok {
+		return typ
+	}
+	return fieldSQLType(t)
+}
+
+// cellDBText returns a new cell value as the text of the database value
+// cellDBValue makes of it, or NULL.
+func cellDBText(value string, spec *FieldSpec) pgtype.Text {
+	switch v := cellDBValue(value, spec).(type) {
+	case pgtype.Numeric:
+		if s, err := v.Value(); err == nil && s != nil {
+			return pgtype.Text{String: s.(string), Valid: true}
+		}
+	case pgtype.Date:
+		if v.Valid {
+			return pgtype.Text{String: v.Time.Format("2006-01-02"), Valid: true}
+		}
+	case pgtype.Bool:
+		if v.Valid {
+			return pgtype.Text{String: strconv.FormatBool(v.Bool), Valid: true}
+		}
+	case pgtype.Text:
+		return v
+	}
+	return pgtype.Text{}
+}
+
+// PreviewBulkEdit returns the values a bulk edit would write, without
+// writing them.
+func (s *Service) PreviewBulkEdit(ctx context.Context, tableKey string, req BulkEditRequest) (*BulkEditPreview, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if len(def.Info.UniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
+	}
+	if len(req.Keys) == 0 {
+		return nil, fmt.Errorf("no rows specified")
+	}
+	targets, err := bulkEditTargets(def, req)
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := s.tableColumnTypes(ctx, tableKey)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.planBulkEdit(ctx, s.pool, def, types, req.Keys, targets, false)
+	if err != nil {
+		return nil, err
+	}
+	preview := &BulkEditPreview{Changes: make([]BulkEditChange, 0)}
+	for _, row := range rows {
+		changes := row.changes
+		if bulkEditRowError(changes) != "" {
+			preview.Failed++
+			for _, c := range changes {
+				if c.Error != "" {
+					preview.Changes = append(preview.Changes, c)
+				}
+			}
+			continue
+		}
+		changed := false
+		for _, c := range changes {
+			if c.NewValue != c.OldValue {
+				preview.Changes = append(preview.Changes, c)
+				changed = true
+			}
+		}
+		if !changed {
+			preview.Unchanged++
+		}
+	}
+	return preview, nil
+}
+
+// describe summarizes the edit for the audit log.
+func (req BulkColumnEdit) describe() string {
+	switch req.Mode {
+	case BulkEditReplace:
+		return fmt.Sprintf("replace %q with %q", req.Find, req.Value)
+	case BulkEditAdjust:
+		return "adjust " + strings.TrimSpace(req.Value)
+	case BulkEditTrim, BulkEditUpper, BulkEditLower:
+		return string(req.Mode)
+	}
+	return req.Value
+}
+
+// describe summarizes the edits for the audit log, one column per line
+// when there are several.
+func (req BulkEditRequest) describe() string {
+	if len(req.Edits) == 1 {
+		return req.Edits[0].describe()
+	}
+	lines := make([]string, len(req.Edits))
+	for i, edit := range req.Edits {
+		lines[i] = edit.Column + ": " + edit.describe()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// columns returns the names of the columns req edits.
+func (req BulkEditRequest) columns() []string {
+	names := make([]string, len(req.Edits))
+	for i, edit := range req.Edits {
+		names[i] = edit.Column
+	}
+	return names
+}
@@ -0,0 +1,298 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseAdjustment(t *testing.T) {
+	tests := []struct {
+		expr, old, want string
+	}{
+		{"+10%", "1200.50", "1320.55"},
+		{"-10%", "100", "90"},
+		{"*1.05", "100.01", "105.01"}, // 105.0105 rounded to 2 places
+		{"*1.05", "0.10", "0.11"},     // 0.105 rounds half away from zero
+		{"/3", "10.00", "3.33"},
+		{"+25", "-5.5", "19.5"},
+		{"-0.005", "1.00", "0.995"}, // The operand's places when it has more
+		{"+ 2", "3", "5"},
+		{"*2", "", ""}, // Empty stays empty
+	}
+	for _, tt := range tests {
+		fn, err := parseAdjustment(tt.expr)
+		if err != nil {
+			t.Errorf("parseAdjustment(%q): %v", tt.expr, err)
+			continue
+		}
+		got, err := fn(tt.old)
+		if err != nil {
+			t.Errorf("%q on %q: %v", tt.expr, tt.old, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q on %q = %q, want %q", tt.expr, tt.old, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "10", "+", "%10", "*10%", "/0", "+abc", "*1/2", "+1e3"} {
+		if _, err := parseAdjustment(expr); !errors.Is(err, ErrInvalidBulkEdit) {
+			t.Errorf("parseAdjustment(%q) err = %v, want ErrInvalidBulkEdit", expr, err)
+		}
+	}
+
+	fn, _ := parseAdjustment("+1")
+	if _, err := fn("n/a"); err == nil {
+		t.Error("adjusting a non-number: want error")
+	}
+}
+
+func TestBulkEditTransform(t *testing.T) {
+	text := FieldSpec{Name: "Status", Type: FieldText}
+	amount := FieldSpec{Name: "Amount", Type: FieldNumeric}
+
+	tests := []struct {
+		req      BulkColumnEdit
+		spec     FieldSpec
+		old, new string
+	}{
+		{BulkColumnEdit{Value: "open"}, text, "closed", "open"},
+		{BulkColumnEdit{Mode: BulkEditSet, Value: "5"}, amount, "1", "5"},
+		{BulkColumnEdit{Mode: BulkEditReplace, Find: "Inc.", Value: "Inc"}, text, "Acme Inc. (Inc.)", "Acme Inc (Inc)"},
+		{BulkColumnEdit{Mode: BulkEditTrim}, text, "  a b ", "a b"},
+		{BulkColumnEdit{Mode: BulkEditUpper}, text, "eu-west", "EU-WEST"},
+		{BulkColumnEdit{Mode: BulkEditLower}, FieldSpec{Type: FieldEnum}, "OPEN", "open"},
+		{BulkColumnEdit{Mode: BulkEditAdjust, Value: "+1"}, amount, "1.50", "2.50"},
+	}
+	for _, tt := range tests {
+		fn, err := bulkEditTransform(tt.req, tt.spec)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.req, err)
+			continue
+		}
+		if got, _ := fn(tt.old); got != tt.new {
+			t.Errorf("%+v on %q = %q, want %q", tt.req, tt.old, got, tt.new)
+		}
+	}
+
+	invalid := []struct {
+		req  BulkColumnEdit
+		spec FieldSpec
+	}{
+		{BulkColumnEdit{Mode: BulkEditReplace, Value: "x"}, text}, // No find
+		{BulkColumnEdit{Mode: BulkEditAdjust, Value: "+1"}, text},
+		{BulkColumnEdit{Mode: BulkEditUpper}, amount},
+		{BulkColumnEdit{Mode: "reverse"}, text},
+	}
+	for _, tt := range invalid {
+		if _, err := bulkEditTransform(tt.req, tt.spec); !errors.Is(err, ErrInvalidBulkEdit) {
+			t.Errorf("%+v on %s: err = %v, want ErrInvalidBulkEdit", tt.req, tt.spec.Name, err)
+		}
+	}
+	if _, err := bulkEditTransform(BulkColumnEdit{Value: "abc"}, amount); err == nil {
+		t.Error("setting a number column to text: want error")
+	}
+}
+
+func TestBulkEditDescribe(t *testing.T) {
+	tests := map[string]BulkColumnEdit{
+		"closed":               {Value: "closed"},
+		`replace "a" with "b"`: {Mode: BulkEditReplace, Find: "a", Value: "b"},
+		"adjust +10%":          {Mode: BulkEditAdjust, Value: " +10% "},
+		"trim":                 {Mode: BulkEditTrim},
+	}
+	for want, req := range tests {
+		if got := req.describe(); got != want {
+			t.Errorf("describe(%+v) = %q, want %q", req, got, want)
+		}
+	}
+
+	req := BulkEditRequest{Edits: []BulkColumnEdit{
+		{Column: "Status", Value: "closed"},
+		{Column: "Amount", Mode: BulkEditAdjust, Value: "*2"},
+	}}
+	if got, want := req.describe(), "Status: closed\nAmount: adjust *2"; got != want {
+		t.Errorf("describe(two edits) = %q, want %q", got, want)
+	}
+}
+
+func TestBulkEditTargets(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "invoices", UniqueKey: []string{"Invoice"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice", Type: FieldText},
+			{Name: "Status", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric, DBColumn: "amount_usd"},
+		},
+	}
+	targets, err := bulkEditTargets(def, BulkEditRequest{Edits: []BulkColumnEdit{
+		{Column: "status", Mode: BulkEditUpper},
+		{Column: "Amount", Mode: BulkEditAdjust, Value: "+1"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].dbCol != "status" || targets[1].dbCol != "amount_usd" {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if got, _ := targets[1].transform("2.50"); got != "3.50" {
+		t.Errorf("Amount +1 on 2.50 = %q", got)
+	}
+
+	invalid := map[string][]BulkColumnEdit{
+		"no edits":     nil,
+		"same column":  {{Column: "Status", Value: "a"}, {Column: "status", Mode: BulkEditTrim}},
+		"unique key":   {{Column: "Status", Value: "a"}, {Column: "Invoice", Value: "1"}},
+		"unknown":      {{Column: "Notes", Value: "a"}},
+		"invalid mode": {{Column: "Status", Value: "a"}, {Column: "Amount", Mode: BulkEditUpper}},
+	}
+	for name, edits := range invalid {
+		if _, err := bulkEditTargets(def, BulkEditRequest{Edits: edits}); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestBulkEditRowError(t *testing.T) {
+	tests := []struct {
+		changes []BulkEditChange
+		want    string
+	}{
+		{[]BulkEditChange{{Column: "A"}, {Column: "B"}}, ""},
+		{[]BulkEditChange{{Error: "row not found"}}, "row not found"},
+		{[]BulkEditChange{{Column: "A", Error: "bad"}, {Column: "B"}, {Column: "C", Error: "worse"}}, "A: bad; C: worse"},
+	}
+	for _, tt := range tests {
+		if got := bulkEditRowError(tt.changes); got != tt.want {
+			t.Errorf("bulkEditRowError(%+v) = %q, want %q", tt.changes, got, tt.want)
+		}
+	}
+}
+
+// execRecorder is a DBTX that records Exec calls. Query and QueryRow are
+// not implemented and panic if called.
+type execRecorder struct {
+	DBTX
+	sql  string
+	args []any
+}
+
+func (r *execRecorder) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	r.sql, r.args = sql, args
+	return pgconn.CommandTag{}, nil
+}
+
+func bulkEditTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "invoices", UniqueKey: []string{"Region", "Invoice Date"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Region", Type: FieldText},
+			{Name: "Invoice Date", Type: FieldDate},
+			{Name: "Status", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+		},
+		SoftDelete: true,
+	}
+}
+
+func TestBulkEditUpdate(t *testing.T) {
+	def := bulkEditTestTable()
+	targets, err := bulkEditTargets(def, BulkEditRequest{Edits: []BulkColumnEdit{
+		{Column: "Status", Value: "closed"},
+		{Column: "Amount", Mode: BulkEditAdjust, Value: "*2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{"invoice_date": "date", "amount": "numeric(12,2)"}
+	u := newBulkEditUpdate(def, types, targets)
+
+	rows := []bulkEditRow{
+		{key: []string{"EU", "2024-03-01"}, changes: []BulkEditChange{
+			{OldValue: "open", NewValue: "closed"}, {OldValue: "10.00", NewValue: "20.00"}}},
+		{key: []string{"US", "2024-03-02"}, changes: []BulkEditChange{
+			{OldValue: "closed", NewValue: "closed"}, {OldValue: "", NewValue: ""}}}, // Unchanged
+		{key: []string{"US", "2024-03-03"}, changes: []BulkEditChange{
+			{OldValue: "", NewValue: "closed"}, {OldValue: "", NewValue: ""}}},
+	}
+	for i, row := range rows {
+		if got, want := u.add(row), i != 1; got != want {
+			t.Errorf("add(row %d) = %v, want %v", i, got, want)
+		}
+	}
+
+	var rec execRecorder
+	if err := u.exec(context.Background(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	wantSQL := `UPDATE "invoices" AS t
+SET "status" = CASE WHEN k.c1 THEN k.v1::TEXT ELSE t."status" END,
+    "amount" = CASE WHEN k.c2 THEN k.v2::numeric(12,2) ELSE t."amount" END
+FROM unnest($1::text[], $2::text[], $3::text[], $4::boolean[], $5::text[], $6::boolean[]) AS k(k1, k2, v1, c1, v2, c2)
+WHERE t."region" = k.k1::TEXT AND t."invoice_date" = k.k2::date AND t."deleted_at" IS NULL`
+	if rec.sql != wantSQL {
+		t.Errorf("sql:\n%s\nwant:\n%s", rec.sql, wantSQL)
+	}
+	text := func(s string) pgtype.Text { return pgtype.Text{String: s, Valid: s != ""} }
+	wantArgs := []any{
+		[]string{"EU", "US"}, []string{"2024-03-01", "2024-03-03"},
+		[]pgtype.Text{text("closed"), text("closed")}, []bool{true, true},
+		[]pgtype.Text{text("20.00"), {}}, []bool{true, false},
+	}
+	if !reflect.DeepEqual(rec.args, wantArgs) {
+		t.Errorf("args = %v\nwant %v", rec.args, wantArgs)
+	}
+
+	// Nothing to write, no statement
+	rec = execRecorder{}
+	if err := newBulkEditUpdate(def, types, targets).exec(context.Background(), &rec); err != nil || rec.sql != "" {
+		t.Errorf("empty update ran %q, %v", rec.sql, err)
+	}
+}
+
+func TestPlanBulkEdit_InvalidKeys(t *testing.T) {
+	// None of the keys can match a row, so nothing is queried (q is nil)
+	s := &Service{}
+	rows, err := s.planBulkEdit(context.Background(), nil, bulkEditTestTable(), nil,
+		[]string{"EU", "EU|not a date", "EU|", "EU", "EU|2024-03-01|x"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.changes[0].RowKey+": "+bulkEditRowError(row.changes))
+	}
+	want := []string{
+		"EU: invalid key format",
+		"EU|not a date: row not found",
+		"EU|: row not found",
+		"EU|2024-03-01|x: invalid key format",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestCellDBText(t *testing.T) {
+	tests := []struct {
+		value string
+		typ   FieldType
+		want  pgtype.Text
+	}{
+		{"1200.50", FieldNumeric, pgtype.Text{String: "1200.50", Valid: true}},
+		{"2024-03-01", FieldDate, pgtype.Text{String: "2024-03-01", Valid: true}},
+		{"yes", FieldBool, pgtype.Text{String: "true", Valid: true}},
+		{" open ", FieldText, pgtype.Text{String: "open", Valid: true}},
+		{"", FieldNumeric, pgtype.Text{}},
+	}
+	for _, tt := range tests {
+		if got := cellDBText(tt.value, &FieldSpec{Type: tt.typ}); got != tt.want {
+			t.Errorf("cellDBText(%q, %v) = %+v, want %+v", tt.value, tt.typ, got, tt.want)
+		}
+	}
+}
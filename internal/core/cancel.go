@@ -0,0 +1,73 @@
+package core
+
+// cancel.go makes cancelling an upload, or any other context, stop its
+// database work promptly - on the server as well as in this process.
+//
+// pgx's default reaction to a cancelled context is to drop the connection.
+// The call returns at once, but the backend carries on with the statement,
+// holding its locks, until it next writes to the client; a long INSERT or
+// DELETE can run for minutes. ConfigureCancellation instead has pgx send
+// PostgreSQL a cancel request straight away, which stops a COPY or query
+// within milliseconds and keeps the connection usable, and only drops the
+// connection if the statement has not stopped after DB_CANCEL_GRACE.
+//
+// rollbackTx covers the remaining case: when the connection was dropped
+// after all, the backend is cancelled with pg_cancel_backend from another
+// connection. Behind a pooler in transaction mode it can't be (see
+// pooling.go), and the pooler is left to clean up the server connection.
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+)
+
+// rollbackTimeout bounds the rollback of a cancelled transaction.
+const rollbackTimeout = 5 * time.Second
+
+// ConfigureCancellation makes a cancelled context cancel the statement
+// running on its connection on the server, dropping the connection if the
+// statement has not stopped after grace. Apply it to the pool's connection
+// config before connecting.
+func ConfigureCancellation(cfg *pgx.ConnConfig, grace time.Duration) {
+	cfg.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: grace}
+	}
+}
+
+// rollbackTx rolls back tx unless it was committed. The rollback of a
+// cancelled transaction runs on a fresh context, so the connection returns
+// to the pool clean rather than being discarded. If the rollback fails the
+// connection is gone, but its backend may still be running the cancelled
+// statement, so it is cancelled from another connection.
+func (s *Service) rollbackTx(ctx context.Context, tx pgx.Tx) {
+	if ctx.Err() == nil {
+		tx.Rollback(ctx)
+		return
+	}
+
+	pid := tx.Conn().PgConn().PID()
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	err := tx.Rollback(rctx)
+	if err == nil || errors.Is(err, pgx.ErrTxClosed) {
+		return
+	}
+	if s.transactionPooling() {
+		// pid is the pooler's, not the backend's
+		slog.Warn("rollback of cancelled transaction failed", "error", err)
+		return
+	}
+	slog.Warn("rollback of cancelled transaction failed, cancelling backend",
+		"pid", pid,
+		"error", err,
+	)
+	if _, err := s.pool.Exec(rctx, "SELECT pg_cancel_backend($1)", int64(pid)); err != nil {
+		slog.Error("failed to cancel backend", "pid", pid, "error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// fakeCopyServer is a PostgreSQL server that accepts one COPY FROM STDIN
+// and reads its data until the client gives up or, if honorCancel, a
+// cancel request arrives.
+type fakeCopyServer struct {
+	ln          net.Listener
+	honorCancel bool
+	copying     chan struct{} // Closed when the COPY has started
+	cancels     atomic.Int32  // Cancel requests received
+}
+
+func newFakeCopyServer(t *testing.T, honorCancel bool) *fakeCopyServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeCopyServer{
+		ln:          ln,
+		honorCancel: honorCancel,
+		copying:     make(chan struct{}),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeCopyServer) url() string {
+	return fmt.Sprintf("postgres://test@%s/test?sslmode=disable", srv.ln.Addr())
+}
+
+func (srv *fakeCopyServer) serve(conn net.Conn) {
+	defer conn.Close()
+	be := pgproto3.NewBackend(conn, conn)
+
+	msg, err := be.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	if _, ok := msg.(*pgproto3.CancelRequest); ok {
+		srv.cancels.Add(1)
+		return
+	}
+
+	be.Send(&pgproto3.AuthenticationOk{})
+	be.Send(&pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7})
+	be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if be.Flush() != nil {
+		return
+	}
+
+	copying := false
+	for {
+		msg, err := be.Receive()
+		if err != nil {
+			return
+		}
+		switch msg.(type) {
+		case *pgproto3.Parse:
+			be.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			be.Send(&pgproto3.ParameterDescription{})
+			be.Send(&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+				{Name: []byte("name"), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1},
+			}})
+		case *pgproto3.Sync:
+			be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		case *pgproto3.Query:
+			be.Send(&pgproto3.CopyInResponse{OverallFormat: 1, ColumnFormatCodes: []uint16{1}})
+			copying = true
+			close(srv.copying)
+		case *pgproto3.CopyData:
+			if copying && srv.honorCancel && srv.cancels.Load() > 0 {
+				be.Send(&pgproto3.ErrorResponse{
+					Severity: "ERROR",
+					Code:     "57014",
+					Message:  "canceling statement due to user request",
+				})
+				be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				copying = false
+			}
+		case *pgproto3.CopyDone, *pgproto3.CopyFail:
+			if copying {
+				be.Send(&pgproto3.CommandComplete{CommandTag: []byte("COPY 0")})
+				be.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				copying = false
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+		if be.Flush() != nil {
+			return
+		}
+	}
+}
+
+// copyCancelLatency runs an endless COPY against srv, cancels it once it
+// is under way and returns how long CopyFrom took to return.
+func copyCancelLatency(t *testing.T, srv *fakeCopyServer, grace time.Duration) time.Duration {
+	t.Helper()
+	cfg, err := pgx.ParseConfig(srv.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ConfigureCancellation(cfg, grace)
+
+	connectCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	conn, err := pgx.ConnectConfig(connectCtx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.CopyFrom(ctx, pgx.Identifier{"rows"}, []string{"name"},
+			pgx.CopyFromFunc(func() ([]any, error) { return []any{"row"}, nil }))
+		done <- err
+	}()
+
+	select {
+	case <-srv.copying:
+	case <-time.After(5 * time.Second):
+		t.Fatal("COPY did not start")
+	}
+	time.Sleep(200 * time.Millisecond) // Mid-COPY
+
+	cancel()
+	start := time.Now()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("CopyFrom succeeded after cancel")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("CopyFrom did not return after cancel")
+	}
+	return time.Since(start)
+}
+
+func TestConfigureCancellation_CancelsCopy(t *testing.T) {
+	srv := newFakeCopyServer(t, true)
+	latency := copyCancelLatency(t, srv, 10*time.Second)
+
+	if latency > 2*time.Second {
+		t.Errorf("cancel took %v, want under 2s", latency)
+	}
+	if srv.cancels.Load() == 0 {
+		t.Error("server got no cancel request")
+	}
+}
+
+func TestConfigureCancellation_GraceBoundsIgnoredCancel(t *testing.T) {
+	srv := newFakeCopyServer(t, false)
+	latency := copyCancelLatency(t, srv, 500*time.Millisecond)
+
+	if latency > 2*time.Second {
+		t.Errorf("cancel took %v, want under 2s", latency)
+	}
+	if srv.cancels.Load() == 0 {
+		t.Error("server got no cancel request")
+	}
+}
@@ -0,0 +1,306 @@
+package core
+
+// cell_undo.go undoes and redoes cell edits by replaying the cell_edit
+// entries of the audit log. Each undo or redo is itself recorded as a
+// row_restore entry whose related_audit_id is the edit it reverted or
+// reapplied, so the undo and redo stacks are derived from the log:
+//
+//	undo  the newest cell edit whose latest restore is not an undo
+//	redo  the most recently undone edit, unless a cell of the table has
+//	      been edited since (a new edit clears the redo stack)
+//
+// Both can be limited to one row, so an edit that can't be undone (its row
+// was deleted, say) doesn't block undoing the others.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	cellUndoReason = "undo cell edit"
+	cellRedoReason = "redo cell edit"
+)
+
+var (
+	// ErrNothingToUndo is returned when a table has no cell edit to undo.
+	ErrNothingToUndo = errors.New("no cell edit to undo")
+
+	// ErrNothingToRedo is returned when a table has no undone cell edit to
+	// redo.
+	ErrNothingToRedo = errors.New("no undone cell edit to redo")
+)
+
+// CellUndoResult contains the result of an undo or redo.
+type CellUndoResult struct {
+	Success bool   `json:"success"`
+	EditID  string `json:"editId"` // The cell_edit audit entry undone or redone
+	RowKey  string `json:"rowKey"` // The row's key after the change
+	Column  string `json:"column"`
+	Value   string `json:"value"` // The value written
+
+	// Conflict is set, and nothing written, if the cell no longer holds the
+	// value the edit left (undo) or replaced (redo)
+	Conflict     bool   `json:"conflict,omitempty"`
+	CurrentValue string `json:"currentValue,omitempty"`
+	RowMissing   bool   `json:"rowMissing,omitempty"`
+
+	DuplicateKey   bool   `json:"duplicateKey,omitempty"`
+	ConflictingKey string `json:"conflictingKey,omitempty"`
+}
+
+// cellEdit is a cell_edit audit entry.
+type cellEdit struct {
+	ID       string
+	RowKey   string // The row's key before the edit
+	Column   string
+	OldValue string
+	NewValue string
+}
+
+// replayTarget returns the key of the row the edit's cell is in now, the
+// value it should hold, and the value to write, to undo the edit or (with
+// redo) apply it again. Undoing a key column edit finds the row by its
+// edited key.
+func (s *Service) replayTarget(uniqueKey []string, e cellEdit, redo bool) (rowKey, expect, write string) {
+	if redo {
+		return e.RowKey, e.OldValue, e.NewValue
+	}
+	rowKey = e.RowKey
+	if isKeyColumn(uniqueKey, e.Column) {
+		rowKey = s.buildNewCompositeKey(uniqueKey, e.RowKey, e.Column, e.NewValue)
+	}
+	return rowKey, e.NewValue, e.OldValue
+}
+
+// isKeyColumn reports whether column is part of uniqueKey.
+func isKeyColumn(uniqueKey []string, column string) bool {
+	for _, uk := range uniqueKey {
+		if strings.EqualFold(uk, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoCellEdit reverts the table's most recent cell edit that is not
+// already undone, recorded as a row_restore audit entry. Repeated calls
+// walk back through the edit history. A non-empty rowKey limits this to
+// edits made to the row while it had that key. Returns ErrNothingToUndo if
+// there is none; a cell changed since the edit is reported as a Conflict.
+func (s *Service) UndoCellEdit(ctx context.Context, tableKey, rowKey string) (*CellUndoResult, error) {
+	return s.replayCellEdit(ctx, tableKey, rowKey, false)
+}
+
+// RedoCellEdit reapplies the most recently undone cell edit, unless the
+// table (or, with rowKey, the row) has been edited since. Returns
+// ErrNothingToRedo if there is none.
+func (s *Service) RedoCellEdit(ctx context.Context, tableKey, rowKey string) (*CellUndoResult, error) {
+	return s.replayCellEdit(ctx, tableKey, rowKey, true)
+}
+
+func (s *Service) replayCellEdit(ctx context.Context, tableKey, rowFilter string, redo bool) (*CellUndoResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	defer s.invalidateQueryCache(tableKey)
+	uniqueKey := def.Info.UniqueKey
+	if len(uniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
+	}
+
+	// Serialize undo/redo per table so two clicks can't replay one edit
+	// twice. The lock is held until the restore is recorded.
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "cell_undo:"+tableKey); err != nil {
+		return nil, fmt.Errorf("lock cell history: %w", err)
+	}
+
+	edit, err := findReplayableEdit(ctx, tx, tableKey, rowFilter, redo)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, dbCol := editColumn(def, edit.Column)
+	rowKey, expect, write := s.replayTarget(uniqueKey, edit, redo)
+	if spec != nil {
+		if err := validateCellValue(write, *spec); err != nil {
+			return nil, fmt.Errorf("edit %s: value %q can't be written back: %v", edit.ID, write, err)
+		}
+	}
+	result := &CellUndoResult{EditID: edit.ID, RowKey: rowKey, Column: edit.Column, Value: write}
+
+	current, err := s.getCellValue(ctx, tableKey, def, uniqueKey, rowKey, dbCol)
+	if errors.Is(err, pgx.ErrNoRows) {
+		result.Conflict, result.RowMissing = true, true
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cell: %w", err)
+	}
+	holds, err := s.cellHolds(ctx, tableKey, def, uniqueKey, rowKey, dbCol, expect, spec)
+	if err != nil {
+		return nil, fmt.Errorf("read cell: %w", err)
+	}
+	if !holds {
+		result.Conflict, result.CurrentValue = true, current
+		return result, nil
+	}
+
+	if isKeyColumn(uniqueKey, edit.Column) {
+		newKey := s.buildNewCompositeKey(uniqueKey, rowKey, edit.Column, write)
+		if write != "" {
+			exists, err := s.keyExistsExcludingRow(ctx, tableKey, def, uniqueKey, newKey, rowKey)
+			if err != nil {
+				return nil, fmt.Errorf("duplicate check failed: %w", err)
+			}
+			if exists {
+				result.DuplicateKey, result.ConflictingKey = true, newKey
+				return result, nil
+			}
+		}
+		result.RowKey = newKey
+	}
+
+	if err := s.executeUpdateCell(ctx, tableKey, def, uniqueKey, rowKey, dbCol, write, spec); err != nil {
+		return nil, fmt.Errorf("update failed: %w", err)
+	}
+
+	reason := cellUndoReason
+	if redo {
+		reason = cellRedoReason
+	}
+	if _, err := s.LogAudit(ctx, AuditLogParams{
+		Action:         ActionRowRestore,
+		TableKey:       tableKey,
+		RowKey:         rowKey,
+		ColumnName:     edit.Column,
+		OldValue:       current,
+		NewValue:       write,
+		RowsAffected:   1,
+		RelatedAuditID: edit.ID,
+		Reason:         reason,
+		IPAddress:      GetIPAddressFromContext(ctx),
+		UserAgent:      GetUserAgentFromContext(ctx),
+	}); err != nil {
+		// Without the entry the edit would be replayed again
+		return nil, fmt.Errorf("cell updated but not recorded: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// findReplayableEdit returns the edit the next undo (or redo) applies to,
+// among all of the table's edits or, with rowFilter, that row's.
+func findReplayableEdit(ctx context.Context, q pgx.Tx, tableKey, rowFilter string, redo bool) (cellEdit, error) {
+	query := `
+		SELECT e.id::text, COALESCE(e.row_key, ''), COALESCE(e.column_name, ''),
+		       COALESCE(e.old_value, ''), COALESCE(e.new_value, '')
+		FROM audit_log e
+		WHERE e.action = 'cell_edit' AND e.table_key = $1
+		  AND ($3 = '' OR e.row_key = $3)
+		  AND COALESCE((
+		      SELECT r.reason FROM audit_log r
+		      WHERE r.related_audit_id = e.id AND r.action = 'row_restore'
+		      ORDER BY r.created_at DESC LIMIT 1
+		  ), '') <> $2
+		ORDER BY e.created_at DESC
+		LIMIT 1`
+	notFound := ErrNothingToUndo
+	if redo {
+		query = `
+		SELECT e.id::text, COALESCE(e.row_key, ''), COALESCE(e.column_name, ''),
+		       COALESCE(e.old_value, ''), COALESCE(e.new_value, '')
+		FROM audit_log e
+		JOIN LATERAL (
+		    SELECT r.reason, r.created_at FROM audit_log r
+		    WHERE r.related_audit_id = e.id AND r.action = 'row_restore'
+		    ORDER BY r.created_at DESC LIMIT 1
+		) last ON last.reason = $2
+		WHERE e.action = 'cell_edit' AND e.table_key = $1
+		  AND ($3 = '' OR e.row_key = $3)
+		  AND last.created_at > (
+		      SELECT MAX(created_at) FROM audit_log
+		      WHERE action = 'cell_edit' AND table_key = $1
+		        AND ($3 = '' OR row_key = $3)
+		  )
+		ORDER BY last.created_at DESC
+		LIMIT 1`
+		notFound = ErrNothingToRedo
+	}
+
+	var e cellEdit
+	err := q.QueryRow(ctx, query, tableKey, cellUndoReason, rowFilter).Scan(&e.ID, &e.RowKey, &e.Column, &e.OldValue, &e.NewValue)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cellEdit{}, fmt.Errorf("%w in %s", notFound, tableKey)
+	}
+	if err != nil {
+		return cellEdit{}, fmt.Errorf("read cell history: %w", err)
+	}
+	return e, nil
+}
+
+// editColumn returns the FieldSpec (nil if none) and database column of a
+// display column, as UpdateCell resolves them.
+func editColumn(def TableDefinition, column string) (*FieldSpec, string) {
+	var spec *FieldSpec
+	for i := range def.FieldSpecs {
+		if strings.EqualFold(def.FieldSpecs[i].Name, column) {
+			spec = &def.FieldSpecs[i]
+			break
+		}
+	}
+	dbCol := toDBColumnName(column)
+	if spec != nil && spec.DBColumn != "" {
+		dbCol = spec.DBColumn
+	}
+	return spec, dbCol
+}
+
+// cellHolds reports whether a cell equals value, compared as the column's
+// type so that e.g. "1,200.5" matches a stored 1200.50.
+func (s *Service) cellHolds(ctx context.Context, tableKey string, def TableDefinition, uniqueKey []string, rowKey, dbCol, value string, spec *FieldSpec) (bool, error) {
+	keyParts := strings.Split(rowKey, "|")
+	if len(keyParts) != len(uniqueKey) {
+		return false, fmt.Errorf("invalid row key format")
+	}
+	dbKeyCols := resolveDBColumns(uniqueKey, def.FieldSpecs)
+
+	conditions := make([]string, len(dbKeyCols))
+	args := make([]interface{}, len(dbKeyCols)+1)
+	args[0] = cellDBValue(value, spec)
+	for i, keyCol := range dbKeyCols {
+		conditions[i] = fmt.Sprintf("%s = $%d", quoteIdentifier(keyCol), i+2)
+		args[i+1] = keyParts[i]
+	}
+	query := fmt.Sprintf(
+		"SELECT %s IS NOT DISTINCT FROM $1 FROM %s WHERE %s%s",
+		quoteIdentifier(dbCol),
+		quoteIdentifier(tableKey),
+		strings.Join(conditions, " AND "),
+		andLiveRows(def, ""),
+	)
+
+	var holds bool
+	err := s.pool.QueryRow(ctx, query, args...).Scan(&holds)
+	return holds, err
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestReplayTarget(t *testing.T) {
+	s := &Service{}
+	uniqueKey := []string{"Region", "Order ID"}
+
+	tests := []struct {
+		name        string
+		edit        cellEdit
+		redo        bool
+		wantRow     string
+		wantExpect  string
+		wantWritten string
+	}{
+		{"undo", cellEdit{RowKey: "EU|1", Column: "Amount", OldValue: "10", NewValue: "12"}, false, "EU|1", "12", "10"},
+		{"redo", cellEdit{RowKey: "EU|1", Column: "Amount", OldValue: "10", NewValue: "12"}, true, "EU|1", "10", "12"},
+		{"undo to empty", cellEdit{RowKey: "EU|1", Column: "Amount", NewValue: "12"}, false, "EU|1", "12", ""},
+		// The edit moved the row to key US|1, where undo must find it
+		{"undo key edit", cellEdit{RowKey: "EU|1", Column: "region", OldValue: "EU", NewValue: "US"}, false, "US|1", "US", "EU"},
+		{"redo key edit", cellEdit{RowKey: "EU|1", Column: "region", OldValue: "EU", NewValue: "US"}, true, "EU|1", "EU", "US"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, expect, write := s.replayTarget(uniqueKey, tt.edit, tt.redo)
+			if row != tt.wantRow || expect != tt.wantExpect || write != tt.wantWritten {
+				t.Errorf("replayTarget = (%q, %q, %q), want (%q, %q, %q)", row, expect, write, tt.wantRow, tt.wantExpect, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestUndoCellEdit_Errors(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "undo_notes"},
+		FieldSpecs: []FieldSpec{{Name: "Note", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "undo_notes")
+		registryMu.Unlock()
+	})
+	s := &Service{cfg: &config.Config{}}
+	ctx := context.Background()
+
+	if _, err := s.UndoCellEdit(ctx, "nope", ""); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("unknown table: err = %v", err)
+	}
+	if _, err := s.RedoCellEdit(ctx, "undo_notes", ""); err == nil || !strings.Contains(err.Error(), "no unique key") {
+		t.Errorf("no unique key: err = %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package core
+
+// compressed.go accepts gzip-compressed uploads and zip archives.
+//
+// Gzip input is recognized by its magic bytes rather than the file name and
+// decompressed as the upload streams (see WrapForStreaming), so a .csv.gz
+// costs no more memory than the CSV itself. A zip archive needs random
+// access to its central directory, so it is read into memory and expanded
+// into an upload batch with one file per CSV it contains.
+//
+// Decompressed sizes count against the upload size limit, as if the file
+// had been decompressed before it was uploaded.
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// errZipNotStreamed is returned when a zip archive reaches a streaming
+// upload, which reads a single CSV.
+var errZipNotStreamed = errors.New("zip archives are uploaded as a batch; upload the archive directly to the table")
+
+// IsZipArchive reports whether an upload should be expanded as a zip
+// archive, judged by its file name or its first bytes.
+func IsZipArchive(fileName string, head []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".zip") || bytes.HasPrefix(head, zipMagic)
+}
+
+// decompressedTooLarge is the error for input that decompresses past limit.
+func decompressedTooLarge(limit int64) error {
+	return fmt.Errorf("decompressed file too large (max %d bytes)", limit)
+}
+
+// decompressingReader passes plain input through and decompresses gzip
+// input, deciding on the first Read.
+type decompressingReader struct {
+	src   *bufio.Reader
+	r     io.Reader // Set on the first Read
+	limit int64     // Max decompressed bytes of gzip input; 0 means no limit
+	n     int64
+}
+
+func newDecompressingReader(r io.Reader, limit int64) *decompressingReader {
+	return &decompressingReader{src: bufio.NewReader(r), limit: limit}
+}
+
+func (d *decompressingReader) Read(p []byte) (int, error) {
+	if d.r == nil {
+		head, _ := d.src.Peek(len(zipMagic))
+		switch {
+		case bytes.HasPrefix(head, gzipMagic):
+			gz, err := gzip.NewReader(d.src)
+			if err != nil {
+				return 0, fmt.Errorf("invalid gzip file: %w", err)
+			}
+			d.r = gz
+		case bytes.HasPrefix(head, zipMagic):
+			return 0, errZipNotStreamed
+		default:
+			d.r = d.src
+			d.limit = 0 // Plain input is bounded by the request size
+		}
+	}
+
+	n, err := d.r.Read(p)
+	d.n += int64(n)
+	if d.limit > 0 && d.n > d.limit {
+		return n, decompressedTooLarge(d.limit)
+	}
+	return n, err
+}
+
+// decompressBytes returns data decompressed if it is gzip, else data.
+func decompressBytes(data []byte, limit int64) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	return readAllLimited(newDecompressingReader(bytes.NewReader(data), limit))
+}
+
+// readAllLimited reads r to the end. The limit is enforced by r.
+func readAllLimited(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// archiveEntry is one CSV expanded from a zip archive.
+type archiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// isArchiveCSV reports whether a zip entry is a CSV (or JSON, see
+// isJSONUpload) to upload. Directories, macOS resource forks and hidden
+// files are skipped.
+func isArchiveCSV(f *zip.File) bool {
+	if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), ".") {
+		return false
+	}
+	name := strings.TrimSuffix(strings.ToLower(f.Name), ".gz")
+	switch path.Ext(name) {
+	case ".csv", ".json", ".ndjson", ".jsonl":
+		return true
+	}
+	return false
+}
+
+// expandZip returns the CSVs in a zip archive, in archive order, with any
+// .csv.gz entries decompressed. limit bounds the total decompressed size;
+// 0 means no limit.
+func expandZip(data []byte, limit int64) ([]archiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip file: %w", err)
+	}
+
+	var entries []archiveEntry
+	var total int64
+	for _, f := range zr.File {
+		if !isArchiveCSV(f) {
+			continue
+		}
+		remaining := int64(0)
+		if limit > 0 {
+			remaining = limit - total
+			if remaining <= 0 || f.UncompressedSize64 > uint64(remaining) {
+				return nil, decompressedTooLarge(limit)
+			}
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", f.Name, err)
+		}
+		content, err := readAllLimited(newDecompressingReader(limitedReader(rc, remaining), remaining))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		if limit > 0 && int64(len(content)) > remaining {
+			return nil, decompressedTooLarge(limit)
+		}
+
+		total += int64(len(content))
+		entries = append(entries, archiveEntry{Name: f.Name, Data: content})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("zip file contains no CSV files")
+	}
+	return entries, nil
+}
+
+// limitedReader returns r limited to one byte past limit, so reading past
+// it is detected rather than silently truncated. 0 means no limit.
+func limitedReader(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return io.LimitReader(r, limit+1)
+}
+
+// StartZipUpload expands a zip archive and uploads every CSV in it to
+// tableKey as one upload batch (see StartUploadBatch). Entries may be
+// .csv, .json, .ndjson or .jsonl, optionally gzipped; mapping, mode, dups and dateOpts apply to every file.
+// Returns the batch ID and one upload ID per CSV, in archive order.
+func (s *Service) StartZipUpload(ctx context.Context, tableKey string, r io.Reader, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, []string, error) {
+	maxSize := s.cfg.Upload.MaxFileSize
+	data, err := readAllLimited(limitedReader(r, maxSize))
+	if err != nil {
+		return "", nil, fmt.Errorf("read zip file: %w", err)
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return "", nil, fmt.Errorf("file too large (max %d bytes)", maxSize)
+	}
+
+	entries, err := expandZip(data, maxSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	files := make([]BatchFile, len(entries))
+	for i, e := range entries {
+		files[i] = BatchFile{
+			TableKey:   tableKey,
+			FileName:   e.Name,
+			Data:       e.Data,
+			Mapping:    mapping,
+			Mode:       mode,
+			Duplicates: dups,
+			Dates:      dateOpts,
+		}
+	}
+	return s.StartUploadBatch(ctx, files)
+}
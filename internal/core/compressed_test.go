@@ -0,0 +1,159 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, files map[string][]byte, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range order {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWrapForStreaming_Gzip(t *testing.T) {
+	csv := append([]byte{0xEF, 0xBB, 0xBF}, strings.Repeat("id,name\n1,acme\n", 50)...)
+	compressed := gzipBytes(t, csv)
+
+	reader := WrapForStreaming(bytes.NewReader(compressed), int64(len(compressed)))
+	result, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := string(csv[3:]); string(result) != want {
+		t.Errorf("got %d bytes, want the CSV without its BOM", len(result))
+	}
+	if reader.BytesRead != int64(len(compressed)) || reader.Progress() != 100 {
+		t.Errorf("BytesRead = %d, Progress = %d; want compressed size %d, 100",
+			reader.BytesRead, reader.Progress(), len(compressed))
+	}
+}
+
+func TestWrapForStreaming_DecompressedLimit(t *testing.T) {
+	compressed := gzipBytes(t, bytes.Repeat([]byte("a,b\n"), 1000))
+
+	_, err := io.ReadAll(wrapForStreaming(bytes.NewReader(compressed), 0, 100))
+	if err == nil || MapError(err).Code != "FILE001" {
+		t.Errorf("err = %v, want a file too large error", err)
+	}
+
+	// Plain input is not limited here; the request size bounds it
+	plain := bytes.Repeat([]byte("a,b\n"), 1000)
+	if got, err := io.ReadAll(wrapForStreaming(bytes.NewReader(plain), 0, 100)); err != nil || len(got) != len(plain) {
+		t.Errorf("plain input: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestWrapForStreaming_ZipRejected(t *testing.T) {
+	archive := zipBytes(t, map[string][]byte{"a.csv": []byte("a\n1\n")}, []string{"a.csv"})
+	_, err := io.ReadAll(WrapForStreaming(bytes.NewReader(archive), 0))
+	if !errors.Is(err, errZipNotStreamed) {
+		t.Errorf("err = %v, want errZipNotStreamed", err)
+	}
+}
+
+func TestDecompressBytes(t *testing.T) {
+	plain := []byte("a,b\n1,2\n")
+	if got, err := decompressBytes(plain, 0); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("plain: %q, %v", got, err)
+	}
+	if got, err := decompressBytes(gzipBytes(t, plain), 0); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("gzip: %q, %v", got, err)
+	}
+	if _, err := decompressBytes(gzipBytes(t, plain), 4); err == nil {
+		t.Error("expected decompressed size limit error")
+	}
+	if _, err := decompressBytes([]byte{0x1f, 0x8b, 0, 0}, 0); err == nil {
+		t.Error("expected invalid gzip error")
+	}
+}
+
+func TestIsZipArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want bool
+	}{
+		{"orders.zip", nil, true},
+		{"ORDERS.ZIP", nil, true},
+		{"orders", []byte("PK\x03\x04"), true},
+		{"orders.csv", []byte("id,n"), false},
+		{"orders.csv.gz", []byte{0x1f, 0x8b, 8, 0}, false},
+	}
+	for _, tt := range tests {
+		if got := IsZipArchive(tt.name, tt.head); got != tt.want {
+			t.Errorf("IsZipArchive(%q, %q) = %v, want %v", tt.name, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestExpandZip(t *testing.T) {
+	files := map[string][]byte{
+		"exports/":             nil,
+		"exports/orders.csv":   []byte("id\n1\n"),
+		"exports/refunds.CSV":  []byte("id\n2\n"),
+		"exports/lines.csv.gz": gzipBytes(t, []byte("id\n3\n")),
+		"exports/readme.txt":   []byte("not a csv"),
+		"exports/.hidden.csv":  []byte("id\n4\n"),
+		"__MACOSX/orders.csv":  []byte("junk"),
+	}
+	order := []string{"exports/", "exports/orders.csv", "exports/readme.txt", "exports/lines.csv.gz",
+		"exports/.hidden.csv", "__MACOSX/orders.csv", "exports/refunds.CSV"}
+	archive := zipBytes(t, files, order)
+
+	entries, err := expandZip(archive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name+"="+strings.TrimSpace(string(e.Data)))
+	}
+	want := "exports/orders.csv=id\n1 exports/lines.csv.gz=id\n3 exports/refunds.CSV=id\n2"
+	if strings.Join(got, " ") != want {
+		t.Errorf("entries = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	if _, err := expandZip(archive, 12); err == nil || MapError(err).Code != "FILE001" {
+		t.Errorf("limit: err = %v, want a file too large error", err)
+	}
+
+	empty := zipBytes(t, map[string][]byte{"readme.txt": []byte("x")}, []string{"readme.txt"})
+	if _, err := expandZip(empty, 0); err == nil || !strings.Contains(err.Error(), "no CSV files") {
+		t.Errorf("empty: err = %v", err)
+	}
+	if _, err := expandZip([]byte("not a zip"), 0); err == nil {
+		t.Error("expected invalid zip error")
+	}
+}
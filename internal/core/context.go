@@ -7,6 +7,10 @@ type contextKey string
 const (
 	ctxKeyIPAddress contextKey = "audit_ip"
 	ctxKeyUserAgent contextKey = "audit_ua"
+	ctxKeyUploader  contextKey = "uploader"
+	ctxKeyUser      contextKey = "user"
+	ctxKeyAPIToken  contextKey = "api_token"
+	ctxKeyApproval  contextKey = "approval"
 )
 
 // ContextWithIPAddress adds IP address to context for audit logging.
@@ -34,3 +38,49 @@ func GetUserAgentFromContext(ctx context.Context) string {
 	}
 	return ""
 }
+
+// ContextWithUploader identifies who is uploading, for per-uploader upload
+// quotas, e.g. by a hash of their API key.
+func ContextWithUploader(ctx context.Context, uploader string) context.Context {
+	return context.WithValue(ctx, ctxKeyUploader, uploader)
+}
+
+// GetUploaderFromContext returns the uploader set by ContextWithUploader,
+// or "ip:" and the IP address if none was set. It returns "" for work the
+// server started itself.
+func GetUploaderFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(ctxKeyUploader).(string); ok && v != "" {
+		return v
+	}
+	if ip := GetIPAddressFromContext(ctx); ip != "" {
+		return "ip:" + ip
+	}
+	return ""
+}
+
+// ContextWithUser adds the signed-in user to context; audit entries logged
+// with it are attributed to them.
+func ContextWithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, ctxKeyUser, u)
+}
+
+// GetUserFromContext returns the signed-in user, or nil.
+func GetUserFromContext(ctx context.Context) *User {
+	if u, ok := ctx.Value(ctxKeyUser).(*User); ok {
+		return u
+	}
+	return nil
+}
+
+// ContextWithAPIToken adds the API token a request authenticated with.
+func ContextWithAPIToken(ctx context.Context, t *APIToken) context.Context {
+	return context.WithValue(ctx, ctxKeyAPIToken, t)
+}
+
+// GetAPITokenFromContext returns the request's API token, or nil.
+func GetAPITokenFromContext(ctx context.Context) *APIToken {
+	if t, ok := ctx.Value(ctxKeyAPIToken).(*APIToken); ok {
+		return t
+	}
+	return nil
+}
@@ -25,10 +25,11 @@ import (
 // Matches integers, decimals, and scientific notation.
 var numericRegex = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
 
-// TwoDigitYearPivot defines how 2-digit years are interpreted.
+// DefaultTwoDigitYearPivot defines how 2-digit years are interpreted.
 // Years that would result in dates more than this many years in the future
-// are assumed to be in the previous century.
-var TwoDigitYearPivot = 20
+// are assumed to be in the previous century. An upload or FieldSpec may set
+// its own pivot (see DateOptions).
+const DefaultTwoDigitYearPivot = 20
 
 // Date layouts split by year format for proper 2-digit year handling
 var (
@@ -54,36 +55,53 @@ func ToPgText(s string) pgtype.Text {
 }
 
 // ToPgDate converts a string to pgtype.Date.
-// Supports multiple date formats and handles 2-digit years with pivot.
+// Supports multiple date formats and handles 2-digit years with
+// DefaultTwoDigitYearPivot. Numeric dates are read month first (01/02/2024
+// is January 2nd); see ToPgDateFormat for day-first files.
 func ToPgDate(s string) pgtype.Date {
+	d, _ := parsePgDate(s, fourDigitYearLayouts, twoDigitYearLayouts, DefaultTwoDigitYearPivot)
+	return d
+}
+
+// parsePgDate tries the 4-digit year layouts, then the 2-digit year
+// layouts with pivot year adjustment. twoDigit reports whether the date
+// had a 2-digit year.
+func parsePgDate(s string, fourDigit, twoDigit []string, pivot int) (d pgtype.Date, twoDigitYear bool) {
 	s = strings.TrimSpace(s)
 	if s == "" {
-		return pgtype.Date{Valid: false}
+		return pgtype.Date{Valid: false}, false
 	}
 
 	// Try 4-digit year layouts first (unambiguous)
-	for _, layout := range fourDigitYearLayouts {
+	for _, layout := range fourDigit {
 		t, err := time.Parse(layout, s)
 		if err == nil {
-			return pgtype.Date{Time: t, Valid: true}
+			return pgtype.Date{Time: t, Valid: true}, false
 		}
 	}
 
 	// Try 2-digit year layouts with pivot year adjustment
 	currentYear := time.Now().Year()
-	pivotYear := currentYear + TwoDigitYearPivot
+	pivotYear := currentYear + pivot
 
-	for _, layout := range twoDigitYearLayouts {
+	for _, layout := range twoDigit {
 		t, err := time.Parse(layout, s)
 		if err == nil {
+			// Move the year into the 100 years ending at pivotYear;
+			// time.Parse puts 69-99 in the 1900s
+			if t.Year() < 2000 {
+				t = t.AddDate(100, 0, 0)
+			}
 			if t.Year() > pivotYear {
 				t = t.AddDate(-100, 0, 0)
+			} else if t.Year() <= pivotYear-100 {
+				t = t.AddDate(100, 0, 0)
 			}
-			return pgtype.Date{Time: t, Valid: true}
+			return pgtype.Date{Time: t, Valid: true}, true
 		}
 	}
 
-	return pgtype.Date{Valid: false}
+	return pgtype.Date{Valid: false}, false
 }
 
 // ToPgNumeric converts a string to pgtype.Numeric.
@@ -297,10 +297,6 @@ func TestToPgNumeric_SpecificValues(t *testing.T) {
 // ----------------------------------------------------------------------------
 
 func TestToPgDate(t *testing.T) {
-	// Store original pivot for restoration
-	originalPivot := TwoDigitYearPivot
-	defer func() { TwoDigitYearPivot = originalPivot }()
-
 	tests := []struct {
 		name      string
 		input     string
@@ -515,14 +511,9 @@ func TestToPgDate(t *testing.T) {
 
 // TestToPgDate_TwoDigitYear tests 2-digit year handling with pivot year logic
 func TestToPgDate_TwoDigitYear(t *testing.T) {
-	// Save original and restore after test
-	originalPivot := TwoDigitYearPivot
-	defer func() { TwoDigitYearPivot = originalPivot }()
-
-	// Set pivot to 20 years from now
-	TwoDigitYearPivot = 20
+	// The default pivot is 20 years from now
 	currentYear := time.Now().Year()
-	pivotYear := currentYear + 20
+	pivotYear := currentYear + DefaultTwoDigitYearPivot
 
 	tests := []struct {
 		name      string
@@ -0,0 +1,248 @@
+package core
+
+// data_quality.go profiles the columns of a table, so an import can be
+// sanity-checked at a glance: how many values are empty and how many
+// distinct, how long text values run, what dates span and which numbers
+// lie far from the rest.
+//
+// Numbers are outliers outside Tukey's fences, 1.5 interquartile ranges
+// beyond the quartiles. Columns are flagged (QualityFlag) when something
+// looks off, such as a required column with empty values or dates in the
+// future; a flag is a prompt to look, not a validation error.
+//
+// Soft-deleted rows are left out, and derived columns are not profiled.
+// Lengths and date ranges show single rows' values, so aggregates-only
+// callers are refused.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// QualityHighNullRate is the share of empty values above which a column is
+// flagged QualityHighNulls.
+const QualityHighNullRate = 0.5
+
+// QualityFlag marks something worth checking in a column.
+type QualityFlag string
+
+const (
+	QualityEmpty           QualityFlag = "empty"            // No values at all
+	QualityMissingRequired QualityFlag = "missing_required" // A required column has empty values
+	QualityHighNulls       QualityFlag = "high_null_rate"   // Mostly empty (see QualityHighNullRate)
+	QualityConstant        QualityFlag = "constant"         // The same value in every row that has one
+	QualityOutliers        QualityFlag = "outliers"         // Numbers outside the fences
+	QualityFutureDates     QualityFlag = "future_dates"     // Dates after today
+	QualityUnknownValues   QualityFlag = "unknown_values"   // Enum values not in EnumValues
+)
+
+// ColumnQuality profiles one column. Fields for other column types are
+// left empty.
+type ColumnQuality struct {
+	Column   string  `json:"column"`
+	Type     string  `json:"type"`     // "text", "enum", "date", "numeric" or "bool"
+	Empty    int64   `json:"empty"`    // Rows without a value
+	NullRate float64 `json:"nullRate"` // Empty as a share of all rows, 0 to 1
+	Distinct int64   `json:"distinct"` // Distinct values
+
+	// Text and enum columns
+	MinLength     *int  `json:"minLength,omitempty"`
+	MaxLength     *int  `json:"maxLength,omitempty"`
+	UnknownValues int64 `json:"unknownValues,omitempty"` // Enum values not in EnumValues
+
+	// Date columns, as 2024-01-31
+	MinDate     string `json:"minDate,omitempty"`
+	MaxDate     string `json:"maxDate,omitempty"`
+	FutureDates int64  `json:"futureDates,omitempty"`
+
+	// Numeric columns: values below LowerFence or above UpperFence are
+	// outliers
+	LowerFence *float64 `json:"lowerFence,omitempty"`
+	UpperFence *float64 `json:"upperFence,omitempty"`
+	Outliers   int64    `json:"outliers,omitempty"`
+
+	Flags []QualityFlag `json:"flags,omitempty"`
+}
+
+// DataQualityReport profiles every column of a table.
+type DataQualityReport struct {
+	TableKey string          `json:"tableKey"`
+	Rows     int64           `json:"rows"`
+	Columns  []ColumnQuality `json:"columns"` // In FieldSpecs order
+}
+
+// qualityValue returns the expression of a column's value for profiling:
+// empty text counts as no value.
+func qualityValue(spec FieldSpec) string {
+	col := quoteIdentifier(resolveDBColumn(spec.Name, []FieldSpec{spec}))
+	if spec.Type == FieldText || spec.Type == FieldEnum {
+		return "NULLIF(" + col + "::text, '')"
+	}
+	return col
+}
+
+// qualitySelect returns the SELECT list profiling specs: the row count,
+// then for each column its values, distinct values, two bounds (lengths
+// for text, quartiles for numbers), the date range and a count of odd
+// values (unknown enum values, future dates). Enum values are bound from
+// $argIdx on; they are returned as args.
+func qualitySelect(specs []FieldSpec, argIdx int) (string, []any) {
+	parts := []string{"COUNT(*)"}
+	var args []any
+	for _, spec := range specs {
+		v := qualityValue(spec)
+		lo, hi, minDate, maxDate, odd := "NULL::float8", "NULL::float8", "NULL::text", "NULL::text", "0::bigint"
+		switch spec.Type {
+		case FieldText, FieldEnum:
+			lo = "MIN(length(" + v + "))::float8"
+			hi = "MAX(length(" + v + "))::float8"
+			if spec.Type == FieldEnum && len(spec.EnumValues) > 0 {
+				values := make([]string, len(spec.EnumValues))
+				for i, e := range spec.EnumValues {
+					values[i] = strings.ToLower(e)
+				}
+				odd = fmt.Sprintf("COUNT(*) FILTER (WHERE lower(%s) <> ALL($%d::text[]))", v, argIdx)
+				args = append(args, values)
+				argIdx++
+			}
+		case FieldNumeric:
+			lo = "percentile_cont(0.25) WITHIN GROUP (ORDER BY " + v + "::float8)"
+			hi = "percentile_cont(0.75) WITHIN GROUP (ORDER BY " + v + "::float8)"
+		case FieldDate:
+			minDate = "to_char(MIN(" + v + "), 'YYYY-MM-DD')"
+			maxDate = "to_char(MAX(" + v + "), 'YYYY-MM-DD')"
+			odd = "COUNT(*) FILTER (WHERE " + v + " > CURRENT_DATE)"
+		}
+		parts = append(parts, "COUNT("+v+")", "COUNT(DISTINCT "+v+")", lo, hi, minDate, maxDate, odd)
+	}
+	return strings.Join(parts, ", "), args
+}
+
+// tukeyFences returns the bounds outside which values between quartiles
+// q1 and q3 are outliers.
+func tukeyFences(q1, q3 float64) (float64, float64) {
+	iqr := q3 - q1
+	return q1 - 1.5*iqr, q3 + 1.5*iqr
+}
+
+// GetDataQuality profiles the columns of tableKey's live rows.
+func (s *Service) GetDataQuality(ctx context.Context, tableKey string) (*DataQualityReport, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if AggregatesOnly(ctx) {
+		return nil, fmt.Errorf("%w: a data quality report shows single rows' values", ErrAggregatesOnly)
+	}
+
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
+	whereClause, args := wb.Build()
+	from := " FROM " + quoteIdentifier(tableKey) + whereClause
+
+	sel, selArgs := qualitySelect(def.FieldSpecs, wb.NextArgIndex())
+	report := &DataQualityReport{TableKey: tableKey, Columns: make([]ColumnQuality, len(def.FieldSpecs))}
+	type bounds struct {
+		lo, hi           *float64
+		minDate, maxDate *string
+		odd              int64
+	}
+	values := make([]int64, len(def.FieldSpecs)) // Non-empty values per column
+	b := make([]bounds, len(def.FieldSpecs))
+	dest := []any{&report.Rows}
+	for i := range def.FieldSpecs {
+		c := &report.Columns[i]
+		dest = append(dest, &values[i], &c.Distinct, &b[i].lo, &b[i].hi, &b[i].minDate, &b[i].maxDate, &b[i].odd)
+	}
+	if err := s.pool.QueryRow(ctx, "SELECT "+sel+from, append(args, selArgs...)...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("query data quality: %w", err)
+	}
+
+	// Outliers need the quartiles, so they are counted in a second pass
+	var outlierExprs []string
+	var outlierCols []int
+	outlierArgs := append([]any(nil), args...)
+	for i, spec := range def.FieldSpecs {
+		c := &report.Columns[i]
+		c.Column, c.Type = spec.Name, fieldTypeName(spec.Type)
+		c.Empty = report.Rows - values[i]
+		if report.Rows > 0 {
+			c.NullRate = float64(c.Empty) / float64(report.Rows)
+		}
+		switch spec.Type {
+		case FieldText, FieldEnum:
+			if b[i].lo != nil && b[i].hi != nil {
+				lo, hi := int(*b[i].lo), int(*b[i].hi)
+				c.MinLength, c.MaxLength = &lo, &hi
+			}
+			c.UnknownValues = b[i].odd
+		case FieldDate:
+			if b[i].minDate != nil && b[i].maxDate != nil {
+				c.MinDate, c.MaxDate = *b[i].minDate, *b[i].maxDate
+			}
+			c.FutureDates = b[i].odd
+		case FieldNumeric:
+			if b[i].lo != nil && b[i].hi != nil {
+				lo, hi := tukeyFences(*b[i].lo, *b[i].hi)
+				c.LowerFence, c.UpperFence = &lo, &hi
+				v := qualityValue(spec) + "::float8"
+				n := len(outlierArgs) + 1
+				outlierExprs = append(outlierExprs, fmt.Sprintf("COUNT(*) FILTER (WHERE %s < $%d::float8 OR %s > $%d::float8)", v, n, v, n+1))
+				outlierArgs = append(outlierArgs, lo, hi)
+				outlierCols = append(outlierCols, i)
+			}
+		}
+	}
+	if len(outlierExprs) > 0 {
+		counts := make([]int64, len(outlierCols))
+		dest := make([]any, len(counts))
+		for i := range counts {
+			dest[i] = &counts[i]
+		}
+		if err := s.pool.QueryRow(ctx, "SELECT "+strings.Join(outlierExprs, ", ")+from, outlierArgs...).Scan(dest...); err != nil {
+			return nil, fmt.Errorf("query outliers: %w", err)
+		}
+		for j, i := range outlierCols {
+			report.Columns[i].Outliers = counts[j]
+		}
+	}
+
+	for i, spec := range def.FieldSpecs {
+		report.Columns[i].Flags = qualityFlags(spec, report.Columns[i], report.Rows)
+	}
+	return report, nil
+}
+
+// qualityFlags returns the flags of column c of a table with rows rows.
+func qualityFlags(spec FieldSpec, c ColumnQuality, rows int64) []QualityFlag {
+	if rows == 0 {
+		return nil
+	}
+	if c.Empty == rows {
+		return []QualityFlag{QualityEmpty}
+	}
+	var flags []QualityFlag
+	if spec.Required && !spec.AllowEmpty && c.Empty > 0 {
+		flags = append(flags, QualityMissingRequired)
+	}
+	if c.NullRate > QualityHighNullRate {
+		flags = append(flags, QualityHighNulls)
+	}
+	if c.Distinct == 1 && rows-c.Empty > 1 {
+		flags = append(flags, QualityConstant)
+	}
+	if c.Outliers > 0 {
+		flags = append(flags, QualityOutliers)
+	}
+	if c.FutureDates > 0 {
+		flags = append(flags, QualityFutureDates)
+	}
+	if c.UnknownValues > 0 {
+		flags = append(flags, QualityUnknownValues)
+	}
+	return flags
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQualitySelect(t *testing.T) {
+	specs := []FieldSpec{
+		{Name: "Vendor", Type: FieldText},
+		{Name: "Status", Type: FieldEnum, EnumValues: []string{"Open", "Closed"}},
+		{Name: "Amount", Type: FieldNumeric, DBColumn: "amount_usd"},
+		{Name: "Bill Date", Type: FieldDate},
+		{Name: "Paid", Type: FieldBool},
+	}
+	sel, args := qualitySelect(specs, 3)
+
+	if !strings.HasPrefix(sel, "COUNT(*), ") || strings.Count(sel, "COUNT(DISTINCT ") != len(specs) {
+		t.Fatalf("select = %s", sel)
+	}
+	for _, want := range []string{
+		`COUNT(DISTINCT NULLIF("vendor"::text, ''))`,
+		`MAX(length(NULLIF("vendor"::text, '')))::float8`,
+		`COUNT(*) FILTER (WHERE lower(NULLIF("status"::text, '')) <> ALL($3::text[]))`,
+		`percentile_cont(0.75) WITHIN GROUP (ORDER BY "amount_usd"::float8)`,
+		`to_char(MIN("bill_date"), 'YYYY-MM-DD')`,
+		`COUNT(*) FILTER (WHERE "bill_date" > CURRENT_DATE)`,
+		`COUNT(DISTINCT "paid")`,
+	} {
+		if !strings.Contains(sel, want) {
+			t.Errorf("select missing %s", want)
+		}
+	}
+	if want := []any{[]string{"open", "closed"}}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestTukeyFences(t *testing.T) {
+	lo, hi := tukeyFences(10, 20)
+	if lo != -5 || hi != 35 {
+		t.Errorf("fences = %v, %v; want -5, 35", lo, hi)
+	}
+}
+
+func TestQualityFlags(t *testing.T) {
+	required := FieldSpec{Name: "Invoice", Required: true}
+	optional := FieldSpec{Name: "Memo"}
+
+	tests := []struct {
+		name string
+		spec FieldSpec
+		c    ColumnQuality
+		rows int64
+		want []QualityFlag
+	}{
+		{"no rows", required, ColumnQuality{}, 0, nil},
+		{"clean", required, ColumnQuality{Distinct: 10}, 10, nil},
+		{"all empty", required, ColumnQuality{Empty: 10, NullRate: 1}, 10, []QualityFlag{QualityEmpty}},
+		{"missing required", required, ColumnQuality{Empty: 2, NullRate: 0.2, Distinct: 8}, 10, []QualityFlag{QualityMissingRequired}},
+		{"allowed empty", FieldSpec{Required: true, AllowEmpty: true}, ColumnQuality{Empty: 2, NullRate: 0.2, Distinct: 8}, 10, nil},
+		{"mostly empty", optional, ColumnQuality{Empty: 8, NullRate: 0.8, Distinct: 2}, 10, []QualityFlag{QualityHighNulls}},
+		{"constant", optional, ColumnQuality{Distinct: 1}, 10, []QualityFlag{QualityConstant}},
+		{"single row", optional, ColumnQuality{Distinct: 1}, 1, nil},
+		{"odd values", optional, ColumnQuality{Distinct: 5, Outliers: 1, FutureDates: 2, UnknownValues: 3}, 10,
+			[]QualityFlag{QualityOutliers, QualityFutureDates, QualityUnknownValues}},
+	}
+	for _, tt := range tests {
+		if got := qualityFlags(tt.spec, tt.c, tt.rows); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: flags = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
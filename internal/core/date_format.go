@@ -0,0 +1,332 @@
+package core
+
+// date_format.go handles the ambiguities of numeric dates: day-month order
+// and the century of 2-digit years.
+//
+// ToPgDate reads 01/02/2024 as January 2nd, which silently corrupts a file
+// written day first. An upload's DateOptions settle it: with the "dmy"
+// format, and for any date with a 2-digit year, the file's date cells are
+// rewritten as ISO dates before anything else sees the row, so validation,
+// BuildParams and duplicate keys all read them the upload's way. Each date
+// column's dates are also tallied, and the preview, upload result and
+// validation report get a DateWarning for a column whose dates could be
+// read either way (automatic format only) or that has 2-digit years.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DateFormat is how an upload reads numeric dates such as 01/02/2024.
+type DateFormat string
+
+const (
+	DateFormatAuto DateFormat = ""    // Month first, warning about ambiguous columns
+	DateFormatMDY  DateFormat = "mdy" // Month first: 01/02/2024 is January 2nd
+	DateFormatDMY  DateFormat = "dmy" // Day first: 01/02/2024 is February 1st
+)
+
+// Day-first counterparts of the month-first date layouts. Year-first and
+// named-month layouts read the same either way.
+var (
+	dayFirstTwoDigitYearLayouts = []string{
+		"2/1/06", "02/01/06", "2-1-06", "2.1.06", "02.01.06",
+	}
+	dayFirstFourDigitYearLayouts = []string{
+		"2/1/2006", "02/01/2006", "2-1-2006", "02-01-2006", "2.1.2006", "02.01.2006",
+		"2006-01-02", "2006/01/02", "2006.01.02",
+		"Jan 2, 2006", "2 Jan 2006",
+		"20060102",
+	}
+)
+
+// numericDateRegex matches the dates whose first two parts could be either
+// day or month: 1/2/24, 01-02-2024, 01.02.2024.
+var numericDateRegex = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{2}|\d{4})$`)
+
+// DateOptions are an upload's settings for reading dates.
+type DateOptions struct {
+	Format DateFormat // How numeric dates such as 01/02/2024 are read
+
+	// YearPivot overrides the FieldSpec's and DefaultTwoDigitYearPivot for
+	// every date column: 2-digit years more than this many years ahead are
+	// taken as the previous century. 0 keeps the column's pivot.
+	YearPivot int
+}
+
+// Bounds of a 2-digit year pivot.
+const (
+	minYearPivot = 1
+	maxYearPivot = 99
+)
+
+// NewDateOptions validates date options from a request. An empty format or
+// "auto" is DateFormatAuto, and a yearPivot of 0 keeps each column's pivot.
+func NewDateOptions(format string, yearPivot int) (DateOptions, error) {
+	f, err := ParseDateFormat(format)
+	if err != nil {
+		return DateOptions{}, err
+	}
+	if err := checkYearPivot(yearPivot); err != nil {
+		return DateOptions{}, err
+	}
+	return DateOptions{Format: f, YearPivot: yearPivot}, nil
+}
+
+// ParseDateOptions is NewDateOptions for form values; an empty yearPivot
+// is 0.
+func ParseDateOptions(format, yearPivot string) (DateOptions, error) {
+	pivot := 0
+	if yearPivot = strings.TrimSpace(yearPivot); yearPivot != "" {
+		var err error
+		if pivot, err = strconv.Atoi(yearPivot); err != nil {
+			return DateOptions{}, fmt.Errorf("invalid year pivot %q (want %d-%d years)", yearPivot, minYearPivot, maxYearPivot)
+		}
+	}
+	return NewDateOptions(format, pivot)
+}
+
+// validated returns o as NewDateOptions would.
+func (o DateOptions) validated() (DateOptions, error) {
+	return NewDateOptions(string(o.Format), o.YearPivot)
+}
+
+// checkYearPivot checks an upload's or FieldSpec's pivot, where 0 is unset.
+func checkYearPivot(pivot int) error {
+	if pivot != 0 && (pivot < minYearPivot || pivot > maxYearPivot) {
+		return fmt.Errorf("invalid year pivot %d (want %d-%d years)", pivot, minYearPivot, maxYearPivot)
+	}
+	return nil
+}
+
+// yearPivot returns the pivot for 2-digit years in spec's column.
+func (o DateOptions) yearPivot(spec FieldSpec) int {
+	switch {
+	case o.YearPivot != 0:
+		return o.YearPivot
+	case spec.YearPivot != 0:
+		return spec.YearPivot
+	default:
+		return DefaultTwoDigitYearPivot
+	}
+}
+
+// parseDate converts a date cell of spec's column as the upload reads it.
+// twoDigitYear reports whether its year had 2 digits.
+func (o DateOptions) parseDate(s string, spec FieldSpec) (d pgtype.Date, twoDigitYear bool) {
+	if o.Format == DateFormatDMY {
+		return parsePgDate(s, dayFirstFourDigitYearLayouts, dayFirstTwoDigitYearLayouts, o.yearPivot(spec))
+	}
+	return parsePgDate(s, fourDigitYearLayouts, twoDigitYearLayouts, o.yearPivot(spec))
+}
+
+// ParseDateFormat validates a date format from a request. An empty string
+// or "auto" returns DateFormatAuto.
+func ParseDateFormat(s string) (DateFormat, error) {
+	switch f := DateFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case DateFormatAuto, "auto":
+		return DateFormatAuto, nil
+	case DateFormatMDY, DateFormatDMY:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid date format %q (want mdy, dmy, or auto)", s)
+	}
+}
+
+// ToPgDateFormat converts a string to pgtype.Date, reading numeric dates in
+// the given format.
+func ToPgDateFormat(s string, format DateFormat) pgtype.Date {
+	d, _ := DateOptions{Format: format}.parseDate(s, FieldSpec{})
+	return d
+}
+
+// normalizeDates returns row with the date cells that ToPgDate would read
+// differently from the upload rewritten as ISO dates (2024-02-01): every
+// date of a day-first file, and dates with 2-digit years. row itself is not
+// modified. Other dates that don't parse are left for validation, except
+// in a day-first file, where they are an error.
+func normalizeDates(row []string, headerIdx HeaderIndex, def TableDefinition, opts DateOptions) ([]string, error) {
+	out, copied := row, false
+	for _, spec := range def.FieldSpecs {
+		if spec.Type != FieldDate {
+			continue
+		}
+		pos, ok := headerIdx[strings.ToLower(spec.Name)]
+		if !ok || pos >= len(row) {
+			continue
+		}
+		raw := CleanCell(row[pos])
+		if raw == "" {
+			continue
+		}
+		d, twoDigitYear := opts.parseDate(raw, spec)
+		if !d.Valid {
+			if opts.Format == DateFormatDMY {
+				return nil, fmt.Errorf("invalid date for %q: %q (expected DD/MM/YYYY)", spec.Name, raw)
+			}
+			continue
+		}
+		if opts.Format != DateFormatDMY && !twoDigitYear {
+			continue
+		}
+		if !copied {
+			out, copied = append([]string(nil), row...), true
+		}
+		out[pos] = d.Time.Format("2006-01-02")
+	}
+	return out, nil
+}
+
+// Kinds of DateWarning.
+const (
+	DateWarningAmbiguous    = "ambiguous_day_month" // Dates may have day and month swapped
+	DateWarningTwoDigitYear = "two_digit_year"      // Dates with 2-digit years were given a century
+)
+
+// DateWarning flags a date column whose dates may not have been read as
+// meant: numeric dates that may have day and month swapped, or 2-digit
+// years whose century was chosen by the pivot.
+type DateWarning struct {
+	Kind       string `json:"kind"`
+	Column     string `json:"column"`
+	Checked    int    `json:"checked"`    // Numeric dates in the column
+	Ambiguous  int    `json:"ambiguous"`  // Dates that read differently day first, e.g. 01/02/2024
+	DayFirst   int    `json:"dayFirst"`   // Dates only valid day first, e.g. 25/01/2024
+	MonthFirst int    `json:"monthFirst"` // Dates only valid month first, e.g. 01/25/2024
+	Example    string `json:"example"`    // An ambiguous date, or one with a 2-digit year
+	Message    string `json:"message"`
+
+	// Two-digit year warnings only
+	TwoDigitYears int `json:"twoDigitYears,omitempty"` // Dates with 2-digit years
+	YearPivot     int `json:"yearPivot,omitempty"`     // The column's pivot
+	FirstYear     int `json:"firstYear,omitempty"`     // Earliest year they were read as
+	LastYear      int `json:"lastYear,omitempty"`      // Latest year they were read as
+}
+
+// dateStats tallies how the dates of each date column read. A nil
+// *dateStats ignores everything.
+type dateStats struct {
+	opts    DateOptions
+	columns []*dateColumnStats
+}
+
+type dateColumnStats struct {
+	pos       int
+	spec      FieldSpec
+	ambiguity DateWarning // Automatic format only
+	years     DateWarning
+}
+
+// newDateStats returns the tallies for the table's date columns in a file,
+// or nil if it has none.
+func newDateStats(def TableDefinition, headerIdx HeaderIndex, opts DateOptions) *dateStats {
+	d := &dateStats{opts: opts}
+	for _, spec := range def.FieldSpecs {
+		if spec.Type != FieldDate {
+			continue
+		}
+		if pos, ok := headerIdx[strings.ToLower(spec.Name)]; ok {
+			d.columns = append(d.columns, &dateColumnStats{
+				pos:       pos,
+				spec:      spec,
+				ambiguity: DateWarning{Kind: DateWarningAmbiguous, Column: spec.Name},
+				years:     DateWarning{Kind: DateWarningTwoDigitYear, Column: spec.Name, YearPivot: opts.yearPivot(spec)},
+			})
+		}
+	}
+	if len(d.columns) == 0 {
+		return nil
+	}
+	return d
+}
+
+// observe tallies a row's dates.
+func (d *dateStats) observe(row []string) {
+	if d == nil {
+		return
+	}
+	for _, c := range d.columns {
+		if c.pos >= len(row) {
+			continue
+		}
+		raw := CleanCell(row[c.pos])
+
+		if date, twoDigitYear := d.opts.parseDate(raw, c.spec); date.Valid && twoDigitYear {
+			w, year := &c.years, date.Time.Year()
+			w.TwoDigitYears++
+			if w.Example == "" {
+				w.Example = raw
+			}
+			if w.FirstYear == 0 || year < w.FirstYear {
+				w.FirstYear = year
+			}
+			if year > w.LastYear {
+				w.LastYear = year
+			}
+		}
+
+		if d.opts.Format != DateFormatAuto {
+			continue
+		}
+		m := numericDateRegex.FindStringSubmatch(raw)
+		if m == nil {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		second, _ := strconv.Atoi(m[2])
+		if first == 0 || second == 0 {
+			continue
+		}
+
+		w := &c.ambiguity
+		switch {
+		case first <= 12 && second <= 12:
+			w.Checked++
+			if first != second {
+				w.Ambiguous++
+				if w.Example == "" {
+					w.Example = raw
+				}
+			}
+		case first <= 31 && second <= 12:
+			w.Checked++
+			w.DayFirst++
+		case first <= 12 && second <= 31:
+			w.Checked++
+			w.MonthFirst++
+		}
+	}
+}
+
+// warnings returns a warning for each column with ambiguous dates that
+// nothing shows to be month first (either no date in it is only valid
+// month first, or some are only valid day first), and for each column with
+// 2-digit years.
+func (d *dateStats) warnings() []DateWarning {
+	if d == nil {
+		return nil
+	}
+	var warnings []DateWarning
+	for _, c := range d.columns {
+		if w := c.ambiguity; w.Ambiguous > 0 && (w.MonthFirst == 0 || w.DayFirst > 0) {
+			if w.DayFirst > 0 {
+				w.Message = fmt.Sprintf("%s looks day first: %d of %d dates are only valid as DD/MM, and dates such as %s are read as MM/DD. Set the date format to dmy if the file is DD/MM.",
+					w.Column, w.DayFirst, w.Checked, w.Example)
+			} else {
+				w.Message = fmt.Sprintf("%d of %d dates in %s, such as %s, could be MM/DD or DD/MM; they are read as MM/DD. Set the date format to dmy if the file is DD/MM.",
+					w.Ambiguous, w.Checked, w.Column, w.Example)
+			}
+			warnings = append(warnings, w)
+		}
+		if w := c.years; w.TwoDigitYears > 0 {
+			w.Message = fmt.Sprintf("%d dates in %s, such as %s, have 2-digit years; they are read as years %d to %d, taking years more than %d years ahead as the previous century. Set the year pivot to change this.",
+				w.TwoDigitYears, w.Column, w.Example, w.FirstYear, w.LastYear, w.YearPivot)
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseDateFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    DateFormat
+		wantErr bool
+	}{
+		{"", DateFormatAuto, false},
+		{"auto", DateFormatAuto, false},
+		{"mdy", DateFormatMDY, false},
+		{" DMY ", DateFormatDMY, false},
+		{"ymd", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDateFormat(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewDateOptions(t *testing.T) {
+	if o, err := NewDateOptions("dmy", 50); err != nil || o != (DateOptions{Format: DateFormatDMY, YearPivot: 50}) {
+		t.Errorf("NewDateOptions = %+v, %v", o, err)
+	}
+	for _, pivot := range []int{-1, 100} {
+		if _, err := NewDateOptions("", pivot); err == nil {
+			t.Errorf("pivot %d: want error", pivot)
+		}
+	}
+	if o, err := ParseDateOptions("", ""); err != nil || o != (DateOptions{}) {
+		t.Errorf("ParseDateOptions empty = %+v, %v", o, err)
+	}
+	if o, err := ParseDateOptions("mdy", " 5 "); err != nil || o.YearPivot != 5 {
+		t.Errorf("ParseDateOptions = %+v, %v", o, err)
+	}
+	if _, err := ParseDateOptions("", "soon"); err == nil {
+		t.Error("invalid year pivot: want error")
+	}
+}
+
+func TestDateOptionsYearPivot(t *testing.T) {
+	year := time.Now().Year()
+	ahead := func(n int) string { return fmt.Sprintf("%02d", (year+n)%100) }
+	spec := FieldSpec{Name: "Due", Type: FieldDate, YearPivot: 5}
+
+	tests := []struct {
+		name string
+		opts DateOptions
+		spec FieldSpec
+		in   string
+		want int
+	}{
+		{"default keeps 20 years ahead", DateOptions{}, FieldSpec{}, "01/15/" + ahead(20), year + 20},
+		{"default past 20 years ahead", DateOptions{}, FieldSpec{}, "01/15/" + ahead(21), year + 21 - 100},
+		{"field pivot", DateOptions{}, spec, "01/15/" + ahead(6), year + 6 - 100},
+		{"upload pivot beats field", DateOptions{YearPivot: 10}, spec, "01/15/" + ahead(6), year + 6},
+		{"large pivot reaches the next century", DateOptions{YearPivot: 90}, FieldSpec{}, "01/15/" + ahead(80), year + 80},
+		{"day first", DateOptions{Format: DateFormatDMY, YearPivot: 1}, FieldSpec{}, "15/01/" + ahead(2), year + 2 - 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, twoDigitYear := tt.opts.parseDate(tt.in, tt.spec)
+			if !d.Valid || !twoDigitYear || d.Time.Year() != tt.want {
+				t.Errorf("parseDate(%q) = %v, %v; want year %d", tt.in, d.Time, twoDigitYear, tt.want)
+			}
+		})
+	}
+
+	if _, twoDigitYear := (DateOptions{}).parseDate("01/15/2024", FieldSpec{}); twoDigitYear {
+		t.Error("4-digit year reported as 2-digit")
+	}
+}
+
+func TestToPgDateFormat(t *testing.T) {
+	tests := []struct {
+		in     string
+		format DateFormat
+		want   string
+	}{
+		{"01/02/2024", DateFormatAuto, "2024-01-02"},
+		{"01/02/2024", DateFormatMDY, "2024-01-02"},
+		{"01/02/2024", DateFormatDMY, "2024-02-01"},
+		{"25.01.24", DateFormatDMY, "2024-01-25"},
+		{"2024-03-04", DateFormatDMY, "2024-03-04"},
+		{"01/25/2024", DateFormatDMY, ""},
+	}
+	for _, tt := range tests {
+		d := ToPgDateFormat(tt.in, tt.format)
+		got := ""
+		if d.Valid {
+			got = d.Time.Format("2006-01-02")
+		}
+		if got != tt.want {
+			t.Errorf("ToPgDateFormat(%q, %q) = %q, want %q", tt.in, tt.format, got, tt.want)
+		}
+	}
+}
+
+func dateTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "date_invoices", Columns: []string{"Invoice", "Due"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice", Type: FieldText, Required: true},
+			{Name: "Due", Type: FieldDate, Required: true},
+		},
+		BuildParams: func(row []string, _ HeaderIndex, _ pgtype.UUID) (any, error) { return row, nil },
+	}
+}
+
+func TestNormalizeDates(t *testing.T) {
+	def := dateTestTable()
+	headerIdx := MakeHeaderIndex([]string{"Invoice", "Due"})
+
+	row := []string{"I1", "25/01/2024"}
+	got, err := normalizeDates(row, headerIdx, def, DateOptions{Format: DateFormatDMY})
+	if err != nil {
+		t.Fatalf("normalizeDates: %v", err)
+	}
+	if got[1] != "2024-01-25" || row[1] != "25/01/2024" {
+		t.Errorf("got %v, row %v", got, row)
+	}
+
+	if got, _ := normalizeDates(row, headerIdx, def, DateOptions{}); got[1] != "25/01/2024" {
+		t.Errorf("auto changed the row: %v", got)
+	}
+	if got, err := normalizeDates([]string{"I2", ""}, headerIdx, def, DateOptions{Format: DateFormatDMY}); err != nil || got[1] != "" {
+		t.Errorf("empty date: %v, %v", got, err)
+	}
+	// 2-digit years are resolved with the upload's pivot in any format
+	ahead := fmt.Sprintf("%02d", (time.Now().Year()+30)%100)
+	got, err = normalizeDates([]string{"I4", "3/1/" + ahead}, headerIdx, def, DateOptions{YearPivot: 40})
+	if err != nil || got[1] != fmt.Sprintf("%d-03-01", time.Now().Year()+30) {
+		t.Errorf("2-digit year: %v, %v", got, err)
+	}
+	if got, _ := normalizeDates([]string{"I5", "n/a"}, headerIdx, def, DateOptions{}); got[1] != "n/a" {
+		t.Errorf("invalid date changed: %v", got)
+	}
+	if _, err := normalizeDates([]string{"I3", "01/25/2024"}, headerIdx, def, DateOptions{Format: DateFormatDMY}); err == nil || !strings.Contains(err.Error(), "DD/MM/YYYY") {
+		t.Errorf("month-first date in a dmy file: err = %v", err)
+	}
+}
+
+func TestDateStatsWarnings(t *testing.T) {
+	def := dateTestTable()
+	headerIdx := MakeHeaderIndex([]string{"Invoice", "Due"})
+
+	tests := []struct {
+		name  string
+		dates []string
+		want  string // Substring of the warning; empty for none
+	}{
+		{"all ambiguous", []string{"01/02/2024", "03/04/2024", "2024-05-06"}, "2 of 2 dates in Due"},
+		{"some day first", []string{"01/02/2024", "25/01/2024"}, "Due looks day first"},
+		{"month first", []string{"01/02/2024", "01/25/2024"}, ""},
+		{"both", []string{"01/02/2024", "01/25/2024", "25/01/2024"}, "looks day first"},
+		{"same day and month", []string{"01/01/2024", "02/02/2024"}, ""},
+		{"unambiguous only", []string{"2024-01-02", "Jan 2, 2024"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := newDateStats(def, headerIdx, DateOptions{})
+			for _, d := range tt.dates {
+				stats.observe([]string{"I", d})
+			}
+			warnings := stats.warnings()
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("warnings = %+v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0].Message, tt.want) || warnings[0].Example != "01/02/2024" {
+				t.Errorf("warnings = %+v, want %q", warnings, tt.want)
+			}
+		})
+	}
+
+	// An explicit format turns the ambiguity check off
+	stats := newDateStats(def, headerIdx, DateOptions{Format: DateFormatMDY})
+	stats.observe([]string{"I", "01/02/2024"})
+	if w := stats.warnings(); w != nil {
+		t.Errorf("mdy: warnings = %+v", w)
+	}
+	stats = nil
+	stats.observe([]string{"I", "01/02/2024"})
+	if stats.warnings() != nil {
+		t.Error("nil stats returned warnings")
+	}
+}
+
+func TestDateStatsTwoDigitYears(t *testing.T) {
+	def := dateTestTable()
+	headerIdx := MakeHeaderIndex([]string{"Invoice", "Due"})
+	year := time.Now().Year()
+
+	stats := newDateStats(def, headerIdx, DateOptions{YearPivot: 10})
+	for _, d := range []string{"2024-01-02", fmt.Sprintf("12/31/%02d", (year+5)%100), fmt.Sprintf("12/31/%02d", (year+50)%100)} {
+		stats.observe([]string{"I", d})
+	}
+	var w *DateWarning
+	for i, got := range stats.warnings() {
+		if got.Kind == DateWarningTwoDigitYear {
+			w = &stats.warnings()[i]
+		}
+	}
+	if w == nil || w.TwoDigitYears != 2 || w.YearPivot != 10 || w.FirstYear != year+50-100 || w.LastYear != year+5 {
+		t.Fatalf("warning = %+v", w)
+	}
+	if !strings.Contains(w.Message, "more than 10 years ahead") {
+		t.Errorf("message = %q", w.Message)
+	}
+}
+
+func TestValidateFile_DateFormat(t *testing.T) {
+	Register(dateTestTable())
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "date_invoices")
+		registryMu.Unlock()
+	})
+	data := []byte("Invoice,Due\nI1,01/02/2024\nI2,25/01/2024\n")
+
+	report, err := ValidateFile("date_invoices", data, ValidateOptions{})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	// 25/01/2024 is not a month-first date
+	if report.ErrorRows != 1 || len(report.DateWarnings) != 1 || report.DateWarnings[0].DayFirst != 1 {
+		t.Errorf("auto: errors = %d, warnings = %+v", report.ErrorRows, report.DateWarnings)
+	}
+
+	report, err = ValidateFile("date_invoices", data, ValidateOptions{Dates: DateOptions{Format: DateFormatDMY}})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	if report.ErrorRows != 0 || report.DateWarnings != nil || !report.Passed {
+		t.Errorf("dmy: errors = %+v, warnings = %+v", report.Errors, report.DateWarnings)
+	}
+
+	if _, err := ValidateFile("date_invoices", data, ValidateOptions{Dates: DateOptions{Format: "ymd"}}); err == nil {
+		t.Error("invalid date format: want error")
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+// denied_audit.go records operations that were refused before they ran, so
+// security reviews see attempted actions as well as successful ones.
+//
+// Permission, network, read-only, legal hold and upload hook refusals
+// (such as a freeze window) are access_denied entries (medium severity).
+// Rate-limit blocks and uploads failed by the duplicate policy are
+// request_rejected entries (low severity): they are usually honest
+// mistakes, but a burst of them is worth a look.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// DenialKind is why an operation was refused.
+type DenialKind string
+
+const (
+	DenialPermission DenialKind = "permission" // Missing or invalid credentials, or missing role
+	DenialNetwork    DenialKind = "network"    // Client network not allowed by policy
+	DenialReadOnly   DenialKind = "read_only"  // Write to a read-only table
+	DenialPolicy     DenialKind = "policy"     // Upload rejected by a hook, e.g. a freeze window
+	DenialRateLimit  DenialKind = "rate_limit" // Rate limit or auth throttle
+	DenialDuplicate  DenialKind = "duplicate"  // Upload failed by its duplicate policy
+	DenialLegalHold  DenialKind = "legal_hold" // Purge, reset, rollback or delete of held rows
+)
+
+// Denial describes one refused operation.
+type Denial struct {
+	Kind     DenialKind
+	TableKey string // Table targeted, if known
+	UploadID string // Upload refused, if any
+	Method   string // HTTP method, if the refusal came from a request
+	Path     string // Request path, if the refusal came from a request
+	Code     string // Error code returned to the client, e.g. "AUTH_INVALID_KEY"
+	Detail   string // Human-readable explanation
+}
+
+// action returns the audit action recorded for the denial's kind.
+func (k DenialKind) action() AuditAction {
+	switch k {
+	case DenialRateLimit, DenialDuplicate:
+		return ActionRequestRejected
+	default:
+		return ActionAccessDenied
+	}
+}
+
+// deniedAuditParams builds the audit entry for a denial.
+func deniedAuditParams(ctx context.Context, d Denial) AuditLogParams {
+	data := map[string]any{"kind": string(d.Kind)}
+	if d.Code != "" {
+		data["code"] = d.Code
+	}
+	if d.Method != "" {
+		data["method"] = d.Method
+		data["path"] = d.Path
+	}
+
+	reason := "Denied"
+	if d.Method != "" {
+		reason += fmt.Sprintf(" %s %s", d.Method, d.Path)
+	}
+	if d.Detail != "" {
+		reason += ": " + d.Detail
+	}
+
+	return AuditLogParams{
+		Action:    d.Kind.action(),
+		TableKey:  d.TableKey,
+		UploadID:  d.UploadID,
+		RowData:   data,
+		IPAddress: GetIPAddressFromContext(ctx),
+		UserAgent: GetUserAgentFromContext(ctx),
+		Reason:    reason,
+	}
+}
+
+// LogDenied records a refused operation in the audit log. Failures are
+// logged, not returned: the caller is already rejecting the request.
+func (s *Service) LogDenied(ctx context.Context, d Denial) {
+	// The request context ends as soon as the rejection is written
+	ctx, cancel := s.withOpTimeout(context.WithoutCancel(ctx), opMutation)
+	defer cancel()
+
+	if _, err := s.LogAudit(ctx, deniedAuditParams(ctx, d)); err != nil {
+		slog.Error("failed to log denied operation audit",
+			"kind", d.Kind,
+			"code", d.Code,
+			"path", d.Path,
+			"error", err,
+		)
+	}
+}
+
+// logDuplicateRejection records an upload failed by the fail-upload
+// duplicate policy. Other errors are ignored.
+func (s *Service) logDuplicateRejection(ctx context.Context, tableKey, uploadID string, err error) {
+	var dupErr *DuplicateRowError
+	if !errors.As(err, &dupErr) {
+		return
+	}
+	s.LogDenied(ctx, Denial{
+		Kind:     DenialDuplicate,
+		TableKey: tableKey,
+		UploadID: uploadID,
+		Code:     "UPL007",
+		Detail:   dupErr.Error(),
+	})
+}
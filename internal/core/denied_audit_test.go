@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeniedAuditParams(t *testing.T) {
+	ctx := ContextWithUserAgent(ContextWithIPAddress(context.Background(), "10.0.0.1"), "curl/8")
+	p := deniedAuditParams(ctx, Denial{
+		Kind:     DenialPermission,
+		TableKey: "sfdc_customers",
+		Method:   "POST",
+		Path:     "/api/reset/sfdc_customers",
+		Code:     "AUTH_INVALID_KEY",
+		Detail:   "invalid API key",
+	})
+
+	if p.Action != ActionAccessDenied || auditSeverity(p.Action) != SeverityMedium {
+		t.Errorf("action %q severity %q", p.Action, auditSeverity(p.Action))
+	}
+	if p.TableKey != "sfdc_customers" || p.IPAddress != "10.0.0.1" || p.UserAgent != "curl/8" {
+		t.Errorf("params = %+v", p)
+	}
+	if p.RowData["kind"] != "permission" || p.RowData["code"] != "AUTH_INVALID_KEY" || p.RowData["path"] != "/api/reset/sfdc_customers" {
+		t.Errorf("row data = %v", p.RowData)
+	}
+	if p.Reason != "Denied POST /api/reset/sfdc_customers: invalid API key" {
+		t.Errorf("reason = %q", p.Reason)
+	}
+
+	// Rate limits and duplicate-policy failures are low severity rejections
+	for _, kind := range []DenialKind{DenialRateLimit, DenialDuplicate} {
+		p = deniedAuditParams(ctx, Denial{Kind: kind, UploadID: "u1", Detail: "x"})
+		if p.Action != ActionRequestRejected || auditSeverity(p.Action) != SeverityLow || determineSeverity(p.Action) != SeverityLow {
+			t.Errorf("%s: action %q severity %q", kind, p.Action, auditSeverity(p.Action))
+		}
+		if p.RowData["method"] != nil || p.Reason != "Denied: x" || p.UploadID != "u1" {
+			t.Errorf("%s: params = %+v", kind, p)
+		}
+	}
+
+	for _, kind := range []DenialKind{DenialNetwork, DenialReadOnly, DenialPolicy} {
+		if got := kind.action(); got != ActionAccessDenied {
+			t.Errorf("%s: action = %q", kind, got)
+		}
+	}
+}
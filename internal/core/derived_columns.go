@@ -0,0 +1,216 @@
+package core
+
+// derived_columns.go adds columns computed from a row's stored columns,
+// such as a margin from an amount and a cost, to what a table shows. They
+// are not stored, so uploads, edits and import templates never see them.
+//
+// A derived column is a SQL expression or a Go function. A SQL column is
+// computed by the query, so it sorts, filters and, if numeric, aggregates
+// like a stored column. A Go column is computed from each row as it is
+// read: it is shown and exported, but can't be sorted, filtered or
+// aggregated. Neither kind is searched.
+//
+// Derived columns follow the stored columns in GetTableData rows, exports
+// and the table view. SQL exports leave them out, so the file still loads
+// back into the table.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DerivedColumn declares a column computed from a row's other columns.
+type DerivedColumn struct {
+	Name    string        // Column header name
+	Type    FieldType     // Type of the computed value
+	Mask    MaskKind      // Masking policy for anonymized exports (see anonymize.go)
+	Display DisplayFormat // How values are shown in the table view and exports
+
+	// SQL is an expression over the table's database columns, such as
+	// `(amount - cost) / NULLIF(amount, 0)`.
+	SQL string
+
+	// Compute is used when SQL is empty. row holds the stored columns as
+	// read from the database (see RowFloat); the result may be a pgtype
+	// value, a string, bool, int, float64 or time.Time, or nil.
+	Compute func(row TableRow) any
+}
+
+// Spec describes d as a FieldSpec, for code that looks columns up by name.
+func (d DerivedColumn) Spec() FieldSpec {
+	return FieldSpec{Name: d.Name, Type: d.Type, Mask: d.Mask, Display: d.Display}
+}
+
+// DisplayColumns returns the columns of def's rows as GetTableData returns
+// them: the stored columns, then the derived ones.
+func DisplayColumns(def TableDefinition) []string {
+	if len(def.Derived) == 0 {
+		return def.Info.Columns
+	}
+	cols := make([]string, 0, len(def.Info.Columns)+len(def.Derived))
+	cols = append(cols, def.Info.Columns...)
+	for _, d := range def.Derived {
+		cols = append(cols, d.Name)
+	}
+	return cols
+}
+
+// ExportColumns returns the columns e writes for def: DisplayColumns, or
+// only the stored columns for SQL exports.
+func ExportColumns(e Exporter, def TableDefinition) []string {
+	if _, ok := e.(*sqlExporter); ok {
+		return def.Info.Columns
+	}
+	return DisplayColumns(def)
+}
+
+// FilterField returns the FieldSpec of the column named name that rows can
+// be filtered and sorted by, and for a derived column its SQL expression
+// ("" for a stored column). Go-computed columns are not found.
+func FilterField(def TableDefinition, name string) (FieldSpec, string, bool) {
+	for _, spec := range def.FieldSpecs {
+		if strings.EqualFold(spec.Name, name) {
+			return spec, "", true
+		}
+	}
+	if d, ok := derivedColumn(def, name); ok && d.SQL != "" {
+		return d.Spec(), d.SQL, true
+	}
+	return FieldSpec{}, "", false
+}
+
+// derivedColumn returns def's derived column named name.
+func derivedColumn(def TableDefinition, name string) (DerivedColumn, bool) {
+	for _, d := range def.Derived {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return DerivedColumn{}, false
+}
+
+// columnSpecs maps the lowercased name of each of def's columns, stored
+// and derived, to its FieldSpec.
+func columnSpecs(def TableDefinition) map[string]FieldSpec {
+	specs := make(map[string]FieldSpec, len(def.FieldSpecs)+len(def.Derived))
+	for _, spec := range def.FieldSpecs {
+		specs[strings.ToLower(spec.Name)] = spec
+	}
+	for _, d := range def.Derived {
+		specs[strings.ToLower(d.Name)] = d.Spec()
+	}
+	return specs
+}
+
+// columnExpr returns the SQL reading column name of def: its quoted
+// database column, or a derived column's expression in parentheses.
+func columnExpr(def TableDefinition, name string) string {
+	if d, ok := derivedColumn(def, name); ok && d.SQL != "" {
+		return "(" + d.SQL + ")"
+	}
+	return quoteIdentifier(resolveDBColumn(name, def.FieldSpecs))
+}
+
+// selectColumns returns the SELECT list for columns of def and the columns
+// it reads, in order. Go-computed columns are left out and returned
+// separately, for computeDerived.
+func selectColumns(def TableDefinition, columns []string) (exprs, read []string, computed []DerivedColumn) {
+	for _, col := range columns {
+		if d, ok := derivedColumn(def, col); ok && d.SQL == "" {
+			computed = append(computed, d)
+			continue
+		}
+		exprs = append(exprs, columnExpr(def, col))
+		read = append(read, col)
+	}
+	return exprs, read, computed
+}
+
+// computeDerived adds the values of the Go-computed columns to row.
+func computeDerived(row TableRow, computed []DerivedColumn) {
+	for _, d := range computed {
+		row[d.Name] = derivedValue(d.Type, d.Compute(row))
+	}
+}
+
+// derivedValue converts a Compute result to the type the database returns
+// for a column of type t, so it is formatted as a stored value would be.
+func derivedValue(t FieldType, v any) any {
+	switch val := v.(type) {
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return nil
+		}
+		return ToPgNumeric(strconv.FormatFloat(val, 'f', -1, 64))
+	case int:
+		return ToPgNumeric(strconv.Itoa(val))
+	case int64:
+		return ToPgNumeric(strconv.FormatInt(val, 10))
+	case string:
+		if t == FieldNumeric {
+			return ToPgNumeric(val)
+		}
+		return pgtype.Text{String: val, Valid: true}
+	case bool:
+		return pgtype.Bool{Bool: val, Valid: true}
+	case time.Time:
+		return pgtype.Date{Time: val, Valid: !val.IsZero()}
+	}
+	return v
+}
+
+// RowFloat returns the number in row's column col, for Compute functions.
+// It is false for an empty or non-numeric value.
+func RowFloat(row TableRow, col string) (float64, bool) {
+	switch val := row[col].(type) {
+	case pgtype.Numeric:
+		f, err := val.Float64Value()
+		return f.Float64, err == nil && f.Valid
+	case float64:
+		return val, true
+	case int64:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// validateDerived checks def's derived columns.
+func validateDerived(def TableDefinition) error {
+	names := make(map[string]bool, len(def.FieldSpecs)+len(def.Derived))
+	for _, spec := range def.FieldSpecs {
+		names[strings.ToLower(spec.Name)] = true
+	}
+	for _, col := range def.Info.Columns {
+		names[strings.ToLower(col)] = true
+	}
+	for _, d := range def.Derived {
+		if d.Name == "" {
+			return fmt.Errorf("derived column has no name")
+		}
+		if names[strings.ToLower(d.Name)] {
+			return fmt.Errorf("derived column %s: name is already a column", d.Name)
+		}
+		names[strings.ToLower(d.Name)] = true
+
+		if (d.SQL == "") == (d.Compute == nil) {
+			return fmt.Errorf("derived column %s: set one of SQL and Compute", d.Name)
+		}
+		if err := checkMask(d.Mask, d.Type); err != nil {
+			return fmt.Errorf("derived column %s: %w", d.Name, err)
+		}
+		if err := checkDisplayFormat(d.Spec()); err != nil {
+			return fmt.Errorf("derived column %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func derivedTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "deals", Columns: []string{"Deal", "Amount", "Cost"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Deal", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Cost", Type: FieldNumeric, DBColumn: "cost_usd"},
+		},
+		Derived: []DerivedColumn{
+			{Name: "Margin", Type: FieldNumeric, SQL: `("amount" - "cost_usd") / NULLIF("amount", 0)`},
+			{Name: "Profit", Type: FieldNumeric, Compute: func(row TableRow) any {
+				amount, ok1 := RowFloat(row, "Amount")
+				cost, ok2 := RowFloat(row, "Cost")
+				if !ok1 || !ok2 {
+					return nil
+				}
+				return amount - cost
+			}},
+		},
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	def := derivedTestTable()
+
+	cols := DisplayColumns(def)
+	if want := []string{"Deal", "Amount", "Cost", "Margin", "Profit"}; !reflect.DeepEqual(cols, want) {
+		t.Fatalf("DisplayColumns = %v, want %v", cols, want)
+	}
+
+	exprs, read, computed := selectColumns(def, cols)
+	wantExprs := []string{`"deal"`, `"amount"`, `"cost_usd"`, `(("amount" - "cost_usd") / NULLIF("amount", 0))`}
+	if !reflect.DeepEqual(exprs, wantExprs) {
+		t.Errorf("exprs = %v, want %v", exprs, wantExprs)
+	}
+	if want := []string{"Deal", "Amount", "Cost", "Margin"}; !reflect.DeepEqual(read, want) {
+		t.Errorf("read = %v, want %v", read, want)
+	}
+	if len(computed) != 1 || computed[0].Name != "Profit" {
+		t.Fatalf("computed = %+v", computed)
+	}
+
+	row := TableRow{"Amount": ToPgNumeric("120.50"), "Cost": ToPgNumeric("100")}
+	computeDerived(row, computed)
+	if got := FormatExportCell(row["Profit"]); got != "20.50" {
+		t.Errorf("Profit = %q, want 20.50", got)
+	}
+	row = TableRow{"Amount": ToPgNumeric("120.50"), "Cost": nil}
+	computeDerived(row, computed)
+	if row["Profit"] != nil {
+		t.Errorf("Profit without cost = %v, want nil", row["Profit"])
+	}
+}
+
+func TestFilterField(t *testing.T) {
+	def := derivedTestTable()
+
+	spec, expr, ok := FilterField(def, "margin")
+	if !ok || spec.Name != "Margin" || spec.Type != FieldNumeric || expr == "" {
+		t.Errorf("Margin = %+v, %q, %v", spec, expr, ok)
+	}
+	if spec, expr, ok := FilterField(def, "Cost"); !ok || spec.DBColumn != "cost_usd" || expr != "" {
+		t.Errorf("Cost = %+v, %q, %v", spec, expr, ok)
+	}
+	if _, _, ok := FilterField(def, "Profit"); ok {
+		t.Error("Go-computed column is filterable")
+	}
+
+	sql, args, next := buildSingleFilter(ColumnFilter{DBColumn: "margin", Expr: `"a" - "b"`, Operator: OpGreaterEq, Value: "0.2", Type: FieldNumeric}, 3)
+	if sql != `("a" - "b") >= $3` || !reflect.DeepEqual(args, []interface{}{"0.2"}) || next != 4 {
+		t.Errorf("buildSingleFilter = %s, %v, %d", sql, args, next)
+	}
+}
+
+func TestOrderByDerived(t *testing.T) {
+	def := derivedTestTable()
+	sorts := []SortSpec{{Column: "Profit", Dir: "asc"}, {Column: "Margin", Dir: "desc"}}
+	got, gotSorts := orderByClause(def, sorts, 4)
+	if want := `(("amount" - "cost_usd") / NULLIF("amount", 0)) desc`; got != want {
+		t.Errorf("clause = %s, want %s", got, want)
+	}
+	if want := []SortSpec{{Column: "Margin", Dir: "desc"}}; !reflect.DeepEqual(gotSorts, want) {
+		t.Errorf("sorts = %+v, want %+v", gotSorts, want)
+	}
+}
+
+func TestDerivedValue(t *testing.T) {
+	tests := []struct {
+		t    FieldType
+		v    any
+		want string
+	}{
+		{FieldNumeric, 2.5, "2.50"},
+		{FieldNumeric, 3, "3"},
+		{FieldNumeric, "7", "7"},
+		{FieldText, "open", "open"},
+		{FieldBool, true, "Yes"},
+		{FieldNumeric, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := FormatExportCell(derivedValue(tt.t, tt.v)); got != tt.want {
+			t.Errorf("derivedValue(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+	if _, ok := derivedValue(FieldText, "x").(pgtype.Text); !ok {
+		t.Error("text result is not pgtype.Text")
+	}
+}
+
+func TestValidateDerived(t *testing.T) {
+	compute := func(TableRow) any { return nil }
+	tests := []struct {
+		name    string
+		derived []DerivedColumn
+		wantErr bool
+	}{
+		{"valid", []DerivedColumn{{Name: "Margin", SQL: "1"}, {Name: "Profit", Compute: compute}}, false},
+		{"no name", []DerivedColumn{{SQL: "1"}}, true},
+		{"stored name", []DerivedColumn{{Name: "amount", SQL: "1"}}, true},
+		{"twice", []DerivedColumn{{Name: "Margin", SQL: "1"}, {Name: "Margin", SQL: "2"}}, true},
+		{"neither", []DerivedColumn{{Name: "Margin"}}, true},
+		{"both", []DerivedColumn{{Name: "Margin", SQL: "1", Compute: compute}}, true},
+		{"bad display", []DerivedColumn{{Name: "Margin", Type: FieldText, SQL: "1", Display: DisplayFormat{Decimals: 2}}}, true},
+	}
+	for _, tt := range tests {
+		def := derivedTestTable()
+		def.Derived = tt.derived
+		if err := validateDerived(def); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
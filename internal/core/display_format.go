@@ -0,0 +1,195 @@
+package core
+
+// display_format.go formats cell values for people: the table view, the
+// JSON data API and the csv, json and xlsx exports all show a cell as its
+// FieldSpec's DisplayFormat writes it, so a column's formatting is set
+// once, in its table definition. Without hints numbers show whole numbers
+// plainly and others to 2 decimals, dates as YYYY-MM-DD and bools as
+// Yes/No. The sql export writes stored values instead (CellFormatter).
+//
+// Hints only change how a value is shown, never what is stored. An export
+// written with a custom date layout or enum labels may not upload again
+// unchanged.
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DecimalsNone is the DisplayFormat.Decimals of numbers rounded to whole
+// numbers.
+const DecimalsNone = -1
+
+// DisplayFormat holds a column's display formatting hints. The zero value
+// formats as FormatExportCell.
+type DisplayFormat struct {
+	Decimals   int               // FieldNumeric: decimal places; 0 is the default, DecimalsNone none
+	DateLayout string            // FieldDate: Go time layout; "" is 2006-01-02
+	TrueLabel  string            // FieldBool: label of true; "" is Yes
+	FalseLabel string            // FieldBool: label of false; "" is No
+	EnumLabels map[string]string // FieldEnum: display names by stored value; others show as stored
+}
+
+// Format returns v as shown in the table view and exports; NULL is empty.
+func (f DisplayFormat) Format(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+
+	case pgtype.Numeric:
+		if !val.Valid {
+			return ""
+		}
+		return f.formatNumber(val)
+
+	case pgtype.Date:
+		if !val.Valid {
+			return ""
+		}
+		return f.formatDate(val.Time)
+
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return f.formatDate(val)
+
+	case pgtype.Bool:
+		if !val.Valid {
+			return ""
+		}
+		return f.formatBool(val.Bool)
+
+	case bool:
+		return f.formatBool(val)
+
+	case pgtype.Text:
+		if !val.Valid {
+			return ""
+		}
+		return f.formatText(val.String)
+
+	case string:
+		return f.formatText(val)
+
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// formatNumber rounds to Decimals places exactly, half away from zero;
+// the default goes through float64, as numbers always have.
+func (f DisplayFormat) formatNumber(val pgtype.Numeric) string {
+	if f.Decimals != 0 && !val.NaN && val.InfinityModifier == pgtype.Finite {
+		if s, err := val.Value(); err == nil && s != nil {
+			if r, ok := new(big.Rat).SetString(s.(string)); ok {
+				return r.FloatString(max(f.Decimals, 0))
+			}
+		}
+	}
+	n, err := val.Float64Value()
+	if err != nil || !n.Valid {
+		return ""
+	}
+	if n.Float64 == float64(int64(n.Float64)) {
+		return fmt.Sprintf("%.0f", n.Float64)
+	}
+	return fmt.Sprintf("%.2f", n.Float64)
+}
+
+func (f DisplayFormat) formatDate(t time.Time) string {
+	return t.Format(f.dateLayout())
+}
+
+func (f DisplayFormat) dateLayout() string {
+	if f.DateLayout != "" {
+		return f.DateLayout
+	}
+	return exportDateLayout
+}
+
+func (f DisplayFormat) formatBool(b bool) string {
+	if b {
+		return f.trueLabel()
+	}
+	return f.falseLabel()
+}
+
+func (f DisplayFormat) trueLabel() string {
+	if f.TrueLabel != "" {
+		return f.TrueLabel
+	}
+	return "Yes"
+}
+
+func (f DisplayFormat) falseLabel() string {
+	if f.FalseLabel != "" {
+		return f.FalseLabel
+	}
+	return "No"
+}
+
+func (f DisplayFormat) formatText(s string) string {
+	if label, ok := f.EnumLabels[s]; ok {
+		return label
+	}
+	return s
+}
+
+// parseBool reports the value of a bool formatted with f, or the stored
+// forms true and false; ok is false for anything else.
+func (f DisplayFormat) parseBool(s string) (b, ok bool) {
+	switch {
+	case strings.EqualFold(s, f.trueLabel()), strings.EqualFold(s, "true"):
+		return true, true
+	case strings.EqualFold(s, f.falseLabel()), strings.EqualFold(s, "false"):
+		return false, true
+	}
+	return false, false
+}
+
+// ColumnFormats returns the DisplayFormat of each column, the zero value
+// for columns without a FieldSpec or derived column.
+func ColumnFormats(def TableDefinition, columns []string) []DisplayFormat {
+	specs := columnSpecs(def)
+	formats := make([]DisplayFormat, len(columns))
+	for i, col := range columns {
+		formats[i] = specs[strings.ToLower(col)].Display
+	}
+	return formats
+}
+
+// checkDisplayFormat reports display hints that don't fit their field.
+func checkDisplayFormat(spec FieldSpec) error {
+	f := spec.Display
+	if f.Decimals < DecimalsNone || f.Decimals > 10 {
+		return fmt.Errorf("display decimals must be DecimalsNone or 0 to 10, not %d", f.Decimals)
+	}
+	if f.Decimals != 0 && spec.Type != FieldNumeric {
+		return fmt.Errorf("display decimals need a numeric field")
+	}
+	if f.DateLayout != "" && spec.Type != FieldDate {
+		return fmt.Errorf("a display date layout needs a date field")
+	}
+	if (f.TrueLabel != "" || f.FalseLabel != "") && spec.Type != FieldBool {
+		return fmt.Errorf("display bool labels need a bool field")
+	}
+	if strings.EqualFold(f.trueLabel(), f.falseLabel()) {
+		return fmt.Errorf("display bool labels must differ")
+	}
+	if len(f.EnumLabels) > 0 {
+		if spec.Type != FieldEnum {
+			return fmt.Errorf("display enum labels need an enum field")
+		}
+		for value := range f.EnumLabels {
+			if !containsFold(spec.EnumValues, value) {
+				return fmt.Errorf("display label for %q, which is not one of the enum values", value)
+			}
+		}
+	}
+	return nil
+}
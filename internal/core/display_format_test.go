@@ -0,0 +1,93 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestDisplayFormat(t *testing.T) {
+	num := func(s string) pgtype.Numeric {
+		var n pgtype.Numeric
+		n.Scan(s)
+		return n
+	}
+	date := pgtype.Date{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	labels := DisplayFormat{
+		TrueLabel:  "Active",
+		FalseLabel: "Inactive",
+		EnumLabels: map[string]string{"cw": "Closed Won"},
+	}
+
+	tests := []struct {
+		name   string
+		format DisplayFormat
+		v      any
+		want   string
+	}{
+		{"default whole number", DisplayFormat{}, num("1200"), "1200"},
+		{"default decimals", DisplayFormat{}, num("1200.456"), "1200.46"},
+		{"fixed decimals", DisplayFormat{Decimals: 4}, num("1200.5"), "1200.5000"},
+		{"fixed decimals on whole number", DisplayFormat{Decimals: 2}, num("3"), "3.00"},
+		{"no decimals", DisplayFormat{Decimals: DecimalsNone}, num("1200.6"), "1201"},
+		{"null number", DisplayFormat{Decimals: 2}, pgtype.Numeric{}, ""},
+		{"default date", DisplayFormat{}, date, "2024-03-01"},
+		{"date layout", DisplayFormat{DateLayout: "01/02/2006"}, date, "03/01/2024"},
+		{"date layout on time", DisplayFormat{DateLayout: "Jan 2, 2006"}, date.Time, "Mar 1, 2024"},
+		{"default bool", DisplayFormat{}, pgtype.Bool{Bool: true, Valid: true}, "Yes"},
+		{"true label", labels, pgtype.Bool{Bool: true, Valid: true}, "Active"},
+		{"false label", labels, false, "Inactive"},
+		{"null bool", labels, pgtype.Bool{}, ""},
+		{"enum label", labels, pgtype.Text{String: "cw", Valid: true}, "Closed Won"},
+		{"enum without label", labels, "open", "open"},
+		{"nil", labels, nil, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Format(tt.v); got != tt.want {
+			t.Errorf("%s: Format(%v) = %q, want %q", tt.name, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestColumnFormats(t *testing.T) {
+	def := exportTestTable()
+	def.FieldSpecs[1].Display = DisplayFormat{Decimals: 2}
+	formats := ColumnFormats(def, []string{"Vendor", "amount", "Missing"})
+	if len(formats) != 3 || formats[1].Decimals != 2 || formats[0].Decimals != 0 || formats[2].Decimals != 0 {
+		t.Errorf("formats = %+v", formats)
+	}
+}
+
+func TestCheckDisplayFormat(t *testing.T) {
+	enum := []string{"open", "closed"}
+	valid := []FieldSpec{
+		{Type: FieldText},
+		{Type: FieldNumeric, Display: DisplayFormat{Decimals: 4}},
+		{Type: FieldNumeric, Display: DisplayFormat{Decimals: DecimalsNone}},
+		{Type: FieldDate, Display: DisplayFormat{DateLayout: "02 Jan 2006"}},
+		{Type: FieldBool, Display: DisplayFormat{TrueLabel: "On"}},
+		{Type: FieldEnum, EnumValues: enum, Display: DisplayFormat{EnumLabels: map[string]string{"open": "Open"}}},
+	}
+	for _, spec := range valid {
+		if err := checkDisplayFormat(spec); err != nil {
+			t.Errorf("%+v: %v", spec, err)
+		}
+	}
+
+	invalid := []FieldSpec{
+		{Type: FieldNumeric, Display: DisplayFormat{Decimals: -2}},
+		{Type: FieldNumeric, Display: DisplayFormat{Decimals: 11}},
+		{Type: FieldText, Display: DisplayFormat{Decimals: 2}},
+		{Type: FieldNumeric, Display: DisplayFormat{DateLayout: "2006"}},
+		{Type: FieldText, Display: DisplayFormat{TrueLabel: "On"}},
+		{Type: FieldBool, Display: DisplayFormat{TrueLabel: "no"}},
+		{Type: FieldText, Display: DisplayFormat{EnumLabels: map[string]string{"open": "Open"}}},
+		{Type: FieldEnum, EnumValues: enum, Display: DisplayFormat{EnumLabels: map[string]string{"won": "Won"}}},
+	}
+	for _, spec := range invalid {
+		if err := checkDisplayFormat(spec); err == nil {
+			t.Errorf("%+v: no error", spec)
+		}
+	}
+}
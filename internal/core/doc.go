@@ -39,6 +39,25 @@
 //  3. Rows are validated and inserted in batches of [Config.Upload.BatchSize]
 //  4. Progress is broadcast to subscribers via [Service.SubscribeProgress]
 //
+// [Service.StartUploadFromURL] feeds the same pipeline from an https://, s3://
+// or gs:// URL, for exports too large to pass through a browser. Other schemes
+// can be added with [RegisterSourceProvider].
+//
+// # Embedding
+//
+// Transports and tests should depend on the interfaces Service implements
+// rather than on Service itself:
+//
+//   - [Uploader]: starting, tracking and pre-checking uploads
+//   - [TableQuerier]: table rows, summaries and upload history
+//   - [Auditor]: writing and reading the audit log
+//   - [TemplateStore]: import templates
+//
+// These are the supported embedding API: their methods keep their
+// signatures between releases, while Service's other methods (resets,
+// rollbacks, admin operations) may change. The web server uses them for
+// its handlers, and web.Server.SetServices swaps in other implementations.
+//
 // # Error Handling
 //
 // Technical errors are mapped to user-friendly messages using [MapError].
@@ -46,8 +65,8 @@
 //
 //   - DB001-DB007: Database errors (duplicates, constraints, connections)
 //   - VAL001-VAL006: Validation errors (formats, missing columns)
-//   - FILE001-FILE005: File errors (size, encoding, format)
-//   - UPL001-UPL005: Upload errors (cancelled, timeout, not found)
+//   - FILE001-FILE009: File errors (size, encoding, format, table limits)
+//   - UPL001-UPL009: Upload errors (cancelled, timeout, not found, daily limit, quotas)
 //
 // # Audit Logging
 //
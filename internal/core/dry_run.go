@@ -0,0 +1,210 @@
+package core
+
+// dry_run.go runs an upload end to end without keeping anything.
+//
+// AnalyzeUpload re-implements validation and samples its findings, which is
+// enough for a quick look but can disagree with what the upload actually
+// does (database constraint errors, for instance, only show up on insert).
+// A dry run instead drives the real upload pipeline - header detection,
+// buildAndValidate, batch inserts with row-by-row fallback, replace and
+// upsert handling - inside a transaction that is always rolled back, and
+// reports every failed row rather than a sample.
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// dryRunKeyChunk bounds how many keys go into one CheckDuplicates query
+// (composite keys use one bind parameter per key part).
+const dryRunKeyChunk = 1000
+
+// DryRunSummary contains the counts a real upload of the file would produce.
+type DryRunSummary struct {
+	TotalRows       int `json:"totalRows"`
+	Inserted        int `json:"inserted"`        // Rows that would be inserted as new
+	Updated         int `json:"updated"`         // Upsert only: rows that would replace an existing row
+	Replaced        int `json:"replaced"`        // Replace only: existing rows that would be deleted
+	Deleted         int `json:"deleted"`         // Delete only: rows whose key the file lists
+	ErrorRows       int `json:"errorRows"`       // Rows that would be skipped
+	DuplicateInFile int `json:"duplicateInFile"` // Extra occurrences of keys repeated in the file
+	ExistingRows    int `json:"existingRows"`    // Valid rows whose key is already in the table
+}
+
+// DryRunRowError is one row that a real upload would skip.
+type DryRunRowError struct {
+	LineNumber int      `json:"lineNumber"`
+	Reason     string   `json:"reason"`
+	Values     []string `json:"values"`
+}
+
+// DryRunExistingRow is a valid row whose unique key is already in the table.
+// Insert mode would add a second row with that key; upsert would replace it.
+type DryRunExistingRow struct {
+	LineNumber int    `json:"lineNumber"`
+	RowKey     string `json:"rowKey"`
+}
+
+// DryRunReport is the complete result of a dry-run upload.
+type DryRunReport struct {
+	TableKey         string              `json:"tableKey"`
+	Mode             UploadMode          `json:"mode"`
+	DateWarnings     []DateWarning       `json:"dateWarnings,omitempty"`
+	Summary          DryRunSummary       `json:"summary"`
+	Errors           []DryRunRowError    `json:"errors"`
+	Duplicates       []DuplicatePreview  `json:"duplicates"`
+	Existing         []DryRunExistingRow `json:"existing"`
+	ProcessingTimeMs int64               `json:"processingTimeMs"`
+}
+
+// DryRunUpload runs the full upload pipeline for fileData inside a
+// transaction that is rolled back, and reports every row that would fail,
+// every key repeated in the file, and every key already in the table.
+// Nothing is written: no data rows, upload record, failed rows or audit
+// entry. Like a real upload it occupies an upload slot while running,
+// ContextWithImportColumns limits it to some of the table's columns, and
+// ContextWithMappingConstants fills some with constants.
+func (s *Service) DryRunUpload(ctx context.Context, tableKey string, fileData []byte, mapping map[string]int, mode UploadMode, dateOpts DateOptions) (*DryRunReport, error) {
+	startTime := time.Now()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	mode, err := resolveUploadMode(def, mode)
+	if err != nil {
+		return nil, err
+	}
+	dateOpts, err = dateOpts.validated()
+	if err != nil {
+		return nil, err
+	}
+	def, omitted, err := s.selectImportColumns(ctx, def, mode)
+	if err != nil {
+		return nil, err
+	}
+	constants, err := uploadConstants(ctx, def, mapping, dateOpts)
+	if err != nil {
+		return nil, err
+	}
+	if limit := def.Limits.MaxFileBytes; limit > 0 && int64(len(fileData)) > limit {
+		return nil, fmt.Errorf("file exceeds table size limit for %s: %d bytes (max %d)",
+			tableKey, len(fileData), limit)
+	}
+
+	fileData, err = parquetAsCSV("", fileData, def, len(mapping) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireUploadSlot(ctx, tableKey, GetUploaderFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	runCtx, cancel := context.WithTimeout(ctx, s.UploadTimeout())
+	defer cancel()
+
+	upload := &activeUpload{
+		ID:         "dry-run",
+		TableKey:   tableKey,
+		Mapping:    mapping,
+		Mode:       mode,
+		Dates:      dateOpts,
+		Omitted:    omitted,
+		Transforms: templateTransforms(ctx),
+		Constants:  constants,
+		DryRun:     true,
+		RowKeys:    make(map[string][]int),
+	}
+	result := s.processStreamingRecords(runCtx, upload, def, toUTF8(fileData), "", startTime)
+	if result.Error != "" {
+		return nil, fmt.Errorf("dry run: %s", result.Error)
+	}
+
+	report := &DryRunReport{
+		TableKey:     tableKey,
+		Mode:         mode,
+		DateWarnings: result.DateWarnings,
+		Summary: DryRunSummary{
+			TotalRows: result.TotalRows,
+			Inserted:  result.Inserted,
+			Updated:   result.Updated,
+			Replaced:  result.Replaced,
+			Deleted:   result.Deleted,
+			ErrorRows: len(result.FailedRows),
+		},
+		Errors:     make([]DryRunRowError, 0, len(result.FailedRows)),
+		Duplicates: []DuplicatePreview{},
+		Existing:   []DryRunExistingRow{},
+	}
+
+	failedLines := make(map[int]bool, len(result.FailedRows))
+	for _, fr := range result.FailedRows {
+		failedLines[fr.LineNumber] = true
+		report.Errors = append(report.Errors, DryRunRowError{
+			LineNumber: fr.LineNumber,
+			Reason:     fr.Reason,
+			Values:     fr.Data,
+		})
+	}
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].LineNumber < report.Errors[j].LineNumber
+	})
+
+	// Keys are recorded when a row validates; drop rows that then failed
+	// to insert
+	keys := make([]string, 0, len(upload.RowKeys))
+	for key, lines := range upload.RowKeys {
+		kept := lines[:0]
+		for _, line := range lines {
+			if !failedLines[line] {
+				kept = append(kept, line)
+			}
+		}
+		if len(kept) == 0 {
+			delete(upload.RowKeys, key)
+			continue
+		}
+		upload.RowKeys[key] = kept
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if lines := upload.RowKeys[key]; len(lines) > 1 {
+			report.Summary.DuplicateInFile += len(lines) - 1
+			report.Duplicates = append(report.Duplicates, DuplicatePreview{RowKey: key, LineNumbers: lines})
+		}
+	}
+
+	// Replace mode empties the table first, so existing keys don't matter;
+	// a delete upload reports keys it did not find as errors instead
+	if mode != UploadModeReplace && mode != UploadModeDelete {
+		for start := 0; start < len(keys); start += dryRunKeyChunk {
+			end := min(start+dryRunKeyChunk, len(keys))
+			existing, err := s.CheckDuplicates(ctx, tableKey, keys[start:end])
+			if err != nil {
+				return nil, fmt.Errorf("check existing keys: %w", err)
+			}
+			for _, key := range existing {
+				for _, line := range upload.RowKeys[key] {
+					report.Existing = append(report.Existing, DryRunExistingRow{LineNumber: line, RowKey: key})
+				}
+			}
+		}
+		sort.Slice(report.Existing, func(i, j int) bool {
+			return report.Existing[i].LineNumber < report.Existing[j].LineNumber
+		})
+		report.Summary.ExistingRows = len(report.Existing)
+	}
+
+	report.ProcessingTimeMs = time.Since(startTime).Milliseconds()
+	return report, nil
+}
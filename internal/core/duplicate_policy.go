@@ -0,0 +1,393 @@
+package core
+
+// duplicate_policy.go enforces an upload's DuplicatePolicy.
+//
+// Keys are compared on their database values, not the CSV text: each key
+// part is cleaned, normalized and parsed with the column's FieldSpec type,
+// so "1/2/24" and "2024-01-02" are the same date key. Rows with an empty key
+// part are never duplicates, as in AnalyzeUpload.
+//
+// Duplicates within the file are found by remembering every key seen so
+// far, so an upload with a policy other than keep-both holds one map entry
+// per distinct key. Duplicates of existing rows are found with one query
+// per batch inside the upload transaction, which in replace mode already
+// sees the table empty. Overwriting existing rows is left to the upsert
+// step that runs before commit (see upload_mode.go).
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	db "github.com/JonMunkholm/TUI/internal/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ParseDuplicatePolicy validates a policy from a request. An empty string
+// returns an empty policy, meaning the upload mode's default.
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "", DuplicateKeepBoth, DuplicateSkip, DuplicateOverwrite, DuplicateFailUpload:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid duplicate policy %q (want skip, overwrite, fail-upload, or keep-both)", s)
+	}
+}
+
+// resolveDuplicatePolicy picks the requested policy, else the mode's
+// default, and checks it against the mode. Overwrite in insert mode
+// overwrites existing rows, which is upsert, so the mode is upgraded.
+// Delete mode inserts nothing, so it takes no policy but keep-both.
+func resolveDuplicatePolicy(def TableDefinition, mode UploadMode, requested DuplicatePolicy) (UploadMode, DuplicatePolicy, error) {
+	if _, err := ParseDuplicatePolicy(string(requested)); err != nil {
+		return "", "", err
+	}
+	policy := requested
+	if policy == "" {
+		policy = DuplicateKeepBoth
+		if mode == UploadModeUpsert {
+			policy = DuplicateOverwrite
+		}
+	}
+	if mode == UploadModeDelete && policy != DuplicateKeepBoth {
+		return "", "", fmt.Errorf("delete mode inserts no rows; the %s duplicate policy does not apply", policy)
+	}
+	if policy == DuplicateKeepBoth {
+		if mode == UploadModeUpsert {
+			return "", "", fmt.Errorf("upsert mode overwrites duplicates; use insert mode to keep both")
+		}
+		return mode, policy, nil
+	}
+
+	if len(def.Info.UniqueKey) == 0 {
+		return "", "", fmt.Errorf("duplicate policy %s requires a unique key, and %s has none", policy, def.Info.Key)
+	}
+	switch {
+	case mode == UploadModeUpsert && policy != DuplicateOverwrite:
+		return "", "", fmt.Errorf("upsert mode overwrites duplicates; use insert mode with the %s policy", policy)
+	case mode == UploadModeInsert && policy == DuplicateOverwrite:
+		mode = UploadModeUpsert
+	}
+	return mode, policy, nil
+}
+
+// DuplicateRowError is returned by an upload with the fail-upload policy.
+type DuplicateRowError struct {
+	LineNumber int
+	Key        string // Key parts joined with "|"
+	FirstLine  int    // Earlier line with the key; 0 if the key is already in the table
+}
+
+func (e *DuplicateRowError) Error() string {
+	if e.FirstLine > 0 {
+		return fmt.Sprintf("key %s on line %d repeats line %d; upload failed by duplicate policy", e.Key, e.LineNumber, e.FirstLine)
+	}
+	return fmt.Sprintf("key %s on line %d is already in the table; upload failed by duplicate policy", e.Key, e.LineNumber)
+}
+
+// duplicateGuard applies a DuplicatePolicy to an upload's batches.
+type duplicateGuard struct {
+	policy    DuplicatePolicy
+	def       TableDefinition
+	uploadID  pgtype.UUID
+	headerIdx HeaderIndex
+	specs     []FieldSpec // Key column specs, in UniqueKey order
+	seen      map[string]int
+
+	skipped int // Rows dropped by the skip policy
+	inFile  int // Earlier rows of the file replaced under overwrite
+}
+
+// newDuplicateGuard returns a guard for the upload, or nil when the policy
+// needs no checks.
+func newDuplicateGuard(def TableDefinition, policy DuplicatePolicy, uploadID pgtype.UUID, headerIdx HeaderIndex) *duplicateGuard {
+	if policy == "" || policy == DuplicateKeepBoth || len(def.Info.UniqueKey) == 0 {
+		return nil
+	}
+	return &duplicateGuard{
+		policy:    policy,
+		def:       def,
+		uploadID:  uploadID,
+		headerIdx: headerIdx,
+		specs:     keySpecs(def),
+		seen:      make(map[string]int),
+	}
+}
+
+// keySpecs returns the FieldSpecs of def's unique key columns, in
+// UniqueKey order. Columns without a spec are compared as text.
+func keySpecs(def TableDefinition) []FieldSpec {
+	specs := make([]FieldSpec, len(def.Info.UniqueKey))
+	for i, col := range def.Info.UniqueKey {
+		specs[i] = FieldSpec{Name: col, Type: FieldText}
+		for _, spec := range def.FieldSpecs {
+			if strings.EqualFold(spec.Name, col) {
+				specs[i] = spec
+				break
+			}
+		}
+	}
+	return specs
+}
+
+// Skipped returns the rows dropped by the skip policy. Safe on nil.
+func (g *duplicateGuard) Skipped() int {
+	if g == nil {
+		return 0
+	}
+	return g.skipped
+}
+
+// InFile returns the earlier rows replaced under overwrite. Safe on nil.
+func (g *duplicateGuard) InFile() int {
+	if g == nil {
+		return 0
+	}
+	return g.inFile
+}
+
+// canonicalKeyPart returns a key part as the text of its database value,
+// or false if it is empty or does not parse.
+func canonicalKeyPart(spec FieldSpec, raw string) (string, bool) {
+	v := CleanCell(raw)
+	if spec.Normalizer != nil {
+		v = spec.Normalizer(v)
+	}
+	switch spec.Type {
+	case FieldDate:
+		d := ToPgDate(v)
+		return d.Time.Format("2006-01-02"), d.Valid
+	case FieldNumeric:
+		n := ToPgNumeric(v)
+		if !n.Valid {
+			return "", false
+		}
+		val, err := n.Value()
+		s, ok := val.(string)
+		return s, err == nil && ok
+	case FieldBool:
+		b := ToPgBool(v)
+		return fmt.Sprint(b.Bool), b.Valid
+	default:
+		t := ToPgText(v)
+		return t.String, t.Valid
+	}
+}
+
+// rowKey returns the canonical key parts of a row.
+func (g *duplicateGuard) rowKey(row []string) ([]string, bool) {
+	return rowKeyParts(g.specs, g.headerIdx, row)
+}
+
+// rowKeyParts returns the canonical key parts of a CSV row, or false if a
+// part is missing, empty or does not parse.
+func rowKeyParts(specs []FieldSpec, headerIdx HeaderIndex, row []string) ([]string, bool) {
+	parts := make([]string, len(specs))
+	for i, spec := range specs {
+		pos, ok := headerIdx[strings.ToLower(spec.Name)]
+		if !ok || pos >= len(row) {
+			return nil, false
+		}
+		if parts[i], ok = canonicalKeyPart(spec, row[pos]); !ok {
+			return nil, false
+		}
+	}
+	return parts, true
+}
+
+// filter applies the policy to batch within tx before it is inserted. It
+// returns the rows to insert, compacted in place, and how many rows this
+// upload had already inserted that were deleted because a later row
+// overwrites them.
+func (g *duplicateGuard) filter(ctx context.Context, tx pgx.Tx, batch []validatedRow) ([]validatedRow, int, error) {
+	drop := make([]bool, len(batch))
+	keys := make([][]string, len(batch))
+	var earlier [][]string          // Overwrite: keys inserted by previous batches
+	inBatch := make(map[string]int) // Overwrite: key -> index in batch
+
+	for i, vr := range batch {
+		parts, ok := g.rowKey(vr.row)
+		if !ok {
+			continue
+		}
+		keys[i] = parts
+		k := strings.Join(parts, "\x00")
+		first, seen := g.seen[k]
+
+		switch g.policy {
+		case DuplicateOverwrite:
+			if prev, ok := inBatch[k]; ok {
+				drop[prev] = true
+				g.inFile++
+			} else if seen {
+				earlier = append(earlier, parts)
+			}
+			inBatch[k] = i
+			g.seen[k] = vr.lineNum
+		case DuplicateSkip:
+			if seen {
+				drop[i] = true
+				g.skipped++
+				continue
+			}
+			g.seen[k] = vr.lineNum
+		case DuplicateFailUpload:
+			if seen {
+				return nil, 0, &DuplicateRowError{LineNumber: vr.lineNum, Key: strings.Join(parts, "|"), FirstLine: first}
+			}
+			g.seen[k] = vr.lineNum
+		}
+	}
+
+	deleted := 0
+	if g.policy == DuplicateOverwrite {
+		if len(earlier) > 0 {
+			tag, err := tx.Exec(ctx, deleteEarlierSQL(g.def), keyArgs(g.uploadID, earlier)...)
+			if err != nil {
+				return nil, 0, fmt.Errorf("overwrite earlier rows: %w", err)
+			}
+			deleted = int(tag.RowsAffected())
+			g.inFile += deleted
+		}
+	} else if err := g.dropExisting(ctx, tx, batch, keys, drop); err != nil {
+		return nil, 0, err
+	}
+
+	kept := batch[:0]
+	for i, vr := range batch {
+		if !drop[i] {
+			kept = append(kept, vr)
+		}
+	}
+	return kept, deleted, nil
+}
+
+// dropExisting marks rows whose key is already in the table from an
+// earlier upload: skipped under skip, an error under fail-upload.
+func (g *duplicateGuard) dropExisting(ctx context.Context, tx pgx.Tx, batch []validatedRow, keys [][]string, drop []bool) error {
+	var check [][]string
+	var index []int
+	for i, parts := range keys {
+		if parts != nil && !drop[i] {
+			check = append(check, parts)
+			index = append(index, i)
+		}
+	}
+	if len(check) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, existingKeysSQL(g.def), keyArgs(g.uploadID, check)...)
+	if err != nil {
+		return fmt.Errorf("check existing keys: %w", err)
+	}
+	var found []int
+	for rows.Next() {
+		var ord int64
+		if err := rows.Scan(&ord); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan existing key: %w", err)
+		}
+		found = append(found, index[ord-1])
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("check existing keys: %w", err)
+	}
+
+	for _, i := range found {
+		if g.policy == DuplicateFailUpload {
+			return &DuplicateRowError{LineNumber: batch[i].lineNum, Key: strings.Join(keys[i], "|")}
+		}
+		drop[i] = true
+		g.skipped++
+	}
+	return nil
+}
+
+// keyArgs returns the upload ID followed by one text array per key column.
+func keyArgs(uploadID pgtype.UUID, keys [][]string) []any {
+	args := []any{uploadID}
+	for col := range keys[0] {
+		values := make([]string, len(keys))
+		for i, parts := range keys {
+			values[i] = parts[col]
+		}
+		args = append(args, values)
+	}
+	return args
+}
+
+// keyJoin returns the unnest source and match conditions comparing table
+// alias t with the key arrays $2, $3, ... cast to each column's type.
+func keyJoin(def TableDefinition) (unnest string, conds []string) {
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	arrays := make([]string, len(keyCols))
+	names := make([]string, len(keyCols))
+	conds = make([]string, len(keyCols))
+	for i, col := range keyCols {
+		typ := "TEXT"
+		for _, spec := range def.FieldSpecs {
+			if strings.EqualFold(spec.Name, def.Info.UniqueKey[i]) {
+				typ = fieldSQLType(spec.Type)
+				break
+			}
+		}
+		arrays[i] = fmt.Sprintf("$%d::text[]", i+2)
+		names[i] = fmt.Sprintf("k%d", i+1)
+		conds[i] = fmt.Sprintf("t.%s = k.k%d::%s", quoteIdentifier(col), i+1, typ)
+	}
+	return fmt.Sprintf("unnest(%s) WITH ORDINALITY AS k(%s, i)", strings.Join(arrays, ", "), strings.Join(names, ", ")), conds
+}
+
+// existingKeysSQL returns the ordinals (1-based) of the keys in $2... that
+// are already in the table from an upload other than $1. Soft-deleted rows
+// don't count.
+func existingKeysSQL(def TableDefinition) string {
+	unnest, conds := keyJoin(def)
+	if live := liveRowsCondition(def, "t"); live != "" {
+		conds = append(conds, live)
+	}
+	return fmt.Sprintf(`SELECT k.i FROM %s
+WHERE EXISTS (
+	SELECT 1 FROM %s AS t
+	WHERE t.upload_id IS DISTINCT FROM $1
+	  AND %s
+)`, unnest, quoteIdentifier(def.Info.Key), strings.Join(conds, "\n\t  AND "))
+}
+
+// deleteEarlierSQL deletes rows of upload $1 whose key is one of $2...
+func deleteEarlierSQL(def TableDefinition) string {
+	unnest, conds := keyJoin(def)
+	return fmt.Sprintf(`DELETE FROM %s AS t
+USING %s
+WHERE t.upload_id = $1
+  AND %s`, quoteIdentifier(def.Info.Key), unnest, strings.Join(conds, "\n  AND "))
+}
+
+// recordDuplicateRows stores how many rows the duplicate policy skipped or
+// replaced on the upload record. The policy itself is recorded when the
+// upload starts (see linkUploadRecord).
+func recordDuplicateRows(ctx context.Context, q db.DBTX, uploadID pgtype.UUID, result *UploadResult) error {
+	if _, err := q.Exec(ctx, `UPDATE csv_uploads SET rows_duplicate = $2 WHERE id = $1`,
+		uploadID, result.DupSkipped+result.DupInFile); err != nil {
+		return fmt.Errorf("record duplicate rows: %w", err)
+	}
+	return nil
+}
+
+// duplicateAuditSuffix describes the duplicate policy for the audit log.
+func duplicateAuditSuffix(result *UploadResult) string {
+	if result.Duplicates == "" {
+		return ""
+	}
+	switch {
+	case result.DupSkipped > 0:
+		return fmt.Sprintf(" [duplicates: %s, %d skipped]", result.Duplicates, result.DupSkipped)
+	case result.DupInFile > 0:
+		return fmt.Sprintf(" [duplicates: %s, %d replaced within file]", result.Duplicates, result.DupInFile)
+	default:
+		return fmt.Sprintf(" [duplicates: %s]", result.Duplicates)
+	}
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func dupTestDef() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "dup_orders", UniqueKey: []string{"Order ID", "Close Date"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Order ID", Type: FieldText},
+			{Name: "Close Date", DBColumn: "closed_on", Type: FieldDate},
+			{Name: "Amount", Type: FieldNumeric},
+		},
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, s := range []string{"", "skip", " Overwrite ", "fail-upload", "keep-both"} {
+		if _, err := ParseDuplicatePolicy(s); err != nil {
+			t.Errorf("ParseDuplicatePolicy(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("ignore"); err == nil {
+		t.Error("ParseDuplicatePolicy(ignore): expected error")
+	}
+}
+
+func TestResolveDuplicatePolicy(t *testing.T) {
+	def := dupTestDef()
+	noKey := TableDefinition{Info: TableInfo{Key: "no_key"}}
+
+	tests := []struct {
+		name       string
+		def        TableDefinition
+		mode       UploadMode
+		requested  DuplicatePolicy
+		wantMode   UploadMode
+		wantPolicy DuplicatePolicy
+		wantErr    string
+	}{
+		{"insert default", def, UploadModeInsert, "", UploadModeInsert, DuplicateKeepBoth, ""},
+		{"upsert default", def, UploadModeUpsert, "", UploadModeUpsert, DuplicateOverwrite, ""},
+		{"replace default", def, UploadModeReplace, "", UploadModeReplace, DuplicateKeepBoth, ""},
+		{"insert skip", def, UploadModeInsert, DuplicateSkip, UploadModeInsert, DuplicateSkip, ""},
+		{"insert overwrite upgrades", def, UploadModeInsert, DuplicateOverwrite, UploadModeUpsert, DuplicateOverwrite, ""},
+		{"replace fail-upload", def, UploadModeReplace, DuplicateFailUpload, UploadModeReplace, DuplicateFailUpload, ""},
+		{"no key keep-both", noKey, UploadModeInsert, "", UploadModeInsert, DuplicateKeepBoth, ""},
+		{"no key skip", noKey, UploadModeInsert, DuplicateSkip, "", "", "requires a unique key"},
+		{"upsert skip", def, UploadModeUpsert, DuplicateSkip, "", "", "upsert mode overwrites"},
+		{"upsert keep-both", def, UploadModeUpsert, DuplicateKeepBoth, "", "", "upsert mode overwrites"},
+		{"delete default", def, UploadModeDelete, "", UploadModeDelete, DuplicateKeepBoth, ""},
+		{"delete skip", def, UploadModeDelete, DuplicateSkip, "", "", "does not apply"},
+		{"invalid", def, UploadModeInsert, "ignore", "", "", "invalid duplicate policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, policy, err := resolveDuplicatePolicy(tt.def, tt.mode, tt.requested)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode != tt.wantMode || policy != tt.wantPolicy {
+				t.Errorf("got %s/%s, want %s/%s", mode, policy, tt.wantMode, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestCanonicalKeyPart(t *testing.T) {
+	tests := []struct {
+		spec   FieldSpec
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{FieldSpec{Type: FieldText}, ` ="A-1" `, "A-1", true},
+		{FieldSpec{Type: FieldText}, "", "", false},
+		{FieldSpec{Type: FieldDate}, "1/2/2024", "2024-01-02", true},
+		{FieldSpec{Type: FieldDate}, "2024-01-02", "2024-01-02", true},
+		{FieldSpec{Type: FieldDate}, "soon", "", false},
+		{FieldSpec{Type: FieldNumeric}, "$1,250.50", "1250.50", true},
+		{FieldSpec{Type: FieldBool}, "yes", "true", true},
+		{FieldSpec{Type: FieldText, Normalizer: strings.ToUpper}, "a-1", "A-1", true},
+	}
+	for _, tt := range tests {
+		got, ok := canonicalKeyPart(tt.spec, tt.raw)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("canonicalKeyPart(%v, %q) = %q, %v; want %q, %v", tt.spec.Type, tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNewDuplicateGuard_KeepBothIsNil(t *testing.T) {
+	def := dupTestDef()
+	for _, p := range []DuplicatePolicy{"", DuplicateKeepBoth} {
+		if g := newDuplicateGuard(def, p, pgtype.UUID{}, HeaderIndex{}); g != nil {
+			t.Errorf("policy %q: expected nil guard", p)
+		}
+	}
+	var g *duplicateGuard
+	if g.Skipped() != 0 || g.InFile() != 0 {
+		t.Error("nil guard should report zero counts")
+	}
+}
+
+func TestDuplicateGuard_OverwriteWithinBatch(t *testing.T) {
+	def := dupTestDef()
+	idx := HeaderIndex{"order id": 0, "close date": 1, "amount": 2}
+	g := newDuplicateGuard(def, DuplicateOverwrite, pgtype.UUID{}, idx)
+
+	batch := []validatedRow{
+		{lineNum: 2, row: []string{"A-1", "1/2/2024", "10"}},
+		{lineNum: 3, row: []string{"A-2", "1/2/2024", "20"}},
+		{lineNum: 4, row: []string{"A-1", "2024-01-02", "30"}}, // Same key as line 2
+		{lineNum: 5, row: []string{"", "1/2/2024", "40"}},      // Empty key part: never a duplicate
+		{lineNum: 6, row: []string{"", "1/2/2024", "50"}},
+	}
+	// The first batch has no keys from earlier batches, so no query is run.
+	kept, deleted, err := g.filter(context.Background(), nil, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, vr := range kept {
+		lines = append(lines, vr.lineNum)
+	}
+	if want := []int{3, 4, 5, 6}; !equalInts(lines, want) {
+		t.Errorf("kept lines %v, want %v", lines, want)
+	}
+	if deleted != 0 || g.InFile() != 1 {
+		t.Errorf("deleted = %d, inFile = %d; want 0, 1", deleted, g.InFile())
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDuplicateKeySQL(t *testing.T) {
+	def := dupTestDef()
+
+	existing := existingKeysSQL(def)
+	for _, want := range []string{
+		`unnest($2::text[], $3::text[]) WITH ORDINALITY AS k(k1, k2, i)`,
+		`FROM "dup_orders" AS t`,
+		`t.upload_id IS DISTINCT FROM $1`,
+		`t."order_id" = k.k1::TEXT`,
+		`t."closed_on" = k.k2::DATE`,
+	} {
+		if !strings.Contains(existing, want) {
+			t.Errorf("existingKeysSQL missing %q:\n%s", want, existing)
+		}
+	}
+
+	del := deleteEarlierSQL(def)
+	for _, want := range []string{`DELETE FROM "dup_orders" AS t`, `WHERE t.upload_id = $1`, `t."closed_on" = k.k2::DATE`} {
+		if !strings.Contains(del, want) {
+			t.Errorf("deleteEarlierSQL missing %q:\n%s", want, del)
+		}
+	}
+
+	args := keyArgs(pgtype.UUID{}, [][]string{{"A-1", "2024-01-02"}, {"A-2", "2024-01-03"}})
+	if len(args) != 3 {
+		t.Fatalf("keyArgs returned %d args, want 3", len(args))
+	}
+	if ids := args[1].([]string); ids[0] != "A-1" || ids[1] != "A-2" {
+		t.Errorf("key column 1 = %v", ids)
+	}
+}
+
+func TestDuplicateAuditSuffix(t *testing.T) {
+	tests := []struct {
+		result UploadResult
+		want   string
+	}{
+		{UploadResult{}, ""},
+		{UploadResult{Duplicates: DuplicateKeepBoth}, " [duplicates: keep-both]"},
+		{UploadResult{Duplicates: DuplicateSkip, DupSkipped: 3}, " [duplicates: skip, 3 skipped]"},
+		{UploadResult{Duplicates: DuplicateOverwrite, DupInFile: 2}, " [duplicates: overwrite, 2 replaced within file]"},
+	}
+	for _, tt := range tests {
+		if got := duplicateAuditSuffix(&tt.result); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
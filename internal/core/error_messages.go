@@ -50,7 +50,7 @@
 //
 //	VAL002 - Invalid number: Invalid number format detected
 //	         Action: Remove currency symbols and use standard decimal format
-//	         Patterns: "invalid number"
+//	         Patterns: "invalid number", "invalid numeric"
 //
 //	VAL003 - Required field: Required field is empty
 //	         Action: Ensure all required columns have values
@@ -62,12 +62,25 @@
 //
 //	VAL005 - Column not found: Expected column not found in CSV
 //	         Action: Verify column headers match the template exactly
-//	         Patterns: "column not found"
+//	         Patterns: "column not found", "header not found"
 //
 //	VAL006 - Invalid enum: Value is not in the allowed list
 //	         Action: Check the allowed values for this field
 //	         Patterns: "invalid enum"
 //
+//	VAL007 - Invalid boolean: Value is not a recognized true/false value
+//	         Action: Use true/false, yes/no, or 1/0
+//	         Patterns: "invalid bool"
+//
+//	VAL008 - Column count: Row has fewer columns than the header
+//	         Action: Check the row for missing delimiters or unbalanced quotes
+//	         Patterns: "columns, got"
+//
+//	VAL009 - Rule failed: Row breaks one of the table's validation rules
+//	         Action: Correct the values named in the rule message
+//	         Patterns: failed-row reasons starting with "rule " (see
+//	         MapRowError; MapError can't tell them from other messages)
+//
 // # File Errors (FILE001-FILE099)
 //
 // Errors related to file handling and parsing:
@@ -92,6 +105,26 @@
 //	          Action: Please upload a CSV file with data rows
 //	          Patterns: "empty file"
 //
+//	FILE006 - Table size limit: File exceeds the size limit for this table
+//	          Action: Check you selected the right table, or split the file
+//	          Patterns: "exceeds table size limit"
+//
+//	FILE007 - Table row limit: File has more rows than this table accepts
+//	          Action: Check you selected the right table, or split the file
+//	          Patterns: "exceeds table row limit"
+//
+//	FILE008 - Invalid JSON: File is not valid JSON
+//	          Action: Upload a JSON array of objects, or one object per line (NDJSON)
+//	          Patterns: "invalid json"
+//
+//	FILE009 - Invalid Parquet: File is not a Parquet file that can be imported
+//	          Action: Write the file with a flat schema and Snappy, gzip or no compression
+//	          Patterns: "invalid parquet"
+//
+//	FILE010 - Template drift: File headers no longer match the import template
+//	          Action: Update the template's mapping to the suggested one, or upload without the template
+//	          Patterns: "template drift"
+//
 // # Upload Errors (UPL001-UPL099)
 //
 // Errors related to the upload process and session management:
@@ -116,6 +149,22 @@
 //	         Action: Try uploading a smaller file or check your connection
 //	         Patterns: "context deadline exceeded"
 //
+//	UPL006 - Daily limit: Daily upload limit reached for this table
+//	         Action: Try again tomorrow or roll back an earlier upload
+//	         Patterns: "daily upload limit"
+//
+//	UPL007 - Duplicate row: A duplicate key failed the upload (fail-upload policy)
+//	         Action: Remove the duplicate, or upload with the skip or overwrite policy
+//	         Patterns: "failed by duplicate policy"
+//
+//	UPL008 - Uploader busy: Too many uploads running for this API key or client
+//	         Action: Wait for one of your uploads to finish and try again
+//	         Patterns: "concurrent upload limit reached"
+//
+//	UPL009 - Daily quota: Daily upload quota used up for this API key or client
+//	         Action: Try again after midnight UTC or ask an administrator to raise the quota
+//	         Patterns: "daily upload quota"
+//
 // # Table Errors (TBL001-TBL099)
 //
 // Errors related to table configuration and access:
@@ -128,6 +177,10 @@
 //	         Action: This table type is not configured
 //	         Patterns: "unknown table"
 //
+//	TBL003 - Read-only table: The table is a view and cannot be changed
+//	         Action: Upload to or edit the tables the view is built from
+//	         Patterns: "table is read-only"
+//
 // # Rate Limiting (RATE001-RATE099)
 //
 // Errors related to request throttling:
@@ -160,6 +213,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -271,7 +325,7 @@ var errorPatterns = []errorPattern{
 	},
 
 	// =========================================================================
-	// Validation Errors (VAL001-VAL006)
+	// Validation Errors (VAL001-VAL008)
 	// These errors occur when data doesn't match expected formats.
 	// =========================================================================
 	{
@@ -290,6 +344,14 @@ var errorPatterns = []errorPattern{
 			Code:    "VAL002",
 		},
 	},
+	{
+		pattern: "invalid numeric",
+		msg: UserMessage{
+			Message: "Invalid number format detected",
+			Action:  "Remove currency symbols and use standard decimal format",
+			Code:    "VAL002",
+		},
+	},
 	{
 		pattern: "required field",
 		msg: UserMessage{
@@ -314,6 +376,14 @@ var errorPatterns = []errorPattern{
 			Code:    "VAL005",
 		},
 	},
+	{
+		pattern: "header not found",
+		msg: UserMessage{
+			Message: "Expected column not found in CSV",
+			Action:  "Verify column headers match the template exactly",
+			Code:    "VAL005",
+		},
+	},
 	{
 		pattern: "invalid enum",
 		msg: UserMessage{
@@ -322,9 +392,25 @@ var errorPatterns = []errorPattern{
 			Code:    "VAL006",
 		},
 	},
+	{
+		pattern: "invalid bool",
+		msg: UserMessage{
+			Message: "Value is not a recognized true/false value",
+			Action:  "Use true/false, yes/no, or 1/0",
+			Code:    "VAL007",
+		},
+	},
+	{
+		pattern: "columns, got",
+		msg: UserMessage{
+			Message: "Row has fewer columns than the header",
+			Action:  "Check the row for missing delimiters or unbalanced quotes",
+			Code:    "VAL008",
+		},
+	},
 
 	// =========================================================================
-	// File Errors (FILE001-FILE005)
+	// File Errors (FILE001-FILE010)
 	// These errors occur when processing uploaded files.
 	// =========================================================================
 	{
@@ -367,9 +453,49 @@ var errorPatterns = []errorPattern{
 			Code:    "FILE005",
 		},
 	},
+	{
+		pattern: "exceeds table size limit",
+		msg: UserMessage{
+			Message: "File exceeds the size limit for this table",
+			Action:  "Check you selected the right table, or split the file",
+			Code:    "FILE006",
+		},
+	},
+	{
+		pattern: "exceeds table row limit",
+		msg: UserMessage{
+			Message: "File has more rows than this table accepts",
+			Action:  "Check you selected the right table, or split the file",
+			Code:    "FILE007",
+		},
+	},
+	{
+		pattern: "invalid json",
+		msg: UserMessage{
+			Message: "File is not valid JSON",
+			Action:  "Upload a JSON array of objects, or one object per line (NDJSON)",
+			Code:    "FILE008",
+		},
+	},
+	{
+		pattern: "invalid parquet",
+		msg: UserMessage{
+			Message: "File is not a Parquet file that can be imported",
+			Action:  "Write the file with a flat schema and Snappy, gzip or no compression",
+			Code:    "FILE009",
+		},
+	},
+	{
+		pattern: "template drift",
+		msg: UserMessage{
+			Message: "File headers no longer match the import template",
+			Action:  "Update the template's mapping to the suggested one, or upload without the template",
+			Code:    "FILE010",
+		},
+	},
 
 	// =========================================================================
-	// Upload Errors (UPL001-UPL005)
+	// Upload Errors (UPL001-UPL009)
 	// These errors occur during the upload process and session management.
 	// =========================================================================
 	{
@@ -412,9 +538,41 @@ var errorPatterns = []errorPattern{
 			Code:    "UPL005",
 		},
 	},
+	{
+		pattern: "daily upload limit",
+		msg: UserMessage{
+			Message: "Daily upload limit reached for this table",
+			Action:  "Try again tomorrow or roll back an earlier upload",
+			Code:    "UPL006",
+		},
+	},
+	{
+		pattern: "failed by duplicate policy",
+		msg: UserMessage{
+			Message: "The file contains a duplicate row",
+			Action:  "Remove the duplicate, or upload with the skip or overwrite policy",
+			Code:    "UPL007",
+		},
+	},
+	{
+		pattern: "concurrent upload limit reached",
+		msg: UserMessage{
+			Message: "Too many uploads running for this API key or client",
+			Action:  "Wait for one of your uploads to finish and try again",
+			Code:    "UPL008",
+		},
+	},
+	{
+		pattern: "daily upload quota",
+		msg: UserMessage{
+			Message: "Daily upload quota used up for this API key or client",
+			Action:  "Try again after midnight UTC or ask an administrator to raise the quota",
+			Code:    "UPL009",
+		},
+	},
 
 	// =========================================================================
-	// Table Errors (TBL001-TBL002)
+	// Table Errors (TBL001-TBL003)
 	// These errors occur when working with database tables.
 	// =========================================================================
 	{
@@ -433,6 +591,14 @@ var errorPatterns = []errorPattern{
 			Code:    "TBL002",
 		},
 	},
+	{
+		pattern: "table is read-only",
+		msg: UserMessage{
+			Message: "This table is a read-only view",
+			Action:  "Upload to or edit the tables the view is built from",
+			Code:    "TBL003",
+		},
+	},
 
 	// =========================================================================
 	// Rate Limiting (RATE001)
@@ -457,6 +623,23 @@ var defaultMessage = UserMessage{
 	Code:    "ERR000",
 }
 
+// ruleMessage is returned by MapRowError for rule failures (VAL009).
+var ruleMessage = UserMessage{
+	Message: "Row breaks one of the table's validation rules",
+	Action:  "Correct the values named in the rule message",
+	Code:    "VAL009",
+}
+
+// MapRowError is MapError for a failed row's reason. Rule failures ("rule
+// <name>: ...") map to VAL009 whatever their message says, so that a rule
+// explaining an "invalid date range" isn't reported as VAL001.
+func MapRowError(reason string) UserMessage {
+	if strings.HasPrefix(reason, "rule ") {
+		return ruleMessage
+	}
+	return MapError(errors.New(reason))
+}
+
 // MapError converts a technical error to a user-friendly message.
 // It searches through known error patterns (case-insensitive) and returns
 // the first match. If no pattern matches, a generic fallback message with
@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -54,6 +55,66 @@ func TestMapError(t *testing.T) {
 			wantCode:    "FILE001",
 			wantMessage: "File exceeds maximum size limit (100MB)",
 		},
+		{
+			name:        "table size limit maps correctly",
+			err:         errors.New("file exceeds table size limit for ns_items: 2000 bytes (max 1000)"),
+			wantCode:    "FILE006",
+			wantMessage: "File exceeds the size limit for this table",
+		},
+		{
+			name:        "table row limit maps correctly",
+			err:         errors.New("upload exceeds table row limit for ns_items (max 100 rows)"),
+			wantCode:    "FILE007",
+			wantMessage: "File has more rows than this table accepts",
+		},
+		{
+			name:        "invalid JSON maps correctly",
+			err:         errors.New("read CSV: invalid JSON: object 3: invalid character '}' looking for beginning of value"),
+			wantCode:    "FILE008",
+			wantMessage: "File is not valid JSON",
+		},
+		{
+			name:        "invalid Parquet maps correctly",
+			err:         errors.New("invalid parquet file: Zstandard compression is not supported; write the file with Snappy, gzip or no compression"),
+			wantCode:    "FILE009",
+			wantMessage: "File is not a Parquet file that can be imported",
+		},
+		{
+			name:        "template drift maps correctly",
+			err:         errors.New(`template drift: file headers differ from template "Vendor export": missing "Amount" (affects Amount); suggested mapping: Name=0`),
+			wantCode:    "FILE010",
+			wantMessage: "File headers no longer match the import template",
+		},
+		{
+			name:        "daily upload limit maps correctly",
+			err:         errors.New("daily upload limit reached for ns_items: 5 uploads today (max 5)"),
+			wantCode:    "UPL006",
+			wantMessage: "Daily upload limit reached for this table",
+		},
+		{
+			name:        "read-only table maps correctly",
+			err:         checkWritable(TableDefinition{Info: TableInfo{Key: "v"}, View: "SELECT 1"}),
+			wantCode:    "TBL003",
+			wantMessage: "This table is a read-only view",
+		},
+		{
+			name:        "duplicate policy failure maps correctly",
+			err:         &DuplicateRowError{LineNumber: 9, Key: "A-1", FirstLine: 4},
+			wantCode:    "UPL007",
+			wantMessage: "The file contains a duplicate row",
+		},
+		{
+			name:        "uploader concurrency limit maps correctly",
+			err:         fmt.Errorf("acquire upload slot for ns_items: %w (max 2)", ErrUploaderBusy),
+			wantCode:    "UPL008",
+			wantMessage: "Too many uploads running for this API key or client",
+		},
+		{
+			name:        "daily uploader quota maps correctly",
+			err:         fmt.Errorf("%w: 20 of 20 uploads used today", ErrUploadQuotaExceeded),
+			wantCode:    "UPL009",
+			wantMessage: "Daily upload quota used up for this API key or client",
+		},
 		{
 			name:        "rate limit maps correctly",
 			err:         errors.New("rate limit exceeded"),
@@ -87,6 +148,24 @@ func TestMapError(t *testing.T) {
 	}
 }
 
+func TestMapRowError(t *testing.T) {
+	tests := []struct {
+		reason   string
+		wantCode string
+	}{
+		{`invalid numeric for "Amount": "ten"`, "VAL002"},
+		{`invalid bool for "Paid": "maybe"`, "VAL007"},
+		{"expected 4 columns, got 2", "VAL008"},
+		{"rule end_after_start: invalid date range", "VAL009"},
+		{`invalid date for "Due": "soon"`, "VAL001"},
+	}
+	for _, tt := range tests {
+		if got := MapRowError(tt.reason); got.Code != tt.wantCode {
+			t.Errorf("MapRowError(%q) = %s, want %s", tt.reason, got.Code, tt.wantCode)
+		}
+	}
+}
+
 func TestFormatUserError(t *testing.T) {
 	err := errors.New("duplicate key value violates")
 	result := FormatUserError(err)
@@ -0,0 +1,141 @@
+package core
+
+// export_audit.go records data leaving the system. Every table export,
+// failed-row download, audit log export, retention archive download and upload evidence package gets a data_export audit entry
+// with the filters applied, the row count and the format, so compliance
+// can answer "who downloaded what" as well as "who changed what".
+//
+// Like every audit entry, the requester is identified by IP address and
+// User-Agent. An export that fails partway is still recorded, with the
+// rows sent before the failure.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ExportKind is what an export contains.
+type ExportKind string
+
+const (
+	ExportTableData        ExportKind = "table_data"        // Rows of a table
+	ExportFailedRows       ExportKind = "failed_rows"       // Rows an upload rejected
+	ExportAuditLog         ExportKind = "audit_log"         // Audit log entries
+	ExportRetentionArchive ExportKind = "retention_archive" // Rows purged by retention (see retention_archive.go)
+	ExportUploadEvidence   ExportKind = "upload_evidence"   // An upload's evidence package (see upload_evidence.go)
+)
+
+// ExportRecord describes one completed (or aborted) export.
+type ExportRecord struct {
+	Kind     ExportKind
+	TableKey string         // Table exported, or the audit log's table filter
+	UploadID string         // Failed rows and upload evidence only
+	Format   string         // e.g. "csv"
+	FileName string         // Name offered to the client
+	Filters  map[string]any // Filter set applied; nil or empty for everything
+	Rows     int            // Rows sent, excluding the header
+	Err      error          // Set if the export stopped early
+
+	// Anonymized is set for anonymized sample exports (see anonymize.go)
+	Anonymized bool
+
+	// Watermark is the export ID written into a watermarked export (see
+	// export_watermark.go)
+	Watermark string
+}
+
+// FilterSetAudit returns a table export's search and column filters in the
+// form recorded in the audit log.
+func FilterSetAudit(search string, filters FilterSet) map[string]any {
+	f := make(map[string]any)
+	if search != "" {
+		f["search"] = search
+	}
+	if len(filters.Filters) > 0 {
+		cols := make([]map[string]string, len(filters.Filters))
+		for i, cf := range filters.Filters {
+			cols[i] = map[string]string{"column": cf.Column, "op": string(cf.Operator), "value": cf.Value}
+			if cf.Group != "" {
+				cols[i]["group"] = cf.Group
+			}
+			if cf.Negate {
+				cols[i]["not"] = "true"
+			}
+		}
+		f["filters"] = cols
+	}
+	return f
+}
+
+// exportAuditParams builds the audit entry for an export.
+func exportAuditParams(ctx context.Context, rec ExportRecord) AuditLogParams {
+	data := map[string]any{
+		"kind":      string(rec.Kind),
+		"format":    rec.Format,
+		"file_name": rec.FileName,
+	}
+	if len(rec.Filters) > 0 {
+		data["filters"] = rec.Filters
+	}
+	if rec.Anonymized {
+		data["anonymized"] = true
+	}
+	if rec.Watermark != "" {
+		data["watermark"] = rec.Watermark
+	}
+
+	var reason string
+	switch rec.Kind {
+	case ExportFailedRows:
+		reason = fmt.Sprintf("Downloaded %d failed rows of upload %s as %s", rec.Rows, rec.UploadID, rec.Format)
+	case ExportAuditLog:
+		reason = fmt.Sprintf("Exported %d audit log entries as %s", rec.Rows, rec.Format)
+	case ExportRetentionArchive:
+		reason = fmt.Sprintf("Downloaded retention archive %s with %d purged rows of %s", rec.FileName, rec.Rows, rec.TableKey)
+	case ExportUploadEvidence:
+		reason = fmt.Sprintf("Downloaded evidence package of upload %s with %d failed rows", rec.UploadID, rec.Rows)
+	default:
+		if rec.Anonymized {
+			reason = fmt.Sprintf("Exported %d anonymized sample rows of %s as %s", rec.Rows, rec.TableKey, rec.Format)
+			break
+		}
+		reason = fmt.Sprintf("Exported %d rows of %s as %s", rec.Rows, rec.TableKey, rec.Format)
+	}
+	if rec.Watermark != "" {
+		reason += fmt.Sprintf(", watermarked %s", rec.Watermark)
+	}
+	if rec.Err != nil {
+		data["incomplete"] = true
+		reason += fmt.Sprintf(" (stopped early: %v)", rec.Err)
+	}
+
+	return AuditLogParams{
+		Action:       ActionDataExport,
+		TableKey:     rec.TableKey,
+		UploadID:     rec.UploadID,
+		NewValue:     rec.Format,
+		RowData:      data,
+		RowsAffected: rec.Rows,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       reason,
+	}
+}
+
+// LogExport records an export in the audit log. Failures are logged, not
+// returned: the data has already been sent.
+func (s *Service) LogExport(ctx context.Context, rec ExportRecord) {
+	// The request context may already be cancelled by a client disconnect
+	ctx, cancel := s.withOpTimeout(context.WithoutCancel(ctx), opMutation)
+	defer cancel()
+
+	if _, err := s.LogAudit(ctx, exportAuditParams(ctx, rec)); err != nil {
+		slog.Error("failed to log export audit",
+			"kind", rec.Kind,
+			"table", rec.TableKey,
+			"rows", rec.Rows,
+			"error", err,
+		)
+	}
+}
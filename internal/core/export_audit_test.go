@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFilterSetAudit(t *testing.T) {
+	if got := FilterSetAudit("", FilterSet{}); len(got) != 0 {
+		t.Errorf("no filters: got %v", got)
+	}
+
+	got := FilterSetAudit("acme", FilterSet{Filters: []ColumnFilter{
+		{Column: "Amount", DBColumn: "amount", Operator: OpGreaterEq, Value: "100"},
+	}})
+	if got["search"] != "acme" {
+		t.Errorf("search = %v", got["search"])
+	}
+	cols, ok := got["filters"].([]map[string]string)
+	if !ok || len(cols) != 1 || cols[0]["column"] != "Amount" || cols[0]["op"] != "gte" || cols[0]["value"] != "100" {
+		t.Errorf("filters = %v", got["filters"])
+	}
+}
+
+func TestExportAuditParams(t *testing.T) {
+	ctx := ContextWithUserAgent(ContextWithIPAddress(context.Background(), "10.0.0.1"), "curl/8")
+	p := exportAuditParams(ctx, ExportRecord{
+		Kind:     ExportTableData,
+		TableKey: "sfdc_customers",
+		Format:   "csv",
+		FileName: "sfdc_customers_20240101_000000.csv",
+		Filters:  map[string]any{"search": "acme"},
+		Rows:     42,
+	})
+
+	if p.Action != ActionDataExport || auditSeverity(p.Action) != SeverityHigh {
+		t.Errorf("action %q severity %q", p.Action, auditSeverity(p.Action))
+	}
+	if p.TableKey != "sfdc_customers" || p.RowsAffected != 42 || p.NewValue != "csv" {
+		t.Errorf("params = %+v", p)
+	}
+	if p.IPAddress != "10.0.0.1" || p.UserAgent != "curl/8" {
+		t.Errorf("requester = %q, %q", p.IPAddress, p.UserAgent)
+	}
+	if p.RowData["kind"] != "table_data" || p.RowData["filters"] == nil || p.RowData["incomplete"] != nil {
+		t.Errorf("row data = %v", p.RowData)
+	}
+	if p.Reason != "Exported 42 rows of sfdc_customers as csv" {
+		t.Errorf("reason = %q", p.Reason)
+	}
+
+	// A failed export is still recorded, marked incomplete
+	p = exportAuditParams(ctx, ExportRecord{Kind: ExportFailedRows, UploadID: "u1", Format: "csv", Rows: 3, Err: errors.New("broken pipe")})
+	if p.RowData["incomplete"] != true || p.RowData["filters"] != nil || p.UploadID != "u1" {
+		t.Errorf("row data = %v", p.RowData)
+	}
+	if p.Reason != "Downloaded 3 failed rows of upload u1 as csv (stopped early: broken pipe)" {
+		t.Errorf("reason = %q", p.Reason)
+	}
+}
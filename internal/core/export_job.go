@@ -0,0 +1,390 @@
+package core
+
+// export_job.go runs table exports in the background. A streaming export
+// of a million-row table holds its request open for as long as the query
+// runs; an export job instead returns at once and writes the file to disk.
+//
+// StartExport returns the ID of an export operation, followed like any
+// other at /api/operations/{id}/progress. When the file is complete the
+// operation's result link points at its download. Files are deleted
+// EXPORT_JOB_TTL after they were written; expired and incomplete jobs are
+// removed on startup and whenever another export starts.
+//
+// The export is recorded in the audit log when the file is downloaded,
+// not when it is written: that is when the data leaves the system.
+//
+// File layout (per operation ID):
+//
+//	<id>.export  the file, in the requested format
+//	<id>.json    the manifest, written last: a job without one is
+//	             incomplete and removed on startup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	exportJobDataExt     = ".export"
+	exportJobManifestExt = ".json"
+)
+
+// DefaultExportJobTTL is how long export job files are kept when
+// EXPORT_JOB_TTL is 0.
+const DefaultExportJobTTL = 24 * time.Hour
+
+var (
+	// ErrExportJobNotFound is returned for an unknown or expired export job.
+	ErrExportJobNotFound = errors.New("export job not found")
+
+	// ErrExportJobRunning is returned when downloading an export job whose
+	// file is still being written.
+	ErrExportJobRunning = errors.New("export job still running")
+)
+
+// ExportJob is the manifest of a finished export job.
+type ExportJob struct {
+	ID          string         `json:"id"` // Operation ID
+	TableKey    string         `json:"tableKey"`
+	Format      ExportFormat   `json:"format"`
+	FileName    string         `json:"fileName"` // Name offered for download
+	ContentType string         `json:"contentType"`
+	Filters     map[string]any `json:"filters,omitempty"`   // As recorded in the audit log
+	Watermark   string         `json:"watermark,omitempty"` // Export ID (see export_watermark.go)
+	Rows        int            `json:"rows"`
+	Size        int64          `json:"size"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ExpiresAt   time.Time      `json:"expiresAt"`
+}
+
+// ExportJobStore holds the files written by export jobs in a directory.
+type ExportJobStore struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewExportJobStore opens the export job directory dir, creating it if
+// needed. Files are kept for ttl (DefaultExportJobTTL if 0). Incomplete
+// and expired jobs left by a previous run are removed.
+func NewExportJobStore(dir string, ttl time.Duration) (*ExportJobStore, error) {
+	if ttl <= 0 {
+		ttl = DefaultExportJobTTL
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create export job directory: %w", err)
+	}
+	st := &ExportJobStore{dir: dir, ttl: ttl}
+
+	partial, _ := filepath.Glob(filepath.Join(dir, "*"+exportJobDataExt+".tmp"))
+	for _, path := range partial {
+		os.Remove(path)
+	}
+	data, _ := filepath.Glob(filepath.Join(dir, "*"+exportJobDataExt))
+	for _, path := range data {
+		id := strings.TrimSuffix(filepath.Base(path), exportJobDataExt)
+		if _, err := os.Stat(st.path(id, exportJobManifestExt)); errors.Is(err, os.ErrNotExist) {
+			os.Remove(path)
+		}
+	}
+	if _, err := st.Expire(time.Now()); err != nil {
+		slog.Warn("failed to expire export jobs", "error", err)
+	}
+	return st, nil
+}
+
+// ExportJobs returns the export job store.
+func (s *Service) ExportJobs() *ExportJobStore {
+	return s.exportJobs
+}
+
+// exportJobFile is an export job's file while it is being written.
+type exportJobFile struct {
+	st  *ExportJobStore
+	id  string
+	f   *os.File
+	buf *bufio.Writer
+}
+
+// create opens the temporary file of job id.
+func (st *ExportJobStore) create(id string) (*exportJobFile, error) {
+	f, err := os.OpenFile(st.path(id, exportJobDataExt)+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create export job file: %w", err)
+	}
+	return &exportJobFile{st: st, id: id, f: f, buf: bufio.NewWriterSize(f, 64*1024)}, nil
+}
+
+func (jf *exportJobFile) Write(p []byte) (int, error) {
+	return jf.buf.Write(p)
+}
+
+// commit stores the file and writes the manifest. meta's ID, size and
+// times are filled in.
+func (jf *exportJobFile) commit(meta ExportJob) (*ExportJob, error) {
+	tmp := jf.f.Name()
+	err := jf.buf.Flush()
+	if closeErr := jf.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(tmp); err == nil {
+			meta.Size = info.Size()
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmp, jf.st.path(jf.id, exportJobDataExt))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("store export job file: %w", err)
+	}
+
+	now := time.Now().UTC()
+	meta.ID = jf.id
+	meta.CreatedAt = now
+	meta.ExpiresAt = now.Add(jf.st.ttl)
+	manifest, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = os.WriteFile(jf.st.path(jf.id, exportJobManifestExt), manifest, 0o600)
+	}
+	if err != nil {
+		os.Remove(jf.st.path(jf.id, exportJobDataExt))
+		return nil, fmt.Errorf("write export job manifest: %w", err)
+	}
+	return &meta, nil
+}
+
+// discard removes the unfinished file.
+func (jf *exportJobFile) discard() {
+	jf.f.Close()
+	os.Remove(jf.f.Name())
+}
+
+// Get returns the manifest of job id.
+func (st *ExportJobStore) Get(id string) (*ExportJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrExportJobNotFound
+	}
+	data, err := os.ReadFile(st.path(id, exportJobManifestExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read export job manifest: %w", err)
+	}
+	var job ExportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("parse export job manifest: %w", err)
+	}
+	if !time.Now().Before(job.ExpiresAt) {
+		return nil, ErrExportJobNotFound
+	}
+	return &job, nil
+}
+
+// Open returns the file of job id and its manifest.
+func (st *ExportJobStore) Open(id string) (io.ReadCloser, *ExportJob, error) {
+	job, err := st.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(st.path(id, exportJobDataExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("open export job file: %w", err)
+	}
+	return f, job, nil
+}
+
+// Expire deletes jobs whose files expired before now and returns how many
+// were deleted.
+func (st *ExportJobStore) Expire(now time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(st.dir, "*"+exportJobManifestExt))
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	var errs []error
+	for _, path := range paths {
+		var job ExportJob
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &job)
+		}
+		if err != nil {
+			slog.Warn("unreadable export job manifest", "path", path, "error", err)
+			continue
+		}
+		if now.Before(job.ExpiresAt) {
+			continue
+		}
+		// The manifest goes first, so a failure leaves an incomplete job
+		// that is cleaned up on the next start
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		os.Remove(st.path(strings.TrimSuffix(filepath.Base(path), exportJobManifestExt), exportJobDataExt))
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func (st *ExportJobStore) path(id, ext string) string {
+	return filepath.Join(st.dir, id+ext)
+}
+
+// stepExport is the single step of an export operation.
+const stepExport = "export"
+
+// exportFlushInterval is how many rows an export writes between flushes
+// and progress updates.
+const exportFlushInterval = 1000
+
+// StartExport exports the rows of tableKey matching search and filters to
+// a file in format, configured with opts and watermarked if they or the
+// table ask for it (see ExportWatermark), in the background, and returns
+// the operation ID to follow it with. The export keeps running if ctx is
+// cancelled; CancelOperation stops it. Once complete, OpenExportJob
+// returns the file.
+func (s *Service) StartExport(ctx context.Context, tableKey string, format ExportFormat, opts ExportOptions, search string, filters FilterSet) (string, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return "", fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if _, err := s.exportJobs.Expire(time.Now()); err != nil {
+		slog.Warn("failed to expire export jobs", "error", err)
+	}
+
+	watermark, err := ExportWatermark(def, opts)
+	if err != nil {
+		return "", err
+	}
+
+	id := uuid.New().String()
+	file, err := s.exportJobs.create(id)
+	if err != nil {
+		return "", err
+	}
+	exporter, err := NewExporter(format, file, def)
+	if err == nil {
+		err = ConfigureExporter(exporter, opts)
+	}
+	if err != nil {
+		file.discard()
+		return "", err
+	}
+
+	op := s.startOperation(ctx, id, OperationExport, tableKey, []OperationStep{{Name: stepExport, Weight: 1}})
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		job, err := s.runExport(ctx, op, def, exporter, file, search, filters, watermark)
+		if err != nil {
+			file.discard()
+			return err
+		}
+		op.SetResultLink("/api/export-job/" + job.ID + "/download")
+		return nil
+	})
+	return id, nil
+}
+
+// runExport writes the export of StartExport under op and stores the file.
+func (s *Service) runExport(ctx context.Context, op *Operation, def TableDefinition, exporter Exporter, file *exportJobFile, search string, filters FilterSet, watermark string) (*ExportJob, error) {
+	op.Begin(stepExport)
+
+	total, err := s.countExportRows(ctx, def, search, filters)
+	if err != nil {
+		op.EndStep(stepExport, err)
+		return nil, err
+	}
+	op.Advance(stepExport, 0, total)
+
+	rows := 0
+	columns, mark := WatermarkExport(exporter, ExportColumns(exporter, def), watermark)
+	format := ExportRowFormatter(exporter, def, columns)
+	err = exporter.WriteHeader(columns)
+	if err == nil {
+		err = s.StreamTableData(ctx, def.Info.Key, search, filters, func(row TableRow) error {
+			mark(row)
+			if err := exporter.WriteRow(format(row)); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushInterval == 0 {
+				op.Advance(stepExport, int64(rows), total)
+				return exporter.Flush()
+			}
+			return nil
+		})
+	}
+	if closeErr := exporter.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	op.SetDetail(stepExport, fmt.Sprintf("%d rows", rows))
+	if err != nil {
+		err = fmt.Errorf("export %s after %d rows: %w", def.Info.Key, rows, err)
+		op.EndStep(stepExport, err)
+		return nil, err
+	}
+
+	job, err := file.commit(ExportJob{
+		TableKey:    def.Info.Key,
+		Format:      ExportFormat(exporter.Extension()),
+		FileName:    fmt.Sprintf("%s_%s.%s", def.Info.Key, time.Now().Format("20060102_150405"), exporter.Extension()),
+		ContentType: exporter.ContentType(),
+		Filters:     FilterSetAudit(search, filters),
+		Watermark:   watermark,
+		Rows:        rows,
+	})
+	op.EndStep(stepExport, err)
+	return job, err
+}
+
+// countExportRows counts the rows an export of def with search and filters
+// writes, for its progress.
+func (s *Service) countExportRows(ctx context.Context, def TableDefinition, search string, filters FilterSet) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
+	wb.AddSearch(search, def.FieldSpecs)
+	wb.AddFilters(filters)
+	whereClause, args := wb.Build()
+
+	var n int64
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+quoteIdentifier(def.Info.Key)+whereClause, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count rows: %w", err)
+	}
+	return n, nil
+}
+
+// OpenExportJob returns the file written by export job id and its
+// manifest. It returns ErrExportJobRunning while the export is still
+// being written.
+func (s *Service) OpenExportJob(id string) (io.ReadCloser, *ExportJob, error) {
+	rc, job, err := s.exportJobs.Open(id)
+	if errors.Is(err, ErrExportJobNotFound) {
+		if p, perr := s.GetOperationProgress(id); perr == nil && p.Kind == OperationExport && p.Status == OperationRunning {
+			return nil, nil, ErrExportJobRunning
+		}
+	}
+	return rc, job, err
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestExportJobStore_CommitOpen(t *testing.T) {
+	st, err := NewExportJobStore(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewExportJobStore: %v", err)
+	}
+	id := uuid.New().String()
+	file, err := st.create(id)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := st.Get(id); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("Get before commit error = %v, want ErrExportJobNotFound", err)
+	}
+
+	io.WriteString(file, "Vendor\nAcme\n")
+	job, err := file.commit(ExportJob{TableKey: "vendor_bills", Format: ExportCSV, FileName: "vendor_bills.csv", Rows: 1})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if job.ID != id || job.Size != 12 || job.ExpiresAt.Sub(job.CreatedAt) != time.Hour {
+		t.Errorf("committed job = %+v", job)
+	}
+
+	rc, got, err := st.Open(id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "Vendor\nAcme\n" || got.Rows != 1 || got.TableKey != "vendor_bills" {
+		t.Errorf("Open() = %q, %+v", data, got)
+	}
+
+	if _, err := st.Get("../" + id); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("Get(invalid id) error = %v, want ErrExportJobNotFound", err)
+	}
+}
+
+func TestExportJobStore_Discard(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := NewExportJobStore(dir, time.Hour)
+	file, err := st.create(uuid.New().String())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	io.WriteString(file, "partial")
+	file.discard()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("discard left %d files", len(entries))
+	}
+}
+
+func TestExportJobStore_Expire(t *testing.T) {
+	dir := t.TempDir()
+	st, _ := NewExportJobStore(dir, time.Hour)
+	id := uuid.New().String()
+	file, _ := st.create(id)
+	if _, err := file.commit(ExportJob{TableKey: "vendor_bills"}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	if n, err := st.Expire(time.Now()); n != 0 || err != nil {
+		t.Errorf("Expire(now) = %d, %v; want nothing expired", n, err)
+	}
+	if n, err := st.Expire(time.Now().Add(2 * time.Hour)); n != 1 || err != nil {
+		t.Errorf("Expire(later) = %d, %v; want 1", n, err)
+	}
+	if _, err := st.Get(id); !errors.Is(err, ErrExportJobNotFound) {
+		t.Errorf("Get after expiry error = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expiry left %d files", len(entries))
+	}
+}
+
+func TestNewExportJobStore_RemovesIncomplete(t *testing.T) {
+	dir := t.TempDir()
+	orphan := filepath.Join(dir, uuid.New().String()+exportJobDataExt)
+	partial := filepath.Join(dir, uuid.New().String()+exportJobDataExt+".tmp")
+	for _, path := range []string{orphan, partial} {
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewExportJobStore(dir, 0); err != nil {
+		t.Fatalf("NewExportJobStore: %v", err)
+	}
+	for _, path := range []string{orphan, partial} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s was not removed", filepath.Base(path))
+		}
+	}
+}
+
+func TestFormatExportCell(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{nil, ""},
+		{ToPgNumeric("1250"), "1250"},
+		{ToPgNumeric("1250.5"), "1250.50"},
+		{ToPgDate("2024-03-01"), "2024-03-01"},
+		{pgtype.Text{}, ""},
+		{pgtype.Bool{Bool: true, Valid: true}, "Yes"},
+		{false, "No"},
+		{"Acme", "Acme"},
+	}
+	for _, tt := range tests {
+		if got := FormatExportCell(tt.in); got != tt.want {
+			t.Errorf("FormatExportCell(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
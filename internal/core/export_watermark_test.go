@@ -0,0 +1,151 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestExportWatermark(t *testing.T) {
+	def := exportTestTable()
+	id := regexp.MustCompile(`^EXP-[0-9a-f]{16}$`)
+
+	tests := []struct {
+		always bool
+		opt    string
+		marked bool
+	}{
+		{false, "", false},
+		{false, "false", false},
+		{false, "true", true},
+		{true, "", true},
+		{true, "false", true},
+	}
+	for _, tt := range tests {
+		def.WatermarkExports = tt.always
+		got, err := ExportWatermark(def, ExportOptions{WatermarkOption: tt.opt})
+		if err != nil {
+			t.Fatalf("always %v, option %q: %v", tt.always, tt.opt, err)
+		}
+		if marked := got != ""; marked != tt.marked || (marked && !id.MatchString(got)) {
+			t.Errorf("always %v, option %q: watermark %q", tt.always, tt.opt, got)
+		}
+	}
+
+	a, _ := ExportWatermark(def, nil)
+	b, _ := ExportWatermark(def, nil)
+	if a == b {
+		t.Errorf("watermarks repeat: %s", a)
+	}
+	if _, err := ExportWatermark(def, ExportOptions{WatermarkOption: "sometimes"}); !errors.Is(err, ErrInvalidExportOption) {
+		t.Errorf("invalid option: err = %v", err)
+	}
+}
+
+func TestWatermarkExport(t *testing.T) {
+	def := exportTestTable()
+	const id = "EXP-0123456789abcdef"
+
+	var buf bytes.Buffer
+	e, _ := NewExporter(ExportCSV, &buf, def)
+	if err := ConfigureExporter(e, ExportOptions{WatermarkOption: "true"}); err != nil {
+		t.Fatalf("ConfigureExporter: %v", err)
+	}
+	columns, mark := WatermarkExport(e, def.Info.Columns, id)
+	if want := append(append([]string{}, def.Info.Columns...), WatermarkColumn); !reflect.DeepEqual(columns, want) {
+		t.Fatalf("columns = %v, want %v", columns, want)
+	}
+	if len(def.Info.Columns) != 4 {
+		t.Errorf("table columns changed: %v", def.Info.Columns)
+	}
+	row := TableRow{"Vendor": "Acme"}
+	mark(row)
+	format := ExportRowFormatter(e, def, columns)
+	e.WriteHeader(columns)
+	e.WriteRow(format(row))
+	e.Close()
+	if want := "Vendor,Amount,Paid,Bill Date,Export ID\nAcme,,,," + id + "\n"; buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+
+	columns, mark = WatermarkExport(e, def.Info.Columns, "")
+	row = TableRow{}
+	mark(row)
+	if len(columns) != 4 || len(row) != 0 {
+		t.Errorf("unmarked export: columns %v, row %v", columns, row)
+	}
+
+	// SQL scripts carry the ID as a comment, not a column
+	buf.Reset()
+	e, _ = NewExporter(ExportSQL, &buf, def)
+	columns, _ = WatermarkExport(e, def.Info.Columns, id)
+	if len(columns) != 4 {
+		t.Errorf("sql columns = %v", columns)
+	}
+	e.WriteHeader(columns)
+	e.Close()
+	if !strings.Contains(buf.String(), "\n-- Export ID: "+id+"\nBEGIN;") {
+		t.Errorf("sql = %s", buf.String())
+	}
+}
+
+func TestXLSXExporter_HiddenWatermark(t *testing.T) {
+	sheet := func(columns []string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		e, _ := NewExporter(ExportXLSX, &buf, exportTestTable())
+		if columns != nil {
+			e.WriteHeader(columns)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("output is not a zip: %v", err)
+		}
+		for _, f := range zr.File {
+			if f.Name == "xl/worksheets/sheet1.xml" {
+				rc, _ := f.Open()
+				b, _ := io.ReadAll(rc)
+				rc.Close()
+				return string(b)
+			}
+		}
+		t.Fatal("no worksheet")
+		return ""
+	}
+
+	got := sheet([]string{"Vendor", "Amount", WatermarkColumn})
+	if !strings.Contains(got, `<cols><col min="3" max="3" width="0" hidden="1"/></cols><sheetData><row>`) {
+		t.Errorf("watermark column not hidden: %s", got)
+	}
+	if got := sheet([]string{"Vendor"}); strings.Contains(got, "<cols>") || !strings.Contains(got, "<sheetData><row>") {
+		t.Errorf("unmarked sheet = %s", got)
+	}
+	if got := sheet(nil); !strings.HasSuffix(got, "<sheetData></sheetData></worksheet>") {
+		t.Errorf("empty sheet = %s", got)
+	}
+}
+
+func TestExportAuditParams_Watermark(t *testing.T) {
+	p := exportAuditParams(context.Background(), ExportRecord{
+		Kind:      ExportTableData,
+		TableKey:  "vendor_bills",
+		Format:    "xlsx",
+		Rows:      12,
+		Watermark: "EXP-0123456789abcdef",
+	})
+	if p.RowData["watermark"] != "EXP-0123456789abcdef" {
+		t.Errorf("row data = %v", p.RowData)
+	}
+	if want := "Exported 12 rows of vendor_bills as xlsx, watermarked EXP-0123456789abcdef"; p.Reason != want {
+		t.Errorf("reason = %q, want %q", p.Reason, want)
+	}
+}
@@ -0,0 +1,330 @@
+package core
+
+// exporter.go writes table exports in the formats /api/export offers. An
+// Exporter receives the header and then one formatted record at a time, so
+// exports stream from StreamTableData straight to the client whatever the
+// format; nothing holds the whole table in memory.
+//
+// Formats:
+//
+//	csv   RFC 4180 CSV, the header row first
+//	json  A JSON array of objects keyed by column. Numeric columns are
+//	      numbers, bool columns true/false and empty cells null; a value
+//	      that does not parse as its column's type (e.g. a masked one) is
+//	      kept as a string
+//	xlsx  An Excel workbook with one sheet, the header row first. Numeric
+//	      cells are numbers, everything else text. Written without shared
+//	      strings so rows can stream; limited to xlsxMaxRows rows
+//	sql   A script of INSERT statements loading the rows into the table,
+//	      in batches (see sql_export.go)
+//
+// Cells are written as their columns' DisplayFormat shows them (see
+// display_format.go). Formats may take options (ConfigureExporter) and
+// write cells from their stored values instead (CellFormatter). Further
+// formats are added with RegisterExporter.
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ExportFormat names a table export format.
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+	ExportXLSX ExportFormat = "xlsx"
+)
+
+var (
+	// ErrUnknownExportFormat is returned by NewExporter for an unregistered format.
+	ErrUnknownExportFormat = errors.New("unknown export format")
+
+	// ErrInvalidExportOption is returned by ConfigureExporter for options
+	// the format doesn't take or values it doesn't accept.
+	ErrInvalidExportOption = errors.New("invalid export option")
+)
+
+// Exporter writes one export file. WriteHeader is called once, before any
+// WriteRow, with the table's columns; each record has one value per column,
+// formatted by its DisplayFormat unless the Exporter is a CellFormatter (see
+// ExportRowFormatter). Close finishes the file and must be called even if no
+// rows were written. Flush pushes buffered output to the underlying writer
+// so a streaming response can send it.
+type Exporter interface {
+	ContentType() string
+	Extension() string // File extension without the dot
+	WriteHeader(columns []string) error
+	WriteRow(record []string) error
+	Flush() error
+	Close() error
+}
+
+// ExportOptions are format-specific export settings by name, such as the
+// sql format's batch size. The watermark option applies to every format
+// and is read by ExportWatermark, not the exporter.
+type ExportOptions map[string]string
+
+// ConfigurableExporter is an Exporter that takes ExportOptions.
+type ConfigurableExporter interface {
+	Exporter
+	Configure(opts ExportOptions) error
+}
+
+// CellFormatter is implemented by an Exporter that formats cells for its
+// records itself, instead of with the columns' DisplayFormat.
+type CellFormatter interface {
+	FormatCell(v any) string
+}
+
+// ExporterFunc creates an Exporter writing to w for a table.
+type ExporterFunc func(w io.Writer, def TableDefinition) Exporter
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[ExportFormat]ExporterFunc{
+		ExportCSV:  newCSVExporter,
+		ExportJSON: newJSONExporter,
+		ExportXLSX: newXLSXExporter,
+		ExportSQL:  newSQLExporter,
+	}
+)
+
+// RegisterExporter adds or replaces an export format.
+func RegisterExporter(format ExportFormat, fn ExporterFunc) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exporters[format] = fn
+}
+
+// ExportFormats returns the registered export formats, sorted.
+func ExportFormats() []ExportFormat {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	formats := make([]ExportFormat, 0, len(exporters))
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// NewExporter returns an Exporter for format writing to w. An empty format
+// is CSV.
+func NewExporter(format ExportFormat, w io.Writer, def TableDefinition) (Exporter, error) {
+	if format == "" {
+		format = ExportCSV
+	}
+	exportersMu.RLock()
+	fn, ok := exporters[format]
+	exportersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: %s)", ErrUnknownExportFormat, format, joinFormats(ExportFormats()))
+	}
+	return fn(w, def), nil
+}
+
+// ConfigureExporter applies opts to e before its header is written. Empty
+// options are ignored; any other is an error for a format without options.
+func ConfigureExporter(e Exporter, opts ExportOptions) error {
+	set := make(ExportOptions, len(opts))
+	for name, v := range opts {
+		if v != "" && name != WatermarkOption {
+			set[name] = v
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	c, ok := e.(ConfigurableExporter)
+	if !ok {
+		return fmt.Errorf("%w: the %s format takes no options", ErrInvalidExportOption, e.Extension())
+	}
+	return c.Configure(set)
+}
+
+// ExportRowFormatter returns a function giving the record e writes for a
+// row: one value per column, formatted by the column's DisplayFormat unless
+// e is a CellFormatter.
+func ExportRowFormatter(e Exporter, def TableDefinition, columns []string) func(row TableRow) []string {
+	if f, ok := e.(CellFormatter); ok {
+		return func(row TableRow) []string {
+			record := make([]string, len(columns))
+			for i, col := range columns {
+				record[i] = f.FormatCell(row[col])
+			}
+			return record
+		}
+	}
+	formats := ColumnFormats(def, columns)
+	return func(row TableRow) []string {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = formats[i].Format(row[col])
+		}
+		return record
+	}
+}
+
+func joinFormats(formats []ExportFormat) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// FormatExportCell formats a cell value as it is written to an export of
+// a column without display hints: numbers with up to 2 decimals, dates as
+// YYYY-MM-DD and bools as Yes/No (see DisplayFormat).
+func FormatExportCell(v any) string {
+	return DisplayFormat{}.Format(v)
+}
+
+// exportColumnTypes maps each FieldSpec and derived column name to its
+// type, for exporters that write typed values.
+func exportColumnTypes(def TableDefinition) map[string]FieldType {
+	types := make(map[string]FieldType, len(def.FieldSpecs)+len(def.Derived))
+	for _, spec := range def.FieldSpecs {
+		types[spec.Name] = spec.Type
+	}
+	for _, d := range def.Derived {
+		types[d.Name] = d.Type
+	}
+	return types
+}
+
+// exportNumber returns v as a JSON/XLSX number, or false if it is not one.
+func exportNumber(v string) (string, bool) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+// csvExporter writes CSV.
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer, _ TableDefinition) Exporter {
+	return &csvExporter{w: csv.NewWriter(w)}
+}
+
+func (e *csvExporter) ContentType() string                { return "text/csv" }
+func (e *csvExporter) Extension() string                  { return "csv" }
+func (e *csvExporter) WriteHeader(columns []string) error { return e.w.Write(columns) }
+func (e *csvExporter) WriteRow(record []string) error     { return e.w.Write(record) }
+
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error { return e.Flush() }
+
+// jsonExporter writes a JSON array of objects.
+type jsonExporter struct {
+	w         *bufio.Writer
+	def       TableDefinition
+	types     map[string]FieldType
+	keys      [][]byte // Encoded column names, with the trailing colon
+	colType   []FieldType
+	colFormat []DisplayFormat
+	rows      int
+}
+
+func newJSONExporter(w io.Writer, def TableDefinition) Exporter {
+	return &jsonExporter{w: bufio.NewWriter(w), def: def, types: exportColumnTypes(def)}
+}
+
+func (e *jsonExporter) ContentType() string { return "application/json" }
+func (e *jsonExporter) Extension() string   { return "json" }
+
+func (e *jsonExporter) WriteHeader(columns []string) error {
+	e.keys = make([][]byte, len(columns))
+	e.colType = make([]FieldType, len(columns))
+	for i, col := range columns {
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		e.keys[i] = append(key, ':')
+		e.colType[i] = e.types[col]
+	}
+	e.colFormat = ColumnFormats(e.def, columns)
+	_, err := e.w.WriteString("[")
+	return err
+}
+
+func (e *jsonExporter) WriteRow(record []string) error {
+	if e.rows > 0 {
+		e.w.WriteString(",")
+	}
+	e.w.WriteString("\n{")
+	for i, key := range e.keys {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.w.Write(key)
+		var v string
+		if i < len(record) {
+			v = record[i]
+		}
+		if err := e.writeValue(v, e.colType[i], e.colFormat[i]); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	_, err := e.w.WriteString("}")
+	return err
+}
+
+func (e *jsonExporter) writeValue(v string, t FieldType, format DisplayFormat) error {
+	if v == "" {
+		_, err := e.w.WriteString("null")
+		return err
+	}
+	switch t {
+	case FieldNumeric:
+		if n, ok := exportNumber(v); ok {
+			_, err := e.w.WriteString(n)
+			return err
+		}
+	case FieldBool:
+		if b, ok := format.parseBool(v); ok {
+			_, err := e.w.WriteString(strconv.FormatBool(b))
+			return err
+		}
+	}
+	s, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(s)
+	return err
+}
+
+func (e *jsonExporter) Flush() error { return e.w.Flush() }
+
+func (e *jsonExporter) Close() error {
+	if e.keys == nil {
+		e.w.WriteString("[")
+	}
+	if e.rows > 0 {
+		e.w.WriteString("\n")
+	}
+	e.w.WriteString("]\n")
+	return e.w.Flush()
+}
@@ -0,0 +1,306 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func exportTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "vendor_bills", Columns: []string{"Vendor", "Amount", "Paid", "Bill Date"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Vendor", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Paid", Type: FieldBool},
+			{Name: "Bill Date", Type: FieldDate},
+		},
+	}
+}
+
+var exportTestRows = [][]string{
+	{"Acme, Inc.", "1250.50", "Yes", "2024-03-01"},
+	{"<Globex> & \"Co\"", "", "No", ""},
+	{"007", "masked", "", "2024-03-02"},
+}
+
+func runExport(t *testing.T, format ExportFormat) (Exporter, []byte) {
+	t.Helper()
+	def := exportTestTable()
+	var buf bytes.Buffer
+	e, err := NewExporter(format, &buf, def)
+	if err != nil {
+		t.Fatalf("NewExporter(%q): %v", format, err)
+	}
+	if err := e.WriteHeader(def.Info.Columns); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, row := range exportTestRows {
+		if err := e.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return e, buf.Bytes()
+}
+
+func TestNewExporter_Formats(t *testing.T) {
+	if got := ExportFormats(); !reflect.DeepEqual(got, []ExportFormat{ExportCSV, ExportJSON, ExportSQL, ExportXLSX}) {
+		t.Errorf("ExportFormats() = %v", got)
+	}
+	e, err := NewExporter("", io.Discard, exportTestTable())
+	if err != nil || e.Extension() != "csv" {
+		t.Errorf("NewExporter(\"\") = %v, %v; want csv", e, err)
+	}
+	if _, err := NewExporter("pdf", io.Discard, exportTestTable()); !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("NewExporter(pdf) error = %v, want ErrUnknownExportFormat", err)
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	e, out := runExport(t, ExportCSV)
+	if e.ContentType() != "text/csv" {
+		t.Errorf("ContentType() = %q", e.ContentType())
+	}
+	want := "Vendor,Amount,Paid,Bill Date\n" +
+		"\"Acme, Inc.\",1250.50,Yes,2024-03-01\n" +
+		"\"<Globex> & \"\"Co\"\"\",,No,\n" +
+		"007,masked,,2024-03-02\n"
+	if string(out) != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	_, out := runExport(t, ExportJSON)
+	var got []map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	want := []map[string]any{
+		{"Vendor": "Acme, Inc.", "Amount": 1250.5, "Paid": true, "Bill Date": "2024-03-01"},
+		{"Vendor": "<Globex> & \"Co\"", "Amount": nil, "Paid": false, "Bill Date": nil},
+		{"Vendor": "007", "Amount": "masked", "Paid": nil, "Bill Date": "2024-03-02"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v\nwant %v", got, want)
+	}
+}
+
+func TestJSONExporter_Empty(t *testing.T) {
+	for _, header := range []bool{true, false} {
+		var buf bytes.Buffer
+		e, _ := NewExporter(ExportJSON, &buf, exportTestTable())
+		if header {
+			e.WriteHeader([]string{"Vendor"})
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != "[]" {
+			t.Errorf("empty export (header %v) = %q, want []", header, got)
+		}
+	}
+}
+
+func TestXLSXExporter(t *testing.T) {
+	_, out := runExport(t, ExportXLSX)
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatalf("output is not a zip: %v", err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `<sheet name="vendor_bills"`) {
+		t.Errorf("workbook = %s", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c t="inlineStr" s="1"><is><t xml:space="preserve">Bill Date</t></is></c>`,
+		`<c><v>1250.5</v></c>`,
+		`<t xml:space="preserve">&lt;Globex&gt; &amp; &#34;Co&#34;</t>`,
+		`<t xml:space="preserve">007</t>`,
+		`<t xml:space="preserve">masked</t>`,
+		`<c/>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %s", want)
+		}
+	}
+	if n := strings.Count(sheet, "<row>"); n != 4 {
+		t.Errorf("sheet has %d rows, want 4", n)
+	}
+}
+
+func TestSQLExporter(t *testing.T) {
+	e, out := runExport(t, ExportSQL)
+	if e.ContentType() != "application/sql" {
+		t.Errorf("ContentType() = %q", e.ContentType())
+	}
+	want := `-- vendor_bills: INSERT statements
+BEGIN;
+
+INSERT INTO "vendor_bills" ("vendor", "amount", "paid", "bill_date") VALUES
+  ('Acme, Inc.', 1250.50, TRUE, '2024-03-01'),
+  ('<Globex> & "Co"', NULL, FALSE, NULL),
+  ('007', 'masked', NULL, '2024-03-02');
+
+COMMIT;
+-- 3 rows
+`
+	if string(out) != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestSQLExporter_OnConflict(t *testing.T) {
+	def := exportTestTable()
+	def.Info.UniqueKey = []string{"Vendor"}
+	def.SoftDelete = true
+	export := func(opts ExportOptions) string {
+		t.Helper()
+		var buf bytes.Buffer
+		e, _ := NewExporter(ExportSQL, &buf, def)
+		if err := ConfigureExporter(e, opts); err != nil {
+			t.Fatalf("ConfigureExporter(%v): %v", opts, err)
+		}
+		e.WriteHeader(def.Info.Columns)
+		for _, row := range exportTestRows {
+			e.WriteRow(row)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		return buf.String()
+	}
+
+	out := export(ExportOptions{"batch": "2", "on_conflict": "update"})
+	for _, want := range []string{
+		"-- vendor_bills: INSERT statements, on_conflict=update\n",
+		`DELETE FROM "vendor_bills" WHERE ("vendor" IS NOT DISTINCT FROM 'Acme, Inc.' AND "deleted_at" IS NULL)` +
+			"\n  OR (\"vendor\" IS NOT DISTINCT FROM '<Globex> & \"Co\"' AND \"deleted_at\" IS NULL);\n",
+		`DELETE FROM "vendor_bills" WHERE ("vendor" IS NOT DISTINCT FROM '007' AND "deleted_at" IS NULL);`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("update script missing %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "INSERT INTO"); n != 2 {
+		t.Errorf("update script has %d INSERTs, want 2 batches", n)
+	}
+
+	out = export(ExportOptions{"batch": "2", "on_conflict": "nothing"})
+	want := `INSERT INTO "vendor_bills" ("vendor", "amount", "paid", "bill_date")
+SELECT '007', 'masked', NULL, '2024-03-02'
+WHERE NOT EXISTS (SELECT 1 FROM "vendor_bills" WHERE "vendor" IS NOT DISTINCT FROM '007' AND "deleted_at" IS NULL);`
+	if !strings.Contains(out, want) || strings.Count(out, "INSERT INTO") != 3 {
+		t.Errorf("nothing script:\n%s", out)
+	}
+
+	for _, opts := range []ExportOptions{{"batch": "0"}, {"batch": "x"}, {"on_conflict": "replace"}, {"delimiter": ";"}} {
+		e, _ := NewExporter(ExportSQL, io.Discard, def)
+		if err := ConfigureExporter(e, opts); !errors.Is(err, ErrInvalidExportOption) {
+			t.Errorf("ConfigureExporter(%v) = %v, want ErrInvalidExportOption", opts, err)
+		}
+	}
+	e, _ := NewExporter(ExportSQL, io.Discard, exportTestTable())
+	if err := ConfigureExporter(e, ExportOptions{"on_conflict": "nothing"}); !errors.Is(err, ErrInvalidExportOption) {
+		t.Errorf("on_conflict without a unique key: err = %v", err)
+	}
+	e, _ = NewExporter(ExportCSV, io.Discard, def)
+	if err := ConfigureExporter(e, ExportOptions{"batch": "10", "on_conflict": ""}); !errors.Is(err, ErrInvalidExportOption) {
+		t.Errorf("options for csv: err = %v", err)
+	}
+	if err := ConfigureExporter(e, ExportOptions{"on_conflict": ""}); err != nil {
+		t.Errorf("empty options for csv: %v", err)
+	}
+}
+
+func TestExportRowFormatter(t *testing.T) {
+	var amount pgtype.Numeric
+	amount.Scan("0.0725")
+	row := TableRow{"Amount": amount, "Paid": pgtype.Bool{Bool: true, Valid: true}, "Vendor": pgtype.Text{}}
+	columns := []string{"Vendor", "Amount", "Paid"}
+
+	def := exportTestTable()
+	csv, _ := NewExporter(ExportCSV, io.Discard, def)
+	if got := ExportRowFormatter(csv, def, columns)(row); !reflect.DeepEqual(got, []string{"", "0.07", "Yes"}) {
+		t.Errorf("csv record = %q", got)
+	}
+	sql, _ := NewExporter(ExportSQL, io.Discard, def)
+	if got := ExportRowFormatter(sql, def, columns)(row); !reflect.DeepEqual(got, []string{"", "0.0725", "true"}) {
+		t.Errorf("sql record = %q", got)
+	}
+
+	// Display hints apply to every format but sql
+	def.FieldSpecs[1].Display = DisplayFormat{Decimals: 3}
+	def.FieldSpecs[2].Display = DisplayFormat{TrueLabel: "Paid", FalseLabel: "Open"}
+	if got := ExportRowFormatter(csv, def, columns)(row); !reflect.DeepEqual(got, []string{"", "0.073", "Paid"}) {
+		t.Errorf("csv record with hints = %q", got)
+	}
+	if got := ExportRowFormatter(sql, def, columns)(row); !reflect.DeepEqual(got, []string{"", "0.0725", "true"}) {
+		t.Errorf("sql record with hints = %q", got)
+	}
+}
+
+func TestJSONExporter_BoolLabels(t *testing.T) {
+	def := exportTestTable()
+	def.FieldSpecs[2].Display = DisplayFormat{TrueLabel: "Paid", FalseLabel: "Open"}
+	var buf bytes.Buffer
+	e, _ := NewExporter(ExportJSON, &buf, def)
+	e.WriteHeader([]string{"Paid"})
+	for _, v := range []string{"Paid", "open", "true", "maybe"} {
+		e.WriteRow([]string{v})
+	}
+	e.Close()
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{true, false, true, "maybe"}
+	for i, row := range got {
+		if row["Paid"] != want[i] {
+			t.Errorf("row %d: Paid = %v, want %v", i, row["Paid"], want[i])
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	tests := map[string]string{
+		"vendor_bills":          "vendor_bills",
+		"a/b:c[d]":              "a_b_c_d_",
+		"":                      "Sheet1",
+		strings.Repeat("x", 40): strings.Repeat("x", 31),
+	}
+	for in, want := range tests {
+		if got := xlsxSheetName(in); got != want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
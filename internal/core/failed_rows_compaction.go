@@ -0,0 +1,275 @@
+package core
+
+// failed_rows_compaction.go shrinks old failed-row data.
+//
+// Every failed row keeps its full original CSV cells so users can download
+// and fix them, but after a few weeks those reports are rarely opened. The
+// compaction job, run with the archive scheduler, rewrites failed rows older
+// than a cutoff in one of two ways:
+//
+//   - compress:  cells are CSV-encoded and DEFLATE-compressed into
+//     row_data_gz; reads inflate them transparently
+//   - summarize: only the table's unique key cells are kept (other cells
+//     become empty), which is lossy but smallest. Uploads whose headers do
+//     not contain the key columns are compressed instead
+//
+// Rows are rewritten, never deleted, so failed-row counts stay intact.
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Failed-row compaction modes (FAILED_ROWS_COMPACT_MODE).
+const (
+	CompactCompress  = "compress"
+	CompactSummarize = "summarize"
+)
+
+// compressFailedRow CSV-encodes cells and DEFLATE-compresses the result.
+func compressFailedRow(cells []string) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(fw)
+	if err := cw.Write(cells); err != nil {
+		return nil, err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressFailedRow reverses compressFailedRow.
+func decompressFailedRow(data []byte) ([]string, error) {
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("inflate failed row: %w", err)
+	}
+	r := csv.NewReader(bytes.NewReader(raw))
+	r.FieldsPerRecord = -1
+	cells, err := r.Read()
+	if err == io.EOF {
+		return []string{}, nil // Row with no cells
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode failed row: %w", err)
+	}
+	return cells, nil
+}
+
+// failedRowData returns the original cells of a failed row, inflating
+// compressed rows.
+func failedRowData(rowData []string, compressed []byte) ([]string, error) {
+	if compressed == nil {
+		return rowData, nil
+	}
+	return decompressFailedRow(compressed)
+}
+
+// summaryPositions returns the header positions of the table's unique key
+// columns, or nil if the table has no key or the headers lack a key column.
+func summaryPositions(tableKey string, headers []string) []int {
+	def, ok := Get(tableKey)
+	if !ok || len(def.Info.UniqueKey) == 0 || len(headers) == 0 {
+		return nil
+	}
+	idx := MakeHeaderIndex(headers)
+	positions := make([]int, 0, len(def.Info.UniqueKey))
+	for _, col := range def.Info.UniqueKey {
+		pos, ok := idx[strings.ToLower(col)]
+		if !ok {
+			return nil
+		}
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+// summarizeFailedRow keeps only the cells at positions, blanking the rest
+// so the row still lines up with the upload's headers.
+func summarizeFailedRow(cells []string, positions []int) []string {
+	out := make([]string, len(cells))
+	for _, pos := range positions {
+		if pos < len(cells) {
+			out[pos] = cells[pos]
+		}
+	}
+	return out
+}
+
+// compactFailedRows compacts failed rows older than olderThanDays in
+// batches of batchSize and returns how many rows were rewritten.
+func (s *Service) compactFailedRows(ctx context.Context, olderThanDays, batchSize int, mode string) (int64, error) {
+	var total int64
+	for {
+		n, err := s.compactFailedRowsBatch(ctx, olderThanDays, batchSize, mode)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		if n < batchSize || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
+
+// compactFailedRowsBatch compacts up to batchSize rows in one transaction.
+func (s *Service) compactFailedRowsBatch(ctx context.Context, olderThanDays, batchSize int, mode string) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT f.id, f.row_data, u.name, u.csv_headers
+		FROM upload_failed_rows f
+		JOIN csv_uploads u ON u.id = f.upload_id
+		WHERE f.compacted IS NULL
+		  AND f.created_at < NOW() - make_interval(days => $1)
+		ORDER BY f.created_at
+		LIMIT $2
+		FOR UPDATE OF f SKIP LOCKED`, olderThanDays, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("select failed rows: %w", err)
+	}
+
+	type candidate struct {
+		id      pgtype.UUID
+		cells   []string
+		table   string
+		headers []string
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.cells, &c.table, &c.headers); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan failed row: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("select failed rows: %w", err)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	// Key positions depend only on the table and headers, shared by all
+	// rows of an upload
+	positions := make(map[string][]int)
+	batch := &pgx.Batch{}
+	for _, c := range candidates {
+		if mode == CompactSummarize {
+			cacheKey := c.table + "\x00" + strings.Join(c.headers, "\x00")
+			pos, ok := positions[cacheKey]
+			if !ok {
+				pos = summaryPositions(c.table, c.headers)
+				positions[cacheKey] = pos
+			}
+			if pos != nil {
+				batch.Queue(`UPDATE upload_failed_rows SET row_data = $2, compacted = 'summarized' WHERE id = $1`,
+					c.id, summarizeFailedRow(c.cells, pos))
+				continue
+			}
+		}
+
+		gz, err := compressFailedRow(c.cells)
+		if err != nil {
+			return 0, fmt.Errorf("compress failed row: %w", err)
+		}
+		batch.Queue(`UPDATE upload_failed_rows SET row_data = '{}', row_data_gz = $2, compacted = 'compressed' WHERE id = $1`,
+			c.id, gz)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return 0, fmt.Errorf("update failed rows: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(candidates), nil
+}
+
+// runFailedRowCompaction runs one compaction pass for the scheduler and
+// returns the rows compacted. Errors are logged and returned for the
+// retention operation.
+func (s *Service) runFailedRowCompaction(ctx context.Context, cfg ArchiveConfig) (int64, error) {
+	if cfg.FailedRowsCompactDays <= 0 {
+		return 0, nil
+	}
+	start := time.Now()
+	compacted, err := s.compactFailedRows(ctx, cfg.FailedRowsCompactDays, cfg.BatchSize, cfg.FailedRowsCompactMode)
+	if err != nil {
+		slog.Error("failed row compaction failed", "rows_compacted", compacted, "error", err)
+		return compacted, err
+	}
+	slog.Info("compacted old failed rows",
+		"rows_compacted", compacted,
+		"mode", cfg.FailedRowsCompactMode,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return compacted, nil
+}
+
+// storedFailedRow is a failed row as read back, with compacted data inflated.
+type storedFailedRow struct {
+	LineNumber int32
+	Reason     string
+	RowData    []string
+}
+
+// listFailedRows reads an upload's failed rows in line order, inflating
+// compressed rows. A limit of 0 returns all rows.
+func (s *Service) listFailedRows(ctx context.Context, uploadID pgtype.UUID, limit, offset int) ([]storedFailedRow, error) {
+	query := `
+		SELECT line_number, reason, row_data, row_data_gz
+		FROM upload_failed_rows
+		WHERE upload_id = $1
+		ORDER BY line_number`
+	args := []any{uploadID}
+	if limit > 0 {
+		query += " LIMIT $2 OFFSET $3"
+		args = append(args, limit, offset)
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []storedFailedRow
+	for rows.Next() {
+		var row storedFailedRow
+		var compressed []byte
+		if err := rows.Scan(&row.LineNumber, &row.Reason, &row.RowData, &compressed); err != nil {
+			return nil, err
+		}
+		if row.RowData, err = failedRowData(row.RowData, compressed); err != nil {
+			return nil, fmt.Errorf("line %d: %w", row.LineNumber, err)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
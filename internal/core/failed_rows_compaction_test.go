@@ -0,0 +1,73 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompressFailedRow_RoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"INV-1", "Acme, Inc.", `say "hi"`, "line1\nline2", ""},
+		{strings.Repeat("x", 10000)},
+		{},
+	}
+	for _, cells := range rows {
+		data, err := compressFailedRow(cells)
+		if err != nil {
+			t.Fatalf("compress: %v", err)
+		}
+		got, err := failedRowData([]string{}, data)
+		if err != nil {
+			t.Fatalf("decompress: %v", err)
+		}
+		if !reflect.DeepEqual(got, cells) {
+			t.Errorf("round trip = %q, want %q", got, cells)
+		}
+	}
+
+	if _, err := decompressFailedRow([]byte("not deflate")); err == nil {
+		t.Error("expected error for corrupt data")
+	}
+
+	plain := []string{"a", "b"}
+	if got, _ := failedRowData(plain, nil); !reflect.DeepEqual(got, plain) {
+		t.Errorf("uncompressed row = %q", got)
+	}
+}
+
+func TestSummarizeFailedRow(t *testing.T) {
+	Register(TableDefinition{
+		Info: TableInfo{Key: "compaction_test", Group: "Test", Label: "Compaction Test", UniqueKey: []string{"Invoice ID", "Line"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice ID", Type: FieldText},
+			{Name: "Line", Type: FieldText},
+			{Name: "Memo", Type: FieldText},
+		},
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "compaction_test")
+		registryMu.Unlock()
+	}()
+
+	headers := []string{"Memo", " invoice id ", "LINE"}
+	pos := summaryPositions("compaction_test", headers)
+	if !reflect.DeepEqual(pos, []int{1, 2}) {
+		t.Fatalf("positions = %v, want [1 2]", pos)
+	}
+	got := summarizeFailedRow([]string{"long memo", "INV-1", "3"}, pos)
+	if !reflect.DeepEqual(got, []string{"", "INV-1", "3"}) {
+		t.Errorf("summary = %q", got)
+	}
+	if got := summarizeFailedRow([]string{"short"}, pos); !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("short row summary = %q", got)
+	}
+
+	if pos := summaryPositions("compaction_test", []string{"Invoice ID", "Memo"}); pos != nil {
+		t.Errorf("missing key column: positions = %v, want nil", pos)
+	}
+	if pos := summaryPositions("no_such_table", headers); pos != nil {
+		t.Errorf("unknown table: positions = %v, want nil", pos)
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+// filter_groups.go combines column filters with OR groups and negation.
+//
+// A FilterSet stays a flat list of filters; each filter's Group path places
+// it in a tree of groups. Top-level filters (empty Group) are ANDed. The
+// filters and subgroups of a group are ORed, those of its subgroups ANDed,
+// and so on, alternating with depth. So
+//
+//	(Status = active OR Status = pending) AND Amount > 1000
+//
+// is Status eq active and Status eq pending in group "g" plus a top-level
+// Amount gt 1000, and (A AND B) OR C puts A and B in group "g.x" and C in
+// "g". A group segment starting with "!" negates that group, and a filter's
+// Negate negates the filter alone. Negations use IS NOT TRUE, so a negated
+// condition also matches rows where the column is empty.
+//
+// In URLs a filter is filter[col]=op:value or filter[col][group]=op:value,
+// with the value prefixed by "!" to negate it.
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MaxFilterGroupDepth is how deeply filter groups may nest.
+const MaxFilterGroupDepth = 4
+
+// ValidFilterGroup reports whether group is a usable Group path: empty, or
+// up to MaxFilterGroupDepth dot-separated names of letters, digits, "_" or
+// "-", each optionally prefixed by "!".
+func ValidFilterGroup(group string) bool {
+	if group == "" {
+		return true
+	}
+	segments := strings.Split(group, ".")
+	if len(segments) > MaxFilterGroupDepth {
+		return false
+	}
+	for _, seg := range segments {
+		seg = strings.TrimPrefix(seg, "!")
+		if seg == "" || len(seg) > 32 {
+			return false
+		}
+		for _, r := range seg {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParseFilterValue splits a filter parameter value, "op:value" or
+// "!op:value", into its operator, value and negation.
+func ParseFilterValue(s string) (FilterOperator, string, bool, bool) {
+	negate := strings.HasPrefix(s, "!")
+	op, value, ok := strings.Cut(strings.TrimPrefix(s, "!"), ":")
+	if !ok {
+		return "", "", false, false
+	}
+	return FilterOperator(op), value, negate, true
+}
+
+// Param returns the URL query parameter name and value for the filter.
+func (f ColumnFilter) Param() (string, string) {
+	key := "filter[" + f.Column + "]"
+	if f.Group != "" {
+		key += "[" + f.Group + "]"
+	}
+	value := string(f.Operator) + ":" + f.Value
+	if f.Negate {
+		value = "!" + value
+	}
+	return key, value
+}
+
+// QueryParams returns the filters as URL query parameters, each preceded
+// by "&", or "" if there are none.
+func (fs FilterSet) QueryParams() string {
+	var b strings.Builder
+	for _, f := range fs.Filters {
+		key, value := f.Param()
+		b.WriteString("&" + url.QueryEscape(key) + "=" + url.QueryEscape(value))
+	}
+	return b.String()
+}
+
+// filterNode is a group of filters and subgroups.
+type filterNode struct {
+	negate   bool
+	filters  []ColumnFilter
+	children []*filterNode
+	byName   map[string]*filterNode
+}
+
+// filterTree arranges filters into groups by their Group paths, keeping
+// the order in which filters and groups first appear.
+func filterTree(filters []ColumnFilter) *filterNode {
+	root := &filterNode{}
+	for _, f := range filters {
+		n := root
+		if f.Group != "" {
+			for _, seg := range strings.Split(f.Group, ".") {
+				n = n.child(seg)
+			}
+		}
+		n.filters = append(n.filters, f)
+	}
+	return root
+}
+
+func (n *filterNode) child(name string) *filterNode {
+	if c, ok := n.byName[name]; ok {
+		return c
+	}
+	if n.byName == nil {
+		n.byName = make(map[string]*filterNode)
+	}
+	c := &filterNode{negate: strings.HasPrefix(name, "!")}
+	n.byName[name] = c
+	n.children = append(n.children, c)
+	return c
+}
+
+// conditions returns the SQL conditions of a node's members, which the
+// caller combines (AND at even depths, OR at odd ones).
+func (n *filterNode) conditions(depth int, argIdx int) ([]string, []interface{}, int) {
+	var conds []string
+	var args []interface{}
+	for _, f := range n.filters {
+		cond, filterArgs, next := buildSingleFilter(f, argIdx)
+		if cond == "" {
+			continue
+		}
+		if f.Negate {
+			cond = negateCondition(cond)
+		}
+		conds = append(conds, cond)
+		args = append(args, filterArgs...)
+		argIdx = next
+	}
+	for _, c := range n.children {
+		cond, childArgs, next := c.sql(depth+1, argIdx)
+		if cond == "" {
+			continue
+		}
+		conds = append(conds, cond)
+		args = append(args, childArgs...)
+		argIdx = next
+	}
+	return conds, args, argIdx
+}
+
+// sql returns a group's condition, or "" if it has no valid filters.
+func (n *filterNode) sql(depth int, argIdx int) (string, []interface{}, int) {
+	conds, args, next := n.conditions(depth, argIdx)
+	if len(conds) == 0 {
+		return "", nil, argIdx
+	}
+	joiner := " AND "
+	if depth%2 == 1 {
+		joiner = " OR "
+	}
+	cond := "(" + strings.Join(conds, joiner) + ")"
+	if n.negate {
+		cond = negateCondition(cond)
+	}
+	return cond, args, next
+}
+
+// negateCondition returns a condition true wherever cond is false or NULL.
+func negateCondition(cond string) string {
+	return fmt.Sprintf("(%s) IS NOT TRUE", cond)
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+var testFixedWidthLayout = FixedWidthLayout{
+	Columns: []FixedWidthColumn{
+		{Name: "id", Start: 1, Length: 4},
+		{Name: "name", Start: 5, Length: 10},
+		{Name: "amount", Start: 15, Length: 8},
+	},
+	SkipLines: 1,
+}
+
+func TestFixedWidthLayout_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  FixedWidthLayout
+		wantErr string
+	}{
+		{"valid", testFixedWidthLayout, ""},
+		{"no columns", FixedWidthLayout{}, "no columns"},
+		{"negative skip", FixedWidthLayout{Columns: testFixedWidthLayout.Columns, SkipLines: -1}, "skiplines"},
+		{"unnamed", FixedWidthLayout{Columns: []FixedWidthColumn{{Start: 1, Length: 2}}}, "name"},
+		{"duplicate name", FixedWidthLayout{Columns: []FixedWidthColumn{
+			{Name: "a", Start: 1, Length: 2}, {Name: "A", Start: 3, Length: 2},
+		}}, "duplicate"},
+		{"zero start", FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "a", Start: 0, Length: 2}}}, "start"},
+		{"zero length", FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "a", Start: 1, Length: 0}}}, "length"},
+		{"overlap", FixedWidthLayout{Columns: []FixedWidthColumn{
+			{Name: "a", Start: 1, Length: 5}, {Name: "b", Start: 5, Length: 2},
+		}}, "overlap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.layout.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidFixedWidth) {
+				t.Fatalf("Validate() = %v, want ErrInvalidFixedWidth", err)
+			}
+			if !strings.Contains(strings.ToLower(err.Error()), tt.wantErr) {
+				t.Errorf("Validate() = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFixedWidthLayout_Slice(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"0001Alice       123.45", []string{"0001", "Alice", "123.45"}},
+		{"0002Bob", []string{"0002", "Bob", ""}},
+		{"00", []string{"00", "", ""}},
+		{"0003Zoë     ü    9.50", []string{"0003", "Zoë     ü", "9.50"}},
+	}
+
+	for _, tt := range tests {
+		got := testFixedWidthLayout.Slice(tt.line)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Slice(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFixedWidthReader(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	input := "CUSTOMER EXTRACT 2024-01-31\r\n" +
+		"0001Alice       123.45\r\n" +
+		"\r\n" +
+		"0002Bob, Jr.     -7.00\r\n" +
+		"0003Carol"
+
+	out, err := io.ReadAll(s.FixedWidthReader(strings.NewReader(input), testFixedWidthLayout))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	want := "id,name,amount\n" +
+		"0001,Alice,123.45\n" +
+		"0002,\"Bob, Jr.\",-7.00\n" +
+		"0003,Carol,\n"
+	if string(out) != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestFixedWidthReader_Gzip(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	layout := FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "id", Start: 1, Length: 4}}}
+	input := gzipBytes(t, []byte("0001\n0002\n"))
+
+	out, err := io.ReadAll(s.FixedWidthReader(strings.NewReader(string(input)), layout))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "id\n0001\n0002\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestPreviewFixedWidth(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	input := "CUSTOMER EXTRACT\n0001Alice       123.45\n\n0002Bob          9.00\n0003Carol        1.00\n"
+
+	preview, err := s.PreviewFixedWidth(strings.NewReader(input), testFixedWidthLayout, 4)
+	if err != nil {
+		t.Fatalf("PreviewFixedWidth: %v", err)
+	}
+
+	if want := "|---|---------|-------"; preview.Ruler != want {
+		t.Errorf("Ruler = %q, want %q", preview.Ruler, want)
+	}
+	if len(preview.Lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(preview.Lines))
+	}
+	for i, skipped := range []bool{true, false, true, false} {
+		if preview.Lines[i].Skipped != skipped {
+			t.Errorf("line %d skipped = %v, want %v", i+1, preview.Lines[i].Skipped, skipped)
+		}
+	}
+	if got := preview.Lines[3].Fields; len(got) != 3 || got[1] != "Bob" {
+		t.Errorf("line 4 fields = %q", got)
+	}
+
+	if _, err := s.PreviewFixedWidth(strings.NewReader(input), FixedWidthLayout{}, 0); !errors.Is(err, ErrInvalidFixedWidth) {
+		t.Errorf("empty layout error = %v, want ErrInvalidFixedWidth", err)
+	}
+}
+
+func TestFixedWidthMapping(t *testing.T) {
+	def := TableDefinition{Info: TableInfo{Key: "customers", Columns: []string{"Name", "Amount", "Region"}}}
+
+	got, err := fixedWidthMapping(def, testFixedWidthLayout, nil)
+	if err != nil {
+		t.Fatalf("fixedWidthMapping: %v", err)
+	}
+	if len(got) != 2 || got["Name"] != 1 || got["Amount"] != 2 {
+		t.Errorf("mapping = %v, want Name:1 Amount:2", got)
+	}
+
+	if _, err := fixedWidthMapping(def, testFixedWidthLayout, map[string]int{"Region": 3}); !errors.Is(err, ErrInvalidFixedWidth) {
+		t.Errorf("out of range mapping error = %v, want ErrInvalidFixedWidth", err)
+	}
+
+	other := FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "x", Start: 1, Length: 1}}}
+	if _, err := fixedWidthMapping(def, other, nil); !errors.Is(err, ErrInvalidFixedWidth) {
+		t.Errorf("unmatched layout error = %v, want ErrInvalidFixedWidth", err)
+	}
+}
@@ -21,6 +21,17 @@ const TemplateMatchThreshold = 0.7
 // Used when the client doesn't specify a page size.
 const DefaultPageSize = 25
 
+// DefaultMaxPageSize caps table view page sizes when QUERY_MAX_PAGE_SIZE is 0.
+const DefaultMaxPageSize = 500
+
+// DefaultMaxSortLevels caps the sort columns applied to table data when
+// QUERY_MAX_SORT_LEVELS is 0.
+const DefaultMaxSortLevels = 4
+
+// DefaultMaxSummaryGroups caps the groups a grouped summary returns when
+// QUERY_MAX_SUMMARY_GROUPS is 0.
+const DefaultMaxSummaryGroups = 10000
+
 // DefaultHistoryLimit is the default number of history entries to retrieve.
 // Applies to upload history, audit log, and similar paginated lists.
 const DefaultHistoryLimit = 50
@@ -95,16 +106,13 @@ func (w *WhereBuilder) AddSearch(query string, specs []FieldSpec) {
 	}
 }
 
-// AddFilters adds column filter conditions (AND together).
+// AddFilters adds column filter conditions. Top-level filters and groups
+// are ANDed together; see filter_groups.go for OR groups and negation.
 func (w *WhereBuilder) AddFilters(filters FilterSet) {
-	for _, f := range filters.Filters {
-		condition, filterArgs, newArgIdx := buildSingleFilter(f, w.argIndex)
-		if condition != "" {
-			w.conditions = append(w.conditions, condition)
-			w.args = append(w.args, filterArgs...)
-			w.argIndex = newArgIdx
-		}
-	}
+	conditions, filterArgs, newArgIdx := filterTree(filters.Filters).conditions(0, w.argIndex)
+	w.conditions = append(w.conditions, conditions...)
+	w.args = append(w.args, filterArgs...)
+	w.argIndex = newArgIdx
 }
 
 // Build returns the WHERE clause string and arguments.
@@ -131,6 +139,13 @@ func (w *WhereBuilder) AddUploadID(uploadID string) {
 	w.argIndex++
 }
 
+// AddLiveRows skips soft-deleted rows of def (see liveRowsCondition).
+func (w *WhereBuilder) AddLiveRows(def TableDefinition) {
+	if cond := liveRowsCondition(def, ""); cond != "" {
+		w.conditions = append(w.conditions, cond)
+	}
+}
+
 // Add adds a column = $N condition if the value is non-empty.
 // The column name is used directly (should be a valid SQL identifier).
 func (w *WhereBuilder) Add(column, value string) {
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
 )
 
 // ============================================================================
@@ -381,6 +384,81 @@ func TestWhereBuilder_AddFilters(t *testing.T) {
 			wantArgsCount: 2,
 			wantArgs:      []interface{}{"active", "%user%"},
 		},
+		{
+			name: "OR group ANDed with top-level filter",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: OpEquals, Value: "active", Group: "g"},
+					{DBColumn: "status", Operator: OpEquals, Value: "pending", Group: "g"},
+					{DBColumn: "amount", Operator: OpGreater, Value: "1000"},
+				},
+			},
+			wantClause:    ` WHERE "amount" > $1 AND ("status" = $2 OR "status" = $3)`,
+			wantArgsCount: 3,
+			wantArgs:      []interface{}{"1000", "active", "pending"},
+		},
+		{
+			name: "separate OR groups",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: OpEquals, Value: "active", Group: "a"},
+					{DBColumn: "region", Operator: OpEquals, Value: "EU", Group: "b"},
+					{DBColumn: "status", Operator: OpEquals, Value: "pending", Group: "a"},
+					{DBColumn: "region", Operator: OpEquals, Value: "US", Group: "b"},
+				},
+			},
+			wantClause:    ` WHERE ("status" = $1 OR "status" = $2) AND ("region" = $3 OR "region" = $4)`,
+			wantArgsCount: 4,
+			wantArgs:      []interface{}{"active", "pending", "EU", "US"},
+		},
+		{
+			name: "nested AND group inside OR group",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: OpEquals, Value: "active", Group: "g.x"},
+					{DBColumn: "amount", Operator: OpGreater, Value: "1000", Group: "g.x"},
+					{DBColumn: "vip", Operator: OpEquals, Value: "true", Group: "g"},
+				},
+			},
+			wantClause:    ` WHERE ("vip" = $1 OR ("status" = $2 AND "amount" > $3))`,
+			wantArgsCount: 3,
+			wantArgs:      []interface{}{"true", "active", "1000"},
+		},
+		{
+			name: "negated filter",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: OpEquals, Value: "closed", Negate: true},
+				},
+			},
+			wantClause:    ` WHERE ("status" = $1) IS NOT TRUE`,
+			wantArgsCount: 1,
+			wantArgs:      []interface{}{"closed"},
+		},
+		{
+			name: "negated group",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: OpEquals, Value: "closed", Group: "!g"},
+					{DBColumn: "status", Operator: OpIn, Value: "void,draft", Group: "!g"},
+				},
+			},
+			wantClause:    ` WHERE (("status" = $1 OR "status" IN ($2, $3))) IS NOT TRUE`,
+			wantArgsCount: 3,
+			wantArgs:      []interface{}{"closed", "void", "draft"},
+		},
+		{
+			name: "group without valid filters is skipped",
+			filters: FilterSet{
+				Filters: []ColumnFilter{
+					{DBColumn: "status", Operator: "bogus", Value: "x", Group: "g"},
+					{DBColumn: "amount", Operator: OpLess, Value: "5"},
+				},
+			},
+			wantClause:    ` WHERE "amount" < $1`,
+			wantArgsCount: 1,
+			wantArgs:      []interface{}{"5"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -789,6 +867,30 @@ func TestBuildSingleFilter(t *testing.T) {
 			wantArgs:    []interface{}{"active"},
 			wantNextIdx: 6,
 		},
+		{
+			name:        "not equals also matches empty values",
+			filter:      ColumnFilter{DBColumn: "status", Operator: OpNotEquals, Value: "closed"},
+			argIdx:      2,
+			wantSQL:     `"status" IS DISTINCT FROM $2`,
+			wantArgs:    []interface{}{"closed"},
+			wantNextIdx: 3,
+		},
+		{
+			name:        "is empty on text counts empty strings",
+			filter:      ColumnFilter{DBColumn: "notes", Operator: OpIsNull, Type: FieldText},
+			argIdx:      1,
+			wantSQL:     `NULLIF("notes", '') IS NULL`,
+			wantArgs:    nil,
+			wantNextIdx: 1,
+		},
+		{
+			name:        "is not empty takes no argument",
+			filter:      ColumnFilter{DBColumn: "amount", Operator: OpNotNull, Type: FieldNumeric},
+			argIdx:      4,
+			wantSQL:     `"amount" IS NOT NULL`,
+			wantArgs:    nil,
+			wantNextIdx: 4,
+		},
 		{
 			name:        "unknown operator returns empty",
 			filter:      ColumnFilter{DBColumn: "col", Operator: "unknown", Value: "val"},
@@ -893,3 +995,154 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+// ============================================================================
+// Page Size Tests
+// ============================================================================
+
+func TestClampPageSize(t *testing.T) {
+	s := &Service{cfg: &config.Config{Query: config.QueryConfig{MinPageSize: 10, MaxPageSize: 100}}}
+	tests := []struct{ in, want int }{
+		{0, DefaultPageSize},
+		{-5, DefaultPageSize},
+		{5, 10},
+		{50, 50},
+		{1000, 100},
+	}
+	for _, tt := range tests {
+		if got := s.ClampPageSize(tt.in); got != tt.want {
+			t.Errorf("ClampPageSize(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	// Zero limits fall back to 1..DefaultMaxPageSize
+	s = &Service{cfg: &config.Config{}}
+	if got := s.ClampPageSize(1); got != 1 {
+		t.Errorf("ClampPageSize(1) = %d, want 1", got)
+	}
+	if got := s.ClampPageSize(1 << 20); got != DefaultMaxPageSize {
+		t.Errorf("ClampPageSize(1<<20) = %d, want %d", got, DefaultMaxPageSize)
+	}
+}
+
+// ============================================================================
+// Sort Tests
+// ============================================================================
+
+func TestOrderByClause(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "recon", Columns: []string{"Customer", "Invoice", "Line", "Date", "Amount"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice", DBColumn: "invoice_no"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		sorts     []SortSpec
+		maxLevels int
+		want      string
+		wantSorts []SortSpec
+	}{
+		{
+			name:      "default",
+			maxLevels: 4,
+			want:      `"customer" asc`,
+			wantSorts: []SortSpec{{Column: "Customer", Dir: "asc"}},
+		},
+		{
+			name: "four levels with null ordering",
+			sorts: []SortSpec{
+				{Column: "Customer", Dir: "asc"},
+				{Column: "Invoice", Dir: "DESC", Nulls: "last"},
+				{Column: "Line", Dir: "asc", Nulls: "FIRST"},
+				{Column: "Date", Dir: "sideways", Nulls: "middle"},
+			},
+			maxLevels: 4,
+			want:      `"customer" asc, "invoice_no" desc NULLS LAST, "line" asc NULLS FIRST, "date" asc`,
+			wantSorts: []SortSpec{
+				{Column: "Customer", Dir: "asc"},
+				{Column: "Invoice", Dir: "desc", Nulls: "last"},
+				{Column: "Line", Dir: "asc", Nulls: "first"},
+				{Column: "Date", Dir: "asc"},
+			},
+		},
+		{
+			name: "capped, skipping unknown and repeated columns",
+			sorts: []SortSpec{
+				{Column: "Nope", Dir: "asc"},
+				{Column: "Amount", Dir: "desc"},
+				{Column: "Amount", Dir: "asc"},
+				{Column: "Date", Dir: "asc"},
+				{Column: "Line", Dir: "asc"},
+			},
+			maxLevels: 2,
+			want:      `"amount" desc, "date" asc`,
+			wantSorts: []SortSpec{{Column: "Amount", Dir: "desc"}, {Column: "Date", Dir: "asc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotSorts := orderByClause(def, tt.sorts, tt.maxLevels)
+			if got != tt.want {
+				t.Errorf("clause = %s, want %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(gotSorts, tt.wantSorts) {
+				t.Errorf("sorts = %+v, want %+v", gotSorts, tt.wantSorts)
+			}
+		})
+	}
+}
+
+func TestMaxSortLevels(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	if got := s.MaxSortLevels(); got != DefaultMaxSortLevels {
+		t.Errorf("MaxSortLevels() = %d, want %d", got, DefaultMaxSortLevels)
+	}
+	s.cfg.Query.MaxSortLevels = 6
+	if got := s.MaxSortLevels(); got != 6 {
+		t.Errorf("MaxSortLevels() = %d, want 6", got)
+	}
+}
+
+func TestValidFilterGroup(t *testing.T) {
+	tests := []struct {
+		group string
+		want  bool
+	}{
+		{"", true},
+		{"g", true},
+		{"!g", true},
+		{"status_or.!x-1", true},
+		{"a.b.c.d", true},
+		{"a.b.c.d.e", false},
+		{"g.", false},
+		{"!", false},
+		{"g x", false},
+		{"g]", false},
+	}
+	for _, tt := range tests {
+		if got := ValidFilterGroup(tt.group); got != tt.want {
+			t.Errorf("ValidFilterGroup(%q) = %v, want %v", tt.group, got, tt.want)
+		}
+	}
+}
+
+func TestFilterSet_QueryParams(t *testing.T) {
+	fs := FilterSet{Filters: []ColumnFilter{
+		{Column: "Status", Operator: OpEquals, Value: "active", Group: "g"},
+		{Column: "Amount", Operator: OpGreater, Value: "1000", Negate: true},
+	}}
+	want := "&filter%5BStatus%5D%5Bg%5D=eq%3Aactive&filter%5BAmount%5D=%21gt%3A1000"
+	if got := fs.QueryParams(); got != want {
+		t.Errorf("QueryParams() = %q, want %q", got, want)
+	}
+
+	op, value, negate, ok := ParseFilterValue("!gt:1000")
+	if !ok || op != OpGreater || value != "1000" || !negate {
+		t.Errorf("ParseFilterValue = %q, %q, %v, %v", op, value, negate, ok)
+	}
+	if _, _, _, ok := ParseFilterValue("gt"); ok {
+		t.Error("ParseFilterValue without operator separator should fail")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // RecordCellEdit logs a cell edit to the audit log.
@@ -20,6 +23,39 @@ func (s *Service) RecordCellEdit(ctx context.Context, tableKey, rowKey, column,
 	return err
 }
 
+// recordCellEdits logs many cell edits within tx with one COPY, as
+// RecordCellEdit would one at a time, linked by batchID.
+func recordCellEdits(ctx context.Context, tx pgx.Tx, tableKey, batchID string, changes []BulkEditChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	user := withContextUser(ctx, AuditLogParams{})
+	severity := string(determineSeverity(ActionCellEdit))
+	rows := make([][]any, len(changes))
+	for i, c := range changes {
+		rows[i] = []any{
+			string(ActionCellEdit), severity, tableKey,
+			ToPgText(user.UserID), ToPgText(user.UserEmail), ToPgText(user.UserName),
+			ToPgText(c.RowKey), ToPgText(c.Column), ToPgText(c.OldValue), ToPgText(c.NewValue),
+			ToPgInt4(1), ToPgUUID(batchID),
+		}
+	}
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"audit_log"},
+		[]string{
+			"action", "severity", "table_key",
+			"user_id", "user_email", "user_name",
+			"row_key", "column_name", "old_value", "new_value",
+			"rows_affected", "batch_id",
+		},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("record cell edits: %w", err)
+	}
+	return nil
+}
+
 // RecordRowDelete logs a row deletion to the audit log.
 func (s *Service) RecordRowDelete(ctx context.Context, tableKey, rowKey string, rowData map[string]interface{}) error {
 	_, err := s.LogAudit(ctx, AuditLogParams{
@@ -50,24 +86,21 @@ func (s *Service) getCellValue(ctx context.Context, tableKey string, def TableDe
 		args[i] = keyParts[i]
 	}
 
+	// Postgres's text form (2024-01-31, 1200.50, true) is what an edit
+	// would accept, so recorded old values can be written back by undo
 	query := fmt.Sprintf(
-		"SELECT %s FROM %s WHERE %s",
+		"SELECT %s::text FROM %s WHERE %s%s",
 		quoteIdentifier(dbCol),
 		quoteIdentifier(tableKey),
 		strings.Join(conditions, " AND "),
+		andLiveRows(def, ""),
 	)
 
-	var value interface{}
-	err := s.pool.QueryRow(ctx, query, args...).Scan(&value)
-	if err != nil {
+	var value pgtype.Text
+	if err := s.pool.QueryRow(ctx, query, args...).Scan(&value); err != nil {
 		return "", err
 	}
-
-	// Convert to string
-	if value == nil {
-		return "", nil
-	}
-	return fmt.Sprintf("%v", value), nil
+	return value.String, nil
 }
 
 // getRowData fetches all column data for a row.
@@ -100,10 +133,11 @@ func (s *Service) getRowData(ctx context.Context, tableKey, rowKey string) (map[
 	}
 
 	query := fmt.Sprintf(
-		"SELECT %s FROM %s WHERE %s",
+		"SELECT %s FROM %s WHERE %s%s",
 		strings.Join(quotedCols, ", "),
 		quoteIdentifier(tableKey),
 		strings.Join(conditions, " AND "),
+		andLiveRows(def, ""),
 	)
 
 	rows, err := s.pool.Query(ctx, query, args...)
@@ -0,0 +1,283 @@
+package core
+
+// insert_row.go adds single rows typed into the table view, or cloned from
+// an existing row with some values changed. A row is validated as an
+// upload validates it: the FieldSpec checks, then the table's ValidateRow
+// and ValidateBatch rules, the batch being just this row. Its key is
+// checked against the table's live rows under the lock restores take (see
+// row_restore.go), so an insert and a restore can't both add the same key.
+// The row is recorded as a row_insert audit entry with its values, which
+// starts its row history.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrRowNotFound is returned by CloneRow when the row to clone is not in
+// the table.
+var ErrRowNotFound = errors.New("row not found")
+
+// InsertRowResult contains the result of InsertRow. A row that fails
+// validation or whose key is taken is not inserted.
+type InsertRowResult struct {
+	Success         bool              `json:"success"`
+	RowKey          string            `json:"rowKey,omitempty"`
+	FieldErrors     map[string]string `json:"fieldErrors,omitempty"` // Column -> why its value is invalid
+	ValidationError string            `json:"validationError,omitempty"`
+	DuplicateKey    bool              `json:"duplicateKey,omitempty"`
+}
+
+// InsertRow inserts one row, with values keyed by display column. Columns
+// left out are NULL (or their database default).
+func (s *Service) InsertRow(ctx context.Context, tableKey string, values map[string]string) (*InsertRowResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	return s.insertRow(ctx, def, values, "")
+}
+
+// CloneRow inserts a copy of the row with key rowKey, with values keyed by
+// display column replacing its values; an empty value clears the column.
+// The copy is validated as InsertRow validates a row, so values must give
+// it a new unique key.
+func (s *Service) CloneRow(ctx context.Context, tableKey, rowKey string, values map[string]string) (*InsertRowResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	if len(def.Info.UniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key", tableKey)
+	}
+
+	current := make(map[string][]string, 1)
+	if err := s.currentRowText(ctx, def, []string{rowKey}, current); err != nil {
+		return nil, fmt.Errorf("read row: %w", err)
+	}
+	source, ok := current[rowKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRowNotFound, rowKey)
+	}
+	return s.insertRow(ctx, def, cloneRowValues(def, source, values), "Cloned from "+rowKey)
+}
+
+// cloneRowValues returns the values of source, a row as currentRowText
+// reads it, with values replacing them. Empty values are left out, so the
+// copy has NULL there.
+func cloneRowValues(def TableDefinition, source []string, values map[string]string) map[string]string {
+	row := make(map[string]string, len(source))
+	for i, col := range def.Info.Columns {
+		if i < len(source) && source[i] != "" {
+			row[col] = source[i]
+		}
+	}
+	for col, value := range values {
+		if spec, _ := editColumn(def, col); spec != nil {
+			col = spec.Name
+		}
+		if value == "" {
+			delete(row, col)
+		} else {
+			row[col] = value
+		}
+	}
+	return row
+}
+
+// insertRow validates and inserts one row; reason is recorded with it.
+func (s *Service) insertRow(ctx context.Context, def TableDefinition, values map[string]string, reason string) (*InsertRowResult, error) {
+	tableKey := def.Info.Key
+	defer s.invalidateQueryCache(tableKey)
+
+	row, fieldErrors := insertRowValues(def, values)
+	if len(fieldErrors) > 0 {
+		return &InsertRowResult{FieldErrors: fieldErrors}, nil
+	}
+	if len(row) == 0 {
+		return &InsertRowResult{ValidationError: "no values given"}, nil
+	}
+	if err := insertRowRules(def, values); err != nil {
+		return &InsertRowResult{ValidationError: err.Error()}, nil
+	}
+	rowKey, keyParts := insertRowKey(def, row)
+	result := &InsertRowResult{RowKey: rowKey}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer s.rollbackTx(ctx, tx)
+
+	// Rows with an empty key part are never duplicates, as in uploads
+	if keyParts != nil {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "row_restore:"+tableKey); err != nil {
+			return nil, fmt.Errorf("lock table keys: %w", err)
+		}
+		var exists bool
+		if err := tx.QueryRow(ctx, insertRowKeyExistsSQL(def), keyParts...).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("duplicate check failed: %w", err)
+		}
+		if exists {
+			result.DuplicateKey = true
+			return result, nil
+		}
+	}
+
+	var cols, placeholders []string
+	var args []interface{}
+	for i := range def.FieldSpecs {
+		spec := &def.FieldSpecs[i]
+		value, ok := row[spec.Name]
+		if !ok {
+			continue
+		}
+		_, dbCol := editColumn(def, spec.Name)
+		args = append(args, cellDBValue(value, spec))
+		cols = append(cols, quoteIdentifier(dbCol))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(tableKey), strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+	if _, err := tx.Exec(ctx, insertSQL, args...); err != nil {
+		return nil, fmt.Errorf("insert failed: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	rowData := make(map[string]interface{}, len(row))
+	for col, value := range row {
+		rowData[col] = value
+	}
+	// The row is committed, so a failed entry is logged rather than
+	// reported as a failed insert
+	if _, err := s.LogAudit(ctx, AuditLogParams{
+		Action:       ActionRowInsert,
+		TableKey:     tableKey,
+		RowKey:       rowKey,
+		RowData:      rowData,
+		RowsAffected: 1,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       reason,
+	}); err != nil {
+		slog.Error("failed to log row insert audit",
+			"table", tableKey,
+			"row_key", rowKey,
+			"error", err,
+		)
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// insertRowValues returns the values of a new row keyed by display column,
+// cleaned and normalized as an upload stores them, or why each invalid
+// value is rejected. Required columns must be given.
+func insertRowValues(def TableDefinition, values map[string]string) (map[string]string, map[string]string) {
+	row := make(map[string]string, len(values))
+	errs := make(map[string]string)
+	for col, raw := range values {
+		spec, _ := editColumn(def, col)
+		if spec == nil {
+			errs[col] = "unknown column"
+			continue
+		}
+		value := CleanCell(raw)
+		if spec.Normalizer != nil && value != "" {
+			value = spec.Normalizer(value)
+		}
+		row[spec.Name] = value
+	}
+
+	for _, spec := range def.FieldSpecs {
+		value := row[spec.Name]
+		if value == "" {
+			if spec.Required && !spec.AllowEmpty {
+				errs[spec.Name] = "required field is empty"
+			}
+			continue
+		}
+		if err := validateCellValue(value, spec); err != nil {
+			errs[spec.Name] = err.Error()
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return row, nil
+}
+
+// insertRowRules runs the table's ValidateRow and ValidateBatch rules on a
+// new row's values, as given.
+func insertRowRules(def TableDefinition, values map[string]string) error {
+	row := make([]string, 0, len(values))
+	headerIdx := make(HeaderIndex, len(values))
+	for col, value := range values {
+		headerIdx[strings.ToLower(col)] = len(row)
+		row = append(row, value)
+	}
+	if err := checkRowRule(row, headerIdx, def); err != nil {
+		return err
+	}
+	if def.ValidateBatch == nil {
+		return nil
+	}
+	if errs := def.ValidateBatch([]map[string]string{ruleValues(row, headerIdx, def)}); len(errs) > 0 && errs[0] != nil {
+		return asRuleError(errs[0], defaultBatchRule)
+	}
+	return nil
+}
+
+// insertRowKey returns a new row's key, its parts joined with "|", and the
+// parts as query arguments; the arguments are nil if the table has no
+// unique key or a part is empty.
+func insertRowKey(def TableDefinition, row map[string]string) (string, []any) {
+	if len(def.Info.UniqueKey) == 0 {
+		return "", nil
+	}
+	specs := keySpecs(def)
+	parts := make([]string, len(specs))
+	args := make([]any, len(specs))
+	for i, spec := range specs {
+		value := row[spec.Name]
+		canonical, ok := canonicalKeyPart(spec, value)
+		if !ok {
+			canonical = value
+			args = nil
+		} else if args != nil {
+			args[i] = canonical
+		}
+		parts[i] = canonical
+	}
+	return strings.Join(parts, "|"), args
+}
+
+// insertRowKeyExistsSQL reports whether a live row has the key $1, $2, ...
+func insertRowKeyExistsSQL(def TableDefinition) string {
+	specs := keySpecs(def)
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	conds := make([]string, len(keyCols))
+	for i, col := range keyCols {
+		conds[i] = fmt.Sprintf("%s = $%d::%s", quoteIdentifier(col), i+1, fieldSQLType(specs[i].Type))
+	}
+	return fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s%s)",
+		quoteIdentifier(def.Info.Key), strings.Join(conds, " AND "), andLiveRows(def, ""))
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInsertRowValues(t *testing.T) {
+	def := bulkEditTestTable()
+	def.FieldSpecs[0].Required = true
+	def.FieldSpecs[2].Normalizer = strings.ToLower
+
+	row, errs := insertRowValues(def, map[string]string{
+		"region":       " EU ",
+		"Invoice Date": "03/01/2024",
+		"Status":       "OPEN",
+	})
+	if errs != nil {
+		t.Fatalf("errs = %v", errs)
+	}
+	if row["Region"] != "EU" || row["Status"] != "open" || row["Invoice Date"] == "" {
+		t.Errorf("row = %v", row)
+	}
+	if _, ok := row["Amount"]; ok {
+		t.Errorf("row has a value for Amount, which was not given")
+	}
+
+	_, errs = insertRowValues(def, map[string]string{
+		"Region": " ",
+		"Amount": "lots",
+		"Colour": "red",
+	})
+	for _, col := range []string{"Region", "Amount", "Colour"} {
+		if errs[col] == "" {
+			t.Errorf("no error for %s: %v", col, errs)
+		}
+	}
+	if errs["Region"] != "required field is empty" || errs["Colour"] != "unknown column" {
+		t.Errorf("errs = %v", errs)
+	}
+}
+
+func TestInsertRowRules(t *testing.T) {
+	def := bulkEditTestTable()
+	if err := insertRowRules(def, map[string]string{"Region": "EU"}); err != nil {
+		t.Fatalf("no rules: %v", err)
+	}
+
+	def.ValidateRow = func(values map[string]string) error {
+		if values["Status"] == "void" && values["Amount"] != "" {
+			return errors.New("void rows have no amount")
+		}
+		return nil
+	}
+	def.ValidateBatch = func(rows []map[string]string) map[int]error {
+		errs := make(map[int]error)
+		for i, row := range rows {
+			if row["Region"] == "XX" {
+				errs[i] = errors.New("unknown region")
+			}
+		}
+		return errs
+	}
+	tests := []struct {
+		values  map[string]string
+		wantErr string
+	}{
+		{map[string]string{"Region": "EU", "Status": "void"}, ""},
+		{map[string]string{"Region": "EU", "Status": "void", "amount": "5"}, "void rows have no amount"},
+		{map[string]string{"Region": "XX"}, "unknown region"},
+	}
+	for _, tt := range tests {
+		err := insertRowRules(def, tt.values)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: %v", tt.values, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%v: err = %v, want %q", tt.values, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCloneRowValues(t *testing.T) {
+	def := bulkEditTestTable()
+	def.Info.Columns = []string{"Region", "Invoice Date", "Status", "Amount"}
+
+	got := cloneRowValues(def, []string{"EU", "2024-03-01", "open", ""}, map[string]string{
+		"invoice date": "2024-04-01",
+		"Status":       "",
+		"Amount":       "12.50",
+	})
+	want := map[string]string{"Region": "EU", "Invoice Date": "2024-04-01", "Amount": "12.50"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for col, v := range want {
+		if got[col] != v {
+			t.Errorf("%s = %q, want %q", col, got[col], v)
+		}
+	}
+}
+
+func TestInsertRowKey(t *testing.T) {
+	def := bulkEditTestTable()
+
+	key, args := insertRowKey(def, map[string]string{"Region": "EU", "Invoice Date": "03/01/2024"})
+	if key != "EU|2024-03-01" || len(args) != 2 || args[1] != "2024-03-01" {
+		t.Errorf("key = %q, args = %v", key, args)
+	}
+
+	// An empty key part is never a duplicate
+	key, args = insertRowKey(def, map[string]string{"Region": "EU"})
+	if key != "EU|" || args != nil {
+		t.Errorf("key = %q, args = %v", key, args)
+	}
+
+	def.Info.UniqueKey = nil
+	if key, args := insertRowKey(def, map[string]string{"Region": "EU"}); key != "" || args != nil {
+		t.Errorf("no unique key: key = %q, args = %v", key, args)
+	}
+}
+
+func TestInsertRowKeyExistsSQL(t *testing.T) {
+	got := insertRowKeyExistsSQL(bulkEditTestTable())
+	for _, want := range []string{
+		`"region" = $1::TEXT`,
+		`"invoice_date" = $2::DATE`,
+		`"deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SQL %q missing %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+// interfaces.go splits Service into the cohesive interfaces other
+// transports and tests program against. Service implements all of them;
+// see "Embedding" in the package documentation.
+
+import (
+	"context"
+	"io"
+)
+
+// Uploader starts and tracks uploads, and checks files before they are
+// uploaded.
+type Uploader interface {
+	// Starting uploads
+	StartUploadStreaming(ctx context.Context, tableKey string, fileName string, reader io.Reader, fileSize int64, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, error)
+	StartZipUpload(ctx context.Context, tableKey string, r io.Reader, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, []string, error)
+	StartUploadFromURL(ctx context.Context, tableKey, rawURL string, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, error)
+	StartUploadBatch(ctx context.Context, files []BatchFile) (string, []string, error)
+	StartRoutedUpload(ctx context.Context, fileName string, data []byte, route UploadRoute, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (*RoutedUpload, error)
+	AttachToUploadBatch(ctx context.Context, batchID string, file BatchFile) (string, error)
+
+	// Tracking uploads
+	ResumeProgress(uploadID string, lastEventID int64) (<-chan UploadProgress, error)
+	GetUploadResult(uploadID string) (*UploadResult, error)
+	GetUploadBatch(ctx context.Context, batchID string) (*UploadBatchStatus, error)
+	CancelUpload(uploadID string) error
+	UploadQueueStatus(ctx context.Context) UploadQueueStatus
+
+	// Checking files
+	AnalyzeUpload(ctx context.Context, tableKey string, fileData []byte, mapping map[string]int, mode UploadMode, dateOpts DateOptions) (*PreviewResponse, error)
+	DryRunUpload(ctx context.Context, tableKey string, fileData []byte, mapping map[string]int, mode UploadMode, dateOpts DateOptions) (*DryRunReport, error)
+	ValidateFile(tableKey, fileName string, fileData []byte, mapping map[string]int, dateOpts DateOptions, thresholds ValidationThresholds) (*ValidationReport, error)
+	CheckDuplicates(ctx context.Context, tableKey string, keys []string) ([]string, error)
+
+	// Other sources
+	PasteFile(text string) ([]byte, string, error)
+	MaxPasteSize() int64
+	PreviewFixedWidth(r io.Reader, layout FixedWidthLayout, maxLines int) (*FixedWidthPreview, error)
+	FixedWidthReader(r io.Reader, layout FixedWidthLayout) io.Reader
+
+	// Resumable uploads
+	PendingUploads(token string) []PendingUpload
+	CreateResumableUpload(ctx context.Context, token string, req ResumableRequest) (*PendingUpload, error)
+	AppendResumableChunk(id, token string, offset int64, r io.Reader) (*PendingUpload, error)
+	GetResumableUpload(id, token string) (*PendingUpload, error)
+	CompleteResumableUpload(ctx context.Context, id, token string) (*PendingUpload, error)
+	CancelResumableUpload(id, token string) error
+}
+
+// TableQuerier reads table data and upload history.
+type TableQuerier interface {
+	ListTablesByGroup() map[string][]TableInfo
+	GetAllTableStats(ctx context.Context) (map[string]*TableStats, error)
+
+	// Rows
+	GetTableData(ctx context.Context, tableKey string, page, pageSize int, sorts []SortSpec, searchQuery string, filters FilterSet) (*TableDataResult, error)
+	GetTableDataAfter(ctx context.Context, tableKey, cursor string, pageSize int, sorts []SortSpec, searchQuery string, filters FilterSet) (*TableDataResult, error)
+	GetGroupedData(ctx context.Context, tableKey string, groupBy []GroupSpec, aggs []AggSpec, filters FilterSet) (*SummaryResult, error)
+	GetHistogram(ctx context.Context, tableKey, column string, from, to float64, buckets int, filters FilterSet) (*HistogramResult, error)
+	GetDataQuality(ctx context.Context, tableKey string) (*DataQualityReport, error)
+	StreamTableData(ctx context.Context, tableKey, searchQuery string, filters FilterSet, callback func(row TableRow) error) error
+	StreamTableSample(ctx context.Context, tableKey, searchQuery string, filters FilterSet, size int, callback func(row TableRow) error) error
+	ListDeletedRows(ctx context.Context, tableKey string, limit int) ([]DeletedRow, error)
+	ListRecycledRows(ctx context.Context, tableKey string, limit int) ([]RecycledRow, error)
+	GetRowHistory(ctx context.Context, tableKey, rowKey string) (*RowHistory, error)
+	ClampPageSize(pageSize int) int
+	MaxSortLevels() int
+
+	// Upload history
+	GetUploadHistory(ctx context.Context, tableKey string) ([]UploadHistoryEntry, error)
+	GetUploadDetail(ctx context.Context, uploadID string) (*UploadDetail, error)
+	GetUploadWithHeaders(ctx context.Context, uploadID string) (*UploadWithHeaders, error)
+	GetUploadInsertedRows(ctx context.Context, uploadID, tableKey string, page, pageSize int) (*UploadRowsResult, error)
+	GetUploadFailedRowsPaginated(ctx context.Context, uploadID string, page, pageSize int) ([]FailedRowDetail, int64, error)
+	GetFailedRows(ctx context.Context, uploadID string) ([]FailedRowExport, error)
+	GetKeyViolationTrend(ctx context.Context, tableKey string, limit int) (*KeyViolationTrend, error)
+}
+
+// Auditor writes and reads the audit log.
+type Auditor interface {
+	LogAudit(ctx context.Context, params AuditLogParams) (*AuditEntry, error)
+	LogDenied(ctx context.Context, d Denial)
+	LogExport(ctx context.Context, rec ExportRecord)
+
+	GetAuditLog(ctx context.Context, filter AuditLogFilter) ([]AuditEntry, error)
+	CountAuditLog(ctx context.Context, filter AuditLogFilter) (int64, error)
+	GetAuditLogByID(ctx context.Context, id string) (*AuditEntry, error)
+	StreamAuditLog(ctx context.Context, filter AuditLogFilter, callback func(entry AuditEntry) error) error
+}
+
+// TemplateStore keeps import templates.
+type TemplateStore interface {
+	ListTemplates(ctx context.Context, tableKey string) ([]ImportTemplate, error)
+	MatchTemplates(ctx context.Context, tableKey string, csvHeaders []string) ([]TemplateMatch, error)
+	GetTemplate(ctx context.Context, id string) (*ImportTemplate, error)
+	CreateTemplate(ctx context.Context, tableKey, name string, mapping map[string]int, csvHeaders []string, transforms []MappingTransform) (*ImportTemplate, error)
+	UpdateTemplate(ctx context.Context, id, name string, mapping map[string]int, csvHeaders []string, transforms []MappingTransform) (*ImportTemplate, error)
+	CreateFixedWidthTemplate(ctx context.Context, tableKey, name string, mapping map[string]int, layout FixedWidthLayout) (*ImportTemplate, error)
+	UpdateFixedWidthTemplate(ctx context.Context, id, name string, mapping map[string]int, layout FixedWidthLayout) (*ImportTemplate, error)
+	DeleteTemplate(ctx context.Context, id string) error
+}
+
+// Service implements every interface.
+var (
+	_ Uploader      = (*Service)(nil)
+	_ TableQuerier  = (*Service)(nil)
+	_ Auditor       = (*Service)(nil)
+	_ TemplateStore = (*Service)(nil)
+)
@@ -0,0 +1,273 @@
+package core
+
+// json_ingest.go reads NDJSON (one object per line) and JSON-array files,
+// so API dumps can be uploaded without converting them to CSV first.
+//
+// A JSON upload is converted to CSV as it streams and then goes through the
+// same pipeline as any other file. Each object is one row and its top-level
+// keys are the columns. Without a column mapping the header is the table's
+// columns, in order, followed by any other keys; objects that omit a key
+// (or set it to null) leave that column empty. With a mapping the header is
+// the keys in the order they first appear, so mapping indexes count keys
+// like CSV columns. The header is chosen from the first jsonSampleRecords
+// objects: a key that first appears later is kept only if it is a table
+// column. Nested objects and arrays are imported as compact JSON text.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// jsonSampleRecords is how many objects are read to choose the header.
+const jsonSampleRecords = 100
+
+// isJSONUpload reports whether an upload is JSON: by its extension
+// (.json, .ndjson or .jsonl, optionally gzipped), else by the first
+// non-space character of head, which must be "[" or "{".
+func isJSONUpload(fileName string, head []byte) bool {
+	name := strings.TrimSuffix(strings.ToLower(fileName), ".gz")
+	switch filepath.Ext(name) {
+	case ".json", ".ndjson", ".jsonl":
+		return true
+	case ".csv", ".tsv", ".txt", ".parquet":
+		return false
+	}
+	trimmed := bytes.TrimLeft(stripBOM(head), " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{')
+}
+
+// jsonAsCSV converts data to CSV if it is a JSON upload, for uploads
+// processed in memory. data must already be UTF-8. Errors describe the
+// file, as for a CSV that cannot be read.
+func jsonAsCSV(fileName string, data []byte, def TableDefinition, mapped bool) ([]byte, error) {
+	if !isJSONUpload(fileName, sniffHead(data)) {
+		return data, nil
+	}
+	return io.ReadAll(newJSONRecordReader(bytes.NewReader(data), def, mapped))
+}
+
+// jsonField is one key of a JSON object, with its value as CSV text.
+type jsonField struct {
+	key   string
+	value string
+}
+
+// jsonRecordReader converts a JSON array or NDJSON stream of objects into CSV.
+type jsonRecordReader struct {
+	src     *bufio.Reader
+	dec     *json.Decoder
+	def     TableDefinition
+	mapped  bool // Header is the keys alone, for an explicit column mapping
+	array   bool // Input is a JSON array rather than NDJSON
+	started bool
+	done    bool           // Last object read
+	n       int            // Objects read
+	slots   map[string]int // Normalized key -> header position
+	width   int
+	pending [][]jsonField // Sampled objects not yet written
+	out     bytes.Buffer  // CSV not yet returned
+	csv     *csv.Writer
+	err     error // Error from the input, returned once out is drained
+}
+
+// newJSONRecordReader returns r, UTF-8 JSON, as CSV with a header row.
+// mapped is whether the upload has an explicit column mapping.
+func newJSONRecordReader(r io.Reader, def TableDefinition, mapped bool) io.Reader {
+	j := &jsonRecordReader{src: bufio.NewReader(r), def: def, mapped: mapped}
+	j.csv = csv.NewWriter(&j.out)
+	return j
+}
+
+func (j *jsonRecordReader) Read(p []byte) (int, error) {
+	for j.out.Len() == 0 && j.err == nil {
+		if !j.started {
+			j.started = true
+			j.err = j.start()
+			continue
+		}
+
+		var rec []jsonField
+		if len(j.pending) > 0 {
+			rec, j.pending = j.pending[0], j.pending[1:]
+		} else if rec, j.err = j.next(); j.err != nil {
+			continue
+		}
+		j.writeRecord(rec)
+	}
+
+	if j.out.Len() > 0 {
+		return j.out.Read(p)
+	}
+	return 0, j.err
+}
+
+// start detects the format, samples the first objects and writes the header.
+func (j *jsonRecordReader) start() error {
+	for {
+		b, err := j.src.ReadByte()
+		if err == io.EOF {
+			return fmt.Errorf("empty file")
+		}
+		if err != nil {
+			return err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			j.array = b == '['
+			j.src.UnreadByte()
+			break
+		}
+	}
+	j.dec = json.NewDecoder(j.src)
+	if j.array {
+		// Consume the "[" so objects are read one at a time
+		if _, err := j.dec.Token(); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	for len(j.pending) < jsonSampleRecords {
+		rec, err := j.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		j.pending = append(j.pending, rec)
+	}
+	if len(j.pending) == 0 {
+		return fmt.Errorf("empty file: no objects")
+	}
+
+	var header []string
+	j.slots = make(map[string]int)
+	add := func(name string) {
+		key := normalizeJSONKey(name)
+		if _, ok := j.slots[key]; !ok {
+			j.slots[key] = len(header)
+			header = append(header, name)
+		}
+	}
+	if !j.mapped {
+		for _, col := range j.def.Info.Columns {
+			add(col)
+		}
+	}
+	columns := len(header)
+
+	matched := j.mapped
+	for _, rec := range j.pending {
+		for _, f := range rec {
+			if i, ok := j.slots[normalizeJSONKey(f.key)]; ok && i < columns {
+				matched = true
+			}
+			add(f.key)
+		}
+	}
+	if !matched {
+		return fmt.Errorf("header not found (expected: %v): no key of the first %d JSON objects is a column; set a column mapping",
+			j.def.Info.Columns, len(j.pending))
+	}
+
+	j.width = len(header)
+	j.writeRow(header)
+	return nil
+}
+
+// next reads one object. It returns io.EOF after the last one.
+func (j *jsonRecordReader) next() ([]jsonField, error) {
+	if j.done {
+		return nil, io.EOF
+	}
+	if j.array && !j.dec.More() {
+		if _, err := j.dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: array is not closed: %w", err)
+		}
+		j.done = true
+		return nil, io.EOF
+	}
+
+	j.n++
+	tok, err := j.dec.Token()
+	if err == io.EOF && !j.array {
+		j.done = true
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: object %d: %w", j.n, err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("invalid JSON: value %d is not an object", j.n)
+	}
+
+	var rec []jsonField
+	for j.dec.More() {
+		tok, err := j.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: object %d: %w", j.n, err)
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := j.dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: object %d, key %q: %w", j.n, key, err)
+		}
+		rec = append(rec, jsonField{key: key, value: jsonCell(raw)})
+	}
+	if _, err := j.dec.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON: object %d: %w", j.n, err)
+	}
+	return rec, nil
+}
+
+// writeRecord writes an object's values at their header positions. Keys
+// outside the header are dropped.
+func (j *jsonRecordReader) writeRecord(rec []jsonField) {
+	row := make([]string, j.width)
+	for _, f := range rec {
+		if i, ok := j.slots[normalizeJSONKey(f.key)]; ok {
+			row[i] = f.value
+		}
+	}
+	j.writeRow(row)
+}
+
+func (j *jsonRecordReader) writeRow(row []string) {
+	// Writing to a bytes.Buffer cannot fail
+	j.csv.Write(row)
+	j.csv.Flush()
+}
+
+// normalizeJSONKey matches keys to columns as header cells are matched.
+func normalizeJSONKey(key string) string {
+	return strings.ToLower(CleanCell(key))
+}
+
+// jsonCell formats a JSON value as a CSV cell: null is empty, strings are
+// unquoted, numbers and booleans are kept as written, and objects and
+// arrays are compact JSON.
+func jsonCell(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	switch raw[0] {
+	case 'n':
+		return ""
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	case '{', '[':
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err == nil {
+			return buf.String()
+		}
+	}
+	return string(raw)
+}
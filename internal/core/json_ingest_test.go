@@ -0,0 +1,160 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+var jsonTestDef = TableDefinition{Info: TableInfo{Key: "json_orders", Columns: []string{"Order ID", "Amount", "Status"}}}
+
+func readJSONAsCSV(t *testing.T, input string, mapped bool) (string, error) {
+	t.Helper()
+	out, err := io.ReadAll(newJSONRecordReader(strings.NewReader(input), jsonTestDef, mapped))
+	return string(out), err
+}
+
+func TestIsJSONUpload(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		head string
+		want bool
+	}{
+		{"json extension", "dump.json", "", true},
+		{"ndjson gzipped", "dump.NDJSON.gz", "", true},
+		{"jsonl", "dump.jsonl", "id,name", true},
+		{"csv extension wins", "orders.csv", "[1,2]", false},
+		{"sniffed array", "", "  \n[{\"a\":1}]", true},
+		{"sniffed object with bom", "", "\xEF\xBB\xBF{\"a\":1}", true},
+		{"sniffed csv", "upload.dat", "Order ID,Amount", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isJSONUpload(tt.file, []byte(tt.head)); got != tt.want {
+				t.Errorf("isJSONUpload(%q, %q) = %v, want %v", tt.file, tt.head, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONRecordReader(t *testing.T) {
+	want := "Order ID,Amount,Status,note,tags\n" +
+		"A1,10.50,open,,\n" +
+		"A2,3,,\"line one\nline two\",\"[\"\"x\"\",\"\"y\"\"]\"\n" +
+		"A3,,closed,,\n"
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"array", `[
+			{"order id": "A1", "amount": 10.50, "status": "open"},
+			{"Status": null, "Order ID": "A2", "Amount": 3, "note": "line one\nline two", "tags": ["x", "y"]},
+			{"Order ID": "A3", "Status": "closed"}
+		]`},
+		{"ndjson", `{"order id": "A1", "amount": 10.50, "status": "open"}
+{"Status": null, "Order ID": "A2", "Amount": 3, "note": "line one\nline two", "tags": [ "x", "y" ]}
+
+{"Order ID": "A3", "Status": "closed"}
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readJSONAsCSV(t, tt.input, false)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if got != want {
+				t.Errorf("output =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONRecordReader_Mapped(t *testing.T) {
+	got, err := readJSONAsCSV(t, `{"amt": 1, "ref": "A1"}
+{"ref": "A2", "amt": 2, "extra": true}`, true)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if want := "amt,ref,extra\n1,A1,\n2,A2,true\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestJSONRecordReader_KeysAfterSample(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < jsonSampleRecords; i++ {
+		fmt.Fprintf(&b, "{\"Order ID\": \"A%d\"}\n", i)
+	}
+	b.WriteString(`{"Order ID": "late", "Status": "open", "unknown": "dropped"}`)
+
+	got, err := readJSONAsCSV(t, b.String(), false)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.HasPrefix(got, "Order ID,Amount,Status\n") || !strings.HasSuffix(got, "late,,open\n") {
+		t.Errorf("output ends %q", got[max(0, len(got)-60):])
+	}
+}
+
+func TestJSONRecordReader_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		mapped  bool
+		wantErr string
+	}{
+		{"empty", "  \n", false, "empty file"},
+		{"empty array", "[]", false, "empty file"},
+		{"no matching key", `{"foo": 1}`, false, "header not found"},
+		{"no matching key with mapping", `{"foo": 1}`, true, ""},
+		{"not an object", `[{"Order ID": "A1"}, 2]`, false, "invalid JSON: value 2 is not an object"},
+		{"syntax error", "{\"Order ID\": \"A1\"}\n{\"Order ID\": }", false, "invalid JSON: object 2"},
+		{"unclosed array", `[{"Order ID": "A1"}`, false, "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readJSONAsCSV(t, tt.input, tt.mapped)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFile_JSON(t *testing.T) {
+	registerValidateTestTable(t)
+
+	data := `[
+		{"Order ID": "A1", "Amount": 10, "Paid": true, "Status": "open"},
+		{"Order ID": "A2", "Amount": "ten", "Paid": false, "Status": "open"}
+	]`
+	report, err := ValidateFile("validate_orders", []byte(data), ValidateOptions{FileName: "orders.json"})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	if report.FileError != nil || report.TotalRows != 2 || report.ErrorRows != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Errors[0].LineNumber != 3 {
+		t.Errorf("error line = %d, want 3", report.Errors[0].LineNumber)
+	}
+
+	report, err = ValidateFile("validate_orders", []byte(`{"Order ID": "A1",`), ValidateOptions{})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	if report.FileError == nil || report.FileError.Code != "FILE008" {
+		t.Errorf("file error = %+v, want FILE008", report.FileError)
+	}
+}
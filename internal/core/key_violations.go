@@ -0,0 +1,257 @@
+package core
+
+// key_violations.go tracks unique key violations per upload and alerts
+// when a table's rate spikes.
+//
+// A key violation is a row whose unique key was already taken: rows the
+// skip policy dropped, earlier rows the overwrite policy replaced within the
+// file, and rows the database rejected with a unique_violation. Upserts are
+// not violations; replacing rows by key is what upsert mode is for.
+//
+// Each completed upload records its count. When
+// UPLOAD_KEY_VIOLATION_ALERT_PERCENT is set, the rate over the table's last
+// UPLOAD_KEY_VIOLATION_ALERT_WINDOW uploads is checked after every upload,
+// and crossing the threshold logs a warning and posts to
+// UPLOAD_ALERT_WEBHOOK_URL. A sudden rise usually means the source system
+// changed what its keys mean. The alert fires once per crossing and re-arms
+// when the rate drops back under the threshold.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/JonMunkholm/TUI/internal/database"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// uniqueViolationCode is the SQLSTATE for unique_violation, as it appears
+// in a failed row's reason.
+const uniqueViolationCode = "SQLSTATE 23505"
+
+// maxKeyViolationTrend caps the uploads GetKeyViolationTrend returns.
+const maxKeyViolationTrend = 500
+
+// KeyViolationPoint is one upload in a table's key violation trend.
+type KeyViolationPoint struct {
+	UploadID   string    `json:"upload_id"`
+	FileName   string    `json:"file_name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Rows       int       `json:"rows"`
+	Violations int       `json:"violations"`
+	Percent    float64   `json:"percent"` // Violations per 100 rows
+}
+
+// KeyViolationTrend is a table's recent key violations, oldest first.
+type KeyViolationTrend struct {
+	TableKey  string              `json:"table_key"`
+	Uploads   []KeyViolationPoint `json:"uploads"`
+	Percent   float64             `json:"percent"`   // Over all Uploads
+	Threshold int                 `json:"threshold"` // Alert percent; 0 if alerts are off
+	Alerting  bool                `json:"alerting"`  // Percent is over Threshold
+}
+
+// KeyViolationAlert is the JSON body posted to the alert webhook.
+type KeyViolationAlert struct {
+	Event    string            `json:"event"` // Always "key_violation_spike"
+	Message  string            `json:"message"`
+	Trend    KeyViolationTrend `json:"trend"`
+	UploadID string            `json:"upload_id"` // The upload that crossed the threshold
+}
+
+// keyViolationAlerts remembers which tables are over the threshold, so an
+// alert fires once per crossing rather than after every upload.
+type keyViolationAlerts struct {
+	mu       sync.Mutex
+	alerting map[string]bool
+}
+
+// transition records whether tableKey is over the threshold and reports
+// whether it just crossed it.
+func (a *keyViolationAlerts) transition(tableKey string, over bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.alerting == nil {
+		a.alerting = make(map[string]bool)
+	}
+	was := a.alerting[tableKey]
+	a.alerting[tableKey] = over
+	return over && !was
+}
+
+// countKeyViolations returns the key violations of a finished upload.
+func countKeyViolations(result *UploadResult, failedRows []FailedRow) int {
+	n := result.DupSkipped + result.DupInFile
+	for _, fr := range failedRows {
+		if strings.Contains(fr.Reason, uniqueViolationCode) {
+			n++
+		}
+	}
+	return n
+}
+
+// recordKeyViolations stores the upload's key violation count.
+func recordKeyViolations(ctx context.Context, q db.DBTX, uploadID pgtype.UUID, violations int) error {
+	if _, err := q.Exec(ctx, `UPDATE csv_uploads SET rows_key_violation = $2 WHERE id = $1`,
+		uploadID, violations); err != nil {
+		return fmt.Errorf("record key violations: %w", err)
+	}
+	return nil
+}
+
+// keyViolationPercent returns violations per 100 rows; 0 for no rows.
+func keyViolationPercent(violations, rows int) float64 {
+	if rows <= 0 {
+		return 0
+	}
+	return float64(violations) * 100 / float64(rows)
+}
+
+// GetKeyViolationTrend returns the key violations of the table's last
+// limit uploads (the alert window if limit is 0, at most
+// maxKeyViolationTrend). Uploads recorded before violations were tracked
+// are left out.
+func (s *Service) GetKeyViolationTrend(ctx context.Context, tableKey string, limit int) (*KeyViolationTrend, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if limit <= 0 {
+		limit = s.cfg.Upload.KeyViolationAlertWindow
+	}
+	switch {
+	case limit <= 0:
+		limit = 10
+	case limit > maxKeyViolationTrend:
+		limit = maxKeyViolationTrend
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	// Rows skipped by the duplicate policy are in neither inserted nor skipped
+	rows, err := s.pool.Query(ctx, `
+SELECT id, COALESCE(file_name, ''), uploaded_at,
+       COALESCE(rows_inserted, 0) + COALESCE(rows_skipped, 0) + COALESCE(rows_duplicate, 0),
+       rows_key_violation
+FROM csv_uploads
+WHERE name = $1 AND action = 'upload' AND rows_key_violation IS NOT NULL
+ORDER BY uploaded_at DESC
+LIMIT $2`, def.Info.Key, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query key violations: %w", err)
+	}
+	defer rows.Close()
+
+	trend := &KeyViolationTrend{TableKey: def.Info.Key, Uploads: []KeyViolationPoint{}}
+	for rows.Next() {
+		var (
+			id         pgtype.UUID
+			p          KeyViolationPoint
+			uploadedAt pgtype.Timestamp
+		)
+		if err := rows.Scan(&id, &p.FileName, &uploadedAt, &p.Rows, &p.Violations); err != nil {
+			return nil, fmt.Errorf("scan key violations: %w", err)
+		}
+		p.UploadID = PgUUIDToString(id)
+		p.UploadedAt = uploadedAt.Time
+		p.Percent = keyViolationPercent(p.Violations, p.Rows)
+		trend.Uploads = append(trend.Uploads, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query key violations: %w", err)
+	}
+
+	// Oldest first, so the trend reads left to right
+	for i, j := 0, len(trend.Uploads)-1; i < j; i, j = i+1, j-1 {
+		trend.Uploads[i], trend.Uploads[j] = trend.Uploads[j], trend.Uploads[i]
+	}
+	s.summarizeKeyViolations(trend)
+	return trend, nil
+}
+
+// summarizeKeyViolations sets the trend's overall percent and whether it
+// is over the alert threshold.
+func (s *Service) summarizeKeyViolations(trend *KeyViolationTrend) {
+	var rows, violations int
+	for _, p := range trend.Uploads {
+		rows += p.Rows
+		violations += p.Violations
+	}
+	trend.Percent = keyViolationPercent(violations, rows)
+	trend.Threshold = s.cfg.Upload.KeyViolationAlertPercent
+	trend.Alerting = trend.Threshold > 0 && trend.Percent > float64(trend.Threshold)
+}
+
+// checkKeyViolations alerts, in the background, if the upload pushed the
+// table's key violation rate over the threshold.
+func (s *Service) checkKeyViolations(tableKey, uploadID string) {
+	if s.cfg.Upload.KeyViolationAlertPercent <= 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := s.withOpTimeout(context.Background(), opQuery)
+		defer cancel()
+
+		trend, err := s.GetKeyViolationTrend(ctx, tableKey, 0)
+		if err != nil {
+			slog.Error("failed to check key violations", "table", tableKey, "error", err)
+			return
+		}
+		if !s.keyAlerts.transition(tableKey, trend.Alerting) {
+			return
+		}
+
+		alert := KeyViolationAlert{
+			Event: "key_violation_spike",
+			Message: fmt.Sprintf("%.1f%% of rows in the last %d uploads to %s violated its unique key (threshold %d%%)",
+				trend.Percent, len(trend.Uploads), tableKey, trend.Threshold),
+			Trend:    *trend,
+			UploadID: uploadID,
+		}
+		slog.Warn("unique key violations spiked",
+			"table", tableKey,
+			"percent", fmt.Sprintf("%.1f", trend.Percent),
+			"threshold", trend.Threshold,
+			"uploads", len(trend.Uploads),
+			"upload_id", uploadID,
+		)
+		if url := s.cfg.Upload.AlertWebhookURL; url != "" {
+			if err := postAlert(ctx, url, alert); err != nil {
+				slog.Error("failed to post key violation alert", "table", tableKey, "error", err)
+			}
+		}
+	}()
+}
+
+// alertHTTPClient posts alerts to the webhook.
+var alertHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// postAlert posts alert as JSON to url; any 2xx response is success.
+func postAlert(ctx context.Context, url string, alert any) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := alertHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestCountKeyViolations(t *testing.T) {
+	result := &UploadResult{DupSkipped: 2, DupInFile: 1}
+	failed := []FailedRow{
+		{Reason: `insert: ERROR: duplicate key value violates unique constraint "orders_pkey" (SQLSTATE 23505)`},
+		{Reason: `insert: ERROR: null value in column "id" violates not-null constraint (SQLSTATE 23502)`},
+		{Reason: "expected 3 columns, got 2"},
+	}
+	if got := countKeyViolations(result, failed); got != 4 {
+		t.Errorf("countKeyViolations = %d, want 4", got)
+	}
+}
+
+func TestKeyViolationAlerts_Transition(t *testing.T) {
+	var a keyViolationAlerts
+	steps := []struct {
+		over, want bool
+	}{
+		{false, false},
+		{true, true},  // Crossed
+		{true, false}, // Still over
+		{false, false},
+		{true, true}, // Crossed again
+	}
+	for i, st := range steps {
+		if got := a.transition("orders", st.over); got != st.want {
+			t.Errorf("step %d: transition(%v) = %v, want %v", i, st.over, got, st.want)
+		}
+	}
+	if !a.transition("refunds", true) {
+		t.Error("tables should alert independently")
+	}
+}
+
+func TestSummarizeKeyViolations(t *testing.T) {
+	s := &Service{cfg: &config.Config{Upload: config.UploadConfig{KeyViolationAlertPercent: 5}}}
+	trend := &KeyViolationTrend{Uploads: []KeyViolationPoint{
+		{Rows: 100, Violations: 1},
+		{Rows: 100, Violations: 1},
+		{Rows: 200, Violations: 30},
+	}}
+	s.summarizeKeyViolations(trend)
+	if trend.Percent != 8 || trend.Threshold != 5 || !trend.Alerting {
+		t.Errorf("trend = %+v, want 8%% over a 5%% threshold", trend)
+	}
+
+	s.cfg.Upload.KeyViolationAlertPercent = 0
+	s.summarizeKeyViolations(trend)
+	if trend.Alerting {
+		t.Error("alerts disabled: Alerting should be false")
+	}
+	if got := keyViolationPercent(3, 0); got != 0 {
+		t.Errorf("keyViolationPercent(3, 0) = %v, want 0", got)
+	}
+}
+
+func TestPostAlert(t *testing.T) {
+	var got KeyViolationAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	alert := KeyViolationAlert{Event: "key_violation_spike", UploadID: "u1", Trend: KeyViolationTrend{TableKey: "orders"}}
+	if err := postAlert(context.Background(), srv.URL, alert); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != "key_violation_spike" || got.Trend.TableKey != "orders" || got.UploadID != "u1" {
+		t.Errorf("posted %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := postAlert(context.Background(), failing.URL, alert); err == nil {
+		t.Error("expected error for a 502 response")
+	}
+}
@@ -0,0 +1,352 @@
+package core
+
+// legal_hold.go keeps data under legal hold from being destroyed.
+//
+// A hold covers a whole table, or with filters just the rows matching them
+// (the same column filters as the table view, combined with AND). While a
+// hold is active:
+//
+//   - the retention job leaves held rows in the recycle bin and purges the
+//     rest (see purgeExpiredRows)
+//   - resets, upload rollbacks, row deletes, recycle bin purges, replace
+//     uploads and upserts that would remove any held row are refused with
+//     ErrLegalHold, and the refusal is recorded as an access_denied audit
+//     entry
+//
+// Holds are placed and released by an admin. Both are audited at critical
+// severity. A hold cannot be edited: release it and place a new one, so
+// the audit log shows exactly what was held when. Released holds are kept.
+//
+// Holds protect rows from deletion only; cell edits and uploads that only
+// add rows are not affected.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Legal hold errors.
+var (
+	ErrLegalHold         = errors.New("blocked by legal hold")
+	ErrLegalHoldNotFound = errors.New("legal hold not found")
+	ErrInvalidLegalHold  = errors.New("invalid legal hold")
+)
+
+// LegalHold is a hold on a table's rows.
+type LegalHold struct {
+	ID       string       `json:"id"`
+	TableKey string       `json:"tableKey"`
+	Name     string       `json:"name"` // e.g. the matter or case reference
+	Reason   string       `json:"reason,omitempty"`
+	Filters  []ViewFilter `json:"filters"` // Empty holds the whole table
+	// Set once the hold is released
+	ReleasedAt    *time.Time `json:"releasedAt,omitempty"`
+	ReleaseReason string     `json:"releaseReason,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the hold is still in force.
+func (h LegalHold) Active() bool {
+	return h.ReleasedAt == nil
+}
+
+// LegalHoldParams contains the fields of a hold to place.
+type LegalHoldParams struct {
+	Name    string       `json:"name"`
+	Reason  string       `json:"reason"`
+	Filters []ViewFilter `json:"filters"`
+}
+
+// validateLegalHold checks p against the table's columns and returns it
+// with column names in their canonical case.
+func validateLegalHold(def TableDefinition, p LegalHoldParams) (LegalHoldParams, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return p, fmt.Errorf("%w: name is required", ErrInvalidLegalHold)
+	}
+	p.Reason = strings.TrimSpace(p.Reason)
+
+	specs := make(map[string]FieldSpec, len(def.FieldSpecs))
+	for _, spec := range def.FieldSpecs {
+		specs[strings.ToLower(spec.Name)] = spec
+	}
+	filters := make([]ViewFilter, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		spec, ok := specs[strings.ToLower(ResolveColumnName(def, strings.TrimSpace(f.Column), "legal hold"))]
+		if !ok {
+			return p, fmt.Errorf("%w: unknown column %s", ErrInvalidLegalHold, f.Column)
+		}
+		if !ValidOperator(f.Operator, spec.Type) {
+			return p, fmt.Errorf("%w: operator %q is not valid for column %s", ErrInvalidLegalHold, f.Operator, spec.Name)
+		}
+		if !f.Operator.TakesValue() {
+			f.Value = ""
+		} else if f.Value == "" {
+			return p, fmt.Errorf("%w: filter on %s has no value", ErrInvalidLegalHold, spec.Name)
+		}
+		if !ValidFilterGroup(f.Group) {
+			return p, fmt.Errorf("%w: invalid filter group %q", ErrInvalidLegalHold, f.Group)
+		}
+		filters = append(filters, ViewFilter{Column: spec.Name, Operator: f.Operator, Value: f.Value, Group: f.Group, Not: f.Not})
+	}
+	p.Filters = filters
+	return p, nil
+}
+
+// condition returns the SQL condition matching the rows the hold covers,
+// numbering placeholders from argIdx. A hold without filters matches every
+// row; so does one whose filtered columns have all left the table, since a
+// hold must never silently cover less.
+func (h LegalHold) condition(def TableDefinition, argIdx int) (string, []any) {
+	_, filters := SavedView{Filters: h.Filters}.Query(def)
+	if len(filters.Filters) == 0 {
+		return "TRUE", nil
+	}
+	conditions, args, _ := filterTree(filters.Filters).conditions(0, argIdx)
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
+
+// legalHoldsCondition returns the SQL condition matching rows covered by
+// any of holds, numbering placeholders from argIdx.
+func legalHoldsCondition(def TableDefinition, holds []LegalHold, argIdx int) (string, []any) {
+	conditions := make([]string, len(holds))
+	var args []any
+	for i, h := range holds {
+		cond, holdArgs := h.condition(def, argIdx+len(args))
+		conditions[i] = cond
+		args = append(args, holdArgs...)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// PlaceLegalHold places a hold on a table, or on the rows matching p's
+// filters. It is audited as legal_hold_set.
+func (s *Service) PlaceLegalHold(ctx context.Context, tableKey string, p LegalHoldParams) (*LegalHold, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	p, err := validateLegalHold(def, p)
+	if err != nil {
+		return nil, err
+	}
+	filtersJSON, err := json.Marshal(p.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("marshal filters: %w", err)
+	}
+
+	qctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	hold, err := scanLegalHold(s.pool.QueryRow(qctx,
+		`INSERT INTO legal_holds (table_key, name, reason, filters)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+legalHoldColumns,
+		tableKey, p.Name, p.Reason, filtersJSON,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("place legal hold: %w", err)
+	}
+
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), legalHoldAuditParams(ctx, ActionLegalHoldSet, hold)); err != nil {
+		return hold, fmt.Errorf("legal hold %s placed but not audited: %w", hold.ID, err)
+	}
+	return hold, nil
+}
+
+// ReleaseLegalHold lifts an active hold. A reason is required. The release
+// is audited as legal_hold_release.
+func (s *Service) ReleaseLegalHold(ctx context.Context, id, reason string) (*LegalHold, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required to release a hold", ErrInvalidLegalHold)
+	}
+	uid := ToPgUUID(id)
+	if !uid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrLegalHoldNotFound, id)
+	}
+
+	qctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	hold, err := scanLegalHold(s.pool.QueryRow(qctx,
+		`UPDATE legal_holds SET released_at = NOW(), release_reason = $2
+		 WHERE id = $1 AND released_at IS NULL
+		 RETURNING `+legalHoldColumns,
+		uid, reason,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unknown, or released already
+		if _, getErr := s.GetLegalHold(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, fmt.Errorf("%w: hold %s is already released", ErrInvalidLegalHold, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("release legal hold: %w", err)
+	}
+
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), legalHoldAuditParams(ctx, ActionLegalHoldRelease, hold)); err != nil {
+		return hold, fmt.Errorf("legal hold %s released but not audited: %w", hold.ID, err)
+	}
+	return hold, nil
+}
+
+// GetLegalHold retrieves a hold by ID.
+func (s *Service) GetLegalHold(ctx context.Context, id string) (*LegalHold, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	uid := ToPgUUID(id)
+	if !uid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrLegalHoldNotFound, id)
+	}
+	hold, err := scanLegalHold(s.pool.QueryRow(ctx,
+		`SELECT `+legalHoldColumns+` FROM legal_holds WHERE id = $1`, uid))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrLegalHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get legal hold: %w", err)
+	}
+	return hold, nil
+}
+
+// ListLegalHolds returns holds, newest first. tableKey filters them when
+// non-empty; released holds are included only with includeReleased.
+func (s *Service) ListLegalHolds(ctx context.Context, tableKey string, includeReleased bool) ([]LegalHold, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	query := `SELECT ` + legalHoldColumns + ` FROM legal_holds WHERE ($1 = '' OR table_key = $1)`
+	if !includeReleased {
+		query += ` AND released_at IS NULL`
+	}
+	rows, err := s.pool.Query(ctx, query+` ORDER BY created_at DESC`, tableKey)
+	if err != nil {
+		return nil, fmt.Errorf("list legal holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := make([]LegalHold, 0)
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan legal hold: %w", err)
+		}
+		holds = append(holds, *hold)
+	}
+	return holds, rows.Err()
+}
+
+// checkLegalHold returns ErrLegalHold if any row of def matching where
+// (with args) is under an active hold, naming the holds. op describes the
+// blocked operation for the error and the access_denied audit entry.
+func (s *Service) checkLegalHold(ctx context.Context, def TableDefinition, op, where string, args ...any) error {
+	return s.checkLegalHoldIn(ctx, s.pool, def, op, where, args...)
+}
+
+// checkLegalHoldIn is checkLegalHold reading the rows through q, such as an
+// upload transaction whose own uncommitted rows the condition refers to.
+func (s *Service) checkLegalHoldIn(ctx context.Context, q DBTX, def TableDefinition, op, where string, args ...any) error {
+	holds, err := s.ListLegalHolds(ctx, def.Info.Key, false)
+	if err != nil {
+		return fmt.Errorf("check legal holds: %w", err)
+	}
+	if len(holds) == 0 {
+		return nil
+	}
+
+	qctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+	names, err := heldBy(qctx, q, def, holds, where, args...)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	err = fmt.Errorf("%w: %s of %s would remove rows held by %s", ErrLegalHold, op, def.Info.Key, quoteList(names))
+	s.LogDenied(ctx, Denial{Kind: DenialLegalHold, TableKey: def.Info.Key, Detail: err.Error()})
+	return err
+}
+
+// heldBy returns the names of the holds covering any row of def matching
+// where (with args), reading the rows through q.
+func heldBy(ctx context.Context, q DBTX, def TableDefinition, holds []LegalHold, where string, args ...any) ([]string, error) {
+	var names []string
+	for _, h := range holds {
+		cond, holdArgs := h.condition(def, len(args)+1)
+		query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE (%s) AND %s)", quoteIdentifier(def.Info.Key), where, cond)
+		var held bool
+		if err := q.QueryRow(ctx, query, append(args[:len(args):len(args)], holdArgs...)...).Scan(&held); err != nil {
+			return nil, fmt.Errorf("check legal hold %s: %w", h.Name, err)
+		}
+		if held {
+			names = append(names, h.Name)
+		}
+	}
+	return names, nil
+}
+
+// legalHoldAuditParams builds the audit entry for placing or releasing a hold.
+func legalHoldAuditParams(ctx context.Context, action AuditAction, h *LegalHold) AuditLogParams {
+	data := map[string]any{
+		"hold_id": h.ID,
+		"name":    h.Name,
+		"filters": h.Filters,
+	}
+	reason := fmt.Sprintf("Placed legal hold %q on %s", h.Name, h.TableKey)
+	if len(h.Filters) > 0 {
+		reason += " (filtered rows)"
+	}
+	if h.Reason != "" {
+		reason += ": " + h.Reason
+	}
+	if action == ActionLegalHoldRelease {
+		reason = fmt.Sprintf("Released legal hold %q on %s: %s", h.Name, h.TableKey, h.ReleaseReason)
+	}
+	return AuditLogParams{
+		Action:    action,
+		TableKey:  h.TableKey,
+		NewValue:  h.ID,
+		RowData:   data,
+		IPAddress: GetIPAddressFromContext(ctx),
+		UserAgent: GetUserAgentFromContext(ctx),
+		Reason:    reason,
+	}
+}
+
+// legalHoldColumns is the column list scanned by scanLegalHold.
+const legalHoldColumns = `id, table_key, name, reason, filters, created_at, released_at, release_reason`
+
+// scanLegalHold scans one row selected with legalHoldColumns. Column names
+// are resolved through the table's renames.
+func scanLegalHold(row pgx.Row) (*LegalHold, error) {
+	var (
+		id       pgtype.UUID
+		filters  []byte
+		released pgtype.Timestamptz
+		hold     LegalHold
+	)
+	if err := row.Scan(&id, &hold.TableKey, &hold.Name, &hold.Reason,
+		&filters, &hold.CreatedAt, &released, &hold.ReleaseReason); err != nil {
+		return nil, err
+	}
+	hold.ID = PgUUIDToString(id)
+	if released.Valid {
+		hold.ReleasedAt = &released.Time
+	}
+	if err := json.Unmarshal(filters, &hold.Filters); err != nil {
+		return nil, fmt.Errorf("unmarshal filters: %w", err)
+	}
+	if def, ok := Get(hold.TableKey); ok && len(def.Renames) > 0 {
+		for i := range hold.Filters {
+			hold.Filters[i].Column = ResolveColumnName(def, hold.Filters[i].Column, "legal hold")
+		}
+	}
+	return &hold, nil
+}
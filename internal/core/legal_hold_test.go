@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func holdTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "invoices", Columns: []string{"Customer", "Amount"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Customer", Type: FieldText},
+			{Name: "Amount", Type: FieldNumeric},
+		},
+		Renames: []ColumnRename{{From: "Client", To: "Customer"}},
+	}
+}
+
+func TestValidateLegalHold(t *testing.T) {
+	def := holdTestTable()
+
+	got, err := validateLegalHold(def, LegalHoldParams{
+		Name: " Matter 2024-17 ",
+		Filters: []ViewFilter{
+			{Column: "client", Operator: OpEquals, Value: "Acme"},
+			{Column: "Amount", Operator: OpNotNull, Value: "ignored"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("validateLegalHold: %v", err)
+	}
+	want := []ViewFilter{
+		{Column: "Customer", Operator: OpEquals, Value: "Acme"},
+		{Column: "Amount", Operator: OpNotNull},
+	}
+	if got.Name != "Matter 2024-17" || !reflect.DeepEqual(got.Filters, want) {
+		t.Errorf("validateLegalHold() = %+v, want name trimmed and filters %+v", got, want)
+	}
+
+	for name, p := range map[string]LegalHoldParams{
+		"no name":        {Filters: nil},
+		"unknown column": {Name: "m", Filters: []ViewFilter{{Column: "Region", Operator: OpEquals, Value: "x"}}},
+		"bad operator":   {Name: "m", Filters: []ViewFilter{{Column: "Customer", Operator: OpGreater, Value: "x"}}},
+		"missing value":  {Name: "m", Filters: []ViewFilter{{Column: "Customer", Operator: OpEquals}}},
+	} {
+		if _, err := validateLegalHold(def, p); !errors.Is(err, ErrInvalidLegalHold) {
+			t.Errorf("%s: error = %v, want ErrInvalidLegalHold", name, err)
+		}
+	}
+}
+
+func TestLegalHoldCondition(t *testing.T) {
+	def := holdTestTable()
+
+	whole := LegalHold{Name: "all"}
+	if cond, args := whole.condition(def, 1); cond != "TRUE" || args != nil {
+		t.Errorf("whole-table condition = %q %v, want TRUE", cond, args)
+	}
+
+	gone := LegalHold{Name: "gone", Filters: []ViewFilter{{Column: "Region", Operator: OpEquals, Value: "EU"}}}
+	if cond, _ := gone.condition(def, 1); cond != "TRUE" {
+		t.Errorf("condition on removed columns = %q, want TRUE", cond)
+	}
+
+	acme := LegalHold{Name: "acme", Filters: []ViewFilter{{Column: "Customer", Operator: OpEquals, Value: "Acme"}}}
+	cond, args := acme.condition(def, 3)
+	if !strings.Contains(cond, "$3") || len(args) != 1 {
+		t.Errorf("filtered condition = %q %v, want one arg numbered $3", cond, args)
+	}
+
+	combined, args := legalHoldsCondition(def, []LegalHold{acme, acme}, 2)
+	if !strings.Contains(combined, "$2") || !strings.Contains(combined, "$3") || !strings.Contains(combined, " OR ") || len(args) != 2 {
+		t.Errorf("legalHoldsCondition() = %q %v", combined, args)
+	}
+}
+
+func TestLegalHoldAuditParams(t *testing.T) {
+	released := time.Now()
+	hold := &LegalHold{
+		ID:       "h1",
+		TableKey: "invoices",
+		Name:     "Matter 17",
+		Reason:   "litigation",
+		Filters:  []ViewFilter{{Column: "Customer", Operator: OpEquals, Value: "Acme"}},
+	}
+
+	p := legalHoldAuditParams(context.Background(), ActionLegalHoldSet, hold)
+	if p.Reason != `Placed legal hold "Matter 17" on invoices (filtered rows): litigation` {
+		t.Errorf("set reason = %q", p.Reason)
+	}
+
+	hold.ReleasedAt, hold.ReleaseReason = &released, "case closed"
+	p = legalHoldAuditParams(context.Background(), ActionLegalHoldRelease, hold)
+	if p.Reason != `Released legal hold "Matter 17" on invoices: case closed` || p.NewValue != "h1" {
+		t.Errorf("release params = %+v", p)
+	}
+
+	for _, a := range []AuditAction{ActionLegalHoldSet, ActionLegalHoldRelease} {
+		if determineSeverity(a) != SeverityCritical || auditSeverity(a) != SeverityCritical {
+			t.Errorf("%s severity is not critical", a)
+		}
+	}
+}
+
+// existsDB answers each QueryRow with the next of held, recording the
+// queries. Other methods are not implemented and panic if called.
+type existsDB struct {
+	DBTX
+	held    []bool
+	queries []string
+	args    [][]any
+}
+
+func (db *existsDB) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	db.queries = append(db.queries, sql)
+	db.args = append(db.args, args)
+	held := db.held[0]
+	db.held = db.held[1:]
+	return existsRow(held)
+}
+
+type existsRow bool
+
+func (r existsRow) Scan(dest ...any) error {
+	*dest[0].(*bool) = bool(r)
+	return nil
+}
+
+func TestHeldBy(t *testing.T) {
+	def := holdTestTable()
+	holds := []LegalHold{
+		{Name: "all"},
+		{Name: "acme", Filters: []ViewFilter{{Column: "Customer", Operator: OpEquals, Value: "Acme"}}},
+	}
+	db := &existsDB{held: []bool{false, true}}
+
+	names, err := heldBy(context.Background(), db, def, holds, "upload_id = $1", "u1")
+	if err != nil {
+		t.Fatalf("heldBy: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"acme"}) {
+		t.Errorf("names = %v, want [acme]", names)
+	}
+	if len(db.queries) != 2 || !strings.Contains(db.queries[1], `FROM "invoices" WHERE (upload_id = $1) AND (`) {
+		t.Fatalf("queries = %q", db.queries)
+	}
+	if len(db.args[1]) != 2 || db.args[1][0] != "u1" || db.args[1][1] != "Acme" {
+		t.Errorf("args = %v, want the caller's then the hold's", db.args[1])
+	}
+}
+
+// TestUploadModes_LegalHold checks that replace and upsert uploads cannot
+// remove held rows. It needs TEST_DATABASE_URL.
+func TestUploadModes_LegalHold(t *testing.T) {
+	s := testDBService(t)
+	ctx := context.Background()
+	key := registerRangeTestTable(t)
+	def, _ := Get(key)
+	def.Info.UniqueKey = []string{"n"}
+	table := quoteIdentifier(key)
+
+	if _, err := s.pool.Exec(ctx, "CREATE TABLE "+table+" (id SERIAL PRIMARY KEY, upload_id UUID, n INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.pool.Exec(ctx, "DROP TABLE "+table)
+		s.pool.Exec(ctx, "DELETE FROM legal_holds WHERE table_key = $1", key)
+	})
+	held := ToPgUUID(uuid.NewString())
+	if _, err := s.pool.Exec(ctx, "INSERT INTO "+table+" (upload_id, n) VALUES ($1, 1)", held); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PlaceLegalHold(ctx, key, LegalHoldParams{Name: "matter"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// upload adds rows with the given values in a transaction and runs fn
+	// in it, rolling back afterwards
+	upload := func(fn func(pgx.Tx, pgtype.UUID) (int, error), values ...int) error {
+		tx, err := s.pool.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+		id := ToPgUUID(uuid.NewString())
+		for _, v := range values {
+			if _, err := tx.Exec(ctx, "INSERT INTO "+table+" (upload_id, n) VALUES ($1, $2)", id, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err = fn(tx, id)
+		return err
+	}
+
+	replace := func(tx pgx.Tx, _ pgtype.UUID) (int, error) { return s.clearForReplace(ctx, tx, def) }
+	if err := upload(replace); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("replace: error = %v, want ErrLegalHold", err)
+	}
+
+	upsert := func(tx pgx.Tx, id pgtype.UUID) (int, error) { return s.applyUpsert(ctx, tx, def, id, nil) }
+	if err := upload(upsert, 1); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("upsert displacing a held row: error = %v, want ErrLegalHold", err)
+	}
+	if err := upload(upsert, 2); err != nil {
+		t.Errorf("upsert of a new key: %v", err)
+	}
+
+	var n int
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil || n != 1 {
+		t.Errorf("rows = %d, %v; want the held row kept", n, err)
+	}
+}
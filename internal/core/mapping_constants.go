@@ -0,0 +1,185 @@
+package core
+
+// mapping_constants.go lets a column mapping fill a table column with a
+// constant instead of a CSV column, so a file missing a column whose value
+// is known anyway (every row came from SFDC, or was imported today) can be
+// uploaded without editing it first.
+//
+// In a mapping sent with an upload, an entry whose value is a string rather
+// than a CSV index is a constant. A value starting with "=" is an
+// expression:
+//
+//	=today  the date the upload started, as 2006-01-02
+//	=now    the time the upload started, as RFC 3339
+//
+// Constants are checked against their column's FieldSpec before the upload
+// starts, so a bad value fails the request rather than every row. They are
+// appended to each row after the template's transforms (see
+// template_transforms.go) and win over the mapping and the transforms.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConstant is returned for a mapping constant that cannot fill
+// its column.
+var ErrInvalidConstant = errors.New("invalid mapping constant")
+
+const ctxKeyMappingConstants contextKey = "upload_mapping_constants"
+
+// ContextWithMappingConstants fills columns of an upload, dry run or
+// preview started with ctx with constants: column name -> value or
+// expression. It needs the column mapping to be given as well.
+func ContextWithMappingConstants(ctx context.Context, constants map[string]string) context.Context {
+	return context.WithValue(ctx, ctxKeyMappingConstants, constants)
+}
+
+// mappingConstantsFromContext returns the constants set by
+// ContextWithMappingConstants, or nil.
+func mappingConstantsFromContext(ctx context.Context) map[string]string {
+	constants, _ := ctx.Value(ctxKeyMappingConstants).(map[string]string)
+	return constants
+}
+
+// ParseMapping reads a column mapping from a request: a JSON object whose
+// values are CSV column indexes, or strings for constants.
+func ParseMapping(data []byte) (map[string]int, map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	var mapping map[string]int
+	var constants map[string]string
+	for col, v := range raw {
+		var idx int
+		if err := json.Unmarshal(v, &idx); err == nil {
+			if mapping == nil {
+				mapping = make(map[string]int)
+			}
+			mapping[col] = idx
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(v, &value); err != nil {
+			return nil, nil, fmt.Errorf("mapping for %q: want a column index or a constant", col)
+		}
+		if constants == nil {
+			constants = make(map[string]string)
+		}
+		constants[col] = value
+	}
+	return mapping, constants, nil
+}
+
+// mappingConstant is a checked constant: the table column it fills and the
+// value every row gets.
+type mappingConstant struct {
+	field string
+	value string
+}
+
+// resolveConstants checks constants against def and returns them in
+// column order, with expressions evaluated at now and dates read with
+// opts. Constants need a mapping, which places the file's other columns;
+// without one the header must match the table's columns exactly.
+func resolveConstants(def TableDefinition, constants map[string]string, mapping map[string]int, opts DateOptions, now time.Time) ([]mappingConstant, error) {
+	if len(constants) == 0 {
+		return nil, nil
+	}
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("%w: constants need a column mapping for the file's columns", ErrInvalidConstant)
+	}
+
+	out := make([]mappingConstant, 0, len(constants))
+	for col, raw := range constants {
+		name, ok := tableColumn(def, ResolveColumnName(def, col, "mapping constant"))
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has no column %q", ErrInvalidConstant, def.Info.Key, col)
+		}
+		value, err := evalConstant(raw, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConstant, name, err)
+		}
+		for _, spec := range def.FieldSpecs {
+			if strings.EqualFold(spec.Name, name) {
+				if value, err = checkConstant(spec, value, opts); err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrInvalidConstant, err)
+				}
+				break
+			}
+		}
+		out = append(out, mappingConstant{field: name, value: value})
+	}
+
+	order := make(map[string]int, len(def.Info.Columns))
+	for i, c := range def.Info.Columns {
+		order[c] = i
+	}
+	sort.Slice(out, func(i, j int) bool { return order[out[i].field] < order[out[j].field] })
+	return out, nil
+}
+
+// evalConstant returns the value of a constant, evaluating an expression.
+func evalConstant(raw string, now time.Time) (string, error) {
+	value := strings.TrimSpace(raw)
+	if !strings.HasPrefix(value, "=") {
+		if value == "" {
+			return "", errors.New("constant is empty")
+		}
+		return value, nil
+	}
+	switch expr := strings.ToLower(strings.TrimSpace(value[1:])); expr {
+	case "today":
+		return now.Format("2006-01-02"), nil
+	case "now":
+		return now.Format(time.RFC3339), nil
+	default:
+		return "", fmt.Errorf("unknown expression %q (want =today or =now)", value)
+	}
+}
+
+// checkConstant checks value as a cell of spec's column, as buildAndValidate
+// would, and returns it as the upload should read it: dates are rewritten
+// as ISO dates and enum values take the spec's spelling.
+func checkConstant(spec FieldSpec, value string, opts DateOptions) (string, error) {
+	check := value
+	if spec.Normalizer != nil {
+		check = spec.Normalizer(check)
+	}
+	switch spec.Type {
+	case FieldEnum:
+		for _, v := range spec.EnumValues {
+			if strings.EqualFold(check, v) {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("invalid enum for %q: %q", spec.Name, value)
+	case FieldDate:
+		d, _ := opts.parseDate(check, spec)
+		if !d.Valid {
+			return "", fmt.Errorf("invalid date for %q: %q", spec.Name, value)
+		}
+		return d.Time.Format("2006-01-02"), nil
+	case FieldNumeric:
+		if !ToPgNumeric(check).Valid {
+			return "", fmt.Errorf("invalid numeric for %q: %q", spec.Name, value)
+		}
+	case FieldBool:
+		if !ToPgBool(check).Valid {
+			return "", fmt.Errorf("invalid bool for %q: %q", spec.Name, value)
+		}
+	}
+	return value, nil
+}
+
+// uploadConstants checks the constants set on ctx for an upload of def
+// with mapping, evaluating expressions now.
+func uploadConstants(ctx context.Context, def TableDefinition, mapping map[string]int, opts DateOptions) ([]mappingConstant, error) {
+	return resolveConstants(def, mappingConstantsFromContext(ctx), mapping, opts, time.Now())
+}
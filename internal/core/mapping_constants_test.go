@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func constantTestDef() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "deals", Columns: []string{"Name", "Source", "Stage", "Amount", "Imported"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Name", Type: FieldText, Required: true},
+			{Name: "Source", Type: FieldText},
+			{Name: "Stage", Type: FieldEnum, EnumValues: []string{"Open", "Won"}},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Imported", Type: FieldDate},
+		},
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping, constants, err := ParseMapping([]byte(`{"Name": 0, "Source": "SFDC", "Imported": "=today"}`))
+	if err != nil {
+		t.Fatalf("ParseMapping: %v", err)
+	}
+	if len(mapping) != 1 || mapping["Name"] != 0 {
+		t.Errorf("mapping = %v", mapping)
+	}
+	if len(constants) != 2 || constants["Source"] != "SFDC" || constants["Imported"] != "=today" {
+		t.Errorf("constants = %v", constants)
+	}
+
+	if _, _, err := ParseMapping([]byte(`{"Name": true}`)); err == nil {
+		t.Error("expected error for a bool entry")
+	}
+}
+
+func TestResolveConstants(t *testing.T) {
+	def := constantTestDef()
+	now := time.Date(2025, 3, 4, 15, 30, 0, 0, time.UTC)
+	mapping := map[string]int{"Name": 0}
+
+	got, err := resolveConstants(def, map[string]string{
+		"imported": "=Today",
+		"stage":    "won",
+		"Source":   "SFDC",
+	}, mapping, DateOptions{}, now)
+	if err != nil {
+		t.Fatalf("resolveConstants: %v", err)
+	}
+	var parts []string
+	for _, c := range got {
+		parts = append(parts, c.field+"="+c.value)
+	}
+	if s := strings.Join(parts, ","); s != "Source=SFDC,Stage=Won,Imported=2025-03-04" {
+		t.Errorf("constants = %s", s)
+	}
+
+	got, err = resolveConstants(def, map[string]string{"Imported": "04/03/2025"}, mapping, DateOptions{Format: DateFormatDMY}, now)
+	if err != nil || got[0].value != "2025-03-04" {
+		t.Errorf("day-first date = %v, %v", got, err)
+	}
+
+	for name, constants := range map[string]map[string]string{
+		"unknown column":     {"Region": "EMEA"},
+		"bad enum":           {"Stage": "Lost"},
+		"bad numeric":        {"Amount": "lots"},
+		"bad date":           {"Imported": "soon"},
+		"unknown expression": {"Imported": "=tomorrow"},
+		"empty":              {"Source": " "},
+	} {
+		if _, err := resolveConstants(def, constants, mapping, DateOptions{}, now); !errors.Is(err, ErrInvalidConstant) {
+			t.Errorf("%s: err = %v, want ErrInvalidConstant", name, err)
+		}
+	}
+
+	if _, err := resolveConstants(def, map[string]string{"Source": "SFDC"}, nil, DateOptions{}, now); !errors.Is(err, ErrInvalidConstant) {
+		t.Errorf("no mapping: err = %v, want ErrInvalidConstant", err)
+	}
+}
+
+func TestRowTransformerConstants(t *testing.T) {
+	headerIdx := HeaderIndex{"name": 0, "source": 1}
+	rt := newRowTransformer(nil, []mappingConstant{{field: "Source", value: "SFDC"}}, headerIdx, 2)
+
+	if headerIdx["source"] != 2 {
+		t.Fatalf("header index = %v, want the constant to win over the mapping", headerIdx)
+	}
+	if got := strings.Join(rt.apply([]string{"Acme", "web"}), "|"); got != "Acme|web|SFDC" {
+		t.Errorf("apply = %s", got)
+	}
+}
@@ -0,0 +1,301 @@
+package core
+
+// operation_registry.go records operations in the operations table.
+//
+// Every operation gets a row when it starts and an update when it finishes,
+// with its steps, so finished work stays queryable after its in-memory
+// progress is dropped.
+// Rows are written with an upsert that never overwrites a finished row,
+// which makes the start and finish writes safe in either order. Recording is
+// best effort: a failed write is logged and the operation itself carries on,
+// as with the audit log.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// operationInitiatorSystem is the initiator of work not started by a request.
+const operationInitiatorSystem = "system"
+
+// operationRetention is how long a finished operation's live progress stays
+// in memory, matching how long finished uploads stay tracked.
+const operationRetention = 5 * time.Minute
+
+// OperationRecord is an operation as stored in the operations table.
+type OperationRecord struct {
+	ID         string             `json:"id"`
+	Kind       string             `json:"kind"`
+	Status     OperationStatus    `json:"status"`
+	TableKey   string             `json:"tableKey,omitempty"`
+	Initiator  string             `json:"initiator"`
+	UserAgent  string             `json:"userAgent,omitempty"`
+	ParentID   string             `json:"parentId,omitempty"`
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	DurationMs *int64             `json:"durationMs,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	ResultLink string             `json:"resultLink,omitempty"`
+	Steps      []StepProgress     `json:"steps,omitempty"`    // Steps as last recorded
+	Progress   *OperationProgress `json:"progress,omitempty"` // Live steps while tracked in memory
+}
+
+// OperationFilter selects operations for ListOperations.
+type OperationFilter struct {
+	Kind     string
+	Status   OperationStatus
+	TableKey string
+	ParentID string
+	Since    time.Time // Started at or after; zero for no bound
+	Until    time.Time // Started at or before; zero for no bound
+	Limit    int       // Default 50, max 500
+	Offset   int
+}
+
+// bindOperation fills in op's record fields from ctx and makes Finish
+// record the outcome and drop the live progress after operationRetention.
+// Must be called before op is shared.
+func (s *Service) bindOperation(ctx context.Context, op *Operation, tableKey string) {
+	op.progress.TableKey = tableKey
+	op.initiator = GetIPAddressFromContext(ctx)
+	if op.initiator == "" {
+		op.initiator = operationInitiatorSystem
+	}
+	op.userAgent = GetUserAgentFromContext(ctx)
+	op.onFinish = func(OperationProgress) {
+		s.saveOperation(op)
+		time.AfterFunc(operationRetention, func() {
+			s.mu.Lock()
+			if s.operations[op.ID()] == op {
+				delete(s.operations, op.ID())
+			}
+			s.mu.Unlock()
+		})
+	}
+}
+
+// saveOperation writes op's current state to the operations table.
+func (s *Service) saveOperation(op *Operation) {
+	if op == nil || s.pool == nil {
+		return
+	}
+	p := op.Progress()
+
+	var durationMs *int64
+	if p.FinishedAt != nil {
+		ms := p.FinishedAt.Sub(p.StartedAt).Milliseconds()
+		durationMs = &ms
+	}
+	var parentID pgtype.UUID
+	if op.parent != nil {
+		parentID = ToPgUUID(op.parent.ID())
+	}
+	steps, _ := json.Marshal(p.Steps)
+
+	ctx, cancel := s.withOpTimeout(context.Background(), opMutation)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO operations (id, kind, status, table_key, initiator, user_agent,
+		                        parent_id, started_at, finished_at, duration_ms, error, result_link, steps)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, ''), $13)
+		ON CONFLICT (id) DO UPDATE SET
+			status      = EXCLUDED.status,
+			finished_at = EXCLUDED.finished_at,
+			duration_ms = EXCLUDED.duration_ms,
+			error       = EXCLUDED.error,
+			result_link = EXCLUDED.result_link,
+			steps       = EXCLUDED.steps
+		WHERE operations.finished_at IS NULL`,
+		ToPgUUID(p.OperationID), p.Kind, string(p.Status), p.TableKey, op.initiator, op.userAgent,
+		parentID, p.StartedAt, p.FinishedAt, durationMs, p.Error, p.ResultLink, steps,
+	)
+	if err != nil {
+		slog.Error("failed to record operation",
+			"operation_id", p.OperationID,
+			"kind", p.Kind,
+			"status", p.Status,
+			"error", err,
+		)
+	}
+}
+
+// MarkInterruptedOperations fails operations recorded as running by an
+// earlier process. Call at startup, before any new operation starts.
+func (s *Service) MarkInterruptedOperations(ctx context.Context) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE operations
+		SET status = 'failed',
+		    finished_at = NOW(),
+		    duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::BIGINT,
+		    error = 'interrupted by server restart'
+		WHERE status = 'running'`)
+	if err != nil {
+		return 0, fmt.Errorf("mark interrupted operations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// operationColumns is the column list scanned by scanOperation.
+const operationColumns = `id, kind, status, COALESCE(table_key, ''), initiator, user_agent,
+	parent_id, started_at, finished_at, duration_ms, COALESCE(error, ''), COALESCE(result_link, ''), steps`
+
+// scanOperation scans one operations row selected with operationColumns.
+func scanOperation(row pgx.Row) (OperationRecord, error) {
+	var rec OperationRecord
+	var id, parentID pgtype.UUID
+	var status string
+	var steps []byte
+	if err := row.Scan(&id, &rec.Kind, &status, &rec.TableKey, &rec.Initiator, &rec.UserAgent,
+		&parentID, &rec.StartedAt, &rec.FinishedAt, &rec.DurationMs, &rec.Error, &rec.ResultLink, &steps); err != nil {
+		return rec, err
+	}
+	if len(steps) > 0 {
+		if err := json.Unmarshal(steps, &rec.Steps); err != nil {
+			return rec, fmt.Errorf("decode steps: %w", err)
+		}
+	}
+	rec.ID = PgUUIDToString(id)
+	rec.Status = OperationStatus(status)
+	if parentID.Valid {
+		rec.ParentID = PgUUIDToString(parentID)
+	}
+	return rec, nil
+}
+
+// ListOperations returns recorded operations matching f, newest first,
+// and the total number of matches. Running operations still tracked in
+// memory include their live progress.
+func (s *Service) ListOperations(ctx context.Context, f OperationFilter) ([]OperationRecord, int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Kind != "" {
+		add("kind = $%d", f.Kind)
+	}
+	if f.Status != "" {
+		add("status = $%d", string(f.Status))
+	}
+	if f.TableKey != "" {
+		add("table_key = $%d", f.TableKey)
+	}
+	if f.ParentID != "" {
+		if _, err := uuid.Parse(f.ParentID); err != nil {
+			return []OperationRecord{}, 0, nil // No operation can have it as parent
+		}
+		add("parent_id = $%d", ToPgUUID(f.ParentID))
+	}
+	if !f.Since.IsZero() {
+		add("started_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("started_at <= $%d", f.Until)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int64
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM operations"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count operations: %w", err)
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	limit = min(limit, 500)
+	offset := max(f.Offset, 0)
+	query := fmt.Sprintf("SELECT %s FROM operations%s ORDER BY started_at DESC, id LIMIT %d OFFSET %d",
+		operationColumns, where, limit, offset)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query operations: %w", err)
+	}
+	defer rows.Close()
+
+	records := []OperationRecord{}
+	for rows.Next() {
+		rec, err := scanOperation(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan operation: %w", err)
+		}
+		if rec.Status == OperationRunning {
+			rec.Progress = s.liveOperationProgress(rec.ID)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("rows error: %w", err)
+	}
+	return records, total, nil
+}
+
+// GetOperation returns a recorded operation, with its live progress if it
+// is still tracked in memory. An operation that is tracked but whose record
+// could not be written is reported from memory alone.
+func (s *Service) GetOperation(ctx context.Context, id string) (*OperationRecord, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	live := s.liveOperationProgress(id)
+	rec, err := scanOperation(s.pool.QueryRow(ctx,
+		"SELECT "+operationColumns+" FROM operations WHERE id = $1", ToPgUUID(id)))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows) && live != nil:
+		return &OperationRecord{
+			ID:         live.OperationID,
+			Kind:       live.Kind,
+			Status:     live.Status,
+			TableKey:   live.TableKey,
+			StartedAt:  live.StartedAt,
+			FinishedAt: live.FinishedAt,
+			Error:      live.Error,
+			ResultLink: live.ResultLink,
+			Steps:      live.Steps,
+			Progress:   live,
+		}, nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	case err != nil:
+		return nil, fmt.Errorf("get operation: %w", err)
+	}
+	rec.Progress = live
+	return &rec, nil
+}
+
+// liveOperationProgress returns the in-memory progress of an operation, or
+// nil if it is not tracked.
+func (s *Service) liveOperationProgress(id string) *OperationProgress {
+	s.mu.RLock()
+	op, ok := s.operations[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	p := op.Progress()
+	return &p
+}
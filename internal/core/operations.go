@@ -0,0 +1,587 @@
+package core
+
+// operations.go implements step-based progress for long-running operations.
+//
+// An operation is a sequence of named steps, each with a weight that says
+// how much of the whole it represents. Steps run in order; the current step
+// may report current/total units of work, and the overall percentage is the
+// weighted sum of step completion. This lets multi-phase work - a replace
+// upload that deletes then inserts, or a batch of several files - report one
+// coherent progress figure instead of restarting at 0% per phase.
+//
+// Uploads and upload batches register an operation under their upload or
+// batch ID, so /api/operations/{id}/progress works for either. Other work
+// (exports, retention runs, audit imports, resets, rollbacks) registers with
+// StartOperation and drives its steps with Begin, Advance, EndStep and
+// Finish. Work started with runDetached can be stopped by CancelOperation.
+// Every operation
+// also gets a row in the operations table (see operation_registry.go). All
+// Operation methods are safe on a nil receiver, so code paths without an
+// operation (dry runs) need no checks.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrOperationNotFound is returned for an operation ID that is not tracked.
+var ErrOperationNotFound = errors.New("operation not found")
+
+// ErrOperationNotCancellable is returned when cancelling an operation that
+// has already finished or cannot be stopped.
+var ErrOperationNotCancellable = errors.New("operation cannot be cancelled")
+
+// Operation kinds.
+const (
+	OperationUpload      = "upload"
+	OperationUploadBatch = "upload_batch"
+	OperationExport      = "export"
+	OperationRetention   = "retention"
+	OperationAuditImport = "audit_import"
+	OperationBackfill    = "backfill"
+	OperationReset       = "reset"
+	OperationRollback    = "rollback"
+	OperationTableAction = "table_action"
+)
+
+// Upload operation steps.
+const (
+	stepRead     = "read"     // Header detection and upload record
+	stepReplace  = "replace"  // Replace mode: delete existing rows
+	stepInsert   = "insert"   // Validate and insert rows
+	stepFinalize = "finalize" // Commit, counts, failed rows
+)
+
+// OperationStep declares one step of an operation.
+type OperationStep struct {
+	Name   string
+	Weight int // Relative share of the operation; values below 1 count as 1
+}
+
+// OperationStatus is the overall state of an operation.
+type OperationStatus string
+
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationComplete  OperationStatus = "complete"
+	OperationFailed    OperationStatus = "failed"
+	OperationCancelled OperationStatus = "cancelled"
+)
+
+// StepStatus is the state of one step.
+type StepStatus string
+
+const (
+	StepPending  StepStatus = "pending"
+	StepRunning  StepStatus = "running"
+	StepComplete StepStatus = "complete"
+	StepFailed   StepStatus = "failed"
+)
+
+// StepProgress is the progress of one step.
+type StepProgress struct {
+	Name    string     `json:"name"`
+	Weight  int        `json:"weight"`
+	Status  StepStatus `json:"status"`
+	Current int64      `json:"current"`
+	Total   int64      `json:"total"` // 0 if unknown
+	Detail  string     `json:"detail,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// fraction returns how much of the step is done, from 0 to 1.
+func (p StepProgress) fraction() float64 {
+	switch {
+	case p.Status == StepComplete:
+		return 1
+	case p.Current >= p.Total && p.Total > 0:
+		return 1
+	case p.Total > 0:
+		return float64(p.Current) / float64(p.Total)
+	default:
+		return 0
+	}
+}
+
+// OperationProgress is a snapshot of an operation's progress.
+type OperationProgress struct {
+	OperationID string          `json:"operationId"`
+	Kind        string          `json:"kind"`
+	Status      OperationStatus `json:"status"`
+	TableKey    string          `json:"tableKey,omitempty"`
+	Step        string          `json:"step,omitempty"` // Running step, if any
+	Percent     int             `json:"percent"`
+	Steps       []StepProgress  `json:"steps"`
+	Error       string          `json:"error,omitempty"`
+	ResultLink  string          `json:"resultLink,omitempty"`
+	StartedAt   time.Time       `json:"startedAt"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// Operation tracks the steps of one running operation.
+type Operation struct {
+	mu        sync.Mutex
+	progress  OperationProgress
+	listeners []chan OperationProgress
+	done      chan struct{}
+
+	// A sub-operation reports its progress as one step of its parent
+	parent     *Operation
+	parentStep string
+
+	// Set by bindOperation before the operation is shared
+	initiator string
+	userAgent string
+	onFinish  func(OperationProgress)
+
+	// Set by runDetached; stops the work behind the operation
+	cancel context.CancelFunc
+}
+
+// newOperation creates an operation with all steps pending.
+func newOperation(id, kind string, steps []OperationStep) *Operation {
+	op := &Operation{
+		progress: OperationProgress{
+			OperationID: id,
+			Kind:        kind,
+			Status:      OperationRunning,
+			Steps:       make([]StepProgress, 0, len(steps)),
+			StartedAt:   time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	op.addSteps(steps)
+	return op
+}
+
+// attachTo makes op report its progress as step of parent: the step begins
+// with op's first update, advances with op's percentage, and ends with op.
+// Must be called before op is shared.
+func (op *Operation) attachTo(parent *Operation, step string) {
+	op.parent = parent
+	op.parentStep = step
+}
+
+// StartOperation registers a new operation started from ctx and records it.
+// The caller must call Finish when the work ends.
+func (s *Service) StartOperation(ctx context.Context, kind, tableKey string, steps []OperationStep) *Operation {
+	return s.startOperation(ctx, uuid.New().String(), kind, tableKey, steps)
+}
+
+// startOperation registers and records an operation under a given ID,
+// replacing any finished operation with the same ID.
+func (s *Service) startOperation(ctx context.Context, id, kind, tableKey string, steps []OperationStep) *Operation {
+	op := newOperation(id, kind, steps)
+	s.bindOperation(ctx, op, tableKey)
+	s.mu.Lock()
+	s.operations[id] = op
+	s.mu.Unlock()
+	s.saveOperation(op)
+	return op
+}
+
+// startStepOperation registers and records an operation that reports its
+// progress as step of parent. The caller must call Finish when the work ends.
+func (s *Service) startStepOperation(ctx context.Context, parent *Operation, step, kind, tableKey string, steps []OperationStep) *Operation {
+	op := newOperation(uuid.New().String(), kind, steps)
+	s.bindOperation(ctx, op, tableKey)
+	op.attachTo(parent, step)
+	s.mu.Lock()
+	s.operations[op.ID()] = op
+	s.mu.Unlock()
+	s.saveOperation(op)
+	return op
+}
+
+// runDetached runs fn for op in the background and finishes op with its
+// error. The work outlives ctx, which only supplies request metadata, and
+// stops when CancelOperation is called for op.
+func (s *Service) runDetached(ctx context.Context, op *Operation, fn func(context.Context) error) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	op.mu.Lock()
+	op.cancel = cancel
+	op.mu.Unlock()
+	go func() {
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				p := op.Progress()
+				slog.Error("panic in operation", "operation_id", p.OperationID, "kind", p.Kind, "panic", r)
+				op.Finish(fmt.Errorf("internal error: %v", r))
+			}
+		}()
+		op.Finish(fn(ctx))
+	}()
+}
+
+// CancelOperation stops a running operation started with runDetached. The
+// operation finishes as cancelled once its current batch of work ends.
+func (s *Service) CancelOperation(id string) error {
+	s.mu.RLock()
+	op, ok := s.operations[id]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	}
+
+	op.mu.Lock()
+	cancel := op.cancel
+	running := op.progress.Status == OperationRunning
+	op.mu.Unlock()
+	if cancel == nil || !running {
+		return ErrOperationNotCancellable
+	}
+	cancel()
+	return nil
+}
+
+// GetOperationProgress returns the current progress of an operation.
+func (s *Service) GetOperationProgress(id string) (OperationProgress, error) {
+	s.mu.RLock()
+	op, ok := s.operations[id]
+	s.mu.RUnlock()
+	if !ok {
+		return OperationProgress{}, fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	}
+	return op.Progress(), nil
+}
+
+// SubscribeOperation returns a channel of progress updates for an
+// operation. The current progress is sent first; the channel is closed when
+// the operation finishes. Slow listeners miss intermediate updates.
+func (s *Service) SubscribeOperation(id string) (<-chan OperationProgress, error) {
+	s.mu.RLock()
+	op, ok := s.operations[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	}
+
+	ch := make(chan OperationProgress, 10)
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	ch <- op.snapshot()
+	select {
+	case <-op.done:
+		close(ch)
+	default:
+		op.listeners = append(op.listeners, ch)
+	}
+	return ch, nil
+}
+
+// Progress returns a snapshot of the operation's progress.
+func (op *Operation) Progress() OperationProgress {
+	if op == nil {
+		return OperationProgress{}
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.snapshot()
+}
+
+// Finished reports whether Finish has been called.
+func (op *Operation) Finished() bool {
+	if op == nil {
+		return true
+	}
+	select {
+	case <-op.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// ID returns the operation ID.
+func (op *Operation) ID() string {
+	if op == nil {
+		return ""
+	}
+	return op.progress.OperationID // Immutable
+}
+
+// SetResultLink records where the operation's result can be viewed.
+func (op *Operation) SetResultLink(link string) {
+	op.update(func() bool {
+		op.progress.ResultLink = link
+		return true
+	})
+}
+
+// AddSteps appends steps to a running operation.
+func (op *Operation) AddSteps(steps ...OperationStep) {
+	op.update(func() bool {
+		op.addSteps(steps)
+		return true
+	})
+}
+
+// Begin starts the named step and completes any step before it. Steps
+// only move forward: beginning a step that is behind the running one, or
+// already finished, does nothing.
+func (op *Operation) Begin(step string) {
+	op.update(func() bool {
+		i := op.stepIndex(step)
+		if i < 0 || op.progress.Steps[i].Status != StepPending {
+			return false
+		}
+		for j := range op.progress.Steps[:i] {
+			if s := &op.progress.Steps[j]; s.Status == StepPending || s.Status == StepRunning {
+				s.Status = StepComplete
+			}
+		}
+		op.progress.Steps[i].Status = StepRunning
+		return true
+	})
+}
+
+// Advance reports current of total units done for a running step.
+func (op *Operation) Advance(step string, current, total int64) {
+	op.update(func() bool {
+		i := op.stepIndex(step)
+		if i < 0 || op.progress.Steps[i].Status != StepRunning {
+			return false
+		}
+		op.progress.Steps[i].Current = current
+		op.progress.Steps[i].Total = total
+		return true
+	})
+}
+
+// SetDetail attaches a short description to a step.
+func (op *Operation) SetDetail(step, detail string) {
+	op.update(func() bool {
+		i := op.stepIndex(step)
+		if i < 0 {
+			return false
+		}
+		op.progress.Steps[i].Detail = detail
+		return true
+	})
+}
+
+// EndStep marks a step complete, or failed if err is non-nil, without
+// finishing the operation. Used when a failed step does not stop the rest.
+func (op *Operation) EndStep(step string, err error) {
+	op.update(func() bool {
+		i := op.stepIndex(step)
+		if i < 0 {
+			return false
+		}
+		s := &op.progress.Steps[i]
+		s.Status = StepComplete
+		if err != nil {
+			s.Status = StepFailed
+			s.Error = err.Error()
+		}
+		return true
+	})
+}
+
+// Finish ends the operation. With a nil err every unfinished step is
+// completed; otherwise the running step fails and the operation is marked
+// failed, or cancelled if err is context.Canceled. Later calls do nothing.
+func (op *Operation) Finish(err error) {
+	if op == nil {
+		return
+	}
+	p, ok := op.finish(err)
+	if !ok {
+		return
+	}
+
+	if op.parent != nil {
+		var stepErr error
+		if err != nil {
+			stepErr = fmt.Errorf("%s %s", p.OperationID, p.Status)
+		}
+		op.parent.EndStep(op.parentStep, stepErr)
+	}
+	if op.onFinish != nil {
+		op.onFinish(p)
+	}
+}
+
+// finish applies Finish under the lock and returns the final progress, or
+// false if the operation had already finished.
+func (op *Operation) finish(err error) (OperationProgress, bool) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	select {
+	case <-op.done:
+		return OperationProgress{}, false
+	default:
+	}
+
+	now := time.Now()
+	op.progress.FinishedAt = &now
+	switch {
+	case err == nil:
+		op.progress.Status = OperationComplete
+		for i := range op.progress.Steps {
+			if s := &op.progress.Steps[i]; s.Status != StepFailed {
+				s.Status = StepComplete
+			}
+		}
+	case errors.Is(err, context.Canceled):
+		op.progress.Status = OperationCancelled
+	default:
+		op.progress.Status = OperationFailed
+		op.progress.Error = err.Error()
+		for i := range op.progress.Steps {
+			if s := &op.progress.Steps[i]; s.Status == StepRunning {
+				s.Status = StepFailed
+				s.Error = err.Error()
+			}
+		}
+	}
+
+	op.notify()
+	for _, ch := range op.listeners {
+		close(ch)
+	}
+	op.listeners = nil
+	close(op.done)
+	return op.snapshot(), true
+}
+
+// update applies fn to a running operation and notifies listeners if fn
+// reports a change.
+func (op *Operation) update(fn func() bool) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	if op.progress.Status != OperationRunning {
+		return
+	}
+	if !fn() {
+		return
+	}
+	op.notify()
+	if op.parent != nil {
+		op.parent.Begin(op.parentStep)
+		op.parent.Advance(op.parentStep, int64(op.snapshot().Percent), 100)
+	}
+}
+
+// addSteps appends pending steps. Caller holds op.mu or owns op.
+func (op *Operation) addSteps(steps []OperationStep) {
+	for _, step := range steps {
+		op.progress.Steps = append(op.progress.Steps, StepProgress{
+			Name:   step.Name,
+			Weight: max(step.Weight, 1),
+			Status: StepPending,
+		})
+	}
+}
+
+// stepIndex returns the position of the named step, or -1.
+func (op *Operation) stepIndex(name string) int {
+	for i, s := range op.progress.Steps {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapshot copies the progress and computes the derived fields. Caller
+// holds op.mu.
+func (op *Operation) snapshot() OperationProgress {
+	p := op.progress
+	p.Steps = append([]StepProgress(nil), op.progress.Steps...)
+
+	var done float64
+	var total int
+	p.Step = ""
+	for _, s := range p.Steps {
+		total += s.Weight
+		done += float64(s.Weight) * s.fraction()
+		if s.Status == StepRunning && p.Step == "" {
+			p.Step = s.Name
+		}
+	}
+	if total > 0 {
+		p.Percent = int(done * 100 / float64(total))
+	}
+	if p.Status == OperationComplete {
+		p.Percent = 100
+	}
+	return p
+}
+
+// notify sends the current progress to listeners without blocking.
+// Caller holds op.mu.
+func (op *Operation) notify() {
+	if len(op.listeners) == 0 {
+		return
+	}
+	p := op.snapshot()
+	for _, ch := range op.listeners {
+		select {
+		case ch <- p:
+		default:
+			// Listener is slow, skip this update
+		}
+	}
+}
+
+// failedStepsError summarizes failed steps as an operation error, or
+// returns nil if no step failed. unit names what the steps are.
+func failedStepsError(p OperationProgress, unit string) error {
+	failed := 0
+	for _, step := range p.Steps {
+		if step.Status == StepFailed {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d %s failed", failed, len(p.Steps), unit)
+}
+
+// uploadSteps returns the steps of an upload in the given mode. Inserting
+// rows dominates the time, so it carries most of the weight.
+func uploadSteps(mode UploadMode) []OperationStep {
+	steps := []OperationStep{{Name: stepRead, Weight: 1}}
+	if mode == UploadModeReplace {
+		steps = append(steps, OperationStep{Name: stepReplace, Weight: 2})
+	}
+	return append(steps,
+		OperationStep{Name: stepInsert, Weight: 8},
+		OperationStep{Name: stepFinalize, Weight: 1},
+	)
+}
+
+// syncUploadOperation mirrors upload progress onto the upload's operation
+// steps. Replace and finalize have no upload phase and are begun directly
+// by the upload pipeline.
+func syncUploadOperation(op *Operation, p UploadProgress) {
+	switch p.Phase {
+	case PhaseStarting, PhaseReading:
+		op.Begin(stepRead)
+	case PhaseValidating, PhaseInserting:
+		op.Begin(stepInsert)
+		if p.TotalRows > 0 {
+			op.Advance(stepInsert, int64(p.CurrentRow), int64(p.TotalRows))
+		} else {
+			op.Advance(stepInsert, p.BytesRead, p.BytesTotal)
+		}
+	case PhaseComplete:
+		op.Finish(nil)
+	case PhaseFailed:
+		op.Finish(errors.New(p.Error))
+	case PhaseCancelled:
+		op.Finish(context.Canceled)
+	}
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOperation_WeightedSteps(t *testing.T) {
+	op := newOperation("op1", OperationUpload, uploadSteps(UploadModeReplace))
+	if p := op.Progress(); len(p.Steps) != 4 || p.Percent != 0 || p.Status != OperationRunning {
+		t.Fatalf("initial progress = %+v", p)
+	}
+
+	// Weights: read 1, replace 2, insert 8, finalize 1
+	op.Begin(stepRead)
+	op.Begin(stepReplace)
+	if p := op.Progress(); p.Step != stepReplace || p.Steps[0].Status != StepComplete || p.Percent != 8 {
+		t.Errorf("after replace begins: step %q, percent %d", p.Step, p.Percent)
+	}
+
+	op.Begin(stepInsert)
+	op.Advance(stepInsert, 50, 100)
+	if p := op.Progress(); p.Percent != 58 {
+		t.Errorf("halfway through insert: percent %d, want 58", p.Percent)
+	}
+
+	// Steps only move forward
+	op.Begin(stepRead)
+	op.Advance(stepRead, 1, 1)
+	if p := op.Progress(); p.Step != stepInsert || p.Percent != 58 {
+		t.Errorf("after moving back: step %q, percent %d", p.Step, p.Percent)
+	}
+
+	op.Finish(nil)
+	if p := op.Progress(); p.Status != OperationComplete || p.Percent != 100 || p.FinishedAt == nil {
+		t.Errorf("finished progress = %+v", p)
+	}
+	op.Finish(errors.New("late"))
+	if p := op.Progress(); p.Status != OperationComplete || p.Error != "" {
+		t.Errorf("second Finish changed progress: %+v", p)
+	}
+}
+
+func TestOperation_FailureAndCancel(t *testing.T) {
+	op := newOperation("op1", OperationUpload, uploadSteps(UploadModeInsert))
+	op.Begin(stepInsert)
+	op.Finish(errors.New("boom"))
+	p := op.Progress()
+	if p.Status != OperationFailed || p.Error != "boom" || p.Steps[1].Status != StepFailed || p.Steps[2].Status != StepPending {
+		t.Errorf("failed progress = %+v", p)
+	}
+
+	op = newOperation("op2", OperationUpload, uploadSteps(UploadModeInsert))
+	op.Finish(context.Canceled)
+	if p := op.Progress(); p.Status != OperationCancelled || p.Error != "" {
+		t.Errorf("cancelled progress = %+v", p)
+	}
+
+	var nilOp *Operation
+	nilOp.Begin(stepRead)
+	nilOp.Finish(nil)
+	if !nilOp.Finished() {
+		t.Error("nil operation should report finished")
+	}
+}
+
+func TestOperation_SubOperationsReportToParent(t *testing.T) {
+	parent := newOperation("batch", OperationUploadBatch, []OperationStep{
+		{Name: "1. a.csv", Weight: 1},
+		{Name: "2. b.csv", Weight: 3},
+	})
+	a := newOperation("a", OperationUpload, uploadSteps(UploadModeInsert))
+	a.attachTo(parent, "1. a.csv")
+	b := newOperation("b", OperationUpload, uploadSteps(UploadModeInsert))
+	b.attachTo(parent, "2. b.csv")
+
+	a.Begin(stepRead)
+	if p := parent.Progress(); p.Step != "1. a.csv" || p.Steps[0].Status != StepRunning {
+		t.Errorf("parent after child begins = %+v", p)
+	}
+	a.Finish(errors.New("bad header"))
+	b.Begin(stepInsert)
+	b.Advance(stepInsert, 1, 2)
+
+	p := parent.Progress()
+	if p.Steps[0].Status != StepFailed || p.Steps[0].Error == "" {
+		t.Errorf("failed child step = %+v", p.Steps[0])
+	}
+	// b is at 1 (read) + 4 (half of insert) of 10 weight = 50%, so its
+	// step contributes 3 * 0.5 of 4
+	if p.Percent != 37 {
+		t.Errorf("parent percent = %d, want 37", p.Percent)
+	}
+
+	b.Finish(nil)
+	parent.Finish(failedStepsError(parent.Progress(), "files"))
+	if p := parent.Progress(); p.Status != OperationFailed || p.Error != "1 of 2 files failed" {
+		t.Errorf("parent after children finish = %+v", p)
+	}
+}
+
+func TestSyncUploadOperation(t *testing.T) {
+	op := newOperation("u", OperationUpload, uploadSteps(UploadModeInsert))
+	syncUploadOperation(op, UploadProgress{Phase: PhaseInserting, BytesRead: 30, BytesTotal: 60})
+	p := op.Progress()
+	if p.Step != stepInsert || p.Steps[1].Current != 30 || p.Steps[1].Total != 60 {
+		t.Errorf("streaming progress = %+v", p)
+	}
+	syncUploadOperation(op, UploadProgress{Phase: PhaseInserting, TotalRows: 10, CurrentRow: 4})
+	if p := op.Progress(); p.Steps[1].Current != 4 || p.Steps[1].Total != 10 {
+		t.Errorf("row progress = %+v", p.Steps[1])
+	}
+	syncUploadOperation(op, UploadProgress{Phase: PhaseFailed, Error: "commit: boom"})
+	if p := op.Progress(); p.Status != OperationFailed || p.Error != "commit: boom" {
+		t.Errorf("failed sync = %+v", p)
+	}
+}
+
+func TestBindOperation_Initiator(t *testing.T) {
+	s := &Service{operations: make(map[string]*Operation)}
+
+	op := newOperation("job", OperationRetention, []OperationStep{{Name: "archive", Weight: 1}})
+	s.bindOperation(context.Background(), op, "")
+	if op.initiator != operationInitiatorSystem {
+		t.Errorf("background initiator = %q, want %q", op.initiator, operationInitiatorSystem)
+	}
+
+	ctx := ContextWithIPAddress(context.Background(), "10.0.0.1")
+	op = newOperation("export", OperationExport, []OperationStep{{Name: "export", Weight: 1}})
+	s.bindOperation(ctx, op, "customers")
+	if op.initiator != "10.0.0.1" || op.Progress().TableKey != "customers" {
+		t.Errorf("request operation = initiator %q, progress %+v", op.initiator, op.Progress())
+	}
+
+	// Finishing without a database only skips the record
+	op.Finish(nil)
+	if p := op.Progress(); p.Status != OperationComplete {
+		t.Errorf("finished progress = %+v", p)
+	}
+}
+
+func TestCancelOperation(t *testing.T) {
+	s := &Service{operations: make(map[string]*Operation)}
+	ctx := context.Background()
+
+	if err := s.CancelOperation("missing"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("missing: err = %v", err)
+	}
+
+	// Only work started with runDetached can be stopped
+	plain := s.StartOperation(ctx, OperationReset, "customers", []OperationStep{{Name: "customers", Weight: 1}})
+	if err := s.CancelOperation(plain.ID()); !errors.Is(err, ErrOperationNotCancellable) {
+		t.Errorf("synchronous: err = %v", err)
+	}
+	plain.Finish(nil)
+
+	op := s.StartOperation(ctx, OperationRollback, "customers", rollbackSteps)
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := s.CancelOperation(op.ID()); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	<-op.done
+	if p := op.Progress(); p.Status != OperationCancelled {
+		t.Errorf("status = %s, want cancelled", p.Status)
+	}
+	if err := s.CancelOperation(op.ID()); !errors.Is(err, ErrOperationNotCancellable) {
+		t.Errorf("finished: err = %v", err)
+	}
+}
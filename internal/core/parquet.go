@@ -0,0 +1,819 @@
+package core
+
+// parquet.go imports Parquet files, so large fact-table extracts can be
+// loaded without converting them to CSV first.
+//
+// Like JSON (see json_ingest.go), a Parquet file is converted to CSV as it
+// is read and then goes through the same validation and batch pipeline as
+// any other upload. The file is read one row group at a time, so memory
+// grows with the size of a row group rather than of the file. Parquet keeps
+// its metadata at the end of the file, so it needs random access: uploads
+// are read from the multipart file or the spool, and a Parquet file cannot
+// be streamed from a URL.
+//
+// Each Parquet column becomes a CSV column named after it. Without a column
+// mapping the table's columns come first, in order, matched to Parquet
+// columns by name as header cells are, followed by the other Parquet
+// columns; with a mapping (e.g. from an import template) the columns stay
+// in schema order, so mapping indexes count Parquet columns.
+//
+// Supported: flat schemas of required and optional columns, every physical
+// type, the PLAIN, dictionary, RLE, delta and byte-stream-split encodings,
+// data pages v1 and v2, and Snappy, gzip or no compression. Nested and
+// repeated columns, other codecs (notably Zstandard) and encrypted files
+// are rejected with ErrInvalidParquet.
+//
+// Values are written as:
+//   - DATE as 2006-01-02
+//   - TIMESTAMP and INT96 in UTC, as 2006-01-02 15:04:05.999999999, or as a
+//     date alone at midnight so they load into date columns
+//   - TIME as 15:04:05.999999999
+//   - DECIMAL as a plain decimal number, e.g. 1234.50
+//   - Nulls as empty cells
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidParquet is returned for a Parquet file that cannot be read.
+var ErrInvalidParquet = errors.New("invalid parquet file")
+
+const (
+	parquetMagic = "PAR1"
+
+	// maxParquetFooter bounds the metadata read before it is decoded.
+	maxParquetFooter = 64 << 20
+
+	// parquetFlushBytes is how much CSV is buffered before Read returns it.
+	parquetFlushBytes = 64 << 10
+
+	// julianUnixEpoch is the Julian day of 1970-01-01, for INT96 timestamps.
+	julianUnixEpoch = 2440588
+)
+
+// isParquetUpload reports whether an upload is Parquet by its extension,
+// else by the "PAR1" magic that starts and ends every Parquet file. data
+// may be nil, or hold the start or all of the file.
+func isParquetUpload(fileName string, data []byte) bool {
+	if strings.EqualFold(filepath.Ext(fileName), ".parquet") {
+		return true
+	}
+	return len(data) >= 12 && bytes.HasPrefix(data, []byte(parquetMagic)) && bytes.HasSuffix(data, []byte(parquetMagic))
+}
+
+// sniffParquet reports whether r, of size bytes, starts and ends with the
+// Parquet magic.
+func sniffParquet(r io.ReaderAt, size int64) bool {
+	if size < 12 {
+		return false
+	}
+	var head, tail [4]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return false
+	}
+	if _, err := r.ReadAt(tail[:], size-4); err != nil && err != io.EOF {
+		return false
+	}
+	return string(head[:]) == parquetMagic && string(tail[:]) == parquetMagic
+}
+
+// parquetAsCSV converts data to CSV if it is a Parquet upload, for uploads
+// processed in memory. Call it before transcoding, which would mangle the
+// binary file.
+func parquetAsCSV(fileName string, data []byte, def TableDefinition, mapped bool) ([]byte, error) {
+	if !isParquetUpload(fileName, data) {
+		return data, nil
+	}
+	f, err := openParquet(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(newParquetRecordReader(f, def, mapped))
+}
+
+// parquetSource opens reader as Parquet if the upload is Parquet, and
+// returns nil if it is not. Parquet needs random access, so reader must
+// also be an io.ReaderAt of fileSize bytes.
+func parquetSource(reader io.Reader, fileName string, fileSize int64, def TableDefinition, mapped bool) (*parquetRecordReader, error) {
+	ra, ok := reader.(io.ReaderAt)
+	if !ok || fileSize <= 0 {
+		if isParquetUpload(fileName, nil) {
+			return nil, fmt.Errorf("%w: Parquet needs random access; upload the file itself rather than a stream or URL", ErrInvalidParquet)
+		}
+		return nil, nil
+	}
+	if !isParquetUpload(fileName, nil) && !sniffParquet(ra, fileSize) {
+		return nil, nil
+	}
+	f, err := openParquet(ra, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	return newParquetRecordReader(f, def, mapped), nil
+}
+
+// wrapParquetForStreaming is wrapForStreaming for Parquet converted to
+// CSV. Progress counts the bytes of the Parquet file read, so it stays
+// comparable with totalSize.
+func wrapParquetForStreaming(pq *parquetRecordReader, totalSize int64) *StreamingCountingReader {
+	counter := &StreamingCountingReader{Total: totalSize, countedAtSource: true}
+	pq.read = &counter.BytesRead
+	counter.peek = bufio.NewReaderSize(NewStreamingUTF8Sanitizer(pq), sniffSize)
+	counter.reader = counter.peek
+	return counter
+}
+
+// parquetValueKind is how a column's values are written as text.
+type parquetValueKind int
+
+const (
+	pqValuePlain parquetValueKind = iota
+	pqValueDecimal
+	pqValueDate
+	pqValueTime
+	pqValueTimestamp
+	pqValueUnsigned
+	pqValueUUID
+)
+
+// parquetColumn is a leaf column of a flat schema.
+type parquetColumn struct {
+	name       string
+	typ        int32
+	typeLength int
+	optional   bool
+	kind       parquetValueKind
+	unit       int16 // pqMillis, pqMicros or pqNanos for times and timestamps
+	scale      int   // Decimal places of a decimal
+}
+
+func newParquetColumn(el pqSchemaElement) parquetColumn {
+	c := parquetColumn{
+		name:       el.name,
+		typ:        el.typ,
+		typeLength: int(el.typeLength),
+		optional:   el.repetition == pqOptional,
+	}
+	lt := el.logical
+	switch {
+	case lt.kind == pqLogicalDecimal:
+		c.kind, c.scale = pqValueDecimal, int(lt.scale)
+	case lt.kind == pqLogicalDate:
+		c.kind = pqValueDate
+	case lt.kind == pqLogicalTime:
+		c.kind, c.unit = pqValueTime, lt.unit
+	case lt.kind == pqLogicalTimestamp:
+		c.kind, c.unit = pqValueTimestamp, lt.unit
+	case lt.kind == pqLogicalInteger && lt.unsigned:
+		c.kind = pqValueUnsigned
+	case lt.kind == pqLogicalUUID:
+		c.kind = pqValueUUID
+	case lt.kind != 0:
+		// Other annotations (strings, JSON, enums, signed integers) are written as is
+	case el.converted == pqConvertedDecimal:
+		c.kind, c.scale = pqValueDecimal, int(el.scale)
+	case el.converted == pqConvertedDate:
+		c.kind = pqValueDate
+	case el.converted == pqConvertedTimeMillis:
+		c.kind, c.unit = pqValueTime, pqMillis
+	case el.converted == pqConvertedTimeMicros:
+		c.kind, c.unit = pqValueTime, pqMicros
+	case el.converted == pqConvertedTimestampMillis:
+		c.kind, c.unit = pqValueTimestamp, pqMillis
+	case el.converted == pqConvertedTimestampMicros:
+		c.kind, c.unit = pqValueTimestamp, pqMicros
+	case el.converted >= pqConvertedUint8 && el.converted <= pqConvertedUint64:
+		c.kind = pqValueUnsigned
+	}
+	return c
+}
+
+// parquetFile is an open Parquet file.
+type parquetFile struct {
+	r          io.ReaderAt
+	size       int64
+	footerSize int64
+	meta       *pqFileMetaData
+	columns    []parquetColumn
+}
+
+// openParquet reads and checks the metadata of a Parquet file.
+func openParquet(r io.ReaderAt, size int64) (*parquetFile, error) {
+	if size < 12 {
+		return nil, fmt.Errorf("%w: file too short", ErrInvalidParquet)
+	}
+	var head [4]byte
+	var tail [8]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, fmt.Errorf("read parquet file: %w", err)
+	}
+	if _, err := r.ReadAt(tail[:], size-8); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read parquet file: %w", err)
+	}
+	if string(tail[4:]) == "PARE" {
+		return nil, fmt.Errorf("%w: encrypted files are not supported", ErrInvalidParquet)
+	}
+	if string(head[:]) != parquetMagic || string(tail[4:]) != parquetMagic {
+		return nil, fmt.Errorf("%w: not a Parquet file", ErrInvalidParquet)
+	}
+
+	footerLen := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerLen == 0 || footerLen > size-12 || footerLen > maxParquetFooter {
+		return nil, fmt.Errorf("%w: bad footer length %d", ErrInvalidParquet, footerLen)
+	}
+	footer := make([]byte, footerLen)
+	if _, err := r.ReadAt(footer, size-8-footerLen); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read parquet file: %w", err)
+	}
+	meta, err := readFileMetaData(footer)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &parquetFile{r: r, size: size, footerSize: footerLen + 8, meta: meta}
+	if len(meta.schema) < 2 {
+		return nil, fmt.Errorf("%w: no columns", ErrInvalidParquet)
+	}
+	if int(meta.schema[0].numChildren) != len(meta.schema)-1 {
+		return nil, fmt.Errorf("%w: nested columns are not supported; flatten the schema first", ErrInvalidParquet)
+	}
+	for _, el := range meta.schema[1:] {
+		switch {
+		case el.numChildren > 0 || el.typ < 0:
+			return nil, fmt.Errorf("%w: nested column %q is not supported; flatten the schema first", ErrInvalidParquet, el.name)
+		case el.repetition == pqRepeated:
+			return nil, fmt.Errorf("%w: repeated column %q is not supported", ErrInvalidParquet, el.name)
+		case el.typ > pqFixedLenByteArray:
+			return nil, fmt.Errorf("%w: column %q has unknown type %d", ErrInvalidParquet, el.name, el.typ)
+		}
+		f.columns = append(f.columns, newParquetColumn(el))
+	}
+
+	for i, rg := range meta.rowGroups {
+		if len(rg.columns) != len(f.columns) {
+			return nil, fmt.Errorf("%w: row group %d has %d columns, want %d", ErrInvalidParquet, i+1, len(rg.columns), len(f.columns))
+		}
+		if rg.numRows < 0 {
+			return nil, fmt.Errorf("%w: row group %d has a negative row count", ErrInvalidParquet, i+1)
+		}
+		for j, cc := range rg.columns {
+			if cc.filePath != "" {
+				return nil, fmt.Errorf("%w: column %q is stored in another file", ErrInvalidParquet, f.columns[j].name)
+			}
+			if cc.meta.typ != f.columns[j].typ {
+				return nil, fmt.Errorf("%w: column %q has a different type in row group %d", ErrInvalidParquet, f.columns[j].name, i+1)
+			}
+		}
+	}
+	return f, nil
+}
+
+// readColumn reads and decodes one column of a row group as CSV cells.
+func (f *parquetFile) readColumn(rg pqRowGroup, i int) ([]string, int64, error) {
+	c := &f.columns[i]
+	meta := rg.columns[i].meta
+
+	start := meta.dataPageOffset
+	if meta.hasDictPageOffs && meta.dictPageOffset > 0 && meta.dictPageOffset < start {
+		start = meta.dictPageOffset
+	}
+	if start < int64(len(parquetMagic)) || meta.compressedSize <= 0 || start+meta.compressedSize > f.size-f.footerSize {
+		return nil, 0, fmt.Errorf("%w: column %q has a bad offset", ErrInvalidParquet, c.name)
+	}
+	buf := make([]byte, meta.compressedSize)
+	if _, err := f.r.ReadAt(buf, start); err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("read parquet column %q: %w", c.name, err)
+	}
+
+	rows := int(rg.numRows)
+	cells := make([]string, 0, rows)
+	var dict []string
+	for len(cells) < rows {
+		h, n, err := readPageHeader(buf)
+		if err != nil {
+			return nil, 0, fmt.Errorf("column %q: %w", c.name, err)
+		}
+		buf = buf[n:]
+		if int(h.compressedSize) > len(buf) {
+			return nil, 0, fmt.Errorf("%w: column %q is truncated", ErrInvalidParquet, c.name)
+		}
+		body := buf[:h.compressedSize]
+		buf = buf[h.compressedSize:]
+
+		switch h.typ {
+		case pqDictionaryPage:
+			if h.encoding != pqPlain && h.encoding != pqPlainDictionary {
+				return nil, 0, fmt.Errorf("%w: column %q has an unsupported dictionary encoding %d", ErrInvalidParquet, c.name, h.encoding)
+			}
+			data, err := decompressPage(meta.codec, body, int(h.uncompressedSize))
+			if err == nil {
+				dict, err = c.plainValues(data, int(h.numValues))
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("column %q: %w", c.name, err)
+			}
+		case pqDataPage, pqDataPageV2:
+			if int(h.numValues) > rows-len(cells) {
+				return nil, 0, fmt.Errorf("%w: column %q has more values than rows", ErrInvalidParquet, c.name)
+			}
+			cells, err = c.readDataPage(h, meta.codec, body, dict, cells)
+			if err != nil {
+				return nil, 0, fmt.Errorf("column %q: %w", c.name, err)
+			}
+		default:
+			// Index pages carry nothing to import
+		}
+		if len(buf) == 0 && len(cells) < rows {
+			return nil, 0, fmt.Errorf("%w: column %q has fewer values than rows", ErrInvalidParquet, c.name)
+		}
+	}
+	return cells, meta.compressedSize, nil
+}
+
+// readDataPage decodes a data page and appends its cells to cells.
+func (c *parquetColumn) readDataPage(h pqPageHeader, codec int32, body []byte, dict []string, cells []string) ([]string, error) {
+	n := int(h.numValues)
+	var levels, data []byte
+	if h.typ == pqDataPageV2 {
+		if h.repLength != 0 {
+			return nil, fmt.Errorf("%w: repeated values are not supported", ErrInvalidParquet)
+		}
+		if h.defLength < 0 || int(h.defLength) > len(body) {
+			return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+		}
+		levels, data = body[:h.defLength], body[h.defLength:]
+		if h.isCompressed {
+			var err error
+			if data, err = decompressPage(codec, data, int(h.uncompressedSize-h.defLength)); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		var err error
+		if data, err = decompressPage(codec, body, int(h.uncompressedSize)); err != nil {
+			return nil, err
+		}
+		if c.optional {
+			if h.defEncoding != pqRLE {
+				return nil, fmt.Errorf("%w: unsupported definition level encoding %d", ErrInvalidParquet, h.defEncoding)
+			}
+			if len(data) < 4 {
+				return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+			}
+			l := binary.LittleEndian.Uint32(data)
+			if uint64(l) > uint64(len(data)-4) {
+				return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+			}
+			levels, data = data[4:4+l], data[4+l:]
+		}
+	}
+
+	present := n
+	var defs []uint32
+	if c.optional {
+		var err error
+		if defs, err = readHybrid(levels, 1, n); err != nil {
+			return nil, err
+		}
+		present = 0
+		for _, d := range defs {
+			if d == 1 {
+				present++
+			}
+		}
+	}
+
+	values, err := c.decodeValues(h.encoding, data, present, dict)
+	if err != nil {
+		return nil, err
+	}
+	if defs == nil {
+		return append(cells, values...), nil
+	}
+	next := 0
+	for _, d := range defs {
+		if d == 1 {
+			cells = append(cells, values[next])
+			next++
+		} else {
+			cells = append(cells, "")
+		}
+	}
+	return cells, nil
+}
+
+// decodeValues decodes n non-null values of a data page.
+func (c *parquetColumn) decodeValues(encoding int32, data []byte, n int, dict []string) ([]string, error) {
+	switch encoding {
+	case pqPlain:
+		return c.plainValues(data, n)
+
+	case pqPlainDictionary, pqRLEDictionary:
+		if dict == nil {
+			return nil, fmt.Errorf("%w: dictionary page missing", ErrInvalidParquet)
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+		}
+		idx, err := readHybrid(data[1:], int(data[0]), n)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]string, n)
+		for i, k := range idx {
+			if int(k) >= len(dict) {
+				return nil, fmt.Errorf("%w: dictionary index out of range", ErrInvalidParquet)
+			}
+			out[i] = dict[k]
+		}
+		return out, nil
+
+	case pqRLE:
+		if c.typ != pqBoolean || len(data) < 4 {
+			return nil, fmt.Errorf("%w: unsupported RLE values", ErrInvalidParquet)
+		}
+		bits, err := readHybrid(data[4:], 1, n)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]string, n)
+		for i, b := range bits {
+			out[i] = strconv.FormatBool(b == 1)
+		}
+		return out, nil
+
+	case pqDeltaBinaryPacked:
+		if c.typ != pqInt32 && c.typ != pqInt64 {
+			break
+		}
+		ints, _, err := readDeltaBinaryPacked(data, n)
+		if err != nil {
+			return nil, err
+		}
+		if len(ints) < n {
+			return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+		}
+		out := make([]string, n)
+		for i := range out {
+			if c.typ == pqInt32 {
+				out[i] = c.int32Cell(int32(ints[i]))
+			} else {
+				out[i] = c.int64Cell(ints[i])
+			}
+		}
+		return out, nil
+
+	case pqDeltaLengthByteArray, pqDeltaByteArray:
+		if c.typ != pqByteArray && c.typ != pqFixedLenByteArray {
+			break
+		}
+		var vals [][]byte
+		var err error
+		if encoding == pqDeltaByteArray {
+			vals, err = readDeltaByteArray(data, n)
+		} else {
+			vals, _, err = readDeltaLengthByteArray(data, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) < n {
+			return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+		}
+		out := make([]string, n)
+		for i := range out {
+			out[i] = c.bytesCell(vals[i])
+		}
+		return out, nil
+
+	case pqByteStreamSplit:
+		width := c.width()
+		if width <= 0 {
+			break
+		}
+		plain, err := unsplitBytes(data, width, n)
+		if err != nil {
+			return nil, err
+		}
+		return c.plainValues(plain, n)
+	}
+	return nil, fmt.Errorf("%w: unsupported encoding %d for column %q", ErrInvalidParquet, encoding, c.name)
+}
+
+// width returns the size in bytes of a fixed-size value; 0 for booleans
+// and byte arrays.
+func (c *parquetColumn) width() int {
+	switch c.typ {
+	case pqInt32, pqFloat:
+		return 4
+	case pqInt64, pqDouble:
+		return 8
+	case pqInt96:
+		return 12
+	case pqFixedLenByteArray:
+		return c.typeLength
+	}
+	return 0
+}
+
+// plainValues decodes n PLAIN-encoded values.
+func (c *parquetColumn) plainValues(data []byte, n int) ([]string, error) {
+	truncated := fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+	if n < 0 {
+		return nil, truncated
+	}
+
+	if c.typ == pqBoolean {
+		if len(data) < (n+7)/8 {
+			return nil, truncated
+		}
+		out := make([]string, n)
+		for i := range out {
+			out[i] = strconv.FormatBool(data[i>>3]&(1<<(i&7)) != 0)
+		}
+		return out, nil
+	}
+
+	if c.typ == pqByteArray {
+		out := make([]string, 0, min(n, len(data)/4))
+		for i := 0; i < n; i++ {
+			if len(data) < 4 {
+				return nil, truncated
+			}
+			l := binary.LittleEndian.Uint32(data)
+			if uint64(l) > uint64(len(data)-4) {
+				return nil, truncated
+			}
+			out = append(out, c.bytesCell(data[4:4+l]))
+			data = data[4+l:]
+		}
+		return out, nil
+	}
+
+	width := c.width()
+	if width <= 0 {
+		return nil, fmt.Errorf("%w: column %q has a bad type length", ErrInvalidParquet, c.name)
+	}
+	if len(data)/width < n {
+		return nil, truncated
+	}
+	out := make([]string, n)
+	for i := range out {
+		v := data[i*width : (i+1)*width]
+		switch c.typ {
+		case pqInt32:
+			out[i] = c.int32Cell(int32(binary.LittleEndian.Uint32(v)))
+		case pqInt64:
+			out[i] = c.int64Cell(int64(binary.LittleEndian.Uint64(v)))
+		case pqInt96:
+			nanos := int64(binary.LittleEndian.Uint64(v))
+			day := int64(binary.LittleEndian.Uint32(v[8:]))
+			out[i] = timestampCell(time.Unix((day-julianUnixEpoch)*86400, nanos))
+		case pqFloat:
+			out[i] = strconv.FormatFloat(float64(float32At(v)), 'f', -1, 32)
+		case pqDouble:
+			out[i] = strconv.FormatFloat(float64At(v), 'f', -1, 64)
+		case pqFixedLenByteArray:
+			out[i] = c.bytesCell(v)
+		}
+	}
+	return out, nil
+}
+
+func (c *parquetColumn) int32Cell(v int32) string {
+	switch c.kind {
+	case pqValueDecimal:
+		return formatDecimal(big.NewInt(int64(v)), c.scale)
+	case pqValueDate:
+		return time.Unix(int64(v)*86400, 0).UTC().Format("2006-01-02")
+	case pqValueTime:
+		return timeOfDayCell(int64(v), c.unit)
+	case pqValueUnsigned:
+		return strconv.FormatUint(uint64(uint32(v)), 10)
+	}
+	return strconv.FormatInt(int64(v), 10)
+}
+
+func (c *parquetColumn) int64Cell(v int64) string {
+	switch c.kind {
+	case pqValueDecimal:
+		return formatDecimal(big.NewInt(v), c.scale)
+	case pqValueTime:
+		return timeOfDayCell(v, c.unit)
+	case pqValueTimestamp:
+		switch c.unit {
+		case pqMillis:
+			return timestampCell(time.UnixMilli(v))
+		case pqMicros:
+			return timestampCell(time.UnixMicro(v))
+		default:
+			return timestampCell(time.Unix(0, v))
+		}
+	case pqValueUnsigned:
+		return strconv.FormatUint(uint64(v), 10)
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func (c *parquetColumn) bytesCell(b []byte) string {
+	switch {
+	case c.kind == pqValueDecimal:
+		// Big-endian two's complement
+		v := new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+		}
+		return formatDecimal(v, c.scale)
+	case c.kind == pqValueUUID && len(b) == 16:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	}
+	return string(b)
+}
+
+// formatDecimal writes an unscaled decimal with scale decimal places.
+func formatDecimal(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return unscaled.String()
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	s := digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// timestampCell writes t in UTC, as a date alone if it is midnight.
+func timestampCell(t time.Time) string {
+	t = t.UTC()
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04:05.999999999")
+}
+
+// timeOfDayCell writes a time since midnight in the given unit.
+func timeOfDayCell(v int64, unit int16) string {
+	switch unit {
+	case pqMillis:
+		v *= int64(time.Millisecond)
+	case pqMicros:
+		v *= int64(time.Microsecond)
+	}
+	return time.Unix(0, v).UTC().Format("15:04:05.999999999")
+}
+
+// parquetRecordReader converts a Parquet file to CSV, one row group at a time.
+type parquetRecordReader struct {
+	file    *parquetFile
+	def     TableDefinition
+	mapped  bool   // Header is the Parquet columns alone, for an explicit column mapping
+	read    *int64 // If set, bytes of the file read so far, for progress
+	started bool
+	order   []int      // Parquet column of each CSV column; -1 for none
+	group   int        // Next row group to read
+	cells   [][]string // Current row group, by Parquet column
+	row     int        // Next row of the current row group
+	rows    int        // Rows in the current row group
+	out     bytes.Buffer
+	csv     *csv.Writer
+	err     error // Returned once out is drained
+}
+
+// newParquetRecordReader returns f as CSV with a header row. mapped is
+// whether the upload has an explicit column mapping.
+func newParquetRecordReader(f *parquetFile, def TableDefinition, mapped bool) *parquetRecordReader {
+	p := &parquetRecordReader{file: f, def: def, mapped: mapped}
+	p.csv = csv.NewWriter(&p.out)
+	return p
+}
+
+func (p *parquetRecordReader) Read(b []byte) (int, error) {
+	for p.out.Len() == 0 && p.err == nil {
+		switch {
+		case !p.started:
+			p.started = true
+			p.err = p.start()
+		case p.row < p.rows:
+			p.writeRows()
+		case p.group < len(p.file.meta.rowGroups):
+			p.err = p.loadGroup()
+		default:
+			p.err = io.EOF
+		}
+	}
+
+	if p.out.Len() > 0 {
+		return p.out.Read(b)
+	}
+	return 0, p.err
+}
+
+// start chooses the CSV columns and writes the header.
+func (p *parquetRecordReader) start() error {
+	p.addRead(p.file.footerSize)
+
+	var header []string
+	if p.mapped {
+		for i, c := range p.file.columns {
+			header = append(header, c.name)
+			p.order = append(p.order, i)
+		}
+	} else {
+		slots := make(map[string]int)
+		for i := len(p.file.columns) - 1; i >= 0; i-- {
+			slots[normalizeJSONKey(p.file.columns[i].name)] = i // First wins
+		}
+		used := make([]bool, len(p.file.columns))
+		matched := false
+		for _, col := range p.def.Info.Columns {
+			i, ok := slots[normalizeJSONKey(col)]
+			if ok && !used[i] {
+				used[i], matched = true, true
+			} else {
+				i = -1
+			}
+			header = append(header, col)
+			p.order = append(p.order, i)
+		}
+		if !matched {
+			return fmt.Errorf("header not found (expected: %v): no Parquet column is named after a column; set a column mapping",
+				p.def.Info.Columns)
+		}
+		for i, c := range p.file.columns {
+			if !used[i] {
+				header = append(header, c.name)
+				p.order = append(p.order, i)
+			}
+		}
+	}
+
+	p.csv.Write(header)
+	p.csv.Flush()
+	return nil
+}
+
+// loadGroup reads the next row group.
+func (p *parquetRecordReader) loadGroup() error {
+	rg := p.file.meta.rowGroups[p.group]
+	p.group++
+	p.cells = make([][]string, len(p.file.columns))
+	for i := range p.file.columns {
+		cells, n, err := p.file.readColumn(rg, i)
+		if err != nil {
+			return err
+		}
+		p.cells[i] = cells
+		p.addRead(n)
+	}
+	p.row, p.rows = 0, int(rg.numRows)
+	return nil
+}
+
+// writeRows writes rows of the current row group until enough CSV is
+// buffered.
+func (p *parquetRecordReader) writeRows() {
+	record := make([]string, len(p.order))
+	for p.row < p.rows && p.out.Len() < parquetFlushBytes {
+		for j, i := range p.order {
+			if i < 0 {
+				record[j] = ""
+			} else {
+				record[j] = p.cells[i][p.row]
+			}
+		}
+		// Writing to a bytes.Buffer cannot fail
+		p.csv.Write(record)
+		p.row++
+		if p.row%256 == 0 {
+			p.csv.Flush()
+		}
+	}
+	p.csv.Flush()
+	if p.row == p.rows {
+		p.cells = nil
+	}
+}
+
+func (p *parquetRecordReader) addRead(n int64) {
+	if p.read != nil {
+		*p.read += n
+	}
+}
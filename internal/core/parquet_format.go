@@ -0,0 +1,893 @@
+package core
+
+// parquet_format.go decodes the parts of the Parquet format that
+// parquet.go needs: the Thrift compact-protocol footer and page headers,
+// the value encodings, and Snappy and gzip page compression. It covers
+// flat schemas only; see parquet.go for what is supported.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Parquet physical types.
+const (
+	pqBoolean           = 0
+	pqInt32             = 1
+	pqInt64             = 2
+	pqInt96             = 3
+	pqFloat             = 4
+	pqDouble            = 5
+	pqByteArray         = 6
+	pqFixedLenByteArray = 7
+)
+
+// Parquet repetition types.
+const (
+	pqRequired = 0
+	pqOptional = 1
+	pqRepeated = 2
+)
+
+// Parquet converted types (the legacy logical type annotations).
+const (
+	pqConvertedUTF8            = 0
+	pqConvertedDecimal         = 5
+	pqConvertedDate            = 6
+	pqConvertedTimeMillis      = 7
+	pqConvertedTimeMicros      = 8
+	pqConvertedTimestampMillis = 9
+	pqConvertedTimestampMicros = 10
+	pqConvertedUint8           = 11
+	pqConvertedUint64          = 14
+)
+
+// Parquet logical types, by their field ID in the LogicalType union.
+const (
+	pqLogicalDecimal   = 5
+	pqLogicalDate      = 6
+	pqLogicalTime      = 7
+	pqLogicalTimestamp = 8
+	pqLogicalInteger   = 10
+	pqLogicalUUID      = 14
+)
+
+// Time units, by their field ID in the TimeUnit union.
+const (
+	pqMillis = 1
+	pqMicros = 2
+	pqNanos  = 3
+)
+
+// Parquet compression codecs.
+const (
+	pqUncompressed = 0
+	pqSnappy       = 1
+	pqGzip         = 2
+)
+
+// pqCodecNames names the codecs that are not supported, for errors.
+var pqCodecNames = map[int32]string{3: "LZO", 4: "Brotli", 5: "LZ4", 6: "Zstandard", 7: "LZ4"}
+
+// Parquet page types.
+const (
+	pqDataPage       = 0
+	pqDictionaryPage = 2
+	pqDataPageV2     = 3
+)
+
+// Parquet encodings.
+const (
+	pqPlain                = 0
+	pqPlainDictionary      = 2
+	pqRLE                  = 3
+	pqDeltaBinaryPacked    = 5
+	pqDeltaLengthByteArray = 6
+	pqDeltaByteArray       = 7
+	pqRLEDictionary        = 8
+	pqByteStreamSplit      = 9
+)
+
+// pqSchemaElement is one node of a Parquet schema.
+type pqSchemaElement struct {
+	typ         int32 // Physical type; -1 for groups
+	typeLength  int32
+	repetition  int32
+	name        string
+	numChildren int32
+	converted   int32 // -1 if unset
+	scale       int32
+	logical     pqLogicalType
+}
+
+// pqLogicalType is the subset of a LogicalType annotation that affects
+// how values are written out.
+type pqLogicalType struct {
+	kind     int16 // Field ID of the set union member; 0 if unset
+	unit     int16 // TIME and TIMESTAMP unit
+	scale    int32 // DECIMAL scale
+	unsigned bool  // INTEGER signedness
+}
+
+type pqFileMetaData struct {
+	schema    []pqSchemaElement
+	numRows   int64
+	rowGroups []pqRowGroup
+}
+
+type pqRowGroup struct {
+	columns []pqColumnChunk
+	numRows int64
+}
+
+type pqColumnChunk struct {
+	filePath string // Set if the chunk is in another file
+	meta     pqColumnMetaData
+}
+
+type pqColumnMetaData struct {
+	typ             int32
+	path            []string
+	codec           int32
+	numValues       int64
+	compressedSize  int64
+	dataPageOffset  int64
+	dictPageOffset  int64
+	hasDictPageOffs bool
+}
+
+type pqPageHeader struct {
+	typ              int32
+	uncompressedSize int32
+	compressedSize   int32
+
+	numValues   int32
+	encoding    int32
+	defEncoding int32 // DATA_PAGE only
+
+	// DATA_PAGE_V2 only
+	numNulls     int32
+	defLength    int32
+	repLength    int32
+	isCompressed bool
+}
+
+// Thrift compact protocol types.
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftByte   = 3
+	thriftI16    = 4
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftDouble = 7
+	thriftBinary = 8
+	thriftList   = 9
+	thriftSet    = 10
+	thriftMap    = 11
+	thriftStruct = 12
+)
+
+// thriftReader decodes the Thrift compact protocol from a byte slice.
+type thriftReader struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+var errThriftShort = fmt.Errorf("%w: truncated metadata", ErrInvalidParquet)
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, errThriftShort
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errThriftShort
+	}
+	r.pos += n
+	return v, nil
+}
+
+// varint reads a zigzag-encoded integer.
+func (r *thriftReader) varint() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) i32() (int32, error) {
+	v, err := r.varint()
+	return int32(v), err
+}
+
+func (r *thriftReader) binary() ([]byte, error) {
+	n, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)-r.pos) {
+		return nil, errThriftShort
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *thriftReader) string() (string, error) {
+	b, err := r.binary()
+	return string(b), err
+}
+
+// list reads a list header, returning the size and element type.
+func (r *thriftReader) list() (int, byte, error) {
+	h, err := r.byte()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := int(h >> 4)
+	if size == 15 {
+		n, err := r.uvarint()
+		if err != nil {
+			return 0, 0, err
+		}
+		if n > uint64(len(r.buf)-r.pos) {
+			return 0, 0, errThriftShort // Every element takes at least a byte
+		}
+		size = int(n)
+	}
+	return size, h & 0x0f, nil
+}
+
+// fields calls fn for each field of a struct until its stop byte. fn must
+// read or skip the field's value.
+func (r *thriftReader) fields(fn func(id int16, typ byte) error) error {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > 32 {
+		return fmt.Errorf("%w: metadata nested too deeply", ErrInvalidParquet)
+	}
+
+	var id int16
+	for {
+		h, err := r.byte()
+		if err != nil {
+			return err
+		}
+		typ := h & 0x0f
+		if typ == thriftStop {
+			return nil
+		}
+		if delta := h >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			v, err := r.varint()
+			if err != nil {
+				return err
+			}
+			id = int16(v)
+		}
+		if err := fn(id, typ); err != nil {
+			return err
+		}
+	}
+}
+
+// skip skips a value of type typ.
+func (r *thriftReader) skip(typ byte) error {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		return nil
+	case thriftByte:
+		_, err := r.byte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := r.uvarint()
+		return err
+	case thriftDouble:
+		if len(r.buf)-r.pos < 8 {
+			return errThriftShort
+		}
+		r.pos += 8
+		return nil
+	case thriftBinary:
+		_, err := r.binary()
+		return err
+	case thriftList, thriftSet:
+		n, elem, err := r.list()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := r.skipElem(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftMap:
+		n, err := r.uvarint()
+		if err != nil || n == 0 {
+			return err
+		}
+		kv, err := r.byte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := r.skipElem(kv >> 4); err != nil {
+				return err
+			}
+			if err := r.skipElem(kv & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return r.fields(func(_ int16, typ byte) error { return r.skip(typ) })
+	}
+	return fmt.Errorf("%w: unknown metadata type %d", ErrInvalidParquet, typ)
+}
+
+// skipElem skips a list, set or map element; booleans take a byte there.
+func (r *thriftReader) skipElem(typ byte) error {
+	if typ == thriftTrue || typ == thriftFalse {
+		_, err := r.byte()
+		return err
+	}
+	return r.skip(typ)
+}
+
+// readFileMetaData decodes the FileMetaData footer.
+func readFileMetaData(buf []byte) (*pqFileMetaData, error) {
+	r := &thriftReader{buf: buf}
+	m := &pqFileMetaData{}
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 2 && typ == thriftList:
+			var n int
+			if n, _, err = r.list(); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				el, err := readSchemaElement(r)
+				if err != nil {
+					return err
+				}
+				m.schema = append(m.schema, el)
+			}
+		case id == 3 && typ == thriftI64:
+			m.numRows, err = r.varint()
+		case id == 4 && typ == thriftList:
+			var n int
+			if n, _, err = r.list(); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				rg, err := readRowGroup(r)
+				if err != nil {
+					return err
+				}
+				m.rowGroups = append(m.rowGroups, rg)
+			}
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return m, err
+}
+
+func readSchemaElement(r *thriftReader) (pqSchemaElement, error) {
+	el := pqSchemaElement{typ: -1, converted: -1}
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftI32:
+			el.typ, err = r.i32()
+		case id == 2 && typ == thriftI32:
+			el.typeLength, err = r.i32()
+		case id == 3 && typ == thriftI32:
+			el.repetition, err = r.i32()
+		case id == 4 && typ == thriftBinary:
+			el.name, err = r.string()
+		case id == 5 && typ == thriftI32:
+			el.numChildren, err = r.i32()
+		case id == 6 && typ == thriftI32:
+			el.converted, err = r.i32()
+		case id == 7 && typ == thriftI32:
+			el.scale, err = r.i32()
+		case id == 10 && typ == thriftStruct:
+			el.logical, err = readLogicalType(r)
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return el, err
+}
+
+func readLogicalType(r *thriftReader) (pqLogicalType, error) {
+	var lt pqLogicalType
+	err := r.fields(func(id int16, typ byte) error {
+		if typ != thriftStruct {
+			return r.skip(typ)
+		}
+		lt.kind = id
+		return r.fields(func(fid int16, ftyp byte) error {
+			var err error
+			switch {
+			case id == pqLogicalDecimal && fid == 1 && ftyp == thriftI32:
+				lt.scale, err = r.i32()
+			case (id == pqLogicalTime || id == pqLogicalTimestamp) && fid == 2 && ftyp == thriftStruct:
+				// TimeUnit is a union of empty structs
+				err = r.fields(func(uid int16, utyp byte) error {
+					lt.unit = uid
+					return r.skip(utyp)
+				})
+			case id == pqLogicalInteger && fid == 2:
+				lt.unsigned = ftyp == thriftFalse
+			default:
+				err = r.skip(ftyp)
+			}
+			return err
+		})
+	})
+	return lt, err
+}
+
+func readRowGroup(r *thriftReader) (pqRowGroup, error) {
+	var rg pqRowGroup
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftList:
+			var n int
+			if n, _, err = r.list(); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				cc, err := readColumnChunk(r)
+				if err != nil {
+					return err
+				}
+				rg.columns = append(rg.columns, cc)
+			}
+		case id == 3 && typ == thriftI64:
+			rg.numRows, err = r.varint()
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return rg, err
+}
+
+func readColumnChunk(r *thriftReader) (pqColumnChunk, error) {
+	var cc pqColumnChunk
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftBinary:
+			cc.filePath, err = r.string()
+		case id == 3 && typ == thriftStruct:
+			cc.meta, err = readColumnMetaData(r)
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return cc, err
+}
+
+func readColumnMetaData(r *thriftReader) (pqColumnMetaData, error) {
+	var m pqColumnMetaData
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftI32:
+			m.typ, err = r.i32()
+		case id == 3 && typ == thriftList:
+			var n int
+			if n, _, err = r.list(); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				s, err := r.string()
+				if err != nil {
+					return err
+				}
+				m.path = append(m.path, s)
+			}
+		case id == 4 && typ == thriftI32:
+			m.codec, err = r.i32()
+		case id == 5 && typ == thriftI64:
+			m.numValues, err = r.varint()
+		case id == 7 && typ == thriftI64:
+			m.compressedSize, err = r.varint()
+		case id == 9 && typ == thriftI64:
+			m.dataPageOffset, err = r.varint()
+		case id == 11 && typ == thriftI64:
+			m.dictPageOffset, err = r.varint()
+			m.hasDictPageOffs = true
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	return m, err
+}
+
+// readPageHeader decodes a page header from the start of buf, returning
+// it and its length.
+func readPageHeader(buf []byte) (pqPageHeader, int, error) {
+	r := &thriftReader{buf: buf}
+	h := pqPageHeader{isCompressed: true}
+	err := r.fields(func(id int16, typ byte) error {
+		var err error
+		switch {
+		case id == 1 && typ == thriftI32:
+			h.typ, err = r.i32()
+		case id == 2 && typ == thriftI32:
+			h.uncompressedSize, err = r.i32()
+		case id == 3 && typ == thriftI32:
+			h.compressedSize, err = r.i32()
+		case (id == 5 || id == 7) && typ == thriftStruct:
+			// DataPageHeader and DictionaryPageHeader start alike
+			err = r.fields(func(fid int16, ftyp byte) error {
+				var err error
+				switch {
+				case fid == 1 && ftyp == thriftI32:
+					h.numValues, err = r.i32()
+				case fid == 2 && ftyp == thriftI32:
+					h.encoding, err = r.i32()
+				case fid == 3 && ftyp == thriftI32 && id == 5:
+					h.defEncoding, err = r.i32()
+				default:
+					err = r.skip(ftyp)
+				}
+				return err
+			})
+		case id == 8 && typ == thriftStruct:
+			err = r.fields(func(fid int16, ftyp byte) error {
+				var err error
+				switch {
+				case fid == 1 && ftyp == thriftI32:
+					h.numValues, err = r.i32()
+				case fid == 2 && ftyp == thriftI32:
+					h.numNulls, err = r.i32()
+				case fid == 4 && ftyp == thriftI32:
+					h.encoding, err = r.i32()
+				case fid == 5 && ftyp == thriftI32:
+					h.defLength, err = r.i32()
+				case fid == 6 && ftyp == thriftI32:
+					h.repLength, err = r.i32()
+				case fid == 7 && (ftyp == thriftTrue || ftyp == thriftFalse):
+					h.isCompressed = ftyp == thriftTrue
+				default:
+					err = r.skip(ftyp)
+				}
+				return err
+			})
+		default:
+			err = r.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return h, 0, err
+	}
+	if h.compressedSize < 0 || h.uncompressedSize < 0 || h.numValues < 0 {
+		return h, 0, fmt.Errorf("%w: bad page header", ErrInvalidParquet)
+	}
+	return h, r.pos, nil
+}
+
+// decompressPage decompresses a page body of size bytes once decompressed.
+func decompressPage(codec int32, data []byte, size int) ([]byte, error) {
+	switch codec {
+	case pqUncompressed:
+		return data, nil
+	case pqSnappy:
+		return snappyDecode(data, size)
+	case pqGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: gzip page: %v", ErrInvalidParquet, err)
+		}
+		out := make([]byte, 0, size)
+		buf := bytes.NewBuffer(out)
+		if _, err := io.Copy(buf, io.LimitReader(zr, int64(size)+1)); err != nil {
+			return nil, fmt.Errorf("%w: gzip page: %v", ErrInvalidParquet, err)
+		}
+		if buf.Len() != size {
+			return nil, fmt.Errorf("%w: gzip page has the wrong size", ErrInvalidParquet)
+		}
+		return buf.Bytes(), nil
+	}
+	if name, ok := pqCodecNames[codec]; ok {
+		return nil, fmt.Errorf("%w: %s compression is not supported; write the file with Snappy, gzip or no compression", ErrInvalidParquet, name)
+	}
+	return nil, fmt.Errorf("%w: unknown compression codec %d", ErrInvalidParquet, codec)
+}
+
+// snappyDecode decodes a Snappy block (not the framing format) that
+// should decode to size bytes.
+func snappyDecode(src []byte, size int) ([]byte, error) {
+	corrupt := fmt.Errorf("%w: corrupt snappy page", ErrInvalidParquet)
+
+	n, hdr := binary.Uvarint(src)
+	if hdr <= 0 || n != uint64(size) {
+		return nil, corrupt
+	}
+	src = src[hdr:]
+	dst := make([]byte, 0, size)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case 0: // Literal
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, corrupt
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if length > len(src) || len(dst)+length > size {
+				return nil, corrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1: // Copy with a 1-byte offset
+			if len(src) < 2 {
+				return nil, corrupt
+			}
+			length = 4 + int(tag>>2)&7
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2: // Copy with a 2-byte offset
+			if len(src) < 3 {
+				return nil, corrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3: // Copy with a 4-byte offset
+			if len(src) < 5 {
+				return nil, corrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+length > size {
+			return nil, corrupt
+		}
+		// Copies may overlap their own output, so go byte by byte
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	if len(dst) != size {
+		return nil, corrupt
+	}
+	return dst, nil
+}
+
+// readHybrid decodes n values of the RLE/bit-packed hybrid encoding with
+// the given bit width, as used for definition levels, dictionary indexes
+// and booleans.
+func readHybrid(data []byte, bitWidth, n int) ([]uint32, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return nil, fmt.Errorf("%w: bad bit width %d", ErrInvalidParquet, bitWidth)
+	}
+	out := make([]uint32, 0, n)
+	byteWidth := (bitWidth + 7) / 8
+	for len(out) < n {
+		h, k := binary.Uvarint(data)
+		if k <= 0 {
+			return nil, fmt.Errorf("%w: truncated levels or indexes", ErrInvalidParquet)
+		}
+		data = data[k:]
+		if h&1 == 0 {
+			count := int(h >> 1)
+			if len(data) < byteWidth {
+				return nil, fmt.Errorf("%w: truncated levels or indexes", ErrInvalidParquet)
+			}
+			var v uint32
+			for i := byteWidth - 1; i >= 0; i-- {
+				v = v<<8 | uint32(data[i])
+			}
+			data = data[byteWidth:]
+			for i := 0; i < count && len(out) < n; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+
+		groups := h >> 1
+		size := groups * uint64(bitWidth)
+		if bitWidth > 0 && groups > uint64(len(data)) || size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: truncated levels or indexes", ErrInvalidParquet)
+		}
+		count := n - len(out)
+		if groups < uint64(count+7)/8 {
+			count = int(groups) * 8
+		}
+		for _, v := range unpackBits(data[:size], bitWidth, count) {
+			out = append(out, uint32(v))
+		}
+		data = data[size:]
+	}
+	return out, nil
+}
+
+// unpackBits unpacks n little-endian bit-packed values of the given width
+// (at most 64) from data, which must hold them all.
+func unpackBits(data []byte, bitWidth, n int) []uint64 {
+	out := make([]uint64, n)
+	if bitWidth == 0 {
+		return out
+	}
+	mask := uint64(1)<<bitWidth - 1
+	for i := range out {
+		bit := i * bitWidth
+		idx, shift := bit>>3, uint(bit&7)
+		var acc uint64
+		for k := 0; k < 8 && idx+k < len(data); k++ {
+			acc |= uint64(data[idx+k]) << (8 * k)
+		}
+		v := acc >> shift
+		if shift > 0 && bitWidth+int(shift) > 64 && idx+8 < len(data) {
+			v |= uint64(data[idx+8]) << (64 - shift)
+		}
+		out[i] = v & mask
+	}
+	return out
+}
+
+// readDeltaBinaryPacked decodes up to limit DELTA_BINARY_PACKED integers,
+// returning them and the number of bytes read.
+func readDeltaBinaryPacked(data []byte, limit int) ([]int64, int, error) {
+	bad := fmt.Errorf("%w: bad delta encoding", ErrInvalidParquet)
+	r := &thriftReader{buf: data}
+	blockSize, err := r.uvarint()
+	if err != nil {
+		return nil, 0, bad
+	}
+	miniblocks, err := r.uvarint()
+	if err != nil || miniblocks == 0 || blockSize%miniblocks != 0 {
+		return nil, 0, bad
+	}
+	total, err := r.uvarint()
+	if err != nil || total > uint64(limit) {
+		return nil, 0, bad
+	}
+	first, err := r.varint()
+	if err != nil {
+		return nil, 0, bad
+	}
+	perMini := int(blockSize / miniblocks)
+
+	out := make([]int64, 0, total)
+	if total > 0 {
+		out = append(out, first)
+	}
+	prev := first
+	for uint64(len(out)) < total {
+		minDelta, err := r.varint()
+		if err != nil {
+			return nil, 0, bad
+		}
+		if len(r.buf)-r.pos < int(miniblocks) {
+			return nil, 0, bad
+		}
+		widths := r.buf[r.pos : r.pos+int(miniblocks)]
+		r.pos += int(miniblocks)
+		for _, w := range widths {
+			if uint64(len(out)) >= total {
+				break
+			}
+			if w > 64 {
+				return nil, 0, bad
+			}
+			size := perMini * int(w) / 8
+			if len(r.buf)-r.pos < size {
+				return nil, 0, bad
+			}
+			for _, d := range unpackBits(r.buf[r.pos:r.pos+size], int(w), perMini) {
+				if uint64(len(out)) >= total {
+					break
+				}
+				prev += minDelta + int64(d)
+				out = append(out, prev)
+			}
+			r.pos += size
+		}
+	}
+	return out, r.pos, nil
+}
+
+// readDeltaLengthByteArray decodes up to limit DELTA_LENGTH_BYTE_ARRAY
+// values, returning them and the number of bytes read.
+func readDeltaLengthByteArray(data []byte, limit int) ([][]byte, int, error) {
+	lengths, pos, err := readDeltaBinaryPacked(data, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([][]byte, len(lengths))
+	for i, l := range lengths {
+		if l < 0 || l > int64(len(data)-pos) {
+			return nil, 0, fmt.Errorf("%w: bad delta encoding", ErrInvalidParquet)
+		}
+		out[i] = data[pos : pos+int(l)]
+		pos += int(l)
+	}
+	return out, pos, nil
+}
+
+// readDeltaByteArray decodes up to limit DELTA_BYTE_ARRAY values.
+func readDeltaByteArray(data []byte, limit int) ([][]byte, error) {
+	prefixes, pos, err := readDeltaBinaryPacked(data, limit)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, _, err := readDeltaLengthByteArray(data[pos:], limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(suffixes) != len(prefixes) {
+		return nil, fmt.Errorf("%w: bad delta encoding", ErrInvalidParquet)
+	}
+	out := make([][]byte, len(prefixes))
+	var prev []byte
+	for i, p := range prefixes {
+		if p < 0 || p > int64(len(prev)) {
+			return nil, fmt.Errorf("%w: bad delta encoding", ErrInvalidParquet)
+		}
+		v := make([]byte, 0, int(p)+len(suffixes[i]))
+		v = append(append(v, prev[:p]...), suffixes[i]...)
+		out[i] = v
+		prev = v
+	}
+	return out, nil
+}
+
+// unsplitBytes reverses BYTE_STREAM_SPLIT for n values of width bytes,
+// returning them in PLAIN layout.
+func unsplitBytes(data []byte, width, n int) ([]byte, error) {
+	if len(data) < width*n {
+		return nil, fmt.Errorf("%w: truncated page", ErrInvalidParquet)
+	}
+	out := make([]byte, width*n)
+	for i := 0; i < n; i++ {
+		for b := 0; b < width; b++ {
+			out[i*width+b] = data[b*n+i]
+		}
+	}
+	return out, nil
+}
+
+func float32At(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+func float64At(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
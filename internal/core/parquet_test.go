@@ -0,0 +1,576 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// thriftWriter writes the Thrift compact protocol, for building test files.
+// Field headers always use the long form, so fields may come in any order.
+type thriftWriter struct{ bytes.Buffer }
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	w.WriteByte(typ)
+	w.varint(int64(id))
+}
+
+func (w *thriftWriter) varint(v int64) {
+	w.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+}
+
+func (w *thriftWriter) i32(id int16, v int32) { w.field(id, thriftI32); w.varint(int64(v)) }
+func (w *thriftWriter) i64(id int16, v int64) { w.field(id, thriftI64); w.varint(v) }
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinary)
+	w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+	w.Write(b)
+}
+
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.WriteByte(0xf0 | elem)
+	w.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (w *thriftWriter) stop() { w.WriteByte(thriftStop) }
+
+// pqTestColumn is one column chunk of a test file.
+type pqTestColumn struct {
+	name     string
+	typ      int32
+	optional bool
+	repeated bool
+	schema   func(w *thriftWriter) // Writes extra SchemaElement fields
+	values   [][]byte              // PLAIN-encoded values; nil for null
+	dict     [][]byte              // If set, values are one-byte dictionary indexes
+}
+
+func plainInt32(v int32) []byte { return binary.LittleEndian.AppendUint32(nil, uint32(v)) }
+func plainInt64(v int64) []byte { return binary.LittleEndian.AppendUint64(nil, uint64(v)) }
+func plainDouble(v float64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+}
+func plainString(s string) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+// compressTest compresses a page; Snappy is written as literals only.
+func compressTest(t *testing.T, codec int32, data []byte) []byte {
+	t.Helper()
+	switch codec {
+	case pqSnappy:
+		out := binary.AppendUvarint(nil, uint64(len(data)))
+		for len(data) > 0 {
+			n := min(len(data), 1<<16)
+			out = append(out, 61<<2, byte(n-1), byte((n-1)>>8))
+			out = append(out, data[:n]...)
+			data = data[n:]
+		}
+		return out
+	case pqGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+	return data
+}
+
+// bitPacked encodes values of the given width as one bit-packed run of
+// the RLE/bit-packed hybrid encoding.
+func bitPacked(values []uint32, width int) []byte {
+	groups := (len(values) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups*width)
+	for i, v := range values {
+		for b := 0; b < width; b++ {
+			if v&(1<<b) != 0 {
+				bit := i*width + b
+				packed[bit/8] |= 1 << (bit % 8)
+			}
+		}
+	}
+	return append(out, packed...)
+}
+
+// pageHeader writes a PageHeader with a DataPageHeader or a
+// DictionaryPageHeader.
+func pageHeader(typ int32, plainSize, size, numValues int, encoding int32) []byte {
+	var w thriftWriter
+	w.i32(1, typ)
+	w.i32(2, int32(plainSize))
+	w.i32(3, int32(size))
+	if typ == pqDictionaryPage {
+		w.field(7, thriftStruct)
+		w.i32(1, int32(numValues))
+		w.i32(2, encoding)
+	} else {
+		w.field(5, thriftStruct)
+		w.i32(1, int32(numValues))
+		w.i32(2, encoding)
+		w.i32(3, pqRLE)
+		w.i32(4, pqRLE)
+	}
+	w.stop()
+	w.stop()
+	return w.Bytes()
+}
+
+// buildParquet writes a Parquet file with one row group per element of
+// groups, each holding one data page per column. The schema is taken from
+// the first row group.
+func buildParquet(t *testing.T, codec int32, groups ...[]pqTestColumn) []byte {
+	t.Helper()
+	file := bytes.NewBufferString(parquetMagic)
+
+	type chunk struct {
+		typ                  int32
+		name                 string
+		rows                 int
+		size, data, dictOffs int64
+	}
+	var chunks [][]chunk
+	total := 0
+	for _, cols := range groups {
+		var row []chunk
+		for _, c := range cols {
+			ch := chunk{typ: c.typ, name: c.name, rows: len(c.values), dictOffs: -1}
+			start := int64(file.Len())
+
+			encoding := int32(pqPlain)
+			if c.dict != nil {
+				var plain []byte
+				for _, v := range c.dict {
+					plain = append(plain, v...)
+				}
+				body := compressTest(t, codec, plain)
+				ch.dictOffs = int64(file.Len())
+				file.Write(pageHeader(pqDictionaryPage, len(plain), len(body), len(c.dict), pqPlain))
+				file.Write(body)
+				encoding = pqRLEDictionary
+			}
+
+			var page, values []byte
+			var defs, indexes []uint32
+			for _, v := range c.values {
+				if v == nil {
+					defs = append(defs, 0)
+					continue
+				}
+				defs = append(defs, 1)
+				if c.dict != nil {
+					indexes = append(indexes, uint32(v[0]))
+				} else {
+					values = append(values, v...)
+				}
+			}
+			if c.optional {
+				levels := bitPacked(defs, 1)
+				page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+				page = append(page, levels...)
+			}
+			if c.dict != nil {
+				values = append([]byte{8}, bitPacked(indexes, 8)...)
+			}
+			page = append(page, values...)
+
+			body := compressTest(t, codec, page)
+			ch.data = int64(file.Len())
+			file.Write(pageHeader(pqDataPage, len(page), len(body), len(c.values), encoding))
+			file.Write(body)
+			ch.size = int64(file.Len()) - start
+			row = append(row, ch)
+		}
+		chunks = append(chunks, row)
+		total += row[0].rows
+	}
+
+	var w thriftWriter
+	w.i32(1, 1)
+	w.list(2, thriftStruct, len(groups[0])+1)
+	w.binary(4, []byte("schema"))
+	w.i32(5, int32(len(groups[0])))
+	w.stop()
+	for _, c := range groups[0] {
+		w.i32(1, c.typ)
+		switch {
+		case c.repeated:
+			w.i32(3, pqRepeated)
+		case c.optional:
+			w.i32(3, pqOptional)
+		default:
+			w.i32(3, pqRequired)
+		}
+		w.binary(4, []byte(c.name))
+		if c.schema != nil {
+			c.schema(&w)
+		}
+		w.stop()
+	}
+	w.i64(3, int64(total))
+	w.list(4, thriftStruct, len(chunks))
+	for _, row := range chunks {
+		w.list(1, thriftStruct, len(row))
+		for _, ch := range row {
+			w.i64(2, ch.data)
+			w.field(3, thriftStruct)
+			w.i32(1, ch.typ)
+			w.list(2, thriftI32, 1)
+			w.varint(pqPlain)
+			w.list(3, thriftBinary, 1)
+			w.Write(binary.AppendUvarint(nil, uint64(len(ch.name))))
+			w.WriteString(ch.name)
+			w.i32(4, codec)
+			w.i64(5, int64(ch.rows))
+			w.i64(6, ch.size)
+			w.i64(7, ch.size)
+			w.i64(9, ch.data)
+			if ch.dictOffs >= 0 {
+				w.i64(11, ch.dictOffs)
+			}
+			w.stop()
+			w.stop()
+		}
+		w.i64(2, 0)
+		w.i64(3, int64(row[0].rows))
+		w.stop()
+	}
+	w.stop()
+
+	file.Write(w.Bytes())
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(w.Len())))
+	file.WriteString(parquetMagic)
+	return file.Bytes()
+}
+
+var parquetTestDef = TableDefinition{Info: TableInfo{Key: "pq_orders", Columns: []string{"Order ID", "Amount", "Placed", "Status"}}}
+
+// parquetOrders returns a row group of the orders test file.
+func parquetOrders(ids []string, cents []int64, placed []time.Time) []pqTestColumn {
+	cols := []pqTestColumn{
+		{name: "note", typ: pqDouble},
+		{name: "order id", typ: pqByteArray, schema: func(w *thriftWriter) { w.i32(6, pqConvertedUTF8) }},
+		{name: "AMOUNT", typ: pqInt64, optional: true, schema: func(w *thriftWriter) {
+			w.i32(6, pqConvertedDecimal)
+			w.i32(7, 2)
+			w.i32(8, 18)
+		}},
+		{name: "placed", typ: pqInt64, schema: func(w *thriftWriter) {
+			w.field(10, thriftStruct)
+			w.field(pqLogicalTimestamp, thriftStruct)
+			w.field(1, thriftTrue)
+			w.field(2, thriftStruct)
+			w.field(pqMicros, thriftStruct)
+			w.stop()
+			w.stop()
+			w.stop()
+			w.stop()
+		}},
+	}
+	for i, id := range ids {
+		cols[0].values = append(cols[0].values, plainDouble(float64(i)+0.5))
+		cols[1].values = append(cols[1].values, plainString(id))
+		if cents[i] < 0 {
+			cols[2].values = append(cols[2].values, nil)
+		} else {
+			cols[2].values = append(cols[2].values, plainInt64(cents[i]))
+		}
+		cols[3].values = append(cols[3].values, plainInt64(placed[i].UnixMicro()))
+	}
+	return cols
+}
+
+func readParquetAsCSV(t *testing.T, data []byte, mapped bool) (string, error) {
+	t.Helper()
+	out, err := parquetAsCSV("orders.parquet", data, parquetTestDef, mapped)
+	return string(out), err
+}
+
+func TestParquetRecordReader(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	data := buildParquet(t, pqSnappy,
+		parquetOrders([]string{"A1", "A2"}, []int64{1050, -1}, []time.Time{day, day.Add(90 * time.Minute)}),
+		parquetOrders([]string{"A3"}, []int64{7}, []time.Time{day.Add(1500 * time.Microsecond)}),
+	)
+
+	want := "Order ID,Amount,Placed,Status,note\n" +
+		"A1,10.50,2024-03-01,,0.5\n" +
+		"A2,,2024-03-01 01:30:00,,1.5\n" +
+		"A3,0.07,2024-03-01 00:00:00.0015,,0.5\n"
+	got, err := readParquetAsCSV(t, data, false)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+
+	want = "note,order id,AMOUNT,placed\n" +
+		"0.5,A1,10.50,2024-03-01\n" +
+		"1.5,A2,,2024-03-01 01:30:00\n" +
+		"0.5,A3,0.07,2024-03-01 00:00:00.0015\n"
+	got, err = readParquetAsCSV(t, data, true)
+	if err != nil {
+		t.Fatalf("read mapped: %v", err)
+	}
+	if got != want {
+		t.Errorf("mapped output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParquetRecordReader_DictionaryGzip(t *testing.T) {
+	data := buildParquet(t, pqGzip, []pqTestColumn{
+		{name: "Order ID", typ: pqInt32, values: [][]byte{plainInt32(1), plainInt32(2), plainInt32(3)}},
+		{name: "status", typ: pqByteArray, optional: true,
+			dict:   [][]byte{plainString("open"), plainString("closed")},
+			values: [][]byte{{1}, nil, {0}}},
+		{name: "day", typ: pqInt32, schema: func(w *thriftWriter) { w.i32(6, pqConvertedDate) },
+			values: [][]byte{plainInt32(0), plainInt32(19783), plainInt32(-1)}},
+	})
+
+	want := "Order ID,Amount,Placed,Status,day\n" +
+		"1,,,closed,1970-01-01\n" +
+		"2,,,,2024-03-01\n" +
+		"3,,,open,1969-12-31\n"
+	got, err := readParquetAsCSV(t, data, false)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParquetRecordReader_NoMatchingColumns(t *testing.T) {
+	data := buildParquet(t, pqUncompressed, []pqTestColumn{
+		{name: "ref", typ: pqInt32, values: [][]byte{plainInt32(1)}},
+	})
+	_, err := readParquetAsCSV(t, data, false)
+	if err == nil || !strings.Contains(err.Error(), "header not found") {
+		t.Errorf("error = %v, want header not found", err)
+	}
+}
+
+func TestParquetRecordReader_Progress(t *testing.T) {
+	data := buildParquet(t, pqUncompressed, []pqTestColumn{
+		{name: "Order ID", typ: pqInt64, values: [][]byte{plainInt64(1), plainInt64(2)}},
+	})
+	f, err := openParquet(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	counter := wrapParquetForStreaming(newParquetRecordReader(f, parquetTestDef, false), int64(len(data)))
+	out, err := io.ReadAll(counter)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "Order ID,Amount,Placed,Status\n1,,,\n") {
+		t.Errorf("output = %q", out)
+	}
+	// Everything but the leading magic is counted
+	if want := int64(len(data) - len(parquetMagic)); counter.BytesRead != want {
+		t.Errorf("BytesRead = %d, want %d", counter.BytesRead, want)
+	}
+}
+
+func TestOpenParquet_Rejects(t *testing.T) {
+	valid := func(cols ...pqTestColumn) []byte { return buildParquet(t, pqUncompressed, cols) }
+	one := [][]byte{plainInt32(1)}
+
+	encrypted := valid(pqTestColumn{name: "a", typ: pqInt32, values: one})
+	copy(encrypted[len(encrypted)-4:], "PARE")
+
+	badFooter := valid(pqTestColumn{name: "a", typ: pqInt32, values: one})
+	binary.LittleEndian.PutUint32(badFooter[len(badFooter)-8:], 1<<30)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"csv", []byte("Order ID,Amount\nA1,10.50\n"), "not a Parquet file"},
+		{"too short", []byte("PAR1PAR1"), "file too short"},
+		{"encrypted", encrypted, "encrypted"},
+		{"bad footer", badFooter, "bad footer length"},
+		{"repeated", valid(pqTestColumn{name: "tags", typ: pqInt32, repeated: true, values: one}), `repeated column "tags"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openParquet(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, ErrInvalidParquet) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetAsCSV_UnsupportedCodec(t *testing.T) {
+	// Pages are written as is for codecs the test writer does not know
+	zstd := buildParquet(t, 6, []pqTestColumn{
+		{name: "Order ID", typ: pqInt32, values: [][]byte{plainInt32(1)}},
+	})
+
+	_, err := readParquetAsCSV(t, zstd, false)
+	if !errors.Is(err, ErrInvalidParquet) || !strings.Contains(err.Error(), "Zstandard compression is not supported") {
+		t.Errorf("error = %v, want unsupported Zstandard", err)
+	}
+}
+
+func TestIsParquetUpload(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		want bool
+	}{
+		{"extension", "facts.PARQUET", "", true},
+		{"sniffed", "upload.dat", "PAR1....PAR1", true},
+		{"too short", "", "PAR1PAR1", false},
+		{"csv", "facts.csv", "Order ID,Amount", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isParquetUpload(tt.file, []byte(tt.data)); got != tt.want {
+				t.Errorf("isParquetUpload(%q, %q) = %v, want %v", tt.file, tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetSource(t *testing.T) {
+	data := buildParquet(t, pqUncompressed, []pqTestColumn{
+		{name: "Order ID", typ: pqInt32, values: [][]byte{plainInt32(1)}},
+	})
+
+	// Sniffed from an io.ReaderAt whatever the file is called
+	pq, err := parquetSource(bytes.NewReader(data), "upload.dat", int64(len(data)), parquetTestDef, false)
+	if err != nil || pq == nil {
+		t.Fatalf("parquetSource = %v, %v; want a reader", pq, err)
+	}
+
+	pq, err = parquetSource(strings.NewReader("a,b\n1,2\n"), "upload.csv", 8, parquetTestDef, false)
+	if err != nil || pq != nil {
+		t.Errorf("CSV: parquetSource = %v, %v; want nil, nil", pq, err)
+	}
+
+	_, err = parquetSource(io.MultiReader(bytes.NewReader(data)), "facts.parquet", int64(len(data)), parquetTestDef, false)
+	if !errors.Is(err, ErrInvalidParquet) {
+		t.Errorf("stream: error = %v, want ErrInvalidParquet", err)
+	}
+}
+
+func TestSnappyDecode(t *testing.T) {
+	// "abc" as a literal, then a 6-byte copy at offset 3 that overlaps itself
+	got, err := snappyDecode([]byte{9, 2 << 2, 'a', 'b', 'c', (6-4)<<2 | 1, 3}, 9)
+	if err != nil || string(got) != "abcabcabc" {
+		t.Errorf("snappyDecode = %q, %v; want abcabcabc", got, err)
+	}
+
+	corrupt := [][]byte{
+		{9, 2 << 2, 'a', 'b', 'c'},              // Too short
+		{3, (6-4)<<2 | 1, 3},                    // Copy before any output
+		{3, 10 << 2, 'a', 'b', 'c'},             // Literal past the input
+		{4, 2 << 2, 'a', 'b', 'c', 1<<2 | 1, 9}, // Copy past the start
+	}
+	for _, src := range corrupt {
+		if _, err := snappyDecode(src, int(src[0])); !errors.Is(err, ErrInvalidParquet) {
+			t.Errorf("snappyDecode(%v) error = %v, want ErrInvalidParquet", src, err)
+		}
+	}
+}
+
+func TestReadHybrid(t *testing.T) {
+	// An RLE run of three 1s, then one bit-packed group of 1,0,1,0,...
+	got, err := readHybrid([]byte{3 << 1, 1, 1<<1 | 1, 0b101}, 1, 6)
+	if err != nil {
+		t.Fatalf("readHybrid: %v", err)
+	}
+	want := []uint32{1, 1, 1, 1, 0, 1}
+	if len(got) != len(want) {
+		t.Fatalf("readHybrid = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("readHybrid = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := readHybrid([]byte{4<<1 | 1, 0xff}, 3, 32); !errors.Is(err, ErrInvalidParquet) {
+		t.Errorf("truncated: error = %v, want ErrInvalidParquet", err)
+	}
+}
+
+func TestReadDeltaBinaryPacked(t *testing.T) {
+	// Block of 128 in 4 miniblocks; min delta -2, first miniblock 2 bits wide
+	data := []byte{128, 1, 4, 8, 14, 3, 2, 0, 0, 0, 0xc0, 0x3f, 0, 0, 0, 0, 0, 0}
+	got, _, err := readDeltaBinaryPacked(data, 8)
+	if err != nil {
+		t.Fatalf("readDeltaBinaryPacked: %v", err)
+	}
+	want := []int64{7, 5, 3, 1, 2, 3, 4, 5}
+	if len(got) != len(want) {
+		t.Fatalf("readDeltaBinaryPacked = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("readDeltaBinaryPacked = %v, want %v", got, want)
+		}
+	}
+
+	if _, _, err := readDeltaBinaryPacked(data, 4); !errors.Is(err, ErrInvalidParquet) {
+		t.Errorf("over limit: error = %v, want ErrInvalidParquet", err)
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		unscaled int64
+		scale    int
+		want     string
+	}{
+		{123450, 2, "1234.50"},
+		{-5, 3, "-0.005"},
+		{7, 0, "7"},
+		{0, 2, "0.00"},
+	}
+	for _, tt := range tests {
+		if got := formatDecimal(big.NewInt(tt.unscaled), tt.scale); got != tt.want {
+			t.Errorf("formatDecimal(%d, %d) = %q, want %q", tt.unscaled, tt.scale, got, tt.want)
+		}
+	}
+
+	c := parquetColumn{typ: pqFixedLenByteArray, typeLength: 2, kind: pqValueDecimal, scale: 1}
+	if got := c.bytesCell([]byte{0xff, 0x85}); got != "-12.3" {
+		t.Errorf("bytesCell = %q, want -12.3", got)
+	}
+}
+
+func TestParquetTimeCells(t *testing.T) {
+	c := parquetColumn{typ: pqInt32, kind: pqValueTime, unit: pqMillis}
+	if got := c.int32Cell(45296789); got != "12:34:56.789" {
+		t.Errorf("TIME millis = %q, want 12:34:56.789", got)
+	}
+
+	// INT96: nanoseconds into the day, then the Julian day
+	int96 := binary.LittleEndian.AppendUint64(nil, uint64(3*time.Hour))
+	int96 = binary.LittleEndian.AppendUint32(int96, julianUnixEpoch+1)
+	c = parquetColumn{typ: pqInt96}
+	got, err := c.plainValues(int96, 1)
+	if err != nil || got[0] != "1970-01-02 03:00:00" {
+		t.Errorf("INT96 = %v, %v; want 1970-01-02 03:00:00", got, err)
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+// paste.go accepts rows pasted from the clipboard, so a handful of rows
+// copied from a spreadsheet can be added without saving a file first.
+//
+// Spreadsheets copy cells as tab-delimited text; comma-delimited text works
+// too, as the delimiter is sniffed as for any upload. The text is treated
+// as a small in-memory file and goes through the usual preview, validation
+// and upload paths. Pastes are capped at UPLOAD_MAX_PASTE_SIZE.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPaste is returned for pasted text that cannot be uploaded.
+var ErrInvalidPaste = errors.New("invalid paste")
+
+// ErrPasteTooLarge is returned for pasted text over UPLOAD_MAX_PASTE_SIZE.
+var ErrPasteTooLarge = errors.New("paste too large")
+
+// DefaultMaxPasteSize is the paste limit if UPLOAD_MAX_PASTE_SIZE is unset.
+const DefaultMaxPasteSize = 256 << 10
+
+// PasteStep is what an upload from pasted text does.
+type PasteStep string
+
+const (
+	PasteUpload   PasteStep = "upload"   // Insert the rows
+	PastePreview  PasteStep = "preview"  // Analyze what an upload would do
+	PasteValidate PasteStep = "validate" // Validate every row without inserting
+)
+
+// ParsePasteStep parses a paste step; empty means PasteUpload.
+func ParsePasteStep(s string) (PasteStep, error) {
+	switch step := PasteStep(strings.ToLower(strings.TrimSpace(s))); step {
+	case "":
+		return PasteUpload, nil
+	case PasteUpload, PastePreview, PasteValidate:
+		return step, nil
+	}
+	return "", fmt.Errorf("%w: unknown step %q (want upload, preview or validate)", ErrInvalidPaste, s)
+}
+
+// MaxPasteSize returns the largest paste accepted, in bytes.
+func (s *Service) MaxPasteSize() int64 {
+	if s.cfg.Upload.MaxPasteSize > 0 {
+		return s.cfg.Upload.MaxPasteSize
+	}
+	return DefaultMaxPasteSize
+}
+
+// PasteFile returns pasted text as the contents and name of an upload.
+// The name, "clipboard.tsv" or "clipboard.csv", is what upload history
+// shows. The text needs a header row and at least one row of data.
+func (s *Service) PasteFile(text string) ([]byte, string, error) {
+	if limit := s.MaxPasteSize(); int64(len(text)) > limit {
+		return nil, "", fmt.Errorf("%w: %d bytes (max %d); upload a file instead", ErrPasteTooLarge, len(text), limit)
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return nil, "", fmt.Errorf("%w: nothing was pasted", ErrInvalidPaste)
+	}
+	text = strings.TrimLeft(text, "\r\n")
+	header, rest, _ := strings.Cut(text, "\n")
+	if strings.TrimSpace(header) == "" {
+		return nil, "", fmt.Errorf("%w: the header row is empty", ErrInvalidPaste)
+	}
+	if strings.TrimSpace(rest) == "" {
+		return nil, "", fmt.Errorf("%w: paste a header row and at least one row of data", ErrInvalidPaste)
+	}
+
+	name := "clipboard.csv"
+	if strings.Contains(header, "\t") {
+		name = "clipboard.tsv"
+	}
+	return []byte(text), name, nil
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestPasteFile(t *testing.T) {
+	s := &Service{cfg: &config.Config{Upload: config.UploadConfig{MaxPasteSize: 64}}}
+
+	data, name, err := s.PasteFile("\r\nName\tAmount\r\nAcme\t10.50\r\n")
+	if err != nil {
+		t.Fatalf("PasteFile: %v", err)
+	}
+	if name != "clipboard.tsv" || string(data) != "Name\tAmount\r\nAcme\t10.50\r\n" {
+		t.Errorf("PasteFile = %q, %q", data, name)
+	}
+	if _, name, _ := s.PasteFile("Name,Amount\nAcme,1\n"); name != "clipboard.csv" {
+		t.Errorf("comma-delimited name = %q, want clipboard.csv", name)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want error
+	}{
+		{"empty", " \n\n", ErrInvalidPaste},
+		{"header only", "Name\tAmount\n", ErrInvalidPaste},
+		{"too large", "Name\n" + strings.Repeat("x", 64), ErrPasteTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.PasteFile(tt.text); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePasteStep(t *testing.T) {
+	for in, want := range map[string]PasteStep{"": PasteUpload, " Preview ": PastePreview, "validate": PasteValidate} {
+		if got, err := ParsePasteStep(in); err != nil || got != want {
+			t.Errorf("ParsePasteStep(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePasteStep("insert"); !errors.Is(err, ErrInvalidPaste) {
+		t.Errorf("unknown step error = %v, want ErrInvalidPaste", err)
+	}
+}
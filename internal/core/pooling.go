@@ -0,0 +1,46 @@
+package core
+
+// pooling.go lets the server run behind a connection pooler in transaction
+// mode, such as pgbouncer with pool_mode = transaction. There each
+// transaction, and each statement outside one, may run on a different
+// server connection, so nothing may rely on state a connection keeps
+// between them:
+//
+//   - prepared statements: pgx prepares and caches every query on its
+//     connection by default. With DB_TRANSACTION_POOLING set,
+//     ConfigurePooling has queries sent with the unnamed statement in a
+//     single round trip instead, and nothing is cached.
+//   - advisory locks: only transaction-level locks (pg_advisory_xact_lock)
+//     are taken, released at commit or rollback on the connection that
+//     took them.
+//   - settings: only SET LOCAL, which ends with the transaction.
+//   - temporary tables, LISTEN and WITH HOLD cursors are not used.
+//   - backend PIDs: the PID a client sees belongs to the pooler, so a
+//     backend left running by a dropped connection can't be cancelled with
+//     pg_cancel_backend (see rollbackTx). Cancel requests still work; the
+//     pooler forwards them.
+//
+// Only prepared statements and PIDs depend on the setting; the rest holds
+// in every mode, and TestSessionStateFree keeps it that way.
+
+import (
+	"github.com/jackc/pgx/v5"
+)
+
+// ConfigurePooling prepares a connection config for a pooler in
+// transaction mode, if transactionPooling is set. Apply it to the pool's
+// connection config before connecting.
+func ConfigurePooling(cfg *pgx.ConnConfig, transactionPooling bool) {
+	if !transactionPooling {
+		return
+	}
+	cfg.DefaultQueryExecMode = pgx.QueryExecModeExec
+	cfg.StatementCacheCapacity = 0
+	cfg.DescriptionCacheCapacity = 0
+}
+
+// transactionPooling reports whether the server runs behind a pooler in
+// transaction mode.
+func (s *Service) transactionPooling() bool {
+	return s.cfg != nil && s.cfg.Database.TransactionPooling
+}
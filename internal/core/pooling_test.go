@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestConfigurePooling(t *testing.T) {
+	cfg, err := pgx.ParseConfig("postgres://localhost/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ConfigurePooling(cfg, false)
+	if cfg.DefaultQueryExecMode != pgx.QueryExecModeCacheStatement || cfg.StatementCacheCapacity == 0 {
+		t.Errorf("without pooling: mode %v, cache %d; want pgx defaults", cfg.DefaultQueryExecMode, cfg.StatementCacheCapacity)
+	}
+
+	ConfigurePooling(cfg, true)
+	if cfg.DefaultQueryExecMode != pgx.QueryExecModeExec {
+		t.Errorf("mode = %v, want exec", cfg.DefaultQueryExecMode)
+	}
+	if cfg.StatementCacheCapacity != 0 || cfg.DescriptionCacheCapacity != 0 {
+		t.Errorf("caches = %d, %d; want 0", cfg.StatementCacheCapacity, cfg.DescriptionCacheCapacity)
+	}
+}
+
+// sessionStatePatterns match SQL that keeps state on a connection past the
+// end of its transaction, which a transaction pooler would leak to other
+// clients or lose.
+var sessionStatePatterns = map[string]*regexp.Regexp{
+	"session advisory lock":      regexp.MustCompile(`(?i)pg_(try_)?advisory_lock(_shared)?\s*\(`),
+	"SET without LOCAL":          regexp.MustCompile(`(?i)^\s*SET\s+(SESSION\s+)?[a-z_.]+\s*(=|TO\b)`),
+	"set_config for the session": regexp.MustCompile(`(?i)set_config\s*\([^)]*,\s*false\s*\)`),
+	"temporary table":            regexp.MustCompile(`(?i)CREATE\s+(GLOBAL\s+|LOCAL\s+)?TEMP(ORARY)?\s+TABLE`),
+	"LISTEN":                     regexp.MustCompile(`(?i)^\s*(UN)?LISTEN\s`),
+	"held cursor":                regexp.MustCompile(`(?i)\bWITH\s+HOLD\b`),
+	"prepared statement":         regexp.MustCompile(`(?i)^\s*PREPARE\s+\w+`),
+}
+
+// TestSessionStateFree checks that no SQL in the package keeps state on a
+// connection between transactions (see pooling.go).
+func TestSessionStateFree(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			sql, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			for what, re := range sessionStatePatterns {
+				if re.MatchString(sql) {
+					t.Errorf("%s: %s in %q", fset.Position(lit.Pos()), what, sql)
+				}
+			}
+			return true
+		})
+	}
+}
+
+func TestSessionStatePatterns(t *testing.T) {
+	bad := []string{
+		"SELECT pg_advisory_lock(hashtext($1))",
+		"SELECT pg_try_advisory_lock(1)",
+		"SET statement_timeout = '5s'",
+		"SET SESSION search_path TO app",
+		"SELECT set_config('app.user', $1, false)",
+		"CREATE TEMP TABLE t (id int)",
+		"LISTEN activity",
+		"DECLARE c CURSOR WITH HOLD FOR SELECT 1",
+		"PREPARE q AS SELECT 1",
+	}
+	good := []string{
+		"SELECT pg_advisory_xact_lock(hashtext($1))",
+		"SET LOCAL statement_timeout = '5s'",
+		"SELECT set_config('app.user', $1, true)",
+		"UPDATE t SET a = $1 WHERE id = $2",
+		"SELECT 1",
+	}
+	matches := func(sql string) bool {
+		for _, re := range sessionStatePatterns {
+			if re.MatchString(sql) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sql := range bad {
+		if !matches(sql) {
+			t.Errorf("%q not caught", sql)
+		}
+	}
+	for _, sql := range good {
+		if matches(sql) {
+			t.Errorf("%q caught", sql)
+		}
+	}
+}
+
+// TestTransactionPoolingMatrix runs the kinds of statement the service
+// relies on against each database in TEST_DATABASE_URL (PostgreSQL
+// directly) and TEST_PGBOUNCER_URL (pgbouncer with pool_mode =
+// transaction), with and without ConfigurePooling. pgbouncer is only
+// tried with it, since prepared statements fail there without it.
+func TestTransactionPoolingMatrix(t *testing.T) {
+	matrix := []struct {
+		name, env string
+		pooling   bool
+	}{
+		{"direct", "TEST_DATABASE_URL", false},
+		{"direct pooling mode", "TEST_DATABASE_URL", true},
+		{"pgbouncer", "TEST_PGBOUNCER_URL", true},
+	}
+	ran := false
+	for _, m := range matrix {
+		url := os.Getenv(m.env)
+		if url == "" {
+			continue
+		}
+		ran = true
+		t.Run(m.name, func(t *testing.T) {
+			testPoolingCompatibility(t, url, m.pooling)
+		})
+	}
+	if !ran {
+		t.Skip("set TEST_DATABASE_URL and TEST_PGBOUNCER_URL to run")
+	}
+}
+
+func testPoolingCompatibility(t *testing.T, url string, pooling bool) {
+	ctx := context.Background()
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.MaxConns = 4
+	ConfigureCancellation(cfg.ConnConfig, 0)
+	ConfigurePooling(cfg.ConnConfig, pooling)
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	table := "pooling_test_" + strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+	if _, err := pool.Exec(ctx, `CREATE TABLE `+table+` (id UUID PRIMARY KEY, name TEXT, amount NUMERIC)`); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Exec(ctx, `DROP TABLE `+table)
+
+	// Enough concurrent work that statements land on different server
+	// connections
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := poolingWorkload(ctx, pool, table, i); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var n int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 16*3 {
+		t.Errorf("rows = %d, want %d", n, 16*3)
+	}
+}
+
+// poolingWorkload runs parameterized queries, a transaction holding an
+// advisory lock, and a COPY, as the service does.
+func poolingWorkload(ctx context.Context, pool *pgxpool.Pool, table string, i int) error {
+	id := uuid.New()
+	name := fmt.Sprintf("row %d", i)
+	if _, err := pool.Exec(ctx, `INSERT INTO `+table+` (id, name, amount) VALUES ($1, $2, $3)`,
+		ToPgUUID(id.String()), name, ToPgNumeric("10.50")); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	var got string
+	if err := pool.QueryRow(ctx, `SELECT name FROM `+table+` WHERE name = ANY($1) AND id = $2`,
+		[]string{name, "other"}, ToPgUUID(id.String())).Scan(&got); err != nil {
+		return fmt.Errorf("select: %w", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "pooling_test:"+table); err != nil {
+		return fmt.Errorf("advisory lock: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = '5s'"); err != nil {
+		return fmt.Errorf("SET LOCAL: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE `+table+` SET amount = amount * 2 WHERE id = $1`, ToPgUUID(id.String())); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO `+table+` (id, name) VALUES ($1, $2)`, ToPgUUID(uuid.NewString()), name); err != nil {
+		return fmt.Errorf("insert in transaction: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if _, err := pool.CopyFrom(ctx, pgx.Identifier{table}, []string{"id", "name"},
+		pgx.CopyFromRows([][]any{{ToPgUUID(uuid.NewString()), name}})); err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ type PreviewSummary struct {
 	UpdateRows      int `json:"updateRows"`
 	ErrorRows       int `json:"errorRows"`
 	DuplicateInFile int `json:"duplicateInFile"`
+	UnchangedRows   int `json:"unchangedRows,omitempty"` // Upsert only: updates that change no value
 }
 
 // RowPreview represents a single row for preview display.
@@ -50,11 +51,15 @@ type DuplicatePreview struct {
 
 // PreviewResponse is the complete response from upload preview analysis.
 type PreviewResponse struct {
+	Mode             UploadMode         `json:"mode"`
 	Summary          PreviewSummary     `json:"summary"`
 	NewRowSamples    []RowPreview       `json:"newRowSamples"`
 	UpdateDiffs      []UpdateDiff       `json:"updateDiffs"`
 	ErrorSamples     []ErrorPreview     `json:"errorSamples"`
 	DuplicateSamples []DuplicatePreview `json:"duplicateSamples"`
+	ColumnChanges    []ColumnChange     `json:"columnChanges,omitempty"` // Upsert only
+	DateWarnings     []DateWarning      `json:"dateWarnings,omitempty"`
+	TemplateDrift    *TemplateDrift     `json:"templateDrift,omitempty"`
 	ProcessingTimeMs int64              `json:"processingTimeMs"`
 }
 
@@ -68,16 +73,44 @@ const (
 
 // AnalyzeUpload performs read-only analysis of a CSV upload.
 // It validates all rows, checks for duplicates, and returns a preview of what will happen.
-func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData []byte, mapping map[string]int) (*PreviewResponse, error) {
+// mode, dateOpts and ContextWithMappingConstants are as for StartUpload.
+// For an upsert, every update is compared with the row it would replace
+// (see preview_upsert.go).
+func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData []byte, mapping map[string]int, mode UploadMode, dateOpts DateOptions) (*PreviewResponse, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	startTime := time.Now()
 
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	mode, err := resolveUploadMode(def, mode)
+	if err != nil {
+		return nil, err
+	}
+	dateOpts, err = dateOpts.validated()
+	if err != nil {
+		return nil, err
+	}
+	constants, err := uploadConstants(ctx, def, mapping, dateOpts)
+	if err != nil {
+		return nil, err
+	}
 
-	// Sanitize and parse CSV
-	fileData = sanitizeUTF8(fileData)
+	// Transcode to UTF-8 and parse CSV, converting Parquet and JSON first
+	fileData, err = parquetAsCSV("", fileData, def, len(mapping) > 0)
+	if err != nil {
+		return nil, err
+	}
+	fileData, err = jsonAsCSV("", stripBOM(toUTF8(fileData)), def, len(mapping) > 0)
+	if err != nil {
+		return nil, err
+	}
 	records, err := parseCSV(fileData)
 	if err != nil {
 		return nil, fmt.Errorf("parse CSV: %w", err)
@@ -91,12 +124,16 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 	var csvHeaderIdx HeaderIndex
 	var dataRows [][]string
 	var headerRowIndex int
+	var drift *TemplateDrift
 
 	if len(mapping) > 0 {
 		headerRow := records[0]
 		dataRows = records[1:]
 		headerRowIndex = 0
-		csvHeaderIdx = buildMappedHeaderIndex(mapping, headerRow)
+		csvHeaderIdx = buildMappedHeaderIndex(resolveMappingColumns(def, mapping, "preview mapping"), headerRow)
+		// Reported rather than failed, even when UPLOAD_TEMPLATE_DRIFT
+		// blocks uploads, so the suggested mapping can be reviewed
+		drift, _ = s.checkTemplateDrift(templateFromContext(ctx), tableKey, headerRow)
 	} else {
 		headerIdx := findHeaderInRecords(records, def.Info.Columns)
 		if headerIdx < 0 {
@@ -114,9 +151,11 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 
 	// Initialize response
 	resp := &PreviewResponse{
+		Mode: mode,
 		Summary: PreviewSummary{
 			TotalRows: len(dataRows),
 		},
+		TemplateDrift: drift,
 	}
 
 	// Track duplicates within file
@@ -128,11 +167,14 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 		lineNumber int
 		rowKey     string
 		values     map[string]string
+		normalized map[string]string // values after date normalization, for upsert diffs
 		errors     []string
 		isEmpty    bool
 	}
 
 	analyzedRows := make([]analyzedRow, 0, len(dataRows))
+	transformer := newRowTransformer(templateTransforms(ctx), constants, csvHeaderIdx, len(records[headerRowIndex]))
+	dates := newDateStats(def, csvHeaderIdx, dateOpts)
 
 	for i, row := range dataRows {
 		lineNum := headerRowIndex + i + 2 // 1-indexed, after header
@@ -143,9 +185,19 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 			continue
 		}
 
-		// Extract values and validate
+		// Extract values and validate; values keep the file's dates
+		row = transformer.apply(row)
 		values := extractRowValues(row, csvHeaderIdx, def)
-		errors := validateRowComplete(row, csvHeaderIdx, def)
+		dates.observe(row)
+		var errors []string
+		normalizedValues := values
+		if normalized, err := normalizeDates(row, csvHeaderIdx, def, dateOpts); err != nil {
+			errors = []string{err.Error()}
+		} else {
+			row = normalized
+			normalizedValues = extractRowValues(row, csvHeaderIdx, def)
+			errors = validateRowComplete(row, csvHeaderIdx, def)
+		}
 
 		// Extract unique key
 		rowKey := ""
@@ -160,6 +212,7 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 			lineNumber: lineNum,
 			rowKey:     rowKey,
 			values:     values,
+			normalized: normalizedValues,
 			errors:     errors,
 		})
 
@@ -238,9 +291,21 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 		})
 	}
 
-	// Fetch current values for update diffs
-	if len(updateRows) > 0 {
-		// Limit to first N for diff display
+	// An upsert replaces each matched row, so compare all of them
+	if mode == UploadModeUpsert && len(updateRows) > 0 {
+		rows := make([]upsertRow, len(updateRows))
+		for i, ar := range updateRows {
+			rows[i] = upsertRow{lineNumber: ar.lineNumber, rowKey: ar.rowKey, values: ar.normalized}
+		}
+		changes, diffs, unchanged, err := s.upsertChanges(ctx, def, rows)
+		if err != nil {
+			return nil, err
+		}
+		resp.ColumnChanges = changes
+		resp.UpdateDiffs = diffs
+		resp.Summary.UnchangedRows = unchanged
+	} else if len(updateRows) > 0 {
+		// Fetch current values for update diffs, limited to the first N
 		diffCount := len(updateRows)
 		if diffCount > maxUpdateDiffs {
 			diffCount = maxUpdateDiffs
@@ -279,6 +344,7 @@ func (s *Service) AnalyzeUpload(ctx context.Context, tableKey string, fileData [
 		}
 	}
 
+	resp.DateWarnings = dates.warnings()
 	resp.ProcessingTimeMs = time.Since(startTime).Milliseconds()
 	return resp, nil
 }
@@ -346,6 +412,12 @@ func validateRowComplete(row []string, headerIdx HeaderIndex, def TableDefinitio
 		}
 	}
 
+	if len(errors) == 0 {
+		if err := checkRowRule(row, headerIdx, def); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
 	return errors
 }
 
@@ -0,0 +1,252 @@
+package core
+
+// preview_upsert.go extends AnalyzeUpload for upsert uploads. An upsert
+// replaces each matched row with the file's row, so the preview fetches the
+// current values of every matched key and counts, per column, how many rows
+// would change and how many would lose a value - which catches a file that
+// is missing a column, or has it empty, before it overwrites good data.
+//
+// Values are compared by type: "1,200.50" matches a stored 1200.5, dates
+// compare as dates, and enums ignore case. Columns the file does not carry
+// count as empty, since the upsert writes them as empty.
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Upsert preview limits
+const (
+	maxChangeSamples   = 3    // Samples per column
+	upsertDiffKeyChunk = 1000 // Keys per query for current values
+)
+
+// ColumnChange counts the matched rows whose value for Column an upsert
+// would change.
+type ColumnChange struct {
+	Column  string        `json:"column"`
+	Changed int           `json:"changed"`
+	Cleared int           `json:"cleared"` // Of those, rows whose current value would be emptied
+	Samples []ValueChange `json:"samples"`
+}
+
+// ValueChange is one changed value in a ColumnChange.
+type ValueChange struct {
+	LineNumber int    `json:"lineNumber"`
+	RowKey     string `json:"rowKey"`
+	Current    string `json:"current"`
+	Incoming   string `json:"incoming"`
+}
+
+// upsertRow is a valid file row whose key is already in the table.
+type upsertRow struct {
+	lineNumber int
+	rowKey     string
+	values     map[string]string // By column, after transforms and date normalization
+}
+
+// upsertChanges compares rows with the table's current values; see
+// diffUpsertRows.
+func (s *Service) upsertChanges(ctx context.Context, def TableDefinition, rows []upsertRow) ([]ColumnChange, []UpdateDiff, int, error) {
+	keys := make([]string, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		if !seen[r.rowKey] {
+			seen[r.rowKey] = true
+			keys = append(keys, r.rowKey)
+		}
+	}
+	current := make(map[string][]string, len(keys))
+	for start := 0; start < len(keys); start += upsertDiffKeyChunk {
+		end := min(start+upsertDiffKeyChunk, len(keys))
+		if err := s.currentRowText(ctx, def, keys[start:end], current); err != nil {
+			return nil, nil, 0, fmt.Errorf("fetch current rows: %w", err)
+		}
+	}
+	changes, diffs, unchanged := diffUpsertRows(def, rows, current)
+	return changes, diffs, unchanged, nil
+}
+
+// diffUpsertRows compares rows with current, the rows they would replace
+// by row key. It returns the per-column changes, most changed first;
+// sample diffs of up to maxUpdateDiffs rows that change; and how many rows
+// would change nothing.
+func diffUpsertRows(def TableDefinition, rows []upsertRow, current map[string][]string) ([]ColumnChange, []UpdateDiff, int) {
+	cols := def.Info.Columns
+	specs := make(map[string]FieldSpec, len(def.FieldSpecs))
+	for _, spec := range def.FieldSpecs {
+		specs[spec.Name] = spec
+	}
+	changes := make([]ColumnChange, len(cols))
+	for i, col := range cols {
+		changes[i] = ColumnChange{Column: col, Samples: []ValueChange{}}
+	}
+
+	var diffs []UpdateDiff
+	unchanged := 0
+	for _, r := range rows {
+		cur, ok := current[r.rowKey]
+		if !ok {
+			continue // Deleted since CheckDuplicates
+		}
+		var changed []string
+		for i, col := range cols {
+			incoming := r.values[col]
+			if comparableValue(specs[col], incoming, true) == comparableValue(specs[col], cur[i], false) {
+				continue
+			}
+			changed = append(changed, col)
+			c := &changes[i]
+			c.Changed++
+			if incoming == "" {
+				c.Cleared++
+			}
+			if len(c.Samples) < maxChangeSamples {
+				c.Samples = append(c.Samples, ValueChange{
+					LineNumber: r.lineNumber,
+					RowKey:     r.rowKey,
+					Current:    cur[i],
+					Incoming:   incoming,
+				})
+			}
+		}
+		if len(changed) == 0 {
+			unchanged++
+			continue
+		}
+		if len(diffs) < maxUpdateDiffs {
+			currentMap := make(map[string]string, len(cols))
+			incomingMap := make(map[string]string, len(cols))
+			for i, col := range cols {
+				currentMap[col] = cur[i]
+				incomingMap[col] = r.values[col]
+			}
+			diffs = append(diffs, UpdateDiff{
+				LineNumber: r.lineNumber,
+				RowKey:     r.rowKey,
+				Current:    currentMap,
+				Incoming:   incomingMap,
+				Changed:    changed,
+			})
+		}
+	}
+
+	kept := changes[:0]
+	for _, c := range changes {
+		if c.Changed > 0 {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Changed > kept[j].Changed })
+	return kept, diffs, unchanged
+}
+
+// currentRowText adds the live rows of def matching keys to rows, by row
+// key, with each column's value as text in def.Info.Columns order.
+func (s *Service) currentRowText(ctx context.Context, def TableDefinition, keys []string, rows map[string][]string) error {
+	dbKeyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	var where string
+	var args []any
+	if len(dbKeyCols) == 1 {
+		where = fmt.Sprintf("%s = ANY($1)", quoteIdentifier(dbKeyCols[0]))
+		args = []any{keys}
+	} else {
+		tuples := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts := strings.Split(key, "|")
+			if len(parts) != len(dbKeyCols) {
+				continue
+			}
+			placeholders := make([]string, len(parts))
+			for i, part := range parts {
+				args = append(args, part)
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+			tuples = append(tuples, "("+strings.Join(placeholders, ", ")+")")
+		}
+		if len(tuples) == 0 {
+			return nil
+		}
+		where = fmt.Sprintf("(%s) IN (%s)", strings.Join(quoteColumns(dbKeyCols), ", "), strings.Join(tuples, ", "))
+	}
+
+	dbCols := resolveDBColumns(def.Info.Columns, def.FieldSpecs)
+	selects := make([]string, len(dbCols))
+	for i, col := range dbCols {
+		selects[i] = fmt.Sprintf("COALESCE(%s::text, '')", quoteIdentifier(col))
+	}
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s%s",
+		rowKeyExpr(def),
+		strings.Join(selects, ", "),
+		quoteIdentifier(def.Info.Key),
+		where,
+		andLiveRows(def, ""),
+	)
+
+	result, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	for result.Next() {
+		var key string
+		values := make([]string, len(dbCols))
+		dest := make([]any, 0, len(dbCols)+1)
+		dest = append(dest, &key)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := result.Scan(dest...); err != nil {
+			return err
+		}
+		rows[key] = values
+	}
+	return result.Err()
+}
+
+// comparableValue returns v in a form that is equal for values an upload
+// would store identically. incoming values get spec's normalizer first,
+// as an upload applies it; stored values already had it applied.
+func comparableValue(spec FieldSpec, v string, incoming bool) string {
+	v = CleanCell(v)
+	if incoming && v != "" && spec.Normalizer != nil {
+		v = spec.Normalizer(v)
+	}
+	if v == "" {
+		return ""
+	}
+	switch spec.Type {
+	case FieldNumeric:
+		if n := ToPgNumeric(v); n.Valid && n.Int != nil {
+			// Drop trailing zeros so 1200.50 and 1200.5 match
+			i, exp := new(big.Int).Set(n.Int), n.Exp
+			ten, rem := big.NewInt(10), new(big.Int)
+			for i.Sign() != 0 {
+				q, r := new(big.Int).QuoRem(i, ten, rem)
+				if r.Sign() != 0 {
+					break
+				}
+				i, exp = q, exp+1
+			}
+			if i.Sign() == 0 {
+				return "0"
+			}
+			return i.String() + "e" + strconv.Itoa(int(exp))
+		}
+	case FieldDate:
+		if d := ToPgDate(v); d.Valid {
+			return d.Time.Format("2006-01-02")
+		}
+	case FieldBool:
+		if b := ToPgBool(v); b.Valid {
+			return strconv.FormatBool(b.Bool)
+		}
+	case FieldEnum:
+		return strings.ToLower(v)
+	}
+	return v
+}
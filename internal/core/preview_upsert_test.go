@@ -0,0 +1,81 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComparableValue(t *testing.T) {
+	same := []struct {
+		spec            FieldSpec
+		incoming, saved string
+	}{
+		{FieldSpec{Type: FieldNumeric}, "$1,200.50", "1200.5"},
+		{FieldSpec{Type: FieldNumeric}, "(3.00)", "-3"},
+		{FieldSpec{Type: FieldNumeric}, "0.00", "0"},
+		{FieldSpec{Type: FieldDate}, "2025-03-04", "2025-03-04"},
+		{FieldSpec{Type: FieldBool}, "yes", "true"},
+		{FieldSpec{Type: FieldEnum}, "won", "Won"},
+		{FieldSpec{Type: FieldText, Normalizer: strings.ToUpper}, "acme", "ACME"},
+	}
+	for _, c := range same {
+		if a, b := comparableValue(c.spec, c.incoming, true), comparableValue(c.spec, c.saved, false); a != b {
+			t.Errorf("%v: %q -> %q, %q -> %q; want equal", c.spec.Type, c.incoming, a, c.saved, b)
+		}
+	}
+	if comparableValue(FieldSpec{Type: FieldNumeric}, "120", true) == comparableValue(FieldSpec{Type: FieldNumeric}, "12", false) {
+		t.Error("120 and 12 compare equal")
+	}
+}
+
+func TestDiffUpsertRows(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "deals", Columns: []string{"ID", "Stage", "Amount", "Owner"}, UniqueKey: []string{"ID"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "ID", Type: FieldText, Required: true},
+			{Name: "Stage", Type: FieldEnum, EnumValues: []string{"Open", "Won"}},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Owner", Type: FieldText},
+		},
+	}
+	current := map[string][]string{
+		"1": {"1", "Open", "100.00", "ada"},
+		"2": {"2", "Won", "250", "bob"},
+		"3": {"3", "Open", "75", "cy"},
+	}
+	// The file has no Owner column, so every owner would be cleared
+	rows := []upsertRow{
+		{lineNumber: 2, rowKey: "1", values: map[string]string{"ID": "1", "Stage": "won", "Amount": "100"}},
+		{lineNumber: 3, rowKey: "2", values: map[string]string{"ID": "2", "Stage": "Won", "Amount": "300"}},
+		{lineNumber: 4, rowKey: "3", values: map[string]string{"ID": "3", "Stage": "open", "Amount": "75"}},
+		{lineNumber: 5, rowKey: "4", values: map[string]string{"ID": "4"}}, // Deleted meanwhile
+	}
+
+	changes, diffs, unchanged := diffUpsertRows(def, rows, current)
+	if unchanged != 0 {
+		t.Errorf("unchanged = %d, want 0", unchanged)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Column)
+	}
+	if strings.Join(got, ",") != "Owner,Stage,Amount" {
+		t.Fatalf("columns = %v, want Owner,Stage,Amount", got)
+	}
+	if owner := changes[0]; owner.Changed != 3 || owner.Cleared != 3 || len(owner.Samples) != maxChangeSamples {
+		t.Errorf("owner = %+v", owner)
+	}
+	if amount := changes[2]; amount.Changed != 1 || amount.Cleared != 0 ||
+		amount.Samples[0] != (ValueChange{LineNumber: 3, RowKey: "2", Current: "250", Incoming: "300"}) {
+		t.Errorf("amount = %+v", amount)
+	}
+	if len(diffs) != 3 || strings.Join(diffs[0].Changed, ",") != "Stage,Owner" {
+		t.Errorf("diffs = %+v", diffs)
+	}
+
+	// With the owner column, row 3 is unchanged
+	rows[2].values["Owner"] = "cy"
+	if _, _, unchanged := diffUpsertRows(def, rows[2:3], current); unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", unchanged)
+	}
+}
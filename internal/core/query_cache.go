@@ -0,0 +1,100 @@
+package core
+
+// query_cache.go caches the results of the count and aggregate queries
+// every dashboard and table view load runs: GetTableStats, GetAllTableStats
+// and unfiltered GetColumnAggregations. Filtered aggregations vary with
+// every search and are not cached.
+//
+// Entries live for QUERY_CACHE_TTL (0 disables the cache). Anything that
+// changes a table's rows - uploads, rollbacks, resets, edits, deletes,
+// restores and backfills - invalidates its entries, the all-tables stats
+// and every view's entries, since views read other tables. A result read
+// while an invalidation happens is not stored, so a query that started
+// before a change cannot put the old rows back.
+//
+// Cached values are shared between callers and must not be modified.
+
+import (
+	"sync"
+	"time"
+)
+
+// allTablesCacheKey is the table of cache entries covering every table.
+const allTablesCacheKey = ""
+
+// queryCacheKey identifies a cached result: the table it reads and which
+// query it is.
+type queryCacheKey struct {
+	table string
+	query string
+}
+
+type queryCacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// queryCache holds query results until they expire or their table changes.
+// The zero value is ready to use.
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[queryCacheKey]queryCacheEntry
+	gen     uint64 // Bumped by every invalidation
+}
+
+// cached returns the cached result for key, or calls load and caches what
+// it returns for ttl. Errors are not cached. A ttl of 0 always calls load.
+func cached[T any](c *queryCache, ttl time.Duration, key queryCacheKey, load func() (T, error)) (T, error) {
+	if ttl <= 0 {
+		return load()
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.value.(T), nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	v, err := load()
+	if err != nil {
+		return v, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		if c.entries == nil {
+			c.entries = make(map[queryCacheKey]queryCacheEntry)
+		}
+		c.entries[key] = queryCacheEntry{value: v, expires: time.Now().Add(ttl)}
+	}
+	return v, nil
+}
+
+// invalidate drops the entries reading tableKey, the all-tables entries
+// and the entries of views.
+func (c *queryCache) invalidate(tableKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key := range c.entries {
+		if key.table == tableKey || key.table == allTablesCacheKey {
+			delete(c.entries, key)
+		} else if def, ok := Get(key.table); ok && def.View != "" {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cacheTTL returns how long query results are cached.
+func (s *Service) cacheTTL() time.Duration {
+	return s.cfg.Query.CacheTTL
+}
+
+// invalidateQueryCache drops cached query results that read tableKey. Call
+// it once the table's rows have changed (after commit).
+func (s *Service) invalidateQueryCache(tableKey string) {
+	s.queryCache.invalidate(tableKey)
+}
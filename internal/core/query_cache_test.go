@@ -0,0 +1,62 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryCache(t *testing.T) {
+	var c queryCache
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return loads, nil
+	}
+	stats := queryCacheKey{"invoices", "stats"}
+	all := queryCacheKey{allTablesCacheKey, "stats"}
+
+	if v, _ := cached(&c, time.Minute, stats, load); v != 1 {
+		t.Fatalf("first load = %d", v)
+	}
+	if v, _ := cached(&c, time.Minute, stats, load); v != 1 {
+		t.Errorf("cached = %d, want 1 without a second query", v)
+	}
+	cached(&c, time.Minute, all, load)
+	cached(&c, time.Minute, queryCacheKey{"vendor_bills", "stats"}, load)
+
+	// A change drops the table's and the all-tables entries only
+	c.invalidate("invoices")
+	if v, _ := cached(&c, time.Minute, stats, load); v != 4 {
+		t.Errorf("after invalidate = %d, want a fresh load", v)
+	}
+	if v, _ := cached(&c, time.Minute, all, load); v != 5 {
+		t.Errorf("all tables after invalidate = %d, want a fresh load", v)
+	}
+	if v, _ := cached(&c, time.Minute, queryCacheKey{"vendor_bills", "stats"}, load); v != 3 {
+		t.Errorf("other table = %d, want its cached value", v)
+	}
+
+	// A result read across an invalidation is not stored
+	cached(&c, time.Minute, queryCacheKey{"journal", "stats"}, func() (int, error) {
+		c.invalidate("journal")
+		return 0, nil
+	})
+	if v, _ := cached(&c, time.Minute, queryCacheKey{"journal", "stats"}, load); v != 6 {
+		t.Errorf("stale result was cached: got %d", v)
+	}
+
+	// Errors are not cached, and a zero TTL disables the cache
+	if _, err := cached(&c, time.Minute, queryCacheKey{"ar", "stats"}, func() (int, error) {
+		return 0, errors.New("boom")
+	}); err == nil {
+		t.Error("error not returned")
+	}
+	if v, _ := cached(&c, time.Minute, queryCacheKey{"ar", "stats"}, load); v != 7 {
+		t.Errorf("after error = %d, want a fresh load", v)
+	}
+	cached(&c, 0, stats, load)
+	if v, _ := cached(&c, 0, stats, load); v != 9 {
+		t.Errorf("zero TTL = %d, want a load per call", v)
+	}
+}
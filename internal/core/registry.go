@@ -32,6 +32,43 @@ func Register(def TableDefinition) {
 	if _, exists := registry[def.Info.Key]; exists {
 		panic(fmt.Sprintf("table already registered: %s", def.Info.Key))
 	}
+	if err := validateStatistics(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if err := validateSchemaEvolution(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if err := validateView(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if err := validateSoftDelete(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if err := validateActions(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if err := validateDerived(def); err != nil {
+		panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+	}
+	if def.MinGroupSize < 0 {
+		panic(fmt.Sprintf("table %s: MinGroupSize must not be negative", def.Info.Key))
+	}
+	for _, spec := range def.FieldSpecs {
+		if err := checkYearPivot(spec.YearPivot); err != nil {
+			panic(fmt.Sprintf("table %s: field %s: %v", def.Info.Key, spec.Name, err))
+		}
+		if err := checkMask(spec.Mask, spec.Type); err != nil {
+			panic(fmt.Sprintf("table %s: field %s: %v", def.Info.Key, spec.Name, err))
+		}
+		if err := checkDisplayFormat(spec); err != nil {
+			panic(fmt.Sprintf("table %s: field %s: %v", def.Info.Key, spec.Name, err))
+		}
+	}
+	if def.UploadMode != "" {
+		if _, err := resolveUploadMode(def, def.UploadMode); err != nil {
+			panic(fmt.Sprintf("table %s: %v", def.Info.Key, err))
+		}
+	}
 
 	// Populate Columns from FieldSpecs if not set
 	if len(def.Info.Columns) == 0 && len(def.FieldSpecs) > 0 {
@@ -41,6 +78,13 @@ func Register(def TableDefinition) {
 		}
 	}
 
+	def.Info.ReadOnly = def.IsView()
+
+	// Tables without their own rollback delete by the upload_id column
+	if !def.IsView() && def.DeleteByUploadID == nil {
+		def.DeleteByUploadID = deleteByUploadID(def.Info.Key)
+	}
+
 	registry[def.Info.Key] = def
 }
 
@@ -129,3 +173,49 @@ func Clear() {
 	defer registryMu.Unlock()
 	registry = make(map[string]TableDefinition)
 }
+
+// SetEnumValues replaces the allowed values of an enum column at runtime.
+// Used by declarative bootstrap to tune enums per environment.
+func SetEnumValues(tableKey, column string, values []string) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	def, ok := registry[tableKey]
+	if !ok {
+		return fmt.Errorf("unknown table: %s", tableKey)
+	}
+
+	// Copy FieldSpecs so definitions already handed out by Get are unaffected
+	specs := make([]FieldSpec, len(def.FieldSpecs))
+	copy(specs, def.FieldSpecs)
+
+	for i := range specs {
+		if specs[i].Name != column {
+			continue
+		}
+		if specs[i].Type != FieldEnum {
+			return fmt.Errorf("column %s in %s is not an enum", column, tableKey)
+		}
+		specs[i].EnumValues = append([]string(nil), values...)
+		def.FieldSpecs = specs
+		registry[tableKey] = def
+		return nil
+	}
+
+	return fmt.Errorf("unknown column %s in %s", column, tableKey)
+}
+
+// SetUploadLimits replaces the upload limits of a table at runtime.
+// Used by declarative bootstrap to tune limits per environment.
+func SetUploadLimits(tableKey string, limits UploadLimits) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	def, ok := registry[tableKey]
+	if !ok {
+		return fmt.Errorf("unknown table: %s", tableKey)
+	}
+	def.Limits = limits
+	registry[tableKey] = def
+	return nil
+}
@@ -0,0 +1,335 @@
+package core
+
+// remote_source.go streams uploads from remote URLs, so large exports
+// (Salesforce, NetSuite) can be loaded without passing through a browser.
+//
+// Sources are pluggable per URL scheme. Built in:
+//
+//	https://host/path/export.csv        Plain HTTPS GET
+//	s3://bucket/key.csv                 Amazon S3 or an S3-compatible store
+//	gs://bucket/object.csv              Google Cloud Storage
+//
+// Providers read the standard environment of their platform, as the secret
+// providers do:
+//
+//	s3:  AWS_REGION (or AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID,
+//	     AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN (optional),
+//	     AWS_ENDPOINT_URL_S3 (optional; path-style requests)
+//	gs:  GOOGLE_OAUTH_ACCESS_TOKEN, or the GCE/GKE metadata server
+//
+// Fetching server-side makes this an SSRF surface, so only URLs under one of
+// the configured UPLOAD_REMOTE_SOURCES prefixes may be read, and HTTPS
+// redirects may not leave the original host.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+// ErrRemoteSourceNotAllowed is returned for URLs outside the configured
+// remote source prefixes, or with a scheme no provider handles.
+var ErrRemoteSourceNotAllowed = errors.New("remote source not allowed")
+
+// SourceProvider opens a remote file for one URL scheme. size is -1 if the
+// source does not report it. The caller closes body.
+type SourceProvider interface {
+	Open(ctx context.Context, u *url.URL) (body io.ReadCloser, size int64, err error)
+}
+
+// SourceProviderFunc adapts a function to the SourceProvider interface.
+type SourceProviderFunc func(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error)
+
+// Open calls f(ctx, u).
+func (f SourceProviderFunc) Open(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	return f(ctx, u)
+}
+
+var (
+	sourceProvidersMu sync.RWMutex
+	sourceProviders   = map[string]SourceProvider{
+		"https": SourceProviderFunc(openHTTPSSource),
+		"s3":    SourceProviderFunc(openS3Source),
+		"gs":    SourceProviderFunc(openGCSSource),
+	}
+)
+
+// RegisterSourceProvider adds or replaces the upload source for a scheme.
+// The scheme must still be allowed by UPLOAD_REMOTE_SOURCES.
+func RegisterSourceProvider(scheme string, p SourceProvider) {
+	sourceProvidersMu.Lock()
+	defer sourceProvidersMu.Unlock()
+	sourceProviders[scheme] = p
+}
+
+// sourceHTTPClient is used by the built-in providers. There is no overall
+// timeout, since bodies are streamed for as long as the upload runs; the
+// upload context bounds it instead.
+var sourceHTTPClient = &http.Client{
+	Transport: func() http.RoundTripper {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ResponseHeaderTimeout = 30 * time.Second
+		return t
+	}(),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "https" || req.URL.Host != via[0].URL.Host {
+			return fmt.Errorf("%w: redirect to %s", ErrRemoteSourceNotAllowed, req.URL.Redacted())
+		}
+		return nil
+	},
+}
+
+// StartUploadFromURL begins an asynchronous streaming upload of the CSV at
+// rawURL. The file is read by the upload as it is processed, so memory use
+// is the same as for StartUploadStreaming. Returns the upload ID.
+func (s *Service) StartUploadFromURL(ctx context.Context, tableKey, rawURL string, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, error) {
+	u, err := s.parseRemoteSource(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	sourceProvidersMu.RLock()
+	p := sourceProviders[u.Scheme]
+	sourceProvidersMu.RUnlock()
+
+	// The body outlives the request, so it is bounded by the upload timeout
+	// rather than ctx
+	openCtx, cancel := context.WithTimeout(context.Background(), s.UploadTimeout())
+	body, size, err := p.Open(openCtx, u)
+	if err != nil {
+		cancel()
+		return "", fmt.Errorf("open %s: %w", u.Redacted(), err)
+	}
+
+	maxSize := s.cfg.Upload.MaxFileSize
+	if maxSize > 0 && size > maxSize {
+		body.Close()
+		cancel()
+		return "", fmt.Errorf("remote file too large: %d bytes (max %d)", size, maxSize)
+	}
+
+	fileName := path.Base(u.Path)
+	if fileName == "." || fileName == "/" {
+		fileName = u.Host
+	}
+	reader := &remoteBody{body: body, cancel: cancel, remaining: maxSize, limited: maxSize > 0}
+
+	slog.Info("starting upload from URL", "table", tableKey, "source", u.Redacted(), "size", size)
+	uploadID, err := s.StartUploadStreaming(ctx, tableKey, fileName, reader, max(size, 0), mapping, mode, dups, dateOpts)
+	if err != nil {
+		reader.Close()
+		return "", err
+	}
+	return uploadID, nil
+}
+
+// parseRemoteSource parses rawURL and checks it against the configured
+// remote source prefixes.
+func (s *Service) parseRemoteSource(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL: %w", err)
+	}
+	if u.User != nil || u.Host == "" || u.Fragment != "" {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteSourceNotAllowed, u.Redacted())
+	}
+	// Servers may resolve dot segments, escaping an allowed prefix
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg == ".." || seg == "." {
+			return nil, fmt.Errorf("%w: %s", ErrRemoteSourceNotAllowed, u.Redacted())
+		}
+	}
+
+	sourceProvidersMu.RLock()
+	_, ok := sourceProviders[u.Scheme]
+	sourceProvidersMu.RUnlock()
+	if !ok || !remoteSourceAllowed(s.cfg.Upload.RemoteSources, u) {
+		return nil, fmt.Errorf("%w: %s", ErrRemoteSourceNotAllowed, u.Redacted())
+	}
+	return u, nil
+}
+
+// remoteSourceAllowed reports whether u falls under one of the prefixes.
+// A prefix matches whole path segments: "s3://b/exports" allows
+// "s3://b/exports/x.csv" but not "s3://b/exports-old/x.csv".
+func remoteSourceAllowed(prefixes []string, u *url.URL) bool {
+	target := u.Scheme + "://" + u.Host + u.EscapedPath()
+	for _, prefix := range prefixes {
+		if prefix == "" || !strings.HasPrefix(target, prefix) {
+			continue
+		}
+		rest := target[len(prefix):]
+		if rest == "" || strings.HasSuffix(prefix, "/") || strings.HasPrefix(rest, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteBody enforces the upload size limit on a remote body of unknown or
+// misreported size, and releases the open context when closed.
+type remoteBody struct {
+	body      io.ReadCloser
+	cancel    context.CancelFunc
+	remaining int64
+	limited   bool
+}
+
+func (r *remoteBody) Read(p []byte) (int, error) {
+	if r.limited {
+		if r.remaining <= 0 {
+			// Distinguish a file of exactly the limit from one over it
+			var probe [1]byte
+			if n, _ := r.body.Read(probe[:]); n > 0 {
+				return 0, errors.New("remote file exceeds the maximum upload size")
+			}
+			return 0, io.EOF
+		}
+		if int64(len(p)) > r.remaining {
+			p = p[:r.remaining]
+		}
+	}
+	n, err := r.body.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (r *remoteBody) Close() error {
+	err := r.body.Close()
+	r.cancel()
+	return err
+}
+
+// =============================================================================
+// Providers
+// =============================================================================
+
+// openHTTPSSource fetches the URL with a plain GET.
+func openHTTPSSource(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return doSourceRequest(req)
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body, sent as
+// x-amz-content-sha256 on signed S3 GETs.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// openS3Source fetches s3://bucket/key with GetObject, signed with SigV4
+// when credentials are set. AWS uses virtual-hosted URLs; a custom endpoint
+// (MinIO, R2, ...) uses path-style.
+func openS3Source(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, 0, fmt.Errorf("AWS_REGION must be set")
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, 0, fmt.Errorf("missing object key")
+	}
+	var target *url.URL
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_S3"); endpoint != "" {
+		base, err := url.Parse(strings.TrimRight(endpoint, "/"))
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid AWS_ENDPOINT_URL_S3: %w", err)
+		}
+		target = &url.URL{Scheme: base.Scheme, Host: base.Host,
+			Path:    base.Path + "/" + bucket + "/" + key,
+			RawPath: base.EscapedPath() + "/" + s3EscapePath(bucket) + "/" + s3EscapePath(key)}
+	} else {
+		target = &url.URL{Scheme: "https", Host: bucket + ".s3." + region + ".amazonaws.com",
+			Path: "/" + key, RawPath: "/" + s3EscapePath(key)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey != "" && secretKey != "" {
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+		if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+			req.Header.Set("X-Amz-Security-Token", token)
+		}
+		config.SignAWSRequest(req, nil, accessKey, secretKey, region, "s3", time.Now())
+	}
+	return doSourceRequest(req)
+}
+
+// s3EscapePath URI-encodes an object key for SigV4: everything except
+// unreserved characters and "/" is percent-encoded.
+func s3EscapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// gcsEndpoint is the Cloud Storage JSON API; a variable so tests can point
+// it at a local server.
+var gcsEndpoint = "https://storage.googleapis.com"
+
+// openGCSSource downloads gs://bucket/object through the JSON API.
+func openGCSSource(ctx context.Context, u *url.URL) (io.ReadCloser, int64, error) {
+	object := strings.TrimPrefix(u.Path, "/")
+	if object == "" {
+		return nil, 0, fmt.Errorf("missing object name")
+	}
+
+	token, err := config.GCPAccessToken(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get access token: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		gcsEndpoint, url.PathEscape(u.Host), url.PathEscape(object))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doSourceRequest(req)
+}
+
+// doSourceRequest sends req and returns the body of a 200 response.
+// Error bodies are not included, since they may echo request details.
+func doSourceRequest(req *http.Request) (io.ReadCloser, int64, error) {
+	resp, err := sourceHTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("GET %s: status %d", req.URL.Host, resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestParseRemoteSource(t *testing.T) {
+	s := &Service{cfg: &config.Config{Upload: config.UploadConfig{
+		RemoteSources: []string{"s3://exports/netsuite", "https://files.example.com/sf/"},
+	}}}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"s3://exports/netsuite/invoices.csv", true},
+		{"s3://exports/netsuite", true},
+		{"s3://exports/netsuite-old/invoices.csv", false},
+		{"s3://other/netsuite/invoices.csv", false},
+		{"https://files.example.com/sf/accounts.csv?sig=abc", true},
+		{"https://files.example.com.evil.test/sf/accounts.csv", false},
+		{"https://files.example.com/sf/../admin.csv", false},
+		{"https://files.example.com/sf/%2e%2e/admin.csv", false},
+		{"https://user:pw@files.example.com/sf/accounts.csv", false},
+		{"http://files.example.com/sf/accounts.csv", false},
+		{"ftp://files.example.com/sf/accounts.csv", false},
+	}
+	for _, tt := range tests {
+		_, err := s.parseRemoteSource(tt.url)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.url, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrRemoteSourceNotAllowed) {
+			t.Errorf("%s: err = %v, want ErrRemoteSourceNotAllowed", tt.url, err)
+		}
+	}
+
+	s.cfg.Upload.RemoteSources = nil
+	if _, err := s.parseRemoteSource("s3://exports/netsuite/invoices.csv"); !errors.Is(err, ErrRemoteSourceNotAllowed) {
+		t.Errorf("with no prefixes configured: err = %v", err)
+	}
+}
+
+func TestOpenS3Source_PathStyleSigned(t *testing.T) {
+	var gotPath, gotAuth, gotHash string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotHash = r.Header.Get("X-Amz-Content-Sha256")
+		io.WriteString(w, "id,name\n1,a\n")
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+
+	u, _ := url.Parse("s3://exports/netsuite/Q1 invoices+credits.csv")
+	body, size, err := openS3Source(t.Context(), u)
+	if err != nil {
+		t.Fatalf("openS3Source: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+
+	if gotPath != "/exports/netsuite/Q1%20invoices%2Bcredits.csv" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(gotAuth, "/us-east-1/s3/aws4_request") {
+		t.Errorf("authorization = %q", gotAuth)
+	}
+	if gotHash != emptyPayloadHash {
+		t.Errorf("content hash = %q", gotHash)
+	}
+	if size != int64(len(data)) || string(data) != "id,name\n1,a\n" {
+		t.Errorf("size %d, body %q", size, data)
+	}
+}
+
+func TestOpenHTTPSSource_StatusAndRedirects(t *testing.T) {
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.csv":
+			http.NotFound(w, r)
+		case "/moved.csv":
+			http.Redirect(w, r, other.URL+"/x.csv", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	orig := sourceHTTPClient.Transport
+	sourceHTTPClient.Transport = srv.Client().Transport
+	defer func() { sourceHTTPClient.Transport = orig }()
+
+	u, _ := url.Parse(srv.URL + "/missing.csv")
+	if _, _, err := openHTTPSSource(t.Context(), u); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("missing file: err = %v", err)
+	}
+	u, _ = url.Parse(srv.URL + "/moved.csv")
+	if _, _, err := openHTTPSSource(t.Context(), u); !errors.Is(err, ErrRemoteSourceNotAllowed) {
+		t.Errorf("cross-host redirect: err = %v", err)
+	}
+}
+
+func TestRemoteBody_SizeLimit(t *testing.T) {
+	cancelled := false
+	read := func(data string, limit int64) (string, error) {
+		r := &remoteBody{
+			body:      io.NopCloser(strings.NewReader(data)),
+			cancel:    func() { cancelled = true },
+			remaining: limit,
+			limited:   limit > 0,
+		}
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		return string(got), err
+	}
+
+	if got, err := read("12345", 5); err != nil || got != "12345" {
+		t.Errorf("exactly at limit: %q, %v", got, err)
+	}
+	if _, err := read("123456", 5); err == nil {
+		t.Error("over limit: expected error")
+	}
+	if got, err := read("123456", 0); err != nil || got != "123456" {
+		t.Errorf("unlimited: %q, %v", got, err)
+	}
+	if !cancelled {
+		t.Error("Close should cancel the open context")
+	}
+}
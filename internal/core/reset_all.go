@@ -0,0 +1,497 @@
+package core
+
+// reset_all.go empties every table in dependency order.
+//
+// Tables declare the tables their rows refer to in References, and foreign
+// keys between registered tables in the database count too. ResetAll
+// empties a table before the tables it references, so no row is left
+// pointing at a deleted one and no foreign key is violated on the way.
+//
+// By default the whole reset is one transaction: if any table fails, or the
+// run is cancelled or times out, every table keeps its rows. An incremental
+// reset commits each table on its own instead. A table that fails does not
+// stop the run: the others are still reset, except the tables a table that
+// kept its rows references, which keep theirs too. Each table's outcome is
+// a step of the reset operation and is recorded after every table, so
+// ResumeResetAll can pick up an incremental run that failed, was cancelled
+// or was cut off by a restart, resetting only the tables it did not finish.
+//
+// A transactional reset is audited as one table_reset entry listing the
+// rows deleted from each table; an incremental one gets an entry per table
+// it reset, sharing a batch ID.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// stepResetCommit is the last step of a transactional reset of all tables.
+// Its presence also marks the run as one that cannot be resumed.
+const stepResetCommit = "commit"
+
+// ResetAllOptions controls ResetAll.
+type ResetAllOptions struct {
+	// Incremental commits each table on its own, so a failed table does not
+	// undo the others and a run that stops part way can be resumed. By
+	// default all tables are reset in one transaction.
+	Incremental bool
+}
+
+// TableResetStatus is the outcome of one table in ResetAll.
+type TableResetStatus string
+
+const (
+	TableResetDone       TableResetStatus = "reset"
+	TableResetFailed     TableResetStatus = "failed"
+	TableResetSkipped    TableResetStatus = "skipped"     // Referenced by a table that kept its rows, or reset by the resumed run
+	TableResetPending    TableResetStatus = "pending"     // Not reached before the run stopped
+	TableResetRolledBack TableResetStatus = "rolled_back" // Reset, then undone with the rest of the transaction
+)
+
+// TableResetResult is the outcome of one table in ResetAll.
+type TableResetResult struct {
+	TableKey    string           `json:"tableKey"`
+	Status      TableResetStatus `json:"status"`
+	RowsDeleted int64            `json:"rowsDeleted"`
+	Reason      string           `json:"reason,omitempty"`
+}
+
+// ResetAllResult reports what ResetAll did to each table, in reset order.
+type ResetAllResult struct {
+	OperationID  string             `json:"operationId"`
+	ResumedFrom  string             `json:"resumedFrom,omitempty"`
+	Incremental  bool               `json:"incremental"`
+	Tables       []TableResetResult `json:"tables"`
+	RowsDeleted  int64              `json:"rowsDeleted"`
+	AuditBatchID string             `json:"auditBatchId,omitempty"` // Of the run's table_reset entries
+	Success      bool               `json:"success"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// resetAllPlan is the work of a ResetAll run.
+type resetAllPlan struct {
+	order       []TableDefinition
+	incremental bool
+	done        map[string]bool // Tables the resumed run already reset
+	resumedFrom string
+}
+
+// steps returns the operation steps of the plan: one per table, named by
+// table key, and a commit step for a transactional run.
+func (p *resetAllPlan) steps() []OperationStep {
+	steps := resetSteps(p.order...)
+	if !p.incremental {
+		steps = append(steps, OperationStep{Name: stepResetCommit, Weight: 1})
+	}
+	return steps
+}
+
+// ResetAll deletes all data from all registered tables in dependency
+// order, as a reset operation with one step per table. The result reports
+// each table; the error says why tables were not reset.
+func (s *Service) ResetAll(ctx context.Context, opts ResetAllOptions) (ResetAllResult, error) {
+	p, err := s.newResetAllPlan(ctx, opts)
+	if err != nil {
+		return ResetAllResult{Incremental: opts.Incremental, Error: err.Error()}, err
+	}
+	return s.runResetAllPlan(ctx, p)
+}
+
+// StartResetAll runs ResetAll in the background and returns the operation
+// ID to follow it with.
+func (s *Service) StartResetAll(ctx context.Context, opts ResetAllOptions) (string, error) {
+	p, err := s.newResetAllPlan(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	return s.startResetAllPlan(ctx, p), nil
+}
+
+// ResumeResetAll resets the tables that an earlier incremental ResetAll,
+// identified by its operation ID, did not finish. Tables it reset are
+// skipped.
+func (s *Service) ResumeResetAll(ctx context.Context, operationID string) (ResetAllResult, error) {
+	p, err := s.resumeResetAllPlan(ctx, operationID)
+	if err != nil {
+		return ResetAllResult{ResumedFrom: operationID, Incremental: true, Error: err.Error()}, err
+	}
+	return s.runResetAllPlan(ctx, p)
+}
+
+// StartResumeResetAll runs ResumeResetAll in the background and returns
+// the operation ID to follow it with.
+func (s *Service) StartResumeResetAll(ctx context.Context, operationID string) (string, error) {
+	p, err := s.resumeResetAllPlan(ctx, operationID)
+	if err != nil {
+		return "", err
+	}
+	return s.startResetAllPlan(ctx, p), nil
+}
+
+// newResetAllPlan orders every table except views for reset. No table is
+// reset if any holds rows under a legal hold, or if the reset needs
+// approval.
+func (s *Service) newResetAllPlan(ctx context.Context, opts ResetAllOptions) (*resetAllPlan, error) {
+	var defs []TableDefinition
+	for _, def := range All() {
+		if !def.IsView() {
+			defs = append(defs, def)
+		}
+	}
+	fks, err := s.foreignKeyReferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := resetOrder(withForeignKeys(defs, fks))
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range order {
+		if err := s.checkLegalHold(ctx, def, "reset", "TRUE"); err != nil {
+			return nil, err
+		}
+	}
+	req := approvalRequest{kind: ApprovalResetAll, params: map[string]string{"incremental": strconv.FormatBool(opts.Incremental)}}
+	if err := s.requireApproval(ctx, req, func(ctx context.Context, limit int64) (int64, error) {
+		var total int64
+		for _, def := range order {
+			n, err := s.countRows(ctx, def, limit, "TRUE")
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+		return total, nil
+	}); err != nil {
+		return nil, err
+	}
+	return &resetAllPlan{order: order, incremental: opts.Incremental}, nil
+}
+
+// resumeResetAllPlan plans the rest of a recorded incremental ResetAll.
+func (s *Service) resumeResetAllPlan(ctx context.Context, operationID string) (*resetAllPlan, error) {
+	rec, err := s.GetOperation(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Kind != OperationReset || rec.TableKey != "" {
+		return nil, fmt.Errorf("operation %s is not a reset of all tables", operationID)
+	}
+	switch rec.Status {
+	case OperationRunning:
+		return nil, fmt.Errorf("operation %s is still running", operationID)
+	case OperationComplete:
+		return nil, fmt.Errorf("operation %s already reset every table", operationID)
+	}
+	steps := rec.Steps
+	if rec.Progress != nil {
+		steps = rec.Progress.Steps
+	}
+	if slices.ContainsFunc(steps, func(step StepProgress) bool { return step.Name == stepResetCommit }) {
+		return nil, fmt.Errorf("operation %s ran in one transaction and reset nothing; start a new reset", operationID)
+	}
+
+	p, err := s.newResetAllPlan(ctx, ResetAllOptions{Incremental: true})
+	if err != nil {
+		return nil, err
+	}
+	p.done = make(map[string]bool)
+	for _, step := range steps {
+		if step.Status == StepComplete {
+			p.done[step.Name] = true
+		}
+	}
+	p.resumedFrom = operationID
+	return p, nil
+}
+
+// runResetAllPlan runs p as a new reset operation and waits for it.
+func (s *Service) runResetAllPlan(ctx context.Context, p *resetAllPlan) (ResetAllResult, error) {
+	op := s.StartOperation(ctx, OperationReset, "", p.steps())
+	result, err := s.runResetAll(ctx, op, p)
+	op.Finish(err)
+	return result, err
+}
+
+// startResetAllPlan runs p as a new reset operation in the background.
+func (s *Service) startResetAllPlan(ctx context.Context, p *resetAllPlan) string {
+	op := s.StartOperation(ctx, OperationReset, "", p.steps())
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		_, err := s.runResetAll(ctx, op, p)
+		return err
+	})
+	return op.ID()
+}
+
+// runResetAll resets the tables of p under op, within the reset timeout.
+func (s *Service) runResetAll(ctx context.Context, op *Operation, p *resetAllPlan) (ResetAllResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.ResetTimeout())
+	defer cancel()
+
+	result := ResetAllResult{
+		OperationID:  op.ID(),
+		ResumedFrom:  p.resumedFrom,
+		Incremental:  p.incremental,
+		Tables:       make([]TableResetResult, 0, len(p.order)),
+		AuditBatchID: uuid.New().String(),
+	}
+	var err error
+	if p.incremental {
+		err = s.resetIncrementally(ctx, op, p, &result)
+	} else {
+		err = s.resetInTransaction(ctx, op, p, &result)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Success = true
+	return result, nil
+}
+
+// resetInTransaction resets every table of p in one transaction, auditing
+// the run once it commits. On failure all tables keep their rows.
+func (s *Service) resetInTransaction(ctx context.Context, op *Operation, p *resetAllPlan, result *ResetAllResult) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	// undo reports the tables reset so far as rolled back
+	undo := func() {
+		result.RowsDeleted = 0
+		for i := range result.Tables {
+			if result.Tables[i].Status == TableResetDone {
+				result.Tables[i].Status = TableResetRolledBack
+				op.SetDetail(result.Tables[i].TableKey, "rolled back")
+			}
+		}
+	}
+
+	for i, def := range p.order {
+		n, err := s.resetTable(ctx, tx, op, def)
+		if err != nil {
+			undo()
+			result.Tables = append(result.Tables, TableResetResult{
+				TableKey: def.Info.Key, Status: TableResetFailed, Reason: err.Error(),
+			})
+			for _, rest := range p.order[i+1:] {
+				result.Tables = append(result.Tables, TableResetResult{TableKey: rest.Info.Key, Status: TableResetPending})
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("no table was reset: %w", err)
+		}
+		result.Tables = append(result.Tables, TableResetResult{TableKey: def.Info.Key, Status: TableResetDone, RowsDeleted: n})
+		result.RowsDeleted += n
+	}
+
+	op.Begin(stepResetCommit)
+	if err := tx.Commit(ctx); err != nil {
+		op.EndStep(stepResetCommit, err)
+		undo()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("commit: %w", err)
+	}
+	op.EndStep(stepResetCommit, nil)
+
+	for _, r := range result.Tables {
+		s.notifyTableReset(r.TableKey, r.RowsDeleted, result.AuditBatchID, nil)
+	}
+	// The reset is committed, so a failed entry is logged rather than
+	// reported as a failed reset
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), resetAllAuditParams(ctx, result)); err != nil {
+		slog.Error("failed to log reset all audit", "operation_id", result.OperationID, "error", err)
+	}
+	return nil
+}
+
+// resetAllAuditParams returns the single audit entry of a transactional
+// reset of all tables, with the rows deleted from each table.
+func resetAllAuditParams(ctx context.Context, result *ResetAllResult) AuditLogParams {
+	tables := make(map[string]int64, len(result.Tables))
+	for _, r := range result.Tables {
+		tables[r.TableKey] = r.RowsDeleted
+	}
+	return AuditLogParams{
+		Action:       ActionTableReset,
+		RowsAffected: int(result.RowsDeleted),
+		BatchID:      result.AuditBatchID,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       fmt.Sprintf("Reset all %d tables in one transaction", len(tables)),
+		RowData: map[string]any{
+			"operation_id": result.OperationID,
+			"tables":       tables,
+		},
+	}
+}
+
+// resetIncrementally resets the tables of p in order, each committed on its
+// own, continuing past tables that fail.
+func (s *Service) resetIncrementally(ctx context.Context, op *Operation, p *resetAllPlan, result *ResetAllResult) error {
+	referrers := make(map[string][]string)
+	for _, def := range p.order {
+		for _, ref := range def.References {
+			referrers[ref] = append(referrers[ref], def.Info.Key)
+		}
+	}
+	kept := make(map[string]bool) // Tables that still have their rows
+
+	var notReset, pending int
+	for _, def := range p.order {
+		tableKey := def.Info.Key
+		r := TableResetResult{TableKey: tableKey}
+		by := slices.IndexFunc(referrers[tableKey], func(k string) bool { return kept[k] })
+
+		switch {
+		case p.done[tableKey]:
+			r.Status = TableResetSkipped
+			r.Reason = "reset by operation " + p.resumedFrom
+			op.Begin(tableKey)
+			op.SetDetail(tableKey, r.Reason)
+			op.EndStep(tableKey, nil)
+		case ctx.Err() != nil:
+			r.Status = TableResetPending
+			kept[tableKey] = true
+			pending++
+		case by >= 0:
+			r.Status = TableResetSkipped
+			r.Reason = fmt.Sprintf("referenced by %s, which was not reset", referrers[tableKey][by])
+			op.SetDetail(tableKey, r.Reason)
+			kept[tableKey] = true
+			notReset++
+		default:
+			n, err := s.resetTable(ctx, s.pool, op, def)
+			s.logTableReset(ctx, tableKey, n, result.AuditBatchID, err)
+			r.RowsDeleted = n
+			result.RowsDeleted += n
+			r.Status = TableResetDone
+			if err != nil {
+				r.Status = TableResetFailed
+				r.Reason = err.Error()
+				kept[tableKey] = true
+				notReset++
+			}
+			// Keep each table's outcome durable for ResumeResetAll
+			s.saveOperation(op)
+		}
+		result.Tables = append(result.Tables, r)
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("stopped with %d of %d tables left: %w", pending, len(p.order), ctx.Err())
+	}
+	if notReset > 0 {
+		return fmt.Errorf("%d of %d tables not reset", notReset, len(p.order))
+	}
+	return nil
+}
+
+// foreignKeyReferences returns, for each table in the current schema with
+// foreign keys, the tables they reference.
+func (s *Service) foreignKeyReferences(ctx context.Context) (map[string][]string, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT child.relname::text, parent.relname::text
+		FROM pg_constraint c
+		JOIN pg_class child ON child.oid = c.conrelid
+		JOIN pg_class parent ON parent.oid = c.confrelid
+		WHERE c.contype = 'f'
+		  AND child.relnamespace = to_regnamespace(current_schema())`)
+	if err != nil {
+		return nil, fmt.Errorf("read foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	fks := make(map[string][]string)
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			return nil, fmt.Errorf("scan foreign key: %w", err)
+		}
+		fks[child] = append(fks[child], parent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return fks, nil
+}
+
+// withForeignKeys returns defs with the foreign key references in fks added
+// to each table's declared References.
+func withForeignKeys(defs []TableDefinition, fks map[string][]string) []TableDefinition {
+	out := make([]TableDefinition, len(defs))
+	for i, def := range defs {
+		refs := slices.Clone(def.References)
+		for _, ref := range fks[def.Info.Key] {
+			if !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		def.References = refs
+		out[i] = def
+	}
+	return out
+}
+
+// resetOrder orders tables so each comes before the tables it references.
+// Otherwise tables keep their order in defs. References to tables not in
+// defs, and to the table itself, are ignored; a cycle is an error.
+func resetOrder(defs []TableDefinition) ([]TableDefinition, error) {
+	keys := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		keys[def.Info.Key] = true
+	}
+	// Tables not yet placed that reference each table
+	referrers := make(map[string]int)
+	for _, def := range defs {
+		for _, ref := range def.References {
+			if keys[ref] && ref != def.Info.Key {
+				referrers[ref]++
+			}
+		}
+	}
+
+	order := make([]TableDefinition, 0, len(defs))
+	placed := make(map[string]bool, len(defs))
+	for len(order) < len(defs) {
+		next := -1
+		for i, def := range defs {
+			if !placed[def.Info.Key] && referrers[def.Info.Key] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for _, def := range defs {
+				if !placed[def.Info.Key] {
+					cycle = append(cycle, def.Info.Key)
+				}
+			}
+			return nil, fmt.Errorf("tables reference each other in a cycle: %s", strings.Join(cycle, ", "))
+		}
+		def := defs[next]
+		placed[def.Info.Key] = true
+		order = append(order, def)
+		for _, ref := range def.References {
+			if keys[ref] && ref != def.Info.Key {
+				referrers[ref]--
+			}
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func resetOrderKeys(defs []TableDefinition) string {
+	keys := make([]string, len(defs))
+	for i, def := range defs {
+		keys[i] = def.Info.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestResetOrder(t *testing.T) {
+	table := func(key string, refs ...string) TableDefinition {
+		return TableDefinition{Info: TableInfo{Key: key}, References: refs}
+	}
+
+	// Lines before invoices before customers; unrelated tables keep their place
+	defs := []TableDefinition{
+		table("customers"),
+		table("invoices", "customers"),
+		table("notes"),
+		table("invoice_lines", "invoices", "customers"),
+	}
+	order, err := resetOrder(defs)
+	if err != nil {
+		t.Fatalf("resetOrder: %v", err)
+	}
+	if got := resetOrderKeys(order); got != "notes,invoice_lines,invoices,customers" {
+		t.Errorf("order = %s", got)
+	}
+
+	// Unknown tables and self references do not constrain the order
+	order, err = resetOrder([]TableDefinition{table("a", "missing"), table("b", "b")})
+	if err != nil || resetOrderKeys(order) != "a,b" {
+		t.Errorf("order = %s, err = %v", resetOrderKeys(order), err)
+	}
+
+	_, err = resetOrder([]TableDefinition{table("a", "b"), table("b", "c"), table("c", "a"), table("d")})
+	if err == nil || !strings.Contains(err.Error(), "cycle: a, b, c") {
+		t.Errorf("cycle: err = %v", err)
+	}
+}
+
+func TestWithForeignKeys(t *testing.T) {
+	defs := []TableDefinition{
+		{Info: TableInfo{Key: "customers"}},
+		{Info: TableInfo{Key: "invoices"}, References: []string{"customers"}},
+	}
+	fks := map[string][]string{"customers": {"regions"}, "invoices": {"customers", "currencies"}}
+
+	got := withForeignKeys(defs, fks)
+	if strings.Join(got[0].References, ",") != "regions" || strings.Join(got[1].References, ",") != "customers,currencies" {
+		t.Errorf("references = %v, %v", got[0].References, got[1].References)
+	}
+	// The registered definitions are not changed
+	if len(defs[0].References) != 0 || len(defs[1].References) != 1 {
+		t.Errorf("defs modified: %v, %v", defs[0].References, defs[1].References)
+	}
+}
+
+func TestResetAllPlan_Steps(t *testing.T) {
+	p := &resetAllPlan{order: []TableDefinition{{Info: TableInfo{Key: "a"}}, {Info: TableInfo{Key: "b"}}}}
+	if steps := p.steps(); len(steps) != 3 || steps[2].Name != stepResetCommit {
+		t.Errorf("transactional steps = %+v", steps)
+	}
+	p.incremental = true
+	if steps := p.steps(); len(steps) != 2 {
+		t.Errorf("incremental steps = %+v", steps)
+	}
+}
+
+func TestResetAllAuditParams(t *testing.T) {
+	result := &ResetAllResult{
+		OperationID:  "op1",
+		AuditBatchID: "b1",
+		RowsDeleted:  7,
+		Tables: []TableResetResult{
+			{TableKey: "lines", Status: TableResetDone, RowsDeleted: 5},
+			{TableKey: "orders", Status: TableResetDone, RowsDeleted: 2},
+		},
+	}
+	p := resetAllAuditParams(context.Background(), result)
+	if p.Action != ActionTableReset || p.TableKey != "" || p.RowsAffected != 7 || p.BatchID != "b1" {
+		t.Errorf("params = %+v", p)
+	}
+	if want := map[string]int64{"lines": 5, "orders": 2}; !reflect.DeepEqual(p.RowData["tables"], want) {
+		t.Errorf("tables = %v, want %v", p.RowData["tables"], want)
+	}
+	if p.Reason != "Reset all 2 tables in one transaction" {
+		t.Errorf("reason = %q", p.Reason)
+	}
+}
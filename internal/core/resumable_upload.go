@@ -0,0 +1,538 @@
+package core
+
+// resumable_upload.go lets a browser upload a file in chunks and pick the
+// upload back up after a page refresh instead of starting over.
+//
+// CreateResumableUpload opens a session for one file, bound to a resume
+// token the browser keeps in local storage. Chunks are appended in order
+// with AppendResumableChunk, each encrypted into the spool as it arrives,
+// so only the session's bookkeeping is held in memory. After a refresh the
+// browser lists its sessions with PendingUploads, has the user pick the
+// same file again, and sends the bytes the server does not have yet.
+// CompleteResumableUpload then processes the chunks as one streaming upload
+// (or one batch, for a zip archive); the session keeps the upload ID so a
+// refreshed page can reattach to its progress.
+//
+// Chunks are only ever stored encrypted, so resumable uploads need
+// UPLOAD_SPOOL_KEYS. Sessions live in memory: a restart loses them, and
+// their chunks show up as orphaned in the spool report. An unfinished
+// session is discarded, chunks and all, once it has been idle for
+// UPLOAD_RESUMABLE_TTL; a completed one once its upload is no longer
+// tracked.
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minResumeTokenLen = 16
+	maxResumeTokenLen = 128
+
+	// maxPendingPerToken caps the unfinished sessions one token may hold, so
+	// a single client cannot fill the spool with abandoned chunks.
+	maxPendingPerToken = 10
+)
+
+var (
+	// ErrResumableDisabled is returned when upload spooling is off.
+	ErrResumableDisabled = errors.New("resumable uploads need upload spooling (UPLOAD_SPOOL_KEYS)")
+
+	// ErrResumableNotFound is returned for an unknown session, or one
+	// bound to a different resume token.
+	ErrResumableNotFound = errors.New("resumable upload not found")
+
+	// ErrResumableOffset is returned for a chunk that does not start where
+	// the previous one ended.
+	ErrResumableOffset = errors.New("chunk offset does not match bytes received")
+
+	// ErrResumableBusy is returned while another request is writing to or
+	// completing the session, and for chunks sent after completion.
+	ErrResumableBusy = errors.New("resumable upload is busy")
+
+	// ErrResumableIncomplete is returned when completing a session that
+	// has not received the whole file.
+	ErrResumableIncomplete = errors.New("resumable upload is incomplete")
+)
+
+// ResumableRequest describes the file a resumable upload will carry.
+type ResumableRequest struct {
+	TableKey   string
+	FileName   string
+	Size       int64          // Exact size of the file in bytes
+	Mapping    map[string]int // Optional: expected column -> CSV index
+	Mode       UploadMode     // Empty uses the table's default
+	Duplicates DuplicatePolicy
+	Dates      DateOptions
+}
+
+// PendingUpload is the state of a resumable upload session.
+type PendingUpload struct {
+	SessionID string    `json:"session_id"`
+	TableKey  string    `json:"table_key"`
+	FileName  string    `json:"file_name"`
+	Size      int64     `json:"size"`
+	Received  int64     `json:"received"`   // Bytes stored; the next chunk starts here
+	ChunkSize int64     `json:"chunk_size"` // Largest chunk accepted
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set once completed; follow the upload's progress from here
+	UploadID  string   `json:"upload_id,omitempty"`
+	BatchID   string   `json:"batch_id,omitempty"`   // Zip archives only
+	UploadIDs []string `json:"upload_ids,omitempty"` // Zip archives only
+}
+
+// completed reports whether processing has started.
+func (p *PendingUpload) completed() bool {
+	return p.UploadID != "" || p.BatchID != ""
+}
+
+// resumableSession is one resumable upload.
+type resumableSession struct {
+	tokenHash [sha256.Size]byte
+
+	mu      sync.Mutex
+	info    PendingUpload
+	req     ResumableRequest
+	chunks  []string // Spool IDs, in file order
+	busy    bool     // A chunk is being written or the upload is starting
+	removed bool     // Discarded; late requests must not touch the spool
+}
+
+// resumableSessions tracks resumable upload sessions by ID.
+type resumableSessions struct {
+	mu   sync.Mutex
+	byID map[string]*resumableSession
+}
+
+// NewResumeToken returns a random resume token for clients that do not
+// supply their own.
+func NewResumeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate resume token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validResumeToken reports whether token is long enough to be unguessable
+// and uses only URL-safe characters.
+func validResumeToken(token string) bool {
+	if len(token) < minResumeTokenLen || len(token) > maxResumeTokenLen {
+		return false
+	}
+	for _, c := range token {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// CreateResumableUpload opens a resumable upload session for a file, bound
+// to token. The table, mode, duplicate policy and size are checked up
+// front, so a file that would be rejected is rejected before any chunk is
+// sent.
+func (s *Service) CreateResumableUpload(ctx context.Context, token string, req ResumableRequest) (*PendingUpload, error) {
+	if s.spool == nil {
+		return nil, ErrResumableDisabled
+	}
+	if !validResumeToken(token) {
+		return nil, fmt.Errorf("invalid resume token (want %d-%d letters, digits, - or _)", minResumeTokenLen, maxResumeTokenLen)
+	}
+
+	def, ok := Get(req.TableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", req.TableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	mode, err := resolveUploadMode(def, req.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := resolveDuplicatePolicy(def, mode, req.Duplicates); err != nil {
+		return nil, err
+	}
+	if _, err := req.Dates.validated(); err != nil {
+		return nil, err
+	}
+	if req.FileName == "" {
+		return nil, fmt.Errorf("missing file name")
+	}
+	if req.Size <= 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if maxSize := s.cfg.Upload.MaxFileSize; maxSize > 0 && req.Size > maxSize {
+		return nil, fmt.Errorf("file too large (max %d bytes)", maxSize)
+	}
+	if err := s.checkUploadLimits(ctx, def, req.Size); err != nil {
+		return nil, err
+	}
+
+	s.pruneResumable()
+
+	hash := sha256.Sum256([]byte(token))
+	now := time.Now()
+	sess := &resumableSession{
+		tokenHash: hash,
+		req:       req,
+		info: PendingUpload{
+			SessionID: uuid.New().String(),
+			TableKey:  req.TableKey,
+			FileName:  req.FileName,
+			Size:      req.Size,
+			ChunkSize: s.cfg.Upload.ResumableChunkSize,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	s.resumables.mu.Lock()
+	defer s.resumables.mu.Unlock()
+	pending := 0
+	for _, other := range s.resumables.byID {
+		other.mu.Lock()
+		if other.tokenHash == hash && !other.info.completed() {
+			pending++
+		}
+		other.mu.Unlock()
+	}
+	if pending >= maxPendingPerToken {
+		return nil, fmt.Errorf("too many unfinished resumable uploads (max %d); finish or cancel one first", maxPendingPerToken)
+	}
+	if s.resumables.byID == nil {
+		s.resumables.byID = make(map[string]*resumableSession)
+	}
+	s.resumables.byID[sess.info.SessionID] = sess
+
+	info := sess.info
+	return &info, nil
+}
+
+// lookupResumable returns the session with id if it is bound to token.
+func (s *Service) lookupResumable(id, token string) (*resumableSession, error) {
+	s.resumables.mu.Lock()
+	sess, ok := s.resumables.byID[id]
+	s.resumables.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(token))
+	if !ok || subtle.ConstantTimeCompare(sess.tokenHash[:], hash[:]) != 1 {
+		return nil, ErrResumableNotFound
+	}
+	return sess, nil
+}
+
+// GetResumableUpload returns a session's state, e.g. to find where to
+// resume after a failed chunk.
+func (s *Service) GetResumableUpload(id, token string) (*PendingUpload, error) {
+	sess, err := s.lookupResumable(id, token)
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	info := sess.info
+	return &info, nil
+}
+
+// AppendResumableChunk stores the next chunk of a session's file. offset
+// must equal the bytes received so far; a chunk may be at most ChunkSize
+// bytes and must not run past the file's declared size.
+func (s *Service) AppendResumableChunk(id, token string, offset int64, r io.Reader) (*PendingUpload, error) {
+	sess, err := s.lookupResumable(id, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	switch {
+	case sess.removed:
+		sess.mu.Unlock()
+		return nil, ErrResumableNotFound
+	case sess.info.completed():
+		sess.mu.Unlock()
+		return nil, fmt.Errorf("%w: already completed", ErrResumableBusy)
+	case sess.busy:
+		sess.mu.Unlock()
+		return nil, ErrResumableBusy
+	case offset != sess.info.Received:
+		received := sess.info.Received
+		sess.mu.Unlock()
+		return nil, fmt.Errorf("%w: got %d, have %d", ErrResumableOffset, offset, received)
+	}
+	limit := sess.info.Size - sess.info.Received
+	if chunk := sess.info.ChunkSize; chunk > 0 && chunk < limit {
+		limit = chunk
+	}
+	sess.busy = true
+	sess.mu.Unlock()
+
+	// One byte past the limit tells an oversized chunk from an exact one
+	spoolID, n, err := s.spool.Write(io.LimitReader(r, limit+1))
+	if err == nil && (n == 0 || n > limit) {
+		s.spool.Remove(spoolID)
+		if n == 0 {
+			err = fmt.Errorf("empty chunk")
+		} else {
+			err = fmt.Errorf("chunk too large (max %d bytes here)", limit)
+		}
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.busy = false
+	if err != nil {
+		return nil, err
+	}
+	if sess.removed {
+		// Cancelled or expired while the chunk was being written
+		s.spool.Remove(spoolID)
+		return nil, ErrResumableNotFound
+	}
+	sess.chunks = append(sess.chunks, spoolID)
+	sess.info.Received += n
+	sess.info.UpdatedAt = time.Now()
+	info := sess.info
+	return &info, nil
+}
+
+// CompleteResumableUpload starts processing a session whose file has fully
+// arrived, as StartUploadStreaming does (or StartZipUpload, for a zip
+// archive). Completing a session again returns the upload already started,
+// so a client that lost the first response can simply retry.
+func (s *Service) CompleteResumableUpload(ctx context.Context, id, token string) (*PendingUpload, error) {
+	sess, err := s.lookupResumable(id, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	switch {
+	case sess.removed:
+		sess.mu.Unlock()
+		return nil, ErrResumableNotFound
+	case sess.info.completed():
+		info := sess.info
+		sess.mu.Unlock()
+		return &info, nil
+	case sess.busy:
+		sess.mu.Unlock()
+		return nil, ErrResumableBusy
+	case sess.info.Received < sess.info.Size:
+		received, size := sess.info.Received, sess.info.Size
+		sess.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d of %d bytes received", ErrResumableIncomplete, received, size)
+	}
+	sess.busy = true
+	chunks := sess.chunks
+	req := sess.req
+	sess.mu.Unlock()
+
+	var uploadID, batchID string
+	var uploadIDs []string
+	reader, err := s.openResumableChunks(chunks)
+	if err == nil {
+		// Like a form upload, a zip archive is expanded into a batch
+		head, _ := reader.Peek(len(zipMagic))
+		if IsZipArchive(req.FileName, head) {
+			batchID, uploadIDs, err = s.StartZipUpload(ctx, req.TableKey, reader, req.Mapping, req.Mode, req.Duplicates, req.Dates)
+			if err == nil {
+				reader.Close() // Read into memory; the chunks are no longer needed
+			}
+		} else {
+			uploadID, err = s.StartUploadStreaming(ctx, req.TableKey, req.FileName, reader, req.Size, req.Mapping, req.Mode, req.Duplicates, req.Dates)
+		}
+		if err != nil {
+			// Keep the chunks so the client can retry, e.g. after ErrTooManyUploads
+			reader.closeFiles()
+		}
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.busy = false
+	if err != nil {
+		return nil, err
+	}
+	sess.chunks = nil // Owned by the upload now
+	sess.info.UploadID = uploadID
+	sess.info.BatchID = batchID
+	sess.info.UploadIDs = uploadIDs
+	sess.info.UpdatedAt = time.Now()
+	info := sess.info
+	return &info, nil
+}
+
+// CancelResumableUpload discards a session and its chunks. A completed
+// session is only dropped from the pending list; cancel the upload itself
+// with CancelUpload.
+func (s *Service) CancelResumableUpload(id, token string) error {
+	sess, err := s.lookupResumable(id, token)
+	if err != nil {
+		return err
+	}
+
+	sess.mu.Lock()
+	if sess.busy && !sess.info.completed() {
+		sess.mu.Unlock()
+		return ErrResumableBusy
+	}
+	sess.mu.Unlock()
+
+	s.resumables.mu.Lock()
+	delete(s.resumables.byID, id)
+	s.resumables.mu.Unlock()
+	s.discardResumable(sess)
+	return nil
+}
+
+// PendingUploads returns the sessions bound to token, oldest first:
+// unfinished ones to resume, and completed ones whose upload is still
+// tracked, to reattach to.
+func (s *Service) PendingUploads(token string) []PendingUpload {
+	s.pruneResumable()
+
+	hash := sha256.Sum256([]byte(token))
+	uploads := []PendingUpload{}
+
+	s.resumables.mu.Lock()
+	for _, sess := range s.resumables.byID {
+		if subtle.ConstantTimeCompare(sess.tokenHash[:], hash[:]) != 1 {
+			continue
+		}
+		sess.mu.Lock()
+		uploads = append(uploads, sess.info)
+		sess.mu.Unlock()
+	}
+	s.resumables.mu.Unlock()
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].CreatedAt.Before(uploads[j].CreatedAt)
+	})
+	return uploads
+}
+
+// pruneResumable drops unfinished sessions idle for longer than
+// UPLOAD_RESUMABLE_TTL and completed sessions whose uploads are no longer
+// tracked.
+func (s *Service) pruneResumable() {
+	ttl := s.cfg.Upload.ResumableTTL
+	now := time.Now()
+
+	var expired []*resumableSession
+	s.resumables.mu.Lock()
+	for id, sess := range s.resumables.byID {
+		sess.mu.Lock()
+		var drop bool
+		switch {
+		case sess.busy:
+		case sess.info.completed():
+			drop = !s.uploadsTracked(sess.info)
+		default:
+			drop = ttl > 0 && now.Sub(sess.info.UpdatedAt) > ttl
+		}
+		sess.mu.Unlock()
+		if drop {
+			delete(s.resumables.byID, id)
+			expired = append(expired, sess)
+		}
+	}
+	s.resumables.mu.Unlock()
+
+	for _, sess := range expired {
+		s.discardResumable(sess)
+	}
+}
+
+// uploadsTracked reports whether any upload started for a completed
+// session is still in memory.
+func (s *Service) uploadsTracked(info PendingUpload) bool {
+	ids := info.UploadIDs
+	if info.UploadID != "" {
+		ids = []string{info.UploadID}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range ids {
+		if _, ok := s.uploads[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// discardResumable deletes a removed session's chunks.
+func (s *Service) discardResumable(sess *resumableSession) {
+	sess.mu.Lock()
+	chunks := sess.chunks
+	sess.chunks = nil
+	sess.removed = true
+	sess.mu.Unlock()
+
+	for _, id := range chunks {
+		s.spool.Remove(id)
+	}
+}
+
+// openResumableChunks returns a reader over a session's chunks in order.
+// Closing it deletes the chunks.
+func (s *Service) openResumableChunks(chunks []string) (*resumableReader, error) {
+	rr := &resumableReader{spool: s.spool, chunks: chunks}
+	readers := make([]io.Reader, 0, len(chunks))
+	for _, id := range chunks {
+		f, err := s.spool.OpenRetained(id)
+		if err != nil {
+			rr.closeFiles()
+			return nil, err
+		}
+		rr.files = append(rr.files, f)
+		readers = append(readers, f)
+	}
+	rr.Reader = bufio.NewReader(io.MultiReader(readers...))
+	return rr, nil
+}
+
+// resumableReader reads a session's chunks as one file.
+type resumableReader struct {
+	*bufio.Reader
+	spool  *Spool
+	chunks []string
+	files  []io.Closer
+	once   sync.Once
+}
+
+// closeFiles closes the chunk files, leaving them on disk.
+func (r *resumableReader) closeFiles() {
+	for _, f := range r.files {
+		f.Close()
+	}
+	r.files = nil
+}
+
+// Close closes and deletes the chunks. Safe to call more than once.
+func (r *resumableReader) Close() error {
+	r.once.Do(func() {
+		r.closeFiles()
+		for _, id := range r.chunks {
+			r.spool.Remove(id)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+const testResumeToken = "browser-token-0123456789"
+
+func newResumableTestService(t *testing.T) *Service {
+	t.Helper()
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "resumable_orders"},
+		FieldSpecs: []FieldSpec{{Name: "Order ID", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "resumable_orders")
+		registryMu.Unlock()
+	})
+
+	sp, err := NewSpool(t.TempDir(), []string{testSpoolKey("k1", 1)})
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	return &Service{
+		cfg: &config.Config{Upload: config.UploadConfig{
+			MaxFileSize:        1000,
+			ResumableChunkSize: 4,
+			ResumableTTL:       time.Hour,
+		}},
+		spool:   sp,
+		uploads: make(map[string]*activeUpload),
+	}
+}
+
+func spoolArtifacts(t *testing.T, s *Service) int {
+	t.Helper()
+	report, err := s.spool.Report()
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	return len(report.Artifacts)
+}
+
+func TestCreateResumableUpload_Validation(t *testing.T) {
+	s := newResumableTestService(t)
+	ctx := context.Background()
+	valid := ResumableRequest{TableKey: "resumable_orders", FileName: "a.csv", Size: 10}
+
+	tests := []struct {
+		name  string
+		token string
+		edit  func(*ResumableRequest)
+		want  string
+	}{
+		{"short token", "short", nil, "invalid resume token"},
+		{"bad token chars", "browser token 0123456789", nil, "invalid resume token"},
+		{"unknown table", testResumeToken, func(r *ResumableRequest) { r.TableKey = "nope" }, "unknown table"},
+		{"bad mode", testResumeToken, func(r *ResumableRequest) { r.Mode = "merge" }, "mode"},
+		{"no name", testResumeToken, func(r *ResumableRequest) { r.FileName = "" }, "missing file name"},
+		{"empty", testResumeToken, func(r *ResumableRequest) { r.Size = 0 }, "file is empty"},
+		{"too large", testResumeToken, func(r *ResumableRequest) { r.Size = 1001 }, "file too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			if tt.edit != nil {
+				tt.edit(&req)
+			}
+			_, err := s.CreateResumableUpload(ctx, tt.token, req)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&Service{cfg: &config.Config{}}).CreateResumableUpload(ctx, testResumeToken, valid); !errors.Is(err, ErrResumableDisabled) {
+		t.Errorf("without spool: err = %v, want ErrResumableDisabled", err)
+	}
+
+	for i := 0; i < maxPendingPerToken; i++ {
+		if _, err := s.CreateResumableUpload(ctx, testResumeToken, valid); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if _, err := s.CreateResumableUpload(ctx, testResumeToken, valid); err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Errorf("over cap: err = %v", err)
+	}
+}
+
+func TestAppendResumableChunk(t *testing.T) {
+	s := newResumableTestService(t)
+	data := "id\n1\n2\n3"
+
+	p, err := s.CreateResumableUpload(context.Background(), testResumeToken, ResumableRequest{TableKey: "resumable_orders", FileName: "a.csv", Size: int64(len(data))})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	id := p.SessionID
+
+	if _, err := s.AppendResumableChunk(id, "other-token-0123456789", 0, strings.NewReader(data[:4])); !errors.Is(err, ErrResumableNotFound) {
+		t.Errorf("wrong token: err = %v, want ErrResumableNotFound", err)
+	}
+	if _, err := s.AppendResumableChunk(id, testResumeToken, 0, strings.NewReader(data[:5])); err == nil || !strings.Contains(err.Error(), "chunk too large") {
+		t.Errorf("oversized chunk: err = %v", err)
+	}
+	if _, err := s.AppendResumableChunk(id, testResumeToken, 0, strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "empty chunk") {
+		t.Errorf("empty chunk: err = %v", err)
+	}
+	if n := spoolArtifacts(t, s); n != 0 {
+		t.Errorf("rejected chunks left %d spool files", n)
+	}
+
+	if p, err = s.AppendResumableChunk(id, testResumeToken, 0, strings.NewReader(data[:4])); err != nil || p.Received != 4 {
+		t.Fatalf("chunk 1: %+v, %v", p, err)
+	}
+	// A chunk resent after a lost response is rejected; the client
+	// resumes from Received
+	if _, err := s.AppendResumableChunk(id, testResumeToken, 0, strings.NewReader(data[:4])); !errors.Is(err, ErrResumableOffset) {
+		t.Errorf("stale offset: err = %v, want ErrResumableOffset", err)
+	}
+	if _, err := s.CompleteResumableUpload(context.Background(), id, testResumeToken); !errors.Is(err, ErrResumableIncomplete) {
+		t.Errorf("complete early: err = %v, want ErrResumableIncomplete", err)
+	}
+	// The last chunk may not run past the declared size
+	if _, err := s.AppendResumableChunk(id, testResumeToken, 4, strings.NewReader(data[4:]+"4")); err == nil {
+		t.Error("chunk past size accepted")
+	}
+	if p, err = s.AppendResumableChunk(id, testResumeToken, 4, strings.NewReader(data[4:])); err != nil || p.Received != int64(len(data)) {
+		t.Fatalf("chunk 2: %+v, %v", p, err)
+	}
+
+	s.resumables.mu.Lock()
+	chunks := s.resumables.byID[id].chunks
+	s.resumables.mu.Unlock()
+	r, err := s.openResumableChunks(chunks)
+	if err != nil {
+		t.Fatalf("openResumableChunks: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != data {
+		t.Errorf("reassembled %q, want %q", got, data)
+	}
+	if n := spoolArtifacts(t, s); n != 0 {
+		t.Errorf("Close left %d spool files", n)
+	}
+}
+
+func TestPendingUploads(t *testing.T) {
+	s := newResumableTestService(t)
+	ctx := context.Background()
+	req := ResumableRequest{TableKey: "resumable_orders", FileName: "a.csv", Size: 8}
+
+	first, _ := s.CreateResumableUpload(ctx, testResumeToken, req)
+	second, _ := s.CreateResumableUpload(ctx, testResumeToken, req)
+	if _, err := s.CreateResumableUpload(ctx, "other-token-0123456789", req); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	s.AppendResumableChunk(second.SessionID, testResumeToken, 0, bytes.NewReader([]byte("id\n1")))
+
+	pending := s.PendingUploads(testResumeToken)
+	if len(pending) != 2 || pending[0].SessionID != first.SessionID || pending[1].Received != 4 {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if got := s.PendingUploads("unknown-token-0123456789"); len(got) != 0 {
+		t.Errorf("unknown token: %+v", got)
+	}
+
+	// Cancelling discards the session and its chunks
+	if err := s.CancelResumableUpload(second.SessionID, testResumeToken); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if n := spoolArtifacts(t, s); n != 0 {
+		t.Errorf("cancel left %d spool files", n)
+	}
+	if _, err := s.GetResumableUpload(second.SessionID, testResumeToken); !errors.Is(err, ErrResumableNotFound) {
+		t.Errorf("get after cancel: err = %v", err)
+	}
+
+	// Idle sessions expire
+	s.resumables.mu.Lock()
+	s.resumables.byID[first.SessionID].info.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	s.resumables.mu.Unlock()
+	if got := s.PendingUploads(testResumeToken); len(got) != 0 {
+		t.Errorf("expired session still pending: %+v", got)
+	}
+
+	// A completed session stays listed while its upload is tracked
+	third, _ := s.CreateResumableUpload(ctx, testResumeToken, req)
+	s.resumables.mu.Lock()
+	s.resumables.byID[third.SessionID].info.UploadID = "u1"
+	s.resumables.mu.Unlock()
+	s.uploads["u1"] = &activeUpload{}
+	if got := s.PendingUploads(testResumeToken); len(got) != 1 || got[0].UploadID != "u1" {
+		t.Errorf("tracked upload: %+v", got)
+	}
+	delete(s.uploads, "u1")
+	if got := s.PendingUploads(testResumeToken); len(got) != 0 {
+		t.Errorf("finished upload still pending: %+v", got)
+	}
+}
@@ -0,0 +1,314 @@
+package core
+
+// retention_archive.go keeps a copy of rows before the retention job purges
+// them, so a legal hold or a late audit can still reach removed data for a
+// grace period afterwards.
+//
+// When ARCHIVE_RETENTION_EXPORT_DIR is set, each purge first writes the rows
+// it is about to delete to a retention archive: a gzipped NDJSON file (one
+// JSON object per row, every column included) plus a JSON manifest with the
+// row count, the purge cutoff and the SHA-256 of the file as stored. A
+// table is only purged once its archive is safely on disk; if writing it
+// fails the rows stay where they are until the next run.
+//
+// Archives are deleted ARCHIVE_RETENTION_EXPORT_GRACE_DAYS after they were
+// written. They are listed and downloaded through the admin API, and every
+// download is recorded in the audit log.
+//
+// File layout (per archive ID):
+//
+//	<id>.ndjson.gz  the rows
+//	<id>.json       the manifest, written last: an archive without one is
+//	                incomplete and removed on startup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	retentionDataExt     = ".ndjson.gz"
+	retentionManifestExt = ".json"
+)
+
+// DefaultRetentionGraceDays is how long retention archives are kept when
+// ARCHIVE_RETENTION_EXPORT_GRACE_DAYS is 0.
+const DefaultRetentionGraceDays = 90
+
+// ErrRetentionArchiveNotFound is returned for an unknown archive ID.
+var ErrRetentionArchiveNotFound = errors.New("retention archive not found")
+
+// RetentionArchiveKind is what a retention archive holds.
+type RetentionArchiveKind string
+
+const (
+	RetentionRecycleBin   RetentionArchiveKind = "recycle_bin"   // Rows purged from a table's recycle bin
+	RetentionAuditArchive RetentionArchiveKind = "audit_archive" // Archived audit entries past ARCHIVE_RETENTION_YEARS
+)
+
+// RetentionArchive is the manifest of a retention archive.
+type RetentionArchive struct {
+	ID        string               `json:"id"`
+	Kind      RetentionArchiveKind `json:"kind"`
+	TableKey  string               `json:"tableKey"` // Table purged, or audit_log_archive
+	Rows      int64                `json:"rows"`
+	Cutoff    time.Time            `json:"cutoff"` // Rows deleted (audit entries: created) before this were purged
+	CreatedAt time.Time            `json:"createdAt"`
+	ExpiresAt time.Time            `json:"expiresAt"`
+	FileName  string               `json:"fileName"` // Name offered for download
+	Size      int64                `json:"size"`     // Bytes of the gzipped file
+	SHA256    string               `json:"sha256"`   // Hex SHA-256 of the gzipped file
+}
+
+// RetentionStore holds retention archives in a directory.
+type RetentionStore struct {
+	dir   string
+	grace time.Duration
+}
+
+// NewRetentionStore opens the archive directory dir, creating it if needed.
+// Archives are kept graceDays days (DefaultRetentionGraceDays if 0).
+// Incomplete archives left by a previous run are removed.
+func NewRetentionStore(dir string, graceDays int) (*RetentionStore, error) {
+	if graceDays <= 0 {
+		graceDays = DefaultRetentionGraceDays
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create retention archive directory: %w", err)
+	}
+	st := &RetentionStore{dir: dir, grace: time.Duration(graceDays) * 24 * time.Hour}
+
+	partial, _ := filepath.Glob(filepath.Join(dir, "*"+retentionDataExt+".tmp"))
+	for _, path := range partial {
+		os.Remove(path)
+	}
+	data, _ := filepath.Glob(filepath.Join(dir, "*"+retentionDataExt))
+	for _, path := range data {
+		id := strings.TrimSuffix(filepath.Base(path), retentionDataExt)
+		if _, err := os.Stat(st.path(id, retentionManifestExt)); errors.Is(err, os.ErrNotExist) {
+			os.Remove(path)
+		}
+	}
+	return st, nil
+}
+
+// RetentionArchives returns the retention archive store, or nil if
+// ARCHIVE_RETENTION_EXPORT_DIR is unset.
+func (s *Service) RetentionArchives() *RetentionStore {
+	return s.retention
+}
+
+// Create writes a retention archive: write receives the uncompressed
+// NDJSON stream and returns the rows written. meta's ID, times, size and
+// checksum are filled in. An archive of zero rows is discarded and
+// returns a nil manifest.
+func (st *RetentionStore) Create(meta RetentionArchive, write func(io.Writer) (int64, error)) (*RetentionArchive, error) {
+	id, err := newSpoolID()
+	if err != nil {
+		return nil, err
+	}
+	dataPath := st.path(id, retentionDataExt)
+	tmp := dataPath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create retention archive: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	counted := &countingWriter{w: io.MultiWriter(f, hash)}
+	zw := gzip.NewWriter(counted)
+	rows, err := write(zw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write retention archive: %w", err)
+	}
+	if rows == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	meta.ID = id
+	meta.Rows = rows
+	meta.CreatedAt = now
+	meta.ExpiresAt = now.Add(st.grace)
+	meta.Size = counted.n
+	meta.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	meta.FileName = fmt.Sprintf("%s_%s%s", meta.TableKey, now.Format("20060102T150405Z"), retentionDataExt)
+
+	if err := os.Rename(tmp, dataPath); err != nil {
+		return nil, fmt.Errorf("store retention archive: %w", err)
+	}
+	manifest, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = os.WriteFile(st.path(id, retentionManifestExt), manifest, 0o600)
+	}
+	if err != nil {
+		os.Remove(dataPath)
+		return nil, fmt.Errorf("write retention archive manifest: %w", err)
+	}
+	return &meta, nil
+}
+
+// List returns the manifests of all archives, newest first. kind and
+// tableKey filter them when non-empty.
+func (st *RetentionStore) List(kind RetentionArchiveKind, tableKey string) ([]RetentionArchive, error) {
+	paths, err := filepath.Glob(filepath.Join(st.dir, "*"+retentionManifestExt))
+	if err != nil {
+		return nil, err
+	}
+	archives := []RetentionArchive{}
+	for _, path := range paths {
+		a, err := st.Get(strings.TrimSuffix(filepath.Base(path), retentionManifestExt))
+		if err != nil {
+			slog.Warn("unreadable retention archive manifest", "path", path, "error", err)
+			continue
+		}
+		if (kind == "" || a.Kind == kind) && (tableKey == "" || a.TableKey == tableKey) {
+			archives = append(archives, *a)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].CreatedAt.After(archives[j].CreatedAt)
+	})
+	return archives, nil
+}
+
+// Get returns the manifest of archive id.
+func (st *RetentionStore) Get(id string) (*RetentionArchive, error) {
+	if !validSpoolID(id) {
+		return nil, ErrRetentionArchiveNotFound
+	}
+	data, err := os.ReadFile(st.path(id, retentionManifestExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRetentionArchiveNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read retention archive manifest: %w", err)
+	}
+	var a RetentionArchive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("parse retention archive manifest: %w", err)
+	}
+	return &a, nil
+}
+
+// Open returns the gzipped NDJSON file of archive id and its manifest.
+func (st *RetentionStore) Open(id string) (io.ReadCloser, *RetentionArchive, error) {
+	a, err := st.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(st.path(id, retentionDataExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrRetentionArchiveNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("open retention archive: %w", err)
+	}
+	return f, a, nil
+}
+
+// Expire deletes archives whose grace period ended before now and returns
+// how many were deleted.
+func (st *RetentionStore) Expire(now time.Time) (int, error) {
+	archives, err := st.List("", "")
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	var errs []error
+	for _, a := range archives {
+		if now.Before(a.ExpiresAt) {
+			continue
+		}
+		// The manifest goes first, so a failure leaves an incomplete
+		// archive that is cleaned up on the next start
+		if err := os.Remove(st.path(a.ID, retentionManifestExt)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		os.Remove(st.path(a.ID, retentionDataExt))
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+func (st *RetentionStore) path(id, ext string) string {
+	return filepath.Join(st.dir, id+ext)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// archiveRows writes the rows of table matching where (with args) to a
+// retention archive, one JSON object per row. It returns nil if no rows
+// match. Rows are read with the same condition the purge then deletes by,
+// so everything purged is in the archive.
+func (s *Service) archiveRows(ctx context.Context, kind RetentionArchiveKind, table, where string, cutoff time.Time, args ...any) (*RetentionArchive, error) {
+	meta := RetentionArchive{Kind: kind, TableKey: table, Cutoff: cutoff.UTC()}
+	return s.retention.Create(meta, func(w io.Writer) (int64, error) {
+		query := fmt.Sprintf("SELECT row_to_json(t)::text FROM %s t WHERE %s", quoteIdentifier(table), where)
+		rows, err := s.pool.Query(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+
+		var n int64
+		var line string
+		for rows.Next() {
+			if err := rows.Scan(&line); err != nil {
+				return n, err
+			}
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, rows.Err()
+	})
+}
+
+// runRetentionExpiry deletes retention archives past their grace period
+// for the scheduler and returns how many it deleted.
+func (s *Service) runRetentionExpiry() (int, error) {
+	if s.retention == nil {
+		return 0, nil
+	}
+	deleted, err := s.retention.Expire(time.Now())
+	if err != nil {
+		slog.Error("retention archive expiry failed", "archives_deleted", deleted, "error", err)
+		return deleted, err
+	}
+	if deleted > 0 {
+		slog.Info("deleted expired retention archives", "archives_deleted", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRows(lines ...string) func(io.Writer) (int64, error) {
+	return func(w io.Writer) (int64, error) {
+		for _, l := range lines {
+			if _, err := io.WriteString(w, l+"\n"); err != nil {
+				return 0, err
+			}
+		}
+		return int64(len(lines)), nil
+	}
+}
+
+func TestRetentionStore_CreateAndOpen(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewRetentionStore(dir, 0)
+	if err != nil {
+		t.Fatalf("NewRetentionStore: %v", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+	a, err := st.Create(RetentionArchive{Kind: RetentionRecycleBin, TableKey: "vendor_bills", Cutoff: cutoff},
+		writeRows(`{"id":1}`, `{"id":2}`))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if a.Rows != 2 || a.TableKey != "vendor_bills" || a.Kind != RetentionRecycleBin {
+		t.Errorf("archive = %+v", a)
+	}
+	if got := a.ExpiresAt.Sub(a.CreatedAt); got != DefaultRetentionGraceDays*24*time.Hour {
+		t.Errorf("grace = %v, want %d days", got, DefaultRetentionGraceDays)
+	}
+
+	f, got, err := st.Open(a.ID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	sum := sha256.Sum256(raw)
+	if hex.EncodeToString(sum[:]) != got.SHA256 || int64(len(raw)) != got.Size {
+		t.Errorf("checksum/size = %s/%d, want %s/%d", got.SHA256, got.Size, hex.EncodeToString(sum[:]), len(raw))
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRetentionStore_ZeroRows(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewRetentionStore(dir, 7)
+	if err != nil {
+		t.Fatalf("NewRetentionStore: %v", err)
+	}
+	a, err := st.Create(RetentionArchive{Kind: RetentionRecycleBin, TableKey: "t"}, writeRows())
+	if a != nil || err != nil {
+		t.Fatalf("Create() = %v, %v; want nil, nil", a, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("directory has %d entries, want none", len(entries))
+	}
+}
+
+func TestRetentionStore_WriteError(t *testing.T) {
+	st, err := NewRetentionStore(t.TempDir(), 7)
+	if err != nil {
+		t.Fatalf("NewRetentionStore: %v", err)
+	}
+	boom := errors.New("boom")
+	_, err = st.Create(RetentionArchive{TableKey: "t"}, func(io.Writer) (int64, error) { return 1, boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("Create() error = %v, want boom", err)
+	}
+	if list, _ := st.List("", ""); len(list) != 0 {
+		t.Errorf("List() = %+v, want none", list)
+	}
+}
+
+func TestRetentionStore_ListGetExpire(t *testing.T) {
+	st, err := NewRetentionStore(t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("NewRetentionStore: %v", err)
+	}
+	bills, _ := st.Create(RetentionArchive{Kind: RetentionRecycleBin, TableKey: "vendor_bills"}, writeRows("{}"))
+	audit, _ := st.Create(RetentionArchive{Kind: RetentionAuditArchive, TableKey: "audit_log_archive"}, writeRows("{}"))
+
+	if list, _ := st.List("", ""); len(list) != 2 {
+		t.Errorf("List() = %d archives, want 2", len(list))
+	}
+	if list, _ := st.List(RetentionAuditArchive, ""); len(list) != 1 || list[0].ID != audit.ID {
+		t.Errorf("List(audit_archive) = %+v", list)
+	}
+	if list, _ := st.List("", "vendor_bills"); len(list) != 1 || list[0].ID != bills.ID {
+		t.Errorf("List(vendor_bills) = %+v", list)
+	}
+
+	for _, id := range []string{"00000000000000000000000000000000", "../etc/passwd", ""} {
+		if _, err := st.Get(id); !errors.Is(err, ErrRetentionArchiveNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrRetentionArchiveNotFound", id, err)
+		}
+	}
+
+	if n, err := st.Expire(time.Now()); n != 0 || err != nil {
+		t.Errorf("Expire(now) = %d, %v; want 0", n, err)
+	}
+	if n, err := st.Expire(time.Now().Add(48 * time.Hour)); n != 2 || err != nil {
+		t.Errorf("Expire(+2d) = %d, %v; want 2", n, err)
+	}
+	if _, err := st.Get(bills.ID); !errors.Is(err, ErrRetentionArchiveNotFound) {
+		t.Errorf("Get() after expiry error = %v, want ErrRetentionArchiveNotFound", err)
+	}
+}
+
+func TestNewRetentionStore_RemovesIncomplete(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewRetentionStore(dir, 1)
+	if err != nil {
+		t.Fatalf("NewRetentionStore: %v", err)
+	}
+	kept, _ := st.Create(RetentionArchive{Kind: RetentionRecycleBin, TableKey: "t"}, writeRows("{}"))
+
+	// A data file without a manifest and a leftover temp file
+	orphan := filepath.Join(dir, "0123456789abcdef0123456789abcdef"+retentionDataExt)
+	for _, path := range []string{orphan, orphan + ".tmp"} {
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := NewRetentionStore(dir, 1); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	for _, path := range []string{orphan, orphan + ".tmp"} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s still exists", filepath.Base(path))
+		}
+	}
+	if _, err := st.Get(kept.ID); err != nil {
+		t.Errorf("complete archive removed: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxRetryBackoff caps the exponential delay between batch retries.
+const maxRetryBackoff = 5 * time.Second
+
+// retryablePgCodes are PostgreSQL SQLSTATE codes for failures that are not
+// caused by the data itself, after which rolling back to a savepoint leaves
+// the transaction usable, so the statement may be repeated in it. Upload
+// transactions run at READ COMMITTED, where each statement takes a new
+// snapshot, so a repeated statement can succeed after a serialization
+// failure too.
+var retryablePgCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+}
+
+// txFailedPgCodes are PostgreSQL SQLSTATE codes for failures that end the
+// transaction: the server dropped or refused the connection, so nothing
+// more can be done in it.
+var txFailedPgCodes = map[string]bool{
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isRetryableError reports whether err is a transient database error worth
+// retrying within the same transaction: serialization failures, deadlocks
+// and lock timeouts. Constraint and data errors are never retryable, and neither are the
+// errors isTxFailedError reports.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && retryablePgCodes[pgErr.Code]
+}
+
+// isTxFailedError reports whether err leaves the transaction it happened
+// in unusable, though it was no fault of the data: connection resets and
+// server shutdowns. Repeating the statement in the same transaction can't
+// succeed, so the caller should give the transaction up.
+func isTxFailedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08 - Connection Exception
+		if len(pgErr.Code) == 5 && pgErr.Code[:2] == "08" {
+			return true
+		}
+		return txFailedPgCodes[pgErr.Code]
+	}
+
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryBackoff returns the delay before the given retry attempt (1-based),
+// doubling from base and capped at maxRetryBackoff.
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 || attempt <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return delay
+}
+
+// sleepContext waits for d or until ctx is done.
+// Returns false if the context was cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		txFailed  bool
+	}{
+		{"nil", nil, false, false},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true, false},
+		{"lock timeout", &pgconn.PgError{Code: "55P03"}, true, false},
+		{"wrapped deadlock", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "40P01"}), true, false},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true, false},
+		{"connection exception class", &pgconn.PgError{Code: "08006"}, false, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, false, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false, false},
+		{"invalid text", &pgconn.PgError{Code: "22P02"}, false, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, false, true},
+		{"context cancelled", context.Canceled, false, false},
+		{"plain error", errors.New("boom"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err); got != tt.retryable {
+				t.Errorf("isRetryableError(%v) = %v, want %v", tt.err, got, tt.retryable)
+			}
+			if got := isTxFailedError(tt.err); got != tt.txFailed {
+				t.Errorf("isTxFailedError(%v) = %v, want %v", tt.err, got, tt.txFailed)
+			}
+		})
+	}
+}
+
+// failingInsert returns a table whose inserts fail with errs in turn, then
+// succeed, and a count of the inserts tried.
+func failingInsert(errs ...error) (TableDefinition, *int) {
+	n := 0
+	return TableDefinition{
+		Info: TableInfo{Key: "retry_test"},
+		Insert: func(context.Context, DBTX, any) error {
+			n++
+			if n <= len(errs) {
+				return errs[n-1]
+			}
+			return nil
+		},
+	}, &n
+}
+
+// execTx is a pgx.Tx whose Exec always succeeds, for the savepoint
+// statements around an insert. Other methods are not implemented and
+// panic if called.
+type execTx struct {
+	pgx.Tx
+}
+
+func (execTx) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func TestInsertBatch_Retries(t *testing.T) {
+	s := &Service{cfg: &config.Config{Upload: config.UploadConfig{BatchRetries: 3}}}
+	batch := []validatedRow{{lineNum: 2, params: "a"}, {lineNum: 3, params: "b"}}
+
+	// Serialization failures, deadlocks and lock timeouts are undone by the
+	// savepoint, so the batch is repeated
+	for _, cause := range []error{&pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40P01"}, &pgconn.PgError{Code: "55P03"}} {
+		def, inserts := failingInsert(cause, cause)
+		var failedRows []FailedRow
+		n, retries, err := s.insertBatch(context.Background(), execTx{}, def, batch, &failedRows, "test.csv", nil)
+		if err != nil || n != 2 || retries != 2 || len(failedRows) != 0 {
+			t.Errorf("%v: inserted %d, retries %d, err %v", cause, n, retries, err)
+		}
+		if *inserts != 4 {
+			t.Errorf("%v: %d inserts, want 2 failed tries and 2 rows", cause, *inserts)
+		}
+	}
+
+	// Past BatchRetries the rows are tried one by one, and the one that
+	// still fails is reported
+	cause := &pgconn.PgError{Code: "40001"}
+	def, _ := failingInsert(cause, cause, cause, cause, cause)
+	var failedRows []FailedRow
+	n, retries, err := s.insertBatch(context.Background(), execTx{}, def, batch, &failedRows, "test.csv", nil)
+	if err != nil || n != 1 || retries != 3 || len(failedRows) != 1 || failedRows[0].LineNumber != 2 {
+		t.Errorf("exhausted: inserted %d, retries %d, failed rows %v, err %v", n, retries, failedRows, err)
+	}
+}
+
+func TestInsertBatch_TxFailed(t *testing.T) {
+	s := &Service{cfg: &config.Config{Upload: config.UploadConfig{BatchRetries: 3}}}
+	batch := []validatedRow{{lineNum: 2, params: "a"}, {lineNum: 3, params: "b"}}
+
+	// A dropped connection can't be retried in the same transaction, and
+	// isn't the rows' fault: the upload fails
+	for _, cause := range []error{&pgconn.PgError{Code: "08006"}, &pgconn.PgError{Code: "57P01"}, io.ErrUnexpectedEOF} {
+		def, inserts := failingInsert(cause, cause, cause, cause)
+		var failedRows []FailedRow
+		n, retries, err := s.insertBatch(context.Background(), execTx{}, def, batch, &failedRows, "test.csv", nil)
+		if !errors.Is(err, cause) || n != 0 || retries != 0 {
+			t.Errorf("%v: inserted %d, retries %d, err %v", cause, n, retries, err)
+		}
+		if *inserts != 1 || len(failedRows) != 0 {
+			t.Errorf("%v: %d inserts, failed rows %v", cause, *inserts, failedRows)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 0},
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{10, maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := retryBackoff(base, tt.attempt); got != tt.want {
+			t.Errorf("retryBackoff(%v, %d) = %v, want %v", base, tt.attempt, got, tt.want)
+		}
+	}
+
+	if got := retryBackoff(0, 3); got != 0 {
+		t.Errorf("retryBackoff with zero base = %v, want 0", got)
+	}
+}
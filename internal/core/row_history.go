@@ -0,0 +1,183 @@
+package core
+
+// row_history.go reads back what happened to one row: its insert from the
+// table view, cell edits, deletes and restores, from the live and the
+// archived audit log.
+//
+// Entries are recorded under the key a row had at the time, and an edit
+// to a key column gives the row a new key, recorded under the old one.
+// GetRowHistory follows those renames back from the key asked for, so the
+// history includes what happened to the row under its earlier keys. A key
+// that was given up and later reused by another row can't be told apart
+// from a rename, and that row's entries are included too.
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Row history settings
+const (
+	MaxRowHistoryEntries = 500 // Newest entries returned; older ones are left out
+	maxRowKeyChain       = 20  // Earlier keys followed back
+)
+
+// rowHistoryActions are the audit actions that change a single row.
+var rowHistoryActions = []string{string(ActionRowInsert), string(ActionCellEdit), string(ActionRowDelete), string(ActionRowRestore)}
+
+// RowHistory is what happened to one row, oldest first.
+type RowHistory struct {
+	TableKey  string       `json:"tableKey"`
+	RowKey    string       `json:"rowKey"`
+	Keys      []string     `json:"keys"` // Earlier keys of the row, newest first
+	Entries   []AuditEntry `json:"entries"`
+	Truncated bool         `json:"truncated,omitempty"` // Older entries left out
+}
+
+// keyChange is an edit that set a key column: the row had key From, and
+// Column was set to Value.
+type keyChange struct {
+	From   string
+	Column string
+	Value  string
+}
+
+// GetRowHistory returns the insert, cell edits, deletes and restores
+// recorded for the row of tableKey with key rowKey, including those under
+// its earlier keys, oldest first. Only the newest MaxRowHistoryEntries are returned.
+func (s *Service) GetRowHistory(ctx context.Context, tableKey, rowKey string) (*RowHistory, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	uniqueKey := def.Info.UniqueKey
+	if len(uniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
+	}
+
+	keys, err := s.rowKeyChain(ctx, tableKey, uniqueKey, rowKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+auditEntryColumns+` FROM (
+			SELECT `+auditEntryColumns+` FROM audit_log
+			WHERE table_key = $1 AND row_key = ANY($2) AND action = ANY($3)
+			UNION ALL
+			SELECT `+auditEntryColumns+` FROM audit_log_archive
+			WHERE table_key = $1 AND row_key = ANY($2) AND action = ANY($3)
+		) h
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`,
+		tableKey, keys, rowHistoryActions, MaxRowHistoryEntries+1,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query row history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanAuditLogRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan row history: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	history := &RowHistory{TableKey: tableKey, RowKey: rowKey, Keys: keys[1:]}
+	if len(entries) > MaxRowHistoryEntries {
+		entries = entries[:MaxRowHistoryEntries]
+		history.Truncated = true
+	}
+	slices.Reverse(entries)
+	history.Entries = entries
+	return history, nil
+}
+
+// rowKeyChain returns rowKey followed by the earlier keys of its row,
+// newest first, found from the edits that set a key column.
+func (s *Service) rowKeyChain(ctx context.Context, tableKey string, uniqueKey []string, rowKey string) ([]string, error) {
+	keys := []string{rowKey}
+	frontier := []string{rowKey}
+	for len(frontier) > 0 && len(keys) <= maxRowKeyChain {
+		var values []string
+		for _, k := range frontier {
+			values = append(values, strings.Split(k, "|")...)
+		}
+		changes, err := s.keyChangesTo(ctx, tableKey, values)
+		if err != nil {
+			return nil, err
+		}
+		frontier = s.earlierKeys(uniqueKey, frontier, keys, changes)
+		keys = append(keys, frontier...)
+	}
+	if len(keys) > maxRowKeyChain+1 {
+		keys = keys[:maxRowKeyChain+1]
+	}
+	return keys, nil
+}
+
+// keyChangesTo returns the cell edits, undos and redos of tableKey that
+// set a column to one of values.
+func (s *Service) keyChangesTo(ctx context.Context, tableKey string, values []string) ([]keyChange, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT row_key, column_name, new_value FROM audit_log
+		WHERE table_key = $1 AND action = ANY($2) AND new_value = ANY($3)
+		  AND row_key IS NOT NULL AND column_name IS NOT NULL
+		UNION ALL
+		SELECT row_key, column_name, new_value FROM audit_log_archive
+		WHERE table_key = $1 AND action = ANY($2) AND new_value = ANY($3)
+		  AND row_key IS NOT NULL AND column_name IS NOT NULL`,
+		tableKey, []string{string(ActionCellEdit), string(ActionRowRestore)}, values,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query key changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []keyChange
+	for rows.Next() {
+		var c keyChange
+		if err := rows.Scan(&c.From, &c.Column, &c.Value); err != nil {
+			return nil, fmt.Errorf("scan key change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// earlierKeys returns the keys changes renamed to one of targets, leaving
+// out those already in seen.
+func (s *Service) earlierKeys(uniqueKey, targets, seen []string, changes []keyChange) []string {
+	var found []string
+	for _, c := range changes {
+		if !isKeyColumn(uniqueKey, c.Column) || c.From == "" {
+			continue
+		}
+		to := s.buildNewCompositeKey(uniqueKey, c.From, c.Column, c.Value)
+		if to == c.From || !slices.Contains(targets, to) {
+			continue
+		}
+		if slices.Contains(seen, c.From) || slices.Contains(found, c.From) {
+			continue
+		}
+		found = append(found, c.From)
+	}
+	return found
+}
+
+// auditEntryColumns are the audit_log and audit_log_archive columns read
+// by scanAuditLogRow, in its order.
+const auditEntryColumns = `id, action, severity, table_key, user_id, user_email, user_name,
+	ip_address, user_agent, row_key, column_name, old_value, new_value,
+	row_data, rows_affected, upload_id, batch_id, related_audit_id, reason, created_at`
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEarlierKeys(t *testing.T) {
+	s := &Service{}
+	uniqueKey := []string{"Region", "Invoice"}
+
+	changes := []keyChange{
+		{From: "EU|100", Column: "Invoice", Value: "101"},  // Renamed to EU|101
+		{From: "US|101", Column: "region", Value: "EU"},    // Renamed to EU|101, case-insensitive column
+		{From: "EU|7", Column: "Amount", Value: "101"},     // Not a key column
+		{From: "EU|101", Column: "Invoice", Value: "101"},  // Unchanged key
+		{From: "EU|100", Column: "Invoice", Value: "101"},  // Same rename again
+		{From: "APAC|5", Column: "Invoice", Value: "101"},  // Renamed to APAC|101
+		{From: "EU|100", Column: "Invoice", Value: "EU"},   // Renamed to EU|EU
+		{From: "", Column: "Invoice", Value: "101"},        // No row key
+		{From: "EU|99", Column: "Invoice", Value: "101"},   // Already seen
+		{From: "EU|98|x", Column: "Invoice", Value: "101"}, // Malformed key
+	}
+	got := s.earlierKeys(uniqueKey, []string{"EU|101"}, []string{"EU|101", "EU|99"}, changes)
+	want := []string{"EU|100", "US|101"}
+	if !slices.Equal(got, want) {
+		t.Errorf("earlierKeys = %v, want %v", got, want)
+	}
+
+	// A single-column key is replaced whole
+	got = s.earlierKeys([]string{"ID"}, []string{"B"}, []string{"B"}, []keyChange{
+		{From: "A", Column: "id", Value: "B"},
+		{From: "C", Column: "id", Value: "D"},
+	})
+	if !slices.Equal(got, []string{"A"}) {
+		t.Errorf("single key: earlierKeys = %v, want [A]", got)
+	}
+}
+
+func TestAuditEntryColumns(t *testing.T) {
+	// scanAuditLogRow scans 20 columns
+	n := 1
+	for _, c := range auditEntryColumns {
+		if c == ',' {
+			n++
+		}
+	}
+	if n != 20 {
+		t.Errorf("auditEntryColumns has %d columns, want 20", n)
+	}
+}
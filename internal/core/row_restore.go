@@ -0,0 +1,339 @@
+package core
+
+// row_restore.go restores deleted rows from the audit log. DeleteRows
+// records each row's data in a row_delete entry (see RecordRowDelete); a
+// restore validates that data like a cell edit, inserts it again, and
+// records a row_restore entry whose related_audit_id is the deletion, so a
+// deletion is restored at most once. A soft-deleted row that has not been
+// purged is taken out of the recycle bin instead (see soft_delete.go).
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const rowRestoreReason = "restore deleted row"
+
+// Default and maximum number of deleted rows listed.
+const (
+	defaultDeletedRowsLimit = 50
+	maxDeletedRowsLimit     = 500
+)
+
+// DeletedRow is a row_delete audit entry: a row as it was when deleted.
+type DeletedRow struct {
+	HistoryID string                 `json:"historyId"` // The row_delete audit entry
+	RowKey    string                 `json:"rowKey"`
+	RowData   map[string]interface{} `json:"rowData"`
+	DeletedBy string                 `json:"deletedBy,omitempty"`
+	DeletedAt time.Time              `json:"deletedAt"`
+	Restored  bool                   `json:"restored"`
+}
+
+// RestoredRow is the outcome of restoring one deleted row.
+type RestoredRow struct {
+	HistoryID string `json:"historyId"`
+	RowKey    string `json:"rowKey,omitempty"`
+	Restored  bool   `json:"restored"`
+	Error     string `json:"error,omitempty"` // Why the row was not restored
+}
+
+// RestoreResult contains the result of RestoreDeletedRows.
+type RestoreResult struct {
+	Restored int           `json:"restored"`
+	Skipped  int           `json:"skipped"`
+	Rows     []RestoredRow `json:"rows"`
+}
+
+// ListDeletedRows returns the table's most recently deleted rows, newest
+// first, and whether each has been restored. limit defaults to 50 and is
+// capped at 500.
+func (s *Service) ListDeletedRows(ctx context.Context, tableKey string, limit int) ([]DeletedRow, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	if _, ok := Get(tableKey); !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if limit <= 0 {
+		limit = defaultDeletedRowsLimit
+	}
+	if limit > maxDeletedRowsLimit {
+		limit = maxDeletedRowsLimit
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT e.id::text, COALESCE(e.row_key, ''), e.row_data,
+		       COALESCE(e.user_email, ''), e.created_at,
+		       EXISTS (
+		           SELECT 1 FROM audit_log r
+		           WHERE r.related_audit_id = e.id AND r.action = 'row_restore' AND r.reason = $2
+		       )
+		FROM audit_log e
+		WHERE e.action = 'row_delete' AND e.table_key = $1
+		ORDER BY e.created_at DESC
+		LIMIT $3`,
+		tableKey, rowRestoreReason, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list deleted rows: %w", err)
+	}
+	defer rows.Close()
+
+	deleted := make([]DeletedRow, 0)
+	for rows.Next() {
+		var d DeletedRow
+		var rowData []byte
+		if err := rows.Scan(&d.HistoryID, &d.RowKey, &rowData, &d.DeletedBy, &d.DeletedAt, &d.Restored); err != nil {
+			return nil, fmt.Errorf("scan deleted row: %w", err)
+		}
+		if len(rowData) > 0 {
+			_ = json.Unmarshal(rowData, &d.RowData)
+		}
+		deleted = append(deleted, d)
+	}
+	return deleted, rows.Err()
+}
+
+// RestoreDeletedRows inserts the rows deleted by the given row_delete audit
+// entries again. Each row's saved data is validated against the table's
+// FieldSpecs and ValidateRow; a row that fails, was already restored, or
+// whose key is in the table again is skipped and reported in its
+// RestoredRow. Each restore is recorded as a row_restore audit entry.
+func (s *Service) RestoreDeletedRows(ctx context.Context, tableKey string, historyIDs []string) (*RestoreResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	defer s.invalidateQueryCache(tableKey)
+	uniqueKey := def.Info.UniqueKey
+	if len(uniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
+	}
+
+	// Serialize restores per table so a deletion can't be restored twice
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "row_restore:"+tableKey); err != nil {
+		return nil, fmt.Errorf("lock row history: %w", err)
+	}
+
+	result := &RestoreResult{Rows: make([]RestoredRow, 0, len(historyIDs))}
+	for _, id := range historyIDs {
+		row := RestoredRow{HistoryID: id}
+		if err := s.restoreDeletedRow(ctx, tx, def, &row); err != nil {
+			row.Error = err.Error()
+			result.Skipped++
+		} else {
+			row.Restored = true
+			result.Restored++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
+
+// restoreDeletedRow restores the row of one row_delete entry within tx,
+// setting row.RowKey. The error says why the row was skipped.
+func (s *Service) restoreDeletedRow(ctx context.Context, tx pgx.Tx, def TableDefinition, row *RestoredRow) error {
+	tableKey := def.Info.Key
+
+	var rowKey string
+	var rowData []byte
+	var restored bool
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(e.row_key, ''), e.row_data,
+		       EXISTS (
+		           SELECT 1 FROM audit_log r
+		           WHERE r.related_audit_id = e.id AND r.action = 'row_restore' AND r.reason = $3
+		       )
+		FROM audit_log e
+		WHERE e.id::text = $1 AND e.action = 'row_delete' AND e.table_key = $2`,
+		row.HistoryID, tableKey, rowRestoreReason,
+	).Scan(&rowKey, &rowData, &restored)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("no deleted row %s in %s", row.HistoryID, tableKey)
+	}
+	if err != nil {
+		return fmt.Errorf("read deleted row: %w", err)
+	}
+	row.RowKey = rowKey
+	if restored {
+		return errors.New("already restored")
+	}
+
+	// A soft-deleted row is still in the table, unless it was purged
+	if def.SoftDelete {
+		restored, err := undeleteRow(ctx, tx, def, rowKey)
+		if errors.Is(err, errKeyTaken) {
+			return fmt.Errorf("a row with key %s already exists", rowKey)
+		}
+		if err != nil {
+			return err
+		}
+		if restored {
+			return s.logRowRestore(ctx, tableKey, rowKey, rowData, row.HistoryID)
+		}
+	}
+
+	if len(rowData) == 0 {
+		return errors.New("row data was not recorded")
+	}
+
+	values, err := deletedRowValues(def, rowData)
+	if err != nil {
+		return err
+	}
+	if err := validateRestoredRow(def, values); err != nil {
+		return err
+	}
+
+	// The key may have been taken by an upload or edit since
+	var exists bool
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	conditions := make([]string, len(keyCols))
+	keyArgs := make([]interface{}, len(keyCols))
+	for i, col := range keyCols {
+		conditions[i] = fmt.Sprintf("%s = $%d", quoteIdentifier(col), i+1)
+		keyArgs[i] = values[def.Info.UniqueKey[i]]
+	}
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s%s)", quoteIdentifier(tableKey), strings.Join(conditions, " AND "), andLiveRows(def, ""))
+	if err := tx.QueryRow(ctx, query, keyArgs...).Scan(&exists); err != nil {
+		return fmt.Errorf("duplicate check failed: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("a row with key %s already exists", rowKey)
+	}
+
+	var cols, placeholders []string
+	var args []interface{}
+	for _, col := range def.Info.Columns {
+		value, ok := values[col]
+		if !ok {
+			continue
+		}
+		spec, dbCol := editColumn(def, col)
+		args = append(args, cellDBValue(value, spec))
+		cols = append(cols, quoteIdentifier(dbCol))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(tableKey), strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+
+	// A savepoint keeps one failed insert from aborting the others
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	if _, err := sp.Exec(ctx, insertSQL, args...); err != nil {
+		sp.Rollback(ctx)
+		return fmt.Errorf("insert: %w", err)
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+
+	return s.logRowRestore(ctx, tableKey, rowKey, rowData, row.HistoryID)
+}
+
+// logRowRestore records the restore of the row deleted by the row_delete
+// entry historyID.
+func (s *Service) logRowRestore(ctx context.Context, tableKey, rowKey string, rowData []byte, historyID string) error {
+	var saved map[string]interface{}
+	_ = json.Unmarshal(rowData, &saved)
+	if _, err := s.LogAudit(ctx, AuditLogParams{
+		Action:         ActionRowRestore,
+		TableKey:       tableKey,
+		RowKey:         rowKey,
+		RowData:        saved,
+		RowsAffected:   1,
+		RelatedAuditID: historyID,
+		Reason:         rowRestoreReason,
+		IPAddress:      GetIPAddressFromContext(ctx),
+		UserAgent:      GetUserAgentFromContext(ctx),
+	}); err != nil {
+		// Without the entry the row could be restored again
+		return fmt.Errorf("restore not recorded: %w", err)
+	}
+	return nil
+}
+
+// deletedRowValues returns a row_delete entry's saved data as cell values
+// keyed by display column, in the form a cell edit accepts.
+func deletedRowValues(def TableDefinition, rowData []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(rowData))
+	dec.UseNumber() // Keep numerics exact
+	var saved map[string]interface{}
+	if err := dec.Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid row data: %w", err)
+	}
+
+	values := make(map[string]string, len(saved))
+	for _, col := range def.Info.Columns {
+		v, ok := saved[col]
+		if !ok {
+			continue
+		}
+		spec, _ := editColumn(def, col)
+		switch v := v.(type) {
+		case nil:
+			values[col] = ""
+		case string:
+			// Dates are saved as timestamps (2024-01-31T00:00:00Z)
+			if t, err := time.Parse(time.RFC3339, v); err == nil && spec != nil && spec.Type == FieldDate {
+				v = t.Format("2006-01-02")
+			}
+			values[col] = v
+		case json.Number:
+			values[col] = v.String()
+		case bool:
+			values[col] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("column %q: unsupported saved value %v", col, v)
+		}
+	}
+	return values, nil
+}
+
+// validateRestoredRow checks restored values as a cell edit and an upload
+// would: each field on its own, then the table's ValidateRow rule.
+func validateRestoredRow(def TableDefinition, values map[string]string) error {
+	for _, spec := range def.FieldSpecs {
+		value := values[spec.Name]
+		if value == "" && spec.Required && !spec.AllowEmpty {
+			return fmt.Errorf("empty required field %q", spec.Name)
+		}
+		if err := validateCellValue(value, spec); err != nil {
+			return fmt.Errorf("column %q: %v", spec.Name, err)
+		}
+	}
+
+	row := make([]string, 0, len(values))
+	headerIdx := make(HeaderIndex, len(values))
+	for col, value := range values {
+		headerIdx[strings.ToLower(col)] = len(row)
+		row = append(row, value)
+	}
+	return checkRowRule(row, headerIdx, def)
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func restoreTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{
+			Key:       "restore_orders",
+			Columns:   []string{"Order ID", "Amount", "Due", "Paid", "Status"},
+			UniqueKey: []string{"Order ID"},
+		},
+		FieldSpecs: []FieldSpec{
+			{Name: "Order ID", Type: FieldText, Required: true},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Due", Type: FieldDate},
+			{Name: "Paid", Type: FieldBool},
+			{Name: "Status", Type: FieldEnum, EnumValues: []string{"open", "closed"}},
+		},
+		ValidateRow: func(row map[string]string) error {
+			if row["Status"] == "closed" && row["Paid"] != "true" {
+				return NewRuleError("closed_paid", "closed orders must be paid")
+			}
+			return nil
+		},
+	}
+}
+
+func TestDeletedRowValues(t *testing.T) {
+	def := restoreTestTable()
+
+	// As RecordRowDelete saves a row read back with getRowData
+	values, err := deletedRowValues(def, []byte(`{"Order ID": "A1", "Amount": 1200.50, "Due": "2024-01-31T00:00:00Z", "Paid": true, "Status": null, "Extra": "x"}`))
+	if err != nil {
+		t.Fatalf("deletedRowValues: %v", err)
+	}
+	want := map[string]string{"Order ID": "A1", "Amount": "1200.50", "Due": "2024-01-31", "Paid": "true", "Status": ""}
+	if len(values) != len(want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	for col, v := range want {
+		if values[col] != v {
+			t.Errorf("%s = %q, want %q", col, values[col], v)
+		}
+	}
+
+	if _, err := deletedRowValues(def, []byte(`{"Amount": [1, 2]}`)); err == nil {
+		t.Error("array value: want error")
+	}
+	if _, err := deletedRowValues(def, []byte(`not json`)); err == nil {
+		t.Error("invalid JSON: want error")
+	}
+}
+
+func TestValidateRestoredRow(t *testing.T) {
+	def := restoreTestTable()
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   string
+	}{
+		{"valid", map[string]string{"Order ID": "A1", "Amount": "10", "Paid": "true", "Status": "closed"}, ""},
+		{"missing key", map[string]string{"Amount": "10"}, `empty required field "Order ID"`},
+		{"bad enum", map[string]string{"Order ID": "A1", "Status": "pending"}, `column "Status"`},
+		{"rule", map[string]string{"Order ID": "A1", "Paid": "false", "Status": "closed"}, "rule closed_paid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRestoredRow(def, tt.values)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreDeletedRows_Errors(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "restore_notes"},
+		FieldSpecs: []FieldSpec{{Name: "Note", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "restore_notes")
+		registryMu.Unlock()
+	})
+	s := &Service{cfg: &config.Config{}}
+	ctx := context.Background()
+
+	if _, err := s.RestoreDeletedRows(ctx, "nope", []string{"x"}); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("unknown table: err = %v", err)
+	}
+	if _, err := s.RestoreDeletedRows(ctx, "restore_notes", []string{"x"}); err == nil || !strings.Contains(err.Error(), "no unique key") {
+		t.Errorf("no unique key: err = %v", err)
+	}
+	if _, err := s.ListDeletedRows(ctx, "nope", 0); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("list unknown table: err = %v", err)
+	}
+}
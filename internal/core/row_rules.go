@@ -0,0 +1,123 @@
+package core
+
+// row_rules.go runs a table's custom validation hooks.
+//
+// FieldSpecs check one field at a time. Rules that relate fields ("end_date
+// must be after start_date", "amount must be positive when type=invoice")
+// go in TableDefinition.ValidateRow, and rules that relate rows in
+// ValidateBatch. Both run after the FieldSpec checks, on values keyed by
+// FieldSpec name, and a failing row is skipped like any other invalid row.
+// Its failed-row reason starts with the rule name:
+//
+//	rule end_after_start: end_date must be after start_date
+//
+// Hooks name their rules by returning a RuleError (see NewRuleError); any
+// other error is reported under the hook's name.
+//
+// ValidateBatch only sees one insert batch at a time, so rules spanning a
+// whole file need a batch size at least as large as the file. The upload
+// preview runs ValidateRow but not ValidateBatch.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Rule names for hook errors that are not a RuleError.
+const (
+	defaultRowRule   = "ValidateRow"
+	defaultBatchRule = "ValidateBatch"
+)
+
+// RuleError is a failed custom validation rule.
+type RuleError struct {
+	Rule string // Short name of the rule, e.g. "end_after_start"
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %s: %v", e.Rule, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// NewRuleError returns a RuleError for rule with a formatted message.
+func NewRuleError(rule, format string, args ...any) error {
+	return &RuleError{Rule: rule, Err: fmt.Errorf(format, args...)}
+}
+
+// asRuleError returns err as a RuleError, naming it defaultRule if the hook
+// did not.
+func asRuleError(err error, defaultRule string) *RuleError {
+	var re *RuleError
+	if errors.As(err, &re) {
+		return re
+	}
+	return &RuleError{Rule: defaultRule, Err: err}
+}
+
+// ruleValues returns the row's values as the hooks see them: cleaned and
+// normalized, keyed by FieldSpec name. Columns missing from the file are
+// left out.
+func ruleValues(row []string, headerIdx HeaderIndex, def TableDefinition) map[string]string {
+	values := make(map[string]string, len(def.FieldSpecs))
+	for _, spec := range def.FieldSpecs {
+		pos, ok := headerIdx[strings.ToLower(spec.Name)]
+		if !ok || pos >= len(row) {
+			continue
+		}
+		raw := CleanCell(row[pos])
+		if spec.Normalizer != nil && raw != "" {
+			raw = spec.Normalizer(raw)
+		}
+		values[spec.Name] = raw
+	}
+	return values
+}
+
+// checkRowRule runs the table's ValidateRow hook on a row.
+func checkRowRule(row []string, headerIdx HeaderIndex, def TableDefinition) error {
+	if def.ValidateRow == nil {
+		return nil
+	}
+	if err := def.ValidateRow(ruleValues(row, headerIdx, def)); err != nil {
+		return asRuleError(err, defaultRowRule)
+	}
+	return nil
+}
+
+// applyBatchRules runs the table's ValidateBatch hook on a batch, records
+// the rows it rejects as failed and returns the rest. The batch's backing
+// array is reused.
+func applyBatchRules(def TableDefinition, batch []validatedRow, headerIdx HeaderIndex, failedRows *[]FailedRow, fileName string) []validatedRow {
+	if def.ValidateBatch == nil || len(batch) == 0 {
+		return batch
+	}
+
+	values := make([]map[string]string, len(batch))
+	for i, vr := range batch {
+		values[i] = ruleValues(vr.row, headerIdx, def)
+	}
+	errs := def.ValidateBatch(values)
+	if len(errs) == 0 {
+		return batch
+	}
+
+	kept := batch[:0]
+	for i, vr := range batch {
+		if err := errs[i]; err != nil {
+			*failedRows = append(*failedRows, FailedRow{
+				FileName:   fileName,
+				LineNumber: vr.lineNum,
+				Reason:     asRuleError(err, defaultBatchRule).Error(),
+				Data:       vr.row,
+			})
+			continue
+		}
+		kept = append(kept, vr)
+	}
+	return kept
+}
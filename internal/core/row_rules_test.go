@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func ruleTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "rule_test"},
+		FieldSpecs: []FieldSpec{
+			{Name: "type", Type: FieldText, Normalizer: strings.ToLower},
+			{Name: "amount", Type: FieldNumeric},
+			{Name: "start_date", Type: FieldDate},
+			{Name: "end_date", Type: FieldDate},
+		},
+		BuildParams: func(row []string, _ HeaderIndex, _ pgtype.UUID) (any, error) { return row, nil },
+		ValidateRow: func(row map[string]string) error {
+			if row["type"] == "invoice" && strings.HasPrefix(row["amount"], "-") {
+				return NewRuleError("positive_invoice", "amount must be positive when type=invoice")
+			}
+			if row["end_date"] != "" && row["end_date"] < row["start_date"] {
+				return errors.New("end_date must be after start_date")
+			}
+			return nil
+		},
+	}
+}
+
+func TestBuildAndValidate_RowRule(t *testing.T) {
+	def := ruleTestTable()
+	headerIdx := HeaderIndex{"type": 0, "amount": 1, "start_date": 2, "end_date": 3}
+
+	tests := []struct {
+		name string
+		row  []string
+		want string
+	}{
+		{"valid", []string{"Invoice", "10", "2024-01-01", "2024-02-01"}, ""},
+		{"named rule", []string{" INVOICE ", "-5", "2024-01-01", ""}, "rule positive_invoice: amount must be positive when type=invoice"},
+		{"unnamed rule", []string{"credit", "-5", "2024-02-01", "2024-01-01"}, "rule ValidateRow: end_date must be after start_date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildAndValidate(tt.row, headerIdx, def, pgtype.UUID{})
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("err = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Missing columns are left out of the map
+	if got := ruleValues([]string{"X"}, HeaderIndex{"type": 0}, def); len(got) != 1 || got["type"] != "x" {
+		t.Errorf("ruleValues = %v", got)
+	}
+}
+
+func TestApplyBatchRules(t *testing.T) {
+	def := ruleTestTable()
+	headerIdx := HeaderIndex{"type": 0, "amount": 1}
+
+	// Invoices in a batch must not share an amount
+	def.ValidateBatch = func(rows []map[string]string) map[int]error {
+		errs := make(map[int]error)
+		seen := make(map[string]bool)
+		for i, row := range rows {
+			if seen[row["amount"]] {
+				errs[i] = NewRuleError("unique_amount", "amount %s repeated in batch", row["amount"])
+			}
+			seen[row["amount"]] = true
+		}
+		if len(rows) > 3 {
+			errs[3] = errors.New("too many rows")
+		}
+		return errs
+	}
+
+	batch := []validatedRow{
+		{lineNum: 2, row: []string{"invoice", "10"}},
+		{lineNum: 3, row: []string{"invoice", "20"}},
+		{lineNum: 4, row: []string{"invoice", "10"}},
+		{lineNum: 5, row: []string{"invoice", "30"}},
+	}
+	var failed []FailedRow
+	kept := applyBatchRules(def, batch, headerIdx, &failed, "f.csv")
+
+	if len(kept) != 2 || kept[0].lineNum != 2 || kept[1].lineNum != 3 {
+		t.Errorf("kept = %+v", kept)
+	}
+	if len(failed) != 2 {
+		t.Fatalf("failed = %+v", failed)
+	}
+	if failed[0].LineNumber != 4 || failed[0].Reason != "rule unique_amount: amount 10 repeated in batch" || failed[0].FileName != "f.csv" {
+		t.Errorf("failed[0] = %+v", failed[0])
+	}
+	if failed[1].LineNumber != 5 || failed[1].Reason != "rule ValidateBatch: too many rows" {
+		t.Errorf("failed[1] = %+v", failed[1])
+	}
+
+	// Without a hook the batch is returned as is
+	def.ValidateBatch = nil
+	if got := applyBatchRules(def, batch[:1], headerIdx, &failed, "f.csv"); len(got) != 1 || len(failed) != 2 {
+		t.Errorf("no hook: kept %d, failed %d", len(got), len(failed))
+	}
+}
+
+func TestRuleError_Unwrap(t *testing.T) {
+	base := errors.New("boom")
+	err := asRuleError(base, defaultRowRule)
+	if !errors.Is(err, base) || err.Rule != defaultRowRule {
+		t.Errorf("asRuleError = %+v", err)
+	}
+	named := NewRuleError("r", "x")
+	if got := asRuleError(named, defaultRowRule); got.Rule != "r" {
+		t.Errorf("rule = %q, want r", got.Rule)
+	}
+}
@@ -0,0 +1,406 @@
+package core
+
+// saved_views.go persists named table views: a search term, column filters,
+// sort order and the visible columns, so a view like "Q4 open invoices" can
+// be reopened with one click.
+//
+// Views are stored per table by display column name. Names are resolved
+// through the table's renames when a view is read, like import templates,
+// so a view saved before a column rename keeps working.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ViewFilter is one column filter of a saved view.
+type ViewFilter struct {
+	Column   string         `json:"column"`
+	Operator FilterOperator `json:"op"`
+	Value    string         `json:"value"`
+	Group    string         `json:"group,omitempty"` // OR group path (see filter_groups.go)
+	Not      bool           `json:"not,omitempty"`   // Negate the condition
+}
+
+// ViewSort is one sort level of a saved view.
+type ViewSort struct {
+	Column string `json:"column"`
+	Dir    string `json:"dir"`             // "asc" or "desc"
+	Nulls  string `json:"nulls,omitempty"` // "first", "last", or empty for the default
+}
+
+// SavedView is a named combination of search, filters, sorts and visible
+// columns for a table.
+type SavedView struct {
+	ID       string       `json:"id"`
+	TableKey string       `json:"tableKey"`
+	Name     string       `json:"name"`
+	Search   string       `json:"search,omitempty"`
+	Filters  []ViewFilter `json:"filters"`
+	Sorts    []ViewSort   `json:"sorts"`
+	// Columns lists the visible columns in display order; empty shows all
+	Columns   []string  `json:"columns"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SavedViewParams contains the fields of a view to create or update.
+type SavedViewParams struct {
+	Name    string       `json:"name"`
+	Search  string       `json:"search"`
+	Filters []ViewFilter `json:"filters"`
+	Sorts   []ViewSort   `json:"sorts"`
+	Columns []string     `json:"columns"`
+}
+
+// Saved view errors.
+var (
+	ErrSavedViewNotFound = errors.New("saved view not found")
+	ErrSavedViewExists   = errors.New("saved view already exists")
+	ErrInvalidSavedView  = errors.New("invalid saved view")
+)
+
+// validateSavedView checks p against the table's columns and returns it
+// with column names in their canonical case and sort directions defaulted.
+// maxSorts is the most sort levels a view may have.
+func validateSavedView(def TableDefinition, p SavedViewParams, maxSorts int) (SavedViewParams, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return p, fmt.Errorf("%w: name is required", ErrInvalidSavedView)
+	}
+
+	specs := columnSpecs(def)
+	lookup := func(col string) (FieldSpec, error) {
+		spec, ok := specs[strings.ToLower(ResolveColumnName(def, strings.TrimSpace(col), "saved view"))]
+		if !ok {
+			return FieldSpec{}, fmt.Errorf("%w: unknown column %s", ErrInvalidSavedView, col)
+		}
+		return spec, nil
+	}
+	// Go-computed derived columns are only shown (see derived_columns.go)
+	queryable := func(col string) (FieldSpec, error) {
+		spec, err := lookup(col)
+		if err != nil {
+			return spec, err
+		}
+		if _, _, ok := FilterField(def, spec.Name); !ok {
+			return spec, fmt.Errorf("%w: column %s cannot be filtered or sorted", ErrInvalidSavedView, spec.Name)
+		}
+		return spec, nil
+	}
+
+	out := SavedViewParams{
+		Name:    p.Name,
+		Search:  strings.TrimSpace(p.Search),
+		Filters: make([]ViewFilter, 0, len(p.Filters)),
+		Sorts:   make([]ViewSort, 0, len(p.Sorts)),
+		Columns: make([]string, 0, len(p.Columns)),
+	}
+
+	for _, f := range p.Filters {
+		spec, err := queryable(f.Column)
+		if err != nil {
+			return p, err
+		}
+		if !ValidOperator(f.Operator, spec.Type) {
+			return p, fmt.Errorf("%w: operator %q is not valid for column %s", ErrInvalidSavedView, f.Operator, spec.Name)
+		}
+		if !f.Operator.TakesValue() {
+			f.Value = ""
+		} else if f.Value == "" {
+			return p, fmt.Errorf("%w: filter on %s has no value", ErrInvalidSavedView, spec.Name)
+		}
+		if !ValidFilterGroup(f.Group) {
+			return p, fmt.Errorf("%w: invalid filter group %q", ErrInvalidSavedView, f.Group)
+		}
+		out.Filters = append(out.Filters, ViewFilter{Column: spec.Name, Operator: f.Operator, Value: f.Value, Group: f.Group, Not: f.Not})
+	}
+
+	if len(p.Sorts) > maxSorts {
+		return p, fmt.Errorf("%w: at most %d sort levels are supported", ErrInvalidSavedView, maxSorts)
+	}
+	for _, srt := range p.Sorts {
+		spec, err := queryable(srt.Column)
+		if err != nil {
+			return p, err
+		}
+		dir := strings.ToLower(srt.Dir)
+		switch dir {
+		case "":
+			dir = "asc"
+		case "asc", "desc":
+		default:
+			return p, fmt.Errorf("%w: invalid sort direction %q", ErrInvalidSavedView, srt.Dir)
+		}
+		nulls := strings.ToLower(srt.Nulls)
+		if nulls != "" && nulls != "first" && nulls != "last" {
+			return p, fmt.Errorf("%w: invalid null ordering %q (want first or last)", ErrInvalidSavedView, srt.Nulls)
+		}
+		out.Sorts = append(out.Sorts, ViewSort{Column: spec.Name, Dir: dir, Nulls: nulls})
+	}
+
+	seen := make(map[string]bool, len(p.Columns))
+	for _, col := range p.Columns {
+		spec, err := lookup(col)
+		if err != nil {
+			return p, err
+		}
+		if seen[spec.Name] {
+			continue
+		}
+		seen[spec.Name] = true
+		out.Columns = append(out.Columns, spec.Name)
+	}
+
+	return out, nil
+}
+
+// Query returns the view's sorts and filters in the form GetTableData and
+// the export endpoints take. Columns no longer in the table are dropped.
+func (v SavedView) Query(def TableDefinition) ([]SortSpec, FilterSet) {
+	sorts := make([]SortSpec, 0, len(v.Sorts))
+	for _, srt := range v.Sorts {
+		if spec, _, ok := FilterField(def, srt.Column); ok {
+			sorts = append(sorts, SortSpec{Column: spec.Name, Dir: srt.Dir, Nulls: srt.Nulls})
+		}
+	}
+
+	var filters FilterSet
+	for _, f := range v.Filters {
+		spec, expr, ok := FilterField(def, f.Column)
+		if !ok || !ValidOperator(f.Operator, spec.Type) {
+			continue
+		}
+		filters.Filters = append(filters.Filters, ColumnFilter{
+			Column:   spec.Name,
+			DBColumn: resolveDBColumn(spec.Name, def.FieldSpecs),
+			Expr:     expr,
+			Operator: f.Operator,
+			Value:    f.Value,
+			Type:     spec.Type,
+			Group:    f.Group,
+			Negate:   f.Not,
+		})
+	}
+	return sorts, filters
+}
+
+// VisibleColumns returns the view's columns that are still in the table, in
+// the view's order, or all of the table's columns if it names none.
+func (v SavedView) VisibleColumns(def TableDefinition) []string {
+	all := DisplayColumns(def)
+	cols := make([]string, 0, len(v.Columns))
+	for _, col := range v.Columns {
+		if containsColumn(all, col) {
+			cols = append(cols, col)
+		}
+	}
+	if len(cols) == 0 {
+		return all
+	}
+	return cols
+}
+
+// CreateSavedView saves a new view for a table.
+func (s *Service) CreateSavedView(ctx context.Context, tableKey string, p SavedViewParams) (*SavedView, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	p, err := validateSavedView(def, p, s.MaxSortLevels())
+	if err != nil {
+		return nil, err
+	}
+
+	filtersJSON, sortsJSON, columnsJSON, err := marshalSavedView(p)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.pool.QueryRow(ctx,
+		`INSERT INTO saved_views (table_key, name, search_query, filters, sorts, columns)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+savedViewColumns,
+		tableKey, p.Name, p.Search, filtersJSON, sortsJSON, columnsJSON,
+	)
+	view, err := scanSavedView(row)
+	if err != nil {
+		if strings.Contains(err.Error(), "saved_views_table_name_unique") {
+			return nil, fmt.Errorf("%w: %s", ErrSavedViewExists, p.Name)
+		}
+		return nil, fmt.Errorf("create view: %w", err)
+	}
+	return view, nil
+}
+
+// GetSavedView retrieves a saved view by ID.
+func (s *Service) GetSavedView(ctx context.Context, id string) (*SavedView, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	uid := ToPgUUID(id)
+	if !uid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrSavedViewNotFound, id)
+	}
+
+	view, err := scanSavedView(s.pool.QueryRow(ctx,
+		`SELECT `+savedViewColumns+` FROM saved_views WHERE id = $1`, uid))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSavedViewNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get view: %w", err)
+	}
+	return view, nil
+}
+
+// ListSavedViews returns a table's saved views ordered by name.
+func (s *Service) ListSavedViews(ctx context.Context, tableKey string) ([]SavedView, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+savedViewColumns+` FROM saved_views WHERE table_key = $1 ORDER BY name`, tableKey)
+	if err != nil {
+		return nil, fmt.Errorf("list views: %w", err)
+	}
+	defer rows.Close()
+
+	views := make([]SavedView, 0)
+	for rows.Next() {
+		view, err := scanSavedView(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan view: %w", err)
+		}
+		views = append(views, *view)
+	}
+	return views, rows.Err()
+}
+
+// UpdateSavedView replaces a saved view's name and settings.
+func (s *Service) UpdateSavedView(ctx context.Context, id string, p SavedViewParams) (*SavedView, error) {
+	existing, err := s.GetSavedView(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, ok := Get(existing.TableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", existing.TableKey)
+	}
+	p, err = validateSavedView(def, p, s.MaxSortLevels())
+	if err != nil {
+		return nil, err
+	}
+
+	filtersJSON, sortsJSON, columnsJSON, err := marshalSavedView(p)
+	if err != nil {
+		return nil, err
+	}
+
+	view, err := scanSavedView(s.pool.QueryRow(ctx,
+		`UPDATE saved_views
+		 SET name = $2, search_query = $3, filters = $4, sorts = $5, columns = $6, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+savedViewColumns,
+		ToPgUUID(id), p.Name, p.Search, filtersJSON, sortsJSON, columnsJSON,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSavedViewNotFound
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "saved_views_table_name_unique") {
+			return nil, fmt.Errorf("%w: %s", ErrSavedViewExists, p.Name)
+		}
+		return nil, fmt.Errorf("update view: %w", err)
+	}
+	return view, nil
+}
+
+// DeleteSavedView removes a saved view.
+func (s *Service) DeleteSavedView(ctx context.Context, id string) error {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	uid := ToPgUUID(id)
+	if !uid.Valid {
+		return fmt.Errorf("%w: invalid ID %s", ErrSavedViewNotFound, id)
+	}
+
+	tag, err := s.pool.Exec(ctx, `DELETE FROM saved_views WHERE id = $1`, uid)
+	if err != nil {
+		return fmt.Errorf("delete view: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSavedViewNotFound
+	}
+	return nil
+}
+
+// savedViewColumns is the column list scanned by scanSavedView.
+const savedViewColumns = `id, table_key, name, search_query, filters, sorts, columns, created_at, updated_at`
+
+// marshalSavedView encodes a view's filters, sorts and columns for storage.
+func marshalSavedView(p SavedViewParams) (filters, sorts, columns []byte, err error) {
+	if filters, err = json.Marshal(p.Filters); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal filters: %w", err)
+	}
+	if sorts, err = json.Marshal(p.Sorts); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal sorts: %w", err)
+	}
+	if columns, err = json.Marshal(p.Columns); err != nil {
+		return nil, nil, nil, fmt.Errorf("marshal columns: %w", err)
+	}
+	return filters, sorts, columns, nil
+}
+
+// scanSavedView scans one row selected with savedViewColumns. Column names
+// are resolved through the table's renames.
+func scanSavedView(row pgx.Row) (*SavedView, error) {
+	var (
+		id                      pgtype.UUID
+		filters, sorts, columns []byte
+		view                    SavedView
+	)
+	if err := row.Scan(&id, &view.TableKey, &view.Name, &view.Search,
+		&filters, &sorts, &columns, &view.CreatedAt, &view.UpdatedAt); err != nil {
+		return nil, err
+	}
+	view.ID = PgUUIDToString(id)
+
+	if err := json.Unmarshal(filters, &view.Filters); err != nil {
+		return nil, fmt.Errorf("unmarshal filters: %w", err)
+	}
+	if err := json.Unmarshal(sorts, &view.Sorts); err != nil {
+		return nil, fmt.Errorf("unmarshal sorts: %w", err)
+	}
+	if err := json.Unmarshal(columns, &view.Columns); err != nil {
+		return nil, fmt.Errorf("unmarshal columns: %w", err)
+	}
+
+	if def, ok := Get(view.TableKey); ok && len(def.Renames) > 0 {
+		for i := range view.Filters {
+			view.Filters[i].Column = ResolveColumnName(def, view.Filters[i].Column, "saved view")
+		}
+		for i := range view.Sorts {
+			view.Sorts[i].Column = ResolveColumnName(def, view.Sorts[i].Column, "saved view")
+		}
+		for i := range view.Columns {
+			view.Columns[i] = ResolveColumnName(def, view.Columns[i], "saved view")
+		}
+	}
+	return &view, nil
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// savedViewCustomers is evolvedCustomers under its own key, so the old
+// names resolved here are not counted against other tests.
+func savedViewCustomers() TableDefinition {
+	def := evolvedCustomers()
+	def.Info.Key = "saved_view_customers"
+	return def
+}
+
+func TestValidateSavedView(t *testing.T) {
+	def := savedViewCustomers()
+
+	got, err := validateSavedView(def, SavedViewParams{
+		Name:    "  West accounts ",
+		Search:  " acme ",
+		Filters: []ViewFilter{{Column: "territory", Operator: OpEquals, Value: "West"}, {Column: "signed", Operator: OpIsNull, Value: "ignored"}},
+		Sorts:   []ViewSort{{Column: "signed"}, {Column: "Customer Name", Dir: "DESC", Nulls: "LAST"}},
+		Columns: []string{"customer id", "Region", "region"},
+	}, DefaultMaxSortLevels)
+	if err != nil {
+		t.Fatalf("validateSavedView: %v", err)
+	}
+	want := SavedViewParams{
+		Name:    "West accounts",
+		Search:  "acme",
+		Filters: []ViewFilter{{Column: "Region", Operator: OpEquals, Value: "West"}, {Column: "Signed", Operator: OpIsNull}},
+		Sorts:   []ViewSort{{Column: "Signed", Dir: "asc"}, {Column: "Account Name", Dir: "desc", Nulls: "last"}},
+		Columns: []string{"Customer ID", "Region"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		p    SavedViewParams
+	}{
+		{"no name", SavedViewParams{Name: " "}},
+		{"unknown filter column", SavedViewParams{Name: "v", Filters: []ViewFilter{{Column: "Nope", Operator: OpEquals, Value: "x"}}}},
+		{"operator for another type", SavedViewParams{Name: "v", Filters: []ViewFilter{{Column: "Signed", Operator: OpContains, Value: "2024"}}}},
+		{"empty filter value", SavedViewParams{Name: "v", Filters: []ViewFilter{{Column: "Region", Operator: OpEquals}}}},
+		{"too many sorts", SavedViewParams{Name: "v", Sorts: []ViewSort{{Column: "Region"}, {Column: "Signed"}, {Column: "Customer ID"}}}},
+		{"bad direction", SavedViewParams{Name: "v", Sorts: []ViewSort{{Column: "Region", Dir: "up"}}}},
+		{"bad null ordering", SavedViewParams{Name: "v", Sorts: []ViewSort{{Column: "Region", Nulls: "middle"}}}},
+		{"unknown visible column", SavedViewParams{Name: "v", Columns: []string{"legacy_code"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateSavedView(def, tt.p, 2); !errors.Is(err, ErrInvalidSavedView) {
+				t.Errorf("err = %v, want ErrInvalidSavedView", err)
+			}
+		})
+	}
+}
+
+func TestSavedViewQuery(t *testing.T) {
+	def := savedViewCustomers()
+	def.Info.Columns = []string{"Customer ID", "Account Name", "Region", "Signed"}
+
+	view := SavedView{
+		Filters: []ViewFilter{
+			{Column: "Signed", Operator: OpGreaterEq, Value: "2024-01-01"},
+			{Column: "Dropped", Operator: OpEquals, Value: "x"},
+		},
+		Sorts:   []ViewSort{{Column: "Region", Dir: "desc"}},
+		Columns: []string{"Region", "Dropped", "Customer ID"},
+	}
+	sorts, filters := view.Query(def)
+	if !reflect.DeepEqual(sorts, []SortSpec{{Column: "Region", Dir: "desc"}}) {
+		t.Errorf("sorts = %+v", sorts)
+	}
+	if len(filters.Filters) != 1 {
+		t.Fatalf("filters = %+v", filters.Filters)
+	}
+	if f := filters.Filters[0]; f.DBColumn != "signed_date" || f.Type != FieldDate || f.Operator != OpGreaterEq {
+		t.Errorf("filter = %+v", f)
+	}
+
+	if got := view.VisibleColumns(def); !reflect.DeepEqual(got, []string{"Region", "Customer ID"}) {
+		t.Errorf("visible columns = %v", got)
+	}
+	if got := (SavedView{}).VisibleColumns(def); !reflect.DeepEqual(got, def.Info.Columns) {
+		t.Errorf("no columns: got %v", got)
+	}
+}
@@ -6,16 +6,32 @@ package core
 //  1. Move old entries from audit_log to audit_log_archive (hot -> cold)
 //  2. Purge very old entries from the archive based on retention policy
 //
+// The same run compacts old failed-row data and purges rows that have been
+// in a soft-delete table's recycle bin past their retention. Purged rows
+// can be kept in retention archives for a grace period first (see
+// retention_archive.go).
+//
+// Each run is a retention operation, followed and cancelled like any
+// other, and is recorded in archive_runs with what it did (see
+// archive_runs.go). The scheduler runs CheckInterval after the last run
+// recorded there, so a restart does not reset the clock, and a run missed
+// while the server was down is caught up as soon as it starts.
+// StartArchiveRun runs one on demand, which also moves the next scheduled
+// run. Only one run happens at a time.
+//
 // The scheduler is designed to be long-running and context-aware for graceful
 // shutdown. It logs progress and errors but does not fail the application
 // if individual archive operations fail.
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	db "github.com/JonMunkholm/TUI/internal/database"
+	"github.com/google/uuid"
 )
 
 // ArchiveConfig holds configuration for the archive scheduler.
@@ -25,67 +41,239 @@ type ArchiveConfig struct {
 	ArchiveRetentionYears int           // Years to keep in archive (default: 7)
 	BatchSize             int           // Rows per batch (default: 5000)
 	CheckInterval         time.Duration // How often to run (default: 24h)
+
+	FailedRowsCompactDays int    // Compact failed rows older than this; 0 disables (default: 30)
+	FailedRowsCompactMode string // CompactCompress or CompactSummarize (default: compress)
+
+	SoftDeleteRetentionDays int // Purge soft-deleted rows older than this; 0 disables (default: 30)
+}
+
+// Archive scheduler timing
+const (
+	defaultArchiveInterval = 24 * time.Hour
+	archiveRetryDelay      = 5 * time.Minute // After failing to read the last run
+)
+
+// archiveScheduler is the state of the archive scheduler shared with
+// on-demand runs.
+type archiveScheduler struct {
+	mu      sync.Mutex
+	cfg     *ArchiveConfig // Nil until StartArchiveScheduler
+	running string         // Operation ID of the run in progress
+	nextRun time.Time
+}
+
+// claim marks a run as in progress as opID, or returns the run already in
+// progress.
+func (a *archiveScheduler) claim(opID string) (running string, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running != "" {
+		return a.running, false
+	}
+	a.running = opID
+	return "", true
 }
 
-// StartArchiveScheduler starts a background goroutine that periodically
-// archives old audit log entries and purges very old archives.
-// It runs immediately on start, then every CheckInterval.
-// The scheduler stops when the context is cancelled.
+// release marks the run in progress as done.
+func (a *archiveScheduler) release() {
+	a.mu.Lock()
+	a.running = ""
+	a.mu.Unlock()
+}
+
+// StartArchiveScheduler archives old audit log entries and purges very
+// old archives every CheckInterval, counted from the last recorded run,
+// until ctx is cancelled. If no run was recorded in the last
+// CheckInterval it runs at once, to catch up.
 func (s *Service) StartArchiveScheduler(ctx context.Context, cfg ArchiveConfig) {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultArchiveInterval
+	}
+	s.archive.mu.Lock()
+	s.archive.cfg = &cfg
+	s.archive.mu.Unlock()
+
 	slog.Info("archive scheduler started",
 		"hot_retention_days", cfg.HotRetentionDays,
 		"archive_retention_years", cfg.ArchiveRetentionYears,
 		"batch_size", cfg.BatchSize,
+		"interval", cfg.CheckInterval,
 	)
+	if n, err := s.interruptArchiveRuns(ctx); err != nil {
+		slog.Error("failed to close interrupted archive runs", "error", err)
+	} else if n > 0 {
+		slog.Warn("archive runs were interrupted by a shutdown", "runs", n)
+	}
 
-	// Run immediately on startup
-	s.runArchiveJob(ctx, cfg)
-
-	// Then run periodically
-	ticker := time.NewTicker(cfg.CheckInterval)
-	defer ticker.Stop()
-
+	trigger := ArchiveCatchUp
 	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("archive scheduler stopped")
-			return
-		case <-ticker.C:
-			s.runArchiveJob(ctx, cfg)
+		wait, err := s.untilNextArchiveRun(ctx, cfg.CheckInterval, time.Now())
+		if err != nil {
+			slog.Error("failed to read last archive run", "error", err)
+			wait = archiveRetryDelay
+			if cfg.CheckInterval < wait {
+				wait = cfg.CheckInterval
+			}
 		}
+		if wait > 0 {
+			trigger = ArchiveScheduled
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				slog.Info("archive scheduler stopped")
+				return
+			case <-timer.C:
+			}
+			// An on-demand run may have moved the next run meanwhile
+			continue
+		}
+		s.runScheduledArchive(ctx, cfg, trigger)
+		trigger = ArchiveScheduled
+	}
+}
+
+// untilNextArchiveRun returns how long after now the next scheduled run is
+// due, which is not positive if it is due already, and notes when that is
+// for ArchiveStatus.
+func (s *Service) untilNextArchiveRun(ctx context.Context, interval time.Duration, now time.Time) (time.Duration, error) {
+	last, err := s.lastArchiveRunStart(ctx)
+	if err != nil {
+		return 0, err
+	}
+	next := now
+	if !last.IsZero() {
+		next = last.Add(interval)
+	}
+	s.archive.mu.Lock()
+	s.archive.nextRun = next
+	s.archive.mu.Unlock()
+	return next.Sub(now), nil
+}
+
+// runScheduledArchive runs the archive job now unless a run is in
+// progress already.
+func (s *Service) runScheduledArchive(ctx context.Context, cfg ArchiveConfig, trigger ArchiveTrigger) {
+	id := uuid.New().String()
+	if running, ok := s.archive.claim(id); !ok {
+		slog.Info("skipping scheduled archive run; one is in progress", "operation_id", running)
+		return
+	}
+	defer s.archive.release()
+	op := s.startOperation(ctx, id, OperationRetention, "", archiveSteps)
+	op.Finish(s.runArchiveJob(ctx, op, cfg, trigger))
+}
+
+// StartArchiveRun runs the archive job in the background now and returns
+// the operation ID to follow it with. The run keeps going if ctx is
+// cancelled; CancelOperation stops it.
+func (s *Service) StartArchiveRun(ctx context.Context) (string, error) {
+	s.archive.mu.Lock()
+	cfg := s.archive.cfg
+	s.archive.mu.Unlock()
+	if cfg == nil {
+		return "", ErrArchiveNotScheduled
+	}
+
+	id := uuid.New().String()
+	if running, ok := s.archive.claim(id); !ok {
+		return "", fmt.Errorf("%w (operation %s)", ErrArchiveRunning, running)
 	}
+	op := s.startOperation(ctx, id, OperationRetention, "", archiveSteps)
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		defer s.archive.release()
+		return s.runArchiveJob(ctx, op, *cfg, ArchiveManual)
+	})
+	return id, nil
 }
 
-// runArchiveJob performs one archive + purge cycle.
-func (s *Service) runArchiveJob(ctx context.Context, cfg ArchiveConfig) {
-	slog.Debug("archive job started")
-	start := time.Now()
+// Retention run steps.
+const (
+	stepArchive = "archive" // Move old audit entries to the archive
+	stepPurge   = "purge"   // Delete archive entries past retention
+	stepCompact = "compact" // Compact old failed-row data
+	stepRecycle = "recycle" // Purge expired soft-deleted rows
+	stepExpire  = "expire"  // Delete retention archives past their grace period
+)
+
+// archiveSteps are the steps of a retention operation.
+var archiveSteps = []OperationStep{
+	{Name: stepArchive, Weight: 2},
+	{Name: stepPurge, Weight: 1},
+	{Name: stepCompact, Weight: 1},
+	{Name: stepRecycle, Weight: 1},
+	{Name: stepExpire, Weight: 1},
+}
+
+// runArchiveJob performs one archive + purge cycle for op and records it
+// in archive_runs. A failing step is logged and the remaining steps still
+// run; the error says how many failed.
+func (s *Service) runArchiveJob(ctx context.Context, op *Operation, cfg ArchiveConfig, trigger ArchiveTrigger) error {
+	slog.Debug("archive job started", "trigger", trigger)
+	run := &ArchiveRun{ID: op.ID(), Trigger: trigger, Initiator: archiveInitiator(ctx), StartedAt: time.Now()}
+	s.recordArchiveRun(ctx, run)
 
 	// Archive old entries from hot to cold storage
+	op.Begin(stepArchive)
 	archiveStart := time.Now()
 	archived, err := s.archiveOldAuditLogs(ctx, cfg.HotRetentionDays, cfg.BatchSize)
 	if err != nil {
 		slog.Error("archive failed", "error", err)
 	} else {
+		run.EntriesArchived = archived
+		op.SetDetail(stepArchive, fmt.Sprintf("%d entries archived", archived))
 		slog.Info("archived audit log entries",
 			"entries_archived", archived,
 			"duration_ms", time.Since(archiveStart).Milliseconds(),
 		)
 	}
+	op.EndStep(stepArchive, err)
 
 	// Purge very old archives
+	op.Begin(stepPurge)
 	purgeStart := time.Now()
 	purged, err := s.purgeOldArchives(ctx, cfg.ArchiveRetentionYears)
 	if err != nil {
 		slog.Error("purge failed", "error", err)
 	} else {
+		run.EntriesPurged = purged
+		op.SetDetail(stepPurge, fmt.Sprintf("%d entries purged", purged))
 		slog.Info("purged old archive entries",
 			"entries_purged", purged,
 			"duration_ms", time.Since(purgeStart).Milliseconds(),
 		)
 	}
+	op.EndStep(stepPurge, err)
+
+	// Compact old failed-row data
+	op.Begin(stepCompact)
+	run.FailedRowsCompacted, err = s.runFailedRowCompaction(ctx, cfg)
+	op.SetDetail(stepCompact, fmt.Sprintf("%d failed rows compacted", run.FailedRowsCompacted))
+	op.EndStep(stepCompact, err)
+
+	// Purge expired recycle bin rows
+	op.Begin(stepRecycle)
+	run.RecycledRowsPurged, err = s.runRecyclePurge(ctx, cfg)
+	op.SetDetail(stepRecycle, fmt.Sprintf("%d recycled rows purged", run.RecycledRowsPurged))
+	op.EndStep(stepRecycle, err)
+
+	// Delete retention archives past their grace period
+	op.Begin(stepExpire)
+	expired, err := s.runRetentionExpiry()
+	run.ArchivesExpired = int64(expired)
+	op.SetDetail(stepExpire, fmt.Sprintf("%d retention archives deleted", expired))
+	op.EndStep(stepExpire, err)
 
-	slog.Info("archive job completed", "duration_ms", time.Since(start).Milliseconds())
+	err = failedStepsError(op.Progress(), "steps")
+	s.finishArchiveRun(ctx, run, err)
+	slog.Info("archive job completed",
+		"trigger", trigger,
+		"duration_ms", *run.DurationMs,
+		"entries_archived", run.EntriesArchived,
+		"entries_purged", run.EntriesPurged,
+	)
+	return err
 }
 
 // archiveOldAuditLogs moves audit entries older than daysToKeep to cold storage.
@@ -100,8 +288,23 @@ func (s *Service) archiveOldAuditLogs(ctx context.Context, daysToKeep, batchSize
 	return int64(result), nil
 }
 
-// purgeOldArchives deletes archived entries older than yearsToKeep.
+// purgeOldArchives deletes archived entries older than yearsToKeep. With
+// retention archives enabled the entries are archived first, and nothing
+// is deleted if that fails.
 func (s *Service) purgeOldArchives(ctx context.Context, yearsToKeep int) (int64, error) {
+	if s.retention != nil {
+		cutoff := time.Now().AddDate(-yearsToKeep, 0, 0)
+		archive, err := s.archiveRows(ctx, RetentionAuditArchive, "audit_log_archive", "created_at < $1", cutoff, cutoff)
+		if err != nil || archive == nil {
+			return 0, err
+		}
+		tag, err := s.pool.Exec(ctx, "DELETE FROM audit_log_archive WHERE created_at < $1", cutoff)
+		if err != nil {
+			return 0, err
+		}
+		return tag.RowsAffected(), nil
+	}
+
 	result, err := db.New(s.pool).PurgeOldArchives(ctx, int32(yearsToKeep))
 	if err != nil {
 		return 0, err
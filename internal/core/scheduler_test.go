@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArchiveScheduler_OneRunAtATime(t *testing.T) {
+	var a archiveScheduler
+	if _, ok := a.claim("op1"); !ok {
+		t.Fatal("first claim refused")
+	}
+	if running, ok := a.claim("op2"); ok || running != "op1" {
+		t.Errorf("second claim = %q, %v; want op1, false", running, ok)
+	}
+	a.release()
+	if _, ok := a.claim("op2"); !ok {
+		t.Error("claim after release refused")
+	}
+}
+
+func TestStartArchiveRun_Errors(t *testing.T) {
+	s := &Service{operations: make(map[string]*Operation)}
+	if _, err := s.StartArchiveRun(context.Background()); !errors.Is(err, ErrArchiveNotScheduled) {
+		t.Errorf("before the scheduler started: err = %v, want ErrArchiveNotScheduled", err)
+	}
+
+	s.archive.cfg = &ArchiveConfig{CheckInterval: time.Hour}
+	s.archive.claim("scheduled")
+	if _, err := s.StartArchiveRun(context.Background()); !errors.Is(err, ErrArchiveRunning) {
+		t.Errorf("during a run: err = %v, want ErrArchiveRunning", err)
+	}
+	if len(s.operations) != 0 {
+		t.Errorf("%d operations started", len(s.operations))
+	}
+}
@@ -0,0 +1,510 @@
+package core
+
+// schema_evolution.go keeps renamed and removed columns from breaking things
+// silently.
+//
+// A table declares its new shape in the registry: FieldSpecs hold the
+// current columns, Renames map old header names to current ones, and
+// DroppedColumns lists database columns that are gone. From that:
+//
+//   - Old names keep resolving in upload mappings, import templates and
+//     sort/filter parameters (saved views), and each use is counted
+//   - GenerateSchemaMigration diffs the registry against the live database
+//     and renders a goose migration (see cmd/schemamigrate)
+//   - DeprecatedColumnReport lists old names still in use, so the Renames
+//     entry can be deleted once nothing refers to it
+//
+// Renames always point at the current name. When a column is renamed
+// twice, update the first entry's To rather than chaining.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// validateSchemaEvolution checks a definition's Renames and DroppedColumns.
+func validateSchemaEvolution(def TableDefinition) error {
+	current := currentColumnNames(def)
+	seen := make(map[string]bool)
+	for _, r := range def.Renames {
+		if r.From == "" || r.To == "" {
+			return fmt.Errorf("rename needs both From and To")
+		}
+		if !containsFold(current, r.To) {
+			return fmt.Errorf("rename %q -> %q: %q is not a current column", r.From, r.To, r.To)
+		}
+		if containsFold(current, r.From) {
+			return fmt.Errorf("rename %q -> %q: %q is still a current column", r.From, r.To, r.From)
+		}
+		key := strings.ToLower(r.From)
+		if seen[key] {
+			return fmt.Errorf("column %q renamed more than once", r.From)
+		}
+		seen[key] = true
+	}
+
+	dbCols := resolveDBColumns(current, def.FieldSpecs)
+	for _, col := range def.DroppedColumns {
+		if containsFold(dbCols, col) {
+			return fmt.Errorf("dropped column %q is still a current column", col)
+		}
+	}
+	return nil
+}
+
+// currentColumnNames returns the header names of a definition's columns.
+// Info.Columns is derived from FieldSpecs at registration, so this also
+// works on definitions that have not been registered yet.
+func currentColumnNames(def TableDefinition) []string {
+	if len(def.FieldSpecs) == 0 {
+		return def.Info.Columns
+	}
+	names := make([]string, len(def.FieldSpecs))
+	for i, spec := range def.FieldSpecs {
+		names[i] = spec.Name
+	}
+	return names
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveColumn returns the current name of col, following Renames.
+// renamed reports whether col was an old name. Unknown columns are returned
+// unchanged with renamed false.
+func resolveColumn(def TableDefinition, col string) (name string, renamed bool) {
+	for _, current := range currentColumnNames(def) {
+		if strings.EqualFold(current, col) {
+			return current, false
+		}
+	}
+	for _, r := range def.Renames {
+		if strings.EqualFold(r.From, col) {
+			return r.To, true
+		}
+	}
+	return col, false
+}
+
+// ResolveColumnName returns the current name of col in def, following
+// Renames, and counts the use of an old name under source (e.g. "sort",
+// "filter") for DeprecatedColumnReport.
+func ResolveColumnName(def TableDefinition, col, source string) string {
+	name, renamed := resolveColumn(def, col)
+	if renamed {
+		deprecatedUses.record(def.Info.Key, col, source)
+	}
+	return name
+}
+
+// resolveMappingColumns returns mapping with old column names replaced by
+// current ones. A current name wins over an old name for the same column.
+func resolveMappingColumns(def TableDefinition, mapping map[string]int, source string) map[string]int {
+	if len(def.Renames) == 0 || len(mapping) == 0 {
+		return mapping
+	}
+	resolved := make(map[string]int, len(mapping))
+	for col, idx := range mapping {
+		if _, renamed := resolveColumn(def, col); !renamed {
+			resolved[col] = idx
+		}
+	}
+	for col, idx := range mapping {
+		name, renamed := resolveColumn(def, col)
+		if !renamed {
+			continue
+		}
+		deprecatedUses.record(def.Info.Key, col, source)
+		if _, ok := resolved[name]; !ok {
+			resolved[name] = idx
+		}
+	}
+	return resolved
+}
+
+// =============================================================================
+// Usage tracking
+// =============================================================================
+
+// DeprecatedUse counts requests that referred to a column by an old name
+// since the server started.
+type DeprecatedUse struct {
+	Source   string    `json:"source"` // "upload mapping", "sort", "filter", ...
+	Count    int64     `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+type deprecatedUseKey struct {
+	table, column, source string
+}
+
+// deprecatedUseTracker counts uses of old column names. Like the registry it
+// is process-wide, since old names are resolved outside any Service.
+type deprecatedUseTracker struct {
+	mu   sync.Mutex
+	uses map[deprecatedUseKey]*DeprecatedUse
+}
+
+var deprecatedUses = &deprecatedUseTracker{uses: make(map[deprecatedUseKey]*DeprecatedUse)}
+
+func (t *deprecatedUseTracker) record(table, column, source string) {
+	key := deprecatedUseKey{table, strings.ToLower(column), source}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.uses[key]
+	if !ok {
+		u = &DeprecatedUse{Source: source}
+		t.uses[key] = u
+	}
+	u.Count++
+	u.LastUsed = time.Now()
+}
+
+// forColumn returns the uses of an old column name, by source.
+func (t *deprecatedUseTracker) forColumn(table, column string) []DeprecatedUse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var uses []DeprecatedUse
+	for key, u := range t.uses {
+		if key.table == table && key.column == strings.ToLower(column) {
+			uses = append(uses, *u)
+		}
+	}
+	sort.Slice(uses, func(i, j int) bool { return uses[i].Source < uses[j].Source })
+	return uses
+}
+
+// =============================================================================
+// Deprecated mapping report
+// =============================================================================
+
+// DeprecatedColumn is one declared rename and what still refers to its old name.
+type DeprecatedColumn struct {
+	TableKey  string          `json:"tableKey"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Templates []TemplateRef   `json:"templates"` // Import templates mapping the old name
+	Uses      []DeprecatedUse `json:"uses"`      // Requests since startup
+	InUse     bool            `json:"inUse"`
+}
+
+// TemplateRef identifies an import template.
+type TemplateRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DeprecatedColumnReport lists every declared rename with the import
+// templates and recent requests still using the old name. Renames that are
+// not in use can be removed from the registry.
+func (s *Service) DeprecatedColumnReport(ctx context.Context) ([]DeprecatedColumn, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	report := []DeprecatedColumn{}
+	for _, def := range All() {
+		if len(def.Renames) == 0 {
+			continue
+		}
+
+		templates, err := s.storedTemplateMappings(ctx, def.Info.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range def.Renames {
+			entry := DeprecatedColumn{
+				TableKey:  def.Info.Key,
+				From:      r.From,
+				To:        r.To,
+				Templates: []TemplateRef{},
+				Uses:      deprecatedUses.forColumn(def.Info.Key, r.From),
+			}
+			if entry.Uses == nil {
+				entry.Uses = []DeprecatedUse{}
+			}
+			for _, t := range templates {
+				for col := range t.mapping {
+					if strings.EqualFold(col, r.From) {
+						entry.Templates = append(entry.Templates, t.TemplateRef)
+						break
+					}
+				}
+			}
+			entry.InUse = len(entry.Templates) > 0 || len(entry.Uses) > 0
+			report = append(report, entry)
+		}
+	}
+	return report, nil
+}
+
+// =============================================================================
+// Migration generation
+// =============================================================================
+
+// SchemaMigration is a generated goose migration.
+type SchemaMigration struct {
+	Up       []string // SQL statements, applied in order
+	Down     []string // SQL statements reverting Up, applied in order
+	Warnings []string // Differences that need a manual decision
+}
+
+// Empty reports whether the database already matches the registry.
+func (m SchemaMigration) Empty() bool {
+	return len(m.Up) == 0 && len(m.Warnings) == 0
+}
+
+// SQL renders the migration in goose format.
+func (m SchemaMigration) SQL() string {
+	var b strings.Builder
+	b.WriteString("-- Generated by cmd/schemamigrate from the table registry.\n")
+	b.WriteString("-- Update sql/queries to match and run sqlc generate before applying.\n")
+	for _, w := range m.Warnings {
+		b.WriteString("-- WARNING: " + w + "\n")
+	}
+	b.WriteString("\n-- +goose Up\n")
+	for _, stmt := range m.Up {
+		b.WriteString(stmt + ";\n")
+	}
+	b.WriteString("\n-- +goose Down\n")
+	for _, stmt := range m.Down {
+		b.WriteString(stmt + ";\n")
+	}
+	return b.String()
+}
+
+// append adds another migration's statements. Down statements are
+// prepended so the combined Down reverts in reverse order.
+func (m *SchemaMigration) append(o SchemaMigration) {
+	m.Up = append(m.Up, o.Up...)
+	m.Down = append(o.Down, m.Down...)
+	m.Warnings = append(m.Warnings, o.Warnings...)
+}
+
+// GenerateSchemaMigration compares the registered shape of each table in
+// tableKeys (all tables if empty) with the database and returns the
+// migration that brings the database in line: renamed columns are renamed,
+// new FieldSpecs added, DroppedColumns dropped, and import templates
+// rewritten to use current column names.
+func (s *Service) GenerateSchemaMigration(ctx context.Context, tableKeys ...string) (SchemaMigration, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	var defs []TableDefinition
+	if len(tableKeys) == 0 {
+		defs = All()
+	}
+	for _, key := range tableKeys {
+		def, ok := Get(key)
+		if !ok {
+			return SchemaMigration{}, fmt.Errorf("unknown table: %s", key)
+		}
+		defs = append(defs, def)
+	}
+
+	var m SchemaMigration
+	for _, def := range defs {
+		if def.IsView() {
+			continue // Recreated from its SELECT by SyncViews
+		}
+		existing, err := s.tableColumnTypes(ctx, def.Info.Key)
+		if err != nil {
+			return SchemaMigration{}, err
+		}
+		if existing == nil {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("table %s does not exist; create it with a hand-written migration", def.Info.Key))
+			continue
+		}
+		templates, err := s.storedTemplateMappings(ctx, def.Info.Key)
+		if err != nil {
+			return SchemaMigration{}, err
+		}
+		templateCols := make(map[string]bool)
+		for _, t := range templates {
+			for col := range t.mapping {
+				templateCols[col] = true
+			}
+		}
+		m.append(planSchemaMigration(def, existing, templateCols))
+	}
+	return m, nil
+}
+
+// tableColumnTypes returns the columns of a table with their SQL types, or
+// nil if the table does not exist.
+func (s *Service) tableColumnTypes(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+		FROM pg_attribute a
+		WHERE a.attrelid = to_regclass($1)
+		  AND a.attnum > 0
+		  AND NOT a.attisdropped`,
+		quoteIdentifier(table),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var cols map[string]string
+	for rows.Next() {
+		var name, typ string
+		if err := rows.Scan(&name, &typ); err != nil {
+			return nil, fmt.Errorf("scan column: %w", err)
+		}
+		if cols == nil {
+			cols = make(map[string]string)
+		}
+		cols[name] = typ
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return cols, nil
+}
+
+// storedTemplate is an import template's column mapping as stored, before
+// old column names are resolved.
+type storedTemplate struct {
+	TemplateRef
+	mapping map[string]int
+}
+
+// storedTemplateMappings returns the stored column mappings of a table's
+// import templates.
+func (s *Service) storedTemplateMappings(ctx context.Context, tableKey string) ([]storedTemplate, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, name, column_mapping FROM import_templates WHERE table_key = $1 ORDER BY name`, tableKey)
+	if err != nil {
+		return nil, fmt.Errorf("read templates of %s: %w", tableKey, err)
+	}
+	defer rows.Close()
+
+	var templates []storedTemplate
+	for rows.Next() {
+		var t storedTemplate
+		var id pgtype.UUID
+		var raw []byte
+		if err := rows.Scan(&id, &t.Name, &raw); err != nil {
+			return nil, fmt.Errorf("scan template: %w", err)
+		}
+		if json.Unmarshal(raw, &t.mapping) != nil {
+			continue // Skip invalid templates, as ListTemplates does
+		}
+		t.ID = PgUUIDToString(id)
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return templates, nil
+}
+
+// planSchemaMigration diffs one table's definition against its existing
+// columns (database name -> SQL type) and the columns its import templates
+// map.
+func planSchemaMigration(def TableDefinition, existing map[string]string, templateCols map[string]bool) SchemaMigration {
+	var m SchemaMigration
+	table := quoteIdentifier(def.Info.Key)
+	has := func(col string) bool { _, ok := existing[col]; return ok }
+
+	// Renames first, so a renamed column is not also added
+	for _, r := range def.Renames {
+		from := r.FromDB
+		if from == "" {
+			from = toDBColumnName(r.From)
+		}
+		to := resolveDBColumn(r.To, def.FieldSpecs)
+		switch {
+		case from == to || !has(from):
+			// Header-only rename, or already applied
+		case has(to):
+			m.Warnings = append(m.Warnings, fmt.Sprintf("%s has both %s and %s; move the data and drop %s by hand",
+				def.Info.Key, from, to, from))
+		default:
+			m.Up = append(m.Up, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, quoteIdentifier(from), quoteIdentifier(to)))
+			m.Down = append([]string{fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, quoteIdentifier(to), quoteIdentifier(from))}, m.Down...)
+			existing[to] = existing[from]
+			delete(existing, from)
+		}
+	}
+
+	for _, spec := range def.FieldSpecs {
+		col := resolveDBColumn(spec.Name, def.FieldSpecs)
+		if has(col) {
+			continue
+		}
+		m.Up = append(m.Up, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, quoteIdentifier(col), fieldSQLType(spec.Type)))
+		m.Down = append([]string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, quoteIdentifier(col))}, m.Down...)
+	}
+
+	if def.SoftDelete && !has(softDeleteColumn) {
+		m.Up = append(m.Up,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s TIMESTAMPTZ", table, quoteIdentifier(softDeleteColumn)),
+			softDeleteIndexSQL(def.Info.Key))
+		m.Down = append([]string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, quoteIdentifier(softDeleteColumn))}, m.Down...)
+	}
+
+	for _, col := range def.DroppedColumns {
+		typ, ok := existing[col]
+		if !ok {
+			continue
+		}
+		m.Up = append(m.Up, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, quoteIdentifier(col)))
+		m.Down = append([]string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, quoteIdentifier(col), typ)}, m.Down...)
+	}
+
+	// Point templates at current names, so the rename can later be removed
+	for _, r := range def.Renames {
+		if !templateCols[r.From] {
+			continue
+		}
+		m.Up = append(m.Up, renameTemplateColumnSQL(def.Info.Key, r.From, r.To))
+		m.Down = append([]string{renameTemplateColumnSQL(def.Info.Key, r.To, r.From)}, m.Down...)
+	}
+
+	return m
+}
+
+// renameTemplateColumnSQL renames a key in the column mappings of a table's
+// import templates, unless the new key is already mapped.
+func renameTemplateColumnSQL(tableKey, from, to string) string {
+	return fmt.Sprintf(`UPDATE import_templates
+SET column_mapping = (column_mapping - %[2]s) || jsonb_build_object(%[3]s, column_mapping -> %[2]s),
+    updated_at = NOW()
+WHERE table_key = %[1]s AND column_mapping ? %[2]s AND NOT column_mapping ? %[3]s`,
+		quoteLiteral(tableKey), quoteLiteral(from), quoteLiteral(to))
+}
+
+// fieldSQLType is the column type used for a new column of a field type,
+// matching the existing table migrations.
+func fieldSQLType(t FieldType) string {
+	switch t {
+	case FieldDate:
+		return "DATE"
+	case FieldNumeric:
+		return "NUMERIC"
+	case FieldBool:
+		return "BOOLEAN"
+	default:
+		return "TEXT"
+	}
+}
+
+// quoteLiteral quotes a string as a SQL literal, for generated migrations.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
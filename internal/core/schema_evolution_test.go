@@ -0,0 +1,169 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func evolvedCustomers() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "evolved_customers"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Customer ID", DBColumn: "customer_id", Type: FieldText},
+			{Name: "Account Name", DBColumn: "account_name", Type: FieldText},
+			{Name: "Region", Type: FieldText},
+			{Name: "Signed", DBColumn: "signed_date", Type: FieldDate},
+		},
+		Renames: []ColumnRename{
+			{From: "Customer Name", To: "Account Name"},
+			{From: "Territory", To: "Region", FromDB: "sales_territory"},
+		},
+		DroppedColumns: []string{"legacy_code"},
+	}
+}
+
+func TestValidateSchemaEvolution(t *testing.T) {
+	if err := validateSchemaEvolution(evolvedCustomers()); err != nil {
+		t.Fatalf("valid definition: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*TableDefinition)
+		want   string
+	}{
+		{"unknown target", func(d *TableDefinition) { d.Renames[0].To = "Nope" }, "not a current column"},
+		{"old name still current", func(d *TableDefinition) { d.Renames[0].From = "region" }, "still a current column"},
+		{"renamed twice", func(d *TableDefinition) { d.Renames[1].From = "customer name" }, "renamed more than once"},
+		{"dropped column current", func(d *TableDefinition) { d.DroppedColumns = []string{"signed_date"} }, "still a current column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := evolvedCustomers()
+			tt.modify(&def)
+			err := validateSchemaEvolution(def)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveColumnName_CountsOldNames(t *testing.T) {
+	def := evolvedCustomers()
+
+	if got := ResolveColumnName(def, "customer name", "sort"); got != "Account Name" {
+		t.Errorf("old name resolved to %q", got)
+	}
+	if got := ResolveColumnName(def, "REGION", "sort"); got != "Region" {
+		t.Errorf("current name resolved to %q", got)
+	}
+	if got := ResolveColumnName(def, "Unknown", "sort"); got != "Unknown" {
+		t.Errorf("unknown name resolved to %q", got)
+	}
+
+	uses := deprecatedUses.forColumn(def.Info.Key, "Customer Name")
+	if len(uses) != 1 || uses[0].Source != "sort" || uses[0].Count != 1 {
+		t.Errorf("uses = %+v", uses)
+	}
+	if uses := deprecatedUses.forColumn(def.Info.Key, "Region"); len(uses) != 0 {
+		t.Errorf("current name counted: %+v", uses)
+	}
+}
+
+func TestResolveMappingColumns(t *testing.T) {
+	def := evolvedCustomers()
+	got := resolveMappingColumns(def, map[string]int{
+		"Customer ID":   0,
+		"Customer Name": 1,
+		"Territory":     2,
+		"Region":        3, // Current name wins over the old one
+	}, "upload mapping")
+
+	want := map[string]int{"Customer ID": 0, "Account Name": 1, "Region": 3}
+	if len(got) != len(want) {
+		t.Fatalf("mapping = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("mapping[%q] = %d, want %d", k, got[k], v)
+		}
+	}
+}
+
+func TestPlanSchemaMigration(t *testing.T) {
+	def := evolvedCustomers()
+	existing := map[string]string{
+		"id":              "integer",
+		"customer_id":     "text",
+		"customer_name":   "text",
+		"sales_territory": "text",
+		"legacy_code":     "character varying(20)",
+	}
+	m := planSchemaMigration(def, existing, map[string]bool{"Customer Name": true})
+
+	wantUp := []string{
+		`ALTER TABLE "evolved_customers" RENAME COLUMN "customer_name" TO "account_name"`,
+		`ALTER TABLE "evolved_customers" RENAME COLUMN "sales_territory" TO "region"`,
+		`ALTER TABLE "evolved_customers" ADD COLUMN "signed_date" DATE`,
+		`ALTER TABLE "evolved_customers" DROP COLUMN "legacy_code"`,
+	}
+	if len(m.Up) != len(wantUp)+1 {
+		t.Fatalf("up = %q", m.Up)
+	}
+	for i, want := range wantUp {
+		if m.Up[i] != want {
+			t.Errorf("up[%d] = %q, want %q", i, m.Up[i], want)
+		}
+	}
+	if tmpl := m.Up[len(wantUp)]; !strings.Contains(tmpl, "UPDATE import_templates") || !strings.Contains(tmpl, "'Customer Name'") {
+		t.Errorf("template rewrite = %q", tmpl)
+	}
+
+	// Down reverts in reverse order
+	if len(m.Down) != len(m.Up) ||
+		!strings.Contains(m.Down[1], `ADD COLUMN "legacy_code" character varying(20)`) ||
+		m.Down[len(m.Down)-1] != `ALTER TABLE "evolved_customers" RENAME COLUMN "account_name" TO "customer_name"` {
+		t.Errorf("down = %q", m.Down)
+	}
+
+	sql := m.SQL()
+	if !strings.Contains(sql, "-- +goose Up\n") || !strings.Contains(sql, "-- +goose Down\n") {
+		t.Errorf("SQL missing goose markers:\n%s", sql)
+	}
+
+	// Once applied, nothing is left to do
+	applied := map[string]string{"customer_id": "text", "account_name": "text", "region": "text", "signed_date": "date"}
+	if m := planSchemaMigration(def, applied, nil); !m.Empty() {
+		t.Errorf("applied schema still has changes: %+v", m)
+	}
+}
+
+func TestPlanSchemaMigration_SoftDelete(t *testing.T) {
+	def := evolvedCustomers()
+	def.SoftDelete = true
+	applied := map[string]string{"customer_id": "text", "account_name": "text", "region": "text", "signed_date": "date"}
+
+	m := planSchemaMigration(def, applied, nil)
+	if len(m.Up) != 2 || m.Up[0] != `ALTER TABLE "evolved_customers" ADD COLUMN "deleted_at" TIMESTAMPTZ` ||
+		len(m.Down) != 1 || m.Down[0] != `ALTER TABLE "evolved_customers" DROP COLUMN "deleted_at"` {
+		t.Errorf("migration = %+v", m)
+	}
+
+	applied["deleted_at"] = "timestamp with time zone"
+	if m := planSchemaMigration(def, applied, nil); !m.Empty() {
+		t.Errorf("applied schema still has changes: %+v", m)
+	}
+}
+
+func TestPlanSchemaMigration_ConflictWarns(t *testing.T) {
+	def := evolvedCustomers()
+	existing := map[string]string{
+		"customer_id": "text", "customer_name": "text", "account_name": "text",
+		"region": "text", "signed_date": "date",
+	}
+	m := planSchemaMigration(def, existing, nil)
+	if len(m.Up) != 0 || len(m.Warnings) != 1 || !strings.Contains(m.Warnings[0], "both customer_name and account_name") {
+		t.Errorf("migration = %+v", m)
+	}
+}
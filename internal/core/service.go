@@ -24,8 +24,47 @@ type Service struct {
 	// uploadLimiter controls concurrent upload processing.
 	uploadLimiter *UploadLimiter
 
-	mu      sync.RWMutex
-	uploads map[string]*activeUpload
+	// uploadQuotas limits each uploader within uploadLimiter.
+	uploadQuotas *UploadQuotas
+
+	// spool stores uploads encrypted on disk; nil if spooling is disabled.
+	spool *Spool
+
+	// retention keeps copies of purged rows; nil if pre-purge export is disabled.
+	retention *RetentionStore
+
+	// exportJobs holds the files written by background exports.
+	exportJobs *ExportJobStore
+
+	// tableEvents pushes table changes to SubscribeTableEvents.
+	tableEvents *tableEventHub
+
+	// activity pushes uploads, rollbacks, resets and bulk edits to
+	// SubscribeEvents.
+	activity *activityHub
+
+	// queryCache holds table stats and aggregations between changes.
+	queryCache queryCache
+
+	// stats coalesces post-upload extended statistics refreshes.
+	stats statsRefresher
+
+	// keyAlerts tracks which tables are over the key violation threshold.
+	keyAlerts keyViolationAlerts
+
+	// resumables tracks chunked browser uploads that survive a page refresh.
+	resumables resumableSessions
+
+	// actions tracks the custom table actions running.
+	actions runningActions
+
+	// archive is the archive scheduler's state (see scheduler.go).
+	archive archiveScheduler
+
+	mu         sync.RWMutex
+	uploads    map[string]*activeUpload
+	batches    map[string]*uploadBatch
+	operations map[string]*Operation
 }
 
 // UploadTimeout returns the configured upload timeout.
@@ -38,18 +77,62 @@ func (s *Service) ResetTimeout() time.Duration {
 	return s.cfg.Upload.ResetTimeout
 }
 
+// opKind classifies Service operations for per-operation query timeouts.
+type opKind int
+
+const (
+	opQuery     opKind = iota // Lookups and paginated reads
+	opAggregate               // Counts, aggregations, whole-table scans
+	opMutation                // Edits, deletes, rollbacks
+)
+
+// withOpTimeout bounds ctx by the configured timeout for the operation kind.
+// An earlier deadline already on ctx (e.g. from HTTP middleware) still wins.
+// A zero timeout leaves ctx unbounded. Callers must always call cancel.
+func (s *Service) withOpTimeout(ctx context.Context, kind opKind) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+	switch kind {
+	case opQuery:
+		timeout = s.cfg.Database.QueryTimeout
+	case opAggregate:
+		timeout = s.cfg.Database.AggregateTimeout
+	case opMutation:
+		timeout = s.cfg.Database.MutationTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 type activeUpload struct {
 	ID         string
 	TableKey   string
 	FileName   string
 	Cancel     context.CancelFunc
 	Progress   UploadProgress
-	ProgressMu sync.RWMutex   // Protects Progress field from concurrent access
+	ProgressMu sync.RWMutex // Protects Progress field from concurrent access
 	Result     *UploadResult
 	Done       chan struct{}
 	Listeners  []chan UploadProgress
-	ListenerMu sync.Mutex
-	Mapping    map[string]int // User-provided column mapping: expected column -> CSV index
+	ListenerMu sync.Mutex         // Protects Listeners, events, phases and closed
+	events     int64              // Progress notifications sent; the last one's EventID
+	phases     []UploadProgress   // Progress at each phase change, replayed by ResumeProgress
+	closed     bool               // Listeners were closed; later subscribers get only the replay
+	Mapping    map[string]int     // User-provided column mapping: expected column -> CSV index
+	Mode       UploadMode         // Resolved upload mode; never empty
+	Duplicates DuplicatePolicy    // Resolved duplicate policy; never empty
+	Dates      DateOptions        // How dates are read
+	Template   *ImportTemplate    // Template the mapping came from, checked for header drift; may be nil
+	Transforms []MappingTransform // The template's join and split transforms (see template_transforms.go)
+	Constants  []mappingConstant  // Columns the mapping fills with a constant (see mapping_constants.go)
+	DryRun     bool               // Roll back instead of committing (see DryRunUpload)
+	RowKeys    map[string][]int   // Dry run only: unique key -> lines of valid rows
+	Omitted    []string           // Database columns a selective import leaves out (see upload_columns.go)
+	Deleted    []deletedRow       // Delete mode: rows deleted so far (see upload_delete.go)
+	BatchID    string             // Upload batch this file belongs to; empty for single uploads
+	RecordID   string             // csv_uploads ID once created; read only after Done is closed
+	Op         *Operation         // Step-based progress; nil for dry runs
 }
 
 // setProgress updates the progress atomically using the provided modifier function.
@@ -75,14 +158,53 @@ func NewService(pool *pgxpool.Pool, cfg *config.Config) (*Service, error) {
 
 	uploadsDir := filepath.Join(wd, "accounting", "uploads")
 
-	return &Service{
+	var spool *Spool
+	if len(cfg.Upload.SpoolKeys) > 0 {
+		dir := cfg.Upload.SpoolDir
+		if dir == "" {
+			dir = uploadsDir
+		}
+		if spool, err = NewSpool(dir, cfg.Upload.SpoolKeys); err != nil {
+			return nil, fmt.Errorf("create upload spool: %w", err)
+		}
+	}
+
+	var retention *RetentionStore
+	if dir := cfg.Archive.RetentionExportDir; dir != "" {
+		if retention, err = NewRetentionStore(dir, cfg.Archive.RetentionExportGraceDays); err != nil {
+			return nil, fmt.Errorf("create retention archive store: %w", err)
+		}
+	}
+
+	exportDir := cfg.Query.ExportJobDir
+	if exportDir == "" {
+		exportDir = filepath.Join(wd, "accounting", "exports")
+	}
+	exportJobs, err := NewExportJobStore(exportDir, cfg.Query.ExportJobTTL)
+	if err != nil {
+		return nil, fmt.Errorf("create export job store: %w", err)
+	}
+
+	quotas := NewUploadQuotas(cfg.Upload.MaxConcurrentPerUploader, cfg.Upload.DailyUploadsPerUploader,
+		cfg.Upload.DailyBytesPerUploader, cfg.Upload.MaxWaitTime)
+
+	s := &Service{
 		pool:          pool,
 		cfg:           cfg,
 		uploadsDir:    uploadsDir,
 		Audit:         NewAuditService(pool),
 		uploadLimiter: NewUploadLimiter(cfg.Upload.MaxConcurrent, cfg.Upload.MaxWaitTime),
+		uploadQuotas:  quotas,
+		spool:         spool,
+		retention:     retention,
+		exportJobs:    exportJobs,
 		uploads:       make(map[string]*activeUpload),
-	}, nil
+		batches:       make(map[string]*uploadBatch),
+		operations:    make(map[string]*Operation),
+		activity:      newActivityHub(),
+	}
+	s.tableEvents = newTableEventHub(tableEventDebounce, s.GetTableStats)
+	return s, nil
 }
 
 // Config returns the service configuration.
@@ -90,6 +212,11 @@ func (s *Service) Config() *config.Config {
 	return s.cfg
 }
 
+// Spool returns the encrypted upload spool, or nil if spooling is disabled.
+func (s *Service) Spool() *Spool {
+	return s.spool
+}
+
 // UploadLimiterStatus returns the current state of the upload limiter.
 // Used for monitoring and the /api/upload-status endpoint.
 func (s *Service) UploadLimiterStatus() UploadLimiterStatus {
@@ -102,14 +229,22 @@ func (s *Service) WaitForUploads(ctx context.Context) error {
 	return s.uploadLimiter.WaitForDrain(ctx)
 }
 
-// notifyProgress sends progress updates to all listeners.
+// notifyProgress sends progress updates to all listeners. Each update
+// gets the next event ID, and phase changes are kept for ResumeProgress.
 func (upload *activeUpload) notifyProgress() {
 	// Get thread-safe copy of progress before acquiring listener lock
 	progress := upload.getProgress()
+	syncUploadOperation(upload.Op, progress)
 
 	upload.ListenerMu.Lock()
 	defer upload.ListenerMu.Unlock()
 
+	upload.events++
+	progress.EventID = upload.events
+	if n := len(upload.phases); n == 0 || upload.phases[n-1].Phase != progress.Phase {
+		upload.phases = append(upload.phases, progress)
+	}
+
 	for _, ch := range upload.Listeners {
 		select {
 		case ch <- progress:
@@ -128,6 +263,7 @@ func (upload *activeUpload) closeListeners() {
 		close(ch)
 	}
 	upload.Listeners = nil
+	upload.closed = true
 }
 
 // cleanup removes the upload from tracking after a delay.
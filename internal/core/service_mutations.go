@@ -5,82 +5,170 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// Reset deletes all data from a specific table.
+// Reset deletes all data from a specific table. It runs as a reset
+// operation, deleting in batches within the reset timeout; see StartReset
+// to run it in the background.
 func (s *Service) Reset(ctx context.Context, tableKey string) error {
+	def, err := resetTarget(tableKey)
+	if err != nil {
+		return err
+	}
+	if err := s.checkLegalHold(ctx, def, "reset", "TRUE"); err != nil {
+		return err
+	}
+	if err := s.requireResetApproval(ctx, def); err != nil {
+		return err
+	}
+	op := s.StartOperation(ctx, OperationReset, tableKey, resetSteps(def))
+	err = s.runReset(ctx, op, def)
+	op.Finish(err)
+	return err
+}
+
+// StartReset runs Reset in the background and returns the operation ID to
+// follow it with. The reset keeps running if ctx is cancelled;
+// CancelOperation stops it after the current batch.
+func (s *Service) StartReset(ctx context.Context, tableKey string) (string, error) {
+	def, err := resetTarget(tableKey)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkLegalHold(ctx, def, "reset", "TRUE"); err != nil {
+		return "", err
+	}
+	if err := s.requireResetApproval(ctx, def); err != nil {
+		return "", err
+	}
+	op := s.StartOperation(ctx, OperationReset, tableKey, resetSteps(def))
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		return s.runReset(ctx, op, def)
+	})
+	return op.ID(), nil
+}
+
+// resetTarget returns the definition of a table that may be reset.
+func resetTarget(tableKey string) (TableDefinition, error) {
 	def, ok := Get(tableKey)
 	if !ok {
-		return fmt.Errorf("unknown table: %s", tableKey)
+		return TableDefinition{}, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return TableDefinition{}, err
+	}
+	return def, nil
+}
 
-	// Get row count before reset for audit logging
-	rowCount, _ := countTable(ctx, s.pool, tableKey)
+// resetSteps returns one step per table, named by table key.
+func resetSteps(defs ...TableDefinition) []OperationStep {
+	steps := make([]OperationStep, len(defs))
+	for i, def := range defs {
+		steps[i] = OperationStep{Name: def.Info.Key, Weight: 1}
+	}
+	return steps
+}
 
-	resetCtx, cancel := context.WithTimeout(ctx, s.ResetTimeout())
+// runReset empties one table under op, within the reset timeout.
+func (s *Service) runReset(ctx context.Context, op *Operation, def TableDefinition) error {
+	ctx, cancel := context.WithTimeout(ctx, s.ResetTimeout())
 	defer cancel()
+	deleted, err := s.resetTable(ctx, s.pool, op, def)
+	s.logTableReset(ctx, def.Info.Key, deleted, "", err)
+	return err
+}
 
-	if err := def.Reset(resetCtx, s.pool); err != nil {
-		return err
+// resetTable empties a table through db as the step of op named by its key
+// and returns the rows deleted. Rows go in batches; the table's own Reset
+// then clears anything inserted meanwhile.
+func (s *Service) resetTable(ctx context.Context, db DBTX, op *Operation, def TableDefinition) (int64, error) {
+	tableKey := def.Info.Key
+	op.Begin(tableKey)
+	deleted, err := s.deleteInBatches(ctx, db, op, tableKey, tableKey, "TRUE")
+	if err == nil {
+		err = def.Reset(ctx, db)
+	}
+	op.EndStep(tableKey, err)
+	if err != nil {
+		return deleted, fmt.Errorf("reset %s: %w", tableKey, err)
 	}
+	return deleted, nil
+}
 
-	// Log audit entry for table reset
-	s.LogAudit(ctx, AuditLogParams{
+// logTableReset audits a table reset with the rows actually deleted, also
+// when it stopped part way with err, and notifies table event and activity
+// subscribers and webhooks. Resets run together share batchID.
+func (s *Service) logTableReset(ctx context.Context, tableKey string, deleted int64, batchID string, err error) {
+	if err != nil && deleted == 0 {
+		return
+	}
+	s.notifyTableReset(tableKey, deleted, batchID, err)
+	params := AuditLogParams{
 		Action:       ActionTableReset,
 		TableKey:     tableKey,
-		RowsAffected: int(rowCount),
+		RowsAffected: int(deleted),
+		BatchID:      batchID,
 		IPAddress:    GetIPAddressFromContext(ctx),
 		UserAgent:    GetUserAgentFromContext(ctx),
-	})
-
-	return nil
+	}
+	if err != nil {
+		params.Reason = fmt.Sprintf("reset stopped after %d rows: %v", deleted, err)
+	}
+	s.LogAudit(context.WithoutCancel(ctx), params)
 }
 
-// ResetAll deletes all data from all registered tables.
-func (s *Service) ResetAll(ctx context.Context) error {
-	resetCtx, cancel := context.WithTimeout(ctx, s.ResetTimeout())
-	defer cancel()
-
-	for _, def := range All() {
-		// Get row count before reset for audit logging
-		rowCount, _ := countTable(ctx, s.pool, def.Info.Key)
-
-		if err := def.Reset(resetCtx, s.pool); err != nil {
-			return fmt.Errorf("reset %s: %w", def.Info.Key, err)
-		}
-
-		// Log audit entry for each table reset
-		s.LogAudit(ctx, AuditLogParams{
-			Action:       ActionTableReset,
-			TableKey:     def.Info.Key,
-			RowsAffected: int(rowCount),
-			IPAddress:    GetIPAddressFromContext(ctx),
-			UserAgent:    GetUserAgentFromContext(ctx),
-		})
+// notifyTableReset tells table event and activity subscribers and webhooks
+// that deleted rows of tableKey were reset, stopping with err if not nil.
+func (s *Service) notifyTableReset(tableKey string, deleted int64, batchID string, err error) {
+	s.notifyTableChanged(tableKey, TableChangeReset)
+	s.activity.publish(ActivityEvent{Kind: ActivityReset, TableKey: tableKey, Rows: deleted})
+	reset := WebhookPayload{Event: ActivityReset, TableKey: tableKey, BatchID: batchID, RowsDeleted: deleted}
+	if err != nil {
+		reset.Error = err.Error()
 	}
-
-	return nil
+	s.sendWebhook(reset)
 }
 
 // DeleteRows deletes rows by their unique key values.
 // Keys are in format "val1|val2" for composite keys.
+// Rows of a SoftDelete table are moved to its recycle bin instead.
+// Nothing is deleted if any of the rows is under a legal hold.
 // Returns count of deleted rows.
 func (s *Service) DeleteRows(ctx context.Context, tableKey string, keys []string) (int, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return 0, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return 0, err
+	}
+	defer s.invalidateQueryCache(tableKey)
 
 	uniqueKey := def.Info.UniqueKey
 	if len(uniqueKey) == 0 {
 		return 0, fmt.Errorf("table %s has no unique key defined", tableKey)
 	}
 
+	if err := s.checkLegalHold(ctx, def, "delete", rowKeyExpr(def)+" = ANY($1)", keys); err != nil {
+		return 0, err
+	}
+
 	// Build DB column names for unique key columns
 	dbCols := resolveDBColumns(uniqueKey, def.FieldSpecs)
 
 	var totalDeleted int64
 
+	// Soft delete marks current rows instead of removing them
+	deleteSQL := "DELETE FROM %s WHERE %s"
+	if def.SoftDelete {
+		deleteSQL = "UPDATE %s SET " + quoteIdentifier(softDeleteColumn) + " = NOW() WHERE %s" + andLiveRows(def, "")
+	}
+
 	// Record deletions in history before deleting
 	for _, key := range keys {
 		if rowData, err := s.getRowData(ctx, tableKey, key); err == nil && rowData != nil {
@@ -91,9 +179,9 @@ func (s *Service) DeleteRows(ctx context.Context, tableKey string, keys []string
 	if len(uniqueKey) == 1 {
 		// Single column key - use ANY for batch efficiency
 		query := fmt.Sprintf(
-			"DELETE FROM %s WHERE %s = ANY($1)",
+			deleteSQL,
 			quoteIdentifier(tableKey),
-			quoteIdentifier(dbCols[0]),
+			quoteIdentifier(dbCols[0])+" = ANY($1)",
 		)
 		result, err := s.pool.Exec(ctx, query, keys)
 		if err != nil {
@@ -116,7 +204,7 @@ func (s *Service) DeleteRows(ctx context.Context, tableKey string, keys []string
 			}
 
 			query := fmt.Sprintf(
-				"DELETE FROM %s WHERE %s",
+				deleteSQL,
 				quoteIdentifier(tableKey),
 				strings.Join(conditions, " AND "),
 			)
@@ -148,10 +236,17 @@ type UpdateCellResult struct {
 
 // UpdateCell updates a single cell value.
 func (s *Service) UpdateCell(ctx context.Context, tableKey string, req UpdateCellRequest) (*UpdateCellResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	defer s.invalidateQueryCache(tableKey)
 
 	uniqueKey := def.Info.UniqueKey
 	if len(uniqueKey) == 0 {
@@ -226,11 +321,11 @@ func (s *Service) UpdateCell(ctx context.Context, tableKey string, req UpdateCel
 	return &UpdateCellResult{Success: true}, nil
 }
 
-// BulkEditRequest represents a request to edit multiple rows.
+// BulkEditRequest represents a request to edit multiple rows. Each edit
+// changes one column (see bulk_edit.go).
 type BulkEditRequest struct {
-	Keys   []string
-	Column string
-	Value  string
+	Keys  []string
+	Edits []BulkColumnEdit
 }
 
 // BulkEditResult contains the result of a bulk edit operation.
@@ -240,15 +335,23 @@ type BulkEditResult struct {
 	Errors  []string `json:"errors,omitempty"`
 }
 
-// BulkEditRows updates a single column across multiple rows.
+// BulkEditRows updates one or more columns across multiple rows. Rows that
+// can't take the new values are skipped and reported; the rest are updated
+// in one transaction, so a database error leaves every row unchanged.
 func (s *Service) BulkEditRows(ctx context.Context, tableKey string, req BulkEditRequest) (*BulkEditResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	defer s.invalidateQueryCache(tableKey)
 
-	uniqueKey := def.Info.UniqueKey
-	if len(uniqueKey) == 0 {
+	if len(def.Info.UniqueKey) == 0 {
 		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
 	}
 
@@ -256,74 +359,83 @@ func (s *Service) BulkEditRows(ctx context.Context, tableKey string, req BulkEdi
 		return nil, fmt.Errorf("no rows specified")
 	}
 
-	// Find the FieldSpec for this column
-	var fieldSpec *FieldSpec
-	for i := range def.FieldSpecs {
-		if strings.EqualFold(def.FieldSpecs[i].Name, req.Column) {
-			fieldSpec = &def.FieldSpecs[i]
-			break
-		}
-	}
-	if fieldSpec == nil {
-		return nil, fmt.Errorf("column not found: %s", req.Column)
+	// Validate the edits against the column types (once, not per row)
+	targets, err := bulkEditTargets(def, req)
+	if err != nil {
+		return nil, err
 	}
 
-	// Block editing unique key columns (would cause duplicates)
-	for _, uk := range uniqueKey {
-		if strings.EqualFold(uk, req.Column) {
-			return nil, fmt.Errorf("cannot bulk edit unique key column: %s", req.Column)
-		}
+	types, err := s.tableColumnTypes(ctx, tableKey)
+	if err != nil {
+		return nil, err
 	}
 
-	// Determine DB column name
-	dbCol := toDBColumnName(req.Column)
-	if fieldSpec.DBColumn != "" {
-		dbCol = fieldSpec.DBColumn
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer s.rollbackTx(ctx, tx)
 
-	// Validate value against type (once, not per row)
-	if err := validateCellValue(req.Value, *fieldSpec); err != nil {
-		return nil, fmt.Errorf("invalid value: %v", err)
+	// Read old values for history and work out the new ones
+	rows, err := s.planBulkEdit(ctx, tx, def, types, req.Keys, targets, true)
+	if err != nil {
+		return nil, err
 	}
 
 	result := &BulkEditResult{}
-
-	// Update each row
-	for _, key := range req.Keys {
-		// Get old value for history
-		oldValue, _ := s.getCellValue(ctx, tableKey, def, uniqueKey, key, dbCol)
-
-		// Skip if value is unchanged
-		if oldValue == req.Value {
-			result.Updated++ // Count as success but no actual change
-			continue
-		}
-
-		// Execute update
-		err := s.executeUpdateCell(ctx, tableKey, def, uniqueKey, key, dbCol, req.Value, fieldSpec)
-		if err != nil {
+	update := newBulkEditUpdate(def, types, targets)
+	var edited []BulkEditChange
+	for _, row := range rows {
+		if msg := bulkEditRowError(row.changes); msg != "" {
 			result.Failed++
-			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", key, err))
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", row.changes[0].RowKey, msg))
 			continue
 		}
 
-		// Record in history
-		s.RecordCellEdit(ctx, tableKey, key, req.Column, oldValue, req.Value)
+		// A row with no changed cells counts as a success
+		if update.add(row) {
+			for _, c := range row.changes {
+				if c.OldValue != c.NewValue {
+					edited = append(edited, c)
+				}
+			}
+		}
 		result.Updated++
 	}
 
+	// One UPDATE for every row, and one COPY for the history of each
+	// changed cell
+	if err := update.exec(ctx, tx); err != nil {
+		return nil, fmt.Errorf("bulk edit %s: %w", tableKey, err)
+	}
+	batchID := uuid.NewString()
+	if err := recordCellEdits(ctx, tx, tableKey, batchID, edited); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
 	// Log audit entry for bulk edit (only if any rows were actually updated)
 	if result.Updated > 0 {
+		changeSet := make([]map[string]string, len(edited))
+		for i, c := range edited {
+			changeSet[i] = map[string]string{"rowKey": c.RowKey, "column": c.Column, "oldValue": c.OldValue, "newValue": c.NewValue}
+		}
+		columns := strings.Join(req.columns(), ", ")
 		s.LogAudit(ctx, AuditLogParams{
 			Action:       ActionBulkEdit,
 			TableKey:     tableKey,
-			ColumnName:   req.Column,
-			NewValue:     req.Value,
+			ColumnName:   columns,
+			NewValue:     req.describe(),
+			RowData:      map[string]interface{}{"changes": changeSet},
 			RowsAffected: result.Updated,
+			BatchID:      batchID,
 			IPAddress:    GetIPAddressFromContext(ctx),
 			UserAgent:    GetUserAgentFromContext(ctx),
 			Reason:       fmt.Sprintf("Bulk edited %d rows", result.Updated),
 		})
+		s.activity.publish(ActivityEvent{Kind: ActivityBulkEdit, TableKey: tableKey, Column: columns, Rows: int64(result.Updated)})
 	}
 
 	return result, nil
@@ -419,10 +531,11 @@ func (s *Service) keyExistsExcludingRow(ctx context.Context, tableKey string, de
 	}
 
 	query := fmt.Sprintf(
-		"SELECT EXISTS(SELECT 1 FROM %s WHERE %s AND (%s))",
+		"SELECT EXISTS(SELECT 1 FROM %s WHERE %s AND (%s)%s)",
 		quoteIdentifier(tableKey),
 		strings.Join(conditions, " AND "),
 		strings.Join(excludeConditions, " OR "),
+		andLiveRows(def, ""),
 	)
 
 	var exists bool
@@ -434,6 +547,26 @@ func (s *Service) keyExistsExcludingRow(ctx context.Context, tableKey string, de
 	return exists, nil
 }
 
+// cellDBValue converts an edited cell value to its column type; empty is NULL.
+func cellDBValue(value string, spec *FieldSpec) interface{} {
+	if value == "" {
+		return nil
+	}
+	if spec == nil {
+		return ToPgText(value)
+	}
+	switch spec.Type {
+	case FieldNumeric:
+		return ToPgNumeric(value)
+	case FieldDate:
+		return ToPgDate(value)
+	case FieldBool:
+		return ToPgBool(value)
+	default:
+		return ToPgText(value)
+	}
+}
+
 // executeUpdateCell performs the actual database update.
 func (s *Service) executeUpdateCell(ctx context.Context, tableKey string, def TableDefinition, uniqueKey []string, rowKey, dbCol, value string, spec *FieldSpec) error {
 	keyParts := strings.Split(rowKey, "|")
@@ -444,40 +577,22 @@ func (s *Service) executeUpdateCell(ctx context.Context, tableKey string, def Ta
 	// Build DB column names for unique key
 	dbKeyCols := resolveDBColumns(uniqueKey, def.FieldSpecs)
 
-	// Convert value to appropriate type
-	var dbValue interface{}
-	if value == "" {
-		dbValue = nil
-	} else if spec != nil {
-		switch spec.Type {
-		case FieldNumeric:
-			dbValue = ToPgNumeric(value)
-		case FieldDate:
-			dbValue = ToPgDate(value)
-		case FieldBool:
-			dbValue = ToPgBool(value)
-		default:
-			dbValue = ToPgText(value)
-		}
-	} else {
-		dbValue = ToPgText(value)
-	}
-
 	// Build parameterized query
 	conditions := make([]string, len(dbKeyCols))
 	args := make([]interface{}, len(dbKeyCols)+1)
 
-	args[0] = dbValue // Value to set is $1
+	args[0] = cellDBValue(value, spec) // Value to set is $1
 	for i, keyCol := range dbKeyCols {
 		conditions[i] = fmt.Sprintf("%s = $%d", quoteIdentifier(keyCol), i+2)
 		args[i+1] = keyParts[i]
 	}
 
 	query := fmt.Sprintf(
-		"UPDATE %s SET %s = $1 WHERE %s",
+		"UPDATE %s SET %s = $1 WHERE %s%s",
 		quoteIdentifier(tableKey),
 		quoteIdentifier(dbCol),
 		strings.Join(conditions, " AND "),
+		andLiveRows(def, ""),
 	)
 
 	_, err := s.pool.Exec(ctx, query, args...)
@@ -497,6 +612,14 @@ type ImportTemplate struct {
 	CSVHeaders    []string       `json:"csvHeaders"`
 	CreatedAt     time.Time      `json:"createdAt"`
 	UpdatedAt     time.Time      `json:"updatedAt"`
+
+	// FixedWidth is set for fixed-width file templates; CSVHeaders then
+	// holds its column names (see fixed_width.go)
+	FixedWidth *FixedWidthLayout `json:"fixedWidth,omitempty"`
+
+	// Transforms build columns from several CSV columns, or several
+	// columns from one (see template_transforms.go)
+	Transforms []MappingTransform `json:"transforms,omitempty"`
 }
 
 // TemplateMatch represents a template that matches CSV headers.
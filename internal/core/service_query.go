@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	db "github.com/JonMunkholm/TUI/internal/database"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -30,28 +29,19 @@ func (s *Service) ListTablesByGroup() map[string][]TableInfo {
 	return result
 }
 
-// countTable returns the row count for a specific table.
+// countTable returns the row count for a registered table or view,
+// excluding soft-deleted rows.
 func countTable(ctx context.Context, pool *pgxpool.Pool, tableKey string) (int64, error) {
-	q := db.New(pool)
-
-	switch tableKey {
-	case "sfdc_customers":
-		return q.CountSfdcCustomers(ctx)
-	case "sfdc_price_book":
-		return q.CountSfdcPriceBook(ctx)
-	case "sfdc_opp_detail":
-		return q.CountSfdcOppDetail(ctx)
-	case "ns_customers":
-		return q.CountNsCustomers(ctx)
-	case "ns_invoice_detail":
-		return q.CountNsInvoiceDetail(ctx)
-	case "ns_so_detail":
-		return q.CountNsSoDetail(ctx)
-	case "anrok_transactions":
-		return q.CountAnrokTransactions(ctx)
-	default:
+	def, ok := Get(tableKey)
+	if !ok {
 		return 0, fmt.Errorf("unknown table: %s", tableKey)
 	}
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
+	whereClause, _ := wb.Build()
+	var n int64
+	err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+quoteIdentifier(tableKey)+whereClause).Scan(&n)
+	return n, err
 }
 
 // TableRow represents a single row of data as key-value pairs.
@@ -64,17 +54,23 @@ type TableDataResult struct {
 	Page          int
 	PageSize      int
 	TotalPages    int
+	Cursor        string            // Keyset mode: the cursor this page was read after
+	NextCursor    string            // Cursor for the page after this one; empty on the last page
 	Sorts         []SortSpec        // Ordered list of sort specifications (max 2)
 	SortColumn    string            // Primary sort column (first in Sorts) - kept for backwards compat
 	SortDir       string            // Primary sort direction - kept for backwards compat
 	SearchQuery   string            // Current search term, if any
 	ActiveFilters map[string]string // Active column filters: column -> "op:value"
+	Filters       FilterSet         // Filters as requested, including groups, for building URLs
 	Aggregations  Aggregations      // Column aggregations for numeric columns
 }
 
 // buildSingleFilter generates SQL for a single filter.
 func buildSingleFilter(f ColumnFilter, argIdx int) (string, []interface{}, int) {
 	col := quoteIdentifier(f.DBColumn)
+	if f.Expr != "" {
+		col = "(" + f.Expr + ")"
+	}
 
 	switch f.Operator {
 	case OpContains:
@@ -85,6 +81,21 @@ func buildSingleFilter(f ColumnFilter, argIdx int) (string, []interface{}, int)
 		return fmt.Sprintf("%s = $%d", col, argIdx),
 			[]interface{}{f.Value}, argIdx + 1
 
+	case OpNotEquals:
+		// Empty cells differ from any value, so they match too
+		return fmt.Sprintf("%s IS DISTINCT FROM $%d", col, argIdx),
+			[]interface{}{f.Value}, argIdx + 1
+
+	case OpIsNull, OpNotNull:
+		// An edited text cell may hold "" rather than NULL
+		if f.Type == FieldText {
+			col = fmt.Sprintf("NULLIF(%s, '')", col)
+		}
+		if f.Operator == OpIsNull {
+			return col + " IS NULL", nil, argIdx
+		}
+		return col + " IS NOT NULL", nil, argIdx
+
 	case OpStartsWith:
 		return fmt.Sprintf("%s ILIKE $%d", col, argIdx),
 			[]interface{}{f.Value + "%"}, argIdx + 1
@@ -128,20 +139,113 @@ func buildSingleFilter(f ColumnFilter, argIdx int) (string, []interface{}, int)
 	}
 }
 
+// ClampPageSize limits a requested table view page size to
+// QUERY_MIN_PAGE_SIZE..QUERY_MAX_PAGE_SIZE. Zero or less means DefaultPageSize.
+func (s *Service) ClampPageSize(pageSize int) int {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	lo, hi := max(s.cfg.Query.MinPageSize, 1), s.cfg.Query.MaxPageSize
+	if hi <= 0 {
+		hi = DefaultMaxPageSize
+	}
+	return min(max(pageSize, lo), max(hi, lo))
+}
+
+// MaxSortLevels returns how many sort columns GetTableData applies
+// (QUERY_MAX_SORT_LEVELS, else DefaultMaxSortLevels).
+func (s *Service) MaxSortLevels() int {
+	if s.cfg.Query.MaxSortLevels > 0 {
+		return s.cfg.Query.MaxSortLevels
+	}
+	return DefaultMaxSortLevels
+}
+
+// orderByClause builds the ORDER BY list for sorts, keeping at most
+// maxLevels valid ones; unknown and Go-computed columns (see
+// derived_columns.go) are skipped and a column sorted
+// twice keeps its first level. With no valid sort it orders by the first
+// column. It returns the clause and the sorts applied, normalized.
+func orderByClause(def TableDefinition, sorts []SortSpec, maxLevels int) (string, []SortSpec) {
+	var validSorts []SortSpec
+	var orderParts []string
+	seen := make(map[string]bool)
+	for _, sort := range sorts {
+		if len(validSorts) >= maxLevels {
+			break
+		}
+		sort.Column = ResolveColumnName(def, sort.Column, "sort")
+		if sort.Column == "" || seen[sort.Column] {
+			continue
+		}
+		if _, expr, _ := FilterField(def, sort.Column); expr == "" && !containsColumn(def.Info.Columns, sort.Column) {
+			continue
+		}
+		seen[sort.Column] = true
+
+		dir := strings.ToLower(sort.Dir)
+		if dir != "asc" && dir != "desc" {
+			dir = "asc"
+		}
+		part := fmt.Sprintf("%s %s", columnExpr(def, sort.Column), dir)
+		nulls := strings.ToLower(sort.Nulls)
+		switch nulls {
+		case "first":
+			part += " NULLS FIRST"
+		case "last":
+			part += " NULLS LAST"
+		default:
+			nulls = ""
+		}
+		orderParts = append(orderParts, part)
+		validSorts = append(validSorts, SortSpec{Column: sort.Column, Dir: dir, Nulls: nulls})
+	}
+
+	// Default to first column if no valid sorts
+	if len(orderParts) == 0 {
+		defaultCol := def.Info.Columns[0]
+		orderParts = append(orderParts, fmt.Sprintf("%s asc", quoteIdentifier(resolveDBColumn(defaultCol, def.FieldSpecs))))
+		validSorts = append(validSorts, SortSpec{Column: defaultCol, Dir: "asc"})
+	}
+	return strings.Join(orderParts, ", "), validSorts
+}
+
 // GetTableData fetches paginated, sorted, and optionally filtered data from any table.
+// The result's NextCursor continues with GetTableDataAfter.
 func (s *Service) GetTableData(ctx context.Context, tableKey string, page, pageSize int, sorts []SortSpec, searchQuery string, filters FilterSet) (*TableDataResult, error) {
+	return s.getTableData(ctx, tableKey, page, "", pageSize, sorts, searchQuery, filters)
+}
+
+// GetTableDataAfter is GetTableData with keyset pagination: it fetches the
+// page of rows that follows cursor, the NextCursor of an earlier result with
+// the same sort (see table_cursor.go). An empty cursor fetches the first
+// page. The result's Page is 0, as the page number is not known. Views
+// have no cursors: their NextCursor is always empty.
+func (s *Service) GetTableDataAfter(ctx context.Context, tableKey, cursor string, pageSize int, sorts []SortSpec, searchQuery string, filters FilterSet) (*TableDataResult, error) {
+	if cursor == "" {
+		return s.getTableData(ctx, tableKey, 1, "", pageSize, sorts, searchQuery, filters)
+	}
+	return s.getTableData(ctx, tableKey, 0, cursor, pageSize, sorts, searchQuery, filters)
+}
+
+// getTableData reads a page by number, or after cursor if it is set.
+func (s *Service) getTableData(ctx context.Context, tableKey string, page int, cursor string, pageSize int, sorts []SortSpec, searchQuery string, filters FilterSet) (*TableDataResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	pageSize = s.ClampPageSize(pageSize)
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
 
 	// Build column mappings using helper
-	displayColumns := def.Info.Columns
-	dbColumns := resolveDBColumns(displayColumns, def.FieldSpecs)
-	quotedCols := quoteColumns(dbColumns)
+	selectExprs, displayColumns, computed := selectColumns(def, DisplayColumns(def))
 
 	// Build WHERE clause using WhereBuilder
 	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
 	wb.AddSearch(searchQuery, def.FieldSpecs)
 	wb.AddFilters(filters)
 	whereClause, queryArgs := wb.Build()
@@ -155,70 +259,54 @@ func (s *Service) GetTableData(ctx context.Context, tableKey string, page, pageS
 	}
 
 	// Calculate pagination
-	if page < 1 {
-		page = 1
-	}
 	totalPages := int((totalRows + int64(pageSize) - 1) / int64(pageSize))
 	if totalPages < 1 {
 		totalPages = 1
 	}
-	if page > totalPages {
-		page = totalPages
+	offset := 0
+	if cursor == "" {
+		page = min(max(page, 1), totalPages)
+		offset = (page - 1) * pageSize
 	}
-	offset := (page - 1) * pageSize
 
-	// Build ORDER BY clause from sorts (max 2 levels)
-	var validSorts []SortSpec
-	var orderParts []string
-	for _, sort := range sorts {
-		if sort.Column == "" || !containsColumn(displayColumns, sort.Column) {
-			continue
-		}
-		dir := strings.ToLower(sort.Dir)
-		if dir != "asc" && dir != "desc" {
-			dir = "asc"
-		}
-		// Find DB column name
-		sortDBColumn := toDBColumnName(sort.Column)
-		for _, spec := range def.FieldSpecs {
-			if spec.Name == sort.Column && spec.DBColumn != "" {
-				sortDBColumn = spec.DBColumn
-				break
-			}
+	orderBy, validSorts := orderByClause(def, sorts, s.MaxSortLevels())
+	keyed := def.View == "" // Views need not have an id, so get no cursors
+	if cursor != "" {
+		if !keyed {
+			return nil, fmt.Errorf("%w: %s is a view", ErrInvalidCursor, tableKey)
 		}
-		orderParts = append(orderParts, fmt.Sprintf("%s %s", quoteIdentifier(sortDBColumn), dir))
-		validSorts = append(validSorts, SortSpec{Column: sort.Column, Dir: dir})
-		if len(validSorts) >= 2 { // Max 2 sort levels
-			break
+		c, err := decodeTableCursor(cursor, validSorts)
+		if err != nil {
+			return nil, err
 		}
+		wb.AddKeyset(def, c)
+		whereClause, queryArgs = wb.Build()
 	}
 
-	// Default to first column if no valid sorts
-	if len(orderParts) == 0 {
-		defaultCol := displayColumns[0]
-		defaultDBCol := toDBColumnName(defaultCol)
-		for _, spec := range def.FieldSpecs {
-			if spec.Name == defaultCol && spec.DBColumn != "" {
-				defaultDBCol = spec.DBColumn
-				break
-			}
+	// Select the sort keys and id as text too, to build the next cursor.
+	// id breaks ties so every row has a distinct position
+	selectCols := selectExprs
+	if keyed {
+		selectCols = append(selectCols[:len(selectCols):len(selectCols)], "id::text")
+		for _, sort := range validSorts {
+			selectCols = append(selectCols, columnExpr(def, sort.Column)+"::text")
 		}
-		orderParts = append(orderParts, fmt.Sprintf("%s asc", quoteIdentifier(defaultDBCol)))
-		validSorts = append(validSorts, SortSpec{Column: defaultCol, Dir: "asc"})
+		orderBy += ", id"
 	}
 
-	// Build SELECT query with WHERE and ORDER BY clauses
+	// Build SELECT query with WHERE and ORDER BY clauses. One row more than
+	// the page shows whether there is a next page
 	argIndex := wb.NextArgIndex()
 	query := fmt.Sprintf(
 		"SELECT %s FROM %s%s ORDER BY %s LIMIT $%d OFFSET $%d",
-		strings.Join(quotedCols, ", "),
+		strings.Join(selectCols, ", "),
 		quoteIdentifier(tableKey),
 		whereClause,
-		strings.Join(orderParts, ", "),
+		orderBy,
 		argIndex,
 		argIndex+1,
 	)
-	queryArgs = append(queryArgs, pageSize, offset)
+	queryArgs = append(queryArgs, pageSize+1, offset)
 
 	// Execute query
 	rows, err := s.pool.Query(ctx, query, queryArgs...)
@@ -228,18 +316,31 @@ func (s *Service) GetTableData(ctx context.Context, tableKey string, page, pageS
 	defer rows.Close()
 
 	// Collect results
-	var resultRows []TableRow
+	var (
+		resultRows []TableRow
+		lastKeys   []any // id and sort keys of the last row, as text
+		nextCursor string
+	)
 	for rows.Next() {
 		values, err := rows.Values()
 		if err != nil {
 			return nil, fmt.Errorf("read row values: %w", err)
 		}
+		if len(resultRows) == pageSize {
+			// The extra row: the next page starts after the last one shown
+			if keyed {
+				nextCursor = rowCursor(validSorts, lastKeys)
+			}
+			break
+		}
 
 		row := make(TableRow)
 		for i, col := range displayColumns {
 			row[col] = values[i]
 		}
+		computeDerived(row, computed)
 		resultRows = append(resultRows, row)
+		lastKeys = values[len(displayColumns):]
 	}
 
 	if err := rows.Err(); err != nil {
@@ -266,11 +367,14 @@ func (s *Service) GetTableData(ctx context.Context, tableKey string, page, pageS
 		Page:          page,
 		PageSize:      pageSize,
 		TotalPages:    totalPages,
+		Cursor:        cursor,
+		NextCursor:    nextCursor,
 		Sorts:         validSorts,
 		SortColumn:    primarySortCol,
 		SortDir:       primarySortDir,
 		SearchQuery:   searchQuery,
 		ActiveFilters: activeFilters,
+		Filters:       filters,
 	}
 
 	// Fetch aggregations for numeric columns
@@ -283,16 +387,29 @@ func (s *Service) GetTableData(ctx context.Context, tableKey string, page, pageS
 
 // GetColumnAggregations calculates Sum, Avg, Min, Max for numeric columns.
 // Uses the same WHERE clause as GetTableData to aggregate filtered data.
+// Unfiltered results are cached (see query_cache.go).
 func (s *Service) GetColumnAggregations(ctx context.Context, tableKey string, searchQuery string, filters FilterSet) (Aggregations, error) {
+	if searchQuery == "" && len(filters.Filters) == 0 {
+		return cached(&s.queryCache, s.cacheTTL(), queryCacheKey{tableKey, "aggregations"}, func() (Aggregations, error) {
+			return s.getColumnAggregations(ctx, tableKey, searchQuery, filters)
+		})
+	}
+	return s.getColumnAggregations(ctx, tableKey, searchQuery, filters)
+}
+
+func (s *Service) getColumnAggregations(ctx context.Context, tableKey string, searchQuery string, filters FilterSet) (Aggregations, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
 
-	// Identify numeric columns from FieldSpecs
+	// Identify numeric columns from FieldSpecs and SQL derived columns
 	type numericCol struct {
-		name     string
-		dbColumn string
+		name string
+		expr string
 	}
 	var numericCols []numericCol
 
@@ -302,7 +419,12 @@ func (s *Service) GetColumnAggregations(ctx context.Context, tableKey string, se
 			if dbCol == "" {
 				dbCol = toDBColumnName(spec.Name)
 			}
-			numericCols = append(numericCols, numericCol{spec.Name, dbCol})
+			numericCols = append(numericCols, numericCol{spec.Name, quoteIdentifier(dbCol)})
+		}
+	}
+	for _, d := range def.Derived {
+		if d.Type == FieldNumeric && d.SQL != "" {
+			numericCols = append(numericCols, numericCol{d.Name, columnExpr(def, d.Name)})
 		}
 	}
 
@@ -313,6 +435,7 @@ func (s *Service) GetColumnAggregations(ctx context.Context, tableKey string, se
 
 	// Build WHERE clause using WhereBuilder
 	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
 	wb.AddSearch(searchQuery, def.FieldSpecs)
 	wb.AddFilters(filters)
 	whereClause, queryArgs := wb.Build()
@@ -320,7 +443,7 @@ func (s *Service) GetColumnAggregations(ctx context.Context, tableKey string, se
 	// Build aggregation SELECT expressions: SUM, AVG, MIN, MAX, COUNT per column
 	var selectExprs []string
 	for _, col := range numericCols {
-		quoted := quoteIdentifier(col.dbColumn)
+		quoted := col.expr
 		selectExprs = append(selectExprs,
 			fmt.Sprintf("SUM(%s)", quoted),
 			fmt.Sprintf("AVG(%s)", quoted),
@@ -385,20 +508,25 @@ func (s *Service) GetColumnAggregations(ctx context.Context, tableKey string, se
 }
 
 // GetAllTableData fetches all data from a table without pagination.
-// Used for CSV export. Optionally filters by search query and column filters.
+// Optionally filters by search query and column filters.
+//
+// Deprecated: GetAllTableData holds every matching row in memory. Exports
+// use StreamTableData, which writes each row as it is read.
 func (s *Service) GetAllTableData(ctx context.Context, tableKey, searchQuery string, filters FilterSet) (*TableDataResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
 	}
 
 	// Build column names using helper
-	displayColumns := def.Info.Columns
-	dbColumns := resolveDBColumns(displayColumns, def.FieldSpecs)
-	quotedCols := quoteColumns(dbColumns)
+	quotedCols, displayColumns, computed := selectColumns(def, DisplayColumns(def))
 
 	// Build WHERE clause using WhereBuilder
 	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
 	wb.AddSearch(searchQuery, def.FieldSpecs)
 	wb.AddFilters(filters)
 	whereClause, queryArgs := wb.Build()
@@ -423,6 +551,7 @@ func (s *Service) GetAllTableData(ctx context.Context, tableKey, searchQuery str
 			TotalRows:     0,
 			SearchQuery:   searchQuery,
 			ActiveFilters: activeFilters,
+			Filters:       filters,
 		}, nil
 	}
 
@@ -452,6 +581,7 @@ func (s *Service) GetAllTableData(ctx context.Context, tableKey, searchQuery str
 		for i, col := range displayColumns {
 			row[col] = values[i]
 		}
+		computeDerived(row, computed)
 		resultRows = append(resultRows, row)
 	}
 
@@ -464,6 +594,7 @@ func (s *Service) GetAllTableData(ctx context.Context, tableKey, searchQuery str
 		TotalRows:     totalRows,
 		SearchQuery:   searchQuery,
 		ActiveFilters: activeFilters,
+		Filters:       filters,
 	}, nil
 }
 
@@ -494,6 +625,9 @@ func toDBColumnName(name string) string {
 // Keys are expected to be in the format "val1|val2" for composite keys.
 // Returns the list of keys that already exist in the database.
 func (s *Service) CheckDuplicates(ctx context.Context, tableKey string, keys []string) ([]string, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
@@ -514,10 +648,11 @@ func (s *Service) CheckDuplicates(ctx context.Context, tableKey string, keys []s
 	if len(uniqueKey) == 1 {
 		// Single column unique key - use ANY for efficiency
 		query = fmt.Sprintf(
-			"SELECT DISTINCT %s FROM %s WHERE %s = ANY($1)",
+			"SELECT DISTINCT %s FROM %s WHERE %s = ANY($1)%s",
 			quoteIdentifier(dbCols[0]),
 			quoteIdentifier(tableKey),
 			quoteIdentifier(dbCols[0]),
+			andLiveRows(def, ""),
 		)
 		args = []interface{}{keys}
 	} else {
@@ -557,10 +692,11 @@ func (s *Service) CheckDuplicates(ctx context.Context, tableKey string, keys []s
 		}
 
 		query = fmt.Sprintf(
-			"SELECT DISTINCT %s FROM %s WHERE %s",
+			"SELECT DISTINCT %s FROM %s WHERE (%s)%s",
 			strings.Join(concatExpr, " || '|' || "),
 			quoteIdentifier(tableKey),
 			strings.Join(conditions, " OR "),
+			andLiveRows(def, ""),
 		)
 	}
 
@@ -596,32 +732,53 @@ func quoteColumns(cols []string) []string {
 }
 
 // StreamTableData streams table data row by row via callback, avoiding memory accumulation.
-// Used for large CSV exports. The callback receives display column names as keys.
+// Used for exports in every format, snapshots and background export jobs. The callback receives display column names as keys.
 // Returns after all rows are processed or on first error.
 func (s *Service) StreamTableData(ctx context.Context, tableKey, searchQuery string, filters FilterSet, callback func(row TableRow) error) error {
 	def, ok := Get(tableKey)
 	if !ok {
 		return fmt.Errorf("unknown table: %s", tableKey)
 	}
+	return s.streamRows(ctx, def, searchQuery, filters, 0, callback)
+}
+
+// StreamTableSample streams up to size rows chosen at random from the rows
+// matching the search and filters. Used for anonymized sample exports.
+func (s *Service) StreamTableSample(ctx context.Context, tableKey, searchQuery string, filters FilterSet, size int, callback func(row TableRow) error) error {
+	def, ok := Get(tableKey)
+	if !ok {
+		return fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if size <= 0 {
+		return fmt.Errorf("sample size must be positive, got %d", size)
+	}
+	return s.streamRows(ctx, def, searchQuery, filters, size, callback)
+}
 
+// streamRows runs the export query for def. A positive sample returns that
+// many random rows instead of every row in first-column order.
+func (s *Service) streamRows(ctx context.Context, def TableDefinition, searchQuery string, filters FilterSet, sample int, callback func(row TableRow) error) error {
 	// Build column names using helper
-	displayColumns := def.Info.Columns
-	dbColumns := resolveDBColumns(displayColumns, def.FieldSpecs)
-	quotedCols := quoteColumns(dbColumns)
+	quotedCols, displayColumns, computed := selectColumns(def, DisplayColumns(def))
 
 	// Build WHERE clause using WhereBuilder
 	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
 	wb.AddSearch(searchQuery, def.FieldSpecs)
 	wb.AddFilters(filters)
 	whereClause, queryArgs := wb.Build()
 
-	// Query ALL rows (no LIMIT/OFFSET), sorted by first column
+	// Query ALL rows (no LIMIT/OFFSET) sorted by first column, or a random sample
+	order := quotedCols[0] + " ASC"
+	if sample > 0 {
+		order = fmt.Sprintf("random() LIMIT %d", sample)
+	}
 	query := fmt.Sprintf(
-		"SELECT %s FROM %s%s ORDER BY %s ASC",
+		"SELECT %s FROM %s%s ORDER BY %s",
 		strings.Join(quotedCols, ", "),
-		quoteIdentifier(tableKey),
+		quoteIdentifier(def.Info.Key),
 		whereClause,
-		quotedCols[0],
+		order,
 	)
 
 	rows, err := s.pool.Query(ctx, query, queryArgs...)
@@ -645,6 +802,7 @@ func (s *Service) StreamTableData(ctx context.Context, tableKey, searchQuery str
 		for i, col := range displayColumns {
 			row[col] = values[i]
 		}
+		computeDerived(row, computed)
 
 		if err := callback(row); err != nil {
 			return err
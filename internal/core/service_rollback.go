@@ -3,13 +3,85 @@ package core
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	db "github.com/JonMunkholm/TUI/internal/database"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// deleteByUploadID returns the DeleteByUploadIDFunc of a table that follows
+// the upload_id convention of the table migrations and declared tables:
+// each row's upload_id column names the upload that inserted it.
+func deleteByUploadID(table string) DeleteByUploadIDFunc {
+	query := "DELETE FROM " + quoteIdentifier(table) + " WHERE upload_id = $1"
+	return func(ctx context.Context, db DBTX, uploadID pgtype.UUID) (int64, error) {
+		tag, err := db.Exec(ctx, query, uploadID)
+		return tag.RowsAffected(), err
+	}
+}
+
+// Rollback operation steps.
+const (
+	stepRollbackDelete = "delete" // Delete the upload's rows in batches
+	stepRollbackMark   = "mark"   // Mark the upload rolled back and audit
+)
+
 // RollbackUpload deletes all rows that were inserted from a specific upload.
+// It runs as a rollback operation, deleting in batches within the reset
+// timeout; see StartRollback to run it in the background.
 func (s *Service) RollbackUpload(ctx context.Context, uploadID string) (RollbackResult, error) {
+	result, def, pgUUID, err := s.rollbackTarget(ctx, uploadID)
+	if err != nil {
+		return result, err
+	}
+	op := s.StartOperation(ctx, OperationRollback, def.Info.Key, rollbackSteps)
+	result.OperationID = op.ID()
+	result, err = s.runRollback(ctx, op, def, pgUUID, result, rollbackNote{})
+	op.Finish(err)
+	return result, err
+}
+
+// StartRollback checks that an upload can be rolled back and runs
+// RollbackUpload in the background, returning the operation ID to follow it
+// with. The rollback keeps running if ctx is cancelled; CancelOperation
+// stops it after the current batch, leaving the upload active.
+func (s *Service) StartRollback(ctx context.Context, uploadID string) (RollbackResult, error) {
+	result, def, pgUUID, err := s.rollbackTarget(ctx, uploadID)
+	if err != nil {
+		return result, err
+	}
+	op := s.StartOperation(ctx, OperationRollback, def.Info.Key, rollbackSteps)
+	result.OperationID = op.ID()
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		_, err := s.runRollback(ctx, op, def, pgUUID, result, rollbackNote{})
+		return err
+	})
+	return result, nil
+}
+
+// rollbackSteps are the steps of a rollback operation; deleting is nearly
+// all of the work.
+var rollbackSteps = []OperationStep{
+	{Name: stepRollbackDelete, Weight: 9},
+	{Name: stepRollbackMark, Weight: 1},
+}
+
+// rollbackNote is recorded with the audit entries of a rollback run as part
+// of a larger one, such as a range rollback.
+type rollbackNote struct {
+	BatchID string
+	Reason  string
+}
+
+// rollbackTarget looks up an upload and checks that it can be rolled back.
+// The result carries the upload and table, or the error for the response.
+func (s *Service) rollbackTarget(ctx context.Context, uploadID string) (RollbackResult, TableDefinition, pgtype.UUID, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	result := RollbackResult{
 		UploadID: uploadID,
 	}
@@ -18,14 +90,14 @@ func (s *Service) RollbackUpload(ctx context.Context, uploadID string) (Rollback
 	var pgUUID pgtype.UUID
 	if err := pgUUID.Scan(uploadID); err != nil {
 		result.Error = fmt.Sprintf("invalid upload ID: %v", err)
-		return result, fmt.Errorf("invalid upload ID: %w", err)
+		return result, TableDefinition{}, pgUUID, fmt.Errorf("invalid upload ID: %w", err)
 	}
 
 	// Get upload info
 	upload, err := db.New(s.pool).GetUploadById(ctx, pgUUID)
 	if err != nil {
 		result.Error = fmt.Sprintf("upload not found: %v", err)
-		return result, fmt.Errorf("get upload: %w", err)
+		return result, TableDefinition{}, pgUUID, fmt.Errorf("get upload: %w", err)
 	}
 
 	result.TableKey = upload.Name
@@ -33,47 +105,298 @@ func (s *Service) RollbackUpload(ctx context.Context, uploadID string) (Rollback
 	// Check if already rolled back
 	if upload.Status.Valid && upload.Status.String == "rolled_back" {
 		result.Error = "upload already rolled back"
-		return result, fmt.Errorf("upload already rolled back")
+		return result, TableDefinition{}, pgUUID, fmt.Errorf("upload already rolled back")
 	}
 
 	// Get table definition
 	def, ok := Get(upload.Name)
 	if !ok {
 		result.Error = fmt.Sprintf("unknown table: %s", upload.Name)
-		return result, fmt.Errorf("unknown table: %s", upload.Name)
+		return result, TableDefinition{}, pgUUID, fmt.Errorf("unknown table: %s", upload.Name)
 	}
 
 	// Check if table supports rollback
 	if def.DeleteByUploadID == nil {
 		result.Error = "table does not support rollback"
-		return result, fmt.Errorf("table does not support rollback")
+		return result, TableDefinition{}, pgUUID, fmt.Errorf("table does not support rollback")
+	}
+
+	if err := s.checkLegalHold(ctx, def, "rollback", "upload_id = $1", pgUUID); err != nil {
+		result.Error = err.Error()
+		return result, TableDefinition{}, pgUUID, err
 	}
+	req := approvalRequest{kind: ApprovalRollback, tableKey: def.Info.Key, target: uploadID}
+	if err := s.requireApproval(ctx, req, func(ctx context.Context, limit int64) (int64, error) {
+		return s.countRows(ctx, def, limit, "upload_id = $1", pgUUID)
+	}); err != nil {
+		result.Error = err.Error()
+		return result, TableDefinition{}, pgUUID, err
+	}
+
+	return result, def, pgUUID, nil
+}
+
+// runRollback deletes an upload's rows under op within the reset timeout,
+// then marks the upload rolled back. Rows go in batches; the table's own
+// DeleteByUploadID then clears any the batches missed. A rollback that
+// stops part way leaves the upload active and is audited with the rows it
+// deleted, so running it again finishes the job. note groups the audit
+// entries of a rollback that is part of a larger one.
+func (s *Service) runRollback(ctx context.Context, op *Operation, def TableDefinition, pgUUID pgtype.UUID, result RollbackResult, note rollbackNote) (RollbackResult, error) {
+	auditCtx := context.WithoutCancel(ctx)
+	ctx, cancel := context.WithTimeout(ctx, s.ResetTimeout())
+	defer cancel()
 
 	// Delete the rows
-	rowsDeleted, err := def.DeleteByUploadID(ctx, s.pool, pgUUID)
+	op.Begin(stepRollbackDelete)
+	rowsDeleted, err := s.deleteInBatches(ctx, s.pool, op, stepRollbackDelete, def.Info.Key, "upload_id = $1", pgUUID)
+	if err == nil {
+		var n int64
+		n, err = def.DeleteByUploadID(ctx, s.pool, pgUUID)
+		rowsDeleted += n
+	}
+	result.RowsDeleted = rowsDeleted
+	op.EndStep(stepRollbackDelete, err)
 	if err != nil {
-		result.Error = fmt.Sprintf("delete failed: %v", err)
+		result.Error = fmt.Sprintf("delete failed after %d rows: %v", rowsDeleted, err)
+		if rowsDeleted > 0 {
+			reason := fmt.Sprintf("rollback stopped after %d rows: %v", rowsDeleted, err)
+			if note.Reason != "" {
+				reason = note.Reason + ": " + reason
+			}
+			s.LogAudit(auditCtx, AuditLogParams{
+				Action:       ActionUploadRollback,
+				TableKey:     result.TableKey,
+				UploadID:     result.UploadID,
+				BatchID:      note.BatchID,
+				RowsAffected: int(rowsDeleted),
+				Reason:       reason,
+				IPAddress:    GetIPAddressFromContext(ctx),
+				UserAgent:    GetUserAgentFromContext(ctx),
+			})
+		}
 		return result, fmt.Errorf("delete by upload ID: %w", err)
 	}
 
-	// Mark upload as rolled back
-	if err := db.New(s.pool).MarkUploadRolledBack(ctx, pgUUID); err != nil {
+	// Mark upload as rolled back, even if cancelled now that its rows are gone
+	op.Begin(stepRollbackMark)
+	markCtx, cancelMark := s.withOpTimeout(auditCtx, opMutation)
+	defer cancelMark()
+	if err := db.New(s.pool).MarkUploadRolledBack(markCtx, pgUUID); err != nil {
 		// Log but don't fail - rows are already deleted
 		result.Error = fmt.Sprintf("warning: rows deleted but status update failed: %v", err)
 	}
 
-	result.RowsDeleted = rowsDeleted
 	result.Success = true
 
 	// Log audit entry for rollback
-	s.LogAudit(ctx, AuditLogParams{
+	s.LogAudit(auditCtx, AuditLogParams{
 		Action:       ActionUploadRollback,
 		TableKey:     result.TableKey,
-		UploadID:     uploadID,
+		UploadID:     result.UploadID,
+		BatchID:      note.BatchID,
 		RowsAffected: int(rowsDeleted),
+		Reason:       note.Reason,
 		IPAddress:    GetIPAddressFromContext(ctx),
 		UserAgent:    GetUserAgentFromContext(ctx),
 	})
+	s.emitUploadEvent(auditCtx, rollbackEvent(result.TableKey, result.UploadID, rowsDeleted))
+
+	return result, nil
+}
+
+// RollbackUploadsInRange rolls back every active upload for a table whose
+// uploaded_at falls within [from, to], newest first. It runs as a rollback
+// operation with one step per upload, each rolled back like RollbackUpload;
+// see StartRollbackRange to run it in the background. A run that stops part
+// way leaves the remaining uploads active, so running it again finishes the
+// job. With preview set, nothing is deleted and the result lists what would be.
+func (s *Service) RollbackUploadsInRange(ctx context.Context, tableKey string, from, to time.Time, preview bool) (RollbackRangeResult, error) {
+	result, def, err := s.rangeRollbackTarget(ctx, tableKey, from, to, preview)
+	result.Preview = preview
+	if err != nil || preview || len(result.Uploads) == 0 {
+		result.Success = err == nil
+		return result, err
+	}
+	op := s.StartOperation(ctx, OperationRollback, tableKey, rangeRollbackSteps(result.Uploads))
+	result.OperationID = op.ID()
+	result, err = s.runRangeRollback(ctx, op, def, result)
+	op.Finish(err)
+	return result, err
+}
+
+// StartRollbackRange lists the uploads RollbackUploadsInRange would roll
+// back and rolls them back in the background, returning the operation ID to
+// follow it with. There is no operation when the range holds no uploads.
+// CancelOperation stops the run after the current batch.
+func (s *Service) StartRollbackRange(ctx context.Context, tableKey string, from, to time.Time) (RollbackRangeResult, error) {
+	result, def, err := s.rangeRollbackTarget(ctx, tableKey, from, to, false)
+	if err != nil || len(result.Uploads) == 0 {
+		result.Success = err == nil
+		return result, err
+	}
+	op := s.StartOperation(ctx, OperationRollback, tableKey, rangeRollbackSteps(result.Uploads))
+	result.OperationID = op.ID()
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		_, err := s.runRangeRollback(ctx, op, def, result)
+		return err
+	})
+	return result, nil
+}
+
+// rangeRollbackTarget checks a range rollback and lists its uploads. Unless
+// previewing, it also checks that no upload holds rows under a legal hold.
+// The result carries the uploads, or the error for the response.
+func (s *Service) rangeRollbackTarget(ctx context.Context, tableKey string, from, to time.Time, preview bool) (RollbackRangeResult, TableDefinition, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	result := RollbackRangeResult{
+		TableKey: tableKey,
+		From:     from,
+		To:       to,
+		Uploads:  []RollbackPreview{},
+	}
+
+	def, ok := Get(tableKey)
+	if !ok {
+		result.Error = fmt.Sprintf("unknown table: %s", tableKey)
+		return result, def, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if def.DeleteByUploadID == nil {
+		result.Error = "table does not support rollback"
+		return result, def, fmt.Errorf("table does not support rollback")
+	}
+	if to.Before(from) {
+		result.Error = "end of range is before start"
+		return result, def, fmt.Errorf("invalid range: %s is before %s", to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+
+	uploads, err := s.listUploadsInRange(ctx, def, from, to)
+	if err != nil {
+		result.Error = err.Error()
+		return result, def, err
+	}
+	result.Uploads = uploads
+	for _, u := range uploads {
+		result.RowsToDelete += u.RowsToDelete
+	}
+	if preview {
+		return result, def, nil
+	}
+
+	for _, u := range uploads {
+		if err := s.checkLegalHold(ctx, def, "rollback", "upload_id = $1", ToPgUUID(u.UploadID)); err != nil {
+			result.Error = err.Error()
+			return result, def, err
+		}
+	}
 
+	// The approval names the uploads, so uploads added to the range after it
+	// was approved are not rolled back with it
+	ids := make([]string, len(uploads))
+	for i, u := range uploads {
+		ids[i] = u.UploadID
+	}
+	slices.Sort(ids)
+	req := approvalRequest{kind: ApprovalRollbackRange, tableKey: tableKey, params: map[string]string{
+		"from":    from.Format(time.RFC3339Nano),
+		"to":      to.Format(time.RFC3339Nano),
+		"uploads": strings.Join(ids, ","),
+	}}
+	if err := s.requireApproval(ctx, req, func(context.Context, int64) (int64, error) {
+		return result.RowsToDelete, nil
+	}); err != nil {
+		result.Error = err.Error()
+		return result, def, err
+	}
+	return result, def, nil
+}
+
+// rangeRollbackSteps returns one step per upload of a range rollback,
+// weighted by the rows it removes.
+func rangeRollbackSteps(uploads []RollbackPreview) []OperationStep {
+	steps := make([]OperationStep, len(uploads))
+	for i, u := range uploads {
+		steps[i] = OperationStep{
+			Name:   fmt.Sprintf("%d. %s", i+1, u.FileName),
+			Weight: int(max(u.RowsToDelete, 1)),
+		}
+	}
+	return steps
+}
+
+// runRangeRollback rolls back the uploads of result in order, each under
+// its own rollback operation attached to a step of op. It stops at the
+// first upload that fails or when ctx is done. The audit entries share a
+// batch ID.
+func (s *Service) runRangeRollback(ctx context.Context, op *Operation, def TableDefinition, result RollbackRangeResult) (RollbackRangeResult, error) {
+	note := rollbackNote{
+		BatchID: uuid.New().String(),
+		Reason:  fmt.Sprintf("range rollback %s to %s", result.From.Format(time.RFC3339), result.To.Format(time.RFC3339)),
+	}
+	steps := rangeRollbackSteps(result.Uploads)
+	for i, u := range result.Uploads {
+		child := s.startStepOperation(ctx, op, steps[i].Name, OperationRollback, def.Info.Key, rollbackSteps)
+		r, err := s.runRollback(ctx, child, def, ToPgUUID(u.UploadID), RollbackResult{
+			UploadID:    u.UploadID,
+			OperationID: child.ID(),
+			TableKey:    def.Info.Key,
+		}, note)
+		child.Finish(err)
+		result.RowsDeleted += r.RowsDeleted
+		if err != nil {
+			result.Error = fmt.Sprintf("upload %s: %s", u.UploadID, r.Error)
+			return result, fmt.Errorf("roll back upload %s: %w", u.UploadID, err)
+		}
+	}
+	result.Success = true
 	return result, nil
 }
+
+// listUploadsInRange returns active uploads for a table within [from, to],
+// newest first, with the number of rows each would remove.
+func (s *Service) listUploadsInRange(ctx context.Context, def TableDefinition, from, to time.Time) ([]RollbackPreview, error) {
+	query := fmt.Sprintf(`
+		SELECT u.id, COALESCE(u.file_name, ''), u.uploaded_at,
+		       (SELECT COUNT(*) FROM %s t WHERE t.upload_id = u.id)
+		FROM csv_uploads u
+		WHERE u.name = $1
+		  AND u.status = 'active'
+		  AND u.deleted_at IS NULL
+		  AND u.uploaded_at >= $2
+		  AND u.uploaded_at <= $3
+		ORDER BY u.uploaded_at DESC`,
+		quoteIdentifier(def.Info.Key),
+	)
+
+	rows, err := s.pool.Query(ctx, query, def.Info.Key, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query uploads in range: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := []RollbackPreview{}
+	for rows.Next() {
+		var id pgtype.UUID
+		var fileName string
+		var uploadedAt time.Time
+		var count int64
+		if err := rows.Scan(&id, &fileName, &uploadedAt, &count); err != nil {
+			return nil, fmt.Errorf("scan upload: %w", err)
+		}
+		uploads = append(uploads, RollbackPreview{
+			UploadID:     PgUUIDToString(id),
+			TableKey:     def.Info.Key,
+			FileName:     fileName,
+			UploadedAt:   uploadedAt.Format(time.RFC3339),
+			RowsToDelete: count,
+			CanRollback:  true,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return uploads, nil
+}
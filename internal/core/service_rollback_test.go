@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestRegister_DefaultDeleteByUploadID(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "rollback_notes"},
+		FieldSpecs: []FieldSpec{{Name: "Note", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "rollback_notes")
+		registryMu.Unlock()
+	})
+
+	def, _ := Get("rollback_notes")
+	if def.DeleteByUploadID == nil {
+		t.Fatal("DeleteByUploadID not set")
+	}
+	tx := &recordingTx{}
+	if _, err := def.DeleteByUploadID(context.Background(), tx, pgtype.UUID{}); err != nil {
+		t.Fatalf("DeleteByUploadID: %v", err)
+	}
+	if len(tx.stmts) != 1 || tx.stmts[0] != `DELETE FROM "rollback_notes" WHERE upload_id = $1` {
+		t.Errorf("stmts = %q", tx.stmts)
+	}
+}
+
+// registerRangeTestTable registers a table with a random key, which rolls
+// back by upload_id, and returns the key.
+func registerRangeTestTable(t *testing.T) string {
+	t.Helper()
+	key := "range_test_" + strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+	Register(TableDefinition{
+		Info:       TableInfo{Key: key, Label: key, Group: "Test"},
+		FieldSpecs: []FieldSpec{{Name: "n", Type: FieldNumeric}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, key)
+		registryMu.Unlock()
+	})
+	return key
+}
+
+// testDBService returns a Service connected to TEST_DATABASE_URL, which
+// must point at a database migrated with sql/schema, or skips the test.
+func testDBService(t *testing.T) *Service {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("set TEST_DATABASE_URL to a migrated database to run")
+	}
+	t.Setenv("DATABASE_URL", url)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	// Keep files the service writes next to its working directory out of
+	// the source tree
+	t.Chdir(t.TempDir())
+	s, err := NewService(pool, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRollbackUploadsInRange_UnknownTable(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	result, err := s.RollbackUploadsInRange(context.Background(), "no_such_table", from, from.Add(time.Hour), false)
+	if err == nil {
+		t.Fatal("expected an error for an unknown table")
+	}
+	if result.Error == "" || result.Success {
+		t.Errorf("result = %+v, want an unsuccessful result with an error", result)
+	}
+}
+
+func TestRollbackUploadsInRange_EndBeforeStart(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+	key := registerRangeTestTable(t)
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	result, err := s.RollbackUploadsInRange(context.Background(), key, from, from.Add(-time.Hour), false)
+	if err == nil {
+		t.Fatal("expected an error for a range ending before it starts")
+	}
+	if result.Error != "end of range is before start" {
+		t.Errorf("error = %q, want %q", result.Error, "end of range is before start")
+	}
+}
+
+// TestRollbackUploadsInRange_Database rolls back real uploads. It needs
+// TEST_DATABASE_URL.
+func TestRollbackUploadsInRange_Database(t *testing.T) {
+	s := testDBService(t)
+	ctx := context.Background()
+	key := registerRangeTestTable(t)
+	table := quoteIdentifier(key)
+
+	if _, err := s.pool.Exec(ctx, "CREATE TABLE "+table+" (id SERIAL PRIMARY KEY, upload_id UUID, n INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.pool.Exec(ctx, "DROP TABLE "+table)
+		s.pool.Exec(ctx, "DELETE FROM csv_uploads WHERE name = $1", key)
+	})
+
+	// Three uploads a day apart, each with one row more than the last
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ids := make([]string, 3)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+		if _, err := s.pool.Exec(ctx,
+			"INSERT INTO csv_uploads (id, name, action, file_name, uploaded_at) VALUES ($1, $2, 'upload', $3, $4)",
+			ToPgUUID(ids[i]), key, "day.csv", base.AddDate(0, 0, i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.pool.Exec(ctx,
+			"INSERT INTO "+table+" (upload_id, n) SELECT $1, g FROM generate_series(1, $2) g",
+			ToPgUUID(ids[i]), i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("empty range", func(t *testing.T) {
+		result, err := s.RollbackUploadsInRange(ctx, key, base.AddDate(1, 0, 0), base.AddDate(1, 0, 1), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Success || len(result.Uploads) != 0 || result.RowsDeleted != 0 {
+			t.Errorf("result = %+v, want success with nothing rolled back", result)
+		}
+	})
+
+	t.Run("several uploads", func(t *testing.T) {
+		// Covers the second and third uploads only
+		from, to := base.AddDate(0, 0, 1), base.AddDate(0, 0, 2)
+
+		preview, err := s.RollbackUploadsInRange(ctx, key, from, to, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(preview.Uploads) != 2 || preview.RowsToDelete != 5 || preview.RowsDeleted != 0 {
+			t.Fatalf("preview = %+v, want 2 uploads and 5 rows to delete", preview)
+		}
+		if preview.Uploads[0].UploadID != ids[2] {
+			t.Errorf("first upload = %s, want the newest %s", preview.Uploads[0].UploadID, ids[2])
+		}
+
+		result, err := s.RollbackUploadsInRange(ctx, key, from, to, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Success || result.RowsDeleted != 5 {
+			t.Errorf("result = %+v, want 5 rows deleted", result)
+		}
+		if p, err := s.GetOperationProgress(result.OperationID); err != nil || p.Status != OperationComplete || len(p.Steps) != 2 {
+			t.Errorf("operation = %+v, %v; want a complete operation with a step per upload", p, err)
+		}
+
+		var left int
+		if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&left); err != nil {
+			t.Fatal(err)
+		}
+		if left != 1 {
+			t.Errorf("rows left = %d, want 1 from the upload outside the range", left)
+		}
+		var active int
+		if err := s.pool.QueryRow(ctx,
+			"SELECT COUNT(*) FROM csv_uploads WHERE name = $1 AND status = 'active'", key).Scan(&active); err != nil {
+			t.Fatal(err)
+		}
+		if active != 1 {
+			t.Errorf("active uploads = %d, want 1", active)
+		}
+	})
+}
@@ -0,0 +1,365 @@
+package core
+
+// service_snapshots.go provides export snapshots for "what changed since I
+// last pulled this" workflows.
+//
+// A snapshot stores a manifest of (unique key, content hash) pairs for every
+// row matched by an export. The exported file itself is not kept; later, the
+// current table state is hashed the same way and compared against the
+// manifest to report added, removed, and changed rows.
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ExportSnapshot describes a stored export manifest.
+type ExportSnapshot struct {
+	ID          string         `json:"id"`
+	TableKey    string         `json:"tableKey"`
+	Name        string         `json:"name"`
+	SearchQuery string         `json:"searchQuery,omitempty"`
+	Filters     []ColumnFilter `json:"filters,omitempty"`
+	RowCount    int            `json:"rowCount"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+// SnapshotDiff contains the differences between a snapshot and current table state.
+// Keys are unique key values in "val1|val2" format.
+type SnapshotDiff struct {
+	Snapshot     ExportSnapshot `json:"snapshot"`
+	Added        []string       `json:"added"`
+	Removed      []string       `json:"removed"`
+	Changed      []string       `json:"changed"`
+	Unchanged    int            `json:"unchanged"`
+	CurrentCount int            `json:"currentCount"`
+}
+
+// CreateExportSnapshot records a manifest of the rows currently matched by the
+// given search and filters. Only row keys and content hashes are stored.
+func (s *Service) CreateExportSnapshot(ctx context.Context, tableKey, name, searchQuery string, filters FilterSet) (*ExportSnapshot, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if len(def.Info.UniqueKey) == 0 {
+		return nil, fmt.Errorf("table %s has no unique key defined", tableKey)
+	}
+	if name == "" {
+		name = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	manifest, err := s.buildSnapshotManifest(ctx, def, searchQuery, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	filtersJSON, err := json.Marshal(filters.Filters)
+	if err != nil {
+		return nil, fmt.Errorf("marshal filters: %w", err)
+	}
+
+	snapshot := &ExportSnapshot{
+		TableKey:    tableKey,
+		Name:        name,
+		SearchQuery: searchQuery,
+		Filters:     filters.Filters,
+		RowCount:    len(manifest),
+	}
+
+	var id pgtype.UUID
+	err = tx.QueryRow(ctx,
+		`INSERT INTO export_snapshots (table_key, name, search_query, filters, row_count)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at`,
+		tableKey, name, searchQuery, filtersJSON, len(manifest),
+	).Scan(&id, &snapshot.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create snapshot: %w", err)
+	}
+	snapshot.ID = PgUUIDToString(id)
+
+	copyRows := make([][]any, 0, len(manifest))
+	for key, hash := range manifest {
+		copyRows = append(copyRows, []any{id, key, hash})
+	}
+
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"export_snapshot_rows"},
+		[]string{"snapshot_id", "row_key", "row_hash"},
+		pgx.CopyFromRows(copyRows),
+	); err != nil {
+		return nil, fmt.Errorf("COPY snapshot rows: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// ListExportSnapshots returns snapshots for a table, newest first.
+func (s *Service) ListExportSnapshots(ctx context.Context, tableKey string) ([]ExportSnapshot, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, table_key, name, search_query, filters, row_count, created_at
+		 FROM export_snapshots
+		 WHERE table_key = $1
+		 ORDER BY created_at DESC`,
+		tableKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []ExportSnapshot{}
+	for rows.Next() {
+		var snap ExportSnapshot
+		var id pgtype.UUID
+		var filtersJSON []byte
+		if err := rows.Scan(&id, &snap.TableKey, &snap.Name, &snap.SearchQuery, &filtersJSON, &snap.RowCount, &snap.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan snapshot: %w", err)
+		}
+		snap.ID = PgUUIDToString(id)
+		if err := json.Unmarshal(filtersJSON, &snap.Filters); err != nil {
+			return nil, fmt.Errorf("unmarshal filters: %w", err)
+		}
+		snapshots = append(snapshots, snap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetExportSnapshot returns a single snapshot by ID.
+func (s *Service) GetExportSnapshot(ctx context.Context, snapshotID string) (*ExportSnapshot, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	var pgUUID pgtype.UUID
+	if err := pgUUID.Scan(snapshotID); err != nil {
+		return nil, fmt.Errorf("invalid snapshot ID: %w", err)
+	}
+
+	snap := &ExportSnapshot{ID: snapshotID}
+	var filtersJSON []byte
+	err := s.pool.QueryRow(ctx,
+		`SELECT table_key, name, search_query, filters, row_count, created_at
+		 FROM export_snapshots
+		 WHERE id = $1`,
+		pgUUID,
+	).Scan(&snap.TableKey, &snap.Name, &snap.SearchQuery, &filtersJSON, &snap.RowCount, &snap.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot not found: %w", err)
+	}
+	if err := json.Unmarshal(filtersJSON, &snap.Filters); err != nil {
+		return nil, fmt.Errorf("unmarshal filters: %w", err)
+	}
+
+	return snap, nil
+}
+
+// DeleteExportSnapshot removes a snapshot and its manifest.
+func (s *Service) DeleteExportSnapshot(ctx context.Context, snapshotID string) error {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	var pgUUID pgtype.UUID
+	if err := pgUUID.Scan(snapshotID); err != nil {
+		return fmt.Errorf("invalid snapshot ID: %w", err)
+	}
+
+	tag, err := s.pool.Exec(ctx, "DELETE FROM export_snapshots WHERE id = $1", pgUUID)
+	if err != nil {
+		return fmt.Errorf("delete snapshot: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+
+	return nil
+}
+
+// GetSnapshotDiff compares the current state of the snapshot's table against
+// the stored manifest. The snapshot's search and filters are reapplied, so a
+// row that no longer matches them is reported as removed.
+func (s *Service) GetSnapshotDiff(ctx context.Context, snapshotID string) (*SnapshotDiff, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	snap, err := s.GetExportSnapshot(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	def, ok := Get(snap.TableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", snap.TableKey)
+	}
+
+	previous, err := s.loadSnapshotManifest(ctx, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.buildSnapshotManifest(ctx, def, snap.SearchQuery, FilterSet{Filters: snap.Filters})
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffManifests(previous, current)
+	diff.Snapshot = *snap
+	diff.CurrentCount = len(current)
+	return diff, nil
+}
+
+// loadSnapshotManifest reads the stored key -> hash manifest for a snapshot.
+func (s *Service) loadSnapshotManifest(ctx context.Context, snapshotID string) (map[string]string, error) {
+	rows, err := s.pool.Query(ctx,
+		"SELECT row_key, row_hash FROM export_snapshot_rows WHERE snapshot_id = $1",
+		snapshotID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query snapshot rows: %w", err)
+	}
+	defer rows.Close()
+
+	manifest := make(map[string]string)
+	for rows.Next() {
+		var key, hash string
+		if err := rows.Scan(&key, &hash); err != nil {
+			return nil, fmt.Errorf("scan snapshot row: %w", err)
+		}
+		manifest[key] = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// buildSnapshotManifest streams the matching rows and returns key -> hash.
+// Rows sharing a unique key (no DB constraint enforces uniqueness) are folded
+// into a single order-independent hash.
+func (s *Service) buildSnapshotManifest(ctx context.Context, def TableDefinition, searchQuery string, filters FilterSet) (map[string]string, error) {
+	columns := def.Info.Columns
+	hashes := make(map[string][]string)
+
+	err := s.StreamTableData(ctx, def.Info.Key, searchQuery, filters, func(row TableRow) error {
+		key := snapshotRowKey(row, def.Info.UniqueKey)
+		hashes[key] = append(hashes[key], hashSnapshotRow(row, columns))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("stream table data: %w", err)
+	}
+
+	manifest := make(map[string]string, len(hashes))
+	for key, hs := range hashes {
+		if len(hs) == 1 {
+			manifest[key] = hs[0]
+			continue
+		}
+		sort.Strings(hs)
+		sum := sha256.Sum256([]byte(strings.Join(hs, "")))
+		manifest[key] = hex.EncodeToString(sum[:])
+	}
+
+	return manifest, nil
+}
+
+// diffManifests compares two key -> hash manifests.
+// Result key slices are sorted for stable output.
+func diffManifests(previous, current map[string]string) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+
+	for key, hash := range current {
+		prevHash, ok := previous[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case prevHash != hash:
+			diff.Changed = append(diff.Changed, key)
+		default:
+			diff.Unchanged++
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// snapshotRowKey builds the "val1|val2" unique key for a row.
+func snapshotRowKey(row TableRow, uniqueKey []string) string {
+	parts := make([]string, len(uniqueKey))
+	for i, col := range uniqueKey {
+		parts[i] = snapshotValue(row[col])
+	}
+	return strings.Join(parts, "|")
+}
+
+// hashSnapshotRow returns a hex SHA-256 of the row's values in column order.
+// Values are separated by a unit separator so ("ab","c") != ("a","bc").
+func hashSnapshotRow(row TableRow, columns []string) string {
+	h := sha256.New()
+	for _, col := range columns {
+		h.Write([]byte(snapshotValue(row[col])))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// snapshotValue formats a database value exactly (no rounding) for hashing.
+func snapshotValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case driver.Valuer:
+		dv, err := val.Value()
+		if err != nil || dv == nil {
+			return ""
+		}
+		return snapshotValue(dv)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// Snapshot Manifest Tests
+// ============================================================================
+
+func TestDiffManifests(t *testing.T) {
+	previous := map[string]string{
+		"a": "h1",
+		"b": "h2",
+		"c": "h3",
+	}
+	current := map[string]string{
+		"a": "h1",
+		"b": "h2-changed",
+		"d": "h4",
+	}
+
+	diff := diffManifests(previous, current)
+
+	if !reflect.DeepEqual(diff.Added, []string{"d"}) {
+		t.Errorf("expected added [d], got %v", diff.Added)
+	}
+	if !reflect.DeepEqual(diff.Removed, []string{"c"}) {
+		t.Errorf("expected removed [c], got %v", diff.Removed)
+	}
+	if !reflect.DeepEqual(diff.Changed, []string{"b"}) {
+		t.Errorf("expected changed [b], got %v", diff.Changed)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("expected 1 unchanged, got %d", diff.Unchanged)
+	}
+}
+
+func TestDiffManifests_EmptySlicesNotNil(t *testing.T) {
+	diff := diffManifests(map[string]string{}, map[string]string{})
+
+	if diff.Added == nil || diff.Removed == nil || diff.Changed == nil {
+		t.Error("expected empty slices, got nil (would encode as JSON null)")
+	}
+}
+
+func TestHashSnapshotRow(t *testing.T) {
+	columns := []string{"x", "y"}
+
+	h1 := hashSnapshotRow(TableRow{"x": "ab", "y": "c"}, columns)
+	h2 := hashSnapshotRow(TableRow{"x": "a", "y": "bc"}, columns)
+	if h1 == h2 {
+		t.Error("expected different hashes for shifted values")
+	}
+
+	h3 := hashSnapshotRow(TableRow{"x": "ab", "y": "c", "ignored": "z"}, columns)
+	if h1 != h3 {
+		t.Error("expected columns outside the list to be ignored")
+	}
+}
+
+func TestSnapshotRowKey(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := TableRow{"id": "42", "date": ts, "empty": nil}
+
+	got := snapshotRowKey(row, []string{"id", "date", "empty"})
+	want := "42|2024-01-02T03:04:05Z|"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
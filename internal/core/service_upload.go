@@ -16,24 +16,78 @@ import (
 // StartUpload begins an asynchronous upload operation.
 // Returns the upload ID immediately. Use SubscribeProgress to get updates.
 // If mapping is non-nil, it maps expected column names to CSV column indices.
+// An empty mode uses the table's default UploadMode, and an empty dups
+// policy the mode's default (see DuplicatePolicy). dateOpts sets how dates
+// are read (see DateOptions). fileData may be gzip-compressed. Upload hooks
+// (see UploadHook) may change mapping, mode, dups and dateOpts, or reject
+// the upload with ErrUploadRejected. ContextWithImportColumns limits the
+// upload to some of the table's columns, and ContextWithMappingConstants
+// fills some with constants.
 //
 // Returns ErrTooManyUploads if the concurrent upload limit is reached and
 // no slot becomes available within the timeout period.
-func (s *Service) StartUpload(ctx context.Context, tableKey string, fileName string, fileData []byte, mapping map[string]int) (string, error) {
+func (s *Service) StartUpload(ctx context.Context, tableKey string, fileName string, fileData []byte, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, error) {
 	def, ok := Get(tableKey)
 	if !ok {
 		return "", fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return "", err
+	}
+
+	mode, err := resolveUploadMode(def, mode)
+	if err != nil {
+		return "", err
+	}
+	mode, dups, err = resolveDuplicatePolicy(def, mode, dups)
+	if err != nil {
+		return "", err
+	}
+	dateOpts, err = dateOpts.validated()
+	if err != nil {
+		return "", err
+	}
+
+	fileData, err = decompressBytes(fileData, s.cfg.Upload.MaxFileSize)
+	if err != nil {
+		return "", err
+	}
+
+	req := UploadRequest{TableKey: tableKey, FileName: fileName, Size: int64(len(fileData)), Mapping: mapping, Mode: mode, Duplicates: dups, Dates: dateOpts}
+	if err := s.admitUpload(ctx, def, &req); err != nil {
+		return "", err
+	}
+	mapping, mode, dups, dateOpts = req.Mapping, req.Mode, req.Duplicates, req.Dates
+
+	def, omitted, err := s.selectImportColumns(ctx, def, mode)
+	if err != nil {
+		return "", err
+	}
+	constants, err := uploadConstants(ctx, def, mapping, dateOpts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkUploadLimits(ctx, def, int64(len(fileData))); err != nil {
+		return "", err
+	}
+
+	uploader := GetUploaderFromContext(ctx)
+	if err := s.chargeUploadQuota(ctx, tableKey, uploader, 1, int64(len(fileData))); err != nil {
+		return "", err
+	}
 
 	// Acquire upload slot (blocks until available or timeout)
-	if err := s.uploadLimiter.Acquire(ctx); err != nil {
-		return "", fmt.Errorf("acquire upload slot for %s: %w", tableKey, err)
+	release, err := s.acquireUploadSlot(ctx, tableKey, uploader)
+	if err != nil {
+		s.uploadQuotas.Refund(uploader, 1, int64(len(fileData)))
+		return "", err
 	}
 
 	uploadID := uuid.New().String()
 
 	// Create cancellable context
-	uploadCtx, cancel := context.WithTimeout(context.Background(), s.UploadTimeout())
+	uploadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.UploadTimeout()) // Keeps the caller for audit
 
 	upload := &activeUpload{
 		ID:       uploadID,
@@ -46,9 +100,17 @@ func (s *Service) StartUpload(ctx context.Context, tableKey string, fileName str
 			Phase:    PhaseStarting,
 			FileName: fileName,
 		},
-		Done:      make(chan struct{}),
-		Listeners: make([]chan UploadProgress, 0),
-		Mapping:   mapping,
+		Done:       make(chan struct{}),
+		Listeners:  make([]chan UploadProgress, 0),
+		Mapping:    mapping,
+		Mode:       mode,
+		Duplicates: dups,
+		Dates:      dateOpts,
+		Omitted:    omitted,
+		Template:   templateFromContext(ctx),
+		Transforms: templateTransforms(ctx),
+		Constants:  constants,
+		Op:         s.startOperation(ctx, uploadID, OperationUpload, tableKey, uploadSteps(mode)),
 	}
 
 	s.mu.Lock()
@@ -57,7 +119,7 @@ func (s *Service) StartUpload(ctx context.Context, tableKey string, fileName str
 
 	// Process in background with panic recovery to ensure limiter release
 	go func() {
-		defer s.uploadLimiter.Release()
+		defer release()
 		defer func() {
 			if r := recover(); r != nil {
 				slog.Error("panic in upload",
@@ -87,29 +149,88 @@ func (s *Service) StartUpload(ctx context.Context, tableKey string, fileName str
 // Parameters:
 //   - reader: The CSV file data as an io.Reader (typically http.Request.FormFile)
 //   - fileSize: Total file size in bytes for progress tracking (0 if unknown)
+//   - mode: How rows interact with existing rows; empty uses the table default
+//   - dups: What to do with duplicate keys; empty uses the mode's default
+//   - dateOpts: How dates are read; the zero value reads them month first
 //
 // The reader is wrapped with:
+//   - Gzip decompression, if the input is gzip (e.g. a .csv.gz export)
+//   - Transcoding from UTF-16 or Windows-1252 to UTF-8, if sniffed
 //   - BOM detection/skipping (handles Windows UTF-8 files)
 //   - UTF-8 sanitization (replaces invalid sequences)
 //   - Byte counting (for progress reporting)
 //
+// As with StartUpload, upload hooks may change or reject the upload, and
+// ContextWithImportColumns limits it to some of the table's columns.
+//
 // Returns ErrTooManyUploads if the concurrent upload limit is reached and
 // no slot becomes available within the timeout period.
-func (s *Service) StartUploadStreaming(ctx context.Context, tableKey string, fileName string, reader io.Reader, fileSize int64, mapping map[string]int) (string, error) {
+//
+// If reader is an io.Closer (e.g. a spooled upload), it is closed once
+// processing finishes. If an error is returned, the caller still owns it.
+func (s *Service) StartUploadStreaming(ctx context.Context, tableKey string, fileName string, reader io.Reader, fileSize int64, mapping map[string]int, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (string, error) {
 	def, ok := Get(tableKey)
 	if !ok {
 		return "", fmt.Errorf("unknown table: %s", tableKey)
 	}
+	if err := checkWritable(def); err != nil {
+		return "", err
+	}
+
+	mode, err := resolveUploadMode(def, mode)
+	if err != nil {
+		return "", err
+	}
+	mode, dups, err = resolveDuplicatePolicy(def, mode, dups)
+	if err != nil {
+		return "", err
+	}
+	dateOpts, err = dateOpts.validated()
+	if err != nil {
+		return "", err
+	}
+
+	req := UploadRequest{TableKey: tableKey, FileName: fileName, Size: fileSize, Mapping: mapping, Mode: mode, Duplicates: dups, Dates: dateOpts}
+	if err := s.admitUpload(ctx, def, &req); err != nil {
+		return "", err
+	}
+	mapping, mode, dups, dateOpts = req.Mapping, req.Mode, req.Duplicates, req.Dates
+
+	def, omitted, err := s.selectImportColumns(ctx, def, mode)
+	if err != nil {
+		return "", err
+	}
+	constants, err := uploadConstants(ctx, def, mapping, dateOpts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkUploadLimits(ctx, def, fileSize); err != nil {
+		return "", err
+	}
+
+	// Parquet is read by row group and converted to CSV (see parquet.go)
+	parquet, err := parquetSource(reader, fileName, fileSize, def, len(mapping) > 0)
+	if err != nil {
+		return "", err
+	}
+
+	uploader := GetUploaderFromContext(ctx)
+	if err := s.chargeUploadQuota(ctx, tableKey, uploader, 1, fileSize); err != nil {
+		return "", err
+	}
 
 	// Acquire upload slot (blocks until available or timeout)
-	if err := s.uploadLimiter.Acquire(ctx); err != nil {
-		return "", fmt.Errorf("acquire upload slot for %s: %w", tableKey, err)
+	release, err := s.acquireUploadSlot(ctx, tableKey, uploader)
+	if err != nil {
+		s.uploadQuotas.Refund(uploader, 1, fileSize)
+		return "", err
 	}
 
 	uploadID := uuid.New().String()
 
 	// Create cancellable context
-	uploadCtx, cancel := context.WithTimeout(context.Background(), s.UploadTimeout())
+	uploadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.UploadTimeout()) // Keeps the caller for audit
 
 	upload := &activeUpload{
 		ID:       uploadID,
@@ -123,21 +244,37 @@ func (s *Service) StartUploadStreaming(ctx context.Context, tableKey string, fil
 			FileName:   fileName,
 			BytesTotal: fileSize,
 		},
-		Done:      make(chan struct{}),
-		Listeners: make([]chan UploadProgress, 0),
-		Mapping:   mapping,
+		Done:       make(chan struct{}),
+		Listeners:  make([]chan UploadProgress, 0),
+		Mapping:    mapping,
+		Mode:       mode,
+		Duplicates: dups,
+		Dates:      dateOpts,
+		Omitted:    omitted,
+		Template:   templateFromContext(ctx),
+		Transforms: templateTransforms(ctx),
+		Constants:  constants,
+		Op:         s.startOperation(ctx, uploadID, OperationUpload, tableKey, uploadSteps(mode)),
 	}
 
 	s.mu.Lock()
 	s.uploads[uploadID] = upload
 	s.mu.Unlock()
 
-	// Wrap reader with streaming processors (BOM skip, UTF-8 sanitize, byte counting)
-	streamingReader := WrapForStreaming(reader, fileSize)
+	// Wrap reader with streaming processors (gunzip, BOM skip, UTF-8 sanitize, byte counting)
+	var streamingReader *StreamingCountingReader
+	if parquet != nil {
+		streamingReader = wrapParquetForStreaming(parquet, fileSize)
+	} else {
+		streamingReader = wrapForStreaming(reader, fileSize, s.cfg.Upload.MaxFileSize)
+	}
 
 	// Process in background with panic recovery to ensure limiter release
 	go func() {
-		defer s.uploadLimiter.Release()
+		defer release()
+		if c, ok := reader.(io.Closer); ok {
+			defer c.Close()
+		}
 		defer func() {
 			if r := recover(); r != nil {
 				slog.Error("panic in streaming upload",
@@ -161,9 +298,63 @@ func (s *Service) StartUploadStreaming(ctx context.Context, tableKey string, fil
 	return uploadID, nil
 }
 
+// checkUploadLimits enforces the table's file size and daily upload limits
+// before an upload starts. fileSize may be 0 if unknown. The row limit is
+// enforced while rows are processed (see rowLimitError).
+func (s *Service) checkUploadLimits(ctx context.Context, def TableDefinition, fileSize int64) error {
+	limits := def.Limits
+
+	if limits.MaxFileBytes > 0 && fileSize > limits.MaxFileBytes {
+		return fmt.Errorf("file exceeds table size limit for %s: %d bytes (max %d)",
+			def.Info.Key, fileSize, limits.MaxFileBytes)
+	}
+
+	if limits.MaxUploadsPerDay > 0 {
+		ctx, cancel := s.withOpTimeout(ctx, opQuery)
+		defer cancel()
+
+		var count int
+		err := s.pool.QueryRow(ctx, `
+			SELECT COUNT(*)
+			FROM csv_uploads
+			WHERE name = $1
+			  AND status = 'active'
+			  AND uploaded_at >= date_trunc('day', now())`,
+			def.Info.Key,
+		).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("count today's uploads for %s: %w", def.Info.Key, err)
+		}
+		if count >= limits.MaxUploadsPerDay {
+			return fmt.Errorf("daily upload limit reached for %s: %d uploads today (max %d)",
+				def.Info.Key, count, limits.MaxUploadsPerDay)
+		}
+	}
+
+	return nil
+}
+
+// rowLimitError returns the error for an upload that exceeded the table's
+// MaxRows, or "" if the limit is unset or not yet exceeded.
+func rowLimitError(def TableDefinition, rows int) string {
+	if def.Limits.MaxRows <= 0 || rows <= def.Limits.MaxRows {
+		return ""
+	}
+	return fmt.Sprintf("upload exceeds table row limit for %s (max %d rows)", def.Info.Key, def.Limits.MaxRows)
+}
+
 // SubscribeProgress returns a channel that receives progress updates.
-// The channel is closed when the upload completes.
+// The current progress is sent immediately.
 func (s *Service) SubscribeProgress(uploadID string) (<-chan UploadProgress, error) {
+	return s.ResumeProgress(uploadID, 0)
+}
+
+// ResumeProgress is SubscribeProgress for a client reconnecting after the
+// update with EventID lastEventID. The channel first receives the phase
+// changes sent since then, so the client sees each phase it missed, then
+// the current progress and later updates. If the upload has finished the
+// channel is closed after the replay.
+func (s *Service) ResumeProgress(uploadID string, lastEventID int64) (<-chan UploadProgress, error) {
 	s.mu.RLock()
 	upload, ok := s.uploads[uploadID]
 	s.mu.RUnlock()
@@ -172,23 +363,44 @@ func (s *Service) SubscribeProgress(uploadID string) (<-chan UploadProgress, err
 		return nil, fmt.Errorf("upload not found: %s", uploadID)
 	}
 
-	ch := make(chan UploadProgress, 10)
-
 	// Get thread-safe copy of current progress before acquiring listener lock
 	currentProgress := upload.getProgress()
 
 	upload.ListenerMu.Lock()
-	upload.Listeners = append(upload.Listeners, ch)
-	// Send current progress immediately
-	select {
-	case ch <- currentProgress:
-	default:
-	}
-	upload.ListenerMu.Unlock()
+	defer upload.ListenerMu.Unlock()
+
+	currentProgress.EventID = upload.events
+	replay := missedPhases(upload.phases, lastEventID, currentProgress.EventID)
+	replay = append(replay, currentProgress)
 
+	ch := make(chan UploadProgress, len(replay)+10)
+	for _, p := range replay {
+		ch <- p
+	}
+	if upload.closed {
+		close(ch)
+	} else {
+		upload.Listeners = append(upload.Listeners, ch)
+	}
 	return ch, nil
 }
 
+// missedPhases returns the phase changes sent after lastEventID and before
+// the current update, which is sent in full. A lastEventID of 0 is a new
+// subscriber and misses nothing.
+func missedPhases(phases []UploadProgress, lastEventID, current int64) []UploadProgress {
+	if lastEventID <= 0 {
+		return nil
+	}
+	var missed []UploadProgress
+	for _, p := range phases {
+		if p.EventID > lastEventID && p.EventID < current {
+			missed = append(missed, p)
+		}
+	}
+	return missed
+}
+
 // CancelUpload cancels an in-progress upload.
 func (s *Service) CancelUpload(uploadID string) error {
 	s.mu.RLock()
@@ -249,11 +461,24 @@ type LastUploadInfo struct {
 
 // GetTableRowCount returns the row count for a specific table.
 func (s *Service) GetTableRowCount(ctx context.Context, tableKey string) (int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	return countTable(ctx, s.pool, tableKey)
 }
 
 // GetTableStats returns row count and last upload info for a table.
+// Results are cached (see query_cache.go).
 func (s *Service) GetTableStats(ctx context.Context, tableKey string) (*TableStats, error) {
+	return cached(&s.queryCache, s.cacheTTL(), queryCacheKey{tableKey, "stats"}, func() (*TableStats, error) {
+		return s.getTableStats(ctx, tableKey)
+	})
+}
+
+func (s *Service) getTableStats(ctx context.Context, tableKey string) (*TableStats, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	count, err := countTable(ctx, s.pool, tableKey)
 	if err != nil {
 		return nil, err
@@ -274,6 +499,9 @@ func (s *Service) GetTableStats(ctx context.Context, tableKey string) (*TableSta
 
 // GetLastUpload returns info about the last upload for a table.
 func (s *Service) GetLastUpload(ctx context.Context, tableKey string) (*LastUploadInfo, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	row, err := db.New(s.pool).GetLastUpload(ctx, tableKey)
 	if err != nil {
 		return nil, err
@@ -293,7 +521,17 @@ func (s *Service) GetLastUpload(ctx context.Context, tableKey string) (*LastUplo
 
 // GetAllTableStats returns stats for all registered tables in a single optimized query.
 // This reduces N+1 queries (14 for 7 tables) to just 2 queries total.
+// Results are cached (see query_cache.go).
 func (s *Service) GetAllTableStats(ctx context.Context) (map[string]*TableStats, error) {
+	return cached(&s.queryCache, s.cacheTTL(), queryCacheKey{allTablesCacheKey, "stats"}, func() (map[string]*TableStats, error) {
+		return s.getAllTableStats(ctx)
+	})
+}
+
+func (s *Service) getAllTableStats(ctx context.Context) (map[string]*TableStats, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
 	// Get all registered table keys
 	allDefs := All()
 	if len(allDefs) == 0 {
@@ -309,10 +547,14 @@ func (s *Service) GetAllTableStats(ctx context.Context) (map[string]*TableStats,
 	// Build: SELECT 'table_key' as table_key, COUNT(*) as cnt FROM table_key UNION ALL ...
 	var unionParts []string
 	for _, def := range allDefs {
+		wb := NewWhereBuilder()
+		wb.AddLiveRows(def)
+		whereClause, _ := wb.Build()
 		unionParts = append(unionParts, fmt.Sprintf(
-			"SELECT '%s' as table_key, COUNT(*) as cnt FROM %s",
+			"SELECT '%s' as table_key, COUNT(*) as cnt FROM %s%s",
 			def.Info.Key,
 			quoteIdentifier(def.Info.Key),
+			whereClause,
 		))
 	}
 	countQuery := strings.Join(unionParts, " UNION ALL ")
@@ -397,6 +639,9 @@ type UploadHistoryEntry struct {
 
 // GetUploadHistory returns the upload history for a table.
 func (s *Service) GetUploadHistory(ctx context.Context, tableKey string) ([]UploadHistoryEntry, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	rows, err := db.New(s.pool).GetUploadHistory(ctx, tableKey)
 	if err != nil {
 		return nil, err
@@ -442,6 +687,9 @@ type UploadWithHeaders struct {
 
 // GetUploadWithHeaders returns upload info including CSV headers.
 func (s *Service) GetUploadWithHeaders(ctx context.Context, uploadID string) (*UploadWithHeaders, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	var pgUUID pgtype.UUID
 	if err := pgUUID.Scan(uploadID); err != nil {
 		return nil, fmt.Errorf("invalid upload ID: %w", err)
@@ -460,14 +708,32 @@ func (s *Service) GetUploadWithHeaders(ctx context.Context, uploadID string) (*U
 	}, nil
 }
 
+// UploadTableKey returns the table of upload uploadID, running or done.
+func (s *Service) UploadTableKey(ctx context.Context, uploadID string) (string, error) {
+	s.mu.RLock()
+	upload, ok := s.uploads[uploadID]
+	s.mu.RUnlock()
+	if ok {
+		return upload.TableKey, nil
+	}
+	u, err := s.GetUploadWithHeaders(ctx, uploadID)
+	if err != nil {
+		return "", err
+	}
+	return u.TableKey, nil
+}
+
 // GetFailedRows returns all failed rows for an upload.
 func (s *Service) GetFailedRows(ctx context.Context, uploadID string) ([]FailedRowExport, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	var pgUUID pgtype.UUID
 	if err := pgUUID.Scan(uploadID); err != nil {
 		return nil, fmt.Errorf("invalid upload ID: %w", err)
 	}
 
-	rows, err := db.New(s.pool).GetFailedRowsByUploadId(ctx, pgUUID)
+	rows, err := s.listFailedRows(ctx, pgUUID, 0, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -499,6 +765,9 @@ type UploadDetail struct {
 
 // GetUploadDetail returns full details about an upload.
 func (s *Service) GetUploadDetail(ctx context.Context, uploadID string) (*UploadDetail, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	var pgUUID pgtype.UUID
 	if err := pgUUID.Scan(uploadID); err != nil {
 		return nil, fmt.Errorf("invalid upload ID: %w", err)
@@ -533,6 +802,9 @@ type UploadRowsResult struct {
 
 // GetUploadInsertedRows returns paginated rows inserted by a specific upload.
 func (s *Service) GetUploadInsertedRows(ctx context.Context, uploadID, tableKey string, page, pageSize int) (*UploadRowsResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	def, ok := Get(tableKey)
 	if !ok {
 		return nil, fmt.Errorf("unknown table: %s", tableKey)
@@ -546,6 +818,7 @@ func (s *Service) GetUploadInsertedRows(ctx context.Context, uploadID, tableKey
 	// Build WHERE clause for upload_id
 	wb := NewWhereBuilder()
 	wb.AddUploadID(uploadID)
+	wb.AddLiveRows(def)
 	whereClause, queryArgs := wb.Build()
 
 	// Get total count
@@ -625,6 +898,9 @@ type FailedRowDetail struct {
 
 // GetUploadFailedRowsPaginated returns paginated failed rows for an upload.
 func (s *Service) GetUploadFailedRowsPaginated(ctx context.Context, uploadID string, page, pageSize int) ([]FailedRowDetail, int64, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	var pgUUID pgtype.UUID
 	if err := pgUUID.Scan(uploadID); err != nil {
 		return nil, 0, fmt.Errorf("invalid upload ID: %w", err)
@@ -638,11 +914,7 @@ func (s *Service) GetUploadFailedRowsPaginated(ctx context.Context, uploadID str
 
 	// Get paginated rows
 	offset := (page - 1) * pageSize
-	rows, err := db.New(s.pool).GetFailedRowsByUploadIdPaginated(ctx, db.GetFailedRowsByUploadIdPaginatedParams{
-		UploadID: pgUUID,
-		Limit:    int32(pageSize),
-		Offset:   int32(offset),
-	})
+	rows, err := s.listFailedRows(ctx, pgUUID, pageSize, offset)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -0,0 +1,83 @@
+package core
+
+import "testing"
+
+func TestResumeProgress(t *testing.T) {
+	s := &Service{uploads: make(map[string]*activeUpload)}
+	upload := &activeUpload{ID: "u1"}
+	s.uploads["u1"] = upload
+
+	step := func(phase UploadPhase, row int) {
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = phase
+			p.CurrentRow = row
+		})
+		upload.notifyProgress()
+	}
+	step(PhaseStarting, 0)   // 1
+	step(PhaseValidating, 0) // 2
+	step(PhaseValidating, 50)
+	step(PhaseInserting, 50) // 4
+	step(PhaseInserting, 90)
+
+	drain := func(ch <-chan UploadProgress) []int64 {
+		var ids []int64
+		for {
+			select {
+			case p, ok := <-ch:
+				if !ok {
+					return ids
+				}
+				ids = append(ids, p.EventID)
+			default:
+				return ids
+			}
+		}
+	}
+	equal := func(a, b []int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, tt := range []struct {
+		last int64
+		want []int64
+	}{
+		{0, []int64{5}},
+		{1, []int64{2, 4, 5}},
+		{3, []int64{4, 5}},
+		{5, []int64{5}},
+	} {
+		ch, err := s.ResumeProgress("u1", tt.last)
+		if err != nil {
+			t.Fatalf("ResumeProgress: %v", err)
+		}
+		if got := drain(ch); !equal(got, tt.want) {
+			t.Errorf("after %d: event IDs = %v, want %v", tt.last, got, tt.want)
+		}
+	}
+
+	// A finished upload replays, then closes the channel
+	upload.closeListeners()
+	ch, err := s.ResumeProgress("u1", 3)
+	if err != nil {
+		t.Fatalf("ResumeProgress: %v", err)
+	}
+	if got := drain(ch); !equal(got, []int64{4, 5}) {
+		t.Errorf("finished: event IDs = %v", got)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel of a finished upload should be closed")
+	}
+
+	if _, err := s.ResumeProgress("missing", 0); err == nil {
+		t.Error("expected error for unknown upload")
+	}
+}
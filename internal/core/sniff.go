@@ -0,0 +1,345 @@
+package core
+
+// sniff.go detects the character encoding and delimiter of uploaded CSVs.
+//
+// Not every export is comma-separated UTF-8: European NetSuite exports use
+// semicolons, Excel's "Unicode Text" is tab-separated UTF-16, and older
+// Windows tools write Windows-1252. The encoding is sniffed from the first
+// sniffSize bytes and the input transcoded to UTF-8 before any other
+// processing; the delimiter is then sniffed from the transcoded text and
+// handed to csv.Reader.
+//
+// Latin-1 (ISO-8859-1) is decoded as Windows-1252, which differs only in
+// 0x80-0x9F: Latin-1 puts rarely used C1 control codes there, Windows-1252
+// puts printable characters such as € and curly quotes.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// sniffSize is how much of the input is inspected to pick the encoding and
+// delimiter.
+const sniffSize = 64 * 1024
+
+// textEncoding is a character encoding of uploaded text.
+type textEncoding int
+
+const (
+	encUTF8 textEncoding = iota
+	encUTF16LE
+	encUTF16BE
+	encWindows1252
+)
+
+func (e textEncoding) String() string {
+	switch e {
+	case encUTF16LE:
+		return "UTF-16LE"
+	case encUTF16BE:
+		return "UTF-16BE"
+	case encWindows1252:
+		return "Windows-1252"
+	default:
+		return "UTF-8"
+	}
+}
+
+// sniffEncoding guesses the encoding of text starting with head and returns
+// it with the length of its byte order mark, if any. A UTF-8 BOM is left in
+// place; BOMSkippingReader and stripBOM remove it.
+//
+// A BOM decides the encoding. UTF-16 without one is recognized by the
+// zero byte that every ASCII character carries. Otherwise text that is
+// mostly valid UTF-8 is UTF-8 (the invalid bytes are sanitized later), and
+// anything else is Windows-1252.
+func sniffEncoding(head []byte) (textEncoding, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return encUTF16LE, 2
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return encUTF16BE, 2
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return encUTF8, 0
+	}
+
+	if enc, ok := sniffUTF16(head); ok {
+		return enc, 0
+	}
+	if looksLikeUTF8(head) {
+		return encUTF8, 0
+	}
+	return encWindows1252, 0
+}
+
+// sniffUTF16 reports UTF-16 when at least a third of the code units in head
+// have a zero high byte and none have a zero low byte, as in ASCII text.
+func sniffUTF16(head []byte) (textEncoding, bool) {
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	units := len(head) / 2
+	if units < 2 {
+		return encUTF8, false
+	}
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			evenZeros++
+		}
+		if head[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros*3 >= units && evenZeros == 0:
+		return encUTF16LE, true
+	case evenZeros*3 >= units && oddZeros == 0:
+		return encUTF16BE, true
+	}
+	return encUTF8, false
+}
+
+// looksLikeUTF8 reports whether head is UTF-8 with at most a few stray bytes:
+// valid multi-byte sequences must outnumber invalid bytes. A sequence cut
+// off at the end of head is ignored.
+func looksLikeUTF8(head []byte) bool {
+	head = head[:len(head)-incompleteTrailingBytes(head)]
+	if utf8.Valid(head) {
+		return true
+	}
+	var multi, invalid int
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			multi++
+		}
+		head = head[size:]
+	}
+	return multi > invalid
+}
+
+// windows1252 maps bytes 0x80-0x9F to Unicode. The five bytes Windows-1252
+// leaves undefined map to the C1 control code of the same value, as Latin-1
+// does.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeWindows1252 appends the UTF-8 encoding of Windows-1252 src to dst.
+func decodeWindows1252(dst, src []byte) []byte {
+	for _, b := range src {
+		switch {
+		case b < 0x80:
+			dst = append(dst, b)
+		case b < 0xA0:
+			dst = utf8.AppendRune(dst, windows1252[b-0x80])
+		default:
+			dst = utf8.AppendRune(dst, rune(b))
+		}
+	}
+	return dst
+}
+
+// decodeUTF16 appends the UTF-8 encoding of UTF-16 src to dst and returns
+// it with the bytes of src left undecoded: an odd trailing byte or a lone
+// high surrogate that may be completed by the next read. At EOF nothing is
+// left; incomplete input decodes to U+FFFD.
+func decodeUTF16(dst, src []byte, order binary.ByteOrder, atEOF bool) ([]byte, []byte) {
+	for len(src) >= 2 {
+		r := rune(order.Uint16(src))
+		if utf16.IsSurrogate(r) && r < 0xDC00 {
+			if len(src) < 4 {
+				if !atEOF {
+					break
+				}
+				dst = utf8.AppendRune(dst, utf8.RuneError)
+				src = src[2:]
+				continue
+			}
+			if dec := utf16.DecodeRune(r, rune(order.Uint16(src[2:]))); dec != utf8.RuneError {
+				dst = utf8.AppendRune(dst, dec)
+				src = src[4:]
+				continue
+			}
+			r = utf8.RuneError
+		} else if utf16.IsSurrogate(r) {
+			r = utf8.RuneError
+		}
+		dst = utf8.AppendRune(dst, r)
+		src = src[2:]
+	}
+	if atEOF && len(src) > 0 {
+		dst = utf8.AppendRune(dst, utf8.RuneError)
+		src = nil
+	}
+	return dst, src
+}
+
+// transcodingReader converts input to UTF-8, sniffing its encoding on the
+// first Read. UTF-8 input passes through untouched.
+type transcodingReader struct {
+	src     *bufio.Reader
+	enc     textEncoding
+	decided bool
+	buf     []byte // Raw bytes read but not yet decoded
+	out     []byte // Decoded bytes not yet returned
+	err     error  // Error from src, returned once out is drained
+}
+
+func newTranscodingReader(r io.Reader) *transcodingReader {
+	return &transcodingReader{src: bufio.NewReaderSize(r, sniffSize)}
+}
+
+func (t *transcodingReader) Read(p []byte) (int, error) {
+	if !t.decided {
+		t.decided = true
+		head, _ := t.src.Peek(sniffSize)
+		var bom int
+		t.enc, bom = sniffEncoding(head)
+		t.src.Discard(bom)
+	}
+	if t.enc == encUTF8 {
+		return t.src.Read(p)
+	}
+
+	for len(t.out) == 0 && t.err == nil {
+		chunk := make([]byte, 32*1024)
+		n, err := t.src.Read(chunk)
+		t.buf = append(t.buf, chunk[:n]...)
+		if err != nil {
+			t.err = err
+		}
+		t.out, t.buf = t.decode(t.out[:0], t.buf, err != nil)
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	if len(t.out) == 0 && t.err != nil {
+		return n, t.err
+	}
+	return n, nil
+}
+
+func (t *transcodingReader) decode(dst, src []byte, atEOF bool) ([]byte, []byte) {
+	switch t.enc {
+	case encUTF16LE:
+		dst, rest := decodeUTF16(dst, src, binary.LittleEndian, atEOF)
+		return dst, append(src[:0], rest...)
+	case encUTF16BE:
+		dst, rest := decodeUTF16(dst, src, binary.BigEndian, atEOF)
+		return dst, append(src[:0], rest...)
+	default:
+		return decodeWindows1252(dst, src), src[:0]
+	}
+}
+
+// sniffHead returns the part of data that is sniffed.
+func sniffHead(data []byte) []byte {
+	if len(data) > sniffSize {
+		return data[:sniffSize]
+	}
+	return data
+}
+
+// toUTF8 returns data transcoded to UTF-8 with invalid sequences replaced,
+// for uploads processed in memory.
+func toUTF8(data []byte) []byte {
+	enc, bom := sniffEncoding(sniffHead(data))
+	data = data[bom:]
+
+	switch enc {
+	case encUTF16LE:
+		data, _ = decodeUTF16(make([]byte, 0, len(data)), data, binary.LittleEndian, true)
+	case encUTF16BE:
+		data, _ = decodeUTF16(make([]byte, 0, len(data)), data, binary.BigEndian, true)
+	case encWindows1252:
+		data = decodeWindows1252(make([]byte, 0, len(data)+len(data)/8), data)
+	}
+	return sanitizeUTF8(data)
+}
+
+// delimiters are the field separators sniffDelimiter chooses from, in order
+// of preference on a tie.
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// sniffDelimiter picks the delimiter of the CSV text in head (UTF-8, after
+// transcoding). For each candidate it counts occurrences outside quotes on
+// each of the first lines and takes the most common non-zero count; the
+// candidate with that count on the most lines wins, then the one with the
+// higher count. Title rows above the header don't match and are outvoted.
+// Returns ',' when no candidate appears at all.
+func sniffDelimiter(head []byte) rune {
+	const maxLines = 50
+
+	counts := make([][]int, len(delimiters))
+	line := make([]int, len(delimiters))
+	lines := 0
+	inQuotes := false
+	endLine := func() {
+		for i := range delimiters {
+			counts[i] = append(counts[i], line[i])
+			line[i] = 0
+		}
+		lines++
+	}
+	for _, b := range head {
+		if lines == maxLines {
+			break
+		}
+		switch {
+		case b == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case b == '\n':
+			endLine()
+		default:
+			for i, d := range delimiters {
+				if rune(b) == d {
+					line[i]++
+				}
+			}
+		}
+	}
+	// Keep a partial last line only when it is the whole sample
+	if lines == 0 {
+		endLine()
+	}
+
+	best, bestLines, bestCount := ',', 0, 0
+	for i, d := range delimiters {
+		mode, matching := modeCount(counts[i])
+		if mode == 0 {
+			continue
+		}
+		if matching > bestLines || (matching == bestLines && mode > bestCount) {
+			best, bestLines, bestCount = d, matching, mode
+		}
+	}
+	return best
+}
+
+// modeCount returns the most common non-zero value in counts and how many
+// times it occurs, preferring the larger value on a tie.
+func modeCount(counts []int) (mode, occurrences int) {
+	freq := make(map[int]int)
+	for _, c := range counts {
+		if c > 0 {
+			freq[c]++
+		}
+	}
+	for c, n := range freq {
+		if n > occurrences || (n == occurrences && c > mode) {
+			mode, occurrences = c, n
+		}
+	}
+	return mode, occurrences
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, order binary.AppendByteOrder, bom bool) []byte {
+	var buf []byte
+	if bom {
+		buf = order.AppendUint16(buf, 0xFEFF)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf = order.AppendUint16(buf, u)
+	}
+	return buf
+}
+
+func TestSniffEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    textEncoding
+		wantBOM int
+	}{
+		{"ascii", []byte("id,name\n1,acme\n"), encUTF8, 0},
+		{"utf-8", []byte("id,name\n1,Müller\n"), encUTF8, 0},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFM\xfcller\n"), encUTF8, 0},
+		{"utf-8 cut mid-rune", []byte("id\nM\xC3"), encUTF8, 0},
+		{"utf-8 stray byte", []byte("Müller,Société,Zoë\x96\n"), encUTF8, 0},
+		{"utf-16le bom", encodeUTF16("id;name\n", binary.LittleEndian, true), encUTF16LE, 2},
+		{"utf-16be bom", encodeUTF16("id;name\n", binary.BigEndian, true), encUTF16BE, 2},
+		{"utf-16le no bom", encodeUTF16("id\tname\n", binary.LittleEndian, false), encUTF16LE, 0},
+		{"utf-16be no bom", encodeUTF16("id\tname\n", binary.BigEndian, false), encUTF16BE, 0},
+		{"windows-1252", []byte("name;amount\nM\xfcller;\x80 5\n"), encWindows1252, 0},
+		{"empty", nil, encUTF8, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, bom := sniffEncoding(tt.input)
+			if got != tt.want || bom != tt.wantBOM {
+				t.Errorf("sniffEncoding = %v, %d; want %v, %d", got, bom, tt.want, tt.wantBOM)
+			}
+		})
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	want := "name;amount\nMüller;€ 5\n"
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"utf-8", []byte(want)},
+		{"utf-16le", encodeUTF16(want, binary.LittleEndian, true)},
+		{"utf-16be", encodeUTF16(want, binary.BigEndian, false)},
+		{"windows-1252", []byte("name;amount\nM\xfcller;\x80 5\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(toUTF8(tt.input)); got != want {
+				t.Errorf("toUTF8 = %q, want %q", got, want)
+			}
+		})
+	}
+
+	// Latin-1 matches Windows-1252 outside 0x80-0x9F
+	if got := string(toUTF8([]byte("caf\xe9,\xa9\n"))); got != "café,©\n" {
+		t.Errorf("latin-1: got %q", got)
+	}
+}
+
+func TestDecodeUTF16_Surrogates(t *testing.T) {
+	src := encodeUTF16("a😀b", binary.LittleEndian, false)
+
+	// A surrogate pair split across reads is held back
+	got, rest := decodeUTF16(nil, src[:4], binary.LittleEndian, false)
+	if string(got) != "a" || len(rest) != 2 {
+		t.Fatalf("got %q, rest %d bytes", got, len(rest))
+	}
+	got, rest = decodeUTF16(got, append(rest, src[4:]...), binary.LittleEndian, true)
+	if string(got) != "a😀b" || len(rest) != 0 {
+		t.Errorf("got %q, rest %d bytes", got, len(rest))
+	}
+
+	// Lone surrogates and an odd trailing byte become U+FFFD at EOF
+	bad := append(encodeUTF16("a", binary.LittleEndian, false), 0x00, 0xDC, 0x00, 0xD8, 'x')
+	if got, _ := decodeUTF16(nil, bad, binary.LittleEndian, true); string(got) != "a���" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  rune
+	}{
+		{"comma", "id,name,amount\n1,acme,5\n2,globex,6\n", ','},
+		{"semicolon", "id;name;amount\n1;acme;5,50\n2;globex;1.234,00\n", ';'},
+		{"tab", "id\tname\n1\tacme, inc\n", '\t'},
+		{"pipe", "id|name|amount\n1|acme|5\n", '|'},
+		{"quoted commas", "id;name\n1;\"a,b,c\"\n2;\"d,e,f\"\n", ';'},
+		{"title rows", "Sales Orders, EU;\nExported 2024-01-01\nid;name;amount\n1;acme;5\n2;globex;6\n", ';'},
+		{"single column", "id\n1\n2\n", ','},
+		{"no newline", "a;b;c", ';'},
+		{"empty", "", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffDelimiter([]byte(tt.input)); got != tt.want {
+				t.Errorf("sniffDelimiter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV_Semicolon(t *testing.T) {
+	records, err := parseCSV([]byte("id;amount\n1;5,50\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[1]) != 2 || records[1][1] != "5,50" {
+		t.Errorf("records = %q", records)
+	}
+}
+
+func TestWrapForStreaming_Transcodes(t *testing.T) {
+	want := strings.Repeat("name\tamount\nMüller\t€ 5\n", 2000)
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"utf-16le", encodeUTF16(want, binary.LittleEndian, true)},
+		{"utf-16be", encodeUTF16(want, binary.BigEndian, true)},
+		{"windows-1252", bytes.ReplaceAll(bytes.ReplaceAll([]byte(want), []byte("ü"), []byte{0xfc}), []byte("€"), []byte{0x80})},
+		{"gzip utf-16le", gzipBytes(t, encodeUTF16(want, binary.LittleEndian, true))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time splits code units and surrogate pairs
+			reader := WrapForStreaming(iotest.OneByteReader(bytes.NewReader(tt.input)), int64(len(tt.input)))
+			if d := reader.Delimiter(); d != '\t' {
+				t.Errorf("Delimiter = %q, want tab", d)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("got %d bytes, want %d bytes of UTF-8", len(got), len(want))
+			}
+			if reader.Progress() != 100 {
+				t.Errorf("Progress = %d, want 100", reader.Progress())
+			}
+		})
+	}
+}
+
+func TestStreamingCountingReader_DelimiterDefault(t *testing.T) {
+	if d := NewStreamingCountingReader(strings.NewReader("a;b\n"), 0).Delimiter(); d != ',' {
+		t.Errorf("Delimiter = %q, want ','", d)
+	}
+}
@@ -0,0 +1,432 @@
+package core
+
+// soft_delete.go implements soft delete for tables with SoftDelete set.
+// DeleteRows marks such rows with a deleted_at timestamp instead of
+// removing them, and every query of current data skips marked rows (see
+// liveRowsCondition). The marked rows form the table's recycle bin: they
+// can be listed, restored or purged, and the retention job purges those
+// deleted more than SoftDeleteRetentionDays ago.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// softDeleteColumn holds when a soft-deleted row was deleted; NULL for
+// current rows.
+const softDeleteColumn = "deleted_at"
+
+const recyclePurgeReason = "purge from recycle bin"
+
+// Default and maximum number of recycle bin rows listed.
+const (
+	defaultRecycledRowsLimit = 50
+	maxRecycledRowsLimit     = 500
+)
+
+// validateSoftDelete checks that a soft-delete table can be deleted from.
+func validateSoftDelete(def TableDefinition) error {
+	if !def.SoftDelete {
+		return nil
+	}
+	if def.IsView() {
+		return errors.New("a view cannot use soft delete")
+	}
+	if len(def.Info.UniqueKey) == 0 {
+		return errors.New("soft delete needs a unique key")
+	}
+	return nil
+}
+
+// liveRowsCondition returns the condition that skips soft-deleted rows of
+// def, with columns qualified by alias if given. It is empty for tables
+// without soft delete.
+func liveRowsCondition(def TableDefinition, alias string) string {
+	if !def.SoftDelete {
+		return ""
+	}
+	col := quoteIdentifier(softDeleteColumn)
+	if alias != "" {
+		col = alias + "." + col
+	}
+	return col + " IS NULL"
+}
+
+// andLiveRows returns liveRowsCondition prefixed with AND, for appending to
+// an existing WHERE clause.
+func andLiveRows(def TableDefinition, alias string) string {
+	if cond := liveRowsCondition(def, alias); cond != "" {
+		return " AND " + cond
+	}
+	return ""
+}
+
+// softDeleteIndexSQL creates the index the recycle bin and the purge job
+// read soft-deleted rows by.
+func softDeleteIndexSQL(table string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s) WHERE %s IS NOT NULL",
+		quoteIdentifier("idx_"+table+"_"+softDeleteColumn), quoteIdentifier(table),
+		softDeleteColumn, softDeleteColumn)
+}
+
+// rowKeyConditions matches the unique key columns of def against rowKey
+// ("val1|val2"), numbering placeholders from argIdx.
+func rowKeyConditions(def TableDefinition, rowKey string, argIdx int) ([]string, []interface{}, error) {
+	parts := strings.Split(rowKey, "|")
+	if len(parts) != len(def.Info.UniqueKey) {
+		return nil, nil, fmt.Errorf("invalid key format")
+	}
+	dbCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	conditions := make([]string, len(dbCols))
+	args := make([]interface{}, len(parts))
+	for i, col := range dbCols {
+		conditions[i] = fmt.Sprintf("%s = $%d", quoteIdentifier(col), argIdx+i)
+		args[i] = parts[i]
+	}
+	return conditions, args, nil
+}
+
+// rowKeyExpr returns the SQL expression building a row's key in the
+// "val1|val2" form used by DeleteRows and CheckDuplicates.
+func rowKeyExpr(def TableDefinition) string {
+	dbCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+	parts := make([]string, len(dbCols))
+	for i, col := range dbCols {
+		parts[i] = fmt.Sprintf("COALESCE(%s::text, '')", quoteIdentifier(col))
+	}
+	return strings.Join(parts, " || '|' || ")
+}
+
+// softDeleteTable returns the definition of a soft-delete table.
+func softDeleteTable(tableKey string) (TableDefinition, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return TableDefinition{}, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if !def.SoftDelete {
+		return TableDefinition{}, fmt.Errorf("table %s does not use soft delete", tableKey)
+	}
+	return def, nil
+}
+
+// RecycledRow is a soft-deleted row in a table's recycle bin.
+type RecycledRow struct {
+	RowKey    string    `json:"rowKey"`
+	RowData   TableRow  `json:"rowData"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// RecycleResult contains the result of restoring or purging recycled rows.
+type RecycleResult struct {
+	Restored  int      `json:"restored,omitempty"`
+	Purged    int      `json:"purged,omitempty"`
+	NotFound  []string `json:"notFound,omitempty"`  // Keys with no row in the recycle bin
+	Conflicts []string `json:"conflicts,omitempty"` // Restore: keys already in the table again
+}
+
+// ListRecycledRows returns the rows in a soft-delete table's recycle bin,
+// most recently deleted first. limit defaults to 50 and is capped at 500.
+func (s *Service) ListRecycledRows(ctx context.Context, tableKey string, limit int) ([]RecycledRow, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, err := softDeleteTable(tableKey)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultRecycledRowsLimit
+	}
+	if limit > maxRecycledRowsLimit {
+		limit = maxRecycledRowsLimit
+	}
+
+	displayColumns := def.Info.Columns
+	quotedCols := quoteColumns(resolveDBColumns(displayColumns, def.FieldSpecs))
+	deletedAt := quoteIdentifier(softDeleteColumn)
+	query := fmt.Sprintf(
+		"SELECT %s, %s, %s FROM %s WHERE %s IS NOT NULL ORDER BY %s DESC LIMIT $1",
+		rowKeyExpr(def), deletedAt, strings.Join(quotedCols, ", "),
+		quoteIdentifier(tableKey), deletedAt, deletedAt,
+	)
+	rows, err := s.pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list recycled rows: %w", err)
+	}
+	defer rows.Close()
+
+	recycled := make([]RecycledRow, 0)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("read row values: %w", err)
+		}
+		r := RecycledRow{RowData: make(TableRow, len(displayColumns))}
+		r.RowKey, _ = values[0].(string)
+		r.DeletedAt, _ = values[1].(time.Time)
+		for i, col := range displayColumns {
+			r.RowData[col] = values[i+2]
+		}
+		recycled = append(recycled, r)
+	}
+	return recycled, rows.Err()
+}
+
+// RestoreRecycledRows moves rows out of a soft-delete table's recycle bin.
+// If a key was deleted more than once, its most recently deleted row is
+// restored. A key already in the table again is left in the bin and
+// reported as a conflict. Each restore is recorded as a row_restore entry
+// linked to the row's latest row_delete entry, so RestoreDeletedRows sees
+// it as restored.
+func (s *Service) RestoreRecycledRows(ctx context.Context, tableKey string, keys []string) (*RecycleResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, err := softDeleteTable(tableKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	defer s.invalidateQueryCache(tableKey)
+
+	// Share RestoreDeletedRows's lock, so a row can't be restored by both
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "row_restore:"+tableKey); err != nil {
+		return nil, fmt.Errorf("lock row history: %w", err)
+	}
+
+	result := &RecycleResult{}
+	for _, key := range keys {
+		restored, err := undeleteRow(ctx, tx, def, key)
+		if errors.Is(err, errKeyTaken) {
+			result.Conflicts = append(result.Conflicts, key)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !restored {
+			result.NotFound = append(result.NotFound, key)
+			continue
+		}
+
+		var deleteID string
+		err = tx.QueryRow(ctx, `
+			SELECT id::text FROM audit_log
+			WHERE action = 'row_delete' AND table_key = $1 AND row_key = $2
+			ORDER BY created_at DESC
+			LIMIT 1`,
+			tableKey, key,
+		).Scan(&deleteID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("find row deletion: %w", err)
+		}
+		if err := s.logRowRestore(ctx, tableKey, key, nil, deleteID); err != nil {
+			return nil, err
+		}
+		result.Restored++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return result, nil
+}
+
+// errKeyTaken reports that a recycled row's key is in the table again.
+var errKeyTaken = errors.New("key already exists")
+
+// undeleteRow clears deleted_at on the most recently deleted row with the
+// given key, within tx. It reports false if the key has no row in the
+// recycle bin, and errKeyTaken if a current row has the key.
+func undeleteRow(ctx context.Context, tx pgx.Tx, def TableDefinition, rowKey string) (bool, error) {
+	conditions, args, err := rowKeyConditions(def, rowKey, 1)
+	if err != nil {
+		return false, nil
+	}
+	table := quoteIdentifier(def.Info.Key)
+	where := strings.Join(conditions, " AND ")
+	deletedAt := quoteIdentifier(softDeleteColumn)
+
+	var taken bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s%s)", table, where, andLiveRows(def, ""))
+	if err := tx.QueryRow(ctx, query, args...).Scan(&taken); err != nil {
+		return false, fmt.Errorf("duplicate check failed: %w", err)
+	}
+
+	if taken {
+		// Tell a conflict from a key that is not in the bin
+		query = fmt.Sprintf("SELECT 1 FROM %s WHERE %s AND %s IS NOT NULL LIMIT 1", table, where, deletedAt)
+		var one int
+		err := tx.QueryRow(ctx, query, args...).Scan(&one)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read recycled row: %w", err)
+		}
+		return false, errKeyTaken
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET %s = NULL
+		WHERE id = (
+			SELECT id FROM %s
+			WHERE %s AND %s IS NOT NULL
+			ORDER BY %s DESC
+			LIMIT 1
+		)`, table, deletedAt, table, where, deletedAt, deletedAt)
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("restore row: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PurgeRecycledRows permanently deletes the rows with the given keys from a
+// soft-delete table's recycle bin. Current rows are never touched, and
+// nothing is purged if any of the rows is under a legal hold. The purge is
+// recorded as one row_purge audit entry.
+func (s *Service) PurgeRecycledRows(ctx context.Context, tableKey string, keys []string) (*RecycleResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	def, err := softDeleteTable(tableKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+
+	held := rowKeyExpr(def) + " = ANY($1) AND " + quoteIdentifier(softDeleteColumn) + " IS NOT NULL"
+	if err := s.checkLegalHold(ctx, def, "purge", held, keys); err != nil {
+		return nil, err
+	}
+
+	result := &RecycleResult{}
+	for _, key := range keys {
+		conditions, args, err := rowKeyConditions(def, key, 1)
+		if err != nil {
+			result.NotFound = append(result.NotFound, key)
+			continue
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s AND %s IS NOT NULL",
+			quoteIdentifier(tableKey), strings.Join(conditions, " AND "), quoteIdentifier(softDeleteColumn))
+		tag, err := s.pool.Exec(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("purge failed: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			result.NotFound = append(result.NotFound, key)
+			continue
+		}
+		result.Purged += int(tag.RowsAffected())
+	}
+
+	if result.Purged > 0 {
+		params := AuditLogParams{
+			Action:       ActionRowPurge,
+			TableKey:     tableKey,
+			RowsAffected: result.Purged,
+			Reason:       recyclePurgeReason,
+			IPAddress:    GetIPAddressFromContext(ctx),
+			UserAgent:    GetUserAgentFromContext(ctx),
+		}
+		if len(keys) == 1 {
+			params.RowKey = keys[0]
+		}
+		s.LogAudit(ctx, params)
+	}
+	return result, nil
+}
+
+// purgeExpiredRows permanently deletes rows soft-deleted more than
+// daysToKeep days ago from every soft-delete table, recording a row_purge
+// audit entry per table. Rows under a legal hold stay in the recycle bin.
+// With retention archives enabled, each table's rows are archived first
+// and the table is skipped if that fails. A failing table does not stop
+// the others.
+func (s *Service) purgeExpiredRows(ctx context.Context, daysToKeep int) (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -daysToKeep)
+
+	var total int64
+	var errs []error
+	for _, def := range All() {
+		if !def.SoftDelete {
+			continue
+		}
+		where := quoteIdentifier(softDeleteColumn) + " < $1"
+		args := []any{cutoff}
+		holds, err := s.ListLegalHolds(ctx, def.Info.Key, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purge %s: %w", def.Info.Key, err))
+			continue
+		}
+		if len(holds) > 0 {
+			cond, holdArgs := legalHoldsCondition(def, holds, 2)
+			where += " AND NOT " + cond
+			args = append(args, holdArgs...)
+		}
+
+		reason := fmt.Sprintf("rows deleted more than %d days ago", daysToKeep)
+		if s.retention != nil {
+			archive, err := s.archiveRows(ctx, RetentionRecycleBin, def.Info.Key, where, cutoff, args...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("archive %s before purge: %w", def.Info.Key, err))
+				continue
+			}
+			if archive == nil {
+				continue // Nothing to purge
+			}
+			reason += fmt.Sprintf(" (retention archive %s)", archive.ID)
+		}
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", quoteIdentifier(def.Info.Key), where)
+		tag, err := s.pool.Exec(ctx, query, args...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purge %s: %w", def.Info.Key, err))
+			continue
+		}
+		if n := tag.RowsAffected(); n > 0 {
+			total += n
+			s.LogAudit(ctx, AuditLogParams{
+				Action:       ActionRowPurge,
+				TableKey:     def.Info.Key,
+				RowsAffected: int(n),
+				Reason:       reason,
+			})
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// runRecyclePurge runs one recycle bin purge for the scheduler and returns
+// the rows purged. Errors are logged and returned for the retention
+// operation.
+func (s *Service) runRecyclePurge(ctx context.Context, cfg ArchiveConfig) (int64, error) {
+	if cfg.SoftDeleteRetentionDays <= 0 {
+		return 0, nil
+	}
+	start := time.Now()
+	purged, err := s.purgeExpiredRows(ctx, cfg.SoftDeleteRetentionDays)
+	if err != nil {
+		slog.Error("recycle bin purge failed", "rows_purged", purged, "error", err)
+		return purged, err
+	}
+	slog.Info("purged expired recycle bin rows",
+		"rows_purged", purged,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return purged, nil
+}
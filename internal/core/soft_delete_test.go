@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func softDeleteTestDef() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "ledger", UniqueKey: []string{"Entry ID", "Line"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Entry ID", DBColumn: "entry_id", Type: FieldText},
+			{Name: "Line", Type: FieldNumeric},
+		},
+		SoftDelete: true,
+	}
+}
+
+func TestValidateSoftDelete(t *testing.T) {
+	def := softDeleteTestDef()
+	if err := validateSoftDelete(def); err != nil {
+		t.Errorf("valid: %v", err)
+	}
+
+	def.Info.UniqueKey = nil
+	if err := validateSoftDelete(def); err == nil || !strings.Contains(err.Error(), "unique key") {
+		t.Errorf("no unique key: err = %v", err)
+	}
+
+	def = softDeleteTestDef()
+	def.View = "SELECT 1"
+	if err := validateSoftDelete(def); err == nil || !strings.Contains(err.Error(), "view") {
+		t.Errorf("view: err = %v", err)
+	}
+}
+
+func TestLiveRowsCondition(t *testing.T) {
+	def := softDeleteTestDef()
+	if got := liveRowsCondition(def, ""); got != `"deleted_at" IS NULL` {
+		t.Errorf("got %q", got)
+	}
+	if got := andLiveRows(def, "t"); got != ` AND t."deleted_at" IS NULL` {
+		t.Errorf("got %q", got)
+	}
+
+	def.SoftDelete = false
+	if got := andLiveRows(def, "t"); got != "" {
+		t.Errorf("hard delete: got %q", got)
+	}
+
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(softDeleteTestDef())
+	wb.Add("entry_id", "E1")
+	where, args := wb.Build()
+	if where != ` WHERE "deleted_at" IS NULL AND entry_id = $1` || len(args) != 1 {
+		t.Errorf("where = %q, args = %v", where, args)
+	}
+}
+
+func TestSoftDeleteSQL(t *testing.T) {
+	def := softDeleteTestDef()
+
+	conds, args, err := rowKeyConditions(def, "E1|2", 3)
+	if err != nil || strings.Join(conds, " AND ") != `"entry_id" = $3 AND "line" = $4` || len(args) != 2 || args[1] != "2" {
+		t.Errorf("rowKeyConditions = %v, %v, %v", conds, args, err)
+	}
+	if _, _, err := rowKeyConditions(def, "E1", 1); err == nil {
+		t.Error("short key: want error")
+	}
+	if got := rowKeyExpr(def); got != `COALESCE("entry_id"::text, '') || '|' || COALESCE("line"::text, '')` {
+		t.Errorf("rowKeyExpr = %q", got)
+	}
+
+	// Uploads neither see nor replace rows in the recycle bin
+	if got := existingKeysSQL(def); !strings.Contains(got, `t."deleted_at" IS NULL`) {
+		t.Errorf("existingKeysSQL:\n%s", got)
+	}
+	if got := upsertSQL(def); !strings.Contains(got, `old."deleted_at" IS NULL`) {
+		t.Errorf("upsertSQL:\n%s", got)
+	}
+	def.SoftDelete = false
+	if got := upsertSQL(def); strings.Contains(got, "deleted_at") {
+		t.Errorf("hard delete upsertSQL:\n%s", got)
+	}
+}
+
+func TestRecycleBin_Errors(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "recycle_notes", UniqueKey: []string{"Note"}},
+		FieldSpecs: []FieldSpec{{Name: "Note", Type: FieldText}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "recycle_notes")
+		registryMu.Unlock()
+	})
+	s := &Service{cfg: &config.Config{}}
+	ctx := context.Background()
+
+	if _, err := s.ListRecycledRows(ctx, "nope", 0); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("unknown table: err = %v", err)
+	}
+	if _, err := s.RestoreRecycledRows(ctx, "recycle_notes", []string{"x"}); err == nil || !strings.Contains(err.Error(), "does not use soft delete") {
+		t.Errorf("restore: err = %v", err)
+	}
+	if _, err := s.PurgeRecycledRows(ctx, "recycle_notes", []string{"x"}); err == nil || !strings.Contains(err.Error(), "does not use soft delete") {
+		t.Errorf("purge: err = %v", err)
+	}
+}
@@ -0,0 +1,743 @@
+package core
+
+// spool.go provides encrypted on-disk spooling of uploaded files.
+//
+// When enabled, an upload is written to the spool directory as it is
+// received and processed from there, so the request body never has to be
+// held in memory. Spooled files are always encrypted at rest:
+//
+//   - AES-256-GCM over 64 KiB chunks, so files are encrypted and decrypted
+//     as streams in O(chunk) memory
+//   - Each chunk is authenticated with its position and a final-chunk flag,
+//     so reordered, altered, or truncated files fail to decrypt
+//   - The header names the key that encrypted the file; the first key
+//     encrypts new files and every configured key can decrypt. Keys can be
+//     replaced at runtime (SetKeys) and existing artifacts re-encrypted
+//     with the active key (Rotate), after which old keys can be dropped
+//
+// Files are deleted once their upload finishes. Files left behind by a
+// crash are kept and reported as not in use until an admin deletes them.
+//
+// File layout:
+//
+//	magic (8) | keyID length (1) | keyID | nonce prefix (8)
+//	{ length|finalBit (4, big endian) | ciphertext }...
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolMagic       = "CSVSPL\x00\x01"
+	spoolExt         = ".spool"
+	spoolChunkSize   = 64 << 10
+	spoolNonceSize   = 8
+	spoolFinalBit    = 1 << 31
+	spoolKeySize     = 32
+	spoolMaxKeyIDLen = 255
+)
+
+// ErrSpoolCorrupt is returned when a spooled file fails authentication,
+// is truncated, or was encrypted with a key that is no longer configured.
+var ErrSpoolCorrupt = errors.New("spooled file is corrupt or its key is unavailable")
+
+// ErrSpoolInUse is returned when deleting an artifact that is being processed.
+var ErrSpoolInUse = errors.New("spooled upload is being processed")
+
+// spoolKey is a named AES-256 key.
+type spoolKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Spool stores uploaded files encrypted at rest until they are processed.
+type Spool struct {
+	dir string
+
+	mu    sync.RWMutex
+	keys  []spoolKey // keys[0] encrypts; all decrypt
+	inUse map[string]bool
+}
+
+// SpoolArtifact describes a spooled file for the admin report.
+type SpoolArtifact struct {
+	ID        string    `json:"id"`
+	KeyID     string    `json:"keyId"`
+	DiskBytes int64     `json:"diskBytes"`
+	CreatedAt time.Time `json:"createdAt"`
+	InUse     bool      `json:"inUse"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// SpoolReport summarizes the spool directory and its artifacts.
+type SpoolReport struct {
+	Enabled     bool            `json:"enabled"`
+	Dir         string          `json:"dir,omitempty"`
+	ActiveKeyID string          `json:"activeKeyId,omitempty"`
+	KeyIDs      []string        `json:"keyIds,omitempty"`
+	Artifacts   []SpoolArtifact `json:"artifacts"`
+	Stale       int             `json:"stale"`    // Artifacts not encrypted with the active key
+	Orphaned    int             `json:"orphaned"` // Artifacts not being processed
+}
+
+// SpoolRotation is the result of re-encrypting artifacts with the active key.
+type SpoolRotation struct {
+	Rotated int      `json:"rotated"`
+	Skipped int      `json:"skipped"` // In use; deleted when their upload finishes
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// parseSpoolKeys parses "keyID:base64key" entries into AES-256 keys.
+// The first entry is the active key.
+func parseSpoolKeys(entries []string) ([]spoolKey, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("no spool keys configured")
+	}
+
+	keys := make([]spoolKey, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || len(id) > spoolMaxKeyIDLen {
+			return nil, fmt.Errorf("spool key %d: expected keyID:base64key", i+1)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("spool key %q: duplicate key ID", id)
+		}
+		seen[id] = true
+
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("spool key %q: invalid base64: %w", id, err)
+		}
+		if len(raw) != spoolKeySize {
+			return nil, fmt.Errorf("spool key %q: must be %d bytes, got %d", id, spoolKeySize, len(raw))
+		}
+
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("spool key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("spool key %q: %w", id, err)
+		}
+		keys = append(keys, spoolKey{id: id, aead: aead})
+	}
+	return keys, nil
+}
+
+// NewSpool creates a spool in dir using the given "keyID:base64key" entries.
+// Partial writes from a previous run are removed; complete files are kept.
+func NewSpool(dir string, keyEntries []string) (*Spool, error) {
+	keys, err := parseSpoolKeys(keyEntries)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	sp := &Spool{dir: dir, keys: keys, inUse: make(map[string]bool)}
+
+	partial, _ := filepath.Glob(filepath.Join(dir, "*"+spoolExt+".tmp"))
+	for _, path := range partial {
+		os.Remove(path)
+	}
+	if ids, err := sp.list(); err != nil {
+		return nil, err
+	} else if len(ids) > 0 {
+		slog.Warn("spool contains orphaned uploads from a previous run",
+			"dir", dir, "count", len(ids))
+	}
+
+	return sp, nil
+}
+
+// SetKeys replaces the key ring, e.g. after UPLOAD_SPOOL_KEYS was rotated
+// in a secret store. New files use the first key; existing files stay
+// readable only if their key is still listed.
+func (sp *Spool) SetKeys(keyEntries []string) error {
+	keys, err := parseSpoolKeys(keyEntries)
+	if err != nil {
+		return err
+	}
+	sp.mu.Lock()
+	sp.keys = keys
+	sp.mu.Unlock()
+	return nil
+}
+
+// activeKey returns the key that encrypts new files.
+func (sp *Spool) activeKey() spoolKey {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	return sp.keys[0]
+}
+
+// findKey returns the key with the given ID.
+func (sp *Spool) findKey(id string) (spoolKey, bool) {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	for _, k := range sp.keys {
+		if k.id == id {
+			return k, true
+		}
+	}
+	return spoolKey{}, false
+}
+
+// Write encrypts r into a new spooled file with the active key and returns
+// its ID and the number of plaintext bytes written. The file counts as in
+// use until it is opened and closed, or removed. Nothing is left on disk if
+// writing fails.
+func (sp *Spool) Write(r io.Reader) (string, int64, error) {
+	id, err := newSpoolID()
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := sp.writeFile(sp.path(id), r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	sp.mu.Lock()
+	sp.inUse[id] = true
+	sp.mu.Unlock()
+	return id, n, nil
+}
+
+// writeFile encrypts r into path via a temp file and an atomic rename.
+func (sp *Spool) writeFile(path string, r io.Reader) (int64, error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("create spool file: %w", err)
+	}
+
+	n, err := sp.encrypt(f, r)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("close spool file: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// encrypt writes the header and encrypted chunks of r to w.
+func (sp *Spool) encrypt(w io.Writer, r io.Reader) (int64, error) {
+	key := sp.activeKey()
+
+	prefix := make([]byte, spoolNonceSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return 0, fmt.Errorf("generate nonce: %w", err)
+	}
+	header := make([]byte, 0, len(spoolMagic)+1+len(key.id)+spoolNonceSize)
+	header = append(header, spoolMagic...)
+	header = append(header, byte(len(key.id)))
+	header = append(header, key.id...)
+	header = append(header, prefix...)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(header); err != nil {
+		return 0, fmt.Errorf("write spool header: %w", err)
+	}
+
+	c := spoolCipher{aead: key.aead, prefix: prefix, header: header}
+	plain := make([]byte, spoolChunkSize)
+	next := make([]byte, spoolChunkSize)
+	var sealed []byte
+	var total int64
+
+	// Read one chunk ahead so the last chunk can be flagged as final.
+	n, err := io.ReadFull(r, plain)
+	for {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		final := err != nil
+		var nextN int
+		var nextErr error
+		if !final {
+			nextN, nextErr = io.ReadFull(r, next)
+			if nextErr == io.EOF {
+				final = true
+			}
+		}
+
+		sealed = c.seal(sealed[:0], plain[:n], final)
+		var frame [4]byte
+		length := uint32(len(sealed))
+		if final {
+			length |= spoolFinalBit
+		}
+		binary.BigEndian.PutUint32(frame[:], length)
+		if _, err := bw.Write(frame[:]); err != nil {
+			return 0, fmt.Errorf("write spool chunk: %w", err)
+		}
+		if _, err := bw.Write(sealed); err != nil {
+			return 0, fmt.Errorf("write spool chunk: %w", err)
+		}
+		total += int64(n)
+
+		if final {
+			break
+		}
+		plain, next = next, plain
+		n, err = nextN, nextErr
+	}
+
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("write spool file: %w", err)
+	}
+	return total, nil
+}
+
+// Open returns a reader that decrypts the spooled file transparently.
+// Closing the reader deletes the file.
+func (sp *Spool) Open(id string) (io.ReadCloser, error) {
+	return sp.open(id, func() { sp.Remove(id) })
+}
+
+// OpenRetained is like Open, but closing the reader leaves the file in
+// place; the caller removes it once it is no longer needed.
+func (sp *Spool) OpenRetained(id string) (io.ReadCloser, error) {
+	return sp.open(id, func() {})
+}
+
+func (sp *Spool) open(id string, onClose func()) (io.ReadCloser, error) {
+	if !validSpoolID(id) {
+		return nil, fmt.Errorf("open spooled upload: %w", os.ErrNotExist)
+	}
+	f, err := os.Open(sp.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("open spooled upload: %w", err)
+	}
+	r, err := sp.newReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	sp.mu.Lock()
+	sp.inUse[id] = true
+	sp.mu.Unlock()
+
+	return &spoolReadCloser{spoolReader: r, file: f, onClose: onClose}, nil
+}
+
+// Remove deletes a spooled file that will not be processed.
+func (sp *Spool) Remove(id string) {
+	os.Remove(sp.path(id))
+	sp.mu.Lock()
+	delete(sp.inUse, id)
+	sp.mu.Unlock()
+}
+
+// Delete removes an orphaned artifact. Artifacts in use cannot be deleted.
+func (sp *Spool) Delete(id string) error {
+	if !validSpoolID(id) {
+		return fmt.Errorf("spooled upload %q: %w", id, os.ErrNotExist)
+	}
+	sp.mu.RLock()
+	inUse := sp.inUse[id]
+	sp.mu.RUnlock()
+	if inUse {
+		return ErrSpoolInUse
+	}
+	return os.Remove(sp.path(id))
+}
+
+// Report lists spooled artifacts and the key that encrypted each.
+// A nil *Spool reports spooling as disabled.
+func (sp *Spool) Report() (SpoolReport, error) {
+	if sp == nil {
+		return SpoolReport{Artifacts: []SpoolArtifact{}}, nil
+	}
+
+	report := SpoolReport{
+		Enabled:   true,
+		Dir:       sp.dir,
+		Artifacts: []SpoolArtifact{},
+	}
+
+	ids, err := sp.list()
+	if err != nil {
+		return report, err
+	}
+
+	sp.mu.RLock()
+	report.ActiveKeyID = sp.keys[0].id
+	for _, k := range sp.keys {
+		report.KeyIDs = append(report.KeyIDs, k.id)
+	}
+	inUse := make(map[string]bool, len(sp.inUse))
+	for id := range sp.inUse {
+		inUse[id] = true
+	}
+	sp.mu.RUnlock()
+
+	for _, id := range ids {
+		info, err := os.Stat(sp.path(id))
+		if err != nil {
+			continue // Finished and deleted since listing
+		}
+		a := SpoolArtifact{
+			ID:        id,
+			DiskBytes: info.Size(),
+			CreatedAt: info.ModTime(),
+			InUse:     inUse[id],
+		}
+		if a.KeyID, err = sp.keyIDOf(id); err != nil {
+			a.Error = err.Error()
+		}
+		if a.KeyID != report.ActiveKeyID {
+			report.Stale++
+		}
+		if !a.InUse {
+			report.Orphaned++
+		}
+		report.Artifacts = append(report.Artifacts, a)
+	}
+	sort.Slice(report.Artifacts, func(i, j int) bool {
+		return report.Artifacts[i].CreatedAt.Before(report.Artifacts[j].CreatedAt)
+	})
+	return report, nil
+}
+
+// Rotate re-encrypts every artifact not already encrypted with the active
+// key. Artifacts in use are skipped, since they are deleted when their
+// upload finishes. After a clean rotation, old keys can be removed.
+func (sp *Spool) Rotate() (SpoolRotation, error) {
+	var result SpoolRotation
+	if sp == nil {
+		return result, nil
+	}
+
+	ids, err := sp.list()
+	if err != nil {
+		return result, err
+	}
+	active := sp.activeKey().id
+	for _, id := range ids {
+		keyID, err := sp.keyIDOf(id)
+		if err == nil && keyID == active {
+			continue
+		}
+		sp.mu.RLock()
+		inUse := sp.inUse[id]
+		sp.mu.RUnlock()
+		if inUse {
+			result.Skipped++
+			continue
+		}
+		if err == nil {
+			err = sp.reencrypt(id)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Finished and deleted meanwhile
+		}
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", id, err))
+			continue
+		}
+		result.Rotated++
+	}
+	return result, nil
+}
+
+// reencrypt decrypts an artifact and rewrites it with the active key.
+func (sp *Spool) reencrypt(id string) error {
+	f, err := os.Open(sp.path(id))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := sp.newReader(f)
+	if err != nil {
+		return err
+	}
+	_, err = sp.writeFile(sp.path(id), r)
+	return err
+}
+
+// list returns the IDs of all spooled files.
+func (sp *Spool) list() ([]string, error) {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool directory: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), spoolExt) {
+			ids = append(ids, strings.TrimSuffix(e.Name(), spoolExt))
+		}
+	}
+	return ids, nil
+}
+
+// keyIDOf reads the key ID from an artifact's header.
+func (sp *Spool) keyIDOf(id string) (string, error) {
+	f, err := os.Open(sp.path(id))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	keyID, _, _, err := readSpoolHeader(bufio.NewReader(f))
+	return keyID, err
+}
+
+func (sp *Spool) path(id string) string {
+	return filepath.Join(sp.dir, id+spoolExt)
+}
+
+// newReader reads the header of f and returns a decrypting reader.
+func (sp *Spool) newReader(f io.Reader) (*spoolReader, error) {
+	br := bufio.NewReader(f)
+	keyID, prefix, header, err := readSpoolHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := sp.findKey(keyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrSpoolCorrupt, keyID)
+	}
+	return &spoolReader{
+		r: br,
+		c: spoolCipher{aead: k.aead, prefix: prefix, header: header},
+	}, nil
+}
+
+// readSpoolHeader parses the file header, returning the key ID, nonce
+// prefix, and raw header bytes.
+func readSpoolHeader(r io.Reader) (string, []byte, []byte, error) {
+	fixed := make([]byte, len(spoolMagic)+1)
+	if _, err := io.ReadFull(r, fixed); err != nil || string(fixed[:len(spoolMagic)]) != spoolMagic {
+		return "", nil, nil, fmt.Errorf("%w: bad header", ErrSpoolCorrupt)
+	}
+	rest := make([]byte, int(fixed[len(spoolMagic)])+spoolNonceSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return "", nil, nil, fmt.Errorf("%w: bad header", ErrSpoolCorrupt)
+	}
+	keyLen := len(rest) - spoolNonceSize
+	return string(rest[:keyLen]), rest[keyLen:], append(fixed, rest...), nil
+}
+
+// spoolCipher seals and opens chunks. The nonce is the file's random prefix
+// followed by the chunk index; the header and final flag are authenticated.
+type spoolCipher struct {
+	aead    cipher.AEAD
+	prefix  []byte
+	header  []byte
+	counter uint32
+}
+
+func (c *spoolCipher) nonceAndAAD(final bool) ([]byte, []byte) {
+	nonce := make([]byte, c.aead.NonceSize())
+	copy(nonce, c.prefix)
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], c.counter)
+	c.counter++
+
+	aad := make([]byte, len(c.header)+1)
+	copy(aad, c.header)
+	if final {
+		aad[len(aad)-1] = 1
+	}
+	return nonce, aad
+}
+
+func (c *spoolCipher) seal(dst, plain []byte, final bool) []byte {
+	nonce, aad := c.nonceAndAAD(final)
+	return c.aead.Seal(dst, nonce, plain, aad)
+}
+
+func (c *spoolCipher) open(dst, sealed []byte, final bool) ([]byte, error) {
+	nonce, aad := c.nonceAndAAD(final)
+	return c.aead.Open(dst, nonce, sealed, aad)
+}
+
+// spoolReader decrypts a spooled file chunk by chunk.
+type spoolReader struct {
+	r      *bufio.Reader
+	c      spoolCipher
+	sealed []byte
+	buf    []byte // Decrypted chunk
+	plain  []byte // Unread part of buf
+	done   bool
+}
+
+// Read implements io.Reader.
+func (s *spoolReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk into s.plain.
+func (s *spoolReader) next() error {
+	var frame [4]byte
+	if _, err := io.ReadFull(s.r, frame[:]); err != nil {
+		return fmt.Errorf("%w: truncated", ErrSpoolCorrupt)
+	}
+	length := binary.BigEndian.Uint32(frame[:])
+	final := length&spoolFinalBit != 0
+	length &^= spoolFinalBit
+	if length > spoolChunkSize+uint32(s.c.aead.Overhead()) {
+		return fmt.Errorf("%w: bad chunk length", ErrSpoolCorrupt)
+	}
+
+	if cap(s.sealed) < int(length) {
+		s.sealed = make([]byte, length)
+	}
+	s.sealed = s.sealed[:length]
+	if _, err := io.ReadFull(s.r, s.sealed); err != nil {
+		return fmt.Errorf("%w: truncated", ErrSpoolCorrupt)
+	}
+	plain, err := s.c.open(s.buf[:0], s.sealed, final)
+	if err != nil {
+		return ErrSpoolCorrupt
+	}
+	s.buf = plain
+	s.plain = plain
+	s.done = final
+	return nil
+}
+
+// spoolReadCloser deletes the spooled file when closed.
+type spoolReadCloser struct {
+	*spoolReader
+	file    *os.File
+	once    sync.Once
+	onClose func()
+
+	// ReadAt state; ReadAt does not move the Read position
+	atMu    sync.Mutex
+	atIndex int64  // Chunk held in atChunk
+	atChunk []byte // nil until a chunk is read
+}
+
+// ReadAt implements io.ReaderAt for formats that need random access, such
+// as Parquet. Every chunk but the last holds spoolChunkSize plaintext
+// bytes, so the chunk holding off is found without reading the ones
+// before it; each chunk read is still authenticated.
+func (s *spoolReadCloser) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("read spooled upload: negative offset")
+	}
+	s.atMu.Lock()
+	defer s.atMu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		index := pos / spoolChunkSize
+		if s.atChunk == nil || s.atIndex != index {
+			if err := s.readChunkAt(index); err != nil {
+				return n, err
+			}
+		}
+		within := int(pos % spoolChunkSize)
+		if within >= len(s.atChunk) {
+			return n, io.EOF // Past the end of the final chunk
+		}
+		n += copy(p[n:], s.atChunk[within:])
+	}
+	return n, nil
+}
+
+// readChunkAt decrypts chunk index into atChunk.
+func (s *spoolReadCloser) readChunkAt(index int64) error {
+	c := s.c
+	frameSize := int64(4 + spoolChunkSize + c.aead.Overhead())
+	start := int64(len(c.header)) + index*frameSize
+
+	var frame [4]byte
+	if _, err := s.file.ReadAt(frame[:], start); err != nil {
+		if err == io.EOF {
+			return io.EOF // Past the final chunk
+		}
+		return fmt.Errorf("read spooled upload: %w", err)
+	}
+	length := binary.BigEndian.Uint32(frame[:])
+	final := length&spoolFinalBit != 0
+	length &^= spoolFinalBit
+	if length > spoolChunkSize+uint32(c.aead.Overhead()) {
+		return fmt.Errorf("%w: bad chunk length", ErrSpoolCorrupt)
+	}
+	sealed := make([]byte, length)
+	if _, err := s.file.ReadAt(sealed, start+4); err != nil {
+		return fmt.Errorf("%w: truncated", ErrSpoolCorrupt)
+	}
+
+	c.counter = uint32(index)
+	plain, err := c.open(s.atChunk[:0], sealed, final)
+	if err != nil {
+		s.atChunk = nil
+		return ErrSpoolCorrupt
+	}
+	s.atChunk, s.atIndex = plain, index
+	return nil
+}
+
+// Close closes and deletes the spooled file. Safe to call more than once.
+func (s *spoolReadCloser) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.file.Close()
+		s.onClose()
+	})
+	return err
+}
+
+// validSpoolID reports whether id looks like an ID from newSpoolID, so
+// admin-supplied IDs cannot escape the spool directory.
+func validSpoolID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// newSpoolID returns a random hex ID for a spooled file.
+func newSpoolID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate spool ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSpoolKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, spoolKeySize))
+}
+
+func TestSpool_RoundTrip(t *testing.T) {
+	sp, err := NewSpool(t.TempDir(), []string{testSpoolKey("k1", 1)})
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+
+	sizes := []int{0, 1, spoolChunkSize - 1, spoolChunkSize, spoolChunkSize + 1, 3*spoolChunkSize + 17}
+	for _, size := range sizes {
+		data := bytes.Repeat([]byte("id,amount\n1,2.50\n"), size/17+1)[:size]
+
+		id, n, err := sp.Write(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("size %d: Write: %v", size, err)
+		}
+		if n != int64(size) {
+			t.Errorf("size %d: Write returned %d bytes", size, n)
+		}
+
+		raw, _ := os.ReadFile(sp.path(id))
+		if size > 16 && bytes.Contains(raw, data[:16]) {
+			t.Errorf("size %d: plaintext found on disk", size)
+		}
+
+		rc, err := sp.Open(id)
+		if err != nil {
+			t.Fatalf("size %d: Open: %v", size, err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: decrypted %d bytes that differ from input", size, len(got))
+		}
+
+		rc.Close()
+		if _, err := os.Stat(sp.path(id)); !os.IsNotExist(err) {
+			t.Errorf("size %d: spooled file not deleted on Close", size)
+		}
+	}
+}
+
+func TestSpool_DetectsTampering(t *testing.T) {
+	sp, _ := NewSpool(t.TempDir(), []string{testSpoolKey("k1", 1)})
+	data := strings.Repeat("a,b,c\n", spoolChunkSize/3)
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+	}{
+		{"flipped byte", func(b []byte) []byte { b[len(b)/2] ^= 1; return b }},
+		{"truncated at chunk boundary", func(b []byte) []byte {
+			// header + first frame (length prefix + sealed chunk)
+			return b[:len(spoolMagic)+1+2+spoolNonceSize+4+spoolChunkSize+16]
+		}},
+		{"truncated mid-chunk", func(b []byte) []byte { return b[:len(b)-5] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, _, err := sp.Write(strings.NewReader(data))
+			if err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			raw, _ := os.ReadFile(sp.path(id))
+			os.WriteFile(sp.path(id), tt.mutate(raw), 0o600)
+
+			rc, err := sp.Open(id)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer rc.Close()
+			if _, err := io.ReadAll(rc); !errors.Is(err, ErrSpoolCorrupt) {
+				t.Errorf("ReadAll error = %v, want ErrSpoolCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestSpool_RotateKeys(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSpool(dir, []string{testSpoolKey("k1", 1)})
+
+	id, _, err := sp.Write(strings.NewReader("id,amount\n1,2\n"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Restart with a new active key; the artifact is orphaned and stale
+	sp, err = NewSpool(dir, []string{testSpoolKey("k2", 2), testSpoolKey("k1", 1)})
+	if err != nil {
+		t.Fatalf("NewSpool: %v", err)
+	}
+	report, err := sp.Report()
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(report.Artifacts) != 1 || report.Artifacts[0].KeyID != "k1" || report.Stale != 1 || report.Orphaned != 1 {
+		t.Fatalf("report before rotation = %+v", report)
+	}
+
+	result, err := sp.Rotate()
+	if err != nil || result.Rotated != 1 || result.Failed != 0 {
+		t.Fatalf("Rotate = %+v, %v", result, err)
+	}
+
+	// Old key can now be dropped
+	if err := sp.SetKeys([]string{testSpoolKey("k2", 2)}); err != nil {
+		t.Fatalf("SetKeys: %v", err)
+	}
+	if report, _ := sp.Report(); report.Stale != 0 || report.Artifacts[0].KeyID != "k2" {
+		t.Errorf("report after rotation = %+v", report)
+	}
+	rc, err := sp.Open(id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rc.Close()
+	if got, err := io.ReadAll(rc); err != nil || string(got) != "id,amount\n1,2\n" {
+		t.Errorf("ReadAll = %q, %v", got, err)
+	}
+}
+
+func TestSpool_ReadAt(t *testing.T) {
+	sp, _ := NewSpool(t.TempDir(), []string{testSpoolKey("k1", 1)})
+	data := bytes.Repeat([]byte("0123456789abcdef"), (3*spoolChunkSize+17)/16+1)[:3*spoolChunkSize+17]
+	id, _, err := sp.Write(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	rc, err := sp.Open(id)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer rc.Close()
+
+	ra, ok := rc.(io.ReaderAt)
+	if !ok {
+		t.Fatal("spooled reader is not an io.ReaderAt")
+	}
+	reads := []struct{ off, n int }{
+		{len(data) - 8, 8},                       // Final chunk
+		{0, 16},                                  // Back to the first
+		{spoolChunkSize - 5, 10},                 // Across a chunk boundary
+		{spoolChunkSize / 2, spoolChunkSize * 2}, // Across three chunks
+	}
+	for _, r := range reads {
+		p := make([]byte, r.n)
+		if n, err := ra.ReadAt(p, int64(r.off)); err != nil || n != r.n {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v", r.n, r.off, n, err)
+		}
+		if !bytes.Equal(p, data[r.off:r.off+r.n]) {
+			t.Errorf("ReadAt(%d, %d) returned the wrong bytes", r.n, r.off)
+		}
+	}
+
+	p := make([]byte, 16)
+	if n, err := ra.ReadAt(p, int64(len(data)-4)); err != io.EOF || n != 4 {
+		t.Errorf("ReadAt past the end = %d, %v; want 4, EOF", n, err)
+	}
+
+	// Random access leaves sequential reads untouched
+	got, err := io.ReadAll(rc)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ReadAll after ReadAt = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestSpool_UnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSpool(dir, []string{testSpoolKey("k1", 1)})
+	id, _, _ := sp.Write(strings.NewReader("x"))
+
+	sp, _ = NewSpool(dir, []string{testSpoolKey("k2", 2)})
+	if _, err := sp.Open(id); !errors.Is(err, ErrSpoolCorrupt) {
+		t.Errorf("Open error = %v, want ErrSpoolCorrupt", err)
+	}
+}
+
+func TestSpool_Delete(t *testing.T) {
+	dir := t.TempDir()
+	sp, _ := NewSpool(dir, []string{testSpoolKey("k1", 1)})
+	id, _, _ := sp.Write(strings.NewReader("x"))
+
+	if err := sp.Delete(id); !errors.Is(err, ErrSpoolInUse) {
+		t.Errorf("Delete in-use error = %v, want ErrSpoolInUse", err)
+	}
+	if err := sp.Delete("../../etc/passwd"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Delete invalid ID error = %v, want ErrNotExist", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, id+spoolExt+".tmp"), []byte("partial"), 0o600)
+	sp, _ = NewSpool(dir, []string{testSpoolKey("k1", 1)})
+	if _, err := os.Stat(filepath.Join(dir, id+spoolExt+".tmp")); !os.IsNotExist(err) {
+		t.Error("partial write not removed on startup")
+	}
+	if err := sp.Delete(id); err != nil {
+		t.Errorf("Delete orphan: %v", err)
+	}
+}
+
+func TestParseSpoolKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr string
+	}{
+		{"valid", []string{testSpoolKey("k2", 2), testSpoolKey("k1", 1)}, ""},
+		{"empty", nil, "no spool keys"},
+		{"missing ID", []string{base64.StdEncoding.EncodeToString(make([]byte, 32))}, "expected keyID:base64key"},
+		{"short key", []string{"k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16))}, "must be 32 bytes"},
+		{"bad base64", []string{"k1:not-base64!"}, "invalid base64"},
+		{"duplicate", []string{testSpoolKey("k1", 1), testSpoolKey("k1", 2)}, "duplicate key ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSpoolKeys(tt.entries)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
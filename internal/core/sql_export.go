@@ -0,0 +1,279 @@
+package core
+
+// sql_export.go writes the sql export format (see exporter.go): a script of
+// INSERT statements that loads the exported rows into the same table in
+// another environment, e.g. with psql -f, in one transaction. Rows are
+// inserted a batch at a time with multi-row VALUES lists. Values are
+// written as stored, numbers to every decimal place, rather than formatted
+// as in a CSV export; empty cells are NULL, as an upload stores them.
+// Literals assume standard_conforming_strings, PostgreSQL's default.
+//
+// Options (ConfigureExporter):
+//
+//	batch        Rows per INSERT, 1 to sqlExportMaxBatch (default 100)
+//	on_conflict  What a row whose unique key is already in the table does:
+//	             empty (default) inserts it anyway, nothing skips it and
+//	             update replaces the existing rows
+//
+// Data tables have no unique constraints (see upload_mode.go), so ON
+// CONFLICT would never fire. The script checks the unique key itself,
+// with NULL key parts matching each other as in an upsert upload: update
+// deletes each batch's existing rows before inserting it, and nothing
+// inserts each row with its own INSERT ... SELECT ... WHERE NOT EXISTS,
+// so it is not batched.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ExportSQL is the format of SQL INSERT scripts.
+const ExportSQL ExportFormat = "sql"
+
+const (
+	sqlExportDefaultBatch = 100
+	sqlExportMaxBatch     = 1000
+)
+
+// Values of the sql format's on_conflict option.
+const (
+	sqlConflictInsert  = ""
+	sqlConflictNothing = "nothing"
+	sqlConflictUpdate  = "update"
+)
+
+// sqlNumber matches numbers that can be written as unquoted literals.
+var sqlNumber = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// sqlExporter writes an SQL script of INSERT statements.
+type sqlExporter struct {
+	w          *bufio.Writer
+	def        TableDefinition
+	table      string // Quoted table name
+	types      map[string]FieldType
+	batch      int
+	onConflict string
+	watermark  string // Export ID, written as a comment (see export_watermark.go)
+
+	insert  string // INSERT INTO ... (columns), once the header is written
+	colType []FieldType
+	keyCols []int    // Indexes of the unique key's columns in each record
+	keyDB   []string // Quoted database names of the unique key's columns
+	pending [][]string
+	rows    int
+}
+
+func newSQLExporter(w io.Writer, def TableDefinition) Exporter {
+	return &sqlExporter{
+		w:     bufio.NewWriter(w),
+		def:   def,
+		table: quoteIdentifier(def.Info.Key),
+		types: exportColumnTypes(def),
+		batch: sqlExportDefaultBatch,
+	}
+}
+
+func (e *sqlExporter) ContentType() string { return "application/sql" }
+func (e *sqlExporter) Extension() string   { return "sql" }
+
+// Configure applies the batch and on_conflict options.
+func (e *sqlExporter) Configure(opts ExportOptions) error {
+	for name, v := range opts {
+		switch name {
+		case "batch":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > sqlExportMaxBatch {
+				return fmt.Errorf("%w: batch must be between 1 and %d", ErrInvalidExportOption, sqlExportMaxBatch)
+			}
+			e.batch = n
+		case "on_conflict":
+			switch v = strings.ToLower(v); v {
+			case sqlConflictInsert, sqlConflictNothing, sqlConflictUpdate:
+				e.onConflict = v
+			default:
+				return fmt.Errorf("%w: on_conflict must be nothing or update, not %q", ErrInvalidExportOption, v)
+			}
+		default:
+			return fmt.Errorf("%w: the sql format has no option %q", ErrInvalidExportOption, name)
+		}
+	}
+	if e.onConflict != sqlConflictInsert && len(e.def.Info.UniqueKey) == 0 {
+		return fmt.Errorf("%w: on_conflict needs a unique key, and %s has none", ErrInvalidExportOption, e.def.Info.Key)
+	}
+	return nil
+}
+
+// FormatCell writes values exactly, rather than as FormatExportCell does.
+func (e *sqlExporter) FormatCell(v any) string {
+	switch val := v.(type) {
+	case pgtype.Numeric:
+		s, err := val.Value()
+		if err != nil || s == nil {
+			return ""
+		}
+		return s.(string)
+	case pgtype.Bool:
+		if !val.Valid {
+			return ""
+		}
+		return strconv.FormatBool(val.Bool)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format("2006-01-02 15:04:05.999999999Z07:00")
+	}
+	return FormatExportCell(v)
+}
+
+// SetWatermark writes the export ID as a comment, so the script still
+// loads into the table.
+func (e *sqlExporter) SetWatermark(id string) {
+	e.watermark = id
+}
+
+func (e *sqlExporter) WriteHeader(columns []string) error {
+	dbCols := make([]string, len(columns))
+	e.colType = make([]FieldType, len(columns))
+	for i, col := range columns {
+		dbCols[i] = quoteIdentifier(resolveDBColumn(col, e.def.FieldSpecs))
+		e.colType[i] = e.types[col]
+	}
+	if e.onConflict != sqlConflictInsert {
+		for _, key := range e.def.Info.UniqueKey {
+			i := -1
+			for j, col := range columns {
+				if strings.EqualFold(col, key) {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return fmt.Errorf("%w: unique key column %s is not exported", ErrInvalidExportOption, key)
+			}
+			e.keyCols = append(e.keyCols, i)
+			e.keyDB = append(e.keyDB, dbCols[i])
+		}
+	}
+	e.insert = "INSERT INTO " + e.table + " (" + strings.Join(dbCols, ", ") + ")"
+
+	fmt.Fprintf(e.w, "-- %s: INSERT statements", e.def.Info.Key)
+	if e.onConflict != sqlConflictInsert {
+		fmt.Fprintf(e.w, ", on_conflict=%s", e.onConflict)
+	}
+	if e.watermark != "" {
+		fmt.Fprintf(e.w, "\n-- %s: %s", WatermarkColumn, e.watermark)
+	}
+	_, err := e.w.WriteString("\nBEGIN;\n")
+	return err
+}
+
+func (e *sqlExporter) WriteRow(record []string) error {
+	values := make([]string, len(e.colType))
+	for i, t := range e.colType {
+		var v string
+		if i < len(record) {
+			v = record[i]
+		}
+		values[i] = sqlLiteral(v, t)
+	}
+	e.rows++
+
+	if e.onConflict == sqlConflictNothing {
+		_, err := fmt.Fprintf(e.w, "\n%s\nSELECT %s\nWHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s);\n",
+			e.insert, strings.Join(values, ", "), e.table, e.keyMatch(values))
+		return err
+	}
+	e.pending = append(e.pending, values)
+	if len(e.pending) >= e.batch {
+		return e.writeBatch()
+	}
+	return nil
+}
+
+// keyMatch returns the condition matching live rows with the unique key of
+// a row's values.
+func (e *sqlExporter) keyMatch(values []string) string {
+	conds := make([]string, len(e.keyCols))
+	for i, col := range e.keyCols {
+		conds[i] = e.keyDB[i] + " IS NOT DISTINCT FROM " + values[col]
+	}
+	if live := liveRowsCondition(e.def, ""); live != "" {
+		conds = append(conds, live)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// writeBatch writes the pending rows as one INSERT, preceded with update
+// by the DELETE of the rows they replace.
+func (e *sqlExporter) writeBatch() error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	e.w.WriteString("\n")
+	if e.onConflict == sqlConflictUpdate {
+		fmt.Fprintf(e.w, "DELETE FROM %s WHERE", e.table)
+		for i, values := range e.pending {
+			if i > 0 {
+				e.w.WriteString("\n  OR")
+			}
+			fmt.Fprintf(e.w, " (%s)", e.keyMatch(values))
+		}
+		e.w.WriteString(";\n")
+	}
+	e.w.WriteString(e.insert + " VALUES")
+	for i, values := range e.pending {
+		if i > 0 {
+			e.w.WriteString(",")
+		}
+		fmt.Fprintf(e.w, "\n  (%s)", strings.Join(values, ", "))
+	}
+	e.pending = e.pending[:0]
+	_, err := e.w.WriteString(";\n")
+	return err
+}
+
+// sqlLiteral returns a cell value as an SQL literal for a column of type t.
+// Untyped literals take the column's type on insert.
+func sqlLiteral(v string, t FieldType) string {
+	if v == "" {
+		return "NULL"
+	}
+	switch t {
+	case FieldNumeric:
+		if sqlNumber.MatchString(v) {
+			return v
+		}
+	case FieldBool:
+		// Matches FormatExportCell's Yes/No
+		switch strings.ToLower(v) {
+		case "yes", "true":
+			return "TRUE"
+		case "no", "false":
+			return "FALSE"
+		}
+	}
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+func (e *sqlExporter) Flush() error { return e.w.Flush() }
+
+func (e *sqlExporter) Close() error {
+	if e.insert == "" {
+		return e.w.Flush()
+	}
+	if err := e.writeBatch(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.w, "\nCOMMIT;\n-- %d rows\n", e.rows)
+	return e.w.Flush()
+}
@@ -0,0 +1,293 @@
+package core
+
+// statistics.go keeps PostgreSQL extended statistics current for tables that
+// declare correlated columns (TableDefinition.Statistics).
+//
+// By default the planner assumes columns are independent, so a filter such
+// as city = 'Austin' AND state = 'TX' is estimated as the product of both
+// selectivities and badly underestimated. Extended statistics record the
+// correlation. They are only as fresh as the last ANALYZE, and autovacuum
+// can lag far behind a bulk import, so each upload that inserts rows
+// schedules a refresh. Refreshes run in the background and are coalesced
+// per table: one runs at a time and at most one more is queued.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statisticsKinds maps CREATE STATISTICS kinds to their pg_statistic_ext.stxkind codes.
+var statisticsKinds = map[string]string{
+	"ndistinct":    "d",
+	"dependencies": "f",
+	"mcv":          "m",
+}
+
+// maxIdentifierLen is PostgreSQL's NAMEDATALEN - 1.
+const maxIdentifierLen = 63
+
+// StatisticsObject describes one extended statistics object on a table.
+type StatisticsObject struct {
+	Name     string   `json:"name"`
+	Columns  []string `json:"columns"`
+	Kinds    []string `json:"kinds"`
+	Declared bool     `json:"declared"` // Listed in the table definition
+	Exists   bool     `json:"exists"`   // Present in the database
+
+	// Planner inputs from pg_stats_ext; empty until the table is analyzed.
+	NDistinct    string `json:"nDistinct,omitempty"`
+	Dependencies string `json:"dependencies,omitempty"`
+}
+
+// TableStatistics reports extended statistics and analyze freshness for a table.
+type TableStatistics struct {
+	TableKey             string             `json:"tableKey"`
+	LastAnalyze          *time.Time         `json:"lastAnalyze,omitempty"` // Manual or autovacuum, whichever is later
+	ModifiedSinceAnalyze int64              `json:"modifiedSinceAnalyze"`
+	Objects              []StatisticsObject `json:"objects"`
+}
+
+// validateStatistics checks declared statistics against the table's columns.
+// Called from Register, so mistakes surface at startup.
+func validateStatistics(def TableDefinition) error {
+	columns := make(map[string]bool, len(def.FieldSpecs))
+	for _, spec := range def.FieldSpecs {
+		col := spec.DBColumn
+		if col == "" {
+			col = toDBColumnName(spec.Name)
+		}
+		columns[col] = true
+	}
+
+	for i, st := range def.Statistics {
+		if len(st.Columns) < 2 {
+			return fmt.Errorf("statistics %d: need at least two columns", i+1)
+		}
+		for _, col := range st.Columns {
+			if !columns[col] {
+				return fmt.Errorf("statistics %d: unknown column %q", i+1, col)
+			}
+		}
+		for _, kind := range st.Kinds {
+			if _, ok := statisticsKinds[kind]; !ok {
+				return fmt.Errorf("statistics %d: unknown kind %q (want ndistinct, dependencies, or mcv)", i+1, kind)
+			}
+		}
+	}
+	return nil
+}
+
+// statisticsName returns a stable object name derived from the table and
+// columns, hashed when it would exceed PostgreSQL's identifier limit.
+func statisticsName(table string, st ExtendedStatistics) string {
+	name := table + "_" + strings.Join(st.Columns, "_") + "_stats"
+	if len(name) <= maxIdentifierLen {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	prefix := table
+	if len(prefix) > maxIdentifierLen-len("__stats")-12 {
+		prefix = prefix[:maxIdentifierLen-len("__stats")-12]
+	}
+	return prefix + "_" + hex.EncodeToString(sum[:6]) + "_stats"
+}
+
+// createStatisticsSQL builds an idempotent CREATE STATISTICS statement.
+func createStatisticsSQL(table string, st ExtendedStatistics) string {
+	cols := make([]string, len(st.Columns))
+	for i, col := range st.Columns {
+		cols[i] = quoteIdentifier(col)
+	}
+	kinds := ""
+	if len(st.Kinds) > 0 {
+		kinds = " (" + strings.Join(st.Kinds, ", ") + ")"
+	}
+	return fmt.Sprintf("CREATE STATISTICS IF NOT EXISTS %s%s ON %s FROM %s",
+		quoteIdentifier(statisticsName(table, st)), kinds, strings.Join(cols, ", "), quoteIdentifier(table))
+}
+
+// statsRefresher coalesces background statistics refreshes per table.
+type statsRefresher struct {
+	mu      sync.Mutex
+	running map[string]bool
+	pending map[string]bool
+}
+
+// scheduleStatisticsRefresh refreshes the table's extended statistics in the
+// background after an upload inserted rows. No-op if none are declared.
+func (s *Service) scheduleStatisticsRefresh(def TableDefinition, inserted int) {
+	if len(def.Statistics) == 0 || inserted == 0 {
+		return
+	}
+	key := def.Info.Key
+
+	r := &s.stats
+	r.mu.Lock()
+	if r.running == nil {
+		r.running = make(map[string]bool)
+		r.pending = make(map[string]bool)
+	}
+	if r.running[key] {
+		r.pending[key] = true
+		r.mu.Unlock()
+		return
+	}
+	r.running[key] = true
+	r.mu.Unlock()
+
+	go func() {
+		for {
+			ctx, cancel := s.withOpTimeout(context.Background(), opAggregate)
+			start := time.Now()
+			if err := s.refreshStatistics(ctx, def); err != nil {
+				slog.Error("failed to refresh table statistics", "table", key, "error", err)
+			} else {
+				slog.Debug("refreshed table statistics", "table", key, "duration", time.Since(start))
+			}
+			cancel()
+
+			r.mu.Lock()
+			if !r.pending[key] {
+				delete(r.running, key)
+				r.mu.Unlock()
+				return
+			}
+			delete(r.pending, key)
+			r.mu.Unlock()
+		}
+	}()
+}
+
+// refreshStatistics creates missing statistics objects and re-analyzes the table.
+func (s *Service) refreshStatistics(ctx context.Context, def TableDefinition) error {
+	table := def.Info.Key
+	for _, st := range def.Statistics {
+		if _, err := s.pool.Exec(ctx, createStatisticsSQL(table, st)); err != nil {
+			return fmt.Errorf("create statistics %s: %w", statisticsName(table, st), err)
+		}
+	}
+	if _, err := s.pool.Exec(ctx, "ANALYZE "+quoteIdentifier(table)); err != nil {
+		return fmt.Errorf("analyze %s: %w", table, err)
+	}
+	return nil
+}
+
+// RefreshStatistics synchronously creates the table's declared extended
+// statistics and re-analyzes it, then returns the resulting report.
+func (s *Service) RefreshStatistics(ctx context.Context, tableKey string) (*TableStatistics, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+
+	refreshCtx, cancel := s.withOpTimeout(ctx, opAggregate)
+	err := s.refreshStatistics(refreshCtx, def)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	return s.TableStatistics(ctx, tableKey)
+}
+
+// TableStatistics reports the table's extended statistics, declared or not,
+// with the planner's current estimates and how stale the last ANALYZE is.
+func (s *Service) TableStatistics(ctx context.Context, tableKey string) (*TableStatistics, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	report := &TableStatistics{TableKey: tableKey, Objects: []StatisticsObject{}}
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT GREATEST(last_analyze, last_autoanalyze), n_mod_since_analyze
+		FROM pg_stat_user_tables
+		WHERE relid = to_regclass($1)`, tableKey).Scan(&report.LastAnalyze, &report.ModifiedSinceAnalyze)
+	if err != nil {
+		return nil, fmt.Errorf("read analyze status for %s: %w", tableKey, err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT s.stxname::text,
+		       ARRAY(SELECT a.attname::text
+		             FROM unnest(s.stxkeys::int2[]) WITH ORDINALITY AS k(attnum, ord)
+		             JOIN pg_attribute a ON a.attrelid = s.stxrelid AND a.attnum = k.attnum
+		             ORDER BY k.ord),
+		       s.stxkind::text[],
+		       COALESCE(d.n_distinct::text, ''),
+		       COALESCE(d.dependencies::text, '')
+		FROM pg_statistic_ext s
+		LEFT JOIN pg_stats_ext d
+		       ON d.statistics_schemaname = s.stxnamespace::regnamespace::text
+		      AND d.statistics_name = s.stxname
+		WHERE s.stxrelid = to_regclass($1)
+		ORDER BY s.stxname`, tableKey)
+	if err != nil {
+		return nil, fmt.Errorf("list statistics for %s: %w", tableKey, err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]int)
+	for rows.Next() {
+		var obj StatisticsObject
+		var codes []string
+		if err := rows.Scan(&obj.Name, &obj.Columns, &codes, &obj.NDistinct, &obj.Dependencies); err != nil {
+			return nil, fmt.Errorf("scan statistics for %s: %w", tableKey, err)
+		}
+		obj.Exists = true
+		obj.Kinds = statisticsKindNames(codes)
+		existing[obj.Name] = len(report.Objects)
+		report.Objects = append(report.Objects, obj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list statistics for %s: %w", tableKey, err)
+	}
+
+	for _, st := range def.Statistics {
+		name := statisticsName(tableKey, st)
+		if i, ok := existing[name]; ok {
+			report.Objects[i].Declared = true
+			continue
+		}
+		kinds := st.Kinds
+		if len(kinds) == 0 {
+			kinds = []string{"ndistinct", "dependencies", "mcv"}
+		}
+		report.Objects = append(report.Objects, StatisticsObject{
+			Name:     name,
+			Columns:  st.Columns,
+			Kinds:    kinds,
+			Declared: true,
+		})
+	}
+	return report, nil
+}
+
+// statisticsKindNames converts stxkind codes to CREATE STATISTICS kind names.
+// Codes without a name (e.g. expression statistics) are kept as-is.
+func statisticsKindNames(codes []string) []string {
+	names := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name := code
+		for kind, c := range statisticsKinds {
+			if c == code {
+				name = kind
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
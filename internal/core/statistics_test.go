@@ -0,0 +1,98 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateStatistics(t *testing.T) {
+	def := TableDefinition{
+		FieldSpecs: []FieldSpec{
+			{Name: "Ship City", DBColumn: "city"},
+			{Name: "Ship State", DBColumn: "state"},
+			{Name: "country"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		stats   []ExtendedStatistics
+		wantErr string
+	}{
+		{"valid", []ExtendedStatistics{{Columns: []string{"country", "state", "city"}}}, ""},
+		{"valid kinds", []ExtendedStatistics{{Columns: []string{"state", "city"}, Kinds: []string{"dependencies", "mcv"}}}, ""},
+		{"one column", []ExtendedStatistics{{Columns: []string{"city"}}}, "at least two columns"},
+		{"display name", []ExtendedStatistics{{Columns: []string{"Ship City", "state"}}}, `unknown column "Ship City"`},
+		{"bad kind", []ExtendedStatistics{{Columns: []string{"state", "city"}, Kinds: []string{"histogram"}}}, `unknown kind "histogram"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def.Statistics = tt.stats
+			err := validateStatistics(def)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateStatisticsSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		st   ExtendedStatistics
+		want string
+	}{
+		{
+			name: "all kinds",
+			st:   ExtendedStatistics{Columns: []string{"type", "account_name"}},
+			want: `CREATE STATISTICS IF NOT EXISTS "sfdc_customers_type_account_name_stats" ` +
+				`ON "type", "account_name" FROM "sfdc_customers"`,
+		},
+		{
+			name: "selected kinds",
+			st:   ExtendedStatistics{Columns: []string{"type", "account_name"}, Kinds: []string{"dependencies", "mcv"}},
+			want: `CREATE STATISTICS IF NOT EXISTS "sfdc_customers_type_account_name_stats" ` +
+				`(dependencies, mcv) ON "type", "account_name" FROM "sfdc_customers"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createStatisticsSQL("sfdc_customers", tt.st); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatisticsName(t *testing.T) {
+	short := statisticsName("sfdc_customers", ExtendedStatistics{Columns: []string{"type", "account_name"}})
+	if short != "sfdc_customers_type_account_name_stats" {
+		t.Errorf("short name = %q", short)
+	}
+
+	st := ExtendedStatistics{Columns: []string{"shipping_address_country", "shipping_address_state", "shipping_address_city"}}
+	long := statisticsName("ns_invoice_detail", st)
+	if len(long) > maxIdentifierLen {
+		t.Errorf("name %q exceeds %d bytes", long, maxIdentifierLen)
+	}
+	if long != statisticsName("ns_invoice_detail", st) {
+		t.Error("name is not stable")
+	}
+	other := statisticsName("ns_invoice_detail", ExtendedStatistics{Columns: []string{"shipping_address_country", "shipping_address_city"}})
+	if long == other {
+		t.Error("different column sets produced the same name")
+	}
+}
+
+func TestStatisticsKindNames(t *testing.T) {
+	got := strings.Join(statisticsKindNames([]string{"d", "f", "m", "e"}), ",")
+	if got != "ndistinct,dependencies,mcv,e" {
+		t.Errorf("got %q", got)
+	}
+}
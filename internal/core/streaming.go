@@ -8,10 +8,13 @@ package core
 //   - StreamingUTF8Sanitizer: Replaces invalid UTF-8 sequences with '?'
 //   - BOMSkippingReader: Removes UTF-8 BOM (0xEF 0xBB 0xBF) from Windows files
 //   - StreamingCountingReader: Tracks bytes read for progress reporting
+//   - gzip input is decompressed on the fly (see compressed.go)
+//   - UTF-16 and Windows-1252 input is transcoded to UTF-8 (see sniff.go)
 //
 // Use WrapForStreaming to apply all transforms in the correct order.
 
 import (
+	"bufio"
 	"io"
 	"unicode/utf8"
 )
@@ -261,6 +264,26 @@ type StreamingCountingReader struct {
 	reader    io.Reader
 	BytesRead int64
 	Total     int64  // If known (0 if unknown)
+
+	// countedAtSource is set by WrapForStreaming, which counts the input
+	// before decompression so BytesRead stays comparable with Total.
+	countedAtSource bool
+
+	// peek buffers the transformed input so Delimiter can sniff it; set by
+	// WrapForStreaming.
+	peek *bufio.Reader
+}
+
+// sourceCounter adds the bytes read from reader to n.
+type sourceCounter struct {
+	reader io.Reader
+	n      *int64
+}
+
+func (c *sourceCounter) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	*c.n += int64(n)
+	return n, err
 }
 
 // NewStreamingCountingReader creates a counting reader with optional total size.
@@ -274,10 +297,33 @@ func NewStreamingCountingReader(r io.Reader, total int64) *StreamingCountingRead
 // Read implements io.Reader.
 func (r *StreamingCountingReader) Read(p []byte) (int, error) {
 	n, err := r.reader.Read(p)
-	r.BytesRead += int64(n)
+	if !r.countedAtSource {
+		r.BytesRead += int64(n)
+	}
 	return n, err
 }
 
+// Delimiter sniffs the CSV delimiter from the start of the input (see
+// sniffDelimiter). Call it before the first Read; without WrapForStreaming
+// it returns ','.
+func (r *StreamingCountingReader) Delimiter() rune {
+	if r.peek == nil {
+		return ','
+	}
+	head, _ := r.peek.Peek(sniffSize)
+	return sniffDelimiter(head)
+}
+
+// IsJSON reports whether the input is a JSON upload (see isJSONUpload).
+// Call it before the first Read; without WrapForStreaming it sniffs nothing.
+func (r *StreamingCountingReader) IsJSON(fileName string) bool {
+	if r.peek == nil {
+		return isJSONUpload(fileName, nil)
+	}
+	head, _ := r.peek.Peek(sniffSize)
+	return isJSONUpload(fileName, head)
+}
+
 // Progress returns the read progress as a percentage (0-100).
 // Returns 0 if total is unknown.
 func (r *StreamingCountingReader) Progress() int {
@@ -287,15 +333,28 @@ func (r *StreamingCountingReader) Progress() int {
 	return int(r.BytesRead * 100 / r.Total)
 }
 
-// WrapForStreaming wraps a reader with BOM skipping, UTF-8 sanitization,
-// and byte counting for progress tracking.
+// WrapForStreaming wraps a reader with gzip decompression, transcoding to
+// UTF-8, BOM skipping, UTF-8 sanitization, and byte counting for progress
+// tracking. The result's Delimiter sniffs the CSV delimiter.
 //
 // The order matters:
-// 1. BOM must be stripped first (before any processing)
-// 2. UTF-8 sanitization happens next
-// 3. Counting wraps everything for progress
+// 1. Bytes are counted as read from r, before decompression, like totalSize
+// 2. Gzip input is detected by its magic bytes and decompressed
+// 3. The encoding is sniffed and the text transcoded to UTF-8
+// 4. BOM must be stripped next (before any processing)
+// 5. UTF-8 sanitization happens last
 func WrapForStreaming(r io.Reader, totalSize int64) *StreamingCountingReader {
-	bomReader := NewBOMSkippingReader(r)
-	sanitizedReader := NewStreamingUTF8Sanitizer(bomReader)
-	return NewStreamingCountingReader(sanitizedReader, totalSize)
+	return wrapForStreaming(r, totalSize, 0)
+}
+
+// wrapForStreaming is WrapForStreaming with a limit on the decompressed
+// size of gzip input; 0 means no limit.
+func wrapForStreaming(r io.Reader, totalSize, maxDecompressed int64) *StreamingCountingReader {
+	counter := &StreamingCountingReader{Total: totalSize, countedAtSource: true}
+	source := &sourceCounter{reader: r, n: &counter.BytesRead}
+	decoded := newTranscodingReader(newDecompressingReader(source, maxDecompressed))
+	bomReader := NewBOMSkippingReader(decoded)
+	counter.peek = bufio.NewReaderSize(NewStreamingUTF8Sanitizer(bomReader), sniffSize)
+	counter.reader = counter.peek
+	return counter
 }
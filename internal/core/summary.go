@@ -0,0 +1,323 @@
+package core
+
+// summary.go answers grouped aggregation queries, such as the sum of
+// Amount by Customer by month, so summary views don't need an export to a
+// spreadsheet pivot table.
+//
+// Groups are filtered exactly like the table view, through WhereBuilder,
+// and soft-deleted rows are left out. Date columns can be grouped by day,
+// week, month, quarter or year. Group keys come back as text in a format
+// that sorts and reads the same in every client: dates as 2024-01-31,
+// months as 2024-01, quarters as 2024-Q1, years as 2024. Groups are
+// ordered by their keys, with empty keys last, and capped at
+// QUERY_MAX_SUMMARY_GROUPS.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSummary is returned for a summary request that names unknown
+// columns, functions or date buckets.
+var ErrInvalidSummary = errors.New("invalid summary")
+
+// MaxSummaryGroupColumns caps the columns a summary groups by.
+const MaxSummaryGroupColumns = 4
+
+// MaxSummaryAggregates caps the aggregates a summary computes per group.
+const MaxSummaryAggregates = 16
+
+// AggFunc is an aggregate function computed for each group of a summary.
+type AggFunc string
+
+const (
+	AggSum   AggFunc = "sum"   // Numeric columns
+	AggAvg   AggFunc = "avg"   // Numeric columns
+	AggMin   AggFunc = "min"   // Numeric columns
+	AggMax   AggFunc = "max"   // Numeric columns
+	AggCount AggFunc = "count" // Non-empty values of any column
+)
+
+// DateBucket truncates a date column so a summary groups by period.
+type DateBucket string
+
+const (
+	BucketNone    DateBucket = ""
+	BucketDay     DateBucket = "day"
+	BucketWeek    DateBucket = "week" // Keyed by the week's Monday
+	BucketMonth   DateBucket = "month"
+	BucketQuarter DateBucket = "quarter"
+	BucketYear    DateBucket = "year"
+)
+
+// dateBucketFormats are the to_char formats of each bucket's group keys.
+var dateBucketFormats = map[DateBucket]string{
+	BucketNone:    "YYYY-MM-DD",
+	BucketDay:     "YYYY-MM-DD",
+	BucketWeek:    "YYYY-MM-DD",
+	BucketMonth:   "YYYY-MM",
+	BucketQuarter: `YYYY-"Q"Q`,
+	BucketYear:    "YYYY",
+}
+
+// GroupSpec is a column a summary groups by.
+type GroupSpec struct {
+	Column string     `json:"column"`           // Display column name
+	Bucket DateBucket `json:"bucket,omitempty"` // Date columns only
+}
+
+// AggSpec is an aggregate a summary computes for each group.
+type AggSpec struct {
+	Func   AggFunc `json:"func"`
+	Column string  `json:"column,omitempty"` // Display column name; empty counts rows
+}
+
+// String returns the spec as ParseAggSpec reads it, e.g. "sum:Amount".
+func (a AggSpec) String() string {
+	if a.Column == "" {
+		return string(a.Func)
+	}
+	return string(a.Func) + ":" + a.Column
+}
+
+// ParseGroupSpec parses "Column" or "Column:bucket", e.g. "Invoice Date:month".
+func ParseGroupSpec(s string) (GroupSpec, error) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		bucket := DateBucket(strings.ToLower(strings.TrimSpace(s[i+1:])))
+		if _, ok := dateBucketFormats[bucket]; !ok || bucket == BucketNone {
+			return GroupSpec{}, fmt.Errorf("%w: unknown date bucket %q (want day, week, month, quarter or year)", ErrInvalidSummary, s[i+1:])
+		}
+		return GroupSpec{Column: strings.TrimSpace(s[:i]), Bucket: bucket}, nil
+	}
+	if s == "" {
+		return GroupSpec{}, fmt.Errorf("%w: empty group column", ErrInvalidSummary)
+	}
+	return GroupSpec{Column: s}, nil
+}
+
+// ParseAggSpec parses "func:Column", e.g. "sum:Amount", or "count" alone
+// to count rows.
+func ParseAggSpec(s string) (AggSpec, error) {
+	name, col, _ := strings.Cut(strings.TrimSpace(s), ":")
+	fn := AggFunc(strings.ToLower(strings.TrimSpace(name)))
+	switch fn {
+	case AggSum, AggAvg, AggMin, AggMax, AggCount:
+	default:
+		return AggSpec{}, fmt.Errorf("%w: unknown aggregate %q (want sum, avg, min, max or count)", ErrInvalidSummary, name)
+	}
+	col = strings.TrimSpace(col)
+	if col == "" && fn != AggCount {
+		return AggSpec{}, fmt.Errorf("%w: %s needs a column, as in %s:Amount", ErrInvalidSummary, fn, fn)
+	}
+	return AggSpec{Func: fn, Column: col}, nil
+}
+
+// SummaryGroup is one group of a summary.
+type SummaryGroup struct {
+	Keys   []*string  `json:"keys"`   // One per GroupSpec; nil for empty values
+	Count  int64      `json:"count"`  // Rows in the group
+	Values []*float64 `json:"values"` // One per AggSpec; nil if the group has no values
+}
+
+// SummaryResult is a grouped summary of a table.
+type SummaryResult struct {
+	TableKey  string         `json:"tableKey"`
+	GroupBy   []GroupSpec    `json:"groupBy"`
+	Aggs      []AggSpec      `json:"aggs"`
+	Groups    []SummaryGroup `json:"groups"`
+	Truncated bool           `json:"truncated"` // More groups than QUERY_MAX_SUMMARY_GROUPS
+
+	// Suppressed counts the groups too small to show aggregates-only
+	// callers (see aggregate_access.go)
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// MaxSummaryGroups returns the most groups a summary returns.
+func (s *Service) MaxSummaryGroups() int {
+	if s.cfg.Query.MaxSummaryGroups > 0 {
+		return s.cfg.Query.MaxSummaryGroups
+	}
+	return DefaultMaxSummaryGroups
+}
+
+// GetGroupedData aggregates the rows of a table matching filters, grouped
+// by groupBy. Every group has a row count; aggs adds an aggregate each.
+// With no groupBy it returns a single group over all matching rows. For
+// aggregates-only callers, min and max are refused and small groups left
+// out.
+func (s *Service) GetGroupedData(ctx context.Context, tableKey string, groupBy []GroupSpec, aggs []AggSpec, filters FilterSet) (*SummaryResult, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opAggregate)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+
+	aggregatesOnly := AggregatesOnly(ctx)
+	if aggregatesOnly {
+		if err := checkAggregateSpecs(aggs); err != nil {
+			return nil, err
+		}
+	}
+	query, err := summaryQuery(def, groupBy, aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	wb := NewWhereBuilder()
+	wb.AddLiveRows(def)
+	wb.AddFilters(filters)
+	whereClause, queryArgs := wb.Build()
+
+	maxGroups := s.MaxSummaryGroups()
+	sql := "SELECT " + query.selects + " FROM " + quoteIdentifier(tableKey) + whereClause +
+		query.grouping + fmt.Sprintf(" LIMIT $%d", wb.NextArgIndex())
+	queryArgs = append(queryArgs, maxGroups+1)
+
+	rows, err := s.pool.Query(ctx, sql, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query summary: %w", err)
+	}
+	defer rows.Close()
+
+	result := &SummaryResult{
+		TableKey: tableKey,
+		GroupBy:  query.groupBy,
+		Aggs:     query.aggs,
+		Groups:   []SummaryGroup{},
+	}
+	for rows.Next() {
+		if len(result.Groups) == maxGroups {
+			result.Truncated = true
+			break
+		}
+		g := SummaryGroup{
+			Keys:   make([]*string, len(query.groupBy)),
+			Values: make([]*float64, len(query.aggs)),
+		}
+		dest := make([]interface{}, 0, len(g.Keys)+1+len(g.Values))
+		for i := range g.Keys {
+			dest = append(dest, &g.Keys[i])
+		}
+		dest = append(dest, &g.Count)
+		for i := range g.Values {
+			dest = append(dest, &g.Values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan summary: %w", err)
+		}
+		result.Groups = append(result.Groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	if aggregatesOnly {
+		suppressSmallGroups(result, s.minGroupSize(def))
+	}
+	return result, nil
+}
+
+// builtSummary is the SELECT list and GROUP BY / ORDER BY clauses of a
+// summary query, and the specs they were built from, normalized.
+type builtSummary struct {
+	selects  string
+	grouping string // Empty without group columns
+	groupBy  []GroupSpec
+	aggs     []AggSpec
+}
+
+// summaryQuery validates groupBy and aggs against def and builds the query.
+func summaryQuery(def TableDefinition, groupBy []GroupSpec, aggs []AggSpec) (builtSummary, error) {
+	if len(groupBy) > MaxSummaryGroupColumns {
+		return builtSummary{}, fmt.Errorf("%w: at most %d group columns", ErrInvalidSummary, MaxSummaryGroupColumns)
+	}
+	if len(aggs) > MaxSummaryAggregates {
+		return builtSummary{}, fmt.Errorf("%w: at most %d aggregates", ErrInvalidSummary, MaxSummaryAggregates)
+	}
+
+	b := builtSummary{groupBy: make([]GroupSpec, 0, len(groupBy)), aggs: make([]AggSpec, 0, len(aggs))}
+	var keys, groups, selects []string
+	for _, g := range groupBy {
+		name, spec, err := summaryColumn(def, g.Column)
+		if err != nil {
+			return builtSummary{}, err
+		}
+		if _, ok := dateBucketFormats[g.Bucket]; !ok {
+			return builtSummary{}, fmt.Errorf("%w: unknown date bucket %q", ErrInvalidSummary, g.Bucket)
+		}
+		col := columnExpr(def, name)
+		group, key := col, col+"::text"
+		switch {
+		case spec.Type == FieldDate:
+			if g.Bucket != BucketNone && g.Bucket != BucketDay {
+				group = fmt.Sprintf("date_trunc('%s', %s)", g.Bucket, col)
+			}
+			key = fmt.Sprintf("to_char(%s, '%s')", group, dateBucketFormats[g.Bucket])
+		case g.Bucket != BucketNone:
+			return builtSummary{}, fmt.Errorf("%w: %s is not a date column and cannot be grouped by %s", ErrInvalidSummary, name, g.Bucket)
+		case spec.Type == FieldText:
+			// An edited text cell may hold "" rather than NULL
+			group = fmt.Sprintf("NULLIF(%s, '')", col)
+			key = group
+		}
+		groups = append(groups, group)
+		keys = append(keys, key)
+		b.groupBy = append(b.groupBy, GroupSpec{Column: name, Bucket: g.Bucket})
+	}
+
+	selects = append(selects, keys...)
+	selects = append(selects, "COUNT(*)")
+	for _, a := range aggs {
+		switch a.Func {
+		case AggSum, AggAvg, AggMin, AggMax, AggCount:
+		default:
+			return builtSummary{}, fmt.Errorf("%w: unknown aggregate %q", ErrInvalidSummary, a.Func)
+		}
+		if a.Func == AggCount && a.Column == "" {
+			selects = append(selects, "COUNT(*)::float8")
+			b.aggs = append(b.aggs, a)
+			continue
+		}
+		name, spec, err := summaryColumn(def, a.Column)
+		if err != nil {
+			return builtSummary{}, err
+		}
+		col := columnExpr(def, name)
+		switch {
+		case a.Func == AggCount:
+			if spec.Type == FieldText {
+				col = fmt.Sprintf("NULLIF(%s, '')", col)
+			}
+			selects = append(selects, fmt.Sprintf("COUNT(%s)::float8", col))
+		case spec.Type != FieldNumeric:
+			return builtSummary{}, fmt.Errorf("%w: %s needs a numeric column; %s is not", ErrInvalidSummary, a.Func, name)
+		default:
+			selects = append(selects, fmt.Sprintf("%s(%s)::float8", strings.ToUpper(string(a.Func)), col))
+		}
+		b.aggs = append(b.aggs, AggSpec{Func: a.Func, Column: name})
+	}
+
+	b.selects = strings.Join(selects, ", ")
+	if len(groups) > 0 {
+		order := make([]string, len(groups))
+		for i, g := range groups {
+			order[i] = g + " NULLS LAST"
+		}
+		b.grouping = " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(order, ", ")
+	}
+	return b, nil
+}
+
+// summaryColumn resolves col, which may be an old name, to a current
+// column of def, stored or SQL derived, and its FieldSpec.
+func summaryColumn(def TableDefinition, col string) (string, *FieldSpec, error) {
+	name := ResolveColumnName(def, col, "summary")
+	if spec, _, ok := FilterField(def, name); ok {
+		return spec.Name, &spec, nil
+	}
+	return "", nil, fmt.Errorf("%w: column %q not found in %s", ErrInvalidSummary, col, def.Info.Key)
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseGroupSpec(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    GroupSpec
+		wantErr bool
+	}{
+		{in: "Customer", want: GroupSpec{Column: "Customer"}},
+		{in: " Invoice Date:Month ", want: GroupSpec{Column: "Invoice Date", Bucket: BucketMonth}},
+		{in: "Date:quarter", want: GroupSpec{Column: "Date", Bucket: BucketQuarter}},
+		{in: "Date:fortnight", wantErr: true},
+		{in: "Date:", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGroupSpec(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidSummary) {
+				t.Errorf("ParseGroupSpec(%q) error = %v, want ErrInvalidSummary", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseGroupSpec(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAggSpec(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AggSpec
+		wantErr bool
+	}{
+		{in: "sum:Amount", want: AggSpec{Func: AggSum, Column: "Amount"}},
+		{in: "AVG: Amount ", want: AggSpec{Func: AggAvg, Column: "Amount"}},
+		{in: "count", want: AggSpec{Func: AggCount}},
+		{in: "count:Customer", want: AggSpec{Func: AggCount, Column: "Customer"}},
+		{in: "sum", wantErr: true},
+		{in: "median:Amount", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAggSpec(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidSummary) {
+				t.Errorf("ParseAggSpec(%q) error = %v, want ErrInvalidSummary", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAggSpec(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+		if s := got.String(); tt.in == "sum:Amount" && s != tt.in {
+			t.Errorf("String() = %q, want %q", s, tt.in)
+		}
+	}
+}
+
+func TestSummaryQuery(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "invoices", Columns: []string{"Customer", "Invoice Date", "Amount", "Paid"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Customer", Type: FieldText},
+			{Name: "Invoice Date", DBColumn: "inv_date", Type: FieldDate},
+			{Name: "Amount", Type: FieldNumeric},
+			{Name: "Paid", Type: FieldBool},
+		},
+		Renames: []ColumnRename{{From: "Total", To: "Amount"}},
+	}
+
+	tests := []struct {
+		name         string
+		groupBy      []GroupSpec
+		aggs         []AggSpec
+		wantSelects  string
+		wantGrouping string
+		wantAggs     []AggSpec
+		wantErr      bool
+	}{
+		{
+			name:         "sum by customer by month",
+			groupBy:      []GroupSpec{{Column: "customer"}, {Column: "Invoice Date", Bucket: BucketMonth}},
+			aggs:         []AggSpec{{Func: AggSum, Column: "Amount"}, {Func: AggCount}},
+			wantSelects:  `NULLIF("customer", ''), to_char(date_trunc('month', "inv_date"), 'YYYY-MM'), COUNT(*), SUM("amount")::float8, COUNT(*)::float8`,
+			wantGrouping: ` GROUP BY NULLIF("customer", ''), date_trunc('month', "inv_date") ORDER BY NULLIF("customer", '') NULLS LAST, date_trunc('month', "inv_date") NULLS LAST`,
+			wantAggs:     []AggSpec{{Func: AggSum, Column: "Amount"}, {Func: AggCount}},
+		},
+		{
+			name:         "plain date and bool keys",
+			groupBy:      []GroupSpec{{Column: "Invoice Date"}, {Column: "Paid"}},
+			wantSelects:  `to_char("inv_date", 'YYYY-MM-DD'), "paid"::text, COUNT(*)`,
+			wantGrouping: ` GROUP BY "inv_date", "paid" ORDER BY "inv_date" NULLS LAST, "paid" NULLS LAST`,
+			wantAggs:     []AggSpec{},
+		},
+		{
+			name:        "no groups, renamed column",
+			aggs:        []AggSpec{{Func: AggMax, Column: "Total"}, {Func: AggCount, Column: "Customer"}},
+			wantSelects: `COUNT(*), MAX("amount")::float8, COUNT(NULLIF("customer", ''))::float8`,
+			wantAggs:    []AggSpec{{Func: AggMax, Column: "Amount"}, {Func: AggCount, Column: "Customer"}},
+		},
+		{
+			name:    "sum of a text column",
+			aggs:    []AggSpec{{Func: AggSum, Column: "Customer"}},
+			wantErr: true,
+		},
+		{
+			name:    "bucket on a non-date column",
+			groupBy: []GroupSpec{{Column: "Amount", Bucket: BucketYear}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown bucket",
+			groupBy: []GroupSpec{{Column: "Invoice Date", Bucket: "decade'); --"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown function",
+			aggs:    []AggSpec{{Func: "stddev", Column: "Amount"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown column",
+			groupBy: []GroupSpec{{Column: "Region"}},
+			wantErr: true,
+		},
+		{
+			name:    "too many group columns",
+			groupBy: []GroupSpec{{Column: "Customer"}, {Column: "Paid"}, {Column: "Amount"}, {Column: "Invoice Date"}, {Column: "Customer"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := summaryQuery(def, tt.groupBy, tt.aggs)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSummary) {
+					t.Fatalf("summaryQuery() error = %v, want ErrInvalidSummary", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("summaryQuery() error = %v", err)
+			}
+			if got.selects != tt.wantSelects {
+				t.Errorf("selects =\n  %s\nwant\n  %s", got.selects, tt.wantSelects)
+			}
+			if got.grouping != tt.wantGrouping {
+				t.Errorf("grouping =\n  %s\nwant\n  %s", got.grouping, tt.wantGrouping)
+			}
+			if !reflect.DeepEqual(got.aggs, tt.wantAggs) {
+				t.Errorf("aggs = %+v, want %+v", got.aggs, tt.wantAggs)
+			}
+			if len(got.groupBy) != len(tt.groupBy) {
+				t.Errorf("groupBy = %+v, want %d columns", got.groupBy, len(tt.groupBy))
+			}
+		})
+	}
+}
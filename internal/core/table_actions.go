@@ -0,0 +1,239 @@
+package core
+
+// table_actions.go lets a table offer custom actions, such as "Recalculate
+// commissions" or "Push to billing", declared in its TableDefinition next
+// to its other hooks:
+//
+//	core.Register(core.TableDefinition{
+//		Info: core.TableInfo{Key: "sales", ...},
+//		Actions: []core.TableAction{{
+//			Name:  "recalculate-commissions",
+//			Label: "Recalculate commissions",
+//			Run: func(ctx context.Context, run *core.ActionRun) (core.ActionResult, error) {
+//				tag, err := run.DB.Exec(ctx, `UPDATE sales SET commission = amount * rate`)
+//				return core.ActionResult{RowsAffected: int(tag.RowsAffected())}, err
+//			},
+//		}},
+//	})
+//
+// ListTableActions lists them for the table actions API. StartTableAction
+// runs one in the background as a table_action operation, which can be
+// followed and cancelled like a reset, and records the outcome in the
+// audit log. An action runs at most once per table at a time.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Table action errors.
+var (
+	ErrActionNotFound = errors.New("table action not found")
+	ErrActionRunning  = errors.New("table action already running")
+)
+
+// TableAction is a custom action on a table.
+type TableAction struct {
+	Name        string // Identifier in the API: lower case letters, digits, - and _
+	Label       string // Shown to users; Name if empty
+	Description string
+
+	// Steps are the progress steps Run drives through ActionRun.Op. If
+	// empty the action has one step, named Name, begun and ended for it.
+	Steps []OperationStep
+
+	Run TableActionFunc
+}
+
+// TableActionFunc does the work of a table action. It should stop soon
+// after ctx is cancelled.
+type TableActionFunc func(ctx context.Context, run *ActionRun) (ActionResult, error)
+
+// ActionRun is what a running table action works with.
+type ActionRun struct {
+	Table  TableDefinition
+	Params map[string]string // From the request, unchecked
+	DB     DBTX              // The database pool
+	Op     *Operation        // Progress: Begin, Advance, SetDetail and EndStep
+}
+
+// ActionResult is what a table action did, recorded in the audit log.
+type ActionResult struct {
+	RowsAffected int
+	Message      string // Summary, e.g. "Pushed 120 invoices"
+}
+
+// TableActionInfo describes a table action in the API.
+type TableActionInfo struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	OperationID string `json:"operationId,omitempty"` // While running
+}
+
+var actionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validateActions checks a table's actions at registration.
+func validateActions(def TableDefinition) error {
+	seen := make(map[string]bool, len(def.Actions))
+	for _, a := range def.Actions {
+		if !actionNamePattern.MatchString(a.Name) {
+			return fmt.Errorf("action %q: name must be lower case letters, digits, - and _", a.Name)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("action %q declared twice", a.Name)
+		}
+		seen[a.Name] = true
+		if a.Run == nil {
+			return fmt.Errorf("action %q has no Run function", a.Name)
+		}
+	}
+	return nil
+}
+
+// runningActions tracks the table actions running, by table and action,
+// with their operation IDs.
+type runningActions struct {
+	mu   sync.Mutex
+	byID map[string]string
+}
+
+// claim marks key as running as opID, or returns the operation already
+// running it.
+func (r *runningActions) claim(key, opID string) (running string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, busy := r.byID[key]; busy {
+		return id, false
+	}
+	if r.byID == nil {
+		r.byID = make(map[string]string)
+	}
+	r.byID[key] = opID
+	return "", true
+}
+
+// release marks key as no longer running.
+func (r *runningActions) release(key string) {
+	r.mu.Lock()
+	delete(r.byID, key)
+	r.mu.Unlock()
+}
+
+// running returns the operation running key, or "".
+func (r *runningActions) running(key string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.byID[key]
+}
+
+// ListTableActions returns the actions of tableKey, in declaration order.
+func (s *Service) ListTableActions(tableKey string) ([]TableActionInfo, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	infos := make([]TableActionInfo, len(def.Actions))
+	for i, a := range def.Actions {
+		infos[i] = TableActionInfo{
+			Name:        a.Name,
+			Label:       a.label(),
+			Description: a.Description,
+			OperationID: s.actions.running(tableKey + "/" + a.Name),
+		}
+	}
+	return infos, nil
+}
+
+// StartTableAction runs the action name of tableKey in the background and
+// returns the operation ID to follow it with. The action keeps running if
+// ctx is cancelled; CancelOperation cancels the context it runs with.
+func (s *Service) StartTableAction(ctx context.Context, tableKey, name string, params map[string]string) (string, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return "", fmt.Errorf("unknown table: %s", tableKey)
+	}
+	action, ok := def.action(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s on %s", ErrActionNotFound, name, tableKey)
+	}
+
+	steps := action.Steps
+	if len(steps) == 0 {
+		steps = []OperationStep{{Name: action.Name, Weight: 1}}
+	}
+	id := uuid.New().String()
+	key := tableKey + "/" + name
+	if running, ok := s.actions.claim(key, id); !ok {
+		return "", fmt.Errorf("%w: %s on %s (operation %s)", ErrActionRunning, name, tableKey, running)
+	}
+	op := s.startOperation(ctx, id, OperationTableAction, tableKey, steps)
+
+	s.runDetached(ctx, op, func(ctx context.Context) error {
+		defer s.actions.release(key)
+		defer s.invalidateQueryCache(tableKey)
+
+		run := &ActionRun{Table: def, Params: params, DB: s.pool, Op: op}
+		if len(action.Steps) == 0 {
+			op.Begin(action.Name)
+		}
+		result, err := action.Run(ctx, run)
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if len(action.Steps) == 0 {
+			op.EndStep(action.Name, err)
+		}
+		s.logTableAction(ctx, def, action, result, err)
+		return err
+	})
+	return op.ID(), nil
+}
+
+// action returns the action of def named name.
+func (def TableDefinition) action(name string) (TableAction, bool) {
+	for _, a := range def.Actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return TableAction{}, false
+}
+
+// label returns the action's label, or its name.
+func (a TableAction) label() string {
+	if a.Label != "" {
+		return a.Label
+	}
+	return a.Name
+}
+
+// logTableAction records a finished table action in the audit log, also
+// when it failed or was cancelled part way.
+func (s *Service) logTableAction(ctx context.Context, def TableDefinition, action TableAction, result ActionResult, err error) {
+	reason := fmt.Sprintf("Ran %q", action.label())
+	switch {
+	case errors.Is(err, context.Canceled):
+		reason += " (cancelled)"
+	case err != nil:
+		reason += ": failed: " + err.Error()
+	}
+	if result.Message != "" {
+		reason += ": " + result.Message
+	}
+	ctx = context.WithoutCancel(ctx)
+	s.LogAudit(ctx, AuditLogParams{
+		Action:       ActionTableAction,
+		TableKey:     def.Info.Key,
+		NewValue:     action.Name,
+		RowsAffected: result.RowsAffected,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       reason,
+	})
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func noopAction(context.Context, *ActionRun) (ActionResult, error) { return ActionResult{}, nil }
+
+func TestValidateActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []TableAction
+		wantErr string
+	}{
+		{"none", nil, ""},
+		{"valid", []TableAction{{Name: "push-to_billing2", Run: noopAction}, {Name: "recalc", Run: noopAction}}, ""},
+		{"empty name", []TableAction{{Run: noopAction}}, "name must be"},
+		{"upper case", []TableAction{{Name: "Recalc", Run: noopAction}}, "name must be"},
+		{"slash", []TableAction{{Name: "a/b", Run: noopAction}}, "name must be"},
+		{"duplicate", []TableAction{{Name: "recalc", Run: noopAction}, {Name: "recalc", Run: noopAction}}, "declared twice"},
+		{"no run", []TableAction{{Name: "recalc"}}, "no Run function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateActions(TableDefinition{Actions: tt.actions})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTableActions_ListAndStartErrors(t *testing.T) {
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "action_sales"},
+		FieldSpecs: []FieldSpec{{Name: "Amount", Type: FieldNumeric}},
+		Actions: []TableAction{
+			{Name: "recalc", Label: "Recalculate", Description: "Recalculate commissions", Run: noopAction},
+			{Name: "push", Run: noopAction},
+		},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "action_sales")
+		registryMu.Unlock()
+	})
+	s := &Service{operations: make(map[string]*Operation)}
+
+	if _, ok := s.actions.claim("action_sales/push", "op-1"); !ok {
+		t.Fatal("claim failed")
+	}
+	infos, err := s.ListTableActions("action_sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TableActionInfo{
+		{Name: "recalc", Label: "Recalculate", Description: "Recalculate commissions"},
+		{Name: "push", Label: "push", OperationID: "op-1"},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("got %d actions, want %d", len(infos), len(want))
+	}
+	for i := range want {
+		if infos[i] != want[i] {
+			t.Errorf("action %d = %+v, want %+v", i, infos[i], want[i])
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := s.ListTableActions("no_such_table"); err == nil {
+		t.Error("ListTableActions of an unknown table succeeded")
+	}
+	if _, err := s.StartTableAction(ctx, "no_such_table", "recalc", nil); err == nil {
+		t.Error("StartTableAction on an unknown table succeeded")
+	}
+	if _, err := s.StartTableAction(ctx, "action_sales", "nope", nil); !errors.Is(err, ErrActionNotFound) {
+		t.Errorf("unknown action: error = %v, want ErrActionNotFound", err)
+	}
+	_, err = s.StartTableAction(ctx, "action_sales", "push", nil)
+	if !errors.Is(err, ErrActionRunning) || !strings.Contains(err.Error(), "op-1") {
+		t.Errorf("running action: error = %v, want ErrActionRunning naming op-1", err)
+	}
+	if len(s.operations) != 0 {
+		t.Errorf("refused starts tracked %d operations", len(s.operations))
+	}
+
+	s.actions.release("action_sales/push")
+	if id := s.actions.running("action_sales/push"); id != "" {
+		t.Errorf("released action still running as %s", id)
+	}
+}
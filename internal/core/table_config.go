@@ -0,0 +1,411 @@
+package core
+
+// table_config.go registers tables declared in schema files, so a new CSV
+// type can be added without writing Go or recompiling.
+//
+// A schema file lists tables with their field specs, unique key and upload
+// settings, in JSON or YAML (by extension: .yaml or .yml). The insert,
+// reset, rollback and COPY functions that hand-written tables get from sqlc
+// are generated from the field specs instead, and SyncConfigTables creates
+// each table at startup if it does not exist, with the same layout as the
+// table migrations: an id, one column per field, and upload_id (and
+// deleted_at with softDelete).
+//
+// Later changes to a declared table are applied like any other schema
+// change: add fields (or Renames, in Go) and use GenerateSchemaMigration.
+// Normalizers and validation hooks are Go functions and cannot be declared.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"gopkg.in/yaml.v3"
+)
+
+// TableConfigFile is the schema file format.
+type TableConfigFile struct {
+	Tables []TableConfig `json:"tables"`
+}
+
+// TableConfig declares one table.
+type TableConfig struct {
+	Key        string        `json:"key"`                  // Table and database table name, e.g. "hubspot_deals"
+	Group      string        `json:"group,omitempty"`      // Default "Custom"
+	Label      string        `json:"label,omitempty"`      // Default Key
+	Directory  string        `json:"directory,omitempty"`  // Default Label
+	UniqueKey  []string      `json:"uniqueKey,omitempty"`  // Field names
+	UploadMode UploadMode    `json:"uploadMode,omitempty"` // Default insert
+	SoftDelete bool          `json:"softDelete,omitempty"` // Deletes go to a recycle bin; needs uniqueKey
+	References []string      `json:"references,omitempty"` // Keys of tables this one's rows refer to
+	Limits     UploadLimits  `json:"limits,omitempty"`
+	Fields     []FieldConfig `json:"fields"`
+
+	// Fewest rows per summary group or histogram bin shown to
+	// aggregates-only callers; default QUERY_AGGREGATE_MIN_GROUP_SIZE
+	MinGroupSize int `json:"minGroupSize,omitempty"`
+
+	// Watermark every export with a traceable export ID
+	WatermarkExports bool `json:"watermarkExports,omitempty"`
+}
+
+// FieldConfig declares one column.
+type FieldConfig struct {
+	Name       string   `json:"name"`               // CSV header
+	DBColumn   string   `json:"dbColumn,omitempty"` // Default derived from Name
+	Type       string   `json:"type,omitempty"`     // text (default), enum, date, numeric or bool
+	Required   bool     `json:"required,omitempty"`
+	AllowEmpty bool     `json:"allowEmpty,omitempty"`
+	EnumValues []string `json:"enumValues,omitempty"`
+	YearPivot  int      `json:"yearPivot,omitempty"` // Dates only; see DefaultTwoDigitYearPivot
+	Mask       MaskKind `json:"mask,omitempty"`      // name, email, phone, id or redact; see anonymize.go
+}
+
+// fieldTypeNames maps schema file type names to field types.
+var fieldTypeNames = map[string]FieldType{
+	"text":    FieldText,
+	"enum":    FieldEnum,
+	"date":    FieldDate,
+	"numeric": FieldNumeric,
+	"bool":    FieldBool,
+}
+
+// configIdentifier is what a declared table key or column may look like,
+// so generated SQL never depends on quoting unusual names.
+var configIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// ParseTableConfig decodes a JSON schema file. Unknown fields are rejected
+// so typos fail loudly instead of being ignored.
+func ParseTableConfig(data []byte) (*TableConfigFile, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var file TableConfigFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse table config: %w", err)
+	}
+	return &file, nil
+}
+
+// LoadTableConfigFile reads and parses a schema file from disk. YAML is
+// converted to JSON first, so both formats accept exactly the same fields.
+func LoadTableConfigFile(path string) (*TableConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read table config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse table config %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parse table config %s: %w", path, err)
+		}
+	}
+
+	file, err := ParseTableConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// RegisterTableConfigFiles loads schema files and registers their tables.
+// Every table is checked before any is registered, so a bad file leaves
+// the registry unchanged.
+func RegisterTableConfigFiles(paths ...string) error {
+	var defs []TableDefinition
+	seen := make(map[string]string)
+	for _, path := range paths {
+		file, err := LoadTableConfigFile(path)
+		if err != nil {
+			return err
+		}
+		for _, tc := range file.Tables {
+			def, err := tc.Definition()
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if prev, ok := seen[def.Info.Key]; ok {
+				return fmt.Errorf("%s: table %s already declared in %s", path, def.Info.Key, prev)
+			}
+			if _, ok := Get(def.Info.Key); ok {
+				return fmt.Errorf("%s: table %s is already registered", path, def.Info.Key)
+			}
+			seen[def.Info.Key] = path
+			defs = append(defs, def)
+		}
+	}
+
+	for _, def := range defs {
+		Register(def)
+	}
+	return nil
+}
+
+// Definition validates the declaration and builds its table definition.
+func (tc TableConfig) Definition() (TableDefinition, error) {
+	if !configIdentifier.MatchString(tc.Key) {
+		return TableDefinition{}, fmt.Errorf("table key %q must be lowercase letters, digits and underscores", tc.Key)
+	}
+	fail := func(format string, args ...any) (TableDefinition, error) {
+		return TableDefinition{}, fmt.Errorf("table %s: %s", tc.Key, fmt.Sprintf(format, args...))
+	}
+	if len(tc.Fields) == 0 {
+		return fail("no fields")
+	}
+
+	specs := make([]FieldSpec, len(tc.Fields))
+	names := make(map[string]bool, len(tc.Fields))
+	columns := map[string]bool{"id": true, "upload_id": true}
+	if tc.SoftDelete {
+		columns[softDeleteColumn] = true
+	}
+	for i, f := range tc.Fields {
+		if f.Name == "" {
+			return fail("field %d has no name", i+1)
+		}
+		if names[strings.ToLower(f.Name)] {
+			return fail("duplicate field %q", f.Name)
+		}
+		names[strings.ToLower(f.Name)] = true
+
+		col := f.DBColumn
+		if col == "" {
+			col = toDBColumnName(f.Name)
+		}
+		if !configIdentifier.MatchString(col) {
+			return fail("field %q: column %q must be lowercase letters, digits and underscores (set dbColumn)", f.Name, col)
+		}
+		if columns[col] {
+			return fail("field %q: column %q is already used", f.Name, col)
+		}
+		columns[col] = true
+
+		typeName := f.Type
+		if typeName == "" {
+			typeName = "text"
+		}
+		ft, ok := fieldTypeNames[strings.ToLower(typeName)]
+		if !ok {
+			return fail("field %q: unknown type %q (want text, enum, date, numeric or bool)", f.Name, f.Type)
+		}
+		if ft == FieldEnum && len(f.EnumValues) == 0 {
+			return fail("field %q: enum needs enumValues", f.Name)
+		}
+		if ft != FieldEnum && len(f.EnumValues) > 0 {
+			return fail("field %q: enumValues need type enum", f.Name)
+		}
+		if ft != FieldDate && f.YearPivot != 0 {
+			return fail("field %q: yearPivot needs type date", f.Name)
+		}
+		if err := checkYearPivot(f.YearPivot); err != nil {
+			return fail("field %q: %v", f.Name, err)
+		}
+		if err := checkMask(f.Mask, ft); err != nil {
+			return fail("field %q: %v", f.Name, err)
+		}
+
+		specs[i] = FieldSpec{
+			Name:       f.Name,
+			DBColumn:   col,
+			Type:       ft,
+			Required:   f.Required,
+			AllowEmpty: f.AllowEmpty,
+			EnumValues: f.EnumValues,
+			YearPivot:  f.YearPivot,
+			Mask:       f.Mask,
+		}
+	}
+	for _, k := range tc.UniqueKey {
+		if !names[strings.ToLower(k)] {
+			return fail("unique key column %q is not a field", k)
+		}
+	}
+	for _, ref := range tc.References {
+		if ref == tc.Key {
+			return fail("references itself")
+		}
+	}
+	if tc.MinGroupSize < 0 {
+		return fail("minGroupSize must not be negative")
+	}
+
+	def := TableDefinition{
+		Info: TableInfo{
+			Key:       tc.Key,
+			Group:     tc.Group,
+			Label:     tc.Label,
+			Directory: tc.Directory,
+			UniqueKey: tc.UniqueKey,
+		},
+		FieldSpecs:       specs,
+		Limits:           tc.Limits,
+		UploadMode:       tc.UploadMode,
+		SoftDelete:       tc.SoftDelete,
+		References:       tc.References,
+		MinGroupSize:     tc.MinGroupSize,
+		WatermarkExports: tc.WatermarkExports,
+		declared:         true,
+	}
+	if def.Info.Group == "" {
+		def.Info.Group = "Custom"
+	}
+	if def.Info.Label == "" {
+		def.Info.Label = tc.Key
+	}
+	if def.Info.Directory == "" {
+		def.Info.Directory = def.Info.Label
+	}
+	if def.UploadMode != "" {
+		if _, err := resolveUploadMode(def, def.UploadMode); err != nil {
+			return fail("%v", err)
+		}
+	}
+	if err := validateSoftDelete(def); err != nil {
+		return fail("%v", err)
+	}
+	addGeneratedFuncs(&def)
+	return def, nil
+}
+
+// IsDeclared reports whether the table was registered from a schema file.
+func (def TableDefinition) IsDeclared() bool {
+	return def.declared
+}
+
+// declaredColumns returns the database columns of a declared table in
+// insert order, ending with upload_id.
+func declaredColumns(def TableDefinition) []string {
+	cols := make([]string, 0, len(def.FieldSpecs)+1)
+	for _, spec := range def.FieldSpecs {
+		cols = append(cols, spec.DBColumn)
+	}
+	return append(cols, "upload_id")
+}
+
+// addGeneratedFuncs fills in the upload functions of a declared table.
+// Params are the row's values in declaredColumns order.
+func addGeneratedFuncs(def *TableDefinition) {
+	specs := def.FieldSpecs
+	table := quoteIdentifier(def.Info.Key)
+	cols := declaredColumns(*def)
+
+	quoted := make([]string, len(cols))
+	placeholders := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdentifier(col)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+
+	def.BuildParams = func(row []string, idx HeaderIndex, uploadID pgtype.UUID) (any, error) {
+		values := ruleValues(row, idx, TableDefinition{FieldSpecs: specs})
+		params := make([]any, 0, len(specs)+1)
+		for _, spec := range specs {
+			raw := values[spec.Name]
+			switch spec.Type {
+			case FieldDate:
+				params = append(params, ToPgDate(raw))
+			case FieldNumeric:
+				params = append(params, ToPgNumeric(raw))
+			case FieldBool:
+				params = append(params, ToPgBool(raw))
+			default:
+				params = append(params, ToPgText(raw))
+			}
+		}
+		return append(params, uploadID), nil
+	}
+	def.Insert = func(ctx context.Context, db DBTX, params any) error {
+		_, err := db.Exec(ctx, insertSQL, params.([]any)...)
+		return err
+	}
+	def.Reset = func(ctx context.Context, db DBTX) error {
+		_, err := db.Exec(ctx, "DELETE FROM "+table)
+		return err
+	}
+	def.DeleteByUploadID = deleteByUploadID(def.Info.Key)
+	def.CopyColumns = cols
+	def.CopyRow = func(params any) []any {
+		return params.([]any)
+	}
+}
+
+// createDeclaredTableSQL returns the statements that create a declared
+// table and its indexes if missing.
+func createDeclaredTableSQL(def TableDefinition) []string {
+	key := def.Info.Key
+	lines := []string{"    id UUID PRIMARY KEY DEFAULT gen_random_uuid()"}
+	for _, spec := range def.FieldSpecs {
+		lines = append(lines, fmt.Sprintf("    %s %s", quoteIdentifier(spec.DBColumn), fieldSQLType(spec.Type)))
+	}
+	lines = append(lines, "    upload_id UUID REFERENCES csv_uploads(id) ON DELETE SET NULL")
+	if def.SoftDelete {
+		lines = append(lines, "    "+softDeleteColumn+" TIMESTAMPTZ")
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n)", quoteIdentifier(key), strings.Join(lines, ",\n")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(upload_id) WHERE upload_id IS NOT NULL",
+			quoteIdentifier("idx_"+key+"_upload_id"), quoteIdentifier(key)),
+	}
+	if len(def.Info.UniqueKey) > 0 {
+		keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+		for i, col := range keyCols {
+			keyCols[i] = quoteIdentifier(col)
+		}
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)",
+			quoteIdentifier("idx_"+key+"_unique_key"), quoteIdentifier(key), strings.Join(keyCols, ", ")))
+	}
+	if def.SoftDelete {
+		stmts = append(stmts, softDeleteIndexSQL(key))
+	}
+	return stmts
+}
+
+// SyncConfigTables creates every declared table that does not exist yet.
+// Existing tables are left alone; see GenerateSchemaMigration for changes.
+// A table that fails does not stop the others; all failures are returned
+// joined.
+func (s *Service) SyncConfigTables(ctx context.Context) error {
+	var errs []error
+	for _, def := range All() {
+		if !def.IsDeclared() {
+			continue
+		}
+		if err := s.syncConfigTable(ctx, def); err != nil {
+			errs = append(errs, fmt.Errorf("create table %s: %w", def.Info.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) syncConfigTable(ctx context.Context, def TableDefinition) error {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range createDeclaredTableSQL(def) {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
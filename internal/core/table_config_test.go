@@ -0,0 +1,223 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const testTableConfigYAML = `
+tables:
+  - key: hubspot_deals
+    group: HubSpot
+    label: Deals
+    uniqueKey: [Deal ID]
+    uploadMode: upsert
+    limits:
+      maxRows: 5000
+    fields:
+      - name: Deal ID
+        required: true
+      - name: Stage
+        type: enum
+        enumValues: [open, won, lost]
+      - name: Close Date
+        type: date
+      - name: Amount
+        dbColumn: amount_usd
+        type: numeric
+      - name: Is Renewal
+        type: bool
+`
+
+func writeTableConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func unregisterTables(t *testing.T, keys ...string) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		for _, k := range keys {
+			delete(registry, k)
+		}
+		registryMu.Unlock()
+	})
+}
+
+func TestLoadTableConfigFile_YAMLAndJSON(t *testing.T) {
+	fromYAML, err := LoadTableConfigFile(writeTableConfig(t, "tables.yaml", testTableConfigYAML))
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	fromJSON, err := LoadTableConfigFile(writeTableConfig(t, "tables.json", `{"tables": [{
+		"key": "hubspot_deals", "group": "HubSpot", "label": "Deals",
+		"uniqueKey": ["Deal ID"], "uploadMode": "upsert", "limits": {"maxRows": 5000},
+		"fields": [
+			{"name": "Deal ID", "required": true},
+			{"name": "Stage", "type": "enum", "enumValues": ["open", "won", "lost"]},
+			{"name": "Close Date", "type": "date"},
+			{"name": "Amount", "dbColumn": "amount_usd", "type": "numeric"},
+			{"name": "Is Renewal", "type": "bool"}
+		]}]}`))
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+
+	y, _ := fromYAML.Tables[0].Definition()
+	j, _ := fromJSON.Tables[0].Definition()
+	if strings.Join(declaredColumns(y), ",") != strings.Join(declaredColumns(j), ",") || y.Limits != j.Limits || y.UploadMode != j.UploadMode {
+		t.Errorf("yaml and json differ:\n%+v\n%+v", y, j)
+	}
+	if got := strings.Join(declaredColumns(y), ","); got != "deal_id,stage,close_date,amount_usd,is_renewal,upload_id" {
+		t.Errorf("columns = %s", got)
+	}
+	if y.Info.Group != "HubSpot" || y.Info.Directory != "Deals" || y.Limits.MaxRows != 5000 || !y.IsDeclared() {
+		t.Errorf("definition = %+v", y.Info)
+	}
+
+	// Typos fail in both formats
+	if _, err := LoadTableConfigFile(writeTableConfig(t, "typo.yml", "tables:\n  - key: t\n    feilds: []\n")); err == nil || !strings.Contains(err.Error(), "feilds") {
+		t.Errorf("unknown yaml field: err = %v", err)
+	}
+}
+
+func TestTableConfig_Definition_Errors(t *testing.T) {
+	text := []FieldConfig{{Name: "Name"}}
+	tests := []struct {
+		name string
+		tc   TableConfig
+		want string
+	}{
+		{"bad key", TableConfig{Key: "Deals", Fields: text}, "lowercase"},
+		{"no fields", TableConfig{Key: "t"}, "no fields"},
+		{"unnamed field", TableConfig{Key: "t", Fields: []FieldConfig{{}}}, "field 1 has no name"},
+		{"duplicate field", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A"}, {Name: "a"}}}, "duplicate field"},
+		{"bad column", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "Amount ($)"}}}, "set dbColumn"},
+		{"reserved column", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "ID"}}}, `column "id" is already used`},
+		{"unknown type", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A", Type: "money"}}}, "unknown type"},
+		{"enum without values", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A", Type: "enum"}}}, "enum needs enumValues"},
+		{"values without enum", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A", EnumValues: []string{"x"}}}}, "need type enum"},
+		{"bad unique key", TableConfig{Key: "t", Fields: text, UniqueKey: []string{"ID"}}, "not a field"},
+		{"upsert without key", TableConfig{Key: "t", Fields: text, UploadMode: UploadModeUpsert}, "table t:"},
+		{"soft delete without key", TableConfig{Key: "t", Fields: text, SoftDelete: true}, "soft delete needs a unique key"},
+		{"soft delete column", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "Deleted At"}}, UniqueKey: []string{"Deleted At"}, SoftDelete: true}, `column "deleted_at" is already used`},
+		{"self reference", TableConfig{Key: "t", Fields: text, References: []string{"t"}}, "references itself"},
+		{"unknown mask", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A", Mask: "hash"}}}, "unknown mask"},
+		{"mask on numeric", TableConfig{Key: "t", Fields: []FieldConfig{{Name: "A", Type: "numeric", Mask: MaskName}}}, "needs a text field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.tc.Definition(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableConfig_BuildParams(t *testing.T) {
+	file, err := LoadTableConfigFile(writeTableConfig(t, "tables.yaml", testTableConfigYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, _ := file.Tables[0].Definition()
+
+	headerIdx := HeaderIndex{"deal id": 0, "stage": 1, "close date": 2, "amount": 3}
+	uploadID := ToPgUUID("8c6d1f4e-3b1a-4c55-9a7e-2f0d5b6c7a11")
+	params, err := def.BuildParams([]string{" D-1 ", "won", "2024-03-31", "1,200.50"}, headerIdx, uploadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := def.CopyRow(params)
+	if len(row) != len(def.CopyColumns) {
+		t.Fatalf("row has %d values for %d columns", len(row), len(def.CopyColumns))
+	}
+	if v := row[0].(pgtype.Text); v.String != "D-1" {
+		t.Errorf("deal_id = %+v", v)
+	}
+	if v := row[2].(pgtype.Date); !v.Valid {
+		t.Errorf("close_date = %+v", v)
+	}
+	if v := row[3].(pgtype.Numeric); !v.Valid {
+		t.Errorf("amount_usd = %+v", v)
+	}
+	if v := row[4].(pgtype.Bool); v.Valid {
+		t.Errorf("is_renewal missing from file = %+v, want NULL", v)
+	}
+	if row[5] != uploadID {
+		t.Errorf("upload_id = %v", row[5])
+	}
+}
+
+func TestCreateDeclaredTableSQL(t *testing.T) {
+	def, err := TableConfig{
+		Key:       "vendors",
+		UniqueKey: []string{"Vendor ID", "Region"},
+		Fields:    []FieldConfig{{Name: "Vendor ID"}, {Name: "Region"}, {Name: "Since", Type: "date"}},
+	}.Definition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE IF NOT EXISTS \"vendors\" (\n" +
+			"    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),\n" +
+			"    \"vendor_id\" TEXT,\n" +
+			"    \"region\" TEXT,\n" +
+			"    \"since\" DATE,\n" +
+			"    upload_id UUID REFERENCES csv_uploads(id) ON DELETE SET NULL\n)",
+		`CREATE INDEX IF NOT EXISTS "idx_vendors_upload_id" ON "vendors"(upload_id) WHERE upload_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS "idx_vendors_unique_key" ON "vendors"("vendor_id", "region")`,
+	}
+	got := createDeclaredTableSQL(def)
+	if strings.Join(got, ";\n") != strings.Join(want, ";\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, ";\n"), strings.Join(want, ";\n"))
+	}
+
+	def.SoftDelete = true
+	got = createDeclaredTableSQL(def)
+	if !strings.Contains(got[0], "ON DELETE SET NULL,\n    deleted_at TIMESTAMPTZ\n)") ||
+		got[len(got)-1] != `CREATE INDEX IF NOT EXISTS "idx_vendors_deleted_at" ON "vendors"(deleted_at) WHERE deleted_at IS NOT NULL` {
+		t.Errorf("soft delete:\n%s", strings.Join(got, ";\n"))
+	}
+}
+
+func TestRegisterTableConfigFiles(t *testing.T) {
+	unregisterTables(t, "hubspot_deals", "config_a")
+
+	good := writeTableConfig(t, "tables.yaml", testTableConfigYAML)
+	bad := writeTableConfig(t, "more.json", `{"tables": [
+		{"key": "config_a", "fields": [{"name": "A"}]},
+		{"key": "config_b", "fields": []}
+	]}`)
+
+	// Nothing is registered when any table is invalid
+	if err := RegisterTableConfigFiles(good, bad); err == nil || !strings.Contains(err.Error(), "table config_b: no fields") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok := Get("hubspot_deals"); ok {
+		t.Error("hubspot_deals registered despite the error")
+	}
+
+	if err := RegisterTableConfigFiles(good); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	def, ok := Get("hubspot_deals")
+	if !ok || len(def.Info.Columns) != 5 || !def.SupportsCopy() {
+		t.Errorf("registered = %+v", def.Info)
+	}
+	if err := RegisterTableConfigFiles(good); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("re-register: err = %v", err)
+	}
+
+	dup := writeTableConfig(t, "dup.json", `{"tables": [{"key": "config_a", "fields": [{"name": "A"}]}, {"key": "config_a", "fields": [{"name": "A"}]}]}`)
+	if err := RegisterTableConfigFiles(dup); err == nil || !strings.Contains(err.Error(), "already declared") {
+		t.Errorf("duplicate key: err = %v", err)
+	}
+}
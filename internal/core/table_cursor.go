@@ -0,0 +1,151 @@
+package core
+
+// table_cursor.go adds keyset pagination to GetTableData.
+//
+// OFFSET pagination makes the database read and discard every row before
+// the page, which gets slow past ~100k rows. A cursor instead records the
+// sort key values of the last row served, plus its id (every table has
+// one) to break ties, and the next page is read with a WHERE clause that
+// starts right after that row, so any page costs the same as the first.
+//
+// Cursors are opaque base64url tokens. A cursor is only valid for the sort
+// it was issued with; search and filters may change between pages, as the
+// position is in the sort order rather than in the result set.
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a cursor that cannot be decoded or was
+// issued for a different sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// tableCursor is the decoded form of a cursor token.
+type tableCursor struct {
+	Sorts  []SortSpec `json:"s"`
+	Values []*string  `json:"v"` // Sort key values as text, nil for NULL
+	ID     string     `json:"id"`
+}
+
+// encodeTableCursor returns the token for the row with the given sort key
+// values and id.
+func encodeTableCursor(sorts []SortSpec, values []*string, id string) string {
+	b, _ := json.Marshal(tableCursor{Sorts: sorts, Values: values, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeTableCursor parses a token, checking it was issued for sorts.
+func decodeTableCursor(token string, sorts []SortSpec) (*tableCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c tableCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID == "" || len(c.Values) != len(c.Sorts) {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	if len(c.Sorts) != len(sorts) {
+		return nil, fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+	}
+	for i := range sorts {
+		if c.Sorts[i] != sorts[i] {
+			return nil, fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+		}
+	}
+	return &c, nil
+}
+
+// rowCursor returns the cursor after a row, from its id and sort key
+// values as selected by GetTableData: text, or nil for NULL.
+func rowCursor(sorts []SortSpec, keys []any) string {
+	id, _ := keys[0].(string)
+	values := make([]*string, len(sorts))
+	for i := range sorts {
+		if v, ok := keys[i+1].(string); ok {
+			values[i] = &v
+		}
+	}
+	return encodeTableCursor(sorts, values, id)
+}
+
+// AddKeyset adds the condition selecting the rows after cursor c.
+func (w *WhereBuilder) AddKeyset(def TableDefinition, c *tableCursor) {
+	cond, args, next := keysetCondition(def, c, w.argIndex)
+	w.conditions = append(w.conditions, cond)
+	w.args = append(w.args, args...)
+	w.argIndex = next
+}
+
+// nullsFirst reports whether a normalized sort puts NULLs first, as
+// PostgreSQL does by default for descending sorts.
+func nullsFirst(s SortSpec) bool {
+	return s.Nulls == "first" || (s.Nulls == "" && s.Dir == "desc")
+}
+
+// keysetCondition returns the WHERE condition selecting the rows after c
+// in the order of c.Sorts followed by id, with placeholders from argIdx.
+// It expands the row comparison by hand, one branch per sort level, since
+// levels may differ in direction and NULL placement:
+//
+//	k1 after v1 OR (k1 = v1 AND k2 after v2) OR ... OR (... AND id > last id)
+func keysetCondition(def TableDefinition, c *tableCursor, argIdx int) (string, []any, int) {
+	var (
+		branches []string
+		equal    []string // Conditions for the levels before the current one
+		args     []any
+	)
+	for i, sort := range c.Sorts {
+		col := columnExpr(def, sort.Column)
+		v := c.Values[i]
+
+		var after string
+		switch {
+		case v == nil && nullsFirst(sort):
+			after = col + " IS NOT NULL"
+		case v == nil:
+			// Nothing sorts after NULLs placed last, except by a later level
+		default:
+			op := ">"
+			if sort.Dir == "desc" {
+				op = "<"
+			}
+			after = fmt.Sprintf("%s %s $%d", col, op, argIdx)
+			if !nullsFirst(sort) {
+				after = fmt.Sprintf("(%s OR %s IS NULL)", after, col)
+			}
+			args = append(args, *v)
+			argIdx++
+		}
+		if after != "" {
+			branches = append(branches, andConditions(append(equal, after)))
+		}
+
+		if v == nil {
+			equal = append(equal, col+" IS NULL")
+		} else {
+			equal = append(equal, fmt.Sprintf("%s = $%d", col, argIdx))
+			args = append(args, *v)
+			argIdx++
+		}
+	}
+	branches = append(branches, andConditions(append(equal, fmt.Sprintf("id > $%d", argIdx))))
+	args = append(args, c.ID)
+	argIdx++
+
+	return "(" + strings.Join(branches, " OR ") + ")", args, argIdx
+}
+
+// andConditions joins conditions with AND, parenthesized if more than one.
+func andConditions(conds []string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, " AND ") + ")"
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTableCursor_RoundTrip(t *testing.T) {
+	sorts := []SortSpec{{Column: "Amount", Dir: "desc"}, {Column: "Vendor", Dir: "asc"}}
+	amount := "120.50"
+	token := rowCursor(sorts, []any{"6f1c2a9e-0000-4000-8000-000000000001", amount, nil})
+
+	c, err := decodeTableCursor(token, sorts)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if c.ID != "6f1c2a9e-0000-4000-8000-000000000001" || *c.Values[0] != amount || c.Values[1] != nil {
+		t.Errorf("cursor = %+v", c)
+	}
+
+	for name, tc := range map[string]struct {
+		token string
+		sorts []SortSpec
+	}{
+		"other direction": {token, []SortSpec{{Column: "Amount", Dir: "asc"}, {Column: "Vendor", Dir: "asc"}}},
+		"fewer levels":    {token, sorts[:1]},
+		"not base64":      {"%%%", sorts},
+		"not json":        {"bm90IGpzb24", sorts},
+	} {
+		if _, err := decodeTableCursor(tc.token, tc.sorts); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	def := TableDefinition{FieldSpecs: []FieldSpec{
+		{Name: "Amount", DBColumn: "amount", Type: FieldNumeric},
+		{Name: "Vendor", DBColumn: "vendor", Type: FieldText},
+	}}
+	v := func(s string) *string { return &s }
+
+	tests := []struct {
+		name   string
+		cursor tableCursor
+		want   string
+		args   int
+	}{
+		{
+			name:   "ascending",
+			cursor: tableCursor{Sorts: []SortSpec{{Column: "Vendor", Dir: "asc"}}, Values: []*string{v("Acme")}, ID: "id1"},
+			want:   `(("vendor" > $3 OR "vendor" IS NULL) OR ("vendor" = $4 AND id > $5))`,
+			args:   3,
+		},
+		{
+			name:   "descending then ascending",
+			cursor: tableCursor{Sorts: []SortSpec{{Column: "Amount", Dir: "desc"}, {Column: "Vendor", Dir: "asc"}}, Values: []*string{v("10"), v("Acme")}, ID: "id1"},
+			want:   `("amount" < $3 OR ("amount" = $4 AND ("vendor" > $5 OR "vendor" IS NULL)) OR ("amount" = $4 AND "vendor" = $6 AND id > $7))`,
+			args:   5,
+		},
+		{
+			name:   "NULL last",
+			cursor: tableCursor{Sorts: []SortSpec{{Column: "Vendor", Dir: "asc"}}, Values: []*string{nil}, ID: "id1"},
+			want:   `(("vendor" IS NULL AND id > $3))`,
+			args:   1,
+		},
+		{
+			name:   "NULL first",
+			cursor: tableCursor{Sorts: []SortSpec{{Column: "Vendor", Dir: "desc"}}, Values: []*string{nil}, ID: "id1"},
+			want:   `("vendor" IS NOT NULL OR ("vendor" IS NULL AND id > $3))`,
+			args:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, next := keysetCondition(def, &tt.cursor, 3)
+			if got != tt.want {
+				t.Errorf("condition:\n got %s\nwant %s", got, tt.want)
+			}
+			if len(args) != tt.args || next != 3+tt.args {
+				t.Errorf("args = %v, next = %d", args, next)
+			}
+			if last := fmt.Sprint(args[len(args)-1]); last != "id1" {
+				t.Errorf("last arg = %s, want the id", last)
+			}
+		})
+	}
+}
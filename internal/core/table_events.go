@@ -0,0 +1,178 @@
+package core
+
+// table_events.go pushes table changes to live views such as the dashboard.
+//
+// Uploads, rollbacks and resets call notifyTableChanged once their rows are
+// committed. Notices for a table are debounced: the first one schedules a
+// flush after tableEventDebounce, later ones within that window only update
+// its change, so a batch of uploads to one table costs a single stats query.
+// The flush reads the table's row count and last upload and sends a
+// TableEvent to every subscriber following that table.
+//
+// Nothing is queried while no one is subscribed. A subscriber that does not
+// keep up misses events; the next change to the table carries fresh stats.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// tableEventDebounce is how long notices for one table are collected
+// before its stats are read and pushed.
+const tableEventDebounce = 500 * time.Millisecond
+
+// TableChange names what changed a table.
+type TableChange string
+
+const (
+	TableChangeUpload   TableChange = "upload"
+	TableChangeRollback TableChange = "rollback"
+	TableChangeReset    TableChange = "reset"
+)
+
+// TableEvent is the current state of a table after a change.
+type TableEvent struct {
+	TableKey   string      `json:"table_key"`
+	Change     TableChange `json:"change"` // Latest change in the debounce window
+	RowCount   int64       `json:"row_count"`
+	LastUpload *time.Time  `json:"last_upload,omitempty"`
+	Time       time.Time   `json:"time"`
+}
+
+// tableEventHub debounces table change notices and fans them out.
+type tableEventHub struct {
+	debounce time.Duration
+	stats    func(ctx context.Context, tableKey string) (*TableStats, error)
+
+	mu      sync.Mutex
+	pending map[string]TableChange // Tables with a flush scheduled
+	subs    map[*tableEventSub]struct{}
+}
+
+// tableEventSub is one subscriber. tables is nil to follow every table.
+type tableEventSub struct {
+	tables map[string]bool
+	ch     chan TableEvent
+}
+
+func newTableEventHub(debounce time.Duration, stats func(context.Context, string) (*TableStats, error)) *tableEventHub {
+	return &tableEventHub{
+		debounce: debounce,
+		stats:    stats,
+		pending:  make(map[string]TableChange),
+		subs:     make(map[*tableEventSub]struct{}),
+	}
+}
+
+// subscribe returns a channel of events for tableKeys (all tables if
+// empty). The channel is closed when ctx is done.
+func (h *tableEventHub) subscribe(ctx context.Context, tableKeys []string) <-chan TableEvent {
+	sub := &tableEventSub{ch: make(chan TableEvent, 16)}
+	if len(tableKeys) > 0 {
+		sub.tables = make(map[string]bool, len(tableKeys))
+		for _, key := range tableKeys {
+			sub.tables[key] = true
+		}
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs, sub)
+		close(sub.ch) // Sends happen under h.mu, so none can follow
+		h.mu.Unlock()
+	}()
+	return sub.ch
+}
+
+// notify records a change to tableKey, scheduling a flush unless one is
+// already pending.
+func (h *tableEventHub) notify(tableKey string, change TableChange) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.following(tableKey) {
+		return
+	}
+	if _, ok := h.pending[tableKey]; ok {
+		h.pending[tableKey] = change
+		return
+	}
+	h.pending[tableKey] = change
+	time.AfterFunc(h.debounce, func() { h.flush(tableKey) })
+}
+
+// following reports whether any subscriber wants tableKey's events. Must
+// be called with h.mu held.
+func (h *tableEventHub) following(tableKey string) bool {
+	for sub := range h.subs {
+		if sub.tables == nil || sub.tables[tableKey] {
+			return true
+		}
+	}
+	return false
+}
+
+// flush reads tableKey's stats and sends them to its subscribers.
+func (h *tableEventHub) flush(tableKey string) {
+	h.mu.Lock()
+	change := h.pending[tableKey]
+	delete(h.pending, tableKey)
+	h.mu.Unlock()
+
+	stats, err := h.stats(context.Background(), tableKey)
+	if err != nil {
+		slog.Error("failed to read table stats for event", "table", tableKey, "error", err)
+		return
+	}
+	ev := TableEvent{
+		TableKey: tableKey,
+		Change:   change,
+		RowCount: stats.RowCount,
+		Time:     time.Now(),
+	}
+	if stats.LastUpload != nil {
+		ev.LastUpload = &stats.LastUpload.UploadedAt
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.tables != nil && !sub.tables[tableKey] {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			slog.Debug("dropped table event for slow subscriber", "table", tableKey)
+		}
+	}
+}
+
+// SubscribeTableEvents streams the state of tableKeys (every table if
+// none are given) after each upload, rollback or reset, debounced per
+// table. The channel is closed when ctx is done.
+func (s *Service) SubscribeTableEvents(ctx context.Context, tableKeys []string) (<-chan TableEvent, error) {
+	for _, key := range tableKeys {
+		if _, ok := Get(key); !ok {
+			return nil, fmt.Errorf("unknown table: %s", key)
+		}
+	}
+	return s.tableEvents.subscribe(ctx, tableKeys), nil
+}
+
+// notifyTableChanged tells table event subscribers that tableKey changed,
+// invalidating its cached stats first so the event carries fresh ones.
+func (s *Service) notifyTableChanged(tableKey string, change TableChange) {
+	s.invalidateQueryCache(tableKey)
+	s.tableEvents.notify(tableKey, change)
+}
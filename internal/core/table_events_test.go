@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTableEventHub_DebouncesAndFilters(t *testing.T) {
+	uploaded := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	var queries atomic.Int32
+	h := newTableEventHub(20*time.Millisecond, func(_ context.Context, tableKey string) (*TableStats, error) {
+		queries.Add(1)
+		return &TableStats{RowCount: 42, LastUpload: &LastUploadInfo{UploadedAt: uploaded}}, nil
+	})
+
+	// Nothing is queried without subscribers
+	h.notify("invoices", TableChangeUpload)
+	time.Sleep(50 * time.Millisecond)
+	if n := queries.Load(); n != 0 {
+		t.Fatalf("stats queried %d times without subscribers", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	invoices := h.subscribe(ctx, []string{"invoices"})
+	all := h.subscribe(ctx, nil)
+
+	h.notify("invoices", TableChangeUpload)
+	h.notify("invoices", TableChangeReset)
+	h.notify("vendor_bills", TableChangeUpload)
+
+	select {
+	case ev := <-invoices:
+		if ev.TableKey != "invoices" || ev.Change != TableChangeReset || ev.RowCount != 42 || !ev.LastUpload.Equal(uploaded) {
+			t.Errorf("event = %+v, want latest change with stats", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event for invoices")
+	}
+	select {
+	case ev := <-invoices:
+		t.Errorf("unexpected second event %+v (vendor_bills or undebounced)", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	got := map[string]bool{}
+	for range 2 {
+		select {
+		case ev := <-all:
+			got[ev.TableKey] = true
+		case <-time.After(time.Second):
+			t.Fatal("missing event for subscriber to all tables")
+		}
+	}
+	if !got["invoices"] || !got["vendor_bills"] {
+		t.Errorf("all-tables subscriber got %v", got)
+	}
+	if n := queries.Load(); n != 2 {
+		t.Errorf("stats queried %d times, want once per table", n)
+	}
+
+	cancel()
+	if _, ok := <-invoices; ok {
+		t.Error("channel still open after ctx is done")
+	}
+}
@@ -22,12 +22,12 @@ func registerAnrokTransactions() {
 			UniqueKey: []string{"Transaction ID"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "Transaction ID", DBColumn: "transaction_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Customer ID", DBColumn: "customer_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Customer name", DBColumn: "customer_name", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "Transaction ID", DBColumn: "transaction_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "Customer ID", DBColumn: "customer_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "Customer name", DBColumn: "customer_name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
 			{Name: "Overall VAT ID validation status", DBColumn: "overall_vat_id_status", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Valid VAT IDs", DBColumn: "valid_vat_ids", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Other VAT IDs", DBColumn: "other_vat_ids", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "Valid VAT IDs", DBColumn: "valid_vat_ids", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "Other VAT IDs", DBColumn: "other_vat_ids", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "Invoice date", DBColumn: "invoice_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "Tax date", DBColumn: "tax_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "Transaction currency", DBColumn: "transaction_currency", Type: core.FieldText, Required: false, AllowEmpty: true},
@@ -36,10 +36,10 @@ func registerAnrokTransactions() {
 			{Name: "Tax amount", DBColumn: "tax_amount", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
 			{Name: "Invoice amount", DBColumn: "invoice_amount", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
 			{Name: "Void", DBColumn: "void", Type: core.FieldBool, Required: false, AllowEmpty: true},
-			{Name: "Customer address line 1", DBColumn: "customer_address_line_1", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Customer address city", DBColumn: "customer_address_city", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "Customer address line 1", DBColumn: "customer_address_line_1", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskRedact},
+			{Name: "Customer address city", DBColumn: "customer_address_city", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskRedact},
 			{Name: "Customer address region", DBColumn: "customer_address_region", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "Customer address postal code", DBColumn: "customer_address_postal_code", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "Customer address postal code", DBColumn: "customer_address_postal_code", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "Customer address country", DBColumn: "customer_address_country", Type: core.FieldText, Required: false, AllowEmpty: true},
 			{Name: "Customer country code", DBColumn: "customer_country_code", Type: core.FieldText, Required: false, AllowEmpty: true},
 			{Name: "Jurisdictions", DBColumn: "jurisdictions", Type: core.FieldText, Required: false, AllowEmpty: true},
@@ -93,6 +93,11 @@ func registerAnrokTransactions() {
 			"customer_address_postal_code", "customer_address_country", "customer_country_code",
 			"jurisdictions", "jurisdiction_ids", "return_ids", "upload_id",
 		},
+		// Address columns are hierarchical; country code follows from country
+		Statistics: []core.ExtendedStatistics{
+			{Columns: []string{"customer_address_country", "customer_address_region", "customer_address_city"}},
+			{Columns: []string{"customer_address_country", "customer_country_code"}, Kinds: []string{"dependencies"}},
+		},
 		CopyRow: func(params any) []any {
 			p := params.(db.InsertAnrokTransactionParams)
 			return []any{
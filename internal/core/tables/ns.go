@@ -24,11 +24,11 @@ func registerNsCustomers() {
 			UniqueKey: []string{"internal_id"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "salesforce_id_io", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "internal_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "name", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "salesforce_id_io", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "internal_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
 			{Name: "duplicate", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "company_name", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "company_name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
 			{Name: "balance", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
 			{Name: "unbilled_orders", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
 			{Name: "overdue_balance", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
@@ -82,12 +82,12 @@ func registerNsSoDetail() {
 			UniqueKey: []string{"sfdc_opp_id", "sfdc_opp_line_id"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "sfdc_opp_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "sfdc_opp_line_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "customer_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "sfdc_opp_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "sfdc_opp_line_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "customer_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "product_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "customer_project", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "so_number", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "customer_project", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskRedact},
+			{Name: "so_number", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "document_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "start_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "end_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
@@ -138,6 +138,11 @@ func registerNsSoDetail() {
 			"item_name", "item_display_name", "line_start_date", "line_end_date",
 			"quantity", "unit_price", "amount_gross", "terms_days_till_net_due", "upload_id",
 		},
+		// Each product has one item name; customers buy a narrow set of products
+		Statistics: []core.ExtendedStatistics{
+			{Columns: []string{"product_internal_id", "item_name"}, Kinds: []string{"dependencies"}},
+			{Columns: []string{"customer_internal_id", "product_internal_id"}, Kinds: []string{"ndistinct", "mcv"}},
+		},
 		CopyRow: func(params any) []any {
 			p := params.(db.InsertNsSoDetailParams)
 			return []any{
@@ -160,17 +165,17 @@ func registerNsInvoiceDetail() {
 			UniqueKey: []string{"sfdc_opp_id", "sfdc_opp_line_id"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "sfdc_opp_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "sfdc_opp_line_id", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "sfdc_opp_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "sfdc_opp_line_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "sfdc_pricebook_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "customer_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "customer_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
 			{Name: "product_internal_id", Type: core.FieldText, Required: false, AllowEmpty: true},
 			{Name: "type", Type: core.FieldText, Required: false, AllowEmpty: true},
 			{Name: "date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "date_due", Type: core.FieldDate, Required: false, AllowEmpty: true},
-			{Name: "document_number", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "name", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "memo", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "document_number", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
+			{Name: "memo", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskRedact},
 			{Name: "item", Type: core.FieldText, Required: false, AllowEmpty: true},
 			{Name: "qty", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
 			{Name: "contract_quantity", Type: core.FieldNumeric, Required: false, AllowEmpty: true},
@@ -179,7 +184,7 @@ func registerNsInvoiceDetail() {
 			{Name: "start_date_line", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "end_date_line_level", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "account", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "shipping_address_city", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "shipping_address_city", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskRedact},
 			{Name: "shipping_address_state", Type: core.FieldText, Required: false, AllowEmpty: true, Normalizer: NormalizeUsState},
 			{Name: "shipping_address_country", Type: core.FieldText, Required: false, AllowEmpty: true},
 		},
@@ -227,6 +232,11 @@ func registerNsInvoiceDetail() {
 			"start_date_line", "end_date_line_level", "account",
 			"shipping_address_city", "shipping_address_state", "shipping_address_country", "upload_id",
 		},
+		// Shipping address columns are hierarchical; customers buy a narrow set of products
+		Statistics: []core.ExtendedStatistics{
+			{Columns: []string{"shipping_address_country", "shipping_address_state", "shipping_address_city"}},
+			{Columns: []string{"customer_internal_id", "product_internal_id"}, Kinds: []string{"ndistinct", "mcv"}},
+		},
 		CopyRow: func(params any) []any {
 			p := params.(db.InsertNsInvoiceDetailParams)
 			return []any{
@@ -25,8 +25,8 @@ func registerSfdcCustomers() {
 			UniqueKey: []string{"account_id_casesafe"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "account_id_casesafe", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "account_name", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "account_id_casesafe", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "account_name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
 			{Name: "last_activity", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "type", Type: core.FieldText, Required: false, AllowEmpty: true},
 		},
@@ -115,10 +115,10 @@ func registerSfdcOppDetail() {
 			UniqueKey: []string{"opportunity_product_casesafe_id"},
 		},
 		FieldSpecs: []core.FieldSpec{
-			{Name: "opportunity_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "opportunity_product_casesafe_id", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "opportunity_name", Type: core.FieldText, Required: false, AllowEmpty: true},
-			{Name: "account_name", Type: core.FieldText, Required: false, AllowEmpty: true},
+			{Name: "opportunity_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "opportunity_product_casesafe_id", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskID},
+			{Name: "opportunity_name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
+			{Name: "account_name", Type: core.FieldText, Required: false, AllowEmpty: true, Mask: core.MaskName},
 			{Name: "close_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "booked_date", Type: core.FieldDate, Required: false, AllowEmpty: true},
 			{Name: "fiscal_period", Type: core.FieldText, Required: false, AllowEmpty: true},
@@ -0,0 +1,51 @@
+package tables
+
+import (
+	"github.com/JonMunkholm/TUI/internal/core"
+)
+
+func init() {
+	registerSoOppLines()
+}
+
+// registerSoOppLines joins NetSuite SO lines to the Salesforce opportunity
+// lines they were booked from, to reconcile amounts without exporting both.
+func registerSoOppLines() {
+	core.Register(core.TableDefinition{
+		Info: core.TableInfo{
+			Key:       "so_opp_lines",
+			Group:     "Views",
+			Label:     "SO vs Opp Lines",
+			UniqueKey: []string{"sfdc_opp_id", "sfdc_opp_line_id"},
+		},
+		FieldSpecs: []core.FieldSpec{
+			{Name: "sfdc_opp_id", Type: core.FieldText, Mask: core.MaskID},
+			{Name: "sfdc_opp_line_id", Type: core.FieldText, Mask: core.MaskID},
+			{Name: "so_number", Type: core.FieldText, Mask: core.MaskID},
+			{Name: "opportunity_name", Type: core.FieldText, Mask: core.MaskName},
+			{Name: "account_name", Type: core.FieldText, Mask: core.MaskName},
+			{Name: "item_name", Type: core.FieldText},
+			{Name: "product_name", Type: core.FieldText},
+			{Name: "document_date", Type: core.FieldDate},
+			{Name: "close_date", Type: core.FieldDate},
+			{Name: "so_amount", Type: core.FieldNumeric},
+			{Name: "opp_amount", Type: core.FieldNumeric},
+			{Name: "amount_difference", Type: core.FieldNumeric},
+		},
+		View: `SELECT
+	so.sfdc_opp_id,
+	so.sfdc_opp_line_id,
+	so.so_number,
+	opp.opportunity_name,
+	opp.account_name,
+	so.item_name,
+	opp.product_name,
+	so.document_date,
+	opp.close_date,
+	so.amount_gross AS so_amount,
+	opp.amount AS opp_amount,
+	so.amount_gross - opp.amount AS amount_difference
+FROM ns_so_detail so
+LEFT JOIN sfdc_opp_detail opp ON opp.opportunity_product_casesafe_id = so.sfdc_opp_line_id`,
+	})
+}
@@ -0,0 +1,279 @@
+package core
+
+// template_drift.go detects when a file uploaded with an import template no
+// longer has the headers the template was saved with.
+//
+// A template maps table columns to CSV positions. When a recurring vendor
+// file gains, loses or renames a column, those positions point at the wrong
+// data or past the end of the row, and the affected fields are left empty
+// without any error. Comparing the file's header row with the template's
+// saved CSVHeaders catches this before rows are inserted.
+//
+// UPLOAD_TEMPLATE_DRIFT decides what happens: "warn" (default) uploads as
+// usual and reports the drift in the result, "block" fails the upload when
+// a mapped column is affected, and "off" skips the check. Either way the
+// drift carries a suggested mapping that re-points each column at its
+// header's new position.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// ErrTemplateDrift is returned when a file's headers no longer match the
+// import template it was uploaded with and UPLOAD_TEMPLATE_DRIFT is "block".
+var ErrTemplateDrift = errors.New("template drift")
+
+// Template drift policies (UPLOAD_TEMPLATE_DRIFT).
+const (
+	TemplateDriftWarn  = "warn"
+	TemplateDriftBlock = "block"
+	TemplateDriftOff   = "off"
+)
+
+// HeaderRename is a template header replaced by a new one at the same position.
+type HeaderRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// TemplateDrift describes how a file's header row differs from the headers
+// its import template was saved with.
+type TemplateDrift struct {
+	TemplateID       string         `json:"templateId"`
+	TemplateName     string         `json:"templateName"`
+	Added            []string       `json:"added,omitempty"`    // Headers the template has not seen
+	Removed          []string       `json:"removed,omitempty"`  // Template headers missing from the file
+	Moved            []string       `json:"moved,omitempty"`    // Template headers at a new position
+	Renamed          []HeaderRename `json:"renamed,omitempty"`  // Removed headers replaced at the same position
+	Affected         []string       `json:"affected,omitempty"` // Mapped table columns that would read the wrong data
+	SuggestedMapping map[string]int `json:"suggestedMapping"`
+	Unmapped         []string       `json:"unmapped,omitempty"` // Mapped columns the suggestion could not place
+	Message          string         `json:"message"`
+}
+
+// Breaking reports whether the drift changes what a mapped column reads.
+func (d *TemplateDrift) Breaking() bool {
+	return len(d.Affected) > 0
+}
+
+const ctxKeyTemplate contextKey = "upload_template"
+
+// ContextWithTemplate records the import template an upload's column
+// mapping came from, so the upload can check the file against the headers
+// the template was saved with. Only set it when the template supplied the
+// mapping; an explicit mapping from the request is trusted as given.
+func ContextWithTemplate(ctx context.Context, t *ImportTemplate) context.Context {
+	return context.WithValue(ctx, ctxKeyTemplate, t)
+}
+
+// templateFromContext returns the template set by ContextWithTemplate, or nil.
+func templateFromContext(ctx context.Context) *ImportTemplate {
+	t, _ := ctx.Value(ctxKeyTemplate).(*ImportTemplate)
+	return t
+}
+
+// templateDriftPolicy returns UPLOAD_TEMPLATE_DRIFT, defaulting to warn.
+func (s *Service) templateDriftPolicy() string {
+	if s.cfg == nil || s.cfg.Upload.TemplateDrift == "" {
+		return TemplateDriftWarn
+	}
+	return s.cfg.Upload.TemplateDrift
+}
+
+// checkTemplateDrift compares header with the headers t was saved with. It
+// returns the drift to report, or an ErrTemplateDrift error if the policy
+// blocks it. Both are nil when t is nil, has no saved headers, or matches.
+func (s *Service) checkTemplateDrift(t *ImportTemplate, tableKey string, header []string) (*TemplateDrift, error) {
+	if t == nil {
+		return nil, nil
+	}
+	policy := s.templateDriftPolicy()
+	if policy == TemplateDriftOff {
+		return nil, nil
+	}
+	drift := detectTemplateDrift(t, header)
+	if drift == nil {
+		return nil, nil
+	}
+	if policy == TemplateDriftBlock && drift.Breaking() {
+		return drift, fmt.Errorf("%w: %s; suggested mapping: %s", ErrTemplateDrift, drift.Message, formatMapping(drift.SuggestedMapping))
+	}
+	slog.Warn("upload headers differ from import template",
+		"table", tableKey,
+		"template", t.Name,
+		"added", drift.Added,
+		"removed", drift.Removed,
+		"moved", drift.Moved,
+		"affected", drift.Affected,
+	)
+	return drift, nil
+}
+
+// detectTemplateDrift compares a file's header row with t.CSVHeaders.
+// Headers match case-insensitively, ignoring surrounding spaces. It
+// returns nil if they match or t has no saved headers.
+func detectTemplateDrift(t *ImportTemplate, header []string) *TemplateDrift {
+	if len(t.CSVHeaders) == 0 {
+		return nil
+	}
+
+	filePos := headerPositions(header)
+	tplPos := headerPositions(t.CSVHeaders)
+
+	d := &TemplateDrift{TemplateID: t.ID, TemplateName: t.Name}
+	for i, h := range header {
+		if key := headerKey(h); key != "" && filePos[key] == i {
+			if _, ok := tplPos[key]; !ok {
+				d.Added = append(d.Added, h)
+			}
+		}
+	}
+	renamed := make(map[int]bool) // Template positions whose header was replaced
+	for i, h := range t.CSVHeaders {
+		key := headerKey(h)
+		if key == "" || tplPos[key] != i {
+			continue
+		}
+		pos, ok := filePos[key]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, h)
+			if i < len(header) {
+				if newKey := headerKey(header[i]); newKey != "" && filePos[newKey] == i {
+					if _, known := tplPos[newKey]; !known {
+						d.Renamed = append(d.Renamed, HeaderRename{From: h, To: header[i]})
+						renamed[i] = true
+					}
+				}
+			}
+		case pos != i:
+			d.Moved = append(d.Moved, h)
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0 {
+		return nil
+	}
+
+	d.SuggestedMapping = make(map[string]int, len(t.ColumnMapping))
+	cols := make([]string, 0, len(t.ColumnMapping))
+	for col := range t.ColumnMapping {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		idx := t.ColumnMapping[col]
+		pos, found := -1, false
+		if idx >= 0 && idx < len(t.CSVHeaders) {
+			if p, ok := filePos[headerKey(t.CSVHeaders[idx])]; ok {
+				pos, found = p, true
+			} else if renamed[idx] {
+				pos, found = idx, true
+			}
+		}
+		if !found {
+			pos, found = filePos[headerKey(col)]
+		}
+		if !found {
+			d.Unmapped = append(d.Unmapped, col)
+			d.Affected = append(d.Affected, col)
+			continue
+		}
+		d.SuggestedMapping[col] = pos
+		if pos != idx {
+			d.Affected = append(d.Affected, col)
+		}
+	}
+	d.Message = driftMessage(d)
+	return d
+}
+
+// driftMessage summarizes a drift in one sentence for logs and errors.
+func driftMessage(d *TemplateDrift) string {
+	var parts []string
+	if len(d.Renamed) > 0 {
+		renames := make([]string, len(d.Renamed))
+		for i, r := range d.Renamed {
+			renames[i] = fmt.Sprintf("%q is now %q", r.From, r.To)
+		}
+		parts = append(parts, "renamed "+strings.Join(renames, ", "))
+	}
+	if missing := withoutRenames(d.Removed, d.Renamed, false); len(missing) > 0 {
+		parts = append(parts, "missing "+quoteList(missing))
+	}
+	if added := withoutRenames(d.Added, d.Renamed, true); len(added) > 0 {
+		parts = append(parts, "new "+quoteList(added))
+	}
+	if len(d.Moved) > 0 {
+		parts = append(parts, "moved "+quoteList(d.Moved))
+	}
+	msg := fmt.Sprintf("file headers differ from template %q: %s", d.TemplateName, strings.Join(parts, "; "))
+	if len(d.Affected) > 0 {
+		msg += fmt.Sprintf(" (affects %s)", strings.Join(d.Affected, ", "))
+	}
+	return msg
+}
+
+// withoutRenames returns headers less the old (or, if to, the new) side of renames.
+func withoutRenames(headers []string, renames []HeaderRename, to bool) []string {
+	skip := make(map[string]bool, len(renames))
+	for _, r := range renames {
+		if to {
+			skip[r.To] = true
+		} else {
+			skip[r.From] = true
+		}
+	}
+	var out []string
+	for _, h := range headers {
+		if !skip[h] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// headerPositions maps each normalized header to its first position.
+func headerPositions(headers []string) map[string]int {
+	pos := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if key := headerKey(h); key != "" {
+			if _, dup := pos[key]; !dup {
+				pos[key] = i
+			}
+		}
+	}
+	return pos
+}
+
+// headerKey normalizes a header for comparison, as template matching does.
+func headerKey(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+// quoteList formats headers as a comma-separated list of quoted strings.
+func quoteList(headers []string) string {
+	quoted := make([]string, len(headers))
+	for i, h := range headers {
+		quoted[i] = fmt.Sprintf("%q", h)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// formatMapping formats a column mapping as "col=idx" pairs in column order.
+func formatMapping(m map[string]int) string {
+	cols := make([]string, 0, len(m))
+	for col := range m {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	pairs := make([]string, len(cols))
+	for i, col := range cols {
+		pairs[i] = fmt.Sprintf("%s=%d", col, m[col])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func driftTemplate() *ImportTemplate {
+	return &ImportTemplate{
+		ID:            "tpl-1",
+		TableKey:      "vendor_bills",
+		Name:          "Vendor export",
+		CSVHeaders:    []string{"Vendor", "Bill No", "Amount", "Memo"},
+		ColumnMapping: map[string]int{"vendor_name": 0, "bill_number": 1, "amount": 2},
+	}
+}
+
+func TestDetectTemplateDrift(t *testing.T) {
+	tests := []struct {
+		name         string
+		saved        []string // Template headers, if not driftTemplate's
+		header       []string
+		wantNil      bool
+		wantAdded    []string
+		wantRemoved  []string
+		wantMoved    []string
+		wantRenamed  []HeaderRename
+		wantAffected []string
+		wantMapping  map[string]int
+		wantUnmapped []string
+	}{
+		{
+			name:    "same headers",
+			header:  []string{"Vendor", "Bill No", "Amount", "Memo"},
+			wantNil: true,
+		},
+		{
+			name:    "case and spacing ignored",
+			header:  []string{" vendor ", "BILL NO", "amount", "Memo"},
+			wantNil: true,
+		},
+		{
+			name:        "column appended",
+			header:      []string{"Vendor", "Bill No", "Amount", "Memo", "Currency"},
+			wantAdded:   []string{"Currency"},
+			wantMapping: map[string]int{"vendor_name": 0, "bill_number": 1, "amount": 2},
+		},
+		{
+			name:         "column inserted",
+			header:       []string{"Vendor", "Bill Date", "Bill No", "Amount", "Memo"},
+			wantAdded:    []string{"Bill Date"},
+			wantMoved:    []string{"Bill No", "Amount", "Memo"},
+			wantAffected: []string{"amount", "bill_number"},
+			wantMapping:  map[string]int{"vendor_name": 0, "bill_number": 2, "amount": 3},
+		},
+		{
+			name:        "column renamed in place",
+			header:      []string{"Vendor", "Invoice No", "Amount", "Memo"},
+			wantAdded:   []string{"Invoice No"},
+			wantRemoved: []string{"Bill No"},
+			wantRenamed: []HeaderRename{{From: "Bill No", To: "Invoice No"}},
+			wantMapping: map[string]int{"vendor_name": 0, "bill_number": 1, "amount": 2},
+		},
+		{
+			name:         "mapped column removed",
+			header:       []string{"Vendor", "Bill No", "Memo"},
+			wantRemoved:  []string{"Amount"},
+			wantMoved:    []string{"Memo"},
+			wantAffected: []string{"amount"},
+			wantMapping:  map[string]int{"vendor_name": 0, "bill_number": 1},
+			wantUnmapped: []string{"amount"},
+		},
+		{
+			name:         "falls back to the column name",
+			saved:        []string{"Vendor", "Bill No", "Amount Due", "Memo"},
+			header:       []string{"Vendor", "Bill No", "Memo", "amount"},
+			wantAdded:    []string{"amount"},
+			wantRemoved:  []string{"Amount Due"},
+			wantMoved:    []string{"Memo"},
+			wantAffected: []string{"amount"},
+			wantMapping:  map[string]int{"vendor_name": 0, "bill_number": 1, "amount": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl := driftTemplate()
+			if tt.saved != nil {
+				tpl.CSVHeaders = tt.saved
+			}
+			d := detectTemplateDrift(tpl, tt.header)
+			if tt.wantNil {
+				if d != nil {
+					t.Fatalf("detectTemplateDrift() = %+v, want nil", d)
+				}
+				return
+			}
+			if d == nil {
+				t.Fatal("detectTemplateDrift() = nil, want drift")
+			}
+			check := func(field string, got, want interface{}) {
+				t.Helper()
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+			check("Added", d.Added, tt.wantAdded)
+			check("Removed", d.Removed, tt.wantRemoved)
+			check("Moved", d.Moved, tt.wantMoved)
+			check("Renamed", d.Renamed, tt.wantRenamed)
+			check("Affected", d.Affected, tt.wantAffected)
+			check("SuggestedMapping", d.SuggestedMapping, tt.wantMapping)
+			check("Unmapped", d.Unmapped, tt.wantUnmapped)
+			if d.TemplateID != "tpl-1" || d.TemplateName != "Vendor export" {
+				t.Errorf("template = %q %q, want tpl-1 Vendor export", d.TemplateID, d.TemplateName)
+			}
+		})
+	}
+}
+
+func TestDetectTemplateDrift_NoSavedHeaders(t *testing.T) {
+	tpl := driftTemplate()
+	tpl.CSVHeaders = nil
+	if d := detectTemplateDrift(tpl, []string{"Anything"}); d != nil {
+		t.Errorf("detectTemplateDrift() = %+v, want nil without saved headers", d)
+	}
+}
+
+func TestTemplateDriftMessage(t *testing.T) {
+	d := detectTemplateDrift(driftTemplate(), []string{"Vendor", "Invoice No", "Memo", "Currency"})
+	if d == nil {
+		t.Fatal("detectTemplateDrift() = nil, want drift")
+	}
+	for _, want := range []string{
+		`template "Vendor export"`,
+		`renamed "Bill No" is now "Invoice No"`,
+		`missing "Amount"`,
+		`new "Currency"`,
+		`moved "Memo"`,
+		"(affects amount)",
+	} {
+		if !strings.Contains(d.Message, want) {
+			t.Errorf("Message = %q, want it to contain %q", d.Message, want)
+		}
+	}
+}
+
+func TestCheckTemplateDrift(t *testing.T) {
+	inserted := []string{"Vendor", "Bill Date", "Bill No", "Amount", "Memo"}
+	appended := []string{"Vendor", "Bill No", "Amount", "Memo", "Currency"}
+
+	tests := []struct {
+		name      string
+		policy    string
+		header    []string
+		wantDrift bool
+		wantErr   bool
+	}{
+		{name: "default warns", policy: "", header: inserted, wantDrift: true},
+		{name: "warn", policy: TemplateDriftWarn, header: inserted, wantDrift: true},
+		{name: "block", policy: TemplateDriftBlock, header: inserted, wantDrift: true, wantErr: true},
+		{name: "block lets harmless drift through", policy: TemplateDriftBlock, header: appended, wantDrift: true},
+		{name: "off", policy: TemplateDriftOff, header: inserted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{cfg: &config.Config{Upload: config.UploadConfig{TemplateDrift: tt.policy}}}
+			drift, err := s.checkTemplateDrift(driftTemplate(), "vendor_bills", tt.header)
+			if (drift != nil) != tt.wantDrift {
+				t.Errorf("drift = %+v, want drift %v", drift, tt.wantDrift)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrTemplateDrift) {
+					t.Errorf("err = %v, want ErrTemplateDrift", err)
+				}
+				if !strings.Contains(err.Error(), "suggested mapping: amount=3, bill_number=2, vendor_name=0") {
+					t.Errorf("err = %v, want the suggested mapping", err)
+				}
+			}
+		})
+	}
+
+	s := &Service{cfg: &config.Config{}}
+	if drift, err := s.checkTemplateDrift(nil, "vendor_bills", inserted); drift != nil || err != nil {
+		t.Errorf("checkTemplateDrift(nil) = %v, %v; want nil, nil", drift, err)
+	}
+}
+
+func TestTemplateFromContext(t *testing.T) {
+	if got := templateFromContext(context.Background()); got != nil {
+		t.Errorf("templateFromContext() = %v, want nil", got)
+	}
+	tpl := driftTemplate()
+	if got := templateFromContext(ContextWithTemplate(context.Background(), tpl)); got != tpl {
+		t.Errorf("templateFromContext() = %v, want %v", got, tpl)
+	}
+}
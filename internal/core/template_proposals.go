@@ -0,0 +1,304 @@
+package core
+
+// template_proposals.go proposes import templates from a table's upload
+// history, so mappings users keep entering by hand can be saved once.
+//
+// Uploads record the header row of each file (csv_headers). Files whose
+// header rows are the same, compared as template matching compares them,
+// make one proposal. The mapping itself is not stored with an upload, so a
+// proposal's is rebuilt from the headers: each table column is mapped to
+// the header with its name or one of its old names (see Renames), or
+// failing that, the header that equals one of them ignoring case, spaces
+// and punctuation ("Invoice Date" and "invoice_date"). Columns no header
+// matched are listed, to be mapped by hand after saving.
+//
+// A header row some saved template already has is marked with that
+// template and is never saved again.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrUnknownProposal is returned for a signature that matches no template
+// proposal.
+var ErrUnknownProposal = errors.New("unknown template proposal")
+
+// DefaultProposalMinUploads is how many uploads must share a header row
+// for it to be proposed, unless the caller asks for another number.
+const DefaultProposalMinUploads = 2
+
+// TemplateProposal is an import template suggested by past uploads that
+// shared a header row.
+type TemplateProposal struct {
+	Signature        string         `json:"signature"` // Identifies the header row
+	Name             string         `json:"name"`      // Name the template is saved under
+	CSVHeaders       []string       `json:"csvHeaders"`
+	ColumnMapping    map[string]int `json:"columnMapping"`
+	Unmapped         []string       `json:"unmapped,omitempty"` // Table columns no header matched
+	Uploads          int            `json:"uploads"`
+	LastFileName     string         `json:"lastFileName,omitempty"`
+	LastUploadAt     time.Time      `json:"lastUploadAt"`
+	ExistingTemplate string         `json:"existingTemplate,omitempty"` // Saved template with these headers
+}
+
+// headerUsage is one distinct header row in a table's upload history.
+type headerUsage struct {
+	headers  []string
+	uploads  int
+	lastAt   time.Time
+	lastFile string
+}
+
+// ProposeTemplates returns an import template for each header row at least
+// minUploads of the table's uploads had (DefaultProposalMinUploads if 0),
+// most used first. Rolled-back uploads are not counted.
+func (s *Service) ProposeTemplates(ctx context.Context, tableKey string, minUploads int) ([]TemplateProposal, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if minUploads <= 0 {
+		minUploads = DefaultProposalMinUploads
+	}
+
+	rows, err := s.pool.Query(ctx, `
+SELECT csv_headers, COUNT(*), MAX(uploaded_at),
+       (array_agg(COALESCE(file_name, '') ORDER BY uploaded_at DESC))[1]
+FROM csv_uploads
+WHERE name = $1 AND action = 'upload' AND status IS DISTINCT FROM 'rolled_back'
+  AND cardinality(csv_headers) > 0
+GROUP BY csv_headers`, tableKey)
+	if err != nil {
+		return nil, fmt.Errorf("query upload headers: %w", err)
+	}
+	defer rows.Close()
+
+	var history []headerUsage
+	for rows.Next() {
+		var (
+			u      headerUsage
+			lastAt pgtype.Timestamp
+		)
+		if err := rows.Scan(&u.headers, &u.uploads, &lastAt, &u.lastFile); err != nil {
+			return nil, fmt.Errorf("scan upload headers: %w", err)
+		}
+		u.lastAt = lastAt.Time
+		history = append(history, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	templates, err := s.ListTemplates(ctx, tableKey)
+	if err != nil {
+		return nil, err
+	}
+	return proposeTemplates(def, history, templates, minUploads), nil
+}
+
+// CreateProposedTemplates saves the proposals with the given signatures as
+// import templates, or every proposal if signatures is empty. Proposals a
+// saved template already covers are skipped.
+func (s *Service) CreateProposedTemplates(ctx context.Context, tableKey string, signatures []string, minUploads int) ([]ImportTemplate, error) {
+	proposals, err := s.ProposeTemplates(ctx, tableKey, minUploads)
+	if err != nil {
+		return nil, err
+	}
+
+	bySignature := make(map[string]TemplateProposal, len(proposals))
+	for _, p := range proposals {
+		bySignature[p.Signature] = p
+	}
+	if len(signatures) > 0 {
+		chosen := make([]TemplateProposal, 0, len(signatures))
+		for _, sig := range signatures {
+			p, ok := bySignature[sig]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownProposal, sig)
+			}
+			chosen = append(chosen, p)
+		}
+		proposals = chosen
+	}
+
+	created := []ImportTemplate{}
+	for _, p := range proposals {
+		if p.ExistingTemplate != "" {
+			continue
+		}
+		t, err := s.CreateTemplate(ctx, tableKey, p.Name, p.ColumnMapping, p.CSVHeaders, nil)
+		if err != nil {
+			return created, fmt.Errorf("create template %q: %w", p.Name, err)
+		}
+		created = append(created, *t)
+	}
+	return created, nil
+}
+
+// proposeTemplates groups a table's header rows by signature and proposes
+// a template for each used at least minUploads times. Header rows none of
+// the table's columns can be mapped from are left out.
+func proposeTemplates(def TableDefinition, history []headerUsage, templates []ImportTemplate, minUploads int) []TemplateProposal {
+	grouped := make(map[string]*headerUsage)
+	var order []string
+	for _, u := range history {
+		sig := headerSignature(u.headers)
+		g, ok := grouped[sig]
+		if !ok {
+			u := u
+			grouped[sig] = &u
+			order = append(order, sig)
+			continue
+		}
+		g.uploads += u.uploads
+		if u.lastAt.After(g.lastAt) {
+			g.headers, g.lastAt, g.lastFile = u.headers, u.lastAt, u.lastFile
+		}
+	}
+
+	existing := make(map[string]string, len(templates))
+	names := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		names[strings.ToLower(t.Name)] = true
+		if t.FixedWidth == nil && len(t.CSVHeaders) > 0 {
+			existing[headerSignature(t.CSVHeaders)] = t.Name
+		}
+	}
+
+	proposals := []TemplateProposal{}
+	for _, sig := range order {
+		g := grouped[sig]
+		if g.uploads < minUploads {
+			continue
+		}
+		mapping, unmapped := proposeMapping(def, g.headers)
+		if len(mapping) == 0 {
+			continue
+		}
+		proposals = append(proposals, TemplateProposal{
+			Signature:        sig,
+			CSVHeaders:       g.headers,
+			ColumnMapping:    mapping,
+			Unmapped:         unmapped,
+			Uploads:          g.uploads,
+			LastFileName:     g.lastFile,
+			LastUploadAt:     g.lastAt,
+			ExistingTemplate: existing[sig],
+		})
+	}
+
+	sort.SliceStable(proposals, func(i, j int) bool {
+		if proposals[i].Uploads != proposals[j].Uploads {
+			return proposals[i].Uploads > proposals[j].Uploads
+		}
+		return proposals[i].LastUploadAt.After(proposals[j].LastUploadAt)
+	})
+	for i := range proposals {
+		proposals[i].Name = proposalName(proposals[i].LastFileName, names)
+	}
+	return proposals
+}
+
+// headerSignature identifies a header row, normalized with headerKey.
+func headerSignature(headers []string) string {
+	keys := make([]string, len(headers))
+	for i, h := range headers {
+		keys[i] = headerKey(h)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// proposeMapping maps each of the table's columns to a header by name, and
+// returns the columns it could not map. No header is mapped twice.
+func proposeMapping(def TableDefinition, headers []string) (map[string]int, []string) {
+	exact := headerPositions(headers)
+	loose := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if key := looseHeaderKey(h); key != "" {
+			if _, dup := loose[key]; !dup {
+				loose[key] = i
+			}
+		}
+	}
+
+	mapping := make(map[string]int)
+	used := make(map[int]bool)
+	var unmapped []string
+	for _, spec := range def.FieldSpecs {
+		names := []string{spec.Name}
+		for _, r := range def.Renames {
+			if strings.EqualFold(r.To, spec.Name) {
+				names = append(names, r.From)
+			}
+		}
+
+		idx, found := -1, false
+		for _, name := range names {
+			if i, ok := exact[headerKey(name)]; ok && !used[i] {
+				idx, found = i, true
+				break
+			}
+		}
+		if !found {
+			for _, name := range append(names, spec.DBColumn) {
+				if i, ok := loose[looseHeaderKey(name)]; ok && !used[i] {
+					idx, found = i, true
+					break
+				}
+			}
+		}
+		if !found {
+			unmapped = append(unmapped, spec.Name)
+			continue
+		}
+		mapping[spec.Name] = idx
+		used[idx] = true
+	}
+	return mapping, unmapped
+}
+
+// looseHeaderKey normalizes a header ignoring case, spaces and punctuation.
+func looseHeaderKey(h string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, h)
+}
+
+// proposalSuffix matches the dates and sequence numbers that vary between
+// files of one feed ("vendor_invoices_2024-03.csv").
+var proposalSuffix = regexp.MustCompile(`[\s_\-.]*[\d\s_\-.]+$`)
+
+// proposalName names a proposal after the file it was last seen in,
+// without extension or trailing date, made unique among taken names.
+func proposalName(fileName string, taken map[string]bool) string {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	base = strings.TrimSpace(proposalSuffix.ReplaceAllString(base, ""))
+	if base == "" {
+		base = "Proposed template"
+	}
+	name := base
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s (%d)", base, n)
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func proposalTestTable() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "invoices"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice Number", Type: FieldText},
+			{Name: "Invoice Date", Type: FieldDate},
+			{Name: "Amount", Type: FieldNumeric, DBColumn: "amount_usd"},
+		},
+		Renames: []ColumnRename{{From: "Total", To: "Amount"}},
+	}
+}
+
+func TestProposeMapping(t *testing.T) {
+	def := proposalTestTable()
+	tests := []struct {
+		headers      []string
+		wantMapping  map[string]int
+		wantUnmapped []string
+	}{
+		{
+			[]string{"Invoice Number", "Invoice Date", "Amount"},
+			map[string]int{"Invoice Number": 0, "Invoice Date": 1, "Amount": 2},
+			nil,
+		},
+		{
+			// Old name, loose match and DB column name
+			[]string{"Total", "Memo", "INVOICE_NUMBER", "invoice-date"},
+			map[string]int{"Invoice Number": 2, "Invoice Date": 3, "Amount": 0},
+			nil,
+		},
+		{
+			[]string{"amount_usd", "Invoice #"},
+			map[string]int{"Amount": 0},
+			[]string{"Invoice Number", "Invoice Date"},
+		},
+	}
+	for _, tt := range tests {
+		mapping, unmapped := proposeMapping(def, tt.headers)
+		if !reflect.DeepEqual(mapping, tt.wantMapping) || !reflect.DeepEqual(unmapped, tt.wantUnmapped) {
+			t.Errorf("%v: mapping = %v, unmapped = %v; want %v, %v", tt.headers, mapping, unmapped, tt.wantMapping, tt.wantUnmapped)
+		}
+	}
+}
+
+func TestProposeTemplates(t *testing.T) {
+	def := proposalTestTable()
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	history := []headerUsage{
+		{headers: []string{"Invoice Number", "Invoice Date", "Amount"}, uploads: 2, lastAt: day(1), lastFile: "vendor_2024-02.csv"},
+		// Same header row, spelled differently
+		{headers: []string{" invoice number", "INVOICE DATE", "amount"}, uploads: 1, lastAt: day(5), lastFile: "vendor_2024-03.csv"},
+		{headers: []string{"Total", "Invoice Number"}, uploads: 2, lastAt: day(3), lastFile: "legacy export 7.xlsx"},
+		{headers: []string{"Memo", "Notes"}, uploads: 9, lastAt: day(4), lastFile: "notes.csv"}, // Maps nothing
+		{headers: []string{"Amount"}, uploads: 1, lastAt: day(6), lastFile: "once.csv"},
+	}
+	templates := []ImportTemplate{
+		{Name: "Legacy", CSVHeaders: []string{"total", "invoice number"}},
+		{Name: "vendor"},
+	}
+
+	got := proposeTemplates(def, history, templates, 2)
+	if len(got) != 2 {
+		t.Fatalf("got %d proposals: %+v", len(got), got)
+	}
+
+	vendor := got[0]
+	if vendor.Uploads != 3 || vendor.LastFileName != "vendor_2024-03.csv" || !vendor.LastUploadAt.Equal(day(5)) {
+		t.Errorf("vendor proposal = %+v", vendor)
+	}
+	if vendor.Name != "vendor (2)" || vendor.ExistingTemplate != "" || len(vendor.ColumnMapping) != 3 {
+		t.Errorf("vendor proposal = %+v", vendor)
+	}
+	if vendor.Signature != headerSignature([]string{"invoice number", "invoice date", "amount"}) {
+		t.Errorf("signature %s does not ignore case and spaces", vendor.Signature)
+	}
+
+	legacy := got[1]
+	if legacy.Name != "legacy export" || legacy.ExistingTemplate != "Legacy" {
+		t.Errorf("legacy proposal = %+v", legacy)
+	}
+	if !reflect.DeepEqual(legacy.Unmapped, []string{"Invoice Date"}) {
+		t.Errorf("legacy unmapped = %v", legacy.Unmapped)
+	}
+}
+
+func TestProposalName(t *testing.T) {
+	taken := map[string]bool{"ar aging": true}
+	tests := []struct{ file, want string }{
+		{"AR Aging 2024-03-31.csv", "AR Aging (2)"},
+		{"ar_aging_20240430.csv", "ar_aging"},
+		{"2024-05.csv", "Proposed template"},
+		{"", "Proposed template (2)"},
+	}
+	for _, tt := range tests {
+		if got := proposalName(tt.file, taken); got != tt.want {
+			t.Errorf("proposalName(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
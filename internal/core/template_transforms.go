@@ -0,0 +1,265 @@
+package core
+
+// template_transforms.go lets an import template fill table columns in ways
+// a column mapping cannot: a join builds one column from several CSV
+// columns with a separator between the values, and a split fills several
+// columns from one CSV column, such as "Boston, MA" into a city and a
+// state.
+//
+// Transforms run on each row before its dates are read and it is
+// validated. The values they build are appended after the file's own
+// columns and the header index points the target columns at them, so the
+// rest of the pipeline (validation, BuildParams, duplicate keys) reads them
+// like any other cell. A transform's target wins over the same column in
+// the mapping. Transforms apply when the template supplies the upload's
+// mapping, as the drift check does (see template_drift.go).
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInvalidTransform is returned for a template transform that cannot be
+// applied to its table.
+var ErrInvalidTransform = errors.New("invalid template transform")
+
+// TransformOp is the kind of a MappingTransform.
+type TransformOp string
+
+const (
+	// TransformJoin fills one field with several CSV columns' values,
+	// separated by Separator. Empty values are left out.
+	TransformJoin TransformOp = "join"
+
+	// TransformSplit fills several fields, in order, with the parts of one
+	// CSV column split on Separator. The last field gets the rest of the
+	// value; fields without a part are empty.
+	TransformSplit TransformOp = "split"
+)
+
+// MappingTransform builds table columns from CSV columns.
+type MappingTransform struct {
+	Op        TransformOp `json:"op"`
+	Columns   []int       `json:"columns"`             // CSV column indexes read; a split reads one
+	Fields    []string    `json:"fields"`              // Table columns written; a join writes one
+	Separator string      `json:"separator,omitempty"` // Put between joined values, or split on
+}
+
+// validateTransforms checks transforms against def and returns them with
+// old column names replaced by current ones (see Renames).
+func validateTransforms(def TableDefinition, transforms []MappingTransform) ([]MappingTransform, error) {
+	built := make(map[string]bool)
+	out := make([]MappingTransform, len(transforms))
+	for i, t := range transforms {
+		switch t.Op {
+		case TransformJoin:
+			if len(t.Columns) < 2 || len(t.Fields) != 1 {
+				return nil, fmt.Errorf("%w: a join reads two or more columns into one field", ErrInvalidTransform)
+			}
+		case TransformSplit:
+			if len(t.Columns) != 1 || len(t.Fields) < 2 {
+				return nil, fmt.Errorf("%w: a split reads one column into two or more fields", ErrInvalidTransform)
+			}
+			if t.Separator == "" {
+				return nil, fmt.Errorf("%w: a split needs a separator", ErrInvalidTransform)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown op %q (want join or split)", ErrInvalidTransform, t.Op)
+		}
+		for _, col := range t.Columns {
+			if col < 0 {
+				return nil, fmt.Errorf("%w: column index %d is negative", ErrInvalidTransform, col)
+			}
+		}
+
+		fields := make([]string, len(t.Fields))
+		for j, f := range t.Fields {
+			name, ok := tableColumn(def, ResolveColumnName(def, f, "template transform"))
+			if !ok {
+				return nil, fmt.Errorf("%w: %s has no column %q", ErrInvalidTransform, def.Info.Key, f)
+			}
+			if built[strings.ToLower(name)] {
+				return nil, fmt.Errorf("%w: column %q is built by more than one transform", ErrInvalidTransform, name)
+			}
+			built[strings.ToLower(name)] = true
+			fields[j] = name
+		}
+		t.Fields = fields
+		out[i] = t
+	}
+	return out, nil
+}
+
+// tableColumn returns def's column matching name, ignoring case.
+func tableColumn(def TableDefinition, name string) (string, bool) {
+	for _, c := range def.Info.Columns {
+		if strings.EqualFold(c, name) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// values returns the values t builds from a CSV row, one per field.
+func (t MappingTransform) values(row []string) []string {
+	cell := func(i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(CleanCell(row[i]))
+	}
+
+	if t.Op == TransformJoin {
+		var parts []string
+		for _, col := range t.Columns {
+			if v := cell(col); v != "" {
+				parts = append(parts, v)
+			}
+		}
+		return []string{strings.Join(parts, t.Separator)}
+	}
+
+	out := make([]string, len(t.Fields))
+	if v := cell(t.Columns[0]); v != "" {
+		for i, part := range strings.SplitN(v, t.Separator, len(t.Fields)) {
+			out[i] = strings.TrimSpace(part)
+		}
+	}
+	return out
+}
+
+// rowTransformer applies an upload's transforms and mapping constants (see
+// mapping_constants.go) to its rows.
+type rowTransformer struct {
+	transforms []MappingTransform
+	constants  []mappingConstant
+	width      int // Columns in the file's header; built values follow
+	fields     int // Values built per row
+}
+
+// newRowTransformer points the fields of transforms, then of constants, in
+// headerIdx at the cells after the width columns of the file's header. It
+// returns nil when there are neither.
+func newRowTransformer(transforms []MappingTransform, constants []mappingConstant, headerIdx HeaderIndex, width int) *rowTransformer {
+	if len(transforms) == 0 && len(constants) == 0 {
+		return nil
+	}
+	rt := &rowTransformer{transforms: transforms, constants: constants, width: width}
+	for _, t := range transforms {
+		for _, f := range t.Fields {
+			headerIdx[strings.ToLower(f)] = width + rt.fields
+			rt.fields++
+		}
+	}
+	for _, c := range constants {
+		headerIdx[strings.ToLower(c.field)] = width + rt.fields
+		rt.fields++
+	}
+	return rt
+}
+
+// built returns how many table columns the transforms fill. Safe on nil.
+func (rt *rowTransformer) built() int {
+	if rt == nil {
+		return 0
+	}
+	return rt.fields
+}
+
+// apply returns row with the built values appended. Safe on nil, which
+// returns row unchanged.
+func (rt *rowTransformer) apply(row []string) []string {
+	if rt == nil {
+		return row
+	}
+	cells := make([]string, rt.width, rt.width+rt.fields)
+	copy(cells, row)
+	for _, t := range rt.transforms {
+		cells = append(cells, t.values(row)...)
+	}
+	for _, c := range rt.constants {
+		cells = append(cells, c.value)
+	}
+	return cells
+}
+
+// templateTransforms returns the transforms of the template set by
+// ContextWithTemplate, or nil.
+func templateTransforms(ctx context.Context) []MappingTransform {
+	if t := templateFromContext(ctx); t != nil {
+		return t.Transforms
+	}
+	return nil
+}
+
+// saveTemplateTransforms stores the transforms of template id within tx,
+// after checking them against the template's table. No transforms stores
+// NULL.
+func saveTemplateTransforms(ctx context.Context, tx pgx.Tx, id pgtype.UUID, tableKey string, transforms []MappingTransform) ([]MappingTransform, error) {
+	if len(transforms) == 0 {
+		if _, err := tx.Exec(ctx, `UPDATE import_templates SET transforms = NULL WHERE id = $1`, id); err != nil {
+			return nil, fmt.Errorf("save transforms: %w", err)
+		}
+		return nil, nil
+	}
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	transforms, err := validateTransforms(def, transforms)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(transforms)
+	if err != nil {
+		return nil, fmt.Errorf("marshal transforms: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE import_templates SET transforms = $2 WHERE id = $1`, id, raw); err != nil {
+		return nil, fmt.Errorf("save transforms: %w", err)
+	}
+	return transforms, nil
+}
+
+// loadTemplateTransforms sets Transforms on templates, which the generated
+// template queries do not read.
+func (s *Service) loadTemplateTransforms(ctx context.Context, templates []*ImportTemplate) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	byID := make(map[string]*ImportTemplate, len(templates))
+	ids := make([]pgtype.UUID, 0, len(templates))
+	for _, t := range templates {
+		byID[t.ID] = t
+		ids = append(ids, ToPgUUID(t.ID))
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, transforms FROM import_templates WHERE id = ANY($1) AND transforms IS NOT NULL`, ids)
+	if err != nil {
+		return fmt.Errorf("load template transforms: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id pgtype.UUID
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return fmt.Errorf("scan template transforms: %w", err)
+		}
+		var transforms []MappingTransform
+		if err := json.Unmarshal(raw, &transforms); err != nil {
+			return fmt.Errorf("unmarshal template transforms: %w", err)
+		}
+		if t := byID[PgUUIDToString(id)]; t != nil {
+			t.Transforms = transforms
+		}
+	}
+	return rows.Err()
+}
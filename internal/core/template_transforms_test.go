@@ -0,0 +1,83 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func transformTestDef() TableDefinition {
+	return TableDefinition{
+		Info: TableInfo{Key: "contacts", Columns: []string{"Name", "City", "State"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Name"},
+			{Name: "City"},
+			{Name: "State"},
+		},
+		Renames: []ColumnRename{{From: "Province", To: "State"}},
+	}
+}
+
+func TestValidateTransforms(t *testing.T) {
+	def := transformTestDef()
+
+	got, err := validateTransforms(def, []MappingTransform{
+		{Op: TransformSplit, Columns: []int{1}, Fields: []string{"city", "Province"}, Separator: ","},
+	})
+	if err != nil {
+		t.Fatalf("validateTransforms: %v", err)
+	}
+	if f := strings.Join(got[0].Fields, ","); f != "City,State" {
+		t.Errorf("fields = %s, want current column names", f)
+	}
+
+	for name, transforms := range map[string][]MappingTransform{
+		"unknown op":      {{Op: "upper", Columns: []int{0}, Fields: []string{"Name"}}},
+		"join one column": {{Op: TransformJoin, Columns: []int{0}, Fields: []string{"Name"}}},
+		"split no sep":    {{Op: TransformSplit, Columns: []int{1}, Fields: []string{"City", "State"}}},
+		"unknown field":   {{Op: TransformJoin, Columns: []int{0, 1}, Fields: []string{"Country"}}},
+		"negative column": {{Op: TransformJoin, Columns: []int{-1, 1}, Fields: []string{"Name"}}},
+		"field built twice": {
+			{Op: TransformJoin, Columns: []int{0, 1}, Fields: []string{"Name"}},
+			{Op: TransformSplit, Columns: []int{2}, Fields: []string{"name", "City"}, Separator: ","},
+		},
+	} {
+		if _, err := validateTransforms(def, transforms); !errors.Is(err, ErrInvalidTransform) {
+			t.Errorf("%s: err = %v, want ErrInvalidTransform", name, err)
+		}
+	}
+}
+
+func TestRowTransformer(t *testing.T) {
+	headerIdx := HeaderIndex{"name": 0, "city": 2}
+	rt := newRowTransformer([]MappingTransform{
+		{Op: TransformJoin, Columns: []int{0, 1}, Fields: []string{"Name"}, Separator: " "},
+		{Op: TransformSplit, Columns: []int{2}, Fields: []string{"City", "State"}, Separator: ","},
+	}, nil, headerIdx, 3)
+
+	if headerIdx["name"] != 3 || headerIdx["city"] != 4 || headerIdx["state"] != 5 {
+		t.Fatalf("header index = %v, want built columns after the file's", headerIdx)
+	}
+	if rt.built() != 3 {
+		t.Errorf("built = %d, want 3", rt.built())
+	}
+
+	tests := []struct {
+		row  []string
+		want string
+	}{
+		{[]string{"Ada", "Lovelace", "Austin, TX, USA"}, "Ada|Lovelace|Austin, TX, USA|Ada Lovelace|Austin|TX, USA"},
+		{[]string{"", "Hopper", "Boston"}, "|Hopper|Boston|Hopper|Boston|"},
+		{[]string{"Grace"}, "Grace|||Grace||"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(rt.apply(tt.row), "|"); got != tt.want {
+			t.Errorf("apply(%v) = %s, want %s", tt.row, got, tt.want)
+		}
+	}
+
+	var none *rowTransformer
+	if row := []string{"a"}; none.built() != 0 || len(none.apply(row)) != 1 {
+		t.Error("nil transformer should leave rows unchanged")
+	}
+}
@@ -13,8 +13,12 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// CreateTemplate creates a new import template.
-func (s *Service) CreateTemplate(ctx context.Context, tableKey, name string, mapping map[string]int, csvHeaders []string) (*ImportTemplate, error) {
+// CreateTemplate creates a new import template. transforms, if any, build
+// columns the mapping cannot (see template_transforms.go).
+func (s *Service) CreateTemplate(ctx context.Context, tableKey, name string, mapping map[string]int, csvHeaders []string, transforms []MappingTransform) (*ImportTemplate, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	if name == "" {
 		return nil, fmt.Errorf("template name is required")
 	}
@@ -29,7 +33,13 @@ func (s *Service) CreateTemplate(ctx context.Context, tableKey, name string, map
 		return nil, fmt.Errorf("marshal headers: %w", err)
 	}
 
-	queries := db.New(s.pool)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := db.New(tx)
 	result, err := queries.CreateImportTemplate(ctx, db.CreateImportTemplateParams{
 		TableKey:      tableKey,
 		Name:          name,
@@ -42,6 +52,12 @@ func (s *Service) CreateTemplate(ctx context.Context, tableKey, name string, map
 		}
 		return nil, fmt.Errorf("create template: %w", err)
 	}
+	if transforms, err = saveTemplateTransforms(ctx, tx, result.ID, tableKey, transforms); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
 
 	// Log audit entry for template creation
 	s.LogAudit(ctx, AuditLogParams{
@@ -52,11 +68,19 @@ func (s *Service) CreateTemplate(ctx context.Context, tableKey, name string, map
 		Reason:    fmt.Sprintf("Created template: %s", name),
 	})
 
-	return dbTemplateToTemplate(result)
+	t, err := dbTemplateToTemplate(result)
+	if err != nil {
+		return nil, err
+	}
+	t.Transforms = transforms
+	return t, nil
 }
 
 // GetTemplate retrieves a template by ID.
 func (s *Service) GetTemplate(ctx context.Context, id string) (*ImportTemplate, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid template ID: %w", err)
@@ -68,11 +92,24 @@ func (s *Service) GetTemplate(ctx context.Context, id string) (*ImportTemplate,
 		return nil, fmt.Errorf("get template: %w", err)
 	}
 
-	return dbTemplateToTemplate(result)
+	t, err := dbTemplateToTemplate(result)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadFixedWidthLayouts(ctx, []*ImportTemplate{t}); err != nil {
+		return nil, err
+	}
+	if err := s.loadTemplateTransforms(ctx, []*ImportTemplate{t}); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 // ListTemplates returns all templates for a table.
 func (s *Service) ListTemplates(ctx context.Context, tableKey string) ([]ImportTemplate, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	queries := db.New(s.pool)
 	results, err := queries.ListImportTemplates(ctx, tableKey)
 	if err != nil {
@@ -88,11 +125,25 @@ func (s *Service) ListTemplates(ctx context.Context, tableKey string) ([]ImportT
 		templates = append(templates, *t)
 	}
 
+	ptrs := make([]*ImportTemplate, len(templates))
+	for i := range templates {
+		ptrs[i] = &templates[i]
+	}
+	if err := s.loadFixedWidthLayouts(ctx, ptrs); err != nil {
+		return nil, err
+	}
+	if err := s.loadTemplateTransforms(ctx, ptrs); err != nil {
+		return nil, err
+	}
+
 	return templates, nil
 }
 
-// UpdateTemplate updates an existing template.
-func (s *Service) UpdateTemplate(ctx context.Context, id, name string, mapping map[string]int, csvHeaders []string) (*ImportTemplate, error) {
+// UpdateTemplate updates an existing template, replacing its transforms.
+func (s *Service) UpdateTemplate(ctx context.Context, id, name string, mapping map[string]int, csvHeaders []string, transforms []MappingTransform) (*ImportTemplate, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	if name == "" {
 		return nil, fmt.Errorf("template name is required")
 	}
@@ -112,7 +163,13 @@ func (s *Service) UpdateTemplate(ctx context.Context, id, name string, mapping m
 		return nil, fmt.Errorf("marshal headers: %w", err)
 	}
 
-	queries := db.New(s.pool)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	queries := db.New(tx)
 	result, err := queries.UpdateImportTemplate(ctx, db.UpdateImportTemplateParams{
 		ID:            pgtype.UUID{Bytes: uid, Valid: true},
 		Name:          name,
@@ -122,6 +179,12 @@ func (s *Service) UpdateTemplate(ctx context.Context, id, name string, mapping m
 	if err != nil {
 		return nil, fmt.Errorf("update template: %w", err)
 	}
+	if transforms, err = saveTemplateTransforms(ctx, tx, result.ID, result.TableKey, transforms); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
 
 	// Log audit entry for template update
 	s.LogAudit(ctx, AuditLogParams{
@@ -132,11 +195,19 @@ func (s *Service) UpdateTemplate(ctx context.Context, id, name string, mapping m
 		Reason:    fmt.Sprintf("Updated template: %s", name),
 	})
 
-	return dbTemplateToTemplate(result)
+	t, err := dbTemplateToTemplate(result)
+	if err != nil {
+		return nil, err
+	}
+	t.Transforms = transforms
+	return t, nil
 }
 
 // DeleteTemplate removes a template.
 func (s *Service) DeleteTemplate(ctx context.Context, id string) error {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("invalid template ID: %w", err)
@@ -169,6 +240,9 @@ func (s *Service) DeleteTemplate(ctx context.Context, id string) error {
 
 // MatchTemplates finds templates that match the given CSV headers.
 func (s *Service) MatchTemplates(ctx context.Context, tableKey string, csvHeaders []string) ([]TemplateMatch, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
 	templates, err := s.ListTemplates(ctx, tableKey)
 	if err != nil {
 		return nil, err
@@ -177,6 +251,9 @@ func (s *Service) MatchTemplates(ctx context.Context, tableKey string, csvHeader
 	// Initialize as empty slice (not nil) so JSON encodes as [] instead of null
 	matches := []TemplateMatch{}
 	for _, t := range templates {
+		if t.FixedWidth != nil {
+			continue // Matched by layout, not headers
+		}
 		score := matchTemplateHeaders(csvHeaders, t.CSVHeaders)
 		if score >= TemplateMatchThreshold {
 			matches = append(matches, TemplateMatch{
@@ -227,6 +304,11 @@ func dbTemplateToTemplate(t db.ImportTemplate) (*ImportTemplate, error) {
 		return nil, fmt.Errorf("unmarshal headers: %w", err)
 	}
 
+	// Templates saved before a column rename use its old name
+	if def, ok := Get(t.TableKey); ok {
+		mapping = resolveMappingColumns(def, mapping, "template")
+	}
+
 	id := ""
 	if t.ID.Valid {
 		id = uuid.UUID(t.ID.Bytes).String()
@@ -38,6 +38,9 @@ type FieldSpec struct {
 	Required   bool              // Column must exist in CSV header
 	AllowEmpty bool              // If true, empty values are allowed even when Required
 	EnumValues []string          // Valid values for FieldEnum type
+	YearPivot  int               // FieldDate: 2-digit year pivot; 0 uses DefaultTwoDigitYearPivot
+	Mask       MaskKind          // Masking policy for anonymized exports (see anonymize.go)
+	Display    DisplayFormat     // How values are shown in the table view and exports (see display_format.go)
 	Normalizer func(string) string // Optional transformation function
 }
 
@@ -49,6 +52,7 @@ type TableInfo struct {
 	Directory string   // Upload folder: "Customers"
 	Columns   []string // Header column names
 	UniqueKey []string // Column(s) that form the unique key for duplicate detection
+	ReadOnly  bool     // Set by Register for view tables; uploads and edits are rejected
 }
 
 // HeaderIndex maps column names (lowercase) to their position in the CSV row.
@@ -72,6 +76,16 @@ type DeleteByUploadIDFunc func(ctx context.Context, db DBTX, uploadID pgtype.UUI
 // Each value should be a native Go type or pgtype (e.g., pgtype.Text, pgtype.Numeric).
 type CopyRowFunc func(params any) []any
 
+// RowValidateFunc checks a row's cross-field rules. row maps each FieldSpec
+// name present in the file to its cleaned, normalized value. Return a
+// [RuleError] to name the rule that failed.
+type RowValidateFunc func(row map[string]string) error
+
+// BatchValidateFunc checks rules that span rows. rows holds the values of
+// each row in an insert batch, as for RowValidateFunc; the result maps the
+// index of each failing row to its error.
+type BatchValidateFunc func(rows []map[string]string) map[int]error
+
 // TableDefinition contains everything needed to process a table.
 type TableDefinition struct {
 	Info             TableInfo
@@ -79,7 +93,7 @@ type TableDefinition struct {
 	BuildParams      BuildParamsFunc
 	Insert           InsertFunc
 	Reset            ResetFunc
-	DeleteByUploadID DeleteByUploadIDFunc // Deletes rows by upload_id for rollback
+	DeleteByUploadID DeleteByUploadIDFunc // Deletes rows by upload_id for rollback; Register defaults it
 
 	// Optional: PostgreSQL COPY protocol support (~10-100x faster than INSERT).
 	// If both CopyColumns and CopyRow are set, bulk inserts will use COPY.
@@ -92,6 +106,135 @@ type TableDefinition struct {
 	// CopyRow converts the params struct (from BuildParams) to a row slice.
 	// Values must match the order of CopyColumns exactly.
 	CopyRow CopyRowFunc
+
+	// Optional: per-table upload limits, applied on top of the global
+	// UPLOAD_MAX_FILE_SIZE. Zero values mean no table-specific limit.
+	Limits UploadLimits
+
+	// Optional: how uploads treat rows already in the table when the request
+	// does not choose a mode. Empty means UploadModeInsert.
+	UploadMode UploadMode
+
+	// Optional: correlated column groups. After each upload that inserts
+	// rows, the matching PostgreSQL extended statistics are created if
+	// missing and the table is re-analyzed, so the planner stops assuming
+	// these columns are independent when filtering or grouping on them.
+	Statistics []ExtendedStatistics
+
+	// Optional: schema evolution. Renames keep a column's old names working
+	// in upload mappings, import templates and sort/filter parameters;
+	// DroppedColumns lists database columns removed from FieldSpecs. Both
+	// drive GenerateSchemaMigration (see schema_evolution.go).
+	Renames        []ColumnRename
+	DroppedColumns []string
+
+	// Optional: read-only virtual table. View is the SELECT defining a SQL
+	// view created under Info.Key by SyncViews; FieldSpecs describe its
+	// columns and the upload functions are left nil (see views.go).
+	View string
+
+	// Optional: custom validation run after the FieldSpec checks (see
+	// row_rules.go). ValidateRow sees one row; ValidateBatch sees the rows of
+	// each insert batch, up to UPLOAD_BATCH_SIZE at a time. Failing rows are
+	// skipped and reported as failed rows with the rule's name.
+	ValidateRow   RowValidateFunc
+	ValidateBatch BatchValidateFunc
+
+	// Optional: soft delete. DeleteRows sets the table's deleted_at column
+	// (TIMESTAMPTZ) instead of removing rows, and queries skip rows where it
+	// is set. Those rows are the table's recycle bin: they can be listed,
+	// restored and purged, and are purged after SOFT_DELETE_RETENTION_DAYS
+	// (see soft_delete.go). Requires a unique key.
+	SoftDelete bool
+
+	// Optional: keys of the tables this table's rows refer to, such as a
+	// detail table referring to its header table. ResetAll empties a table
+	// before the tables it references (see reset_all.go).
+	References []string
+
+	// Optional: custom actions offered on the table, such as "Recalculate
+	// commissions", run as tracked operations (see table_actions.go).
+	Actions []TableAction
+
+	// Optional: columns computed from each row's stored columns, shown
+	// after them in the table view, table data and exports but never
+	// stored (see derived_columns.go).
+	Derived []DerivedColumn
+
+	// Optional: watermark every export of the table with an export ID
+	// recorded in the audit log, so a leaked file can be traced back to
+	// who exported it (see export_watermark.go).
+	WatermarkExports bool
+
+	// Optional: the fewest rows a summary group or histogram bin may count
+	// before it is suppressed for aggregates-only users and tokens. Zero
+	// uses QUERY_AGGREGATE_MIN_GROUP_SIZE (see aggregate_access.go).
+	MinGroupSize int
+
+	// declared is set for tables registered from a schema file, whose upload
+	// functions are generated (see table_config.go).
+	declared bool
+}
+
+// ColumnRename declares that a column was renamed.
+type ColumnRename struct {
+	From   string // Previous header name
+	To     string // Current header name; must be a FieldSpec
+	FromDB string // Previous database column; derived from From if empty
+}
+
+// UploadMode controls how uploaded rows interact with rows already in the table.
+type UploadMode string
+
+const (
+	// UploadModeInsert appends every row, even if its unique key already exists.
+	UploadModeInsert UploadMode = "insert"
+
+	// UploadModeUpsert replaces rows from earlier uploads that share a
+	// unique key with an uploaded row. Requires TableInfo.UniqueKey.
+	UploadModeUpsert UploadMode = "upsert"
+
+	// UploadModeReplace deletes every existing row before inserting, in the
+	// same transaction, so a failed upload leaves the table untouched.
+	UploadModeReplace UploadMode = "replace"
+
+	// UploadModeDelete deletes the rows whose unique key is listed in the
+	// file instead of inserting anything. Requires TableInfo.UniqueKey.
+	UploadModeDelete UploadMode = "delete"
+)
+
+// DuplicatePolicy controls what an upload does with a row whose unique key
+// is already in the table or appeared earlier in the same file.
+type DuplicatePolicy string
+
+const (
+	// DuplicateKeepBoth inserts every row. Default outside upsert mode.
+	DuplicateKeepBoth DuplicatePolicy = "keep-both"
+
+	// DuplicateSkip keeps the existing row, or the first row in the file,
+	// and skips the duplicate.
+	DuplicateSkip DuplicatePolicy = "skip"
+
+	// DuplicateOverwrite keeps the last row: it replaces existing rows, as
+	// in upsert mode, and earlier rows in the file. Default in upsert mode.
+	DuplicateOverwrite DuplicatePolicy = "overwrite"
+
+	// DuplicateFailUpload fails the whole upload at the first duplicate.
+	DuplicateFailUpload DuplicatePolicy = "fail-upload"
+)
+
+// ExtendedStatistics declares a CREATE STATISTICS object on correlated columns.
+type ExtendedStatistics struct {
+	Columns []string // Database column names; at least two
+	Kinds   []string // "ndistinct", "dependencies", "mcv"; empty means all
+}
+
+// UploadLimits caps what a single table accepts, so a small reference table
+// cannot accidentally receive a large fact file.
+type UploadLimits struct {
+	MaxFileBytes     int64 `json:"maxFileBytes,omitempty"`     // Largest accepted file
+	MaxRows          int   `json:"maxRows,omitempty"`          // Most data rows per upload
+	MaxUploadsPerDay int   `json:"maxUploadsPerDay,omitempty"` // Most active uploads per calendar day (server time)
 }
 
 // SupportsCopy returns true if the table has COPY protocol support configured.
@@ -104,6 +247,7 @@ func (t TableDefinition) SupportsCopy() bool {
 type UploadPhase string
 
 const (
+	PhaseQueued     UploadPhase = "queued" // Batch file waiting for earlier files
 	PhaseStarting   UploadPhase = "starting"
 	PhaseReading    UploadPhase = "reading"
 	PhaseValidating UploadPhase = "validating"
@@ -128,6 +272,9 @@ type UploadProgress struct {
 	// When streaming, TotalRows may be 0 and progress is calculated from bytes.
 	BytesRead  int64
 	BytesTotal int64
+	// EventID numbers the progress notifications of an upload, starting at
+	// 1; 0 if none was sent yet. See Service.ResumeProgress.
+	EventID int64
 }
 
 // Percent returns the progress as a percentage (0-100).
@@ -154,15 +301,25 @@ type FailedRow struct {
 
 // UploadResult contains the final result of an upload operation.
 type UploadResult struct {
-	UploadID   string
-	TableKey   string
-	FileName   string
-	TotalRows  int
-	Inserted   int
-	Skipped    int
-	FailedRows []FailedRow
-	Duration   time.Duration
-	Error      string // Non-empty if upload failed
+	UploadID      string
+	TableKey      string
+	FileName      string
+	TotalRows     int
+	Mode          UploadMode
+	Inserted      int // Rows added with a new unique key (all rows outside upsert mode)
+	Updated       int // Upsert mode: rows that replaced an existing row with the same key
+	Replaced      int // Replace mode: existing rows deleted before inserting
+	Deleted       int // Delete mode: existing rows deleted by key
+	Skipped       int
+	Duplicates    DuplicatePolicy
+	DupSkipped    int // Skip policy: rows not inserted because their key was taken
+	DupInFile     int // Overwrite policy: earlier rows of the file replaced by a later one
+	FailedRows    []FailedRow
+	Retries       int // Batch inserts repeated after transient DB errors
+	Duration      time.Duration
+	DateWarnings  []DateWarning  // Date columns that may be day first (automatic date format only)
+	TemplateDrift *TemplateDrift // Headers differ from the import template the mapping came from
+	Error         string         // Non-empty if upload failed
 }
 
 // ProgressCallback is called periodically during upload processing.
@@ -181,18 +338,64 @@ const (
 	OpGreater    FilterOperator = "gt"
 	OpLess       FilterOperator = "lt"
 	OpIn         FilterOperator = "in"
+	OpNotEquals  FilterOperator = "neq"
+	OpIsNull     FilterOperator = "null"    // Empty cell; takes no value
+	OpNotNull    FilterOperator = "notnull" // Non-empty cell; takes no value
 )
 
+// TakesValue reports whether op compares against a filter value; the
+// empty and not-empty operators do not.
+func (op FilterOperator) TakesValue() bool {
+	return op != OpIsNull && op != OpNotNull
+}
+
+// ValidOperator reports whether op can filter a column of type ft.
+func ValidOperator(op FilterOperator, ft FieldType) bool {
+	switch op {
+	case OpNotEquals, OpIsNull, OpNotNull:
+		return true
+	}
+	switch ft {
+	case FieldText:
+		switch op {
+		case OpContains, OpEquals, OpStartsWith, OpEndsWith:
+			return true
+		}
+	case FieldNumeric:
+		switch op {
+		case OpEquals, OpGreaterEq, OpLessEq, OpGreater, OpLess:
+			return true
+		}
+	case FieldDate:
+		switch op {
+		case OpEquals, OpGreaterEq, OpLessEq:
+			return true
+		}
+	case FieldBool:
+		return op == OpEquals
+	case FieldEnum:
+		switch op {
+		case OpEquals, OpIn:
+			return true
+		}
+	}
+	return false
+}
+
 // ColumnFilter represents a single filter condition on a column.
 type ColumnFilter struct {
 	Column   string         // Display column name
 	DBColumn string         // Database column name
+	Expr     string         // SQL expression compared instead of DBColumn, for derived columns
 	Operator FilterOperator // Comparison operator
 	Value    string         // Filter value (comma-separated for OpIn)
 	Type     FieldType      // Column type for proper SQL generation
+	Group    string         // OR group path, e.g. "g" or "g.x"; empty at the top level (see filter_groups.go)
+	Negate   bool           // Match rows the condition does not match, including empty values
 }
 
-// FilterSet represents all active filters (combined with AND logic).
+// FilterSet represents all active filters. Top-level filters are combined
+// with AND logic; grouped filters form nested OR/AND groups by their Group.
 type FilterSet struct {
 	Filters []ColumnFilter
 }
@@ -214,11 +417,13 @@ type Aggregations map[string]*ColumnAggregation
 type SortSpec struct {
 	Column string // Display column name
 	Dir    string // "asc" or "desc"
+	Nulls  string // "first", "last", or "" for the database default (last ascending, first descending)
 }
 
 // RollbackResult contains the result of a rollback operation.
 type RollbackResult struct {
 	UploadID    string `json:"uploadId"`
+	OperationID string `json:"operationId,omitempty"`
 	TableKey    string `json:"tableKey"`
 	RowsDeleted int64  `json:"rowsDeleted"`
 	Success     bool   `json:"success"`
@@ -236,3 +441,27 @@ type RollbackPreview struct {
 	CanRollback  bool   `json:"canRollback"`
 	Reason       string `json:"reason,omitempty"`
 }
+
+// RollbackRangeResult contains the result of rolling back all uploads in a
+// time window. Uploads are listed newest first, the order they are reverted.
+type RollbackRangeResult struct {
+	TableKey     string            `json:"tableKey"`
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	Preview      bool              `json:"preview"`
+	Uploads      []RollbackPreview `json:"uploads"`
+	RowsToDelete int64             `json:"rowsToDelete"`
+	RowsDeleted  int64             `json:"rowsDeleted"`
+	OperationID  string            `json:"operationId,omitempty"`
+	Success      bool              `json:"success"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// RollbackBatchResult contains the result of rolling back an upload batch.
+type RollbackBatchResult struct {
+	BatchID     string           `json:"batchId"`
+	Uploads     []RollbackResult `json:"uploads"`
+	RowsDeleted int64            `json:"rowsDeleted"`
+	Success     bool             `json:"success"`
+	Error       string           `json:"error,omitempty"`
+}
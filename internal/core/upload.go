@@ -32,9 +32,19 @@ func (s *Service) processUpload(ctx context.Context, upload *activeUpload, def T
 		close(upload.Done)
 		s.cleanup(upload.ID, 5*time.Minute)
 	}()
+	s.uploadStartedActivity(upload)
 
-	// Sanitize UTF-8 (streaming sanitization would add complexity for minimal gain)
-	fileData = sanitizeUTF8(fileData)
+	// Parquet is converted to CSV before transcoding, which would mangle it
+	fileData, err := parquetAsCSV(upload.FileName, fileData, def, len(upload.Mapping) > 0)
+	if err != nil {
+		result := &UploadResult{UploadID: upload.ID, TableKey: upload.TableKey, FileName: upload.FileName, Mode: upload.Mode, Error: err.Error()}
+		s.finishUploadHooks(ctx, upload, result, "")
+		upload.Result = result
+		return
+	}
+
+	// Transcode to UTF-8 and sanitize (streaming would add complexity for minimal gain)
+	fileData = toUTF8(fileData)
 
 	// Process using streaming parser (reads CSV row-by-row instead of loading all into memory)
 	result := s.processStreamingRecords(ctx, upload, def, fileData, upload.FileName, startTime)
@@ -51,60 +61,113 @@ type validatedRow struct {
 
 // insertBatch attempts to insert a batch of rows.
 // Uses a single savepoint per batch instead of per row (3x fewer round-trips).
-// Returns the number of rows that failed to insert.
-// On batch failure, falls back to row-by-row insertion to identify bad rows.
-func (s *Service) insertBatch(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow, failedRows *[]FailedRow, fileName string) int {
+// Returns the number of rows inserted and the number of batch retries.
+// Transient errors (see isRetryableError) are retried with backoff up to
+// Upload.BatchRetries times. Errors that leave tx unusable (see
+// isTxFailedError) fail the upload, since no row caused them and nothing
+// more can be done in tx. On any other batch failure, falls back to
+// row-by-row insertion to identify bad rows.
+//
+// dups, if not nil, first applies the upload's duplicate policy to the
+// batch; an error means the policy failed the upload.
+func (s *Service) insertBatch(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow, failedRows *[]FailedRow, fileName string, dups *duplicateGuard) (int, int, error) {
+	removed := 0
+	if dups != nil {
+		var err error
+		if batch, removed, err = dups.filter(ctx, tx, batch); err != nil {
+			return 0, 0, err
+		}
+	}
 	if len(batch) == 0 {
-		return 0
+		return -removed, 0, nil
 	}
 
+	retries := 0
+	for {
+		err := s.tryBatchInsert(ctx, tx, def, batch)
+		if err == nil {
+			return len(batch) - removed, retries, nil
+		}
+		if isTxFailedError(err) {
+			return 0, retries, fmt.Errorf("insert batch: %w", err)
+		}
+		if !isRetryableError(err) || retries >= s.cfg.Upload.BatchRetries {
+			break
+		}
+
+		retries++
+		delay := retryBackoff(s.cfg.Upload.RetryBackoff, retries)
+		slog.Warn("transient error during batch insert, retrying",
+			"table", def.Info.Key,
+			"attempt", retries,
+			"delay", delay,
+			"error", err,
+		)
+		if !sleepContext(ctx, delay) {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		// Cancelled: every row would fail, and not for its own sake
+		return 0, retries, ctx.Err()
+	}
+	failed := s.insertRowByRow(ctx, tx, def, batch, failedRows, fileName)
+	return len(batch) - failed - removed, retries, nil
+}
+
+// writeBatch writes a validated batch: inserts it, or in delete mode
+// deletes the rows matching its keys (see upload_delete.go). It returns
+// the same counts as insertBatch.
+func (s *Service) writeBatch(ctx context.Context, tx pgx.Tx, upload *activeUpload, def TableDefinition, uploadID pgtype.UUID, headerIdx HeaderIndex, batch []validatedRow, failedRows *[]FailedRow, fileName string, dups *duplicateGuard) (int, int, error) {
+	if upload.Mode == UploadModeDelete {
+		return 0, 0, s.deleteKeyBatch(ctx, tx, upload, uploadID, headerIdx, batch, failedRows, fileName)
+	}
+	rows := applyBatchRules(def, batch, headerIdx, failedRows, fileName)
+	return s.insertBatch(ctx, tx, def, rows, failedRows, fileName, dups)
+}
+
+// tryBatchInsert inserts the whole batch atomically, using COPY when the
+// table supports it and a savepoint-wrapped INSERT loop otherwise.
+// On error the transaction is rolled back to its state before the call.
+func (s *Service) tryBatchInsert(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow) error {
 	// Try COPY if the table supports it (10-100x faster than INSERT)
 	if def.SupportsCopy() {
-		failed := s.insertWithCopy(ctx, tx, def, batch, failedRows, fileName)
-		if failed == 0 {
-			return 0
+		err := s.insertWithCopy(ctx, tx, def, batch)
+		if err == nil || isRetryableError(err) || isTxFailedError(err) || ctx.Err() != nil {
+			return err
 		}
 		// COPY failed - fall through to savepoint-based insert
 		slog.Debug("COPY insert failed, using savepoint fallback", "table", def.Info.Key)
 	}
 
 	// Create savepoint for the entire batch
-	_, err := tx.Exec(ctx, "SAVEPOINT batch_sp")
-	if err != nil {
-		// Savepoint failed - fall back to row-by-row
-		return s.insertRowByRow(ctx, tx, def, batch, failedRows, fileName)
+	if _, err := tx.Exec(ctx, "SAVEPOINT batch_sp"); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
 	}
 
 	// Try inserting all rows in the batch without per-row savepoints
-	allSucceeded := true
 	for _, vr := range batch {
 		if err := def.Insert(ctx, tx, vr.params); err != nil {
-			allSucceeded = false
-			break
+			// Batch had failures - rollback so the caller can retry or go row-by-row
+			_, _ = tx.Exec(ctx, "ROLLBACK TO SAVEPOINT batch_sp")
+			_, _ = tx.Exec(ctx, "RELEASE SAVEPOINT batch_sp")
+			return err
 		}
 	}
 
-	if allSucceeded {
-		// Release savepoint - batch succeeded
-		_, _ = tx.Exec(ctx, "RELEASE SAVEPOINT batch_sp")
-		return 0
-	}
-
-	// Batch had failures - rollback and retry row-by-row to identify bad rows
-	_, _ = tx.Exec(ctx, "ROLLBACK TO SAVEPOINT batch_sp")
+	// Release savepoint - batch succeeded
 	_, _ = tx.Exec(ctx, "RELEASE SAVEPOINT batch_sp")
-
-	return s.insertRowByRow(ctx, tx, def, batch, failedRows, fileName)
+	return nil
 }
 
 // insertWithCopy uses PostgreSQL COPY protocol for bulk insertion.
-// Returns the number of failed rows (0 = all succeeded).
-// COPY is atomic per batch - if it fails, all rows are rejected.
-func (s *Service) insertWithCopy(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow, failedRows *[]FailedRow, fileName string) int {
+// COPY is atomic per batch - if it fails, all rows are rejected and the
+// error is returned so the caller can retry or fall back.
+func (s *Service) insertWithCopy(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow) error {
 	// Create savepoint so we can rollback if COPY fails
-	_, err := tx.Exec(ctx, "SAVEPOINT copy_sp")
-	if err != nil {
-		return len(batch) // Signal failure
+	if _, err := tx.Exec(ctx, "SAVEPOINT copy_sp"); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
 	}
 
 	// Convert validated rows to COPY format
@@ -114,32 +177,60 @@ func (s *Service) insertWithCopy(ctx context.Context, tx pgx.Tx, def TableDefini
 	}
 
 	// Execute COPY
-	_, err = tx.CopyFrom(ctx,
+	_, err := tx.CopyFrom(ctx,
 		pgx.Identifier{def.Info.Key},
 		def.CopyColumns,
 		pgx.CopyFromRows(copyRows),
 	)
 
 	if err != nil {
-		// COPY failed - rollback and signal failure
+		// COPY failed - rollback and report
 		_, _ = tx.Exec(ctx, "ROLLBACK TO SAVEPOINT copy_sp")
 		_, _ = tx.Exec(ctx, "RELEASE SAVEPOINT copy_sp")
-		return len(batch) // Signal that caller should try row-by-row
+		return err
 	}
 
 	// Success - release savepoint
 	_, _ = tx.Exec(ctx, "RELEASE SAVEPOINT copy_sp")
-	return 0
+	return nil
 }
 
-// insertRowByRow inserts rows one at a time with individual savepoints.
+// Row-by-row fallback savepoint statements. A single savepoint name is reused
+// for every row: ROLLBACK TO keeps the savepoint open, so after a failed row
+// the next insert runs without any savepoint round-trip, and after a
+// successful row the release and re-create are sent as one simple-protocol
+// statement. Constant SQL also avoids per-row fmt.Sprintf allocations.
+const (
+	rowSavepoint         = "SAVEPOINT row_sp"
+	rowSavepointRestart  = "RELEASE SAVEPOINT row_sp; SAVEPOINT row_sp"
+	rowSavepointRollback = "ROLLBACK TO SAVEPOINT row_sp"
+	rowSavepointRelease  = "RELEASE SAVEPOINT row_sp"
+)
+
+// insertRowByRow inserts rows one at a time, each protected by a savepoint.
 // Used as fallback when batch insert fails.
+//
+// Round-trips per row: 2 for a successful row (restart + insert) and 2 for a
+// failed row (insert + rollback), down from 3 with uniquely named savepoints.
+// def.Insert runs through pgx's per-connection statement cache (the default
+// exec mode), so the INSERT is parsed and planned once, not once per row.
 func (s *Service) insertRowByRow(ctx context.Context, tx pgx.Tx, def TableDefinition, batch []validatedRow, failedRows *[]FailedRow, fileName string) int {
 	failed := 0
 
-	for i, vr := range batch {
-		savepointName := fmt.Sprintf("row_sp_%d", i)
-		_, err := tx.Exec(ctx, fmt.Sprintf("SAVEPOINT %s", savepointName))
+	// needSavepoint is true when row_sp is not currently open (start of the
+	// batch, or a previous savepoint statement failed); needRestart is true
+	// when it is open but holds a successful insert that must be kept.
+	needSavepoint := true
+	needRestart := false
+
+	for _, vr := range batch {
+		var err error
+		switch {
+		case needSavepoint:
+			_, err = tx.Exec(ctx, rowSavepoint)
+		case needRestart:
+			_, err = tx.Exec(ctx, rowSavepointRestart)
+		}
 		if err != nil {
 			// If we can't create savepoint, mark row as failed
 			*failedRows = append(*failedRows, FailedRow{
@@ -149,12 +240,17 @@ func (s *Service) insertRowByRow(ctx context.Context, tx pgx.Tx, def TableDefini
 				Data:       vr.row,
 			})
 			failed++
+			needSavepoint = true
+			needRestart = false
 			continue
 		}
+		needSavepoint = false
 
 		if err := def.Insert(ctx, tx, vr.params); err != nil {
-			// Rollback and mark as failed
-			_, _ = tx.Exec(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", savepointName))
+			// Rollback and mark as failed; row_sp stays open for the next row
+			if _, rbErr := tx.Exec(ctx, rowSavepointRollback); rbErr != nil {
+				needSavepoint = true
+			}
 			*failedRows = append(*failedRows, FailedRow{
 				FileName:   fileName,
 				LineNumber: vr.lineNum,
@@ -162,10 +258,14 @@ func (s *Service) insertRowByRow(ctx context.Context, tx pgx.Tx, def TableDefini
 				Data:       vr.row,
 			})
 			failed++
+			needRestart = false
+			continue
 		}
+		needRestart = true
+	}
 
-		// Release savepoint (works for both success and rollback cases)
-		_, _ = tx.Exec(ctx, fmt.Sprintf("RELEASE SAVEPOINT %s", savepointName))
+	if !needSavepoint {
+		_, _ = tx.Exec(ctx, rowSavepointRelease)
 	}
 
 	return failed
@@ -224,6 +324,11 @@ func buildAndValidate(row []string, headerIdx HeaderIndex, def TableDefinition,
 		}
 	}
 
+	// Cross-field rules, once every field is valid on its own
+	if err := checkRowRule(row, headerIdx, def); err != nil {
+		return nil, err
+	}
+
 	// Build params using the table's build function with uploadID
 	return def.BuildParams(row, headerIdx, uploadID)
 }
@@ -254,6 +359,7 @@ func sanitizeUTF8(data []byte) []byte {
 
 func parseCSV(data []byte) ([][]string, error) {
 	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sniffDelimiter(sniffHead(data))
 	r.FieldsPerRecord = -1
 	r.LazyQuotes = true
 	return r.ReadAll()
@@ -380,8 +486,13 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		UploadID: upload.ID,
 		TableKey: upload.TableKey,
 		FileName: fileName,
+		Mode:     upload.Mode,
 	}
 
+	// recordID is set once the upload commits; hooks see a rollback until then
+	var recordID string
+	defer func() { s.finishUploadHooks(ctx, upload, result, recordID) }()
+
 	// Strip BOM if present
 	fileData = stripBOM(fileData)
 
@@ -391,6 +502,13 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		return result
 	}
 
+	fileData, err := jsonAsCSV(fileName, fileData, def, len(upload.Mapping) > 0)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	totalBytes = int64(len(fileData))
+
 	// Create counting reader for byte-based progress
 	cr := &countingReader{
 		r:     bytes.NewReader(fileData),
@@ -407,6 +525,7 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 
 	// Create CSV reader
 	csvReader := csv.NewReader(cr)
+	csvReader.Comma = sniffDelimiter(sniffHead(fileData))
 	csvReader.FieldsPerRecord = -1 // Allow variable field counts
 	csvReader.LazyQuotes = true    // Be lenient with quoting
 
@@ -444,7 +563,18 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		headerRow := headerBuffer[0]
 		csvHeaderRow = headerRow
 		headerRowIndex = 0
-		csvHeaderIdx = buildMappedHeaderIndex(upload.Mapping, headerRow)
+		csvHeaderIdx = buildMappedHeaderIndex(resolveMappingColumns(def, upload.Mapping, "upload mapping"), headerRow)
+		drift, err := s.checkTemplateDrift(upload.Template, upload.TableKey, headerRow)
+		result.TemplateDrift = drift
+		if err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			return result
+		}
 	} else {
 		// Auto-detect header row in buffered rows
 		headerIdx := findHeaderInRecords(headerBuffer, def.Info.Columns)
@@ -462,9 +592,31 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		csvHeaderIdx = MakeHeaderIndex(csvHeaderRow)
 	}
 
-	expectedCols := len(def.Info.Columns)
+	// The template's transforms and the mapping's constants build some
+	// columns; the file supplies the rest
+	transformer := newRowTransformer(upload.Transforms, upload.Constants, csvHeaderIdx, len(csvHeaderRow))
+	expectedCols := len(def.Info.Columns) - transformer.built()
 
-	// Create upload record for tracking
+	// Begin transaction. READ COMMITTED lets a batch that hit a
+	// serialization failure be repeated in it (see retryablePgCodes).
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		result.Error = fmt.Sprintf("begin transaction: %v", err)
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = PhaseFailed
+			p.Error = result.Error
+		})
+		upload.notifyProgress()
+		return result
+	}
+	defer s.rollbackTx(ctx, tx)
+
+	// Create upload record for tracking. A dry run creates it inside the
+	// transaction so the rollback discards it along with the rows
+	var recordDB db.DBTX = s.pool
+	if upload.DryRun {
+		recordDB = tx
+	}
 	var uploadID pgtype.UUID
 	createParams := db.CreateUploadRecordParams{
 		Name:   upload.TableKey,
@@ -474,7 +626,7 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		createParams.FileName.String = fileName
 		createParams.FileName.Valid = true
 	}
-	uploadID, err := db.New(s.pool).CreateUploadRecord(ctx, createParams)
+	uploadID, err = db.New(recordDB).CreateUploadRecord(ctx, createParams)
 	if err != nil {
 		result.Error = fmt.Sprintf("create upload record: %v", err)
 		upload.setProgress(func(p *UploadProgress) {
@@ -484,11 +636,8 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		upload.notifyProgress()
 		return result
 	}
-
-	// Begin transaction
-	tx, err := s.pool.Begin(ctx)
-	if err != nil {
-		result.Error = fmt.Sprintf("begin transaction: %v", err)
+	if err := s.linkUploadRecord(ctx, recordDB, upload, uploadID); err != nil {
+		result.Error = err.Error()
 		upload.setProgress(func(p *UploadProgress) {
 			p.Phase = PhaseFailed
 			p.Error = result.Error
@@ -496,15 +645,31 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		upload.notifyProgress()
 		return result
 	}
-	defer tx.Rollback(ctx)
+
+	// Replace mode starts from an empty table; the delete is only visible
+	// once the upload commits
+	if upload.Mode == UploadModeReplace {
+		upload.Op.Begin(stepReplace)
+		if result.Replaced, err = s.clearForReplace(ctx, tx, def); err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			return result
+		}
+	}
 
 	upload.setProgress(func(p *UploadProgress) {
 		p.Phase = PhaseInserting
 	})
 	upload.notifyProgress()
 
+	dups := newDuplicateGuard(def, upload.Duplicates, uploadID, csvHeaderIdx)
 	var failedRows []FailedRow
 	var totalProcessed int
+	var batches int
 	lineNum := headerRowIndex + 2 // 1-indexed, after header
 
 	// Pre-allocate batch slice (reused across batches)
@@ -516,8 +681,25 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 			return nil
 		}
 
-		batchFailed := s.insertBatch(ctx, tx, def, batch, &failedRows, fileName)
-		batchInserted := len(batch) - batchFailed
+		batchInserted, batchRetries, err := s.writeBatch(ctx, tx, upload, def, uploadID, csvHeaderIdx, batch, &failedRows, fileName, dups)
+		result.Retries += batchRetries
+		if err != nil {
+			phase := PhaseFailed
+			result.Error = err.Error()
+			if ctx.Err() != nil {
+				// Cancelled mid-batch
+				phase, result.Error = PhaseCancelled, "cancelled"
+			}
+			if !upload.DryRun {
+				s.logDuplicateRejection(ctx, upload.TableKey, PgUUIDToString(uploadID), err)
+			}
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = phase
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			return err
+		}
 		result.Inserted += batchInserted
 
 		// Update progress (thread-safe)
@@ -531,11 +713,17 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		})
 		upload.notifyProgress()
 
+		batches++
+		s.afterBatchHooks(ctx, upload, batches, batchInserted, inserted, skipped)
+
 		// Reset batch (reuse backing array)
 		batch = batch[:0]
 		return nil
 	}
 
+	// Tallies ambiguous dates; nil unless the date format is automatic
+	dates := newDateStats(def, csvHeaderIdx, upload.Dates)
+
 	// Helper to validate and add a row to the batch
 	processRow := func(row []string) {
 		totalProcessed++
@@ -556,8 +744,23 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 			return
 		}
 
+		// Read dates in the upload's date format; failed rows keep the
+		// file's values
+		cells := transformer.apply(row)
+		dates.observe(cells)
+		normalized, err := normalizeDates(cells, csvHeaderIdx, def, upload.Dates)
+		if err != nil {
+			failedRows = append(failedRows, FailedRow{
+				FileName:   fileName,
+				LineNumber: lineNum,
+				Reason:     err.Error(),
+				Data:       row,
+			})
+			return
+		}
+
 		// Validate and build params
-		params, err := buildAndValidate(row, csvHeaderIdx, def, uploadID)
+		params, err := buildAndValidate(normalized, csvHeaderIdx, def, uploadID)
 		if err != nil {
 			failedRows = append(failedRows, FailedRow{
 				FileName:   fileName,
@@ -572,14 +775,39 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 			index:   totalProcessed - 1,
 			lineNum: lineNum,
 			params:  params,
-			row:     row,
+			row:     normalized,
+		})
+
+		if upload.DryRun && len(def.Info.UniqueKey) > 0 {
+			if key := extractUniqueKey(normalized, csvHeaderIdx, def.Info.UniqueKey); key != "" {
+				upload.RowKeys[key] = append(upload.RowKeys[key], lineNum)
+			}
+		}
+	}
+
+	// Helper to fail the upload once the table's row limit is exceeded.
+	// The deferred rollback discards rows inserted so far.
+	exceedsRowLimit := func() bool {
+		msg := rowLimitError(def, totalProcessed)
+		if msg == "" {
+			return false
+		}
+		result.Error = msg
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = PhaseFailed
+			p.Error = result.Error
 		})
+		upload.notifyProgress()
+		return true
 	}
 
 	// Process data rows from header buffer (after header row)
 	for i := headerRowIndex + 1; i < len(headerBuffer); i++ {
 		processRow(headerBuffer[i])
 		lineNum++
+		if exceedsRowLimit() {
+			return result
+		}
 
 		// Flush batch if full
 		if len(batch) >= s.cfg.Upload.BatchSize {
@@ -620,6 +848,9 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 
 		processRow(row)
 		lineNum++
+		if exceedsRowLimit() {
+			return result
+		}
 
 		// Flush batch if full
 		if len(batch) >= s.cfg.Upload.BatchSize {
@@ -634,7 +865,39 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 		return result
 	}
 
+	result.Duplicates = upload.Duplicates
+	result.DupSkipped = dups.Skipped()
+	result.DupInFile = dups.InFile()
+	result.Deleted = len(upload.Deleted)
+
+	// Upsert mode: drop earlier rows displaced by this upload's keys
+	if upload.Mode == UploadModeUpsert {
+		updated, err := s.applyUpsert(ctx, tx, def, uploadID, upload.Omitted)
+		if err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			return result
+		}
+		result.Updated = updated
+		result.Inserted -= updated
+	}
+
+	// A dry run stops here; the deferred rollback discards everything
+	if upload.DryRun {
+		result.TotalRows = totalProcessed
+		result.Skipped = len(failedRows)
+		result.FailedRows = failedRows
+		result.DateWarnings = dates.warnings()
+		result.Duration = time.Since(startTime)
+		return result
+	}
+
 	// Commit transaction
+	upload.Op.Begin(stepFinalize)
 	if err := tx.Commit(ctx); err != nil {
 		result.Error = fmt.Sprintf("commit: %v", err)
 		upload.setProgress(func(p *UploadProgress) {
@@ -650,22 +913,25 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 	if uploadID.Valid {
 		uploadIDStr = PgUUIDToString(uploadID)
 	}
+	recordID = uploadIDStr
 	s.LogAudit(ctx, AuditLogParams{
 		Action:       ActionUpload,
 		TableKey:     upload.TableKey,
 		UploadID:     uploadIDStr,
-		RowsAffected: result.Inserted,
+		BatchID:      upload.BatchID,
+		RowsAffected: result.Inserted + result.Updated + result.Deleted,
 		IPAddress:    GetIPAddressFromContext(ctx),
 		UserAgent:    GetUserAgentFromContext(ctx),
-		Reason:       fmt.Sprintf("Uploaded %s", fileName),
+		Reason:       uploadAuditReason(fileName, result),
 	})
+	s.logUploadDeletes(ctx, upload, uploadIDStr)
 
 	// Update upload record with final counts
 	if uploadID.Valid {
 		updateParams := db.UpdateUploadCountsParams{
 			ID: uploadID,
 		}
-		updateParams.RowsInserted.Int32 = int32(result.Inserted)
+		updateParams.RowsInserted.Int32 = int32(result.Inserted + result.Updated)
 		updateParams.RowsInserted.Valid = true
 		updateParams.RowsSkipped.Int32 = int32(len(failedRows))
 		updateParams.RowsSkipped.Valid = true
@@ -677,6 +943,22 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 				"error", err,
 			)
 		}
+		if dups != nil {
+			if err := recordDuplicateRows(ctx, s.pool, uploadID, result); err != nil {
+				slog.Error("failed to record duplicate rows",
+					"upload_id", upload.ID,
+					"error", err,
+				)
+			}
+		}
+		if len(def.Info.UniqueKey) > 0 {
+			if err := recordKeyViolations(ctx, s.pool, uploadID, countKeyViolations(result, failedRows)); err != nil {
+				slog.Error("failed to record key violations",
+					"upload_id", upload.ID,
+					"error", err,
+				)
+			}
+		}
 
 		// Store CSV headers for failed rows export
 		if len(csvHeaderRow) > 0 {
@@ -706,6 +988,7 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 	result.TotalRows = totalProcessed
 	result.Skipped = len(failedRows)
 	result.FailedRows = failedRows
+	result.DateWarnings = dates.warnings()
 	result.Duration = time.Since(startTime)
 
 	upload.setProgress(func(p *UploadProgress) {
@@ -716,6 +999,11 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 	})
 	upload.notifyProgress()
 
+	s.scheduleStatisticsRefresh(def, result.Inserted+result.Updated+result.Replaced+result.Deleted)
+	if uploadID.Valid && len(def.Info.UniqueKey) > 0 {
+		s.checkKeyViolations(def.Info.Key, uploadIDStr)
+	}
+
 	return result
 }
 
@@ -724,6 +1012,7 @@ func (s *Service) processStreamingRecords(ctx context.Context, upload *activeUpl
 // directly from an io.Reader, maintaining O(batch_size) constant memory usage.
 //
 // The StreamingCountingReader already wraps the input with:
+//   - Transcoding to UTF-8
 //   - BOM detection/skipping
 //   - UTF-8 sanitization
 //   - Byte counting for progress
@@ -735,21 +1024,34 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		close(upload.Done)
 		s.cleanup(upload.ID, 5*time.Minute)
 	}()
+	s.uploadStartedActivity(upload)
 
 	result := &UploadResult{
 		UploadID: upload.ID,
 		TableKey: upload.TableKey,
 		FileName: fileName,
+		Mode:     upload.Mode,
 	}
 
+	// recordID is set once the upload commits; hooks see a rollback until then
+	var recordID string
+	defer func() { s.finishUploadHooks(ctx, upload, result, recordID) }()
+
 	// Initialize progress
 	upload.setProgress(func(p *UploadProgress) {
 		p.Phase = PhaseReading
 	})
 	upload.notifyProgress()
 
-	// Create CSV reader directly from the streaming reader
-	csvReader := csv.NewReader(reader)
+	// Create CSV reader directly from the streaming reader; JSON is
+	// converted to CSV as it streams
+	var src io.Reader = reader
+	comma := reader.Delimiter()
+	if reader.IsJSON(fileName) {
+		src, comma = newJSONRecordReader(reader, def, len(upload.Mapping) > 0), ','
+	}
+	csvReader := csv.NewReader(src)
+	csvReader.Comma = comma
 	csvReader.FieldsPerRecord = -1 // Allow variable field counts
 	csvReader.LazyQuotes = true    // Be lenient with quoting
 
@@ -795,7 +1097,19 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		headerRow := headerBuffer[0]
 		csvHeaderRow = headerRow
 		headerRowIndex = 0
-		csvHeaderIdx = buildMappedHeaderIndex(upload.Mapping, headerRow)
+		csvHeaderIdx = buildMappedHeaderIndex(resolveMappingColumns(def, upload.Mapping, "upload mapping"), headerRow)
+		drift, err := s.checkTemplateDrift(upload.Template, upload.TableKey, headerRow)
+		result.TemplateDrift = drift
+		if err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			upload.Result = result
+			return
+		}
 	} else {
 		// Auto-detect header row in buffered rows
 		headerIdx := findHeaderInRecords(headerBuffer, def.Info.Columns)
@@ -814,7 +1128,10 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		csvHeaderIdx = MakeHeaderIndex(csvHeaderRow)
 	}
 
-	expectedCols := len(def.Info.Columns)
+	// The template's transforms and the mapping's constants build some
+	// columns; the file supplies the rest
+	transformer := newRowTransformer(upload.Transforms, upload.Constants, csvHeaderIdx, len(csvHeaderRow))
+	expectedCols := len(def.Info.Columns) - transformer.built()
 
 	// Create upload record for tracking
 	var uploadID pgtype.UUID
@@ -837,9 +1154,20 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		upload.Result = result
 		return
 	}
+	if err := s.linkUploadRecord(ctx, s.pool, upload, uploadID); err != nil {
+		result.Error = err.Error()
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = PhaseFailed
+			p.Error = result.Error
+		})
+		upload.notifyProgress()
+		upload.Result = result
+		return
+	}
 
-	// Begin transaction
-	tx, err := s.pool.Begin(ctx)
+	// Begin transaction. READ COMMITTED lets a batch that hit a
+	// serialization failure be repeated in it (see retryablePgCodes).
+	tx, err := s.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
 		result.Error = fmt.Sprintf("begin transaction: %v", err)
 		upload.setProgress(func(p *UploadProgress) {
@@ -850,15 +1178,33 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		upload.Result = result
 		return
 	}
-	defer tx.Rollback(ctx)
+	defer s.rollbackTx(ctx, tx)
+
+	// Replace mode starts from an empty table; the delete is only visible
+	// once the upload commits
+	if upload.Mode == UploadModeReplace {
+		upload.Op.Begin(stepReplace)
+		if result.Replaced, err = s.clearForReplace(ctx, tx, def); err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			upload.Result = result
+			return
+		}
+	}
 
 	upload.setProgress(func(p *UploadProgress) {
 		p.Phase = PhaseInserting
 	})
 	upload.notifyProgress()
 
+	dups := newDuplicateGuard(def, upload.Duplicates, uploadID, csvHeaderIdx)
 	var failedRows []FailedRow
 	var totalProcessed int
+	var batches int
 	lineNum := headerRowIndex + 2 // 1-indexed, after header
 
 	// Pre-allocate batch slice (reused across batches)
@@ -870,8 +1216,22 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 			return nil
 		}
 
-		batchFailed := s.insertBatch(ctx, tx, def, batch, &failedRows, fileName)
-		batchInserted := len(batch) - batchFailed
+		batchInserted, batchRetries, err := s.writeBatch(ctx, tx, upload, def, uploadID, csvHeaderIdx, batch, &failedRows, fileName, dups)
+		result.Retries += batchRetries
+		if err != nil {
+			phase := PhaseFailed
+			result.Error = err.Error()
+			if ctx.Err() != nil {
+				// Cancelled mid-batch
+				phase, result.Error = PhaseCancelled, "cancelled"
+			}
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = phase
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			return err
+		}
 		result.Inserted += batchInserted
 
 		// Update progress using streaming byte count (thread-safe)
@@ -885,11 +1245,17 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		})
 		upload.notifyProgress()
 
+		batches++
+		s.afterBatchHooks(ctx, upload, batches, batchInserted, inserted, skipped)
+
 		// Reset batch (reuse backing array)
 		batch = batch[:0]
 		return nil
 	}
 
+	// Tallies ambiguous dates; nil unless the date format is automatic
+	dates := newDateStats(def, csvHeaderIdx, upload.Dates)
+
 	// Helper to validate and add a row to the batch
 	processRow := func(row []string) {
 		totalProcessed++
@@ -910,8 +1276,23 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 			return
 		}
 
+		// Read dates in the upload's date format; failed rows keep the
+		// file's values
+		cells := transformer.apply(row)
+		dates.observe(cells)
+		normalized, err := normalizeDates(cells, csvHeaderIdx, def, upload.Dates)
+		if err != nil {
+			failedRows = append(failedRows, FailedRow{
+				FileName:   fileName,
+				LineNumber: lineNum,
+				Reason:     err.Error(),
+				Data:       row,
+			})
+			return
+		}
+
 		// Validate and build params
-		params, err := buildAndValidate(row, csvHeaderIdx, def, uploadID)
+		params, err := buildAndValidate(normalized, csvHeaderIdx, def, uploadID)
 		if err != nil {
 			failedRows = append(failedRows, FailedRow{
 				FileName:   fileName,
@@ -926,14 +1307,34 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 			index:   totalProcessed - 1,
 			lineNum: lineNum,
 			params:  params,
-			row:     row,
+			row:     normalized,
+		})
+	}
+
+	// Helper to fail the upload once the table's row limit is exceeded.
+	// The deferred rollback discards rows inserted so far.
+	exceedsRowLimit := func() bool {
+		msg := rowLimitError(def, totalProcessed)
+		if msg == "" {
+			return false
+		}
+		result.Error = msg
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = PhaseFailed
+			p.Error = result.Error
 		})
+		upload.notifyProgress()
+		return true
 	}
 
 	// Process data rows from header buffer (after header row)
 	for i := headerRowIndex + 1; i < len(headerBuffer); i++ {
 		processRow(headerBuffer[i])
 		lineNum++
+		if exceedsRowLimit() {
+			upload.Result = result
+			return
+		}
 
 		// Flush batch if full
 		if len(batch) >= s.cfg.Upload.BatchSize {
@@ -979,6 +1380,10 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 
 		processRow(row)
 		lineNum++
+		if exceedsRowLimit() {
+			upload.Result = result
+			return
+		}
 
 		// Flush batch if full
 		if len(batch) >= s.cfg.Upload.BatchSize {
@@ -995,7 +1400,30 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 		return
 	}
 
+	result.Duplicates = upload.Duplicates
+	result.DupSkipped = dups.Skipped()
+	result.DupInFile = dups.InFile()
+	result.Deleted = len(upload.Deleted)
+
+	// Upsert mode: drop earlier rows displaced by this upload's keys
+	if upload.Mode == UploadModeUpsert {
+		updated, err := s.applyUpsert(ctx, tx, def, uploadID, upload.Omitted)
+		if err != nil {
+			result.Error = err.Error()
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = result.Error
+			})
+			upload.notifyProgress()
+			upload.Result = result
+			return
+		}
+		result.Updated = updated
+		result.Inserted -= updated
+	}
+
 	// Commit transaction
+	upload.Op.Begin(stepFinalize)
 	if err := tx.Commit(ctx); err != nil {
 		result.Error = fmt.Sprintf("commit: %v", err)
 		upload.setProgress(func(p *UploadProgress) {
@@ -1012,22 +1440,25 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 	if uploadID.Valid {
 		uploadIDStr = PgUUIDToString(uploadID)
 	}
+	recordID = uploadIDStr
 	s.LogAudit(ctx, AuditLogParams{
 		Action:       ActionUpload,
 		TableKey:     upload.TableKey,
 		UploadID:     uploadIDStr,
-		RowsAffected: result.Inserted,
+		BatchID:      upload.BatchID,
+		RowsAffected: result.Inserted + result.Updated + result.Deleted,
 		IPAddress:    GetIPAddressFromContext(ctx),
 		UserAgent:    GetUserAgentFromContext(ctx),
-		Reason:       fmt.Sprintf("Uploaded %s", fileName),
+		Reason:       uploadAuditReason(fileName, result),
 	})
+	s.logUploadDeletes(ctx, upload, uploadIDStr)
 
 	// Update upload record with final counts
 	if uploadID.Valid {
 		updateParams := db.UpdateUploadCountsParams{
 			ID: uploadID,
 		}
-		updateParams.RowsInserted.Int32 = int32(result.Inserted)
+		updateParams.RowsInserted.Int32 = int32(result.Inserted + result.Updated)
 		updateParams.RowsInserted.Valid = true
 		updateParams.RowsSkipped.Int32 = int32(len(failedRows))
 		updateParams.RowsSkipped.Valid = true
@@ -1039,6 +1470,22 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 				"error", err,
 			)
 		}
+		if dups != nil {
+			if err := recordDuplicateRows(ctx, s.pool, uploadID, result); err != nil {
+				slog.Error("failed to record duplicate rows",
+					"upload_id", upload.ID,
+					"error", err,
+				)
+			}
+		}
+		if len(def.Info.UniqueKey) > 0 {
+			if err := recordKeyViolations(ctx, s.pool, uploadID, countKeyViolations(result, failedRows)); err != nil {
+				slog.Error("failed to record key violations",
+					"upload_id", upload.ID,
+					"error", err,
+				)
+			}
+		}
 
 		// Store CSV headers for failed rows export
 		if len(csvHeaderRow) > 0 {
@@ -1068,6 +1515,7 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 	result.TotalRows = totalProcessed
 	result.Skipped = len(failedRows)
 	result.FailedRows = failedRows
+	result.DateWarnings = dates.warnings()
 	result.Duration = time.Since(startTime)
 
 	upload.setProgress(func(p *UploadProgress) {
@@ -1078,5 +1526,10 @@ func (s *Service) processUploadStreaming(ctx context.Context, upload *activeUplo
 	})
 	upload.notifyProgress()
 
+	s.scheduleStatisticsRefresh(def, result.Inserted+result.Updated+result.Replaced+result.Deleted)
+	if uploadID.Valid && len(def.Info.UniqueKey) > 0 {
+		s.checkKeyViolations(def.Info.Key, uploadIDStr)
+	}
+
 	upload.Result = result
 }
@@ -0,0 +1,721 @@
+package core
+
+// upload_batch.go groups several uploads under one batch ID.
+//
+// StartUploadBatch takes several files at once; AttachToUploadBatch adds
+// more to an existing batch, while it runs or later. Each file is a normal
+// upload with its own upload ID, progress stream, result and audit entry,
+// and the batch ID is stored on its csv_uploads row and audit entry (the
+// audit log's BatchID field). Files run one at a time in the order they
+// were added, so a batch holds at most one upload slot and a later file
+// sees the rows committed by earlier ones.
+//
+// A file that fails does not stop the rest of the batch: every file commits
+// or fails on its own, and RollbackUploadBatch reverts all of the batch's
+// active uploads in one transaction. Batch status is assembled from the
+// in-memory uploads while the batch is tracked and from csv_uploads after.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	db "github.com/JonMunkholm/TUI/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// batchRetention is how long an idle batch stays in memory, matching how
+// long finished uploads stay tracked.
+const batchRetention = 5 * time.Minute
+
+// ErrUploadBatchNotFound is returned for a batch ID with no tracked or
+// recorded uploads.
+var ErrUploadBatchNotFound = errors.New("upload batch not found")
+
+// BatchFile is one file of an upload batch.
+type BatchFile struct {
+	TableKey   string
+	FileName   string
+	Data       []byte          // CSV, or gzip-compressed CSV
+	Mapping    map[string]int  // Optional: expected column -> CSV index
+	Mode       UploadMode      // Empty uses the table's default
+	Duplicates DuplicatePolicy // Empty uses the mode's default
+	Dates      DateOptions     // Zero reads numeric dates month first with each column's year pivot
+}
+
+// BatchPhase summarizes the state of an upload batch.
+type BatchPhase string
+
+const (
+	BatchRunning    BatchPhase = "running"     // Some files are queued or processing
+	BatchComplete   BatchPhase = "complete"    // Every file committed
+	BatchFailed     BatchPhase = "failed"      // At least one file failed or was cancelled; others may have committed
+	BatchRolledBack BatchPhase = "rolled_back" // Every committed file was rolled back
+)
+
+// UploadBatchFile is the state of one file in a batch.
+type UploadBatchFile struct {
+	UploadID   string      `json:"uploadId,omitempty"` // Tracking ID; empty once the upload is no longer in memory
+	RecordID   string      `json:"recordId,omitempty"` // csv_uploads ID, once the upload record exists
+	TableKey   string      `json:"tableKey"`
+	FileName   string      `json:"fileName"`
+	Phase      UploadPhase `json:"phase"`
+	Percent    int         `json:"percent"`
+	TotalRows  int         `json:"totalRows"`
+	Inserted   int         `json:"inserted"`
+	Updated    int         `json:"updated"`
+	Deleted    int         `json:"deleted"`
+	Skipped    int         `json:"skipped"`
+	RolledBack bool        `json:"rolledBack"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// UploadBatchStatus is the aggregated state of an upload batch.
+type UploadBatchStatus struct {
+	BatchID        string            `json:"batchId"`
+	Phase          BatchPhase        `json:"phase"`
+	Percent        int               `json:"percent"`
+	TotalFiles     int               `json:"totalFiles"`
+	CompletedFiles int               `json:"completedFiles"`
+	FailedFiles    int               `json:"failedFiles"`
+	TotalRows      int               `json:"totalRows"`
+	Inserted       int               `json:"inserted"`
+	Updated        int               `json:"updated"`
+	Deleted        int               `json:"deleted"`
+	Skipped        int               `json:"skipped"`
+	Files          []UploadBatchFile `json:"files"`
+}
+
+// uploadBatch tracks the files of a batch and the queue still to run.
+type uploadBatch struct {
+	id string
+
+	mu        sync.Mutex
+	uploads   []*activeUpload // Every file added, in order
+	queue     []batchItem     // Files not yet started
+	running   bool            // A goroutine is draining the queue
+	idleSince time.Time
+	op        *Operation // One step per file; replaced if the batch is extended after finishing
+}
+
+// batchItem is a queued batch file.
+type batchItem struct {
+	upload *activeUpload
+	def    TableDefinition
+	data   []byte
+	ctx    context.Context // Cancelled by CancelUpload, even while queued
+
+	uploader string // Whose per-uploader slots the file takes
+}
+
+// StartUploadBatch validates every file and, if all pass, queues them as
+// one batch. Returns the batch ID and one upload ID per file, in order.
+// Files are checked against table limits up front, so a batch is either
+// started in full or not at all.
+func (s *Service) StartUploadBatch(ctx context.Context, files []BatchFile) (string, []string, error) {
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("upload batch has no files")
+	}
+
+	batchID := uuid.New().String()
+	items := make([]batchItem, 0, len(files))
+	for i, f := range files {
+		item, err := s.prepareBatchFile(ctx, batchID, f)
+		if err != nil {
+			return "", nil, fmt.Errorf("file %d (%s): %w", i+1, f.FileName, err)
+		}
+		items = append(items, item)
+	}
+	if err := s.chargeBatchQuota(ctx, items); err != nil {
+		return "", nil, err
+	}
+
+	return batchID, s.enqueueBatch(ctx, batchID, items), nil
+}
+
+// AttachToUploadBatch adds a file to an existing batch and returns its
+// upload ID. The file runs after any files still queued in the batch. A
+// batch that is no longer in memory can still be extended as long as its
+// uploads are recorded.
+func (s *Service) AttachToUploadBatch(ctx context.Context, batchID string, file BatchFile) (string, error) {
+	if _, err := uuid.Parse(batchID); err != nil {
+		return "", fmt.Errorf("invalid batch ID: %w", err)
+	}
+
+	s.mu.RLock()
+	_, ok := s.batches[batchID]
+	s.mu.RUnlock()
+	if !ok {
+		records, err := s.listBatchRecords(ctx, batchID)
+		if err != nil {
+			return "", err
+		}
+		if len(records) == 0 {
+			return "", fmt.Errorf("%w: %s", ErrUploadBatchNotFound, batchID)
+		}
+	}
+
+	item, err := s.prepareBatchFile(ctx, batchID, file)
+	if err != nil {
+		return "", err
+	}
+	if err := s.chargeBatchQuota(ctx, []batchItem{item}); err != nil {
+		return "", err
+	}
+
+	return s.enqueueBatch(ctx, batchID, []batchItem{item})[0], nil
+}
+
+// prepareBatchFile checks a file against its table and builds its upload in
+// the queued phase.
+func (s *Service) prepareBatchFile(ctx context.Context, batchID string, f BatchFile) (batchItem, error) {
+	def, ok := Get(f.TableKey)
+	if !ok {
+		return batchItem{}, fmt.Errorf("unknown table: %s", f.TableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return batchItem{}, err
+	}
+	mode, err := resolveUploadMode(def, f.Mode)
+	if err != nil {
+		return batchItem{}, err
+	}
+	mode, dups, err := resolveDuplicatePolicy(def, mode, f.Duplicates)
+	if err != nil {
+		return batchItem{}, err
+	}
+	dateOpts, err := f.Dates.validated()
+	if err != nil {
+		return batchItem{}, err
+	}
+	if f.Data, err = decompressBytes(f.Data, s.cfg.Upload.MaxFileSize); err != nil {
+		return batchItem{}, err
+	}
+	req := UploadRequest{TableKey: f.TableKey, FileName: f.FileName, Size: int64(len(f.Data)), Mapping: f.Mapping, Mode: mode, Duplicates: dups, Dates: dateOpts, BatchID: batchID}
+	if err := s.admitUpload(ctx, def, &req); err != nil {
+		return batchItem{}, err
+	}
+	f.Mapping, mode, dups, dateOpts = req.Mapping, req.Mode, req.Duplicates, req.Dates
+	def, omitted, err := s.selectImportColumns(ctx, def, mode)
+	if err != nil {
+		return batchItem{}, err
+	}
+	if err := s.checkUploadLimits(ctx, def, int64(len(f.Data))); err != nil {
+		return batchItem{}, err
+	}
+
+	uploadID := uuid.New().String()
+	uploadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx)) // Keeps the caller for audit
+	upload := &activeUpload{
+		ID:       uploadID,
+		TableKey: f.TableKey,
+		FileName: f.FileName,
+		Cancel:   cancel,
+		Progress: UploadProgress{
+			UploadID: uploadID,
+			TableKey: f.TableKey,
+			Phase:    PhaseQueued,
+			FileName: f.FileName,
+		},
+		Done:       make(chan struct{}),
+		Listeners:  make([]chan UploadProgress, 0),
+		Mapping:    f.Mapping,
+		Mode:       mode,
+		Duplicates: dups,
+		Dates:      dateOpts,
+		Omitted:    omitted,
+		BatchID:    batchID,
+	}
+	return batchItem{upload: upload, def: def, data: f.Data, ctx: uploadCtx, uploader: GetUploaderFromContext(ctx)}, nil
+}
+
+// chargeBatchQuota counts a batch's files against the daily quota of the
+// uploader in ctx, all or none.
+func (s *Service) chargeBatchQuota(ctx context.Context, items []batchItem) error {
+	var size int64
+	for _, item := range items {
+		size += int64(len(item.data))
+	}
+	return s.chargeUploadQuota(ctx, items[0].upload.TableKey, GetUploaderFromContext(ctx), len(items), size)
+}
+
+// enqueueBatch registers and queues items in the batch, tracking it again
+// if it was dropped from memory, and starts its worker if it is idle.
+// Returns the items' upload IDs.
+func (s *Service) enqueueBatch(ctx context.Context, batchID string, items []batchItem) []string {
+	ids := make([]string, len(items))
+
+	// Lock order matches cleanupBatch, so an idle batch cannot be dropped
+	// between being looked up and marked running
+	s.mu.Lock()
+	b, ok := s.batches[batchID]
+	if !ok {
+		b = &uploadBatch{id: batchID}
+		s.batches[batchID] = b
+	}
+	b.mu.Lock()
+	steps := make([]OperationStep, len(items))
+	for i, item := range items {
+		steps[i] = OperationStep{
+			Name:   fmt.Sprintf("%d. %s", len(b.uploads)+i+1, item.upload.FileName),
+			Weight: max(len(item.data)>>10, 1), // KiB, so large files weigh more
+		}
+	}
+	var created []*Operation
+	if b.op.Finished() {
+		b.op = newOperation(batchID, OperationUploadBatch, steps)
+		s.bindOperation(ctx, b.op, "")
+		b.op.progress.ResultLink = "/api/upload-batch/" + batchID
+		s.operations[batchID] = b.op
+		created = append(created, b.op)
+	} else {
+		b.op.AddSteps(steps...)
+	}
+	for i, item := range items {
+		op := newOperation(item.upload.ID, OperationUpload, uploadSteps(item.upload.Mode))
+		s.bindOperation(ctx, op, item.upload.TableKey)
+		op.attachTo(b.op, steps[i].Name)
+		item.upload.Op = op
+		s.operations[op.ID()] = op
+		s.uploads[item.upload.ID] = item.upload
+		b.uploads = append(b.uploads, item.upload)
+		ids[i] = item.upload.ID
+		created = append(created, op)
+	}
+	b.queue = append(b.queue, items...)
+	start := !b.running
+	b.running = true
+	b.mu.Unlock()
+	s.mu.Unlock()
+
+	for _, op := range created {
+		s.saveOperation(op)
+	}
+
+	if start {
+		go s.runUploadBatch(b)
+	}
+	return ids
+}
+
+// runUploadBatch processes queued files one at a time until the queue is
+// empty, then schedules the batch's removal from memory.
+func (s *Service) runUploadBatch(b *uploadBatch) {
+	for {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			b.running = false
+			b.idleSince = time.Now()
+			b.op.Finish(failedStepsError(b.op.Progress(), "files"))
+			b.mu.Unlock()
+			s.cleanupBatch(b, batchRetention)
+			return
+		}
+		item := b.queue[0]
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+
+		s.runBatchItem(item)
+	}
+}
+
+// runBatchItem waits for an upload slot and processes one batch file.
+func (s *Service) runBatchItem(item batchItem) {
+	upload := item.upload
+	ctx, cancel := context.WithTimeout(item.ctx, s.UploadTimeout())
+	defer cancel()
+
+	release, err := s.acquireUploadSlot(ctx, upload.TableKey, item.uploader)
+	if err != nil {
+		phase := PhaseFailed
+		if errors.Is(err, context.Canceled) {
+			phase = PhaseCancelled
+		}
+		msg := err.Error()
+		upload.setProgress(func(p *UploadProgress) {
+			p.Phase = phase
+			p.Error = msg
+		})
+		upload.Result = &UploadResult{
+			UploadID: upload.ID,
+			TableKey: upload.TableKey,
+			FileName: upload.FileName,
+			Mode:     upload.Mode,
+			Error:    msg,
+		}
+		upload.notifyProgress()
+		upload.closeListeners()
+		close(upload.Done)
+		s.cleanup(upload.ID, batchRetention)
+		return
+	}
+	defer release()
+
+	// processUpload closes Done itself, so only the failure is recorded here
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("panic in batch upload",
+				"upload_id", upload.ID,
+				"batch_id", upload.BatchID,
+				"table", upload.TableKey,
+				"panic", r,
+			)
+			upload.setProgress(func(p *UploadProgress) {
+				p.Phase = PhaseFailed
+				p.Error = fmt.Sprintf("internal error: %v", r)
+			})
+			upload.notifyProgress()
+		}
+	}()
+	s.processUpload(ctx, upload, item.def, item.data)
+}
+
+// cleanupBatch removes the batch from memory once it has been idle for
+// delay. A batch extended in the meantime is kept.
+func (s *Service) cleanupBatch(b *uploadBatch, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if !b.running && time.Since(b.idleSince) >= delay {
+			delete(s.batches, b.id)
+		}
+	})
+}
+
+// linkUploadRecord records the upload record's ID on upload, points the
+// upload's operation at the record, and stores the upload's batch ID and
+// duplicate policy on the record.
+func (s *Service) linkUploadRecord(ctx context.Context, q db.DBTX, upload *activeUpload, recordID pgtype.UUID) error {
+	upload.RecordID = PgUUIDToString(recordID)
+	upload.Op.SetResultLink("/upload/" + upload.RecordID)
+	if _, err := q.Exec(ctx, `UPDATE csv_uploads SET batch_id = $2, duplicate_policy = $3 WHERE id = $1`,
+		recordID, ToPgUUID(upload.BatchID), ToPgText(string(upload.Duplicates))); err != nil {
+		return fmt.Errorf("link upload record: %w", err)
+	}
+	return nil
+}
+
+// batchRecord is a batch upload as stored in csv_uploads.
+type batchRecord struct {
+	ID         string
+	TableKey   string
+	FileName   string
+	Inserted   int
+	Skipped    int
+	RolledBack bool
+}
+
+// listBatchRecords returns the recorded uploads of a batch, oldest first.
+func (s *Service) listBatchRecords(ctx context.Context, batchID string) ([]batchRecord, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, name, COALESCE(file_name, ''), COALESCE(rows_inserted, 0),
+		       COALESCE(rows_skipped, 0), status = 'rolled_back'
+		FROM csv_uploads
+		WHERE batch_id = $1
+		  AND deleted_at IS NULL
+		ORDER BY uploaded_at, id`, ToPgUUID(batchID))
+	if err != nil {
+		return nil, fmt.Errorf("query batch uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var records []batchRecord
+	for rows.Next() {
+		var id pgtype.UUID
+		var rec batchRecord
+		var inserted, skipped int32
+		if err := rows.Scan(&id, &rec.TableKey, &rec.FileName, &inserted, &skipped, &rec.RolledBack); err != nil {
+			return nil, fmt.Errorf("scan batch upload: %w", err)
+		}
+		rec.ID = PgUUIDToString(id)
+		rec.Inserted = int(inserted)
+		rec.Skipped = int(skipped)
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return records, nil
+}
+
+// GetUploadBatch returns the per-file and aggregated state of a batch.
+// Files still in memory report live progress; files recorded only in
+// csv_uploads report their stored counts, and a failed file that is no
+// longer tracked shows as complete with whatever it recorded.
+func (s *Service) GetUploadBatch(ctx context.Context, batchID string) (*UploadBatchStatus, error) {
+	if _, err := uuid.Parse(batchID); err != nil {
+		return nil, fmt.Errorf("invalid batch ID: %w", err)
+	}
+
+	records, err := s.listBatchRecords(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	b := s.batches[batchID]
+	s.mu.RUnlock()
+	if b == nil && len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUploadBatchNotFound, batchID)
+	}
+
+	byRecord := make(map[string]batchRecord, len(records))
+	for _, rec := range records {
+		byRecord[rec.ID] = rec
+	}
+
+	files := []UploadBatchFile{}
+	if b != nil {
+		b.mu.Lock()
+		uploads := append([]*activeUpload(nil), b.uploads...)
+		b.mu.Unlock()
+		for _, u := range uploads {
+			f := batchFileFromUpload(u)
+			if rec, ok := byRecord[f.RecordID]; ok {
+				f.RolledBack = rec.RolledBack
+				delete(byRecord, f.RecordID)
+			}
+			files = append(files, f)
+		}
+	}
+	for _, rec := range records {
+		if _, ok := byRecord[rec.ID]; !ok {
+			continue // Already reported from memory
+		}
+		files = append(files, UploadBatchFile{
+			RecordID:   rec.ID,
+			TableKey:   rec.TableKey,
+			FileName:   rec.FileName,
+			Phase:      PhaseComplete,
+			Percent:    100,
+			TotalRows:  rec.Inserted + rec.Skipped,
+			Inserted:   rec.Inserted,
+			Skipped:    rec.Skipped,
+			RolledBack: rec.RolledBack,
+		})
+	}
+
+	status := summarizeUploadBatch(files)
+	status.BatchID = batchID
+	return &status, nil
+}
+
+// batchFileFromUpload reports a tracked upload, using its final result
+// once it has finished.
+func batchFileFromUpload(u *activeUpload) UploadBatchFile {
+	p := u.getProgress()
+	f := UploadBatchFile{
+		UploadID:  u.ID,
+		TableKey:  u.TableKey,
+		FileName:  u.FileName,
+		Phase:     p.Phase,
+		Percent:   p.Percent(),
+		TotalRows: p.TotalRows,
+		Inserted:  p.Inserted,
+		Skipped:   p.Skipped,
+		Error:     p.Error,
+	}
+
+	select {
+	case <-u.Done:
+	default:
+		return f
+	}
+	f.RecordID = u.RecordID
+	if r := u.Result; r != nil {
+		f.TotalRows = r.TotalRows
+		f.Inserted = r.Inserted
+		f.Updated = r.Updated
+		f.Deleted = r.Deleted
+		f.Skipped = r.Skipped
+		if r.Error != "" {
+			f.Error = r.Error
+		}
+	}
+	if f.Phase == PhaseComplete {
+		f.Percent = 100
+	}
+	return f
+}
+
+// summarizeUploadBatch aggregates file states into a batch status.
+func summarizeUploadBatch(files []UploadBatchFile) UploadBatchStatus {
+	status := UploadBatchStatus{
+		TotalFiles: len(files),
+		Files:      files,
+	}
+
+	running, committed, rolledBack, percent := false, 0, 0, 0
+	for _, f := range files {
+		percent += f.Percent
+		switch f.Phase {
+		case PhaseComplete:
+			status.CompletedFiles++
+			committed++
+			if f.RolledBack {
+				rolledBack++
+			}
+		case PhaseFailed, PhaseCancelled:
+			status.FailedFiles++
+		default:
+			running = true
+		}
+		status.TotalRows += f.TotalRows
+		status.Inserted += f.Inserted
+		status.Updated += f.Updated
+		status.Deleted += f.Deleted
+		status.Skipped += f.Skipped
+	}
+	if len(files) > 0 {
+		status.Percent = percent / len(files)
+	}
+
+	switch {
+	case running:
+		status.Phase = BatchRunning
+	case committed > 0 && rolledBack == committed:
+		status.Phase = BatchRolledBack
+	case status.FailedFiles > 0:
+		status.Phase = BatchFailed
+	default:
+		status.Phase = BatchComplete
+	}
+	return status
+}
+
+// RollbackUploadBatch rolls back every active upload in a batch, newest
+// first, in a single transaction: either all of them are reverted or none
+// are. A batch with files still queued or processing is refused.
+func (s *Service) RollbackUploadBatch(ctx context.Context, batchID string) (RollbackBatchResult, error) {
+	result := RollbackBatchResult{
+		BatchID: batchID,
+		Uploads: []RollbackResult{},
+	}
+	if _, err := uuid.Parse(batchID); err != nil {
+		result.Error = fmt.Sprintf("invalid batch ID: %v", err)
+		return result, fmt.Errorf("invalid batch ID: %w", err)
+	}
+
+	s.mu.RLock()
+	b := s.batches[batchID]
+	s.mu.RUnlock()
+	if b != nil {
+		b.mu.Lock()
+		running := b.running
+		b.mu.Unlock()
+		if running {
+			result.Error = "upload batch is still processing"
+			return result, fmt.Errorf("upload batch %s is still processing", batchID)
+		}
+	}
+
+	records, err := s.listBatchRecords(ctx, batchID)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	if len(records) == 0 {
+		result.Error = "upload batch not found"
+		return result, fmt.Errorf("%w: %s", ErrUploadBatchNotFound, batchID)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	for _, rec := range records {
+		if def, ok := Get(rec.TableKey); ok && !rec.RolledBack {
+			if err := s.checkLegalHold(ctx, def, "rollback", "upload_id = $1", ToPgUUID(rec.ID)); err != nil {
+				result.Error = err.Error()
+				return result, err
+			}
+		}
+	}
+	req := approvalRequest{kind: ApprovalRollbackBatch, target: batchID}
+	if err := s.requireApproval(ctx, req, func(ctx context.Context, limit int64) (int64, error) {
+		var total int64
+		for _, rec := range records {
+			if def, ok := Get(rec.TableKey); ok && !rec.RolledBack {
+				n, err := s.countRows(ctx, def, limit, "upload_id = $1", ToPgUUID(rec.ID))
+				if err != nil {
+					return 0, err
+				}
+				total += n
+			}
+		}
+		return total, nil
+	}); err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		result.Error = fmt.Sprintf("begin transaction: %v", err)
+		return result, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer s.rollbackTx(ctx, tx)
+
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if rec.RolledBack {
+			continue
+		}
+		def, ok := Get(rec.TableKey)
+		if !ok || def.DeleteByUploadID == nil {
+			result.Error = fmt.Sprintf("upload %s: table %s does not support rollback", rec.ID, rec.TableKey)
+			return result, fmt.Errorf("upload %s: table %s does not support rollback", rec.ID, rec.TableKey)
+		}
+		pgUUID := ToPgUUID(rec.ID)
+		n, err := def.DeleteByUploadID(ctx, tx, pgUUID)
+		if err != nil {
+			result.Error = fmt.Sprintf("delete failed for upload %s: %v", rec.ID, err)
+			return result, fmt.Errorf("delete by upload ID %s: %w", rec.ID, err)
+		}
+		if err := db.New(tx).MarkUploadRolledBack(ctx, pgUUID); err != nil {
+			result.Error = fmt.Sprintf("mark upload %s rolled back: %v", rec.ID, err)
+			return result, fmt.Errorf("mark upload rolled back: %w", err)
+		}
+		result.Uploads = append(result.Uploads, RollbackResult{
+			UploadID:    rec.ID,
+			TableKey:    rec.TableKey,
+			RowsDeleted: n,
+			Success:     true,
+		})
+		result.RowsDeleted += n
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		result.Error = fmt.Sprintf("commit failed: %v", err)
+		result.Uploads = []RollbackResult{}
+		result.RowsDeleted = 0
+		return result, fmt.Errorf("commit: %w", err)
+	}
+
+	// Rollback entries share the batch ID with the batch's upload entries
+	for _, u := range result.Uploads {
+		s.LogAudit(ctx, AuditLogParams{
+			Action:       ActionUploadRollback,
+			TableKey:     u.TableKey,
+			UploadID:     u.UploadID,
+			BatchID:      batchID,
+			RowsAffected: int(u.RowsDeleted),
+			Reason:       "batch rollback",
+			IPAddress:    GetIPAddressFromContext(ctx),
+			UserAgent:    GetUserAgentFromContext(ctx),
+		})
+		ev := rollbackEvent(u.TableKey, u.UploadID, u.RowsDeleted)
+		ev.BatchID = batchID
+		s.emitUploadEvent(ctx, ev)
+	}
+
+	result.Success = true
+	return result, nil
+}
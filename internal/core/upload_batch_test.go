@@ -0,0 +1,58 @@
+package core
+
+import "testing"
+
+func TestSummarizeUploadBatch(t *testing.T) {
+	files := []UploadBatchFile{
+		{Phase: PhaseComplete, Percent: 100, TotalRows: 10, Inserted: 8, Skipped: 2},
+		{Phase: PhaseInserting, Percent: 50, TotalRows: 4, Inserted: 2},
+		{Phase: PhaseQueued},
+	}
+	got := summarizeUploadBatch(files)
+	if got.Phase != BatchRunning || got.Percent != 50 || got.TotalFiles != 3 || got.CompletedFiles != 1 {
+		t.Errorf("running batch = %+v", got)
+	}
+	if got.TotalRows != 14 || got.Inserted != 10 || got.Skipped != 2 {
+		t.Errorf("totals = %d/%d/%d", got.TotalRows, got.Inserted, got.Skipped)
+	}
+
+	tests := []struct {
+		name  string
+		files []UploadBatchFile
+		want  BatchPhase
+	}{
+		{"all complete", []UploadBatchFile{{Phase: PhaseComplete}, {Phase: PhaseComplete}}, BatchComplete},
+		{"one failed", []UploadBatchFile{{Phase: PhaseComplete}, {Phase: PhaseFailed}}, BatchFailed},
+		{"one cancelled", []UploadBatchFile{{Phase: PhaseCancelled}}, BatchFailed},
+		{"rolled back", []UploadBatchFile{{Phase: PhaseComplete, RolledBack: true}, {Phase: PhaseFailed}}, BatchRolledBack},
+		{"partly rolled back", []UploadBatchFile{{Phase: PhaseComplete, RolledBack: true}, {Phase: PhaseComplete}}, BatchComplete},
+	}
+	for _, tt := range tests {
+		if got := summarizeUploadBatch(tt.files); got.Phase != tt.want {
+			t.Errorf("%s: phase = %q, want %q", tt.name, got.Phase, tt.want)
+		}
+	}
+}
+
+func TestBatchFileFromUpload(t *testing.T) {
+	u := &activeUpload{
+		ID:       "u1",
+		TableKey: "t",
+		FileName: "a.csv",
+		Progress: UploadProgress{Phase: PhaseInserting, TotalRows: 10, CurrentRow: 5, Inserted: 5},
+		Done:     make(chan struct{}),
+		RecordID: "r1",
+	}
+	f := batchFileFromUpload(u)
+	if f.Phase != PhaseInserting || f.Percent != 50 || f.RecordID != "" {
+		t.Errorf("in progress file = %+v", f)
+	}
+
+	u.Progress.Phase = PhaseComplete
+	u.Result = &UploadResult{TotalRows: 10, Inserted: 7, Updated: 2, Skipped: 1}
+	close(u.Done)
+	f = batchFileFromUpload(u)
+	if f.Percent != 100 || f.RecordID != "r1" || f.Inserted != 7 || f.Updated != 2 || f.Skipped != 1 {
+		t.Errorf("finished file = %+v", f)
+	}
+}
@@ -0,0 +1,209 @@
+package core
+
+// upload_columns.go implements selective column imports: an upload that
+// carries only some of a table's columns, such as an enrichment file with
+// a key and one new attribute.
+//
+// The upload reads the file as if the table had only the selected columns:
+// the header is matched against them and the columns left out are not
+// required in it. The selection is checked before the upload starts: it
+// must include the table's unique key, and it may only leave out columns
+// the database accepts NULL for (FieldSpec.Required is a rule for complete
+// files, so it does not count here).
+//
+// Columns left out are NULL in inserted rows. In upsert mode a row that
+// replaces existing rows keeps their values instead, so an enrichment file
+// leaves every column it does not carry untouched.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrInvalidImportColumns is returned for a column selection the table
+// cannot be uploaded with.
+var ErrInvalidImportColumns = errors.New("invalid import columns")
+
+const ctxKeyImportColumns contextKey = "upload_import_columns"
+
+// ContextWithImportColumns limits an upload or dry run started with ctx to
+// columns. Empty imports every column.
+func ContextWithImportColumns(ctx context.Context, columns []string) context.Context {
+	return context.WithValue(ctx, ctxKeyImportColumns, columns)
+}
+
+// importColumnsFromContext returns the columns set by
+// ContextWithImportColumns, or nil.
+func importColumnsFromContext(ctx context.Context) []string {
+	cols, _ := ctx.Value(ctxKeyImportColumns).([]string)
+	return cols
+}
+
+// ParseImportColumns splits a comma-separated column list from a request.
+func ParseImportColumns(s string) []string {
+	var cols []string
+	for _, col := range strings.Split(s, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// importColumns returns def narrowed to columns, in table order, and the
+// database columns left out. Old column names are followed (see Renames).
+// No columns returns def unchanged.
+func importColumns(def TableDefinition, columns []string) (TableDefinition, []string, error) {
+	if len(columns) == 0 {
+		return def, nil, nil
+	}
+
+	selected := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		name := ResolveColumnName(def, col, "upload columns")
+		found := false
+		for _, c := range def.Info.Columns {
+			if strings.EqualFold(c, name) {
+				selected[strings.ToLower(c)] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return def, nil, fmt.Errorf("%w: %s has no column %q", ErrInvalidImportColumns, def.Info.Key, col)
+		}
+	}
+	var missingKey []string
+	for _, key := range def.Info.UniqueKey {
+		if !selected[strings.ToLower(key)] {
+			missingKey = append(missingKey, key)
+		}
+	}
+	if len(missingKey) > 0 {
+		return def, nil, fmt.Errorf("%w: the unique key column(s) %s must be imported", ErrInvalidImportColumns, strings.Join(missingKey, ", "))
+	}
+
+	narrowed := def
+	narrowed.Info.Columns = nil
+	for _, c := range def.Info.Columns {
+		if selected[strings.ToLower(c)] {
+			narrowed.Info.Columns = append(narrowed.Info.Columns, c)
+		}
+	}
+	narrowed.FieldSpecs = make([]FieldSpec, len(def.FieldSpecs))
+	var omitted []string
+	for i, spec := range def.FieldSpecs {
+		if !selected[strings.ToLower(spec.Name)] {
+			spec.Required = false
+			omitted = append(omitted, resolveDBColumn(spec.Name, def.FieldSpecs))
+		}
+		narrowed.FieldSpecs[i] = spec
+	}
+	return narrowed, omitted, nil
+}
+
+// checkOmittedColumns fails if the database requires a value in any of
+// the omitted columns: NOT NULL without a default.
+func (s *Service) checkOmittedColumns(ctx context.Context, def TableDefinition, omitted []string) error {
+	if len(omitted) == 0 {
+		return nil
+	}
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		  AND is_nullable = 'NO' AND column_default IS NULL AND column_name = ANY($2)
+		ORDER BY ordinal_position`, def.Info.Key, omitted)
+	if err != nil {
+		return fmt.Errorf("check import columns: %w", err)
+	}
+	defer rows.Close()
+
+	var required []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return fmt.Errorf("check import columns: %w", err)
+		}
+		required = append(required, col)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("check import columns: %w", err)
+	}
+	if len(required) > 0 {
+		return fmt.Errorf("%w: the database requires a value in %s", ErrInvalidImportColumns, strings.Join(required, ", "))
+	}
+	return nil
+}
+
+// selectImportColumns narrows def to the columns set on ctx, checking the
+// selection against the table. It returns the database columns left out.
+// A delete upload reads only the unique key and inserts nothing, so no
+// columns are left out of an insert.
+func (s *Service) selectImportColumns(ctx context.Context, def TableDefinition, mode UploadMode) (TableDefinition, []string, error) {
+	if mode == UploadModeDelete {
+		narrowed, _, err := importColumns(def, def.Info.UniqueKey)
+		return narrowed, nil, err
+	}
+	narrowed, omitted, err := importColumns(def, importColumnsFromContext(ctx))
+	if err != nil {
+		return def, nil, err
+	}
+	if err := s.checkOmittedColumns(ctx, def, omitted); err != nil {
+		return def, nil, err
+	}
+	return narrowed, omitted, nil
+}
+
+// keepOmittedSQL builds the statement that copies the omitted columns of
+// the rows an upsert replaces into upload $1's rows with the same key, so
+// the upsert leaves them untouched. With several rows to replace, the
+// values come from the most recently uploaded one.
+func keepOmittedSQL(def TableDefinition, omitted []string) string {
+	table := quoteIdentifier(def.Info.Key)
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+
+	sets := make([]string, len(omitted))
+	for i, col := range omitted {
+		col = quoteIdentifier(col)
+		sets[i] = fmt.Sprintf("%s = prev.%s", col, col)
+	}
+	conds := make([]string, len(keyCols))
+	for i, col := range keyCols {
+		col = quoteIdentifier(col)
+		conds[i] = fmt.Sprintf("old.%s IS NOT DISTINCT FROM new.%s", col, col)
+	}
+	if live := liveRowsCondition(def, "old"); live != "" {
+		conds = append(conds, live)
+	}
+
+	return fmt.Sprintf(`UPDATE %s AS new SET %s
+FROM (
+	SELECT DISTINCT ON (new.id) new.id AS new_id, old.*
+	FROM %s AS new JOIN %s AS old
+	  ON %s
+	LEFT JOIN csv_uploads AS u ON u.id = old.upload_id
+	WHERE new.upload_id = $1
+	  AND old.upload_id IS DISTINCT FROM $1
+	ORDER BY new.id, u.uploaded_at DESC NULLS LAST
+) AS prev
+WHERE new.id = prev.new_id`, table, strings.Join(sets, ", "), table, table, strings.Join(conds, "\n\t AND "))
+}
+
+// keepOmittedColumns runs keepOmittedSQL within tx, before applyUpsert
+// deletes the replaced rows.
+func keepOmittedColumns(ctx context.Context, tx pgx.Tx, def TableDefinition, omitted []string, uploadID pgtype.UUID) error {
+	if len(omitted) == 0 || len(def.Info.UniqueKey) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, keepOmittedSQL(def, omitted), uploadID); err != nil {
+		return fmt.Errorf("keep columns not imported: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImportColumns(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{
+			Key:       "customers",
+			Columns:   []string{"internal_id", "name", "balance", "region"},
+			UniqueKey: []string{"internal_id"},
+		},
+		FieldSpecs: []FieldSpec{
+			{Name: "internal_id", Required: true},
+			{Name: "name", Required: true},
+			{Name: "balance", Type: FieldNumeric},
+			{Name: "region", DBColumn: "sales_region"},
+		},
+		Renames: []ColumnRename{{From: "territory", To: "region"}},
+	}
+
+	narrowed, omitted, err := importColumns(def, []string{"Territory", "INTERNAL_ID"})
+	if err != nil {
+		t.Fatalf("importColumns: %v", err)
+	}
+	if got := strings.Join(narrowed.Info.Columns, ","); got != "internal_id,region" {
+		t.Errorf("columns = %s, want table order", got)
+	}
+	if got := strings.Join(omitted, ","); got != "name,balance" {
+		t.Errorf("omitted = %s", got)
+	}
+	if narrowed.FieldSpecs[1].Required || !narrowed.FieldSpecs[0].Required {
+		t.Error("only omitted columns should stop being required")
+	}
+	if !def.FieldSpecs[1].Required || len(def.Info.Columns) != 4 {
+		t.Error("importColumns modified the table definition")
+	}
+
+	if same, omitted, err := importColumns(def, nil); err != nil || omitted != nil || len(same.Info.Columns) != 4 {
+		t.Errorf("no columns = %v, %v, %v; want the table unchanged", same.Info.Columns, omitted, err)
+	}
+	for _, cols := range [][]string{{"internal_id", "notes"}, {"name", "balance"}} {
+		if _, _, err := importColumns(def, cols); !errors.Is(err, ErrInvalidImportColumns) {
+			t.Errorf("importColumns(%v) error = %v, want ErrInvalidImportColumns", cols, err)
+		}
+	}
+}
+
+func TestKeepOmittedSQL(t *testing.T) {
+	def := TableDefinition{
+		Info:       TableInfo{Key: "customers", UniqueKey: []string{"internal_id"}},
+		FieldSpecs: []FieldSpec{{Name: "internal_id"}, {Name: "region", DBColumn: "sales_region"}},
+	}
+	got := keepOmittedSQL(def, []string{"name", "sales_region"})
+	for _, want := range []string{
+		`UPDATE "customers" AS new SET "name" = prev."name", "sales_region" = prev."sales_region"`,
+		`old."internal_id" IS NOT DISTINCT FROM new."internal_id"`,
+		`ORDER BY new.id, u.uploaded_at DESC NULLS LAST`,
+		`WHERE new.id = prev.new_id`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SQL missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+// upload_delete.go implements the delete upload mode, for the deletion
+// files source systems export: a file of unique keys whose rows are to be
+// removed.
+//
+// The file is read as if the table had only its unique key columns (see
+// upload_columns.go), so other columns may be present or not. Each batch
+// deletes the rows matching its keys inside the upload transaction, or
+// moves them to the recycle bin if the table uses soft delete; nothing is
+// inserted. A row whose key is incomplete, or matches no current row, is a
+// failed row like any other. Rows held by a legal hold fail the upload.
+//
+// Each deleted row is recorded in a row_delete audit entry linked to the
+// upload once it commits, so it can be restored (see row_restore.go).
+// Rolling back a delete upload cannot bring the rows back.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// deletedRow is a row removed by a delete upload, for its audit entry.
+type deletedRow struct {
+	key  string
+	data map[string]interface{}
+}
+
+// deleteByKeySQL builds the statement that deletes, or soft-deletes, the
+// current rows whose key is one of $2... and returns each row's key
+// ordinal followed by its columns.
+func deleteByKeySQL(def TableDefinition) string {
+	table := quoteIdentifier(def.Info.Key)
+	unnest, conds := keyJoin(def)
+	conds = append(conds, "t.upload_id IS DISTINCT FROM $1")
+	if live := liveRowsCondition(def, "t"); live != "" {
+		conds = append(conds, live)
+	}
+
+	cols := quoteColumns(resolveDBColumns(def.Info.Columns, def.FieldSpecs))
+	for i, col := range cols {
+		cols[i] = "t." + col
+	}
+	returning := "k.i, " + strings.Join(cols, ", ")
+	where := strings.Join(conds, "\n  AND ")
+
+	if def.SoftDelete {
+		return fmt.Sprintf(`UPDATE %s AS t SET %s = NOW()
+FROM %s
+WHERE %s
+RETURNING %s`, table, quoteIdentifier(softDeleteColumn), unnest, where, returning)
+	}
+	return fmt.Sprintf(`DELETE FROM %s AS t
+USING %s
+WHERE %s
+RETURNING %s`, table, unnest, where, returning)
+}
+
+// heldKeysCondition matches the rows deleteByKeySQL would delete, for
+// checkLegalHold.
+func heldKeysCondition(def TableDefinition) string {
+	unnest, conds := keyJoin(def)
+	conds = append(conds, "t.upload_id IS DISTINCT FROM $1")
+	return fmt.Sprintf("id IN (SELECT t.id FROM %s AS t, %s WHERE %s)",
+		quoteIdentifier(def.Info.Key), unnest, strings.Join(conds, " AND "))
+}
+
+// deleteKeyBatch deletes the rows matching the keys of batch within tx and
+// adds them to upload.Deleted. Rows whose key is incomplete or matches no
+// current row are added to failedRows.
+func (s *Service) deleteKeyBatch(ctx context.Context, tx pgx.Tx, upload *activeUpload, uploadID pgtype.UUID, headerIdx HeaderIndex, batch []validatedRow, failedRows *[]FailedRow, fileName string) error {
+	// The upload reads only the key columns; the audit entries record the
+	// whole row
+	def, ok := Get(upload.TableKey)
+	if !ok {
+		return fmt.Errorf("unknown table: %s", upload.TableKey)
+	}
+
+	specs := keySpecs(def)
+	var keys [][]string
+	var index []int
+	for i, vr := range batch {
+		parts, ok := rowKeyParts(specs, headerIdx, vr.row)
+		if !ok {
+			*failedRows = append(*failedRows, FailedRow{
+				FileName:   fileName,
+				LineNumber: vr.lineNum,
+				Reason:     fmt.Sprintf("incomplete key: %s must all have a value", strings.Join(def.Info.UniqueKey, ", ")),
+				Data:       vr.row,
+			})
+			continue
+		}
+		keys = append(keys, parts)
+		index = append(index, i)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := keyArgs(uploadID, keys)
+	if err := s.checkLegalHold(ctx, def, "delete upload", heldKeysCondition(def), args...); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, deleteByKeySQL(def), args...)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", def.Info.Key, err)
+	}
+	matched := make([]bool, len(keys))
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("scan deleted row: %w", err)
+		}
+		ord := int(values[0].(int64)) - 1
+		matched[ord] = true
+		data := make(map[string]interface{}, len(def.Info.Columns))
+		for i, col := range def.Info.Columns {
+			data[col] = values[i+1]
+		}
+		upload.Deleted = append(upload.Deleted, deletedRow{key: strings.Join(keys[ord], "|"), data: data})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("delete %s: %w", def.Info.Key, err)
+	}
+
+	for k, ok := range matched {
+		if ok {
+			continue
+		}
+		vr := batch[index[k]]
+		*failedRows = append(*failedRows, FailedRow{
+			FileName:   fileName,
+			LineNumber: vr.lineNum,
+			Reason:     fmt.Sprintf("no current row with key %s", strings.Join(keys[k], "|")),
+			Data:       vr.row,
+		})
+	}
+	return nil
+}
+
+// logUploadDeletes records the rows a committed delete upload removed,
+// one row_delete audit entry each.
+func (s *Service) logUploadDeletes(ctx context.Context, upload *activeUpload, uploadID string) {
+	for _, row := range upload.Deleted {
+		if _, err := s.LogAudit(ctx, AuditLogParams{
+			Action:       ActionRowDelete,
+			TableKey:     upload.TableKey,
+			RowKey:       row.key,
+			RowData:      row.data,
+			RowsAffected: 1,
+			UploadID:     uploadID,
+			BatchID:      upload.BatchID,
+			IPAddress:    GetIPAddressFromContext(ctx),
+			UserAgent:    GetUserAgentFromContext(ctx),
+		}); err != nil {
+			slog.Error("failed to record deleted row",
+				"upload_id", upload.ID,
+				"row_key", row.key,
+				"error", err,
+			)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeleteByKeySQL(t *testing.T) {
+	def := dupTestDef()
+	def.Info.Columns = []string{"Order ID", "Close Date", "Amount"}
+
+	got := deleteByKeySQL(def)
+	for _, want := range []string{
+		`DELETE FROM "dup_orders" AS t`,
+		`USING unnest($2::text[], $3::text[]) WITH ORDINALITY AS k(k1, k2, i)`,
+		`t."closed_on" = k.k2::DATE`,
+		`t.upload_id IS DISTINCT FROM $1`,
+		`RETURNING k.i, t."order_id", t."closed_on", t."amount"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SQL missing %q:\n%s", want, got)
+		}
+	}
+
+	def.SoftDelete = true
+	got = deleteByKeySQL(def)
+	for _, want := range []string{
+		`UPDATE "dup_orders" AS t SET "deleted_at" = NOW()`,
+		`t."deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("soft delete SQL missing %q:\n%s", want, got)
+		}
+	}
+
+	held := heldKeysCondition(def)
+	if !strings.HasPrefix(held, `id IN (SELECT t.id FROM "dup_orders" AS t, unnest(`) {
+		t.Errorf("held condition = %s", held)
+	}
+}
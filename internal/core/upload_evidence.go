@@ -0,0 +1,194 @@
+package core
+
+// upload_evidence.go bundles what an auditor needs to sign off an upload
+// into one zip file:
+//
+//	summary.json     The upload: table, file, row counts, status, review
+//	failed_rows.csv  Rows the upload rejected, as the failed-rows download
+//	audit_log.json   Every audit entry recorded against the upload, oldest first
+//	SHA256SUMS       Checksums of the files above, in sha256sum format
+//
+// The original file is not part of the package: uploaded files are not
+// retained once processed (see spool.go). summary.json carries its name,
+// headers and row counts instead.
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// UploadEvidenceSummary is summary.json of an evidence package.
+type UploadEvidenceSummary struct {
+	UploadID     string       `json:"upload_id"`
+	TableKey     string       `json:"table_key"`
+	FileName     string       `json:"file_name"`
+	UploadedAt   time.Time    `json:"uploaded_at"`
+	Status       string       `json:"status"` // active or rolled_back
+	RowsInserted int          `json:"rows_inserted"`
+	RowsSkipped  int          `json:"rows_skipped"`
+	DurationMs   int          `json:"duration_ms"`
+	CsvHeaders   []string     `json:"csv_headers,omitempty"`
+	Review       ReviewStatus `json:"review_status,omitempty"`
+	ReviewedAt   *time.Time   `json:"reviewed_at,omitempty"`
+	ReviewedBy   string       `json:"reviewed_by,omitempty"`
+	ReviewNote   string       `json:"review_note,omitempty"`
+	AuditEntries int          `json:"audit_entries"`
+	GeneratedAt  time.Time    `json:"generated_at"`
+}
+
+// UploadEvidence is the content of an upload's evidence package.
+type UploadEvidence struct {
+	Summary    UploadEvidenceSummary
+	FailedRows []FailedRowExport
+	Audit      []AuditEntry
+}
+
+// GetUploadEvidence gathers an upload's evidence package.
+func (s *Service) GetUploadEvidence(ctx context.Context, uploadID string) (*UploadEvidence, error) {
+	detail, err := s.GetUploadDetail(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	review, err := s.GetUploadReview(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	failed, err := s.GetFailedRows(ctx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("get failed rows: %w", err)
+	}
+	audit, err := s.uploadAuditEntries(ctx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("get audit entries: %w", err)
+	}
+
+	return &UploadEvidence{
+		Summary: UploadEvidenceSummary{
+			UploadID:     uploadID,
+			TableKey:     detail.TableKey,
+			FileName:     detail.FileName,
+			UploadedAt:   detail.UploadedAt,
+			Status:       review.Status,
+			RowsInserted: detail.RowsInserted,
+			RowsSkipped:  detail.RowsSkipped,
+			DurationMs:   detail.DurationMs,
+			CsvHeaders:   detail.CsvHeaders,
+			Review:       review.Review,
+			ReviewedAt:   review.ReviewedAt,
+			ReviewedBy:   review.ReviewedBy,
+			ReviewNote:   review.Note,
+			AuditEntries: len(audit),
+			GeneratedAt:  time.Now().UTC(),
+		},
+		FailedRows: failed,
+		Audit:      audit,
+	}, nil
+}
+
+// uploadAuditEntries returns the audit entries recorded against an upload,
+// oldest first, up to ExportLimit.
+func (s *Service) uploadAuditEntries(ctx context.Context, uploadID string) ([]AuditEntry, error) {
+	var id pgtype.UUID
+	if err := id.Scan(uploadID); err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT id, action, severity, table_key, user_id, user_email, user_name,
+		ip_address, user_agent, row_key, column_name, old_value, new_value,
+		row_data, rows_affected, upload_id, batch_id, related_audit_id, reason, created_at
+		FROM audit_log WHERE upload_id = $1 ORDER BY created_at LIMIT $2`, id, ExportLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditLogRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// WriteZip writes the evidence package as a zip file.
+func (e *UploadEvidence) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	var sums []string
+
+	add := func(name string, write func(io.Writer) error) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: e.Summary.GeneratedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+		hash := sha256.New()
+		if err := write(io.MultiWriter(f, hash)); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		sums = append(sums, hex.EncodeToString(hash.Sum(nil))+"  "+name+"\n")
+		return nil
+	}
+
+	err := add("summary.json", func(w io.Writer) error { return writeIndentedJSON(w, e.Summary) })
+	if err == nil {
+		err = add("failed_rows.csv", e.writeFailedRows)
+	}
+	if err == nil {
+		audit := e.Audit
+		if audit == nil {
+			audit = []AuditEntry{}
+		}
+		err = add("audit_log.json", func(w io.Writer) error { return writeIndentedJSON(w, audit) })
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "SHA256SUMS", Method: zip.Deflate, Modified: e.Summary.GeneratedAt})
+	if err != nil {
+		return fmt.Errorf("add SHA256SUMS: %w", err)
+	}
+	for _, line := range sums {
+		if _, err := io.WriteString(f, line); err != nil {
+			return fmt.Errorf("write SHA256SUMS: %w", err)
+		}
+	}
+	return zw.Close()
+}
+
+// writeFailedRows writes the failed rows with the upload's headers, as the
+// failed-rows download does.
+func (e *UploadEvidence) writeFailedRows(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"_line", "_error"}, e.Summary.CsvHeaders...))
+	for _, row := range e.FailedRows {
+		cw.Write(append([]string{strconv.Itoa(int(row.LineNumber)), row.Reason}, row.RowData...))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
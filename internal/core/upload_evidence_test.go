@@ -0,0 +1,81 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadEvidence_WriteZip(t *testing.T) {
+	e := &UploadEvidence{
+		Summary: UploadEvidenceSummary{
+			UploadID:     "6f1c2a9e-0000-4000-8000-000000000001",
+			TableKey:     "vendor_bills",
+			FileName:     "march.csv",
+			Status:       "active",
+			RowsInserted: 10,
+			RowsSkipped:  1,
+			CsvHeaders:   []string{"Vendor", "Amount"},
+			GeneratedAt:  time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		},
+		FailedRows: []FailedRowExport{{LineNumber: 4, Reason: "invalid amount", RowData: []string{"Acme", "x"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := e.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+
+	files := make(map[string]string)
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "summary.json,failed_rows.csv,audit_log.json,SHA256SUMS" {
+		t.Fatalf("files = %s", got)
+	}
+
+	if want := "_line,_error,Vendor,Amount\n4,invalid amount,Acme,x\n"; files["failed_rows.csv"] != want {
+		t.Errorf("failed_rows.csv = %q, want %q", files["failed_rows.csv"], want)
+	}
+	if strings.TrimSpace(files["audit_log.json"]) != "[]" {
+		t.Errorf("audit_log.json = %q, want empty array", files["audit_log.json"])
+	}
+	var summary UploadEvidenceSummary
+	if err := json.Unmarshal([]byte(files["summary.json"]), &summary); err != nil || summary.RowsSkipped != 1 {
+		t.Errorf("summary.json = %s (%v)", files["summary.json"], err)
+	}
+
+	// Every other file is listed with its checksum
+	lines := strings.Split(strings.TrimSpace(files["SHA256SUMS"]), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("SHA256SUMS has %d lines", len(lines))
+	}
+	for _, line := range lines {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			t.Fatalf("bad SHA256SUMS line %q", line)
+		}
+		h := sha256.Sum256([]byte(files[name]))
+		if hex.EncodeToString(h[:]) != sum {
+			t.Errorf("checksum of %s does not match", name)
+		}
+	}
+}
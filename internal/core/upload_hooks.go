@@ -0,0 +1,398 @@
+package core
+
+// upload_hooks.go lets deployments attach business logic to the upload
+// lifecycle without forking the pipeline. Hooks see four events:
+//
+//	before_upload   Before an upload is accepted; may reject it or change
+//	                its mode, duplicate policy or column mapping
+//	after_batch     After each batch is inserted, inside the upload's
+//	                transaction (the rows are not yet visible)
+//	after_commit    After the upload's rows are committed
+//	after_rollback  After an upload's rows are discarded: it failed or was
+//	                cancelled before commit, or was rolled back later
+//
+// Go hooks are registered at init with RegisterUploadHook and run in
+// registration order. Each URL in UPLOAD_HOOK_URLS also receives every
+// event as a JSON POST (see UploadEvent). Dry runs fire no hooks.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUploadRejected is returned when a before_upload hook rejects an upload.
+var ErrUploadRejected = errors.New("upload rejected")
+
+// UploadHookEvent names a point in the upload lifecycle.
+type UploadHookEvent string
+
+const (
+	HookBeforeUpload  UploadHookEvent = "before_upload"
+	HookAfterBatch    UploadHookEvent = "after_batch"
+	HookAfterCommit   UploadHookEvent = "after_commit"
+	HookAfterRollback UploadHookEvent = "after_rollback"
+)
+
+// UploadRequest is an upload about to be accepted. BeforeUpload hooks may
+// change Mapping, Mode, Duplicates and Dates; the result is validated
+// again.
+type UploadRequest struct {
+	TableKey   string
+	FileName   string
+	Size       int64 // Bytes, or 0 if unknown
+	Mapping    map[string]int
+	Mode       UploadMode
+	Duplicates DuplicatePolicy
+	Dates      DateOptions
+	BatchID    string
+}
+
+// UploadEvent describes an after_* event. It is also the body POSTed to
+// external hooks, with Event set to the event name.
+type UploadEvent struct {
+	Event      UploadHookEvent `json:"event"`
+	UploadID   string          `json:"upload_id,omitempty"` // In-memory upload (progress API)
+	RecordID   string          `json:"record_id,omitempty"` // csv_uploads row, once committed
+	TableKey   string          `json:"table_key"`
+	FileName   string          `json:"file_name,omitempty"`
+	Size       int64           `json:"size,omitempty"`
+	Mode       UploadMode      `json:"mode,omitempty"`
+	Duplicates DuplicatePolicy `json:"duplicates,omitempty"`
+	DateFormat DateFormat      `json:"date_format,omitempty"`
+	YearPivot  int             `json:"year_pivot,omitempty"`
+	BatchID    string          `json:"batch_id,omitempty"`
+
+	Batch       int    `json:"batch,omitempty"`        // after_batch: 1-based batch number
+	BatchRows   int    `json:"batch_rows,omitempty"`   // after_batch: rows inserted by the batch
+	Inserted    int    `json:"inserted,omitempty"`     // Rows inserted so far
+	Updated     int    `json:"updated,omitempty"`      // after_commit: rows replaced by upsert
+	Deleted     int    `json:"deleted,omitempty"`      // after_commit: rows deleted by a delete upload
+	Skipped     int    `json:"skipped,omitempty"`      // Rows that failed validation so far
+	RowsDeleted int64  `json:"rows_deleted,omitempty"` // after_rollback of a committed upload
+	Error       string `json:"error,omitempty"`        // after_rollback: why the upload failed
+
+	Time time.Time `json:"time"`
+}
+
+// UploadHook receives upload lifecycle events. Embed NopUploadHook to
+// implement only some of them.
+//
+// Hooks run synchronously on the upload's goroutine, so slow work (network
+// calls, notifications) should be handed off. A panicking hook is logged
+// and ignored.
+type UploadHook interface {
+	// BeforeUpload may modify req or return an error to reject the upload.
+	BeforeUpload(ctx context.Context, req *UploadRequest) error
+	AfterBatch(ctx context.Context, ev UploadEvent)
+	AfterCommit(ctx context.Context, ev UploadEvent)
+	AfterRollback(ctx context.Context, ev UploadEvent)
+}
+
+// NopUploadHook implements UploadHook with no-ops.
+type NopUploadHook struct{}
+
+func (NopUploadHook) BeforeUpload(context.Context, *UploadRequest) error { return nil }
+func (NopUploadHook) AfterBatch(context.Context, UploadEvent)            {}
+func (NopUploadHook) AfterCommit(context.Context, UploadEvent)           {}
+func (NopUploadHook) AfterRollback(context.Context, UploadEvent)         {}
+
+type namedUploadHook struct {
+	name string
+	hook UploadHook
+}
+
+var (
+	uploadHooksMu sync.RWMutex
+	uploadHooks   []namedUploadHook
+)
+
+// RegisterUploadHook adds a hook, or replaces the one registered under
+// name. Hooks run in the order they were first registered.
+func RegisterUploadHook(name string, h UploadHook) {
+	uploadHooksMu.Lock()
+	defer uploadHooksMu.Unlock()
+	for i := range uploadHooks {
+		if uploadHooks[i].name == name {
+			uploadHooks[i].hook = h
+			return
+		}
+	}
+	uploadHooks = append(uploadHooks, namedUploadHook{name: name, hook: h})
+}
+
+func registeredUploadHooks() []namedUploadHook {
+	uploadHooksMu.RLock()
+	defer uploadHooksMu.RUnlock()
+	return append([]namedUploadHook(nil), uploadHooks...)
+}
+
+// hookHTTPClient posts events to external hooks; requests are bounded by
+// UPLOAD_HOOK_TIMEOUT instead of a client timeout.
+var hookHTTPClient = &http.Client{}
+
+// hookResponse is the optional JSON body of an external before_upload
+// response. Empty fields leave the upload unchanged.
+type hookResponse struct {
+	Mode       UploadMode      `json:"mode"`
+	Duplicates DuplicatePolicy `json:"duplicates"`
+	DateFormat DateFormat      `json:"date_format"`
+	YearPivot  int             `json:"year_pivot"`
+	Error      string          `json:"error"`
+}
+
+// beforeUpload runs the before_upload hooks and applies their changes to
+// req, returning an error wrapping ErrUploadRejected if any rejects it.
+// External hooks that cannot be reached reject the upload too.
+func (s *Service) beforeUpload(ctx context.Context, def TableDefinition, req *UploadRequest) error {
+	for _, h := range registeredUploadHooks() {
+		if err := callBeforeUpload(ctx, h, req); err != nil {
+			return fmt.Errorf("%w by hook %s: %v", ErrUploadRejected, h.name, err)
+		}
+	}
+	for i, url := range s.cfg.Upload.HookURLs {
+		if err := s.postBeforeUpload(ctx, url, req); err != nil {
+			return fmt.Errorf("%w by external hook %d: %v", ErrUploadRejected, i+1, err)
+		}
+	}
+
+	mode, err := resolveUploadMode(def, req.Mode)
+	if err != nil {
+		return fmt.Errorf("%w: hook set %v", ErrUploadRejected, err)
+	}
+	mode, dups, err := resolveDuplicatePolicy(def, mode, req.Duplicates)
+	if err != nil {
+		return fmt.Errorf("%w: hook set %v", ErrUploadRejected, err)
+	}
+	dateOpts, err := req.Dates.validated()
+	if err != nil {
+		return fmt.Errorf("%w: hook set %v", ErrUploadRejected, err)
+	}
+	req.Mode, req.Duplicates, req.Dates = mode, dups, dateOpts
+	return nil
+}
+
+// admitUpload runs beforeUpload and records a rejection in the audit log.
+func (s *Service) admitUpload(ctx context.Context, def TableDefinition, req *UploadRequest) error {
+	err := s.beforeUpload(ctx, def, req)
+	if err != nil {
+		s.LogDenied(ctx, Denial{Kind: DenialPolicy, TableKey: req.TableKey, Detail: err.Error()})
+	}
+	return err
+}
+
+// callBeforeUpload runs one hook, turning a panic into a rejection.
+func callBeforeUpload(ctx context.Context, h namedUploadHook, req *UploadRequest) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("upload hook panicked", "hook", h.name, "event", HookBeforeUpload, "panic", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.hook.BeforeUpload(ctx, req)
+}
+
+// postBeforeUpload asks an external hook about req. A non-2xx response
+// rejects the upload; a 2xx JSON response may change its mode, duplicate
+// policy, date format or year pivot.
+func (s *Service) postBeforeUpload(ctx context.Context, url string, req *UploadRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Upload.HookTimeout)
+	defer cancel()
+
+	body, err := postHookEvent(ctx, url, UploadEvent{
+		Event:      HookBeforeUpload,
+		TableKey:   req.TableKey,
+		FileName:   req.FileName,
+		Size:       req.Size,
+		Mode:       req.Mode,
+		Duplicates: req.Duplicates,
+		DateFormat: req.Dates.Format,
+		YearPivot:  req.Dates.YearPivot,
+		BatchID:    req.BatchID,
+		Time:       time.Now(),
+	})
+	var resp hookResponse
+	if len(bytes.TrimSpace(body)) > 0 {
+		// Non-JSON bodies are allowed; they just carry no changes
+		_ = json.Unmarshal(body, &resp)
+	}
+	if err != nil {
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+		return err
+	}
+	if resp.Mode != "" {
+		req.Mode = resp.Mode
+	}
+	if resp.Duplicates != "" {
+		req.Duplicates = resp.Duplicates
+	}
+	if resp.DateFormat != "" {
+		req.Dates.Format = resp.DateFormat
+	}
+	if resp.YearPivot != 0 {
+		req.Dates.YearPivot = resp.YearPivot
+	}
+	return nil
+}
+
+// postHookEvent posts ev as JSON to url and returns up to 64KB of the
+// response body. Any non-2xx response is an error.
+func postHookEvent(ctx context.Context, url string, ev UploadEvent) ([]byte, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hookHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, fmt.Errorf("hook returned %s", resp.Status)
+	}
+	return body, nil
+}
+
+// emitUploadEvent delivers an after_* event: synchronously to Go hooks,
+// and in the background to external hooks, whose failures are logged.
+func (s *Service) emitUploadEvent(ctx context.Context, ev UploadEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	// The upload's own context may already be cancelled (after_rollback of
+	// a cancelled upload); hooks still get its values
+	ctx = context.WithoutCancel(ctx)
+
+	switch {
+	case ev.Event == HookAfterCommit:
+		s.notifyTableChanged(ev.TableKey, TableChangeUpload)
+	case ev.Event == HookAfterRollback && ev.RecordID != "":
+		s.notifyTableChanged(ev.TableKey, TableChangeRollback)
+	}
+	s.uploadActivity(ev)
+	s.uploadWebhook(ev)
+
+	for _, h := range registeredUploadHooks() {
+		callAfterHook(ctx, h, ev)
+	}
+
+	if len(s.cfg.Upload.HookURLs) == 0 {
+		return
+	}
+	timeout := s.cfg.Upload.HookTimeout
+	for _, url := range s.cfg.Upload.HookURLs {
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if _, err := postHookEvent(ctx, url, ev); err != nil {
+				slog.Error("failed to post upload hook event",
+					"event", ev.Event,
+					"table", ev.TableKey,
+					"upload_id", ev.UploadID,
+					"error", err,
+				)
+			}
+		}()
+	}
+}
+
+// callAfterHook runs one hook for ev, logging a panic instead of letting
+// it take down the upload.
+func callAfterHook(ctx context.Context, h namedUploadHook, ev UploadEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("upload hook panicked", "hook", h.name, "event", ev.Event, "panic", r)
+		}
+	}()
+	switch ev.Event {
+	case HookAfterBatch:
+		h.hook.AfterBatch(ctx, ev)
+	case HookAfterCommit:
+		h.hook.AfterCommit(ctx, ev)
+	case HookAfterRollback:
+		h.hook.AfterRollback(ctx, ev)
+	}
+}
+
+// uploadEvent builds an event for an in-flight upload.
+func uploadEvent(event UploadHookEvent, upload *activeUpload) UploadEvent {
+	return UploadEvent{
+		Event:      event,
+		UploadID:   upload.ID,
+		TableKey:   upload.TableKey,
+		FileName:   upload.FileName,
+		Mode:       upload.Mode,
+		Duplicates: upload.Duplicates,
+		DateFormat: upload.Dates.Format,
+		YearPivot:  upload.Dates.YearPivot,
+		BatchID:    upload.BatchID,
+	}
+}
+
+// afterBatchHooks fires after_batch for an upload's batch-th batch, which
+// inserted rows. inserted and skipped are the upload's running totals.
+func (s *Service) afterBatchHooks(ctx context.Context, upload *activeUpload, batch, rows, inserted, skipped int) {
+	if upload.DryRun {
+		return
+	}
+	ev := uploadEvent(HookAfterBatch, upload)
+	ev.Batch = batch
+	ev.BatchRows = rows
+	ev.Inserted = inserted
+	ev.Skipped = skipped
+	s.emitUploadEvent(ctx, ev)
+}
+
+// finishUploadHooks fires after_commit or after_rollback once an upload's
+// processing ends. recordID is the committed csv_uploads row, or empty if
+// the upload's rows were discarded.
+func (s *Service) finishUploadHooks(ctx context.Context, upload *activeUpload, result *UploadResult, recordID string) {
+	if upload.DryRun {
+		return
+	}
+	if recordID != "" {
+		ev := uploadEvent(HookAfterCommit, upload)
+		ev.RecordID = recordID
+		ev.Inserted = result.Inserted
+		ev.Updated = result.Updated
+		ev.Deleted = result.Deleted
+		ev.Skipped = result.Skipped
+		s.emitUploadEvent(ctx, ev)
+		return
+	}
+	ev := uploadEvent(HookAfterRollback, upload)
+	ev.Inserted = result.Inserted
+	ev.Skipped = result.Skipped
+	ev.Error = result.Error
+	if ev.Error == "" {
+		ev.Error = upload.getProgress().Error
+	}
+	s.emitUploadEvent(ctx, ev)
+}
+
+// rollbackEvent builds the after_rollback event for a committed upload
+// rolled back through the rollback API.
+func rollbackEvent(tableKey, recordID string, rowsDeleted int64) UploadEvent {
+	return UploadEvent{
+		Event:       HookAfterRollback,
+		RecordID:    recordID,
+		TableKey:    tableKey,
+		RowsDeleted: rowsDeleted,
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+// testUploadHook records events and runs before for BeforeUpload.
+type testUploadHook struct {
+	NopUploadHook
+	before func(*UploadRequest) error
+	events []UploadEvent
+}
+
+func (h *testUploadHook) BeforeUpload(_ context.Context, req *UploadRequest) error {
+	if h.before == nil {
+		return nil
+	}
+	return h.before(req)
+}
+
+func (h *testUploadHook) AfterCommit(_ context.Context, ev UploadEvent) {
+	h.events = append(h.events, ev)
+}
+
+func (h *testUploadHook) AfterRollback(_ context.Context, ev UploadEvent) {
+	h.events = append(h.events, ev)
+}
+
+func registerTestUploadHook(t *testing.T, name string, h UploadHook) {
+	t.Helper()
+	RegisterUploadHook(name, h)
+	t.Cleanup(func() {
+		uploadHooksMu.Lock()
+		defer uploadHooksMu.Unlock()
+		for i := range uploadHooks {
+			if uploadHooks[i].name == name {
+				uploadHooks = append(uploadHooks[:i], uploadHooks[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+func newHookTestService(urls ...string) *Service {
+	return &Service{cfg: &config.Config{Upload: config.UploadConfig{HookURLs: urls, HookTimeout: time.Second}}}
+}
+
+var hookTestTable = TableDefinition{Info: TableInfo{Key: "hook_orders", UniqueKey: []string{"Order ID"}}}
+
+func TestBeforeUpload_GoHooks(t *testing.T) {
+	s := newHookTestService()
+	ctx := context.Background()
+
+	var order []string
+	registerTestUploadHook(t, "first", &testUploadHook{before: func(req *UploadRequest) error {
+		order = append(order, "first")
+		req.Duplicates = DuplicateOverwrite
+		return nil
+	}})
+	registerTestUploadHook(t, "second", &testUploadHook{before: func(req *UploadRequest) error {
+		order = append(order, "second")
+		if strings.HasPrefix(req.FileName, "draft") {
+			return errors.New("drafts are not accepted")
+		}
+		return nil
+	}})
+
+	// Overwrite implies upsert once the hooks' changes are resolved
+	req := UploadRequest{TableKey: "hook_orders", FileName: "orders.csv", Mode: UploadModeInsert, Duplicates: DuplicateKeepBoth}
+	if err := s.beforeUpload(ctx, hookTestTable, &req); err != nil {
+		t.Fatalf("beforeUpload: %v", err)
+	}
+	if strings.Join(order, ",") != "first,second" || req.Mode != UploadModeUpsert || req.Duplicates != DuplicateOverwrite {
+		t.Errorf("order = %v, req = %+v", order, req)
+	}
+
+	req = UploadRequest{TableKey: "hook_orders", FileName: "draft.csv"}
+	err := s.beforeUpload(ctx, hookTestTable, &req)
+	if !errors.Is(err, ErrUploadRejected) || !strings.Contains(err.Error(), "second: drafts are not accepted") {
+		t.Errorf("rejected: err = %v", err)
+	}
+
+	// Changes are validated like the caller's own options
+	registerTestUploadHook(t, "first", &testUploadHook{before: func(req *UploadRequest) error {
+		req.Mode = "merge"
+		return nil
+	}})
+	if err := s.beforeUpload(ctx, hookTestTable, &UploadRequest{TableKey: "hook_orders"}); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("invalid change: err = %v", err)
+	}
+
+	registerTestUploadHook(t, "first", &testUploadHook{before: func(*UploadRequest) error { panic("boom") }})
+	if err := s.beforeUpload(ctx, hookTestTable, &UploadRequest{TableKey: "hook_orders"}); !errors.Is(err, ErrUploadRejected) || !strings.Contains(err.Error(), "panic: boom") {
+		t.Errorf("panicking hook: err = %v", err)
+	}
+}
+
+func TestBeforeUpload_ExternalHooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev UploadEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil || ev.Event != HookBeforeUpload {
+			http.Error(w, "bad event", http.StatusBadRequest)
+			return
+		}
+		switch ev.FileName {
+		case "skip.csv":
+			w.Write([]byte(`{"duplicates": "skip"}`))
+		case "blocked.csv":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": "period is closed"}`))
+		}
+	}))
+	defer srv.Close()
+	s := newHookTestService(srv.URL)
+	ctx := context.Background()
+
+	req := UploadRequest{TableKey: "hook_orders", FileName: "skip.csv"}
+	if err := s.beforeUpload(ctx, hookTestTable, &req); err != nil || req.Duplicates != DuplicateSkip || req.Mode != UploadModeInsert {
+		t.Errorf("changed: req = %+v, err = %v", req, err)
+	}
+	req = UploadRequest{TableKey: "hook_orders", FileName: "plain.csv"}
+	if err := s.beforeUpload(ctx, hookTestTable, &req); err != nil || req.Duplicates != DuplicateKeepBoth {
+		t.Errorf("unchanged: req = %+v, err = %v", req, err)
+	}
+	err := s.beforeUpload(ctx, hookTestTable, &UploadRequest{TableKey: "hook_orders", FileName: "blocked.csv"})
+	if !errors.Is(err, ErrUploadRejected) || !strings.Contains(err.Error(), "period is closed") {
+		t.Errorf("rejected: err = %v", err)
+	}
+
+	// An unreachable hook fails closed
+	srv.Close()
+	if err := s.beforeUpload(ctx, hookTestTable, &UploadRequest{TableKey: "hook_orders"}); !errors.Is(err, ErrUploadRejected) {
+		t.Errorf("unreachable: err = %v", err)
+	}
+}
+
+func TestFinishUploadHooks(t *testing.T) {
+	events := make(chan UploadEvent, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev UploadEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	defer srv.Close()
+	s := newHookTestService(srv.URL)
+	hook := &testUploadHook{}
+	registerTestUploadHook(t, "recorder", hook)
+
+	upload := &activeUpload{ID: "u1", TableKey: "hook_orders", FileName: "orders.csv", Mode: UploadModeInsert}
+	s.finishUploadHooks(context.Background(), upload, &UploadResult{Inserted: 3, Skipped: 1}, "rec-1")
+	s.finishUploadHooks(context.Background(), upload, &UploadResult{Inserted: 2, Error: "commit: conn closed"}, "")
+	upload.DryRun = true
+	s.finishUploadHooks(context.Background(), upload, &UploadResult{}, "rec-2")
+
+	if len(hook.events) != 2 {
+		t.Fatalf("go hook events = %+v", hook.events)
+	}
+	if ev := hook.events[0]; ev.Event != HookAfterCommit || ev.RecordID != "rec-1" || ev.Inserted != 3 || ev.Skipped != 1 {
+		t.Errorf("commit event = %+v", ev)
+	}
+	if ev := hook.events[1]; ev.Event != HookAfterRollback || ev.RecordID != "" || ev.Error != "commit: conn closed" {
+		t.Errorf("rollback event = %+v", ev)
+	}
+
+	got := map[UploadHookEvent]UploadEvent{}
+	for range 2 {
+		select {
+		case ev := <-events:
+			got[ev.Event] = ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("external hook not called")
+		}
+	}
+	if ev := got[HookAfterCommit]; ev.UploadID != "u1" || ev.TableKey != "hook_orders" || ev.Time.IsZero() {
+		t.Errorf("posted commit event = %+v", ev)
+	}
+	if _, ok := got[HookAfterRollback]; !ok {
+		t.Errorf("posted events = %+v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+// upload_mode.go implements the upsert and replace upload modes. The delete
+// mode is in upload_delete.go.
+//
+// Data tables have no unique constraints (a table's UniqueKey is a business
+// key that earlier uploads may legitimately repeat), so upsert cannot use
+// ON CONFLICT. Instead every row is inserted as usual and, just before the
+// upload commits, rows from earlier uploads sharing a key with one of this
+// upload's rows are deleted in a single statement. Both modes run inside the
+// upload transaction: a failed or cancelled upload changes nothing.
+//
+// Rolling back an upsert or replace upload removes its rows but cannot
+// restore the rows it displaced. An upload that would displace rows under a
+// legal hold fails with ErrLegalHold.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ParseUploadMode validates a mode from a request. An empty string returns
+// an empty mode, meaning the table's default.
+func ParseUploadMode(s string) (UploadMode, error) {
+	switch mode := UploadMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "", UploadModeInsert, UploadModeUpsert, UploadModeReplace, UploadModeDelete:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid upload mode %q (want insert, upsert, replace, or delete)", s)
+	}
+}
+
+// resolveUploadMode picks the requested mode, else the table default, else
+// insert, and checks that the table supports it.
+func resolveUploadMode(def TableDefinition, requested UploadMode) (UploadMode, error) {
+	mode := requested
+	if mode == "" {
+		mode = def.UploadMode
+	}
+	if mode == "" {
+		mode = UploadModeInsert
+	}
+	if _, err := ParseUploadMode(string(mode)); err != nil {
+		return "", err
+	}
+	if (mode == UploadModeUpsert || mode == UploadModeDelete) && len(def.Info.UniqueKey) == 0 {
+		return "", fmt.Errorf("%s mode requires a unique key, and %s has none", mode, def.Info.Key)
+	}
+	return mode, nil
+}
+
+// clearForReplace deletes every row of the table within tx and returns
+// how many were deleted. A table with held rows is left alone.
+func (s *Service) clearForReplace(ctx context.Context, tx pgx.Tx, def TableDefinition) (int, error) {
+	if err := s.checkLegalHold(ctx, def, "replace", "TRUE"); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM "+quoteIdentifier(def.Info.Key))
+	if err != nil {
+		return 0, fmt.Errorf("clear %s for replace: %w", def.Info.Key, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// upsertSQL builds the statement that deletes rows from earlier uploads
+// whose unique key matches a row of upload $1, and counts how many of the
+// upload's rows replaced at least one existing row. NULL key parts match
+// each other, as in CheckDuplicates. Soft-deleted rows stay in the recycle
+// bin.
+func upsertSQL(def TableDefinition) string {
+	table := quoteIdentifier(def.Info.Key)
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+
+	conds := make([]string, len(keyCols))
+	for i, col := range keyCols {
+		col = quoteIdentifier(col)
+		conds[i] = fmt.Sprintf("old.%s IS NOT DISTINCT FROM new.%s", col, col)
+	}
+	if live := liveRowsCondition(def, "old"); live != "" {
+		conds = append(conds, live)
+	}
+
+	return fmt.Sprintf(`WITH replaced AS (
+	DELETE FROM %s AS old
+	USING %s AS new
+	WHERE new.upload_id = $1
+	  AND old.upload_id IS DISTINCT FROM $1
+	  AND %s
+	RETURNING new.id
+)
+SELECT count(DISTINCT id) FROM replaced`, table, table, strings.Join(conds, "\n\t  AND "))
+}
+
+// displacedRowsCondition returns the condition matching the rows that
+// upsertSQL deletes for upload $1, for checking them against legal holds.
+func displacedRowsCondition(def TableDefinition) string {
+	table := quoteIdentifier(def.Info.Key)
+	keyCols := resolveDBColumns(def.Info.UniqueKey, def.FieldSpecs)
+
+	conds := make([]string, len(keyCols))
+	for i, col := range keyCols {
+		col = quoteIdentifier(col)
+		conds[i] = fmt.Sprintf("new.%s IS NOT DISTINCT FROM %s.%s", col, table, col)
+	}
+	where := fmt.Sprintf(`upload_id IS DISTINCT FROM $1
+	  AND EXISTS (SELECT 1 FROM %s AS new WHERE new.upload_id = $1 AND %s)`, table, strings.Join(conds, " AND "))
+	return where + andLiveRows(def, table)
+}
+
+// applyUpsert deletes rows displaced by the upload within tx and returns
+// how many of the upload's rows were updates rather than new keys. Nothing
+// is deleted if any displaced row is held. The omitted columns of a
+// selective import keep the displaced rows' values (see upload_columns.go).
+func (s *Service) applyUpsert(ctx context.Context, tx pgx.Tx, def TableDefinition, uploadID pgtype.UUID, omitted []string) (int, error) {
+	if err := s.checkLegalHoldIn(ctx, tx, def, "upsert", displacedRowsCondition(def), uploadID); err != nil {
+		return 0, err
+	}
+	if err := keepOmittedColumns(ctx, tx, def, omitted, uploadID); err != nil {
+		return 0, err
+	}
+	var updated int
+	if err := tx.QueryRow(ctx, upsertSQL(def), uploadID).Scan(&updated); err != nil {
+		return 0, fmt.Errorf("upsert %s: %w", def.Info.Key, err)
+	}
+	return updated, nil
+}
+
+// uploadAuditReason describes a committed upload for the audit log.
+func uploadAuditReason(fileName string, result *UploadResult) string {
+	var reason string
+	switch result.Mode {
+	case UploadModeUpsert:
+		reason = fmt.Sprintf("Uploaded %s (upsert: %d inserted, %d updated)", fileName, result.Inserted, result.Updated)
+	case UploadModeReplace:
+		reason = fmt.Sprintf("Uploaded %s (replace: %d existing rows deleted)", fileName, result.Replaced)
+	case UploadModeDelete:
+		reason = fmt.Sprintf("Uploaded %s (delete: %d rows deleted)", fileName, result.Deleted)
+	default:
+		reason = fmt.Sprintf("Uploaded %s", fileName)
+	}
+	return reason + duplicateAuditSuffix(result)
+}
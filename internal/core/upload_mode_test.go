@@ -0,0 +1,127 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseUploadMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    UploadMode
+		wantErr bool
+	}{
+		{"", "", false},
+		{"insert", UploadModeInsert, false},
+		{" Upsert ", UploadModeUpsert, false},
+		{"REPLACE", UploadModeReplace, false},
+		{"delete", UploadModeDelete, false},
+		{"merge", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseUploadMode(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseUploadMode(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveUploadMode(t *testing.T) {
+	keyed := TableDefinition{Info: TableInfo{Key: "keyed", UniqueKey: []string{"id"}}, UploadMode: UploadModeUpsert}
+	unkeyed := TableDefinition{Info: TableInfo{Key: "unkeyed"}}
+
+	tests := []struct {
+		name      string
+		def       TableDefinition
+		requested UploadMode
+		want      UploadMode
+		wantErr   string
+	}{
+		{"default insert", unkeyed, "", UploadModeInsert, ""},
+		{"table default", keyed, "", UploadModeUpsert, ""},
+		{"request overrides table", keyed, UploadModeReplace, UploadModeReplace, ""},
+		{"upsert needs unique key", unkeyed, UploadModeUpsert, "", "requires a unique key"},
+		{"delete needs unique key", unkeyed, UploadModeDelete, "", "requires a unique key"},
+		{"invalid mode", unkeyed, "merge", "", "invalid upload mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveUploadMode(tt.def, tt.requested)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "ns_so_detail", UniqueKey: []string{"Opp ID", "sfdc_opp_line_id"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Opp ID", DBColumn: "sfdc_opp_id"},
+			{Name: "sfdc_opp_line_id"},
+		},
+	}
+
+	got := upsertSQL(def)
+	for _, want := range []string{
+		`DELETE FROM "ns_so_detail" AS old`,
+		`USING "ns_so_detail" AS new`,
+		`WHERE new.upload_id = $1`,
+		`old.upload_id IS DISTINCT FROM $1`,
+		`old."sfdc_opp_id" IS NOT DISTINCT FROM new."sfdc_opp_id"`,
+		`AND old."sfdc_opp_line_id" IS NOT DISTINCT FROM new."sfdc_opp_line_id"`,
+		`SELECT count(DISTINCT id) FROM replaced`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("upsertSQL missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestDisplacedRowsCondition(t *testing.T) {
+	def := TableDefinition{
+		Info: TableInfo{Key: "ns_so_detail", UniqueKey: []string{"Opp ID", "sfdc_opp_line_id"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Opp ID", DBColumn: "sfdc_opp_id"},
+			{Name: "sfdc_opp_line_id"},
+		},
+		SoftDelete: true,
+	}
+
+	got := displacedRowsCondition(def)
+	for _, want := range []string{
+		`upload_id IS DISTINCT FROM $1`,
+		`EXISTS (SELECT 1 FROM "ns_so_detail" AS new WHERE new.upload_id = $1`,
+		`new."sfdc_opp_id" IS NOT DISTINCT FROM "ns_so_detail"."sfdc_opp_id"`,
+		`AND new."sfdc_opp_line_id" IS NOT DISTINCT FROM "ns_so_detail"."sfdc_opp_line_id")`,
+		`AND "ns_so_detail"."deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("displacedRowsCondition missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestUploadAuditReason(t *testing.T) {
+	tests := []struct {
+		result UploadResult
+		want   string
+	}{
+		{UploadResult{Mode: UploadModeInsert, Inserted: 5}, "Uploaded f.csv"},
+		{UploadResult{Mode: UploadModeUpsert, Inserted: 3, Updated: 2}, "Uploaded f.csv (upsert: 3 inserted, 2 updated)"},
+		{UploadResult{Mode: UploadModeReplace, Inserted: 5, Replaced: 9}, "Uploaded f.csv (replace: 9 existing rows deleted)"},
+		{UploadResult{Mode: UploadModeInsert, Inserted: 4, Duplicates: DuplicateSkip, DupSkipped: 1}, "Uploaded f.csv [duplicates: skip, 1 skipped]"},
+	}
+	for _, tt := range tests {
+		if got := uploadAuditReason("f.csv", &tt.result); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package core
+
+// upload_quota.go keeps one uploader from taking every upload slot.
+//
+// The global UploadLimiter caps parallel uploads for the whole server; a
+// client uploading ten large files at once can still hold all of its slots.
+// UploadQuotas is layered on top of it, per uploader: an API key, or the
+// client IP for requests without one.
+//
+//   - Concurrency: an uploader waits for one of its own slots (up to
+//     UPLOAD_MAX_WAIT_TIME, like the global limiter) before it waits for a
+//     global one, and fails with ErrUploaderBusy (UPL008) if none frees up.
+//   - Daily volume: uploads and bytes are counted when an upload is
+//     accepted, per UTC day. An upload that would go over either quota
+//     fails at once with ErrUploadQuotaExceeded (UPL009).
+//
+// Counters are kept in memory and start from zero after a restart.
+// Uploads started by the server itself (no uploader in the context) are
+// not limited.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUploaderBusy is returned when an uploader's own upload slots stay
+	// occupied for the wait timeout.
+	ErrUploaderBusy = errors.New("concurrent upload limit reached for this uploader")
+
+	// ErrUploadQuotaExceeded is returned when an upload would take an
+	// uploader over its daily quota.
+	ErrUploadQuotaExceeded = errors.New("daily upload quota exceeded")
+)
+
+// UploaderQuota is the quota status of one uploader.
+type UploaderQuota struct {
+	Uploader         string    `json:"uploader"`
+	Active           int       `json:"active"`
+	MaxConcurrent    int       `json:"max_concurrent,omitempty"` // 0: no per-uploader limit
+	UploadsToday     int       `json:"uploads_today"`
+	MaxUploadsPerDay int       `json:"max_uploads_per_day,omitempty"` // 0: no quota
+	BytesToday       int64     `json:"bytes_today"`
+	MaxBytesPerDay   int64     `json:"max_bytes_per_day,omitempty"` // 0: no quota
+	ResetsAt         time.Time `json:"resets_at"`
+}
+
+// UploadQuotas tracks per-uploader concurrency and daily volume.
+type UploadQuotas struct {
+	maxConcurrent int
+	dailyUploads  int
+	dailyBytes    int64
+	maxWait       time.Duration
+	now           func() time.Time
+
+	mu    sync.Mutex
+	day   time.Time // UTC midnight the daily counters started
+	users map[string]*uploaderUsage
+}
+
+// uploaderUsage is one uploader's slots and today's counters.
+type uploaderUsage struct {
+	slots   chan struct{} // nil without a concurrency limit
+	active  int
+	uploads int
+	bytes   int64
+}
+
+// NewUploadQuotas creates per-uploader quotas. Zero limits are disabled.
+// Uploaders wait up to maxWait for a slot (DefaultMaxWaitTime if 0).
+func NewUploadQuotas(maxConcurrent, dailyUploads int, dailyBytes int64, maxWait time.Duration) *UploadQuotas {
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWaitTime
+	}
+	return &UploadQuotas{
+		maxConcurrent: maxConcurrent,
+		dailyUploads:  dailyUploads,
+		dailyBytes:    dailyBytes,
+		maxWait:       maxWait,
+		now:           time.Now,
+		users:         make(map[string]*uploaderUsage),
+	}
+}
+
+// rollover resets the daily counters when a new UTC day has started.
+// Must be called with q.mu held.
+func (q *UploadQuotas) rollover() {
+	today := q.now().UTC().Truncate(24 * time.Hour)
+	if !today.After(q.day) {
+		return
+	}
+	q.day = today
+	for id, u := range q.users {
+		if u.active == 0 {
+			delete(q.users, id)
+			continue
+		}
+		u.uploads, u.bytes = 0, 0
+	}
+}
+
+// usage returns the uploader's entry for today, creating it if needed.
+// Must be called with q.mu held.
+func (q *UploadQuotas) usage(uploader string) *uploaderUsage {
+	q.rollover()
+	u, ok := q.users[uploader]
+	if !ok {
+		u = &uploaderUsage{}
+		if q.maxConcurrent > 0 {
+			u.slots = make(chan struct{}, q.maxConcurrent)
+		}
+		q.users[uploader] = u
+	}
+	return u
+}
+
+// Charge counts uploads uploads of size bytes in total against the
+// uploader's daily quota, or returns ErrUploadQuotaExceeded and counts
+// nothing if that would go over it.
+func (q *UploadQuotas) Charge(uploader string, uploads int, size int64) error {
+	if uploader == "" || (q.dailyUploads <= 0 && q.dailyBytes <= 0) {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.usage(uploader)
+	if q.dailyUploads > 0 && u.uploads+uploads > q.dailyUploads {
+		return fmt.Errorf("%w: %d of %d uploads used today", ErrUploadQuotaExceeded, u.uploads, q.dailyUploads)
+	}
+	if q.dailyBytes > 0 && u.bytes+size > q.dailyBytes {
+		return fmt.Errorf("%w: %d of %d bytes used today, upload is %d bytes", ErrUploadQuotaExceeded, u.bytes, q.dailyBytes, size)
+	}
+	u.uploads += uploads
+	u.bytes += size
+	return nil
+}
+
+// Refund gives back a Charge for uploads that did not start.
+func (q *UploadQuotas) Refund(uploader string, uploads int, size int64) {
+	if uploader == "" || (q.dailyUploads <= 0 && q.dailyBytes <= 0) {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usage(uploader)
+	u.uploads = max(u.uploads-uploads, 0)
+	u.bytes = max(u.bytes-size, 0)
+}
+
+// Acquire takes one of the uploader's slots, waiting up to the wait
+// timeout. The caller must call Release when the upload ends.
+func (q *UploadQuotas) Acquire(ctx context.Context, uploader string) error {
+	if uploader == "" {
+		return nil
+	}
+	q.mu.Lock()
+	u := q.usage(uploader)
+	u.active++ // Keeps the entry across a day change while waiting
+	slots := u.slots
+	q.mu.Unlock()
+	if slots == nil {
+		return nil
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, q.maxWait)
+	defer cancel()
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-waitCtx.Done():
+		q.mu.Lock()
+		u.active--
+		q.mu.Unlock()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%w (max %d)", ErrUploaderBusy, q.maxConcurrent)
+	}
+}
+
+// Release frees a slot taken with Acquire.
+func (q *UploadQuotas) Release(uploader string) {
+	if uploader == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.users[uploader]
+	if !ok {
+		return
+	}
+	u.active--
+	if u.slots != nil {
+		<-u.slots
+	}
+}
+
+// Status returns the uploader's quota status.
+func (q *UploadQuotas) Status(uploader string) UploaderQuota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	status := UploaderQuota{
+		Uploader:         uploader,
+		MaxConcurrent:    q.maxConcurrent,
+		MaxUploadsPerDay: q.dailyUploads,
+		MaxBytesPerDay:   q.dailyBytes,
+		ResetsAt:         q.day.Add(24 * time.Hour),
+	}
+	if u, ok := q.users[uploader]; ok {
+		status.Active = u.active
+		if u.slots != nil {
+			status.Active = len(u.slots) // Waiting uploads are not active yet
+		}
+		status.UploadsToday = u.uploads
+		status.BytesToday = u.bytes
+	}
+	return status
+}
+
+// acquireUploadSlot takes one of uploader's slots and then a global one,
+// and returns the function that releases both.
+func (s *Service) acquireUploadSlot(ctx context.Context, tableKey, uploader string) (func(), error) {
+	if err := s.uploadQuotas.Acquire(ctx, uploader); err != nil {
+		if errors.Is(err, ErrUploaderBusy) {
+			s.logQuotaDenial(ctx, tableKey, "UPL008", err)
+		}
+		return nil, fmt.Errorf("acquire upload slot for %s: %w", tableKey, err)
+	}
+	if err := s.uploadLimiter.Acquire(ctx); err != nil {
+		s.uploadQuotas.Release(uploader)
+		return nil, fmt.Errorf("acquire upload slot for %s: %w", tableKey, err)
+	}
+	return func() {
+		s.uploadLimiter.Release()
+		s.uploadQuotas.Release(uploader)
+	}, nil
+}
+
+// chargeUploadQuota counts uploads uploads of size bytes to tableKey
+// against uploader's daily quota.
+func (s *Service) chargeUploadQuota(ctx context.Context, tableKey, uploader string, uploads int, size int64) error {
+	if err := s.uploadQuotas.Charge(uploader, uploads, size); err != nil {
+		s.logQuotaDenial(ctx, tableKey, "UPL009", err)
+		return err
+	}
+	return nil
+}
+
+// logQuotaDenial records an upload refused by a per-uploader quota.
+func (s *Service) logQuotaDenial(ctx context.Context, tableKey, code string, err error) {
+	s.LogDenied(ctx, Denial{
+		Kind:     DenialRateLimit,
+		TableKey: tableKey,
+		Code:     code,
+		Detail:   err.Error(),
+	})
+}
+
+// UploadQueueStatus is the global limiter's state and, for a request from
+// an identified uploader, that uploader's quota.
+type UploadQueueStatus struct {
+	UploadLimiterStatus
+	Quota *UploaderQuota `json:"quota,omitempty"`
+}
+
+// UploadQueueStatus returns the limiter state and the quota of the
+// uploader in ctx.
+func (s *Service) UploadQueueStatus(ctx context.Context) UploadQueueStatus {
+	status := UploadQueueStatus{UploadLimiterStatus: s.uploadLimiter.Status()}
+	if uploader := GetUploaderFromContext(ctx); uploader != "" {
+		quota := s.uploadQuotas.Status(uploader)
+		status.Quota = &quota
+	}
+	return status
+}
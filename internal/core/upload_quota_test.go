@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUploadQuotas_Concurrency(t *testing.T) {
+	q := NewUploadQuotas(1, 0, 0, 50*time.Millisecond)
+	ctx := context.Background()
+
+	if err := q.Acquire(ctx, "key:a"); err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+	if err := q.Acquire(ctx, "key:a"); !errors.Is(err, ErrUploaderBusy) {
+		t.Errorf("second Acquire error = %v, want ErrUploaderBusy", err)
+	}
+	// Another uploader has its own slots
+	if err := q.Acquire(ctx, "key:b"); err != nil {
+		t.Errorf("Acquire for another uploader: %v", err)
+	}
+	if got := q.Status("key:a").Active; got != 1 {
+		t.Errorf("Active = %d, want 1 (the timed-out wait is not counted)", got)
+	}
+
+	q.Release("key:a")
+	if err := q.Acquire(ctx, "key:a"); err != nil {
+		t.Errorf("Acquire after Release: %v", err)
+	}
+
+	// Server-started uploads are not limited
+	for range 3 {
+		if err := q.Acquire(ctx, ""); err != nil {
+			t.Fatalf("Acquire without uploader: %v", err)
+		}
+	}
+}
+
+func TestUploadQuotas_DailyVolume(t *testing.T) {
+	q := NewUploadQuotas(0, 3, 1000, 0)
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	if err := q.Charge("ip:10.0.0.1", 2, 600); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	if err := q.Charge("ip:10.0.0.1", 1, 500); !errors.Is(err, ErrUploadQuotaExceeded) {
+		t.Errorf("Charge over byte quota error = %v, want ErrUploadQuotaExceeded", err)
+	}
+	if err := q.Charge("ip:10.0.0.1", 2, 10); !errors.Is(err, ErrUploadQuotaExceeded) {
+		t.Errorf("Charge over upload quota error = %v, want ErrUploadQuotaExceeded", err)
+	}
+	st := q.Status("ip:10.0.0.1")
+	if st.UploadsToday != 2 || st.BytesToday != 600 || !st.ResetsAt.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Status = %+v, want refused charges not counted", st)
+	}
+
+	q.Refund("ip:10.0.0.1", 1, 300)
+	if st := q.Status("ip:10.0.0.1"); st.UploadsToday != 1 || st.BytesToday != 300 {
+		t.Errorf("Status after Refund = %+v", st)
+	}
+
+	// A new UTC day starts from zero
+	now = now.Add(2 * time.Hour)
+	if err := q.Charge("ip:10.0.0.1", 3, 1000); err != nil {
+		t.Errorf("Charge on the next day: %v", err)
+	}
+}
+
+func TestUploaderFromContext(t *testing.T) {
+	ctx := context.Background()
+	if got := GetUploaderFromContext(ctx); got != "" {
+		t.Errorf("uploader without request metadata = %q, want empty", got)
+	}
+	ctx = ContextWithIPAddress(ctx, "10.0.0.1")
+	if got := GetUploaderFromContext(ctx); got != "ip:10.0.0.1" {
+		t.Errorf("uploader from IP = %q", got)
+	}
+	ctx = ContextWithUploader(ctx, "key:abc")
+	if got := GetUploaderFromContext(ctx); got != "key:abc" {
+		t.Errorf("uploader from key = %q", got)
+	}
+}
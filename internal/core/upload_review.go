@@ -0,0 +1,296 @@
+package core
+
+// upload_review.go adds a sign-off workflow to uploads, so month-end close
+// can track which imports have been reviewed.
+//
+// The review status is separate from an upload's status (active or
+// rolled_back), which says whether its rows are still in the table. An
+// upload starts with no review status; from there:
+//
+//	(none)       -> needs-review, approved
+//	needs-review -> approved
+//	approved     -> needs-review (reopened), archived
+//	archived     -> needs-review (reopened)
+//
+// A rolled-back upload cannot be approved. Approving, archiving and
+// reopening a signed-off upload need the reviewer role, which the caller
+// grants with ReviewChange.CanSignOff (the web layer checks the request's
+// API key against REVIEWER_API_KEYS). Each change is recorded on the
+// upload and in the audit log.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ReviewStatus is an upload's place in the review workflow.
+type ReviewStatus string
+
+const (
+	ReviewNone        ReviewStatus = ""
+	ReviewNeedsReview ReviewStatus = "needs-review"
+	ReviewApproved    ReviewStatus = "approved"
+	ReviewArchived    ReviewStatus = "archived"
+)
+
+var (
+	// ErrInvalidReviewTransition is returned for a review change the
+	// workflow does not allow.
+	ErrInvalidReviewTransition = errors.New("invalid review transition")
+
+	// ErrReviewerRequired is returned when a sign-off is requested without
+	// the reviewer role.
+	ErrReviewerRequired = errors.New("reviewer role required")
+)
+
+// reviewTransitions lists the statuses each status may move to.
+var reviewTransitions = map[ReviewStatus][]ReviewStatus{
+	ReviewNone:        {ReviewNeedsReview, ReviewApproved},
+	ReviewNeedsReview: {ReviewApproved},
+	ReviewApproved:    {ReviewNeedsReview, ReviewArchived},
+	ReviewArchived:    {ReviewNeedsReview},
+}
+
+// ParseReviewStatus validates a review status from a request. Empty is
+// not a valid target; a review can be reopened but not cleared.
+func ParseReviewStatus(s string) (ReviewStatus, error) {
+	switch st := ReviewStatus(strings.ToLower(strings.TrimSpace(s))); st {
+	case ReviewNeedsReview, ReviewApproved, ReviewArchived:
+		return st, nil
+	default:
+		return "", fmt.Errorf("invalid review status %q (want needs-review, approved, or archived)", s)
+	}
+}
+
+// isSignOff reports whether moving from one status to another signs off
+// an upload or withdraws a sign-off, which needs the reviewer role.
+func isSignOff(from, to ReviewStatus) bool {
+	return to == ReviewApproved || to == ReviewArchived || from == ReviewApproved || from == ReviewArchived
+}
+
+// checkReviewTransition returns ErrInvalidReviewTransition if the workflow
+// does not allow moving from one status to the other.
+func checkReviewTransition(from, to ReviewStatus) error {
+	for _, next := range reviewTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	if from == ReviewNone {
+		return fmt.Errorf("%w: cannot move a new upload to %s", ErrInvalidReviewTransition, to)
+	}
+	return fmt.Errorf("%w: cannot move from %s to %s", ErrInvalidReviewTransition, from, to)
+}
+
+// UploadReview is an upload's review state.
+type UploadReview struct {
+	UploadID   string       `json:"upload_id"`
+	TableKey   string       `json:"table_key"`
+	FileName   string       `json:"file_name"`
+	UploadedAt time.Time    `json:"uploaded_at"`
+	Status     string       `json:"status"` // active or rolled_back
+	Review     ReviewStatus `json:"review_status"`
+	ReviewedAt *time.Time   `json:"reviewed_at,omitempty"`
+	ReviewedBy string       `json:"reviewed_by,omitempty"`
+	Note       string       `json:"review_note,omitempty"`
+}
+
+// ReviewChange is a request to move an upload to another review status.
+type ReviewChange struct {
+	Status   ReviewStatus
+	Reviewer string // Who made the change, as given by the caller
+	Note     string
+
+	// CanSignOff is set when the caller holds the reviewer role.
+	CanSignOff bool
+}
+
+// UploadReviewFilter selects uploads for ListUploadReviews. Empty fields
+// match everything.
+type UploadReviewFilter struct {
+	TableKey string
+	Review   string // A ReviewStatus, or "none" for uploads never reviewed
+	Limit    int    // Default 100, max 1000
+}
+
+const uploadReviewColumns = `id, name, COALESCE(file_name, ''), uploaded_at, COALESCE(status, 'active'),
+       COALESCE(review_status, ''), reviewed_at, COALESCE(reviewed_by, ''), COALESCE(review_note, '')`
+
+func scanUploadReview(row pgx.Row) (*UploadReview, error) {
+	var (
+		id                     pgtype.UUID
+		uploadedAt, reviewedAt pgtype.Timestamp
+		r                      UploadReview
+	)
+	if err := row.Scan(&id, &r.TableKey, &r.FileName, &uploadedAt, &r.Status,
+		&r.Review, &reviewedAt, &r.ReviewedBy, &r.Note); err != nil {
+		return nil, err
+	}
+	r.UploadID = PgUUIDToString(id)
+	r.UploadedAt = uploadedAt.Time
+	if reviewedAt.Valid {
+		r.ReviewedAt = &reviewedAt.Time
+	}
+	return &r, nil
+}
+
+// GetUploadReview returns an upload's review state.
+func (s *Service) GetUploadReview(ctx context.Context, uploadID string) (*UploadReview, error) {
+	var id pgtype.UUID
+	if err := id.Scan(uploadID); err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	r, err := scanUploadReview(s.pool.QueryRow(ctx,
+		`SELECT `+uploadReviewColumns+` FROM csv_uploads WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("upload not found: %s", uploadID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get upload review: %w", err)
+	}
+	return r, nil
+}
+
+// SetUploadReview moves an upload to another review status and records
+// the change in the audit log. The row is locked while the transition is
+// checked, so two reviewers cannot both move it from the same status.
+func (s *Service) SetUploadReview(ctx context.Context, uploadID string, change ReviewChange) (*UploadReview, error) {
+	if _, err := ParseReviewStatus(string(change.Status)); err != nil {
+		return nil, err
+	}
+	var id pgtype.UUID
+	if err := id.Scan(uploadID); err != nil {
+		return nil, fmt.Errorf("invalid upload ID: %w", err)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	current, err := scanUploadReview(tx.QueryRow(ctx,
+		`SELECT `+uploadReviewColumns+` FROM csv_uploads WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("upload not found: %s", uploadID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get upload review: %w", err)
+	}
+	if err := checkReviewTransition(current.Review, change.Status); err != nil {
+		return nil, err
+	}
+	if isSignOff(current.Review, change.Status) && !change.CanSignOff {
+		return nil, fmt.Errorf("%w to move an upload from %s to %s", ErrReviewerRequired, reviewLabel(current.Review), change.Status)
+	}
+	if change.Status == ReviewApproved && current.Status == "rolled_back" {
+		return nil, fmt.Errorf("%w: upload was rolled back", ErrInvalidReviewTransition)
+	}
+
+	updated, err := scanUploadReview(tx.QueryRow(ctx, `
+UPDATE csv_uploads
+SET review_status = $2, reviewed_at = NOW(), reviewed_by = NULLIF($3, ''), review_note = NULLIF($4, '')
+WHERE id = $1
+RETURNING `+uploadReviewColumns, id, string(change.Status), change.Reviewer, change.Note))
+	if err != nil {
+		return nil, fmt.Errorf("update upload review: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+
+	s.LogAudit(ctx, AuditLogParams{
+		Action:       ActionUploadReview,
+		TableKey:     current.TableKey,
+		UploadID:     uploadID,
+		OldValue:     reviewLabel(current.Review),
+		NewValue:     string(change.Status),
+		UserName:     change.Reviewer,
+		IPAddress:    GetIPAddressFromContext(ctx),
+		UserAgent:    GetUserAgentFromContext(ctx),
+		Reason:       change.Note,
+		RowsAffected: 1,
+	})
+	return updated, nil
+}
+
+// reviewLabel names a review status for the audit log.
+func reviewLabel(st ReviewStatus) string {
+	if st == ReviewNone {
+		return "none"
+	}
+	return string(st)
+}
+
+// ListUploadReviews returns uploads matching the filter, newest first.
+func (s *Service) ListUploadReviews(ctx context.Context, f UploadReviewFilter) ([]UploadReview, error) {
+	var (
+		conds = []string{"action = 'upload'"}
+		args  []any
+	)
+	if f.TableKey != "" {
+		if _, ok := Get(f.TableKey); !ok {
+			return nil, fmt.Errorf("unknown table: %s", f.TableKey)
+		}
+		args = append(args, f.TableKey)
+		conds = append(conds, fmt.Sprintf("name = $%d", len(args)))
+	}
+	switch f.Review {
+	case "":
+	case "none":
+		conds = append(conds, "review_status IS NULL")
+	default:
+		st, err := ParseReviewStatus(f.Review)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(st))
+		conds = append(conds, fmt.Sprintf("review_status = $%d", len(args)))
+	}
+
+	limit := f.Limit
+	switch {
+	case limit <= 0:
+		limit = 100
+	case limit > 1000:
+		limit = 1000
+	}
+	args = append(args, limit)
+
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`SELECT %s FROM csv_uploads WHERE %s ORDER BY uploaded_at DESC LIMIT $%d`,
+		uploadReviewColumns, strings.Join(conds, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list upload reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []UploadReview{}
+	for rows.Next() {
+		r, err := scanUploadReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan upload review: %w", err)
+		}
+		reviews = append(reviews, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list upload reviews: %w", err)
+	}
+	return reviews, nil
+}
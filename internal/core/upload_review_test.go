@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func TestParseReviewStatus(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ReviewStatus
+		wantErr bool
+	}{
+		{"needs-review", ReviewNeedsReview, false},
+		{" Approved ", ReviewApproved, false},
+		{"archived", ReviewArchived, false},
+		{"", "", true},
+		{"none", "", true},
+		{"rejected", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReviewStatus(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseReviewStatus(%q) = %q, %v; want %q, err %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckReviewTransition(t *testing.T) {
+	allowed := map[[2]ReviewStatus]bool{
+		{ReviewNone, ReviewNeedsReview}:     true,
+		{ReviewNone, ReviewApproved}:        true,
+		{ReviewNeedsReview, ReviewApproved}: true,
+		{ReviewApproved, ReviewNeedsReview}: true,
+		{ReviewApproved, ReviewArchived}:    true,
+		{ReviewArchived, ReviewNeedsReview}: true,
+	}
+	from := []ReviewStatus{ReviewNone, ReviewNeedsReview, ReviewApproved, ReviewArchived}
+	to := []ReviewStatus{ReviewNeedsReview, ReviewApproved, ReviewArchived}
+	for _, f := range from {
+		for _, tt := range to {
+			err := checkReviewTransition(f, tt)
+			if want := allowed[[2]ReviewStatus{f, tt}]; want != (err == nil) {
+				t.Errorf("checkReviewTransition(%q, %q) = %v, want allowed %v", f, tt, err, want)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidReviewTransition) {
+				t.Errorf("checkReviewTransition(%q, %q) = %v, want ErrInvalidReviewTransition", f, tt, err)
+			}
+		}
+	}
+}
+
+func TestIsSignOff(t *testing.T) {
+	tests := []struct {
+		from, to ReviewStatus
+		want     bool
+	}{
+		{ReviewNone, ReviewNeedsReview, false},
+		{ReviewNone, ReviewApproved, true},
+		{ReviewNeedsReview, ReviewApproved, true},
+		{ReviewApproved, ReviewNeedsReview, true},
+		{ReviewApproved, ReviewArchived, true},
+		{ReviewArchived, ReviewNeedsReview, true},
+	}
+	for _, tt := range tests {
+		if got := isSignOff(tt.from, tt.to); got != tt.want {
+			t.Errorf("isSignOff(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSetUploadReview_RejectsBadInput(t *testing.T) {
+	s := &Service{cfg: &config.Config{}}
+
+	// Both are rejected before the database is touched
+	if _, err := s.SetUploadReview(context.Background(), "00000000-0000-0000-0000-000000000001",
+		ReviewChange{Status: "rejected"}); err == nil {
+		t.Error("expected error for invalid status")
+	}
+	if _, err := s.SetUploadReview(context.Background(), "not-a-uuid",
+		ReviewChange{Status: ReviewApproved}); err == nil {
+		t.Error("expected error for invalid upload ID")
+	}
+}
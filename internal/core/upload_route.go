@@ -0,0 +1,257 @@
+package core
+
+// upload_route.go implements routed uploads: one file whose rows belong to
+// different tables, such as a CRM export with a Record Type column, is
+// split by that column and each part uploaded to its table.
+//
+// The parts run as an upload batch (see upload_batch.go): each is a normal
+// upload with its own validation, inserts, upload record and rollback, and
+// RollbackUploadBatch reverts them together. Every part keeps the file's
+// header, and is mapped to its table by column name, so columns other
+// tables use are ignored. A part missing one of its table's required
+// columns fails the whole request before anything starts.
+//
+// Rows whose route value matches no route go to the default table, if
+// there is one, and are otherwise left out and counted in the result.
+// Line numbers in a part's failed rows count the part's own lines.
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidRoute is returned for a routed upload that cannot be split.
+var ErrInvalidRoute = errors.New("invalid upload route")
+
+// maxUnroutedLines is how many lines of unrouted rows a RoutedUpload
+// lists.
+const maxUnroutedLines = 20
+
+// UploadRoute says which table each row of a routed upload goes to.
+type UploadRoute struct {
+	Column  string            // File column holding each row's route value
+	Tables  map[string]string // Route value -> table key; values match ignoring case and surrounding space
+	Default string            // Table for rows matching no route; empty leaves them out
+}
+
+// RoutedFile is the part of a routed upload sent to one table.
+type RoutedFile struct {
+	TableKey string `json:"table_key"`
+	FileName string `json:"file_name"`
+	UploadID string `json:"upload_id"`
+	Rows     int    `json:"rows"`
+}
+
+// RoutedUpload is a started routed upload.
+type RoutedUpload struct {
+	BatchID       string       `json:"batch_id"`
+	Files         []RoutedFile `json:"files"`
+	Unrouted      int          `json:"unrouted"`                 // Rows left out: no route matched and no default
+	UnroutedLines []int        `json:"unrouted_lines,omitempty"` // The first of their line numbers
+}
+
+// StartRoutedUpload splits a CSV file by route and starts the parts as one
+// upload batch, with mode, dups and dateOpts for every part. data may be
+// gzip-compressed. Returns ErrInvalidRoute if the route does not fit the
+// file, and the batch's errors otherwise.
+func (s *Service) StartRoutedUpload(ctx context.Context, fileName string, data []byte, route UploadRoute, mode UploadMode, dups DuplicatePolicy, dateOpts DateOptions) (*RoutedUpload, error) {
+	if IsZipArchive(fileName, data) || isParquetUpload(fileName, data) || isJSONUpload(fileName, sniffHead(data)) {
+		return nil, fmt.Errorf("%w: routed uploads read CSV files", ErrInvalidRoute)
+	}
+	data, err := decompressBytes(data, s.cfg.Upload.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, result, err := splitRoutedFile(fileName, stripBOM(toUTF8(data)), route)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]BatchFile, len(parts))
+	for i, p := range parts {
+		files[i] = BatchFile{
+			TableKey:   p.tableKey,
+			FileName:   p.fileName,
+			Data:       p.data,
+			Mapping:    p.mapping,
+			Mode:       mode,
+			Duplicates: dups,
+			Dates:      dateOpts,
+		}
+	}
+	batchID, uploadIDs, err := s.StartUploadBatch(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	result.BatchID = batchID
+	for i, p := range parts {
+		result.Files = append(result.Files, RoutedFile{
+			TableKey: p.tableKey,
+			FileName: p.fileName,
+			UploadID: uploadIDs[i],
+			Rows:     p.rows,
+		})
+	}
+	return result, nil
+}
+
+// routedPart is the CSV of one table's rows.
+type routedPart struct {
+	tableKey string
+	fileName string
+	data     []byte
+	mapping  map[string]int
+	rows     int
+}
+
+// splitRoutedFile checks route against the file's header and returns one
+// part per table that gets rows, in table key order, and the rows left
+// out.
+func splitRoutedFile(fileName string, data []byte, route UploadRoute) ([]routedPart, *RoutedUpload, error) {
+	tables, err := routeTables(route)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := parseCSV(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CSV: %w", err)
+	}
+	first := 0
+	for first < len(records) && isEmptyRow(records[first]) {
+		first++
+	}
+	if first == len(records) {
+		return nil, nil, fmt.Errorf("empty file")
+	}
+	header := records[first]
+
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(CleanCell(name), strings.TrimSpace(route.Column)) {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, nil, fmt.Errorf("%w: file has no column %q", ErrInvalidRoute, route.Column)
+	}
+
+	result := &RoutedUpload{}
+	rows := make(map[string][][]string)
+	for i, row := range records[first+1:] {
+		if isEmptyRow(row) {
+			continue
+		}
+		var value string
+		if col < len(row) {
+			value = strings.ToLower(CleanCell(row[col]))
+		}
+		tableKey, ok := tables[value]
+		if !ok {
+			tableKey = route.Default
+		}
+		if tableKey == "" {
+			result.Unrouted++
+			if len(result.UnroutedLines) < maxUnroutedLines {
+				result.UnroutedLines = append(result.UnroutedLines, first+i+2)
+			}
+			continue
+		}
+		rows[tableKey] = append(rows[tableKey], row)
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%w: no rows matched a route", ErrInvalidRoute)
+	}
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	base := strings.TrimSuffix(fileName, path.Ext(fileName))
+	parts := make([]routedPart, 0, len(keys))
+	for _, key := range keys {
+		mapping, err := routeMapping(key, header)
+		if err != nil {
+			return nil, nil, err
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(header)
+		w.WriteAll(rows[key])
+		if err := w.Error(); err != nil {
+			return nil, nil, fmt.Errorf("write %s rows: %w", key, err)
+		}
+		parts = append(parts, routedPart{
+			tableKey: key,
+			fileName: fmt.Sprintf("%s.%s.csv", base, key),
+			data:     buf.Bytes(),
+			mapping:  mapping,
+			rows:     len(rows[key]),
+		})
+	}
+	return parts, result, nil
+}
+
+// routeTables returns route's tables by lowercased route value, after
+// checking every table exists.
+func routeTables(route UploadRoute) (map[string]string, error) {
+	if strings.TrimSpace(route.Column) == "" {
+		return nil, fmt.Errorf("%w: no route column", ErrInvalidRoute)
+	}
+	if len(route.Tables) == 0 {
+		return nil, fmt.Errorf("%w: no routes", ErrInvalidRoute)
+	}
+	tables := make(map[string]string, len(route.Tables))
+	for value, key := range route.Tables {
+		if _, ok := Get(key); !ok {
+			return nil, fmt.Errorf("%w: unknown table %q for %q", ErrInvalidRoute, key, value)
+		}
+		tables[strings.ToLower(strings.TrimSpace(value))] = key
+	}
+	if route.Default != "" {
+		if _, ok := Get(route.Default); !ok {
+			return nil, fmt.Errorf("%w: unknown default table %q", ErrInvalidRoute, route.Default)
+		}
+	}
+	return tables, nil
+}
+
+// routeMapping maps tableKey's columns to the file's header by name,
+// following renamed columns. A required column missing from the header is
+// an error; other missing columns are left out of the mapping.
+func routeMapping(tableKey string, header []string) (map[string]int, error) {
+	def, _ := Get(tableKey)
+	headerIdx := MakeHeaderIndex(header)
+	mapping := make(map[string]int, len(def.Info.Columns))
+	for _, spec := range def.FieldSpecs {
+		idx, ok := headerIdx[strings.ToLower(spec.Name)]
+		if !ok {
+			for _, r := range def.Renames {
+				if strings.EqualFold(r.To, spec.Name) {
+					if idx, ok = headerIdx[strings.ToLower(r.From)]; ok {
+						break
+					}
+				}
+			}
+		}
+		if !ok {
+			if spec.Required {
+				return nil, fmt.Errorf("%w: file has no column %q for %s", ErrInvalidRoute, spec.Name, tableKey)
+			}
+			continue
+		}
+		mapping[spec.Name] = idx
+	}
+	return mapping, nil
+}
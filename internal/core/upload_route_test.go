@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func registerRouteTables(t *testing.T) {
+	Register(TableDefinition{
+		Info: TableInfo{Key: "route_accounts", Columns: []string{"Name", "Industry"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Name", Type: FieldText, Required: true},
+			{Name: "Industry", Type: FieldText},
+		},
+	})
+	Register(TableDefinition{
+		Info: TableInfo{Key: "route_contacts", Columns: []string{"Name", "Email"}},
+		FieldSpecs: []FieldSpec{
+			{Name: "Name", Type: FieldText, Required: true},
+			{Name: "Email", Type: FieldText, Required: true},
+		},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "route_accounts")
+		delete(registry, "route_contacts")
+		registryMu.Unlock()
+	})
+}
+
+func TestSplitRoutedFile(t *testing.T) {
+	registerRouteTables(t)
+
+	data := []byte("Record Type,Name,Email,Industry\n" +
+		"Account,Acme,,Retail\n" +
+		"contact ,Ada,ada@example.com,\n" +
+		"Lead,Bob,bob@example.com,\n" +
+		"Account,Globex,,Energy\n")
+	route := UploadRoute{
+		Column: "record type",
+		Tables: map[string]string{"Account": "route_accounts", "Contact": "route_contacts"},
+	}
+
+	parts, result, err := splitRoutedFile("crm.csv", data, route)
+	if err != nil {
+		t.Fatalf("splitRoutedFile: %v", err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("parts = %d, want 2", len(parts))
+	}
+
+	accounts := parts[0]
+	if accounts.tableKey != "route_accounts" || accounts.fileName != "crm.route_accounts.csv" || accounts.rows != 2 {
+		t.Errorf("accounts part = %+v", accounts)
+	}
+	if accounts.mapping["Name"] != 1 || accounts.mapping["Industry"] != 3 || len(accounts.mapping) != 2 {
+		t.Errorf("accounts mapping = %v", accounts.mapping)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(accounts.data)), "\n"); len(lines) != 3 || lines[2] != "Account,Globex,,Energy" {
+		t.Errorf("accounts data = %q", accounts.data)
+	}
+	if parts[1].tableKey != "route_contacts" || parts[1].rows != 1 {
+		t.Errorf("contacts part = %+v", parts[1])
+	}
+	if result.Unrouted != 1 || len(result.UnroutedLines) != 1 || result.UnroutedLines[0] != 4 {
+		t.Errorf("unrouted = %d %v, want line 4", result.Unrouted, result.UnroutedLines)
+	}
+
+	// The default table takes the rest
+	route.Default = "route_contacts"
+	parts, result, err = splitRoutedFile("crm.csv", data, route)
+	if err != nil {
+		t.Fatalf("splitRoutedFile with default: %v", err)
+	}
+	if parts[1].rows != 2 || result.Unrouted != 0 {
+		t.Errorf("with default: contacts rows = %d, unrouted = %d", parts[1].rows, result.Unrouted)
+	}
+
+	for name, r := range map[string]UploadRoute{
+		"missing column": {Column: "Type", Tables: route.Tables},
+		"no routes":      {Column: "Record Type"},
+		"unknown table":  {Column: "Record Type", Tables: map[string]string{"Account": "nope"}},
+		"no matches":     {Column: "Record Type", Tables: map[string]string{"Opportunity": "route_accounts"}},
+	} {
+		if _, _, err := splitRoutedFile("crm.csv", data, r); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("%s: err = %v, want ErrInvalidRoute", name, err)
+		}
+	}
+
+	// A table whose required column the file lacks
+	noEmail := []byte("Record Type,Name\nContact,Ada\n")
+	if _, _, err := splitRoutedFile("crm.csv", noEmail, route); !errors.Is(err, ErrInvalidRoute) {
+		t.Errorf("missing required column: err = %v, want ErrInvalidRoute", err)
+	}
+}
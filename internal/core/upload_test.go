@@ -2,7 +2,13 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // ============================================================================
@@ -646,6 +652,66 @@ func TestFindHeaderInRecords(t *testing.T) {
 	}
 }
 
+// ============================================================================
+// insertRowByRow Tests
+// ============================================================================
+
+// recordingTx is a pgx.Tx that records every Exec statement.
+// Methods other than Exec are not implemented and panic if called.
+type recordingTx struct {
+	pgx.Tx
+	stmts []string
+}
+
+func (tx *recordingTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.stmts = append(tx.stmts, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestInsertRowByRow_SavepointReuse(t *testing.T) {
+	tx := &recordingTx{}
+	def := TableDefinition{
+		Insert: func(_ context.Context, db DBTX, params any) error {
+			db.Exec(context.Background(), "INSERT")
+			if params == "bad" {
+				return errors.New("constraint violation")
+			}
+			return nil
+		},
+	}
+	batch := []validatedRow{
+		{lineNum: 2, params: "ok"},
+		{lineNum: 3, params: "bad"},
+		{lineNum: 4, params: "ok"},
+		{lineNum: 5, params: "ok"},
+	}
+
+	var failedRows []FailedRow
+	s := &Service{}
+	failed := s.insertRowByRow(context.Background(), tx, def, batch, &failedRows, "test.csv")
+
+	if failed != 1 || len(failedRows) != 1 || failedRows[0].LineNumber != 3 {
+		t.Fatalf("expected line 3 to fail, got failed=%d rows=%v", failed, failedRows)
+	}
+
+	want := []string{
+		rowSavepoint, "INSERT",
+		rowSavepointRestart, "INSERT", rowSavepointRollback,
+		"INSERT",
+		rowSavepointRestart, "INSERT",
+		rowSavepointRelease,
+	}
+	if !reflect.DeepEqual(tx.stmts, want) {
+		t.Errorf("statements:\n got  %q\n want %q", tx.stmts, want)
+	}
+
+	// Old behavior: SAVEPOINT + INSERT + RELEASE per row (+ ROLLBACK on failure)
+	oldRoundTrips := 3*len(batch) + failed
+	if len(tx.stmts) >= oldRoundTrips {
+		t.Errorf("expected fewer than %d round-trips, got %d", oldRoundTrips, len(tx.stmts))
+	}
+}
+
 // ============================================================================
 // Benchmark Tests
 // ============================================================================
@@ -0,0 +1,465 @@
+package core
+
+// users.go keeps the user accounts people sign in to the web UI with, in
+// the auth_users and auth_sessions tables.
+//
+// Passwords are stored as salted PBKDF2-SHA256 hashes. Signing in creates
+// a session: a random token handed to the browser in a cookie, and a CSRF
+// token the browser must echo on requests that change anything. Only the
+// SHA-256 of each is stored. A session lasts SESSION_TTL; signing out or
+// disabling the user ends it early.
+//
+// Every user has a role: a viewer may only read, an editor may also change
+// data, and an admin may also use the admin API. The web layer enforces
+// roles and CSRF tokens for requests signed in with a session.
+//
+// The web layer puts the signed-in user in the request context (see
+// ContextWithUser), and every audit entry written with that context is
+// attributed to them.
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// User errors.
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidUser        = errors.New("invalid user")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrSessionExpired     = errors.New("session expired or signed out")
+)
+
+// Password and session settings
+const (
+	minPasswordLength  = 12
+	passwordIterations = 600_000 // OWASP's 2023 recommendation for PBKDF2-SHA256
+	passwordSaltBytes  = 16
+	passwordKeyBytes   = 32
+	sessionTokenBytes  = 32
+	defaultSessionTTL  = 12 * time.Hour
+)
+
+// User roles, from least to most privileged.
+const (
+	RoleViewer = "viewer" // Reads only
+	RoleEditor = "editor" // Also uploads, edits, deletes and resets
+	RoleAdmin  = "admin"  // Also the admin API
+)
+
+// User is a user account.
+type User struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	Role        string     `json:"role"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+	DisabledAt  *time.Time `json:"disabledAt,omitempty"`
+
+	// AggregatesOnly limits the user to grouped aggregations and
+	// histograms; see aggregate_access.go.
+	AggregatesOnly bool `json:"aggregatesOnly,omitempty"`
+}
+
+// DisplayName returns the user's name, or their email if they have none.
+func (u User) DisplayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.Email
+}
+
+// UserParams contains the fields of a user to create.
+type UserParams struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"` // Defaults to viewer
+
+	AggregatesOnly bool `json:"aggregatesOnly"`
+}
+
+// Session is a signed-in user's session. Token and CSRFToken are only
+// known when the session is created.
+type Session struct {
+	Token     string    `json:"-"`
+	CSRFToken string    `json:"csrfToken,omitempty"`
+	User      User      `json:"user"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	csrfHash string // Stored hash of CSRFToken
+}
+
+// CheckCSRF reports whether token is the session's CSRF token.
+func (s *Session) CheckCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(tokenHash(token)), []byte(s.csrfHash)) == 1
+}
+
+// NormalizeEmail returns email as accounts are stored and looked up:
+// trimmed and in lower case.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validRole reports whether role is one of the user roles.
+func validRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// validateUser checks p and returns it with the email normalized and the
+// role defaulted.
+func validateUser(p UserParams) (UserParams, error) {
+	p.Email = NormalizeEmail(p.Email)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Role = strings.ToLower(strings.TrimSpace(p.Role))
+	if p.Role == "" {
+		p.Role = RoleViewer
+	}
+	if !validRole(p.Role) {
+		return p, fmt.Errorf("%w: role must be viewer, editor or admin, not %q", ErrInvalidUser, p.Role)
+	}
+	if at := strings.IndexByte(p.Email, '@'); at < 1 || at == len(p.Email)-1 {
+		return p, fmt.Errorf("%w: %q is not an email address", ErrInvalidUser, p.Email)
+	}
+	if len(p.Password) < minPasswordLength {
+		return p, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLength)
+	}
+	return p, nil
+}
+
+// hashPassword returns a salted PBKDF2-SHA256 hash of password in the form
+// pbkdf2-sha256$<iterations>$<salt>$<key>.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// dummyPasswordHash is checked against when no user has the email, so an
+// unknown email takes as long to reject as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("not a real password")
+	return hash
+})
+
+// tokenHash returns the stored form of a session or CSRF token.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken returns a random token for a session or CSRF check.
+func newToken() (string, error) {
+	token := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// CreateUser creates a user account.
+func (s *Service) CreateUser(ctx context.Context, p UserParams) (*User, error) {
+	p, err := validateUser(p)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hashPassword(p.Password)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	qctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	user, err := scanUser(s.pool.QueryRow(qctx,
+		`INSERT INTO auth_users (email, name, password_hash, role, aggregates_only)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+userColumns,
+		p.Email, p.Name, hash, p.Role, p.AggregatesOnly,
+	))
+	if err != nil {
+		if strings.Contains(err.Error(), "auth_users_email_key") {
+			return nil, fmt.Errorf("%w: %s", ErrUserExists, p.Email)
+		}
+		return nil, fmt.Errorf("create user: %w", err)
+	}
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), userAuditParams(ctx, ActionUserCreate, user)); err != nil {
+		return user, fmt.Errorf("user %s created but not audited: %w", user.ID, err)
+	}
+	return user, nil
+}
+
+// ListUsers returns all users, disabled ones included, by email.
+func (s *Service) ListUsers(ctx context.Context) ([]User, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+userColumns+` FROM auth_users ORDER BY email`)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+// DisableUser stops a user from signing in and ends their sessions. The
+// account is kept so the audit log still names them.
+func (s *Service) DisableUser(ctx context.Context, id string) (*User, error) {
+	uid := ToPgUUID(id)
+	if !uid.Valid {
+		return nil, fmt.Errorf("%w: invalid ID %s", ErrUserNotFound, id)
+	}
+
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`UPDATE auth_users SET is_active = FALSE, disabled_at = COALESCE(disabled_at, NOW())
+		 WHERE id = $1
+		 RETURNING `+userColumns,
+		uid,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("disable user: %w", err)
+	}
+	if _, err := s.pool.Exec(ctx, `DELETE FROM auth_sessions WHERE user_id = $1`, uid); err != nil {
+		return user, fmt.Errorf("user %s disabled but sessions not ended: %w", user.ID, err)
+	}
+	if _, err := s.LogAudit(context.WithoutCancel(ctx), userAuditParams(ctx, ActionUserDisable, user)); err != nil {
+		return user, fmt.Errorf("user %s disabled but not audited: %w", user.ID, err)
+	}
+	return user, nil
+}
+
+// userAuditParams returns the audit entry of creating or disabling u.
+func userAuditParams(ctx context.Context, action AuditAction, u *User) AuditLogParams {
+	reason := fmt.Sprintf("Created user %s (%s)", u.Email, u.Role)
+	if u.AggregatesOnly {
+		reason += " (aggregates only)"
+	}
+	if action == ActionUserDisable {
+		reason = "Disabled user " + u.Email + " and ended their sessions"
+	}
+	return AuditLogParams{
+		Action:    action,
+		NewValue:  u.ID,
+		IPAddress: GetIPAddressFromContext(ctx),
+		UserAgent: GetUserAgentFromContext(ctx),
+		Reason:    reason,
+		RowData: map[string]any{
+			"user_id":         u.ID,
+			"email":           u.Email,
+			"name":            u.Name,
+			"role":            u.Role,
+			"aggregates_only": u.AggregatesOnly,
+		},
+	}
+}
+
+// Login checks an email and password and starts a session for the user.
+// A wrong password, unknown email and disabled user all return
+// ErrInvalidCredentials.
+func (s *Service) Login(ctx context.Context, email, password string) (*Session, error) {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	var (
+		id   pgtype.UUID
+		hash string
+	)
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, password_hash FROM auth_users WHERE email = $1 AND is_active`,
+		NormalizeEmail(email),
+	).Scan(&id, &hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		checkPassword(dummyPasswordHash(), password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up user: %w", err)
+	}
+	if !checkPassword(hash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate session token: %w", err)
+	}
+	csrf, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate CSRF token: %w", err)
+	}
+	ttl := s.cfg.Security.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	session := &Session{Token: token, CSRFToken: csrf, ExpiresAt: time.Now().Add(ttl), csrfHash: tokenHash(csrf)}
+
+	// Expired sessions are cleared on the way
+	if _, err := s.pool.Exec(ctx, `DELETE FROM auth_sessions WHERE expires_at < NOW()`); err != nil {
+		return nil, fmt.Errorf("clear expired sessions: %w", err)
+	}
+	if _, err := s.pool.Exec(ctx,
+		`INSERT INTO auth_sessions (token_hash, csrf_token_hash, user_id, user_agent, ip_address, expires_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, '')::inet, $6)`,
+		tokenHash(session.Token), session.csrfHash, id,
+		GetUserAgentFromContext(ctx), GetIPAddressFromContext(ctx), session.ExpiresAt,
+	); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`UPDATE auth_users SET last_login_at = NOW() WHERE id = $1 RETURNING `+userColumns, id))
+	if err != nil {
+		return nil, fmt.Errorf("record login: %w", err)
+	}
+	session.User = *user
+	return session, nil
+}
+
+// GetSession returns the session of token with its user, or
+// ErrSessionExpired if the session is unknown, expired or its user
+// disabled.
+func (s *Service) GetSession(ctx context.Context, token string) (*Session, error) {
+	if token == "" {
+		return nil, ErrSessionExpired
+	}
+	ctx, cancel := s.withOpTimeout(ctx, opQuery)
+	defer cancel()
+
+	var session Session
+	user, err := scanUser(s.pool.QueryRow(ctx,
+		`SELECT `+prefixedUserColumns+`, s.expires_at, s.csrf_token_hash
+		 FROM auth_sessions s JOIN auth_users u ON u.id = s.user_id
+		 WHERE s.token_hash = $1 AND s.expires_at > NOW() AND u.is_active`,
+		tokenHash(token),
+	), &session.ExpiresAt, &session.csrfHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSessionExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+	session.User = *user
+	return &session, nil
+}
+
+// Logout ends the session of token. Ending an unknown session is not an
+// error.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	if _, err := s.pool.Exec(ctx, `DELETE FROM auth_sessions WHERE token_hash = $1`, tokenHash(token)); err != nil {
+		return fmt.Errorf("end session: %w", err)
+	}
+	return nil
+}
+
+// withContextUser attributes p to the user in ctx, or else its API token,
+// unless p names a user already. Tokens are recorded with a "token:" ID.
+func withContextUser(ctx context.Context, p AuditLogParams) AuditLogParams {
+	if p.UserID != "" || p.UserEmail != "" {
+		return p
+	}
+	if u := GetUserFromContext(ctx); u != nil {
+		p.UserID = u.ID
+		p.UserEmail = u.Email
+		p.UserName = u.Name
+	} else if t := GetAPITokenFromContext(ctx); t != nil {
+		p.UserID = "token:" + t.ID
+		p.UserName = "API token " + t.Name
+	}
+	return p
+}
+
+const userColumns = `id, email, name, role, created_at, last_login_at, disabled_at, aggregates_only`
+
+const prefixedUserColumns = `u.id, u.email, u.name, u.role, u.created_at, u.last_login_at, u.disabled_at, u.aggregates_only`
+
+// scanUser scans one row selected with userColumns, followed by any extra
+// columns into extra.
+func scanUser(row pgx.Row, extra ...any) (*User, error) {
+	var (
+		id        pgtype.UUID
+		lastLogin pgtype.Timestamptz
+		disabled  pgtype.Timestamptz
+		user      User
+	)
+	dest := append([]any{&id, &user.Email, &user.Name, &user.Role, &user.CreatedAt, &lastLogin, &disabled, &user.AggregatesOnly}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	user.ID = PgUUIDToString(id)
+	if lastLogin.Valid {
+		user.LastLoginAt = &lastLogin.Time
+	}
+	if disabled.Valid {
+		user.DisabledAt = &disabled.Time
+	}
+	return &user, nil
+}
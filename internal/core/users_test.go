@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse battery")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") {
+		t.Errorf("hash = %q", hash)
+	}
+	if !checkPassword(hash, "correct horse battery") {
+		t.Error("checkPassword rejected the right password")
+	}
+	if checkPassword(hash, "correct horse battery ") {
+		t.Error("checkPassword accepted a wrong password")
+	}
+	if other, _ := hashPassword("correct horse battery"); other == hash {
+		t.Error("two hashes of one password are equal; salt not random")
+	}
+	for _, bad := range []string{"", "plain", "pbkdf2-sha256$x$AA$AA", "bcrypt$10$AA$AA"} {
+		if checkPassword(bad, "") {
+			t.Errorf("checkPassword(%q) = true", bad)
+		}
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	p, err := validateUser(UserParams{Email: " Ada@Example.com ", Name: " Ada ", Password: "long enough pw"})
+	if err != nil {
+		t.Fatalf("validateUser: %v", err)
+	}
+	if p.Email != "ada@example.com" || p.Name != "Ada" || p.Role != RoleViewer {
+		t.Errorf("params = %+v, want a normalized viewer", p)
+	}
+	if p, err := validateUser(UserParams{Email: "ada@example.com", Password: "long enough pw", Role: " Admin "}); err != nil || p.Role != RoleAdmin {
+		t.Errorf("role = %q, %v; want admin", p.Role, err)
+	}
+	for name, p := range map[string]UserParams{
+		"no at":          {Email: "ada", Password: "long enough pw"},
+		"no domain":      {Email: "ada@", Password: "long enough pw"},
+		"short password": {Email: "ada@example.com", Password: "short"},
+		"unknown role":   {Email: "ada@example.com", Password: "long enough pw", Role: "owner"},
+	} {
+		if _, err := validateUser(p); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("%s: err = %v, want ErrInvalidUser", name, err)
+		}
+	}
+}
+
+func TestWithContextUser(t *testing.T) {
+	ctx := ContextWithUser(context.Background(), &User{ID: "u1", Email: "ada@example.com", Name: "Ada"})
+
+	p := withContextUser(ctx, AuditLogParams{Action: ActionCellEdit})
+	if p.UserID != "u1" || p.UserEmail != "ada@example.com" || p.UserName != "Ada" {
+		t.Errorf("params = %+v, want the context user", p)
+	}
+
+	// An explicit user wins
+	p = withContextUser(ctx, AuditLogParams{Action: ActionAuditImport, UserEmail: "bob@example.com"})
+	if p.UserID != "" || p.UserEmail != "bob@example.com" {
+		t.Errorf("params = %+v, want the explicit user", p)
+	}
+
+	if p := withContextUser(context.Background(), AuditLogParams{}); p.UserID != "" {
+		t.Errorf("params = %+v without a user", p)
+	}
+}
+
+func TestSessionCheckCSRF(t *testing.T) {
+	token, err := newToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{csrfHash: tokenHash(token)}
+
+	if !session.CheckCSRF(token) {
+		t.Error("CheckCSRF rejected the session's token")
+	}
+	for _, bad := range []string{"", token + "0", strings.ToUpper(token)} {
+		if session.CheckCSRF(bad) {
+			t.Errorf("CheckCSRF(%q) = true", bad)
+		}
+	}
+	if (&Session{}).CheckCSRF("") {
+		t.Error("CheckCSRF accepted an empty token for a session without one")
+	}
+}
+
+func TestUserAuditParams(t *testing.T) {
+	ctx := ContextWithUserAgent(ContextWithIPAddress(context.Background(), "192.0.2.1"), "test-agent")
+	u := &User{ID: "u1", Email: "ada@example.com", Name: "Ada", Role: RoleEditor}
+
+	p := userAuditParams(ctx, ActionUserCreate, u)
+	if p.Action != ActionUserCreate || p.NewValue != "u1" || p.IPAddress != "192.0.2.1" {
+		t.Errorf("params = %+v", p)
+	}
+	if p.Reason != "Created user ada@example.com (editor)" || p.RowData["role"] != RoleEditor {
+		t.Errorf("reason %q row data %v, want the role", p.Reason, p.RowData)
+	}
+	if p := userAuditParams(ctx, ActionUserDisable, u); !strings.HasPrefix(p.Reason, "Disabled user ada@example.com") {
+		t.Errorf("reason = %q", p.Reason)
+	}
+}
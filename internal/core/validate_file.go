@@ -0,0 +1,317 @@
+package core
+
+// validate_file.go checks a file against a table without a database, for
+// vendors' pre-submission checks and CI pipelines.
+//
+// The checks are the upload's own: header detection or the column mapping,
+// the column count, every FieldSpec, BuildParams, ValidateRow, and
+// ValidateBatch over batches of the upload batch size, plus the table's
+// size and row limits. What only the database can tell - keys already in
+// the table, constraint errors on insert, the daily upload limit - is left
+// to the dry run (see DryRunUpload).
+//
+// Unlike an upload, which stops at a row's first error, the report counts
+// every error of a row, grouped by error code (see MapRowError). Whether
+// the file passes is decided by ValidationThresholds.
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxValidationErrors bounds the row errors listed in a ValidationReport;
+// the counts cover every error.
+const maxValidationErrors = 100
+
+// ValidationThresholds decide whether a validated file passes. Each
+// threshold that is not negative must hold; with both negative, the file
+// passes only without error rows. The zero value allows no errors.
+type ValidationThresholds struct {
+	MaxErrorRows    int     `json:"maxErrorRows"`
+	MaxErrorPercent float64 `json:"maxErrorPercent"` // Of data rows, 0-100
+}
+
+// ValidateOptions configure ValidateFile.
+type ValidateOptions struct {
+	FileName    string
+	Mapping     map[string]int // Expected column -> CSV column index, as for uploads
+	BatchSize   int            // Rows per ValidateBatch call; 0 means one batch
+	MaxFileSize int64          // Max (decompressed) file size in bytes; 0 means no limit
+	Dates       DateOptions    // How dates are read, as for uploads
+	Thresholds  ValidationThresholds
+}
+
+// ValidationCodeCount is how often one error code occurred.
+type ValidationCodeCount struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Action  string `json:"action"`
+	Count   int    `json:"count"` // Errors with this code
+	Rows    int    `json:"rows"`  // Rows with at least one such error
+}
+
+// ValidationRowError is one error of one row.
+type ValidationRowError struct {
+	LineNumber int    `json:"lineNumber"`
+	Code       string `json:"code"`
+	Reason     string `json:"reason"`
+}
+
+// ValidationFileError is a problem with the file as a whole (no header,
+// too large, too many rows) that fails it regardless of thresholds.
+type ValidationFileError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+// ValidationReport is the machine-readable result of ValidateFile.
+type ValidationReport struct {
+	TableKey         string                          `json:"tableKey"`
+	FileName         string                          `json:"fileName,omitempty"`
+	Passed           bool                            `json:"passed"`
+	TotalRows        int                             `json:"totalRows"` // Non-empty data rows
+	ValidRows        int                             `json:"validRows"`
+	ErrorRows        int                             `json:"errorRows"`
+	ErrorPercent     float64                         `json:"errorPercent"`
+	DuplicateInFile  int                             `json:"duplicateInFile"` // Extra occurrences of repeated keys
+	ErrorCodes       map[string]*ValidationCodeCount `json:"errorCodes"`
+	Errors           []ValidationRowError            `json:"errors"`
+	ErrorsTruncated  bool                            `json:"errorsTruncated,omitempty"`
+	FileError        *ValidationFileError            `json:"fileError,omitempty"`
+	DateWarnings     []DateWarning                   `json:"dateWarnings,omitempty"`
+	Thresholds       ValidationThresholds            `json:"thresholds"`
+	Failed           []string                        `json:"failed,omitempty"` // Why the file did not pass
+	ProcessingTimeMs int64                           `json:"processingTimeMs"`
+}
+
+// ValidateFile checks fileData (which may be gzip-compressed) against a
+// table as an upload would, without touching the database, and reports the
+// errors by code. A problem with the file itself is reported as the
+// report's FileError; the returned error is only for an unknown or
+// read-only table or an invalid date format.
+func ValidateFile(tableKey string, fileData []byte, opts ValidateOptions) (*ValidationReport, error) {
+	startTime := time.Now()
+
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	if err := checkWritable(def); err != nil {
+		return nil, err
+	}
+	dateOpts, err := opts.Dates.validated()
+	if err != nil {
+		return nil, err
+	}
+	opts.Dates = dateOpts
+
+	report := &ValidationReport{
+		TableKey:   tableKey,
+		FileName:   opts.FileName,
+		ErrorCodes: map[string]*ValidationCodeCount{},
+		Errors:     []ValidationRowError{},
+		Thresholds: opts.Thresholds,
+	}
+
+	v := &fileValidator{def: def, report: report}
+	v.run(fileData, opts)
+	report.Passed, report.Failed = opts.Thresholds.check(report)
+	report.ProcessingTimeMs = time.Since(startTime).Milliseconds()
+	return report, nil
+}
+
+// ValidateFile is the package-level ValidateFile with the service's upload
+// batch size and file size limit.
+func (s *Service) ValidateFile(tableKey, fileName string, fileData []byte, mapping map[string]int, dateOpts DateOptions, thresholds ValidationThresholds) (*ValidationReport, error) {
+	return ValidateFile(tableKey, fileData, ValidateOptions{
+		FileName:    fileName,
+		Mapping:     mapping,
+		BatchSize:   s.cfg.Upload.BatchSize,
+		MaxFileSize: s.cfg.Upload.MaxFileSize,
+		Dates:       dateOpts,
+		Thresholds:  thresholds,
+	})
+}
+
+// fileValidator accumulates a ValidationReport.
+type fileValidator struct {
+	def    TableDefinition
+	report *ValidationReport
+}
+
+func (v *fileValidator) run(fileData []byte, opts ValidateOptions) {
+	def := v.def
+
+	fileData, err := decompressBytes(fileData, opts.MaxFileSize)
+	if err != nil {
+		v.fileError(err.Error())
+		return
+	}
+	if opts.MaxFileSize > 0 && int64(len(fileData)) > opts.MaxFileSize {
+		v.fileError(fmt.Sprintf("file too large: %d bytes (max %d)", len(fileData), opts.MaxFileSize))
+		return
+	}
+	if limit := def.Limits.MaxFileBytes; limit > 0 && int64(len(fileData)) > limit {
+		v.fileError(fmt.Sprintf("file exceeds table size limit for %s: %d bytes (max %d)",
+			def.Info.Key, len(fileData), limit))
+		return
+	}
+
+	fileData, err = parquetAsCSV(opts.FileName, fileData, def, len(opts.Mapping) > 0)
+	if err != nil {
+		v.fileError(err.Error())
+		return
+	}
+	fileData, err = jsonAsCSV(opts.FileName, stripBOM(toUTF8(fileData)), def, len(opts.Mapping) > 0)
+	if err != nil {
+		v.fileError(err.Error())
+		return
+	}
+	records, err := parseCSV(fileData)
+	if err != nil {
+		v.fileError(fmt.Sprintf("invalid CSV: %v", err))
+		return
+	}
+	if len(records) == 0 {
+		v.fileError("empty file")
+		return
+	}
+
+	// Find the header as the upload does
+	var headerIdx HeaderIndex
+	headerRowIndex := 0
+	if len(opts.Mapping) > 0 {
+		headerIdx = buildMappedHeaderIndex(resolveMappingColumns(def, opts.Mapping, "validation mapping"), records[0])
+	} else {
+		headerRowIndex = findHeaderInRecords(records, def.Info.Columns)
+		if headerRowIndex < 0 {
+			v.fileError(fmt.Sprintf("header not found (expected: %v)", def.Info.Columns))
+			return
+		}
+		headerIdx = MakeHeaderIndex(records[headerRowIndex])
+	}
+	dataRows := records[headerRowIndex+1:]
+	if msg := rowLimitError(def, len(dataRows)); msg != "" {
+		v.fileError(msg)
+		return
+	}
+
+	var batch []validatedRow
+	seenKeys := make(map[string]int)
+	dates := newDateStats(def, headerIdx, opts.Dates)
+	flush := func() {
+		var failed []FailedRow
+		kept := applyBatchRules(def, batch, headerIdx, &failed, opts.FileName)
+		for _, fr := range failed {
+			v.rowErrors(fr.LineNumber, []string{fr.Reason})
+		}
+		v.report.ValidRows += len(kept)
+		for _, vr := range kept {
+			if len(def.Info.UniqueKey) == 0 {
+				break
+			}
+			if key := extractUniqueKey(vr.row, headerIdx, def.Info.UniqueKey); key != "" {
+				if seenKeys[key]++; seenKeys[key] > 1 {
+					v.report.DuplicateInFile++
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for i, row := range dataRows {
+		if isEmptyRow(row) {
+			continue
+		}
+		v.report.TotalRows++
+		lineNum := headerRowIndex + i + 2 // 1-indexed, after header
+
+		// Report every field error; BuildParams only runs once they pass
+		dates.observe(row)
+		row, err := normalizeDates(row, headerIdx, def, opts.Dates)
+		if err != nil {
+			v.rowErrors(lineNum, []string{err.Error()})
+			continue
+		}
+		errs := validateRowComplete(row, headerIdx, def)
+		if len(errs) == 0 {
+			if _, err := buildAndValidate(row, headerIdx, def, pgtype.UUID{}); err != nil {
+				errs = []string{err.Error()}
+			}
+		}
+		if len(errs) > 0 {
+			v.rowErrors(lineNum, errs)
+			continue
+		}
+
+		batch = append(batch, validatedRow{index: i, lineNum: lineNum, row: row})
+		if opts.BatchSize > 0 && len(batch) >= opts.BatchSize {
+			flush()
+		}
+	}
+	flush()
+	v.report.DateWarnings = dates.warnings()
+
+	if v.report.TotalRows > 0 {
+		v.report.ErrorPercent = float64(v.report.ErrorRows) * 100 / float64(v.report.TotalRows)
+	}
+	sort.SliceStable(v.report.Errors, func(i, j int) bool {
+		return v.report.Errors[i].LineNumber < v.report.Errors[j].LineNumber
+	})
+}
+
+// rowErrors records the errors of one invalid row.
+func (v *fileValidator) rowErrors(lineNum int, reasons []string) {
+	v.report.ErrorRows++
+	counted := make(map[string]bool, len(reasons))
+	for _, reason := range reasons {
+		msg := MapRowError(reason)
+		c := v.report.ErrorCodes[msg.Code]
+		if c == nil {
+			c = &ValidationCodeCount{Code: msg.Code, Message: msg.Message, Action: msg.Action}
+			v.report.ErrorCodes[msg.Code] = c
+		}
+		c.Count++
+		if !counted[msg.Code] {
+			counted[msg.Code] = true
+			c.Rows++
+		}
+
+		if len(v.report.Errors) < maxValidationErrors {
+			v.report.Errors = append(v.report.Errors, ValidationRowError{LineNumber: lineNum, Code: msg.Code, Reason: reason})
+		} else {
+			v.report.ErrorsTruncated = true
+		}
+	}
+}
+
+// fileError records a problem with the whole file.
+func (v *fileValidator) fileError(reason string) {
+	msg := MapRowError(reason)
+	v.report.FileError = &ValidationFileError{Code: msg.Code, Message: msg.Message, Reason: reason}
+}
+
+// check reports whether a report passes the thresholds, and if not why.
+func (t ValidationThresholds) check(r *ValidationReport) (bool, []string) {
+	var failed []string
+	if r.FileError != nil {
+		failed = append(failed, fmt.Sprintf("file error %s: %s", r.FileError.Code, r.FileError.Reason))
+	}
+	if t.MaxErrorRows < 0 && t.MaxErrorPercent < 0 {
+		if r.ErrorRows > 0 {
+			failed = append(failed, fmt.Sprintf("%d error rows (none allowed)", r.ErrorRows))
+		}
+		return len(failed) == 0, failed
+	}
+	if t.MaxErrorRows >= 0 && r.ErrorRows > t.MaxErrorRows {
+		failed = append(failed, fmt.Sprintf("%d error rows (max %d)", r.ErrorRows, t.MaxErrorRows))
+	}
+	if t.MaxErrorPercent >= 0 && r.ErrorPercent > t.MaxErrorPercent {
+		failed = append(failed, fmt.Sprintf("%.2f%% error rows (max %g%%)", r.ErrorPercent, t.MaxErrorPercent))
+	}
+	return len(failed) == 0, failed
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func registerValidateTestTable(t *testing.T) {
+	t.Helper()
+	Register(TableDefinition{
+		Info: TableInfo{
+			Key:       "validate_orders",
+			Columns:   []string{"Order ID", "Amount", "Paid", "Status"},
+			UniqueKey: []string{"Order ID"},
+		},
+		FieldSpecs: []FieldSpec{
+			{Name: "Order ID", Type: FieldText, Required: true},
+			{Name: "Amount", Type: FieldNumeric, Required: true},
+			{Name: "Paid", Type: FieldBool, Required: true},
+			{Name: "Status", Type: FieldEnum, Required: true, EnumValues: []string{"open", "closed"}},
+		},
+		Limits:      UploadLimits{MaxRows: 8},
+		BuildParams: func(row []string, _ HeaderIndex, _ pgtype.UUID) (any, error) { return row, nil },
+		ValidateRow: func(row map[string]string) error {
+			if row["Status"] == "closed" && row["Amount"] == "0" {
+				return NewRuleError("closed_amount", "closed orders need an invalid date of payment")
+			}
+			return nil
+		},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "validate_orders")
+		registryMu.Unlock()
+	})
+}
+
+const validateTestCSV = `Export 2024-01-31
+Order ID,Amount,Paid,Status
+A1,10,yes,open
+A2,ten,maybe,open
+A3,5,no,pending
+A1,7,no,closed
+
+,,,
+A4,0,yes,closed
+A5,1
+`
+
+func TestValidateFile(t *testing.T) {
+	registerValidateTestTable(t)
+
+	report, err := ValidateFile("validate_orders", []byte(validateTestCSV), ValidateOptions{
+		FileName:   "orders.csv",
+		Thresholds: ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: -1},
+	})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	if report.TotalRows != 6 || report.ValidRows != 2 || report.ErrorRows != 4 || report.DuplicateInFile != 1 {
+		t.Errorf("rows = %d total, %d valid, %d error, %d duplicate", report.TotalRows, report.ValidRows, report.ErrorRows, report.DuplicateInFile)
+	}
+
+	// Every error of a row counts; the rule is VAL009 despite its message
+	wantCodes := map[string][2]int{"VAL002": {1, 1}, "VAL007": {1, 1}, "VAL006": {1, 1}, "VAL008": {1, 1}, "VAL009": {1, 1}}
+	if len(report.ErrorCodes) != len(wantCodes) {
+		t.Errorf("codes = %v", report.ErrorCodes)
+	}
+	for code, want := range wantCodes {
+		if c := report.ErrorCodes[code]; c == nil || c.Count != want[0] || c.Rows != want[1] {
+			t.Errorf("code %s = %+v, want count %d rows %d", code, c, want[0], want[1])
+		}
+	}
+	// Line numbers count records, as an upload's do; blank lines are not records
+	if len(report.Errors) != 5 || report.Errors[0].LineNumber != 4 || report.Errors[len(report.Errors)-1].LineNumber != 9 {
+		t.Errorf("errors = %+v", report.Errors)
+	}
+	if report.Passed || len(report.Failed) != 1 || report.FileError != nil {
+		t.Errorf("passed = %v, failed = %v, file error = %+v", report.Passed, report.Failed, report.FileError)
+	}
+}
+
+func TestValidateFile_FileErrors(t *testing.T) {
+	registerValidateTestTable(t)
+
+	tests := []struct {
+		name     string
+		data     string
+		opts     ValidateOptions
+		wantCode string
+	}{
+		{"empty", "", ValidateOptions{}, "FILE005"},
+		{"no header", "a,b\n1,2\n", ValidateOptions{}, "VAL005"},
+		{"too large", validateTestCSV, ValidateOptions{MaxFileSize: 10}, "FILE001"},
+		{"row limit", "Order ID,Amount,Paid,Status\n" + strings.Repeat("A1,1,yes,open\n", 9), ValidateOptions{}, "FILE007"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ValidateFile("validate_orders", []byte(tt.data), tt.opts)
+			if err != nil {
+				t.Fatalf("ValidateFile: %v", err)
+			}
+			if report.FileError == nil || report.FileError.Code != tt.wantCode || report.Passed {
+				t.Errorf("file error = %+v, passed = %v, want %s", report.FileError, report.Passed, tt.wantCode)
+			}
+		})
+	}
+
+	if _, err := ValidateFile("nope", nil, ValidateOptions{}); err == nil || !strings.Contains(err.Error(), "unknown table") {
+		t.Errorf("unknown table: err = %v", err)
+	}
+}
+
+func TestValidateFile_GzipAndMapping(t *testing.T) {
+	registerValidateTestTable(t)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("status,id,amount,paid\nopen,B1,3,yes\nopen,B2,x,no\n"))
+	gz.Close()
+
+	mapping := map[string]int{"Order ID": 1, "Amount": 2, "Paid": 3, "Status": 0}
+	report, err := ValidateFile("validate_orders", buf.Bytes(), ValidateOptions{Mapping: mapping})
+	if err != nil {
+		t.Fatalf("ValidateFile: %v", err)
+	}
+	if report.TotalRows != 2 || report.ErrorRows != 1 || report.ErrorPercent != 50 || report.ErrorCodes["VAL002"] == nil {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestValidationThresholds(t *testing.T) {
+	r := &ValidationReport{TotalRows: 100, ErrorRows: 3, ErrorPercent: 3}
+
+	tests := []struct {
+		name       string
+		thresholds ValidationThresholds
+		want       bool
+	}{
+		{"zero value allows no errors", ValidationThresholds{}, false},
+		{"none set allows no errors", ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: -1}, false},
+		{"rows", ValidationThresholds{MaxErrorRows: 3, MaxErrorPercent: -1}, true},
+		{"percent", ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: 2.5}, false},
+		{"both must hold", ValidationThresholds{MaxErrorRows: 5, MaxErrorPercent: 2}, false},
+		{"both hold", ValidationThresholds{MaxErrorRows: 5, MaxErrorPercent: 5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, failed := tt.thresholds.check(r); got != tt.want {
+				t.Errorf("check = %v (%v), want %v", got, failed, tt.want)
+			}
+		})
+	}
+
+	// A file error fails whatever the thresholds
+	r = &ValidationReport{FileError: &ValidationFileError{Code: "FILE005", Reason: "empty file"}}
+	if ok, _ := (ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: 100}).check(r); ok {
+		t.Error("file error passed")
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+// validate_value.go checks a single value against a column, for forms and
+// the inline cell editor that want to flag a bad value as it is typed.
+//
+// The value goes through what an upload does to a cell of the column: it
+// is cleaned (CleanCell), dates are read with the upload's DateOptions,
+// the FieldSpec's Normalizer runs, and the result is checked and converted
+// to the column's type. Errors are worded as upload errors, so they carry
+// the same error codes (see MapRowError). Table rules (ValidateRow,
+// ValidateBatch) need a whole row and are not run.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownColumn is returned for a column the table does not have.
+var ErrUnknownColumn = errors.New("unknown column")
+
+// ValueValidation is the result of ValidateValue.
+type ValueValidation struct {
+	Valid  bool   `json:"valid"`
+	Column string `json:"column"`          // Current column name
+	Value  string `json:"value"`           // The value as stored; empty is NULL
+	Error  string `json:"error,omitempty"` // Why an upload would reject the value
+	Code   string `json:"code,omitempty"`  // Error code, as for upload errors
+	Action string `json:"action,omitempty"`
+}
+
+// ValidateValue checks value for a table's column as an upload checks a
+// cell, and returns the value as it would be stored. column may be an old
+// name (see Renames). The error is for an unknown table or column or
+// invalid date options; an invalid value is reported in the result.
+func ValidateValue(tableKey, column, value string, opts DateOptions) (*ValueValidation, error) {
+	def, ok := Get(tableKey)
+	if !ok {
+		return nil, fmt.Errorf("unknown table: %s", tableKey)
+	}
+	opts, err := opts.validated()
+	if err != nil {
+		return nil, err
+	}
+	name, _ := resolveColumn(def, column)
+	spec, _ := editColumn(def, name)
+	if spec == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+	}
+
+	result := &ValueValidation{Column: spec.Name}
+	stored, reason := storedValue(*spec, value, opts)
+	if reason != "" {
+		msg := MapRowError(reason)
+		result.Error, result.Code, result.Action = reason, msg.Code, msg.Action
+		return result, nil
+	}
+	result.Valid = true
+	result.Value = stored
+	return result, nil
+}
+
+// storedValue returns value as an upload stores it in spec's column, as
+// text ("" for NULL), or why the upload rejects it.
+func storedValue(spec FieldSpec, value string, opts DateOptions) (string, string) {
+	raw := CleanCell(value)
+	if raw == "" {
+		if spec.Required && !spec.AllowEmpty {
+			return "", fmt.Sprintf("empty required field %q", spec.Name)
+		}
+		return "", ""
+	}
+
+	// Dates are read the upload's way before the normalizer sees them (see
+	// normalizeDates)
+	if spec.Type == FieldDate {
+		d, twoDigitYear := opts.parseDate(raw, spec)
+		switch {
+		case !d.Valid && opts.Format == DateFormatDMY:
+			return "", fmt.Sprintf("invalid date for %q: %q (expected DD/MM/YYYY)", spec.Name, raw)
+		case d.Valid && (opts.Format == DateFormatDMY || twoDigitYear):
+			raw = d.Time.Format("2006-01-02")
+		}
+	}
+	if spec.Normalizer != nil {
+		if raw = spec.Normalizer(raw); raw == "" {
+			return "", ""
+		}
+	}
+
+	switch spec.Type {
+	case FieldDate:
+		d := ToPgDate(raw)
+		if !d.Valid {
+			return "", fmt.Sprintf("invalid date for %q: %q", spec.Name, raw)
+		}
+		return d.Time.Format("2006-01-02"), ""
+	case FieldNumeric:
+		n := ToPgNumeric(raw)
+		v, err := n.Value()
+		s, ok := v.(string)
+		if !n.Valid || err != nil || !ok {
+			return "", fmt.Sprintf("invalid numeric for %q: %q", spec.Name, raw)
+		}
+		return s, ""
+	case FieldBool:
+		b := ToPgBool(raw)
+		if !b.Valid {
+			return "", fmt.Sprintf("invalid bool for %q: %q", spec.Name, raw)
+		}
+		return strconv.FormatBool(b.Bool), ""
+	case FieldEnum:
+		for _, v := range spec.EnumValues {
+			if strings.EqualFold(raw, v) {
+				return ToPgText(raw).String, ""
+			}
+		}
+		if len(spec.EnumValues) > 0 {
+			return "", fmt.Sprintf("invalid enum for %q: %q", spec.Name, raw)
+		}
+	}
+	return ToPgText(raw).String, ""
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStoredValue(t *testing.T) {
+	specs := map[string]FieldSpec{
+		"text":     {Name: "Vendor", Type: FieldText, Normalizer: strings.ToUpper},
+		"required": {Name: "Invoice", Type: FieldText, Required: true},
+		"num":      {Name: "Amount", Type: FieldNumeric},
+		"date":     {Name: "Invoice Date", Type: FieldDate},
+		"bool":     {Name: "Paid", Type: FieldBool},
+		"enum":     {Name: "Status", Type: FieldEnum, EnumValues: []string{"open", "closed"}},
+	}
+	dmy := DateOptions{Format: DateFormatDMY}
+
+	tests := []struct {
+		spec       string
+		value      string
+		opts       DateOptions
+		want       string
+		wantReason string
+	}{
+		{"text", ` ="acme" `, DateOptions{}, "ACME", ""},
+		{"text", "", DateOptions{}, "", ""},
+		{"required", " ", DateOptions{}, "", `empty required field "Invoice"`},
+		{"num", "1,200.50", DateOptions{}, "1200.50", ""},
+		{"num", "-45", DateOptions{}, "-45", ""},
+		{"num", "lots", DateOptions{}, "", `invalid numeric for "Amount": "lots"`},
+		{"date", "03/01/2024", DateOptions{}, "2024-03-01", ""},
+		{"date", "03/01/2024", dmy, "2024-01-03", ""},
+		{"date", "13/25/2024", dmy, "", `invalid date for "Invoice Date": "13/25/2024" (expected DD/MM/YYYY)`},
+		{"date", "someday", DateOptions{}, "", `invalid date for "Invoice Date": "someday"`},
+		{"bool", "Yes", DateOptions{}, "true", ""},
+		{"bool", "maybe", DateOptions{}, "", `invalid bool for "Paid": "maybe"`},
+		{"enum", "Closed", DateOptions{}, "Closed", ""},
+		{"enum", "void", DateOptions{}, "", `invalid enum for "Status": "void"`},
+	}
+	for _, tt := range tests {
+		got, reason := storedValue(specs[tt.spec], tt.value, tt.opts)
+		if got != tt.want || reason != tt.wantReason {
+			t.Errorf("%s %q: got %q, %q; want %q, %q", tt.spec, tt.value, got, reason, tt.want, tt.wantReason)
+		}
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	Register(TableDefinition{
+		Info: TableInfo{Key: "validate_value_invoices"},
+		FieldSpecs: []FieldSpec{
+			{Name: "Invoice", Type: FieldText, Required: true},
+			{Name: "Amount", Type: FieldNumeric},
+		},
+		Renames: []ColumnRename{{From: "Total", To: "Amount"}},
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "validate_value_invoices")
+		registryMu.Unlock()
+	})
+
+	got, err := ValidateValue("validate_value_invoices", "total", "1,000", DateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Valid || got.Column != "Amount" || got.Value != "1000" {
+		t.Errorf("got %+v", got)
+	}
+
+	got, err = ValidateValue("validate_value_invoices", "Amount", "ten", DateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Valid || got.Code != "VAL002" || got.Error == "" || got.Action == "" {
+		t.Errorf("got %+v", got)
+	}
+
+	got, err = ValidateValue("validate_value_invoices", "Invoice", "", DateOptions{})
+	if err != nil || got.Valid || got.Code != "VAL003" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	if _, err := ValidateValue("validate_value_invoices", "Memo", "x", DateOptions{}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("unknown column: err = %v", err)
+	}
+	if _, err := ValidateValue("validate_value_invoices", "Amount", "1", DateOptions{Format: "ymd"}); err == nil {
+		t.Error("invalid date format: no error")
+	}
+	if _, err := ValidateValue("no_such_table", "Amount", "1", DateOptions{}); err == nil {
+		t.Error("unknown table: no error")
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+// views.go supports read-only virtual tables backed by SQL views.
+//
+// A view table is registered like any other table, with FieldSpecs for its
+// columns, but sets View to the SELECT that defines it instead of the
+// upload functions. The view is created under the table's key by SyncViews
+// at startup, so the dashboard, table view, filtering, sorting and export
+// read it with the same queries as an imported table. Uploads, edits,
+// resets and backfills reject it with ErrReadOnlyTable.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnlyTable is returned when an upload or edit targets a view table.
+var ErrReadOnlyTable = errors.New("table is read-only")
+
+// IsView reports whether the table is a read-only view.
+func (def TableDefinition) IsView() bool {
+	return def.View != ""
+}
+
+// checkWritable returns ErrReadOnlyTable if def is a view.
+func checkWritable(def TableDefinition) error {
+	if def.IsView() {
+		return fmt.Errorf("%w: %s is a view", ErrReadOnlyTable, def.Info.Key)
+	}
+	return nil
+}
+
+// validateView checks that a view table declares columns and none of the
+// write-path options, which a view cannot honor.
+func validateView(def TableDefinition) error {
+	if !def.IsView() {
+		return nil
+	}
+	switch {
+	case len(def.FieldSpecs) == 0:
+		return fmt.Errorf("view needs FieldSpecs for its columns")
+	case def.BuildParams != nil || def.Insert != nil || def.Reset != nil || def.DeleteByUploadID != nil:
+		return fmt.Errorf("view cannot define upload or reset functions")
+	case len(def.CopyColumns) > 0 || def.CopyRow != nil:
+		return fmt.Errorf("view cannot define COPY support")
+	case def.UploadMode != "":
+		return fmt.Errorf("view cannot set an upload mode")
+	case len(def.Statistics) > 0:
+		return fmt.Errorf("view cannot declare extended statistics")
+	case def.ValidateRow != nil || def.ValidateBatch != nil:
+		return fmt.Errorf("view cannot define validation rules")
+	case len(def.Renames) > 0 || len(def.DroppedColumns) > 0:
+		return fmt.Errorf("view columns are renamed in its SELECT, not with Renames")
+	}
+	return nil
+}
+
+// createViewSQL returns the statement that creates or replaces the view.
+func createViewSQL(def TableDefinition) string {
+	return fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s", quoteIdentifier(def.Info.Key), def.View)
+}
+
+// SyncViews creates every registered view table, replacing the existing
+// view. CREATE OR REPLACE cannot drop or retype columns, so when a view's
+// columns changed it is dropped and recreated in one transaction. A view
+// that fails does not stop the others; all failures are returned joined.
+func (s *Service) SyncViews(ctx context.Context) error {
+	var errs []error
+	for _, def := range All() {
+		if !def.IsView() {
+			continue
+		}
+		if err := s.syncView(ctx, def); err != nil {
+			errs = append(errs, fmt.Errorf("create view %s: %w", def.Info.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Service) syncView(ctx context.Context, def TableDefinition) error {
+	ctx, cancel := s.withOpTimeout(ctx, opMutation)
+	defer cancel()
+
+	if _, err := s.pool.Exec(ctx, createViewSQL(def)); err == nil {
+		return nil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DROP VIEW IF EXISTS "+quoteIdentifier(def.Info.Key)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, createViewSQL(def)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
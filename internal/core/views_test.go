@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+func registerViewTable(t *testing.T) {
+	t.Helper()
+	Register(TableDefinition{
+		Info:       TableInfo{Key: "view_orders", Group: "Views"},
+		FieldSpecs: []FieldSpec{{Name: "Order ID", Type: FieldText}, {Name: "Total", Type: FieldNumeric}},
+		View:       "SELECT order_id, total FROM orders",
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "view_orders")
+		registryMu.Unlock()
+	})
+}
+
+func TestRegister_View(t *testing.T) {
+	registerViewTable(t)
+
+	def, _ := Get("view_orders")
+	if !def.IsView() || !def.Info.ReadOnly {
+		t.Errorf("view not marked read-only: %+v", def.Info)
+	}
+	if len(def.Info.Columns) != 2 {
+		t.Errorf("Columns = %v, want populated from FieldSpecs", def.Info.Columns)
+	}
+	if got := createViewSQL(def); got != `CREATE OR REPLACE VIEW "view_orders" AS SELECT order_id, total FROM orders` {
+		t.Errorf("createViewSQL = %q", got)
+	}
+}
+
+func TestValidateView(t *testing.T) {
+	specs := []FieldSpec{{Name: "a", Type: FieldText}}
+	tests := []struct {
+		name string
+		def  TableDefinition
+		want string
+	}{
+		{"no columns", TableDefinition{View: "SELECT 1"}, "FieldSpecs"},
+		{"insert", TableDefinition{View: "SELECT 1", FieldSpecs: specs, Insert: func(context.Context, DBTX, any) error { return nil }}, "upload or reset"},
+		{"copy", TableDefinition{View: "SELECT 1", FieldSpecs: specs, CopyColumns: []string{"a"}}, "COPY"},
+		{"upload mode", TableDefinition{View: "SELECT 1", FieldSpecs: specs, UploadMode: UploadModeUpsert}, "upload mode"},
+		{"statistics", TableDefinition{View: "SELECT 1", FieldSpecs: specs, Statistics: []ExtendedStatistics{{Columns: []string{"a"}}}}, "statistics"},
+		{"renames", TableDefinition{View: "SELECT 1", FieldSpecs: specs, Renames: []ColumnRename{{From: "b", To: "a"}}}, "Renames"},
+		{"rules", TableDefinition{View: "SELECT 1", FieldSpecs: specs, ValidateRow: func(map[string]string) error { return nil }}, "validation rules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateView(tt.def)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if err := validateView(TableDefinition{View: "SELECT 1", FieldSpecs: specs}); err != nil {
+		t.Errorf("valid view: %v", err)
+	}
+	if err := validateView(TableDefinition{}); err != nil {
+		t.Errorf("non-view: %v", err)
+	}
+}
+
+func TestView_RejectsWrites(t *testing.T) {
+	registerViewTable(t)
+	s := &Service{cfg: &config.Config{}}
+	ctx := context.Background()
+
+	checks := map[string]error{}
+	_, checks["StartUpload"] = s.StartUpload(ctx, "view_orders", "f.csv", []byte("a\n1\n"), nil, "", "", DateOptions{})
+	_, checks["StartUploadStreaming"] = s.StartUploadStreaming(ctx, "view_orders", "f.csv", strings.NewReader("a\n1\n"), 4, nil, "", "", DateOptions{})
+	_, checks["DryRunUpload"] = s.DryRunUpload(ctx, "view_orders", []byte("a\n1\n"), nil, "", DateOptions{})
+	_, checks["prepareBatchFile"] = s.prepareBatchFile(ctx, "b", BatchFile{TableKey: "view_orders", FileName: "f.csv", Data: []byte("a\n1\n")})
+	checks["Reset"] = s.Reset(ctx, "view_orders")
+	_, checks["DeleteRows"] = s.DeleteRows(ctx, "view_orders", []string{"1"})
+	_, checks["UpdateCell"] = s.UpdateCell(ctx, "view_orders", UpdateCellRequest{})
+	_, checks["BulkEditRows"] = s.BulkEditRows(ctx, "view_orders", BulkEditRequest{})
+	_, checks["planBackfill"] = planBackfill("view_orders", "Total", BackfillSource{Kind: BackfillReparse, Column: "Order ID"})
+
+	for name, err := range checks {
+		if !errors.Is(err, ErrReadOnlyTable) {
+			t.Errorf("%s: err = %v, want ErrReadOnlyTable", name, err)
+		}
+	}
+}
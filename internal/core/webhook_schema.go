@@ -0,0 +1,113 @@
+package core
+
+// webhook_schema.go defines the webhook payload schema receivers can code
+// against, served as JSON Schema by GET /api/webhooks/schema.
+//
+// A schema version is a promise. Within it, payloads may gain optional
+// fields and new events, so receivers must ignore fields they don't know;
+// but no field is removed, renamed, retyped or made optional. A change that
+// needs any of those bumps WebhookSchemaVersion. webhook_schema_test.go
+// pins the v1 contract and fails on changes that would break it.
+
+import "slices"
+
+// webhookEventsV1 are the events of schema version 1.
+var webhookEventsV1 = []ActivityKind{
+	ActivityUploadCompleted,
+	ActivityUploadFailed,
+	ActivityRollback,
+	ActivityReset,
+}
+
+// webhookField is one field of a webhook payload.
+type webhookField struct {
+	name     string
+	typ      string         // JSON Schema type
+	format   string         // JSON Schema format, if any
+	events   []ActivityKind // Events that may carry it; nil for all
+	required bool           // Present in every payload of those events
+	desc     string
+}
+
+// webhookFieldsV1 are the fields of schema version 1. Counts of zero and
+// empty strings are left out of payloads, so only the envelope is
+// required.
+var webhookFieldsV1 = func() []webhookField {
+	uploads := []ActivityKind{ActivityUploadCompleted, ActivityUploadFailed, ActivityRollback}
+	return []webhookField{
+		{name: "schema_version", typ: "integer", required: true, desc: "Payload schema version"},
+		{name: "id", typ: "string", required: true, desc: "Delivery ID, the same for every attempt"},
+		{name: "event", typ: "string", required: true, desc: "Event name"},
+		{name: "table_key", typ: "string", required: true, desc: "Table the event happened to"},
+		{name: "time", typ: "string", format: "date-time", required: true, desc: "When the event happened"},
+		{name: "upload_id", typ: "string", events: uploads, desc: "In-memory upload ID, as used by the progress API"},
+		{name: "record_id", typ: "string", events: []ActivityKind{ActivityUploadCompleted, ActivityRollback}, desc: "Upload history ID, once committed"},
+		{name: "file_name", typ: "string", events: uploads, desc: "Uploaded file"},
+		{name: "mode", typ: "string", events: uploads, desc: "Upload mode: insert, upsert, replace or delete"},
+		{name: "batch_id", typ: "string", desc: "Upload batch, or resets run together"},
+		{name: "inserted", typ: "integer", events: uploads, desc: "Rows inserted"},
+		{name: "updated", typ: "integer", events: []ActivityKind{ActivityUploadCompleted}, desc: "Rows replaced by an upsert"},
+		{name: "deleted", typ: "integer", events: []ActivityKind{ActivityUploadCompleted}, desc: "Rows deleted by a delete upload"},
+		{name: "skipped", typ: "integer", events: uploads, desc: "Rows that failed validation"},
+		{name: "rows_deleted", typ: "integer", events: []ActivityKind{ActivityRollback, ActivityReset}, desc: "Rows removed by the rollback or reset"},
+		{name: "error", typ: "string", events: []ActivityKind{ActivityUploadFailed, ActivityReset}, desc: "Why the upload failed, or why the reset stopped part way"},
+	}
+}()
+
+// WebhookSchemaDoc is the JSON Schema of each event's payload for one
+// schema version.
+type WebhookSchemaDoc struct {
+	Version int                             `json:"schema_version"`
+	Events  map[ActivityKind]map[string]any `json:"events"`
+}
+
+// WebhookSchema returns the payload schemas of version, or false if there
+// is no such version.
+func WebhookSchema(version int) (*WebhookSchemaDoc, bool) {
+	if version != 1 {
+		return nil, false
+	}
+	doc := &WebhookSchemaDoc{Version: version, Events: make(map[ActivityKind]map[string]any, len(webhookEventsV1))}
+	for _, event := range webhookEventsV1 {
+		doc.Events[event] = webhookEventSchema(version, event, webhookFieldsV1)
+	}
+	return doc, true
+}
+
+// webhookEventSchema returns the JSON Schema of event's payload.
+func webhookEventSchema(version int, event ActivityKind, fields []webhookField) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, f := range fields {
+		if !f.carriedBy(event) {
+			continue
+		}
+		prop := map[string]any{"type": f.typ, "description": f.desc}
+		if f.format != "" {
+			prop["format"] = f.format
+		}
+		switch f.name {
+		case "schema_version":
+			prop["const"] = version
+		case "event":
+			prop["const"] = event
+		}
+		properties[f.name] = prop
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                string(event),
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": true,
+	}
+}
+
+// carriedBy reports whether payloads of event may carry f.
+func (f webhookField) carriedBy(event ActivityKind) bool {
+	return f.events == nil || slices.Contains(f.events, event)
+}
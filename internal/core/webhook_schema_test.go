@@ -0,0 +1,187 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookContractV1 is what receivers of schema version 1 may rely on:
+// for each event, its fields and their JSON types. It must never change;
+// fields may only be added to the schema.
+var webhookContractV1 = map[ActivityKind]map[string]string{
+	ActivityUploadCompleted: {
+		"schema_version": "integer", "id": "string", "event": "string", "table_key": "string", "time": "string",
+		"upload_id": "string", "record_id": "string", "file_name": "string", "mode": "string", "batch_id": "string",
+		"inserted": "integer", "updated": "integer", "deleted": "integer", "skipped": "integer",
+	},
+	ActivityUploadFailed: {
+		"schema_version": "integer", "id": "string", "event": "string", "table_key": "string", "time": "string",
+		"upload_id": "string", "file_name": "string", "mode": "string", "batch_id": "string",
+		"inserted": "integer", "skipped": "integer", "error": "string",
+	},
+	ActivityRollback: {
+		"schema_version": "integer", "id": "string", "event": "string", "table_key": "string", "time": "string",
+		"upload_id": "string", "record_id": "string", "file_name": "string", "mode": "string", "batch_id": "string",
+		"inserted": "integer", "skipped": "integer", "rows_deleted": "integer",
+	},
+	ActivityReset: {
+		"schema_version": "integer", "id": "string", "event": "string", "table_key": "string", "time": "string",
+		"batch_id": "string", "rows_deleted": "integer", "error": "string",
+	},
+}
+
+// webhookRequiredV1 are the fields of every version 1 payload.
+var webhookRequiredV1 = []string{"schema_version", "id", "event", "table_key", "time"}
+
+func TestWebhookSchemaV1_KeepsContract(t *testing.T) {
+	doc, ok := WebhookSchema(1)
+	if !ok {
+		t.Fatal("WebhookSchema(1) not found")
+	}
+	if doc.Version != 1 {
+		t.Errorf("Version = %d", doc.Version)
+	}
+	for event, fields := range webhookContractV1 {
+		schema, ok := doc.Events[event]
+		if !ok {
+			t.Errorf("%s: event removed", event)
+			continue
+		}
+		props := schema["properties"].(map[string]any)
+		for name, typ := range fields {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				t.Errorf("%s: field %s removed", event, name)
+				continue
+			}
+			if prop["type"] != typ {
+				t.Errorf("%s: field %s is %v, was %s", event, name, prop["type"], typ)
+			}
+		}
+		required := schema["required"].([]string)
+		for _, name := range webhookRequiredV1 {
+			if !slices.Contains(required, name) {
+				t.Errorf("%s: field %s no longer required", event, name)
+			}
+		}
+		if schema["additionalProperties"] != true {
+			t.Errorf("%s: additional properties not allowed", event)
+		}
+	}
+
+	if _, ok := WebhookSchema(2); ok {
+		t.Error("WebhookSchema(2) found")
+	}
+}
+
+func TestWebhookPayloads_MatchSchema(t *testing.T) {
+	doc, _ := WebhookSchema(WebhookSchemaVersion)
+	at := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+	upload := UploadEvent{
+		UploadID: "u1", RecordID: "r1", TableKey: "invoices", FileName: "jan.csv", Mode: UploadModeInsert, BatchID: "b1",
+		Inserted: 8, Updated: 2, Deleted: 1, Skipped: 3, RowsDeleted: 10, Error: "bad file", Time: at,
+	}
+	committed, rolledBack, failed := upload, upload, upload
+	committed.Event = HookAfterCommit
+	rolledBack.Event = HookAfterRollback
+	failed.Event, failed.RecordID = HookAfterRollback, "" // Never committed
+	var payloads []WebhookPayload
+	for _, ev := range []UploadEvent{committed, rolledBack, failed} {
+		p, ok := uploadWebhookPayload(ev)
+		if !ok {
+			t.Fatalf("no payload for %s", ev.Event)
+		}
+		payloads = append(payloads, p)
+	}
+	payloads = append(payloads, WebhookPayload{Event: ActivityReset, TableKey: "invoices", BatchID: "b1", RowsDeleted: 10, Error: "timeout"})
+
+	seen := map[ActivityKind]bool{}
+	for _, p := range payloads {
+		_, body, err := encodeWebhook(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		event := ActivityKind(got["event"].(string))
+		seen[event] = true
+		schema, ok := doc.Events[event]
+		if !ok {
+			t.Errorf("event %s not in schema", event)
+			continue
+		}
+		props := schema["properties"].(map[string]any)
+		for name, value := range got {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				t.Errorf("%s: field %s sent but not in schema", event, name)
+				continue
+			}
+			if typ := jsonType(value); typ != prop["type"] {
+				t.Errorf("%s: field %s is %s, schema says %v", event, name, typ, prop["type"])
+			}
+		}
+		for _, name := range schema["required"].([]string) {
+			if _, ok := got[name]; !ok {
+				t.Errorf("%s: required field %s missing", event, name)
+			}
+		}
+		if got["schema_version"] != float64(WebhookSchemaVersion) {
+			t.Errorf("%s: schema_version = %v", event, got["schema_version"])
+		}
+	}
+	for _, event := range webhookEventsV1 {
+		if !seen[event] {
+			t.Errorf("no payload checked for %s", event)
+		}
+	}
+}
+
+// TestWebhookPayload_ListsItsFields guards against UploadResult or
+// UploadEvent being embedded in the payload, which would send every field
+// added to them without a schema change.
+func TestWebhookPayload_ListsItsFields(t *testing.T) {
+	declared := map[string]bool{}
+	for _, f := range webhookFieldsV1 {
+		declared[f.name] = true
+	}
+	typ := reflect.TypeOf(WebhookPayload{})
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		if f.Anonymous {
+			t.Errorf("WebhookPayload embeds %s", f.Type)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !declared[name] {
+			t.Errorf("WebhookPayload.%s (%q) is not in the schema", f.Name, name)
+		}
+	}
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "string"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,213 @@
+package core
+
+// webhooks.go notifies downstream systems when data lands or is removed:
+// each URL in WEBHOOK_URLS gets a JSON POST when an upload commits or
+// fails, and when an upload is rolled back or a table reset.
+//
+// Unlike external upload hooks (see upload_hooks.go), webhooks cannot
+// affect the upload and are delivered reliably: a failed delivery is
+// retried with doubling backoff up to WEBHOOK_MAX_ATTEMPTS times. Every
+// attempt carries the same X-Webhook-ID, so a receiver can ignore repeats.
+// With WEBHOOK_SECRET set, each request is signed:
+//
+//	X-Webhook-Timestamp: 1735689600
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Dry runs send no webhooks. Payloads follow a versioned schema; see
+// webhook_schema.go.
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSchemaVersion is the version of the webhook payload schema, sent
+// as schema_version in every payload and in X-Webhook-Schema-Version.
+const WebhookSchemaVersion = 1
+
+// webhookHTTPClient delivers webhooks; requests are bounded by
+// WEBHOOK_TIMEOUT instead of a client timeout.
+var webhookHTTPClient = &http.Client{}
+
+// WebhookPayload is the JSON body POSTed to webhook URLs. Event is one of
+// upload_completed, upload_failed, rollback and reset. A field added here
+// must be added to the schema in webhook_schema.go too.
+type WebhookPayload struct {
+	SchemaVersion int          `json:"schema_version"`
+	ID            string       `json:"id"` // Same for every attempt
+	Event         ActivityKind `json:"event"`
+	TableKey      string       `json:"table_key"`
+	UploadID      string       `json:"upload_id,omitempty"` // In-memory upload (progress API)
+	RecordID      string       `json:"record_id,omitempty"` // csv_uploads row, once committed
+	FileName      string       `json:"file_name,omitempty"`
+	Mode          UploadMode   `json:"mode,omitempty"`
+	BatchID       string       `json:"batch_id,omitempty"`
+
+	Inserted    int    `json:"inserted,omitempty"`
+	Updated     int    `json:"updated,omitempty"`      // upload_completed: rows replaced by upsert
+	Deleted     int    `json:"deleted,omitempty"`      // upload_completed: rows deleted by a delete upload
+	Skipped     int    `json:"skipped,omitempty"`      // Rows that failed validation
+	RowsDeleted int64  `json:"rows_deleted,omitempty"` // rollback, reset
+	Error       string `json:"error,omitempty"`        // upload_failed: why; reset: why it stopped part way
+
+	Time time.Time `json:"time"`
+}
+
+// uploadWebhook sends the webhook for an after_commit or after_rollback
+// event; other events send none.
+func (s *Service) uploadWebhook(ev UploadEvent) {
+	if p, ok := uploadWebhookPayload(ev); ok {
+		s.sendWebhook(p)
+	}
+}
+
+// uploadWebhookPayload returns the webhook payload for ev, or false if ev
+// sends none.
+func uploadWebhookPayload(ev UploadEvent) (WebhookPayload, bool) {
+	p := WebhookPayload{
+		TableKey: ev.TableKey,
+		UploadID: ev.UploadID,
+		RecordID: ev.RecordID,
+		FileName: ev.FileName,
+		Mode:     ev.Mode,
+		BatchID:  ev.BatchID,
+		Inserted: ev.Inserted,
+		Skipped:  ev.Skipped,
+		Time:     ev.Time,
+	}
+	switch {
+	case ev.Event == HookAfterCommit:
+		p.Event = ActivityUploadCompleted
+		p.Updated = ev.Updated
+		p.Deleted = ev.Deleted
+	case ev.Event == HookAfterRollback && ev.RecordID != "":
+		p.Event = ActivityRollback
+		p.RowsDeleted = ev.RowsDeleted
+	case ev.Event == HookAfterRollback:
+		p.Event = ActivityUploadFailed
+		p.Error = ev.Error
+	default:
+		return WebhookPayload{}, false
+	}
+	return p, true
+}
+
+// sendWebhook delivers p to every webhook URL in the background, unless
+// WEBHOOK_EVENTS leaves its event out.
+func (s *Service) sendWebhook(p WebhookPayload) {
+	cfg := s.cfg.Webhooks
+	if len(cfg.URLs) == 0 {
+		return
+	}
+	if len(cfg.Events) > 0 && !slices.Contains(cfg.Events, string(p.Event)) {
+		return
+	}
+	p, body, err := encodeWebhook(p)
+	if err != nil {
+		slog.Error("failed to encode webhook", "event", p.Event, "table", p.TableKey, "error", err)
+		return
+	}
+	for _, url := range cfg.URLs {
+		go s.deliverWebhook(url, p, body)
+	}
+}
+
+// encodeWebhook gives p a delivery ID, schema version and, if it has
+// none, the current time, and returns it with its JSON body.
+func encodeWebhook(p WebhookPayload) (WebhookPayload, []byte, error) {
+	p.SchemaVersion = WebhookSchemaVersion
+	p.ID = uuid.New().String()
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	body, err := json.Marshal(p)
+	return p, body, err
+}
+
+// deliverWebhook posts body to url until it is accepted or the attempts
+// run out. Responses other than 408, 429 and 5xx are not retried.
+func (s *Service) deliverWebhook(url string, p WebhookPayload, body []byte) {
+	cfg := s.cfg.Webhooks
+	backoff := cfg.RetryBackoff
+	var err error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		var retry bool
+		retry, err = postWebhook(url, p, body, cfg.Secret, cfg.Timeout)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == cfg.MaxAttempts {
+			break
+		}
+		slog.Warn("webhook delivery failed, retrying",
+			"event", p.Event,
+			"id", p.ID,
+			"attempt", attempt,
+			"retry_in", backoff,
+			"error", err,
+		)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	slog.Error("failed to deliver webhook",
+		"event", p.Event,
+		"id", p.ID,
+		"table", p.TableKey,
+		"error", err,
+	)
+}
+
+// postWebhook makes one delivery attempt, reporting whether a failure is
+// worth retrying.
+func postWebhook(url string, p WebhookPayload, body []byte, secret string, timeout time.Duration) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", p.ID)
+	req.Header.Set("X-Webhook-Event", string(p.Event))
+	req.Header.Set("X-Webhook-Schema-Version", strconv.Itoa(p.SchemaVersion))
+	if secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Webhook-Timestamp", ts)
+		req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(secret, ts, body))
+	}
+
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode == http.StatusRequestTimeout ||
+			resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+type webhookRequest struct {
+	header  http.Header
+	payload WebhookPayload
+	valid   bool // Signature matched
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	const secret = "s3cret"
+	requests := make(chan webhookRequest, 10)
+	statuses := make(chan int, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := webhookRequest{header: r.Header}
+		json.Unmarshal(body, &req.payload)
+		want := "sha256=" + signWebhook(secret, r.Header.Get("X-Webhook-Timestamp"), body)
+		req.valid = r.Header.Get("X-Webhook-Signature") == want
+		requests <- req
+		w.WriteHeader(<-statuses)
+	}))
+	defer srv.Close()
+
+	s := &Service{cfg: &config.Config{Webhooks: config.WebhookConfig{
+		URLs:         []string{srv.URL},
+		Secret:       secret,
+		Events:       []string{"upload_completed", "upload_failed"},
+		Timeout:      time.Second,
+		MaxAttempts:  3,
+		RetryBackoff: time.Millisecond,
+	}}}
+	next := func() webhookRequest {
+		t.Helper()
+		select {
+		case req := <-requests:
+			return req
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook not delivered")
+			return webhookRequest{}
+		}
+	}
+
+	// A 503 is retried with the same ID
+	statuses <- http.StatusServiceUnavailable
+	statuses <- http.StatusOK
+	s.uploadWebhook(UploadEvent{Event: HookAfterCommit, UploadID: "u1", RecordID: "r1", TableKey: "invoices", Inserted: 8, Updated: 2})
+	first, second := next(), next()
+	if !first.valid || !second.valid {
+		t.Error("signature did not match")
+	}
+	p := second.payload
+	if p.Event != ActivityUploadCompleted || p.TableKey != "invoices" || p.RecordID != "r1" || p.Inserted != 8 || p.Updated != 2 {
+		t.Errorf("payload = %+v", p)
+	}
+	if p.ID == "" || first.payload.ID != p.ID || second.header.Get("X-Webhook-ID") != p.ID {
+		t.Errorf("IDs = %q, %q, header %q; want one ID", first.payload.ID, p.ID, second.header.Get("X-Webhook-ID"))
+	}
+	if second.header.Get("X-Webhook-Event") != "upload_completed" {
+		t.Errorf("X-Webhook-Event = %q", second.header.Get("X-Webhook-Event"))
+	}
+	if p.SchemaVersion != WebhookSchemaVersion || second.header.Get("X-Webhook-Schema-Version") != "1" {
+		t.Errorf("schema version = %d, header %q", p.SchemaVersion, second.header.Get("X-Webhook-Schema-Version"))
+	}
+
+	// A 400 is not retried
+	statuses <- http.StatusBadRequest
+	s.uploadWebhook(UploadEvent{Event: HookAfterRollback, UploadID: "u2", TableKey: "invoices", Error: "cancelled"})
+	if p := next().payload; p.Event != ActivityUploadFailed || !strings.Contains(p.Error, "cancelled") {
+		t.Errorf("failed payload = %+v", p)
+	}
+
+	// Events left out of WEBHOOK_EVENTS, and after_batch, are not sent
+	s.uploadWebhook(UploadEvent{Event: HookAfterRollback, RecordID: "r1", TableKey: "invoices", RowsDeleted: 10})
+	s.uploadWebhook(UploadEvent{Event: HookAfterBatch, UploadID: "u3", TableKey: "invoices"})
+	s.sendWebhook(WebhookPayload{Event: ActivityReset, TableKey: "invoices"})
+	select {
+	case req := <-requests:
+		t.Errorf("unexpected webhook %+v", req.payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,208 @@
+package core
+
+// xlsx_export.go writes the xlsx export format (see exporter.go): a minimal
+// Office Open XML workbook with a single worksheet. The package parts are
+// small and fixed except the worksheet, which is streamed into the zip
+// entry row by row. Text cells are inline strings rather than entries in a
+// shared string table, which Excel would need to see in full before the
+// first row. The first row is the header, in bold. A watermarked export's
+// WatermarkColumn is hidden (see export_watermark.go).
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xlsxMaxRows is Excel's row limit per sheet, including the header.
+const xlsxMaxRows = 1048576
+
+// ErrExportTooLarge is returned when an export exceeds its format's limit.
+var ErrExportTooLarge = errors.New("export exceeds the format's row limit")
+
+// xlsxStaticParts are the package parts written before the worksheet.
+// %s in the workbook is the sheet name.
+var xlsxStaticParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`},
+	// Style 1 is bold, for the header row
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`},
+}
+
+const (
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxExporter writes an xlsx workbook.
+type xlsxExporter struct {
+	zw      *zip.Writer
+	sheet   *bufio.Writer // The worksheet entry, once started
+	name    string
+	types   map[string]FieldType
+	colType []FieldType
+	rows    int // Rows written, including the header
+}
+
+func newXLSXExporter(w io.Writer, def TableDefinition) Exporter {
+	return &xlsxExporter{zw: zip.NewWriter(w), name: xlsxSheetName(def.Info.Key), types: exportColumnTypes(def)}
+}
+
+func (e *xlsxExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+
+func (e *xlsxExporter) Extension() string { return "xlsx" }
+
+// start writes the fixed parts and opens the worksheet.
+func (e *xlsxExporter) start() error {
+	for _, part := range xlsxStaticParts {
+		f, err := e.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		body := part.body
+		if part.name == "xl/workbook.xml" {
+			body = fmt.Sprintf(body, xmlEscape(e.name))
+		}
+		if _, err := io.WriteString(f, body); err != nil {
+			return err
+		}
+	}
+	f, err := e.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriter(f)
+	_, err = e.sheet.WriteString(xlsxSheetStart)
+	return err
+}
+
+func (e *xlsxExporter) WriteHeader(columns []string) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	e.colType = make([]FieldType, len(columns))
+	for i, col := range columns {
+		e.colType[i] = e.types[col]
+		if col == WatermarkColumn {
+			fmt.Fprintf(e.sheet, `<cols><col min="%d" max="%d" width="0" hidden="1"/></cols>`, i+1, i+1)
+		}
+	}
+	e.sheet.WriteString(`<sheetData><row>`)
+	for _, col := range columns {
+		e.sheet.WriteString(`<c t="inlineStr" s="1"><is><t xml:space="preserve">`)
+		e.sheet.WriteString(xmlEscape(col))
+		e.sheet.WriteString(`</t></is></c>`)
+	}
+	e.rows++
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxExporter) WriteRow(record []string) error {
+	if e.rows >= xlsxMaxRows {
+		return fmt.Errorf("%w: xlsx holds at most %d rows; filter the export or use csv", ErrExportTooLarge, xlsxMaxRows-1)
+	}
+	e.sheet.WriteString(`<row>`)
+	for i, v := range record {
+		switch n, ok := exportNumber(v); {
+		case v == "":
+			e.sheet.WriteString(`<c/>`)
+		case ok && i < len(e.colType) && e.colType[i] == FieldNumeric:
+			e.sheet.WriteString(`<c><v>`)
+			e.sheet.WriteString(n)
+			e.sheet.WriteString(`</v></c>`)
+		default:
+			e.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			e.sheet.WriteString(xmlEscape(v))
+			e.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	e.rows++
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (e *xlsxExporter) Flush() error {
+	if e.sheet == nil {
+		return nil
+	}
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Flush()
+}
+
+func (e *xlsxExporter) Close() error {
+	if e.sheet == nil {
+		if err := e.start(); err != nil {
+			return err
+		}
+		e.sheet.WriteString(`<sheetData>`)
+	}
+	if _, err := e.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := e.sheet.Flush(); err != nil {
+		return err
+	}
+	return e.zw.Close()
+}
+
+// xlsxSheetName makes a valid sheet name from a table key: at most 31
+// characters and none of : \ / ? * [ ].
+func xlsxSheetName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, key)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		return "Sheet1"
+	}
+	return name
+}
+
+// xmlEscape escapes s for XML text and attributes. Characters XML cannot
+// hold (most control characters) become U+FFFD.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -2,16 +2,34 @@ package web
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 
 	"github.com/JonMunkholm/TUI/internal/core"
 )
 
-// WithRequestMetadata adds IP and User-Agent to context for audit logging.
+// WithRequestMetadata adds IP and User-Agent to context for audit logging,
+// and the signed-in user, API token or else the API key (hashed) for
+// per-uploader upload quotas. The user and token themselves are already in
+// r's context (see sessionAuth and tokenAuth).
 func WithRequestMetadata(ctx context.Context, r *http.Request) context.Context {
 	ip := r.RemoteAddr // Already processed by chi middleware.RealIP
 	ua := r.Header.Get("User-Agent")
 	ctx = core.ContextWithIPAddress(ctx, ip)
 	ctx = core.ContextWithUserAgent(ctx, ua)
+	if u := core.GetUserFromContext(ctx); u != nil {
+		ctx = core.ContextWithUploader(ctx, "user:"+u.ID)
+	} else if t := core.GetAPITokenFromContext(ctx); t != nil {
+		ctx = core.ContextWithUploader(ctx, "token:"+t.ID)
+	} else if key := r.Header.Get("X-API-Key"); key != "" {
+		ctx = core.ContextWithUploader(ctx, uploaderForKey(key))
+	}
 	return ctx
 }
+
+// uploaderForKey identifies an API key's uploads without keeping the key.
+func uploaderForKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,416 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListLockouts lists client IPs with recent failed API key attempts.
+func (s *Server) handleListLockouts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.lockout.Status())
+}
+
+// handleUnlockClient clears the failed attempts and lockout of a client IP.
+func (s *Server) handleUnlockClient(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(chi.URLParam(r, "ip"))
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, "invalid IP address")
+		return
+	}
+
+	if !s.lockout.Unlock(ip.String()) {
+		writeError(w, http.StatusNotFound, "no failed attempts recorded for this IP")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	if _, err := s.auditor.LogAudit(ctx, core.AuditLogParams{
+		Action:    core.ActionAuthUnlock,
+		IPAddress: core.GetIPAddressFromContext(ctx),
+		UserAgent: core.GetUserAgentFromContext(ctx),
+		Reason:    fmt.Sprintf("Unlocked API key auth for %s", ip),
+	}); err != nil {
+		slog.Error("failed to log unlock audit", "ip", ip.String(), "error", err)
+	}
+
+	writeJSON(w, map[string]string{"status": "unlocked", "ip": ip.String()})
+}
+
+// auditLockout records an audit entry when a client is locked out after
+// repeated failed API key attempts.
+func (s *Server) auditLockout(r *http.Request, _ string, failures int) {
+	ctx := WithRequestMetadata(r.Context(), r)
+	if _, err := s.auditor.LogAudit(ctx, core.AuditLogParams{
+		Action:       core.ActionAuthLockout,
+		IPAddress:    core.GetIPAddressFromContext(ctx),
+		UserAgent:    core.GetUserAgentFromContext(ctx),
+		RowsAffected: failures,
+		Reason: fmt.Sprintf("Locked out for %s after %d failed API key attempts on %s %s",
+			s.cfg.Security.AuthLockoutDuration, failures, r.Method, r.URL.Path),
+	}); err != nil {
+		slog.Error("failed to log lockout audit", "remote_addr", r.RemoteAddr, "error", err)
+	}
+}
+
+// auditAccountLockout records an audit entry when an account is locked
+// out after repeated failed logins, from however many clients.
+func (s *Server) auditAccountLockout(r *http.Request, email string, failures int) {
+	ctx := WithRequestMetadata(r.Context(), r)
+	if _, err := s.service.LogAudit(ctx, core.AuditLogParams{
+		Action:       core.ActionAuthLockout,
+		IPAddress:    core.GetIPAddressFromContext(ctx),
+		UserAgent:    core.GetUserAgentFromContext(ctx),
+		RowsAffected: failures,
+		RowData:      map[string]any{"account": email},
+		Reason: fmt.Sprintf("Locked out account %s for %s after %d failed logins",
+			email, s.cfg.Security.AuthLockoutDuration, failures),
+	}); err != nil {
+		slog.Error("failed to log account lockout audit", "account", email, "error", err)
+	}
+}
+
+// auditDenied records an operation refused before it ran. Repeats of the
+// same rate-limit refusal from one client are recorded once per minute so a
+// flood of blocked requests cannot flood the audit log too.
+func (s *Server) auditDenied(r *http.Request, d core.Denial) {
+	if d.Kind == core.DenialRateLimit {
+		host := r.RemoteAddr
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !s.denials.first(host + " " + d.Code) {
+			return
+		}
+	}
+	if d.Method == "" {
+		d.Method, d.Path = r.Method, r.URL.Path
+	}
+	if d.TableKey == "" {
+		d.TableKey = chi.URLParam(r, "tableKey")
+	}
+	s.auditor.LogDenied(WithRequestMetadata(r.Context(), r), d)
+}
+
+// auditMiddlewareDenial adapts auditDenied to mw.DenialFunc for the auth
+// and network policy middleware.
+func (s *Server) auditMiddlewareDenial(r *http.Request, code, reason string) {
+	kind := core.DenialPermission
+	switch code {
+	case "AUTH_NETWORK_DENIED":
+		kind = core.DenialNetwork
+	case "AUTH_LOCKED", "AUTH_THROTTLED":
+		kind = core.DenialRateLimit
+	}
+	s.auditDenied(r, core.Denial{Kind: kind, Code: code, Detail: reason})
+}
+
+// auditRateLimited records a rate-limit block on a mutating request. Reads
+// are not audited.
+func (s *Server) auditRateLimited(r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	s.auditDenied(r, core.Denial{Kind: core.DenialRateLimit, Code: "RATE_LIMITED", Detail: "rate limit exceeded"})
+}
+
+// handleSpoolReport lists encrypted spooled uploads and their keys.
+func (s *Server) handleSpoolReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.service.Spool().Report()
+	if err != nil {
+		slog.Error("failed to read upload spool", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read upload spool")
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleRotateSpool re-encrypts spooled uploads with the active key.
+func (s *Server) handleRotateSpool(w http.ResponseWriter, r *http.Request) {
+	spool := s.service.Spool()
+	if spool == nil {
+		writeError(w, http.StatusNotFound, "upload spooling is disabled")
+		return
+	}
+
+	result, err := spool.Rotate()
+	if err != nil {
+		slog.Error("failed to rotate upload spool", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to rotate upload spool")
+		return
+	}
+	slog.Info("rotated upload spool keys",
+		"rotated", result.Rotated,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+	)
+	writeJSON(w, result)
+}
+
+// handleDeleteSpoolArtifact deletes an orphaned spooled upload.
+func (s *Server) handleDeleteSpoolArtifact(w http.ResponseWriter, r *http.Request) {
+	spool := s.service.Spool()
+	if spool == nil {
+		writeError(w, http.StatusNotFound, "upload spooling is disabled")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	switch err := spool.Delete(id); {
+	case errors.Is(err, os.ErrNotExist):
+		writeError(w, http.StatusNotFound, "spooled upload not found")
+		return
+	case errors.Is(err, core.ErrSpoolInUse):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		slog.Error("failed to delete spooled upload", "id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to delete spooled upload")
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "deleted", "id": id})
+}
+
+// handleListRetentionArchives lists archives of rows purged by retention.
+func (s *Server) handleListRetentionArchives(w http.ResponseWriter, r *http.Request) {
+	store := s.service.RetentionArchives()
+	if store == nil {
+		writeError(w, http.StatusNotFound, "retention archives are disabled")
+		return
+	}
+
+	kind := core.RetentionArchiveKind(r.URL.Query().Get("kind"))
+	archives, err := store.List(kind, r.URL.Query().Get("table"))
+	if err != nil {
+		slog.Error("failed to list retention archives", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to list retention archives")
+		return
+	}
+	writeJSON(w, map[string]any{"archives": archives})
+}
+
+// handleDownloadRetentionArchive streams a retention archive's gzipped
+// NDJSON file. The download is recorded in the audit log.
+func (s *Server) handleDownloadRetentionArchive(w http.ResponseWriter, r *http.Request) {
+	store := s.service.RetentionArchives()
+	if store == nil {
+		writeError(w, http.StatusNotFound, "retention archives are disabled")
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	f, archive, err := store.Open(id)
+	if errors.Is(err, core.ErrRetentionArchiveNotFound) {
+		writeError(w, http.StatusNotFound, "retention archive not found")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to open retention archive", "id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to open retention archive")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, archive.FileName))
+	w.Header().Set("Content-Length", fmt.Sprint(archive.Size))
+	w.Header().Set("X-Checksum-SHA256", archive.SHA256)
+	_, err = io.Copy(w, f)
+
+	s.auditor.LogExport(WithRequestMetadata(r.Context(), r), core.ExportRecord{
+		Kind:     core.ExportRetentionArchive,
+		TableKey: archive.TableKey,
+		Format:   "ndjson.gz",
+		FileName: archive.FileName,
+		Rows:     int(archive.Rows),
+		Err:      err,
+	})
+}
+
+// handleTableStatistics reports a table's extended statistics for the planner.
+func (s *Server) handleTableStatistics(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+
+	report, err := s.service.TableStatistics(r.Context(), tableKey)
+	if err != nil {
+		slog.Error("failed to read table statistics", "table", tableKey, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to read table statistics")
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleRefreshStatistics creates declared extended statistics and re-analyzes a table.
+func (s *Server) handleRefreshStatistics(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+
+	report, err := s.service.RefreshStatistics(r.Context(), tableKey)
+	if err != nil {
+		slog.Error("failed to refresh table statistics", "table", tableKey, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to refresh table statistics")
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleImportAuditLog imports an audit-log export from another deployment
+// into the archive.
+func (s *Server) handleImportAuditLog(w http.ResponseWriter, r *http.Request) {
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	opts := core.AuditImportOptions{
+		Source:   r.FormValue("source"),
+		FileName: header.Filename,
+		Format:   strings.ToLower(r.FormValue("format")),
+	}
+	if tz := r.FormValue("timezone"); tz != "" {
+		if opts.Location, err = time.LoadLocation(tz); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid timezone %q", tz))
+			return
+		}
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	imp, err := s.service.ImportAuditLog(ctx, data, opts)
+	if err != nil {
+		var invalid *core.AuditImportError
+		if errors.As(err, &invalid) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]any{
+				"error":   fmt.Sprintf("%d invalid audit entries; nothing was imported", invalid.Total),
+				"invalid": invalid.Total,
+				"errors":  invalid.Errors,
+			})
+			return
+		}
+		slog.Error("failed to import audit log", "source", opts.Source, "file", opts.FileName, "error", err)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.Info("imported audit log",
+		"import_id", imp.ID,
+		"source", imp.Source,
+		"imported", imp.EntriesImported,
+		"skipped", imp.EntriesSkipped,
+	)
+	writeJSON(w, imp)
+}
+
+// handleListAuditImports lists audit history imported from other deployments.
+func (s *Server) handleListAuditImports(w http.ResponseWriter, r *http.Request) {
+	imports, err := s.service.ListAuditImports(r.Context())
+	if err != nil {
+		slog.Error("failed to list audit imports", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to list audit imports")
+		return
+	}
+	writeJSON(w, imports)
+}
+
+// handleDeprecatedColumns lists declared column renames and the import
+// templates and requests still using the old names.
+func (s *Server) handleDeprecatedColumns(w http.ResponseWriter, r *http.Request) {
+	report, err := s.service.DeprecatedColumnReport(r.Context())
+	if err != nil {
+		slog.Error("failed to build deprecated column report", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to build deprecated column report")
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleSchemaMigration previews the migration that brings the database in
+// line with the table registry, as cmd/schemamigrate would write it.
+func (s *Server) handleSchemaMigration(w http.ResponseWriter, r *http.Request) {
+	var tables []string
+	if v := r.URL.Query().Get("table"); v != "" {
+		tables = strings.Split(v, ",")
+	}
+	for _, key := range tables {
+		if _, ok := core.Get(key); !ok {
+			writeError(w, http.StatusNotFound, "unknown table: "+key)
+			return
+		}
+	}
+
+	m, err := s.service.GenerateSchemaMigration(r.Context(), tables...)
+	if err != nil {
+		slog.Error("failed to generate schema migration", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to generate schema migration")
+		return
+	}
+	if m.Empty() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, m.SQL())
+}
+
+// handleBackfillColumn starts populating a column of existing rows from a
+// core.BackfillSource.
+func (s *Server) handleBackfillColumn(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+
+	var req struct {
+		Column string              `json:"column"`
+		Source core.BackfillSource `json:"source"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "unknown table: "+tableKey)
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	opID, err := s.service.StartBackfill(ctx, tableKey, req.Column, req.Source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeOperationAccepted(w, opID)
+}
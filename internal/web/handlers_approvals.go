@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListApprovals lists pending approvals, or all of them with
+// ?all=true.
+func (s *Server) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	approvals, err := s.service.ListApprovals(r.Context(), r.URL.Query().Get("all") == "true", parseIntParam(r, "limit", 100))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"approvals": approvals})
+}
+
+// handleGetApproval returns one approval.
+func (s *Server) handleGetApproval(w http.ResponseWriter, r *http.Request) {
+	approval, err := s.service.GetApproval(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+	writeJSON(w, approval)
+}
+
+// handleDecideApproval approves or rejects a pending approval. Approving
+// runs the operation.
+func (s *Server) handleDecideApproval(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Decision string `json:"decision"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	id := chi.URLParam(r, "id")
+	var (
+		approval *core.Approval
+		err      error
+	)
+	switch req.Decision {
+	case "approve":
+		approval, err = s.service.ApproveRequest(ctx, id, req.Note)
+	case "reject":
+		approval, err = s.service.RejectRequest(ctx, id, req.Note)
+	default:
+		writeError(w, http.StatusBadRequest, `decision must be "approve" or "reject"`)
+		return
+	}
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+	if approval.OperationID != "" {
+		w.Header().Set("X-Operation-ID", approval.OperationID)
+	}
+	writeJSON(w, approval)
+}
+
+// writeApprovalError maps an approval error to an HTTP status.
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrApprovalNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrApprovalDecided):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, core.ErrApprovalForbidden):
+		writeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeApprovalRequired answers a reset or rollback that was recorded for
+// approval instead of run, and reports whether err was that.
+func writeApprovalRequired(w http.ResponseWriter, err error) bool {
+	var required *core.ApprovalRequiredError
+	if !errors.As(err, &required) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, map[string]any{
+		"status":   "pending_approval",
+		"message":  err.Error(),
+		"approval": required.Approval,
+	})
+	return true
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+)
+
+// handleArchiveStatus returns the archive scheduler's state and its recent
+// runs.
+func (s *Server) handleArchiveStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.service.ArchiveStatus(r.Context(), parseIntParam(r, "limit", 20))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, status)
+}
+
+// handleRunArchive starts an archive run now and returns its operation ID.
+func (s *Server) handleRunArchive(w http.ResponseWriter, r *http.Request) {
+	opID, err := s.service.StartArchiveRun(WithRequestMetadata(r.Context(), r))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrArchiveRunning):
+			writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, core.ErrArchiveNotScheduled):
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeOperationAccepted(w, opID)
+}
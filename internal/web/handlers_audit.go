@@ -43,13 +43,13 @@ func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	entries, err := s.service.GetAuditLog(r.Context(), coreFilter)
+	entries, err := s.auditor.GetAuditLog(r.Context(), coreFilter)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	totalCount, err := s.service.CountAuditLog(r.Context(), coreFilter)
+	totalCount, err := s.auditor.CountAuditLog(r.Context(), coreFilter)
 	if err != nil {
 		totalCount = int64(len(entries))
 	}
@@ -59,7 +59,7 @@ func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
 	hasFilters := filter.Action != "" || filter.TableKey != "" ||
 		filter.Severity != "" || filter.StartDate != "" || filter.EndDate != ""
 	if hasFilters {
-		unfilteredCount, _ = s.service.CountAuditLog(r.Context(), core.AuditLogFilter{})
+		unfilteredCount, _ = s.auditor.CountAuditLog(r.Context(), core.AuditLogFilter{})
 	}
 
 	tables := make([]string, 0)
@@ -94,7 +94,7 @@ func (s *Server) handleAuditLogEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entry, err := s.service.GetAuditLogByID(r.Context(), id)
+	entry, err := s.auditor.GetAuditLogByID(r.Context(), id)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "audit entry not found")
 		return
@@ -148,7 +148,7 @@ func (s *Server) handleAuditLogExport(w http.ResponseWriter, r *http.Request) {
 	rowCount := 0
 
 	// Stream entries directly from database to response
-	err := s.service.StreamAuditLog(r.Context(), filter, func(e core.AuditEntry) error {
+	err := s.auditor.StreamAuditLog(r.Context(), filter, func(e core.AuditEntry) error {
 		if err := csvWriter.Write([]string{
 			e.ID,
 			e.CreatedAt.Format("2006-01-02 15:04:05"),
@@ -186,9 +186,33 @@ func (s *Server) handleAuditLogExport(w http.ResponseWriter, r *http.Request) {
 
 	// Final flush
 	csvWriter.Flush()
+	if err == nil {
+		err = csvWriter.Error()
+	}
+	s.auditor.LogExport(WithRequestMetadata(r.Context(), r), core.ExportRecord{
+		Kind:     core.ExportAuditLog,
+		TableKey: filter.TableKey,
+		Format:   "csv",
+		FileName: filename,
+		Filters:  auditExportFilters(r),
+		Rows:     rowCount,
+		Err:      err,
+	})
 
 	// Log streaming errors (can't send to client after headers are written)
 	if err != nil && err != r.Context().Err() {
 		_ = err
 	}
 }
+
+// auditExportFilters returns the audit log export's query filters as
+// recorded in its own audit entry.
+func auditExportFilters(r *http.Request) map[string]any {
+	f := make(map[string]any)
+	for _, name := range []string{"table", "action", "severity", "from", "to"} {
+		if v := r.URL.Query().Get(name); v != "" {
+			f[name] = v
+		}
+	}
+	return f
+}
@@ -0,0 +1,367 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	mw "github.com/JonMunkholm/TUI/internal/web/middleware"
+	"github.com/JonMunkholm/TUI/internal/web/templates"
+	"github.com/go-chi/chi/v5"
+)
+
+// Session cookies and the header echoing the CSRF token.
+const (
+	sessionCookie = "session"    // Session token; HttpOnly
+	csrfCookie    = "csrf_token" // CSRF token, readable by the page's scripts
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// sessionAuth puts the user signed in with the request's session cookie in
+// its context, and holds them to their role and to the session's CSRF
+// token. With REQUIRE_LOGIN, requests with neither a session nor a valid
+// API key or token are turned away: pages redirect to /login and API calls
+// get 401.
+func (s *Server) sessionAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+			session, err := s.service.GetSession(r.Context(), c.Value)
+			if err == nil {
+				if s.checkSession(w, r, session) {
+					r = r.WithContext(core.ContextWithUser(r.Context(), &session.User))
+					if session.User.AggregatesOnly && !s.aggregatesOnlyAllowed(r) {
+						s.auditMiddlewareDenial(r, "AUTH_AGGREGATES_ONLY", "user may only query aggregates")
+						writeAuthError(w, http.StatusForbidden, "your account may only query aggregates", "AUTH_AGGREGATES_ONLY")
+						return
+					}
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+			if !errors.Is(err, core.ErrSessionExpired) {
+				slog.Error("failed to look up session", "path", r.URL.Path, "error", err)
+			}
+			clearSessionCookie(w, r)
+		}
+
+		if !s.cfg.Security.RequireLogin || loginExempt(r.URL.Path) ||
+			core.GetAPITokenFromContext(r.Context()) != nil || mw.HasAPIKey(r, s.apiKeys()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			http.Error(w, `{"error":"sign in required","code":"AUTH_LOGIN_REQUIRED"}`, http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	})
+}
+
+// checkSession refuses a signed-in request that its user's role does not
+// allow, or that changes something without the session's CSRF token. It
+// reports whether the request may go on, and answers it with 403 if not.
+func (s *Server) checkSession(w http.ResponseWriter, r *http.Request, session *core.Session) bool {
+	code, detail := "", ""
+	switch {
+	case !roleAllows(session.User.Role, r.Method, r.URL.Path):
+		code, detail = "AUTH_ROLE", "role "+session.User.Role+" may not "+r.Method+" "+r.URL.Path
+	case !safeMethod(r.Method) && !loginExempt(r.URL.Path) && !session.CheckCSRF(r.Header.Get(csrfHeader)):
+		code, detail = "AUTH_CSRF", "missing or wrong "+csrfHeader+" header"
+	default:
+		return true
+	}
+
+	ctx := core.ContextWithUser(r.Context(), &session.User)
+	s.auditDenied(r.WithContext(ctx), core.Denial{Kind: core.DenialPermission, Code: code, Detail: detail})
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": detail, "code": code})
+		return false
+	}
+	http.Error(w, detail, http.StatusForbidden)
+	return false
+}
+
+// roleAllows reports whether a user with role may make a method request
+// to path. Viewers may only read and sign in or out; editors may do
+// anything but use the admin API.
+func roleAllows(role, method, path string) bool {
+	admin := strings.HasPrefix(path, "/api/admin/")
+	switch role {
+	case core.RoleAdmin:
+		return true
+	case core.RoleEditor:
+		return !admin
+	default:
+		return (safeMethod(method) && !admin) || loginExempt(path) || path == "/api/auth/logout"
+	}
+}
+
+// safeMethod reports whether method only reads.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// loginExempt reports whether path is reachable without signing in.
+func loginExempt(path string) bool {
+	return path == "/login" || path == "/api/auth/login" || strings.HasPrefix(path, "/static/")
+}
+
+// aggregateRoutes are the routes aggregates-only users and API tokens with
+// the aggregate scope may use: grouped aggregations, histograms, the table
+// list to find them with, and their own session.
+var aggregateRoutes = map[string]bool{
+	"/api/summary/{tableKey}":   true,
+	"/api/histogram/{tableKey}": true,
+	"/api/tables":               true,
+	"/api/auth/me":              true,
+	"/api/auth/logout":          true,
+}
+
+// aggregatesOnlyAllowed reports whether an aggregates-only user may make r.
+func (s *Server) aggregatesOnlyAllowed(r *http.Request) bool {
+	if loginExempt(r.URL.Path) {
+		return true
+	}
+	pattern, _ := s.findRoute(r)
+	return aggregateRoutes[pattern]
+}
+
+// apiKeys returns the current API keys.
+func (s *Server) apiKeys() []string {
+	if s.cfg.Security.APIKeyProvider != nil {
+		return s.cfg.Security.APIKeyProvider()
+	}
+	return s.cfg.Security.APIKeys
+}
+
+// handleLoginPage renders the sign-in form, or skips it for a user who is
+// signed in already.
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.URL.Query().Get("next"))
+	if core.GetUserFromContext(r.Context()) != nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	templates.LoginPage(next, "").Render(r.Context(), w)
+}
+
+// handleLoginForm signs in from the sign-in form and redirects to the page
+// the user was after.
+func (s *Server) handleLoginForm(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+	next := safeNext(r.PostForm.Get("next"))
+
+	session, ok := s.login(w, r, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if !ok {
+		return
+	}
+	if session == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		templates.LoginPage(next, core.ErrInvalidCredentials.Error()).Render(r.Context(), w)
+		return
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// handleLogin signs in with a JSON email and password, setting the
+// session cookie.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	session, ok := s.login(w, r, req.Email, req.Password)
+	if !ok {
+		return
+	}
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, core.ErrInvalidCredentials.Error())
+		return
+	}
+	writeJSON(w, session)
+}
+
+// login checks credentials under the auth lockout, which counts failures
+// both per client and per account, and sets the session and CSRF cookies.
+// Every failed attempt is audited. It returns a nil session for wrong
+// credentials, and ok false if it has answered the request itself.
+func (s *Server) login(w http.ResponseWriter, r *http.Request, email, password string) (session *core.Session, ok bool) {
+	account := core.NormalizeEmail(email)
+	if s.lockout.Throttle(w, r) || s.accounts.ThrottleKey(w, account) {
+		return nil, false
+	}
+	ctx := WithRequestMetadata(r.Context(), r)
+	session, err := s.service.Login(ctx, email, password)
+	if errors.Is(err, core.ErrInvalidCredentials) {
+		s.lockout.Fail(r)
+		s.accounts.FailKey(r, account)
+		slog.Warn("auth: failed login", "remote_addr", r.RemoteAddr, "account", account)
+		s.auditLoginFailure(ctx, account)
+		return nil, true
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	s.lockout.Succeed(r)
+	s.accounts.SucceedKey(account)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	// Scripts read this cookie to send the token back in csrfHeader; a
+	// page on another site can neither read it nor set the header
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return session, true
+}
+
+// auditLoginFailure records a failed sign-in for account.
+func (s *Server) auditLoginFailure(ctx context.Context, account string) {
+	if _, err := s.service.LogAudit(ctx, core.AuditLogParams{
+		Action:    core.ActionLoginFailure,
+		IPAddress: core.GetIPAddressFromContext(ctx),
+		UserAgent: core.GetUserAgentFromContext(ctx),
+		RowData:   map[string]any{"account": account},
+		Reason:    "Failed sign-in for " + account,
+	}); err != nil {
+		slog.Error("failed to log login failure audit", "account", account, "error", err)
+	}
+}
+
+// handleLogout ends the request's session.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		if err := s.service.Logout(r.Context(), c.Value); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	clearSessionCookie(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCurrentUser returns the signed-in user.
+func (s *Server) handleCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user := core.GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not signed in")
+		return
+	}
+	writeJSON(w, user)
+}
+
+// handleListUsers lists user accounts.
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.service.ListUsers(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"users": users})
+}
+
+// handleCreateUser creates a user account.
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var p core.UserParams
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := s.service.CreateUser(WithRequestMetadata(r.Context(), r), p)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, user)
+}
+
+// handleDisableUser disables a user account and ends its sessions.
+func (s *Server) handleDisableUser(w http.ResponseWriter, r *http.Request) {
+	user, err := s.service.DisableUser(WithRequestMetadata(r.Context(), r), chi.URLParam(r, "id"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	writeJSON(w, user)
+}
+
+// writeUserError maps a user account error to an HTTP status.
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrUserExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, core.ErrInvalidUser):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// clearSessionCookie removes the session and CSRF cookies from the
+// browser.
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// isHTTPS reports whether the browser reached us over HTTPS, directly or
+// through a proxy.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// safeNext returns next if it is a path on this site, or "/".
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
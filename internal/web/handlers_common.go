@@ -3,17 +3,29 @@
 package web
 
 import (
-	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/JonMunkholm/TUI/internal/core"
 	"github.com/JonMunkholm/TUI/internal/web/templates"
-	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/go-chi/chi/v5"
 )
 
+// requireWritable rejects requests that would write to a view table, before
+// the handler reads the request body. Mount it on routes with {tableKey}.
+func (s *Server) requireWritable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if def, ok := core.Get(chi.URLParam(r, "tableKey")); ok && def.IsView() {
+			s.auditDenied(r, core.Denial{Kind: core.DenialReadOnly, Detail: def.Info.Key + " is a view"})
+			writeError(w, http.StatusMethodNotAllowed, core.ErrReadOnlyTable.Error()+": "+def.Info.Key+" is a view")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // parseIntParam parses an integer query parameter with a default value.
 func parseIntParam(r *http.Request, name string, defaultVal int) int {
 	val := r.URL.Query().Get(name)
@@ -27,10 +39,12 @@ func parseIntParam(r *http.Request, name string, defaultVal int) int {
 	return i
 }
 
-// parseSorts parses comma-separated sort parameters from URL.
-func parseSorts(r *http.Request) []core.SortSpec {
+// parseSorts parses comma-separated sort parameters from URL, keeping at
+// most maxLevels.
+func parseSorts(r *http.Request, maxLevels int) []core.SortSpec {
 	sortStr := r.URL.Query().Get("sort")
 	dirStr := r.URL.Query().Get("dir")
+	nullsStr := r.URL.Query().Get("nulls")
 
 	if sortStr == "" {
 		return nil
@@ -38,6 +52,7 @@ func parseSorts(r *http.Request) []core.SortSpec {
 
 	cols := strings.Split(sortStr, ",")
 	dirs := strings.Split(dirStr, ",")
+	nulls := strings.Split(nullsStr, ",")
 
 	var sorts []core.SortSpec
 	for i, col := range cols {
@@ -52,52 +67,62 @@ func parseSorts(r *http.Request) []core.SortSpec {
 				dir = "desc"
 			}
 		}
-		sorts = append(sorts, core.SortSpec{Column: col, Dir: dir})
-		if len(sorts) >= 2 {
+		var n string
+		if i < len(nulls) {
+			n = strings.TrimSpace(nulls[i])
+		}
+		sorts = append(sorts, core.SortSpec{Column: col, Dir: dir, Nulls: n})
+		if len(sorts) >= maxLevels {
 			break
 		}
 	}
 	return sorts
 }
 
-// parseFilters extracts column filters from URL query parameters.
+// parseFilters extracts column filters from URL query parameters:
+// filter[col]=op:value, or filter[col][group]=op:value to put the condition
+// in an OR group, with "!op:value" negating it (see core/filter_groups.go).
 func parseFilters(r *http.Request, def core.TableDefinition) core.FilterSet {
 	var filters []core.ColumnFilter
 
-	specMap := make(map[string]core.FieldSpec)
-	for _, spec := range def.FieldSpecs {
-		specMap[strings.ToLower(spec.Name)] = spec
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
 	}
+	// Sorted so groups and arguments come out in a stable order
+	sort.Strings(keys)
 
-	for key, values := range r.URL.Query() {
+	for _, key := range keys {
 		if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
 			continue
 		}
 
-		colName := key[7 : len(key)-1]
-		if colName == "" {
+		colName, group, grouped := strings.Cut(key[7:len(key)-1], "][")
+		if colName == "" || (grouped && group == "") || !core.ValidFilterGroup(group) {
 			continue
 		}
 
-		spec, ok := specMap[strings.ToLower(colName)]
+		// Saved views may still use a column's old name
+		colName = core.ResolveColumnName(def, colName, "filter")
+		spec, expr, ok := core.FilterField(def, colName)
 		if !ok {
 			continue
 		}
 
-		for _, val := range values {
-			parts := strings.SplitN(val, ":", 2)
-			if len(parts) != 2 {
+		for _, val := range query[key] {
+			op, filterVal, negate, ok := core.ParseFilterValue(val)
+			if !ok {
 				continue
 			}
 
-			op := core.FilterOperator(parts[0])
-			filterVal := parts[1]
-
-			if filterVal == "" {
+			if !op.TakesValue() {
+				filterVal = ""
+			} else if filterVal == "" {
 				continue
 			}
 
-			if !isValidOperator(op, spec.Type) {
+			if !core.ValidOperator(op, spec.Type) {
 				continue
 			}
 
@@ -109,9 +134,12 @@ func parseFilters(r *http.Request, def core.TableDefinition) core.FilterSet {
 			filters = append(filters, core.ColumnFilter{
 				Column:   spec.Name,
 				DBColumn: dbCol,
+				Expr:     expr,
 				Operator: op,
 				Value:    filterVal,
 				Type:     spec.Type,
+				Group:    group,
+				Negate:   negate,
 			})
 		}
 	}
@@ -119,96 +147,6 @@ func parseFilters(r *http.Request, def core.TableDefinition) core.FilterSet {
 	return core.FilterSet{Filters: filters}
 }
 
-// isValidOperator checks if an operator is valid for a given field type.
-func isValidOperator(op core.FilterOperator, ft core.FieldType) bool {
-	switch ft {
-	case core.FieldText:
-		switch op {
-		case core.OpContains, core.OpEquals, core.OpStartsWith, core.OpEndsWith:
-			return true
-		}
-	case core.FieldNumeric:
-		switch op {
-		case core.OpEquals, core.OpGreaterEq, core.OpLessEq, core.OpGreater, core.OpLess:
-			return true
-		}
-	case core.FieldDate:
-		switch op {
-		case core.OpEquals, core.OpGreaterEq, core.OpLessEq:
-			return true
-		}
-	case core.FieldBool:
-		return op == core.OpEquals
-	case core.FieldEnum:
-		switch op {
-		case core.OpEquals, core.OpIn:
-			return true
-		}
-	}
-	return false
-}
-
-// formatCellForExport formats a cell value for CSV export.
-func formatCellForExport(v interface{}) string {
-	if v == nil {
-		return ""
-	}
-
-	switch val := v.(type) {
-	case pgtype.Numeric:
-		if !val.Valid {
-			return ""
-		}
-		f, err := val.Float64Value()
-		if err != nil || !f.Valid {
-			return ""
-		}
-		if f.Float64 == float64(int64(f.Float64)) {
-			return fmt.Sprintf("%.0f", f.Float64)
-		}
-		return fmt.Sprintf("%.2f", f.Float64)
-
-	case pgtype.Date:
-		if !val.Valid {
-			return ""
-		}
-		return val.Time.Format("2006-01-02")
-
-	case pgtype.Text:
-		if !val.Valid {
-			return ""
-		}
-		return val.String
-
-	case pgtype.Bool:
-		if !val.Valid {
-			return ""
-		}
-		if val.Bool {
-			return "Yes"
-		}
-		return "No"
-
-	case time.Time:
-		if val.IsZero() {
-			return ""
-		}
-		return val.Format("2006-01-02")
-
-	case bool:
-		if val {
-			return "Yes"
-		}
-		return "No"
-
-	case string:
-		return val
-
-	default:
-		return fmt.Sprintf("%v", v)
-	}
-}
-
 // buildColumnMeta builds column metadata from a table definition.
 func buildColumnMeta(def core.TableDefinition) []templates.ColumnMeta {
 	uniqueKeySet := make(map[string]bool)
@@ -220,12 +158,18 @@ func buildColumnMeta(def core.TableDefinition) []templates.ColumnMeta {
 	for _, spec := range def.FieldSpecs {
 		specMap[spec.Name] = spec
 	}
+	derived := make(map[string]bool, len(def.Derived))
+	for _, d := range def.Derived {
+		specMap[d.Name] = d.Spec()
+		derived[d.Name] = true
+	}
 
 	meta := make([]templates.ColumnMeta, len(def.Info.Columns))
 	for i, col := range def.Info.Columns {
 		cm := templates.ColumnMeta{
 			Name:        col,
 			IsUniqueKey: uniqueKeySet[col],
+			Derived:     derived[col],
 		}
 
 		if spec, ok := specMap[col]; ok {
@@ -236,6 +180,7 @@ func buildColumnMeta(def core.TableDefinition) []templates.ColumnMeta {
 			cm.Type = fieldTypeToString(spec.Type)
 			cm.EnumValues = spec.EnumValues
 			cm.AllowEmpty = spec.AllowEmpty
+			cm.Format = spec.Display
 		} else {
 			cm.DBColumn = col
 			cm.Type = "text"
@@ -268,35 +213,76 @@ func fieldTypeToString(ft core.FieldType) string {
 
 // UploadResultResponse wraps the upload result for JSON encoding.
 type UploadResultResponse struct {
-	UploadID   string           `json:"upload_id"`
-	TableKey   string           `json:"table_key"`
-	FileName   string           `json:"file_name"`
-	TotalRows  int              `json:"total_rows"`
-	Inserted   int              `json:"inserted"`
-	Skipped    int              `json:"skipped"`
-	FailedRows []core.FailedRow `json:"failed_rows,omitempty"`
-	Duration   string           `json:"duration"`
-	Error      string           `json:"error,omitempty"`
+	UploadID      string               `json:"upload_id"`
+	TableKey      string               `json:"table_key"`
+	FileName      string               `json:"file_name"`
+	TotalRows     int                  `json:"total_rows"`
+	Mode          core.UploadMode      `json:"mode"`
+	Inserted      int                  `json:"inserted"`
+	Updated       int                  `json:"updated"`
+	Replaced      int                  `json:"replaced"`
+	Deleted       int                  `json:"deleted"`
+	Skipped       int                  `json:"skipped"`
+	Duplicates    core.DuplicatePolicy `json:"duplicates"`
+	DupSkipped    int                  `json:"duplicates_skipped"`
+	DupInFile     int                  `json:"duplicates_in_file"`
+	FailedRows    []core.FailedRow     `json:"failed_rows,omitempty"`
+	Retries       int                  `json:"retries"`
+	Duration      string               `json:"duration"`
+	DateWarnings  []core.DateWarning   `json:"date_warnings,omitempty"`
+	TemplateDrift *core.TemplateDrift  `json:"template_drift,omitempty"`
+	Error         string               `json:"error,omitempty"`
 }
 
 // toResponse converts an UploadResult to a JSON-friendly format.
 func toResponse(result *core.UploadResult) UploadResultResponse {
 	return UploadResultResponse{
-		UploadID:   result.UploadID,
-		TableKey:   result.TableKey,
-		FileName:   result.FileName,
-		TotalRows:  result.TotalRows,
-		Inserted:   result.Inserted,
-		Skipped:    result.Skipped,
-		FailedRows: result.FailedRows,
-		Duration:   result.Duration.String(),
-		Error:      result.Error,
+		UploadID:      result.UploadID,
+		TableKey:      result.TableKey,
+		FileName:      result.FileName,
+		TotalRows:     result.TotalRows,
+		Mode:          result.Mode,
+		Inserted:      result.Inserted,
+		Updated:       result.Updated,
+		Replaced:      result.Replaced,
+		Deleted:       result.Deleted,
+		Skipped:       result.Skipped,
+		Duplicates:    result.Duplicates,
+		DupSkipped:    result.DupSkipped,
+		DupInFile:     result.DupInFile,
+		FailedRows:    result.FailedRows,
+		Retries:       result.Retries,
+		Duration:      result.Duration.String(),
+		DateWarnings:  result.DateWarnings,
+		TemplateDrift: result.TemplateDrift,
+		Error:         result.Error,
 	}
 }
 
-// handleUploadQueueStatus returns the current state of the upload limiter.
-// Used for monitoring and to check if the system can accept more uploads.
+// handleUploadQueueStatus returns the current state of the upload limiter
+// and the caller's per-uploader quota. Used for monitoring and to check if
+// the system can accept more uploads.
 func (s *Server) handleUploadQueueStatus(w http.ResponseWriter, r *http.Request) {
-	status := s.service.UploadLimiterStatus()
+	status := s.uploader.UploadQueueStatus(WithRequestMetadata(r.Context(), r))
 	writeJSON(w, status)
 }
+
+// handleWebhookSchema returns the JSON Schema of each webhook event's
+// payload, for ?version= (default: the current version).
+func (s *Server) handleWebhookSchema(w http.ResponseWriter, r *http.Request) {
+	version := core.WebhookSchemaVersion
+	if v := r.URL.Query().Get("version"); v != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid version")
+			return
+		}
+		version = n
+	}
+	doc, ok := core.WebhookSchema(version)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown webhook schema version")
+		return
+	}
+	writeJSON(w, doc)
+}
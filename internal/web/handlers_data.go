@@ -3,8 +3,12 @@ package web
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/JonMunkholm/TUI/internal/core"
@@ -18,7 +22,7 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Fetch all table stats in a single batch (2 queries total)
-	allStats, err := s.service.GetAllTableStats(ctx)
+	allStats, err := s.tables.GetAllTableStats(ctx)
 	if err != nil {
 		// Log error but continue with empty stats
 		allStats = make(map[string]*core.TableStats)
@@ -53,6 +57,96 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	templates.Dashboard(sidebar, groups).Render(ctx, w)
 }
 
+// tableEventKeepAlive is how often an idle table event stream sends a
+// comment, so proxies do not close it.
+const tableEventKeepAlive = 30 * time.Second
+
+// handleTableEvents streams table card updates via Server-Sent Events
+// after uploads, rollbacks and resets, for the tables given as repeated
+// table parameters (all tables if none).
+func (s *Server) handleTableEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := s.service.SubscribeTableEvents(r.Context(), r.URL.Query()["table"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(tableEventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: table\ndata: %s\n\n", data)
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleActivityEvents streams activity across all tables via Server-Sent
+// Events: uploads started, committed and failed, rollbacks, resets and bulk
+// edits, one event each as they happen.
+func (s *Server) handleActivityEvents(w http.ResponseWriter, r *http.Request) {
+	events := s.service.SubscribeEvents(r.Context())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(tableEventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: activity\ndata: %s\n\n", data)
+			flusher.Flush()
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 // handleSettings renders the settings page.
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	sidebar := templates.SidebarParams{ActivePage: "settings"}
@@ -61,7 +155,7 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 
 // handleListTables returns all tables organized by group.
 func (s *Server) handleListTables(w http.ResponseWriter, r *http.Request) {
-	tables := s.service.ListTablesByGroup()
+	tables := s.tables.ListTablesByGroup()
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(tables); err != nil {
@@ -69,6 +163,22 @@ func (s *Server) handleListTables(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleColumnRenames returns a table's column renames (old name -> current
+// name), so the browser can migrate saved column and sort preferences.
+func (s *Server) handleColumnRenames(w http.ResponseWriter, r *http.Request) {
+	def, ok := core.Get(chi.URLParam(r, "tableKey"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	renames := make(map[string]string, len(def.Renames))
+	for _, rn := range def.Renames {
+		renames[rn.From] = rn.To
+	}
+	writeJSON(w, renames)
+}
+
 // handleTableView renders the table data view page.
 func (s *Server) handleTableView(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
@@ -84,11 +194,34 @@ func (s *Server) handleTableView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page := parseIntParam(r, "page", 1)
-	sorts := parseSorts(r)
+	sorts := parseSorts(r, s.tables.MaxSortLevels())
 	search := r.URL.Query().Get("search")
 	filters := parseFilters(r, def)
+	columns := core.DisplayColumns(def)
+
+	// A saved view fills in whatever the request leaves unset, so paging and
+	// re-sorting within the view keep working
+	if viewID := r.URL.Query().Get("view"); viewID != "" {
+		view, err := s.service.GetSavedView(r.Context(), viewID)
+		if err != nil || view.TableKey != tableKey {
+			writeError(w, http.StatusNotFound, "saved view not found")
+			return
+		}
+		viewSorts, viewFilters := view.Query(def)
+		if len(sorts) == 0 {
+			sorts = viewSorts
+		}
+		if search == "" {
+			search = view.Search
+		}
+		if len(filters.Filters) == 0 {
+			filters = viewFilters
+		}
+		columns = view.VisibleColumns(def)
+	}
+	def.Info.Columns = columns
 
-	data, err := s.service.GetTableData(r.Context(), tableKey, page, core.DefaultPageSize, sorts, search, filters)
+	data, err := s.tables.GetTableData(r.Context(), tableKey, page, s.tablePageSize(w, r, tableKey), sorts, search, filters)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -104,6 +237,110 @@ func (s *Server) handleTableView(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleTableData returns a page of table data as JSON, by page number or,
+// with ?cursor=, after the row a previous page's nextCursor points at. Cells
+// are formatted as in a CSV export, by the columns' display hints.
+func (s *Server) handleTableData(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	q := r.URL.Query()
+	sorts := parseSorts(r, s.tables.MaxSortLevels())
+	filters := parseFilters(r, def)
+	pageSize := s.tables.ClampPageSize(parseIntParam(r, "pageSize", core.DefaultPageSize))
+
+	var (
+		data *core.TableDataResult
+		err  error
+	)
+	if cursor := q.Get("cursor"); cursor != "" {
+		data, err = s.tables.GetTableDataAfter(r.Context(), tableKey, cursor, pageSize, sorts, q.Get("search"), filters)
+	} else {
+		data, err = s.tables.GetTableData(r.Context(), tableKey, parseIntParam(r, "page", 1), pageSize, sorts, q.Get("search"), filters)
+	}
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	columns := core.DisplayColumns(def)
+	formats := core.ColumnFormats(def, columns)
+	rows := make([]map[string]string, len(data.Rows))
+	for i, row := range data.Rows {
+		rows[i] = make(map[string]string, len(columns))
+		for j, col := range columns {
+			rows[i][col] = formats[j].Format(row[col])
+		}
+	}
+
+	sortOut := make([]map[string]string, len(data.Sorts))
+	for i, sort := range data.Sorts {
+		sortOut[i] = map[string]string{"column": sort.Column, "dir": sort.Dir}
+		if sort.Nulls != "" {
+			sortOut[i]["nulls"] = sort.Nulls
+		}
+	}
+
+	resp := map[string]interface{}{
+		"tableKey":   tableKey,
+		"columns":    columns,
+		"rows":       rows,
+		"totalRows":  data.TotalRows,
+		"pageSize":   data.PageSize,
+		"totalPages": data.TotalPages,
+		"sorts":      sortOut,
+		"nextCursor": data.NextCursor,
+	}
+	if data.Cursor != "" {
+		resp["cursor"] = data.Cursor
+	} else {
+		resp["page"] = data.Page
+	}
+	writeJSON(w, resp)
+}
+
+// pageSizeCookieMaxAge is how long a table's preferred page size is remembered.
+const pageSizeCookieMaxAge = 365 * 24 * 60 * 60
+
+// tablePageSize returns the page size for a table view: the pageSize query
+// parameter if set, else the size last chosen for the table, else the
+// default, clamped to the configured limits. A chosen size is remembered
+// per table in a cookie, so paging links keep it.
+func (s *Server) tablePageSize(w http.ResponseWriter, r *http.Request, tableKey string) int {
+	name := "page_size_" + tableKey
+	if n := parseIntParam(r, "pageSize", 0); n > 0 {
+		n = s.tables.ClampPageSize(n)
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    strconv.Itoa(n),
+			Path:     "/",
+			MaxAge:   pageSizeCookieMaxAge,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return n
+	}
+	if c, err := r.Cookie(name); err == nil {
+		if n, err := strconv.Atoi(c.Value); err == nil {
+			return s.tables.ClampPageSize(n)
+		}
+	}
+	return s.tables.ClampPageSize(core.DefaultPageSize)
+}
+
 // handleDownloadTemplate returns a CSV template with headers for a table.
 func (s *Server) handleDownloadTemplate(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
@@ -126,7 +363,23 @@ func (s *Server) handleDownloadTemplate(w http.ResponseWriter, r *http.Request)
 	csvWriter.Flush()
 }
 
-// handleExportData exports table data as a streaming CSV file.
+// exportOptionParams are the query parameters passed to exporters as
+// options.
+var exportOptionParams = []string{"batch", "on_conflict", core.WatermarkOption}
+
+// exportOptions returns the format-specific export options in the query.
+func exportOptions(r *http.Request) core.ExportOptions {
+	opts := core.ExportOptions{}
+	for _, name := range exportOptionParams {
+		if v := r.URL.Query().Get(name); v != "" {
+			opts[name] = v
+		}
+	}
+	return opts
+}
+
+// handleExportData exports table data as a streaming file in the format
+// given by ?format= (csv, json, xlsx or sql; default csv).
 // Uses chunked transfer encoding to avoid loading all rows into memory.
 func (s *Server) handleExportData(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
@@ -144,19 +397,65 @@ func (s *Server) handleExportData(w http.ResponseWriter, r *http.Request) {
 	search := r.URL.Query().Get("search")
 	filters := parseFilters(r, def)
 
+	opts := exportOptions(r)
+	exporter, err := core.NewExporter(core.ExportFormat(r.URL.Query().Get("format")), w, def)
+	if err == nil {
+		err = core.ConfigureExporter(exporter, opts)
+	}
+	var watermark string
+	if err == nil {
+		watermark, err = core.ExportWatermark(def, opts)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	columns, markRow := core.WatermarkExport(exporter, core.ExportColumns(exporter, def), watermark)
+
+	// Anonymized sample mode: random rows with the table's masking policy applied
+	var anon *core.Anonymizer
+	sampleSize := 0
+	if r.URL.Query().Get("anonymize") == "true" {
+		sampleSize = core.DefaultSampleRows
+		if v := r.URL.Query().Get("sample"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > core.MaxSampleRows {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("sample must be between 1 and %d", core.MaxSampleRows))
+				return
+			}
+			sampleSize = n
+		}
+		var err error
+		if anon, err = core.NewAnonymizer(def, columns); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	// Set headers for streaming download (chunked transfer is automatic in HTTP/1.1)
 	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("%s_%s.csv", tableKey, timestamp)
-	w.Header().Set("Content-Type", "text/csv")
+	filename := fmt.Sprintf("%s_%s.%s", tableKey, timestamp, exporter.Extension())
+	if anon != nil {
+		filename = fmt.Sprintf("%s_anonymized_%s.%s", tableKey, timestamp, exporter.Extension())
+	}
+	w.Header().Set("Content-Type", exporter.ContentType())
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if watermark != "" {
+		w.Header().Set("X-Export-Watermark", watermark)
+	}
 
-	// Create CSV writer that writes directly to response
-	csvWriter := csv.NewWriter(w)
+	// Record the export as an operation so it shows up in /api/operations
+	ctx := WithRequestMetadata(r.Context(), r)
+	op := s.service.StartOperation(ctx, core.OperationExport, tableKey,
+		[]core.OperationStep{{Name: "export", Weight: 1}})
+	w.Header().Set("X-Operation-ID", op.ID())
+	op.Begin("export")
 
 	// Write header row first
-	if err := csvWriter.Write(def.Info.Columns); err != nil {
+	if err := exporter.WriteHeader(columns); err != nil {
 		// Can't change status code after writing, just log and return
+		op.Finish(err)
 		return
 	}
 
@@ -165,20 +464,21 @@ func (s *Server) handleExportData(w http.ResponseWriter, r *http.Request) {
 	rowCount := 0
 
 	// Stream rows directly from database to response
-	err := s.service.StreamTableData(r.Context(), tableKey, search, filters, func(row core.TableRow) error {
-		record := make([]string, len(def.Info.Columns))
-		for i, col := range def.Info.Columns {
-			record[i] = formatCellForExport(row[col])
+	format := core.ExportRowFormatter(exporter, def, columns)
+	writeRow := func(row core.TableRow) error {
+		markRow(row)
+		record := format(row)
+		if anon != nil {
+			record = anon.Anonymize(record)
 		}
 
-		if err := csvWriter.Write(record); err != nil {
+		if err := exporter.WriteRow(record); err != nil {
 			return err
 		}
 
 		rowCount++
 		if rowCount%flushInterval == 0 {
-			csvWriter.Flush()
-			if err := csvWriter.Error(); err != nil {
+			if err := exporter.Flush(); err != nil {
 				return err
 			}
 			// Flush HTTP response for chunked transfer
@@ -188,14 +488,224 @@ func (s *Server) handleExportData(w http.ResponseWriter, r *http.Request) {
 		}
 
 		return nil
-	})
+	}
+	if anon != nil {
+		err = s.tables.StreamTableSample(r.Context(), tableKey, search, filters, sampleSize, writeRow)
+	} else {
+		err = s.tables.StreamTableData(r.Context(), tableKey, search, filters, writeRow)
+	}
 
-	// Final flush
-	csvWriter.Flush()
+	// Finish the file (JSON closing bracket, XLSX zip directory)
+	if closeErr := exporter.Close(); err == nil {
+		err = closeErr
+	}
+	op.SetDetail("export", fmt.Sprintf("%d rows", rowCount))
+	op.Finish(err)
+	s.auditor.LogExport(ctx, core.ExportRecord{
+		Kind:     core.ExportTableData,
+		TableKey: tableKey,
+		Format:   exporter.Extension(),
+		FileName: filename,
+		Filters:  core.FilterSetAudit(search, filters),
+		Rows:     rowCount,
+		Err:      err,
+
+		Anonymized: anon != nil,
+		Watermark:  watermark,
+	})
 
 	// Log streaming errors (can't send to client after headers are written)
 	if err != nil && err != r.Context().Err() {
-		// Log error but don't expose to client (headers already sent)
-		_ = err
+		slog.Error("table export failed", "table", tableKey, "rows", rowCount, "error", err)
+	}
+}
+
+// handleStartExportJob starts a background export of a table, filtered
+// like handleExportData, and returns its operation ID. The file is
+// downloaded from handleDownloadExportJob once the operation completes.
+func (s *Server) handleStartExportJob(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	opID, err := s.service.StartExport(WithRequestMetadata(r.Context(), r), tableKey,
+		core.ExportFormat(r.URL.Query().Get("format")), exportOptions(r), r.URL.Query().Get("search"), parseFilters(r, def))
+	if errors.Is(err, core.ErrUnknownExportFormat) || errors.Is(err, core.ErrInvalidExportOption) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeOperationAccepted(w, opID)
+}
+
+// handleDownloadExportJob sends the file written by a background export
+// and records the export in the audit log.
+func (s *Server) handleDownloadExportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "operationID")
+	f, job, err := s.service.OpenExportJob(id)
+	switch {
+	case errors.Is(err, core.ErrExportJobRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, core.ErrExportJobNotFound):
+		writeError(w, http.StatusNotFound, "export job not found or expired")
+		return
+	case err != nil:
+		slog.Error("failed to open export job", "id", id, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to open export job")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", job.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, job.FileName))
+	w.Header().Set("Content-Length", fmt.Sprint(job.Size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, err = io.Copy(w, f)
+
+	s.auditor.LogExport(WithRequestMetadata(r.Context(), r), core.ExportRecord{
+		Kind:     core.ExportTableData,
+		TableKey: job.TableKey,
+		Format:   string(job.Format),
+		FileName: job.FileName,
+		Filters:  job.Filters,
+		Rows:     job.Rows,
+		Err:      err,
+
+		Watermark: job.Watermark,
+	})
+}
+
+// handleTraceExportWatermark returns the audit entries of the exports
+// watermarked with an export ID, such as one found in a leaked file.
+func (s *Server) handleTraceExportWatermark(w http.ResponseWriter, r *http.Request) {
+	watermark := chi.URLParam(r, "watermark")
+	entries, err := s.service.TraceExportWatermark(r.Context(), watermark)
+	if errors.Is(err, core.ErrWatermarkNotFound) {
+		writeError(w, http.StatusNotFound, "no export recorded with this watermark")
+		return
+	}
+	if err != nil {
+		slog.Error("failed to trace export watermark", "watermark", watermark, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to trace export watermark")
+		return
+	}
+	writeJSON(w, entries)
+}
+
+// handleSummary returns grouped aggregations of a table, filtered like the
+// table view, e.g. ?group=Customer&group=Invoice Date:month&agg=sum:Amount.
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	query := r.URL.Query()
+	var groupBy []core.GroupSpec
+	for _, v := range query["group"] {
+		g, err := core.ParseGroupSpec(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		groupBy = append(groupBy, g)
+	}
+	var aggs []core.AggSpec
+	for _, v := range query["agg"] {
+		a, err := core.ParseAggSpec(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		aggs = append(aggs, a)
+	}
+
+	result, err := s.tables.GetGroupedData(r.Context(), tableKey, groupBy, aggs, parseFilters(r, def))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidSummary):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, core.ErrAggregatesOnly):
+			writeError(w, http.StatusForbidden, err.Error())
+		default:
+			slog.Error("failed to summarize table", "table", tableKey, "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to summarize table")
+		}
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleHistogram returns counts of a numeric column's values in
+// equal-width buckets, filtered like the table view.
+func (s *Server) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := strconv.ParseFloat(query.Get("from"), 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "from must be a number")
+		return
+	}
+	to, err := strconv.ParseFloat(query.Get("to"), 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "to must be a number")
+		return
+	}
+	buckets := 10
+	if v := query.Get("buckets"); v != "" {
+		if buckets, err = strconv.Atoi(v); err != nil {
+			writeError(w, http.StatusBadRequest, "buckets must be a whole number")
+			return
+		}
+	}
+
+	result, err := s.tables.GetHistogram(r.Context(), tableKey, query.Get("column"), from, to, buckets, parseFilters(r, def))
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidSummary) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		slog.Error("failed to build histogram", "table", tableKey, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to build histogram")
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleDataQuality returns the data quality report of a table: per
+// column empty values, distinct values, lengths, date ranges, outliers
+// and flags.
+func (s *Server) handleDataQuality(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	report, err := s.tables.GetDataQuality(r.Context(), tableKey)
+	if err != nil {
+		if errors.Is(err, core.ErrAggregatesOnly) {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		slog.Error("failed to build data quality report", "table", tableKey, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to build data quality report")
+		return
 	}
+	writeJSON(w, report)
 }
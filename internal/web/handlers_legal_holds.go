@@ -0,0 +1,88 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListLegalHolds lists active legal holds, optionally for one table
+// (?table=) and including released ones (?released=true).
+func (s *Server) handleListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := s.service.ListLegalHolds(r.Context(), r.URL.Query().Get("table"), r.URL.Query().Get("released") == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"holds": holds})
+}
+
+// handlePlaceLegalHold places a legal hold on a table or on the rows
+// matching the request's filters.
+func (s *Server) handlePlaceLegalHold(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Table string `json:"table"`
+		core.LegalHoldParams
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, ok := core.Get(req.Table); !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	hold, err := s.service.PlaceLegalHold(WithRequestMetadata(r.Context(), r), req.Table, req.LegalHoldParams)
+	if err != nil {
+		writeLegalHoldError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, hold)
+}
+
+// handleReleaseLegalHold lifts a legal hold. The request must give a reason.
+func (s *Server) handleReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	hold, err := s.service.ReleaseLegalHold(WithRequestMetadata(r.Context(), r), chi.URLParam(r, "id"), req.Reason)
+	if err != nil {
+		writeLegalHoldError(w, err)
+		return
+	}
+	writeJSON(w, hold)
+}
+
+// writeLegalHoldError maps a legal hold error to an HTTP status.
+func writeLegalHoldError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrLegalHoldNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrInvalidLegalHold):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeHeldError answers a purge, reset, rollback or delete refused by a
+// legal hold with 409 and reports whether err was one.
+func writeHeldError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, core.ErrLegalHold) {
+		return false
+	}
+	writeError(w, http.StatusConflict, err.Error())
+	return true
+}
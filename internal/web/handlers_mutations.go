@@ -1,14 +1,21 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/JonMunkholm/TUI/internal/core"
 	"github.com/go-chi/chi/v5"
 )
 
-// handleReset deletes all data from a specific table.
+// handleReset deletes all data from a specific table. With ?async=true the
+// reset runs in the background and the response carries its operation ID.
 func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
 	if tableKey == "" {
@@ -17,7 +24,28 @@ func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
+	if r.URL.Query().Get("async") == "true" {
+		opID, err := s.service.StartReset(ctx, tableKey)
+		if writeApprovalRequired(w, err) {
+			return
+		}
+		if writeHeldError(w, err) {
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeOperationAccepted(w, opID)
+		return
+	}
 	if err := s.service.Reset(ctx, tableKey); err != nil {
+		if writeApprovalRequired(w, err) {
+			return
+		}
+		if writeHeldError(w, err) {
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -26,19 +54,89 @@ func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"reset"}`))
 }
 
-// handleResetAll deletes all data from all tables.
+// handleResetAll deletes all data from all tables in dependency order, in
+// one transaction unless ?incremental=true, and in the background with
+// ?async=true. The response reports each table; it is a 500 if any table
+// was not reset.
 func (s *Server) handleResetAll(w http.ResponseWriter, r *http.Request) {
 	ctx := WithRequestMetadata(r.Context(), r)
-	if err := s.service.ResetAll(ctx); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	opts := core.ResetAllOptions{Incremental: r.URL.Query().Get("incremental") == "true"}
+	if r.URL.Query().Get("async") == "true" {
+		opID, err := s.service.StartResetAll(ctx, opts)
+		if writeApprovalRequired(w, err) {
+			return
+		}
+		if writeHeldError(w, err) {
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeOperationAccepted(w, opID)
+		return
+	}
+	result, err := s.service.ResetAll(ctx, opts)
+	if writeApprovalRequired(w, err) {
+		return
+	}
+	writeResetAllResult(w, result, err)
+}
+
+// handleResumeResetAll resets the tables an earlier incremental reset of all
+// tables did not finish, in the background with ?async=true.
+func (s *Server) handleResumeResetAll(w http.ResponseWriter, r *http.Request) {
+	operationID := chi.URLParam(r, "operationID")
+	if operationID == "" {
+		writeError(w, http.StatusBadRequest, "missing operation ID")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	if r.URL.Query().Get("async") == "true" {
+		opID, err := s.service.StartResumeResetAll(ctx, operationID)
+		if errors.Is(err, core.ErrOperationNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeOperationAccepted(w, opID)
+		return
+	}
+	result, err := s.service.ResumeResetAll(ctx, operationID)
+	if errors.Is(err, core.ErrOperationNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if err != nil && result.OperationID == "" {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeResetAllResult(w, result, err)
+}
 
+// writeResetAllResult writes the per-table report of a reset of all tables.
+func writeResetAllResult(w http.ResponseWriter, result core.ResetAllResult, err error) {
+	if result.OperationID == "" && writeHeldError(w, err) {
+		return
+	}
+	if err != nil && result.OperationID == "" {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("X-Operation-ID", result.OperationID)
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"status":"reset_all"}`))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
 }
 
-// handleRollbackUpload deletes all rows from a specific upload.
+// handleRollbackUpload deletes all rows from a specific upload, in the
+// background with ?async=true.
 func (s *Server) handleRollbackUpload(w http.ResponseWriter, r *http.Request) {
 	uploadID := chi.URLParam(r, "uploadID")
 	if uploadID == "" {
@@ -47,15 +145,187 @@ func (s *Server) handleRollbackUpload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
+	if r.URL.Query().Get("async") == "true" {
+		result, err := s.service.StartRollback(ctx, uploadID)
+		if writeApprovalRequired(w, err) {
+			return
+		}
+		if writeHeldError(w, err) {
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, result.Error)
+			return
+		}
+		writeOperationAccepted(w, result.OperationID)
+		return
+	}
 	result, err := s.service.RollbackUpload(ctx, uploadID)
+	if writeApprovalRequired(w, err) {
+		return
+	}
+	if writeHeldError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, result.Error)
 		return
 	}
 
+	w.Header().Set("X-Operation-ID", result.OperationID)
 	writeJSON(w, result)
 }
 
+// writeOperationAccepted answers a request whose work continues in the
+// background as the given operation.
+func writeOperationAccepted(w http.ResponseWriter, opID string) {
+	w.Header().Set("X-Operation-ID", opID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"operation_id": opID})
+}
+
+// handleRollbackRange rolls back all active uploads for a table in a date range,
+// in the background with ?async=true. GET previews it.
+// GET previews the uploads and row counts; POST performs the rollback.
+func (s *Server) handleRollbackRange(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+	if def.DeleteByUploadID == nil {
+		writeError(w, http.StatusBadRequest, "table does not support rollback")
+		return
+	}
+
+	from, ok := parseRangeBound(r.URL.Query().Get("from"), false)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid or missing from date")
+		return
+	}
+	to, ok := parseRangeBound(r.URL.Query().Get("to"), true)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid or missing to date")
+		return
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, "end of range is before start")
+		return
+	}
+
+	preview := r.Method == http.MethodGet
+	ctx := WithRequestMetadata(r.Context(), r)
+	if !preview && r.URL.Query().Get("async") == "true" {
+		result, err := s.service.StartRollbackRange(ctx, tableKey, from, to)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, result.Error)
+			return
+		}
+		if result.OperationID == "" {
+			// Nothing in the range to roll back
+			writeJSON(w, result)
+			return
+		}
+		writeOperationAccepted(w, result.OperationID)
+		return
+	}
+	result, err := s.service.RollbackUploadsInRange(ctx, tableKey, from, to, preview)
+	if writeApprovalRequired(w, err) {
+		return
+	}
+	if writeHeldError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, result.Error)
+		return
+	}
+
+	if result.OperationID != "" {
+		w.Header().Set("X-Operation-ID", result.OperationID)
+	}
+	writeJSON(w, result)
+}
+
+// handleRollbackUploadBatch rolls back every active upload in a batch.
+func (s *Server) handleRollbackUploadBatch(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batchID")
+	if batchID == "" {
+		writeError(w, http.StatusBadRequest, "missing batch ID")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	result, err := s.service.RollbackUploadBatch(ctx, batchID)
+	if errors.Is(err, core.ErrUploadBatchNotFound) {
+		writeError(w, http.StatusNotFound, result.Error)
+		return
+	}
+	if writeApprovalRequired(w, err) {
+		return
+	}
+	if writeHeldError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, result.Error)
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// handleBootstrap applies a declarative bootstrap file posted as the request
+// body. With ?dryRun=true only the diff is computed.
+func (s *Server) handleBootstrap(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	spec, err := core.ParseBootstrapSpec(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	result, err := s.service.ApplyBootstrap(WithRequestMetadata(r.Context(), r), spec, dryRun)
+	if err != nil {
+		slog.Error("bootstrap failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to apply bootstrap")
+		return
+	}
+
+	slog.Info("bootstrap applied via API", "dryRun", dryRun, "applied", result.Applied, "errors", result.Errors)
+	writeJSON(w, result)
+}
+
+// parseRangeBound parses a date range bound as RFC3339 or YYYY-MM-DD.
+// A bare date used as an end bound covers the whole day.
+func parseRangeBound(value string, end bool) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, true
+}
+
 // handleCheckDuplicates checks if provided keys already exist in the database.
 func (s *Server) handleCheckDuplicates(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
@@ -80,7 +350,7 @@ func (s *Server) handleCheckDuplicates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	existing, err := s.service.CheckDuplicates(r.Context(), tableKey, req.Keys)
+	existing, err := s.uploader.CheckDuplicates(r.Context(), tableKey, req.Keys)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -113,7 +383,10 @@ func (s *Server) handleDeleteRows(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleted, err := s.service.DeleteRows(r.Context(), tableKey, req.Keys)
+	deleted, err := s.service.DeleteRows(WithRequestMetadata(r.Context(), r), tableKey, req.Keys)
+	if writeHeldError(w, err) {
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -122,6 +395,157 @@ func (s *Server) handleDeleteRows(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]int{"deleted": deleted})
 }
 
+// handleDeletedRows lists the table's recently deleted rows.
+func (s *Server) handleDeletedRows(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	rows, err := s.tables.ListDeletedRows(r.Context(), tableKey, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{"rows": rows})
+}
+
+// handleRowHistory returns the edits, deletes and restores of one row.
+func (s *Server) handleRowHistory(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+	if len(def.Info.UniqueKey) == 0 {
+		writeError(w, http.StatusBadRequest, "table has no unique key")
+		return
+	}
+	rowKey := r.URL.Query().Get("key")
+	if rowKey == "" {
+		writeError(w, http.StatusBadRequest, "missing row key")
+		return
+	}
+
+	history, err := s.tables.GetRowHistory(r.Context(), tableKey, rowKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, history)
+}
+
+// handleRestoreRows restores deleted rows by their row_delete audit IDs.
+func (s *Server) handleRestoreRows(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "no rows specified")
+		return
+	}
+
+	result, err := s.service.RestoreDeletedRows(WithRequestMetadata(r.Context(), r), tableKey, req.IDs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleRecycledRows lists the rows in a soft-delete table's recycle bin.
+func (s *Server) handleRecycledRows(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	rows, err := s.tables.ListRecycledRows(r.Context(), tableKey, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{"rows": rows})
+}
+
+// handleRestoreRecycled restores rows from a soft-delete table's recycle bin.
+func (s *Server) handleRestoreRecycled(w http.ResponseWriter, r *http.Request) {
+	s.handleRecycleKeys(w, r, s.service.RestoreRecycledRows)
+}
+
+// handlePurgeRecycled permanently deletes rows from a soft-delete table's
+// recycle bin.
+func (s *Server) handlePurgeRecycled(w http.ResponseWriter, r *http.Request) {
+	s.handleRecycleKeys(w, r, s.service.PurgeRecycledRows)
+}
+
+// handleRecycleKeys decodes the row keys of a recycle bin request and
+// applies fn to them.
+func (s *Server) handleRecycleKeys(w http.ResponseWriter, r *http.Request, fn func(context.Context, string, []string) (*core.RecycleResult, error)) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Keys) == 0 {
+		writeError(w, http.StatusBadRequest, "no rows specified")
+		return
+	}
+
+	result, err := fn(WithRequestMetadata(r.Context(), r), tableKey, req.Keys)
+	if writeHeldError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
 // handleUpdateCell updates a single cell value.
 func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
@@ -158,8 +582,10 @@ func (s *Server) handleUpdateCell(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, result)
 }
 
-// handleBulkEdit updates a single column across multiple selected rows.
-func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
+// handleInsertRow adds one row from its values by column. A row that fails
+// validation or whose key is taken is reported in the result, not as an
+// error status.
+func (s *Server) handleInsertRow(w http.ResponseWriter, r *http.Request) {
 	tableKey := chi.URLParam(r, "tableKey")
 	if tableKey == "" {
 		writeError(w, http.StatusBadRequest, "missing table key")
@@ -167,31 +593,113 @@ func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Keys   []string `json:"keys"`
-		Column string   `json:"column"`
-		Value  string   `json:"value"`
+		Values map[string]string `json:"values"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	if len(req.Values) == 0 {
+		writeError(w, http.StatusBadRequest, "values are required")
+		return
+	}
 
-	if len(req.Keys) == 0 {
-		writeError(w, http.StatusBadRequest, "no rows specified")
+	result, err := s.service.InsertRow(r.Context(), tableKey, req.Values)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// handleCloneRow inserts a copy of a row with some values changed.
+func (s *Server) handleCloneRow(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	var req struct {
+		RowKey string            `json:"rowKey"`
+		Values map[string]string `json:"values"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.RowKey == "" {
+		writeError(w, http.StatusBadRequest, "rowKey is required")
+		return
+	}
+
+	result, err := s.service.CloneRow(r.Context(), tableKey, req.RowKey, req.Values)
+	if err != nil {
+		if errors.Is(err, core.ErrRowNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// handleUndoCellEdit reverts the table's (or a row's) most recent cell edit.
+func (s *Server) handleUndoCellEdit(w http.ResponseWriter, r *http.Request) {
+	s.replayCellEdit(w, r, s.service.UndoCellEdit)
+}
+
+// handleRedoCellEdit reapplies the most recently undone cell edit.
+func (s *Server) handleRedoCellEdit(w http.ResponseWriter, r *http.Request) {
+	s.replayCellEdit(w, r, s.service.RedoCellEdit)
+}
+
+// replayCellEdit runs an undo or redo. The body is optional; its rowKey
+// limits the replay to one row. A conflict is returned with 409.
+func (s *Server) replayCellEdit(w http.ResponseWriter, r *http.Request, replay func(context.Context, string, string) (*core.CellUndoResult, error)) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
 		return
 	}
 
-	if req.Column == "" {
-		writeError(w, http.StatusBadRequest, "column is required")
+	var req struct {
+		RowKey string `json:"rowKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := replay(WithRequestMetadata(r.Context(), r), tableKey, req.RowKey)
+	switch {
+	case errors.Is(err, core.ErrNothingToUndo), errors.Is(err, core.ErrNothingToRedo):
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result.Conflict || result.DuplicateKey {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+	}
+	writeJSON(w, result)
+}
+
+// handleBulkEdit updates one or more columns across multiple selected rows.
+func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
+	tableKey, req, ok := decodeBulkEdit(w, r)
+	if !ok {
 		return
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
-	result, err := s.service.BulkEditRows(ctx, tableKey, core.BulkEditRequest{
-		Keys:   req.Keys,
-		Column: req.Column,
-		Value:  req.Value,
-	})
+	result, err := s.service.BulkEditRows(ctx, tableKey, req)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
@@ -199,3 +707,68 @@ func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, result)
 }
+
+// handleBulkEditPreview returns the values a bulk edit would write.
+func (s *Server) handleBulkEditPreview(w http.ResponseWriter, r *http.Request) {
+	tableKey, req, ok := decodeBulkEdit(w, r)
+	if !ok {
+		return
+	}
+
+	preview, err := s.service.PreviewBulkEdit(r.Context(), tableKey, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, preview)
+}
+
+// decodeBulkEdit reads a bulk edit request, writing an error if it is
+// incomplete.
+func decodeBulkEdit(w http.ResponseWriter, r *http.Request) (string, core.BulkEditRequest, bool) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return "", core.BulkEditRequest{}, false
+	}
+
+	// A single column's edit, or edits listing several
+	type columnEdit struct {
+		Column string            `json:"column"`
+		Mode   core.BulkEditMode `json:"mode"`
+		Find   string            `json:"find"`
+		Value  string            `json:"value"`
+	}
+	var req struct {
+		Keys []string `json:"keys"`
+		columnEdit
+		Edits []columnEdit `json:"edits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return "", core.BulkEditRequest{}, false
+	}
+
+	if len(req.Keys) == 0 {
+		writeError(w, http.StatusBadRequest, "no rows specified")
+		return "", core.BulkEditRequest{}, false
+	}
+
+	if len(req.Edits) == 0 {
+		req.Edits = []columnEdit{req.columnEdit}
+	} else if req.Column != "" {
+		writeError(w, http.StatusBadRequest, "send either column or edits, not both")
+		return "", core.BulkEditRequest{}, false
+	}
+	edits := make([]core.BulkColumnEdit, len(req.Edits))
+	for i, e := range req.Edits {
+		if e.Column == "" {
+			writeError(w, http.StatusBadRequest, "column is required")
+			return "", core.BulkEditRequest{}, false
+		}
+		edits[i] = core.BulkColumnEdit{Column: e.Column, Mode: e.Mode, Find: e.Find, Value: e.Value}
+	}
+
+	return tableKey, core.BulkEditRequest{Keys: req.Keys, Edits: edits}, true
+}
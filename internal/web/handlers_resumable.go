@@ -0,0 +1,163 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// resumeTokenHeader carries the token a browser's resumable uploads are
+// bound to.
+const resumeTokenHeader = "X-Resume-Token"
+
+// writeResumableError maps resumable upload errors to status codes.
+func writeResumableError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrResumableDisabled), errors.Is(err, core.ErrResumableNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrResumableOffset),
+		errors.Is(err, core.ErrResumableBusy),
+		errors.Is(err, core.ErrResumableIncomplete):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, core.ErrTooManyUploads):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, core.ErrUploaderBusy), errors.Is(err, core.ErrUploadQuotaExceeded):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// handleCreateResumableUpload opens a resumable upload session. Without an
+// X-Resume-Token header a token is generated and returned; the client must
+// send it with every later request for the session.
+func (s *Server) handleCreateResumableUpload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FileName   string         `json:"file_name"`
+		Size       int64          `json:"size"`
+		Mapping    map[string]int `json:"mapping"`
+		Mode       string         `json:"mode"`
+		Duplicates string         `json:"duplicates"`
+		DateFormat string         `json:"date_format"`
+		YearPivot  int            `json:"year_pivot"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	mode, err := core.ParseUploadMode(req.Mode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.NewDateOptions(req.DateFormat, req.YearPivot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token := r.Header.Get(resumeTokenHeader)
+	generated := token == ""
+	if generated {
+		if token, err = core.NewResumeToken(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	pending, err := s.uploader.CreateResumableUpload(r.Context(), token, core.ResumableRequest{
+		TableKey:   chi.URLParam(r, "tableKey"),
+		FileName:   req.FileName,
+		Size:       req.Size,
+		Mapping:    req.Mapping,
+		Mode:       mode,
+		Duplicates: dups,
+		Dates:      dateOpts,
+	})
+	if err != nil {
+		writeResumableError(w, err)
+		return
+	}
+
+	resp := struct {
+		*core.PendingUpload
+		ResumeToken string `json:"resume_token,omitempty"`
+	}{PendingUpload: pending}
+	if generated {
+		resp.ResumeToken = token
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, resp)
+}
+
+// handleGetResumableUpload returns a session's state, including the offset
+// the next chunk must start at.
+func (s *Server) handleGetResumableUpload(w http.ResponseWriter, r *http.Request) {
+	pending, err := s.uploader.GetResumableUpload(chi.URLParam(r, "sessionID"), r.Header.Get(resumeTokenHeader))
+	if err != nil {
+		writeResumableError(w, err)
+		return
+	}
+	writeJSON(w, pending)
+}
+
+// handleAppendResumableChunk stores the request body as the chunk starting
+// at the offset query param.
+func (s *Server) handleAppendResumableChunk(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	// The service rejects oversized chunks itself; this bounds the read
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.Upload.ResumableChunkSize+1)
+
+	pending, err := s.uploader.AppendResumableChunk(chi.URLParam(r, "sessionID"), r.Header.Get(resumeTokenHeader), offset, r.Body)
+	if err != nil {
+		writeResumableError(w, err)
+		return
+	}
+	writeJSON(w, pending)
+}
+
+// handleCompleteResumableUpload starts processing a fully received file.
+// Retrying returns the upload already started.
+func (s *Server) handleCompleteResumableUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := WithRequestMetadata(r.Context(), r)
+	pending, err := s.uploader.CompleteResumableUpload(ctx, chi.URLParam(r, "sessionID"), r.Header.Get(resumeTokenHeader))
+	if err != nil {
+		writeResumableError(w, err)
+		return
+	}
+	writeJSON(w, pending)
+}
+
+// handleCancelResumableUpload discards a session and its chunks.
+func (s *Server) handleCancelResumableUpload(w http.ResponseWriter, r *http.Request) {
+	if err := s.uploader.CancelResumableUpload(chi.URLParam(r, "sessionID"), r.Header.Get(resumeTokenHeader)); err != nil {
+		writeResumableError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "cancelled"})
+}
+
+// handlePendingUploads lists the caller's resumable uploads: unfinished
+// ones to resume and started ones to reattach to.
+func (s *Server) handlePendingUploads(w http.ResponseWriter, r *http.Request) {
+	if s.service.Spool() == nil {
+		writeResumableError(w, core.ErrResumableDisabled)
+		return
+	}
+	writeJSON(w, s.uploader.PendingUploads(r.Header.Get(resumeTokenHeader)))
+}
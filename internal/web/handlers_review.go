@@ -0,0 +1,81 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	mw "github.com/JonMunkholm/TUI/internal/web/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListUploadReviews lists uploads with their review status, newest
+// first, filtered by table and review status.
+func (s *Server) handleListUploadReviews(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	reviews, err := s.service.ListUploadReviews(r.Context(), core.UploadReviewFilter{
+		TableKey: q.Get("table"),
+		Review:   q.Get("review"),
+		Limit:    parseIntParam(r, "limit", 0),
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, reviews)
+}
+
+// handleGetUploadReview returns one upload's review status.
+func (s *Server) handleGetUploadReview(w http.ResponseWriter, r *http.Request) {
+	review, err := s.service.GetUploadReview(r.Context(), chi.URLParam(r, "uploadID"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, review)
+}
+
+// handleSetUploadReview moves an upload to another review status. Signing
+// off needs a reviewer key when REVIEWER_API_KEYS is set.
+func (s *Server) handleSetUploadReview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status   string `json:"status"`
+		Reviewer string `json:"reviewer"`
+		Note     string `json:"note"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	status, err := core.ParseReviewStatus(req.Status)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	keys := s.cfg.Security.ReviewerAPIKeys
+	ctx := WithRequestMetadata(r.Context(), r)
+	review, err := s.service.SetUploadReview(ctx, chi.URLParam(r, "uploadID"), core.ReviewChange{
+		Status:     status,
+		Reviewer:   strings.TrimSpace(req.Reviewer),
+		Note:       strings.TrimSpace(req.Note),
+		CanSignOff: len(keys) == 0 || mw.HasAPIKey(r, keys),
+	})
+	switch {
+	case errors.Is(err, core.ErrReviewerRequired):
+		s.auditDenied(r, core.Denial{Kind: core.DenialPermission, UploadID: chi.URLParam(r, "uploadID"), Detail: err.Error()})
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, core.ErrInvalidReviewTransition):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil && strings.Contains(err.Error(), "not found"):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil && strings.Contains(err.Error(), "invalid upload ID"):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, review)
+	}
+}
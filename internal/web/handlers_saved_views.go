@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListSavedViews returns all saved views for a table.
+func (s *Server) handleListSavedViews(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	views, err := s.service.ListSavedViews(r.Context(), tableKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, views)
+}
+
+// handleGetSavedView returns a single saved view.
+func (s *Server) handleGetSavedView(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.loadSavedView(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, view)
+}
+
+// handleCreateSavedView saves a new view for a table.
+func (s *Server) handleCreateSavedView(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	var req core.SavedViewParams
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	view, err := s.service.CreateSavedView(r.Context(), tableKey, req)
+	if err != nil {
+		writeSavedViewError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, view)
+}
+
+// handleUpdateSavedView replaces a saved view's name and settings.
+func (s *Server) handleUpdateSavedView(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.loadSavedView(w, r); !ok {
+		return
+	}
+
+	var req core.SavedViewParams
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	view, err := s.service.UpdateSavedView(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		writeSavedViewError(w, err)
+		return
+	}
+	writeJSON(w, view)
+}
+
+// handleDeleteSavedView removes a saved view.
+func (s *Server) handleDeleteSavedView(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.loadSavedView(w, r); !ok {
+		return
+	}
+
+	if err := s.service.DeleteSavedView(r.Context(), chi.URLParam(r, "id")); err != nil {
+		writeSavedViewError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "deleted"})
+}
+
+// loadSavedView fetches the {id} view and checks it belongs to {tableKey},
+// writing a 404 if not.
+func (s *Server) loadSavedView(w http.ResponseWriter, r *http.Request) (*core.SavedView, bool) {
+	view, err := s.service.GetSavedView(r.Context(), chi.URLParam(r, "id"))
+	if err == nil && view.TableKey != chi.URLParam(r, "tableKey") {
+		err = core.ErrSavedViewNotFound
+	}
+	if err != nil {
+		writeSavedViewError(w, err)
+		return nil, false
+	}
+	return view, true
+}
+
+// writeSavedViewError maps a saved view error to an HTTP status.
+func writeSavedViewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrSavedViewNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrSavedViewExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, core.ErrInvalidSavedView):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
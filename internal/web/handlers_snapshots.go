@@ -0,0 +1,97 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleCreateSnapshot records an export snapshot for the rows matched by the
+// request's search and filter parameters (same format as the export endpoint).
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	def, ok := core.Get(tableKey)
+	if !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	search := r.URL.Query().Get("search")
+	filters := parseFilters(r, def)
+
+	snapshot, err := s.service.CreateExportSnapshot(r.Context(), tableKey, name, search, filters)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, snapshot)
+}
+
+// handleListSnapshots returns all export snapshots for a table.
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	snapshots, err := s.service.ListExportSnapshots(r.Context(), tableKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, snapshots)
+}
+
+// handleSnapshotDiff compares current table data against a stored snapshot.
+func (s *Server) handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing snapshot id")
+		return
+	}
+
+	diff, err := s.service.GetSnapshotDiff(r.Context(), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "invalid snapshot ID") {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, diff)
+}
+
+// handleDeleteSnapshot removes an export snapshot.
+func (s *Server) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing snapshot id")
+		return
+	}
+
+	if err := s.service.DeleteExportSnapshot(r.Context(), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "deleted"})
+}
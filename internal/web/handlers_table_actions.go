@@ -0,0 +1,45 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	"github.com/go-chi/chi/v5"
+)
+
+// handleListTableActions lists a table's custom actions.
+func (s *Server) handleListTableActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := s.service.ListTableActions(chi.URLParam(r, "tableKey"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"actions": actions})
+}
+
+// handleRunTableAction starts a table's custom action in the background.
+func (s *Server) handleRunTableAction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Params map[string]string `json:"params"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	opID, err := s.service.StartTableAction(ctx, chi.URLParam(r, "tableKey"), chi.URLParam(r, "action"), req.Params)
+	switch {
+	case errors.Is(err, core.ErrActionRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeOperationAccepted(w, opID)
+}
@@ -1,10 +1,16 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/JonMunkholm/TUI/internal/core"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -16,7 +22,7 @@ func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	templates, err := s.service.ListTemplates(r.Context(), tableKey)
+	templates, err := s.templateStore.ListTemplates(r.Context(), tableKey)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -45,7 +51,7 @@ func (s *Server) handleMatchTemplates(w http.ResponseWriter, r *http.Request) {
 		headers[i] = strings.TrimSpace(headers[i])
 	}
 
-	matches, err := s.service.MatchTemplates(r.Context(), tableKey, headers)
+	matches, err := s.templateStore.MatchTemplates(r.Context(), tableKey, headers)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -55,6 +61,81 @@ func (s *Server) handleMatchTemplates(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(matches)
 }
 
+// handleProposeTemplates proposes import templates from the header rows of
+// a table's past uploads.
+func (s *Server) handleProposeTemplates(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+
+	minUploads, ok := parseMinUploads(w, r.URL.Query().Get("minUploads"))
+	if !ok {
+		return
+	}
+
+	proposals, err := s.service.ProposeTemplates(r.Context(), tableKey, minUploads)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposals)
+}
+
+// handleCreateProposedTemplates saves proposed import templates.
+func (s *Server) handleCreateProposedTemplates(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "unknown table")
+		return
+	}
+
+	var req struct {
+		Signatures []string `json:"signatures"`
+		MinUploads int      `json:"minUploads"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.MinUploads < 0 {
+		writeError(w, http.StatusBadRequest, "invalid minUploads")
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	templates, err := s.service.CreateProposedTemplates(ctx, tableKey, req.Signatures, req.MinUploads)
+	if err != nil {
+		if errors.Is(err, core.ErrUnknownProposal) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(templates)
+}
+
+// parseMinUploads parses the minUploads query parameter; empty is 0 (the
+// default). It writes the error response when the value is invalid.
+func parseMinUploads(w http.ResponseWriter, v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		writeError(w, http.StatusBadRequest, "invalid minUploads")
+		return 0, false
+	}
+	return n, true
+}
+
 // handleGetTemplate returns a single import template by ID.
 func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
@@ -63,7 +144,7 @@ func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template, err := s.service.GetTemplate(r.Context(), id)
+	template, err := s.templateStore.GetTemplate(r.Context(), id)
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -76,10 +157,12 @@ func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
 // handleCreateTemplate creates a new import template.
 func (s *Server) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		TableKey      string         `json:"tableKey"`
-		Name          string         `json:"name"`
-		ColumnMapping map[string]int `json:"columnMapping"`
-		CSVHeaders    []string       `json:"csvHeaders"`
+		TableKey      string                  `json:"tableKey"`
+		Name          string                  `json:"name"`
+		ColumnMapping map[string]int          `json:"columnMapping"`
+		CSVHeaders    []string                `json:"csvHeaders"`
+		FixedWidth    *core.FixedWidthLayout  `json:"fixedWidth"`
+		Transforms    []core.MappingTransform `json:"transforms"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -92,14 +175,24 @@ func (s *Server) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.ColumnMapping) == 0 {
+	if len(req.ColumnMapping) == 0 && req.FixedWidth == nil {
 		writeError(w, http.StatusBadRequest, "columnMapping is required")
 		return
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
-	template, err := s.service.CreateTemplate(ctx, req.TableKey, req.Name, req.ColumnMapping, req.CSVHeaders)
+	var template *core.ImportTemplate
+	var err error
+	if req.FixedWidth != nil {
+		template, err = s.templateStore.CreateFixedWidthTemplate(ctx, req.TableKey, req.Name, req.ColumnMapping, *req.FixedWidth)
+	} else {
+		template, err = s.templateStore.CreateTemplate(ctx, req.TableKey, req.Name, req.ColumnMapping, req.CSVHeaders, req.Transforms)
+	}
 	if err != nil {
+		if errors.Is(err, core.ErrInvalidFixedWidth) || errors.Is(err, core.ErrInvalidTransform) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
 			writeError(w, http.StatusConflict, "template name already exists")
 			return
@@ -122,9 +215,11 @@ func (s *Server) handleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name          string         `json:"name"`
-		ColumnMapping map[string]int `json:"columnMapping"`
-		CSVHeaders    []string       `json:"csvHeaders"`
+		Name          string                  `json:"name"`
+		ColumnMapping map[string]int          `json:"columnMapping"`
+		CSVHeaders    []string                `json:"csvHeaders"`
+		FixedWidth    *core.FixedWidthLayout  `json:"fixedWidth"`
+		Transforms    []core.MappingTransform `json:"transforms"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -138,8 +233,18 @@ func (s *Server) handleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
-	template, err := s.service.UpdateTemplate(ctx, id, req.Name, req.ColumnMapping, req.CSVHeaders)
+	var template *core.ImportTemplate
+	var err error
+	if req.FixedWidth != nil {
+		template, err = s.templateStore.UpdateFixedWidthTemplate(ctx, id, req.Name, req.ColumnMapping, *req.FixedWidth)
+	} else {
+		template, err = s.templateStore.UpdateTemplate(ctx, id, req.Name, req.ColumnMapping, req.CSVHeaders, req.Transforms)
+	}
 	if err != nil {
+		if errors.Is(err, core.ErrInvalidFixedWidth) || errors.Is(err, core.ErrInvalidTransform) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -157,7 +262,7 @@ func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := WithRequestMetadata(r.Context(), r)
-	if err := s.service.DeleteTemplate(ctx, id); err != nil {
+	if err := s.templateStore.DeleteTemplate(ctx, id); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -165,3 +270,52 @@ func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"deleted"}`))
 }
+
+// uploadTemplate returns the import template an upload or preview names
+// in its "template" field, or nil if it names none. The template must
+// belong to tableKey.
+func (s *Server) uploadTemplate(ctx context.Context, tableKey, templateID string) (*core.ImportTemplate, error) {
+	if templateID == "" {
+		return nil, nil
+	}
+	t, err := s.templateStore.GetTemplate(ctx, templateID)
+	if err != nil || t.TableKey != tableKey {
+		return nil, fmt.Errorf("import template %s not found for %s", templateID, tableKey)
+	}
+	return t, nil
+}
+
+// templateContext records t on ctx so the upload or preview can warn when
+// the file's headers have drifted from the ones t was saved with. It only
+// does so when t supplies the column mapping: an explicit mapping in the
+// request wins, and fixed-width templates have no header row to compare.
+func templateContext(ctx context.Context, t *core.ImportTemplate, mapping map[string]int) context.Context {
+	if t == nil || len(mapping) > 0 || t.FixedWidth != nil {
+		return ctx
+	}
+	return core.ContextWithTemplate(ctx, t)
+}
+
+// applyTemplate reads an uploaded file through t: a fixed-width template
+// slices it into CSV, and any template supplies its column mapping when
+// the request sets none. A nil t returns file and mapping unchanged. If
+// file is an io.Closer (a spooled upload), the result closes it.
+func (s *Server) applyTemplate(t *core.ImportTemplate, file io.Reader, mapping map[string]int) (io.Reader, map[string]int) {
+	if t == nil {
+		return file, mapping
+	}
+	if len(mapping) == 0 {
+		mapping = t.ColumnMapping
+	}
+	if t.FixedWidth == nil {
+		return file, mapping
+	}
+	sliced := s.uploader.FixedWidthReader(file, *t.FixedWidth)
+	if c, ok := file.(io.Closer); ok {
+		return struct {
+			io.Reader
+			io.Closer
+		}{sliced, c}, mapping
+	}
+	return sliced, mapping
+}
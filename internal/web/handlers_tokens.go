@@ -0,0 +1,211 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/core"
+	mw "github.com/JonMunkholm/TUI/internal/web/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+// tableFreeRoutes are the routes that name no table and show no table's
+// rows, which tokens limited to tables may still read.
+var tableFreeRoutes = map[string]bool{
+	"/api/tables":              true,
+	"/api/auth/me":             true,
+	"/api/upload-queue-status": true,
+	"/api/webhooks/schema":     true,
+}
+
+// tokenAuth puts the API token in the request's X-API-Key header in its
+// context and turns the request away if the token is unknown, revoked or
+// expired, or its scope or tables don't cover it. GET requests need the
+// read scope, or only aggregate on aggregate routes, and others write;
+// destructive routes check for more (see destructiveAuth). Keys from
+// API_KEYS and other headers pass untouched.
+func (s *Server) tokenAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if !strings.HasPrefix(key, core.APITokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.lockout.Throttle(w, r) {
+			return
+		}
+
+		token, err := s.service.AuthenticateAPIToken(r.Context(), key)
+		if err != nil {
+			code := "AUTH_INVALID_TOKEN"
+			switch {
+			case errors.Is(err, core.ErrAPITokenExpired):
+				code = "AUTH_TOKEN_EXPIRED"
+			case !errors.Is(err, core.ErrAPITokenUnknown):
+				slog.Error("failed to look up API token", "path", r.URL.Path, "error", err)
+				writeError(w, http.StatusInternalServerError, "failed to check API token")
+				return
+			}
+			s.lockout.Fail(r)
+			slog.Warn("auth: rejected API token", "path", r.URL.Path, "method", r.Method, "remote_addr", r.RemoteAddr, "error", err)
+			s.auditMiddlewareDenial(r, code, err.Error())
+			writeAuthError(w, http.StatusUnauthorized, err.Error(), code)
+			return
+		}
+		s.lockout.Succeed(r)
+		r = r.WithContext(core.ContextWithAPIToken(r.Context(), token))
+
+		pattern, rctx := s.findRoute(r)
+		scope := core.ScopeWrite
+		switch {
+		case aggregateRoutes[pattern] && r.Method == http.MethodGet:
+			scope = core.ScopeAggregate
+		case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+			scope = core.ScopeRead
+		}
+		if scope != core.ScopeWrite && tableFreeRoutes[pattern] && token.HasScope(scope) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.tokenAllows(w, r, token, scope, s.tokenTable(r, pattern, rctx)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// destructiveAuth guards destructive routes: a request with an API token
+// needs the destructive scope, and any other must pass APIKeyAuth.
+func (s *Server) destructiveAuth(next http.Handler) http.Handler {
+	keyAuth := mw.APIKeyAuth(&s.cfg.Security, s.lockout, s.auditMiddlewareDenial)(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := core.GetAPITokenFromContext(r.Context())
+		if token == nil {
+			keyAuth.ServeHTTP(w, r)
+			return
+		}
+		pattern, rctx := s.findRoute(r)
+		if s.tokenAllows(w, r, token, core.ScopeDestructive, s.tokenTable(r, pattern, rctx)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// tokenAllows reports whether token may make r, needing scope on
+// tableKey, and answers r with 403 if not.
+func (s *Server) tokenAllows(w http.ResponseWriter, r *http.Request, token *core.APIToken, scope core.TokenScope, tableKey string) bool {
+	if token.Allows(scope, tableKey) {
+		return true
+	}
+	reason := "API token lacks the " + string(scope) + " scope"
+	if token.HasScope(scope) {
+		reason = "API token is limited to tables " + strings.Join(token.Tables, ", ")
+	}
+	slog.Warn("auth: API token out of scope",
+		"path", r.URL.Path,
+		"method", r.Method,
+		"token", token.ID,
+		"scope", scope,
+		"table", tableKey,
+	)
+	s.auditMiddlewareDenial(r, "AUTH_TOKEN_SCOPE", reason)
+	writeAuthError(w, http.StatusForbidden, reason, "AUTH_TOKEN_SCOPE")
+	return false
+}
+
+// tokenTable returns the table a request to pattern, with the URL
+// parameters in rctx, works on, for checking tokens limited to tables:
+// its {tableKey}, or the table of the upload or export job it names. It
+// returns "" for other routes and for unknown uploads and jobs.
+func (s *Server) tokenTable(r *http.Request, pattern string, rctx *chi.Context) string {
+	if tableKey := rctx.URLParam("tableKey"); tableKey != "" {
+		return tableKey
+	}
+	token := core.GetAPITokenFromContext(r.Context())
+	if token == nil || len(token.Tables) == 0 {
+		return "" // Not limited, so no need to look
+	}
+	if uploadID := rctx.URLParam("uploadID"); uploadID != "" {
+		tableKey, _ := s.service.UploadTableKey(r.Context(), uploadID)
+		return tableKey
+	}
+	if pattern == "/api/export-job/{operationID}/download" {
+		if job, err := s.service.ExportJobs().Get(rctx.URLParam("operationID")); err == nil {
+			return job.TableKey
+		}
+	}
+	return ""
+}
+
+// findRoute returns the pattern of the route r will be served by, or ""
+// if none, and the route's URL parameters. Middleware runs before
+// routing, so the route is looked up here.
+func (s *Server) findRoute(r *http.Request) (string, *chi.Context) {
+	rctx := chi.NewRouteContext()
+	return s.router.Find(rctx, r.Method, r.URL.Path), rctx
+}
+
+// writeAuthError writes an authentication error in the form the API key
+// middleware uses.
+func writeAuthError(w http.ResponseWriter, status int, msg, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": msg, "code": code}); err != nil {
+		slog.Error("json encode failed", "error", err)
+	}
+}
+
+// handleListAPITokens lists API tokens.
+func (s *Server) handleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.service.ListAPITokens(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, map[string]any{"tokens": tokens})
+}
+
+// handleCreateAPIToken creates an API token. The response is the only
+// time the token is shown.
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var p core.APITokenParams
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	token, err := s.service.CreateAPIToken(r.Context(), p)
+	if err != nil {
+		writeAPITokenError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, token)
+}
+
+// handleRevokeAPIToken revokes an API token.
+func (s *Server) handleRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	token, err := s.service.RevokeAPIToken(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeAPITokenError(w, err)
+		return
+	}
+	writeJSON(w, token)
+}
+
+// writeAPITokenError maps an API token error to an HTTP status.
+func writeAPITokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrAPITokenNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, core.ErrInvalidAPIToken):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
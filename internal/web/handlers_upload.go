@@ -1,12 +1,19 @@
 package web
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/JonMunkholm/TUI/internal/core"
@@ -26,47 +33,918 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	maxSize := s.cfg.Upload.MaxFileSize
 	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 
+	if spool := s.service.Spool(); spool != nil {
+		s.handleSpooledUpload(w, r, spool, tableKey)
+		return
+	}
+
 	if err := r.ParseMultipartForm(maxSize); err != nil {
 		writeError(w, http.StatusBadRequest, "file too large or invalid form")
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	// Parse column mapping if provided; string entries are constants
+	var mapping map[string]int
+	var constants map[string]string
+	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
+		if mapping, constants, err = core.ParseMapping([]byte(mappingJSON)); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid mapping format")
+			return
+		}
+	}
+
+	mode, err := core.ParseUploadMode(r.FormValue("mode"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(r.FormValue("duplicates"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := core.ContextWithImportColumns(WithRequestMetadata(r.Context(), r), core.ParseImportColumns(r.FormValue("columns")))
+	ctx = core.ContextWithMappingConstants(ctx, constants)
+	tpl, err := s.uploadTemplate(ctx, tableKey, r.FormValue("template"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A zip archive is expanded into a batch with one upload per CSV
+	head := make([]byte, 4)
+	n, _ := file.ReadAt(head, 0)
+	if core.IsZipArchive(header.Filename, head[:n]) {
+		if tpl != nil && tpl.FixedWidth != nil {
+			writeError(w, http.StatusBadRequest, errFixedWidthZip)
+			return
+		}
+		s.startZipUpload(ctx, w, tableKey, file, mapping, mode, dups, dateOpts)
+		return
+	}
+
+	// Use streaming upload - pass file directly as io.Reader
+	// No io.ReadAll! Memory stays constant at O(batch_size) ~10MB
+	ctx = templateContext(ctx, tpl, mapping)
+	reader, mapping := s.applyTemplate(tpl, file, mapping)
+	uploadID, err := s.uploader.StartUploadStreaming(ctx, tableKey, header.Filename, reader, header.Size, mapping, mode, dups, dateOpts)
+	if err != nil {
+		writeError(w, uploadStartStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, map[string]string{"upload_id": uploadID})
+}
+
+// handleSpooledUpload streams the multipart body part by part, encrypting
+// the file into the spool as it arrives, then processes it from the spool.
+// Parts may arrive in any order.
+func (s *Server) handleSpooledUpload(w http.ResponseWriter, r *http.Request, spool *core.Spool, tableKey string) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
+
+	var (
+		spoolID   string
+		fileName  string
+		fileSize  int64
+		mapping   map[string]int
+		constants map[string]string
+		tplID     = r.URL.Query().Get("template")
+		modeStr   = r.URL.Query().Get("mode")
+		dupsStr   = r.URL.Query().Get("duplicates")
+		dateStr   = r.URL.Query().Get("date_format")
+		pivotStr  = r.URL.Query().Get("year_pivot")
+		colsStr   = r.URL.Query().Get("columns")
+	)
+	defer func() {
+		if spoolID != "" {
+			spool.Remove(spoolID)
+		}
+	}()
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "file too large or invalid form")
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			if spoolID != "" {
+				break // Only the first file is used, as with FormFile
+			}
+			fileName = part.FileName()
+			spoolID, fileSize, err = spool.Write(part)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				} else {
+					slog.Error("failed to spool upload", "table", tableKey, "error", err)
+					writeError(w, http.StatusInternalServerError, "failed to store upload")
+				}
+				return
+			}
+		case "mapping":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			if len(data) > 0 {
+				if mapping, constants, err = core.ParseMapping(data); err != nil {
+					writeError(w, http.StatusBadRequest, "invalid mapping format")
+					return
+				}
+			}
+		case "template":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			tplID = string(data)
+		case "mode":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			modeStr = string(data)
+		case "duplicates":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			dupsStr = string(data)
+		case "date_format":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			dateStr = string(data)
+		case "year_pivot":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			pivotStr = string(data)
+		case "columns":
+			data, err := io.ReadAll(part)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "file too large or invalid form")
+				return
+			}
+			colsStr = string(data)
+		}
+		part.Close()
+	}
+
+	if spoolID == "" {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	mode, err := core.ParseUploadMode(modeStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(dupsStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.ParseDateOptions(dateStr, pivotStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := core.ContextWithImportColumns(WithRequestMetadata(r.Context(), r), core.ParseImportColumns(colsStr))
+	ctx = core.ContextWithMappingConstants(ctx, constants)
+	tpl, err := s.uploadTemplate(ctx, tableKey, tplID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Decrypts as the upload is processed; closing it deletes the spooled file
+	spooled, err := spool.Open(spoolID)
+	if err != nil {
+		slog.Error("failed to open spooled upload", "table", tableKey, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to store upload")
+		return
+	}
+
+	if core.IsZipArchive(fileName, nil) {
+		defer spooled.Close()
+		if tpl != nil && tpl.FixedWidth != nil {
+			writeError(w, http.StatusBadRequest, errFixedWidthZip)
+			return
+		}
+		s.startZipUpload(ctx, w, tableKey, spooled, mapping, mode, dups, dateOpts)
+		return
+	}
+
+	ctx = templateContext(ctx, tpl, mapping)
+	reader, mapping := s.applyTemplate(tpl, spooled, mapping)
+	uploadID, err := s.uploader.StartUploadStreaming(ctx, tableKey, fileName, reader, fileSize, mapping, mode, dups, dateOpts)
+	if err != nil {
+		spooled.Close()
+		writeError(w, uploadStartStatus(err), err.Error())
+		return
+	}
+	spoolID = "" // Owned by the upload now
+
+	writeJSON(w, map[string]string{"upload_id": uploadID})
+}
+
+// startZipUpload uploads every CSV in a zip archive to tableKey as one
+// batch and responds with the batch and upload IDs.
+func (s *Server) startZipUpload(ctx context.Context, w http.ResponseWriter, tableKey string, archive io.Reader, mapping map[string]int, mode core.UploadMode, dups core.DuplicatePolicy, dateOpts core.DateOptions) {
+	batchID, uploadIDs, err := s.uploader.StartZipUpload(ctx, tableKey, archive, mapping, mode, dups, dateOpts)
+	if err != nil {
+		writeError(w, uploadStartStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, map[string]any{"batch_id": batchID, "upload_ids": uploadIDs})
+}
+
+// handleUploadFromURL starts a streaming upload of a CSV read server-side
+// from an https://, s3:// or gs:// URL.
+func (s *Server) handleUploadFromURL(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	var req struct {
+		URL        string         `json:"url"`
+		Mapping    map[string]int `json:"mapping"`
+		Mode       string         `json:"mode"`
+		Duplicates string         `json:"duplicates"`
+		DateFormat string         `json:"date_format"`
+		YearPivot  int            `json:"year_pivot"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, "missing url")
+		return
+	}
+
+	mode, err := core.ParseUploadMode(req.Mode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.NewDateOptions(req.DateFormat, req.YearPivot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	uploadID, err := s.uploader.StartUploadFromURL(ctx, tableKey, req.URL, req.Mapping, mode, dups, dateOpts)
+	if errors.Is(err, core.ErrRemoteSourceNotAllowed) {
+		s.auditDenied(r, core.Denial{Kind: core.DenialPermission, Detail: err.Error()})
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, uploadStartStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, map[string]string{"upload_id": uploadID})
+}
+
+// handleUploadPaste previews, validates or uploads rows pasted from the
+// clipboard as tab- or comma-delimited text.
+func (s *Server) handleUploadPaste(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	var req struct {
+		Text       string         `json:"text"`
+		Step       string         `json:"step"`
+		Mapping    map[string]int `json:"mapping"`
+		Template   string         `json:"template"`
+		Mode       string         `json:"mode"`
+		Duplicates string         `json:"duplicates"`
+		DateFormat string         `json:"date_format"`
+		YearPivot  int            `json:"year_pivot"`
+	}
+	// JSON escaping can double the text, so allow room beyond the limit
+	body := http.MaxBytesReader(w, r.Body, 2*s.uploader.MaxPasteSize()+64<<10)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "paste too large; upload a file instead")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	step, err := core.ParsePasteStep(req.Step)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, fileName, err := s.uploader.PasteFile(req.Text)
+	if errors.Is(err, core.ErrPasteTooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mode, err := core.ParseUploadMode(req.Mode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(req.Duplicates)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.NewDateOptions(req.DateFormat, req.YearPivot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := WithRequestMetadata(r.Context(), r)
+	tpl, err := s.uploadTemplate(ctx, tableKey, req.Template)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx = templateContext(ctx, tpl, req.Mapping)
+	reader, mapping := s.applyTemplate(tpl, bytes.NewReader(data), req.Mapping)
+
+	if step == core.PasteUpload {
+		uploadID, err := s.uploader.StartUploadStreaming(ctx, tableKey, fileName, reader, int64(len(data)), mapping, mode, dups, dateOpts)
+		if err != nil {
+			writeError(w, uploadStartStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, map[string]string{"upload_id": uploadID})
+		return
+	}
+
+	if tpl != nil {
+		if data, err = io.ReadAll(reader); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if step == core.PastePreview {
+		result, err := s.uploader.AnalyzeUpload(ctx, tableKey, data, mapping, mode, dateOpts)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, result)
+		return
+	}
+
+	thresholds := core.ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: -1}
+	report, err := s.uploader.ValidateFile(tableKey, fileName, data, mapping, dateOpts, thresholds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handlePreview analyzes a CSV file and returns what would happen on upload.
+func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	var mapping map[string]int
+	var constants map[string]string
+	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
+		if mapping, constants, err = core.ParseMapping([]byte(mappingJSON)); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid mapping format")
+			return
+		}
+	}
+
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tpl, err := s.uploadTemplate(r.Context(), tableKey, r.FormValue("template"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx := templateContext(core.ContextWithMappingConstants(r.Context(), constants), tpl, mapping)
+	if tpl != nil {
+		reader, m := s.applyTemplate(tpl, bytes.NewReader(data), mapping)
+		if data, err = io.ReadAll(reader); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		mapping = m
+	}
+
+	mode, err := core.ParseUploadMode(r.FormValue("mode"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A dry run runs the real upload pipeline in a rolled-back transaction
+	// and reports every failed row instead of samples
+	if dryRun, _ := strconv.ParseBool(r.FormValue("dryRun")); dryRun {
+		dryCtx := core.ContextWithImportColumns(WithRequestMetadata(ctx, r), core.ParseImportColumns(r.FormValue("columns")))
+		report, err := s.uploader.DryRunUpload(dryCtx, tableKey, data, mapping, mode, dateOpts)
+		if err != nil {
+			writeError(w, uploadStartStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, report)
+		return
+	}
+
+	result, err := s.uploader.AnalyzeUpload(ctx, tableKey, data, mapping, mode, dateOpts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// uploadStartStatus is the status for an upload that could not start: 429
+// when a per-uploader quota refused it, 400 otherwise.
+func uploadStartStatus(err error) int {
+	if errors.Is(err, core.ErrUploaderBusy) || errors.Is(err, core.ErrUploadQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
+// errFixedWidthZip rejects a fixed-width template applied to a zip archive.
+const errFixedWidthZip = "fixed-width templates cannot be applied to zip archives; upload the files one at a time"
+
+// handlePreviewFixedWidth shows how a fixed-width layout slices the first
+// lines of a file, before a template is saved or a file is uploaded.
+func (s *Server) handlePreviewFixedWidth(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	// The layout comes from the request, or from a saved template
+	var layout core.FixedWidthLayout
+	if layoutJSON := r.FormValue("layout"); layoutJSON != "" {
+		if err := json.Unmarshal([]byte(layoutJSON), &layout); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid layout format")
+			return
+		}
+	} else {
+		tpl, err := s.uploadTemplate(r.Context(), tableKey, r.FormValue("template"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if tpl == nil || tpl.FixedWidth == nil {
+			writeError(w, http.StatusBadRequest, "layout or a fixed-width template is required")
+			return
+		}
+		layout = *tpl.FixedWidth
+	}
+
+	lines, _ := strconv.Atoi(r.FormValue("lines"))
+	preview, err := s.uploader.PreviewFixedWidth(file, layout, lines)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, preview)
+}
+
+// handleValidate checks a file against a table without touching the
+// database and returns a machine-readable report. A file that fails the
+// thresholds is still a 200; the report's "passed" says which.
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if tableKey == "" {
+		writeError(w, http.StatusBadRequest, "missing table key")
+		return
+	}
+
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	var mapping map[string]int
+	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid mapping format")
+			return
+		}
+	}
+
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Unset thresholds are disabled; with neither set, any error fails
+	thresholds := core.ValidationThresholds{MaxErrorRows: -1, MaxErrorPercent: -1}
+	if v := r.FormValue("maxErrors"); v != "" {
+		if thresholds.MaxErrorRows, err = strconv.Atoi(v); err != nil || thresholds.MaxErrorRows < 0 {
+			writeError(w, http.StatusBadRequest, "invalid maxErrors")
+			return
+		}
+	}
+	if v := r.FormValue("maxErrorPercent"); v != "" {
+		if thresholds.MaxErrorPercent, err = strconv.ParseFloat(v, 64); err != nil || thresholds.MaxErrorPercent < 0 {
+			writeError(w, http.StatusBadRequest, "invalid maxErrorPercent")
+			return
+		}
+	}
+
+	report, err := s.uploader.ValidateFile(tableKey, header.Filename, data, mapping, dateOpts, thresholds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleValidateValue checks one value for a column as an upload checks a
+// cell. An invalid value is still a 200; the result's "valid" says which.
+func (s *Server) handleValidateValue(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	column, err := url.PathUnescape(chi.URLParam(r, "column"))
+	if tableKey == "" || err != nil || column == "" {
+		writeError(w, http.StatusBadRequest, "missing table key or column")
+		return
+	}
+
+	var req struct {
+		Value      string `json:"value"`
+		DateFormat string `json:"dateFormat"`
+		YearPivot  int    `json:"yearPivot"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	dateOpts, err := core.NewDateOptions(req.DateFormat, req.YearPivot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := core.ValidateValue(tableKey, column, req.Value, dateOpts)
+	if err != nil {
+		if errors.Is(err, core.ErrUnknownColumn) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleUploadProgress streams upload progress via Server-Sent Events.
+// Each event's ID is the update's sequence number. A reconnecting client
+// sends the last ID it received, in the Last-Event-ID header or the
+// lastEventId query parameter, and is replayed the phase changes it missed
+// followed by the current progress.
+func (s *Server) handleUploadProgress(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "uploadID")
+	if uploadID == "" {
+		writeError(w, http.StatusBadRequest, "missing upload ID")
+		return
+	}
+
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("lastEventId")
+	}
+	var lastEventID int64
+	if lastEventIDStr != "" {
+		lastEventID, _ = strconv.ParseInt(lastEventIDStr, 10, 64)
+	}
+
+	progressCh, err := s.uploader.ResumeProgress(uploadID, lastEventID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	for {
+		select {
+		case progress, ok := <-progressCh:
+			if !ok {
+				// Channel closed - upload complete or cancelled
+				fmt.Fprintf(w, "event: complete\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+
+			data, _ := json.Marshal(progress)
+
+			// Include event ID for client-side tracking and resumption
+			fmt.Fprintf(w, "id: %d\nevent: progress\ndata: %s\n\n", progress.EventID, data)
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleOperationProgress streams step-based progress for any tracked
+// operation (an upload or upload batch) via Server-Sent Events. Each event
+// carries the full step list; the event ID is the overall percentage.
+func (s *Server) handleOperationProgress(w http.ResponseWriter, r *http.Request) {
+	operationID := chi.URLParam(r, "operationID")
+	if operationID == "" {
+		writeError(w, http.StatusBadRequest, "missing operation ID")
+		return
+	}
+
+	progressCh, err := s.service.SubscribeOperation(operationID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	for {
+		select {
+		case progress, ok := <-progressCh:
+			if !ok {
+				fmt.Fprintf(w, "event: complete\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			data, _ := json.Marshal(progress)
+			fmt.Fprintf(w, "id: %d\nevent: progress\ndata: %s\n\n", progress.Percent, data)
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleOperationStatus returns the recorded state of an operation, with its
+// step-based progress while it is still tracked.
+func (s *Server) handleOperationStatus(w http.ResponseWriter, r *http.Request) {
+	operationID := chi.URLParam(r, "operationID")
+	if operationID == "" {
+		writeError(w, http.StatusBadRequest, "missing operation ID")
+		return
+	}
+
+	rec, err := s.service.GetOperation(r.Context(), operationID)
+	if errors.Is(err, core.ErrOperationNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "no file provided")
+		slog.Error("failed to get operation", "operation_id", operationID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to get operation")
 		return
 	}
-	defer file.Close()
 
-	// Parse column mapping if provided
-	var mapping map[string]int
-	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
-		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid mapping format")
+	writeJSON(w, rec)
+}
+
+// handleCancelOperation stops a running background operation, such as a
+// reset, rollback or backfill.
+func (s *Server) handleCancelOperation(w http.ResponseWriter, r *http.Request) {
+	operationID := chi.URLParam(r, "operationID")
+	if operationID == "" {
+		writeError(w, http.StatusBadRequest, "missing operation ID")
+		return
+	}
+
+	err := s.service.CancelOperation(operationID)
+	if errors.Is(err, core.ErrOperationNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"cancelling"}`))
+}
+
+// handleListOperations returns recorded operations, newest first.
+func (s *Server) handleListOperations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := core.OperationFilter{
+		Kind:     q.Get("kind"),
+		Status:   core.OperationStatus(q.Get("status")),
+		TableKey: q.Get("table"),
+		ParentID: q.Get("parent"),
+		Limit:    parseIntParam(r, "limit", 50),
+		Offset:   parseIntParam(r, "offset", 0),
+	}
+	if v := q.Get("since"); v != "" {
+		t, ok := parseRangeBound(v, false)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid since date")
 			return
 		}
+		filter.Since = t
+	}
+	if v := q.Get("until"); v != "" {
+		t, ok := parseRangeBound(v, true)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid until date")
+			return
+		}
+		filter.Until = t
 	}
 
-	// Use streaming upload - pass file directly as io.Reader
-	// No io.ReadAll! Memory stays constant at O(batch_size) ~10MB
-	ctx := WithRequestMetadata(r.Context(), r)
-	uploadID, err := s.service.StartUploadStreaming(ctx, tableKey, header.Filename, file, header.Size, mapping)
+	ops, total, err := s.service.ListOperations(r.Context(), filter)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		slog.Error("failed to list operations", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to list operations")
 		return
 	}
 
-	writeJSON(w, map[string]string{"upload_id": uploadID})
+	writeJSON(w, map[string]any{
+		"operations": ops,
+		"total":      total,
+	})
 }
 
-// handlePreview analyzes a CSV file and returns what would happen on upload.
-func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
-	tableKey := chi.URLParam(r, "tableKey")
-	if tableKey == "" {
-		writeError(w, http.StatusBadRequest, "missing table key")
+// handleCancelUpload cancels an in-progress upload.
+func (s *Server) handleCancelUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "uploadID")
+	if uploadID == "" {
+		writeError(w, http.StatusBadRequest, "missing upload ID")
+		return
+	}
+
+	if err := s.uploader.CancelUpload(uploadID); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"cancelled"}`))
+}
+
+// handleUploadResult returns the final result of an upload.
+func (s *Server) handleUploadResult(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "uploadID")
+	if uploadID == "" {
+		writeError(w, http.StatusBadRequest, "missing upload ID")
+		return
+	}
+
+	result, err := s.uploader.GetUploadResult(uploadID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
+	writeJSON(w, toResponse(result))
+}
+
+// handleUploadBatch starts a batch of CSV uploads sent in one request.
+// Every "file" part is one upload; "table" is given once for all files or
+// once per file in the same order. Files are held in memory, so the
+// combined size is bounded by the upload size limit.
+func (s *Server) handleUploadBatch(w http.ResponseWriter, r *http.Request) {
 	maxSize := s.cfg.Upload.MaxFileSize
 	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 
@@ -75,139 +953,222 @@ func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, _, err := r.FormFile("file")
-	if err != nil {
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
 		writeError(w, http.StatusBadRequest, "no file provided")
 		return
 	}
-	defer file.Close()
+	tables := r.MultipartForm.Value["table"]
+	if len(tables) != 1 && len(tables) != len(headers) {
+		writeError(w, http.StatusBadRequest, "provide one table for all files or one per file")
+		return
+	}
 
-	data, err := io.ReadAll(file)
+	mode, err := core.ParseUploadMode(r.FormValue("mode"))
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to read file")
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(r.FormValue("duplicates"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	var mapping map[string]int
-	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
-		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid mapping format")
+	files := make([]core.BatchFile, len(headers))
+	for i, header := range headers {
+		data, err := readFormFile(header)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to read file")
 			return
 		}
+		tableKey := tables[0]
+		if len(tables) > 1 {
+			tableKey = tables[i]
+		}
+		files[i] = core.BatchFile{TableKey: tableKey, FileName: header.Filename, Data: data, Mode: mode, Duplicates: dups, Dates: dateOpts}
 	}
 
-	result, err := s.service.AnalyzeUpload(r.Context(), tableKey, data, mapping)
+	ctx := WithRequestMetadata(r.Context(), r)
+	batchID, uploadIDs, err := s.uploader.StartUploadBatch(ctx, files)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, uploadStartStatus(err), err.Error())
 		return
 	}
 
-	writeJSON(w, result)
+	writeJSON(w, map[string]any{"batch_id": batchID, "upload_ids": uploadIDs})
 }
 
-// handleUploadProgress streams upload progress via Server-Sent Events.
-// Supports resumption via lastEventId query parameter for reconnection.
-func (s *Server) handleUploadProgress(w http.ResponseWriter, r *http.Request) {
-	uploadID := chi.URLParam(r, "uploadID")
-	if uploadID == "" {
-		writeError(w, http.StatusBadRequest, "missing upload ID")
+// handleRoutedUpload splits one CSV file by a route column and uploads each
+// table's rows as a file of one upload batch.
+func (s *Server) handleRoutedUpload(w http.ResponseWriter, r *http.Request) {
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
 		return
 	}
 
-	// Support resumption from last event ID
-	// The event ID is the progress percentage, allowing clients to skip
-	// already-received events after reconnection
-	lastEventIDStr := r.URL.Query().Get("lastEventId")
-	var lastEventID int
-	if lastEventIDStr != "" {
-		lastEventID, _ = strconv.Atoi(lastEventIDStr)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
+
+	route := core.UploadRoute{
+		Column:  r.FormValue("route_column"),
+		Default: r.FormValue("default_table"),
+	}
+	if routesJSON := r.FormValue("routes"); routesJSON != "" {
+		if err := json.Unmarshal([]byte(routesJSON), &route.Tables); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid routes format")
+			return
+		}
 	}
 
-	progressCh, err := s.service.SubscribeProgress(uploadID)
+	mode, err := core.ParseUploadMode(r.FormValue("mode"))
 	if err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(r.FormValue("duplicates"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		writeError(w, http.StatusInternalServerError, "streaming not supported")
+	ctx := WithRequestMetadata(r.Context(), r)
+	result, err := s.uploader.StartRoutedUpload(ctx, header.Filename, data, route, mode, dups, dateOpts)
+	if err != nil {
+		writeError(w, uploadStartStatus(err), err.Error())
 		return
 	}
 
-	// Track event ID for resumption support
-	// Using progress percentage as event ID provides natural deduplication
-	eventID := lastEventID
+	writeJSON(w, result)
+}
 
-	for {
-		select {
-		case progress, ok := <-progressCh:
-			if !ok {
-				// Channel closed - upload complete or cancelled
-				fmt.Fprintf(w, "event: complete\ndata: {}\n\n")
-				flusher.Flush()
-				return
-			}
+// handleAttachToUploadBatch adds one file to an existing upload batch.
+func (s *Server) handleAttachToUploadBatch(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batchID")
+	if batchID == "" {
+		writeError(w, http.StatusBadRequest, "missing batch ID")
+		return
+	}
 
-			// Calculate current progress percentage for event ID
-			currentPercent := progress.Percent()
+	maxSize := s.cfg.Upload.MaxFileSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
 
-			// Skip events that were already sent (for resumption)
-			// Only skip if we have a lastEventId and current is not greater
-			if lastEventIDStr != "" && currentPercent <= lastEventID {
-				continue
-			}
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		writeError(w, http.StatusBadRequest, "file too large or invalid form")
+		return
+	}
 
-			eventID = currentPercent
-			data, _ := json.Marshal(progress)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "no file provided")
+		return
+	}
+	defer file.Close()
 
-			// Include event ID for client-side tracking and resumption
-			fmt.Fprintf(w, "id: %d\nevent: progress\ndata: %s\n\n", eventID, data)
-			flusher.Flush()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read file")
+		return
+	}
 
-		case <-r.Context().Done():
+	var mapping map[string]int
+	if mappingJSON := r.FormValue("mapping"); mappingJSON != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid mapping format")
 			return
 		}
 	}
-}
 
-// handleCancelUpload cancels an in-progress upload.
-func (s *Server) handleCancelUpload(w http.ResponseWriter, r *http.Request) {
-	uploadID := chi.URLParam(r, "uploadID")
-	if uploadID == "" {
-		writeError(w, http.StatusBadRequest, "missing upload ID")
+	mode, err := core.ParseUploadMode(r.FormValue("mode"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dups, err := core.ParseDuplicatePolicy(r.FormValue("duplicates"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dateOpts, err := core.ParseDateOptions(r.FormValue("date_format"), r.FormValue("year_pivot"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := s.service.CancelUpload(uploadID); err != nil {
+	ctx := WithRequestMetadata(r.Context(), r)
+	uploadID, err := s.uploader.AttachToUploadBatch(ctx, batchID, core.BatchFile{
+		TableKey:   r.FormValue("table"),
+		FileName:   header.Filename,
+		Data:       data,
+		Mapping:    mapping,
+		Mode:       mode,
+		Duplicates: dups,
+		Dates:      dateOpts,
+	})
+	if errors.Is(err, core.ErrUploadBatchNotFound) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if err != nil {
+		writeError(w, uploadStartStatus(err), err.Error())
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(`{"status":"cancelled"}`))
+	writeJSON(w, map[string]string{"batch_id": batchID, "upload_id": uploadID})
 }
 
-// handleUploadResult returns the final result of an upload.
-func (s *Server) handleUploadResult(w http.ResponseWriter, r *http.Request) {
-	uploadID := chi.URLParam(r, "uploadID")
-	if uploadID == "" {
-		writeError(w, http.StatusBadRequest, "missing upload ID")
+// handleUploadBatchStatus returns per-file and aggregated batch progress.
+func (s *Server) handleUploadBatchStatus(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batchID")
+	if batchID == "" {
+		writeError(w, http.StatusBadRequest, "missing batch ID")
 		return
 	}
 
-	result, err := s.service.GetUploadResult(uploadID)
-	if err != nil {
+	status, err := s.uploader.GetUploadBatch(r.Context(), batchID)
+	if errors.Is(err, core.ErrUploadBatchNotFound) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	writeJSON(w, toResponse(result))
+	writeJSON(w, status)
+}
+
+// readFormFile reads an uploaded multipart file into memory.
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
 }
 
 // handleUploadHistory returns the upload history for a table as HTML.
@@ -218,7 +1179,7 @@ func (s *Server) handleUploadHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	history, err := s.service.GetUploadHistory(r.Context(), tableKey)
+	history, err := s.tables.GetUploadHistory(r.Context(), tableKey)
 	if err != nil {
 		history = nil
 	}
@@ -226,6 +1187,23 @@ func (s *Server) handleUploadHistory(w http.ResponseWriter, r *http.Request) {
 	templates.UploadHistory(history).Render(r.Context(), w)
 }
 
+// handleKeyViolations returns the unique key violations of a table's recent
+// uploads, oldest first.
+func (s *Server) handleKeyViolations(w http.ResponseWriter, r *http.Request) {
+	tableKey := chi.URLParam(r, "tableKey")
+	if _, ok := core.Get(tableKey); !ok {
+		writeError(w, http.StatusNotFound, "table not found")
+		return
+	}
+
+	trend, err := s.tables.GetKeyViolationTrend(r.Context(), tableKey, parseIntParam(r, "limit", 0))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, trend)
+}
+
 // handleExportFailedRows exports failed rows from an upload as CSV.
 func (s *Server) handleExportFailedRows(w http.ResponseWriter, r *http.Request) {
 	uploadID := chi.URLParam(r, "uploadID")
@@ -234,7 +1212,7 @@ func (s *Server) handleExportFailedRows(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	upload, err := s.service.GetUploadWithHeaders(r.Context(), uploadID)
+	upload, err := s.tables.GetUploadWithHeaders(r.Context(), uploadID)
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -245,7 +1223,7 @@ func (s *Server) handleExportFailedRows(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	failedRows, err := s.service.GetFailedRows(r.Context(), uploadID)
+	failedRows, err := s.tables.GetFailedRows(r.Context(), uploadID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -270,6 +1248,54 @@ func (s *Server) handleExportFailedRows(w http.ResponseWriter, r *http.Request)
 	}
 
 	csvWriter.Flush()
+	s.auditor.LogExport(WithRequestMetadata(r.Context(), r), core.ExportRecord{
+		Kind:     core.ExportFailedRows,
+		TableKey: upload.TableKey,
+		UploadID: upload.ID,
+		Format:   "csv",
+		FileName: filename,
+		Rows:     len(failedRows),
+		Err:      csvWriter.Error(),
+	})
+}
+
+// handleUploadEvidence downloads an upload's evidence package: a zip of its
+// summary, failed rows, audit entries and their checksums.
+func (s *Server) handleUploadEvidence(w http.ResponseWriter, r *http.Request) {
+	uploadID := chi.URLParam(r, "uploadID")
+	if uploadID == "" {
+		writeError(w, http.StatusBadRequest, "missing upload ID")
+		return
+	}
+
+	evidence, err := s.service.GetUploadEvidence(r.Context(), uploadID)
+	if err != nil {
+		if strings.Contains(err.Error(), "upload not found") || strings.Contains(err.Error(), "invalid upload ID") {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		slog.Error("failed to gather upload evidence", "upload_id", uploadID, "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to gather upload evidence")
+		return
+	}
+
+	filename := fmt.Sprintf("evidence_%s_%s.zip", uploadID, time.Now().Format("20060102_150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	err = evidence.WriteZip(w)
+	if err != nil {
+		slog.Error("failed to write upload evidence", "upload_id", uploadID, "error", err)
+	}
+	s.auditor.LogExport(WithRequestMetadata(r.Context(), r), core.ExportRecord{
+		Kind:     core.ExportUploadEvidence,
+		TableKey: evidence.Summary.TableKey,
+		UploadID: uploadID,
+		Format:   "zip",
+		FileName: filename,
+		Rows:     len(evidence.FailedRows),
+		Err:      err,
+	})
 }
 
 // handleUploadDetail renders the upload detail page showing inserted/skipped rows.
@@ -280,7 +1306,7 @@ func (s *Server) handleUploadDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	upload, err := s.service.GetUploadDetail(r.Context(), uploadID)
+	upload, err := s.tables.GetUploadDetail(r.Context(), uploadID)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "upload not found")
 		return
@@ -310,7 +1336,7 @@ func (s *Server) handleUploadDetail(w http.ResponseWriter, r *http.Request) {
 
 	switch status {
 	case "skipped":
-		failedRows, totalFailed, err := s.service.GetUploadFailedRowsPaginated(r.Context(), uploadID, page, pageSize)
+		failedRows, totalFailed, err := s.tables.GetUploadFailedRowsPaginated(r.Context(), uploadID, page, pageSize)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -319,7 +1345,7 @@ func (s *Server) handleUploadDetail(w http.ResponseWriter, r *http.Request) {
 		params.TotalRows = totalFailed
 		params.TotalPages = int((totalFailed + int64(pageSize) - 1) / int64(pageSize))
 	default:
-		result, err := s.service.GetUploadInsertedRows(r.Context(), uploadID, upload.TableKey, page, pageSize)
+		result, err := s.tables.GetUploadInsertedRows(r.Context(), uploadID, upload.TableKey, page, pageSize)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -8,10 +8,27 @@ import (
 	"github.com/JonMunkholm/TUI/internal/config"
 )
 
+// DenialFunc is called when middleware rejects a request, e.g. to record it
+// in the audit log. code is the error code sent to the client and reason a
+// short explanation. It runs synchronously before the response is written.
+type DenialFunc func(r *http.Request, code, reason string)
+
+// deny calls f if it is non-nil.
+func (f DenialFunc) deny(r *http.Request, code, reason string) {
+	if f != nil {
+		f(r, code, reason)
+	}
+}
+
 // APIKeyAuth returns middleware that validates X-API-Key header against configured keys.
 // If RequireAPIKey is false, all requests pass through.
 // If RequireAPIKey is true but no keys are configured, all requests are rejected.
-func APIKeyAuth(cfg *config.SecurityConfig) func(http.Handler) http.Handler {
+// If lockout is non-nil, failed attempts are tracked per client IP and
+// repeat offenders are throttled with 429 before their key is checked. Only
+// a wrong key counts as a failure: requests without one, such as anonymous
+// probes, would otherwise lock out everyone behind a shared IP.
+// Rejections are reported to onDeny, which may be nil.
+func APIKeyAuth(cfg *config.SecurityConfig, lockout *AuthLockout, onDeny DenialFunc) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip validation if auth is disabled
@@ -20,6 +37,23 @@ func APIKeyAuth(cfg *config.SecurityConfig) func(http.Handler) http.Handler {
 				return
 			}
 
+			ip := clientIP(r)
+			if wait, locked := lockout.check(ip); wait > 0 {
+				slog.Warn("auth: throttled client",
+					"path", r.URL.Path,
+					"method", r.Method,
+					"remote_addr", r.RemoteAddr,
+					"locked", locked,
+				)
+				if locked {
+					onDeny.deny(r, "AUTH_LOCKED", "client locked out after failed API key attempts")
+				} else {
+					onDeny.deny(r, "AUTH_THROTTLED", "API key attempts too frequent")
+				}
+				rejectThrottled(w, wait, locked)
+				return
+			}
+
 			// Get API key from header
 			apiKey := r.Header.Get("X-API-Key")
 			if apiKey == "" {
@@ -28,26 +62,42 @@ func APIKeyAuth(cfg *config.SecurityConfig) func(http.Handler) http.Handler {
 					"method", r.Method,
 					"remote_addr", r.RemoteAddr,
 				)
+				onDeny.deny(r, "AUTH_MISSING_KEY", "missing API key")
 				http.Error(w, `{"error":"missing API key","code":"AUTH_MISSING_KEY"}`, http.StatusUnauthorized)
 				return
 			}
 
 			// Validate against configured keys
-			if !isValidAPIKey(apiKey, cfg.APIKeys) {
+			validKeys := cfg.APIKeys
+			if cfg.APIKeyProvider != nil {
+				validKeys = cfg.APIKeyProvider()
+			}
+			if !isValidAPIKey(apiKey, validKeys) {
+				lockout.recordFailure(r, ip)
 				slog.Warn("auth: invalid API key",
 					"path", r.URL.Path,
 					"method", r.Method,
 					"remote_addr", r.RemoteAddr,
 				)
+				onDeny.deny(r, "AUTH_INVALID_KEY", "invalid API key")
 				http.Error(w, `{"error":"invalid API key","code":"AUTH_INVALID_KEY"}`, http.StatusForbidden)
 				return
 			}
 
+			lockout.succeed(ip)
+
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// HasAPIKey reports whether the request's X-API-Key header is one of keys.
+// Used for role checks on top of APIKeyAuth, e.g. reviewer keys.
+func HasAPIKey(r *http.Request, keys []string) bool {
+	key := r.Header.Get("X-API-Key")
+	return key != "" && isValidAPIKey(key, keys)
+}
+
 // isValidAPIKey checks if the provided key matches any configured key.
 // Uses constant-time comparison and checks ALL keys to prevent timing attacks.
 // The comparison time is constant regardless of which key matches (or none).
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header clients use to mark retries of
+// the same logical operation.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotentBody is the largest response body that is stored for replay.
+// Larger responses are passed through and not deduplicated.
+const maxIdempotentBody = 1 << 20
+
+// idempotencyEntry is a stored (or in-flight) response for one key.
+type idempotencyEntry struct {
+	done    chan struct{} // Closed when the first request finishes
+	status  int
+	header  http.Header
+	body    []byte
+	stored  bool // False if the response was not cacheable
+	expires time.Time
+}
+
+// idempotencyStore holds responses keyed by API key, method, path, and
+// Idempotency-Key.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+// Idempotency returns middleware that deduplicates mutating requests carrying
+// an Idempotency-Key header. The first request runs normally and its
+// response (status < 500, body up to 1MB) is kept for ttl; repeats with the
+// same key replay it with an "Idempotent-Replayed: true" header instead of
+// running the handler again. A repeat that arrives while the first request
+// is still running waits for it. Requests without the header, and GET/HEAD
+// requests, pass through untouched.
+func Idempotency(ttl time.Duration) func(http.Handler) http.Handler {
+	store := &idempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+		ttl:     ttl,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || ttl <= 0 || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			storeKey := r.Header.Get("X-API-Key") + "\x00" + r.Method + "\x00" + r.URL.Path + "\x00" + key
+			entry, owner := store.acquire(storeKey)
+
+			if !owner {
+				select {
+				case <-entry.done:
+				case <-r.Context().Done():
+					return
+				}
+				if entry.stored {
+					replay(w, entry)
+					return
+				}
+				// First attempt failed or was not cacheable - run again
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// A panicking handler must not leave a stored 200 behind
+				store.finish(storeKey, entry, rec, completed)
+			}()
+			next.ServeHTTP(rec, r)
+			completed = true
+		})
+	}
+}
+
+// acquire returns the entry for key, creating it if absent or expired.
+// owner is true if the caller created the entry and must run the handler.
+func (s *idempotencyStore) acquire(key string) (*idempotencyEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > s.ttl/2 {
+		for k, e := range s.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if e, ok := s.entries[key]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e, false
+	}
+
+	e := &idempotencyEntry{done: make(chan struct{})}
+	s.entries[key] = e
+	return e, true
+}
+
+// finish records the owner's response and releases waiting duplicates.
+// Server errors and oversized bodies are not stored, so a retry runs again.
+func (s *idempotencyStore) finish(key string, e *idempotencyEntry, rec *recordingWriter, completed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if completed && rec.status < 500 && !rec.overflow {
+		e.status = rec.status
+		e.header = rec.Header().Clone()
+		// Encoding is re-applied by outer middleware on replay
+		e.header.Del("Content-Encoding")
+		e.header.Del("Content-Length")
+		e.header.Del("Vary")
+		e.body = rec.buf.Bytes()
+		e.stored = true
+		e.expires = time.Now().Add(s.ttl)
+	} else {
+		delete(s.entries, key)
+	}
+	close(e.done)
+}
+
+// replay writes a stored response.
+func replay(w http.ResponseWriter, e *idempotencyEntry) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	overflow    bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > maxIdempotentBody {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap provides access to the underlying ResponseWriter.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
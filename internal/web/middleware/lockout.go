@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Progressive delay: after lockoutDelayAfter failures, each further attempt
+// must wait lockoutBaseDelay, doubling per failure up to lockoutMaxDelay.
+const (
+	lockoutDelayAfter = 3
+	lockoutBaseDelay  = time.Second
+	lockoutMaxDelay   = 30 * time.Second
+)
+
+// LockoutConfig configures brute-force protection for API key auth.
+type LockoutConfig struct {
+	MaxFailures int           // Failures within Window that trigger a lockout; 0 disables
+	Window      time.Duration // How long failures are remembered
+	Duration    time.Duration // How long a lockout lasts
+
+	// OnLockout is called (outside the lock) when a client becomes locked
+	// out; key is its IP, or what the failures were counted under (see
+	// FailKey).
+	OnLockout func(r *http.Request, key string, failures int)
+}
+
+// AuthLockout tracks failed API key attempts per client IP and throttles
+// repeat offenders: first with increasing delays between attempts, then with
+// a temporary lockout. A locked-out client is rejected even with a valid key.
+// Failures may also be counted under another key, such as an account, so
+// attempts spread over many IPs are caught too.
+type AuthLockout struct {
+	mu      sync.Mutex
+	cfg     LockoutConfig
+	clients map[string]*lockoutClient
+	now     func() time.Time
+}
+
+type lockoutClient struct {
+	failures    int
+	firstFail   time.Time
+	lastFail    time.Time
+	lockedUntil time.Time
+}
+
+// LockoutStatus describes a tracked client for the admin API.
+type LockoutStatus struct {
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"lastFailure"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// NewAuthLockout creates a lockout tracker. Returns nil if cfg.MaxFailures
+// is 0; a nil *AuthLockout is valid and never blocks.
+func NewAuthLockout(cfg LockoutConfig) *AuthLockout {
+	if cfg.MaxFailures <= 0 {
+		return nil
+	}
+	return &AuthLockout{
+		cfg:     cfg,
+		clients: make(map[string]*lockoutClient),
+		now:     time.Now,
+	}
+}
+
+// check reports how long key must wait before its next attempt (0 if
+// allowed) and whether it is locked out rather than merely delayed.
+func (l *AuthLockout) check(key string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.clients[key]
+	if !ok {
+		return 0, false
+	}
+	now := l.now()
+	if now.Before(c.lockedUntil) {
+		return c.lockedUntil.Sub(now), true
+	}
+	if wait := c.lastFail.Add(progressiveDelay(c.failures)).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
+
+// fail records a failed attempt under key and reports whether it
+// triggered a lockout.
+func (l *AuthLockout) fail(key string) (int, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	c, ok := l.clients[key]
+	if !ok || (now.Sub(c.firstFail) > l.cfg.Window && now.After(c.lockedUntil)) {
+		c = &lockoutClient{firstFail: now}
+		l.clients[key] = c
+	}
+	c.failures++
+	c.lastFail = now
+
+	if c.failures >= l.cfg.MaxFailures && !now.Before(c.lockedUntil) {
+		c.lockedUntil = now.Add(l.cfg.Duration)
+		return c.failures, true
+	}
+	return c.failures, false
+}
+
+// recordFailure records a failed attempt under key and fires OnLockout if
+// it locked key out.
+func (l *AuthLockout) recordFailure(r *http.Request, key string) {
+	failures, locked := l.fail(key)
+	if !locked {
+		return
+	}
+	slog.Warn("auth: client locked out",
+		"remote_addr", r.RemoteAddr,
+		"key", key,
+		"failures", failures,
+		"duration", l.cfg.Duration,
+	)
+	if l.cfg.OnLockout != nil {
+		l.cfg.OnLockout(r, key, failures)
+	}
+}
+
+// succeed clears the failure history of key.
+func (l *AuthLockout) succeed(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	delete(l.clients, key)
+	l.mu.Unlock()
+}
+
+// Throttle writes a 429 and returns true if r's client must wait before
+// another authentication attempt. Throttle, Fail and Succeed apply the
+// lockout to credentials checked outside APIKeyAuth, e.g. logins.
+func (l *AuthLockout) Throttle(w http.ResponseWriter, r *http.Request) bool {
+	wait, locked := l.check(clientIP(r))
+	if wait <= 0 {
+		return false
+	}
+	rejectThrottled(w, wait, locked)
+	return true
+}
+
+// Fail records a failed authentication attempt by r's client.
+func (l *AuthLockout) Fail(r *http.Request) {
+	l.recordFailure(r, clientIP(r))
+}
+
+// Succeed clears the failure history of r's client.
+func (l *AuthLockout) Succeed(r *http.Request) {
+	l.succeed(clientIP(r))
+}
+
+// ThrottleKey, FailKey and SucceedKey are Throttle, Fail and Succeed for
+// attempts counted under key, e.g. the account a login is for, rather than
+// by r's client.
+func (l *AuthLockout) ThrottleKey(w http.ResponseWriter, key string) bool {
+	wait, locked := l.check(key)
+	if wait <= 0 {
+		return false
+	}
+	rejectThrottled(w, wait, locked)
+	return true
+}
+
+// FailKey records a failed attempt under key; see ThrottleKey.
+func (l *AuthLockout) FailKey(r *http.Request, key string) {
+	l.recordFailure(r, key)
+}
+
+// SucceedKey clears the failure history of key; see ThrottleKey.
+func (l *AuthLockout) SucceedKey(key string) {
+	l.succeed(key)
+}
+
+// Unlock clears the failure history and any lockout of ip.
+// Returns false if ip was not tracked.
+func (l *AuthLockout) Unlock(ip string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.clients[ip]
+	delete(l.clients, ip)
+	return ok
+}
+
+// Status lists tracked clients, locked-out clients first.
+func (l *AuthLockout) Status() []LockoutStatus {
+	if l == nil {
+		return []LockoutStatus{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	out := make([]LockoutStatus, 0, len(l.clients))
+	for ip, c := range l.clients {
+		st := LockoutStatus{IP: ip, Failures: c.failures, LastFailure: c.lastFail}
+		if now.Before(c.lockedUntil) {
+			until := c.lockedUntil
+			st.LockedUntil = &until
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].LockedUntil != nil) != (out[j].LockedUntil != nil) {
+			return out[i].LockedUntil != nil
+		}
+		return out[i].IP < out[j].IP
+	})
+	return out
+}
+
+// sweep drops clients whose failures and lockout have both expired.
+// Caller must hold l.mu.
+func (l *AuthLockout) sweep(now time.Time) {
+	for ip, c := range l.clients {
+		if now.Sub(c.lastFail) > l.cfg.Window && !now.Before(c.lockedUntil) {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// progressiveDelay returns the minimum wait after the given failure count.
+func progressiveDelay(failures int) time.Duration {
+	if failures < lockoutDelayAfter {
+		return 0
+	}
+	exp := failures - lockoutDelayAfter
+	if exp > 5 {
+		return lockoutMaxDelay
+	}
+	return min(lockoutBaseDelay<<exp, lockoutMaxDelay)
+}
+
+// clientIP returns the client IP of r without the port, for use as a key.
+func clientIP(r *http.Request) string {
+	if ip := extractIP(r.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// rejectThrottled writes a 429 for a delayed or locked-out client.
+func rejectThrottled(w http.ResponseWriter, wait time.Duration, locked bool) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	if locked {
+		http.Error(w, `{"error":"too many failed authentication attempts","code":"AUTH_LOCKED"}`, http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, `{"error":"authentication attempts too frequent","code":"AUTH_THROTTLED"}`, http.StatusTooManyRequests)
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+// testLockout returns a lockout on a clock that only moves when advance is
+// called.
+func testLockout(cfg LockoutConfig) (*AuthLockout, func(time.Duration)) {
+	l := NewAuthLockout(cfg)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestAuthLockout_ProgressiveDelay(t *testing.T) {
+	l, advance := testLockout(LockoutConfig{MaxFailures: 100, Window: time.Hour, Duration: time.Hour})
+
+	for i := 1; i < lockoutDelayAfter; i++ {
+		l.fail("ip")
+		if wait, _ := l.check("ip"); wait != 0 {
+			t.Fatalf("after %d failures: wait %v, want none", i, wait)
+		}
+	}
+
+	// Each further failure doubles the delay, up to lockoutMaxDelay
+	want := lockoutBaseDelay
+	for i := lockoutDelayAfter; i < lockoutDelayAfter+8; i++ {
+		l.fail("ip")
+		wait, locked := l.check("ip")
+		if wait != want || locked {
+			t.Fatalf("after %d failures: wait %v locked %v, want %v unlocked", i, wait, locked, want)
+		}
+		advance(wait)
+		if wait, _ := l.check("ip"); wait != 0 {
+			t.Fatalf("after %d failures and the delay: wait %v, want none", i, wait)
+		}
+		want = min(want*2, lockoutMaxDelay)
+	}
+}
+
+func TestAuthLockout_LockoutExpires(t *testing.T) {
+	var lockouts []int
+	l, advance := testLockout(LockoutConfig{
+		MaxFailures: 3,
+		Window:      time.Hour,
+		Duration:    10 * time.Minute,
+		OnLockout:   func(_ *http.Request, _ string, failures int) { lockouts = append(lockouts, failures) },
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for range 3 {
+		l.recordFailure(r, "ip")
+	}
+	wait, locked := l.check("ip")
+	if wait != 10*time.Minute || !locked {
+		t.Fatalf("wait %v locked %v, want a 10m lockout", wait, locked)
+	}
+	if len(lockouts) != 1 || lockouts[0] != 3 {
+		t.Errorf("OnLockout calls = %v, want one after 3 failures", lockouts)
+	}
+
+	// Another failure while locked out doesn't extend the lockout
+	advance(time.Minute)
+	l.recordFailure(r, "ip")
+	if wait, _ := l.check("ip"); wait != 9*time.Minute {
+		t.Errorf("wait %v after a failure while locked, want 9m", wait)
+	}
+	if len(lockouts) != 1 {
+		t.Errorf("OnLockout calls = %v, want one", lockouts)
+	}
+
+	advance(9 * time.Minute)
+	if wait, locked := l.check("ip"); wait != 0 || locked {
+		t.Errorf("wait %v locked %v after the lockout, want none", wait, locked)
+	}
+}
+
+func TestAuthLockout_SucceedResets(t *testing.T) {
+	l, _ := testLockout(LockoutConfig{MaxFailures: 5, Window: time.Hour, Duration: time.Hour})
+
+	for range 4 {
+		l.fail("ip")
+	}
+	l.succeed("ip")
+	if wait, _ := l.check("ip"); wait != 0 {
+		t.Errorf("wait %v after success, want none", wait)
+	}
+	if failures, _ := l.fail("ip"); failures != 1 {
+		t.Errorf("failures = %d after success, want the count to restart", failures)
+	}
+	if len(l.Status()) != 1 {
+		t.Errorf("status = %v, want one client", l.Status())
+	}
+}
+
+func TestAuthLockout_WindowExpires(t *testing.T) {
+	l, advance := testLockout(LockoutConfig{MaxFailures: 3, Window: time.Minute, Duration: time.Hour})
+
+	l.fail("ip")
+	l.fail("ip")
+	advance(2 * time.Minute)
+	if failures, locked := l.fail("ip"); failures != 1 || locked {
+		t.Errorf("failures %d locked %v after the window, want the count to restart", failures, locked)
+	}
+}
+
+func TestAuthLockout_ThrottleFailSucceed(t *testing.T) {
+	l, advance := testLockout(LockoutConfig{MaxFailures: 100, Window: time.Hour, Duration: time.Hour})
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	throttled := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if !l.Throttle(w, r) {
+			return nil
+		}
+		return w
+	}
+
+	for range lockoutDelayAfter {
+		if w := throttled(); w != nil {
+			t.Fatalf("throttled before %d failures: %d", lockoutDelayAfter, w.Code)
+		}
+		l.Fail(r)
+	}
+	w := throttled()
+	if w == nil || w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("after %d failures: %v, want 429 with Retry-After", lockoutDelayAfter, w)
+	}
+
+	// The delay passes, and a success clears the history
+	advance(lockoutBaseDelay)
+	if w := throttled(); w != nil {
+		t.Fatalf("throttled after the delay: %d", w.Code)
+	}
+	l.Fail(r)
+	l.Succeed(r)
+	if w := throttled(); w != nil {
+		t.Errorf("throttled after success: %d", w.Code)
+	}
+}
+
+func TestAuthLockout_Keys(t *testing.T) {
+	var locked []string
+	l, _ := testLockout(LockoutConfig{
+		MaxFailures: 3,
+		Window:      time.Hour,
+		Duration:    time.Hour,
+		OnLockout:   func(_ *http.Request, key string, _ int) { locked = append(locked, key) },
+	})
+
+	// Failures for one account from different clients add up
+	for i := range 3 {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", i+1)
+		l.FailKey(r, "ada@example.com")
+	}
+	w := httptest.NewRecorder()
+	if !l.ThrottleKey(w, "ada@example.com") || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("account not locked out after 3 failures: %d", w.Code)
+	}
+	if len(locked) != 1 || locked[0] != "ada@example.com" {
+		t.Errorf("OnLockout keys = %v, want the account", locked)
+	}
+	if l.ThrottleKey(httptest.NewRecorder(), "bob@example.com") {
+		t.Error("another account is throttled")
+	}
+
+	l.SucceedKey("ada@example.com")
+	if l.ThrottleKey(httptest.NewRecorder(), "ada@example.com") {
+		t.Error("account still throttled after success")
+	}
+}
+
+func TestAuthLockout_Nil(t *testing.T) {
+	if l := NewAuthLockout(LockoutConfig{}); l != nil {
+		t.Fatal("expected nil lockout when MaxFailures is 0")
+	}
+	var l *AuthLockout
+	l.fail("ip")
+	l.succeed("ip")
+	if wait, locked := l.check("ip"); wait != 0 || locked {
+		t.Errorf("nil lockout: wait %v locked %v", wait, locked)
+	}
+}
+
+func TestAPIKeyAuth_Lockout(t *testing.T) {
+	l, _ := testLockout(LockoutConfig{MaxFailures: 2, Window: time.Hour, Duration: time.Hour})
+	cfg := &config.SecurityConfig{RequireAPIKey: true, APIKeys: []string{"good"}}
+	h := APIKeyAuth(cfg, l, nil)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(key string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/tables", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Requests without a key don't count toward a lockout
+	for range 5 {
+		if code := serve(""); code != http.StatusUnauthorized {
+			t.Fatalf("no key: status %d, want 401", code)
+		}
+	}
+	if code := serve("good"); code != http.StatusOK {
+		t.Fatalf("good key after missing keys: status %d, want 200", code)
+	}
+
+	// Wrong keys do, and then even the right key is rejected
+	for range 2 {
+		if code := serve("bad"); code != http.StatusForbidden {
+			t.Fatalf("bad key: status %d, want 403", code)
+		}
+	}
+	if code := serve("good"); code != http.StatusTooManyRequests {
+		t.Errorf("good key after lockout: status %d, want 429", code)
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/JonMunkholm/TUI/internal/config"
+)
+
+// NetworkInfo describes where a client IP originates.
+type NetworkInfo struct {
+	Country string // ISO 3166-1 alpha-2, e.g. "US"
+	ASN     uint32 // Autonomous system number, 0 if unknown
+}
+
+// IPResolver looks up the country and ASN of a client IP, typically backed
+// by a GeoIP database. Implementations must be safe for concurrent use.
+type IPResolver interface {
+	Resolve(ctx context.Context, ip net.IP) (NetworkInfo, error)
+}
+
+// IPResolverFunc adapts a function to the IPResolver interface.
+type IPResolverFunc func(ctx context.Context, ip net.IP) (NetworkInfo, error)
+
+// Resolve calls f(ctx, ip).
+func (f IPResolverFunc) Resolve(ctx context.Context, ip net.IP) (NetworkInfo, error) {
+	return f(ctx, ip)
+}
+
+// ErrNoResolver is returned by resolvers that are not configured.
+var ErrNoResolver = errors.New("no IP resolver configured")
+
+// NetworkPolicy returns middleware that restricts requests by client network,
+// independent of API keys. The client IP is r.RemoteAddr, so this must run
+// after TrustedRealIP.
+//
+//   - If DestructiveAllowCIDRs is set, the client must be inside one of them.
+//   - If DestructiveDenyCountries or DestructiveDenyASNs is set, the client
+//     is looked up with resolver and rejected on a match. Lookup failures
+//     reject the request (fail closed).
+//
+// With no policy configured, all requests pass through. Rejections are
+// reported to onDeny, which may be nil.
+func NetworkPolicy(cfg *config.SecurityConfig, resolver IPResolver, onDeny DenialFunc) func(http.Handler) http.Handler {
+	allowNets := parseNetworks(cfg.DestructiveAllowCIDRs, "netpolicy: invalid allow CIDR, skipping")
+
+	denyCountries := make(map[string]bool, len(cfg.DestructiveDenyCountries))
+	for _, cc := range cfg.DestructiveDenyCountries {
+		denyCountries[strings.ToUpper(cc)] = true
+	}
+	denyASNs := make(map[uint32]bool, len(cfg.DestructiveDenyASNs))
+	for _, s := range cfg.DestructiveDenyASNs {
+		if asn, err := config.ParseASN(s); err == nil {
+			denyASNs[asn] = true
+		}
+	}
+	needsLookup := len(denyCountries) > 0 || len(denyASNs) > 0
+
+	return func(next http.Handler) http.Handler {
+		if len(allowNets) == 0 && !needsLookup {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := extractIP(r.RemoteAddr)
+
+			if len(allowNets) > 0 && !isTrusted(ip, allowNets) {
+				denyNetwork(w, r, onDeny, "not in allowlist")
+				return
+			}
+
+			if needsLookup {
+				if ip == nil {
+					denyNetwork(w, r, onDeny, "unparseable client address")
+					return
+				}
+				info, err := resolver.Resolve(r.Context(), ip)
+				if err != nil {
+					slog.Error("netpolicy: IP lookup failed",
+						"remote_addr", r.RemoteAddr,
+						"error", err,
+					)
+					denyNetwork(w, r, onDeny, "lookup failed")
+					return
+				}
+				if denyCountries[strings.ToUpper(info.Country)] {
+					denyNetwork(w, r, onDeny, "country "+info.Country+" denied")
+					return
+				}
+				if denyASNs[info.ASN] {
+					denyNetwork(w, r, onDeny, "ASN denied")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// denyNetwork logs and rejects a request blocked by network policy.
+func denyNetwork(w http.ResponseWriter, r *http.Request, onDeny DenialFunc, reason string) {
+	slog.Warn("netpolicy: request denied",
+		"path", r.URL.Path,
+		"method", r.Method,
+		"remote_addr", r.RemoteAddr,
+		"reason", reason,
+	)
+	onDeny.deny(r, "AUTH_NETWORK_DENIED", reason)
+	http.Error(w, `{"error":"request not allowed from this network","code":"AUTH_NETWORK_DENIED"}`, http.StatusForbidden)
+}
@@ -16,32 +16,7 @@ import (
 // X-Real-IP headers to bypass rate limiting or audit logging.
 func TrustedRealIP(trustedCIDRs []string) func(http.Handler) http.Handler {
 	// Parse trusted CIDRs once at startup
-	var trustedNets []*net.IPNet
-	for _, cidr := range trustedCIDRs {
-		cidr = strings.TrimSpace(cidr)
-		if cidr == "" {
-			continue
-		}
-
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			// Try parsing as single IP (e.g., "127.0.0.1" instead of "127.0.0.1/32")
-			if ip := net.ParseIP(cidr); ip != nil {
-				mask := net.CIDRMask(128, 128)
-				if ip.To4() != nil {
-					mask = net.CIDRMask(32, 32)
-				}
-				trustedNets = append(trustedNets, &net.IPNet{IP: ip, Mask: mask})
-			} else {
-				slog.Warn("realip: invalid trusted proxy CIDR, skipping",
-					"cidr", cidr,
-					"error", err,
-				)
-			}
-			continue
-		}
-		trustedNets = append(trustedNets, network)
-	}
+	trustedNets := parseNetworks(trustedCIDRs, "realip: invalid trusted proxy CIDR, skipping")
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +53,38 @@ func TrustedRealIP(trustedCIDRs []string) func(http.Handler) http.Handler {
 	}
 }
 
+// parseNetworks parses CIDRs, accepting bare IPs as single-host networks.
+// Invalid entries are logged with msg and skipped.
+func parseNetworks(cidrs []string, msg string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			// Try parsing as single IP (e.g., "127.0.0.1" instead of "127.0.0.1/32")
+			if ip := net.ParseIP(cidr); ip != nil {
+				mask := net.CIDRMask(128, 128)
+				if ip.To4() != nil {
+					mask = net.CIDRMask(32, 32)
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: mask})
+			} else {
+				slog.Warn(msg,
+					"cidr", cidr,
+					"error", err,
+				)
+			}
+			continue
+		}
+		nets = append(nets, network)
+	}
+	return nets
+}
+
 // extractIP parses an IP address from a host:port string or plain IP.
 func extractIP(addr string) net.IP {
 	// Handle "host:port" format
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"sync"
@@ -26,24 +27,94 @@ var staticFiles embed.FS
 
 // Server is the HTTP server for the CSV import application.
 type Server struct {
-	service *core.Service
-	cfg     *config.Config
-	router  *chi.Mux
-	server  *http.Server
+	service    *core.Service // Operations outside the interfaces below
+	cfg        *config.Config
+	router     *chi.Mux
+	server     *http.Server
+	ipResolver mw.IPResolver   // Optional; backs country/ASN deny lists
+	lockout    *mw.AuthLockout // Failed API key tracking; nil if disabled
+	accounts   *mw.AuthLockout // Failed logins per account; nil if disabled
+	denials    *repeatFilter   // Suppresses repeated rate-limit denial audits
+
+	// Handlers reach uploads, queries, audit and templates only through
+	// these interfaces; see SetServices.
+	uploader      core.Uploader
+	tables        core.TableQuerier
+	auditor       core.Auditor
+	templateStore core.TemplateStore
+}
+
+// Services are the implementations handlers use for uploads, queries,
+// audit and templates.
+type Services struct {
+	Uploader      core.Uploader
+	Tables        core.TableQuerier
+	Auditor       core.Auditor
+	TemplateStore core.TemplateStore
 }
 
 // NewServer creates a new Server instance with the given configuration.
 func NewServer(service *core.Service, cfg *config.Config) *Server {
 	s := &Server{
-		service: service,
-		cfg:     cfg,
-		router:  chi.NewRouter(),
+		service:       service,
+		cfg:           cfg,
+		router:        chi.NewRouter(),
+		denials:       newRepeatFilter(time.Minute),
+		uploader:      service,
+		tables:        service,
+		auditor:       service,
+		templateStore: service,
 	}
+	s.lockout = mw.NewAuthLockout(mw.LockoutConfig{
+		MaxFailures: cfg.Security.AuthMaxFailures,
+		Window:      cfg.Security.AuthFailureWindow,
+		Duration:    cfg.Security.AuthLockoutDuration,
+		OnLockout:   s.auditLockout,
+	})
+	s.accounts = mw.NewAuthLockout(mw.LockoutConfig{
+		MaxFailures: cfg.Security.AuthMaxFailures,
+		Window:      cfg.Security.AuthFailureWindow,
+		Duration:    cfg.Security.AuthLockoutDuration,
+		OnLockout:   s.auditAccountLockout,
+	})
 	s.setupMiddleware()
 	s.setupRoutes()
 	return s
 }
 
+// SetServices replaces the implementations handlers use for uploads,
+// queries, audit and templates, e.g. with test doubles or a remote
+// backend. Nil fields keep the Service's. Call before Start.
+func (s *Server) SetServices(svc Services) {
+	if svc.Uploader != nil {
+		s.uploader = svc.Uploader
+	}
+	if svc.Tables != nil {
+		s.tables = svc.Tables
+	}
+	if svc.Auditor != nil {
+		s.auditor = svc.Auditor
+	}
+	if svc.TemplateStore != nil {
+		s.templateStore = svc.TemplateStore
+	}
+}
+
+// SetIPResolver installs the resolver used for the country and ASN deny
+// lists on destructive endpoints. Call before Start. Without a resolver,
+// destructive requests are rejected whenever those lists are configured.
+func (s *Server) SetIPResolver(r mw.IPResolver) {
+	s.ipResolver = r
+}
+
+// resolveNetwork delegates to the installed IPResolver, if any.
+func (s *Server) resolveNetwork(ctx context.Context, ip net.IP) (mw.NetworkInfo, error) {
+	if s.ipResolver == nil {
+		return mw.NetworkInfo{}, mw.ErrNoResolver
+	}
+	return s.ipResolver.Resolve(ctx, ip)
+}
+
 // setupMiddleware configures middleware for all routes.
 // Note: Timeout middleware is applied per-route to avoid killing SSE/streaming endpoints.
 func (s *Server) setupMiddleware() {
@@ -68,9 +139,15 @@ func (s *Server) setupMiddleware() {
 
 	// Rate limiting (configurable)
 	if s.cfg.Rate.Enabled {
-		limiter := newRateLimiter(s.cfg.Rate.RequestsPerMinute, time.Minute)
+		limiter := newRateLimiter(s.cfg.Rate.RequestsPerMinute, time.Minute, s.auditRateLimited)
 		s.router.Use(limiter.middleware)
 	}
+
+	// API token from X-API-Key, checked against its scope and tables
+	s.router.Use(s.tokenAuth)
+
+	// Signed-in user from the session cookie; enforces REQUIRE_LOGIN
+	s.router.Use(s.sessionAuth)
 }
 
 // setupRoutes configures all HTTP routes.
@@ -89,8 +166,15 @@ func (s *Server) setupMiddleware() {
 //   GET  /table/{tableKey}         View table data with pagination, sorting, filtering
 //                                  Query params:
 //                                    - page         (int)    Page number, default 1
-//                                    - sort         (string) Column name(s) to sort by, comma-separated (max 2)
+//                                    - pageSize     (int)    Rows per page, default 25, clamped to
+//                                                   QUERY_MIN_PAGE_SIZE..QUERY_MAX_PAGE_SIZE; remembered
+//                                                   per table in a page_size_{tableKey} cookie
+//                                    - sort         (string) Column name(s) to sort by, comma-separated
+//                                                   (max QUERY_MAX_SORT_LEVELS, default 4; extras ignored)
 //                                    - dir          (string) Sort direction(s): "asc" or "desc", comma-separated
+//                                    - nulls        (string) Where empty values sort, per sort column: "first",
+//                                                   "last", or empty for the default (last ascending,
+//                                                   first descending), comma-separated
 //                                    - search       (string) Full-text search across all columns
 //                                    - filter[col]  (string) Column filter in format "operator:value"
 //                                                   Operators by type:
@@ -99,6 +183,20 @@ func (s *Server) setupMiddleware() {
 //                                                     date:    equals, gte, lte
 //                                                     bool:    equals
 //                                                     enum:    equals, in
+//                                                     all:     neq (also matching empty values),
+//                                                              null and notnull (empty or not;
+//                                                              no value, e.g. "null:")
+//                                                   A "!" before the operator negates the filter
+//                                                   (also matching empty values)
+//                                    - filter[col][group]
+//                                                 (string) Filter in an OR group: filters with the same
+//                                                   group are ORed, and the group is ANDed with the
+//                                                   rest. Dotted groups nest ("g.x" is ANDed inside
+//                                                   "g"); a "!" before a group name negates it
+//                                                   e.g. filter[Status][g]=eq:active&
+//                                                   filter[Status][g]=eq:pending&filter[Amount]=gt:1000
+//                                    - view         (string) Saved view ID; supplies search, filters and
+//                                                   sorts not given in the request, and the visible columns
 //                                  Response: HTML page (full) or table partial (HTMX)
 //
 //   GET  /upload/{uploadID}        View upload detail page showing inserted/skipped rows
@@ -117,16 +215,76 @@ func (s *Server) setupMiddleware() {
 //                                    - to       (string) End date (YYYY-MM-DD)
 //                                  Response: HTML page (full) or audit log partial (HTMX)
 //
+//   GET  /login                    Sign-in form
+//                                  Query params:
+//                                    - next     (string) Path to return to after signing in
+//
+//   POST /login                    Sign in from the form (email, password, next); sets the session
+//                                  cookie and redirects to next
+//                                  Response: 303 redirect, or the form again with 401
+//
+// With REQUIRE_LOGIN, every route except /login, /api/auth/login and /static
+// needs a session cookie or a valid X-API-Key: pages redirect to /login, and
+// API calls get 401 with code AUTH_LOGIN_REQUIRED.
+//
+// A signed-in user is limited by their role: viewers may only read (and
+// sign out), editors may not use /api/admin/, admins may do anything.
+// Signed-in POST, PUT, PATCH and DELETE requests must send the session's
+// CSRF token in the X-CSRF-Token header. Either refusal is 403 with code
+// AUTH_ROLE or AUTH_CSRF and an access_denied audit entry.
+//
 // Static Files
 // ------------
 //   GET  /static/*                 Embedded static assets (HTMX, Tailwind CSS, JS)
 //
 // =============================================================================
+// Authentication API
+// =============================================================================
+//
+//   POST /api/auth/login           Sign in and set the session cookie (HttpOnly, SameSite=Lax)
+//                                  and the csrf_token cookie
+//                                  Request body: { "email": "string", "password": "string" }
+//                                  Response: { "csrfToken": "string",
+//                                              "user": { "id", "email", "name", "role", ... },
+//                                              "expiresAt": "string" }
+//                                  Errors: 401 wrong credentials, 429 after repeated failures
+//                                  from the client or for the account (AUTH_MAX_FAILURES each)
+//                                  Note: Each failure creates a login_failure entry; locking an
+//                                  account out creates an auth_lockout entry
+//
+//   POST /api/auth/logout          End the current session
+//                                  Response: 204 No Content
+//
+//   GET  /api/auth/me              The signed-in user
+//                                  Response: { "id": "string", "email": "string", "name": "string",
+//                                              "role": "string", "createdAt": "string",
+//                                              "lastLoginAt": "string" }
+//                                  Errors: 401 when not signed in
+//
+// =============================================================================
 // System API
 // =============================================================================
 //
 //   GET  /api/upload-queue-status  Get current upload queue/limiter status
-//                                  Response: { "active": int, "max": int, "queued": int }
+//                                  Response: { "active": int, "available": int, "max_concurrent": int,
+//                                    "quota": {                // The caller's per-uploader quota
+//                                      "uploader": "key:hash|ip:addr", "active": int,
+//                                      "max_concurrent": int, "uploads_today": int,
+//                                      "max_uploads_per_day": int, "bytes_today": int,
+//                                      "max_bytes_per_day": int, "resets_at": "RFC3339"
+//                                    } }
+//                                  Note: Each API key (or client IP without one) may run
+//                                  UPLOAD_MAX_CONCURRENT_PER_UPLOADER uploads at once and start
+//                                  UPLOAD_DAILY_UPLOADS_PER_UPLOADER uploads totalling
+//                                  UPLOAD_DAILY_BYTES_PER_UPLOADER bytes per UTC day (0: no limit;
+//                                  max_* fields are omitted). Uploads past a limit fail with 429:
+//                                  UPL008 after waiting UPLOAD_MAX_WAIT_TIME for a slot, UPL009 at once
+//
+//   GET  /api/webhooks/schema      JSON Schema of each webhook event's payload
+//                                  Query: ?version=1 (default: current)
+//                                  Response: { "schema_version": 1,
+//                                    "events": { "upload_completed": {JSON Schema}, ... } }
+//                                  (404 for an unknown version)
 //
 // =============================================================================
 // Table API
@@ -134,16 +292,187 @@ func (s *Server) setupMiddleware() {
 //
 //   GET  /api/tables               List all tables organized by group
 //                                  Response: { "groupName": [{ table definitions }], ... }
+//                                  Note: View tables (TableDefinition.View) have "ReadOnly": true.
+//                                  They can be read, filtered, sorted and exported like any
+//                                  table; uploads, previews, edits, deletes, resets, backfills and
+//                                  statistics refreshes on them return 405 Method Not Allowed
+//
+//   GET  /api/tables/{tableKey}/renames
+//                                  Column renames declared in the registry
+//                                  Response: { "Old Name": "Current Name", ... }
+//                                  Note: Old names keep working in sort, filter[col], upload
+//                                  mappings and import templates
+//
+//   GET  /api/tables/{tableKey}/key-violations
+//                                  Unique key violations of the table's recent uploads, oldest first:
+//                                  rows skipped or replaced by the duplicate policy plus rows the
+//                                  database rejected as duplicate keys
+//                                  Query params:
+//                                    - limit (int) Uploads to include (default: UPLOAD_KEY_VIOLATION_ALERT_WINDOW)
+//                                  Response: { "table_key", "uploads": [{ "upload_id", "file_name",
+//                                              "uploaded_at", "rows", "violations", "percent" }],
+//                                              "percent", "threshold", "alerting" }
+//                                  Note: When the percent crosses UPLOAD_KEY_VIOLATION_ALERT_PERCENT
+//                                  a warning is logged and posted to UPLOAD_ALERT_WEBHOOK_URL
+//
+//   GET  /api/tables/{tableKey}/actions
+//                                  Custom actions registered on the table (TableDefinition.Actions)
+//                                  Response: { "actions": [{ "name", "label", "description",
+//                                              "operationId" (while running) }] }
+//
+//   GET  /api/data/{tableKey}      A page of the table's live rows as JSON
+//                                  Query params:
+//                                    - page         (int) Page number (default: 1)
+//                                    - pageSize     (int) Rows per page (default: 25, clamped to limits)
+//                                    - cursor       (string) Read the page after this nextCursor
+//                                                            instead of by page number
+//                                    - sort, dir, nulls, search, filter[col] (same as table view)
+//                                  Response: { "tableKey", "columns": ["string"],
+//                                              "rows": [{ "column": "value" }], "totalRows",
+//                                              "pageSize", "totalPages", "sorts": [{ "column",
+//                                              "dir", "nulls" }], "page" | "cursor", "nextCursor" }
+//                                  Note: Cells are formatted as in a CSV export, with the
+//                                  columns' display hints (FieldSpec.Display). nextCursor is
+//                                  empty on the last page and for view tables. Cursor pages cost
+//                                  the same however deep they are, where page numbers slow down
+//                                  past ~100k rows. A cursor only works with the sort it was issued
+//                                  for; a mismatched or malformed cursor returns 400
+//
+//   GET  /api/summary/{tableKey}   Grouped aggregations of the table's live rows
+//                                  Query params:
+//                                    - group        (string) Column to group by, repeatable (max 4);
+//                                                            "Column:bucket" groups a date column by
+//                                                            day, week, month, quarter or year
+//                                    - agg          (string) Aggregate per group, repeatable (max 16):
+//                                                            sum|avg|min|max:Column on numeric columns,
+//                                                            count:Column for non-empty values, or
+//                                                            count for rows
+//                                    - filter[col]  (string) Column filters (same format as table view)
+//                                  Response: { "tableKey", "groupBy": [{ "column", "bucket" }],
+//                                              "aggs": [{ "func", "column" }],
+//                                              "groups": [{ "keys": ["string"|null],
+//                                                "count": int, "values": [number|null] }],
+//                                              "truncated": bool }
+//                                  Note: Keys are text: dates 2024-01-31, weeks by their Monday,
+//                                  months 2024-01, quarters 2024-Q1, years 2024; empty values are
+//                                  null and sort last. Groups are ordered by key and capped at
+//                                  QUERY_MAX_SUMMARY_GROUPS (truncated: true). Unknown columns,
+//                                  functions or buckets return 400
+//                                  Aggregates-only callers: min and max return 403, and groups of
+//                                  fewer rows than the table's minimum group size are left out and
+//                                  counted in "suppressed"
+//
+//   GET  /api/histogram/{tableKey} Counts of a numeric column's values in equal-width buckets
+//                                  Query params:
+//                                    - column       (string) Numeric column
+//                                    - from, to     (number) Range of the buckets; from < to
+//                                    - buckets      (int)    Number of buckets (default 10, max 100)
+//                                    - filter[col]  (string) Column filters (same format as table view)
+//                                  Response: { "tableKey", "column",
+//                                              "bins": [{ "from", "to", "count": int|null }],
+//                                              "below": int|null, "above": int|null,
+//                                              "suppressed": int }
+//                                  Note: Empty values are not counted. For aggregates-only callers,
+//                                  counts from 1 to below the table's minimum group size are null
+//
+//   GET  /api/quality/{tableKey}   Column-level data quality of the table's live rows
+//                                  Response: { "tableKey", "rows",
+//                                              "columns": [{ "column", "type", "empty", "nullRate",
+//                                                "distinct", "minLength", "maxLength",
+//                                                "unknownValues", "minDate", "maxDate",
+//                                                "futureDates", "lowerFence", "upperFence",
+//                                                "outliers", "flags": ["string"] }] }
+//                                  Note: Fields not applying to a column's type are omitted.
+//                                  Outliers lie outside Tukey's fences (1.5 IQR beyond the
+//                                  quartiles). Flags: empty, missing_required, high_null_rate,
+//                                  constant, outliers, future_dates, unknown_values
+//                                  Errors: 403 for aggregates-only callers, 404 unknown table
 //
 //   GET  /api/template/{tableKey}  Download empty CSV template with correct headers
 //                                  Response: CSV file attachment with column headers only
 //
-//   GET  /api/export/{tableKey}    Export table data as streaming CSV
+//   GET  /api/export/{tableKey}    Export table data as a streaming file
 //                                  Query params:
+//                                    - format       (string) csv (default), json (array of objects,
+//                                                            typed numbers and bools), xlsx or sql
+//                                                            (INSERT script)
+//                                    - batch        (int)    sql: rows per INSERT (default 100, max 1000)
+//                                    - on_conflict  (string) sql: rows whose unique key exists are
+//                                                            skipped ("nothing") or replace the
+//                                                            existing rows ("update")
 //                                    - search       (string) Full-text search filter
 //                                    - filter[col]  (string) Column filters (same format as table view)
-//                                  Response: Streaming CSV file attachment
-//                                  Note: Uses chunked transfer encoding for large datasets
+//                                    - anonymize    (bool)   "true" exports an anonymized random sample,
+//                                                            masked per the table's FieldSpec masks
+//                                    - sample       (int)    Sample size with anonymize (default 1000,
+//                                                            max 50000)
+//                                    - watermark    (bool)   "true" adds a hidden "Export ID" column
+//                                                            (a comment in sql) traceable with
+//                                                            /api/export-watermark; always on for
+//                                                            tables with WatermarkExports
+//                                  Response: Streaming file attachment in the requested format,
+//                                  with the export ID in X-Export-Watermark
+//                                  Errors: 400 unknown format or invalid option
+//                                  Note: Uses chunked transfer encoding for large datasets.
+//                                  xlsx stops at Excel's 1,048,576-row sheet limit.
+//                                  Recorded in the audit log as data_export with the filters
+//                                  and row count
+//
+//   POST /api/export-jobs/{tableKey}
+//                                  Export table data in the background, for tables too large to
+//                                  stream in one request
+//                                  Query params: format, batch, on_conflict, watermark, search and
+//                                  filter[col], as for /api/export
+//                                  Response: { "operation_id": "uuid" } (202 Accepted), also in
+//                                  X-Operation-ID
+//                                  Errors: 400 unknown format or invalid option, 404 unknown table
+//                                  Note: Follow progress at /api/operations/{operationID}/progress;
+//                                  once complete the operation's resultLink is the download URL.
+//                                  Files are kept for EXPORT_JOB_TTL (default 24h)
+//
+//   GET  /api/export-job/{operationID}/download
+//                                  Download the file written by a background export
+//                                  Response: File attachment in the requested format
+//                                  Errors: 404 unknown or expired, 409 still running
+//                                  Note: Recorded in the audit log as data_export with the
+//                                  filters and row count, like /api/export
+//
+//   GET  /api/export-watermark/{watermark}
+//                                  Trace a watermarked export by the ID in its "Export ID"
+//                                  column, e.g. EXP-3f2a9c41d0b7e815
+//                                  Response: [AuditEntry] - the data_export entries recorded with
+//                                  it (user, IP address, time, filters), oldest first
+//                                  Errors: 404 no export recorded with the watermark
+//
+// =============================================================================
+// Export Snapshot API
+// =============================================================================
+// Snapshots store a (unique key, row hash) manifest of an export so later
+// exports can report what changed. Table must define a unique key.
+//
+//   POST /api/snapshots/{tableKey} Record a snapshot of the rows an export would contain
+//                                  Query params:
+//                                    - name         (string) Optional label, defaults to timestamp
+//                                    - search       (string) Full-text search filter
+//                                    - filter[col]  (string) Column filters (same format as table view)
+//                                  Response: { "id": "uuid", "tableKey": "string", "name": "string", "rowCount": int, ... } (201 Created)
+//
+//   GET  /api/snapshots/{tableKey} List snapshots for a table, newest first
+//                                  Response: [{ snapshot }]
+//
+//   GET  /api/snapshot/{id}/diff   Compare current data against a snapshot
+//                                  Note: The snapshot's search and filters are reapplied
+//                                  Response: {
+//                                    "snapshot": { snapshot },
+//                                    "added": ["key1"],
+//                                    "removed": ["key2"],
+//                                    "changed": ["key3"],
+//                                    "unchanged": int,
+//                                    "currentCount": int
+//                                  }
+//
+//   DELETE /api/snapshot/{id}      Delete a snapshot and its manifest
+//                                  Response: { "status": "deleted" }
 //
 // =============================================================================
 // Upload API
@@ -155,16 +484,110 @@ func (s *Server) setupMiddleware() {
 //   POST /api/upload/{tableKey}    Upload CSV file for import
 //                                  Content-Type: multipart/form-data
 //                                  Form fields:
-//                                    - file     (file)   CSV file (max 100MB)
-//                                    - mapping  (string) Optional JSON column mapping: { "dbColumn": csvIndex }
+//                                    - file     (file)   CSV, JSON, NDJSON, Parquet, gzipped or .zip file
+//                                                        (max 100MB, also after decompression)
+//                                    - mapping  (string) Optional JSON column mapping: { "dbColumn": csvIndex }.
+//                                                        A string value is a constant for every row,
+//                                                        e.g. { "Source": "SFDC" }; "=today" and "=now"
+//                                                        are the upload's start date and time. Checked
+//                                                        against the column's type before the upload
+//                                                        starts (400 if invalid). Not applied to .zip
+//                                                        archives
+//                                    - mode     (string) Optional "insert", "upsert", "replace" or "delete"
+//                                                        (default: the table's UploadMode, else insert);
+//                                                        also accepted as a query param. delete reads
+//                                                        only the unique key columns and deletes the
+//                                                        matching rows; keys that match nothing are
+//                                                        failed rows
+//                                    - duplicates (string) Optional duplicate policy: "skip", "overwrite",
+//                                                        "fail-upload" or "keep-both" (default: overwrite
+//                                                        in upsert mode, else keep-both); also a query param
+//                                    - date_format (string) Optional "mdy" or "dmy": how numeric dates
+//                                                        such as 01/02/2024 are read (default: month
+//                                                        first, warning about columns that look day
+//                                                        first); also a query param
+//                                    - year_pivot (int)  Optional 1-99: 2-digit years more than this
+//                                                        many years ahead are the previous century
+//                                                        (default: the column's, else 20); also a
+//                                                        query param
+//                                    - template (string) Optional import template ID: its mapping is used
+//                                                        when mapping is empty, and a fixed-width
+//                                                        template slices the file into columns first
+//                                                        (not for zip archives); also a query param
+//                                    - columns  (string) Optional comma-separated columns to import, e.g. a
+//                                                        key plus one new attribute; the file only needs
+//                                                        these. They must include the unique key, and
+//                                                        columns left out must be nullable in the
+//                                                        database (else 400). Left-out columns are NULL,
+//                                                        or keep the replaced row's values in upsert
+//                                                        mode; also a query param
 //                                  Response: { "upload_id": "uuid" }
-//                                  Note: Returns immediately; use progress endpoint to track
+//                                  If the template supplies the mapping and the file's headers differ
+//                                  from its saved csvHeaders, the result reports "template_drift"
+//                                  with a suggested mapping; UPLOAD_TEMPLATE_DRIFT=block fails the
+//                                  upload instead when a mapped column moved or is missing (FILE010)
+//                                  Note: Returns immediately; use progress endpoint to track.
+//                                  Per-table limits may reject the file up front (FILE006,
+//                                  UPL006) or fail the upload once too many rows are read (FILE007)
+//                                  Upsert replaces rows from earlier uploads with the same unique key;
+//                                  replace deletes all existing rows. Both apply only if the upload
+//                                  commits, and rolling the upload back does not restore displaced rows.
+//                                  A duplicate is a row whose unique key is already in the table or
+//                                  earlier in the file. skip keeps the first row; overwrite keeps the
+//                                  last (in insert mode it switches the upload to upsert); fail-upload
+//                                  fails the upload at the first duplicate (UPL007)
+//                                  Gzip files are decompressed as they stream. A zip archive is
+//                                  expanded into an upload batch with one upload per .csv, .json,
+//                                  .ndjson or .jsonl (optionally .gz) it contains, and the response is instead
+//                                  { "batch_id": "uuid", "upload_ids": ["uuid", ...] }
+//                                  (see /api/upload-batch/{batchID})
+//                                  The delimiter (comma, semicolon, tab or pipe) and encoding (UTF-8,
+//                                  UTF-16LE/BE, Windows-1252/Latin-1) are detected from the file.
+//                                  A .json, .ndjson or .jsonl file, or one starting with "[" or "{",
+//                                  is read as a JSON array or NDJSON of objects whose keys are the
+//                                  columns (see "JSON Files" in the README)
+//                                  A .parquet file, or one starting and ending with "PAR1", is read
+//                                  one row group at a time; its columns are matched by name, or
+//                                  counted in schema order by a mapping (see "Parquet Files" in the
+//                                  README). Parquet cannot be uploaded from a URL (FILE009)
+//
+//   POST /api/upload/{tableKey}/from-url
+//                                  Stream a CSV into the table from a remote URL, read server-side
+//                                  Request: { "url": "s3://bucket/key.csv|gs://bucket/obj.csv|https://...",
+//                                             "mapping": { "dbColumn": csvIndex }, "mode": "insert",
+//                                             "duplicates": "skip", "date_format": "dmy",
+//                                             "year_pivot": 20 }
+//                                  Response: { "upload_id": "uuid" }
+//                                  Errors: 403 if the URL is not under UPLOAD_REMOTE_SOURCES
+//                                  Note: Processed like /api/upload/{tableKey}, with the same size and
+//                                  per-table limits. HTTPS redirects must stay on the same host.
+//                                  Gzip files are decompressed; zip archives are rejected
+//
+//   POST /api/upload-paste/{tableKey}
+//                                  Preview, validate or upload rows pasted from the clipboard
+//                                  Request: { "text": "Name\tAmount\nAcme\t10.50", "step": "upload",
+//                                             "mapping": { "dbColumn": csvIndex }, "template": "id",
+//                                             "mode": "insert", "duplicates": "skip",
+//                                             "date_format": "dmy", "year_pivot": 20 }
+//                                    - text     Tab- or comma-delimited rows with a header row
+//                                               (max UPLOAD_MAX_PASTE_SIZE, default 256KB)
+//                                    - step     "upload" (default), "preview" or "validate"
+//                                  Response: as /api/upload/{tableKey} for "upload", /api/preview for
+//                                            "preview" and /api/validate for "validate"
+//                                  Errors: 413 if the text is over the limit
+//                                  Note: Recorded in upload history as clipboard.tsv or clipboard.csv
 //
 //   GET  /api/upload/{uploadID}/progress
 //                                  SSE stream for real-time upload progress
 //                                  Query params:
-//                                    - lastEventId (int) Resume from this progress percentage
+//                                    - lastEventId (int) Resume after this event ID; the
+//                                      Last-Event-ID header is used instead when set
 //                                  Response: Server-Sent Events stream
+//                                    - Event IDs number an upload's updates from 1. A resumed
+//                                      stream replays the phase changes after lastEventId,
+//                                      then the current progress
+//                                    - A finished upload still in memory replays, then
+//                                      sends complete
 //                                    - event: progress, data: { "processed": int, "total": int, "inserted": int, "skipped": int }
 //                                    - event: complete, data: {}
 //                                  Headers: Content-Type: text/event-stream
@@ -176,21 +599,247 @@ func (s *Server) setupMiddleware() {
 //                                    "table_key": "string",
 //                                    "file_name": "string",
 //                                    "total_rows": int,
+//                                    "mode": "insert|upsert|replace|delete",
 //                                    "inserted": int,
+//                                    "updated": int,   // upsert: rows that replaced an existing key
+//                                    "replaced": int,  // replace: existing rows deleted
+//                                    "deleted": int,   // delete: rows deleted by key
 //                                    "skipped": int,
+//                                    "duplicates": "skip|overwrite|fail-upload|keep-both",
+//                                    "duplicates_skipped": int,  // skip: rows whose key was taken
+//                                    "duplicates_in_file": int,  // overwrite: earlier rows of the file replaced
 //                                    "failed_rows": [{ "line": int, "reason": "string", "data": [...] }],
+//                                    "retries": int,
 //                                    "duration": "1.5s",
+//                                    "date_warnings": [{ "kind", "column", "checked", "ambiguous",
+//                                      "dayFirst", "monthFirst", "example", "message",
+//                                      "twoDigitYears", "yearPivot", "firstYear", "lastYear" }],
+//                                                                             (optional)
+//                                    "template_drift": { "templateId", "templateName", "added",
+//                                      "removed", "moved", "renamed": [{ "from", "to" }], "affected",
+//                                      "suggestedMapping": { "dbColumn": csvIndex }, "unmapped",
+//                                      "message" },                          (optional)
 //                                    "error": "string" (optional)
 //                                  }
+//                                  Note: date_warnings lists date columns whose numeric dates could
+//                                  be read either way and nothing shows to be month first (kind
+//                                  "ambiguous_day_month", only checked when no date_format is
+//                                  given), and columns with 2-digit years, with the years they
+//                                  were read as (kind "two_digit_year")
+//
+//   GET  /api/operations/{operationID}/progress
+//                                  SSE stream of step-based progress for a running operation
+//                                  (for uploads and batches, operationID is the upload ID or batch ID)
+//                                  Response: Server-Sent Events stream
+//                                    - event: progress, id: percent, data: {
+//                                        "operationId": "uuid",
+//                                        "kind": "upload|upload_batch|export|retention|audit_import|backfill|
+//                                                 reset|rollback",
+//                                        "tableKey": "string", "resultLink": "string",
+//                                        "status": "running|complete|failed|cancelled",
+//                                        "step": "string", "percent": int, "error": "string",
+//                                        "steps": [{ "name": "string", "weight": int,
+//                                                    "status": "pending|running|complete|failed",
+//                                                    "current": int, "total": int }],
+//                                        "startedAt": "RFC3339", "finishedAt": "RFC3339"
+//                                      }
+//                                    - event: complete, data: {}
+//                                  Note: Upload steps are read, replace (replace mode only), insert and
+//                                  finalize; a batch has one step per file, weighted by file size.
+//                                  The overall percent is the weighted sum of step completion
+//
+//   GET  /api/events/tables        SSE stream of table card updates for the dashboard
+//                                  Query params:
+//                                    - table (string, repeatable) Tables to follow (default: all)
+//                                  Response: Server-Sent Events stream, open until the client leaves
+//                                    - event: table, data: {
+//                                        "table_key": "string", "change": "upload|rollback|reset",
+//                                        "row_count": int, "last_upload": "RFC3339" (optional),
+//                                        "time": "RFC3339"
+//                                      }
+//                                  Note: Sent when an upload commits, a committed upload is rolled
+//                                  back or the table is reset. Changes to one table within 500ms
+//                                  are sent as one event with the latest counts. Idle streams get
+//                                  a comment every 30s. 400 for an unknown table
+//
+//   GET  /api/events               SSE activity feed across all tables
+//                                  Response: Server-Sent Events stream, open until the client leaves
+//                                    - event: activity, data: {
+//                                        "kind": "upload_started|upload_completed|upload_failed|
+//                                                 rollback|reset|bulk_edit",
+//                                        "table_key": "string", "upload_id": "string" (optional),
+//                                        "record_id": "uuid" (optional), "file_name": "string" (optional),
+//                                        "rows": int (optional), "column": "string" (bulk_edit),
+//                                        "error": "string" (upload_failed), "time": "RFC3339"
+//                                      }
+//                                  Note: One event per action, sent as it happens; dry runs are not
+//                                  reported. rows is rows written by a completed upload, deleted by a
+//                                  rollback or reset, or changed by a bulk edit. Events name no
+//                                  user. Idle streams get a comment every 30s
+//
+//   GET  /api/operations
+//                                  List recorded operations, newest first
+//                                  Query: ?kind=upload&status=failed&table=key&parent=uuid
+//                                         &since=DATE&until=DATE&limit=50&offset=0
+//                                  (dates are RFC3339 or YYYY-MM-DD; limit max 500)
+//                                  Response: { "operations": [{
+//                                      "id": "uuid", "kind": "string",
+//                                      "status": "running|complete|failed|cancelled",
+//                                      "tableKey": "string", "initiator": "ip|system",
+//                                      "userAgent": "string", "parentId": "uuid",
+//                                      "startedAt": "RFC3339", "finishedAt": "RFC3339",
+//                                      "durationMs": int, "error": "string",
+//                                      "resultLink": "string",
+//                                      "steps": [{ ... }], // As last recorded, same shape as progress steps
+//                                      "progress": { ... } // Running operations only, as a progress event
+//                                    }], "total": int }
+//                                  Note: Operations still running when the server stopped are marked
+//                                  failed at startup. Table exports return their ID in X-Operation-ID
+//
+//   GET  /api/operations/{operationID}
+//                                  One recorded operation, same shape as a list entry, with
+//                                  "progress" while its steps are still tracked in memory
+//
+//   POST /api/operations/{operationID}/cancel
+//                                  Stop a background reset, rollback, backfill, export or table
+//                                  action after its current batch; it finishes as cancelled
+//                                  Response: { "status": "cancelling" }
+//                                  (404 if not tracked, 409 if finished or not cancellable)
 //
 //   POST /api/upload/{uploadID}/cancel
-//                                  Cancel an in-progress upload
+//                                  Cancel an in-progress upload; a running COPY or
+//                                  insert is cancelled in PostgreSQL (see DB_CANCEL_GRACE)
 //                                  Response: { "status": "cancelled" }
 //
 //   GET  /api/upload/{uploadID}/failed-rows
 //                                  Export failed rows from an upload as CSV
 //                                  Response: CSV file with columns: _line, _error, [original columns...]
-//                                  Note: Only available for uploads with stored CSV headers
+//                                  Note: Only available for uploads with stored CSV headers.
+//                                  Recorded in the audit log as data_export
+//
+//   GET  /api/upload/{uploadID}/evidence
+//                                  Download an upload's evidence package for auditors
+//                                  Response: zip file containing
+//                                    - summary.json: upload_id, table_key, file_name, uploaded_at,
+//                                      status, rows_inserted, rows_skipped, duration_ms, csv_headers,
+//                                      review_status, reviewed_at, reviewed_by, review_note,
+//                                      audit_entries, generated_at
+//                                    - failed_rows.csv: as /failed-rows
+//                                    - audit_log.json: audit entries for the upload, oldest first
+//                                    - SHA256SUMS: checksums of the other files (sha256sum -c format)
+//                                  Note: The original file is not included; uploaded files are not
+//                                  retained after processing. 404 if the upload does not exist.
+//                                  Recorded in the audit log as data_export
+//
+//   POST /api/upload-batch         Upload several CSV files as one batch
+//                                  Content-Type: multipart/form-data
+//                                  Form fields:
+//                                    - file     (file)   CSV, JSON or Parquet file, may be gzipped; repeat for each file in the batch
+//                                    - table    (string) Table key, once for all files or once per file in order
+//                                    - mode     (string) Optional "insert", "upsert", "replace" or "delete" for all files
+//                                    - duplicates (string) Optional duplicate policy for all files
+//                                    - date_format, year_pivot Optional date options for all files
+//                                  Response: { "batch_id": "uuid", "upload_ids": ["uuid", ...] }
+//                                  Note: Every file is checked before any starts. Files then run one at
+//                                  a time in order, each as a normal upload with its own progress and
+//                                  result; a failed file does not stop the rest. Files are held in
+//                                  memory, so their combined size is bounded by the upload size limit
+//
+//   POST /api/upload-route         Upload one CSV file whose rows go to different tables
+//                                  Content-Type: multipart/form-data
+//                                  Form fields:
+//                                    - file          (file)   CSV file, may be gzipped
+//                                    - route_column  (string) Column whose value picks each row's table
+//                                    - routes        (string) JSON object: { "route value": "tableKey" };
+//                                                             values match ignoring case
+//                                    - default_table (string) Optional table for rows matching no route;
+//                                                             without it they are left out
+//                                    - mode, duplicates, date_format, year_pivot Optional, for every table
+//                                  Response: {
+//                                    "batch_id": "uuid",
+//                                    "files": [{ "table_key": "string", "file_name": "string",
+//                                                "upload_id": "uuid", "rows": int }],
+//                                    "unrouted": int, "unrouted_lines": [int] (first 20)
+//                                  }
+//                                  Note: Each table's rows run as one file of an upload batch, with
+//                                  its own validation, upload record and rollback; follow them with
+//                                  /api/upload-batch/{batchID}. Columns are matched to each table by
+//                                  header name. 400 if the route column, a table, or a table's
+//                                  required column is missing, or no row matches a route
+//
+//   POST /api/upload-batch/{batchID}/files
+//                                  Add a file to an existing batch; it runs after files already queued
+//                                  Form fields: file, table, mapping, mode, duplicates, date_format, year_pivot (as for
+//                                  /api/upload/{tableKey})
+//                                  Response: { "batch_id": "uuid", "upload_id": "uuid" }
+//
+//   GET  /api/upload-batch/{batchID}
+//                                  Get batch progress and aggregated results
+//                                  Response: {
+//                                    "batchId": "uuid",
+//                                    "phase": "running|complete|failed|rolled_back",
+//                                    "percent": int,
+//                                    "totalFiles": int, "completedFiles": int, "failedFiles": int,
+//                                    "totalRows": int, "inserted": int, "updated": int, "skipped": int,
+//                                    "files": [{ "uploadId": "uuid", "recordId": "uuid", "tableKey": "string",
+//                                                "fileName": "string", "phase": "string", "percent": int,
+//                                                "totalRows": int, "inserted": int, "updated": int,
+//                                                "skipped": int, "rolledBack": bool, "error": "string" }]
+//                                  }
+//                                  Note: recordId is the ID shown in upload history and used for
+//                                  rollback; uploadId is only set while the upload is tracked in memory
+//
+//   POST /api/resumable-upload/{tableKey}
+//                                  Open a resumable upload: the file is sent in chunks and can be
+//                                  resumed after a page refresh
+//                                  Headers: X-Resume-Token (optional) 16-128 letters, digits, - or _
+//                                  Request: { "file_name": "string", "size": int, "mapping": { ... },
+//                                             "mode": "insert", "duplicates": "skip", "date_format": "dmy",
+//                                             "year_pivot": 20 }
+//                                  Response: { pending upload, "resume_token": "string" } (201 Created)
+//                                  Note: Needs UPLOAD_SPOOL_KEYS; returns 404 when spooling is disabled.
+//                                  Without X-Resume-Token a token is generated and returned once; send
+//                                  it on every other resumable request. The table, mode and size are
+//                                  checked here, before any chunk is sent
+//
+//   PUT  /api/resumable-upload/{sessionID}/chunk
+//                                  Append the request body to the file
+//                                  Query params:
+//                                    - offset (int) Bytes already sent; must equal "received"
+//                                  Response: { pending upload }
+//                                  Errors: 409 if offset is not "received"; 400 if the chunk is empty,
+//                                  larger than "chunk_size" or runs past "size"
+//                                  Note: Chunks are encrypted into the spool as they arrive
+//
+//   GET  /api/resumable-upload/{sessionID}
+//                                  Get a session's state
+//                                  Response: {
+//                                    "session_id": "uuid", "table_key": "string",
+//                                    "file_name": "string", "size": int,
+//                                    "received": int,     // Offset of the next chunk
+//                                    "chunk_size": int,   // Largest chunk accepted (UPLOAD_RESUMABLE_CHUNK_SIZE)
+//                                    "created_at": "RFC3339", "updated_at": "RFC3339",
+//                                    "upload_id": "uuid", // Once completed
+//                                    "batch_id": "uuid", "upload_ids": ["uuid", ...] // Zip archives
+//                                  }
+//
+//   POST /api/resumable-upload/{sessionID}/complete
+//                                  Process the received file like /api/upload/{tableKey}
+//                                  Response: { pending upload } with upload_id (or batch_id)
+//                                  Errors: 409 until every byte has been received
+//                                  Note: Retrying returns the upload already started
+//
+//   DELETE /api/resumable-upload/{sessionID}
+//                                  Discard a session and its chunks
+//                                  Response: { "status": "cancelled" }
+//
+//   GET  /api/my/pending-uploads   The X-Resume-Token's sessions, oldest first
+//                                  Response: [{ pending upload }]
+//                                  Note: Lists unfinished sessions, to resume by re-sending the file
+//                                  from "received", and completed ones whose upload is still
+//                                  tracked, to reattach to its progress. Unfinished sessions idle
+//                                  for UPLOAD_RESUMABLE_TTL are discarded. Sessions are kept in
+//                                  memory and do not survive a restart
 //
 // =============================================================================
 // Preview API
@@ -200,7 +849,12 @@ func (s *Server) setupMiddleware() {
 //                                  Content-Type: multipart/form-data
 //                                  Form fields:
 //                                    - file     (file)   CSV file to analyze
-//                                    - mapping  (string) Optional JSON column mapping
+//                                    - mapping  (string) Optional JSON column mapping; constants as for upload
+//                                    - dryRun   (bool)   Run the full pipeline (see below)
+//                                    - mode     (string) insert, upsert, replace or delete (default: table's)
+//                                    - columns  (string) Dry run only: columns to import, as for upload
+//                                    - date_format, year_pivot Optional date options, as for upload
+//                                    - template (string) Optional import template ID, as for upload
 //                                  Response: {
 //                                    "total_rows": int,
 //                                    "valid_rows": int,
@@ -210,6 +864,88 @@ func (s *Server) setupMiddleware() {
 //                                    "unmapped_columns": ["col1", "col2"],
 //                                    "sample_errors": [{ "line": int, "reason": "string" }]
 //                                  }
+//                                  With dryRun=true the real upload pipeline runs in a
+//                                  rolled-back transaction; nothing is written.
+//                                  Response: {
+//                                    "tableKey": "string", "mode": "string",
+//                                    "summary": { "totalRows", "inserted", "updated",
+//                                      "replaced", "deleted", "errorRows", "duplicateInFile",
+//                                      "existingRows": int },
+//                                    "errors": [{ "lineNumber": int, "reason": "string",
+//                                      "values": ["string"] }],        (every failed row)
+//                                    "duplicates": [{ "rowKey": "string", "lineNumbers": [int] }],
+//                                    "existing": [{ "lineNumber": int, "rowKey": "string" }],
+//                                    "dateWarnings": [...],          (optional, as for upload results)
+//                                    "processingTimeMs": int
+//                                  }
+//                                  Without dryRun the analysis also has "dateWarnings" when a date
+//                                  column may be day first, and "templateDrift" (as for upload
+//                                  results) when the template's headers differ from the file's.
+//                                  For an upsert, every update is compared with the row it replaces:
+//                                    "summary": { ..., "unchangedRows": int },
+//                                    "columnChanges": [{ "column": "string", "changed": int,
+//                                      "cleared": int, "samples": [{ "lineNumber": int,
+//                                      "rowKey": "string", "current": "string", "incoming": "string" }] }]
+//                                  and "updateDiffs" samples the rows that change
+//
+//   POST /api/preview/{tableKey}/fixed-width
+//                                  Show how a fixed-width layout slices a file's first lines
+//                                  Content-Type: multipart/form-data
+//                                  Form fields:
+//                                    - file     (file)   Fixed-width file, may be gzipped
+//                                    - layout   (string) JSON layout, as in a template's "fixedWidth"
+//                                    - template (string) Fixed-width template ID, when layout is empty
+//                                    - lines    (int)    Lines to show (default 20, max 200)
+//                                  Response: {
+//                                    "columns": [{ "name": "string", "start": int, "length": int }],
+//                                    "ruler": "string",
+//                                    "lines": [{ "line": int, "text": "string", "skipped": bool,
+//                                      "fields": ["string"] }]
+//                                  }
+//                                  Skipped lines are the layout's skipLines and blank lines.
+//                                  An invalid layout is a 400
+//
+//   POST /api/validate/{tableKey}  Validate a file without touching the database
+//                                  Content-Type: multipart/form-data
+//                                  Form fields:
+//                                    - file             (file)   CSV file, may be gzipped
+//                                    - mapping          (string) Optional JSON column mapping
+//                                    - maxErrors        (int)    Max error rows to pass
+//                                    - maxErrorPercent  (float)  Max percent of error rows to pass
+//                                    - date_format, year_pivot  Optional date options, as for upload
+//                                  Without thresholds any error row fails the file. A failing
+//                                  file is still a 200; cmd/validate gives a CLI exit status.
+//                                  Response: {
+//                                    "tableKey": "string", "passed": bool,
+//                                    "totalRows", "validRows", "errorRows": int,
+//                                    "errorPercent": float, "duplicateInFile": int,
+//                                    "errorCodes": { "VAL001": { "code", "message", "action",
+//                                      "count": int, "rows": int } },
+//                                    "errors": [{ "lineNumber": int, "code": "string",
+//                                      "reason": "string" }],           (first 100)
+//                                    "fileError": { "code", "message", "reason" },  (if any)
+//                                    "dateWarnings": [...],          (optional, as for upload results)
+//                                    "failed": ["string"],           (why it did not pass)
+//                                    "processingTimeMs": int
+//                                  }
+//
+//   POST /api/validate-value/{tableKey}/{column}
+//                                  Check one value for a column as an upload checks a cell
+//                                  Request body: {
+//                                    "value": "string",
+//                                    "dateFormat": "string",  // Optional: mdy, dmy or auto
+//                                    "yearPivot": int         // Optional, as for upload
+//                                  }
+//                                  Response: {
+//                                    "valid": bool,
+//                                    "column": "string",      // Current column name
+//                                    "value": "string",       // As stored, e.g. "2024-03-01"; "" is NULL
+//                                    "error": "string",       // If invalid, worded as upload errors
+//                                    "code": "string",        // e.g. VAL001
+//                                    "action": "string"
+//                                  }
+//                                  Note: Column may be an old name. An invalid value is still
+//                                  a 200; an unknown column is a 400. Table rules are not run
 //
 // =============================================================================
 // Duplicate Check API
@@ -227,6 +963,74 @@ func (s *Server) setupMiddleware() {
 //   POST /api/delete/{tableKey}    Delete multiple rows by unique key
 //                                  Request body: { "keys": ["key1", "key2", ...] }
 //                                  Response: { "deleted": int }
+//                                  Note: Tables with soft delete move the rows to their recycle bin
+//
+//   GET  /api/deleted/{tableKey}   List recently deleted rows, newest first
+//                                  Query params: limit (default 50, max 500)
+//                                  Response: { "rows": [{
+//                                    "historyId": "uuid",  // The row_delete audit entry
+//                                    "rowKey": "string",
+//                                    "rowData": { "column": value },
+//                                    "deletedBy": "string",
+//                                    "deletedAt": "timestamp",
+//                                    "restored": bool
+//                                  }] }
+//
+//   GET  /api/row-history/{tableKey}
+//                                  What happened to one row, oldest first
+//                                  Query params: key (required; the row's unique key)
+//                                  Response: {
+//                                    "tableKey": "string",
+//                                    "rowKey": "string",
+//                                    "keys": ["string"],      // Earlier keys, newest first
+//                                    "entries": [AuditEntry], // cell_edit, row_delete, row_restore
+//                                    "truncated": bool        // Older entries left out
+//                                  }
+//                                  Note: Includes the live and archived audit log, and the
+//                                  entries under keys the row had before a key column was
+//                                  edited. Returns the newest 500 entries.
+//
+//   POST /api/restore/{tableKey}   Re-insert deleted rows from their saved data
+//                                  Request body: { "ids": ["historyId", ...] }
+//                                  Response: {
+//                                    "restored": int,
+//                                    "skipped": int,
+//                                    "rows": [{ "historyId", "rowKey", "restored": bool,
+//                                      "error": "string" }]  // Why a row was skipped
+//                                  }
+//                                  Note: Rows are validated as cell edits are. A row already
+//                                  restored, or whose key is in the table again, is skipped.
+//                                  A soft-deleted row still in the recycle bin is moved out of it.
+//                                  Recorded in the audit log as row_restore, linked to the delete
+//
+//   GET  /api/recycle/{tableKey}   List a soft-delete table's recycle bin, most recently
+//                                  deleted first
+//                                  Query params: limit (default 50, max 500)
+//                                  Response: { "rows": [{
+//                                    "rowKey": "string",
+//                                    "rowData": { "column": value },
+//                                    "deletedAt": "timestamp"
+//                                  }] }
+//                                  Note: Only for tables with soft delete; their deletes set
+//                                  deleted_at and every other query skips those rows
+//
+//   POST /api/recycle/{tableKey}/restore
+//                                  Move rows out of the recycle bin
+//                                  Request body: { "keys": ["key1", ...] }
+//                                  Response: {
+//                                    "restored": int,
+//                                    "notFound": ["key"],   // Not in the recycle bin
+//                                    "conflicts": ["key"]   // Key is in the table again
+//                                  }
+//                                  Note: A key deleted more than once restores its latest row.
+//                                  Recorded in the audit log as row_restore, linked to the delete
+//
+//   POST /api/recycle/{tableKey}/purge
+//                                  Permanently delete rows from the recycle bin
+//                                  Request body: { "keys": ["key1", ...] }
+//                                  Response: { "purged": int, "notFound": ["key"] }
+//                                  Note: Rows are also purged SOFT_DELETE_RETENTION_DAYS after
+//                                  deletion. Recorded in the audit log as row_purge
 //
 //   POST /api/update/{tableKey}    Update a single cell value
 //                                  Request body: {
@@ -240,32 +1044,506 @@ func (s *Server) setupMiddleware() {
 //                                    "new_value": "string"
 //                                  }
 //
-//   POST /api/bulk-edit/{tableKey} Update a column across multiple rows
+//   POST /api/insert/{tableKey}    Add one row, validated as an upload row
+//                                  Request body: { "values": { "column": "value" } }
+//                                  Response: {
+//                                    "success": bool,
+//                                    "rowKey": "string",
+//                                    "fieldErrors": { "column": "why" },  // Invalid values
+//                                    "validationError": "string",         // ValidateRow/Batch rule
+//                                    "duplicateKey": bool                 // Key already in the table
+//                                  }
+//                                  Note: Columns left out are NULL. Recorded in the audit log
+//                                  and row history as row_insert
+//
+//   POST /api/clone/{tableKey}     Add a copy of a row, with some values changed
+//                                  Request body: {
+//                                    "rowKey": "string",                  // Row to copy
+//                                    "values": { "column": "value" }      // Replaced values; "" clears
+//                                  }
+//                                  Response: as for /api/insert
+//                                  Note: The copy needs a new unique key, or it is rejected as
+//                                  duplicateKey. An unknown rowKey is a 404
+//
+//   POST /api/bulk-edit/{tableKey} Update one or more columns across multiple rows
 //                                  Request body: {
 //                                    "keys": ["key1", "key2"],  // Row keys to update
 //                                    "column": "string",        // Column to update
-//                                    "value": "string"          // New value for all rows
+//                                    "mode": "string",          // set (default), replace, adjust,
+//                                                               // trim, upper or lower
+//                                    "find": "string",          // replace: text to replace
+//                                    "value": "string",         // set: new value for all rows;
+//                                                               // replace: replacement text;
+//                                                               // adjust: +n, -n, *n, /n, +n% or -n%
+//                                    "edits": [{ "column", "mode", "find", "value" }]
+//                                                               // Several columns, instead of column
 //                                  }
-//                                  Response: { "updated": int, "errors": [...] }
+//                                  Response: { "updated": int, "failed": int, "errors": [...] }
+//                                  Note: adjust is for numeric columns and keeps each value's
+//                                  decimal places; trim, upper and lower are for text columns.
+//                                  Empty values are left empty by every mode but set. All rows are
+//                                  written with one UPDATE in one transaction; a row failing any
+//                                  column is skipped
+//
+//   POST /api/bulk-edit/{tableKey}/preview
+//                                  The values a bulk edit would write, without writing them
+//                                  Request body: as for /api/bulk-edit/{tableKey}
+//                                  Response: {
+//                                    "changes": [{ "rowKey", "column", "oldValue", "newValue",
+//                                      "error": "string" }],  // Cells that change, or why rows fail
+//                                    "unchanged": int,
+//                                    "failed": int
+//                                  }
+//
+//   POST /api/undo/{tableKey}      Revert the table's most recent cell edit not yet undone
+//                                  Request body (optional): {
+//                                    "rowKey": "string"    // Only undo edits made to this row
+//                                  }
+//                                  Response: {
+//                                    "success": bool,
+//                                    "editId": "uuid",     // The cell_edit audit entry reverted
+//                                    "rowKey": "string",   // The row's key afterwards
+//                                    "column": "string",
+//                                    "value": "string",    // The value restored
+//                                    "conflict": bool,     // The cell changed since the edit
+//                                    "currentValue": "string",
+//                                    "rowMissing": bool,
+//                                    "duplicateKey": bool,
+//                                    "conflictingKey": "string"
+//                                  }
+//                                  Errors: 404 if there is nothing to undo; 409 with the body
+//                                  above on a conflict, in which case nothing is changed
+//                                  Note: Repeated calls walk back through the edit history.
+//                                  Recorded in the audit log as row_restore, linked to the edit
+//
+//   POST /api/redo/{tableKey}      Reapply the most recently undone cell edit
+//                                  Request body and response: as /api/undo/{tableKey}
+//                                  Note: A new edit to the table (or row) clears what can be redone
 //
 // =============================================================================
 // Reset API
 // =============================================================================
 //
 //   POST /api/reset/{tableKey}     Delete all data from a specific table
-//                                  Response: { "status": "reset" }
-//                                  Note: Creates audit log entry
+//                                  Query: ?async=true to run in the background
+//                                  Response: { "status": "reset" }, or with async
+//                                    202 { "operation_id": "uuid" }
+//                                  Note: Creates audit log entry. Deletes in batches of
+//                                  UPLOAD_DELETE_BATCH_SIZE within UPLOAD_RESET_TIMEOUT, as a
+//                                  "reset" operation with one step per table
 //
 //   POST /api/reset                Delete all data from ALL tables
-//                                  Response: { "status": "reset_all" }
-//                                  Note: Creates audit log entries for each table
+//                                  Query params:
+//                                    - async       (bool) Run in the background
+//                                    - incremental (bool) Commit each table on its own
+//                                  Response: {
+//                                    "operationId": "uuid",
+//                                    "incremental": bool,
+//                                    "tables": [{ "tableKey": "string",
+//                                      "status": "reset|failed|skipped|pending|rolled_back",
+//                                      "rowsDeleted": int, "reason": "string" }],
+//                                    "rowsDeleted": int,
+//                                    "auditBatchId": "uuid",
+//                                    "success": bool,
+//                                    "error": "string" (optional)
+//                                  }, 500 if any table was not reset; with async
+//                                    202 { "operation_id": "uuid" }
+//                                  Note: Tables are reset before the tables they reference,
+//                                  declared in References or by foreign key. By default all
+//                                  tables are reset in one transaction (steps: one per table,
+//                                  then commit), so any failure resets none. Incremental: a
+//                                  failed table does not stop the rest, but the tables it
+//                                  references are skipped. A transactional reset creates one
+//                                  table_reset audit entry with each table's rows deleted, an
+//                                  incremental one an entry per table; they have auditBatchId
+//
+//   POST /api/reset/resume/{operationID}
+//                                  Finish an incremental reset of all tables that failed, was
+//                                  cancelled or was interrupted by a restart; tables it reset
+//                                  are skipped
+//                                  Query: ?async=true to run in the background
+//                                  Response: same as POST /api/reset, plus "resumedFrom": "uuid"
+//                                  (404 if the operation is unknown, 409 if it is not a finished,
+//                                  incomplete, incremental reset of all tables)
+//
+//   Note: With APPROVAL_ROW_THRESHOLD set, a reset (POST /api/reset/{tableKey} or /api/reset)
+//   or rollback (POST /api/rollback/{uploadID}, /api/rollback-range/{tableKey} or
+//   /api/upload-batch/{batchID}/rollback) that would remove more rows than the threshold is
+//   not run. It returns 202 { "status": "pending_approval", "message": "string",
+//   "approval": { ... } } and runs once a second user approves it (see POST /api/approvals/{id})
+//
+//   POST /api/tables/{tableKey}/actions/{action}
+//                                  Run a custom table action in the background as a table_action
+//                                  operation; the outcome is recorded in the audit log
+//                                  Request body (optional): { "params": { "name": "value" } }
+//                                  Response: 202 { "operation_id": "uuid" }, also in X-Operation-ID
+//                                  (404 if the table or action is unknown, 409 if the action is
+//                                  already running on the table)
+//
+//   GET  /api/uploads/reviews      List uploads with their review status, newest first
+//                                  Query params:
+//                                    - table  (string) Only uploads to this table
+//                                    - review (string) needs-review, approved, archived, or none
+//                                    - limit  (int)    Max uploads (default: 100, max: 1000)
+//                                  Response: [{ "upload_id", "table_key", "file_name", "uploaded_at",
+//                                    "status": "active|rolled_back", "review_status",
+//                                    "reviewed_at", "reviewed_by", "review_note" }]
+//
+//   GET  /api/upload/{uploadID}/review
+//                                  One upload's review status (same fields as above)
+//
+//   POST /api/upload/{uploadID}/review
+//                                  Move an upload through the review workflow
+//                                  Request: { "status": "needs-review|approved|archived",
+//                                             "reviewer": "string", "note": "string" }
+//                                  Response: the upload's review status
+//                                  Note: Allowed moves are (none) -> needs-review|approved,
+//                                  needs-review -> approved, approved -> needs-review|archived,
+//                                  archived -> needs-review; others return 409. Approving,
+//                                  archiving or reopening a signed-off upload needs an
+//                                  X-API-Key from REVIEWER_API_KEYS when that is set (403
+//                                  otherwise). Rolled-back uploads cannot be approved. Changes
+//                                  are recorded in the audit log as upload_review
 //
 //   POST /api/rollback/{uploadID}  Rollback an upload (delete all rows from that upload)
+//                                  Query: ?async=true to run in the background
 //                                  Response: {
 //                                    "success": bool,
 //                                    "deleted": int,
+//                                    "operationId": "uuid",
+//                                    "error": "string" (optional)
+//                                  }, or with async 202 { "operation_id": "uuid" }
+//                                  Note: Deletes in batches as a "rollback" operation (steps
+//                                  delete and mark). A rollback cancelled or timed out part
+//                                  way keeps the rows deleted so far and leaves the upload
+//                                  active; run it again to finish
+//
+//   GET  /api/rollback-range/{tableKey}
+//                                  Preview a bulk rollback of all active uploads in a date range
+//                                  Query params:
+//                                    - from     (string) Start, RFC3339 or YYYY-MM-DD (required)
+//                                    - to       (string) End, RFC3339 or YYYY-MM-DD inclusive (required)
+//                                  Response: {
+//                                    "tableKey": "string",
+//                                    "preview": true,
+//                                    "uploads": [{ "uploadId": "uuid", "fileName": "string", "uploadedAt": "string", "rowsToDelete": int }],
+//                                    "rowsToDelete": int
+//                                  }
+//                                  Errors: 404 for an unknown table; 400 for a table without
+//                                  rollback support or a range ending before it starts
+//
+//   POST /api/rollback-range/{tableKey}
+//                                  Roll back all active uploads in a date range, newest first
+//                                  Query params: same as preview, plus
+//                                    - async    (bool) Run in the background
+//                                  Response: same as preview, plus "rowsDeleted": int,
+//                                  "operationId": "uuid", "success": bool; with async 202
+//                                  { "operation_id": "uuid" } (200 with the preview when the
+//                                  range is empty)
+//                                  Note: Runs as a "rollback" operation with a step per upload,
+//                                  each rolled back in batches like /api/rollback. A run
+//                                  cancelled, timed out or failed part way leaves the remaining
+//                                  uploads active; run it again to finish
+//
+//   POST /api/upload-batch/{batchID}/rollback
+//                                  Roll back every active upload in a batch, newest first
+//                                  Response: {
+//                                    "batchId": "uuid",
+//                                    "uploads": [{ "uploadId": "uuid", "tableKey": "string", "rowsDeleted": int, "success": true }],
+//                                    "rowsDeleted": int,
+//                                    "success": bool,
 //                                    "error": "string" (optional)
 //                                  }
+//                                  Note: Runs in one transaction; refused while files are still queued
+//                                  or processing
+//
+// =============================================================================
+// Admin API
+// =============================================================================
+//
+//   POST /api/admin/bootstrap      Apply a declarative bootstrap file (same format as BOOTSTRAP_FILE)
+//                                  Query params:
+//                                    - dryRun   (bool) Compute the diff without applying it
+//                                  Request body: {
+//                                    "tables": [{ "key": "string", "enumValues": { "column": ["value"] },
+//                                                 "limits": { "maxFileBytes": int, "maxRows": int,
+//                                                             "maxUploadsPerDay": int } }],
+//                                    "templates": [{ "tableKey": "string", "name": "string",
+//                                                    "columnMapping": {...}, "csvHeaders": [...] }]
+//                                  }
+//                                  Response: {
+//                                    "dryRun": bool,
+//                                    "changes": [{ "kind": "string", "target": "string",
+//                                                  "action": "create|update|unchanged|skipped|error",
+//                                                  "detail": "string" }],
+//                                    "applied": int,
+//                                    "errors": int
+//                                  }
+//                                  Note: Idempotent; nothing absent from the file is deleted.
+//                                  Enum and limit changes are in-memory and should also be in BOOTSTRAP_FILE
+//                                  to survive restarts. Applied enum and limit changes create
+//                                  table_config audit entries; templates create template_create
+//                                  and template_update entries. freezeWindows, webhooks and roles
+//                                  sections are skipped (webhooks are set with WEBHOOK_URLS).
+//
+//   GET  /api/admin/users          List user accounts, disabled ones included
+//                                  Response: { "users": [{ "id", "email", "name", "role",
+//                                              "createdAt", "lastLoginAt", "disabledAt",
+//                                              "aggregatesOnly" }] }
+//
+//   POST /api/admin/users          Create a user account
+//                                  Request body: { "email": "string", "name": "string",
+//                                                  "password": "string" (12+ characters),
+//                                                  "role": "viewer" | "editor" | "admin" (default viewer),
+//                                                  "aggregatesOnly": bool (optional) }
+//                                  Response: 201 Created with the user
+//                                  Errors: 400 invalid, 409 email already taken
+//                                  Note: Creates a user_create audit entry. An aggregatesOnly
+//                                  user may only use /api/summary, /api/histogram, /api/tables
+//                                  and their own session; other routes get 403
+//                                  AUTH_AGGREGATES_ONLY
+//
+//   DELETE /api/admin/users/{id}   Disable a user and end their sessions; the account is kept
+//                                  so audit entries still name them
+//                                  Response: the user
+//                                  Note: Creates a user_disable audit entry
+//
+//   GET  /api/admin/tokens         List API tokens, revoked and expired ones included
+//                                  Response: { "tokens": [{ "id", "name", "prefix", "scope",
+//                                              "tables": [], "createdBy", "ownerId", "createdAt",
+//                                              "expiresAt", "lastUsedAt", "revokedAt" }] }
+//
+//   POST /api/admin/tokens         Create an API token, sent as X-API-Key
+//                                  Request body: { "name": "string",
+//                                                  "scope": "aggregate|read|write|destructive",
+//                                                  "tables": ["tableKey"] (optional; default all),
+//                                                  "expiresAt": "RFC3339" (optional) }
+//                                  Response: 201 Created with the token and "token": "tok_..."
+//                                  (shown only now; only its hash is stored)
+//                                  Errors: 400 invalid
+//                                  Note: aggregate allows only summaries, histograms and the
+//                                  table list, read GET requests, write all others, and
+//                                  destructive also this group. A token limited to tables may
+//                                  only use routes naming one of them, by {tableKey} or by an
+//                                  upload or export job of the table, and read the table list,
+//                                  /api/auth/me, /api/upload-queue-status and
+//                                  /api/webhooks/schema. Requests out of scope get 403
+//                                  AUTH_TOKEN_SCOPE; unknown or revoked tokens 401
+//                                  AUTH_INVALID_TOKEN, expired ones 401 AUTH_TOKEN_EXPIRED
+//
+//   DELETE /api/admin/tokens/{id}  Revoke an API token at once; it is kept so audit entries
+//                                  still name it
+//                                  Response: the token
+//
+//   GET  /api/admin/auth-lockouts  List client IPs with recent failed API key attempts
+//                                  Response: [{ "ip": "string", "failures": int, "lastFailure": "string",
+//                                               "lockedUntil": "string" (only while locked) }]
+//
+//   DELETE /api/admin/auth-lockouts/{ip}
+//                                  Clear failures and any lockout for a client IP
+//                                  Response: { "status": "unlocked", "ip": "string" }
+//                                  Note: Creates audit log entry
+//
+//   GET  /api/admin/archive        The archive scheduler and its recent runs
+//                                  Query params:
+//                                    - limit        (int)    Runs to list (default 20, max 500)
+//                                  Response: { "scheduled": bool, "interval": "24h0m0s",
+//                                    "nextRunAt": "RFC3339", "runningId": "string",
+//                                    "runs": [{ "id", "trigger": "schedule|catch_up|manual",
+//                                      "initiator", "startedAt", "finishedAt", "durationMs",
+//                                      "entriesArchived", "entriesPurged", "failedRowsCompacted",
+//                                      "recycledRowsPurged", "archivesExpired", "error",
+//                                      "interrupted" }] }
+//                                  Note: Runs are due ARCHIVE_CHECK_INTERVAL after the last run
+//                                  that was not interrupted, across restarts; one missed while
+//                                  the server was down runs at startup as catch_up
+//
+//   POST /api/admin/archive/run    Run the archive job now
+//                                  Response: 202 Accepted with { "operation_id": "string" } and
+//                                  X-Operation-ID; follow it at /api/operations/{id}/progress
+//                                  Errors: 409 a run is in progress, 503 scheduler not started
+//                                  Note: The next scheduled run is due ARCHIVE_CHECK_INTERVAL
+//                                  after this one
+//
+//   GET  /api/admin/spool          Report encrypted spooled uploads (see UPLOAD_SPOOL_KEYS)
+//                                  Response: {
+//                                    "enabled": bool, "dir": "string",
+//                                    "activeKeyId": "string", "keyIds": ["string"],
+//                                    "artifacts": [{ "id": "string", "keyId": "string", "diskBytes": int,
+//                                                    "createdAt": "string", "inUse": bool,
+//                                                    "error": "string" (if the header is unreadable) }],
+//                                    "stale": int,     // artifacts not on the active key
+//                                    "orphaned": int   // artifacts not being processed
+//                                  }
+//
+//   POST /api/admin/spool/rotate   Re-encrypt orphaned artifacts with the active key
+//                                  Response: { "rotated": int, "skipped": int, "failed": int,
+//                                              "errors": ["string"] }
+//                                  Note: Once "stale" is 0, old keys can be removed from UPLOAD_SPOOL_KEYS
+//
+//   DELETE /api/admin/spool/{id}   Delete an orphaned spooled upload
+//                                  Response: { "status": "deleted", "id": "string" }
+//                                  Errors: 404 not found or spooling disabled, 409 in use
+//
+//   GET  /api/admin/legal-holds    List legal holds, newest first
+//                                  Query params: table, released ("true" includes released holds)
+//                                  Response: { "holds": [{
+//                                    "id": "string", "tableKey": "string", "name": "string",
+//                                    "reason": "string", "filters": [{ "column", "op", "value" }],
+//                                    "createdAt": "string", "releasedAt": "string" (once released),
+//                                    "releaseReason": "string" }] }
+//
+//   POST /api/admin/legal-holds    Place a legal hold on a table, or on the rows matching filters
+//                                  Request: { "table": "string", "name": "string", "reason": "string",
+//                                             "filters": [{ "column", "op", "value" }] (optional) }
+//                                  Response: 201 with the hold
+//                                  Errors: 400 invalid hold, 404 unknown table
+//                                  Note: Creates a critical legal_hold_set audit entry. While the
+//                                  hold is active, resets, rollbacks, row deletes and recycle bin
+//                                  purges that would remove held rows return 409, replace and
+//                                  upsert uploads that would remove them fail, and retention
+//                                  purges skip held rows
+//
+//   POST /api/admin/legal-holds/{id}/release
+//                                  Release a legal hold
+//                                  Request: { "reason": "string" } (required)
+//                                  Response: the released hold
+//                                  Errors: 400 no reason or already released, 404 not found
+//                                  Note: Creates a critical legal_hold_release audit entry
+//
+//   GET  /api/approvals            List resets and rollbacks awaiting approval, newest first
+//                                  (see APPROVAL_ROW_THRESHOLD)
+//                                  Query params: all ("true" includes decided and expired ones), limit
+//                                  Response: { "approvals": [{
+//                                    "id": "uuid", "kind": "reset|reset_all|rollback|rollback_range|rollback_batch",
+//                                    "tableKey": "string", "target": "string" (upload or batch ID),
+//                                    "params": { "from", "to", "incremental" }, "rows": int,
+//                                    "status": "pending|approved|rejected|expired",
+//                                    "requestedBy": "string", "requestedAt": "string", "expiresAt": "string",
+//                                    "decidedBy": "string", "decidedAt": "string", "note": "string",
+//                                    "operationId": "uuid", "error": "string" }] }
+//
+//   GET  /api/approvals/{id}       One approval, same shape as a list entry
+//                                  Errors: 404 not found
+//
+//   POST /api/approvals/{id}       Approve or reject a pending approval
+//                                  Request: { "decision": "approve|reject", "note": "string" (optional) }
+//                                  Response: the decided approval. Approving runs the operation
+//                                  as requested: resets and single rollbacks in the background,
+//                                  with "operationId" (also in X-Operation-ID); range and batch
+//                                  rollbacks before responding. If it fails, "error" says why; a
+//                                  range rollback fails if the range's uploads changed since the
+//                                  request
+//                                  Errors: 400 bad decision, 403 approver is the requester (a
+//                                  token counts as its owner) or neither a signed-in user nor
+//                                  using a token a user owns, 404 not found, 409 already decided
+//                                  or expired
+//                                  Note: Creates approval_request, approval_grant and approval_reject
+//                                  audit entries; the operation's own entries name the requester
+//                                  and the approver
+//
+//   GET  /api/admin/retention-archives
+//                                  List archives of rows purged by retention (see
+//                                  ARCHIVE_RETENTION_EXPORT_DIR), newest first
+//                                  Query params: kind (recycle_bin, audit_archive), table
+//                                  Response: { "archives": [{
+//                                    "id": "string", "kind": "string", "tableKey": "string",
+//                                    "rows": int, "cutoff": "string", "createdAt": "string",
+//                                    "expiresAt": "string", "fileName": "string",
+//                                    "size": int, "sha256": "string" }] }
+//                                  Errors: 404 retention archives disabled
+//
+//   GET  /api/admin/retention-archives/{id}
+//                                  Download a retention archive (gzipped NDJSON, one row per line)
+//                                  Headers: X-Checksum-SHA256 (hex SHA-256 of the file)
+//                                  Errors: 404 not found or retention archives disabled
+//                                  Note: Creates a data_export audit log entry
+//
+//   GET  /api/admin/statistics/{tableKey}
+//                                  Report extended statistics (TableDefinition.Statistics) and
+//                                  analyze freshness for a table
+//                                  Response: {
+//                                    "tableKey": "string",
+//                                    "lastAnalyze": "string" (omitted if never analyzed),
+//                                    "modifiedSinceAnalyze": int,
+//                                    "objects": [{ "name": "string", "columns": ["string"],
+//                                                  "kinds": ["ndistinct|dependencies|mcv"],
+//                                                  "declared": bool, "exists": bool,
+//                                                  "nDistinct": "string", "dependencies": "string" }]
+//                                  }
+//                                  Note: Declared statistics are created and the table re-analyzed
+//                                  in the background after each upload that inserts rows
+//
+//   POST /api/admin/statistics/{tableKey}/refresh
+//                                  Create missing declared statistics and ANALYZE the table now
+//                                  Response: same as GET
+//
+//   POST /api/admin/audit-log/import
+//                                  Import an audit-log export from another deployment into
+//                                  the archive, with provenance
+//                                  Content-Type: multipart/form-data
+//                                  Form fields:
+//                                    - file     (file)   CSV from /api/audit-log/export, or JSON
+//                                                        (array or stream of audit entries)
+//                                    - source   (string) Label for the origin deployment (required)
+//                                    - format   (string) Optional: csv or json (default: detect)
+//                                    - timezone (string) Optional IANA zone of zone-less CSV
+//                                                        timestamps (default: UTC)
+//                                  Response: {
+//                                    "id": "string", "source": "string", "fileName": "string",
+//                                    "format": "csv|json", "fileSha256": "string",
+//                                    "chainDigest": "string",   // chained SHA-256 over entries in file order
+//                                    "entriesTotal": int, "entriesImported": int,
+//                                    "entriesSkipped": int,     // IDs already present
+//                                    "firstEntryAt": "string", "lastEntryAt": "string",
+//                                    "notes": ["string"],       // continuity: ordering, gaps, overlaps
+//                                    "importedAt": "string"
+//                                  }
+//                                  Errors: 400 { "error", "invalid": int, "errors": ["line N: ..."] }
+//                                  if any entry is invalid; nothing is imported
+//                                  Note: Idempotent per entry ID; creates audit log entry
+//
+//   GET  /api/admin/audit-log/imports
+//                                  List audit imports, newest first
+//                                  Response: [same as import response]
+//
+//   GET  /api/admin/schema/deprecated
+//                                  List declared column renames still referred to by old name
+//                                  Response: [{
+//                                    "tableKey": "string", "from": "string", "to": "string",
+//                                    "templates": [{ "id": "uuid", "name": "string" }],
+//                                    "uses": [{ "source": "upload mapping|preview mapping|template|sort|filter|backfill|summary",
+//                                               "count": int, "lastUsed": "RFC3339" }],
+//                                    "inUse": bool
+//                                  }]
+//                                  Note: Uses are counted since server start. A rename that is not
+//                                  in use can be removed from the registry
+//
+//   GET  /api/admin/schema/migration
+//                                  Preview the migration bringing the database in line with the
+//                                  registry (renamed, added and dropped columns; import templates
+//                                  rewritten to current names), as written by cmd/schemamigrate
+//                                  Query: ?table=key1,key2 (default: all tables)
+//                                  Response: text/plain goose migration, or 204 if up to date
+//
+//   POST /api/admin/backfill/{tableKey}
+//                                  Populate a column of existing rows in batches, e.g. one just
+//                                  added by a schema migration. Runs in the background
+//                                  Body: { "column": "string",
+//                                          "source": {
+//                                            "kind": "reparse|compute|lookup",
+//                                            "column": "string",          // reparse, compute
+//                                            "function": "year|month|quarter|fiscal_year|
+//                                                         fiscal_quarter|upper|lower|trim",
+//                                            "fiscalYearStartMonth": int, // 1-12, default 1
+//                                            "lookupTable": "key", "matchColumn": "string",
+//                                            "lookupKey": "string", "lookupValue": "string",
+//                                            "overwrite": bool, "batchSize": int
+//                                          } }
+//                                  Response: 202 { "operation_id": "uuid" }
+//                                  Note: Only NULL values are filled unless overwrite is set.
+//                                  Follow progress at /api/operations/{operationID}/progress;
+//                                  the result is recorded in the audit log as column_backfill
 //
 // =============================================================================
 // Audit API
@@ -282,6 +1560,8 @@ func (s *Server) setupMiddleware() {
 //                                    ID, Timestamp, Action, Severity, Table, User Email,
 //                                    User Name, IP Address, Row Key, Column, Old Value,
 //                                    New Value, Rows Affected, Upload ID, Reason
+//                                  Note: Recorded in the audit log as data_export with the
+//                                  filters and entry count
 //
 //   GET  /api/audit-log/{id}       Get detail view for a single audit entry
 //                                  Response: HTML partial with entry details
@@ -301,6 +1581,31 @@ func (s *Server) setupMiddleware() {
 //                                    - headers (string) Comma-separated list of CSV column headers
 //                                  Response: [{ template with match score }]
 //
+//   GET  /api/import-templates/{tableKey}/proposals
+//                                  Propose templates from the header rows of past uploads
+//                                  Query params:
+//                                    - minUploads (int) Uploads that must share a header row (default: 2)
+//                                  Response: [{
+//                                    "signature": "string",         // Identifies the header row
+//                                    "name": "string",
+//                                    "csvHeaders": [...],
+//                                    "columnMapping": { "column": csvIndex },
+//                                    "unmapped": ["column"],        // Columns no header matched
+//                                    "uploads": int,
+//                                    "lastFileName": "string",
+//                                    "lastUploadAt": "timestamp",
+//                                    "existingTemplate": "string"   // Saved template with these headers
+//                                  }]
+//
+//   POST /api/import-templates/{tableKey}/proposals
+//                                  Save proposed templates
+//                                  Request body: {
+//                                    "signatures": ["string"],  // Proposals to save (default: all)
+//                                    "minUploads": int          // As for GET
+//                                  }
+//                                  Response: [{ template }] (201 Created)
+//                                  Note: Proposals an existing template covers are skipped
+//
 //   GET  /api/import-template/{id} Get a single template by ID
 //                                  Response: { "id": "uuid", "tableKey": "string", "name": "string", "columnMapping": {...}, "csvHeaders": [...] }
 //
@@ -309,15 +1614,39 @@ func (s *Server) setupMiddleware() {
 //                                    "tableKey": "string",
 //                                    "name": "string",
 //                                    "columnMapping": { "dbColumn": csvIndex },
-//                                    "csvHeaders": ["header1", "header2"]
+//                                    "csvHeaders": ["header1", "header2"],
+//                                    "fixedWidth": {                  (optional)
+//                                      "columns": [{ "name": "string", "start": int, "length": int }],
+//                                      "skipLines": int
+//                                    },
+//                                    "transforms": [                  (optional)
+//                                      { "op": "join", "columns": [csvIndex, ...], "fields": ["dbColumn"],
+//                                        "separator": "string" },
+//                                      { "op": "split", "columns": [csvIndex], "fields": ["dbColumn", ...],
+//                                        "separator": "string" }
+//                                    ]
 //                                  }
 //                                  Response: { created template } (201 Created)
+//                                  Note: A fixed-width template slices each line at the 1-based
+//                                  character positions of its columns, which then act as CSV
+//                                  columns with the layout's names as headers. Without a
+//                                  columnMapping, table columns map to layout columns of the same
+//                                  name. Columns must not overlap (400)
+//                                  Note: A join fills one column with several CSV columns' values
+//                                  (empty ones left out); a split fills several columns with the
+//                                  parts of one, the last taking the rest. They apply whenever the
+//                                  template supplies an upload's mapping, and win over the mapping
+//                                  for the columns they fill. Unknown ops or columns, or a column
+//                                  filled twice, are rejected (400)
 //
 //   PUT  /api/import-template/{id} Update an existing template
 //                                  Request body: {
 //                                    "name": "string",
 //                                    "columnMapping": { "dbColumn": csvIndex },
-//                                    "csvHeaders": ["header1", "header2"]
+//                                    "csvHeaders": ["header1", "header2"],
+//                                    "fixedWidth": { layout }         (optional, as for create)
+//                                    "transforms": [...]              (optional, as for create; replaces
+//                                                                      the template's transforms)
 //                                  }
 //                                  Response: { updated template }
 //
@@ -326,6 +1655,72 @@ func (s *Server) setupMiddleware() {
 //                                  Response: { "status": "deleted" }
 //
 // =============================================================================
+// Saved Views API
+// =============================================================================
+// A saved view is a named search, filter set, sort order and list of visible
+// columns for a table. Open one with GET /table/{tableKey}?view={id}.
+//
+//   GET  /api/views/{tableKey}     List a table's saved views, ordered by name
+//                                  Response: [{ saved view }]
+//
+//   POST /api/views/{tableKey}     Save a new view
+//                                  Request body: {
+//                                    "name": "Q4 open invoices",
+//                                    "search": "string",
+//                                    "filters": [{ "column": "Status", "op": "eq", "value": "Open" }],
+//                                    "sorts": [{ "column": "Due Date", "dir": "asc", "nulls": "last" }],
+//                                    "columns": ["Invoice", "Customer", "Due Date"]
+//                                  }
+//                                  Operators are those of filter[col] on /table/{tableKey}; a filter
+//                                  may also set "group" (as in filter[col][group]) and "not": true
+//                                  (max QUERY_MAX_SORT_LEVELS sorts; "nulls" is optional, "first" or
+//                                  "last"; empty columns shows all)
+//                                  Response: { "id": "uuid", "tableKey": "string", "name": "string",
+//                                    "search", "filters", "sorts", "columns",
+//                                    "createdAt", "updatedAt" } (201 Created)
+//                                  Errors: 400 unknown column or invalid operator,
+//                                  409 name already used for the table
+//
+//   GET  /api/views/{tableKey}/{id}
+//                                  Get a single saved view
+//
+//   PUT  /api/views/{tableKey}/{id}
+//                                  Replace a saved view (same body as POST)
+//                                  Response: { updated view }
+//
+//   DELETE /api/views/{tableKey}/{id}
+//                                  Delete a saved view
+//                                  Response: { "status": "deleted" }
+//
+// =============================================================================
+// Destructive Endpoint Protection
+// =============================================================================
+// Delete, update, bulk edit, template/snapshot mutations, reset, rollback, and
+// admin endpoints pass two independent checks, in order:
+//
+//   1. Network policy: the client IP (after TRUSTED_PROXIES resolution) must be
+//      in DESTRUCTIVE_ALLOW_CIDRS when set, and must not resolve to a country
+//      in DESTRUCTIVE_DENY_COUNTRIES or an ASN in DESTRUCTIVE_DENY_ASNS. The
+//      country/ASN lookup uses the resolver installed with SetIPResolver and
+//      fails closed. Denied requests get 403 with code AUTH_NETWORK_DENIED.
+//   2. API key: X-API-Key when REQUIRE_API_KEY is true. Wrong keys are
+//      tracked per client IP; a missing key is not counted. After 3 failures
+//      further attempts are delayed (1s doubling to 30s), and
+//      AUTH_MAX_FAILURES within AUTH_FAILURE_WINDOW locks the IP out for
+//      AUTH_LOCKOUT_DURATION, even with a valid key.
+//      Throttled requests get 429 with Retry-After and code AUTH_THROTTLED
+//      or AUTH_LOCKED; lockouts create audit log entries.
+//
+// =============================================================================
+// Idempotent Retries
+// =============================================================================
+// Any non-GET /api request may carry an "Idempotency-Key" header. The first
+// request with a given key runs normally; repeats within SERVER_IDEMPOTENCY_TTL
+// replay the stored response (with "Idempotent-Replayed: true") instead of
+// running again. 5xx responses are not stored. The Go client in api/client
+// sets this header automatically and reuses it across retries.
+//
+// =============================================================================
 // Error Response Format
 // =============================================================================
 // All endpoints return errors in a consistent JSON format:
@@ -344,6 +1739,10 @@ func (s *Server) setupMiddleware() {
 //   - 429 Too Many Requests: Rate limit exceeded (includes Retry-After header)
 //   - 500 Internal Server Error: Server-side error
 //
+// Refusals (401/403 on destructive endpoints, network policy denials, writes
+// to views, 429s on mutating requests) are recorded in the audit log as
+// access_denied or request_rejected.
+//
 // =============================================================================
 func (s *Server) setupRoutes() {
 	// Static files (HTMX, Tailwind CSS)
@@ -362,19 +1761,34 @@ func (s *Server) setupRoutes() {
 		r.Get("/upload/{uploadID}", s.handleUploadDetail)
 		r.Get("/audit-log", s.handleAuditLog)
 		r.Get("/settings", s.handleSettings)
+		r.Get("/login", s.handleLoginPage)
+		r.Post("/login", s.handleLoginForm)
 	})
 
 	// API routes
 	s.router.Route("/api", func(r chi.Router) {
+		// Replay responses for retried mutations (Idempotency-Key header)
+		r.Use(mw.Idempotency(s.cfg.Server.IdempotencyTTL))
+
 		// =================================================================
 		// Streaming routes (NO timeout - these can run indefinitely)
 		// =================================================================
 		// SSE progress stream - stays open until upload completes
 		r.Get("/upload/{uploadID}/progress", s.handleUploadProgress)
+		r.Get("/operations/{operationID}/progress", s.handleOperationProgress)
+		// SSE table card updates - stays open while the dashboard is
+		r.Get("/events/tables", s.handleTableEvents)
+		// SSE activity feed - stays open while the page is
+		r.Get("/events", s.handleActivityEvents)
 		// CSV exports - may take time for large datasets
 		r.Get("/export/{tableKey}", s.handleExportData)
+		r.Get("/export-job/{operationID}/download", s.handleDownloadExportJob)
 		r.Get("/audit-log/export", s.handleAuditLogExport)
 		r.Get("/upload/{uploadID}/failed-rows", s.handleExportFailedRows)
+		r.Get("/upload/{uploadID}/evidence", s.handleUploadEvidence)
+		// Export snapshots - hash every matching row
+		r.Post("/snapshots/{tableKey}", s.handleCreateSnapshot)
+		r.Get("/snapshot/{id}/diff", s.handleSnapshotDiff)
 
 		// =================================================================
 		// Standard API routes (WITH timeout)
@@ -382,11 +1796,33 @@ func (s *Server) setupRoutes() {
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.Timeout(s.cfg.Server.RequestTimeout))
 
+			// Sign in and out
+			r.Post("/auth/login", s.handleLogin)
+			r.Post("/auth/logout", s.handleLogout)
+			r.Get("/auth/me", s.handleCurrentUser)
+
 			// System status
 			r.Get("/upload-queue-status", s.handleUploadQueueStatus)
+			r.Get("/webhooks/schema", s.handleWebhookSchema)
 
 			// Table listing
 			r.Get("/tables", s.handleListTables)
+			r.Get("/tables/{tableKey}/renames", s.handleColumnRenames)
+			r.Get("/tables/{tableKey}/key-violations", s.handleKeyViolations)
+			r.Get("/tables/{tableKey}/actions", s.handleListTableActions)
+
+			// Table data as JSON (page or cursor pagination)
+			r.Get("/data/{tableKey}", s.handleTableData)
+
+			// Grouped aggregations
+			r.Get("/summary/{tableKey}", s.handleSummary)
+			r.Get("/histogram/{tableKey}", s.handleHistogram)
+
+			// Column-level data quality
+			r.Get("/quality/{tableKey}", s.handleDataQuality)
+
+			// Background exports (the file is written after the response)
+			r.Post("/export-jobs/{tableKey}", s.handleStartExportJob)
 
 			// Template download
 			r.Get("/template/{tableKey}", s.handleDownloadTemplate)
@@ -394,57 +1830,190 @@ func (s *Server) setupRoutes() {
 			// Upload history
 			r.Get("/history/{tableKey}", s.handleUploadHistory)
 
+			// Recently deleted rows
+			r.Get("/deleted/{tableKey}", s.handleDeletedRows)
+			r.Get("/recycle/{tableKey}", s.handleRecycledRows)
+			r.Get("/row-history/{tableKey}", s.handleRowHistory)
+
+			// Bulk rollback preview
+			r.Get("/rollback-range/{tableKey}", s.handleRollbackRange)
+
 			// Upload operations (with stricter rate limit if configured)
 			r.Group(func(r chi.Router) {
 				if s.cfg.Rate.Enabled && s.cfg.Rate.UploadLimit > 0 {
-					uploadLimiter := newRateLimiter(s.cfg.Rate.UploadLimit, time.Minute)
+					uploadLimiter := newRateLimiter(s.cfg.Rate.UploadLimit, time.Minute, s.auditRateLimited)
 					r.Use(uploadLimiter.middleware)
 				}
-				r.Post("/upload/{tableKey}", s.handleUpload)
-				r.Post("/preview/{tableKey}", s.handlePreview)
+				r.With(s.requireWritable).Post("/upload/{tableKey}", s.handleUpload)
+				r.With(s.requireWritable).Post("/upload/{tableKey}/from-url", s.handleUploadFromURL)
+				r.With(s.requireWritable).Post("/upload-paste/{tableKey}", s.handleUploadPaste)
+				r.Post("/upload-batch", s.handleUploadBatch)
+				r.Post("/upload-batch/{batchID}/files", s.handleAttachToUploadBatch)
+				r.Post("/upload-route", s.handleRoutedUpload)
+				r.With(s.requireWritable).Post("/preview/{tableKey}", s.handlePreview)
+				r.With(s.requireWritable).Post("/preview/{tableKey}/fixed-width", s.handlePreviewFixedWidth)
+				r.With(s.requireWritable).Post("/validate/{tableKey}", s.handleValidate)
+				r.With(s.requireWritable).Post("/resumable-upload/{tableKey}", s.handleCreateResumableUpload)
 			})
 
+			// Resumable upload chunks and state (no stricter rate limit: one
+			// upload sends many chunks)
+			r.Put("/resumable-upload/{sessionID}/chunk", s.handleAppendResumableChunk)
+			r.Get("/resumable-upload/{sessionID}", s.handleGetResumableUpload)
+			r.Post("/resumable-upload/{sessionID}/complete", s.handleCompleteResumableUpload)
+			r.Delete("/resumable-upload/{sessionID}", s.handleCancelResumableUpload)
+			r.Get("/my/pending-uploads", s.handlePendingUploads)
+
 			// Upload read operations (no stricter rate limit)
 			r.Get("/upload/{uploadID}/result", s.handleUploadResult)
+			r.Get("/upload/{uploadID}/review", s.handleGetUploadReview)
+			r.Get("/uploads/reviews", s.handleListUploadReviews)
 			r.Post("/upload/{uploadID}/cancel", s.handleCancelUpload)
+			r.Get("/upload-batch/{batchID}", s.handleUploadBatchStatus)
+			r.Get("/operations", s.handleListOperations)
+			r.Get("/operations/{operationID}", s.handleOperationStatus)
+			r.Post("/operations/{operationID}/cancel", s.handleCancelOperation)
 
 			// Duplicate check
 			r.Post("/check-duplicates/{tableKey}", s.handleCheckDuplicates)
 
+			// Single-value validation (inline editor, external forms)
+			r.Post("/validate-value/{tableKey}/{column}", s.handleValidateValue)
+
 			// Audit log entry detail
 			r.Get("/audit-log/{id}", s.handleAuditLogEntry)
 
+			// Exports recorded with a watermark
+			r.Get("/export-watermark/{watermark}", s.handleTraceExportWatermark)
+
 			// Import templates (read operations)
 			r.Get("/import-templates/{tableKey}", s.handleListTemplates)
 			r.Get("/import-templates/{tableKey}/match", s.handleMatchTemplates)
+			r.Get("/import-templates/{tableKey}/proposals", s.handleProposeTemplates)
+			r.Post("/import-templates/{tableKey}/proposals", s.handleCreateProposedTemplates)
 			r.Get("/import-template/{id}", s.handleGetTemplate)
 			r.Post("/import-template", s.handleCreateTemplate)
 
+			// Saved views
+			r.Get("/views/{tableKey}", s.handleListSavedViews)
+			r.Post("/views/{tableKey}", s.handleCreateSavedView)
+			r.Get("/views/{tableKey}/{id}", s.handleGetSavedView)
+			r.Put("/views/{tableKey}/{id}", s.handleUpdateSavedView)
+			r.Delete("/views/{tableKey}/{id}", s.handleDeleteSavedView)
+
+			// Export snapshots (read operations)
+			r.Get("/snapshots/{tableKey}", s.handleListSnapshots)
+
 			// =============================================================
-			// Destructive operations (protected by API key when enabled)
+			// Destructive operations (protected by network policy, and
+			// API key when enabled or a destructive-scope API token)
 			// =============================================================
 			r.Group(func(r chi.Router) {
-				r.Use(mw.APIKeyAuth(&s.cfg.Security))
+				r.Use(mw.NetworkPolicy(&s.cfg.Security, mw.IPResolverFunc(s.resolveNetwork), s.auditMiddlewareDenial))
+				r.Use(s.destructiveAuth)
 
 				// Delete rows
-				r.Post("/delete/{tableKey}", s.handleDeleteRows)
+				r.With(s.requireWritable).Post("/delete/{tableKey}", s.handleDeleteRows)
+
+				// Restore deleted rows
+				r.With(s.requireWritable).Post("/restore/{tableKey}", s.handleRestoreRows)
+
+				// Soft-delete recycle bin
+				r.With(s.requireWritable).Post("/recycle/{tableKey}/restore", s.handleRestoreRecycled)
+				r.With(s.requireWritable).Post("/recycle/{tableKey}/purge", s.handlePurgeRecycled)
 
 				// Update cell
-				r.Post("/update/{tableKey}", s.handleUpdateCell)
+				r.With(s.requireWritable).Post("/update/{tableKey}", s.handleUpdateCell)
+
+				// Insert row
+				r.With(s.requireWritable).Post("/insert/{tableKey}", s.handleInsertRow)
+
+				// Clone row
+				r.With(s.requireWritable).Post("/clone/{tableKey}", s.handleCloneRow)
 
 				// Bulk edit
-				r.Post("/bulk-edit/{tableKey}", s.handleBulkEdit)
+				r.With(s.requireWritable).Post("/bulk-edit/{tableKey}", s.handleBulkEdit)
+				r.With(s.requireWritable).Post("/bulk-edit/{tableKey}/preview", s.handleBulkEditPreview)
+
+				// Cell edit undo/redo
+				r.With(s.requireWritable).Post("/undo/{tableKey}", s.handleUndoCellEdit)
+				r.With(s.requireWritable).Post("/redo/{tableKey}", s.handleRedoCellEdit)
 
 				// Import template mutations
 				r.Put("/import-template/{id}", s.handleUpdateTemplate)
 				r.Delete("/import-template/{id}", s.handleDeleteTemplate)
 
+				// Export snapshot mutations
+				r.Delete("/snapshot/{id}", s.handleDeleteSnapshot)
+
 				// Reset operations
-				r.Post("/reset/{tableKey}", s.handleReset)
+				r.With(s.requireWritable).Post("/reset/{tableKey}", s.handleReset)
 				r.Post("/reset", s.handleResetAll)
+				r.Post("/reset/resume/{operationID}", s.handleResumeResetAll)
 
 				// Rollback operation
 				r.Post("/rollback/{uploadID}", s.handleRollbackUpload)
+
+				// Upload review workflow
+				r.Post("/upload/{uploadID}/review", s.handleSetUploadReview)
+				r.Post("/rollback-range/{tableKey}", s.handleRollbackRange)
+				r.Post("/upload-batch/{batchID}/rollback", s.handleRollbackUploadBatch)
+
+				// Custom table actions
+				r.Post("/tables/{tableKey}/actions/{action}", s.handleRunTableAction)
+
+				// Declarative bootstrap
+				r.Post("/admin/bootstrap", s.handleBootstrap)
+
+				// User accounts
+				r.Get("/admin/users", s.handleListUsers)
+				r.Post("/admin/users", s.handleCreateUser)
+				r.Delete("/admin/users/{id}", s.handleDisableUser)
+
+				// API tokens
+				r.Get("/admin/tokens", s.handleListAPITokens)
+				r.Post("/admin/tokens", s.handleCreateAPIToken)
+				r.Delete("/admin/tokens/{id}", s.handleRevokeAPIToken)
+
+				// Auth lockout administration
+				r.Get("/admin/auth-lockouts", s.handleListLockouts)
+				r.Delete("/admin/auth-lockouts/{ip}", s.handleUnlockClient)
+
+				// Archive scheduler
+				r.Get("/admin/archive", s.handleArchiveStatus)
+				r.Post("/admin/archive/run", s.handleRunArchive)
+
+				// Encrypted upload spool administration
+				r.Get("/admin/spool", s.handleSpoolReport)
+				r.Post("/admin/spool/rotate", s.handleRotateSpool)
+				r.Delete("/admin/spool/{id}", s.handleDeleteSpoolArtifact)
+
+				// Legal holds
+				r.Get("/admin/legal-holds", s.handleListLegalHolds)
+				r.Post("/admin/legal-holds", s.handlePlaceLegalHold)
+				r.Post("/admin/legal-holds/{id}/release", s.handleReleaseLegalHold)
+
+				// Two-person approval of large resets and rollbacks
+				r.Get("/approvals", s.handleListApprovals)
+				r.Get("/approvals/{id}", s.handleGetApproval)
+				r.Post("/approvals/{id}", s.handleDecideApproval)
+
+				// Pre-purge retention archives
+				r.Get("/admin/retention-archives", s.handleListRetentionArchives)
+				r.Get("/admin/retention-archives/{id}", s.handleDownloadRetentionArchive)
+
+				// Extended statistics for the query planner
+				r.Get("/admin/statistics/{tableKey}", s.handleTableStatistics)
+				r.With(s.requireWritable).Post("/admin/statistics/{tableKey}/refresh", s.handleRefreshStatistics)
+
+				// Schema evolution
+				r.Get("/admin/schema/deprecated", s.handleDeprecatedColumns)
+				r.Get("/admin/schema/migration", s.handleSchemaMigration)
+				r.With(s.requireWritable).Post("/admin/backfill/{tableKey}", s.handleBackfillColumn)
+
+				// Audit history from other deployments
+				r.Post("/admin/audit-log/import", s.handleImportAuditLog)
+				r.Get("/admin/audit-log/imports", s.handleListAuditImports)
 			})
 		})
 	})
@@ -461,6 +2030,11 @@ func (s *Server) Start() error {
 		IdleTimeout:  s.cfg.Server.IdleTimeout,
 	}
 
+	sec := s.cfg.Security
+	if s.ipResolver == nil && (len(sec.DestructiveDenyCountries) > 0 || len(sec.DestructiveDenyASNs) > 0) {
+		slog.Warn("country/ASN deny lists configured without an IP resolver; destructive endpoints will reject all requests")
+	}
+
 	slog.Info("server starting", "addr", addr)
 	return s.server.ListenAndServe()
 }
@@ -505,8 +2079,9 @@ func securityHeaders(next http.Handler) http.Handler {
 type rateLimiter struct {
 	mu       sync.Mutex
 	visitors map[string]*visitor
-	rate     int           // requests per window
-	window   time.Duration // time window
+	rate     int                   // requests per window
+	window   time.Duration         // time window
+	onLimit  func(r *http.Request) // called for each blocked request; may be nil
 }
 
 type visitor struct {
@@ -515,11 +2090,13 @@ type visitor struct {
 }
 
 // newRateLimiter creates a rate limiter with the specified rate per window.
-func newRateLimiter(rate int, window time.Duration) *rateLimiter {
+// onLimit, if non-nil, is called for each request the limiter blocks.
+func newRateLimiter(rate int, window time.Duration, onLimit func(r *http.Request)) *rateLimiter {
 	rl := &rateLimiter{
 		visitors: make(map[string]*visitor),
 		rate:     rate,
 		window:   window,
+		onLimit:  onLimit,
 	}
 	// Start cleanup goroutine
 	go rl.cleanup()
@@ -576,6 +2153,9 @@ func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
 		// RemoteAddr is already set by TrustedRealIP middleware (if trusted proxy)
 		// or contains the direct connection IP (if no proxy configured)
 		if !rl.allow(r.RemoteAddr) {
+			if rl.onLimit != nil {
+				rl.onLimit(r)
+			}
 			w.Header().Set("Retry-After", "60")
 			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
 			return
@@ -585,6 +2165,37 @@ func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
 	})
 }
 
+// repeatFilter reports whether a key has been seen within a window. Used to
+// record one audit entry per client for a burst of identical denials.
+type repeatFilter struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	window time.Duration
+}
+
+// newRepeatFilter creates a filter that forgets keys after window.
+func newRepeatFilter(window time.Duration) *repeatFilter {
+	return &repeatFilter{seen: make(map[string]time.Time), window: window}
+}
+
+// first reports whether key was not seen within the window, and marks it seen.
+func (f *repeatFilter) first(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := f.seen[key]; ok && now.Sub(last) < f.window {
+		return false
+	}
+	for k, last := range f.seen {
+		if now.Sub(last) >= f.window {
+			delete(f.seen, k)
+		}
+	}
+	f.seen[key] = now
+	return true
+}
+
 // writeError writes a JSON error response with user-friendly messages.
 // Logs the full error server-side but returns a mapped user message to the client.
 func writeError(w http.ResponseWriter, status int, message string) {
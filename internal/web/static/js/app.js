@@ -20,7 +20,33 @@ const STORAGE_KEYS = {
     columns: (tableKey) => `columns_${tableKey}`,
     sort: (tableKey) => `sort_${tableKey}`,
     views: (tableKey) => `views_${tableKey}`,
-    metrics: (tableKey) => `agg_metrics_${tableKey}`
+    metrics: (tableKey) => `agg_metrics_${tableKey}`,
+    RESUME_TOKEN: 'resume-token',
+    ACTIVE_UPLOAD: 'active-upload'
+};
+
+// CSRF protection - requests that change anything must echo the session's
+// CSRF token, which the server hands out in the csrf_token cookie
+function csrfToken() {
+    const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+    return match ? decodeURIComponent(match[1]) : '';
+}
+
+document.addEventListener('htmx:configRequest', function(e) {
+    const token = csrfToken();
+    if (token) e.detail.headers['X-CSRF-Token'] = token;
+});
+
+const nativeFetch = window.fetch.bind(window);
+window.fetch = function(input, init = {}) {
+    const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+    const token = csrfToken();
+    if (token && url.origin === window.location.origin) {
+        const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+        headers.set('X-CSRF-Token', token);
+        init = { ...init, headers };
+    }
+    return nativeFetch(input, init);
 };
 
 // Generic storage helpers with JSON parsing
@@ -217,6 +243,7 @@ function showUploadModal() {
 
 function hideUploadModal() {
     hideModal('upload-modal');
+    sessionStorage.removeItem(STORAGE_KEYS.ACTIVE_UPLOAD);
     document.getElementById('upload-progress-container').innerHTML = '';
 }
 
@@ -241,20 +268,37 @@ let currentUpload = {
     sseClient: null
 };
 
-// Start SSE stream for upload progress with robust reconnection
-function startProgressStream(uploadId) {
+// Start SSE stream for upload progress with robust reconnection.
+// sessionId is the resumable upload session the upload came from, if any;
+// it is dismissed once the upload finishes.
+//
+// The upload and its last event ID are kept in sessionStorage, so after a
+// page refresh the stream resumes where it left off: the server replays
+// the phases missed in between and the current progress.
+function startProgressStream(uploadId, sessionId = null) {
     const container = document.getElementById('upload-progress-container');
 
     // Clean up any existing SSE connection
     if (currentUpload.sseClient) {
         currentUpload.sseClient.close();
     }
+    ownUploads.add(uploadId);
+
+    const saved = JSON.parse(sessionStorage.getItem(STORAGE_KEYS.ACTIVE_UPLOAD) || 'null');
+    const active = { id: uploadId, sessionId, lastEventId: saved && saved.id === uploadId ? saved.lastEventId : null };
+    sessionStorage.setItem(STORAGE_KEYS.ACTIVE_UPLOAD, JSON.stringify(active));
 
     // Create new SSE client with exponential backoff reconnection
     const client = new SSEClient(`/api/upload/${uploadId}/progress`, {
         maxRetries: 10,
         baseDelay: 1000,
         maxDelay: 30000,
+        lastEventId: active.lastEventId,
+
+        onEventId: (id) => {
+            active.lastEventId = id;
+            sessionStorage.setItem(STORAGE_KEYS.ACTIVE_UPLOAD, JSON.stringify(active));
+        },
 
         onProgress: (progress) => {
             container.innerHTML = renderProgress(progress, uploadId);
@@ -263,6 +307,8 @@ function startProgressStream(uploadId) {
         onComplete: () => {
             currentUpload.id = null;
             currentUpload.sseClient = null;
+            sessionStorage.removeItem(STORAGE_KEYS.ACTIVE_UPLOAD);
+            if (sessionId) dismissResumableUpload(sessionId);
 
             // Fetch final result
             fetch(`/api/upload/${uploadId}/result`)
@@ -278,6 +324,7 @@ function startProgressStream(uploadId) {
 
         onError: (error) => {
             if (error.code === 'MAX_RETRIES') {
+                sessionStorage.removeItem(STORAGE_KEYS.ACTIVE_UPLOAD);
                 container.innerHTML = renderConnectionLost(uploadId);
             } else if (error.message) {
                 container.innerHTML = renderUploadError(error.message);
@@ -535,6 +582,7 @@ function renderComplete(result) {
     }
 
     html += '</div>';
+    html += renderDateWarnings(result.date_warnings);
 
     if (result.error) {
         html += `<div class="text-sm text-red-600 bg-red-50 rounded p-3">${result.error}</div>`;
@@ -550,6 +598,17 @@ function renderComplete(result) {
     return html;
 }
 
+// Warnings for date columns that may be day first (DD/MM), from an upload
+// result or preview
+function renderDateWarnings(warnings) {
+    if (!warnings || warnings.length === 0) return '';
+    return `
+        <div class="text-sm text-amber-800 bg-amber-50 dark:text-amber-300 dark:bg-amber-900/20 border border-amber-200 dark:border-amber-800 rounded p-3 space-y-1">
+            ${warnings.map(w => `<div>${escapeHtml(w.message)}</div>`).join('')}
+        </div>
+    `;
+}
+
 // Toast notifications
 function showToast(message, isError = false) {
     const toast = document.createElement('div');
@@ -575,6 +634,231 @@ function showError(message) {
     showToast(message, true);
 }
 
+// ============================================================================
+// RESUMABLE UPLOADS
+// ============================================================================
+// Files are sent in chunks to /api/resumable-upload so an upload survives a
+// page refresh. The sessions are bound to a token kept in localStorage;
+// after a refresh the dashboard lists them from /api/my/pending-uploads and
+// either reattaches to the running upload or asks for the file again and
+// sends the rest. Servers without upload spooling answer 404, and the form
+// is uploaded in one request as before.
+
+// Thrown when the server has resumable uploads disabled
+class ResumableUnavailable extends Error {}
+
+// Get this browser's resume token, creating it on first use
+function getResumeToken() {
+    let token = getStorage(STORAGE_KEYS.RESUME_TOKEN, null);
+    if (!token) {
+        const bytes = new Uint8Array(24);
+        crypto.getRandomValues(bytes);
+        token = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+        setStorage(STORAGE_KEYS.RESUME_TOKEN, token);
+    }
+    return token;
+}
+
+// fetch with the resume token; resolves to the parsed JSON body
+async function resumableFetch(url, options = {}) {
+    const response = await fetch(url, {
+        ...options,
+        headers: { ...(options.headers || {}), 'X-Resume-Token': getResumeToken() }
+    });
+    const data = await response.json().catch(() => ({}));
+    if (!response.ok) {
+        const err = new Error(data.error || `Request failed (${response.status})`);
+        err.status = response.status;
+        throw err;
+    }
+    return data;
+}
+
+// Upload a file through a new resumable session
+async function startResumableUpload(tableKey, file, mapping) {
+    let session;
+    try {
+        session = await resumableFetch(`/api/resumable-upload/${encodeURIComponent(tableKey)}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ file_name: file.name, size: file.size, mapping: mapping || undefined })
+        });
+    } catch (e) {
+        if (e.status === 404) throw new ResumableUnavailable(e.message);
+        throw e;
+    }
+    await sendResumableChunks(session, file);
+}
+
+// Send the chunks the server does not have yet, then start the upload
+async function sendResumableChunks(session, file) {
+    const container = document.getElementById('upload-progress-container');
+    const base = `/api/resumable-upload/${session.session_id}`;
+    let received = session.received;
+    let failures = 0;
+
+    while (received < session.size) {
+        container.innerHTML = renderSendingProgress(session.file_name, received, session.size);
+        const chunk = file.slice(received, received + session.chunk_size);
+        try {
+            const state = await resumableFetch(`${base}/chunk?offset=${received}`, { method: 'PUT', body: chunk });
+            received = state.received;
+            failures = 0;
+        } catch (e) {
+            if (e.status === 404 || e.status === 400 || ++failures > 5) throw e;
+            // A lost response or dropped connection: ask where to continue
+            await new Promise(resolve => setTimeout(resolve, 1000 * failures));
+            const state = await resumableFetch(base).catch(() => null);
+            if (state) received = state.received;
+        }
+    }
+
+    container.innerHTML = renderSendingProgress(session.file_name, session.size, session.size);
+    const started = await resumableFetch(`${base}/complete`, { method: 'POST' });
+    reattachResumableUpload(started);
+}
+
+// Follow the progress of a completed session's upload
+function reattachResumableUpload(session) {
+    if (session.upload_id) {
+        startProgressStream(session.upload_id, session.session_id);
+    } else if (session.batch_id) {
+        // Zip archive: each file is its own upload
+        showToast(`Started ${session.upload_ids.length} uploads from ${session.file_name}`);
+        hideUploadModal();
+        dismissResumableUpload(session.session_id);
+    }
+}
+
+// Drop a session from the pending list (and its chunks, if unfinished)
+function dismissResumableUpload(sessionId) {
+    resumableFetch(`/api/resumable-upload/${sessionId}`, { method: 'DELETE' }).catch(() => {});
+}
+
+// Render the file transfer that precedes processing
+function renderSendingProgress(fileName, sent, total) {
+    const percent = total > 0 ? Math.round((sent / total) * 100) : 0;
+    return `
+        <div class="space-y-4">
+            <div class="flex items-center justify-between">
+                <span class="text-sm font-medium text-gray-900 dark:text-white block truncate">${escapeHtml(fileName)}</span>
+                <span class="text-sm text-gray-500 dark:text-gray-400 whitespace-nowrap ml-2">Sending file...</span>
+            </div>
+            <div class="w-full bg-gray-200 dark:bg-gray-700 rounded-full h-3">
+                <div class="h-3 rounded-full transition-all duration-300 bg-blue-500" style="width: ${percent}%"></div>
+            </div>
+            <div class="text-xs text-gray-500 dark:text-gray-400">${formatFileSize(sent)} of ${formatFileSize(total)}</div>
+        </div>
+    `;
+}
+
+// Pending sessions awaiting their file, by session ID
+let pendingResumables = {};
+
+// On load, reattach to a running upload or offer to resume unfinished ones
+async function checkPendingUploads() {
+    if (!document.getElementById('upload-modal') || !getStorage(STORAGE_KEYS.RESUME_TOKEN, null)) return;
+
+    let pending;
+    try {
+        pending = await resumableFetch('/api/my/pending-uploads');
+    } catch (e) {
+        return; // Disabled or unreachable; nothing to resume
+    }
+
+    const running = pending.filter(p => p.upload_id || p.batch_id);
+    if (running.length > 0 && !currentUpload.id) {
+        showUploadModal();
+        reattachResumableUpload(running[running.length - 1]);
+    }
+
+    const unfinished = pending.filter(p => !p.upload_id && !p.batch_id);
+    pendingResumables = Object.fromEntries(unfinished.map(p => [p.session_id, p]));
+    renderPendingUploads();
+}
+
+// Render the panel listing unfinished uploads
+function renderPendingUploads() {
+    let panel = document.getElementById('pending-uploads-panel');
+    const sessions = Object.values(pendingResumables);
+    if (sessions.length === 0) {
+        if (panel) panel.remove();
+        return;
+    }
+    if (!panel) {
+        panel = document.createElement('div');
+        panel.id = 'pending-uploads-panel';
+        panel.className = 'fixed bottom-4 left-4 w-80 bg-white dark:bg-gray-800 border border-gray-200 dark:border-gray-700 rounded-lg shadow-lg p-4 z-40';
+        document.body.appendChild(panel);
+    }
+    panel.innerHTML = `
+        <div class="text-sm font-medium text-gray-900 dark:text-white mb-2">Unfinished uploads</div>
+        <input type="file" id="pending-upload-file" class="hidden" onchange="resumePendingUpload(this)">
+        <ul class="space-y-2">
+            ${sessions.map(p => `
+                <li class="text-xs text-gray-600 dark:text-gray-400">
+                    <div class="truncate"><span class="font-medium text-gray-900 dark:text-white">${escapeHtml(p.file_name)}</span> to ${escapeHtml(p.table_key)}</div>
+                    <div class="flex items-center justify-between">
+                        <span>${formatFileSize(p.received)} of ${formatFileSize(p.size)} sent</span>
+                        <span class="flex gap-2">
+                            <button type="button" onclick="choosePendingUploadFile('${p.session_id}')" class="text-blue-600 hover:underline dark:text-blue-400">Resume</button>
+                            <button type="button" onclick="discardPendingUpload('${p.session_id}')" class="text-red-600 hover:underline dark:text-red-400">Discard</button>
+                        </span>
+                    </div>
+                </li>
+            `).join('')}
+        </ul>
+    `;
+}
+
+// Ask for the file of a pending session; the browser cannot reopen it itself
+function choosePendingUploadFile(sessionId) {
+    const input = document.getElementById('pending-upload-file');
+    input.dataset.sessionId = sessionId;
+    input.value = '';
+    input.click();
+}
+
+// Continue a pending session with the file the user picked again
+function resumePendingUpload(input) {
+    const session = pendingResumables[input.dataset.sessionId];
+    const file = input.files[0];
+    if (!session || !file) return;
+
+    if (file.name !== session.file_name || file.size !== session.size) {
+        showError(`Choose ${session.file_name} (${formatFileSize(session.size)}) to resume this upload`);
+        return;
+    }
+
+    delete pendingResumables[session.session_id];
+    renderPendingUploads();
+    showUploadModal();
+    resumableFetch(`/api/resumable-upload/${session.session_id}`)
+        .then(state => sendResumableChunks(state, file))
+        .catch(e => {
+            document.getElementById('upload-progress-container').innerHTML = renderUploadError(escapeHtml(e.message));
+        });
+}
+
+// Discard a pending session and its chunks
+function discardPendingUpload(sessionId) {
+    dismissResumableUpload(sessionId);
+    delete pendingResumables[sessionId];
+    renderPendingUploads();
+}
+
+// On load, resume the progress stream of the upload this tab was following
+function reattachActiveUpload() {
+    const active = JSON.parse(sessionStorage.getItem(STORAGE_KEYS.ACTIVE_UPLOAD) || 'null');
+    if (!active || !document.getElementById('upload-modal')) return;
+
+    showUploadModal();
+    startProgressStream(active.id, active.sessionId);
+}
+
+document.addEventListener('DOMContentLoaded', reattachActiveUpload);
+document.addEventListener('DOMContentLoaded', checkPendingUploads);
+
 // Add drag and drop styling
 document.addEventListener('DOMContentLoaded', function() {
     document.querySelectorAll('.upload-zone').forEach(zone => {
@@ -604,6 +888,118 @@ document.addEventListener('DOMContentLoaded', function() {
     });
 });
 
+// ============================================================================
+// Dashboard Live Updates
+// ============================================================================
+
+// Table cards follow /api/events/tables, which pushes a table's row count
+// and last upload after uploads, rollbacks and resets, so the dashboard
+// stays current without reloading. EventSource reconnects on its own.
+let tableEvents = null;
+
+function initTableEvents() {
+    const forms = document.querySelectorAll('.table-card form[id^="upload-form-"]');
+    if (forms.length === 0 || tableEvents) return;
+
+    const params = new URLSearchParams();
+    forms.forEach(form => params.append('table', form.id.slice('upload-form-'.length)));
+
+    tableEvents = new EventSource(`/api/events/tables?${params}`);
+    tableEvents.addEventListener('table', (e) => {
+        try {
+            updateTableCard(JSON.parse(e.data));
+        } catch (err) {
+            console.error('[SSE] Failed to parse table event:', err);
+        }
+    });
+}
+
+// Update a table card's row count badge and last upload line
+function updateTableCard(ev) {
+    const form = document.getElementById(`upload-form-${ev.table_key}`);
+    const card = form && form.closest('.table-card');
+    if (!card) return;
+
+    const badge = card.querySelector('.table-row-count');
+    if (badge) badge.textContent = `${ev.row_count} rows`;
+
+    let updated = card.querySelector('.table-last-upload');
+    if (!ev.last_upload) {
+        if (updated) updated.remove();
+        return;
+    }
+    if (!updated) {
+        updated = document.createElement('div');
+        updated.className = 'table-last-upload text-xs text-gray-500 mb-3 dark:text-gray-400';
+        form.before(updated);
+    }
+    updated.textContent = `Updated ${formatTimeAgo(new Date(ev.last_upload))}`;
+}
+
+// Same wording as the server-rendered card (formatTimeAgo in dashboard.templ)
+function formatTimeAgo(date) {
+    const mins = Math.floor((Date.now() - date) / 60000);
+    if (mins < 1) return 'just now';
+    if (mins < 60) return mins === 1 ? '1 minute ago' : `${mins} minutes ago`;
+    const hours = Math.floor(mins / 60);
+    if (hours < 24) return hours === 1 ? '1 hour ago' : `${hours} hours ago`;
+    const days = Math.floor(hours / 24);
+    if (days < 7) return days === 1 ? 'yesterday' : `${days} days ago`;
+    return date.toLocaleDateString('en-US', { month: 'short', day: 'numeric', year: 'numeric' });
+}
+
+document.addEventListener('DOMContentLoaded', initTableEvents);
+
+// ============================================================================
+// Activity Feed
+// ============================================================================
+
+// The dashboard also follows /api/events and shows a toast when a table is
+// uploaded to, rolled back, reset or bulk edited, from this browser or any
+// other. Uploads this tab started already show their own progress and are
+// not repeated.
+let activityEvents = null;
+const ownUploads = new Set();
+
+function initActivityFeed() {
+    if (activityEvents || !document.querySelector('.table-card')) return;
+
+    activityEvents = new EventSource('/api/events');
+    activityEvents.addEventListener('activity', (e) => {
+        try {
+            const ev = JSON.parse(e.data);
+            if (ev.upload_id && ownUploads.has(ev.upload_id)) return;
+            const message = formatActivity(ev);
+            if (message) showToast(escapeHtml(message), ev.kind === 'upload_failed');
+        } catch (err) {
+            console.error('[SSE] Failed to parse activity event:', err);
+        }
+    });
+}
+
+// Describe an activity event for a toast
+function formatActivity(ev) {
+    const rows = `${(ev.rows || 0).toLocaleString()} rows`;
+    switch (ev.kind) {
+        case 'upload_started':
+            return `Upload of ${ev.file_name} to ${ev.table_key} started`;
+        case 'upload_completed':
+            return `${rows} uploaded to ${ev.table_key}`;
+        case 'upload_failed':
+            return `Upload to ${ev.table_key} failed: ${ev.error}`;
+        case 'rollback':
+            return `Upload to ${ev.table_key} rolled back (${rows})`;
+        case 'reset':
+            return `${ev.table_key} was reset (${rows})`;
+        case 'bulk_edit':
+            return `${ev.column} edited in ${rows} of ${ev.table_key}`;
+        default:
+            return null;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', initActivityFeed);
+
 // ============================================================================
 // Column Toggle Feature
 // ============================================================================
@@ -647,6 +1043,53 @@ function toggleColumnDropdown() {
     }
 }
 
+// Tables whose saved preferences were checked for renamed columns this page load
+const renamesChecked = {};
+
+// Rewrite saved column, sort and view preferences that use a column's old
+// name (see GET /api/tables/{tableKey}/renames). Only fetches the renames
+// when something saved refers to a column the table no longer shows.
+function migrateRenamedColumns(tableKey, allColumns) {
+    if (renamesChecked[tableKey]) return Promise.resolve(false);
+    renamesChecked[tableKey] = true;
+
+    const unknown = name => name && !allColumns.includes(name);
+    const columns = getStorage(STORAGE_KEYS.columns(tableKey), null);
+    const sorts = getSavedSorts(tableKey);
+    const views = getSavedViews(tableKey);
+    const stale = (columns || []).some(unknown) ||
+        sorts.some(s => unknown(s.column)) ||
+        views.some(v => (v.columns || []).some(unknown) ||
+            Object.keys(v.filters || {}).some(unknown) ||
+            (v.sort && v.sort.column || '').split(',').some(unknown));
+    if (!stale) return Promise.resolve(false);
+
+    return fetch('/api/tables/' + encodeURIComponent(tableKey) + '/renames')
+        .then(resp => resp.ok ? resp.json() : {})
+        .then(renames => {
+            if (Object.keys(renames).length === 0) return false;
+            const rename = name => renames[name] || name;
+
+            if (columns) saveVisibleColumns(tableKey, columns.map(rename));
+            if (sorts.length > 0) {
+                saveSorts(tableKey, sorts.map(s => ({ ...s, column: rename(s.column) })));
+            }
+            saveViews(tableKey, views.map(v => {
+                const filters = {};
+                for (const [col, opVal] of Object.entries(v.filters || {})) {
+                    filters[rename(col)] = opVal;
+                }
+                const sort = v.sort ? {
+                    ...v.sort,
+                    column: (v.sort.column || '').split(',').map(c => c && rename(c)).join(',')
+                } : v.sort;
+                return { ...v, filters, sort, columns: (v.columns || []).map(rename) };
+            }));
+            return true;
+        })
+        .catch(() => false);
+}
+
 // Initialize column toggle UI and apply saved visibility
 function initColumnToggle() {
     const tableKey = getTableKey();
@@ -655,6 +1098,10 @@ function initColumnToggle() {
     const allColumns = getAllColumns();
     if (allColumns.length === 0) return;
 
+    migrateRenamedColumns(tableKey, allColumns).then(migrated => {
+        if (migrated) initColumnToggle();
+    });
+
     const visible = getVisibleColumns(tableKey, allColumns);
     const container = document.getElementById('column-checkboxes');
     if (!container) return;
@@ -781,7 +1228,7 @@ function getSortStorageKey(tableKey) {
     return STORAGE_KEYS.sort(tableKey);
 }
 
-// Get saved sorts from localStorage (returns array of {column, dir})
+// Get saved sorts from localStorage (returns array of {column, dir, nulls})
 function getSavedSorts(tableKey) {
     const parsed = getStorage(STORAGE_KEYS.sort(tableKey), null);
     if (!parsed) return [];
@@ -806,25 +1253,45 @@ function getCurrentSorts() {
     const url = new URL(window.location.href);
     const sortStr = url.searchParams.get('sort') || '';
     const dirStr = url.searchParams.get('dir') || '';
+    const nullsStr = url.searchParams.get('nulls') || '';
 
     if (!sortStr) return [];
 
     const cols = sortStr.split(',');
     const dirs = dirStr.split(',');
+    const nulls = nullsStr.split(',');
 
+    // The server applies at most QUERY_MAX_SORT_LEVELS of these
     const sorts = [];
-    for (let i = 0; i < cols.length && i < 2; i++) {
+    for (let i = 0; i < cols.length; i++) {
         const col = cols[i].trim();
         if (col) {
             sorts.push({
                 column: col,
-                dir: (dirs[i] || 'asc').trim()
+                dir: (dirs[i] || 'asc').trim(),
+                nulls: (nulls[i] || '').trim()
             });
         }
     }
     return sorts;
 }
 
+// Cycle null ordering: database default, then last, then first
+function toggleNulls(nulls) {
+    return nulls === '' || !nulls ? 'last' : nulls === 'last' ? 'first' : '';
+}
+
+// Build the sort, dir and nulls query params for sorts
+function sortParams(sorts) {
+    const cols = sorts.map(s => s.column).join(',');
+    const dirs = sorts.map(s => s.dir).join(',');
+    let params = `sort=${encodeURIComponent(cols)}&dir=${dirs}`;
+    if (sorts.some(s => s.nulls)) {
+        params += '&nulls=' + sorts.map(s => s.nulls || '').join(',');
+    }
+    return params;
+}
+
 // Toggle sort direction
 function toggleDir(dir) {
     return dir === 'asc' ? 'desc' : 'asc';
@@ -836,10 +1303,7 @@ function buildSortUrl(tableKey, sorts, searchQuery, filterParams) {
         return `/table/${tableKey}?page=1`;
     }
 
-    const cols = sorts.map(s => s.column).join(',');
-    const dirs = sorts.map(s => s.dir).join(',');
-
-    let url = `/table/${tableKey}?page=1&sort=${encodeURIComponent(cols)}&dir=${dirs}`;
+    let url = `/table/${tableKey}?page=1&${sortParams(sorts)}`;
 
     if (searchQuery) {
         url += '&search=' + encodeURIComponent(searchQuery);
@@ -881,27 +1345,22 @@ function handleSortClick(event, element) {
     const currentSorts = getCurrentSorts();
     let newSorts;
 
-    if (isShiftClick && currentSorts.length > 0) {
-        // Shift+Click: add as secondary sort
-        const primary = currentSorts[0];
-        if (primary.column === col) {
-            // Shift+clicking primary - just toggle direction
-            newSorts = [{ column: col, dir: toggleDir(primary.dir) }];
+    if (event.altKey && currentSorts.some(s => s.column === col)) {
+        // Alt+Click on a sorted column: cycle where its empty values go
+        newSorts = currentSorts.map(s => s.column === col ? { ...s, nulls: toggleNulls(s.nulls) } : s);
+    } else if (isShiftClick && currentSorts.length > 0) {
+        // Shift+Click: toggle the column's level, or add it as the next level
+        const existing = currentSorts.find(s => s.column === col);
+        if (existing) {
+            newSorts = currentSorts.map(s => s.column === col ? { ...s, dir: toggleDir(s.dir) } : s);
         } else {
-            // Check if clicking on existing secondary
-            if (currentSorts.length > 1 && currentSorts[1].column === col) {
-                // Toggle secondary direction
-                newSorts = [primary, { column: col, dir: toggleDir(currentSorts[1].dir) }];
-            } else {
-                // Add as new secondary (or replace existing secondary)
-                newSorts = [primary, { column: col, dir: 'asc' }];
-            }
+            newSorts = [...currentSorts, { column: col, dir: 'asc' }];
         }
     } else {
         // Regular click - replace sort
         const existing = currentSorts.find(s => s.column === col);
         const dir = existing ? toggleDir(existing.dir) : 'asc';
-        newSorts = [{ column: col, dir }];
+        newSorts = [{ column: col, dir, nulls: existing ? existing.nulls : '' }];
     }
 
     // Build URL and navigate
@@ -930,10 +1389,11 @@ function initSortPersistence() {
     if (!hasUrlSort) {
         const saved = getSavedSorts(tableKey);
         if (saved.length > 0) {
-            const cols = saved.map(s => s.column).join(',');
-            const dirs = saved.map(s => s.dir).join(',');
-            url.searchParams.set('sort', cols);
-            url.searchParams.set('dir', dirs);
+            url.searchParams.set('sort', saved.map(s => s.column).join(','));
+            url.searchParams.set('dir', saved.map(s => s.dir).join(','));
+            if (saved.some(s => s.nulls)) {
+                url.searchParams.set('nulls', saved.map(s => s.nulls || '').join(','));
+            }
             url.searchParams.set('page', '1');
             window.location.replace(url.toString());
         }
@@ -1334,14 +1794,15 @@ function parseCSV(text, previewOnly = false) {
     const lines = text.trim().split('\n');
     if (lines.length === 0) return { headers: [], rows: [], allRows: [], totalRows: 0 };
 
-    const headers = parseCSVLine(lines[0]);
+    const delimiter = sniffDelimiter(lines);
+    const headers = parseCSVLine(lines[0], delimiter);
     const rows = [];
     const allRows = [];
     const previewCount = Math.min(5, lines.length - 1);
 
     for (let i = 1; i < lines.length; i++) {
         if (lines[i]) {
-            const parsed = parseCSVLine(lines[i]);
+            const parsed = parseCSVLine(lines[i], delimiter);
             allRows.push({ data: parsed, lineNumber: i + 1 });
             if (i <= previewCount) rows.push(parsed);
         }
@@ -1350,7 +1811,26 @@ function parseCSV(text, previewOnly = false) {
     return { headers, rows, allRows, totalRows: lines.length - 1 };
 }
 
-function parseCSVLine(line) {
+// Pick the delimiter the server will use (see sniffDelimiter in sniff.go):
+// the candidate whose most common per-line count matches the most lines.
+function sniffDelimiter(lines) {
+    let best = ',', bestLines = 0, bestCount = 0;
+    for (const d of [',', ';', '\t', '|']) {
+        const freq = new Map();
+        for (const line of lines.slice(0, 50)) {
+            const n = line.replace(/"[^"]*"/g, '').split(d).length - 1;
+            if (n > 0) freq.set(n, (freq.get(n) || 0) + 1);
+        }
+        for (const [count, matching] of freq) {
+            if (matching > bestLines || (matching === bestLines && count > bestCount)) {
+                best = d; bestLines = matching; bestCount = count;
+            }
+        }
+    }
+    return best;
+}
+
+function parseCSVLine(line, delimiter = ',') {
     const result = [];
     let current = '';
     let inQuotes = false;
@@ -1359,7 +1839,7 @@ function parseCSVLine(line) {
         const char = line[i];
         if (char === '"') {
             inQuotes = !inQuotes;
-        } else if (char === ',' && !inQuotes) {
+        } else if (char === delimiter && !inQuotes) {
             result.push(current.trim());
             current = '';
         } else {
@@ -1647,7 +2127,22 @@ function confirmUpload() {
         const saveTemplateContainer = document.getElementById('save-template-container');
         if (saveTemplateContainer) saveTemplateContainer.innerHTML = '';
 
-        htmx.trigger(currentPreviewForm, 'upload');
+        // Send the file resumably if the server supports it, else in one request
+        const form = currentPreviewForm;
+        const fileInput = form.querySelector('input[type="file"]');
+        const file = fileInput && fileInput.files[0];
+        if (file) {
+            showUploadModal();
+            startResumableUpload(form.id.replace('upload-form-', ''), file, mapping).catch(e => {
+                if (e instanceof ResumableUnavailable) {
+                    htmx.trigger(form, 'upload');
+                } else {
+                    document.getElementById('upload-progress-container').innerHTML = renderUploadError(escapeHtml(e.message));
+                }
+            });
+        } else {
+            htmx.trigger(form, 'upload');
+        }
         currentPreviewForm = null;
         // Reset analysis state
         currentPreviewFile = null;
@@ -2336,12 +2831,57 @@ function renderAnalysisResults(result) {
         <div class="mt-4 p-4 rounded-lg bg-white dark:bg-gray-800 border border-gray-200 dark:border-gray-700">
             <div class="text-sm font-medium text-gray-700 dark:text-gray-300 mb-3">Upload Analysis</div>
             ${summaryHtml}
+            ${result.dateWarnings ? `<div class="mb-3">${renderDateWarnings(result.dateWarnings)}</div>` : ''}
+            ${result.mode === 'upsert' && summary.updateRows > 0 ? renderColumnChanges(result.columnChanges || [], summary) : ''}
             ${hasUpdates || hasErrors || hasNew || hasDuplicates ? tabsHtml + tabContentHtml : noDataHtml}
             ${processingTime}
         </div>
     `;
 }
 
+// Render how an upsert would change matched rows, per column
+function renderColumnChanges(changes, summary) {
+    const unchanged = summary.unchangedRows || 0;
+    const header = `
+        <div class="text-xs text-gray-600 dark:text-gray-400 mb-2">
+            ${summary.updateRows} matched ${summary.updateRows === 1 ? 'row' : 'rows'} will be replaced${unchanged > 0 ? `; ${unchanged} without any change` : ''}
+        </div>
+    `;
+    if (changes.length === 0) {
+        return `<div class="mb-3">${header}</div>`;
+    }
+
+    const rows = changes.map(change => {
+        const sample = change.samples[0];
+        const cleared = change.cleared > 0
+            ? `<span class="ml-1 text-amber-600 dark:text-amber-400" title="Current values that would be emptied">(${change.cleared} cleared)</span>`
+            : '';
+        return `
+            <tr>
+                <td class="py-0.5 pr-2 font-medium text-gray-700 dark:text-gray-300">${escapeHtml(change.column)}</td>
+                <td class="py-0.5 pr-2 text-blue-600 dark:text-blue-400">${change.changed}${cleared}</td>
+                <td class="py-0.5 text-gray-500 dark:text-gray-400 truncate">${sample ? `${escapeHtml(sample.current) || 'empty'} → ${escapeHtml(sample.incoming) || 'empty'}` : ''}</td>
+            </tr>
+        `;
+    }).join('');
+
+    return `
+        <div class="mb-3 p-2 rounded border border-blue-100 dark:border-blue-900 bg-blue-50/50 dark:bg-blue-900/10">
+            ${header}
+            <table class="w-full text-xs">
+                <thead>
+                    <tr class="text-gray-500 dark:text-gray-400">
+                        <th class="text-left py-1 pr-2 w-1/3">Column</th>
+                        <th class="text-left py-1 pr-2 w-1/4">Rows changed</th>
+                        <th class="text-left py-1">Example</th>
+                    </tr>
+                </thead>
+                <tbody>${rows}</tbody>
+            </table>
+        </div>
+    `;
+}
+
 // Render update diffs table
 function renderUpdateDiffs(diffs, totalUpdates) {
     const showingInfo = diffs.length < totalUpdates ? `<div class="text-xs text-gray-500 dark:text-gray-400 mb-2">Showing ${diffs.length} of ${totalUpdates} updates</div>` : '';
@@ -2546,6 +3086,11 @@ function updateSelectionUI() {
     } else {
         bar.style.display = 'none';
     }
+
+    const historyBtn = document.getElementById('row-history-btn');
+    if (historyBtn) historyBtn.style.display = count === 1 ? 'inline-flex' : 'none';
+    const cloneBtn = document.getElementById('clone-row-btn');
+    if (cloneBtn) cloneBtn.style.display = count === 1 ? 'inline-flex' : 'none';
 }
 
 // Show delete confirmation modal
@@ -2710,6 +3255,7 @@ function startEdit(cell) {
                 </svg>
             </button>
         </div>
+        <div class="edit-validation hidden text-xs text-red-600 dark:text-red-400 mt-1"></div>
         ${isKeyColumn ? '<div class="text-xs text-amber-600 mt-1">Warning: Editing unique key</div>' : ''}
     `;
 
@@ -2735,12 +3281,12 @@ function buildEditInput(meta, value) {
         case 'numeric':
             return `<input type="number" step="any" value="${escapedValue}"
                     class="edit-input w-full px-2 py-1 text-sm border border-blue-400 rounded focus:ring-2 focus:ring-blue-500 focus:border-blue-500"
-                    onkeydown="handleEditKeydown(event)">`;
+                    onkeydown="handleEditKeydown(event)" oninput="queueEditValidation()">`;
 
         case 'date':
             return `<input type="date" value="${escapedValue}"
                     class="edit-input w-full px-2 py-1 text-sm border border-blue-400 rounded focus:ring-2 focus:ring-blue-500 focus:border-blue-500"
-                    onkeydown="handleEditKeydown(event)">`;
+                    onkeydown="handleEditKeydown(event)" oninput="queueEditValidation()">`;
 
         case 'bool':
             return `<select class="edit-input w-full px-2 py-1 text-sm border border-blue-400 rounded focus:ring-2 focus:ring-blue-500 focus:border-blue-500"
@@ -2764,7 +3310,46 @@ function buildEditInput(meta, value) {
         default:
             return `<input type="text" value="${escapedValue}"
                     class="edit-input w-full px-2 py-1 text-sm border border-blue-400 rounded focus:ring-2 focus:ring-blue-500 focus:border-blue-500"
-                    onkeydown="handleEditKeydown(event)">`;
+                    onkeydown="handleEditKeydown(event)" oninput="queueEditValidation()">`;
+    }
+}
+
+// Check the value being typed as an upload would, once typing pauses
+let editValidationTimer = null;
+
+function queueEditValidation() {
+    clearTimeout(editValidationTimer);
+    editValidationTimer = setTimeout(validateEditInput, 300);
+}
+
+async function validateEditInput() {
+    const cell = currentEditCell;
+    const input = cell && cell.querySelector('.edit-input');
+    const tableKey = getTableKey();
+    if (!input || !tableKey) return;
+
+    const value = input.value;
+    const column = encodeURIComponent(cell.dataset.colName);
+    try {
+        const response = await fetch(`/api/validate-value/${tableKey}/${column}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ value })
+        });
+        if (!response.ok) return;
+        const result = await response.json();
+
+        // Ignore answers for a value that has since changed
+        if (currentEditCell !== cell || input.value !== value) return;
+        const msg = cell.querySelector('.edit-validation');
+        if (msg) {
+            msg.textContent = result.valid ? '' : result.error;
+            msg.classList.toggle('hidden', result.valid);
+        }
+        input.classList.toggle('border-red-500', !result.valid);
+        input.classList.toggle('border-blue-400', result.valid);
+    } catch (e) {
+        console.error('Validate value error:', e);
     }
 }
 
@@ -2886,6 +3471,190 @@ async function saveEdit() {
     }
 }
 
+// Undo the table's last cell edit, or redo the last undone one
+async function undoCellEdit(redo = false) {
+    const tableKey = getTableKey();
+    if (!tableKey) return;
+    const action = redo ? 'redo' : 'undo';
+
+    try {
+        const response = await fetch(`/api/${action}/${tableKey}`, { method: 'POST' });
+        const result = await response.json();
+
+        if (result.success) {
+            showToast(`${redo ? 'Redid' : 'Undid'} edit to ${result.column}`);
+            const url = new URL(window.location.href);
+            htmx.ajax('GET', url.pathname + url.search, { target: '#table-container', swap: 'innerHTML' });
+        } else if (result.rowMissing) {
+            showToast(`Can't ${action}: the edited row no longer exists`, true);
+        } else if (result.conflict) {
+            showToast(`Can't ${action}: ${result.column} has changed since (now "${result.currentValue}")`, true);
+        } else if (result.duplicateKey) {
+            showToast(`Can't ${action}: duplicate key ${result.conflictingKey}`, true);
+        } else {
+            showToast(result.error || `Nothing to ${action}`, true);
+        }
+    } catch (e) {
+        console.error(`${action} error:`, e);
+        showToast(`${redo ? 'Redo' : 'Undo'} failed`, true);
+    }
+}
+
+// ============================================================================
+// Recently Deleted Rows
+// ============================================================================
+
+// Show the panel listing the table's recently deleted rows
+async function showDeletedRows() {
+    const tableKey = getTableKey();
+    if (!tableKey) return;
+
+    let rows;
+    try {
+        const response = await fetch(`/api/deleted/${tableKey}`);
+        const result = await response.json();
+        if (!response.ok) throw new Error(result.error || 'Failed to load deleted rows');
+        rows = result.rows;
+    } catch (e) {
+        showToast(e.message, true);
+        return;
+    }
+
+    let panel = document.getElementById('deleted-rows-panel');
+    if (!panel) {
+        panel = document.createElement('div');
+        panel.id = 'deleted-rows-panel';
+        panel.className = 'fixed bottom-4 right-4 w-96 max-h-96 overflow-y-auto bg-white dark:bg-gray-800 border border-gray-200 dark:border-gray-700 rounded-lg shadow-lg p-4 z-40';
+        document.body.appendChild(panel);
+    }
+    panel.innerHTML = `
+        <div class="flex items-center justify-between mb-2">
+            <span class="text-sm font-medium text-gray-900 dark:text-white">Recently deleted</span>
+            <button type="button" onclick="hideDeletedRows()" class="text-gray-400 hover:text-gray-600 dark:hover:text-gray-200">&times;</button>
+        </div>
+        ${rows.length === 0 ? '<p class="text-xs text-gray-500 dark:text-gray-400">No deleted rows</p>' : ''}
+        <ul class="space-y-2">
+            ${rows.map(row => `
+                <li class="flex items-center justify-between text-xs text-gray-600 dark:text-gray-400">
+                    <span class="truncate">
+                        <span class="font-medium text-gray-900 dark:text-white">${escapeHtml(row.rowKey)}</span>
+                        ${new Date(row.deletedAt).toLocaleString()}${row.deletedBy ? ` by ${escapeHtml(row.deletedBy)}` : ''}
+                    </span>
+                    ${row.restored
+                        ? '<span class="text-gray-400">Restored</span>'
+                        : `<button type="button" onclick="restoreDeletedRow('${row.historyId}')" class="text-blue-600 hover:underline dark:text-blue-400">Restore</button>`}
+                </li>
+            `).join('')}
+        </ul>
+    `;
+}
+
+function hideDeletedRows() {
+    const panel = document.getElementById('deleted-rows-panel');
+    if (panel) panel.remove();
+}
+
+// Restore one deleted row and refresh the table and the panel
+async function restoreDeletedRow(historyId) {
+    const tableKey = getTableKey();
+    try {
+        const response = await fetch(`/api/restore/${tableKey}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ ids: [historyId] })
+        });
+        const result = await response.json();
+        if (!response.ok) throw new Error(result.error || 'Restore failed');
+
+        const row = result.rows[0];
+        if (!row.restored) {
+            showToast(`Can't restore ${row.rowKey || 'row'}: ${row.error}`, true);
+            return;
+        }
+        showToast(`Restored ${row.rowKey}`);
+        const url = new URL(window.location.href);
+        htmx.ajax('GET', url.pathname + url.search, { target: '#table-container', swap: 'innerHTML' });
+        showDeletedRows();
+    } catch (e) {
+        console.error('restore error:', e);
+        showToast(e.message, true);
+    }
+}
+
+// ============================================================================
+// Row History
+// ============================================================================
+
+// Show the drawer listing what happened to the selected row, oldest first
+async function showRowHistory(rowKey) {
+    const tableKey = getTableKey();
+    if (!tableKey) return;
+    if (!rowKey) {
+        if (selectedRows.size !== 1) return;
+        rowKey = [...selectedRows][0];
+    }
+
+    let history;
+    try {
+        const response = await fetch(`/api/row-history/${tableKey}?key=${encodeURIComponent(rowKey)}`);
+        history = await response.json();
+        if (!response.ok) throw new Error(history.error || 'Failed to load row history');
+    } catch (e) {
+        showToast(e.message, true);
+        return;
+    }
+
+    let drawer = document.getElementById('row-history-drawer');
+    if (!drawer) {
+        drawer = document.createElement('div');
+        drawer.id = 'row-history-drawer';
+        drawer.className = 'fixed inset-y-0 right-0 w-96 overflow-y-auto bg-white dark:bg-gray-800 border-l border-gray-200 dark:border-gray-700 shadow-lg p-4 z-40';
+        document.body.appendChild(drawer);
+    }
+    drawer.innerHTML = `
+        <div class="flex items-center justify-between mb-1">
+            <span class="text-sm font-medium text-gray-900 dark:text-white">History of ${escapeHtml(history.rowKey)}</span>
+            <button type="button" onclick="hideRowHistory()" class="text-gray-400 hover:text-gray-600 dark:hover:text-gray-200">&times;</button>
+        </div>
+        ${history.keys.length > 0 ? `<p class="text-xs text-gray-500 dark:text-gray-400 mb-2">Earlier keys: ${history.keys.map(escapeHtml).join(', ')}</p>` : ''}
+        ${history.truncated ? '<p class="text-xs text-amber-600 dark:text-amber-400 mb-2">Only the latest changes are shown</p>' : ''}
+        ${history.entries.length === 0 ? '<p class="text-xs text-gray-500 dark:text-gray-400">No changes recorded</p>' : ''}
+        <ol class="border-l border-gray-200 dark:border-gray-700 ml-1 space-y-3">
+            ${history.entries.map(renderRowHistoryEntry).join('')}
+        </ol>
+    `;
+}
+
+// Render one audit entry of a row's history
+function renderRowHistoryEntry(entry) {
+    const who = entry.userEmail || entry.ipAddress || '';
+    let what;
+    switch (entry.action) {
+        case 'cell_edit':
+            what = `<span class="font-medium">${escapeHtml(entry.columnName)}</span>: ${escapeHtml(entry.oldValue || '(empty)')} &rarr; ${escapeHtml(entry.newValue || '(empty)')}`;
+            break;
+        case 'row_delete':
+            what = '<span class="font-medium text-red-600 dark:text-red-400">Deleted</span>';
+            break;
+        default:
+            what = entry.columnName
+                ? `<span class="font-medium">${escapeHtml(entry.columnName)}</span> restored: ${escapeHtml(entry.oldValue || '(empty)')} &rarr; ${escapeHtml(entry.newValue || '(empty)')}`
+                : '<span class="font-medium text-green-600 dark:text-green-400">Restored</span>';
+    }
+    return `
+        <li class="pl-3 text-xs text-gray-600 dark:text-gray-400">
+            <div class="text-gray-900 dark:text-white">${what}</div>
+            <div>${new Date(entry.createdAt).toLocaleString()}${who ? ` by ${escapeHtml(who)}` : ''}</div>
+            ${entry.reason ? `<div class="italic">${escapeHtml(entry.reason)}</div>` : ''}
+        </li>
+    `;
+}
+
+function hideRowHistory() {
+    const drawer = document.getElementById('row-history-drawer');
+    if (drawer) drawer.remove();
+}
+
 // Cancel editing and restore original cell
 function cancelEdit() {
     if (!currentEditCell) return;
@@ -3017,6 +3786,13 @@ function applyFilter(container) {
     const colName = container.dataset.col;
     const tableKey = container.dataset.table;
 
+    // "Is empty" / "is not empty" replace any other filter on the column
+    const presence = container.querySelector('.filter-presence');
+    if (presence && presence.value) {
+        navigateWithFilter(colName, presence.value + ':');
+        return;
+    }
+
     switch (filterType) {
         case 'text':
             applyTextFilter(container, colName, tableKey);
@@ -3046,6 +3822,11 @@ function applyTextFilter(container, colName, tableKey) {
     const op = opSelect.value;
     const val = valInput.value.trim();
 
+    if (op === 'null' || op === 'notnull') {
+        navigateWithFilter(colName, op + ':');
+        return;
+    }
+
     if (!val) {
         showToast('Please enter a filter value', true);
         return;
@@ -3555,7 +4336,10 @@ async function executeRollback() {
 
         const result = await response.json();
 
-        if (result.success) {
+        if (result.status === 'pending_approval') {
+            hideRollbackModal();
+            showToast('Rollback awaits approval by a second user');
+        } else if (result.success) {
             hideRollbackModal();
             showToast(`Rolled back ${result.rowsDeleted} rows`);
 
@@ -3588,6 +4372,111 @@ async function executeRollback() {
     }
 }
 
+// Report the outcome of a table reset from the dashboard card menu
+function showResetToast(event) {
+    const xhr = event.detail.xhr;
+    if (xhr.status === 202) {
+        showToast('Reset awaits approval by a second user');
+    } else if (event.detail.successful) {
+        showToast('Table reset successfully');
+    } else {
+        let message = 'Unknown error';
+        try {
+            message = JSON.parse(xhr.responseText).error || message;
+        } catch (e) {}
+        showToast('Reset failed: ' + message, true);
+    }
+}
+
+// ============================================================================
+// Data Quality Panel
+// ============================================================================
+
+const QUALITY_FLAG_LABELS = {
+    empty: 'All empty',
+    missing_required: 'Required values missing',
+    high_null_rate: 'Mostly empty',
+    constant: 'Single value',
+    outliers: 'Outliers',
+    future_dates: 'Future dates',
+    unknown_values: 'Unknown values'
+};
+
+// Open the data quality report of the table whose card menu holds btn
+async function showDataQuality(btn) {
+    const menu = btn.closest('[id^="card-menu-"]');
+    const tableKey = menu.id.slice('card-menu-'.length);
+    const label = btn.closest('.table-card').querySelector('h3').textContent;
+    closeCardMenus();
+
+    const content = document.getElementById('quality-content');
+    document.getElementById('quality-table-label').textContent = label;
+    content.innerHTML = '<div class="text-sm text-gray-500 dark:text-gray-400">Profiling columns...</div>';
+    showModal('quality-modal');
+
+    try {
+        const response = await fetch(`/api/quality/${encodeURIComponent(tableKey)}`);
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to load data quality');
+        }
+        content.innerHTML = renderDataQuality(data);
+    } catch (e) {
+        content.innerHTML = `<div class="text-sm text-red-600 dark:text-red-400">${escapeHtml(e.message)}</div>`;
+    }
+}
+
+function renderDataQuality(report) {
+    if (report.rows === 0) {
+        return '<div class="text-sm text-gray-500 dark:text-gray-400">No rows yet</div>';
+    }
+    const pct = (rate) => `${(rate * 100).toFixed(rate > 0 && rate < 0.001 ? 2 : 1)}%`;
+    const num = (n) => Number(n).toLocaleString(undefined, { maximumFractionDigits: 2 });
+
+    const rows = report.columns.map(col => {
+        let range = '';
+        if (col.minLength !== undefined) {
+            range = `${col.minLength}–${col.maxLength} chars`;
+        } else if (col.minDate) {
+            range = `${col.minDate} – ${col.maxDate}`;
+        } else if (col.lowerFence !== undefined) {
+            range = `${num(col.lowerFence)} – ${num(col.upperFence)}`;
+        }
+        const flags = (col.flags || []).map(f =>
+            `<span class="inline-block mr-1 mb-1 px-1.5 py-0.5 rounded text-[10px] bg-amber-100 text-amber-800 dark:bg-amber-900/30 dark:text-amber-400">${escapeHtml(QUALITY_FLAG_LABELS[f] || f)}</span>`
+        ).join('');
+        const notes = [];
+        if (col.outliers) notes.push(`${num(col.outliers)} outliers`);
+        if (col.futureDates) notes.push(`${num(col.futureDates)} future`);
+        if (col.unknownValues) notes.push(`${num(col.unknownValues)} unknown`);
+        return `<tr class="border-t border-gray-100 dark:border-gray-700">
+            <td class="py-1.5 pr-3 font-medium text-gray-900 dark:text-white">${escapeHtml(col.column)}</td>
+            <td class="py-1.5 pr-3 text-gray-500 dark:text-gray-400">${escapeHtml(col.type)}</td>
+            <td class="py-1.5 pr-3 text-right">${pct(col.nullRate)}</td>
+            <td class="py-1.5 pr-3 text-right">${num(col.distinct)}</td>
+            <td class="py-1.5 pr-3 whitespace-nowrap">${escapeHtml(range)}</td>
+            <td class="py-1.5 pr-3 whitespace-nowrap">${escapeHtml(notes.join(', '))}</td>
+            <td class="py-1.5">${flags}</td>
+        </tr>`;
+    }).join('');
+
+    return `<div class="text-sm text-gray-600 dark:text-gray-300 mb-3">${num(report.rows)} rows</div>
+        <table class="w-full text-xs text-gray-700 dark:text-gray-300">
+            <thead>
+                <tr class="text-left text-gray-500 dark:text-gray-400">
+                    <th class="pb-2 pr-3">Column</th>
+                    <th class="pb-2 pr-3">Type</th>
+                    <th class="pb-2 pr-3 text-right">Empty</th>
+                    <th class="pb-2 pr-3 text-right">Distinct</th>
+                    <th class="pb-2 pr-3" title="Text lengths, date range, or the fences outside which numbers are outliers">Range</th>
+                    <th class="pb-2 pr-3">Odd values</th>
+                    <th class="pb-2">Flags</th>
+                </tr>
+            </thead>
+            <tbody>${rows}</tbody>
+        </table>`;
+}
+
 // ============================================================================
 // Reset All Data Modal
 // ============================================================================
@@ -3633,7 +4522,10 @@ async function executeResetAll() {
             headers: { 'Content-Type': 'application/json' }
         });
 
-        if (response.ok) {
+        if (response.status === 202) {
+            hideResetAllModal();
+            showToast('Reset awaits approval by a second user');
+        } else if (response.ok) {
             hideResetAllModal();
             showToast('All data has been reset');
             // Refresh the page to show updated state
@@ -3692,9 +4584,16 @@ function hideBulkEditModal() {
     const columnSelect = document.getElementById('bulk-edit-column');
     if (columnSelect) columnSelect.selectedIndex = 0;
 
-    // Disable submit button
+    // Reset mode, find text and preview
+    const findInput = document.getElementById('bulk-edit-find');
+    if (findInput) findInput.value = '';
+    clearBulkEditPreview();
+
+    // Disable submit and preview buttons
     const submitBtn = document.getElementById('bulk-edit-submit');
     if (submitBtn) submitBtn.disabled = true;
+    const previewBtn = document.getElementById('bulk-edit-preview-btn');
+    if (previewBtn) previewBtn.disabled = true;
 }
 
 // Populate column dropdown with editable columns
@@ -3721,9 +4620,9 @@ function populateBulkEditColumns() {
     // Clear existing options except the placeholder
     columnSelect.innerHTML = '<option value="">Select a column...</option>';
 
-    // Add editable columns (exclude unique key columns)
+    // Add editable columns (exclude unique key and derived columns)
     for (const meta of columnsMeta) {
-        if (meta.isUniqueKey) continue;
+        if (meta.isUniqueKey || meta.derived) continue;
 
         const option = document.createElement('option');
         option.value = meta.name;
@@ -3738,38 +4637,142 @@ function onBulkEditColumnChange() {
     const valueContainer = document.getElementById('bulk-edit-value-container');
     const valueWrapper = document.getElementById('bulk-edit-value-wrapper');
     const submitBtn = document.getElementById('bulk-edit-submit');
+    const previewBtn = document.getElementById('bulk-edit-preview-btn');
+    const modeSelect = document.getElementById('bulk-edit-mode');
 
     if (!columnSelect || !valueContainer) return;
 
     const colName = columnSelect.value;
-    if (!colName) {
-        valueContainer.innerHTML = '';
-        if (valueWrapper) valueWrapper.classList.add('hidden');
-        if (submitBtn) submitBtn.disabled = true;
-        return;
-    }
-
-    const meta = getColumnMeta(colName);
+    const meta = colName ? getColumnMeta(colName) : null;
     if (!meta) {
         valueContainer.innerHTML = '';
         if (valueWrapper) valueWrapper.classList.add('hidden');
         if (submitBtn) submitBtn.disabled = true;
+        if (previewBtn) previewBtn.disabled = true;
         return;
     }
 
-    // Build appropriate input based on column type
-    const inputHTML = buildBulkEditInput(meta);
-    valueContainer.innerHTML = inputHTML;
+    // Offer the modes that fit the column type
+    if (modeSelect) {
+        modeSelect.innerHTML = bulkEditModes(meta.type).map(([mode, label]) =>
+            `<option value="${mode}">${label}</option>`
+        ).join('');
+    }
 
     // Show the value wrapper
     if (valueWrapper) valueWrapper.classList.remove('hidden');
 
-    // Enable submit button
+    // Enable submit and preview buttons
     if (submitBtn) submitBtn.disabled = false;
+    if (previewBtn) previewBtn.disabled = false;
 
-    // Focus the input
-    const input = valueContainer.querySelector('input, select');
-    if (input) input.focus();
+    onBulkEditModeChange();
+}
+
+// Bulk edit modes for a column type, as [mode, label]
+function bulkEditModes(type) {
+    const set = ['set', 'Set to a value'];
+    const replace = ['replace', 'Find and replace'];
+    switch (type) {
+        case 'numeric':
+            return [set, ['adjust', 'Adjust (+10%, *1.05, -25)'], replace];
+        case 'text':
+        case 'enum':
+            return [set, replace, ['trim', 'Trim spaces'], ['upper', 'UPPER CASE'], ['lower', 'lower case']];
+        default:
+            return [set];
+    }
+}
+
+// Handle mode change: show the inputs the mode needs
+function onBulkEditModeChange() {
+    const colName = document.getElementById('bulk-edit-column')?.value;
+    const meta = colName ? getColumnMeta(colName) : null;
+    const mode = document.getElementById('bulk-edit-mode')?.value || 'set';
+    const valueContainer = document.getElementById('bulk-edit-value-container');
+    const inputWrapper = document.getElementById('bulk-edit-input-wrapper');
+    const findWrapper = document.getElementById('bulk-edit-find-wrapper');
+    const valueLabel = document.getElementById('bulk-edit-value-label');
+    if (!meta || !valueContainer) return;
+
+    clearBulkEditPreview();
+    if (findWrapper) findWrapper.classList.toggle('hidden', mode !== 'replace');
+    if (inputWrapper) inputWrapper.classList.toggle('hidden', ['trim', 'upper', 'lower'].includes(mode));
+
+    const inputClass = 'w-full px-3 py-2 text-sm border border-gray-300 dark:border-gray-600 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-700 dark:text-white';
+    switch (mode) {
+        case 'replace':
+            if (valueLabel) valueLabel.textContent = 'Replace With';
+            valueContainer.innerHTML = `<input type="text" id="bulk-edit-value" class="${inputClass}">`;
+            break;
+        case 'adjust':
+            if (valueLabel) valueLabel.textContent = 'Adjustment';
+            valueContainer.innerHTML = `<input type="text" id="bulk-edit-value" class="${inputClass}" placeholder="+10%, -5%, *1.05, /2, +25">`;
+            break;
+        default:
+            if (valueLabel) valueLabel.textContent = 'New Value';
+            valueContainer.innerHTML = buildBulkEditInput(meta);
+    }
+
+    // Focus the first input
+    if (mode === 'replace') document.getElementById('bulk-edit-find')?.focus();
+    else valueContainer.querySelector('input, select')?.focus();
+}
+
+// The bulk edit described by the modal's inputs
+function bulkEditRequest() {
+    return {
+        keys: Array.from(selectedRows),
+        column: document.getElementById('bulk-edit-column')?.value || '',
+        mode: document.getElementById('bulk-edit-mode')?.value || 'set',
+        find: document.getElementById('bulk-edit-find')?.value || '',
+        value: document.getElementById('bulk-edit-value')?.value || ''
+    };
+}
+
+// Show the values the bulk edit would write
+async function previewBulkEdit() {
+    const tableKey = getTableKey();
+    const container = document.getElementById('bulk-edit-preview');
+    if (!tableKey || !container || selectedRows.size === 0) return;
+
+    let preview;
+    try {
+        const response = await fetch(`/api/bulk-edit/${tableKey}/preview`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(bulkEditRequest())
+        });
+        preview = await response.json();
+        if (!response.ok) throw new Error(preview.error || 'Preview failed');
+    } catch (e) {
+        showToast(e.message, true);
+        return;
+    }
+
+    const changed = preview.changes.length - preview.failed;
+    container.innerHTML = `
+        <p class="mb-2 text-gray-600 dark:text-gray-400">
+            ${changed} to change, ${preview.unchanged} unchanged${preview.failed > 0 ? `, <span class="text-red-600 dark:text-red-400">${preview.failed} failing</span>` : ''}
+        </p>
+        <table class="w-full">
+            ${preview.changes.map(c => `
+                <tr class="border-t border-gray-100 dark:border-gray-700">
+                    <td class="py-1 pr-2 font-medium text-gray-900 dark:text-white">${escapeHtml(c.rowKey)}</td>
+                    <td class="py-1 pr-2 text-gray-500 dark:text-gray-400 line-through">${escapeHtml(c.oldValue)}</td>
+                    <td class="py-1 ${c.error ? 'text-red-600 dark:text-red-400' : 'text-gray-900 dark:text-white'}">${escapeHtml(c.error || c.newValue || '(empty)')}</td>
+                </tr>
+            `).join('')}
+        </table>
+    `;
+    container.classList.remove('hidden');
+}
+
+function clearBulkEditPreview() {
+    const container = document.getElementById('bulk-edit-preview');
+    if (!container) return;
+    container.innerHTML = '';
+    container.classList.add('hidden');
 }
 
 // Build input for bulk edit (similar to buildEditInput but without keydown handlers)
@@ -3813,17 +4816,11 @@ async function confirmBulkEdit() {
         return;
     }
 
-    const columnSelect = document.getElementById('bulk-edit-column');
-    const valueInput = document.getElementById('bulk-edit-value');
     const submitBtn = document.getElementById('bulk-edit-submit');
+    const req = bulkEditRequest();
+    const keys = req.keys;
 
-    if (!columnSelect || !valueInput) return;
-
-    const column = columnSelect.value;
-    const value = valueInput.value;
-    const keys = Array.from(selectedRows);
-
-    if (!column) {
+    if (!req.column) {
         showToast('Please select a column', true);
         return;
     }
@@ -3838,7 +4835,7 @@ async function confirmBulkEdit() {
         const response = await fetch(`/api/bulk-edit/${tableKey}`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ keys, column, value })
+            body: JSON.stringify(req)
         });
 
         const result = await response.json();
@@ -3881,3 +4878,207 @@ document.addEventListener('click', function(e) {
         hideBulkEditModal();
     }
 });
+
+// ============================================================================
+// Add Row
+// ============================================================================
+
+// Key of the row being cloned, or null when adding a new row
+let addRowSource = null;
+
+// Show add row modal with one input per column
+function showAddRowModal() {
+    const container = document.getElementById('add-row-fields');
+    if (!container) return;
+
+    addRowSource = null;
+    const title = document.getElementById('add-row-title');
+    if (title) title.textContent = 'Add Row';
+
+    // Re-parse column metadata if not available
+    if (!columnsMeta || columnsMeta.length === 0) {
+        const tableContainer = document.getElementById('table-container');
+        if (tableContainer && tableContainer.dataset.columnsMeta) {
+            try {
+                columnsMeta = JSON.parse(tableContainer.dataset.columnsMeta);
+            } catch (e) {
+                console.error('Failed to parse columns meta:', e);
+                columnsMeta = [];
+            }
+        }
+    }
+    if (!columnsMeta || columnsMeta.length === 0) return;
+
+    // Derived columns are computed, not entered
+    container.innerHTML = columnsMeta.filter(meta => !meta.derived).map(meta => `
+        <div>
+            <label class="block text-sm font-medium text-gray-700 dark:text-gray-300 mb-1">
+                ${escapeHtml(meta.name)}${meta.isUniqueKey ? ' <span class="text-xs text-gray-400">(key)</span>' : ''}
+            </label>
+            ${buildAddRowInput(meta)}
+            <p class="add-row-error hidden mt-1 text-xs text-red-600 dark:text-red-400" data-column="${escapeHtml(meta.name)}"></p>
+        </div>
+    `).join('');
+
+    showModal('add-row-modal');
+
+    const first = container.querySelector('[data-column]');
+    if (first) first.focus();
+}
+
+// Show add row modal filled in with the selected row's values
+function showCloneRowModal() {
+    if (selectedRows.size !== 1) return;
+    const rowKey = [...selectedRows][0];
+    const row = document.querySelector(`tr[data-row-key="${CSS.escape(rowKey)}"]`);
+    if (!row) return;
+
+    showAddRowModal();
+
+    row.querySelectorAll('td.editable-cell').forEach(cell => {
+        const input = document.querySelector(`#add-row-fields .add-row-input[data-column="${CSS.escape(cell.dataset.colName)}"]`);
+        if (!input) return;
+        input.value = cell.dataset.rawValue || '';
+        input.dataset.original = input.value;
+    });
+
+    addRowSource = rowKey;
+    const title = document.getElementById('add-row-title');
+    if (title) title.textContent = 'Clone Row';
+}
+
+// Hide add row modal
+function hideAddRowModal() {
+    hideModal('add-row-modal');
+    addRowSource = null;
+
+    const container = document.getElementById('add-row-fields');
+    if (container) container.innerHTML = '';
+}
+
+// Build the input for one column of a new row
+function buildAddRowInput(meta) {
+    const inputClass = 'add-row-input w-full px-3 py-2 text-sm border border-gray-300 dark:border-gray-600 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-700 dark:text-white';
+    const col = escapeHtml(meta.name);
+
+    switch (meta.type) {
+        case 'numeric':
+            return `<input type="number" step="any" data-column="${col}" class="${inputClass}">`;
+
+        case 'date':
+            return `<input type="date" data-column="${col}" class="${inputClass}">`;
+
+        case 'bool':
+            return `<select data-column="${col}" class="${inputClass}">
+                <option value="">-</option>
+                <option value="true">Yes</option>
+                <option value="false">No</option>
+            </select>`;
+
+        case 'enum':
+            const options = (meta.enumValues || []).map(v =>
+                `<option value="${escapeHtml(v)}">${escapeHtml(v)}</option>`
+            ).join('');
+            return `<select data-column="${col}" class="${inputClass}">
+                <option value="">-</option>
+                ${options}
+            </select>`;
+
+        case 'text':
+        default:
+            return `<input type="text" data-column="${col}" class="${inputClass}">`;
+    }
+}
+
+// Show each column's error under its input
+function showAddRowErrors(fieldErrors) {
+    document.querySelectorAll('#add-row-fields .add-row-error').forEach(el => {
+        const msg = fieldErrors && fieldErrors[el.dataset.column];
+        el.textContent = msg || '';
+        el.classList.toggle('hidden', !msg);
+    });
+}
+
+// Submit the new row
+async function confirmAddRow() {
+    const tableKey = getTableKey();
+    if (!tableKey) {
+        hideAddRowModal();
+        return;
+    }
+
+    // Empty inputs are left out, so the column is NULL. A clone sends only
+    // the values changed from the source row, so the rest are copied as
+    // stored rather than as displayed
+    const rowKey = addRowSource;
+    const values = {};
+    document.querySelectorAll('#add-row-fields .add-row-input').forEach(input => {
+        if (rowKey) {
+            if (input.value !== (input.dataset.original || '')) {
+                values[input.dataset.column] = input.value;
+            }
+        } else if (input.value.trim() !== '') {
+            values[input.dataset.column] = input.value;
+        }
+    });
+    if (!rowKey && Object.keys(values).length === 0) {
+        showToast('Enter at least one value', true);
+        return;
+    }
+
+    const submitBtn = document.getElementById('add-row-submit');
+    if (submitBtn) {
+        submitBtn.disabled = true;
+        submitBtn.textContent = 'Adding...';
+    }
+    showAddRowErrors(null);
+
+    try {
+        const response = rowKey
+            ? await fetch(`/api/clone/${tableKey}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ rowKey, values })
+            })
+            : await fetch(`/api/insert/${tableKey}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ values })
+            });
+
+        const result = await response.json();
+
+        if (!response.ok) {
+            showToast(result.error || 'Add row failed', true);
+        } else if (result.success) {
+            hideAddRowModal();
+            showToast(rowKey ? 'Row cloned' : 'Row added');
+
+            // Refresh the table
+            const url = new URL(window.location.href);
+            htmx.ajax('GET', url.pathname + url.search, { target: '#table-container', swap: 'innerHTML' });
+        } else if (result.fieldErrors) {
+            showAddRowErrors(result.fieldErrors);
+        } else if (result.duplicateKey) {
+            showToast(`A row with key ${result.rowKey} already exists`, true);
+        } else {
+            showToast(result.validationError || 'Add row failed', true);
+        }
+    } catch (e) {
+        console.error('Add row error:', e);
+        showToast('Add row failed', true);
+    } finally {
+        if (submitBtn) {
+            submitBtn.disabled = false;
+            submitBtn.textContent = 'Add Row';
+        }
+    }
+}
+
+// Close add row modal on outside click
+document.addEventListener('click', function(e) {
+    const modal = document.getElementById('add-row-modal');
+    if (modal && e.target === modal) {
+        hideAddRowModal();
+    }
+});
@@ -156,6 +156,15 @@
             return;
         }
 
+        // Undo/redo cell edits (Ctrl+Z / Ctrl+Shift+Z)
+        if ((e.ctrlKey || e.metaKey) && e.key.toLowerCase() === 'z') {
+            if (typeof undoCellEdit === 'function' && getTableKey()) {
+                e.preventDefault();
+                undoCellEdit(e.shiftKey);
+            }
+            return;
+        }
+
         // Single key shortcuts
         switch (e.key) {
             case '/':
@@ -246,6 +255,14 @@
                 }
                 break;
 
+            // Recently deleted rows (Shift+D)
+            case 'D':
+                if (typeof showDeletedRows === 'function' && getTableKey()) {
+                    e.preventDefault();
+                    showDeletedRows();
+                }
+                break;
+
             // Bulk edit selected
             case 'b':
                 if (typeof selectedRows !== 'undefined' && selectedRows.size > 0) {
@@ -561,6 +578,14 @@
                                     <dt><kbd class="kbd">d</kbd></dt>
                                     <dd class="text-gray-600 dark:text-gray-400">Delete selected</dd>
                                 </div>
+                                <div class="flex justify-between">
+                                    <dt><kbd class="kbd">D</kbd></dt>
+                                    <dd class="text-gray-600 dark:text-gray-400">Recently deleted rows</dd>
+                                </div>
+                                <div class="flex justify-between">
+                                    <dt><kbd class="kbd">Ctrl</kbd> <kbd class="kbd">z</kbd></dt>
+                                    <dd class="text-gray-600 dark:text-gray-400">Undo cell edit (add Shift to redo)</dd>
+                                </div>
                                 <div class="flex justify-between">
                                     <dt><kbd class="kbd">E</kbd></dt>
                                     <dd class="text-gray-600 dark:text-gray-400">Export CSV</dd>
@@ -30,6 +30,8 @@ class SSEClient {
      * @param {function} [options.onComplete] - Called on completion
      * @param {function} [options.onError] - Called on error events
      * @param {function} [options.onConnectionChange] - Called when connection state changes
+     * @param {string} [options.lastEventId] - Event ID to resume after, e.g. one saved before a page refresh
+     * @param {function} [options.onEventId] - Called with each new event ID
      */
     constructor(url, options = {}) {
         this.url = url;
@@ -42,11 +44,12 @@ class SSEClient {
         this.onComplete = options.onComplete || (() => {});
         this.onError = options.onError || (() => {});
         this.onConnectionChange = options.onConnectionChange || (() => {});
+        this.onEventId = options.onEventId || (() => {});
 
         // Internal state
         this.eventSource = null;
         this.retryCount = 0;
-        this.lastEventId = null;
+        this.lastEventId = options.lastEventId ?? null;
         this.closed = false;
         this.reconnectTimer = null;
     }
@@ -126,8 +129,9 @@ class SSEClient {
      * @param {MessageEvent} event - The SSE event
      */
     trackEventId(event) {
-        if (event.lastEventId) {
+        if (event.lastEventId && event.lastEventId !== this.lastEventId) {
             this.lastEventId = event.lastEventId;
+            this.onEventId(this.lastEventId);
         }
     }
 
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Export snapshots record a manifest of row hashes at export time so that
+-- later table state can be diffed against it without keeping the file.
+CREATE TABLE export_snapshots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_key TEXT NOT NULL,
+    name TEXT NOT NULL,
+    -- Search and column filters the export was taken with, reapplied on diff
+    search_query TEXT NOT NULL DEFAULT '',
+    filters JSONB NOT NULL DEFAULT '[]',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_export_snapshots_table ON export_snapshots(table_key, created_at DESC);
+
+-- One row per exported record: unique key value and SHA-256 of its contents
+CREATE TABLE export_snapshot_rows (
+    snapshot_id UUID NOT NULL REFERENCES export_snapshots(id) ON DELETE CASCADE,
+    row_key TEXT NOT NULL,
+    row_hash TEXT NOT NULL,
+    PRIMARY KEY (snapshot_id, row_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS export_snapshot_rows;
+DROP TABLE IF EXISTS export_snapshots;
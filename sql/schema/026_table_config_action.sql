@@ -0,0 +1,24 @@
+-- +goose Up
+-- Table settings applied from a bootstrap file are audited as table_config
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing table_config entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete'
+    )) NOT VALID;
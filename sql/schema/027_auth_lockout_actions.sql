@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing auth entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config'
+    )) NOT VALID;
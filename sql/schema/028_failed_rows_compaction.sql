@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Old failed rows are compacted to save space while keeping one row per
+-- failure, so counts are unchanged:
+--   compressed: row_data moved to row_data_gz as DEFLATE-compressed CSV
+--   summarized: row_data reduced to the table's unique key columns
+ALTER TABLE upload_failed_rows
+    ADD COLUMN row_data_gz BYTEA,
+    ADD COLUMN compacted TEXT CHECK (compacted IN ('compressed', 'summarized'));
+
+CREATE INDEX idx_upload_failed_rows_uncompacted
+    ON upload_failed_rows(created_at) WHERE compacted IS NULL;
+
+-- +goose Down
+-- Compressed rows cannot be inflated in SQL; their row_data stays empty.
+DROP INDEX IF EXISTS idx_upload_failed_rows_uncompacted;
+ALTER TABLE upload_failed_rows
+    DROP COLUMN IF EXISTS compacted,
+    DROP COLUMN IF EXISTS row_data_gz;
@@ -0,0 +1,57 @@
+-- +goose Up
+-- Provenance for audit history imported from another deployment's export.
+-- Imported entries go to audit_log_archive with import_id set; entries
+-- recorded by this deployment have import_id NULL.
+CREATE TABLE audit_log_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source TEXT NOT NULL,               -- Operator-supplied label for the origin deployment
+    file_name TEXT,
+    format TEXT NOT NULL CHECK (format IN ('csv', 'json')),
+    file_sha256 TEXT NOT NULL,          -- Digest of the file exactly as uploaded
+    chain_digest TEXT NOT NULL,         -- Running SHA-256 over the entries in file order
+    entries_total INT NOT NULL,
+    entries_imported INT NOT NULL,
+    entries_skipped INT NOT NULL,       -- IDs already present in audit_log or the archive
+    first_entry_at TIMESTAMPTZ,
+    last_entry_at TIMESTAMPTZ,
+    notes TEXT[] NOT NULL DEFAULT '{}',
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_imports_time ON audit_log_imports(imported_at DESC);
+
+ALTER TABLE audit_log_archive
+    ADD COLUMN import_id UUID REFERENCES audit_log_imports(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_archive_import ON audit_log_archive(import_id) WHERE import_id IS NOT NULL;
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing audit_import entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock'
+    )) NOT VALID;
+
+DROP INDEX IF EXISTS idx_audit_archive_import;
+ALTER TABLE audit_log_archive DROP COLUMN IF EXISTS import_id;
+DROP TABLE IF EXISTS audit_log_imports;
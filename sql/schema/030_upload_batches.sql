@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Uploads started together (see StartUploadBatch) share a batch ID so they
+-- can be reported on and rolled back as a unit. NULL for single uploads.
+ALTER TABLE csv_uploads ADD COLUMN batch_id UUID;
+
+CREATE INDEX idx_csv_uploads_batch_id
+    ON csv_uploads(batch_id) WHERE batch_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_csv_uploads_batch_id;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS batch_id;
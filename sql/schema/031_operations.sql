@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- One row per long-running operation (uploads, upload batches, exports,
+-- retention runs, audit imports). Live step progress is kept in memory;
+-- this table is the durable record of what ran, who started it and how it
+-- ended.
+CREATE TABLE operations (
+    id          UUID PRIMARY KEY,
+    kind        TEXT NOT NULL,
+    status      TEXT NOT NULL CHECK (status IN ('running', 'complete', 'failed', 'cancelled')),
+    table_key   TEXT,
+    initiator   TEXT NOT NULL DEFAULT '',   -- Client IP, or 'system' for scheduled work
+    user_agent  TEXT NOT NULL DEFAULT '',
+    parent_id   UUID,                       -- Enclosing operation, e.g. the batch of an upload
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT,
+    error       TEXT,
+    result_link TEXT                        -- Where the result can be viewed, if anywhere
+);
+
+CREATE INDEX idx_operations_started_at ON operations(started_at DESC);
+CREATE INDEX idx_operations_kind_started_at ON operations(kind, started_at DESC);
+CREATE INDEX idx_operations_running ON operations(started_at) WHERE status = 'running';
+
+-- +goose Down
+DROP TABLE IF EXISTS operations;
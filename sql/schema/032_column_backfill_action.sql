@@ -0,0 +1,30 @@
+-- +goose Up
+-- Column backfills (Service.BackfillColumn) are recorded in the audit log.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing column_backfill entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import'
+    )) NOT VALID;
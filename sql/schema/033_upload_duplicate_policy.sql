@@ -0,0 +1,11 @@
+-- +goose Up
+-- Duplicate policy an upload ran with (skip, overwrite, fail-upload,
+-- keep-both) and how many rows it skipped or replaced within the file.
+-- NULL for uploads recorded before policies existed.
+ALTER TABLE csv_uploads ADD COLUMN duplicate_policy TEXT
+    CHECK (duplicate_policy IN ('skip', 'overwrite', 'fail-upload', 'keep-both'));
+ALTER TABLE csv_uploads ADD COLUMN rows_duplicate INT;
+
+-- +goose Down
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS rows_duplicate;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS duplicate_policy;
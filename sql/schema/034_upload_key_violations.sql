@@ -0,0 +1,8 @@
+-- +goose Up
+-- Rows of an upload that violated the table's unique key: duplicate policy
+-- hits plus unique_violation insert failures. NULL for uploads recorded
+-- before violations were tracked.
+ALTER TABLE csv_uploads ADD COLUMN rows_key_violation INT;
+
+-- +goose Down
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS rows_key_violation;
@@ -0,0 +1,49 @@
+-- +goose Up
+-- Review workflow on uploads, separate from status (active/rolled_back):
+-- NULL until someone sets it, then needs-review, approved or archived.
+-- reviewed_at/reviewed_by/review_note describe the latest change.
+ALTER TABLE csv_uploads ADD COLUMN review_status TEXT
+    CHECK (review_status IN ('needs-review', 'approved', 'archived'));
+ALTER TABLE csv_uploads ADD COLUMN reviewed_at TIMESTAMP;
+ALTER TABLE csv_uploads ADD COLUMN reviewed_by TEXT;
+ALTER TABLE csv_uploads ADD COLUMN review_note TEXT;
+CREATE INDEX IF NOT EXISTS idx_csv_uploads_review_status
+    ON csv_uploads(review_status) WHERE review_status IS NOT NULL;
+
+-- Review changes are recorded in the audit log.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing upload_review entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill'
+    )) NOT VALID;
+
+DROP INDEX IF EXISTS idx_csv_uploads_review_status;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS review_note;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE csv_uploads DROP COLUMN IF EXISTS review_status;
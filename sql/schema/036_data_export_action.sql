@@ -0,0 +1,35 @@
+-- +goose Up
+-- Data exports and failed-row downloads (Service.LogExport) are recorded in
+-- the audit log.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing data_export entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review'
+    )) NOT VALID;
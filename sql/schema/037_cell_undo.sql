@@ -0,0 +1,8 @@
+-- +goose Up
+-- Cell edit undo/redo records a row_restore entry pointing at the cell_edit
+-- it reverted or reapplied; finding an edit's latest restore needs this.
+CREATE INDEX IF NOT EXISTS idx_audit_log_related
+    ON audit_log(related_audit_id, created_at DESC) WHERE related_audit_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_log_related;
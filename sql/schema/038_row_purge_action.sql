@@ -0,0 +1,37 @@
+-- +goose Up
+-- Rows purged from a soft-delete table's recycle bin, by hand or by the
+-- retention job, are recorded in the audit log.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing row_purge entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export'
+    )) NOT VALID;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Each operation's steps as last recorded, so the outcome of every step
+-- outlives the in-memory progress and a reset of all tables that stopped
+-- part way can be resumed.
+ALTER TABLE operations ADD COLUMN steps JSONB;
+
+-- +goose Down
+ALTER TABLE operations DROP COLUMN IF EXISTS steps;
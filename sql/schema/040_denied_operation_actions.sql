@@ -0,0 +1,40 @@
+-- +goose Up
+-- Operations refused before they ran (Service.LogDenied) are recorded in the
+-- audit log: access_denied for permission, network and read-only refusals,
+-- request_rejected for rate limits and duplicate-policy failures.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing denial entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge'
+    )) NOT VALID;
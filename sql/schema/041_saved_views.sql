@@ -0,0 +1,21 @@
+-- +goose Up
+-- Saved views: named search, filter, sort and visible-column settings per
+-- table, stored by display column name.
+CREATE TABLE saved_views (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_key TEXT NOT NULL,
+    name TEXT NOT NULL,
+    search_query TEXT NOT NULL DEFAULT '',
+    -- [{"column", "op", "value"}], combined with AND
+    filters JSONB NOT NULL DEFAULT '[]',
+    -- [{"column", "dir"}], in priority order
+    sorts JSONB NOT NULL DEFAULT '[]',
+    -- Visible columns in display order; empty shows all
+    columns JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT saved_views_table_name_unique UNIQUE (table_key, name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS saved_views;
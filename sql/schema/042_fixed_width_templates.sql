@@ -0,0 +1,7 @@
+-- +goose Up
+-- Layout of a fixed-width import template: column names, start positions
+-- and lengths. NULL for templates of delimited files.
+ALTER TABLE import_templates ADD COLUMN fixed_width JSONB;
+
+-- +goose Down
+ALTER TABLE import_templates DROP COLUMN IF EXISTS fixed_width;
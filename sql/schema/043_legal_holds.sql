@@ -0,0 +1,59 @@
+-- +goose Up
+-- Legal holds: while a hold is active, retention purges, resets, rollbacks
+-- and row deletes leave the rows it covers alone. Released holds are kept
+-- as history.
+CREATE TABLE legal_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    table_key TEXT NOT NULL,
+    name TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    -- [{"column", "op", "value"}], combined with AND; empty holds the whole table
+    filters JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    released_at TIMESTAMPTZ,
+    release_reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_legal_holds_active ON legal_holds(table_key) WHERE released_at IS NULL;
+
+-- Placing and releasing holds are audited at critical severity
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing hold entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected'
+    )) NOT VALID;
+
+DROP TABLE IF EXISTS legal_holds;
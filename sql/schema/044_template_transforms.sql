@@ -0,0 +1,8 @@
+-- +goose Up
+-- Join and split transforms of an import template, which build columns
+-- from several CSV columns or several columns from one. NULL for templates
+-- that only map columns.
+ALTER TABLE import_templates ADD COLUMN transforms JSONB;
+
+-- +goose Down
+ALTER TABLE import_templates DROP COLUMN IF EXISTS transforms;
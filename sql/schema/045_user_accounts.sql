@@ -0,0 +1,47 @@
+-- +goose Up
+-- Disabled users keep their auth_users row, so audit entries still
+-- resolve, and are marked inactive with the time they were disabled
+ALTER TABLE auth_users ADD COLUMN disabled_at TIMESTAMPTZ;
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release',
+        'user_create', 'user_disable', 'login_failure'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing user entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release'
+    ));
+
+ALTER TABLE auth_users DROP COLUMN IF EXISTS disabled_at;
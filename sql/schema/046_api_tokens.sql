@@ -0,0 +1,25 @@
+-- +goose Up
+-- API tokens for scripts and integrations, in place of sharing the keys in
+-- API_KEYS. Tokens are looked up by their SHA-256; the plain token is only
+-- shown when it is created. prefix keeps its first characters so a token
+-- can be recognised in lists.
+CREATE TABLE api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    -- read, write or destructive; each includes the ones before it
+    scope TEXT NOT NULL,
+    -- Tables the token may use; empty for all
+    table_keys TEXT[] NOT NULL DEFAULT '{}',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    CONSTRAINT api_tokens_hash_unique UNIQUE (token_hash),
+    CONSTRAINT api_tokens_scope_check CHECK (scope IN ('read', 'write', 'destructive'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_tokens;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Custom table actions are audited when they finish
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release',
+        'user_create', 'user_disable', 'login_failure',
+        'table_action'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing table_action entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release',
+        'user_create', 'user_disable', 'login_failure'
+    )) NOT VALID;
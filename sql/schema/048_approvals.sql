@@ -0,0 +1,83 @@
+-- +goose Up
+-- Pending approvals of large resets and rollbacks. A request over
+-- APPROVAL_ROW_THRESHOLD rows is recorded here instead of run, and runs
+-- once a second user approves it. Decided approvals are kept.
+CREATE TABLE approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    -- reset, reset_all, rollback, rollback_range or rollback_batch
+    kind TEXT NOT NULL,
+    table_key TEXT NOT NULL DEFAULT '',
+    -- Upload or batch ID for rollbacks
+    target TEXT NOT NULL DEFAULT '',
+    params JSONB NOT NULL DEFAULT '{}',
+    -- Rows the operation would remove when requested
+    row_count BIGINT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    -- user:<id>, token:<id> or key:<hash>; empty for an unidentified caller
+    requested_by_id TEXT NOT NULL DEFAULT '',
+    requested_by TEXT NOT NULL DEFAULT '',
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    decided_by_id TEXT NOT NULL DEFAULT '',
+    decided_by TEXT NOT NULL DEFAULT '',
+    decided_at TIMESTAMPTZ,
+    note TEXT NOT NULL DEFAULT '',
+    -- Set when the approved operation runs
+    operation_id TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    CONSTRAINT approvals_status_check CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX idx_approvals_pending ON approvals(requested_at DESC) WHERE status = 'pending';
+
+-- The user who created a token, or who owned the token it was created
+-- with; NULL for tokens created with a key from API_KEYS. Approvals treat
+-- a token as its owner, so nobody approves their own request with one.
+ALTER TABLE api_tokens ADD COLUMN owner_id UUID REFERENCES auth_users(id);
+
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release',
+        'user_create', 'user_disable', 'login_failure',
+        'table_action',
+        'approval_request', 'approval_grant', 'approval_reject'
+    ));
+
+-- +goose Down
+-- NOT VALID keeps existing approval entries rather than deleting audit history
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_action_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_action_check
+    CHECK (action IN (
+        'upload', 'upload_rollback',
+        'cell_edit', 'bulk_edit',
+        'row_delete', 'row_restore',
+        'table_reset',
+        'template_create', 'template_update', 'template_delete',
+        'table_config',
+        'auth_lockout', 'auth_unlock',
+        'audit_import',
+        'column_backfill',
+        'upload_review',
+        'data_export',
+        'row_purge',
+        'access_denied', 'request_rejected',
+        'legal_hold_set', 'legal_hold_release',
+        'user_create', 'user_disable', 'login_failure',
+        'table_action'
+    )) NOT VALID;
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS approvals;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Aggregates-only access: users and API tokens that may query grouped
+-- aggregations and histograms but never row-level data
+ALTER TABLE auth_users ADD COLUMN aggregates_only BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE api_tokens DROP CONSTRAINT IF EXISTS api_tokens_scope_check;
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_scope_check
+    CHECK (scope IN ('aggregate', 'read', 'write', 'destructive'));
+
+-- +goose Down
+ALTER TABLE api_tokens DROP CONSTRAINT IF EXISTS api_tokens_scope_check;
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_scope_check
+    CHECK (scope IN ('read', 'write', 'destructive')) NOT VALID;
+
+ALTER TABLE auth_users DROP COLUMN IF EXISTS aggregates_only;
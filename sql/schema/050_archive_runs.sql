@@ -0,0 +1,27 @@
+-- +goose Up
+-- Runs of the archive scheduler and what each did, so the scheduler can
+-- catch up on runs missed while the server was down and admins can see
+-- when it last ran. id is the run's operation ID.
+CREATE TABLE archive_runs (
+    id UUID PRIMARY KEY,
+    -- schedule, catch_up or manual
+    trigger TEXT NOT NULL,
+    initiator TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    duration_ms BIGINT,
+    entries_archived BIGINT NOT NULL DEFAULT 0,
+    entries_purged BIGINT NOT NULL DEFAULT 0,
+    failed_rows_compacted BIGINT NOT NULL DEFAULT 0,
+    recycled_rows_purged BIGINT NOT NULL DEFAULT 0,
+    archives_expired BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    -- Cancelled or cut short by a shutdown; not counted as the last run
+    interrupted BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT archive_runs_trigger_check CHECK (trigger IN ('schedule', 'catch_up', 'manual'))
+);
+
+CREATE INDEX idx_archive_runs_started ON archive_runs(started_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS archive_runs;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A row's history is read by its key, from both the live and the archived
+-- audit log.
+CREATE INDEX IF NOT EXISTS idx_audit_log_row ON audit_log(table_key, row_key, created_at)
+    WHERE row_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_archive_row ON audit_log_archive(table_key, row_key, created_at)
+    WHERE row_key IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_archive_row;
+DROP INDEX IF EXISTS idx_audit_log_row;
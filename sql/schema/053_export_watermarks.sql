@@ -0,0 +1,11 @@
+-- +goose Up
+-- Watermarked exports are traced by the export ID in their audit entry,
+-- live or archived.
+CREATE INDEX IF NOT EXISTS idx_audit_log_watermark ON audit_log((row_data->>'watermark'))
+    WHERE action = 'data_export';
+CREATE INDEX IF NOT EXISTS idx_audit_archive_watermark ON audit_log_archive((row_data->>'watermark'))
+    WHERE action = 'data_export';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_archive_watermark;
+DROP INDEX IF EXISTS idx_audit_log_watermark;